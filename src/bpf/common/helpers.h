@@ -178,6 +178,32 @@ static __always_inline __u8 extract_tcp_flags(struct tcphdr *tcp)
     return flags;
 }
 
+/* ===== TLS ClientHello detection =====
+ * Whether the TCP payload opens a TLS record carrying a ClientHello:
+ * record type handshake, version 3.x, then handshake type ClientHello.
+ */
+
+#define TLS_CONTENT_HANDSHAKE  0x16
+#define TLS_HANDSHAKE_HELLO    0x01
+
+static __always_inline int is_tls_client_hello(struct xdp_md *ctx,
+                                               struct packet_ctx *pkt)
+{
+    __u16 poff = pkt->payload_offset;
+    if (poff == 0 || poff > 1500 || pkt->l4_payload_len < 6)
+        return 0;
+
+    /* Re-derive fresh data/data_end from ctx (verifier trusts these) */
+    void *data = (void *)(long)ctx->data;
+    void *data_end = (void *)(long)ctx->data_end;
+    __u8 *p = (__u8 *)data + poff;
+    if (!bounds_check(p, 6, data_end))
+        return 0;
+
+    return p[0] == TLS_CONTENT_HANDSHAKE && p[1] == 0x03 &&
+           p[5] == TLS_HANDSHAKE_HELLO;
+}
+
 /* ===== Token bucket rate limiter ===== */
 
 static __always_inline int token_bucket_consume(struct rate_limiter *rl,
@@ -222,6 +248,21 @@ static __always_inline __u64 get_config(__u32 key)
     return *val;
 }
 
+/* ===== Escalation level for a packet =====
+ * The highest of the global level, the minimum level of the destination's
+ * protected prefix policy and the level the prefix escalated to on its own.
+ */
+static __always_inline __u64 escalation_level(struct packet_ctx *pkt)
+{
+    __u64 level = get_config(CFG_ESCALATION_LEVEL);
+    struct dst_policy *dp = pkt->dst_policy;
+    if (dp && dp->min_level > level)
+        level = dp->min_level;
+    if (pkt->dst_level > level)
+        level = pkt->dst_level;
+    return level;
+}
+
 /* ===== Event emission ===== */
 
 static __always_inline void emit_event(struct packet_ctx *pkt,
@@ -233,9 +274,18 @@ static __always_inline void emit_event(struct packet_ctx *pkt,
 {
     struct event *e;
 
+    /* Remembered even if the ring buffer is full, for dst_stats */
+    pkt->drop_reason = drop_reason;
+
     e = bpf_ringbuf_reserve(&events, sizeof(*e), 0);
-    if (!e)
+    if (!e) {
+        /* Counted so userspace knows its view of events is incomplete */
+        __u32 key = 0;
+        struct global_stats *s = bpf_map_lookup_elem(&stats_map, &key);
+        if (s)
+            s->events_lost++;
         return;
+    }
 
     e->timestamp_ns = bpf_ktime_get_ns();
     e->src_ip = pkt->src_ip;
@@ -269,6 +319,29 @@ static __always_inline void stats_rx(struct global_stats *s,
     }
 }
 
+/* Per-protocol RX accounting. DNS counts UDP to or from port 53. */
+static __always_inline void stats_rx_proto(struct global_stats *s,
+                                            struct packet_ctx *pkt)
+{
+    if (!s)
+        return;
+
+    switch (pkt->ip_proto) {
+    case IPPROTO_TCP:
+        if ((pkt->tcp_flags & (TCP_FLAG_SYN | TCP_FLAG_ACK)) == TCP_FLAG_SYN)
+            s->rx_tcp_syn_packets++;
+        break;
+    case IPPROTO_UDP:
+        s->rx_udp_packets++;
+        if (pkt->dst_port == bpf_htons(53) || pkt->src_port == bpf_htons(53))
+            s->rx_dns_packets++;
+        break;
+    case IPPROTO_ICMP:
+        s->rx_icmp_packets++;
+        break;
+    }
+}
+
 static __always_inline void stats_drop(struct global_stats *s,
                                         __u16 pkt_len)
 {
@@ -287,4 +360,68 @@ static __always_inline void stats_tx(struct global_stats *s,
     }
 }
 
+/* Account a packet and its final XDP action against its source IP. */
+static __always_inline void talker_account(const struct packet_ctx *pkt,
+                                            int action, __u64 now_ns)
+{
+    struct talker_stats *t = bpf_map_lookup_elem(&top_talkers, &pkt->src_ip);
+    if (!t) {
+        struct talker_stats init = {};
+        bpf_map_update_elem(&top_talkers, &pkt->src_ip, &init, BPF_NOEXIST);
+        t = bpf_map_lookup_elem(&top_talkers, &pkt->src_ip);
+        if (!t)
+            return;
+    }
+
+    t->packets++;
+    t->bytes += pkt->pkt_len;
+    if (action == XDP_DROP) {
+        t->dropped_packets++;
+        t->dropped_bytes += pkt->pkt_len;
+    }
+    t->last_seen_ns = now_ns;
+}
+
+/* Count the packet against its destination, by drop reason if dropped. */
+static __always_inline void dst_stats_account(const struct packet_ctx *pkt,
+                                              int action)
+{
+    struct dst_stats *d = bpf_map_lookup_elem(&dst_stats, &pkt->dst_ip);
+    if (!d) {
+        struct dst_stats init = {};
+        bpf_map_update_elem(&dst_stats, &pkt->dst_ip, &init, BPF_NOEXIST);
+        d = bpf_map_lookup_elem(&dst_stats, &pkt->dst_ip);
+        if (!d)
+            return;
+    }
+
+    d->rx_packets++;
+    d->rx_bytes += pkt->pkt_len;
+    if (action == XDP_DROP) {
+        __u32 reason = pkt->drop_reason;
+
+        d->dropped_packets++;
+        d->dropped_bytes += pkt->pkt_len;
+        if (reason < DROP_REASON_MAX)
+            d->drop_reasons[reason]++;
+    }
+}
+
+/* Count the packet against the protected prefix it is addressed to, so
+ * per-asset traffic can be reported to the prefix's tenant. */
+static __always_inline void dst_policy_account(const struct packet_ctx *pkt,
+                                               int action)
+{
+    struct dst_policy *p = pkt->dst_policy;
+    if (!p)
+        return;
+
+    __sync_fetch_and_add(&p->rx_packets, 1);
+    __sync_fetch_and_add(&p->rx_bytes, pkt->pkt_len);
+    if (action == XDP_DROP) {
+        __sync_fetch_and_add(&p->dropped_packets, 1);
+        __sync_fetch_and_add(&p->dropped_bytes, pkt->pkt_len);
+    }
+}
+
 #endif /* __HELPERS_H__ */
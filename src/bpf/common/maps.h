@@ -17,28 +17,49 @@ struct {
 
 /* ===== Blacklist (IPv4 CIDR) =====
  * LPM trie for source IP blacklisting.
- * Value: drop reason / attack type hint.
+ * Value: drop reason / attack type hint and hit counters.
  */
 struct {
     __uint(type, BPF_MAP_TYPE_LPM_TRIE);
     __uint(max_entries, 100000);
     __uint(map_flags, BPF_F_NO_PREALLOC);
     __type(key, struct lpm_key_v4);
-    __type(value, __u32);
+    __type(value, struct acl_entry);
 } blacklist_v4 SEC(".maps");
 
 /* ===== Whitelist (IPv4 CIDR) =====
  * LPM trie for source IP whitelisting.
- * Value: 1 = pass unconditionally.
+ * Value: reason 1 = pass unconditionally, and hit counters.
  */
 struct {
     __uint(type, BPF_MAP_TYPE_LPM_TRIE);
     __uint(max_entries, 100000);
     __uint(map_flags, BPF_F_NO_PREALLOC);
     __type(key, struct lpm_key_v4);
-    __type(value, __u32);
+    __type(value, struct acl_entry);
 } whitelist_v4 SEC(".maps");
 
+/* ===== Tenant Whitelist / Blacklist =====
+ * LPM tries of source prefixes per tenant list (dst_policy acl_id). They
+ * apply only to traffic towards the prefixes of the tenant's assets, after
+ * the global lists.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 16384);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, struct tenant_acl_key);
+    __type(value, struct acl_entry);
+} tenant_whitelist SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 16384);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, struct tenant_acl_key);
+    __type(value, struct acl_entry);
+} tenant_blacklist SEC(".maps");
+
 /* ===== Per-Source Rate Limiter =====
  * LRU hash keyed by source IP, per-CPU for lock-free operation.
  * Each entry is a token bucket.
@@ -71,6 +92,116 @@ struct {
     __type(value, struct syn_cookie_ctx);
 } syn_cookie_map SEC(".maps");
 
+/* ===== SYN Cookie Protected Destinations =====
+ * LPM trie of destination prefixes that get SYN cookies while
+ * CFG_SYN_COOKIE_SCOPED is set. Value: 1 = protected.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 1024);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, struct lpm_key_v4);
+    __type(value, __u8);
+} syn_cookie_dst SEC(".maps");
+
+/* ===== Protected Prefix Policies =====
+ * LPM trie of protected destination prefixes -> per-prefix rate limits
+ * and minimum escalation level, written by the control plane's asset
+ * registry.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 1024);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, struct lpm_key_v4);
+    __type(value, struct dst_policy);
+} dst_policy_map SEC(".maps");
+
+/* ===== Per-Prefix Escalation =====
+ * LPM trie of protected destination prefixes -> the escalation level the
+ * control plane evaluated from the prefix's own traffic. Only prefixes
+ * above LOW have an entry.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 1024);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, struct lpm_key_v4);
+    __type(value, __u32);
+} dst_escalation SEC(".maps");
+
+/* ===== Rate Classes =====
+ * rate_classes: class id -> rate limits, written by the control plane.
+ * rate_class_src / rate_class_dst: source and destination prefixes ->
+ * class id. A source binding wins over a destination binding.
+ * rate_class_bucket: per-CPU pps and bps token buckets of each class,
+ * created on the first packet.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, RATE_CLASS_MAX);
+    __type(key, __u32);
+    __type(value, struct rate_class);
+} rate_classes SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 1024);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, struct lpm_key_v4);
+    __type(value, __u32);
+} rate_class_src SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 1024);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, struct lpm_key_v4);
+    __type(value, __u32);
+} rate_class_dst SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_HASH);
+    __uint(max_entries, RATE_CLASS_MAX * 2);
+    __type(key, __u32);
+    __type(value, struct rate_limiter);
+} rate_class_bucket SEC(".maps");
+
+/* ===== Strict TCP State Destinations =====
+ * Destination IP -> bpf_ktime_get_ns until which TCP towards it is
+ * validated strictly, whatever CFG_TCP_STATE_ENABLE and the escalation
+ * level say. Written by the control plane for destinations under an
+ * HTTP flood.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 4096);
+    __type(key, __be32);
+    __type(value, __u64);
+} tcp_strict_dst SEC(".maps");
+
+/* ===== Per-Source Connection Limits =====
+ * conn_limit_dst: destination prefix -> concurrent TCP connections a
+ * single source may hold to it, overriding CFG_CONN_LIMIT.
+ * conn_count: source IP -> open TCP connections. Incremented by conntrack
+ * on new connections and decremented when they close; the control plane
+ * recounts it from conntrack_map to correct for evicted entries.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 1024);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, struct lpm_key_v4);
+    __type(value, __u32);
+} conn_limit_dst SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(max_entries, 262144);
+    __type(key, __be32);
+    __type(value, __u32);
+} conn_count SEC(".maps");
+
 /* ===== Attack Signatures =====
  * Array of up to 256 attack fingerprint rules.
  * Control plane populates from threat intel feeds.
@@ -92,6 +223,17 @@ struct {
     __type(value, __u32);
 } attack_sig_count SEC(".maps");
 
+/* ===== Attack Signature Hit Counters (per-CPU) =====
+ * Indexed like attack_sig_map. Incremented on every match so the
+ * control plane can tell which signatures are currently active.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, 256);
+    __type(key, __u32);
+    __type(value, __u64);
+} attack_sig_hits SEC(".maps");
+
 /* ===== Global Statistics (per-CPU) =====
  * Single-entry per-CPU array for lock-free stats aggregation.
  */
@@ -111,6 +253,17 @@ struct {
     __uint(max_entries, 16 * 1024 * 1024);
 } events SEC(".maps");
 
+/* ===== Packet Capture =====
+ * Perf event array carrying sampled packets (struct capture_meta followed
+ * by up to CFG_CAPTURE_SNAPLEN bytes). Perf output can copy straight from
+ * the XDP buffer, which a ring buffer reservation cannot.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+    __uint(key_size, sizeof(__u32));
+    __uint(value_size, sizeof(__u32));
+} capture_events SEC(".maps");
+
 /* ===== Global Rate Limiter =====
  * Per-CPU array for aggregate PPS/BPS tracking.
  * Index 0: PPS counter, Index 1: BPS counter.
@@ -173,6 +326,73 @@ struct {
     __type(value, __u8);
 } geoip_policy SEC(".maps");
 
+/* ===== GeoIP Country Allow-Lists =====
+ * Hash map: (allow-list id, country_code) -> 1. Protected prefixes whose
+ * dst_policy names an allow-list accept traffic only from its countries.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 8192);
+    __type(key, struct geoip_allow_key);
+    __type(value, __u8);
+} geoip_allow SEC(".maps");
+
+/* ===== GeoIP Country Rate Limits =====
+ * Hash map: country_code(u16) → pps for countries with a RATE_LIMIT
+ * policy. Buckets are per CPU, so the control plane writes each CPU's
+ * share of the country limit. Countries without an entry fall back to
+ * halving the per-source rate.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 512);
+    __type(key, __u16);
+    __type(value, __u64);
+} geoip_country_rate SEC(".maps");
+
+/* ===== GeoIP Country Token Buckets =====
+ * Per-CPU hash: country_code(u16) → token bucket, created on first packet.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_HASH);
+    __uint(max_entries, 512);
+    __type(key, __u16);
+    __type(value, struct rate_limiter);
+} geoip_country_bucket SEC(".maps");
+
+/* ===== GeoIP Country Stats =====
+ * Per-CPU hash: country_code(u16) → counters, read by the control plane.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_HASH);
+    __uint(max_entries, 512);
+    __type(key, __u16);
+    __type(value, struct country_stats);
+} geoip_country_stats SEC(".maps");
+
+/* ===== ASN Database (IPv4 CIDR → origin ASN) =====
+ * LPM trie mapping announced prefixes to their origin AS.
+ * Populated by control plane from GeoLite2-ASN or prefix/ASN text data.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 1000000);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, struct lpm_key_v4);
+    __type(value, struct asn_entry);
+} asn_map SEC(".maps");
+
+/* ===== ASN Policy =====
+ * Hash map: asn(u32) → action(u8), using the GEOIP_ACTION_* values.
+ * Control plane sets per-ASN policy.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, 4096);
+    __type(key, __u32);
+    __type(value, __u8);
+} asn_policy SEC(".maps");
+
 /* ===== IP Reputation Tracking =====
  * LRU hash keyed by source IP for dynamic reputation scoring.
  * Entries created on first seen, score increases on violations.
@@ -217,16 +437,40 @@ struct {
     __type(value, struct threat_intel_entry);
 } threat_intel_map SEC(".maps");
 
+/* ===== Threat Intelligence Feed Counters =====
+ * Per-CPU array: threat_intel_entry.source_id → counters, read by the
+ * control plane to show which feeds actually stop packets.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, THREAT_INTEL_FEEDS_MAX);
+    __type(key, __u32);
+    __type(value, struct threat_intel_feed_stats);
+} threat_intel_stats SEC(".maps");
+
 /* ===== Port Scan Detection =====
- * LRU hash keyed by source IP, tracking distinct ports accessed.
+ * LRU hash keyed by source IP, tracking distinct ports accessed. Shared
+ * across CPUs: RSS spreads one scanner's ports over every queue.
  */
 struct {
-    __uint(type, BPF_MAP_TYPE_LRU_PERCPU_HASH);
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
     __uint(max_entries, 500000);
     __type(key, __be32);
     __type(value, struct port_scan_entry);
 } port_scan_map SEC(".maps");
 
+/* ===== Handshake Flood Tracking =====
+ * LRU hash keyed by source IP and handshake kind: TLS ClientHellos and
+ * QUIC Initials started by the source per second. Shared across CPUs like
+ * port_scan_map, since one source's connections spread over every queue.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(max_entries, 262144);
+    __type(key, struct handshake_key);
+    __type(value, struct handshake_rate);
+} handshake_rate SEC(".maps");
+
 /* ===== Adaptive Rate Limit Overrides =====
  * Hash map: source IP → per-IP override rate (set by anomaly detector).
  * If entry exists, overrides default rate_limit_map rate.
@@ -239,4 +483,103 @@ struct {
     __type(value, __u64);
 } adaptive_rate_map SEC(".maps");
 
+/* ===== Top Talkers =====
+ * LRU hash keyed by source IP with per-source packet/byte/drop counters.
+ * LRU eviction keeps the busiest sources resident; the control plane
+ * derives per-source rates over a sliding window from these totals.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_PERCPU_HASH);
+    __uint(max_entries, 100000);
+    __type(key, __be32);
+    __type(value, struct talker_stats);
+} top_talkers SEC(".maps");
+
+/* ===== Destination Statistics =====
+ * LRU hash keyed by destination IP with per-destination traffic and drop
+ * counters by reason. The control plane sums the destinations of a prefix
+ * to report per-victim stats.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_PERCPU_HASH);
+    __uint(max_entries, 65536);
+    __type(key, __be32);
+    __type(value, struct dst_stats);
+} dst_stats SEC(".maps");
+
+/* ===== Egress Scrubbing =====
+ * Maps of the TC egress program, which stops the protected network from
+ * sending spoofed or reflected traffic.
+ * egress_src_allow: prefixes the network may send from; any other source
+ * leaving the interface is spoofed.
+ * egress_amp_ports: UDP source port of an amplification-prone service ->
+ * responses per second a single destination may receive (0 = none).
+ * egress_amp_bucket: per-CPU token bucket per destination and port.
+ * egress_stats: per-CPU counters of the egress program.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_LPM_TRIE);
+    __uint(max_entries, 1024);
+    __uint(map_flags, BPF_F_NO_PREALLOC);
+    __type(key, struct lpm_key_v4);
+    __type(value, __u8);
+} egress_src_allow SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, EGRESS_AMP_PORTS_MAX);
+    __type(key, __u16);
+    __type(value, __u64);
+} egress_amp_ports SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_PERCPU_HASH);
+    __uint(max_entries, 65536);
+    __type(key, struct egress_amp_key);
+    __type(value, struct rate_limiter);
+} egress_amp_bucket SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, struct egress_stats);
+} egress_stats SEC(".maps");
+
+/* ===== Deep Inspection =====
+ * xsks_map: receive queue -> AF_XDP socket of the userspace inspector.
+ * inspect_ports: destination port (host order) -> INSPECT_DNS or
+ * INSPECT_TLS.
+ * inspect_cleared: sources the inspector found clean -> bpf_ktime_get_ns
+ * until which they are not redirected again.
+ * inspect_stats: per-CPU redirect counters.
+ */
+struct {
+    __uint(type, BPF_MAP_TYPE_XSKMAP);
+    __uint(max_entries, INSPECT_QUEUES_MAX);
+    __type(key, __u32);
+    __type(value, __u32);
+} xsks_map SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_HASH);
+    __uint(max_entries, INSPECT_PORTS_MAX);
+    __type(key, __u16);
+    __type(value, __u8);
+} inspect_ports SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __uint(max_entries, 65536);
+    __type(key, __be32);
+    __type(value, __u64);
+} inspect_cleared SEC(".maps");
+
+struct {
+    __uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+    __uint(max_entries, 1);
+    __type(key, __u32);
+    __type(value, struct inspect_stats);
+} inspect_stats SEC(".maps");
+
 #endif /* __MAPS_H__ */
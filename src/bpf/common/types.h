@@ -43,6 +43,11 @@
 #define ATTACK_PROTO_VIOLATION  13
 #define ATTACK_PAYLOAD_MATCH   14
 #define ATTACK_THREAT_INTEL    15
+#define ATTACK_ASN_BLOCK       16
+#define ATTACK_CONN_FLOOD      17
+#define ATTACK_PORT_SCAN       18
+#define ATTACK_TLS_FLOOD       19
+#define ATTACK_QUIC_FLOOD      20
 
 /* ===== Drop reason codes ===== */
 #define DROP_BLACKLIST          1
@@ -65,6 +70,12 @@
 #define DROP_TCP_STATE          18
 #define DROP_THREAT_INTEL      19
 #define DROP_ESCALATION        20
+#define DROP_ASN               21
+#define DROP_GEOIP_RATE        22
+#define DROP_CONN_LIMIT        23
+#define DROP_TLS_FLOOD         24
+#define DROP_QUIC_FLOOD        25
+#define DROP_REASON_MAX        26  /* Size of per-reason counter arrays */
 
 /* ===== Configuration keys (config map indices) ===== */
 #define CFG_ENABLED             0   /* Global enable/disable */
@@ -88,8 +99,38 @@
 #define CFG_DNS_VALID_MODE     18   /* DNS validation mode: 0=off, 1=basic, 2=strict */
 #define CFG_TCP_STATE_ENABLE   19   /* TCP state machine validation enable */
 #define CFG_ADAPTIVE_RATE      20   /* Adaptive rate limiting enable */
+#define CFG_CAPTURE_MODE       21   /* Packet capture: 0=off, 1=drops, 2=all */
+#define CFG_CAPTURE_SAMPLE     22   /* Capture 1 in N packets (0/1 = every packet) */
+#define CFG_CAPTURE_SNAPLEN    23   /* Bytes captured per packet */
+#define CFG_ASN_ENABLE         24   /* ASN policy enforcement enable */
+#define CFG_SYN_COOKIE_SCOPED  25   /* SYN cookies only for syn_cookie_dst prefixes */
+#define CFG_DIVERSION_ENABLE   26   /* Re-inject clean traffic over gre_tunnels */
+#define CFG_GRE_LOCAL_IP       27   /* Outer source address of re-injected packets (BE) */
+#define CFG_CONN_LIMIT         28   /* Default concurrent TCP connections per source (0 = none) */
+#define CFG_PORT_SCAN_ACTION   29   /* Port scan response: 0=off, 1=score boost, 2=temp block, 3=event only */
+#define CFG_PORT_SCAN_THRESH   30   /* Distinct destination ports per window (0 = 20) */
+#define CFG_PORT_SCAN_WINDOW   31   /* Port scan window in seconds (0 = 10) */
+#define CFG_EGRESS_SPOOF       32   /* Egress: drop sources outside egress_src_allow */
+#define CFG_EGRESS_AMP         33   /* Egress: limit UDP responses from egress_amp_ports */
+#define CFG_INSPECT_ENABLE     34   /* Redirect selected traffic to AF_XDP deep inspection */
+#define CFG_INSPECT_SAMPLE     35   /* Inspect 1 in N selected packets (0/1 = every packet) */
+#define CFG_INSPECT_SCORE      36   /* Always inspect sources scoring this much (0 = never) */
+#define CFG_TLS_HS_RATE        37   /* TLS ClientHellos per second per source (0 = no limit) */
+#define CFG_QUIC_INIT_RATE     38   /* QUIC Initial packets per second per source (0 = no limit) */
 #define CFG_MAX                64
 
+/* ===== Packet capture modes ===== */
+#define CAPTURE_OFF            0
+#define CAPTURE_DROPS          1   /* Only packets the pipeline dropped */
+#define CAPTURE_ALL            2   /* Every packet (suspect traffic during attacks) */
+#define CAPTURE_SNAPLEN_MAX    1518
+
+/* ===== Port scan responses ===== */
+#define PORT_SCAN_OFF          0
+#define PORT_SCAN_SCORE        1   /* Add REP_WEIGHT_PORT_SCAN to the reputation score */
+#define PORT_SCAN_BLOCK        2   /* Event only; the control plane blacklists the source */
+#define PORT_SCAN_EVENT        3   /* Event only */
+
 /* ===== Escalation Levels ===== */
 #define ESCALATION_LOW          0   /* Normal: observe, baseline learning */
 #define ESCALATION_MEDIUM       1   /* Rate limiting active, loose thresholds */
@@ -195,6 +236,49 @@ struct packet_ctx {
 
     /* First 4 bytes of L4 payload as uint32, for fingerprint hash */
     __u32 l4_payload_hash4;
+
+    /* Protected prefix policy covering dst_ip, or NULL */
+    struct dst_policy *dst_policy;
+
+    /* Escalation level of the prefix covering dst_ip (dst_escalation) */
+    __u8 dst_level;
+
+    /* Reason of the last event emitted for the packet (DROP_*) */
+    __u8 drop_reason;
+};
+
+/* ===== Protected prefix policy (dst_policy_map value) ===== */
+struct dst_policy {
+    __u64 syn_rate_pps;    /* Per-source limits towards the prefix; 0 = CFG_*_RATE_PPS */
+    __u64 udp_rate_pps;
+    __u64 icmp_rate_pps;
+    __u32 min_level;       /* Escalation level the prefix is held at, at least */
+    __u16 geo_allow_id;    /* Country allow-list in geoip_allow; 0 = none */
+    __u8  geo_allow_action; /* GEOIP_ACTION_* for countries not on the list */
+    __u8  pad;
+    __u32 acl_id;          /* Owner's lists in tenant_whitelist/blacklist; 0 = none */
+    __u32 pad2;
+    /* Traffic towards the prefix, shared by all CPUs (atomic adds) */
+    __u64 rx_packets;
+    __u64 rx_bytes;
+    __u64 dropped_packets;
+    __u64 dropped_bytes;
+};
+
+/* ===== Rate class (rate_classes value) =====
+ * Named set of rate limits applied to the sources and destinations bound
+ * to the class in rate_class_src and rate_class_dst.
+ */
+#define RATE_CLASS_MAX          64
+#define RATE_CLASS_BUCKET_PPS   0   /* rate_class_bucket key: class id * 2 + kind */
+#define RATE_CLASS_BUCKET_BPS   1
+
+struct rate_class {
+    __u64 syn_rate_pps;    /* Per-source limits; 0 = CFG_*_RATE_PPS */
+    __u64 udp_rate_pps;
+    __u64 icmp_rate_pps;
+    __u64 pps;             /* Limits on all traffic in the class; 0 = none */
+    __u64 bps;
 };
 
 /* ===== Rate limiter entry (per-CPU) ===== */
@@ -238,6 +322,27 @@ struct geoip_entry {
     __u8  pad;
 };
 
+/* ===== Country allow-list key (geoip_allow) =====
+ * A protected prefix's allow-list id and a packed country code.
+ */
+struct geoip_allow_key {
+    __u16 id;
+    __u16 country_code;
+};
+
+/* ===== Per-country counters (per-CPU) ===== */
+struct country_stats {
+    __u64 packets;        /* Packets from the country reaching geoip_check */
+    __u64 dropped;        /* Dropped by a DROP policy */
+    __u64 rate_limited;   /* Dropped by the country token bucket */
+    __u64 monitored;      /* Passed under a MONITOR policy */
+};
+
+/* ===== ASN LPM entry ===== */
+struct asn_entry {
+    __u32 asn;            /* Origin AS number; asn_policy values are GEOIP_ACTION_* */
+};
+
 /* ===== IP Reputation entry ===== */
 struct ip_reputation {
     __u32 score;             /* 0 = clean, higher = worse, 1000 = blocked */
@@ -327,6 +432,19 @@ struct global_stats {
     __u64 ntp_monlist_blocked;
     __u64 tcp_state_violations;
     __u64 port_scan_detected;
+    /* Per-protocol RX counters (feed per-protocol baselines) */
+    __u64 rx_tcp_syn_packets;
+    __u64 rx_udp_packets;
+    __u64 rx_icmp_packets;
+    __u64 rx_dns_packets;
+    __u64 asn_dropped;
+    __u64 conn_limit_dropped;
+    __u64 events_lost;          /* Events not emitted: ring buffer full */
+    /* Handshake floods */
+    __u64 tls_handshakes;       /* TLS ClientHellos seen */
+    __u64 quic_initials;        /* QUIC Initial packets seen */
+    __u64 tls_flood_dropped;
+    __u64 quic_flood_dropped;
 };
 
 /* ===== LPM trie key for CIDR matching ===== */
@@ -335,6 +453,27 @@ struct lpm_key_v4 {
     __be32 addr;
 };
 
+/* ===== Tenant ACL key (tenant_whitelist / tenant_blacklist) =====
+ * The list id is matched in full, so prefixlen is 32 plus the length of
+ * the source prefix.
+ */
+struct tenant_acl_key {
+    __u32 prefixlen;
+    __u32 acl_id;
+    __be32 addr;
+};
+
+/* ===== Blacklist/whitelist entry =====
+ * Hits and last_hit_ns are updated by acl_check on every match and kept by
+ * the control plane when it rewrites an entry.
+ */
+struct acl_entry {
+    __u32 reason;         /* Drop reason (blacklist) or 1 (whitelist) */
+    __u32 pad;
+    __u64 hits;           /* Packets matched */
+    __u64 last_hit_ns;    /* bpf_ktime_get_ns() of the last match, 0 = never */
+};
+
 /* ===== Event sent to userspace via ring buffer ===== */
 struct event {
     __u64 timestamp_ns;
@@ -385,11 +524,101 @@ struct threat_intel_entry {
     __u32 last_updated;   /* Unix timestamp of last update */
 };
 
+/* ===== Per-feed threat intel counters (per-CPU, by source_id) ===== */
+#define THREAT_INTEL_FEEDS_MAX 256
+
+struct threat_intel_feed_stats {
+    __u64 matched;        /* Packets whose source the feed lists */
+    __u64 dropped;        /* Dropped by a drop entry */
+    __u64 rate_limited;   /* Sources put under the stricter rate limit */
+    __u64 monitored;      /* Passed under a monitor entry */
+};
+
+/* ===== Packet capture metadata =====
+ * Prepended to each sampled packet on capture_events; the packet bytes
+ * follow immediately.
+ */
+struct capture_meta {
+    __u64 timestamp_ns;
+    __u32 pkt_len;         /* Length on the wire */
+    __u32 cap_len;         /* Bytes of packet data that follow */
+    __u32 action;          /* XDP action taken */
+    __u32 pad;
+};
+
+/* ===== Per-source traffic counters (top talkers) ===== */
+struct talker_stats {
+    __u64 packets;
+    __u64 bytes;
+    __u64 dropped_packets;
+    __u64 dropped_bytes;
+    __u64 last_seen_ns;
+};
+
+/* ===== Per-destination statistics (per-CPU) ===== */
+struct dst_stats {
+    __u64 rx_packets;
+    __u64 rx_bytes;
+    __u64 dropped_packets;
+    __u64 dropped_bytes;
+    __u64 drop_reasons[DROP_REASON_MAX];  /* Dropped packets by DROP_* */
+};
+
+/* ===== Egress scrubbing (TC egress program) ===== */
+#define EGRESS_AMP_PORTS_MAX   64
+
+struct egress_amp_key {
+    __be32 dst_ip;
+    __u16  port;           /* UDP source port, host order */
+    __u16  pad;
+};
+
+struct egress_stats {
+    __u64 packets;
+    __u64 bytes;
+    __u64 spoofed_dropped;
+    __u64 amp_dropped;
+    __u64 dropped_bytes;
+};
+
+/* ===== Deep inspection (AF_XDP) ===== */
+#define INSPECT_QUEUES_MAX     64
+#define INSPECT_PORTS_MAX      64
+
+/* inspect_ports values: what the inspector parses on the port */
+#define INSPECT_DNS            1   /* UDP: DNS queries */
+#define INSPECT_TLS            2   /* TCP: TLS ClientHellos */
+
+struct inspect_stats {
+    __u64 redirected;      /* Packets handed to an inspector socket */
+    __u64 no_socket;       /* Selected, but no socket on the receive queue */
+};
+
+/* ===== Handshake flood tracking (handshake_rate) ===== */
+#define HANDSHAKE_TLS          0
+#define HANDSHAKE_QUIC         1
+
+struct handshake_key {
+    __be32 src_ip;
+    __u8   kind;           /* HANDSHAKE_* */
+    __u8   pad[3];
+};
+
+struct handshake_rate {
+    __u64 window_start_ns; /* Start of the current one-second window */
+    __u64 last_drop_ns;    /* Last packet dropped over the limit (0 = never) */
+    __u64 dropped;         /* Packets dropped over the limit */
+    __u32 count;           /* Handshakes in the current window */
+    __u32 peak;            /* Most handshakes in any window */
+};
+
 /* ===== Port scan tracking entry ===== */
 struct port_scan_entry {
     __u64 window_start_ns;
-    __u32 distinct_ports;
-    __u32 port_bitmap[2]; /* Quick 64-bit bitmap for first 64 ports */
+    __u64 detected_ns;    /* Last time the threshold was crossed (0 = never) */
+    __u32 distinct_ports; /* Distinct ports in the current window */
+    __u32 peak_ports;     /* Most distinct ports in any window */
+    __u64 port_bitmap[8]; /* Ports seen this window, hashed into 512 bits */
 };
 
 #endif /* __TYPES_H__ */
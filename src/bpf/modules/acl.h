@@ -8,13 +8,22 @@
 
 /* ===== ACL Module =====
  * Checks source IP against whitelist/blacklist LPM tries.
- * Whitelist takes priority over blacklist.
+ * Whitelist takes priority over blacklist. The global lists come first,
+ * then the lists of the tenant owning the destination, if any.
  *
  * Returns:
  *   VERDICT_PASS  - Whitelisted or not in blacklist
  *   VERDICT_DROP  - Blacklisted
  */
 
+/* Count a match against an entry. The trie is shared by all CPUs, so the
+ * hit count is bumped atomically; last_hit_ns is best-effort. */
+static __always_inline void acl_hit(struct acl_entry *e)
+{
+    __sync_fetch_and_add(&e->hits, 1);
+    e->last_hit_ns = bpf_ktime_get_ns();
+}
+
 static __always_inline int acl_check(struct packet_ctx *pkt,
                                       struct global_stats *stats)
 {
@@ -24,13 +33,41 @@ static __always_inline int acl_check(struct packet_ctx *pkt,
     };
 
     /* Whitelist check first — bypass entire pipeline */
-    __u32 *wl = bpf_map_lookup_elem(&whitelist_v4, &key);
-    if (wl)
+    struct acl_entry *wl = bpf_map_lookup_elem(&whitelist_v4, &key);
+    if (wl) {
+        acl_hit(wl);
         return VERDICT_BYPASS;
+    }
 
     /* Blacklist check */
-    __u32 *bl = bpf_map_lookup_elem(&blacklist_v4, &key);
+    struct acl_entry *bl = bpf_map_lookup_elem(&blacklist_v4, &key);
+    if (bl) {
+        acl_hit(bl);
+        if (stats)
+            stats->acl_dropped++;
+
+        emit_event(pkt, ATTACK_NONE, 1, DROP_BLACKLIST, 0, 0);
+        return VERDICT_DROP;
+    }
+
+    if (!pkt->dst_policy || !pkt->dst_policy->acl_id)
+        return VERDICT_PASS;
+
+    struct tenant_acl_key tkey = {
+        .prefixlen = 64,
+        .acl_id = pkt->dst_policy->acl_id,
+        .addr = pkt->src_ip,
+    };
+
+    wl = bpf_map_lookup_elem(&tenant_whitelist, &tkey);
+    if (wl) {
+        acl_hit(wl);
+        return VERDICT_BYPASS;
+    }
+
+    bl = bpf_map_lookup_elem(&tenant_blacklist, &tkey);
     if (bl) {
+        acl_hit(bl);
         if (stats)
             stats->acl_dropped++;
 
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: GPL-2.0
+#ifndef __MOD_ASN_H__
+#define __MOD_ASN_H__
+
+#include "../common/types.h"
+#include "../common/maps.h"
+#include "../common/helpers.h"
+
+/* ===== ASN Blocking Module =====
+ * Looks up the source IP in the ASN LPM trie to obtain its origin AS,
+ * then checks the per-ASN policy map for the configured action. Policies
+ * use the GeoIP actions, so whole networks (e.g. bulletproof hosters) can
+ * be dropped, rate-limited or monitored like countries.
+ *
+ * Unlike GeoIP, sources without an ASN mapping or policy always pass:
+ * unrouted space is not treated as hostile at any escalation level.
+ *
+ * Returns:
+ *   VERDICT_PASS - Allowed
+ *   VERDICT_DROP - Blocked by ASN policy
+ */
+
+/* Rate-limit divisor for sources in rate-limited ASNs */
+#define ASN_RATE_LIMIT_DIVISOR 2
+
+static __always_inline int asn_check(struct packet_ctx *pkt,
+                                      struct global_stats *stats)
+{
+    if (!get_config(CFG_ASN_ENABLE))
+        return VERDICT_PASS;
+
+    struct lpm_key_v4 lpm_key = {
+        .prefixlen = 32,
+        .addr = pkt->src_ip,
+    };
+
+    struct asn_entry *entry;
+    entry = bpf_map_lookup_elem(&asn_map, &lpm_key);
+    if (!entry)
+        return VERDICT_PASS;
+
+    __u32 asn = entry->asn;
+    __u8 *policy;
+    policy = bpf_map_lookup_elem(&asn_policy, &asn);
+    if (!policy)
+        return VERDICT_PASS;
+
+    switch (*policy) {
+    case GEOIP_ACTION_DROP:
+        if (stats)
+            stats->asn_dropped++;
+        emit_event(pkt, ATTACK_ASN_BLOCK, 1, DROP_ASN, 0, 0);
+        return VERDICT_DROP;
+
+    case GEOIP_ACTION_RATE_LIMIT: {
+        /* Install a stricter per-source rate; rate_limiter enforces it */
+        __u64 *existing_rate;
+        existing_rate = bpf_map_lookup_elem(&adaptive_rate_map, &pkt->src_ip);
+
+        if (!existing_rate) {
+            __u64 base_rate;
+            switch (pkt->ip_proto) {
+            case IPPROTO_TCP:
+                base_rate = get_config(CFG_SYN_RATE_PPS);
+                break;
+            case IPPROTO_UDP:
+                base_rate = get_config(CFG_UDP_RATE_PPS);
+                break;
+            case IPPROTO_ICMP:
+                base_rate = get_config(CFG_ICMP_RATE_PPS);
+                break;
+            default:
+                base_rate = get_config(CFG_GLOBAL_PPS_LIMIT);
+                break;
+            }
+
+            if (base_rate > 0) {
+                __u64 stricter_rate = base_rate / ASN_RATE_LIMIT_DIVISOR;
+                if (stricter_rate == 0)
+                    stricter_rate = 1;
+                bpf_map_update_elem(&adaptive_rate_map, &pkt->src_ip,
+                                    &stricter_rate, BPF_NOEXIST);
+            }
+        }
+        return VERDICT_PASS;
+    }
+
+    case GEOIP_ACTION_MONITOR:
+        emit_event(pkt, ATTACK_ASN_BLOCK, 0, 0, 0, 0);
+        return VERDICT_PASS;
+
+    default:
+        break;
+    }
+
+    return VERDICT_PASS;
+}
+
+#endif /* __MOD_ASN_H__ */
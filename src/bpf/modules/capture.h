@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: GPL-2.0
+#ifndef __MOD_CAPTURE_H__
+#define __MOD_CAPTURE_H__
+
+#include "../common/types.h"
+#include "../common/maps.h"
+#include "../common/helpers.h"
+
+/* ===== Packet Capture Module =====
+ *
+ * Samples packets onto capture_events for offline analysis. Runs after
+ * the verdict is known so it can select dropped packets only.
+ *
+ * The control plane turns capture on by setting CFG_CAPTURE_MODE and
+ * writes the samples to pcap files; with capture off the cost is a
+ * single config lookup.
+ */
+
+static __always_inline void capture_packet(struct xdp_md *ctx, int action,
+                                           __u64 now_ns)
+{
+    void *data = (void *)(long)ctx->data;
+    void *data_end = (void *)(long)ctx->data_end;
+    struct capture_meta meta = {};
+    __u64 mode, sample, snaplen, len;
+
+    mode = get_config(CFG_CAPTURE_MODE);
+    if (mode == CAPTURE_OFF)
+        return;
+    if (mode == CAPTURE_DROPS && action != XDP_DROP)
+        return;
+
+    sample = get_config(CFG_CAPTURE_SAMPLE);
+    if (sample > 1 && bpf_get_prandom_u32() % sample != 0)
+        return;
+
+    snaplen = get_config(CFG_CAPTURE_SNAPLEN);
+    if (snaplen == 0 || snaplen > CAPTURE_SNAPLEN_MAX)
+        snaplen = CAPTURE_SNAPLEN_MAX;
+
+    len = data_end - data;
+    if (len > snaplen)
+        len = snaplen;
+
+    meta.timestamp_ns = now_ns;
+    meta.pkt_len = data_end - data;
+    meta.cap_len = len;
+    meta.action = action;
+
+    /* The upper 32 bits of flags ask the kernel to append len bytes
+     * from the start of the XDP buffer. */
+    bpf_perf_event_output(ctx, &capture_events,
+                          BPF_F_CURRENT_CPU | (len << 32),
+                          &meta, sizeof(meta));
+}
+
+#endif /* __MOD_CAPTURE_H__ */
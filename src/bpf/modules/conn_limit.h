@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: GPL-2.0
+#ifndef __MOD_CONN_LIMIT_H__
+#define __MOD_CONN_LIMIT_H__
+
+#include "../common/types.h"
+#include "../common/maps.h"
+#include "../common/helpers.h"
+
+/* ===== Per-Source Connection Limit Module =====
+ *
+ * Caps the concurrent TCP connections a single source can open, so one
+ * address cannot exhaust a server's connection table. The limit comes
+ * from conn_limit_dst for the destination prefix, else CFG_CONN_LIMIT.
+ * Only connection attempts (SYN without ACK) are checked: established
+ * connections are never cut, new ones are refused at the limit.
+ *
+ * Counts are maintained by conntrack, so the module is inert while
+ * connection tracking is disabled.
+ *
+ * Returns:
+ *   VERDICT_PASS - Below the limit, or not a connection attempt
+ *   VERDICT_DROP - Source already holds the maximum connections
+ */
+
+static __always_inline int conn_limit_check(struct packet_ctx *pkt,
+                                             struct global_stats *stats)
+{
+    if (pkt->ip_proto != IPPROTO_TCP)
+        return VERDICT_PASS;
+    if ((pkt->tcp_flags & (TCP_FLAG_SYN | TCP_FLAG_ACK)) != TCP_FLAG_SYN)
+        return VERDICT_PASS;
+    if (!get_config(CFG_CONNTRACK_ENABLE))
+        return VERDICT_PASS;
+
+    struct lpm_key_v4 key = {
+        .prefixlen = 32,
+        .addr = pkt->dst_ip,
+    };
+    __u64 limit;
+    __u32 *dst_limit = bpf_map_lookup_elem(&conn_limit_dst, &key);
+    if (dst_limit)
+        limit = *dst_limit;
+    else
+        limit = get_config(CFG_CONN_LIMIT);
+    if (limit == 0)
+        return VERDICT_PASS;
+
+    __u32 *count = bpf_map_lookup_elem(&conn_count, &pkt->src_ip);
+    if (!count || *count < limit)
+        return VERDICT_PASS;
+
+    if (stats)
+        stats->conn_limit_dropped++;
+    emit_event(pkt, ATTACK_CONN_FLOOD, 1, DROP_CONN_LIMIT, 0, 0);
+    return VERDICT_DROP;
+}
+
+/* Called by conntrack when a source opens a TCP connection. */
+static __always_inline void conn_count_open(__be32 src_ip)
+{
+    __u32 one = 1;
+    __u32 *count = bpf_map_lookup_elem(&conn_count, &src_ip);
+    if (count)
+        __sync_fetch_and_add(count, 1);
+    else
+        bpf_map_update_elem(&conn_count, &src_ip, &one, BPF_NOEXIST);
+}
+
+/* Called by conntrack when a connection reaches CLOSED. */
+static __always_inline void conn_count_close(__be32 src_ip)
+{
+    __u32 *count = bpf_map_lookup_elem(&conn_count, &src_ip);
+    if (count && *count > 0)
+        __sync_fetch_and_add(count, -1);
+}
+
+#endif /* __MOD_CONN_LIMIT_H__ */
@@ -5,6 +5,7 @@
 #include "../common/types.h"
 #include "../common/maps.h"
 #include "../common/helpers.h"
+#include "conn_limit.h"
 
 /* ===== Connection Tracking Module =====
  *
@@ -16,6 +17,9 @@
  *
  * UDP/ICMP: Simplified NEW → ESTABLISHED (based on bidirectional traffic)
  *
+ * TCP connections opened with a SYN and later closed are counted per
+ * source in conn_count for the connection limit module.
+ *
  * Returns:
  *   VERDICT_PASS  - Always (conntrack is informational, doesn't drop)
  */
@@ -96,8 +100,12 @@ static __always_inline int conntrack_update(struct packet_ctx *pkt,
         ct->packets_fwd++;
         ct->bytes_fwd += pkt->pkt_len;
 
-        if (pkt->ip_proto == IPPROTO_TCP)
+        if (pkt->ip_proto == IPPROTO_TCP) {
+            __u8 prev = ct->state;
             conntrack_tcp_state_update(ct, pkt->tcp_flags, 1);
+            if (ct->state == CT_STATE_CLOSED && prev != CT_STATE_CLOSED)
+                conn_count_close(pkt->src_ip);
+        }
 
         return VERDICT_PASS;
     }
@@ -118,8 +126,12 @@ static __always_inline int conntrack_update(struct packet_ctx *pkt,
         ct->packets_rev++;
         ct->bytes_rev += pkt->pkt_len;
 
-        if (pkt->ip_proto == IPPROTO_TCP)
+        if (pkt->ip_proto == IPPROTO_TCP) {
+            __u8 prev = ct->state;
             conntrack_tcp_state_update(ct, pkt->tcp_flags, 0);
+            if (ct->state == CT_STATE_CLOSED && prev != CT_STATE_CLOSED)
+                conn_count_close(pkt->dst_ip);
+        }
 
         /* Promote UDP/ICMP to established on bidirectional traffic */
         if (pkt->ip_proto != IPPROTO_TCP &&
@@ -143,7 +155,11 @@ static __always_inline int conntrack_update(struct packet_ctx *pkt,
         .flags = 0,
     };
 
-    bpf_map_update_elem(&conntrack_map, &ct_key, &new_ct, BPF_NOEXIST);
+    int err = bpf_map_update_elem(&conntrack_map, &ct_key, &new_ct, BPF_NOEXIST);
+
+    if (!err && pkt->ip_proto == IPPROTO_TCP &&
+        (pkt->tcp_flags & (TCP_FLAG_SYN | TCP_FLAG_ACK)) == TCP_FLAG_SYN)
+        conn_count_open(pkt->src_ip);
 
     if (stats)
         stats->conntrack_new++;
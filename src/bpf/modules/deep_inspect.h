@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: GPL-2.0
+#ifndef __MOD_DEEP_INSPECT_H__
+#define __MOD_DEEP_INSPECT_H__
+
+#include "../common/types.h"
+#include "../common/maps.h"
+#include "../common/helpers.h"
+
+/* ===== Deep Inspection Module =====
+ *
+ * Hands a subset of the traffic that passed every other stage to the
+ * userspace inspector over AF_XDP, which parses what XDP cannot: DNS
+ * query names and TLS ClientHellos. Its verdicts come back as blacklist
+ * entries for malicious sources and inspect_cleared entries for clean
+ * ones, which are not redirected again until the entry expires.
+ *
+ * A packet is selected when it carries payload to a port listed in
+ * inspect_ports, over UDP for INSPECT_DNS or as the start of a TLS
+ * ClientHello over TCP for INSPECT_TLS, and its source is not cleared.
+ * Suspect sources, scoring at least CFG_INSPECT_SCORE, are always
+ * redirected; others 1 in CFG_INSPECT_SAMPLE.
+ *
+ * The inspector consumes what it is given, so a source loses at most one
+ * selected packet until it is cleared, which DNS and TLS clients
+ * retransmit. Without a socket on the receive queue the packet passes.
+ *
+ * Returns:
+ *   VERDICT_PASS  - Not selected, or no inspector socket
+ *   VERDICT_REDIR - Redirected to xsks_map (XDP_REDIRECT)
+ */
+
+static __always_inline int deep_inspect(struct xdp_md *ctx,
+                                        struct packet_ctx *pkt,
+                                        __u64 now_ns)
+{
+    if (!get_config(CFG_INSPECT_ENABLE))
+        return VERDICT_PASS;
+    if (pkt->is_fragment || pkt->l4_payload_len == 0)
+        return VERDICT_PASS;
+
+    __u16 port = bpf_ntohs(pkt->dst_port);
+    __u8 *kind = bpf_map_lookup_elem(&inspect_ports, &port);
+    if (!kind)
+        return VERDICT_PASS;
+    switch (*kind) {
+    case INSPECT_DNS:
+        if (pkt->ip_proto != IPPROTO_UDP)
+            return VERDICT_PASS;
+        break;
+    case INSPECT_TLS:
+        if (pkt->ip_proto != IPPROTO_TCP || !is_tls_client_hello(ctx, pkt))
+            return VERDICT_PASS;
+        break;
+    default:
+        return VERDICT_PASS;
+    }
+
+    __u64 *cleared = bpf_map_lookup_elem(&inspect_cleared, &pkt->src_ip);
+    if (cleared && *cleared > now_ns)
+        return VERDICT_PASS;
+
+    /* Suspect sources skip sampling */
+    int suspect = 0;
+    __u64 min_score = get_config(CFG_INSPECT_SCORE);
+    if (min_score > 0) {
+        struct ip_reputation *rep = bpf_map_lookup_elem(&reputation_map, &pkt->src_ip);
+        suspect = rep && rep->score >= min_score;
+    }
+    if (!suspect) {
+        __u64 sample = get_config(CFG_INSPECT_SAMPLE);
+        if (sample > 1 && bpf_get_prandom_u32() % sample != 0)
+            return VERDICT_PASS;
+    }
+
+    __u32 key = 0;
+    struct inspect_stats *st = bpf_map_lookup_elem(&inspect_stats, &key);
+
+    /* XDP_PASS when the queue has no socket */
+    if (bpf_redirect_map(&xsks_map, ctx->rx_queue_index, XDP_PASS) != XDP_REDIRECT) {
+        if (st)
+            st->no_socket++;
+        return VERDICT_PASS;
+    }
+    if (st)
+        st->redirected++;
+    return VERDICT_REDIR;
+}
+
+#endif /* __MOD_DEEP_INSPECT_H__ */
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: GPL-2.0
+#ifndef __MOD_EGRESS_H__
+#define __MOD_EGRESS_H__
+
+#include <linux/pkt_cls.h>
+
+#include "../common/types.h"
+#include "../common/maps.h"
+#include "../common/helpers.h"
+
+/* ===== Egress Scrubbing Module =====
+ *
+ * Runs in the TC egress program on the packets the protected network
+ * sends, so that it cannot be used to launch or reflect attacks:
+ *
+ *  - Spoofed sources (CFG_EGRESS_SPOOF): packets whose source address is
+ *    not in egress_src_allow are dropped, as BCP 38 asks of every network.
+ *  - Amplification (CFG_EGRESS_AMP): UDP responses from the ports in
+ *    egress_amp_ports are limited per destination, so an open resolver or
+ *    NTP server answering spoofed queries cannot flood their victim.
+ *
+ * Only IPv4 is inspected, and only first fragments carry the UDP ports;
+ * everything else passes.
+ *
+ * Returns:
+ *   VERDICT_PASS - Packet may leave
+ *   VERDICT_DROP - Spoofed source, or over its amplification limit
+ */
+
+/* Headers the module reads: Ethernet, IPv4 without options, UDP */
+#define EGRESS_HDR_LEN (sizeof(struct ethhdr) + sizeof(struct iphdr) + sizeof(struct udphdr))
+
+static __always_inline struct egress_stats *egress_get_stats(void)
+{
+    __u32 key = 0;
+    return bpf_map_lookup_elem(&egress_stats, &key);
+}
+
+static __always_inline int egress_spoof_check(struct iphdr *iph)
+{
+    if (!get_config(CFG_EGRESS_SPOOF))
+        return VERDICT_PASS;
+
+    struct lpm_key_v4 key = {
+        .prefixlen = 32,
+        .addr = iph->saddr,
+    };
+    if (bpf_map_lookup_elem(&egress_src_allow, &key))
+        return VERDICT_PASS;
+    return VERDICT_DROP;
+}
+
+static __always_inline int egress_amp_check(struct iphdr *iph,
+                                            struct udphdr *udp,
+                                            __u64 now_ns)
+{
+    if (!get_config(CFG_EGRESS_AMP))
+        return VERDICT_PASS;
+
+    __u16 port = bpf_ntohs(udp->source);
+    __u64 *limit = bpf_map_lookup_elem(&egress_amp_ports, &port);
+    if (!limit)
+        return VERDICT_PASS;
+    if (*limit == 0)
+        return VERDICT_DROP;
+
+    struct egress_amp_key key = {
+        .dst_ip = iph->daddr,
+        .port = port,
+    };
+    struct rate_limiter *rl = bpf_map_lookup_elem(&egress_amp_bucket, &key);
+    if (!rl) {
+        struct rate_limiter new_rl = {
+            .tokens = *limit,
+            .last_refill_ns = now_ns,
+            .rate_pps = *limit,
+            .burst_size = *limit * 2,
+            .total_packets = 1,
+        };
+        bpf_map_update_elem(&egress_amp_bucket, &key, &new_rl, BPF_NOEXIST);
+        return VERDICT_PASS;
+    }
+
+    /* Update rate config in case it changed */
+    rl->rate_pps = *limit;
+    rl->burst_size = *limit * 2;
+
+    if (!token_bucket_consume(rl, now_ns, 1))
+        return VERDICT_DROP;
+    return VERDICT_PASS;
+}
+
+static __always_inline int egress_scrub(struct __sk_buff *skb,
+                                        struct egress_stats *stats,
+                                        __u64 now_ns)
+{
+    void *data = (void *)(long)skb->data;
+    void *data_end = (void *)(long)skb->data_end;
+
+    /* Headers may sit outside the linear part of an outgoing skb */
+    if (data + EGRESS_HDR_LEN > data_end) {
+        bpf_skb_pull_data(skb, EGRESS_HDR_LEN);
+        data = (void *)(long)skb->data;
+        data_end = (void *)(long)skb->data_end;
+    }
+
+    struct ethhdr *eth = data;
+    if (!bounds_check(eth, sizeof(*eth), data_end))
+        return VERDICT_PASS;
+    if (eth->h_proto != bpf_htons(ETH_P_IP))
+        return VERDICT_PASS;
+
+    struct iphdr *iph = (void *)(eth + 1);
+    if (!bounds_check(iph, sizeof(*iph), data_end))
+        return VERDICT_PASS;
+
+    if (stats) {
+        stats->packets++;
+        stats->bytes += skb->len;
+    }
+
+    if (egress_spoof_check(iph) == VERDICT_DROP) {
+        if (stats) {
+            stats->spoofed_dropped++;
+            stats->dropped_bytes += skb->len;
+        }
+        return VERDICT_DROP;
+    }
+
+    if (iph->protocol != IPPROTO_UDP || iph->ihl < 5)
+        return VERDICT_PASS;
+    if (iph->frag_off & bpf_htons(0x1FFF))
+        return VERDICT_PASS;
+
+    struct udphdr *udp = (void *)iph + iph->ihl * 4;
+    if (!bounds_check(udp, sizeof(*udp), data_end))
+        return VERDICT_PASS;
+
+    if (egress_amp_check(iph, udp, now_ns) == VERDICT_DROP) {
+        if (stats) {
+            stats->amp_dropped++;
+            stats->dropped_bytes += skb->len;
+        }
+        return VERDICT_DROP;
+    }
+    return VERDICT_PASS;
+}
+
+#endif /* __MOD_EGRESS_H__ */
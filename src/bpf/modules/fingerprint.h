@@ -76,6 +76,8 @@ static __always_inline int sig_matches(struct attack_sig *sig,
         __u32 _k = (idx);                                                   \
         struct attack_sig *_sig = bpf_map_lookup_elem(&attack_sig_map, &_k);\
         if (_sig && sig_matches(_sig, pkt, src_port_h, dst_port_h)) {       \
+            __u64 *_hits = bpf_map_lookup_elem(&attack_sig_hits, &_k);      \
+            if (_hits) (*_hits)++;                                          \
             if (stats) stats->acl_dropped++;                                \
             emit_event(pkt, ATTACK_NONE, 1, DROP_FINGERPRINT, 0, 0);       \
             return VERDICT_DROP;                                            \
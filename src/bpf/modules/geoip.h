@@ -13,11 +13,22 @@
  * Actions:
  *   GEOIP_ACTION_PASS       - Allow traffic (default)
  *   GEOIP_ACTION_DROP       - Drop traffic from this country
- *   GEOIP_ACTION_RATE_LIMIT - Apply 50% stricter rate via adaptive_rate_map
+ *   GEOIP_ACTION_RATE_LIMIT - Enforce the country's token bucket if it has a
+ *                             rate in geoip_country_rate, otherwise apply a
+ *                             50% stricter per-source rate via adaptive_rate_map
  *   GEOIP_ACTION_MONITOR    - Pass but emit event for monitoring
  *
  * At ESCALATION_CRITICAL, IPs with no country mapping are treated as DROP.
  *
+ * A protected prefix whose dst_policy names a country allow-list accepts
+ * traffic only from the countries in geoip_allow; sources from any other
+ * country, or none, get the prefix's geo_allow_action (DROP or
+ * RATE_LIMIT). Listed countries then go through their country policy as
+ * usual, and count as explicitly allowed at ESCALATION_CRITICAL.
+ *
+ * Per-country packet, drop, rate-limit and monitor counters are kept in
+ * geoip_country_stats for the control plane.
+ *
  * Returns:
  *   VERDICT_PASS - Allowed
  *   VERDICT_DROP - Blocked by GeoIP policy
@@ -26,75 +37,95 @@
 /* Default adaptive rate divisor: 50% stricter means rate = current / 2 */
 #define GEOIP_RATE_LIMIT_DIVISOR 2
 
-static __always_inline int geoip_check(struct packet_ctx *pkt,
-                                        struct global_stats *stats)
+/* Returns this CPU's counters for a country, creating them if needed. */
+static __always_inline struct country_stats *country_stats_get(__u16 country)
 {
-    /* Check if GeoIP module is enabled */
-    if (!get_config(CFG_GEOIP_ENABLE))
-        return VERDICT_PASS;
+    struct country_stats *cs;
+    cs = bpf_map_lookup_elem(&geoip_country_stats, &country);
+    if (cs)
+        return cs;
 
-    __u64 escalation = get_config(CFG_ESCALATION_LEVEL);
-
-    /* Build LPM trie key for source IP lookup */
-    struct lpm_key_v4 lpm_key = {
-        .prefixlen = 32,
-        .addr = pkt->src_ip,
-    };
+    struct country_stats zero = {};
+    bpf_map_update_elem(&geoip_country_stats, &country, &zero, BPF_NOEXIST);
+    return bpf_map_lookup_elem(&geoip_country_stats, &country);
+}
 
-    struct geoip_entry *geo;
-    geo = bpf_map_lookup_elem(&geoip_map, &lpm_key);
+/* Consumes a token from the country bucket. Returns 1 if the packet is
+ * within the country rate (or the bucket was just created), 0 if not. */
+static __always_inline int country_rate_consume(__u16 country, __u64 rate_pps,
+                                                __u64 now_ns)
+{
+    struct rate_limiter *rl;
+    rl = bpf_map_lookup_elem(&geoip_country_bucket, &country);
 
-    if (!geo) {
-        /*
-         * No GeoIP entry for this IP prefix.
-         * At ESCALATION_CRITICAL, treat unknown origins as hostile.
-         */
-        if (escalation >= ESCALATION_CRITICAL) {
-            if (stats) {
-                stats->geoip_dropped++;
-                stats_drop(stats, pkt->pkt_len);
-            }
-            emit_event(pkt, ATTACK_GEOIP_BLOCK, 1, DROP_GEOIP, 0, 0);
-            return VERDICT_DROP;
-        }
-        return VERDICT_PASS;
+    if (!rl) {
+        struct rate_limiter new_rl = {
+            .tokens = rate_pps,
+            .last_refill_ns = now_ns,
+            .rate_pps = rate_pps,
+            .burst_size = rate_pps * 2,
+        };
+        bpf_map_update_elem(&geoip_country_bucket, &country, &new_rl, BPF_NOEXIST);
+        return 1;
     }
 
-    __u16 country = geo->country_code;
+    /* Update rate config in case it changed */
+    rl->rate_pps = rate_pps;
+    rl->burst_size = rate_pps * 2;
 
-    /* Look up per-country policy */
-    __u8 *policy;
-    policy = bpf_map_lookup_elem(&geoip_policy, &country);
+    return token_bucket_consume(rl, now_ns, 1);
+}
 
-    __u8 action;
-    if (policy) {
-        action = *policy;
-    } else {
-        /*
-         * No explicit policy for this country.
-         * At ESCALATION_CRITICAL, drop countries with no explicit allow.
-         */
-        if (escalation >= ESCALATION_CRITICAL) {
-            if (stats) {
-                stats->geoip_dropped++;
-                stats_drop(stats, pkt->pkt_len);
-            }
-            emit_event(pkt, ATTACK_GEOIP_BLOCK, 1, DROP_GEOIP, 0, 0);
-            return VERDICT_DROP;
-        }
-        return VERDICT_PASS;
-    }
+/* Looks up the source country in the allow-list of the destination
+ * prefix. Returns 1 if the country is listed, 0 if the prefix has no
+ * allow-list, or -1 if the country is not on it. */
+static __always_inline int geoip_allow_lookup(struct dst_policy *dp,
+                                              __u16 country)
+{
+    if (!dp || !dp->geo_allow_id)
+        return 0;
+
+    struct geoip_allow_key key = {
+        .id = dp->geo_allow_id,
+        .country_code = country,
+    };
+    return bpf_map_lookup_elem(&geoip_allow, &key) ? 1 : -1;
+}
 
+/* Applies a GEOIP_ACTION_* to a packet from country (0 if unknown). */
+static __always_inline int geoip_apply(struct packet_ctx *pkt,
+                                       struct global_stats *stats,
+                                       struct country_stats *cs,
+                                       __u16 country, __u8 action,
+                                       __u64 now_ns)
+{
     switch (action) {
     case GEOIP_ACTION_DROP:
         if (stats) {
             stats->geoip_dropped++;
             stats_drop(stats, pkt->pkt_len);
         }
+        if (cs)
+            cs->dropped++;
         emit_event(pkt, ATTACK_GEOIP_BLOCK, 1, DROP_GEOIP, 0, 0);
         return VERDICT_DROP;
 
     case GEOIP_ACTION_RATE_LIMIT: {
+        /* Countries with a configured rate share one token bucket */
+        __u64 *country_pps;
+        country_pps = bpf_map_lookup_elem(&geoip_country_rate, &country);
+        if (country_pps && *country_pps > 0) {
+            if (country_rate_consume(country, *country_pps, now_ns))
+                return VERDICT_PASS;
+
+            if (stats)
+                stats->geoip_dropped++;
+            if (cs)
+                cs->rate_limited++;
+            emit_event(pkt, ATTACK_GEOIP_BLOCK, 1, DROP_GEOIP_RATE, 0, 0);
+            return VERDICT_DROP;
+        }
+
         /*
          * Apply 50% stricter rate limit for this source IP.
          * Look up the current adaptive rate; if none exists, read the
@@ -135,6 +166,8 @@ static __always_inline int geoip_check(struct packet_ctx *pkt,
 
     case GEOIP_ACTION_MONITOR:
         /* Pass traffic but emit an event so userspace can track it */
+        if (cs)
+            cs->monitored++;
         emit_event(pkt, ATTACK_GEOIP_BLOCK, 0, 0, 0, 0);
         return VERDICT_PASS;
 
@@ -146,4 +179,79 @@ static __always_inline int geoip_check(struct packet_ctx *pkt,
     return VERDICT_PASS;
 }
 
+static __always_inline int geoip_check(struct packet_ctx *pkt,
+                                        struct global_stats *stats,
+                                        __u64 now_ns)
+{
+    /* Check if GeoIP module is enabled */
+    if (!get_config(CFG_GEOIP_ENABLE))
+        return VERDICT_PASS;
+
+    __u64 escalation = escalation_level(pkt);
+    struct dst_policy *dp = pkt->dst_policy;
+
+    /* Build LPM trie key for source IP lookup */
+    struct lpm_key_v4 lpm_key = {
+        .prefixlen = 32,
+        .addr = pkt->src_ip,
+    };
+
+    struct geoip_entry *geo;
+    geo = bpf_map_lookup_elem(&geoip_map, &lpm_key);
+
+    if (!geo) {
+        /*
+         * No GeoIP entry for this IP prefix.
+         * At ESCALATION_CRITICAL, treat unknown origins as hostile.
+         */
+        if (escalation >= ESCALATION_CRITICAL) {
+            if (stats) {
+                stats->geoip_dropped++;
+                stats_drop(stats, pkt->pkt_len);
+            }
+            emit_event(pkt, ATTACK_GEOIP_BLOCK, 1, DROP_GEOIP, 0, 0);
+            return VERDICT_DROP;
+        }
+        /* An unknown origin is on no allow-list */
+        if (dp && geoip_allow_lookup(dp, 0) < 0)
+            return geoip_apply(pkt, stats, NULL, 0, dp->geo_allow_action, now_ns);
+        return VERDICT_PASS;
+    }
+
+    __u16 country = geo->country_code;
+
+    struct country_stats *cs = country_stats_get(country);
+    if (cs)
+        cs->packets++;
+
+    /* Destination allow-list, ahead of the country's own policy */
+    int listed = geoip_allow_lookup(dp, country);
+    if (dp && listed < 0)
+        return geoip_apply(pkt, stats, cs, country, dp->geo_allow_action, now_ns);
+
+    /* Look up per-country policy */
+    __u8 *policy;
+    policy = bpf_map_lookup_elem(&geoip_policy, &country);
+
+    if (!policy) {
+        /*
+         * No explicit policy for this country.
+         * At ESCALATION_CRITICAL, drop countries with no explicit allow.
+         */
+        if (escalation >= ESCALATION_CRITICAL && listed == 0) {
+            if (stats) {
+                stats->geoip_dropped++;
+                stats_drop(stats, pkt->pkt_len);
+            }
+            if (cs)
+                cs->dropped++;
+            emit_event(pkt, ATTACK_GEOIP_BLOCK, 1, DROP_GEOIP, 0, 0);
+            return VERDICT_DROP;
+        }
+        return VERDICT_PASS;
+    }
+
+    return geoip_apply(pkt, stats, cs, country, *policy, now_ns);
+}
+
 #endif /* __MOD_GEOIP_H__ */
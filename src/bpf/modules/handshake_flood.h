@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: GPL-2.0
+#ifndef __MOD_HANDSHAKE_FLOOD_H__
+#define __MOD_HANDSHAKE_FLOOD_H__
+
+#include "../common/types.h"
+#include "../common/maps.h"
+#include "../common/helpers.h"
+
+/* ===== TLS / QUIC Handshake Flood Module =====
+ *
+ * HTTPS floods complete the TCP handshake, so SYN cookies and the SYN
+ * rate limit never see them; the cost they impose on servers is the
+ * TLS or QUIC handshake. This module counts the handshakes each source
+ * starts per second in handshake_rate, separately for
+ *   - TLS: TCP segments opening a ClientHello record
+ *   - QUIC: long-header Initial packets of QUIC versions 1 and 2
+ * and drops those beyond CFG_TLS_HS_RATE or CFG_QUIC_INIT_RATE in the
+ * current one-second window, emitting ATTACK_TLS_FLOOD or
+ * ATTACK_QUIC_FLOOD. A limit of 0 only counts.
+ *
+ * Returns:
+ *   VERDICT_PASS - Not a handshake, or within the source's limit
+ *   VERDICT_DROP - Source exceeded its handshake rate
+ */
+
+#define HANDSHAKE_WINDOW_NS    1000000000ULL
+
+/* QUIC long header: header form and fixed bits, then the version */
+#define QUIC_LONG_HEADER       0xc0
+#define QUIC_VERSION_1         0x00000001
+#define QUIC_VERSION_2         0x6b3343cf
+
+/* Whether the UDP payload is a QUIC Initial packet. Its long packet type
+ * is 0 in version 1 and 1 in version 2 (RFC 9369). */
+static __always_inline int is_quic_initial(struct xdp_md *ctx,
+                                           struct packet_ctx *pkt)
+{
+    __u16 poff = pkt->payload_offset;
+    if (poff == 0 || poff > 1500 || pkt->l4_payload_len < 5)
+        return 0;
+
+    void *data = (void *)(long)ctx->data;
+    void *data_end = (void *)(long)ctx->data_end;
+    __u8 *p = (__u8 *)data + poff;
+    if (!bounds_check(p, 5, data_end))
+        return 0;
+
+    if ((p[0] & QUIC_LONG_HEADER) != QUIC_LONG_HEADER)
+        return 0;
+    __u32 version = ((__u32)p[1] << 24) | ((__u32)p[2] << 16) |
+                    ((__u32)p[3] << 8) | p[4];
+    __u8 type = (p[0] >> 4) & 0x3;
+    if (version == QUIC_VERSION_1)
+        return type == 0;
+    if (version == QUIC_VERSION_2)
+        return type == 1;
+    return 0;
+}
+
+static __always_inline int handshake_flood_check(struct xdp_md *ctx,
+                                                 struct packet_ctx *pkt,
+                                                 struct global_stats *stats,
+                                                 __u64 now_ns)
+{
+    if (pkt->is_fragment || pkt->l4_payload_len == 0)
+        return VERDICT_PASS;
+
+    __u8 kind;
+    __u64 limit;
+    if (pkt->ip_proto == IPPROTO_TCP && is_tls_client_hello(ctx, pkt)) {
+        kind = HANDSHAKE_TLS;
+        limit = get_config(CFG_TLS_HS_RATE);
+        if (stats)
+            stats->tls_handshakes++;
+    } else if (pkt->ip_proto == IPPROTO_UDP && is_quic_initial(ctx, pkt)) {
+        kind = HANDSHAKE_QUIC;
+        limit = get_config(CFG_QUIC_INIT_RATE);
+        if (stats)
+            stats->quic_initials++;
+    } else {
+        return VERDICT_PASS;
+    }
+    if (limit == 0)
+        return VERDICT_PASS;
+
+    struct handshake_key key = {
+        .src_ip = pkt->src_ip,
+        .kind = kind,
+    };
+    struct handshake_rate *hr = bpf_map_lookup_elem(&handshake_rate, &key);
+    if (!hr) {
+        struct handshake_rate init = {};
+        init.window_start_ns = now_ns;
+        init.count = 1;
+        init.peak = 1;
+        bpf_map_update_elem(&handshake_rate, &key, &init, BPF_NOEXIST);
+        return VERDICT_PASS;
+    }
+
+    if (now_ns - hr->window_start_ns >= HANDSHAKE_WINDOW_NS) {
+        hr->window_start_ns = now_ns;
+        hr->count = 0;
+    }
+    hr->count++;
+    if (hr->count > hr->peak)
+        hr->peak = hr->count;
+    if (hr->count <= limit)
+        return VERDICT_PASS;
+
+    hr->dropped++;
+    hr->last_drop_ns = now_ns;
+    if (kind == HANDSHAKE_TLS) {
+        if (stats)
+            stats->tls_flood_dropped++;
+        emit_event(pkt, ATTACK_TLS_FLOOD, 1, DROP_TLS_FLOOD, hr->count, 0);
+    } else {
+        if (stats)
+            stats->quic_flood_dropped++;
+        emit_event(pkt, ATTACK_QUIC_FLOOD, 1, DROP_QUIC_FLOOD, hr->count, 0);
+    }
+    return VERDICT_DROP;
+}
+
+#endif /* __MOD_HANDSHAKE_FLOOD_H__ */
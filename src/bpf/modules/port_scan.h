@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: GPL-2.0
+#ifndef __MOD_PORT_SCAN_H__
+#define __MOD_PORT_SCAN_H__
+
+#include "../common/types.h"
+#include "../common/maps.h"
+#include "../common/helpers.h"
+#include "reputation.h"
+
+/* ===== Port Scan Detection Module =====
+ *
+ * Counts the distinct TCP/UDP destination ports each source touches
+ * within a window (CFG_PORT_SCAN_WINDOW). When the count first exceeds
+ * CFG_PORT_SCAN_THRESH in a window, the source is flagged: an
+ * ATTACK_PORT_SCAN event is emitted and, depending on
+ * CFG_PORT_SCAN_ACTION, its reputation score is raised. Temporary
+ * blocking is done by the control plane on the event, since blacklist
+ * entries carry no expiry in the data plane.
+ *
+ * Ports are hashed into a 512-bit set, so distinct ports are slightly
+ * undercounted on collisions but a repeated port never counts twice.
+ *
+ * Always returns VERDICT_PASS.
+ */
+
+/* Defaults when the config keys are unset */
+#define PORT_SCAN_THRESHOLD   20              /* Distinct ports before detection */
+#define PORT_SCAN_WINDOW_NS   10000000000ULL  /* 10 seconds in nanoseconds */
+
+static __always_inline int port_scan_check(struct packet_ctx *pkt,
+                                            struct global_stats *stats,
+                                            __u64 now_ns)
+{
+    __u64 action = get_config(CFG_PORT_SCAN_ACTION);
+    if (action == PORT_SCAN_OFF)
+        return VERDICT_PASS;
+    if (pkt->ip_proto != IPPROTO_TCP && pkt->ip_proto != IPPROTO_UDP)
+        return VERDICT_PASS;
+
+    __u64 threshold = get_config(CFG_PORT_SCAN_THRESH);
+    if (threshold == 0)
+        threshold = PORT_SCAN_THRESHOLD;
+    __u64 window_ns = get_config(CFG_PORT_SCAN_WINDOW) * 1000000000ULL;
+    if (window_ns == 0)
+        window_ns = PORT_SCAN_WINDOW_NS;
+
+    /* Fibonacci hash of the port onto one of 512 bits */
+    __u32 slot = ((__u16)(bpf_ntohs(pkt->dst_port) * 40503)) >> 7;
+    __u32 word = (slot >> 6) & 7;
+    __u64 bit = 1ULL << (slot & 63);
+
+    struct port_scan_entry *ps;
+    ps = bpf_map_lookup_elem(&port_scan_map, &pkt->src_ip);
+    if (!ps) {
+        struct port_scan_entry new_ps = {};
+        new_ps.window_start_ns = now_ns;
+        new_ps.distinct_ports = 1;
+        new_ps.peak_ports = 1;
+        new_ps.port_bitmap[word] = bit;
+        bpf_map_update_elem(&port_scan_map, &pkt->src_ip, &new_ps, BPF_NOEXIST);
+        return VERDICT_PASS;
+    }
+
+    /* Window expired: start counting afresh */
+    if (now_ns - ps->window_start_ns > window_ns) {
+        ps->window_start_ns = now_ns;
+        ps->distinct_ports = 0;
+#pragma unroll
+        for (int i = 0; i < 8; i++)
+            ps->port_bitmap[i] = 0;
+    }
+
+    if (ps->port_bitmap[word] & bit)
+        return VERDICT_PASS;
+    ps->port_bitmap[word] |= bit;
+    ps->distinct_ports++;
+    if (ps->distinct_ports > ps->peak_ports)
+        ps->peak_ports = ps->distinct_ports;
+
+    /* Respond once per window, on the port that crosses the threshold */
+    if (ps->distinct_ports != threshold + 1)
+        return VERDICT_PASS;
+
+    ps->detected_ns = now_ns;
+    if (stats)
+        stats->port_scan_detected++;
+    emit_event(pkt, ATTACK_PORT_SCAN, 0, 0, 0, 0);
+
+    if (action == PORT_SCAN_SCORE)
+        reputation_penalize(pkt->src_ip, REP_WEIGHT_PORT_SCAN, now_ns);
+
+    return VERDICT_PASS;
+}
+
+#endif /* __MOD_PORT_SCAN_H__ */
@@ -327,18 +327,22 @@ static __always_inline int memcached_validate(struct packet_ctx *pkt,
  *    - Repeated violations exceeding threshold
  *
  *  At ESCALATION_HIGH or ESCALATION_CRITICAL: strict mode drops on
- *  first violation instead of allowing a tolerance window.
+ *  first violation instead of allowing a tolerance window. Destinations
+ *  in tcp_strict_dst are validated in strict mode even while
+ *  CFG_TCP_STATE_ENABLE is off.
  * ===================================================================== */
 static __always_inline int tcp_state_validate(struct xdp_md *ctx,
                                               struct packet_ctx *pkt,
                                               struct global_stats *stats,
                                               __u64 now_ns)
 {
-    __u64 tcp_state_enabled = get_config(CFG_TCP_STATE_ENABLE);
-    if (!tcp_state_enabled)
+    if (pkt->ip_proto != IPPROTO_TCP)
         return VERDICT_PASS;
 
-    if (pkt->ip_proto != IPPROTO_TCP)
+    __u64 *strict_until = bpf_map_lookup_elem(&tcp_strict_dst, &pkt->dst_ip);
+    int strict_dst = strict_until && *strict_until > now_ns;
+    __u64 tcp_state_enabled = get_config(CFG_TCP_STATE_ENABLE);
+    if (!tcp_state_enabled && !strict_dst)
         return VERDICT_PASS;
 
     if (!pkt->l4_offset)
@@ -356,8 +360,8 @@ static __always_inline int tcp_state_validate(struct xdp_md *ctx,
         return VERDICT_PASS;
 
     __u8 flags = pkt->tcp_flags;
-    __u64 escalation = get_config(CFG_ESCALATION_LEVEL);
-    int strict_mode = (escalation >= ESCALATION_HIGH);
+    __u64 escalation = escalation_level(pkt);
+    int strict_mode = (escalation >= ESCALATION_HIGH) || strict_dst;
     __u32 violation_limit = strict_mode ? 1 : TCP_VIOLATION_LIMIT;
 
     /* Build conntrack key for forward lookup */
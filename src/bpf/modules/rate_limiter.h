@@ -8,35 +8,117 @@
 
 /* ===== Per-Source Rate Limiter Module =====
  * Token bucket rate limiter per source IP.
- * Limits are configured per protocol via config map.
+ * Limits are configured per protocol via config map, and overridden by
+ * the rate class the source or destination is bound to, and above that
+ * per destination by the protected prefix policy. A source's bucket
+ * follows the limit of its latest packet.
+ *
+ * Traffic in a rate class with a pps or bps limit also shares the class
+ * token buckets.
  *
  * Returns:
  *   VERDICT_PASS - Within rate limit
  *   VERDICT_DROP - Rate exceeded
  */
 
+/* Returns the rate class of the packet's source, or else of its
+ * destination, and its id; NULL if neither is bound to a class. */
+static __always_inline struct rate_class *rate_class_lookup(struct packet_ctx *pkt,
+                                                            __u32 *class_id)
+{
+    struct lpm_key_v4 key = {
+        .prefixlen = 32,
+        .addr = pkt->src_ip,
+    };
+    __u32 *id = bpf_map_lookup_elem(&rate_class_src, &key);
+
+    if (!id) {
+        key.addr = pkt->dst_ip;
+        id = bpf_map_lookup_elem(&rate_class_dst, &key);
+        if (!id)
+            return NULL;
+    }
+    *class_id = *id;
+    return bpf_map_lookup_elem(&rate_classes, id);
+}
+
+/* Takes tokens from one of the class buckets. Returns 1 if the packet is
+ * within the class rate (or the bucket was just created), 0 if not. */
+static __always_inline int rate_class_consume(__u32 class_id, __u32 kind,
+                                              __u64 rate, __u64 tokens,
+                                              __u64 now_ns)
+{
+    __u32 key = class_id * 2 + kind;
+    struct rate_limiter *rl;
+    rl = bpf_map_lookup_elem(&rate_class_bucket, &key);
+
+    if (!rl) {
+        struct rate_limiter new_rl = {
+            .tokens = rate,
+            .last_refill_ns = now_ns,
+            .rate_pps = rate,
+            .burst_size = rate * 2,
+        };
+        bpf_map_update_elem(&rate_class_bucket, &key, &new_rl, BPF_NOEXIST);
+        return 1;
+    }
+
+    /* Update rate config in case it changed */
+    rl->rate_pps = rate;
+    rl->burst_size = rate * 2;
+
+    return token_bucket_consume(rl, now_ns, tokens);
+}
+
 static __always_inline int rate_limit_check(struct packet_ctx *pkt,
                                              struct global_stats *stats,
                                              __u64 now_ns)
 {
     __u64 rate_pps;
+    __u64 dst_rate_pps = 0;
     __u32 cfg_key;
+    struct dst_policy *dp = pkt->dst_policy;
+    __u32 class_id = 0;
+    struct rate_class *rc = rate_class_lookup(pkt, &class_id);
+
+    if (rc) {
+        /* Class-wide limits apply to every protocol */
+        if ((rc->pps && !rate_class_consume(class_id, RATE_CLASS_BUCKET_PPS, rc->pps, 1, now_ns)) ||
+            (rc->bps && !rate_class_consume(class_id, RATE_CLASS_BUCKET_BPS, rc->bps / 8, pkt->pkt_len, now_ns))) {
+            if (stats)
+                stats->rate_limited++;
+            emit_event(pkt, ATTACK_NONE, 1, DROP_RATE_LIMIT, 0, 0);
+            return VERDICT_DROP;
+        }
+    }
 
     switch (pkt->ip_proto) {
     case IPPROTO_TCP:
         cfg_key = CFG_SYN_RATE_PPS;
+        if (dp)
+            dst_rate_pps = dp->syn_rate_pps;
+        if (!dst_rate_pps && rc)
+            dst_rate_pps = rc->syn_rate_pps;
         break;
     case IPPROTO_UDP:
         cfg_key = CFG_UDP_RATE_PPS;
+        if (dp)
+            dst_rate_pps = dp->udp_rate_pps;
+        if (!dst_rate_pps && rc)
+            dst_rate_pps = rc->udp_rate_pps;
         break;
     case IPPROTO_ICMP:
         cfg_key = CFG_ICMP_RATE_PPS;
+        if (dp)
+            dst_rate_pps = dp->icmp_rate_pps;
+        if (!dst_rate_pps && rc)
+            dst_rate_pps = rc->icmp_rate_pps;
         break;
     default:
         return VERDICT_PASS;
     }
 
-    rate_pps = get_config(cfg_key);
+    rate_pps = dst_rate_pps ? dst_rate_pps : get_config(cfg_key);
     if (rate_pps == 0)
         return VERDICT_PASS; /* Not configured = no limit */
 
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: GPL-2.0
+#ifndef __MOD_REINJECT_H__
+#define __MOD_REINJECT_H__
+
+#include "../common/types.h"
+#include "../common/maps.h"
+#include "../common/helpers.h"
+
+/* ===== Clean Traffic Re-injection Module =====
+ *
+ * In diversion mode the upstream routes victim prefixes to the scrubber,
+ * so passing traffic to the local stack would loop it straight back.
+ * Packets that survive the pipeline are instead wrapped in GRE
+ * (RFC 2784, no key or checksum) towards the tunnel endpoint of their
+ * destination in gre_tunnels and sent back out with XDP_TX.
+ *
+ * Only untagged frames are re-injected; VLAN-tagged traffic passes.
+ */
+
+#define GRE_PROTO_IPV4  0x0800
+
+struct gre_base_hdr {
+    __be16 flags;
+    __be16 protocol;
+};
+
+#define REINJECT_ENCAP_LEN (sizeof(struct iphdr) + sizeof(struct gre_base_hdr))
+
+static __always_inline int gre_reinject(struct xdp_md *ctx,
+                                        struct packet_ctx *pkt)
+{
+    struct lpm_key_v4 key = {};
+    struct ethhdr eth_orig;
+    __be32 local, remote, *endpoint;
+
+    if (!get_config(CFG_DIVERSION_ENABLE))
+        return VERDICT_PASS;
+    local = (__be32)get_config(CFG_GRE_LOCAL_IP);
+    if (!local)
+        return VERDICT_PASS;
+
+    key.prefixlen = 32;
+    key.addr = pkt->dst_ip;
+    endpoint = bpf_map_lookup_elem(&gre_tunnels, &key);
+    if (!endpoint)
+        return VERDICT_PASS;
+    remote = *endpoint;
+
+    void *data = (void *)(long)ctx->data;
+    void *data_end = (void *)(long)ctx->data_end;
+    struct ethhdr *eth = data;
+    if ((void *)(eth + 1) > data_end)
+        return VERDICT_PASS;
+    if (eth->h_proto != bpf_htons(0x0800))
+        return VERDICT_PASS;
+    __builtin_memcpy(&eth_orig, eth, sizeof(eth_orig));
+
+    if (bpf_xdp_adjust_head(ctx, -(int)REINJECT_ENCAP_LEN))
+        return VERDICT_PASS;
+
+    /* Pointers are invalid after adjust_head; re-derive them. */
+    data = (void *)(long)ctx->data;
+    data_end = (void *)(long)ctx->data_end;
+    eth = data;
+    struct iphdr *outer = (void *)(eth + 1);
+    struct gre_base_hdr *gre = (void *)(outer + 1);
+    if ((void *)(gre + 1) > data_end)
+        return VERDICT_DROP;
+
+    /* Send back to the router that delivered the packet. */
+    __builtin_memcpy(eth->h_dest, eth_orig.h_source, 6);
+    __builtin_memcpy(eth->h_source, eth_orig.h_dest, 6);
+    eth->h_proto = bpf_htons(0x0800);
+
+    outer->version = 4;
+    outer->ihl = 5;
+    outer->tos = 0;
+    outer->tot_len = bpf_htons(pkt->pkt_len + REINJECT_ENCAP_LEN);
+    outer->id = 0;
+    outer->frag_off = bpf_htons(0x4000); /* DF */
+    outer->ttl = 64;
+    outer->protocol = IPPROTO_GRE;
+    outer->check = 0;
+    outer->saddr = local;
+    outer->daddr = remote;
+
+    __u32 csum = 0;
+    __u16 *p = (__u16 *)outer;
+    #pragma unroll
+    for (int i = 0; i < 10; i++)
+        csum += p[i];
+    outer->check = csum_fold(csum);
+
+    gre->flags = 0;
+    gre->protocol = bpf_htons(GRE_PROTO_IPV4);
+
+    return VERDICT_TX;
+}
+
+#endif /* __MOD_REINJECT_H__ */
@@ -103,6 +103,16 @@ static __always_inline int syn_flood_check(struct xdp_md *ctx,
     if (!syn_cookie_enabled)
         return VERDICT_PASS;
 
+    /* Scoped mode: only protected destination prefixes get cookies */
+    if (get_config(CFG_SYN_COOKIE_SCOPED)) {
+        struct lpm_key_v4 dst_key = {
+            .prefixlen = 32,
+            .addr = pkt->dst_ip,
+        };
+        if (!bpf_map_lookup_elem(&syn_cookie_dst, &dst_key))
+            return VERDICT_PASS;
+    }
+
     /* ---- Handle incoming SYN ---- */
     if ((pkt->tcp_flags & (TCP_FLAG_SYN | TCP_FLAG_ACK)) == TCP_FLAG_SYN) {
         __u32 zero = 0;
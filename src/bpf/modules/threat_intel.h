@@ -26,6 +26,9 @@
  * The LPM trie supports both /32 exact matches and CIDR prefixes for
  * blocking entire ranges published by threat feeds.
  *
+ * Matches and their outcome are counted per feed in threat_intel_stats,
+ * keyed by source_id.
+ *
  * Returns:
  *   VERDICT_PASS - Not in threat intel, or below confidence threshold
  *   VERDICT_DROP - Known-bad IP above confidence threshold with action=drop
@@ -41,7 +44,7 @@ static __always_inline int threat_intel_check(struct packet_ctx *pkt,
     if (!get_config(CFG_THREAT_INTEL_EN))
         return VERDICT_PASS;
 
-    __u64 escalation = get_config(CFG_ESCALATION_LEVEL);
+    __u64 escalation = escalation_level(pkt);
 
     /* Build LPM trie key for source IP lookup */
     struct lpm_key_v4 lpm_key = {
@@ -55,6 +58,12 @@ static __always_inline int threat_intel_check(struct packet_ctx *pkt,
     if (!entry)
         return VERDICT_PASS;
 
+    __u32 source = entry->source_id;
+    struct threat_intel_feed_stats *fs;
+    fs = bpf_map_lookup_elem(&threat_intel_stats, &source);
+    if (fs)
+        fs->matched++;
+
     /*
      * Determine confidence thresholds based on escalation level.
      * Higher escalation means we act on lower confidence scores,
@@ -81,6 +90,8 @@ static __always_inline int threat_intel_check(struct packet_ctx *pkt,
     switch (action) {
     case 0: /* Drop */
         if (confidence >= drop_threshold) {
+            if (fs)
+                fs->dropped++;
             if (stats) {
                 stats->threat_intel_dropped++;
                 stats_drop(stats, pkt->pkt_len);
@@ -93,6 +104,8 @@ static __always_inline int threat_intel_check(struct packet_ctx *pkt,
     case 1: {
         /* Rate-limit: mark source IP for stricter rate limiting */
         if (confidence >= rate_limit_threshold) {
+            if (fs)
+                fs->rate_limited++;
             __u64 *existing_rate;
             existing_rate = bpf_map_lookup_elem(&adaptive_rate_map, &pkt->src_ip);
 
@@ -128,6 +141,8 @@ static __always_inline int threat_intel_check(struct packet_ctx *pkt,
 
     case 2:
         /* Monitor: log the match for visibility but allow the packet */
+        if (fs)
+            fs->monitored++;
         emit_event(pkt, ATTACK_THREAT_INTEL, 0, 0, 0, 0);
         return VERDICT_PASS;
 
@@ -8,6 +8,7 @@
  *   3.  Threat intelligence feed check
  *   4.  GeoIP country-based filtering
  *   5.  IP Reputation score check
+ *   5b. Port scan detection
  *   6.  IP Fragment detection
  *   7.  Attack signature fingerprint matching
  *   8.  Payload pattern matching
@@ -17,10 +18,22 @@
  *  12.  ACK Flood detection (requires conntrack)
  *  13.  UDP Flood & Amplification detection
  *  14.  ICMP Flood mitigation
- *  15.  Per-source rate limiting (adaptive)
+ *  15.  Per-source and rate class limiting (adaptive)
  *  16.  Global rate limiting
+ *  16b. Per-source connection limit
+ *  16c. TLS ClientHello and QUIC Initial rate per source
  *  17.  Connection tracking update
- *  18.  Statistics update → XDP_PASS
+ *  17b. Deep inspection: selected traffic redirected (XDP_REDIRECT) to
+ *       the AF_XDP inspector
+ *  18.  Statistics update → XDP_PASS, or GRE re-injection (XDP_TX) of
+ *       clean traffic in diversion mode
+ *
+ * Every parsed packet is then accounted against its source in top_talkers
+ * and, when capture is enabled, sampled onto capture_events.
+ *
+ * The same object carries tc_egress_scrubber, attached to TC egress when
+ * outbound scrubbing is enabled: it drops spoofed sources and limits
+ * amplification-prone UDP responses leaving the protected network.
  */
 
 #include "common/types.h"
@@ -31,7 +44,9 @@
 #include "modules/acl.h"
 #include "modules/threat_intel.h"
 #include "modules/geoip.h"
+#include "modules/asn.h"
 #include "modules/reputation.h"
+#include "modules/port_scan.h"
 #include "modules/fragment.h"
 #include "modules/fingerprint.h"
 #include "modules/payload_match.h"
@@ -41,82 +56,92 @@
 #include "modules/udp_flood.h"
 #include "modules/icmp_flood.h"
 #include "modules/rate_limiter.h"
+#include "modules/conn_limit.h"
+#include "modules/handshake_flood.h"
 #include "modules/conntrack.h"
+#include "modules/capture.h"
+#include "modules/reinject.h"
+#include "modules/deep_inspect.h"
+#include "modules/egress.h"
 
 char _license[] SEC("license") = "GPL";
 
-SEC("xdp")
-int xdp_ddos_scrubber(struct xdp_md *ctx)
+/*
+ * Stages 2-18. Returns the XDP action for an already-parsed packet so the
+ * caller can account it against its source regardless of which stage
+ * decided the verdict.
+ */
+static __always_inline int scrub_pipeline(struct xdp_md *ctx,
+                                          struct packet_ctx *pkt,
+                                          struct global_stats *stats,
+                                          __u64 now_ns)
 {
-    struct packet_ctx pkt = {};
-    struct global_stats *stats;
     int verdict;
-    __u64 now_ns = bpf_ktime_get_ns();
-
-    /* ---- Check if scrubber is enabled ---- */
-    __u64 enabled = get_config(CFG_ENABLED);
-    if (!enabled)
-        return XDP_PASS;
-
-    /* ---- Get per-CPU stats ---- */
-    stats = get_stats();
-
-    /* ---- Stage 1: Parse packet ---- */
-    if (parse_packet(ctx, &pkt) < 0) {
-        /* Malformed packet — count and drop */
-        stats_drop(stats, 0);
-        emit_event(&pkt, ATTACK_NONE, 1, DROP_PARSE_ERROR, 0, 0);
-        return XDP_DROP;
-    }
 
-    /* Record RX stats */
-    stats_rx(stats, pkt.pkt_len);
+    /* Protected prefix policy of the destination, used by later stages */
+    struct lpm_key_v4 dst_key = {
+        .prefixlen = 32,
+        .addr = pkt->dst_ip,
+    };
+    pkt->dst_policy = bpf_map_lookup_elem(&dst_policy_map, &dst_key);
+    __u32 *dst_level = bpf_map_lookup_elem(&dst_escalation, &dst_key);
+    pkt->dst_level = dst_level ? *dst_level : 0;
 
     /* ---- Stage 2: ACL (Whitelist/Blacklist) ---- */
-    verdict = acl_check(&pkt, stats);
+    verdict = acl_check(pkt, stats);
     if (verdict == VERDICT_DROP)
         return XDP_DROP;
     if (verdict == VERDICT_BYPASS) {
         /* Whitelisted source — skip all checks */
-        stats_tx(stats, pkt.pkt_len);
+        stats_tx(stats, pkt->pkt_len);
         return XDP_PASS;
     }
 
     /* ---- Stage 3: Threat Intelligence Feed ---- */
-    verdict = threat_intel_check(&pkt, stats);
+    verdict = threat_intel_check(pkt, stats);
     if (verdict == VERDICT_DROP) {
-        stats_drop(stats, pkt.pkt_len);
+        stats_drop(stats, pkt->pkt_len);
         return XDP_DROP;
     }
 
     /* ---- Stage 4: GeoIP Country Filtering ---- */
-    verdict = geoip_check(&pkt, stats);
+    verdict = geoip_check(pkt, stats, now_ns);
     if (verdict == VERDICT_DROP) {
-        stats_drop(stats, pkt.pkt_len);
+        stats_drop(stats, pkt->pkt_len);
+        return XDP_DROP;
+    }
+
+    /* ---- Stage 4b: ASN Filtering ---- */
+    verdict = asn_check(pkt, stats);
+    if (verdict == VERDICT_DROP) {
+        stats_drop(stats, pkt->pkt_len);
         return XDP_DROP;
     }
 
     /* ---- Stage 5: IP Reputation Check ---- */
-    verdict = reputation_check(&pkt, stats, now_ns);
+    verdict = reputation_check(pkt, stats, now_ns);
     if (verdict == VERDICT_DROP) {
-        stats_drop(stats, pkt.pkt_len);
+        stats_drop(stats, pkt->pkt_len);
         return XDP_DROP;
     }
 
+    /* ---- Stage 5b: Port Scan Detection ---- */
+    port_scan_check(pkt, stats, now_ns);
+
     /* ---- Stage 6: Fragment detection ---- */
-    verdict = fragment_check(ctx, &pkt, stats);
+    verdict = fragment_check(ctx, pkt, stats);
     if (verdict == VERDICT_DROP)
         return XDP_DROP;
 
     /* ---- Stage 7: Attack signature fingerprint ---- */
-    verdict = fingerprint_check(&pkt, stats);
+    verdict = fingerprint_check(pkt, stats);
     if (verdict == VERDICT_DROP)
         return XDP_DROP;
 
     /* ---- Stage 8: Payload Pattern Matching ---- */
-    verdict = payload_match_check(ctx, &pkt, stats);
+    verdict = payload_match_check(ctx, pkt, stats);
     if (verdict == VERDICT_DROP) {
-        stats_drop(stats, pkt.pkt_len);
+        stats_drop(stats, pkt->pkt_len);
         return XDP_DROP;
     }
 
@@ -126,63 +151,136 @@ int xdp_ddos_scrubber(struct xdp_md *ctx)
      * for packet pointers after stack spills. Re-enable when upgrading
      * to kernel 5.17+ which has improved range tracking.
      *
-     * verdict = proto_validate(ctx, &pkt, stats, now_ns);
+     * verdict = proto_validate(ctx, pkt, stats, now_ns);
      * if (verdict == VERDICT_DROP) {
-     *     stats_drop(stats, pkt.pkt_len);
+     *     stats_drop(stats, pkt->pkt_len);
      *     return XDP_DROP;
      * }
      */
 
     /* ---- Stage 11: SYN Flood (SYN Cookie) ---- */
-    verdict = syn_flood_check(ctx, &pkt, stats, now_ns);
+    verdict = syn_flood_check(ctx, pkt, stats, now_ns);
     if (verdict == VERDICT_TX) {
-        stats_tx(stats, pkt.pkt_len);
+        stats_tx(stats, pkt->pkt_len);
         return XDP_TX;
     }
     if (verdict == VERDICT_DROP) {
-        stats_drop(stats, pkt.pkt_len);
+        stats_drop(stats, pkt->pkt_len);
         return XDP_DROP;
     }
 
     /* ---- Stage 12: ACK Flood ---- */
-    verdict = ack_flood_check(&pkt, stats, now_ns);
+    verdict = ack_flood_check(pkt, stats, now_ns);
     if (verdict == VERDICT_DROP) {
-        stats_drop(stats, pkt.pkt_len);
+        stats_drop(stats, pkt->pkt_len);
         return XDP_DROP;
     }
 
     /* ---- Stage 13: UDP Flood & Amplification ---- */
-    verdict = udp_flood_check(&pkt, stats, now_ns);
+    verdict = udp_flood_check(pkt, stats, now_ns);
     if (verdict == VERDICT_DROP) {
-        stats_drop(stats, pkt.pkt_len);
+        stats_drop(stats, pkt->pkt_len);
         return XDP_DROP;
     }
 
     /* ---- Stage 14: ICMP Flood ---- */
-    verdict = icmp_flood_check(ctx, &pkt, stats);
+    verdict = icmp_flood_check(ctx, pkt, stats);
     if (verdict == VERDICT_DROP) {
-        stats_drop(stats, pkt.pkt_len);
+        stats_drop(stats, pkt->pkt_len);
         return XDP_DROP;
     }
 
     /* ---- Stage 15: Per-Source Rate Limiting (Adaptive) ---- */
-    verdict = rate_limit_check(&pkt, stats, now_ns);
+    verdict = rate_limit_check(pkt, stats, now_ns);
     if (verdict == VERDICT_DROP) {
-        stats_drop(stats, pkt.pkt_len);
+        stats_drop(stats, pkt->pkt_len);
         return XDP_DROP;
     }
 
     /* ---- Stage 16: Global Rate Limiting ---- */
-    verdict = global_rate_check(&pkt, stats, now_ns);
+    verdict = global_rate_check(pkt, stats, now_ns);
+    if (verdict == VERDICT_DROP) {
+        stats_drop(stats, pkt->pkt_len);
+        return XDP_DROP;
+    }
+
+    /* ---- Stage 16b: Per-Source Connection Limit ---- */
+    verdict = conn_limit_check(pkt, stats);
+    if (verdict == VERDICT_DROP) {
+        stats_drop(stats, pkt->pkt_len);
+        return XDP_DROP;
+    }
+
+    /* ---- Stage 16c: TLS / QUIC Handshake Floods ---- */
+    verdict = handshake_flood_check(ctx, pkt, stats, now_ns);
     if (verdict == VERDICT_DROP) {
-        stats_drop(stats, pkt.pkt_len);
+        stats_drop(stats, pkt->pkt_len);
         return XDP_DROP;
     }
 
     /* ---- Stage 17: Connection Tracking ---- */
-    conntrack_update(&pkt, stats, now_ns);
+    conntrack_update(pkt, stats, now_ns);
+
+    /* ---- Stage 17b: Deep Inspection (AF_XDP) ---- */
+    verdict = deep_inspect(ctx, pkt, now_ns);
+    if (verdict == VERDICT_REDIR)
+        return XDP_REDIRECT;
 
-    /* ---- Stage 18: Pass ---- */
-    stats_tx(stats, pkt.pkt_len);
+    /* ---- Stage 18: Pass (or re-inject when diverting) ---- */
+    stats_tx(stats, pkt->pkt_len);
+    verdict = gre_reinject(ctx, pkt);
+    if (verdict == VERDICT_TX)
+        return XDP_TX;
+    if (verdict == VERDICT_DROP)
+        return XDP_DROP;
     return XDP_PASS;
 }
+
+SEC("xdp")
+int xdp_ddos_scrubber(struct xdp_md *ctx)
+{
+    struct packet_ctx pkt = {};
+    struct global_stats *stats;
+    int action;
+    __u64 now_ns = bpf_ktime_get_ns();
+
+    /* ---- Check if scrubber is enabled ---- */
+    __u64 enabled = get_config(CFG_ENABLED);
+    if (!enabled)
+        return XDP_PASS;
+
+    /* ---- Get per-CPU stats ---- */
+    stats = get_stats();
+
+    /* ---- Stage 1: Parse packet ---- */
+    if (parse_packet(ctx, &pkt) < 0) {
+        /* Malformed packet — count and drop */
+        stats_drop(stats, 0);
+        emit_event(&pkt, ATTACK_NONE, 1, DROP_PARSE_ERROR, 0, 0);
+        capture_packet(ctx, XDP_DROP, now_ns);
+        return XDP_DROP;
+    }
+
+    /* Record RX stats */
+    stats_rx(stats, pkt.pkt_len);
+    stats_rx_proto(stats, &pkt);
+
+    /* ---- Stages 2-18, then per-source and per-destination accounting ---- */
+    action = scrub_pipeline(ctx, &pkt, stats, now_ns);
+    talker_account(&pkt, action, now_ns);
+    dst_policy_account(&pkt, action);
+    dst_stats_account(&pkt, action);
+    capture_packet(ctx, action, now_ns);
+    return action;
+}
+
+SEC("tc")
+int tc_egress_scrubber(struct __sk_buff *skb)
+{
+    if (!get_config(CFG_ENABLED))
+        return TC_ACT_OK;
+
+    if (egress_scrub(skb, egress_get_stats(), bpf_ktime_get_ns()) == VERDICT_DROP)
+        return TC_ACT_SHOT;
+    return TC_ACT_OK;
+}
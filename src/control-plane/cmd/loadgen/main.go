@@ -0,0 +1,290 @@
+// Command loadgen sends synthetic attack traffic toward a scrubber to
+// validate mitigation and measure XDP throughput before production.
+//
+// Usage:
+//
+//	loadgen -iface IF -dst-mac MAC -dst IP [flags]
+//
+// Frames are written to a raw packet socket on -iface, which must face the
+// scrubber's XDP interface (directly, through a switch, or as the peer of
+// a veth pair). Sending needs root or CAP_NET_RAW. Vectors:
+//
+//	syn        TCP SYN flood
+//	udp        UDP flood
+//	dns        DNS amplification responses (source port 53)
+//	ntp        NTP monlist responses (source port 123)
+//	ssdp       SSDP responses (source port 1900)
+//	memcached  memcached responses (source port 11211)
+//
+// With -api the scrubber's receive and drop rates are polled alongside the
+// send rate, and the drops attributed to the run are summarized at the end.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/pktgen"
+	"golang.org/x/sys/unix"
+)
+
+// Frames prebuilt per worker and sent in rotation.
+const ringSize = 4096
+
+func main() {
+	var (
+		iface    = flag.String("iface", "", "Interface to send on")
+		dstMAC   = flag.String("dst-mac", "", "MAC address of the scrubber interface")
+		dst      = flag.String("dst", "", "Destination IPv4 address (the protected host)")
+		dstPort  = flag.Uint("dst-port", 0, "Destination port (0 varies it per packet)")
+		vector   = flag.String("vector", pktgen.VectorSYN, "Attack vector: "+strings.Join(pktgen.Vectors(), ", "))
+		sources  = flag.String("sources", "198.51.100.0/24", "Prefix the source addresses cycle through")
+		size     = flag.Int("size", 0, "Payload size in bytes (0 = vector default)")
+		pps      = flag.Int("pps", 10000, "Packets per second in total (0 = as fast as possible)")
+		duration = flag.Duration("duration", 10*time.Second, "How long to send")
+		workers  = flag.Int("workers", 1, "Sending goroutines, each with its own socket")
+		apiURL   = flag.String("api", "", "Scrubber API base URL for drop rates, e.g. http://scrubber:9090")
+		apiKey   = flag.String("api-key", "", "API key for -api (or SCRUBBER_API_KEY)")
+	)
+	flag.Parse()
+
+	if err := run(config{
+		iface: *iface, dstMAC: *dstMAC, dst: *dst, dstPort: *dstPort,
+		vector: *vector, sources: *sources, size: *size, pps: *pps,
+		duration: *duration, workers: *workers, apiURL: *apiURL, apiKey: *apiKey,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+type config struct {
+	iface, dstMAC, dst string
+	dstPort            uint
+	vector, sources    string
+	size, pps          int
+	duration           time.Duration
+	workers            int
+	apiURL, apiKey     string
+}
+
+func run(cfg config) error {
+	if cfg.iface == "" || cfg.dstMAC == "" || cfg.dst == "" {
+		return fmt.Errorf("-iface, -dst-mac and -dst are required")
+	}
+	if cfg.workers <= 0 || cfg.pps < 0 || cfg.duration <= 0 || cfg.dstPort > 65535 {
+		return fmt.Errorf("-workers and -duration must be positive, -pps not negative and -dst-port at most 65535")
+	}
+	ifc, err := net.InterfaceByName(cfg.iface)
+	if err != nil {
+		return err
+	}
+	mac, err := net.ParseMAC(cfg.dstMAC)
+	if err != nil {
+		return fmt.Errorf("invalid -dst-mac: %w", err)
+	}
+	dstIP := net.ParseIP(cfg.dst)
+	if dstIP == nil {
+		return fmt.Errorf("invalid -dst %q", cfg.dst)
+	}
+	_, srcNet, err := net.ParseCIDR(cfg.sources)
+	if err != nil {
+		return fmt.Errorf("invalid -sources: %w", err)
+	}
+	gen, err := pktgen.NewGenerator(cfg.vector, srcNet, dstIP, uint16(cfg.dstPort), cfg.size)
+	if err != nil {
+		return err
+	}
+	if cfg.apiKey == "" {
+		cfg.apiKey = os.Getenv("SCRUBBER_API_KEY")
+	}
+	var api *scrubberAPI
+	if cfg.apiURL != "" {
+		api = &scrubberAPI{base: strings.TrimRight(cfg.apiURL, "/"), key: cfg.apiKey, http: &http.Client{Timeout: 2 * time.Second}}
+	}
+
+	socks := make([]int, cfg.workers)
+	for i := range socks {
+		if socks[i], err = openSocket(ifc.Index); err != nil {
+			return err
+		}
+		defer unix.Close(socks[i])
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, cfg.duration)
+	defer cancel()
+
+	var before map[string]float64
+	if api != nil {
+		if before, err = api.stats(); err != nil {
+			return fmt.Errorf("reading scrubber stats: %w", err)
+		}
+	}
+
+	fmt.Printf("Sending %s from %s (%d sources) to %s via %s for %v\n",
+		cfg.vector, srcNet, gen.Sources(), dstIP, cfg.iface, cfg.duration)
+
+	var sent, sentBytes, failed atomic.Uint64
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w, fd := range socks {
+		ring := make([][]byte, ringSize)
+		for i := range ring {
+			// Workers interleave so their sources do not overlap.
+			ring[i] = pktgen.Ethernet(mac, ifc.HardwareAddr, gen.Packet(i*len(socks)+w))
+		}
+		rate := cfg.pps / len(socks)
+		if cfg.pps > 0 && w < cfg.pps%len(socks) {
+			rate++
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			send(ctx, fd, ring, rate, &sent, &sentBytes, &failed)
+		}()
+	}
+
+	progress := time.NewTicker(time.Second)
+	defer progress.Stop()
+	var lastSent, lastBytes uint64
+	last := start
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case now := <-progress.C:
+			n, b := sent.Load(), sentBytes.Load()
+			dt := now.Sub(last).Seconds()
+			line := fmt.Sprintf("sent %10.0f pps %8.1f Mbps", float64(n-lastSent)/dt, float64(b-lastBytes)*8/dt/1e6)
+			if api != nil {
+				if st, err := api.stats(); err == nil {
+					line += fmt.Sprintf("  scrubber rx %10.0f pps drop %10.0f pps", st["rxPps"], st["dropPps"])
+				}
+			}
+			fmt.Println(line)
+			lastSent, lastBytes, last = n, b, now
+		}
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	total := sent.Load()
+	fmt.Printf("\nSent %d packets in %v (%.0f pps, %.1f Mbps), %d send errors\n",
+		total, elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds(),
+		float64(sentBytes.Load())*8/elapsed.Seconds()/1e6, failed.Load())
+
+	if api != nil {
+		// Let the scrubber's once-a-second stats catch up.
+		time.Sleep(2 * time.Second)
+		after, err := api.stats()
+		if err != nil {
+			return fmt.Errorf("reading scrubber stats: %w", err)
+		}
+		rx := after["rxPackets"] - before["rxPackets"]
+		dropped := after["droppedPackets"] - before["droppedPackets"]
+		fmt.Printf("Scrubber received %.0f and dropped %.0f packets", rx, dropped)
+		if total > 0 {
+			fmt.Printf(" (%.1f%% of sent)", dropped*100/float64(total))
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// openSocket opens a raw packet socket bound to the interface, bypassing
+// the qdisc layer where the kernel allows it.
+func openSocket(ifindex int) (int, error) {
+	proto := int(htons(unix.ETH_P_ALL))
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, proto)
+	if err != nil {
+		return -1, fmt.Errorf("opening packet socket (needs CAP_NET_RAW): %w", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: uint16(proto), Ifindex: ifindex}); err != nil {
+		unix.Close(fd)
+		return -1, fmt.Errorf("binding packet socket: %w", err)
+	}
+	unix.SetsockoptInt(fd, unix.SOL_PACKET, unix.PACKET_QDISC_BYPASS, 1)
+	return fd, nil
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// send writes frames from ring until ctx is done, at pps if positive.
+// Paced sends go out in 1ms batches.
+func send(ctx context.Context, fd int, ring [][]byte, pps int, sent, sentBytes, failed *atomic.Uint64) {
+	const tick = time.Millisecond
+	var ticker *time.Ticker
+	perTick := 64 // Batch between context checks when unpaced
+	if pps > 0 {
+		ticker = time.NewTicker(tick)
+		defer ticker.Stop()
+		perTick = max(pps/int(time.Second/tick), 1)
+	}
+
+	// Carry fractional batches so low rates are not rounded up to 1000 pps.
+	var owed float64
+	i := 0
+	for ctx.Err() == nil {
+		n := perTick
+		if pps > 0 {
+			owed += float64(pps) / float64(time.Second/tick)
+			n = int(owed)
+			owed -= float64(n)
+		}
+		for j := 0; j < n; j++ {
+			f := ring[i%len(ring)]
+			i++
+			if _, err := unix.Write(fd, f); err != nil {
+				failed.Add(1)
+				continue
+			}
+			sent.Add(1)
+			sentBytes.Add(uint64(len(f)))
+		}
+		if ticker != nil {
+			select {
+			case <-ctx.Done():
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// scrubberAPI reads the scrubber's stats.
+type scrubberAPI struct {
+	base, key string
+	http      *http.Client
+}
+
+func (a *scrubberAPI) stats() (map[string]float64, error) {
+	req, err := http.NewRequest(http.MethodGet, a.base+"/api/v1/stats", nil)
+	if err != nil {
+		return nil, err
+	}
+	if a.key != "" {
+		req.Header.Set("Authorization", "Bearer "+a.key)
+	}
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET /api/v1/stats: HTTP %d", resp.StatusCode)
+	}
+	var st map[string]float64
+	return st, json.NewDecoder(resp.Body).Decode(&st)
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/compat"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/output"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// versionInfo is the JSON schema of the version command.
+type versionInfo struct {
+	Version   string `json:"version"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+}
+
+// validateResult is the JSON schema of the validate command.
+type validateResult struct {
+	Path   string   `json:"path"`
+	Valid  bool     `json:"valid"`
+	Error  string   `json:"error,omitempty"`
+	Errors []string `json:"errors,omitempty"` // Every problem found, one per entry
+}
+
+// mapInfo describes one map in the BPF object (maps command).
+type mapInfo struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	KeySize    uint32 `json:"keySize"`
+	ValueSize  uint32 `json:"valueSize"`
+	MaxEntries uint32 `json:"maxEntries"`
+}
+
+// programInfo describes one program in the BPF object (maps command).
+type programInfo struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Section string `json:"section"`
+}
+
+// objectInfo is the JSON schema of the maps command.
+type objectInfo struct {
+	Object   string        `json:"object"`
+	Programs []programInfo `json:"programs"`
+	Maps     []mapInfo     `json:"maps"`
+}
+
+// statusInfo is the JSON schema of the status command, mirroring
+// GET /api/v1/status.
+type statusInfo struct {
+	Enabled         bool   `json:"enabled"`
+	InterfaceName   string `json:"interfaceName"`
+	XDPMode         string `json:"xdpMode"`
+	ProgramID       uint32 `json:"programId"`
+	UptimeSeconds   int64  `json:"uptimeSeconds"`
+	Version         string `json:"version"`
+	EscalationLevel uint64 `json:"escalationLevel"`
+	PipelineStages  int    `json:"pipelineStages"`
+}
+
+// doctorReport is the JSON schema of the doctor command.
+type doctorReport struct {
+	Kernel    string           `json:"kernel"`
+	Arch      string           `json:"arch"`
+	Interface string           `json:"interface"`
+	XDPMode   string           `json:"xdpMode"`
+	Usable    bool             `json:"usable"`
+	Features  []compat.Feature `json:"features"`
+}
+
+// verifyResult is the JSON schema of the verify command.
+type verifyResult struct {
+	Object          string           `json:"object"`
+	Kernel          string           `json:"kernel"`
+	Loaded          bool             `json:"loaded"`
+	Error           string           `json:"error,omitempty"`
+	VerifierLog     []string         `json:"verifierLog,omitempty"`
+	LogLines        int              `json:"logLines,omitempty"`
+	LogTruncated    bool             `json:"logTruncated,omitempty"`
+	MissingFeatures []compat.Feature `json:"missingFeatures"`
+}
+
+// errReported is returned by a command whose result, printed in the
+// requested format, already describes the failure.
+var errReported = errors.New("failure reported in command output")
+
+// exitOn terminates the process after an informational command.
+func exitOn(format output.Format, err error) {
+	if err != nil {
+		if !errors.Is(err, errReported) {
+			output.PrintError(os.Stdout, os.Stderr, format, err)
+		}
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func cmdVersion(format output.Format) error {
+	info := versionInfo{
+		Version:   version,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+	}
+	return output.Print(os.Stdout, format, info, func(w io.Writer) {
+		fmt.Fprintf(w, "ddos-scrubber %s (built %s)\n", info.Version, info.BuildTime)
+	})
+}
+
+func cmdValidate(format output.Format, path string) error {
+	res := validateResult{Path: path, Valid: true}
+	if _, err := config.LoadFromFile(path, overrides...); err != nil {
+		res.Valid = false
+		res.Error = err.Error()
+		res.Errors = strings.Split(res.Error, "\n")
+	}
+
+	if err := output.Print(os.Stdout, format, res, func(w io.Writer) {
+		if res.Valid {
+			fmt.Fprintf(w, "%s: configuration is valid\n", res.Path)
+			return
+		}
+		fmt.Fprintf(w, "%s: configuration is invalid\n", res.Path)
+		for _, e := range res.Errors {
+			fmt.Fprintf(w, "  %s\n", e)
+		}
+	}); err != nil {
+		return err
+	}
+	if !res.Valid {
+		return errReported
+	}
+	return nil
+}
+
+func cmdDump(format output.Format, path string) error {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("marshaling config: %w", err)
+	}
+
+	// Round-trip through a generic map so JSON keys match the YAML schema.
+	var generic map[string]interface{}
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return fmt.Errorf("converting config: %w", err)
+	}
+
+	return output.Print(os.Stdout, format, generic, func(w io.Writer) {
+		w.Write(data)
+	})
+}
+
+func cmdMaps(format output.Format, path string) error {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	spec, err := ebpf.LoadCollectionSpec(cfg.BPFObject)
+	if err != nil {
+		return fmt.Errorf("loading collection spec: %w", err)
+	}
+
+	info := objectInfo{Object: cfg.BPFObject}
+	for name, p := range spec.Programs {
+		info.Programs = append(info.Programs, programInfo{
+			Name:    name,
+			Type:    p.Type.String(),
+			Section: p.SectionName,
+		})
+	}
+	for name, m := range spec.Maps {
+		info.Maps = append(info.Maps, mapInfo{
+			Name:       name,
+			Type:       m.Type.String(),
+			KeySize:    m.KeySize,
+			ValueSize:  m.ValueSize,
+			MaxEntries: m.MaxEntries,
+		})
+	}
+	sort.Slice(info.Programs, func(i, j int) bool { return info.Programs[i].Name < info.Programs[j].Name })
+	sort.Slice(info.Maps, func(i, j int) bool { return info.Maps[i].Name < info.Maps[j].Name })
+
+	return output.Print(os.Stdout, format, info, func(w io.Writer) {
+		fmt.Fprintf(w, "Object: %s\n\nPrograms:\n", info.Object)
+		for _, p := range info.Programs {
+			fmt.Fprintf(w, "  %-24s %-12s %s\n", p.Name, p.Type, p.Section)
+		}
+		fmt.Fprintf(w, "\nMaps:\n")
+		for _, m := range info.Maps {
+			fmt.Fprintf(w, "  %-24s %-20s key=%-3d value=%-4d max=%d\n",
+				m.Name, m.Type, m.KeySize, m.ValueSize, m.MaxEntries)
+		}
+	})
+}
+
+func cmdStatus(format output.Format, path, listenOverride string) error {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	addr := cfg.API.Listen
+	if listenOverride != "" {
+		addr = listenOverride
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + dialAddr(addr) + "/api/v1/status")
+	if err != nil {
+		return fmt.Errorf("querying scrubber API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("scrubber API returned HTTP %d", resp.StatusCode)
+	}
+
+	var st statusInfo
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return fmt.Errorf("decoding status: %w", err)
+	}
+
+	return output.Print(os.Stdout, format, st, func(w io.Writer) {
+		fmt.Fprintf(w, "Enabled:          %t\n", st.Enabled)
+		fmt.Fprintf(w, "Interface:        %s (%s)\n", st.InterfaceName, st.XDPMode)
+		fmt.Fprintf(w, "Version:          %s\n", st.Version)
+		fmt.Fprintf(w, "Uptime:           %s\n", time.Duration(st.UptimeSeconds)*time.Second)
+		fmt.Fprintf(w, "Escalation level: %d\n", st.EscalationLevel)
+	})
+}
+
+func cmdDoctor(format output.Format, path, ifaceOverride, modeOverride string) error {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	if ifaceOverride != "" {
+		cfg.Interface = ifaceOverride
+	}
+	if modeOverride != "" {
+		cfg.XDPMode = modeOverride
+	}
+
+	f := compat.Detect()
+	var nativeErr error
+	if cfg.XDPMode == "native" {
+		nativeErr = compat.ProbeNativeXDP(cfg.Interface)
+	}
+
+	rep := doctorReport{
+		Kernel:    f.Kernel,
+		Arch:      f.Arch,
+		Interface: cfg.Interface,
+		XDPMode:   cfg.XDPMode,
+		Features:  f.Report(cfg.Interface, cfg.XDPMode, nativeErr),
+	}
+	rep.Usable = compat.Usable(rep.Features)
+
+	if err := output.Print(os.Stdout, format, rep, func(w io.Writer) {
+		fmt.Fprintf(w, "Kernel:    %s (%s)\n", rep.Kernel, rep.Arch)
+		fmt.Fprintf(w, "Interface: %s (%s mode)\n\n", rep.Interface, rep.XDPMode)
+		for _, ft := range rep.Features {
+			line := fmt.Sprintf("  %-12s %s", "["+string(ft.Status)+"]", ft.Name)
+			if ft.Detail != "" {
+				line += " — " + ft.Detail
+			}
+			fmt.Fprintln(w, line)
+		}
+		if rep.Usable {
+			fmt.Fprintln(w, "\nThe scrubber can run on this host.")
+		} else {
+			fmt.Fprintln(w, "\nRequired features are unavailable; the scrubber cannot run on this host.")
+		}
+	}); err != nil {
+		return err
+	}
+	if !rep.Usable {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// cmdVerify loads the BPF object into the kernel without attaching it and
+// reports the verifier log and missing kernel features if it is rejected.
+func cmdVerify(format output.Format, path string) error {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	loader := bpf.NewLoader(zap.NewNop(), cfg.BPFObject)
+	loader.SetVerifierLogLines(cfg.VerifierLogLines)
+	loadErr := loader.Load()
+	loader.Close()
+
+	f := compat.Detect()
+	res := verifyResult{
+		Object:          cfg.BPFObject,
+		Kernel:          f.Kernel,
+		Loaded:          loadErr == nil,
+		MissingFeatures: f.Missing(),
+	}
+	if loadErr != nil {
+		res.Error = loadErr.Error()
+	}
+	if d := loader.LastLoadError(); d != nil {
+		res.VerifierLog = d.VerifierLog
+		res.LogLines = d.LogLines
+		res.LogTruncated = d.Truncated
+	}
+
+	if err := output.Print(os.Stdout, format, res, func(w io.Writer) {
+		fmt.Fprintf(w, "Object: %s\n", res.Object)
+		fmt.Fprintf(w, "Kernel: %s\n", res.Kernel)
+		for _, ft := range res.MissingFeatures {
+			fmt.Fprintf(w, "  %-12s %s — %s\n", "["+string(ft.Status)+"]", ft.Name, ft.Detail)
+		}
+		if res.Loaded {
+			fmt.Fprintln(w, "\nThe BPF object passed the verifier.")
+			return
+		}
+		if len(res.VerifierLog) > 0 {
+			fmt.Fprintln(w, "\nVerifier log:")
+			if res.LogTruncated {
+				fmt.Fprintf(w, "  ... (last %d of %d lines; set verifier_log_lines: 0 for all)\n",
+					len(res.VerifierLog), res.LogLines)
+			}
+			for _, line := range res.VerifierLog {
+				fmt.Fprintf(w, "  %s\n", line)
+			}
+		}
+		fmt.Fprintf(w, "\nLoad failed: %s\n", res.Error)
+	}); err != nil {
+		return err
+	}
+	if !res.Loaded {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// dialAddr turns a listen address into one a local client can dial.
+func dialAddr(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
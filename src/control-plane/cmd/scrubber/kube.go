@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/kube"
+)
+
+// kubeStartTimeout bounds reading the configuration from the cluster at
+// startup.
+const kubeStartTimeout = 30 * time.Second
+
+// kubeMode is what the operator needs once the scrubber runs.
+type kubeMode struct {
+	client *kube.Client
+	node   string
+	// load parses later versions of the configuration the way the first
+	// one was.
+	load func(data []byte) (*config.Config, error)
+}
+
+// loadKubernetesConfig replaces the bootstrap configuration with the one
+// read from the cluster, with the environment, flags and fixup applied on
+// top, and resolves interface "auto" to the node's external interface.
+func loadKubernetesConfig(bootstrap *config.Config, fixup func(c *config.Config)) (*config.Config, *kubeMode, error) {
+	k := bootstrap.Kubernetes
+	client, err := kube.NewInClusterClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	node := k.NodeName
+	if node == "" {
+		node = os.Getenv("NODE_NAME")
+	}
+	if node == "" {
+		return nil, nil, fmt.Errorf("kubernetes.node_name or NODE_NAME is required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kubeStartTimeout)
+	defer cancel()
+
+	src, err := client.FetchSource(ctx, k)
+	if err != nil {
+		return nil, nil, err
+	}
+	load := func(data []byte) (*config.Config, error) {
+		cfg, err := config.LoadFromKubernetes(data, k, overrides...)
+		if err != nil {
+			return nil, err
+		}
+		fixup(cfg)
+		return cfg, nil
+	}
+	cfg, err := load(src.Data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", src, err)
+	}
+
+	if cfg.Interface == kube.AutoInterface {
+		if cfg.Interface, err = client.DiscoverInterface(ctx, node); err != nil {
+			return nil, nil, fmt.Errorf("discovering the external interface of node %s: %w", node, err)
+		}
+	}
+	// Later versions keep the interface found now; moving the program to
+	// another interface takes a restart anyway.
+	iface := cfg.Interface
+	mode := &kubeMode{client: client, node: node}
+	mode.load = func(data []byte) (*config.Config, error) {
+		c, err := load(data)
+		if err != nil {
+			return nil, err
+		}
+		if c.Interface == kube.AutoInterface {
+			c.Interface = iface
+		}
+		return c, nil
+	}
+	return cfg, mode, nil
+}
@@ -1,4 +1,18 @@
 // Command scrubber is the main entry point for the DDoS scrubber control plane.
+//
+// Usage:
+//
+//	scrubber [flags] [command]
+//
+// With no command the scrubber runs. Informational commands (version,
+// validate, dump, maps, status, doctor, verify) print their result and exit;
+// combine them with --output json for machine-readable output.
+// --validate-config is the same as the validate command: it lists every
+// problem found in the configuration and exits non-zero if there are any.
+//
+// Every config file setting can be overridden with a flag named after its
+// path (--rate-limit.syn-rate-pps=500) or a SCRUBBER_ environment variable
+// (SCRUBBER_RATE_LIMIT_SYN_RATE_PPS=500); flags win over the environment.
 package main
 
 import (
@@ -11,6 +25,8 @@ import (
 
 	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
 	"github.com/ebpf-ddos-scrubber/control-plane/internal/engine"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/kube"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/output"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -28,12 +44,56 @@ func main() {
 		listen     = flag.String("listen", "", "Override gRPC API listen address")
 		logLevel   = flag.String("log-level", "", "Override log level (debug/info/warn/error)")
 		showVer    = flag.Bool("version", false, "Show version and exit")
+		validate   = flag.Bool("validate-config", false, "Validate the configuration, overrides included, and exit")
+		outputFmt  = flag.String("output", "text", "Output format for informational commands (text/json)")
 	)
+	flag.VisitAll(func(f *flag.Flag) { ownFlags = append(ownFlags, f.Name) })
+	flagOverrides := config.RegisterFlags(flag.CommandLine)
+	flag.Usage = usage
 	flag.Parse()
 
+	envOverrides, err := config.EnvOverrides(os.Environ())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	// Flags win over the environment, which wins over the config file.
+	overrides = []config.Overrides{envOverrides, flagOverrides}
+
+	format, err := output.ParseFormat(*outputFmt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	command := flag.Arg(0)
 	if *showVer {
-		fmt.Printf("ddos-scrubber %s (built %s)\n", version, buildTime)
-		os.Exit(0)
+		command = "version"
+	}
+	if *validate {
+		command = "validate"
+	}
+
+	switch command {
+	case "", "run":
+		// Fall through to start the scrubber.
+	case "version":
+		exitOn(format, cmdVersion(format))
+	case "validate":
+		exitOn(format, cmdValidate(format, *configPath))
+	case "dump":
+		exitOn(format, cmdDump(format, *configPath))
+	case "maps":
+		exitOn(format, cmdMaps(format, *configPath))
+	case "status":
+		exitOn(format, cmdStatus(format, *configPath, *listen))
+	case "doctor":
+		exitOn(format, cmdDoctor(format, *configPath, *iface, *mode))
+	case "verify":
+		exitOn(format, cmdVerify(format, *configPath))
+	default:
+		output.PrintError(os.Stdout, os.Stderr, format, fmt.Errorf("unknown command %q", command))
+		os.Exit(2)
 	}
 
 	// Load configuration
@@ -44,17 +104,30 @@ func main() {
 	}
 
 	// Apply CLI overrides
-	if *iface != "" {
-		cfg.Interface = *iface
-	}
-	if *mode != "" {
-		cfg.XDPMode = *mode
+	applyFlags := func(cfg *config.Config) {
+		if *iface != "" {
+			cfg.Interface = *iface
+		}
+		if *mode != "" {
+			cfg.XDPMode = *mode
+		}
+		if *listen != "" {
+			cfg.API.Listen = *listen
+		}
+		if *logLevel != "" {
+			cfg.LogLevel = *logLevel
+		}
 	}
-	if *listen != "" {
-		cfg.API.Listen = *listen
-	}
-	if *logLevel != "" {
-		cfg.LogLevel = *logLevel
+	applyFlags(cfg)
+
+	// In Kubernetes mode the file only bootstraps: the configuration comes
+	// from the cluster.
+	var km *kubeMode
+	if cfg.Kubernetes.Enabled {
+		if cfg, km, err = loadKubernetesConfig(cfg, applyFlags); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: kubernetes: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	// Initialize logger
@@ -77,6 +150,9 @@ func main() {
 	defer cancel()
 
 	eng := engine.New(log, cfg)
+	if km != nil {
+		eng.SetOperator(kube.NewOperator(log, cfg.Kubernetes, km.client, km.node), km.load)
+	}
 	if err := eng.Start(ctx); err != nil {
 		log.Fatal("failed to start engine", zap.Error(err))
 	}
@@ -88,18 +164,56 @@ func main() {
 	sig := <-sigCh
 	log.Info("received signal, shutting down...", zap.String("signal", sig.String()))
 
-	cancel()
+	// Stop drains in order and cancels the engine's context itself.
 	eng.Stop()
 
 	log.Info("DDoS Scrubber stopped")
 }
 
+// overrides are the SCRUBBER_* environment variables and config setting
+// flags, applied over the config file in order.
+var overrides []config.Overrides
+
 func loadConfig(path string) (*config.Config, error) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		// Config file not found — use defaults
-		return config.DefaultConfig(), nil
+		cfg := config.DefaultConfig()
+		for _, o := range overrides {
+			if err := cfg.ApplyOverrides(o); err != nil {
+				return nil, fmt.Errorf("applying overrides: %w", err)
+			}
+		}
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+		return cfg, nil
+	}
+	return config.LoadFromFile(path, overrides...)
+}
+
+// ownFlags are the scrubber's own flags, as opposed to those generated for
+// config settings.
+var ownFlags []string
+
+// usage prints the scrubber's own flags; the generated config setting
+// flags are summarized rather than listed.
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "Usage: %s [flags] [run|version|validate|dump|maps|status|doctor|verify]\n\nFlags:\n", os.Args[0])
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(w)
+	for _, name := range ownFlags {
+		f := flag.Lookup(name)
+		fs.Var(f.Value, f.Name, f.Usage)
 	}
-	return config.LoadFromFile(path)
+	fs.PrintDefaults()
+	fmt.Fprintf(w, `
+Every config file setting can also be set with a flag named after its
+path or a SCRUBBER_ environment variable, e.g. --rate-limit.syn-rate-pps=500
+or SCRUBBER_RATE_LIMIT_SYN_RATE_PPS=500. Flags take precedence over the
+environment, which takes precedence over the file. String lists take
+comma-separated values; other structured settings take YAML flow syntax.
+`)
 }
 
 func newLogger(level string) (*zap.Logger, error) {
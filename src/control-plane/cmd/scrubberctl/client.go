@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// client is a minimal JSON client for the scrubber REST API.
+type client struct {
+	base  string
+	token string // API key, if the API requires one
+	http  *http.Client
+}
+
+// newClient creates a client for addr: host:port, an http(s) URL, or
+// unix:PATH for a unix socket. A non-nil tlsCfg makes bare addresses use
+// HTTPS.
+func newClient(addr string, timeout time.Duration, tlsCfg *tls.Config) *client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsCfg
+
+	scheme := "http://"
+	if tlsCfg != nil {
+		scheme = "https://"
+	}
+	base := strings.TrimRight(addr, "/")
+	if path, ok := strings.CutPrefix(base, "unix:"); ok {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		}
+		base = scheme + "localhost"
+	} else if !strings.Contains(base, "://") {
+		base = scheme + base
+	}
+
+	return &client{
+		base: base,
+		http: &http.Client{Timeout: timeout, Transport: transport},
+	}
+}
+
+// loadTLS builds the client TLS configuration from a CA bundle and an
+// optional client certificate. It returns nil if none is given.
+func loadTLS(ca, cert, key string) (*tls.Config, error) {
+	if ca == "" && cert == "" && key == "" {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if ca != "" {
+		pem, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", ca)
+		}
+	}
+	if cert != "" || key != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	return cfg, nil
+}
+
+func (c *client) get(path string, out interface{}) error {
+	return c.do(http.MethodGet, path, nil, out)
+}
+
+func (c *client) post(path string, body, out interface{}) error {
+	return c.do(http.MethodPost, path, body, out)
+}
+
+func (c *client) put(path string, body, out interface{}) error {
+	return c.do(http.MethodPut, path, body, out)
+}
+
+func (c *client) delete(path string, body, out interface{}) error {
+	return c.do(http.MethodDelete, path, body, out)
+}
+
+// download streams the body of a GET response to w.
+func (c *client) download(path string, w io.Writer) error {
+	req, err := c.newRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("querying scrubber API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("GET %s: HTTP %d: %s", path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("downloading %s: %w", path, err)
+	}
+	return nil
+}
+
+// dialWS opens a WebSocket to path over the same transport, TLS settings
+// and API key as REST requests.
+func (c *client) dialWS(ctx context.Context, path string) (*websocket.Conn, error) {
+	transport := c.http.Transport.(*http.Transport)
+	d := websocket.Dialer{
+		NetDialContext:   transport.DialContext,
+		TLSClientConfig:  transport.TLSClientConfig,
+		HandshakeTimeout: c.http.Timeout,
+	}
+	header := http.Header{}
+	if c.token != "" {
+		header.Set("Authorization", "Bearer "+c.token)
+	}
+	url := "ws" + strings.TrimPrefix(c.base, "http") + path
+	conn, resp, err := d.DialContext(ctx, url, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("connecting to %s: HTTP %d", path, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("connecting to %s: %w", path, err)
+	}
+	return conn, nil
+}
+
+// newRequest builds a request carrying the API key, if set.
+func (c *client) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("building request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+// do sends a request with an optional JSON body and decodes the JSON
+// response into out (if non-nil). Non-2xx responses become errors carrying
+// the server's message.
+func (c *client) do(method, path string, body, out interface{}) error {
+	var rd io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+		rd = bytes.NewReader(data)
+	}
+
+	req, err := c.newRequest(method, path, rd)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("querying scrubber API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if text := strings.TrimSpace(string(msg)); text != "" {
+			return fmt.Errorf("%s %s: HTTP %d: %s", method, path, resp.StatusCode, text)
+		}
+		return fmt.Errorf("%s %s: HTTP %d", method, path, resp.StatusCode)
+	}
+
+	if out == nil {
+		return nil
+	}
+	dec := json.NewDecoder(resp.Body)
+	dec.UseNumber()
+	if err := dec.Decode(out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
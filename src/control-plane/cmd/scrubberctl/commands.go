@@ -0,0 +1,4285 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/output"
+)
+
+// statusInfo mirrors GET /api/v1/status.
+type statusInfo struct {
+	Enabled         bool   `json:"enabled"`
+	InterfaceName   string `json:"interfaceName"`
+	XDPMode         string `json:"xdpMode"`
+	ProgramID       uint32 `json:"programId"`
+	UptimeSeconds   int64  `json:"uptimeSeconds"`
+	Version         string `json:"version"`
+	EscalationLevel uint64 `json:"escalationLevel"`
+	PipelineStages  int    `json:"pipelineStages"`
+	Role            string `json:"role,omitempty"`
+	Maintenance     string `json:"maintenance,omitempty"`
+}
+
+// rateConfig mirrors GET/PUT /api/v1/config/rate.
+type rateConfig struct {
+	SYNRatePPS      uint64 `json:"synRatePps"`
+	UDPRatePPS      uint64 `json:"udpRatePps"`
+	ICMPRatePPS     uint64 `json:"icmpRatePps"`
+	GlobalPPS       uint64 `json:"globalPpsLimit"`
+	GlobalBPS       uint64 `json:"globalBpsLimit"`
+	AdaptiveEnabled bool   `json:"adaptiveEnabled"`
+}
+
+// configKey mirrors the entries of GET /api/v1/config.
+type configKey struct {
+	Key         string          `json:"key"`
+	Index       uint32          `json:"index"`
+	Type        string          `json:"type"`
+	Value       json.RawMessage `json:"value"` // Raw, so large limits print exactly
+	Min         *uint64         `json:"min,omitempty"`
+	Max         *uint64         `json:"max,omitempty"`
+	Values      []string        `json:"values,omitempty"`
+	Description string          `json:"description"`
+	Writable    bool            `json:"writable"`
+	ManagedBy   string          `json:"managedBy,omitempty"`
+}
+
+// configRevision mirrors GET /api/v1/config/revisions and its entries.
+type configRevision struct {
+	Number  int             `json:"number"`
+	At      string          `json:"at"`
+	Source  string          `json:"source"`
+	Author  string          `json:"author,omitempty"`
+	Changes json.RawMessage `json:"changes,omitempty"` // A count in the list, the changes for one revision
+	Against int             `json:"against,omitempty"`
+}
+
+// configChange mirrors the changes of GET /api/v1/config/revisions/{n}.
+type configChange struct {
+	Path string `json:"path"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// reputationEntry mirrors the sources returned by /api/v1/reputation.
+type reputationEntry struct {
+	IP             string    `json:"ip"`
+	Score          uint32    `json:"score"`
+	TotalPackets   uint32    `json:"totalPackets"`
+	DroppedPackets uint32    `json:"droppedPackets"`
+	Blocked        bool      `json:"blocked"`
+	Manual         bool      `json:"manual"`
+	Origin         string    `json:"origin"`
+	FirstSeen      time.Time `json:"firstSeen"`
+	LastSeen       time.Time `json:"lastSeen"`
+}
+
+// reputationTop mirrors GET /api/v1/reputation/top.
+type reputationTop struct {
+	Threshold uint32            `json:"threshold"`
+	Tracked   int               `json:"tracked"`
+	Offenders []reputationEntry `json:"offenders"`
+}
+
+// reputationConfig mirrors GET /api/v1/reputation/config.
+type reputationConfig struct {
+	PollIntervalSec  uint64 `json:"pollIntervalSec"`
+	Decay            string `json:"decay"`
+	DecayRate        uint32 `json:"decayRate"`
+	DecayHalfLifeSec uint64 `json:"decayHalfLifeSec"`
+	UnblockRatio     uint32 `json:"unblockRatio"`
+	Weights          struct {
+		SYNNoACK     uint32 `json:"synNoAck"`
+		RateExceeded uint32 `json:"rateExceeded"`
+		ProtoAnomaly uint32 `json:"protoAnomaly"`
+		BadPayload   uint32 `json:"badPayload"`
+		Fragment     uint32 `json:"fragment"`
+	} `json:"weights"`
+}
+
+// escalationInfo mirrors GET /api/v1/escalation.
+type escalationInfo struct {
+	Level      uint64              `json:"level"`
+	Name       string              `json:"name"`
+	Triggers   []escalationTrigger `json:"triggers"`
+	Indicators map[string]string   `json:"indicators"`
+	Pin        *escalationPin      `json:"pin"`
+	LevelSince string              `json:"levelSince"`
+	MinDwell   float64             `json:"minDwellSec"`
+	MaxLevel   string              `json:"maxLevel"`
+	Prefixes   []escalationPrefix  `json:"prefixes"`
+}
+
+type escalationPrefix struct {
+	Prefix    string  `json:"prefix"`
+	Level     string  `json:"level"`
+	Since     string  `json:"since"`
+	DropRatio float64 `json:"dropRatio"`
+	DropPPS   float64 `json:"dropPps"`
+}
+
+type escalationPin struct {
+	Level  string `json:"level"`
+	Reason string `json:"reason"`
+	Since  string `json:"since"`
+	Until  string `json:"until"`
+}
+
+type escalationTrigger struct {
+	Name      string  `json:"name"`
+	Current   float64 `json:"current"`
+	Threshold float64 `json:"threshold"`
+	Active    bool    `json:"active"`
+}
+
+// escalationHistory mirrors GET /api/v1/escalation/history.
+type escalationHistory struct {
+	Total   int `json:"total"`
+	Entries []struct {
+		Timestamp string              `json:"timestamp"`
+		From      string              `json:"from"`
+		To        string              `json:"to"`
+		Reason    string              `json:"reason"`
+		Triggers  []escalationTrigger `json:"triggers"`
+	} `json:"entries"`
+}
+
+// conntrackInfo mirrors GET /api/v1/conntrack.
+type conntrackInfo struct {
+	ActiveConnections uint64 `json:"activeConnections"`
+	Enabled           bool   `json:"enabled"`
+}
+
+// conntrackFlows mirrors GET /api/v1/conntrack/flows.
+type conntrackFlows struct {
+	Total  int             `json:"total"`
+	Offset int             `json:"offset"`
+	Limit  int             `json:"limit"`
+	Flows  []conntrackFlow `json:"flows"`
+}
+
+type conntrackFlow struct {
+	SrcIP      string    `json:"srcIp"`
+	DstIP      string    `json:"dstIp"`
+	SrcPort    uint16    `json:"srcPort"`
+	DstPort    uint16    `json:"dstPort"`
+	Protocol   uint8     `json:"protocol"`
+	State      string    `json:"state"`
+	Suspect    bool      `json:"suspect"`
+	Flags      uint8     `json:"flags"`
+	PacketsFwd uint64    `json:"packetsFwd"`
+	PacketsRev uint64    `json:"packetsRev"`
+	BytesFwd   uint64    `json:"bytesFwd"`
+	BytesRev   uint64    `json:"bytesRev"`
+	Violations uint8     `json:"violations"`
+	LastSeen   time.Time `json:"lastSeen"`
+}
+
+// captureStatus mirrors GET /api/v1/capture.
+type captureStatus struct {
+	Active     bool      `json:"active"`
+	Mode       string    `json:"mode,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	StartedAt  time.Time `json:"startedAt,omitempty"`
+	StoppedAt  time.Time `json:"stoppedAt,omitempty"`
+	Deadline   time.Time `json:"deadline,omitempty"`
+	SampleRate uint32    `json:"sampleRate,omitempty"`
+	Snaplen    uint32    `json:"snaplen,omitempty"`
+	MaxPackets uint64    `json:"maxPackets,omitempty"`
+	Packets    uint64    `json:"packets"`
+	Bytes      uint64    `json:"bytes"`
+	Lost       uint64    `json:"lost"`
+	File       string    `json:"file,omitempty"`
+	Files      int       `json:"files"`
+}
+
+// captureFile mirrors an entry of GET /api/v1/capture/files.
+type captureFile struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// approvalRequest mirrors an entry of GET /api/v1/approvals.
+type approvalRequest struct {
+	ID         uint32    `json:"id"`
+	Action     string    `json:"action"`
+	Summary    string    `json:"summary"`
+	Status     string    `json:"status"`
+	Created    time.Time `json:"created"`
+	Deadline   time.Time `json:"deadline"`
+	ResolvedBy string    `json:"resolvedBy,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// sigProposal mirrors an entry of GET /api/v1/signatures/proposals.
+type sigProposal struct {
+	ID          uint32    `json:"id"`
+	Status      string    `json:"status"`
+	Index       *uint32   `json:"index,omitempty"`
+	Protocol    uint8     `json:"protocol"`
+	FlagsMask   uint8     `json:"flagsMask"`
+	FlagsMatch  uint8     `json:"flagsMatch"`
+	SrcPortMin  uint16    `json:"srcPortMin"`
+	SrcPortMax  uint16    `json:"srcPortMax"`
+	DstPortMin  uint16    `json:"dstPortMin"`
+	DstPortMax  uint16    `json:"dstPortMax"`
+	PktLenMin   uint16    `json:"pktLenMin"`
+	PktLenMax   uint16    `json:"pktLenMax"`
+	PayloadHash uint32    `json:"payloadHash"`
+	Packets     uint64    `json:"packets"`
+	SharePct    float64   `json:"sharePct"`
+	Sources     int       `json:"sources"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+}
+
+// clusterStatus mirrors GET /api/v1/cluster.
+type clusterStatus struct {
+	Node        string    `json:"node"`
+	Role        string    `json:"role"`
+	ActiveSince time.Time `json:"activeSince"`
+	Peer        string    `json:"peer"`
+	PeerNode    string    `json:"peerNode"`
+	PeerRole    string    `json:"peerRole"`
+	LastContact time.Time `json:"lastContact"`
+	LastError   string    `json:"lastError"`
+	Entries     int       `json:"entries"`
+	Pending     int       `json:"pending"`
+}
+
+// geoipDatabase mirrors GET /api/v1/geoip/database.
+type geoipDatabase struct {
+	Database  string `json:"database"`
+	Locations string `json:"locations"`
+	LoadedAt  string `json:"loadedAt"`
+	Prefixes  int    `json:"prefixes"`
+	Load      *struct {
+		Database string  `json:"database"`
+		Phase    string  `json:"phase"`
+		Started  string  `json:"started"`
+		Read     int     `json:"read"`
+		Changes  int     `json:"changes"`
+		Applied  int     `json:"applied"`
+		Added    int     `json:"added"`
+		Changed  int     `json:"changed"`
+		Removed  int     `json:"removed"`
+		Percent  float64 `json:"percent"`
+		Rate     float64 `json:"rate"`
+		ETASec   int64   `json:"etaSec"`
+	} `json:"load,omitempty"`
+}
+
+// geoipPolicies mirrors GET /api/v1/geoip/policies.
+type geoipPolicies struct {
+	Policies   map[string]string   `json:"policies"`
+	Continents map[string]string   `json:"continents"`
+	Regions    map[string][]string `json:"regions"`
+	Active     map[string]string   `json:"active"`
+	RateLimits map[string]uint64   `json:"rateLimits"`
+	Temporary  []struct {
+		Country string `json:"country"`
+		Action  string `json:"action"`
+		Expires string `json:"expires"`
+		Reason  string `json:"reason"`
+	} `json:"temporary"`
+}
+
+// geoipLookup mirrors GET /api/v1/geoip/lookup.
+type geoipLookup struct {
+	IP        string `json:"ip"`
+	Country   string `json:"country"`
+	Continent string `json:"continent"`
+	Action    string `json:"action"`
+}
+
+// geoipStats mirrors GET /api/v1/geoip/stats.
+type geoipStats struct {
+	Countries []struct {
+		Country     string `json:"country"`
+		Packets     uint64 `json:"packets"`
+		Dropped     uint64 `json:"dropped"`
+		RateLimited uint64 `json:"rateLimited"`
+		Monitored   uint64 `json:"monitored"`
+	} `json:"countries"`
+}
+
+// geoipAudit mirrors GET /api/v1/geoip/audit.
+type geoipAudit struct {
+	Entries []struct {
+		Timestamp string `json:"timestamp"`
+		Action    string `json:"action"`
+		Detail    string `json:"detail"`
+	} `json:"entries"`
+}
+
+// asnPolicies mirrors GET /api/v1/asn/policies.
+type asnPolicies struct {
+	Prefixes int         `json:"prefixes"`
+	Policies []asnPolicy `json:"policies"`
+}
+
+type asnPolicy struct {
+	ASN          uint32 `json:"asn"`
+	Organization string `json:"organization"`
+	Action       string `json:"action"`
+}
+
+// asnLookup mirrors GET /api/v1/asn/lookup.
+type asnLookup struct {
+	IP           string `json:"ip"`
+	ASN          uint32 `json:"asn"`
+	Organization string `json:"organization"`
+	Action       string `json:"action"`
+}
+
+// threatFeeds mirrors GET /api/v1/threatintel/feeds.
+type threatFeeds struct {
+	TotalEntries int          `json:"totalEntries"`
+	LastSync     string       `json:"lastSync"`
+	Feeds        []threatFeed `json:"feeds"`
+}
+
+type threatFeed struct {
+	Name            string `json:"name"`
+	URL             string `json:"url"`
+	Type            string `json:"type"`
+	Enabled         bool   `json:"enabled"`
+	LastSync        string `json:"lastSync"`
+	Entries         int    `json:"entries"`
+	Error           string `json:"error"`
+	ThreatType      string `json:"threatType"`
+	Confidence      uint8  `json:"confidence"`
+	Action          string `json:"action"`
+	SyncIntervalSec int64  `json:"syncIntervalSec"`
+	EntryTTLSec     int64  `json:"entryTtlSec"`
+	APIKeySet       bool   `json:"apiKeySet"`
+	Sync            *struct {
+		Bytes   int64   `json:"bytes"`
+		Percent float64 `json:"percent"`
+		ETASec  int64   `json:"etaSec"`
+	} `json:"sync,omitempty"`
+}
+
+// threatLookup mirrors GET /api/v1/threatintel/lookup.
+type threatLookup struct {
+	IP      string `json:"ip"`
+	Listed  bool   `json:"listed"`
+	Matches []struct {
+		Feed       string `json:"feed"`
+		Prefix     string `json:"prefix"`
+		ThreatType string `json:"threatType"`
+		Confidence uint8  `json:"confidence"`
+		Action     string `json:"action"`
+		LastSeen   string `json:"lastSeen"`
+	} `json:"matches"`
+	Dataplane *struct {
+		Feed       string `json:"feed"`
+		Confidence uint8  `json:"confidence"`
+		Action     string `json:"action"`
+	} `json:"dataplane,omitempty"`
+}
+
+// threatFeedStats mirrors an entry of GET /api/v1/threatintel/stats.
+type threatFeedStats struct {
+	Feed        string `json:"feed"`
+	SourceID    uint8  `json:"sourceId"`
+	Enabled     bool   `json:"enabled"`
+	Entries     int    `json:"entries"`
+	Matched     uint64 `json:"matched"`
+	Dropped     uint64 `json:"dropped"`
+	RateLimited uint64 `json:"rateLimited"`
+	Monitored   uint64 `json:"monitored"`
+}
+
+// threatQuarantine mirrors an element of GET /api/v1/threatintel/quarantine.
+type threatQuarantine struct {
+	Feed     string `json:"feed"`
+	At       string `json:"at"`
+	Entries  int    `json:"entries"`
+	Previous int    `json:"previous"`
+	Reason   string `json:"reason"`
+}
+
+// threatPushResult mirrors the POST /api/v1/threat-intel/push response.
+type threatPushResult struct {
+	Feed     string `json:"feed"`
+	Accepted int    `json:"accepted"`
+}
+
+// bgpStatus mirrors GET /api/v1/bgp.
+type bgpStatus struct {
+	Peers []struct {
+		RouterIP   string `json:"routerIp"`
+		LocalAS    uint32 `json:"localAs"`
+		PeerAS     uint32 `json:"peerAs"`
+		Connected  bool   `json:"connected"`
+		Since      string `json:"since"`
+		Blackholes int    `json:"blackholes"`
+		Flowspec   int    `json:"flowspec"`
+	} `json:"peers"`
+}
+
+// bgpBlackholes mirrors GET /api/v1/bgp/blackholes.
+type bgpBlackholes struct {
+	Blackholes []struct {
+		Prefix      string `json:"prefix"`
+		AnnouncedAt string `json:"announcedAt"`
+		ExpiresAt   string `json:"expiresAt"`
+	} `json:"blackholes"`
+}
+
+// bgpAudit mirrors GET /api/v1/bgp/audit.
+type bgpAudit struct {
+	Total   int `json:"total"`
+	Offset  int `json:"offset"`
+	Entries []struct {
+		Timestamp string `json:"timestamp"`
+		Action    string `json:"action"`
+		Detail    string `json:"detail"`
+	} `json:"entries"`
+}
+
+// prefixStats mirrors GET /api/v1/stats?prefix=CIDR or ?tenant=NAME.
+type prefixStats struct {
+	Prefix         string            `json:"prefix,omitempty"`
+	Tenant         string            `json:"tenant,omitempty"`
+	Prefixes       []string          `json:"prefixes"`
+	Destinations   int               `json:"destinations"`
+	RxPackets      uint64            `json:"rxPackets"`
+	RxBytes        uint64            `json:"rxBytes"`
+	DroppedPackets uint64            `json:"droppedPackets"`
+	DroppedBytes   uint64            `json:"droppedBytes"`
+	DropReasons    map[string]uint64 `json:"dropReasons"`
+}
+
+// authWhoami mirrors GET /api/v1/auth/whoami.
+type authWhoami struct {
+	AuthEnabled bool   `json:"authEnabled"`
+	Identity    string `json:"identity,omitempty"`
+	Source      string `json:"source,omitempty"`
+	Role        string `json:"role,omitempty"`
+	Tenant      string `json:"tenant,omitempty"`
+}
+
+// tenantList mirrors GET /api/v1/tenants.
+type tenantList struct {
+	Tenants []struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Prefixes    []string `json:"prefixes"`
+		Keys        []string `json:"keys"`
+		Clients     []string `json:"clients"`
+	} `json:"tenants"`
+}
+
+// authAudit mirrors GET /api/v1/auth/audit.
+type authAudit struct {
+	Entries []struct {
+		Timestamp string `json:"timestamp"`
+		Identity  string `json:"identity"`
+		Role      string `json:"role"`
+		Method    string `json:"method"`
+		Path      string `json:"path"`
+		Remote    string `json:"remote"`
+		Reason    string `json:"reason"`
+	} `json:"entries"`
+}
+
+// debugRuntime mirrors GET /api/v1/debug/runtime.
+type debugRuntime struct {
+	GoVersion  string `json:"goVersion"`
+	Goroutines int    `json:"goroutines"`
+	NumCPU     int    `json:"numCpu"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+	UptimeSec  int64  `json:"uptimeSec"`
+	Pprof      bool   `json:"pprof"`
+	Heap       struct {
+		AllocBytes      uint64 `json:"allocBytes"`
+		InuseBytes      uint64 `json:"inuseBytes"`
+		IdleBytes       uint64 `json:"idleBytes"`
+		ReleasedBytes   uint64 `json:"releasedBytes"`
+		Objects         uint64 `json:"objects"`
+		SysBytes        uint64 `json:"sysBytes"`
+		TotalAllocBytes uint64 `json:"totalAllocBytes"`
+	} `json:"heap"`
+	GC struct {
+		Count        uint32  `json:"count"`
+		Forced       uint32  `json:"forced"`
+		LastGC       string  `json:"lastGc"`
+		LastPauseMs  float64 `json:"lastPauseMs"`
+		PauseTotalMs float64 `json:"pauseTotalMs"`
+		CPUFraction  float64 `json:"cpuFraction"`
+		NextGCBytes  uint64  `json:"nextGcBytes"`
+	} `json:"gc"`
+	MapIterations []struct {
+		Map       string  `json:"map"`
+		Count     uint64  `json:"count"`
+		LastMs    float64 `json:"lastMs"`
+		MaxMs     float64 `json:"maxMs"`
+		MeanMs    float64 `json:"meanMs"`
+		LastRunAt string  `json:"lastRunAt"`
+	} `json:"mapIterations"`
+	FeedSyncs []struct {
+		Feed       string  `json:"feed"`
+		LastSync   string  `json:"lastSync"`
+		DurationMs float64 `json:"durationMs"`
+		Entries    int     `json:"entries"`
+		Error      string  `json:"error"`
+	} `json:"feedSyncs"`
+	EventPipeline *struct {
+		Read       uint64 `json:"read"`
+		SampledOut uint64 `json:"sampledOut"`
+		Aggregated uint64 `json:"aggregated"`
+		Dispatched uint64 `json:"dispatched"`
+	} `json:"eventPipeline"`
+	RingBuffer *struct {
+		SizeBytes        int    `json:"sizeBytes"`
+		BacklogBytes     int    `json:"backlogBytes"`
+		PeakBacklogBytes int    `json:"peakBacklogBytes"`
+		Lost             uint64 `json:"lost"`
+		LastLoss         string `json:"lastLoss"`
+	} `json:"ringBuffer"`
+}
+
+// synCookieStatus mirrors GET /api/v1/syncookie.
+type synCookieStatus struct {
+	Enabled         bool     `json:"enabled"`
+	Scoped          bool     `json:"scoped"`
+	Destinations    []string `json:"destinations"`
+	SeedRotatedAt   string   `json:"seedRotatedAt"`
+	SeedAgeSec      float64  `json:"seedAgeSec"`
+	SeedRotationSec uint64   `json:"seedRotationSec"`
+	Sent            uint64   `json:"sent"`
+	Validated       uint64   `json:"validated"`
+	Failed          uint64   `json:"failed"`
+	SentPPS         float64  `json:"sentPps"`
+	ValidatedPPS    float64  `json:"validatedPps"`
+	FailedPPS       float64  `json:"failedPps"`
+	SuccessPct      *float64 `json:"successPct"`
+}
+
+// synCookieDest is the result of syncookie add|del.
+type synCookieDest struct {
+	Action string `json:"action"`
+	CIDR   string `json:"cidr"`
+	OK     bool   `json:"ok"`
+}
+
+// tunnelList mirrors GET /api/v1/tunnels.
+type tunnelList struct {
+	Tunnels []struct {
+		Name          string   `json:"name"`
+		Prefix        string   `json:"prefix"`
+		Endpoint      string   `json:"endpoint"`
+		Backup        string   `json:"backup"`
+		Active        string   `json:"active"`
+		EndpointUp    bool     `json:"endpointUp"`
+		BackupUp      bool     `json:"backupUp"`
+		LastCheck     string   `json:"lastCheck"`
+		LastError     string   `json:"lastError"`
+		EndpointRTTMs *float64 `json:"endpointRttMs"`
+	} `json:"tunnels"`
+}
+
+// assetList mirrors GET /api/v1/assets.
+type assetList struct {
+	Assets []assetBody `json:"assets"`
+}
+
+// assetStats mirrors GET /api/v1/assets/stats.
+type assetStats struct {
+	Assets []struct {
+		assetBody
+		RxPackets      uint64 `json:"rxPackets"`
+		RxBytes        uint64 `json:"rxBytes"`
+		DroppedPackets uint64 `json:"droppedPackets"`
+		DroppedBytes   uint64 `json:"droppedBytes"`
+	} `json:"assets"`
+}
+
+// attackSummary is an attack session as returned by /api/v1/attacks.
+type attackSummary struct {
+	ID             string            `json:"id"`
+	Start          time.Time         `json:"start"`
+	End            *time.Time        `json:"end,omitempty"`
+	DurationSec    float64           `json:"durationSec"`
+	PeakDropPPS    float64           `json:"peakDropPps"`
+	PeakDropBPS    float64           `json:"peakDropBps"`
+	DroppedPackets uint64            `json:"droppedPackets"`
+	PeakEscalation string            `json:"peakEscalation,omitempty"`
+	Vectors        map[string]uint64 `json:"vectors"`
+	UniqueSources  int               `json:"uniqueSources"`
+}
+
+// attackList mirrors GET /api/v1/attacks.
+type attackList struct {
+	Active  *attackSummary  `json:"active"`
+	Attacks []attackSummary `json:"attacks"`
+}
+
+// assetBody is a protected asset as sent to and returned by /api/v1/assets.
+type assetBody struct {
+	Name        string `json:"name"`
+	Prefix      string `json:"prefix"`
+	Owner       string `json:"owner,omitempty"`
+	Profile     string `json:"profile,omitempty"`
+	SYNCookie   bool   `json:"synCookie"`
+	SYNRatePPS  uint64 `json:"synRatePps,omitempty"`
+	UDPRatePPS  uint64 `json:"udpRatePps,omitempty"`
+	ICMPRatePPS uint64 `json:"icmpRatePps,omitempty"`
+	AutoRTBH    bool   `json:"autoRtbh"`
+
+	GeoAllow       []string `json:"geoAllow,omitempty"`
+	GeoAllowAction string   `json:"geoAllowAction,omitempty"`
+}
+
+// diversionStatus mirrors GET /api/v1/diversion.
+type diversionStatus struct {
+	Mode       string `json:"mode"`
+	Active     bool   `json:"active"`
+	Since      string `json:"since"`
+	Level      string `json:"level"`
+	StartLevel string `json:"startLevel"`
+	HoldUntil  string `json:"holdUntil"`
+	Prefixes   []struct {
+		Prefix    string `json:"prefix"`
+		Announced bool   `json:"announced"`
+		Tunnel    string `json:"tunnel"`
+		Endpoint  string `json:"endpoint"`
+		Error     string `json:"error"`
+	} `json:"prefixes"`
+}
+
+// scheduleStatus mirrors GET /api/v1/schedule.
+type scheduleStatus struct {
+	Active        string    `json:"active"`
+	Source        string    `json:"source"`
+	Since         time.Time `json:"since"`
+	OverrideUntil time.Time `json:"overrideUntil"`
+	Next          string    `json:"next"`
+	NextAt        time.Time `json:"nextAt"`
+	Timezone      string    `json:"timezone"`
+	Profiles      map[string]struct {
+		SYNRatePPS            uint64            `json:"synRatePps"`
+		UDPRatePPS            uint64            `json:"udpRatePps"`
+		ICMPRatePPS           uint64            `json:"icmpRatePps"`
+		GlobalPPS             uint64            `json:"globalPps"`
+		GlobalBPS             uint64            `json:"globalBps"`
+		GeoIP                 map[string]string `json:"geoip"`
+		GeoIPRateLimits       map[string]uint64 `json:"geoipRateLimits"`
+		EscalationSensitivity float64           `json:"escalationSensitivity"`
+	} `json:"profiles"`
+	Entries []struct {
+		Cron    string `json:"cron"`
+		Profile string `json:"profile"`
+	} `json:"entries"`
+}
+
+// maintenanceWindow is a current or past maintenance period.
+type maintenanceWindow struct {
+	Mode     string    `json:"mode"`
+	Reason   string    `json:"reason"`
+	By       string    `json:"by"`
+	Forced   bool      `json:"forced"`
+	Level    string    `json:"level"`
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+	Ended    time.Time `json:"ended"`
+	EndedBy  string    `json:"endedBy"`
+	EndError string    `json:"endError"`
+}
+
+// maintenanceStatus mirrors GET /api/v1/maintenance.
+type maintenanceStatus struct {
+	State   string              `json:"state"`
+	Current *maintenanceWindow  `json:"current"`
+	History []maintenanceWindow `json:"history"`
+}
+
+// watchdogStatus mirrors GET /api/v1/watchdog.
+type watchdogStatus struct {
+	Enabled   bool      `json:"enabled"`
+	Repair    bool      `json:"repair"`
+	Interval  int       `json:"intervalSec"`
+	LastCheck time.Time `json:"lastCheck"`
+	Healthy   bool      `json:"healthy"`
+	Paused    bool      `json:"paused"`
+	Attach    struct {
+		Interface          string `json:"interface"`
+		ProgramID          uint32 `json:"programId"`
+		Linked             bool   `json:"linked"`
+		InterfaceProgramID uint32 `json:"interfaceProgramId"`
+	} `json:"attach"`
+	Checks   uint64 `json:"checks"`
+	Repairs  uint64 `json:"repairs"`
+	Findings []struct {
+		Time     time.Time `json:"time"`
+		Kind     string    `json:"kind"`
+		Detail   string    `json:"detail"`
+		Repaired bool      `json:"repaired"`
+		Error    string    `json:"error"`
+	} `json:"findings"`
+}
+
+// fleetStatus mirrors GET /api/v1/fleet.
+type fleetStatus struct {
+	Enabled     *bool     `json:"enabled"` // Only present, as false, when disabled
+	Node        string    `json:"node"`
+	Leader      string    `json:"leader"`
+	IsLeader    bool      `json:"isLeader"`
+	LeaderSince time.Time `json:"leaderSince"`
+	Priority    int       `json:"priority"`
+	Size        int       `json:"size"`
+	Live        int       `json:"live"`
+	Quorum      int       `json:"quorum"`
+	Members     []struct {
+		Node        string    `json:"node"`
+		Addr        string    `json:"addr"`
+		Priority    int       `json:"priority"`
+		Leader      bool      `json:"leader"`
+		Live        bool      `json:"live"`
+		LastContact time.Time `json:"lastContact"`
+		LastError   string    `json:"lastError"`
+	} `json:"members"`
+	LastMirror  time.Time `json:"lastMirror"`
+	MirrorError string    `json:"mirrorError"`
+	Reputation  *struct {
+		Published    int            `json:"published"`
+		LastPublish  time.Time      `json:"lastPublish"`
+		PublishError string         `json:"publishError"`
+		Received     map[string]int `json:"received"`
+	} `json:"reputation"`
+}
+
+// connLimitStatus mirrors GET /api/v1/connlimit.
+type connLimitStatus struct {
+	PerSource uint32 `json:"perSource"`
+	Prefixes  []struct {
+		Prefix string `json:"prefix"`
+		Limit  uint32 `json:"limit"`
+	} `json:"prefixes"`
+	Sources []struct {
+		IP    string `json:"ip"`
+		Conns uint32 `json:"conns"`
+	} `json:"sources"`
+	Dropped uint64 `json:"dropped"`
+}
+
+// egressPolicy mirrors the body of PUT /api/v1/egress.
+type egressPolicy struct {
+	BlockSpoofed       bool              `json:"blockSpoofed"`
+	LocalPrefixes      []string          `json:"localPrefixes"`
+	BlockAmplification bool              `json:"blockAmplification"`
+	AmpPorts           map[uint16]uint64 `json:"ampPorts"`
+}
+
+// egressStatus mirrors GET /api/v1/egress.
+type egressStatus struct {
+	Enabled    bool   `json:"enabled"`
+	Attachment string `json:"attachment"`
+	Interface  string `json:"interface"`
+	egressPolicy
+	Stats struct {
+		Packets        uint64 `json:"packets"`
+		Bytes          uint64 `json:"bytes"`
+		SpoofedDropped uint64 `json:"spoofedDropped"`
+		AmpDropped     uint64 `json:"ampDropped"`
+		DroppedBytes   uint64 `json:"droppedBytes"`
+	} `json:"stats"`
+}
+
+// inspectStatus mirrors GET /api/v1/inspect.
+type inspectStatus struct {
+	Enabled    bool              `json:"enabled"`
+	Interface  string            `json:"interface"`
+	Sockets    int               `json:"sockets"`
+	Redirected uint64            `json:"redirected"`
+	NoSocket   uint64            `json:"noSocket"`
+	Inspected  uint64            `json:"inspected"`
+	Malformed  uint64            `json:"malformed"`
+	Cleared    uint64            `json:"cleared"`
+	Blocked    map[string]uint64 `json:"blocked"`
+	Recent     []struct {
+		Time   time.Time `json:"time"`
+		Source string    `json:"source"`
+		Reason string    `json:"reason"`
+		Detail string    `json:"detail"`
+	} `json:"recent"`
+	DNSZones []struct {
+		Domain          string  `json:"domain"`
+		Queries         uint64  `json:"queries"`
+		UniqueRatio     float64 `json:"uniqueRatio"`
+		MeanEntropy     float64 `json:"meanEntropy"`
+		RandomSubdomain bool    `json:"randomSubdomain"`
+	} `json:"dnsZones"`
+	DNSSignatures []struct {
+		Domain string `json:"domain"`
+		MaxQPS uint64 `json:"maxQps"`
+	} `json:"dnsSignatures"`
+}
+
+// baselineStatus mirrors GET /api/v1/baseline.
+type baselineStatus struct {
+	Operational bool `json:"operational"`
+	Samples     int  `json:"samples"`
+	Metrics     struct {
+		BaselinePPS  float64 `json:"baselinePps"`
+		BaselineBPS  float64 `json:"baselineBps"`
+		CurrentPPS   float64 `json:"currentPps"`
+		CurrentBPS   float64 `json:"currentBps"`
+		StdDevPPS    float64 `json:"stdDevPps"`
+		ZScorePPS    float64 `json:"zScorePps"`
+		ZScoreBPS    float64 `json:"zScoreBps"`
+		Anomaly      bool    `json:"anomaly"`
+		AnomalyScore float64 `json:"anomalyScore"`
+		PulseWave    bool    `json:"pulseWave"`
+	} `json:"metrics"`
+	Seasonal struct {
+		Name        string  `json:"name"`
+		BaselinePPS float64 `json:"baselinePps"`
+		StdDevPPS   float64 `json:"stdDevPps"`
+		Samples     int     `json:"samples"`
+		Operational bool    `json:"operational"`
+	} `json:"seasonal"`
+	AdaptiveRates struct {
+		SynPPS    uint64 `json:"synPps"`
+		UDPPPS    uint64 `json:"udpPps"`
+		ICMPPPS   uint64 `json:"icmpPps"`
+		GlobalPPS uint64 `json:"globalPps"`
+		DNSPPS    uint64 `json:"dnsPps"`
+	} `json:"adaptiveRates"`
+	Protocols []struct {
+		Protocol    string  `json:"protocol"`
+		BaselinePPS float64 `json:"baselinePps"`
+		StdDevPPS   float64 `json:"stdDevPps"`
+		CurrentPPS  float64 `json:"currentPps"`
+		Samples     int     `json:"samples"`
+		Operational bool    `json:"operational"`
+	} `json:"protocols"`
+	Pulse struct {
+		Recognized      bool    `json:"recognized"`
+		InBurst         bool    `json:"inBurst"`
+		IntervalSec     float64 `json:"intervalSec"`
+		MeanDurationSec float64 `json:"meanDurationSec"`
+		MeanPeakPPS     float64 `json:"meanPeakPps"`
+	} `json:"pulse"`
+}
+
+// httpFloodStatus mirrors GET /api/v1/httpflood.
+type httpFloodStatus struct {
+	Enabled  bool      `json:"enabled"`
+	Raised   bool      `json:"raised"`
+	Escalate string    `json:"escalate"`
+	LastScan time.Time `json:"lastScan"`
+	Flows    int       `json:"flows"`
+	Targets  []struct {
+		IP             string    `json:"ip"`
+		NewConnsPerSec float64   `json:"newConnsPerSec"`
+		RequestBytes   uint64    `json:"requestBytes"`
+		ResponseBytes  uint64    `json:"responseBytes"`
+		ResponseRatio  float64   `json:"responseRatio"`
+		Flooded        bool      `json:"flooded"`
+		Since          time.Time `json:"since"`
+		LastSeen       time.Time `json:"lastSeen"`
+		StrictUntil    time.Time `json:"strictUntil"`
+	} `json:"targets"`
+}
+
+// scannerList mirrors GET /api/v1/scanners.
+type scannerList struct {
+	Action    string `json:"action"`
+	Threshold uint32 `json:"threshold"`
+	WindowSec uint64 `json:"windowSec"`
+	BlockSec  uint64 `json:"blockSec"`
+	Since     string `json:"since"`
+	Total     int    `json:"total"`
+	Detected  uint64 `json:"detected"`
+	Scanners  []struct {
+		IP            string `json:"ip"`
+		DistinctPorts uint32 `json:"distinctPorts"`
+		PeakPorts     uint32 `json:"peakPorts"`
+		WindowStart   string `json:"windowStart"`
+		Detected      string `json:"detected"`
+		BlockedUntil  string `json:"blockedUntil"`
+	} `json:"scanners"`
+}
+
+// handshakeList mirrors GET /api/v1/handshakes.
+type handshakeList struct {
+	TLSRatePPS    uint64 `json:"tlsRatePps"`
+	QUICRatePPS   uint64 `json:"quicRatePps"`
+	TLSHandshakes uint64 `json:"tlsHandshakes"`
+	QUICInitials  uint64 `json:"quicInitials"`
+	TLSDropped    uint64 `json:"tlsDropped"`
+	QUICDropped   uint64 `json:"quicDropped"`
+	Since         string `json:"since"`
+	Total         int    `json:"total"`
+	Sources       []struct {
+		IP       string `json:"ip"`
+		Kind     string `json:"kind"`
+		Rate     uint32 `json:"rate"`
+		Peak     uint32 `json:"peak"`
+		Dropped  uint64 `json:"dropped"`
+		LastDrop string `json:"lastDrop"`
+	} `json:"sources"`
+}
+
+// sourceRate mirrors one source of GET /api/v1/ratelimit/sources.
+type sourceRate struct {
+	IP             string `json:"ip"`
+	RatePPS        uint64 `json:"ratePps"`
+	Burst          uint64 `json:"burst"`
+	Tokens         uint64 `json:"tokens"`
+	CPUs           int    `json:"cpus"`
+	Packets        uint64 `json:"packets"`
+	DroppedPackets uint64 `json:"droppedPackets"`
+	LastRefill     string `json:"lastRefill"`
+}
+
+// rateLimitList mirrors GET /api/v1/ratelimit/sources.
+type rateLimitList struct {
+	Tracked int          `json:"tracked"`
+	Limited int          `json:"limited"`
+	Sources []sourceRate `json:"sources"`
+}
+
+// rateClassBody is a rate class as sent to and returned by
+// /api/v1/ratelimit/classes.
+type rateClassBody struct {
+	Name         string   `json:"name"`
+	SYNRatePPS   uint64   `json:"synRatePps,omitempty"`
+	UDPRatePPS   uint64   `json:"udpRatePps,omitempty"`
+	ICMPRatePPS  uint64   `json:"icmpRatePps,omitempty"`
+	PPS          uint64   `json:"pps,omitempty"`
+	BPS          uint64   `json:"bps,omitempty"`
+	Sources      []string `json:"sources,omitempty"`
+	Destinations []string `json:"destinations,omitempty"`
+}
+
+// rateClassList mirrors GET /api/v1/ratelimit/classes.
+type rateClassList struct {
+	Classes []struct {
+		rateClassBody
+		Packets        uint64 `json:"packets"`
+		DroppedPackets uint64 `json:"droppedPackets"`
+	} `json:"classes"`
+}
+
+// bpfProgram mirrors GET /api/v1/bpf.
+type bpfProgram struct {
+	ID            uint32 `json:"id"`
+	Name          string `json:"name"`
+	Tag           string `json:"tag"`
+	Object        string `json:"object"`
+	VerifiedInsns uint64 `json:"verifiedInsns"`
+	MemlockBytes  uint64 `json:"memlockBytes"`
+	StatsEnabled  bool   `json:"statsEnabled"`
+	RunCount      uint64 `json:"runCount"`
+	RunTimeNs     uint64 `json:"runTimeNs"`
+}
+
+// bpfMaps mirrors GET /api/v1/bpf/maps.
+type bpfMaps struct {
+	TotalMemlockBytes uint64 `json:"totalMemlockBytes"`
+	Maps              []struct {
+		Name         string `json:"name"`
+		ID           uint32 `json:"id"`
+		Type         string `json:"type"`
+		KeySize      uint32 `json:"keySize"`
+		ValueSize    uint32 `json:"valueSize"`
+		MaxEntries   uint32 `json:"maxEntries"`
+		Entries      int    `json:"entries"`
+		MemlockBytes uint64 `json:"memlockBytes"`
+	} `json:"maps"`
+}
+
+// bpfDiagnostics mirrors GET /api/v1/bpf/diagnostics.
+type bpfDiagnostics struct {
+	Kernel          string `json:"kernel"`
+	Arch            string `json:"arch"`
+	Object          string `json:"object"`
+	MissingFeatures []struct {
+		Name     string `json:"name"`
+		Status   string `json:"status"`
+		Required bool   `json:"required"`
+		Detail   string `json:"detail"`
+	} `json:"missingFeatures"`
+	LastLoadError *struct {
+		Time         string   `json:"time"`
+		Object       string   `json:"object"`
+		Error        string   `json:"error"`
+		VerifierLog  []string `json:"verifierLog"`
+		LogLines     int      `json:"logLines"`
+		LogTruncated bool     `json:"logTruncated"`
+	} `json:"lastLoadError"`
+}
+
+// bpfReplace mirrors POST /api/v1/bpf/replace.
+type bpfReplace struct {
+	OK       bool   `json:"ok"`
+	Object   string `json:"object"`
+	Previous string `json:"previous"`
+	Tag      string `json:"tag,omitempty"`
+}
+
+// aclResult is the JSON schema of acl add/del.
+type aclResult struct {
+	List   string `json:"list"`
+	Action string `json:"action"`
+	CIDR   string `json:"cidr"`
+	TTLSec uint64 `json:"ttlSec,omitempty"`
+	OK     bool   `json:"ok"`
+}
+
+// aclEntry mirrors one entry of GET /api/v1/acl/{blacklist,whitelist}.
+type aclEntry struct {
+	CIDR            string `json:"cidr"`
+	Reason          uint32 `json:"reason,omitempty"`
+	Hits            uint64 `json:"hits"`
+	LastHit         string `json:"lastHit,omitempty"`
+	ExpiresAt       string `json:"expiresAt,omitempty"`
+	TTLRemainingSec int64  `json:"ttlRemainingSec,omitempty"`
+}
+
+var escalationLevels = map[string]uint64{
+	"low": 0, "medium": 1, "high": 2, "critical": 3,
+}
+
+var escalationLevelNames = [...]string{"low", "medium", "high", "critical"}
+
+func cmdStatus(c *client, format output.Format) error {
+	var st statusInfo
+	if err := c.get("/api/v1/status", &st); err != nil {
+		return err
+	}
+	return output.Print(os.Stdout, format, st, func(w io.Writer) {
+		fmt.Fprintf(w, "Enabled:          %t\n", st.Enabled)
+		fmt.Fprintf(w, "Interface:        %s (%s)\n", st.InterfaceName, st.XDPMode)
+		fmt.Fprintf(w, "Version:          %s\n", st.Version)
+		fmt.Fprintf(w, "Uptime:           %s\n", time.Duration(st.UptimeSeconds)*time.Second)
+		fmt.Fprintf(w, "Escalation level: %d\n", st.EscalationLevel)
+		if st.Role != "" {
+			fmt.Fprintf(w, "Cluster role:     %s\n", st.Role)
+		}
+		if st.Maintenance != "" && st.Maintenance != "off" {
+			fmt.Fprintf(w, "Maintenance:      %s\n", st.Maintenance)
+		}
+	})
+}
+
+func cmdStats(c *client, format output.Format, args []string) error {
+	if len(args) > 0 && args[0] == "interval" {
+		return cmdStatsInterval(c, format, args[1:])
+	}
+
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	watch := fs.Bool("watch", false, "Refresh continuously until interrupted")
+	interval := fs.Duration("interval", time.Second, "Refresh interval for -watch")
+	prefix := fs.String("prefix", "", "Only count traffic towards this destination prefix")
+	tenant := fs.String("tenant", "", "Only count traffic towards this tenant's assets")
+	if err := fs.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+
+	show := func() error {
+		if *prefix != "" || *tenant != "" {
+			q := url.Values{}
+			if *prefix != "" {
+				q.Set("prefix", *prefix)
+			}
+			if *tenant != "" {
+				q.Set("tenant", *tenant)
+			}
+			var st prefixStats
+			if err := c.get("/api/v1/stats?"+q.Encode(), &st); err != nil {
+				return err
+			}
+			return output.Print(os.Stdout, format, st, func(w io.Writer) {
+				printPrefixStats(w, st, *watch)
+			})
+		}
+
+		var st map[string]interface{}
+		if err := c.get("/api/v1/stats", &st); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, st, func(w io.Writer) {
+			printStats(w, st, *watch)
+		})
+	}
+
+	if !*watch {
+		return show()
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		if err := show(); err != nil {
+			return err
+		}
+		select {
+		case <-sigCh:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// cmdStatsInterval shows or sets the stats collection interval.
+func cmdStatsInterval(c *client, format output.Format, args []string) error {
+	var res struct {
+		IntervalMS int64 `json:"intervalMs"`
+	}
+	switch len(args) {
+	case 0:
+		if err := c.get("/api/v1/stats/interval", &res); err != nil {
+			return err
+		}
+	case 1:
+		d, err := time.ParseDuration(args[0])
+		if err != nil {
+			return usageError("invalid interval %q (e.g. 100ms, 1s)", args[0])
+		}
+		if err := c.put("/api/v1/stats/interval", map[string]int64{"intervalMs": d.Milliseconds()}, &res); err != nil {
+			return err
+		}
+	default:
+		return usageError("usage: stats interval [DURATION]")
+	}
+	return output.Print(os.Stdout, format, res, func(w io.Writer) {
+		fmt.Fprintf(w, "Collection interval: %v\n", time.Duration(res.IntervalMS)*time.Millisecond)
+	})
+}
+
+// printStats renders the rate summary on one line in watch mode, or the
+// full counter set otherwise.
+func printStats(w io.Writer, st map[string]interface{}, oneLine bool) {
+	if oneLine {
+		fmt.Fprintf(w, "%s  rx %s pps %s bps  drop %s pps %s bps\n",
+			time.Now().Format("15:04:05"),
+			num(st["rxPps"]), num(st["rxBps"]), num(st["dropPps"]), num(st["dropBps"]))
+		return
+	}
+
+	keys := make([]string, 0, len(st))
+	for k := range st {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%-24s %s\n", k, num(st[k]))
+	}
+}
+
+// printPrefixStats renders per-prefix counters on one line in watch mode,
+// or with the drop reason breakdown otherwise.
+func printPrefixStats(w io.Writer, st prefixStats, oneLine bool) {
+	if oneLine {
+		fmt.Fprintf(w, "%s  rx %d pkts %d bytes  dropped %d pkts %d bytes\n",
+			time.Now().Format("15:04:05"), st.RxPackets, st.RxBytes, st.DroppedPackets, st.DroppedBytes)
+		return
+	}
+
+	scope := st.Prefix
+	if st.Tenant != "" {
+		scope = strings.TrimSpace(st.Tenant + " " + st.Prefix)
+	}
+	fmt.Fprintf(w, "%-24s %s\n", "scope", scope)
+	fmt.Fprintf(w, "%-24s %s\n", "prefixes", strings.Join(st.Prefixes, ","))
+	fmt.Fprintf(w, "%-24s %d\n", "destinations", st.Destinations)
+	fmt.Fprintf(w, "%-24s %d\n", "rx_packets", st.RxPackets)
+	fmt.Fprintf(w, "%-24s %d\n", "rx_bytes", st.RxBytes)
+	fmt.Fprintf(w, "%-24s %d\n", "dropped_packets", st.DroppedPackets)
+	fmt.Fprintf(w, "%-24s %d\n", "dropped_bytes", st.DroppedBytes)
+
+	reasons := make([]string, 0, len(st.DropReasons))
+	for r := range st.DropReasons {
+		reasons = append(reasons, r)
+	}
+	sort.Slice(reasons, func(i, j int) bool { return st.DropReasons[reasons[i]] > st.DropReasons[reasons[j]] })
+	for _, r := range reasons {
+		fmt.Fprintf(w, "  %-22s %d\n", r, st.DropReasons[r])
+	}
+}
+
+func cmdACL(c *client, format output.Format, args []string) error {
+	if len(args) < 2 {
+		return usageError("usage: acl list|add|del blacklist|whitelist [-idle D] [-ttl D] [CIDR]")
+	}
+	action, list := args[0], args[1]
+	if list != "blacklist" && list != "whitelist" {
+		return usageError("unknown ACL %q (must be blacklist or whitelist)", list)
+	}
+	path := "/api/v1/acl/" + list
+
+	switch action {
+	case "list":
+		fs := flag.NewFlagSet("acl list", flag.ContinueOnError)
+		idle := fs.Duration("idle", 0, "Only list entries not hit for this long")
+		if err := fs.Parse(args[2:]); err != nil {
+			return usageError("%v", err)
+		}
+		if *idle > 0 {
+			path += "?idle=" + url.QueryEscape(idle.String())
+		}
+		var entries []aclEntry
+		if err := c.get(path, &entries); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, entries, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			if list == "whitelist" {
+				fmt.Fprintln(tw, "CIDR\tHITS\tLAST HIT")
+				for _, e := range entries {
+					lastHit := "never"
+					if e.LastHit != "" {
+						lastHit = e.LastHit
+					}
+					fmt.Fprintf(tw, "%s\t%d\t%s\n", e.CIDR, e.Hits, lastHit)
+				}
+				tw.Flush()
+				return
+			}
+			fmt.Fprintln(tw, "CIDR\tREASON\tHITS\tLAST HIT\tEXPIRES\tTTL")
+			for _, e := range entries {
+				lastHit, expires, ttl := "never", "never", "-"
+				if e.LastHit != "" {
+					lastHit = e.LastHit
+				}
+				if e.ExpiresAt != "" {
+					expires = e.ExpiresAt
+					ttl = (time.Duration(e.TTLRemainingSec) * time.Second).String()
+				}
+				fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\n", e.CIDR, e.Reason, e.Hits, lastHit, expires, ttl)
+			}
+			tw.Flush()
+		})
+
+	case "add", "del":
+		fs := flag.NewFlagSet("acl "+action, flag.ContinueOnError)
+		ttl := fs.Duration("ttl", 0, "Remove the blacklist entry after this long (0 = permanent)")
+		if err := fs.Parse(args[2:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NArg() != 1 {
+			return usageError("usage: acl %s %s [-ttl D] CIDR", action, list)
+		}
+		if *ttl != 0 && (action != "add" || list != "blacklist") {
+			return usageError("-ttl only applies to acl add blacklist")
+		}
+		res := aclResult{List: list, Action: action, CIDR: fs.Arg(0), TTLSec: uint64(ttl.Seconds())}
+		body := map[string]interface{}{"cidr": res.CIDR}
+		if res.TTLSec > 0 {
+			body["ttlSec"] = res.TTLSec
+		}
+
+		var err error
+		if action == "add" {
+			err = c.post(path, body, nil)
+		} else {
+			err = c.delete(path, body, nil)
+		}
+		if err != nil {
+			return err
+		}
+		res.OK = true
+
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			verb := "added to"
+			if action == "del" {
+				verb = "removed from"
+			}
+			fmt.Fprintf(w, "%s %s %s", res.CIDR, verb, list)
+			if *ttl > 0 {
+				fmt.Fprintf(w, " for %s", *ttl)
+			}
+			fmt.Fprintln(w)
+		})
+
+	default:
+		return usageError("unknown acl action %q (must be list, add, or del)", action)
+	}
+}
+
+func cmdRate(c *client, format output.Format, args []string) error {
+	if len(args) == 0 {
+		return usageError("usage: rate get|set [flags]")
+	}
+
+	var rc rateConfig
+	if err := c.get("/api/v1/config/rate", &rc); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "get":
+		// Already fetched.
+
+	case "set":
+		// The API replaces all limits at once, so unspecified ones keep
+		// their current value.
+		fs := flag.NewFlagSet("rate set", flag.ContinueOnError)
+		fs.Uint64Var(&rc.SYNRatePPS, "syn", rc.SYNRatePPS, "Per-source SYN rate (pps)")
+		fs.Uint64Var(&rc.UDPRatePPS, "udp", rc.UDPRatePPS, "Per-source UDP rate (pps)")
+		fs.Uint64Var(&rc.ICMPRatePPS, "icmp", rc.ICMPRatePPS, "Per-source ICMP rate (pps)")
+		fs.Uint64Var(&rc.GlobalPPS, "global-pps", rc.GlobalPPS, "Global PPS limit (0 = disabled)")
+		fs.Uint64Var(&rc.GlobalBPS, "global-bps", rc.GlobalBPS, "Global BPS limit (0 = disabled)")
+		fs.BoolVar(&rc.AdaptiveEnabled, "adaptive", rc.AdaptiveEnabled, "Derive SYN/UDP/ICMP limits from the learned baseline")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NFlag() == 0 {
+			return usageError("rate set: no limits given")
+		}
+		if err := c.put("/api/v1/config/rate", rc, nil); err != nil {
+			return err
+		}
+
+	default:
+		return usageError("unknown rate action %q (must be get or set)", args[0])
+	}
+
+	return output.Print(os.Stdout, format, rc, func(w io.Writer) {
+		fmt.Fprintf(w, "SYN rate:    %d pps\n", rc.SYNRatePPS)
+		fmt.Fprintf(w, "UDP rate:    %d pps\n", rc.UDPRatePPS)
+		fmt.Fprintf(w, "ICMP rate:   %d pps\n", rc.ICMPRatePPS)
+		fmt.Fprintf(w, "Global PPS:  %d\n", rc.GlobalPPS)
+		fmt.Fprintf(w, "Global BPS:  %d\n", rc.GlobalBPS)
+		fmt.Fprintf(w, "Adaptive:    %v\n", rc.AdaptiveEnabled)
+	})
+}
+
+func cmdConfig(c *client, format output.Format, args []string) error {
+	if len(args) == 0 {
+		return usageError("usage: config list|get|set|revisions|diff|rollback [args]")
+	}
+	switch args[0] {
+	case "revisions", "diff", "rollback":
+		return cmdConfigRevisions(c, format, args)
+	}
+
+	var keys []configKey
+	switch args[0] {
+	case "list":
+		if err := c.get("/api/v1/config", &keys); err != nil {
+			return err
+		}
+
+	case "get":
+		if len(args) != 2 {
+			return usageError("usage: config get KEY")
+		}
+		var k configKey
+		if err := c.get("/api/v1/config/"+url.PathEscape(args[1]), &k); err != nil {
+			return err
+		}
+		keys = []configKey{k}
+
+	case "set":
+		if len(args) != 3 {
+			return usageError("usage: config set KEY VALUE")
+		}
+		// true, false and numbers go as JSON literals, anything else
+		// (enum names, addresses) as a string; the API checks the type.
+		value := json.RawMessage(args[2])
+		if !json.Valid(value) {
+			value, _ = json.Marshal(args[2])
+		}
+		var k configKey
+		body := map[string]json.RawMessage{"value": value}
+		if err := c.put("/api/v1/config/"+url.PathEscape(args[1]), body, &k); err != nil {
+			return err
+		}
+		keys = []configKey{k}
+
+	default:
+		return usageError("unknown config action %q (must be list, get, set, revisions, diff, or rollback)", args[0])
+	}
+
+	return output.Print(os.Stdout, format, keys, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "KEY\tVALUE\tRANGE\tDESCRIPTION")
+		for _, k := range keys {
+			valid := ""
+			switch {
+			case k.Values != nil:
+				valid = strings.Join(k.Values, "|")
+			case k.Max != nil:
+				valid = fmt.Sprintf("%d-%d", *k.Min, *k.Max)
+			case k.Type == "bool":
+				valid = "true|false"
+			}
+			desc := k.Description
+			if k.ManagedBy != "" {
+				desc += " (managed by " + k.ManagedBy + ")"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", k.Key, strings.Trim(string(k.Value), `"`), valid, desc)
+		}
+		tw.Flush()
+	})
+}
+
+// cmdConfigRevisions lists configuration revisions, shows what one changed
+// and rolls back to one.
+func cmdConfigRevisions(c *client, format output.Format, args []string) error {
+	switch args[0] {
+	case "revisions":
+		var revs []configRevision
+		if err := c.get("/api/v1/config/revisions", &revs); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, revs, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "REV\tAT\tCHANGES\tAUTHOR\tSOURCE")
+			for _, r := range revs {
+				changes := string(r.Changes)
+				if changes == "" {
+					changes = "-"
+				}
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", r.Number, r.At, changes, r.Author, r.Source)
+			}
+			tw.Flush()
+		})
+
+	case "diff":
+		if len(args) < 2 || len(args) > 3 {
+			return usageError("usage: config diff REV [AGAINST]")
+		}
+		path := "/api/v1/config/revisions/" + url.PathEscape(args[1])
+		if len(args) == 3 {
+			path += "?against=" + url.QueryEscape(args[2])
+		}
+		var rev configRevision
+		if err := c.get(path, &rev); err != nil {
+			return err
+		}
+		var changes []configChange
+		if err := json.Unmarshal(rev.Changes, &changes); err != nil {
+			return fmt.Errorf("decoding changes: %w", err)
+		}
+		return output.Print(os.Stdout, format, changes, func(w io.Writer) {
+			if rev.Against == 0 {
+				fmt.Fprintf(w, "Revision %d is the oldest retained.\n", rev.Number)
+				return
+			}
+			fmt.Fprintf(w, "Revision %d against %d (%s):\n", rev.Number, rev.Against, rev.Source)
+			if len(changes) == 0 {
+				fmt.Fprintln(w, "  no changes")
+				return
+			}
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "SETTING\tFROM\tTO")
+			for _, ch := range changes {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", ch.Path, ch.From, ch.To)
+			}
+			tw.Flush()
+		})
+
+	default: // rollback
+		if len(args) != 2 {
+			return usageError("usage: config rollback REV")
+		}
+		var rev configRevision
+		if err := c.post("/api/v1/config/rollback/"+url.PathEscape(args[1]), nil, &rev); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, rev, func(w io.Writer) {
+			fmt.Fprintf(w, "Rolled back to revision %s; now at revision %d\n", args[1], rev.Number)
+		})
+	}
+}
+
+func cmdReputation(c *client, format output.Format, args []string) error {
+	if len(args) == 0 {
+		return usageError("usage: reputation top|blocked|block|unblock|threshold|export|config [args]")
+	}
+
+	switch args[0] {
+	case "top":
+		fs := flag.NewFlagSet("reputation top", flag.ContinueOnError)
+		limit := fs.Int("limit", 20, "Number of sources to show")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		var top reputationTop
+		if err := c.get(fmt.Sprintf("/api/v1/reputation/top?limit=%d", *limit), &top); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, top, func(w io.Writer) {
+			fmt.Fprintf(w, "Threshold: %d  Tracked: %d\n", top.Threshold, top.Tracked)
+			printReputations(w, top.Offenders)
+		})
+
+	case "blocked":
+		var blocked []reputationEntry
+		if err := c.get("/api/v1/reputation/blocked", &blocked); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, blocked, func(w io.Writer) {
+			printReputations(w, blocked)
+		})
+
+	case "block", "unblock":
+		if len(args) != 2 {
+			return usageError("usage: reputation %s IP", args[0])
+		}
+		body := map[string]string{"ip": args[1]}
+		var err error
+		if args[0] == "block" {
+			err = c.post("/api/v1/reputation/blocked", body, nil)
+		} else {
+			err = c.delete("/api/v1/reputation/blocked", body, nil)
+		}
+		if err != nil {
+			return err
+		}
+		res := map[string]interface{}{"ip": args[1], "action": args[0], "ok": true}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			fmt.Fprintf(w, "%s %sed\n", args[1], args[0])
+		})
+
+	case "threshold":
+		var res struct {
+			Threshold uint32 `json:"threshold"`
+		}
+		switch {
+		case len(args) == 2 && args[1] == "get":
+			if err := c.get("/api/v1/reputation/threshold", &res); err != nil {
+				return err
+			}
+		case len(args) == 3 && args[1] == "set":
+			n, err := strconv.ParseUint(args[2], 10, 32)
+			if err != nil {
+				return usageError("invalid threshold %q", args[2])
+			}
+			if err := c.put("/api/v1/reputation/threshold", map[string]uint64{"threshold": n}, &res); err != nil {
+				return err
+			}
+		default:
+			return usageError("usage: reputation threshold get|set N")
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			fmt.Fprintf(w, "Threshold: %d\n", res.Threshold)
+		})
+
+	case "export":
+		if len(args) != 3 || (args[1] != "csv" && args[1] != "json") {
+			return usageError("usage: reputation export csv|json FILE")
+		}
+		name := args[2]
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		if err := c.download("/api/v1/reputation/export?format="+args[1], f); err != nil {
+			f.Close()
+			os.Remove(name)
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		res := map[string]string{"file": name}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			fmt.Fprintf(w, "Saved %s\n", name)
+		})
+
+	case "config":
+		return cmdReputationConfig(c, format, args[1:])
+
+	default:
+		return usageError("unknown reputation action %q (must be top, blocked, block, unblock, threshold, export, or config)", args[0])
+	}
+}
+
+func printReputations(w io.Writer, reps []reputationEntry) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "IP\tSCORE\tPACKETS\tDROPPED\tBLOCKED\tLAST SEEN")
+	for _, r := range reps {
+		blocked := "-"
+		switch {
+		case r.Manual:
+			blocked = "manual"
+		case r.Origin != "":
+			blocked = "shared by " + r.Origin
+		case r.Blocked:
+			blocked = "auto"
+		}
+		lastSeen := "-"
+		if !r.LastSeen.IsZero() {
+			lastSeen = r.LastSeen.Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\t%s\n",
+			r.IP, r.Score, r.TotalPackets, r.DroppedPackets, blocked, lastSeen)
+	}
+	tw.Flush()
+}
+
+func cmdReputationConfig(c *client, format output.Format, args []string) error {
+	if len(args) == 0 {
+		return usageError("usage: reputation config get|set [flags]")
+	}
+	const path = "/api/v1/reputation/config"
+
+	var rc reputationConfig
+	if err := c.get(path, &rc); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "get":
+		// Already fetched.
+
+	case "set":
+		// Unspecified settings keep their current value.
+		fs := flag.NewFlagSet("reputation config set", flag.ContinueOnError)
+		fs.Uint64Var(&rc.PollIntervalSec, "poll-interval", rc.PollIntervalSec, "Seconds between reputation map polls")
+		fs.StringVar(&rc.Decay, "decay", rc.Decay, "Decay mode (linear or exponential)")
+		fs.Var(uint32Value{&rc.DecayRate}, "decay-rate", "Linear decay per poll (points)")
+		fs.Uint64Var(&rc.DecayHalfLifeSec, "half-life", rc.DecayHalfLifeSec, "Exponential decay half-life (seconds)")
+		fs.Var(uint32Value{&rc.UnblockRatio}, "unblock-ratio", "Auto-unblock below threshold / ratio")
+		fs.Var(uint32Value{&rc.Weights.SYNNoACK}, "weight-syn", "Score per SYN flood drop")
+		fs.Var(uint32Value{&rc.Weights.RateExceeded}, "weight-rate", "Score per rate limit drop")
+		fs.Var(uint32Value{&rc.Weights.ProtoAnomaly}, "weight-proto", "Score per protocol anomaly drop")
+		fs.Var(uint32Value{&rc.Weights.BadPayload}, "weight-payload", "Score per payload/fingerprint drop")
+		fs.Var(uint32Value{&rc.Weights.Fragment}, "weight-fragment", "Score per fragment drop")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NFlag() == 0 {
+			return usageError("reputation config set: no settings given")
+		}
+		if err := c.put(path, rc, &rc); err != nil {
+			return err
+		}
+
+	default:
+		return usageError("unknown reputation config action %q (must be get or set)", args[0])
+	}
+
+	return output.Print(os.Stdout, format, rc, func(w io.Writer) {
+		fmt.Fprintf(w, "Poll interval: %ds\n", rc.PollIntervalSec)
+		if rc.Decay == "exponential" {
+			fmt.Fprintf(w, "Decay:         exponential (half-life %ds)\n", rc.DecayHalfLifeSec)
+		} else {
+			fmt.Fprintf(w, "Decay:         linear (%d per poll)\n", rc.DecayRate)
+		}
+		fmt.Fprintf(w, "Unblock below: threshold / %d\n", rc.UnblockRatio)
+		fmt.Fprintf(w, "Weights:       syn=%d rate=%d proto=%d payload=%d fragment=%d\n",
+			rc.Weights.SYNNoACK, rc.Weights.RateExceeded, rc.Weights.ProtoAnomaly,
+			rc.Weights.BadPayload, rc.Weights.Fragment)
+	})
+}
+
+// uint32Value is a flag.Value for uint32 settings.
+type uint32Value struct{ p *uint32 }
+
+func (v uint32Value) String() string {
+	if v.p == nil {
+		return "0"
+	}
+	return strconv.FormatUint(uint64(*v.p), 10)
+}
+
+func (v uint32Value) Set(s string) error {
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return err
+	}
+	*v.p = uint32(n)
+	return nil
+}
+
+func cmdEscalation(c *client, format output.Format, args []string) error {
+	if len(args) == 0 {
+		return usageError("usage: escalation get|set|pin|unpin|history")
+	}
+
+	switch args[0] {
+	case "get":
+	case "set":
+		fs := flag.NewFlagSet("escalation set", flag.ContinueOnError)
+		reason := fs.String("reason", "", "Reason recorded in the escalation history")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NArg() != 1 {
+			return usageError("usage: escalation set [-reason TEXT] low|medium|high|critical")
+		}
+		name := fs.Arg(0)
+		level, ok := escalationLevels[name]
+		if !ok {
+			n, err := strconv.ParseUint(name, 10, 64)
+			if err != nil || n > 3 {
+				return usageError("invalid escalation level %q", name)
+			}
+			level = n
+		}
+		var err error
+		if *reason != "" {
+			body := map[string]string{"level": escalationLevelNames[level], "reason": *reason}
+			err = c.put("/api/v1/escalation/level", body, nil)
+		} else {
+			err = c.put("/api/v1/escalation", map[string]uint64{"level": level}, nil)
+		}
+		if err != nil {
+			return err
+		}
+	case "pin":
+		fs := flag.NewFlagSet("escalation pin", flag.ContinueOnError)
+		reason := fs.String("reason", "", "Why the level is pinned (required)")
+		duration := fs.Duration("for", 0, "How long the pin holds; 0 until unpinned")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NArg() != 1 || *reason == "" {
+			return usageError("usage: escalation pin -reason TEXT [-for DURATION] medium|high|critical")
+		}
+		body := map[string]interface{}{
+			"level":       fs.Arg(0),
+			"durationSec": uint64(duration.Seconds()),
+			"reason":      *reason,
+		}
+		if err := c.put("/api/v1/escalation/pin", body, nil); err != nil {
+			return err
+		}
+	case "unpin":
+		if err := c.delete("/api/v1/escalation/pin", nil, nil); err != nil {
+			return err
+		}
+	case "history":
+		return cmdEscalationHistory(c, format, args[1:])
+	default:
+		return usageError("unknown escalation action %q (must be get, set, pin, unpin or history)", args[0])
+	}
+
+	var info escalationInfo
+	if err := c.get("/api/v1/escalation", &info); err != nil {
+		return err
+	}
+	return output.Print(os.Stdout, format, info, func(w io.Writer) {
+		fmt.Fprintf(w, "Escalation level: %s (%d)\n", info.Name, info.Level)
+		if info.LevelSince != "" {
+			fmt.Fprintf(w, "Since:            %s", info.LevelSince)
+			if info.MinDwell > 0 {
+				fmt.Fprintf(w, " (min dwell %s)", time.Duration(info.MinDwell*float64(time.Second)))
+			}
+			fmt.Fprintln(w)
+		}
+		if p := info.Pin; p != nil {
+			until := "unpinned"
+			if p.Until != "" {
+				until = p.Until
+			}
+			fmt.Fprintf(w, "Pinned:           %s until %s (%s)\n", p.Level, until, p.Reason)
+		}
+		if len(info.Prefixes) > 0 {
+			fmt.Fprintf(w, "Highest level:    %s\n\n", info.MaxLevel)
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "PREFIX\tLEVEL\tSINCE\tDROP RATIO\tDROP PPS")
+			for _, p := range info.Prefixes {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%.2f\t%.0f\n", p.Prefix, p.Level, p.Since, p.DropRatio, p.DropPPS)
+			}
+			tw.Flush()
+		}
+		if len(info.Indicators) > 0 {
+			names := make([]string, 0, len(info.Indicators))
+			for name := range info.Indicators {
+				names = append(names, name+"="+info.Indicators[name])
+			}
+			sort.Strings(names)
+			fmt.Fprintf(w, "Indicators:       %s\n", strings.Join(names, ", "))
+		}
+		if len(info.Triggers) == 0 {
+			return
+		}
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TRIGGER\tCURRENT\tTHRESHOLD\tACTIVE")
+		for _, t := range info.Triggers {
+			fmt.Fprintf(tw, "%s\t%.2f\t%.2f\t%t\n", t.Name, t.Current, t.Threshold, t.Active)
+		}
+		tw.Flush()
+	})
+}
+
+func cmdEscalationHistory(c *client, format output.Format, args []string) error {
+	fs := flag.NewFlagSet("escalation history", flag.ContinueOnError)
+	limit := fs.Int("limit", 20, "Number of transitions to show")
+	offset := fs.Int("offset", 0, "Number of newer transitions to skip")
+	if err := fs.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+
+	var h escalationHistory
+	path := fmt.Sprintf("/api/v1/escalation/history?limit=%d&offset=%d", *limit, *offset)
+	if err := c.get(path, &h); err != nil {
+		return err
+	}
+	return output.Print(os.Stdout, format, h, func(w io.Writer) {
+		fmt.Fprintf(w, "%d transitions\n\n", h.Total)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIME\tFROM\tTO\tREASON")
+		for _, e := range h.Entries {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", e.Timestamp, e.From, e.To, e.Reason)
+		}
+		tw.Flush()
+	})
+}
+
+func cmdApprovals(c *client, format output.Format, args []string) error {
+	if len(args) == 0 || args[0] == "list" {
+		var reqs []approvalRequest
+		if err := c.get("/api/v1/approvals", &reqs); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, reqs, func(w io.Writer) {
+			if len(reqs) == 0 {
+				fmt.Fprintln(w, "No approval requests")
+				return
+			}
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tACTION\tSTATUS\tCREATED\tDEADLINE\tBY\tSUMMARY")
+			for _, r := range reqs {
+				by := r.ResolvedBy
+				if by == "" {
+					by = "-"
+				}
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					r.ID, r.Action, r.Status, r.Created.Format(time.DateTime),
+					r.Deadline.Format(time.TimeOnly), by, r.Summary)
+			}
+			tw.Flush()
+		})
+	}
+
+	switch args[0] {
+	case "approve", "reject":
+		fs := flag.NewFlagSet("approvals "+args[0], flag.ContinueOnError)
+		reason := fs.String("reason", "", "Why the mitigation is run or vetoed")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NArg() != 1 {
+			return usageError("usage: approvals %s [-reason TEXT] ID", args[0])
+		}
+		id, err := strconv.ParseUint(fs.Arg(0), 10, 32)
+		if err != nil {
+			return usageError("invalid approval request ID %q", fs.Arg(0))
+		}
+		var r approvalRequest
+		body := map[string]interface{}{"id": id, "action": args[0], "reason": *reason}
+		if err := c.post("/api/v1/approvals", body, &r); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, r, func(w io.Writer) {
+			fmt.Fprintf(w, "Request %d (%s) %s\n", r.ID, r.Action, r.Status)
+		})
+
+	default:
+		return usageError("unknown approvals action %q (must be list, approve, or reject)", args[0])
+	}
+}
+
+func cmdConntrack(c *client, format output.Format, args []string) error {
+	action := "show"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "show":
+		var info conntrackInfo
+		if err := c.get("/api/v1/conntrack", &info); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, info, func(w io.Writer) {
+			fmt.Fprintf(w, "Enabled:            %t\n", info.Enabled)
+			fmt.Fprintf(w, "Active connections: %d\n", info.ActiveConnections)
+		})
+
+	case "list":
+		fs := flag.NewFlagSet("conntrack list", flag.ContinueOnError)
+		src := fs.String("src", "", "Source prefix filter")
+		dst := fs.String("dst", "", "Destination prefix filter")
+		proto := fs.String("proto", "", "Protocol filter (tcp, udp, icmp, or number)")
+		state := fs.String("state", "", "State filter (e.g. established, syn_recv)")
+		offset := fs.Int("offset", 0, "Skip this many flows")
+		limit := fs.Int("limit", 50, "Maximum flows to show")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+
+		q := url.Values{}
+		for k, v := range map[string]string{"src": *src, "dst": *dst, "proto": *proto, "state": *state} {
+			if v != "" {
+				q.Set(k, v)
+			}
+		}
+		q.Set("offset", strconv.Itoa(*offset))
+		q.Set("limit", strconv.Itoa(*limit))
+
+		var res conntrackFlows
+		if err := c.get("/api/v1/conntrack/flows?"+q.Encode(), &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "SOURCE\tDESTINATION\tPROTO\tSTATE\tPKTS FWD/REV\tBYTES FWD/REV\tLAST SEEN")
+			for _, f := range res.Flows {
+				st := f.State
+				if f.Suspect {
+					st += "*"
+				}
+				fmt.Fprintf(tw, "%s:%d\t%s:%d\t%d\t%s\t%d/%d\t%d/%d\t%s\n",
+					f.SrcIP, f.SrcPort, f.DstIP, f.DstPort, f.Protocol, st,
+					f.PacketsFwd, f.PacketsRev, f.BytesFwd, f.BytesRev,
+					f.LastSeen.Format(time.TimeOnly))
+			}
+			tw.Flush()
+			fmt.Fprintf(w, "%d-%d of %d flows (* = suspect)\n",
+				min(res.Offset+1, res.Total), res.Offset+len(res.Flows), res.Total)
+		})
+
+	case "flush":
+		var res struct {
+			EntriesRemoved uint64 `json:"entriesRemoved"`
+		}
+		if err := c.post("/api/v1/conntrack/flush", nil, &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			fmt.Fprintf(w, "Flushed %d conntrack entries\n", res.EntriesRemoved)
+		})
+
+	default:
+		return usageError("unknown conntrack action %q (must be show, list, or flush)", action)
+	}
+}
+
+func cmdThreatIntel(c *client, format output.Format, args []string) error {
+	if len(args) == 0 {
+		return usageError("usage: threat-intel feeds|add|del|enable|disable|set|lookup|stats|push|quarantine|sync [args]")
+	}
+	const path = "/api/v1/threatintel/feeds"
+
+	switch args[0] {
+	case "feeds":
+		var res threatFeeds
+		if err := c.get(path, &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			fmt.Fprintf(w, "Total entries: %d\n", res.TotalEntries)
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tTYPE\tENABLED\tENTRIES\tACTION\tCONFIDENCE\tLAST SYNC\tERROR")
+			for _, f := range res.Feeds {
+				lastSync := f.LastSync
+				switch {
+				case f.Sync != nil && f.Sync.Percent > 0:
+					lastSync = fmt.Sprintf("syncing, %.0f%% (%s left)", f.Sync.Percent, time.Duration(f.Sync.ETASec)*time.Second)
+				case f.Sync != nil:
+					lastSync = fmt.Sprintf("syncing, %d bytes read", f.Sync.Bytes)
+				case lastSync == "":
+					lastSync = "never"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%t\t%d\t%s\t%d\t%s\t%s\n",
+					f.Name, f.Type, f.Enabled, f.Entries, f.Action, f.Confidence, lastSync, f.Error)
+			}
+			tw.Flush()
+		})
+
+	case "add":
+		if len(args) != 4 {
+			return usageError("usage: threat-intel add NAME URL TYPE")
+		}
+		body := map[string]string{"name": args[1], "url": args[2], "type": args[3]}
+		if err := c.post(path, body, nil); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, body, func(w io.Writer) {
+			fmt.Fprintf(w, "Feed %s added\n", args[1])
+		})
+
+	case "del":
+		if len(args) != 2 {
+			return usageError("usage: threat-intel del NAME")
+		}
+		var res map[string]interface{}
+		if err := c.delete(path, map[string]string{"name": args[1]}, &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			fmt.Fprintf(w, "Feed %s removed\n", args[1])
+		})
+
+	case "enable", "disable", "set":
+		if len(args) < 2 {
+			return usageError("usage: threat-intel enable|disable NAME, or threat-intel set NAME [-confidence N] [-action A]")
+		}
+		body := map[string]interface{}{"name": args[1]}
+		if args[0] == "set" {
+			fs := flag.NewFlagSet("threat-intel set", flag.ContinueOnError)
+			confidence := fs.Uint("confidence", 0, "Confidence (0-100) for entries without a provider score")
+			action := fs.String("action", "", "Action for listed sources: drop, rate_limit, or monitor")
+			if err := fs.Parse(args[2:]); err != nil {
+				return usageError("%v", err)
+			}
+			if fs.NFlag() == 0 {
+				return usageError("threat-intel set: no settings given")
+			}
+			fs.Visit(func(f *flag.Flag) {
+				switch f.Name {
+				case "confidence":
+					body["confidence"] = *confidence
+				case "action":
+					body["action"] = *action
+				}
+			})
+		} else {
+			if len(args) != 2 {
+				return usageError("usage: threat-intel %s NAME", args[0])
+			}
+			body["enabled"] = args[0] == "enable"
+		}
+
+		var f threatFeed
+		if err := c.put(path, body, &f); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, f, func(w io.Writer) {
+			fmt.Fprintf(w, "Feed %s: enabled=%t action=%s confidence=%d\n",
+				f.Name, f.Enabled, f.Action, f.Confidence)
+		})
+
+	case "lookup":
+		if len(args) != 2 {
+			return usageError("usage: threat-intel lookup IP|CIDR")
+		}
+		var l threatLookup
+		if err := c.get("/api/v1/threatintel/lookup?ip="+url.QueryEscape(args[1]), &l); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, l, func(w io.Writer) {
+			if !l.Listed {
+				fmt.Fprintf(w, "%s: not listed\n", l.IP)
+				return
+			}
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "FEED\tPREFIX\tTHREAT\tCONFIDENCE\tACTION\tLAST SEEN")
+			for _, m := range l.Matches {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n",
+					m.Feed, m.Prefix, m.ThreatType, m.Confidence, m.Action, m.LastSeen)
+			}
+			tw.Flush()
+			if d := l.Dataplane; d != nil {
+				fmt.Fprintf(w, "\nApplied in the data plane: %s (confidence %d, %s)\n", d.Feed, d.Confidence, d.Action)
+			}
+		})
+
+	case "stats":
+		var stats []threatFeedStats
+		if err := c.get("/api/v1/threatintel/stats", &stats); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, stats, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "FEED\tENABLED\tENTRIES\tMATCHED\tDROPPED\tRATE LIMITED\tMONITORED")
+			for _, f := range stats {
+				fmt.Fprintf(tw, "%s\t%v\t%d\t%d\t%d\t%d\t%d\n",
+					f.Feed, f.Enabled, f.Entries, f.Matched, f.Dropped, f.RateLimited, f.Monitored)
+			}
+			tw.Flush()
+		})
+
+	case "push":
+		fs := flag.NewFlagSet("threat-intel push", flag.ContinueOnError)
+		source := fs.String("source", "", "Push feed to add the indicators to (default push)")
+		threatType := fs.String("type", "", "Threat type: botnet, scanner, tor_exit, proxy, or malware")
+		confidence := fs.Int("confidence", -1, "Confidence (0-100, default 80)")
+		action := fs.String("action", "", "Action: drop, rate_limit, or monitor (default drop)")
+		ttl := fs.Duration("ttl", 0, "Expire the indicators after this long (0 = server default)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NArg() == 0 {
+			return usageError("usage: threat-intel push [-source NAME] [-type T] [-confidence N] [-action A] [-ttl D] IP|CIDR...")
+		}
+		indicators := make([]map[string]interface{}, 0, fs.NArg())
+		for _, prefix := range fs.Args() {
+			ind := map[string]interface{}{"ip": prefix}
+			if *threatType != "" {
+				ind["type"] = *threatType
+			}
+			if *confidence >= 0 {
+				ind["confidence"] = *confidence
+			}
+			if *action != "" {
+				ind["action"] = *action
+			}
+			if *ttl > 0 {
+				ind["ttlSec"] = int64(ttl.Seconds())
+			}
+			indicators = append(indicators, ind)
+		}
+
+		var res threatPushResult
+		body := map[string]interface{}{"source": *source, "indicators": indicators}
+		if err := c.post("/api/v1/threat-intel/push", body, &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			fmt.Fprintf(w, "%d indicators pushed to feed %s\n", res.Accepted, res.Feed)
+		})
+
+	case "quarantine":
+		const qpath = "/api/v1/threatintel/quarantine"
+		if len(args) == 1 || args[1] == "list" {
+			var list []threatQuarantine
+			if err := c.get(qpath, &list); err != nil {
+				return err
+			}
+			return output.Print(os.Stdout, format, list, func(w io.Writer) {
+				if len(list) == 0 {
+					fmt.Fprintln(w, "No quarantined feed syncs")
+					return
+				}
+				tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(tw, "FEED\tSINCE\tENTRIES\tPREVIOUS\tREASON")
+				for _, q := range list {
+					fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", q.Feed, q.At, q.Entries, q.Previous, q.Reason)
+				}
+				tw.Flush()
+			})
+		}
+		if len(args) != 3 || (args[1] != "approve" && args[1] != "reject") {
+			return usageError("usage: threat-intel quarantine [list], or threat-intel quarantine approve|reject FEED")
+		}
+		var f threatFeed
+		if err := c.post(qpath, map[string]string{"feed": args[2], "action": args[1]}, &f); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, f, func(w io.Writer) {
+			if args[1] == "approve" {
+				fmt.Fprintf(w, "Quarantined sync of %s approved: %d entries\n", f.Name, f.Entries)
+			} else {
+				fmt.Fprintf(w, "Quarantined sync of %s rejected, %d entries kept\n", f.Name, f.Entries)
+			}
+		})
+
+	case "sync":
+		var res map[string]interface{}
+		if err := c.post("/api/v1/threatintel/sync", nil, &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			fmt.Fprintln(w, "Threat intelligence feeds synced")
+		})
+
+	default:
+		return usageError("unknown threat-intel action %q (must be feeds, add, del, enable, disable, set, lookup, stats, push, quarantine, or sync)", args[0])
+	}
+}
+
+func cmdCapture(c *client, format output.Format, args []string) error {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	var st captureStatus
+	switch action {
+	case "status":
+		if err := c.get("/api/v1/capture", &st); err != nil {
+			return err
+		}
+
+	case "start":
+		fs := flag.NewFlagSet("capture start", flag.ContinueOnError)
+		mode := fs.String("mode", "drops", "Capture dropped packets only (drops) or all traffic (all)")
+		duration := fs.Duration("duration", 0, "Stop after this long (0 = until stopped)")
+		maxPackets := fs.Uint64("max-packets", 0, "Stop after this many packets (0 = unlimited)")
+		sample := fs.Uint("sample", 0, "Capture 1 in N packets (0 = configured default)")
+		snaplen := fs.Uint("snaplen", 0, "Bytes kept per packet (0 = configured default)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		body := map[string]interface{}{
+			"mode":        *mode,
+			"durationSec": uint64(duration.Seconds()),
+			"maxPackets":  *maxPackets,
+			"sampleRate":  *sample,
+			"snaplen":     *snaplen,
+		}
+		if err := c.post("/api/v1/capture", body, &st); err != nil {
+			return err
+		}
+
+	case "stop":
+		if err := c.delete("/api/v1/capture", nil, &st); err != nil {
+			return err
+		}
+
+	case "files":
+		var files []captureFile
+		if err := c.get("/api/v1/capture/files", &files); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, files, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tSIZE\tMODIFIED")
+			for _, f := range files {
+				fmt.Fprintf(tw, "%s\t%d\t%s\n", f.Name, f.Size, f.Modified.Format(time.DateTime))
+			}
+			tw.Flush()
+		})
+
+	case "get":
+		if len(args) != 2 {
+			return usageError("usage: capture get FILE")
+		}
+		name := args[1]
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		if err := c.download("/api/v1/capture/files/"+url.PathEscape(name), f); err != nil {
+			f.Close()
+			os.Remove(name)
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		res := map[string]string{"file": name}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			fmt.Fprintf(w, "Saved %s\n", name)
+		})
+
+	case "rm":
+		if len(args) != 2 {
+			return usageError("usage: capture rm FILE")
+		}
+		if err := c.delete("/api/v1/capture/files/"+url.PathEscape(args[1]), nil, nil); err != nil {
+			return err
+		}
+		res := map[string]interface{}{"file": args[1], "ok": true}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			fmt.Fprintf(w, "Removed %s\n", args[1])
+		})
+
+	default:
+		return usageError("unknown capture action %q (must be status, start, stop, files, get, or rm)", action)
+	}
+
+	return output.Print(os.Stdout, format, st, func(w io.Writer) {
+		if st.StartedAt.IsZero() {
+			fmt.Fprintln(w, "No capture has run")
+			return
+		}
+		state := "stopped"
+		if st.Active {
+			state = "running"
+		}
+		fmt.Fprintf(w, "Capture:  %s (%s, 1 in %d, snaplen %d)\n", state, st.Mode, st.SampleRate, st.Snaplen)
+		if st.Reason != "" {
+			fmt.Fprintf(w, "Reason:   %s\n", st.Reason)
+		}
+		fmt.Fprintf(w, "Started:  %s\n", st.StartedAt.Format(time.DateTime))
+		if !st.Deadline.IsZero() && st.Active {
+			fmt.Fprintf(w, "Deadline: %s\n", st.Deadline.Format(time.DateTime))
+		}
+		fmt.Fprintf(w, "Packets:  %d (%d bytes, %d lost)\n", st.Packets, st.Bytes, st.Lost)
+		fmt.Fprintf(w, "File:     %s (%d written)\n", st.File, st.Files)
+	})
+}
+
+func cmdSignatures(c *client, format output.Format, args []string) error {
+	if len(args) == 0 {
+		return usageError("usage: signatures proposals|approve|reject [ID]")
+	}
+
+	switch args[0] {
+	case "proposals":
+		var props []sigProposal
+		if err := c.get("/api/v1/signatures/proposals", &props); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, props, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tSTATUS\tPROTO\tFLAGS\tSRC PORT\tDST PORT\tLENGTH\tPAYLOAD\tSHARE\tSOURCES\tLAST SEEN")
+			for _, p := range props {
+				flags := "-"
+				if p.FlagsMask != 0 {
+					flags = fmt.Sprintf("0x%02x", p.FlagsMatch)
+				}
+				payload := "-"
+				if p.PayloadHash != 0 {
+					payload = fmt.Sprintf("%08x", p.PayloadHash)
+				}
+				fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%.1f%%\t%d\t%s\n",
+					p.ID, p.Status, p.Protocol, flags,
+					portRange(p.SrcPortMin, p.SrcPortMax), portRange(p.DstPortMin, p.DstPortMax),
+					portRange(p.PktLenMin, p.PktLenMax), payload, p.SharePct, p.Sources,
+					p.LastSeen.Format(time.TimeOnly))
+			}
+			tw.Flush()
+		})
+
+	case "approve", "reject":
+		if len(args) != 2 {
+			return usageError("usage: signatures %s ID", args[0])
+		}
+		id, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return usageError("invalid proposal ID %q", args[1])
+		}
+		var p sigProposal
+		body := map[string]interface{}{"id": id, "action": args[0]}
+		if err := c.post("/api/v1/signatures/proposals", body, &p); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, p, func(w io.Writer) {
+			if p.Index != nil {
+				fmt.Fprintf(w, "Proposal %d %s (signature index %d)\n", p.ID, p.Status, *p.Index)
+			} else {
+				fmt.Fprintf(w, "Proposal %d %s\n", p.ID, p.Status)
+			}
+		})
+
+	default:
+		return usageError("unknown signatures action %q (must be proposals, approve, or reject)", args[0])
+	}
+}
+
+func cmdCluster(c *client, format output.Format, args []string) error {
+	if len(args) != 1 || args[0] != "status" {
+		return usageError("usage: cluster status")
+	}
+
+	var st clusterStatus
+	if err := c.get("/api/v1/cluster", &st); err != nil {
+		return err
+	}
+	return output.Print(os.Stdout, format, st, func(w io.Writer) {
+		fmt.Fprintf(w, "Node:         %s (%s)\n", st.Node, st.Role)
+		if !st.ActiveSince.IsZero() {
+			fmt.Fprintf(w, "Active since: %s\n", st.ActiveSince.Format(time.DateTime))
+		}
+		peer := st.Peer
+		if st.PeerNode != "" {
+			peer = fmt.Sprintf("%s at %s (%s)", st.PeerNode, st.Peer, st.PeerRole)
+		}
+		fmt.Fprintf(w, "Peer:         %s\n", peer)
+		if st.LastContact.IsZero() {
+			fmt.Fprintf(w, "Last contact: never\n")
+		} else {
+			fmt.Fprintf(w, "Last contact: %s\n", st.LastContact.Format(time.DateTime))
+		}
+		if st.LastError != "" {
+			fmt.Fprintf(w, "Last error:   %s\n", st.LastError)
+		}
+		fmt.Fprintf(w, "Entries:      %d (%d pending)\n", st.Entries, st.Pending)
+	})
+}
+
+func cmdGeoIP(c *client, format output.Format, args []string) error {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	const (
+		dbPath     = "/api/v1/geoip/database"
+		policyPath = "/api/v1/geoip/policies"
+	)
+
+	switch action {
+	case "status":
+		var db geoipDatabase
+		if err := c.get(dbPath, &db); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, db, func(w io.Writer) {
+			fmt.Fprintf(w, "Database:   %s\n", db.Database)
+			if db.Locations != "" {
+				fmt.Fprintf(w, "Locations:  %s\n", db.Locations)
+			}
+			fmt.Fprintf(w, "Loaded at:  %s\n", db.LoadedAt)
+			fmt.Fprintf(w, "Prefixes:   %d\n", db.Prefixes)
+			if l := db.Load; l != nil {
+				fmt.Fprintf(w, "Loading:    %s since %s\n", l.Database, l.Started)
+				eta := time.Duration(l.ETASec) * time.Second
+				if l.Phase == "applying" {
+					fmt.Fprintf(w, "Progress:   applying %.1f%%, %d/%d changes (%d added, %d changed, %d removed)\n",
+						l.Percent, l.Applied, l.Changes, l.Added, l.Changed, l.Removed)
+					fmt.Fprintf(w, "Rate:       %.0f changes/s, %s left\n", l.Rate, eta)
+				} else {
+					fmt.Fprintf(w, "Progress:   reading %.1f%%, %d prefixes so far\n", l.Percent, l.Read)
+					fmt.Fprintf(w, "Rate:       %.0f prefixes/s, %s left\n", l.Rate, eta)
+				}
+			}
+		})
+
+	case "cancel":
+		var res map[string]bool
+		if err := c.delete(dbPath, nil, &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			fmt.Fprintln(w, "GeoIP data load canceled")
+		})
+
+	case "load":
+		fs := flag.NewFlagSet("geoip load", flag.ContinueOnError)
+		locations := fs.String("locations", "", "Locations CSV, required with a blocks CSV")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NArg() > 1 {
+			return usageError("usage: geoip load [-locations CSV] [DB]")
+		}
+		body := map[string]string{"database": fs.Arg(0), "locations": *locations}
+		var db geoipDatabase
+		if err := c.post(dbPath, body, &db); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, db, func(w io.Writer) {
+			fmt.Fprintf(w, "Loaded %d prefixes from %s\n", db.Prefixes, db.Database)
+		})
+
+	case "list":
+		var p geoipPolicies
+		if err := c.get(policyPath, &p); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, p, func(w io.Writer) {
+			countries := make([]string, 0, len(p.Active))
+			for cc := range p.Active {
+				countries = append(countries, cc)
+			}
+			sort.Strings(countries)
+			expires := make(map[string]string, len(p.Temporary))
+			for _, t := range p.Temporary {
+				expires[t.Country] = t.Expires
+			}
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "COUNTRY\tACTIVE\tPOLICY\tRATE PPS\tTEMPORARY UNTIL")
+			for _, cc := range countries {
+				policy, rate, until := p.Policies[cc], "-", expires[cc]
+				if policy == "" {
+					policy = "-"
+				}
+				if pps, ok := p.RateLimits[cc]; ok {
+					rate = fmt.Sprint(pps)
+				}
+				if until == "" {
+					until = "-"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", cc, p.Active[cc], policy, rate, until)
+			}
+			tw.Flush()
+
+			if len(p.Continents) == 0 {
+				return
+			}
+			continents := make([]string, 0, len(p.Continents))
+			for c := range p.Continents {
+				continents = append(continents, c)
+			}
+			sort.Strings(continents)
+			fmt.Fprintln(w)
+			tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "CONTINENT\tPOLICY")
+			for _, c := range continents {
+				fmt.Fprintf(tw, "%s\t%s\n", c, p.Continents[c])
+			}
+			tw.Flush()
+		})
+
+	case "set":
+		fs := flag.NewFlagSet("geoip set", flag.ContinueOnError)
+		ttl := fs.Duration("ttl", 0, "Revert after this long (0 = until removed)")
+		reason := fs.String("reason", "", "Why a temporary policy was set, for the audit log")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NArg() != 2 {
+			return usageError("usage: geoip set [-ttl D] [-reason TEXT] CC|CONTINENT|REGION pass|drop|rate_limit|monitor")
+		}
+		body := map[string]interface{}{
+			"action": fs.Arg(1),
+			"ttlSec": int64(ttl.Seconds()),
+			"reason": *reason,
+		}
+		body[geoipTarget(fs.Arg(0))] = fs.Arg(0)
+		if err := c.post(policyPath, body, nil); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, body, func(w io.Writer) {
+			if *ttl > 0 {
+				fmt.Fprintf(w, "%s set to %s for %s\n", strings.ToUpper(fs.Arg(0)), fs.Arg(1), ttl)
+				return
+			}
+			fmt.Fprintf(w, "%s set to %s\n", strings.ToUpper(fs.Arg(0)), fs.Arg(1))
+		})
+
+	case "del":
+		fs := flag.NewFlagSet("geoip del", flag.ContinueOnError)
+		temp := fs.Bool("temp", false, "Clear the temporary policy instead")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NArg() != 1 {
+			return usageError("usage: geoip del [-temp] CC|CONTINENT|REGION")
+		}
+		body := map[string]interface{}{"temporary": *temp}
+		body[geoipTarget(fs.Arg(0))] = fs.Arg(0)
+		if err := c.delete(policyPath, body, nil); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, body, func(w io.Writer) {
+			fmt.Fprintf(w, "%s policy removed\n", strings.ToUpper(fs.Arg(0)))
+		})
+
+	case "lookup":
+		if len(args) != 2 {
+			return usageError("usage: geoip lookup IP")
+		}
+		var l geoipLookup
+		if err := c.get("/api/v1/geoip/lookup?ip="+url.QueryEscape(args[1]), &l); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, l, func(w io.Writer) {
+			if l.Country == "" {
+				fmt.Fprintf(w, "%s: no country\n", l.IP)
+				return
+			}
+			fmt.Fprintf(w, "%s: %s, %s (%s)\n", l.IP, l.Country, l.Continent, l.Action)
+		})
+
+	case "stats":
+		var st geoipStats
+		if err := c.get("/api/v1/geoip/stats", &st); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, st, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "COUNTRY\tPACKETS\tDROPPED\tRATE LIMITED\tMONITORED")
+			for _, cs := range st.Countries {
+				fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n",
+					cs.Country, cs.Packets, cs.Dropped, cs.RateLimited, cs.Monitored)
+			}
+			tw.Flush()
+		})
+
+	case "audit":
+		var res geoipAudit
+		if err := c.get("/api/v1/geoip/audit", &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "TIME\tACTION\tDETAIL")
+			for _, e := range res.Entries {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Timestamp, e.Action, e.Detail)
+			}
+			tw.Flush()
+		})
+
+	default:
+		return usageError("unknown geoip action %q (must be status, load, cancel, list, set, del, lookup, stats, or audit)", action)
+	}
+}
+
+// geoipTarget returns the policy request field for a name: two letters
+// name a country unless they are a continent code; anything longer is a
+// continent region.
+func geoipTarget(name string) string {
+	switch strings.ToUpper(name) {
+	case "AF", "AN", "AS", "EU", "NA", "OC", "SA":
+		return "continent"
+	}
+	if len(name) == 2 {
+		return "country"
+	}
+	return "continent"
+}
+
+func cmdASN(c *client, format output.Format, args []string) error {
+	if len(args) == 0 {
+		return usageError("usage: asn list|set|del|lookup [args]")
+	}
+	const path = "/api/v1/asn/policies"
+
+	switch args[0] {
+	case "list":
+		var p asnPolicies
+		if err := c.get(path, &p); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, p, func(w io.Writer) {
+			fmt.Fprintf(w, "Prefixes loaded: %d\n", p.Prefixes)
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ASN\tACTION\tORGANIZATION")
+			for _, pol := range p.Policies {
+				fmt.Fprintf(tw, "AS%d\t%s\t%s\n", pol.ASN, pol.Action, pol.Organization)
+			}
+			tw.Flush()
+		})
+
+	case "set", "del":
+		want := 2
+		if args[0] == "set" {
+			want = 3
+		}
+		if len(args) != want {
+			return usageError("usage: asn set ASN pass|drop|rate_limit|monitor, or asn del ASN")
+		}
+		asn, err := parseASN(args[1])
+		if err != nil {
+			return err
+		}
+		res := asnPolicy{ASN: asn, Action: "pass"}
+		if args[0] == "set" {
+			res.Action = args[2]
+			err = c.post(path, map[string]interface{}{"asn": asn, "action": res.Action}, nil)
+		} else {
+			err = c.delete(path, map[string]interface{}{"asn": asn}, nil)
+		}
+		if err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			if args[0] == "set" {
+				fmt.Fprintf(w, "AS%d set to %s\n", res.ASN, res.Action)
+			} else {
+				fmt.Fprintf(w, "AS%d policy removed\n", res.ASN)
+			}
+		})
+
+	case "lookup":
+		if len(args) != 2 {
+			return usageError("usage: asn lookup IP")
+		}
+		var l asnLookup
+		if err := c.get("/api/v1/asn/lookup?ip="+url.QueryEscape(args[1]), &l); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, l, func(w io.Writer) {
+			if l.ASN == 0 {
+				fmt.Fprintf(w, "%s: no ASN\n", l.IP)
+				return
+			}
+			fmt.Fprintf(w, "%s: AS%d %s (%s)\n", l.IP, l.ASN, l.Organization, l.Action)
+		})
+
+	default:
+		return usageError("unknown asn action %q (must be list, set, del, or lookup)", args[0])
+	}
+}
+
+func cmdBGP(c *client, format output.Format, args []string) error {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	const path = "/api/v1/bgp/blackholes"
+
+	switch action {
+	case "status":
+		var st bgpStatus
+		if err := c.get("/api/v1/bgp", &st); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, st, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "PEER\tLOCAL AS\tPEER AS\tSTATE\tSINCE\tBLACKHOLES\tFLOWSPEC")
+			for _, p := range st.Peers {
+				state := "down"
+				if p.Connected {
+					state = "established"
+				}
+				fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%d\t%d\n",
+					p.RouterIP, p.LocalAS, p.PeerAS, state, p.Since, p.Blackholes, p.Flowspec)
+			}
+			tw.Flush()
+		})
+
+	case "blackholes":
+		var res bgpBlackholes
+		if err := c.get(path, &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "PREFIX\tANNOUNCED\tEXPIRES")
+			for _, b := range res.Blackholes {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", b.Prefix, b.AnnouncedAt, b.ExpiresAt)
+			}
+			tw.Flush()
+		})
+
+	case "announce":
+		fs := flag.NewFlagSet("bgp announce", flag.ContinueOnError)
+		ttl := fs.Duration("ttl", 0, "Withdraw after this long unless renewed (0 = configured default)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NArg() != 1 {
+			return usageError("usage: bgp announce [-ttl D] PREFIX")
+		}
+		body := map[string]interface{}{"prefix": fs.Arg(0), "ttlSec": uint64(ttl.Seconds())}
+		if err := c.post(path, body, nil); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, body, func(w io.Writer) {
+			fmt.Fprintf(w, "Blackhole announced for %s\n", fs.Arg(0))
+		})
+
+	case "withdraw":
+		if len(args) != 2 {
+			return usageError("usage: bgp withdraw PREFIX")
+		}
+		body := map[string]string{"prefix": args[1]}
+		if err := c.delete(path, body, nil); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, body, func(w io.Writer) {
+			fmt.Fprintf(w, "Blackhole withdrawn for %s\n", args[1])
+		})
+
+	case "audit":
+		fs := flag.NewFlagSet("bgp audit", flag.ContinueOnError)
+		offset := fs.Int("offset", 0, "Skip this many of the newest entries")
+		limit := fs.Int("limit", 50, "Maximum entries to show")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		q := url.Values{}
+		q.Set("offset", strconv.Itoa(*offset))
+		q.Set("limit", strconv.Itoa(*limit))
+
+		var res bgpAudit
+		if err := c.get("/api/v1/bgp/audit?"+q.Encode(), &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "TIME\tACTION\tDETAIL")
+			for _, e := range res.Entries {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", e.Timestamp, e.Action, e.Detail)
+			}
+			tw.Flush()
+			fmt.Fprintf(w, "%d-%d of %d entries\n",
+				min(res.Offset+1, res.Total), res.Offset+len(res.Entries), res.Total)
+		})
+
+	default:
+		return usageError("unknown bgp action %q (must be status, blackholes, announce, withdraw, or audit)", action)
+	}
+}
+
+func cmdSYNCookie(c *client, format output.Format, args []string) error {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	const destPath = "/api/v1/syncookie/destinations"
+
+	switch action {
+	case "status":
+		var st synCookieStatus
+		if err := c.get("/api/v1/syncookie", &st); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, st, func(w io.Writer) {
+			scope := "all destinations"
+			if st.Scoped {
+				scope = strings.Join(st.Destinations, ", ")
+			}
+			fmt.Fprintf(w, "Enabled:      %t (%s)\n", st.Enabled, scope)
+			fmt.Fprintf(w, "Seed age:     %s (rotated every %ds)\n",
+				(time.Duration(st.SeedAgeSec) * time.Second).String(), st.SeedRotationSec)
+			fmt.Fprintf(w, "Sent:         %d (%.0f/s)\n", st.Sent, st.SentPPS)
+			fmt.Fprintf(w, "Validated:    %d (%.0f/s)\n", st.Validated, st.ValidatedPPS)
+			fmt.Fprintf(w, "Failed:       %d (%.0f/s)\n", st.Failed, st.FailedPPS)
+			if st.SuccessPct != nil {
+				fmt.Fprintf(w, "Success rate: %.1f%%\n", *st.SuccessPct)
+			}
+		})
+
+	case "add", "del":
+		if len(args) != 2 {
+			return usageError("usage: syncookie add|del CIDR")
+		}
+		res := synCookieDest{Action: action, CIDR: args[1], OK: true}
+		var err error
+		if action == "add" {
+			err = c.post(destPath, map[string]string{"cidr": args[1]}, nil)
+		} else {
+			err = c.delete(destPath, map[string]string{"cidr": args[1]}, nil)
+		}
+		if err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			if action == "add" {
+				fmt.Fprintf(w, "SYN cookies now protect %s\n", res.CIDR)
+			} else {
+				fmt.Fprintf(w, "%s removed from SYN cookie destinations\n", res.CIDR)
+			}
+		})
+
+	default:
+		return usageError("unknown syncookie action %q (must be status, add, or del)", action)
+	}
+}
+
+func cmdTunnel(c *client, format output.Format, args []string) error {
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	const path = "/api/v1/tunnels"
+
+	switch action {
+	case "list":
+		var res tunnelList
+		if err := c.get(path, &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tPREFIX\tENDPOINT\tBACKUP\tACTIVE\tRTT\tLAST ERROR")
+			for _, t := range res.Tunnels {
+				active, rtt := t.Active, "-"
+				if active == "" {
+					active = "down"
+				}
+				if t.EndpointRTTMs != nil {
+					rtt = fmt.Sprintf("%.1fms", *t.EndpointRTTMs)
+				}
+				backup := t.Backup
+				if backup == "" {
+					backup = "-"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					t.Name, t.Prefix, t.Endpoint, backup, active, rtt, t.LastError)
+			}
+			tw.Flush()
+		})
+
+	case "add":
+		fs := flag.NewFlagSet("tunnel add", flag.ContinueOnError)
+		backup := fs.String("backup", "", "Fallback endpoint used while the primary is unreachable")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NArg() != 3 {
+			return usageError("usage: tunnel add [-backup IP] NAME PREFIX ENDPOINT")
+		}
+		body := map[string]string{
+			"name":     fs.Arg(0),
+			"prefix":   fs.Arg(1),
+			"endpoint": fs.Arg(2),
+			"backup":   *backup,
+		}
+		if err := c.post(path, body, nil); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, body, func(w io.Writer) {
+			fmt.Fprintf(w, "Tunnel %s added: %s via %s\n", fs.Arg(0), fs.Arg(1), fs.Arg(2))
+		})
+
+	case "del":
+		if len(args) != 2 {
+			return usageError("usage: tunnel del NAME")
+		}
+		body := map[string]string{"name": args[1]}
+		if err := c.delete(path, body, nil); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, body, func(w io.Writer) {
+			fmt.Fprintf(w, "Tunnel %s removed\n", args[1])
+		})
+
+	default:
+		return usageError("unknown tunnel action %q (must be list, add, or del)", action)
+	}
+}
+
+func cmdAsset(c *client, format output.Format, args []string) error {
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	const path = "/api/v1/assets"
+
+	switch action {
+	case "list":
+		var res assetList
+		if err := c.get(path, &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tPREFIX\tOWNER\tPROFILE\tSYN COOKIE\tSYN PPS\tUDP PPS\tICMP PPS\tAUTO RTBH\tGEO ALLOW")
+			rate := func(pps uint64) string {
+				if pps == 0 {
+					return "-"
+				}
+				return fmt.Sprint(pps)
+			}
+			for _, a := range res.Assets {
+				owner, profile := a.Owner, a.Profile
+				if owner == "" {
+					owner = "-"
+				}
+				if profile == "" {
+					profile = "low"
+				}
+				geoAllow := "-"
+				if len(a.GeoAllow) > 0 {
+					action := a.GeoAllowAction
+					if action == "" {
+						action = "drop"
+					}
+					geoAllow = strings.Join(a.GeoAllow, ",") + " (else " + action + ")"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\t%s\t%s\t%s\t%t\t%s\n",
+					a.Name, a.Prefix, owner, profile, a.SYNCookie,
+					rate(a.SYNRatePPS), rate(a.UDPRatePPS), rate(a.ICMPRatePPS), a.AutoRTBH, geoAllow)
+			}
+			tw.Flush()
+		})
+
+	case "stats":
+		var res assetStats
+		if err := c.get(path+"/stats", &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tPREFIX\tRX PACKETS\tRX BYTES\tDROPPED PACKETS\tDROPPED BYTES")
+			for _, a := range res.Assets {
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\n",
+					a.Name, a.Prefix, a.RxPackets, a.RxBytes, a.DroppedPackets, a.DroppedBytes)
+			}
+			tw.Flush()
+		})
+
+	case "add", "set":
+		fs := flag.NewFlagSet("asset "+action, flag.ContinueOnError)
+		owner := fs.String("owner", "", "Customer or team owning the prefix")
+		profile := fs.String("profile", "", "Minimum escalation level for the prefix: low, medium, high, or critical")
+		synCookie := fs.Bool("syn-cookie", false, "Answer SYNs to the prefix with SYN cookies")
+		synPPS := fs.Uint64("syn-pps", 0, "Per-source SYN rate limit towards the prefix (0 keeps the global limit)")
+		udpPPS := fs.Uint64("udp-pps", 0, "Per-source UDP rate limit towards the prefix (0 keeps the global limit)")
+		icmpPPS := fs.Uint64("icmp-pps", 0, "Per-source ICMP rate limit towards the prefix (0 keeps the global limit)")
+		autoRTBH := fs.Bool("auto-rtbh", false, "Allow blackholing the prefix over BGP at CRITICAL")
+		geoAllow := fs.String("geo-allow", "", "Comma-separated countries the prefix accepts traffic from (e.g. DE,AT); all others get -geo-allow-action")
+		geoAllowAction := fs.String("geo-allow-action", "", "Action for countries not on -geo-allow: drop (default) or rate_limit")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NArg() != 2 {
+			return usageError("usage: asset %s [flags] NAME PREFIX", action)
+		}
+		body := assetBody{
+			Name:        fs.Arg(0),
+			Prefix:      fs.Arg(1),
+			Owner:       *owner,
+			Profile:     *profile,
+			SYNCookie:   *synCookie,
+			SYNRatePPS:  *synPPS,
+			UDPRatePPS:  *udpPPS,
+			ICMPRatePPS: *icmpPPS,
+			AutoRTBH:    *autoRTBH,
+
+			GeoAllowAction: *geoAllowAction,
+		}
+		if *geoAllow != "" {
+			body.GeoAllow = strings.Split(*geoAllow, ",")
+		}
+		send, verb := c.post, "added"
+		if action == "set" {
+			send, verb = c.put, "updated"
+		}
+		if err := send(path, body, nil); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, body, func(w io.Writer) {
+			fmt.Fprintf(w, "Asset %s %s: %s\n", body.Name, verb, body.Prefix)
+		})
+
+	case "del":
+		if len(args) != 2 {
+			return usageError("usage: asset del NAME")
+		}
+		body := map[string]string{"name": args[1]}
+		if err := c.delete(path, body, nil); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, body, func(w io.Writer) {
+			fmt.Fprintf(w, "Asset %s removed\n", args[1])
+		})
+
+	default:
+		return usageError("unknown asset action %q (must be list, stats, add, set, or del)", action)
+	}
+}
+
+func cmdAttack(c *client, format output.Format, args []string) error {
+	action := "list"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	const path = "/api/v1/attacks"
+
+	switch action {
+	case "list":
+		fs := flag.NewFlagSet("attack list", flag.ContinueOnError)
+		limit := fs.Int("limit", 20, "Maximum number of attacks to list")
+		if len(args) > 0 {
+			args = args[1:]
+		}
+		if err := fs.Parse(args); err != nil {
+			return usageError("%v", err)
+		}
+		var res attackList
+		if err := c.get(fmt.Sprintf("%s?limit=%d", path, *limit), &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tSTART\tDURATION\tPEAK DROP PPS\tDROPPED\tESCALATION\tVECTORS")
+			for _, a := range res.Attacks {
+				duration := (time.Duration(a.DurationSec) * time.Second).String()
+				if a.End == nil {
+					duration += " (active)"
+				}
+				vectors := make([]string, 0, len(a.Vectors))
+				for v := range a.Vectors {
+					vectors = append(vectors, v)
+				}
+				sort.Strings(vectors)
+				escalation := a.PeakEscalation
+				if escalation == "" {
+					escalation = "-"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%.0f\t%d\t%s\t%s\n",
+					a.ID, a.Start.Local().Format(time.DateTime), duration, a.PeakDropPPS,
+					a.DroppedPackets, escalation, strings.Join(vectors, ","))
+			}
+			tw.Flush()
+		})
+
+	case "show":
+		if len(args) != 2 {
+			return usageError("usage: attack show ID")
+		}
+		if format == output.JSON {
+			var res map[string]interface{}
+			if err := c.get(path+"/"+url.PathEscape(args[1]), &res); err != nil {
+				return err
+			}
+			return output.Print(os.Stdout, format, res, nil)
+		}
+		return c.download(path+"/"+url.PathEscape(args[1])+"?format=markdown", os.Stdout)
+
+	case "report":
+		if len(args) != 3 {
+			return usageError("usage: attack report ID FILE")
+		}
+		reportFormat := "markdown"
+		if strings.HasSuffix(args[2], ".json") {
+			reportFormat = "json"
+		}
+		name := args[2]
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		if err := c.download(path+"/"+url.PathEscape(args[1])+"?format="+reportFormat, f); err != nil {
+			f.Close()
+			os.Remove(name)
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		res := map[string]string{"file": name}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			fmt.Fprintf(w, "Saved %s\n", name)
+		})
+
+	default:
+		return usageError("unknown attack action %q (must be list, show, or report)", action)
+	}
+}
+
+func cmdDiversion(c *client, format output.Format, args []string) error {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	const path = "/api/v1/diversion"
+
+	var st diversionStatus
+	switch action {
+	case "status":
+		if err := c.get(path, &st); err != nil {
+			return err
+		}
+
+	case "mode":
+		if len(args) != 2 {
+			return usageError("usage: diversion mode auto|on|off")
+		}
+		if err := c.put(path, map[string]string{"mode": args[1]}, &st); err != nil {
+			return err
+		}
+
+	default:
+		return usageError("unknown diversion action %q (must be status or mode)", action)
+	}
+
+	return output.Print(os.Stdout, format, st, func(w io.Writer) {
+		state := "inactive"
+		if st.Active {
+			state = "ACTIVE"
+		}
+		if st.Since != "" {
+			state += " since " + st.Since
+		}
+		fmt.Fprintf(w, "Diversion: %s (mode %s)\n", state, st.Mode)
+		fmt.Fprintf(w, "Level:     %s (starts at %s)\n", st.Level, st.StartLevel)
+		if st.HoldUntil != "" {
+			fmt.Fprintf(w, "Holding until %s\n", st.HoldUntil)
+		}
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PREFIX\tANNOUNCED\tTUNNEL\tENDPOINT\tERROR")
+		for _, p := range st.Prefixes {
+			tun, ep := p.Tunnel, p.Endpoint
+			if tun == "" {
+				tun = "-"
+			}
+			if ep == "" {
+				ep = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%t\t%s\t%s\t%s\n", p.Prefix, p.Announced, tun, ep, p.Error)
+		}
+		tw.Flush()
+	})
+}
+
+func cmdSchedule(c *client, format output.Format, args []string) error {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	const path = "/api/v1/schedule"
+
+	var st scheduleStatus
+	switch action {
+	case "status":
+		if err := c.get(path, &st); err != nil {
+			return err
+		}
+
+	case "override":
+		fs := flag.NewFlagSet("schedule override", flag.ContinueOnError)
+		dur := fs.Duration("for", 0, "How long to hold the profile (0 = until resumed)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NArg() != 1 {
+			return usageError("usage: schedule override [-for D] PROFILE")
+		}
+		body := map[string]interface{}{
+			"profile":     fs.Arg(0),
+			"durationSec": int64(dur.Seconds()),
+		}
+		if err := c.put(path+"/override", body, &st); err != nil {
+			return err
+		}
+
+	case "resume":
+		if err := c.delete(path+"/override", nil, &st); err != nil {
+			return err
+		}
+
+	default:
+		return usageError("unknown schedule action %q (must be status, override, or resume)", action)
+	}
+
+	return output.Print(os.Stdout, format, st, func(w io.Writer) {
+		active := st.Active
+		if active == "" {
+			active = "none"
+		}
+		if st.Source != "" {
+			active += " (" + st.Source + ")"
+		}
+		if !st.Since.IsZero() {
+			active += " since " + st.Since.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "Profile:  %s\n", active)
+		if !st.OverrideUntil.IsZero() {
+			fmt.Fprintf(w, "Override: until %s\n", st.OverrideUntil.Format(time.RFC3339))
+		} else if st.Source == "override" {
+			fmt.Fprintln(w, "Override: until resumed")
+		}
+		if st.Next != "" {
+			fmt.Fprintf(w, "Next:     %s at %s\n", st.Next, st.NextAt.Format(time.RFC3339))
+		}
+		fmt.Fprintf(w, "Timezone: %s\n", st.Timezone)
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CRON\tPROFILE")
+		for _, e := range st.Entries {
+			fmt.Fprintf(tw, "%s\t%s\n", e.Cron, e.Profile)
+		}
+		tw.Flush()
+	})
+}
+
+func cmdMaintenance(c *client, format output.Format, args []string) error {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	const path = "/api/v1/maintenance"
+
+	var st maintenanceStatus
+	switch action {
+	case "status":
+		if err := c.get(path, &st); err != nil {
+			return err
+		}
+
+	case "on":
+		fs := flag.NewFlagSet("maintenance on", flag.ContinueOnError)
+		mode := fs.String("mode", "", "bypass (pass-all) or detach (default from config)")
+		timeout := fs.Duration("timeout", 0, "Revert automatically after this long (default from config)")
+		force := fs.Bool("force", false, "Enter even while escalation is HIGH or CRITICAL")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NArg() == 0 {
+			return usageError("usage: maintenance on [-mode M] [-timeout D] [-force] REASON...")
+		}
+		body := map[string]interface{}{
+			"mode":       *mode,
+			"reason":     strings.Join(fs.Args(), " "),
+			"timeoutSec": int64(timeout.Seconds()),
+			"force":      *force,
+		}
+		if err := c.post(path, body, &st); err != nil {
+			return err
+		}
+
+	case "off":
+		if err := c.delete(path, nil, &st); err != nil {
+			return err
+		}
+
+	default:
+		return usageError("unknown maintenance action %q (must be status, on, or off)", action)
+	}
+
+	return output.Print(os.Stdout, format, st, func(w io.Writer) {
+		fmt.Fprintf(w, "Maintenance: %s\n", st.State)
+		if cur := st.Current; cur != nil {
+			fmt.Fprintf(w, "Mode:        %s\n", cur.Mode)
+			fmt.Fprintf(w, "Requested:   by %s at %s\n", cur.By, cur.Since.Format(time.RFC3339))
+			fmt.Fprintf(w, "Reason:      %s\n", cur.Reason)
+			fmt.Fprintf(w, "Reverts at:  %s\n", cur.Until.Format(time.RFC3339))
+			if cur.Forced {
+				fmt.Fprintf(w, "Forced at escalation level %s\n", cur.Level)
+			}
+		}
+		if len(st.History) == 0 {
+			return
+		}
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SINCE\tDURATION\tMODE\tBY\tENDED BY\tREASON")
+		for _, h := range st.History {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				h.Since.Format(time.RFC3339), h.Ended.Sub(h.Since).Round(time.Second),
+				h.Mode, h.By, h.EndedBy, h.Reason)
+		}
+		tw.Flush()
+	})
+}
+
+func cmdWatchdog(c *client, format output.Format, args []string) error {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	const path = "/api/v1/watchdog"
+
+	var st watchdogStatus
+	switch action {
+	case "status":
+		if err := c.get(path, &st); err != nil {
+			return err
+		}
+	case "check":
+		if err := c.post(path, nil, &st); err != nil {
+			return err
+		}
+	default:
+		return usageError("unknown watchdog action %q (must be status or check)", action)
+	}
+
+	return output.Print(os.Stdout, format, st, func(w io.Writer) {
+		if !st.Enabled {
+			fmt.Fprintln(w, "Watchdog: disabled")
+			return
+		}
+		health := "healthy"
+		switch {
+		case st.Paused:
+			health = "paused (maintenance)"
+		case !st.Healthy:
+			health = "UNHEALTHY"
+		}
+		repair := "reattach and restore"
+		if !st.Repair {
+			repair = "alert only"
+		}
+		fmt.Fprintf(w, "Watchdog:    %s, every %ds, %s\n", health, st.Interval, repair)
+		if !st.LastCheck.IsZero() {
+			fmt.Fprintf(w, "Last check:  %s (%d checks, %d repairs)\n",
+				st.LastCheck.Format(time.RFC3339), st.Checks, st.Repairs)
+		}
+		fmt.Fprintf(w, "Program:     %d on %s, interface runs %d\n",
+			st.Attach.ProgramID, st.Attach.Interface, st.Attach.InterfaceProgramID)
+		if len(st.Findings) == 0 {
+			return
+		}
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "TIME\tKIND\tREPAIRED\tDETAIL")
+		for _, f := range st.Findings {
+			detail := f.Detail
+			if f.Error != "" {
+				detail += " (repair failed: " + f.Error + ")"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%t\t%s\n", f.Time.Format(time.RFC3339), f.Kind, f.Repaired, detail)
+		}
+		tw.Flush()
+	})
+}
+
+func cmdFleet(c *client, format output.Format, args []string) error {
+	if len(args) > 1 || (len(args) == 1 && args[0] != "status") {
+		return usageError("usage: fleet [status]")
+	}
+
+	var st fleetStatus
+	if err := c.get("/api/v1/fleet", &st); err != nil {
+		return err
+	}
+	return output.Print(os.Stdout, format, st, func(w io.Writer) {
+		if st.Enabled != nil && !*st.Enabled {
+			fmt.Fprintln(w, "Fleet: disabled")
+			return
+		}
+		role := "follower"
+		if st.IsLeader {
+			role = "LEADER since " + st.LeaderSince.Format(time.DateTime)
+		}
+		fmt.Fprintf(w, "Node:     %s (priority %d), %s\n", st.Node, st.Priority, role)
+		leader := st.Leader
+		if leader == "" {
+			leader = "none"
+		}
+		fmt.Fprintf(w, "Leader:   %s\n", leader)
+		quorum := "not required"
+		if st.Quorum > 0 {
+			quorum = fmt.Sprintf("%d needed", st.Quorum)
+		}
+		fmt.Fprintf(w, "Live:     %d of %d (quorum %s)\n", st.Live, st.Size, quorum)
+		if !st.LastMirror.IsZero() {
+			mirror := "ok"
+			if st.MirrorError != "" {
+				mirror = st.MirrorError
+			}
+			fmt.Fprintf(w, "Mirrored: %s (%s)\n", st.LastMirror.Format(time.DateTime), mirror)
+		}
+		if rs := st.Reputation; rs != nil {
+			received := 0
+			for _, n := range rs.Received {
+				received += n
+			}
+			fmt.Fprintf(w, "Shared:   %d sources sent, %d received from %d members\n",
+				rs.Published, received, len(rs.Received))
+			if rs.PublishError != "" {
+				fmt.Fprintf(w, "          last error: %s\n", rs.PublishError)
+			}
+		}
+
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NODE\tADDRESS\tPRIORITY\tLEADER\tLIVE\tLAST CONTACT\tERROR")
+		for _, m := range st.Members {
+			node, contact := m.Node, "never"
+			if node == "" {
+				node = "-"
+			}
+			if !m.LastContact.IsZero() {
+				contact = m.LastContact.Format(time.DateTime)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%t\t%t\t%s\t%s\n",
+				node, m.Addr, m.Priority, m.Leader, m.Live, contact, m.LastError)
+		}
+		tw.Flush()
+	})
+}
+
+func cmdConnLimit(c *client, format output.Format, args []string) error {
+	fs := flag.NewFlagSet("connlimit", flag.ContinueOnError)
+	limit := fs.Int("limit", 20, "Number of sources to show")
+	if err := fs.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+
+	var st connLimitStatus
+	if err := c.get(fmt.Sprintf("/api/v1/connlimit?limit=%d", *limit), &st); err != nil {
+		return err
+	}
+	return output.Print(os.Stdout, format, st, func(w io.Writer) {
+		perSource := "off"
+		if st.PerSource > 0 {
+			perSource = strconv.FormatUint(uint64(st.PerSource), 10)
+		}
+		fmt.Fprintf(w, "Per-source limit: %s  Dropped: %d\n", perSource, st.Dropped)
+		if len(st.Prefixes) > 0 {
+			fmt.Fprintln(w)
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "PREFIX\tLIMIT")
+			for _, p := range st.Prefixes {
+				fmt.Fprintf(tw, "%s\t%d\n", p.Prefix, p.Limit)
+			}
+			tw.Flush()
+		}
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SOURCE\tCONNS")
+		for _, src := range st.Sources {
+			fmt.Fprintf(tw, "%s\t%d\n", src.IP, src.Conns)
+		}
+		tw.Flush()
+	})
+}
+
+func cmdEgress(c *client, format output.Format, args []string) error {
+	const path = "/api/v1/egress"
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	var st egressStatus
+	if err := c.get(path, &st); err != nil {
+		return err
+	}
+
+	switch action {
+	case "status":
+		if len(args) > 1 {
+			return usageError("usage: egress [status]")
+		}
+		return output.Print(os.Stdout, format, st, func(w io.Writer) {
+			if !st.Enabled {
+				fmt.Fprintln(w, "Egress scrubbing: disabled")
+				return
+			}
+			onOff := func(b bool) string {
+				if b {
+					return "on"
+				}
+				return "off"
+			}
+			fmt.Fprintf(w, "Attached:      %s (%s)\n", st.Interface, st.Attachment)
+			fmt.Fprintf(w, "Anti-spoofing: %s  Local prefixes: %s\n",
+				onOff(st.BlockSpoofed), strings.Join(st.LocalPrefixes, ","))
+			fmt.Fprintf(w, "Amplification: %s\n", onOff(st.BlockAmplification))
+			fmt.Fprintf(w, "Packets:       %d (%d bytes)  Dropped: %d spoofed, %d amplification (%d bytes)\n",
+				st.Stats.Packets, st.Stats.Bytes, st.Stats.SpoofedDropped, st.Stats.AmpDropped, st.Stats.DroppedBytes)
+			if len(st.AmpPorts) > 0 {
+				ports := make([]int, 0, len(st.AmpPorts))
+				for port := range st.AmpPorts {
+					ports = append(ports, int(port))
+				}
+				sort.Ints(ports)
+				fmt.Fprintln(w)
+				tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(tw, "UDP SOURCE PORT\tPPS PER DESTINATION")
+				for _, port := range ports {
+					limit := "drop"
+					if pps := st.AmpPorts[uint16(port)]; pps > 0 {
+						limit = strconv.FormatUint(pps, 10)
+					}
+					fmt.Fprintf(tw, "%d\t%s\n", port, limit)
+				}
+				tw.Flush()
+			}
+		})
+
+	case "set":
+		if !st.Enabled {
+			return fmt.Errorf("egress scrubbing is not enabled")
+		}
+		fs := flag.NewFlagSet("egress set", flag.ContinueOnError)
+		spoof := fs.String("spoof", "", "Drop sources outside the local prefixes: on or off")
+		prefixes := fs.String("prefixes", "", "Comma-separated local prefixes, replacing the current ones")
+		amp := fs.String("amp", "", "Limit amplification-prone UDP responses: on or off")
+		ports := fs.String("ports", "", "Comma-separated PORT=PPS limits per destination, replacing the current ones (0 = drop)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NArg() != 0 || fs.NFlag() == 0 {
+			return usageError("usage: egress set [-spoof on|off] [-prefixes CIDR,...] [-amp on|off] [-ports PORT=PPS,...]")
+		}
+
+		policy := st.egressPolicy
+		var err error
+		fs.Visit(func(f *flag.Flag) {
+			if err != nil {
+				return
+			}
+			switch f.Name {
+			case "spoof":
+				policy.BlockSpoofed, err = parseOnOff("spoof", *spoof)
+			case "amp":
+				policy.BlockAmplification, err = parseOnOff("amp", *amp)
+			case "prefixes":
+				policy.LocalPrefixes = nil
+				for _, p := range strings.Split(*prefixes, ",") {
+					if p = strings.TrimSpace(p); p != "" {
+						policy.LocalPrefixes = append(policy.LocalPrefixes, p)
+					}
+				}
+			case "ports":
+				policy.AmpPorts = make(map[uint16]uint64)
+				for _, pl := range strings.Split(*ports, ",") {
+					if pl = strings.TrimSpace(pl); pl == "" {
+						continue
+					}
+					port, pps, ok := strings.Cut(pl, "=")
+					p, perr := strconv.ParseUint(port, 10, 16)
+					n, nerr := strconv.ParseUint(pps, 10, 64)
+					if !ok || perr != nil || nerr != nil {
+						err = usageError("invalid port limit %q (want PORT=PPS)", pl)
+						return
+					}
+					policy.AmpPorts[uint16(p)] = n
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if err := c.put(path, policy, nil); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, policy, func(w io.Writer) {
+			fmt.Fprintln(w, "Egress policy updated")
+		})
+
+	default:
+		return usageError("unknown egress action %q (must be status or set)", action)
+	}
+}
+
+func cmdInspect(c *client, format output.Format, args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	limit := fs.Int("limit", 20, "Number of recent blocks to show")
+	if err := fs.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+
+	var st inspectStatus
+	if err := c.get("/api/v1/inspect", &st); err != nil {
+		return err
+	}
+	if len(st.Recent) > *limit {
+		st.Recent = st.Recent[:*limit]
+	}
+	return output.Print(os.Stdout, format, st, func(w io.Writer) {
+		if !st.Enabled {
+			fmt.Fprintln(w, "Deep inspection: disabled")
+			return
+		}
+		fmt.Fprintf(w, "Interface: %s (%d sockets)  Redirected: %d  No socket: %d\n",
+			st.Interface, st.Sockets, st.Redirected, st.NoSocket)
+		fmt.Fprintf(w, "Inspected: %d  Cleared: %d  Malformed: %d\n", st.Inspected, st.Cleared, st.Malformed)
+
+		if len(st.Blocked) > 0 {
+			reasons := make([]string, 0, len(st.Blocked))
+			for r := range st.Blocked {
+				reasons = append(reasons, r)
+			}
+			sort.Strings(reasons)
+			fmt.Fprintln(w)
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "REASON\tBLOCKED")
+			for _, r := range reasons {
+				fmt.Fprintf(tw, "%s\t%d\n", r, st.Blocked[r])
+			}
+			tw.Flush()
+		}
+		if len(st.Recent) > 0 {
+			fmt.Fprintln(w)
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "TIME\tSOURCE\tREASON\tDETAIL")
+			for _, v := range st.Recent {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", v.Time.Format(time.TimeOnly), v.Source, v.Reason, v.Detail)
+			}
+			tw.Flush()
+		}
+		if len(st.DNSZones) > 0 {
+			fmt.Fprintln(w)
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ZONE\tQUERIES\tUNIQUE\tENTROPY\tRANDOM SUBDOMAIN")
+			for _, z := range st.DNSZones {
+				fmt.Fprintf(tw, "%s\t%d\t%.0f%%\t%.2f\t%t\n", z.Domain, z.Queries, z.UniqueRatio*100, z.MeanEntropy, z.RandomSubdomain)
+			}
+			tw.Flush()
+		}
+		if len(st.DNSSignatures) > 0 {
+			fmt.Fprintln(w)
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ATTACKED ZONE\tRECOMMENDED QPS")
+			for _, sig := range st.DNSSignatures {
+				fmt.Fprintf(tw, "%s\t%d\n", sig.Domain, sig.MaxQPS)
+			}
+			tw.Flush()
+		}
+	})
+}
+
+func cmdBaseline(c *client, format output.Format, args []string) error {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	switch action {
+	case "status":
+	case "reset":
+		var res map[string]bool
+		if err := c.post("/api/v1/baseline/reset", nil, &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			fmt.Fprintln(w, "Baseline reset; learning restarted")
+		})
+	default:
+		return usageError("unknown baseline action %q (must be status or reset)", action)
+	}
+
+	var st baselineStatus
+	if err := c.get("/api/v1/baseline", &st); err != nil {
+		return err
+	}
+	return output.Print(os.Stdout, format, st, func(w io.Writer) {
+		m := st.Metrics
+		state := "operational"
+		if !st.Operational {
+			state = "learning"
+		}
+		fmt.Fprintf(w, "Baseline:  %s (%d samples)\n", state, st.Samples)
+		fmt.Fprintf(w, "PPS:       %.0f (baseline %.0f ± %.0f, z %.2f)\n", m.CurrentPPS, m.BaselinePPS, m.StdDevPPS, m.ZScorePPS)
+		fmt.Fprintf(w, "BPS:       %.0f (baseline %.0f, z %.2f)\n", m.CurrentBPS, m.BaselineBPS, m.ZScoreBPS)
+		seasonal := "learning"
+		if st.Seasonal.Operational {
+			seasonal = fmt.Sprintf("%.0f ± %.0f pps", st.Seasonal.BaselinePPS, st.Seasonal.StdDevPPS)
+		}
+		fmt.Fprintf(w, "Seasonal:  %s, %s (%d samples)\n", st.Seasonal.Name, seasonal, st.Seasonal.Samples)
+		fmt.Fprintf(w, "Anomaly:   %t (score %.2f)\n", m.Anomaly, m.AnomalyScore)
+		if st.Pulse.Recognized {
+			fmt.Fprintf(w, "Pulse:     every %.0fs, bursts of %.0fs at %.0f pps (in burst: %t)\n",
+				st.Pulse.IntervalSec, st.Pulse.MeanDurationSec, st.Pulse.MeanPeakPPS, st.Pulse.InBurst)
+		}
+		r := st.AdaptiveRates
+		fmt.Fprintf(w, "Adaptive:  SYN %d  UDP %d  ICMP %d  DNS %d  global %d pps\n",
+			r.SynPPS, r.UDPPPS, r.ICMPPPS, r.DNSPPS, r.GlobalPPS)
+
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PROTOCOL\tCURRENT PPS\tBASELINE PPS\tSTDDEV\tSAMPLES\tOPERATIONAL")
+		for _, p := range st.Protocols {
+			fmt.Fprintf(tw, "%s\t%.0f\t%.0f\t%.0f\t%d\t%t\n", p.Protocol, p.CurrentPPS, p.BaselinePPS, p.StdDevPPS, p.Samples, p.Operational)
+		}
+		tw.Flush()
+	})
+}
+
+func cmdHTTPFlood(c *client, format output.Format) error {
+	var st httpFloodStatus
+	if err := c.get("/api/v1/httpflood", &st); err != nil {
+		return err
+	}
+	return output.Print(os.Stdout, format, st, func(w io.Writer) {
+		if !st.Enabled {
+			fmt.Fprintln(w, "HTTP flood detection: disabled")
+			return
+		}
+		indicator := "clear"
+		if st.Raised {
+			indicator = "raised"
+			if st.Escalate != "" {
+				indicator += " (escalating to " + strings.ToUpper(st.Escalate) + ")"
+			}
+		}
+		lastScan := "never"
+		if !st.LastScan.IsZero() {
+			lastScan = st.LastScan.Format(time.TimeOnly)
+		}
+		fmt.Fprintf(w, "Indicator: %s  Last scan: %s  Connections: %d\n", indicator, lastScan, st.Flows)
+		if len(st.Targets) == 0 {
+			return
+		}
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "DESTINATION\tNEW CONN/S\tRESPONSE RATIO\tFLOODED\tSINCE\tSTRICT TCP UNTIL")
+		for _, t := range st.Targets {
+			strict := "-"
+			if !t.StrictUntil.IsZero() {
+				strict = t.StrictUntil.Format(time.TimeOnly)
+			}
+			fmt.Fprintf(tw, "%s\t%.0f\t%.1f\t%t\t%s\t%s\n", t.IP, t.NewConnsPerSec, t.ResponseRatio,
+				t.Flooded, t.Since.Format(time.TimeOnly), strict)
+		}
+		tw.Flush()
+	})
+}
+
+func parseOnOff(name, v string) (bool, error) {
+	switch v {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return false, usageError("-%s must be on or off", name)
+}
+
+func cmdScanners(c *client, format output.Format, args []string) error {
+	fs := flag.NewFlagSet("scanners", flag.ContinueOnError)
+	since := fs.Duration("since", 5*time.Minute, "Show scanners flagged within this long")
+	limit := fs.Int("limit", 100, "Number of scanners to show")
+	if err := fs.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+
+	var list scannerList
+	path := fmt.Sprintf("/api/v1/scanners?since=%s&limit=%d", *since, *limit)
+	if err := c.get(path, &list); err != nil {
+		return err
+	}
+	return output.Print(os.Stdout, format, list, func(w io.Writer) {
+		response := list.Action
+		if list.BlockSec > 0 {
+			response += fmt.Sprintf(" (%ds)", list.BlockSec)
+		}
+		fmt.Fprintf(w, "Response: %s  Threshold: %d ports in %ds  Detections: %d\n",
+			response, list.Threshold, list.WindowSec, list.Detected)
+		fmt.Fprintf(w, "Scanners in the last %s: %d\n\n", list.Since, list.Total)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SOURCE\tPORTS\tPEAK\tDETECTED\tBLOCKED UNTIL")
+		for _, sc := range list.Scanners {
+			blocked := sc.BlockedUntil
+			if blocked == "" {
+				blocked = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", sc.IP, sc.DistinctPorts, sc.PeakPorts, sc.Detected, blocked)
+		}
+		tw.Flush()
+	})
+}
+
+func cmdHandshakes(c *client, format output.Format, args []string) error {
+	fs := flag.NewFlagSet("handshakes", flag.ContinueOnError)
+	since := fs.Duration("since", 5*time.Minute, "Show sources with drops within this long")
+	limit := fs.Int("limit", 100, "Number of sources to show")
+	if err := fs.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+
+	var list handshakeList
+	path := fmt.Sprintf("/api/v1/handshakes?since=%s&limit=%d", *since, *limit)
+	if err := c.get(path, &list); err != nil {
+		return err
+	}
+	rate := func(pps uint64) string {
+		if pps == 0 {
+			return "no limit"
+		}
+		return fmt.Sprintf("%d/s", pps)
+	}
+	return output.Print(os.Stdout, format, list, func(w io.Writer) {
+		fmt.Fprintf(w, "TLS:  %s per source, %d ClientHellos, %d dropped\n",
+			rate(list.TLSRatePPS), list.TLSHandshakes, list.TLSDropped)
+		fmt.Fprintf(w, "QUIC: %s per source, %d Initials, %d dropped\n",
+			rate(list.QUICRatePPS), list.QUICInitials, list.QUICDropped)
+		fmt.Fprintf(w, "Sources dropped in the last %s: %d\n\n", list.Since, list.Total)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SOURCE\tKIND\tRATE\tPEAK\tDROPPED\tLAST DROP")
+		for _, src := range list.Sources {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\n", src.IP, src.Kind, src.Rate, src.Peak, src.Dropped, src.LastDrop)
+		}
+		tw.Flush()
+	})
+}
+
+func cmdRateLimit(c *client, format output.Format, args []string) error {
+	if len(args) > 0 && (args[0] == "classes" || args[0] == "class") {
+		return cmdRateClass(c, format, args)
+	}
+	if len(args) > 0 && (args[0] == "show" || args[0] == "reset") {
+		if len(args) != 2 {
+			return usageError("usage: ratelimit %s IP", args[0])
+		}
+		path := "/api/v1/ratelimit/sources/" + url.PathEscape(args[1])
+		if args[0] == "reset" {
+			if err := c.delete(path, nil, nil); err != nil {
+				return err
+			}
+			res := map[string]interface{}{"ip": args[1], "ok": true}
+			return output.Print(os.Stdout, format, res, func(w io.Writer) {
+				fmt.Fprintf(w, "Reset rate limiter of %s\n", args[1])
+			})
+		}
+
+		var sr sourceRate
+		if err := c.get(path, &sr); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, sr, func(w io.Writer) {
+			fmt.Fprintf(w, "Source:      %s\n", sr.IP)
+			fmt.Fprintf(w, "Rate:        %d pps (burst %d)\n", sr.RatePPS, sr.Burst)
+			fmt.Fprintf(w, "Tokens:      %d over %d CPUs\n", sr.Tokens, sr.CPUs)
+			fmt.Fprintf(w, "Packets:     %d\n", sr.Packets)
+			fmt.Fprintf(w, "Dropped:     %d\n", sr.DroppedPackets)
+			fmt.Fprintf(w, "Last refill: %s\n", sr.LastRefill)
+		})
+	}
+
+	fs := flag.NewFlagSet("ratelimit", flag.ContinueOnError)
+	all := fs.Bool("all", false, "Also show sources within their limit")
+	limit := fs.Int("limit", 100, "Number of sources to show")
+	if err := fs.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+
+	var list rateLimitList
+	path := fmt.Sprintf("/api/v1/ratelimit/sources?limit=%d&all=%t", *limit, *all)
+	if err := c.get(path, &list); err != nil {
+		return err
+	}
+	return output.Print(os.Stdout, format, list, func(w io.Writer) {
+		fmt.Fprintf(w, "Tracked sources: %d  Rate limited: %d\n\n", list.Tracked, list.Limited)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SOURCE\tRATE\tBURST\tTOKENS\tPACKETS\tDROPPED\tLAST REFILL")
+		for _, sr := range list.Sources {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%s\n",
+				sr.IP, sr.RatePPS, sr.Burst, sr.Tokens, sr.Packets, sr.DroppedPackets, sr.LastRefill)
+		}
+		tw.Flush()
+	})
+}
+
+// cmdRateClass handles "ratelimit classes" and "ratelimit class
+// add|set|del".
+func cmdRateClass(c *client, format output.Format, args []string) error {
+	const path = "/api/v1/ratelimit/classes"
+	if args[0] == "classes" {
+		var res rateClassList
+		if err := c.get(path, &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tSYN PPS\tUDP PPS\tICMP PPS\tPPS\tBPS\tSOURCES\tDESTINATIONS\tPACKETS\tDROPPED")
+			value := func(v uint64) string {
+				if v == 0 {
+					return "-"
+				}
+				return fmt.Sprint(v)
+			}
+			list := func(prefixes []string) string {
+				if len(prefixes) == 0 {
+					return "-"
+				}
+				return strings.Join(prefixes, ",")
+			}
+			for _, rc := range res.Classes {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\n",
+					rc.Name, value(rc.SYNRatePPS), value(rc.UDPRatePPS), value(rc.ICMPRatePPS),
+					value(rc.PPS), value(rc.BPS), list(rc.Sources), list(rc.Destinations),
+					rc.Packets, rc.DroppedPackets)
+			}
+			tw.Flush()
+		})
+	}
+
+	action := ""
+	if len(args) > 1 {
+		action = args[1]
+	}
+	switch action {
+	case "add", "set":
+		fs := flag.NewFlagSet("ratelimit class "+action, flag.ContinueOnError)
+		synPPS := fs.Uint64("syn-pps", 0, "Per-source SYN rate limit (0 keeps the global limit)")
+		udpPPS := fs.Uint64("udp-pps", 0, "Per-source UDP rate limit (0 keeps the global limit)")
+		icmpPPS := fs.Uint64("icmp-pps", 0, "Per-source ICMP rate limit (0 keeps the global limit)")
+		pps := fs.Uint64("pps", 0, "Packet rate limit of all traffic in the class (0 = none)")
+		bps := fs.Uint64("bps", 0, "Bit rate limit of all traffic in the class (0 = none)")
+		src := fs.String("src", "", "Comma-separated source prefixes bound to the class")
+		dst := fs.String("dst", "", "Comma-separated destination prefixes bound to the class")
+		if err := fs.Parse(args[2:]); err != nil {
+			return usageError("%v", err)
+		}
+		if fs.NArg() != 1 {
+			return usageError("usage: ratelimit class %s [flags] NAME", action)
+		}
+		split := func(s string) []string {
+			var out []string
+			for _, p := range strings.Split(s, ",") {
+				if p = strings.TrimSpace(p); p != "" {
+					out = append(out, p)
+				}
+			}
+			return out
+		}
+		body := rateClassBody{
+			Name:         fs.Arg(0),
+			SYNRatePPS:   *synPPS,
+			UDPRatePPS:   *udpPPS,
+			ICMPRatePPS:  *icmpPPS,
+			PPS:          *pps,
+			BPS:          *bps,
+			Sources:      split(*src),
+			Destinations: split(*dst),
+		}
+		send, verb := c.post, "added"
+		if action == "set" {
+			send, verb = c.put, "updated"
+		}
+		if err := send(path, body, nil); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, body, func(w io.Writer) {
+			fmt.Fprintf(w, "Rate class %s %s\n", body.Name, verb)
+		})
+
+	case "del":
+		if len(args) != 3 {
+			return usageError("usage: ratelimit class del NAME")
+		}
+		body := map[string]string{"name": args[2]}
+		if err := c.delete(path, body, nil); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, body, func(w io.Writer) {
+			fmt.Fprintf(w, "Rate class %s removed\n", args[2])
+		})
+
+	default:
+		return usageError("unknown rate class action %q (must be add, set, or del)", action)
+	}
+}
+
+func cmdBPF(c *client, format output.Format, args []string) error {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "status":
+		var p bpfProgram
+		if err := c.get("/api/v1/bpf", &p); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, p, func(w io.Writer) {
+			fmt.Fprintf(w, "Program:        %s (id %d, tag %s)\n", p.Name, p.ID, p.Tag)
+			fmt.Fprintf(w, "Object:         %s\n", p.Object)
+			if p.VerifiedInsns > 0 {
+				fmt.Fprintf(w, "Verified insns: %d\n", p.VerifiedInsns)
+			}
+			fmt.Fprintf(w, "Memory:         %d bytes\n", p.MemlockBytes)
+			if !p.StatsEnabled {
+				fmt.Fprintln(w, "Run stats:      disabled (sysctl kernel.bpf_stats_enabled=1)")
+				return
+			}
+			avg := 0.0
+			if p.RunCount > 0 {
+				avg = float64(p.RunTimeNs) / float64(p.RunCount)
+			}
+			fmt.Fprintf(w, "Runs:           %d (avg %.0f ns)\n", p.RunCount, avg)
+		})
+
+	case "maps":
+		var res bpfMaps
+		if err := c.get("/api/v1/bpf/maps", &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "MAP\tTYPE\tENTRIES\tMAX\tUSED\tMEMORY")
+			for _, m := range res.Maps {
+				entries, used := "-", "-"
+				if m.Entries >= 0 {
+					entries = strconv.Itoa(m.Entries)
+					if m.MaxEntries > 0 {
+						used = fmt.Sprintf("%.1f%%", 100*float64(m.Entries)/float64(m.MaxEntries))
+					}
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%d\n",
+					m.Name, m.Type, entries, m.MaxEntries, used, m.MemlockBytes)
+			}
+			tw.Flush()
+			fmt.Fprintf(w, "Total memory: %d bytes\n", res.TotalMemlockBytes)
+		})
+
+	case "diagnostics":
+		var d bpfDiagnostics
+		if err := c.get("/api/v1/bpf/diagnostics", &d); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, d, func(w io.Writer) {
+			fmt.Fprintf(w, "Kernel: %s (%s)\n", d.Kernel, d.Arch)
+			fmt.Fprintf(w, "Object: %s\n", d.Object)
+			if len(d.MissingFeatures) == 0 {
+				fmt.Fprintln(w, "All probed kernel features are available.")
+			}
+			for _, ft := range d.MissingFeatures {
+				fmt.Fprintf(w, "  %-12s %s — %s\n", "["+ft.Status+"]", ft.Name, ft.Detail)
+			}
+			le := d.LastLoadError
+			if le == nil {
+				fmt.Fprintln(w, "\nNo failed program loads.")
+				return
+			}
+			fmt.Fprintf(w, "\nLast failed load: %s at %s\n  %s\n", le.Object, le.Time, le.Error)
+			if len(le.VerifierLog) > 0 {
+				fmt.Fprintln(w, "\nVerifier log:")
+				if le.LogTruncated {
+					fmt.Fprintf(w, "  ... (last %d of %d lines)\n", len(le.VerifierLog), le.LogLines)
+				}
+				for _, line := range le.VerifierLog {
+					fmt.Fprintf(w, "  %s\n", line)
+				}
+			}
+		})
+
+	case "replace":
+		if len(args) != 2 {
+			return usageError("usage: bpf replace OBJECT")
+		}
+		var res bpfReplace
+		if err := c.post("/api/v1/bpf/replace", map[string]string{"object": args[1]}, &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			fmt.Fprintf(w, "XDP program replaced: %s (was %s)", res.Object, res.Previous)
+			if res.Tag != "" {
+				fmt.Fprintf(w, ", tag %s", res.Tag)
+			}
+			fmt.Fprintln(w)
+		})
+
+	default:
+		return usageError("unknown bpf action %q (must be status, maps, diagnostics, or replace)", action)
+	}
+}
+
+func cmdAuth(c *client, format output.Format, args []string) error {
+	action := "whoami"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "whoami":
+		var res authWhoami
+		if err := c.get("/api/v1/auth/whoami", &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			if !res.AuthEnabled {
+				fmt.Fprintln(w, "API auth is disabled")
+				return
+			}
+			fmt.Fprintf(w, "%s (%s): %s\n", res.Identity, res.Source, res.Role)
+			if res.Tenant != "" {
+				fmt.Fprintf(w, "Tenant: %s\n", res.Tenant)
+			}
+		})
+
+	case "tenants":
+		var res tenantList
+		if err := c.get("/api/v1/tenants", &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "TENANT\tPREFIXES\tKEYS\tCLIENTS\tDESCRIPTION")
+			list := func(items []string) string {
+				if len(items) == 0 {
+					return "-"
+				}
+				return strings.Join(items, ",")
+			}
+			for _, t := range res.Tenants {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+					t.Name, list(t.Prefixes), list(t.Keys), list(t.Clients), t.Description)
+			}
+			tw.Flush()
+		})
+
+	case "audit":
+		var res authAudit
+		if err := c.get("/api/v1/auth/audit", &res); err != nil {
+			return err
+		}
+		return output.Print(os.Stdout, format, res, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "TIME\tIDENTITY\tROLE\tREQUEST\tREMOTE\tREASON")
+			for _, e := range res.Entries {
+				who := e.Identity
+				if who == "" {
+					who = "-"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s %s\t%s\t%s\n",
+					e.Timestamp, who, e.Role, e.Method, e.Path, e.Remote, e.Reason)
+			}
+			tw.Flush()
+		})
+
+	default:
+		return usageError("unknown auth action %q (must be whoami, audit, or tenants)", action)
+	}
+}
+
+func cmdDebug(c *client, format output.Format, args []string) error {
+	action := "runtime"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	if action != "runtime" {
+		return usageError("unknown debug action %q (must be runtime)", action)
+	}
+
+	var rt debugRuntime
+	if err := c.get("/api/v1/debug/runtime", &rt); err != nil {
+		return err
+	}
+	return output.Print(os.Stdout, format, rt, func(w io.Writer) {
+		fmt.Fprintf(w, "Go:          %s, %d goroutines, GOMAXPROCS %d of %d CPUs\n",
+			rt.GoVersion, rt.Goroutines, rt.GOMAXPROCS, rt.NumCPU)
+		fmt.Fprintf(w, "Uptime:      %v\n", time.Duration(rt.UptimeSec)*time.Second)
+		fmt.Fprintf(w, "Heap:        %d bytes in use, %d objects, %d bytes from the OS\n",
+			rt.Heap.InuseBytes, rt.Heap.Objects, rt.Heap.SysBytes)
+		fmt.Fprintf(w, "GC:          %d runs, last pause %.3f ms, %.2f%% of CPU\n",
+			rt.GC.Count, rt.GC.LastPauseMs, rt.GC.CPUFraction*100)
+		pprof := "disabled (api.pprof)"
+		if rt.Pprof {
+			pprof = "/api/v1/debug/pprof/"
+		}
+		fmt.Fprintf(w, "Profiles:    %s\n", pprof)
+		if p := rt.EventPipeline; p != nil {
+			fmt.Fprintf(w, "Events:      %d read, %d sampled out, %d aggregated, %d dispatched\n",
+				p.Read, p.SampledOut, p.Aggregated, p.Dispatched)
+		}
+		if rb := rt.RingBuffer; rb != nil {
+			fmt.Fprintf(w, "Ring buffer: %d of %d bytes unread (peak %d), %d events lost",
+				rb.BacklogBytes, rb.SizeBytes, rb.PeakBacklogBytes, rb.Lost)
+			if rb.LastLoss != "" {
+				fmt.Fprintf(w, ", last at %s", rb.LastLoss)
+			}
+			fmt.Fprintln(w)
+		}
+
+		if len(rt.MapIterations) > 0 {
+			fmt.Fprintln(w)
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "MAP\tWALKS\tLAST\tMEAN\tMAX")
+			for _, m := range rt.MapIterations {
+				fmt.Fprintf(tw, "%s\t%d\t%.1fms\t%.1fms\t%.1fms\n", m.Map, m.Count, m.LastMs, m.MeanMs, m.MaxMs)
+			}
+			tw.Flush()
+		}
+		if len(rt.FeedSyncs) > 0 {
+			fmt.Fprintln(w)
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "FEED\tLAST SYNC\tDURATION\tENTRIES\tERROR")
+			for _, f := range rt.FeedSyncs {
+				fmt.Fprintf(tw, "%s\t%s\t%.0fms\t%d\t%s\n", f.Feed, f.LastSync, f.DurationMs, f.Entries, f.Error)
+			}
+			tw.Flush()
+		}
+	})
+}
+
+// parseASN accepts "64496" or "AS64496".
+func parseASN(s string) (uint32, error) {
+	digits := s
+	if len(s) > 2 && strings.EqualFold(s[:2], "AS") {
+		digits = s[2:]
+	}
+	n, err := strconv.ParseUint(digits, 10, 32)
+	if err != nil || n == 0 {
+		return 0, usageError("invalid ASN %q", s)
+	}
+	return uint32(n), nil
+}
+
+// portRange renders a min/max range, "*" when unrestricted.
+func portRange(lo, hi uint16) string {
+	switch {
+	case lo == 0 && hi == 0:
+		return "*"
+	case lo == hi:
+		return strconv.Itoa(int(lo))
+	default:
+		return fmt.Sprintf("%d-%d", lo, hi)
+	}
+}
+
+// num formats a decoded JSON number without exponent notation.
+func num(v interface{}) string {
+	switch n := v.(type) {
+	case json.Number:
+		return n.String()
+	case nil:
+		return "-"
+	default:
+		return fmt.Sprint(n)
+	}
+}
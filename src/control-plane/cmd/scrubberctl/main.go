@@ -0,0 +1,388 @@
+// Command scrubberctl is a command-line client for the scrubber control API.
+//
+// Usage:
+//
+//	scrubberctl [flags] <command> [args]
+//
+// Commands:
+//
+//	status                                   Show scrubber status
+//	stats [-watch] [-interval 1s]            Show (or stream) traffic statistics
+//	stats [-prefix CIDR] [-tenant NAME]      Show traffic and drop reasons towards one victim
+//	stats interval [DURATION]                Show or change the stats collection interval
+//	top [-interval 2s] [-talkers N] [-events N] Live dashboard of rates, drops, talkers and events
+//	acl list|add|del blacklist|whitelist [-ttl D] [CIDR]
+//	acl list blacklist|whitelist [-idle D]   List entries not hit for D, to find dead rules
+//	rate get                                 Show per-source rate limits
+//	rate set [-syn N] [-udp N] [-icmp N] [-global-pps N] [-global-bps N] [-adaptive]
+//	config list                              Show every data plane config key and its value
+//	config get|set KEY [VALUE]               Show or change one data plane config key
+//	config revisions                         List configuration revisions
+//	config diff REV [AGAINST]                Show what a revision changed
+//	config rollback REV                      Re-apply a previous revision
+//	escalation get                           Show the escalation level, triggers and indicators
+//	escalation set [-reason TEXT] LEVEL      Force the escalation level
+//	escalation pin -reason TEXT LEVEL        Hold escalation at or above LEVEL [-for D]
+//	escalation unpin                         Remove the escalation pin
+//	escalation history [-limit N]            List escalation transitions, newest first
+//	approvals [list]                         List mitigations staged for NOC approval
+//	approvals approve|reject ID              Run or veto a staged mitigation [-reason TEXT]
+//	reputation top [-limit N]                List the highest-scoring sources
+//	reputation blocked                       List blocked sources
+//	reputation block|unblock IP              Manually block or unblock a source
+//	reputation threshold get|set N           Show or change the auto-block threshold
+//	reputation export csv|json FILE          Download the reputation table
+//	reputation config get                    Show reputation scoring and decay settings
+//	reputation config set [-decay linear|exponential] [-decay-rate N] [-half-life S]
+//	                      [-poll-interval S] [-unblock-ratio N] [-weight-syn N] ...
+//	conntrack show|flush                     Show or flush connection tracking
+//	conntrack list [-src P] [-dst P] [-proto P] [-state S] [-offset N] [-limit N]
+//	syncookie [status]                       Show SYN cookie rates, seed age and scope
+//	syncookie add|del CIDR                   Limit SYN cookies to (or drop) a destination prefix
+//	threat-intel feeds                       List threat intelligence feeds and sync status
+//	threat-intel add NAME URL TYPE           Add a custom feed
+//	threat-intel del|enable|disable NAME     Remove, enable, or disable a feed
+//	threat-intel set NAME [-confidence N] [-action drop|rate_limit|monitor]
+//	threat-intel lookup IP|CIDR              Show which feeds list an address or prefix
+//	threat-intel stats                       Show packets matched and dropped per feed
+//	threat-intel push [-source NAME] [-type T] [-confidence N] [-action A] [-ttl D] IP|CIDR...
+//	threat-intel quarantine [list]           List feed syncs held back as anomalous
+//	threat-intel quarantine approve|reject FEED
+//	threat-intel sync                        Re-sync all threat intelligence feeds
+//	capture status|stop|files                Show, stop, or list packet captures
+//	capture start [-mode drops|all] [-duration D] [-max-packets N] [-sample N] [-snaplen N]
+//	capture get|rm FILE                      Download or delete a pcap file
+//	signatures proposals                     List learned signature proposals
+//	signatures approve|reject ID             Install or discard a proposal
+//	cluster status                           Show HA role and peer sync state
+//	fleet [status]                           Show fleet leader election state
+//	geoip [status]                           Show the loaded GeoIP data and load progress
+//	geoip load [-locations CSV] [DB]         Load GeoIP data, or reload the current file
+//	geoip cancel                             Cancel a GeoIP data load in progress
+//	geoip list                               List country policies in force
+//	geoip set [-ttl D] [-reason TEXT] CC|CONTINENT|REGION ACTION
+//	geoip del [-temp] CC|CONTINENT|REGION    Remove a country, continent or region policy
+//	geoip lookup IP                          Show the country and policy of an address
+//	geoip stats                              Show per-country traffic and drops
+//	geoip audit                              Show country policy changes
+//	asn list                                 List ASN policies
+//	asn set ASN pass|drop|rate_limit|monitor Set the policy for an ASN
+//	asn del ASN                              Remove the policy for an ASN
+//	asn lookup IP                            Show the ASN and policy of an address
+//	bgp status|blackholes                    Show BGP sessions or active blackholes
+//	bgp announce [-ttl D] PREFIX             Announce an RTBH blackhole
+//	bgp withdraw PREFIX                      Withdraw an RTBH blackhole
+//	bgp audit [-offset N] [-limit N]         Page through the BGP audit log
+//	tunnel list                              List GRE return tunnels and endpoint health
+//	tunnel add [-backup IP] NAME PREFIX ENDPOINT
+//	tunnel del NAME                          Remove a GRE return tunnel
+//	asset list                               List protected prefixes and their policies
+//	asset stats                              Show traffic and drops per protected prefix
+//	asset add|set [flags] NAME PREFIX        Add or replace a protected prefix
+//	asset del NAME                           Remove a protected prefix
+//	attack list [-limit N]                   List attack sessions, newest first
+//	attack show ID                           Print an attack postmortem
+//	attack report ID FILE                    Save an attack report (.json or Markdown)
+//	diversion [status]                       Show scrubbing-center diversion state per prefix
+//	diversion mode auto|on|off               Follow escalation, or force diversion on or off
+//	schedule [status]                        Show the active policy profile and the next switch
+//	schedule override [-for D] PROFILE       Hold a policy profile, for D or until resumed
+//	schedule resume                          Return to the scheduled policy profile
+//	maintenance [status]                     Show maintenance state and past windows
+//	maintenance on [flags] REASON...          Bypass or detach XDP (-mode, -timeout, -force)
+//	maintenance off                          End maintenance and resume scrubbing
+//	baseline [status|reset]                  Show the learned traffic baseline or restart learning
+//	watchdog [status|check]                  Show or run the XDP attachment and config check
+//	connlimit [-limit N]                     Show connection limits and the busiest sources
+//	egress [status]                          Show the outbound scrubbing policy and its drops
+//	egress set [-spoof on|off] [-prefixes CIDR,...] [-amp on|off] [-ports PORT=PPS,...]
+//	inspect [-limit N]                       Show deep inspection verdicts and redirected traffic
+//	httpflood                                Show destinations flagged for HTTP request floods
+//	scanners [-since D] [-limit N]           List sources flagged as port scanners
+//	handshakes [-since D] [-limit N]         List sources dropped for TLS/QUIC handshake floods
+//	ratelimit [-all] [-limit N]              List rate-limited sources, most drops first
+//	ratelimit show|reset IP                  Show or reset the token bucket of a source
+//	ratelimit classes                        List rate classes with their bindings and drops
+//	ratelimit class add|set [flags] NAME     Add or replace a rate class (-udp-pps, -pps, -src, -dst, ...)
+//	ratelimit class del NAME                 Remove a rate class
+//	bpf status|maps                          Show the XDP program or map sizes and memory
+//	bpf diagnostics                          Show missing kernel features and the last verifier failure
+//	bpf replace OBJECT                       Hot-swap the XDP program, keeping its maps
+//	auth whoami|audit                        Show your API role or the denied-request log
+//	auth tenants                             List tenants with their prefixes and scoped keys
+//	debug runtime                            Show goroutines, heap, GC, map walks, feed syncs and event loss
+//
+// Every command accepts --output json for machine-readable output.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/output"
+)
+
+var (
+	version   = "dev"
+	buildTime = "unknown"
+)
+
+func main() {
+	var (
+		addr      = flag.String("addr", envOr("SCRUBBER_ADDR", "127.0.0.1:9090"), "Scrubber API address (host:port, URL, or unix:PATH)")
+		caFile    = flag.String("ca", envOr("SCRUBBER_CA", ""), "CA bundle for verifying the API certificate (enables HTTPS)")
+		certFile  = flag.String("cert", envOr("SCRUBBER_CERT", ""), "Client certificate for APIs requiring one")
+		keyFile   = flag.String("key", envOr("SCRUBBER_KEY", ""), "Client certificate key")
+		token     = flag.String("token", envOr("SCRUBBER_TOKEN", ""), "API key sent as a bearer token")
+		timeout   = flag.Duration("timeout", 10*time.Second, "Request timeout")
+		outputFmt = flag.String("output", "text", "Output format (text/json)")
+		showVer   = flag.Bool("version", false, "Show version and exit")
+	)
+	flag.Usage = usage
+	flag.Parse()
+
+	format, err := output.ParseFormat(*outputFmt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	if *showVer {
+		fmt.Printf("scrubberctl %s (built %s)\n", version, buildTime)
+		return
+	}
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	tlsCfg, err := loadTLS(*caFile, *certFile, *keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	c := newClient(*addr, *timeout, tlsCfg)
+	c.token = *token
+	args := flag.Args()[1:]
+
+	switch flag.Arg(0) {
+	case "status":
+		err = cmdStatus(c, format)
+	case "stats":
+		err = cmdStats(c, format, args)
+	case "top":
+		err = cmdTop(c, format, args)
+	case "acl":
+		err = cmdACL(c, format, args)
+	case "rate":
+		err = cmdRate(c, format, args)
+	case "config":
+		err = cmdConfig(c, format, args)
+	case "escalation":
+		err = cmdEscalation(c, format, args)
+	case "approvals":
+		err = cmdApprovals(c, format, args)
+	case "reputation":
+		err = cmdReputation(c, format, args)
+	case "conntrack":
+		err = cmdConntrack(c, format, args)
+	case "syncookie":
+		err = cmdSYNCookie(c, format, args)
+	case "threat-intel":
+		err = cmdThreatIntel(c, format, args)
+	case "capture":
+		err = cmdCapture(c, format, args)
+	case "signatures":
+		err = cmdSignatures(c, format, args)
+	case "cluster":
+		err = cmdCluster(c, format, args)
+	case "fleet":
+		err = cmdFleet(c, format, args)
+	case "geoip":
+		err = cmdGeoIP(c, format, args)
+	case "asn":
+		err = cmdASN(c, format, args)
+	case "bgp":
+		err = cmdBGP(c, format, args)
+	case "tunnel":
+		err = cmdTunnel(c, format, args)
+	case "asset":
+		err = cmdAsset(c, format, args)
+	case "attack":
+		err = cmdAttack(c, format, args)
+	case "diversion":
+		err = cmdDiversion(c, format, args)
+	case "schedule":
+		err = cmdSchedule(c, format, args)
+	case "maintenance":
+		err = cmdMaintenance(c, format, args)
+	case "baseline":
+		err = cmdBaseline(c, format, args)
+	case "watchdog":
+		err = cmdWatchdog(c, format, args)
+	case "connlimit":
+		err = cmdConnLimit(c, format, args)
+	case "egress":
+		err = cmdEgress(c, format, args)
+	case "inspect":
+		err = cmdInspect(c, format, args)
+	case "httpflood":
+		err = cmdHTTPFlood(c, format)
+	case "scanners":
+		err = cmdScanners(c, format, args)
+	case "handshakes":
+		err = cmdHandshakes(c, format, args)
+	case "ratelimit":
+		err = cmdRateLimit(c, format, args)
+	case "bpf":
+		err = cmdBPF(c, format, args)
+	case "auth":
+		err = cmdAuth(c, format, args)
+	case "debug":
+		err = cmdDebug(c, format, args)
+	default:
+		err = usageError("unknown command %q", flag.Arg(0))
+	}
+
+	if err != nil {
+		output.PrintError(os.Stdout, os.Stderr, format, err)
+		if _, ok := err.(usageErr); ok {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+// usageErr marks errors caused by invalid command-line usage (exit code 2).
+type usageErr struct{ msg string }
+
+func (e usageErr) Error() string { return e.msg }
+
+func usageError(format string, args ...interface{}) error {
+	return usageErr{msg: fmt.Sprintf(format, args...)}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: scrubberctl [flags] <command> [args]
+
+Commands:
+  status                                   Show scrubber status
+  stats [-watch] [-interval 1s]            Show (or stream) traffic statistics
+  stats [-prefix CIDR] [-tenant NAME]      Show traffic and drop reasons towards one victim
+  stats interval [DURATION]                Show or change the stats collection interval
+  top [-interval 2s] [-talkers N] [-events N] Live dashboard of rates, drops, talkers and events
+  acl list|add|del blacklist|whitelist [-ttl D] [CIDR]
+  acl list blacklist|whitelist [-idle D]   List entries not hit for D, to find dead rules
+  rate get                                 Show per-source rate limits
+  rate set [-syn N] [-udp N] [-icmp N] [-global-pps N] [-global-bps N] [-adaptive]
+  config list                              Show every data plane config key and its value
+  config get|set KEY [VALUE]               Show or change one data plane config key
+  config revisions                         List configuration revisions
+  config diff REV [AGAINST]                Show what a revision changed
+  config rollback REV                      Re-apply a previous revision
+  escalation get                           Show the escalation level, triggers and indicators
+  escalation set [-reason TEXT] LEVEL      Force the escalation level
+  escalation pin -reason TEXT LEVEL        Hold escalation at or above LEVEL [-for D]
+  escalation unpin                         Remove the escalation pin
+  escalation history [-limit N]            List escalation transitions, newest first
+  approvals [list]                         List mitigations staged for NOC approval
+  approvals approve|reject ID              Run or veto a staged mitigation [-reason TEXT]
+  reputation top [-limit N]                List the highest-scoring sources
+  reputation blocked                       List blocked sources
+  reputation block|unblock IP              Manually block or unblock a source
+  reputation threshold get|set N           Show or change the auto-block threshold
+  reputation export csv|json FILE          Download the reputation table
+  reputation config get                    Show reputation scoring and decay settings
+  reputation config set [-decay linear|exponential] [-decay-rate N] [-half-life S]
+                        [-poll-interval S] [-unblock-ratio N] [-weight-syn N] ...
+  conntrack show|flush                     Show or flush connection tracking
+  conntrack list [-src P] [-dst P] [-proto P] [-state S] [-offset N] [-limit N]
+  syncookie [status]                       Show SYN cookie rates, seed age and scope
+  syncookie add|del CIDR                   Limit SYN cookies to (or drop) a destination prefix
+  threat-intel feeds                       List threat intelligence feeds and sync status
+  threat-intel add NAME URL TYPE           Add a custom feed
+  threat-intel del|enable|disable NAME     Remove, enable, or disable a feed
+  threat-intel set NAME [-confidence N] [-action drop|rate_limit|monitor]
+  threat-intel lookup IP|CIDR              Show which feeds list an address or prefix
+  threat-intel stats                       Show packets matched and dropped per feed
+  threat-intel push [-source NAME] [-type T] [-confidence N] [-action A] [-ttl D] IP|CIDR...
+  threat-intel quarantine [list]           List feed syncs held back as anomalous
+  threat-intel quarantine approve|reject FEED
+  threat-intel sync                        Re-sync all threat intelligence feeds
+  capture status|stop|files                Show, stop, or list packet captures
+  capture start [-mode drops|all] [-duration D] [-max-packets N] [-sample N] [-snaplen N]
+  capture get|rm FILE                      Download or delete a pcap file
+  signatures proposals                     List learned signature proposals
+  signatures approve|reject ID             Install or discard a proposal
+  cluster status                           Show HA role and peer sync state
+  fleet [status]                           Show fleet leader election state
+  geoip [status]                           Show the loaded GeoIP data and load progress
+  geoip load [-locations CSV] [DB]         Load GeoIP data, or reload the current file
+  geoip cancel                             Cancel a GeoIP data load in progress
+  geoip list                               List country policies in force
+  geoip set [-ttl D] [-reason TEXT] CC|CONTINENT|REGION ACTION
+  geoip del [-temp] CC|CONTINENT|REGION    Remove a country, continent or region policy
+  geoip lookup IP                          Show the country and policy of an address
+  geoip stats                              Show per-country traffic and drops
+  geoip audit                              Show country policy changes
+  asn list                                 List ASN policies
+  asn set ASN pass|drop|rate_limit|monitor Set the policy for an ASN
+  asn del ASN                              Remove the policy for an ASN
+  asn lookup IP                            Show the ASN and policy of an address
+  bgp status|blackholes                    Show BGP sessions or active blackholes
+  bgp announce [-ttl D] PREFIX             Announce an RTBH blackhole
+  bgp withdraw PREFIX                      Withdraw an RTBH blackhole
+  bgp audit [-offset N] [-limit N]         Page through the BGP audit log
+  tunnel list                              List GRE return tunnels and endpoint health
+  tunnel add [-backup IP] NAME PREFIX ENDPOINT
+  tunnel del NAME                          Remove a GRE return tunnel
+  asset list                               List protected prefixes and their policies
+  asset stats                              Show traffic and drops per protected prefix
+  asset add|set [flags] NAME PREFIX        Add or replace a protected prefix
+  asset del NAME                           Remove a protected prefix
+  attack list [-limit N]                   List attack sessions, newest first
+  attack show ID                           Print an attack postmortem
+  attack report ID FILE                    Save an attack report (.json or Markdown)
+  diversion [status]                       Show scrubbing-center diversion state per prefix
+  diversion mode auto|on|off               Follow escalation, or force diversion on or off
+  schedule [status]                        Show the active policy profile and the next switch
+  schedule override [-for D] PROFILE       Hold a policy profile, for D or until resumed
+  schedule resume                          Return to the scheduled policy profile
+  maintenance [status]                     Show maintenance state and past windows
+  maintenance on [flags] REASON...          Bypass or detach XDP (-mode, -timeout, -force)
+  maintenance off                          End maintenance and resume scrubbing
+  baseline [status|reset]                  Show the learned traffic baseline or restart learning
+  watchdog [status|check]                  Show or run the XDP attachment and config check
+  connlimit [-limit N]                     Show connection limits and the busiest sources
+  egress [status]                          Show the outbound scrubbing policy and its drops
+  egress set [-spoof on|off] [-prefixes CIDR,...] [-amp on|off] [-ports PORT=PPS,...]
+  inspect [-limit N]                       Show deep inspection verdicts and redirected traffic
+  httpflood                                Show destinations flagged for HTTP request floods
+  scanners [-since D] [-limit N]           List sources flagged as port scanners
+  handshakes [-since D] [-limit N]         List sources dropped for TLS/QUIC handshake floods
+  ratelimit [-all] [-limit N]              List rate-limited sources, most drops first
+  ratelimit show|reset IP                  Show or reset the token bucket of a source
+  ratelimit classes                        List rate classes with their bindings and drops
+  ratelimit class add|set [flags] NAME     Add or replace a rate class (-udp-pps, -pps, -src, -dst, ...)
+  ratelimit class del NAME                 Remove a rate class
+  bpf status|maps                          Show the XDP program or map sizes and memory
+  bpf diagnostics                          Show missing kernel features and the last verifier failure
+  bpf replace OBJECT                       Hot-swap the XDP program, keeping its maps
+  auth whoami|audit                        Show your API role or the denied-request log
+  auth tenants                             List tenants with their prefixes and scoped keys
+  debug runtime                            Show goroutines, heap, GC, map walks, feed syncs and event loss
+
+Flags:
+`)
+	flag.PrintDefaults()
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/output"
+)
+
+// Terminal control sequences: move the cursor home and clear the screen.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// topTalkerList mirrors GET /api/v1/top-talkers.
+type topTalkerList struct {
+	Talkers []struct {
+		IP      string  `json:"ip"`
+		PPS     float64 `json:"pps"`
+		BPS     float64 `json:"bps"`
+		DropPPS float64 `json:"dropPps"`
+	} `json:"talkers"`
+}
+
+// topEvent is an event received over /ws/realtime.
+type topEvent struct {
+	Received   time.Time
+	SrcIP      string      `json:"srcIp"`
+	DstIP      string      `json:"dstIp"`
+	DstPort    uint16      `json:"dstPort"`
+	AttackType string      `json:"attackType"`
+	Action     string      `json:"action"`
+	DropReason string      `json:"dropReason"`
+	PPS        json.Number `json:"ppsEstimate"`
+}
+
+// topFrame is one refresh of the dashboard.
+type topFrame struct {
+	at       time.Time
+	interval time.Duration
+	stats    map[string]interface{}
+	prev     map[string]interface{} // Previous stats, for per-reason rates
+	level    escalationInfo
+	talkers  topTalkerList
+	events   []topEvent
+	eventErr string
+	errs     []string
+}
+
+// eventFeed keeps the latest events streamed over the WebSocket API.
+type eventFeed struct {
+	max int
+
+	mu     sync.Mutex
+	events []topEvent // Newest last
+	err    string
+}
+
+func (f *eventFeed) add(ev topEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, ev)
+	if len(f.events) > f.max {
+		f.events = f.events[len(f.events)-f.max:]
+	}
+	f.err = ""
+}
+
+func (f *eventFeed) setErr(err error) {
+	f.mu.Lock()
+	f.err = err.Error()
+	f.mu.Unlock()
+}
+
+// snapshot returns the events newest first and the last stream error.
+func (f *eventFeed) snapshot() ([]topEvent, string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]topEvent, len(f.events))
+	for i, ev := range f.events {
+		out[len(out)-1-i] = ev
+	}
+	return out, f.err
+}
+
+// run subscribes to the events channel, reconnecting until ctx is done.
+func (f *eventFeed) run(ctx context.Context, c *client) {
+	for {
+		if err := f.stream(ctx, c); err != nil && ctx.Err() == nil {
+			f.setErr(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (f *eventFeed) stream(ctx context.Context, c *client) error {
+	conn, err := c.dialWS(ctx, "/ws/realtime")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	sub := map[string]interface{}{"action": "subscribe", "channels": []string{"events"}}
+	if err := conn.WriteJSON(sub); err != nil {
+		return fmt.Errorf("subscribing to events: %w", err)
+	}
+	for {
+		var msg struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			return fmt.Errorf("event stream: %w", err)
+		}
+		if msg.Type != "event" {
+			continue
+		}
+		var ev topEvent
+		if err := json.Unmarshal(msg.Data, &ev); err != nil {
+			continue
+		}
+		ev.Received = time.Now()
+		f.add(ev)
+	}
+}
+
+func cmdTop(c *client, format output.Format, args []string) error {
+	fs := flag.NewFlagSet("top", flag.ContinueOnError)
+	interval := fs.Duration("interval", 2*time.Second, "Refresh interval")
+	talkers := fs.Int("talkers", 10, "Number of top talkers to show")
+	events := fs.Int("events", 10, "Number of recent events to show")
+	if err := fs.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+	if format != output.Text {
+		return usageError("top only supports text output")
+	}
+	if *interval <= 0 {
+		return usageError("-interval must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	feed := &eventFeed{max: *events}
+	if *events > 0 {
+		go feed.run(ctx, c)
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	var prev map[string]interface{}
+	for {
+		f := topFrame{at: time.Now(), interval: *interval, prev: prev}
+		if err := c.get("/api/v1/stats", &f.stats); err != nil {
+			f.errs = append(f.errs, err.Error())
+		}
+		if err := c.get("/api/v1/escalation", &f.level); err != nil {
+			f.errs = append(f.errs, err.Error())
+		}
+		if err := c.get(fmt.Sprintf("/api/v1/top-talkers?limit=%d", *talkers), &f.talkers); err != nil {
+			f.errs = append(f.errs, err.Error())
+		}
+		f.events, f.eventErr = feed.snapshot()
+		prev = f.stats
+
+		var buf bytes.Buffer
+		buf.WriteString(clearScreen)
+		renderTop(&buf, f)
+		os.Stdout.Write(buf.Bytes())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// renderTop draws one dashboard frame.
+func renderTop(w io.Writer, f topFrame) {
+	level := f.level.Name
+	if level == "" {
+		level = "?"
+	}
+	fmt.Fprintf(w, "scrubber top - %s  escalation %s  (Ctrl-C to quit)\n\n", f.at.Format("15:04:05"), strings.ToUpper(level))
+
+	st := f.stats
+	fmt.Fprintf(w, "RX    %10s pps %10s bps\n", si(st["rxPps"]), si(st["rxBps"]))
+	fmt.Fprintf(w, "TX    %10s pps %10s bps\n", si(st["txPps"]), si(st["txBps"]))
+	fmt.Fprintf(w, "DROP  %10s pps %10s bps\n", si(st["dropPps"]), si(st["dropBps"]))
+	fmt.Fprintf(w, "SYN %s pps  UDP %s pps  ICMP %s pps  DNS %s pps\n\n",
+		si(st["synPps"]), si(st["udpPps"]), si(st["icmpPps"]), si(st["dnsPps"]))
+
+	fmt.Fprintln(w, "DROP REASONS")
+	reasons := dropRates(f.prev, st, f.interval)
+	if len(reasons) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+	for _, r := range reasons {
+		fmt.Fprintf(w, "  %-24s %10s pps\n", r.name, si(r.pps))
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "%-18s %10s %10s %10s\n", "TOP TALKER", "PPS", "BPS", "DROP PPS")
+	for _, t := range f.talkers.Talkers {
+		fmt.Fprintf(w, "%-18s %10s %10s %10s\n", t.IP, si(t.PPS), si(t.BPS), si(t.DropPPS))
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "RECENT EVENTS")
+	if f.eventErr != "" {
+		fmt.Fprintf(w, "  (%s)\n", f.eventErr)
+	}
+	for _, ev := range f.events {
+		fmt.Fprintf(w, "  %s  %-15s -> %s:%d  %s %s (%s) ~%s pps\n",
+			ev.Received.Format("15:04:05"), ev.SrcIP, ev.DstIP, ev.DstPort,
+			ev.AttackType, ev.Action, ev.DropReason, si(ev.PPS))
+	}
+
+	for _, e := range f.errs {
+		fmt.Fprintf(w, "\nerror: %s", e)
+	}
+	if len(f.errs) > 0 {
+		fmt.Fprintln(w)
+	}
+}
+
+type reasonRate struct {
+	name string
+	pps  float64
+}
+
+// dropRates derives per-reason drop rates from the cumulative *Dropped
+// and rateLimited counters of two stats samples, busiest first.
+func dropRates(prev, cur map[string]interface{}, interval time.Duration) []reasonRate {
+	if prev == nil || cur == nil || interval <= 0 {
+		return nil
+	}
+	var out []reasonRate
+	for k, v := range cur {
+		name, ok := strings.CutSuffix(k, "Dropped")
+		if !ok {
+			if k != "rateLimited" {
+				continue
+			}
+			name = k
+		}
+		now, ok1 := toFloat(v)
+		before, ok2 := toFloat(prev[k])
+		if !ok1 || !ok2 || now <= before {
+			continue
+		}
+		out = append(out, reasonRate{name: name, pps: (now - before) / interval.Seconds()})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].pps != out[j].pps {
+			return out[i].pps > out[j].pps
+		}
+		return out[i].name < out[j].name
+	})
+	return out
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// si formats a rate with an SI suffix (k, M, G).
+func si(v interface{}) string {
+	var f float64
+	switch n := v.(type) {
+	case json.Number:
+		var ok bool
+		if f, ok = toFloat(n); !ok {
+			return "-"
+		}
+	case float64:
+		f = n
+	default:
+		return "-"
+	}
+	for _, unit := range []string{"", "k", "M", "G"} {
+		if f < 1000 || unit == "G" {
+			return strconv.FormatFloat(f, 'f', 1, 64) + unit
+		}
+		f /= 1000
+	}
+	return "-"
+}
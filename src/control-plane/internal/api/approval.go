@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/approval"
+	"go.uber.org/zap"
+)
+
+// handleApprovals lists the mitigations staged for approval (GET) and
+// decides one (POST), e.g. {"id":3,"action":"reject","reason":"customer
+// prefers to absorb"}.
+func (s *Server) handleApprovals(w http.ResponseWriter, r *http.Request) {
+	if s.approvals == nil {
+		http.Error(w, "approvals not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.approvals.Requests())
+
+	case http.MethodPost:
+		var req struct {
+			ID     uint32 `json:"id"`
+			Action string `json:"action"` // "approve" or "reject"
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		var (
+			a   approval.Request
+			err error
+		)
+		by := requester(r)
+		switch req.Action {
+		case "approve":
+			a, err = s.approvals.Approve(req.ID, by, req.Reason)
+		case "reject":
+			a, err = s.approvals.Reject(req.ID, by, req.Reason)
+		default:
+			http.Error(w, "action must be approve or reject", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, approval.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		s.log.Warn("mitigation approval decided via API",
+			zap.Uint32("id", req.ID),
+			zap.String("action", req.Action),
+			zap.String("by", by),
+			zap.String("reason", req.Reason),
+		)
+		writeJSON(w, a)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
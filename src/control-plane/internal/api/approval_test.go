@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/approval"
+	"go.uber.org/zap"
+)
+
+func TestApprovals(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	const path = "/api/v1/approvals"
+
+	rec := httptest.NewRecorder()
+	s.handleApprovals(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without manager: status = %d, want 503", rec.Code)
+	}
+
+	cfg := approval.DefaultConfig()
+	cfg.Enabled = true
+	m := approval.NewManager(zap.NewNop(), cfg, nil)
+	m.Gate(approval.ActionRTBH, "blackhole 192.0.2.0/24", func() {})
+	s.SetApprovals(m)
+
+	tests := []struct {
+		method string
+		body   string
+		want   int
+	}{
+		{http.MethodGet, ``, http.StatusOK},
+		{http.MethodPost, `{"id":1,"action":"maybe"}`, http.StatusBadRequest},
+		{http.MethodPost, `{"id":7,"action":"approve"}`, http.StatusNotFound},
+		{http.MethodPost, `{"id":1,"action":"reject","reason":"absorbing it"}`, http.StatusOK},
+		{http.MethodPost, `{"id":1,"action":"approve"}`, http.StatusConflict},
+		{http.MethodDelete, ``, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleApprovals(rec, httptest.NewRequest(tt.method, path, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.body, rec.Code, tt.want)
+		}
+	}
+	if r := m.Requests()[0]; r.Status != approval.StatusRejected || r.Reason != "absorbing it" {
+		t.Errorf("request = %+v", r)
+	}
+}
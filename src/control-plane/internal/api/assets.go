@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/assets"
+	"go.uber.org/zap"
+)
+
+// handleAssets lists, adds, replaces and removes protected assets. Tenant
+// callers see only their own assets and may change their policies, but not
+// their prefix, owner or auto-RTBH permission.
+func (s *Server) handleAssets(w http.ResponseWriter, r *http.Request) {
+	if s.assets == nil {
+		http.Error(w, "asset registry not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]interface{}{"assets": s.tenantAssets(r)})
+
+	case http.MethodPost, http.MethodPut:
+		var req assets.Asset
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodPut {
+			cur, ok := s.assets.Get(req.Name)
+			tenant := requestTenant(r)
+			if !ok || (tenant != "" && cur.Owner != tenant) {
+				http.Error(w, "asset not found", http.StatusNotFound)
+				return
+			}
+			if tenant != "" {
+				req.Prefix, req.Owner, req.AutoRTBH = cur.Prefix, cur.Owner, cur.AutoRTBH
+			}
+			if err := s.assets.Update(req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.log.Info("protected asset updated via API",
+				zap.String("name", req.Name), zap.String("prefix", req.Prefix), zap.String("tenant", tenant))
+		} else {
+			if err := s.assets.Add(req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.log.Info("protected asset added via API",
+				zap.String("name", req.Name), zap.String("prefix", req.Prefix), zap.String("owner", req.Owner))
+		}
+		writeJSON(w, map[string]bool{"ok": true})
+
+	case http.MethodDelete:
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := s.assets.Remove(req.Name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.log.Info("protected asset removed via API", zap.String("name", req.Name))
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAssetStats reports the traffic counted towards each protected
+// asset visible to the caller.
+func (s *Server) handleAssetStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.assets == nil {
+		http.Error(w, "asset registry not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	stats, err := s.assets.Stats(s.tenantAssets(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"assets": stats})
+}
+
+// tenantAssets returns the assets the caller may see: all of them, or only
+// its tenant's.
+func (s *Server) tenantAssets(r *http.Request) []assets.Asset {
+	if tenant := requestTenant(r); tenant != "" {
+		return s.assets.Owned(tenant)
+	}
+	return s.assets.List()
+}
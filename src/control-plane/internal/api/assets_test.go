@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/assets"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+type fakeDstPolicyMap map[string]bpf.DstPolicy
+
+func (f fakeDstPolicyMap) SetDstPolicy(cidr string, p bpf.DstPolicy) error {
+	f[cidr] = p
+	return nil
+}
+
+func (f fakeDstPolicyMap) RemoveDstPolicy(cidr string) error {
+	delete(f, cidr)
+	return nil
+}
+
+func (f fakeDstPolicyMap) DstPolicies() (map[string]bpf.DstPolicy, error) {
+	return f, nil
+}
+
+func (f fakeDstPolicyMap) AddSYNCookieDest(string) error      { return nil }
+func (f fakeDstPolicyMap) RemoveSYNCookieDest(string) error   { return nil }
+func (f fakeDstPolicyMap) SetGeoAllow(uint16, []string) error { return nil }
+func (f fakeDstPolicyMap) RemoveGeoAllow(uint16) error        { return nil }
+
+func TestAssets(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	const path = "/api/v1/assets"
+
+	rec := httptest.NewRecorder()
+	s.handleAssets(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without registry: status = %d, want 503", rec.Code)
+	}
+
+	m := fakeDstPolicyMap{}
+	s.SetAssets(assets.NewRegistry(zap.NewNop(), m))
+
+	tests := []struct {
+		method string
+		body   string
+		want   int
+	}{
+		{http.MethodPost, `{"name":"web","prefix":"203.0.113.0/24","owner":"acme"}`, http.StatusOK},
+		{http.MethodPost, `{"name":"web","prefix":"198.51.100.0/24"}`, http.StatusBadRequest},
+		{http.MethodPost, `{"name":"dns","prefix":"198.51.100.0/24","profile":"severe"}`, http.StatusBadRequest},
+		{http.MethodPut, `{"name":"web","prefix":"203.0.113.0/24","owner":"acme","profile":"medium","udpRatePps":200}`, http.StatusOK},
+		{http.MethodPut, `{"name":"mail","prefix":"198.51.100.0/24"}`, http.StatusNotFound},
+		{http.MethodDelete, `{"name":"mail"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleAssets(rec, httptest.NewRequest(tt.method, path, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.body, rec.Code, tt.want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleAssets(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var res struct {
+		Assets []assets.Asset `json:"assets"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(res.Assets) != 1 || res.Assets[0].Profile != "medium" || m["203.0.113.0/24"].UDPRatePPS != 200 {
+		t.Errorf("assets = %+v, map = %v", res.Assets, m)
+	}
+}
+
+func TestAssetsTenantScope(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	m := fakeDstPolicyMap{}
+	reg := assets.NewRegistry(zap.NewNop(), m)
+	if err := reg.Configure([]assets.Asset{
+		{Name: "web", Prefix: "203.0.113.0/24", Owner: "acme"},
+		{Name: "mail", Prefix: "198.51.100.0/24", Owner: "globex", AutoRTBH: true},
+	}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	s.SetAssets(reg)
+
+	asTenant := func(method, path, body string) *http.Request {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		id := identity{Name: "portal", Source: "key", Role: roleOperator, Tenant: "globex"}
+		return req.WithContext(context.WithValue(req.Context(), identityKey{}, id))
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleAssetStats(rec, asTenant(http.MethodGet, "/api/v1/assets/stats", ""))
+	var res struct {
+		Assets []assets.Stats `json:"assets"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(res.Assets) != 1 || res.Assets[0].Name != "mail" {
+		t.Errorf("tenant assets = %+v, want only mail", res.Assets)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleAssets(rec, asTenant(http.MethodPut, "/api/v1/assets", `{"name":"web","prefix":"203.0.113.0/24","profile":"high"}`))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("updating another tenant's asset: status = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleAssets(rec, asTenant(http.MethodPut, "/api/v1/assets",
+		`{"name":"mail","prefix":"192.0.2.0/24","owner":"acme","profile":"high","autoRtbh":false}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("updating own asset: status = %d, want 200", rec.Code)
+	}
+	a, _ := reg.Get("mail")
+	if a.Prefix != "198.51.100.0/24" || a.Owner != "globex" || !a.AutoRTBH || a.Profile != "high" {
+		t.Errorf("asset after tenant update = %+v", a)
+	}
+}
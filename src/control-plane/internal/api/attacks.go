@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/attacks"
+)
+
+// handleAttacks lists attack sessions, the active one first, then the
+// finished ones newest first. ?limit=N caps the list. Tenants see the
+// attacks on their assets only.
+func (s *Server) handleAttacks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.attacks == nil {
+		http.Error(w, "attack tracking not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	list := s.attacks.List()
+	if tenant := requestTenant(r); tenant != "" {
+		own := list[:0]
+		for _, a := range list {
+			if a, ok := s.tenantAttack(tenant, a); ok {
+				own = append(own, a)
+			}
+		}
+		list = own
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		if n < len(list) {
+			list = list[:n]
+		}
+	}
+
+	var active *attacks.Attack
+	if len(list) > 0 && list[0].Active() {
+		active = &list[0]
+	}
+	writeJSON(w, map[string]interface{}{
+		"active":  active,
+		"attacks": list,
+	})
+}
+
+// handleAttackReport serves /api/v1/attacks/{id} as JSON, or as a
+// Markdown postmortem with ?format=markdown.
+func (s *Server) handleAttackReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.attacks == nil {
+		http.Error(w, "attack tracking not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/v1/attacks/")
+	a, err := s.attacks.Get(id)
+	if errors.Is(err, attacks.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if tenant := requestTenant(r); tenant != "" {
+		var ok bool
+		if a, ok = s.tenantAttack(tenant, a); !ok {
+			http.Error(w, attacks.ErrNotFound.Error(), http.StatusNotFound)
+			return
+		}
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		writeJSON(w, a)
+
+	case "markdown", "md":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+a.ID+`.md"`)
+		w.Write([]byte(attacks.Markdown(a)))
+
+	default:
+		http.Error(w, "format must be json or markdown", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/attacks"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/stats"
+	"go.uber.org/zap"
+)
+
+func TestAttacks(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	s.handleAttacks(rec, httptest.NewRequest(http.MethodGet, "/api/v1/attacks", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status without tracker = %d, want 503", rec.Code)
+	}
+
+	tr := attacks.NewTracker(zap.NewNop(), attacks.DefaultConfig())
+	tr.Observe(&stats.Snapshot{Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), DropPPS: 5000})
+	s.SetAttacks(tr)
+
+	rec = httptest.NewRecorder()
+	s.handleAttacks(rec, httptest.NewRequest(http.MethodGet, "/api/v1/attacks", nil))
+	var list struct {
+		Active  *attacks.Attack  `json:"active"`
+		Attacks []attacks.Attack `json:"attacks"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if list.Active == nil || len(list.Attacks) != 1 || list.Active.ID != "atk-20260102T030405Z" {
+		t.Fatalf("attacks = %+v", list)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleAttackReport(rec, httptest.NewRequest(http.MethodGet, "/api/v1/attacks/atk-20260102T030405Z?format=markdown", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Body.String(), "# Attack report atk-20260102T030405Z") {
+		t.Errorf("markdown report = %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleAttackReport(rec, httptest.NewRequest(http.MethodGet, "/api/v1/attacks/atk-missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing attack status = %d, want 404", rec.Code)
+	}
+}
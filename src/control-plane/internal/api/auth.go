@@ -0,0 +1,364 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"go.uber.org/zap"
+)
+
+// Maximum number of denied requests kept in the auth audit log.
+const maxAuthAuditEntries = 1000
+
+// role is an API permission level. Each role includes the ones below it.
+type role int
+
+const (
+	roleNone role = iota
+	roleViewer
+	roleOperator
+	roleAdmin
+)
+
+func (r role) String() string {
+	switch r {
+	case roleViewer:
+		return "viewer"
+	case roleOperator:
+		return "operator"
+	case roleAdmin:
+		return "admin"
+	}
+	return "none"
+}
+
+func parseRole(s string) (role, error) {
+	switch s {
+	case "viewer":
+		return roleViewer, nil
+	case "operator":
+		return roleOperator, nil
+	case "admin":
+		return roleAdmin, nil
+	}
+	return roleNone, fmt.Errorf("unknown role %q", s)
+}
+
+// operatorPaths are the endpoints an operator may change: ACLs, rate
+// limits and the other day-to-day mitigation controls. Every other
+// state-changing request needs admin.
+var operatorPaths = []string{
+	"/api/v1/acl",
+	"/api/v1/config/rate",
+	"/api/v1/ratelimit",
+	"/api/v1/schedule",
+	"/api/v1/geoip/policies",
+	"/api/v1/asn/policies",
+	"/api/v1/conntrack/flush",
+	"/api/v1/baseline/reset",
+	"/api/v1/reputation/blocked",
+	"/api/v1/reputation/threshold",
+	"/api/v1/capture",
+	"/api/v1/signatures/proposals",
+	"/api/v1/approvals",
+	"/api/v1/threat-intel/push",
+	"/api/v1/threatintel/push",
+	"/api/v1/threatintel/quarantine",
+	"/api/v1/syncookie",
+	"/api/v1/stats/interval",
+}
+
+// requiredRole returns the role needed for a request.
+func requiredRole(r *http.Request) role {
+	if strings.HasPrefix(r.URL.Path, debugPrefix) {
+		return roleAdmin
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		if r.URL.Path == "/api/v1/auth/audit" || strings.HasPrefix(r.URL.Path, revisionsPrefix) {
+			return roleAdmin
+		}
+		return roleViewer
+	}
+	if queryPaths[r.URL.Path] {
+		return roleViewer
+	}
+	for _, p := range operatorPaths {
+		if r.URL.Path == p || strings.HasPrefix(r.URL.Path, p+"/") {
+			return roleOperator
+		}
+	}
+	return roleAdmin
+}
+
+// tenantRole returns the role a tenant-scoped caller needs for a request,
+// or false if tenants may not use the endpoint. These handlers narrow what
+// they show and change to the tenant's own assets.
+func tenantRole(r *http.Request) (role, bool) {
+	switch r.URL.Path {
+	case "/api/v1/auth/whoami", "/api/v1/stats", "/api/v1/assets/stats", "/ws/realtime", "/api/v1/stream":
+		return roleViewer, r.Method == http.MethodGet
+	case "/api/v1/assets":
+		switch r.Method {
+		case http.MethodGet:
+			return roleViewer, true
+		case http.MethodPut:
+			return roleOperator, true
+		}
+	case "/api/v1/attacks":
+		return roleViewer, r.Method == http.MethodGet
+	case "/api/v1/acl/blacklist", "/api/v1/acl/whitelist":
+		switch r.Method {
+		case http.MethodGet:
+			return roleViewer, true
+		case http.MethodPost, http.MethodDelete:
+			return roleOperator, true
+		}
+	}
+	if strings.HasPrefix(r.URL.Path, "/api/v1/attacks/") {
+		return roleViewer, r.Method == http.MethodGet
+	}
+	return roleNone, false
+}
+
+// identity is the authenticated caller of a request.
+type identity struct {
+	Name   string // Key name or certificate common name
+	Source string // "key" or "cert"
+	Role   role
+	Tenant string // Tenant the caller is restricted to, or "" for none
+}
+
+type identityKey struct{}
+
+// requestIdentity returns the caller attached by authMiddleware, if any.
+func requestIdentity(r *http.Request) (identity, bool) {
+	id, ok := r.Context().Value(identityKey{}).(identity)
+	return id, ok
+}
+
+// requestTenant returns the tenant the caller is restricted to, or "" if
+// the caller sees everything.
+func requestTenant(r *http.Request) string {
+	id, _ := requestIdentity(r)
+	return id.Tenant
+}
+
+type apiKey struct {
+	name   string
+	hash   []byte // SHA-256 of the key
+	role   role
+	tenant string
+}
+
+type apiClient struct {
+	role   role
+	tenant string
+}
+
+// authAuditEntry records a request refused by authMiddleware.
+type authAuditEntry struct {
+	Timestamp time.Time
+	Identity  string
+	Role      string
+	Method    string
+	Path      string
+	Remote    string
+	Reason    string
+}
+
+// authorizer resolves callers to roles and keeps the audit log of denied
+// requests.
+type authorizer struct {
+	keys    []apiKey
+	clients map[string]apiClient // Certificate common name -> role
+
+	mu    sync.Mutex
+	audit []authAuditEntry
+}
+
+// newAuthorizer builds an authorizer from cfg. It returns nil if auth is
+// disabled.
+func newAuthorizer(cfg config.APIAuthConfig) (*authorizer, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	a := &authorizer{clients: make(map[string]apiClient, len(cfg.Clients))}
+	for _, k := range cfg.Keys {
+		rl, err := parseRole(k.Role)
+		if err != nil {
+			return nil, fmt.Errorf("api key %s: %w", k.Name, err)
+		}
+		var hash []byte
+		if k.Key != "" {
+			sum := sha256.Sum256([]byte(k.Key))
+			hash = sum[:]
+		} else if hash, err = hex.DecodeString(k.KeySHA256); err != nil {
+			return nil, fmt.Errorf("api key %s: invalid key_sha256: %w", k.Name, err)
+		}
+		a.keys = append(a.keys, apiKey{name: k.Name, hash: hash, role: rl, tenant: k.Tenant})
+	}
+	for _, c := range cfg.Clients {
+		rl, err := parseRole(c.Role)
+		if err != nil {
+			return nil, fmt.Errorf("api client %s: %w", c.CommonName, err)
+		}
+		a.clients[c.CommonName] = apiClient{role: rl, tenant: c.Tenant}
+	}
+	return a, nil
+}
+
+// authenticate identifies the caller by bearer token or, failing that, by
+// verified client certificate.
+func (a *authorizer) authenticate(r *http.Request) (identity, error) {
+	if h := r.Header.Get("Authorization"); h != "" {
+		token, ok := strings.CutPrefix(h, "Bearer ")
+		if !ok {
+			return identity{}, fmt.Errorf("unsupported authorization scheme")
+		}
+		sum := sha256.Sum256([]byte(token))
+		for _, k := range a.keys {
+			if subtle.ConstantTimeCompare(sum[:], k.hash) == 1 {
+				return identity{Name: k.name, Source: "key", Role: k.role, Tenant: k.tenant}, nil
+			}
+		}
+		return identity{}, fmt.Errorf("unknown API key")
+	}
+
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cn := r.TLS.PeerCertificates[0].Subject.CommonName
+		if cl, ok := a.clients[cn]; ok {
+			return identity{Name: cn, Source: "cert", Role: cl.role, Tenant: cl.tenant}, nil
+		}
+		return identity{}, fmt.Errorf("no role for client certificate %q", cn)
+	}
+	return identity{}, fmt.Errorf("no credentials")
+}
+
+func (a *authorizer) recordDenied(e authAuditEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.audit = append(a.audit, e)
+	if len(a.audit) > maxAuthAuditEntries {
+		a.audit = a.audit[len(a.audit)-maxAuthAuditEntries:]
+	}
+}
+
+func (a *authorizer) auditLog() []authAuditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]authAuditEntry, len(a.audit))
+	copy(out, a.audit)
+	return out
+}
+
+// authMiddleware authenticates each request and checks the caller's role
+// against the endpoint; tenant-scoped callers only reach the endpoints in
+// tenantRole. Refused requests are logged and audited. Without auth
+// configured every request passes.
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.auth == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		need := requiredRole(r)
+		id, err := s.auth.authenticate(r)
+		if err == nil && id.Tenant != "" {
+			var ok bool
+			if need, ok = tenantRole(r); !ok {
+				err = fmt.Errorf("not available to tenant %s", id.Tenant)
+			}
+		}
+		if err == nil && id.Role < need {
+			err = fmt.Errorf("requires role %s", need)
+		}
+		if err != nil {
+			s.log.Warn("API request denied",
+				zap.String("identity", id.Name),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("remote", r.RemoteAddr),
+				zap.Error(err),
+			)
+			s.auth.recordDenied(authAuditEntry{
+				Timestamp: time.Now(),
+				Identity:  id.Name,
+				Role:      id.Role.String(),
+				Method:    r.Method,
+				Path:      r.URL.Path,
+				Remote:    r.RemoteAddr,
+				Reason:    err.Error(),
+			})
+			if id.Role == roleNone {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="scrubber"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+			} else {
+				http.Error(w, "forbidden: "+err.Error(), http.StatusForbidden)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+	})
+}
+
+// handleAuthWhoami reports the caller's identity and role.
+func (s *Server) handleAuthWhoami(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, ok := requestIdentity(r)
+	if !ok {
+		writeJSON(w, map[string]interface{}{"authEnabled": false})
+		return
+	}
+	resp := map[string]interface{}{
+		"authEnabled": true,
+		"identity":    id.Name,
+		"source":      id.Source,
+		"role":        id.Role.String(),
+	}
+	if id.Tenant != "" {
+		resp["tenant"] = id.Tenant
+	}
+	writeJSON(w, resp)
+}
+
+// handleAuthAudit lists denied requests, newest first.
+func (s *Server) handleAuthAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.auth == nil {
+		http.Error(w, "API auth not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	audit := s.auth.auditLog()
+	entries := make([]map[string]interface{}, 0, len(audit))
+	for i := len(audit) - 1; i >= 0; i-- {
+		e := audit[i]
+		entries = append(entries, map[string]interface{}{
+			"timestamp": formatTime(e.Timestamp),
+			"identity":  e.Identity,
+			"role":      e.Role,
+			"method":    e.Method,
+			"path":      e.Path,
+			"remote":    e.Remote,
+			"reason":    e.Reason,
+		})
+	}
+	writeJSON(w, map[string]interface{}{"entries": entries})
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"go.uber.org/zap"
+)
+
+func newAuthServer(t *testing.T) (*Server, http.Handler) {
+	t.Helper()
+	opsHash := sha256.Sum256([]byte("ops-key"))
+	a, err := newAuthorizer(config.APIAuthConfig{
+		Enabled: true,
+		Keys: []config.APIKeyConfig{
+			{Name: "dashboard", Key: "view-key", Role: "viewer"},
+			{Name: "noc", KeySHA256: hex.EncodeToString(opsHash[:]), Role: "operator"},
+			{Name: "root", Key: "admin-key", Role: "admin"},
+			{Name: "acme-portal", Key: "tenant-key", Role: "viewer", Tenant: "acme"},
+		},
+		Tenants: []config.APITenantConfig{{Name: "acme"}},
+	})
+	if err != nil {
+		t.Fatalf("newAuthorizer: %v", err)
+	}
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	s.auth = a
+
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, r *http.Request) { writeJSON(w, map[string]bool{"ok": true}) }
+	mux.HandleFunc("/api/v1/status", ok)
+	mux.HandleFunc("/api/v1/acl/blacklist", ok)
+	mux.HandleFunc("/api/v1/bgp/blackholes", ok)
+	mux.HandleFunc("/api/v1/assets", ok)
+	mux.HandleFunc("/api/v1/attacks/", ok)
+	mux.HandleFunc("/api/v1/query", ok)
+	mux.HandleFunc("/api/v1/debug/runtime", ok)
+	mux.HandleFunc("/api/v1/config/", ok)
+	mux.HandleFunc("/api/v1/ratelimit/sources/", ok)
+	mux.HandleFunc("/api/v1/auth/whoami", s.handleAuthWhoami)
+	mux.HandleFunc("/api/v1/auth/audit", s.handleAuthAudit)
+	return s, s.authMiddleware(mux)
+}
+
+func TestAuthRoles(t *testing.T) {
+	_, h := newAuthServer(t)
+
+	tests := []struct {
+		key    string
+		method string
+		path   string
+		want   int
+	}{
+		{"", http.MethodGet, "/api/v1/status", http.StatusUnauthorized},
+		{"wrong", http.MethodGet, "/api/v1/status", http.StatusUnauthorized},
+		{"view-key", http.MethodGet, "/api/v1/status", http.StatusOK},
+		{"view-key", http.MethodPost, "/api/v1/acl/blacklist", http.StatusForbidden},
+		{"view-key", http.MethodPost, "/api/v1/query", http.StatusOK},
+		{"ops-key", http.MethodPost, "/api/v1/acl/blacklist", http.StatusOK},
+		{"ops-key", http.MethodPost, "/api/v1/bgp/blackholes", http.StatusForbidden},
+		{"ops-key", http.MethodGet, "/api/v1/auth/audit", http.StatusForbidden},
+		{"admin-key", http.MethodPost, "/api/v1/bgp/blackholes", http.StatusOK},
+		{"admin-key", http.MethodGet, "/api/v1/auth/audit", http.StatusOK},
+		{"ops-key", http.MethodGet, "/api/v1/debug/runtime", http.StatusForbidden},
+		{"admin-key", http.MethodGet, "/api/v1/debug/runtime", http.StatusOK},
+		{"ops-key", http.MethodPut, "/api/v1/config/rate", http.StatusOK},
+		{"ops-key", http.MethodPut, "/api/v1/config/tcp_state_enable", http.StatusForbidden},
+		{"admin-key", http.MethodPut, "/api/v1/config/tcp_state_enable", http.StatusOK},
+		{"view-key", http.MethodDelete, "/api/v1/ratelimit/sources/198.51.100.7", http.StatusForbidden},
+		{"ops-key", http.MethodDelete, "/api/v1/ratelimit/sources/198.51.100.7", http.StatusOK},
+		{"ops-key", http.MethodGet, "/api/v1/config/revisions/3", http.StatusForbidden},
+		{"admin-key", http.MethodGet, "/api/v1/config/revisions/3", http.StatusOK},
+		{"ops-key", http.MethodPost, "/api/v1/config/rollback/3", http.StatusForbidden},
+		{"tenant-key", http.MethodGet, "/api/v1/assets", http.StatusOK},
+		{"tenant-key", http.MethodPut, "/api/v1/assets", http.StatusForbidden},
+		{"tenant-key", http.MethodGet, "/api/v1/status", http.StatusForbidden},
+		{"tenant-key", http.MethodGet, "/api/v1/auth/whoami", http.StatusOK},
+		{"tenant-key", http.MethodGet, "/api/v1/acl/blacklist", http.StatusOK},
+		{"tenant-key", http.MethodPost, "/api/v1/acl/blacklist", http.StatusForbidden},
+		{"tenant-key", http.MethodGet, "/api/v1/attacks/atk-1", http.StatusOK},
+		{"tenant-key", http.MethodGet, "/api/v1/bgp/blackholes", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.key != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.key)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s %s with %q: status = %d, want %d", tt.method, tt.path, tt.key, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestAuthWhoamiAndAudit(t *testing.T) {
+	s, h := newAuthServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/whoami", nil)
+	req.Header.Set("Authorization", "Bearer ops-key")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var who struct {
+		Identity string `json:"identity"`
+		Role     string `json:"role"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&who); err != nil {
+		t.Fatalf("decoding whoami: %v", err)
+	}
+	if who.Identity != "noc" || who.Role != "operator" {
+		t.Errorf("whoami = %+v, want noc/operator", who)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/bgp/blackholes", nil)
+	req.Header.Set("Authorization", "Bearer ops-key")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	audit := s.auth.auditLog()
+	if len(audit) != 1 {
+		t.Fatalf("audit entries = %d, want 1", len(audit))
+	}
+	if e := audit[0]; e.Identity != "noc" || e.Method != http.MethodDelete || e.Path != "/api/v1/bgp/blackholes" {
+		t.Errorf("audit entry = %+v", e)
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+)
+
+// handleBaseline reports the learned traffic baseline: the current rates
+// against it, the seasonal bucket in use, per-protocol baselines, pulse
+// detection and the rate limits adaptive mode would derive.
+func (s *Server) handleBaseline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.baseline == nil {
+		http.Error(w, "baseline not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	m := s.baseline.GetMetrics()
+	rates := s.baseline.GetAdaptiveRates()
+	pulse := s.baseline.GetPulse()
+
+	protocols := make([]map[string]interface{}, 0)
+	for _, p := range s.baseline.GetProtocolBaselines() {
+		protocols = append(protocols, map[string]interface{}{
+			"protocol":    p.Protocol,
+			"baselinePps": p.BaselinePPS,
+			"stdDevPps":   p.StdDevPPS,
+			"currentPps":  p.CurrentPPS,
+			"samples":     p.Samples,
+			"operational": p.Operational,
+		})
+	}
+	bursts := make([]map[string]interface{}, 0, len(pulse.Bursts))
+	for _, b := range pulse.Bursts {
+		bursts = append(bursts, map[string]interface{}{
+			"start":       formatTime(b.Start),
+			"durationSec": b.Duration.Seconds(),
+			"peakPps":     b.PeakPPS,
+		})
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"operational": s.baseline.IsOperational(),
+		"samples":     s.baseline.SampleCount(),
+		"metrics": map[string]interface{}{
+			"baselinePps":  m.BaselinePPS,
+			"baselineBps":  m.BaselineBPS,
+			"currentPps":   m.CurrentPPS,
+			"currentBps":   m.CurrentBPS,
+			"stdDevPps":    m.StdDevPPS,
+			"stdDevBps":    m.StdDevBPS,
+			"zScorePps":    m.ZScorePPS,
+			"zScoreBps":    m.ZScoreBPS,
+			"anomaly":      m.IsAnomaly,
+			"anomalyScore": m.AnomalyScore,
+			"pulseWave":    m.PulseWave,
+		},
+		"seasonal": map[string]interface{}{
+			"hour":        m.Seasonal.Hour,
+			"name":        m.Seasonal.Name,
+			"baselinePps": m.Seasonal.BaselinePPS,
+			"stdDevPps":   m.Seasonal.StdDevPPS,
+			"baselineBps": m.Seasonal.BaselineBPS,
+			"stdDevBps":   m.Seasonal.StdDevBPS,
+			"samples":     m.Seasonal.Samples,
+			"operational": m.Seasonal.Operational,
+		},
+		"adaptiveRates": map[string]interface{}{
+			"synPps":    rates.SynPPS,
+			"udpPps":    rates.UdpPPS,
+			"icmpPps":   rates.IcmpPPS,
+			"globalPps": rates.GlobalPPS,
+			"dnsPps":    rates.DnsPPS,
+		},
+		"protocols": protocols,
+		"pulse": map[string]interface{}{
+			"recognized":      pulse.Recognized,
+			"inBurst":         pulse.InBurst,
+			"intervalSec":     pulse.Interval.Seconds(),
+			"meanDurationSec": pulse.MeanDuration.Seconds(),
+			"meanPeakPps":     pulse.MeanPeakPPS,
+			"bursts":          bursts,
+		},
+	})
+}
+
+// handleBaselineReset discards the learned baseline, seasonal buckets
+// included, and restarts the learning period. Adaptive rate limits stay at
+// their last values until it completes.
+func (s *Server) handleBaselineReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.baseline == nil {
+		http.Error(w, "baseline not available", http.StatusServiceUnavailable)
+		return
+	}
+	s.baseline.Reset()
+	s.log.Info("baseline reset via API")
+	writeJSON(w, map[string]bool{"ok": true})
+}
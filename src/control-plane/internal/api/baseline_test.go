@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/baseline"
+	"go.uber.org/zap"
+)
+
+func TestBaseline(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	s.handleBaseline(rec, httptest.NewRequest(http.MethodGet, "/api/v1/baseline", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without baseline: status = %d, want 503", rec.Code)
+	}
+
+	b := baseline.NewBaseline(zap.NewNop(), nil)
+	for i := 0; i < 10; i++ {
+		b.Feed(1000, 8e6, 0)
+	}
+	s.SetBaseline(b)
+
+	rec = httptest.NewRecorder()
+	s.handleBaseline(rec, httptest.NewRequest(http.MethodGet, "/api/v1/baseline", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET: status = %d", rec.Code)
+	}
+	var got struct {
+		Operational bool `json:"operational"`
+		Samples     int  `json:"samples"`
+		Metrics     struct {
+			BaselinePps float64 `json:"baselinePps"`
+		} `json:"metrics"`
+		AdaptiveRates struct {
+			SynPps uint64 `json:"synPps"`
+		} `json:"adaptiveRates"`
+		Protocols []map[string]interface{} `json:"protocols"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Operational || got.Samples != 10 || got.Metrics.BaselinePps != 1000 || got.AdaptiveRates.SynPps != 3000 || len(got.Protocols) != 4 {
+		t.Errorf("baseline = %+v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleBaselineReset(rec, httptest.NewRequest(http.MethodGet, "/api/v1/baseline/reset", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET reset: status = %d, want 405", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.handleBaselineReset(rec, httptest.NewRequest(http.MethodPost, "/api/v1/baseline/reset", nil))
+	if rec.Code != http.StatusOK || b.SampleCount() != 0 {
+		t.Errorf("reset: status = %d, samples = %d", rec.Code, b.SampleCount())
+	}
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bgp"
+	"go.uber.org/zap"
+)
+
+// Audit log page sizes.
+const (
+	defaultBGPAuditLimit = 100
+	maxBGPAuditLimit     = 1000
+)
+
+// handleBGP reports the state of each BGP peer session.
+func (s *Server) handleBGP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.bgp == nil {
+		http.Error(w, "BGP not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	peers := s.bgp.Peers()
+	result := make([]map[string]interface{}, 0, len(peers))
+	for _, p := range peers {
+		result = append(result, map[string]interface{}{
+			"routerIp":   p.RouterIP,
+			"localAs":    p.LocalAS,
+			"peerAs":     p.PeerAS,
+			"connected":  p.Connected,
+			"since":      formatTime(p.Since),
+			"blackholes": p.Blackholes,
+			"flowspec":   p.Flowspec,
+		})
+	}
+	writeJSON(w, map[string]interface{}{"peers": result})
+}
+
+// handleBGPBlackholes lists (GET), announces (POST) or withdraws (DELETE)
+// RTBH blackholes.
+func (s *Server) handleBGPBlackholes(w http.ResponseWriter, r *http.Request) {
+	if s.bgp == nil {
+		http.Error(w, "BGP not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		result := make([]map[string]interface{}, 0)
+		for _, rule := range s.bgp.GetActiveRules() {
+			if rule.Action != "blackhole" {
+				continue
+			}
+			result = append(result, map[string]interface{}{
+				"prefix":      rule.DstPrefix,
+				"announcedAt": formatTime(rule.CreatedAt),
+				"expiresAt":   formatTime(rule.ExpiresAt),
+			})
+		}
+		writeJSON(w, map[string]interface{}{"blackholes": result})
+
+	case http.MethodPost:
+		var req struct {
+			Prefix string `json:"prefix"`
+			TTLSec uint64 `json:"ttlSec"` // 0 = configured default
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if s.fleetConflict(w) {
+			return
+		}
+		if !s.bgp.IsConnected() {
+			http.Error(w, "BGP session not established", http.StatusServiceUnavailable)
+			return
+		}
+		if err := s.bgp.AnnounceBlackhole(req.Prefix, time.Duration(req.TTLSec)*time.Second); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.log.Warn("blackhole announced via API", zap.String("prefix", req.Prefix))
+		writeJSON(w, map[string]bool{"ok": true})
+
+	case http.MethodDelete:
+		var req struct {
+			Prefix string `json:"prefix"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if !s.bgp.IsConnected() {
+			http.Error(w, "BGP session not established", http.StatusServiceUnavailable)
+			return
+		}
+		if err := s.bgp.WithdrawBlackhole(req.Prefix); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.log.Info("blackhole withdrawn via API", zap.String("prefix", req.Prefix))
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBGPFlowspec lists (GET), announces (POST) or withdraws (DELETE)
+// Flowspec rules. Rules use the bgp.FlowspecRule JSON form.
+func (s *Server) handleBGPFlowspec(w http.ResponseWriter, r *http.Request) {
+	if s.bgp == nil {
+		http.Error(w, "BGP not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rules := make([]bgp.FlowspecRule, 0)
+		for _, rule := range s.bgp.GetActiveRules() {
+			if rule.Action != "blackhole" {
+				rules = append(rules, rule)
+			}
+		}
+		writeJSON(w, map[string]interface{}{"rules": rules})
+
+	case http.MethodPost, http.MethodDelete:
+		var rule bgp.FlowspecRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodPost && s.fleetConflict(w) {
+			return
+		}
+		if !s.bgp.IsConnected() {
+			http.Error(w, "BGP session not established", http.StatusServiceUnavailable)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			if rule.Reason == "" {
+				rule.Reason = "manual: API"
+			}
+			if err := s.bgp.AnnounceFlowspec(rule); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.log.Warn("flowspec rule announced via API",
+				zap.String("src", rule.SrcPrefix), zap.String("dst", rule.DstPrefix))
+		} else {
+			if err := s.bgp.WithdrawFlowspec(rule); err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			s.log.Info("flowspec rule withdrawn via API",
+				zap.String("src", rule.SrcPrefix), zap.String("dst", rule.DstPrefix))
+		}
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBGPAudit pages through the BGP audit log, newest entry first.
+func (s *Server) handleBGPAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.bgp == nil {
+		http.Error(w, "BGP not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	q := r.URL.Query()
+	offset := 0
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+	limit := defaultBGPAuditLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxBGPAuditLimit {
+			http.Error(w, fmt.Sprintf("limit must be 1-%d", maxBGPAuditLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	audit := s.bgp.GetAuditLog()
+	entries := make([]map[string]interface{}, 0, limit)
+	for i := len(audit) - 1 - offset; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, map[string]interface{}{
+			"timestamp": formatTime(audit[i].Timestamp),
+			"action":    audit[i].Action,
+			"detail":    audit[i].Detail,
+		})
+	}
+	writeJSON(w, map[string]interface{}{
+		"total":   len(audit),
+		"offset":  offset,
+		"limit":   limit,
+		"entries": entries,
+	})
+}
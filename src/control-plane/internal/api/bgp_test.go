@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bgp"
+	"go.uber.org/zap"
+)
+
+func newBGPServer(t *testing.T) *Server {
+	t.Helper()
+	c := bgp.NewClient(zap.NewNop(), bgp.Config{
+		Enabled:            true,
+		RouterIP:           "192.0.2.1",
+		LocalAS:            64512,
+		PeerAS:             64513,
+		AuthorizedPrefixes: []string{"203.0.113.0/24"},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	s.SetBGP(c)
+	return s
+}
+
+func TestBGPBlackholes(t *testing.T) {
+	s := newBGPServer(t)
+	const path = "/api/v1/bgp/blackholes"
+
+	rec := httptest.NewRecorder()
+	s.handleBGPBlackholes(rec, httptest.NewRequest(http.MethodPost, path,
+		strings.NewReader(`{"prefix":"203.0.113.7/32","ttlSec":600}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST status = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleBGPBlackholes(rec, httptest.NewRequest(http.MethodPost, path,
+		strings.NewReader(`{"prefix":"192.0.2.0/24"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unauthorized POST status = %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleBGPBlackholes(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var list struct {
+		Blackholes []map[string]string `json:"blackholes"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Blackholes) != 1 || list.Blackholes[0]["prefix"] != "203.0.113.7/32" || list.Blackholes[0]["expiresAt"] == "" {
+		t.Errorf("blackholes = %v", list.Blackholes)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleBGPBlackholes(rec, httptest.NewRequest(http.MethodDelete, path,
+		strings.NewReader(`{"prefix":"203.0.113.7/32"}`)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("DELETE status = %d", rec.Code)
+	}
+
+	// Newest audit entry first: the withdrawal, then the rejection.
+	rec = httptest.NewRecorder()
+	s.handleBGPAudit(rec, httptest.NewRequest(http.MethodGet, "/api/v1/bgp/audit?limit=2", nil))
+	var audit struct {
+		Total   int                 `json:"total"`
+		Entries []map[string]string `json:"entries"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&audit); err != nil {
+		t.Fatal(err)
+	}
+	if audit.Total != 3 || len(audit.Entries) != 2 ||
+		audit.Entries[0]["action"] != "withdraw_blackhole" || audit.Entries[1]["action"] != "reject_blackhole" {
+		t.Errorf("audit = %+v", audit)
+	}
+}
+
+func TestBGPFlowspec(t *testing.T) {
+	s := newBGPServer(t)
+	const path = "/api/v1/bgp/flowspec"
+	rule := `{"dst_prefix":"203.0.113.0/24","protocol":"udp","src_port":"123","action":"drop"}`
+
+	rec := httptest.NewRecorder()
+	s.handleBGPFlowspec(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(rule)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST status = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleBGPFlowspec(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var list struct {
+		Rules []bgp.FlowspecRule `json:"rules"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Rules) != 1 || list.Rules[0].SrcPort != "123" {
+		t.Errorf("rules = %+v", list.Rules)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleBGPFlowspec(rec, httptest.NewRequest(http.MethodDelete, path, strings.NewReader(rule)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("DELETE status = %d", rec.Code)
+	}
+}
+
+func TestBGPUnavailable(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	rec := httptest.NewRecorder()
+	s.handleBGP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/bgp", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/compat"
+	"go.uber.org/zap"
+)
+
+// handleBPF reports the loaded XDP program: identity, verifier and
+// runtime statistics.
+func (s *Server) handleBPF(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.loader == nil {
+		http.Error(w, "BPF loader not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	st, err := s.loader.ProgramStats()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"id":            st.ID,
+		"name":          st.Name,
+		"tag":           st.Tag,
+		"object":        st.Object,
+		"verifiedInsns": st.VerifiedInsns,
+		"memlockBytes":  st.MemlockBytes,
+		"statsEnabled":  st.StatsEnabled,
+		"runCount":      st.RunCount,
+		"runTimeNs":     st.RunTime.Nanoseconds(),
+	})
+}
+
+// handleBPFMaps lists every loaded map with its size and memory use.
+func (s *Server) handleBPFMaps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.loader == nil {
+		http.Error(w, "BPF loader not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	maps, err := s.loader.MapStats()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var total uint64
+	result := make([]map[string]interface{}, 0, len(maps))
+	for _, m := range maps {
+		total += m.MemlockBytes
+		result = append(result, map[string]interface{}{
+			"name":         m.Name,
+			"id":           m.ID,
+			"type":         m.Type,
+			"keySize":      m.KeySize,
+			"valueSize":    m.ValueSize,
+			"maxEntries":   m.MaxEntries,
+			"entries":      m.Entries,
+			"memlockBytes": m.MemlockBytes,
+		})
+	}
+	writeJSON(w, map[string]interface{}{
+		"totalMemlockBytes": total,
+		"maps":              result,
+	})
+}
+
+// handleBPFDiagnostics reports kernel features missing on this host and
+// why the most recent program load failed, including the verifier log.
+func (s *Server) handleBPFDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.loader == nil {
+		http.Error(w, "BPF loader not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	f := compat.Detect()
+	missing := f.Missing()
+	if missing == nil {
+		missing = []compat.Feature{}
+	}
+	result := map[string]interface{}{
+		"kernel":          f.Kernel,
+		"arch":            f.Arch,
+		"object":          s.loader.ObjectPath(),
+		"missingFeatures": missing,
+		"lastLoadError":   nil,
+	}
+	if d := s.loader.LastLoadError(); d != nil {
+		log := d.VerifierLog
+		if log == nil {
+			log = []string{}
+		}
+		result["lastLoadError"] = map[string]interface{}{
+			"time":         formatTime(d.Time),
+			"object":       d.Object,
+			"error":        d.Error,
+			"verifierLog":  log,
+			"logLines":     d.LogLines,
+			"logTruncated": d.Truncated,
+		}
+	}
+	writeJSON(w, result)
+}
+
+// handleBPFReplace hot-swaps the XDP program for one loaded from another
+// object file, keeping the current maps.
+func (s *Server) handleBPFReplace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.loader == nil {
+		http.Error(w, "BPF loader not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		Object string `json:"object"` // Path of the object file on the scrubber host
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Object == "" {
+		http.Error(w, "object is required", http.StatusBadRequest)
+		return
+	}
+
+	previous := s.loader.ObjectPath()
+	if err := s.loader.Replace(req.Object); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.log.Warn("XDP program replaced via API",
+		zap.String("object", req.Object), zap.String("previous", previous))
+
+	result := map[string]interface{}{
+		"ok":       true,
+		"object":   req.Object,
+		"previous": previous,
+	}
+	if info, err := s.loader.ProgramInfo(); err == nil {
+		result["tag"] = info.Tag
+	}
+	writeJSON(w, result)
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+func TestBPFReplace(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	const path = "/api/v1/bpf/replace"
+
+	rec := httptest.NewRecorder()
+	s.handleBPFReplace(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"object":"x.o"}`)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without loader: status = %d, want 503", rec.Code)
+	}
+
+	s.SetLoader(bpf.NewLoader(zap.NewNop(), "xdp_scrubber.o"))
+	rec = httptest.NewRecorder()
+	s.handleBPFMaps(rec, httptest.NewRequest(http.MethodGet, "/api/v1/bpf/maps", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("maps before load: status = %d, want 500", rec.Code)
+	}
+
+	tests := []struct {
+		method string
+		body   string
+		want   int
+	}{
+		{http.MethodGet, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, `{}`, http.StatusBadRequest},
+		{http.MethodPost, `{"object":`, http.StatusBadRequest},
+		// Nothing is loaded yet, so there is no program to replace.
+		{http.MethodPost, `{"object":"xdp_scrubber_v2.o"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleBPFReplace(rec, httptest.NewRequest(tt.method, path, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s %q: status = %d, want %d", tt.method, tt.body, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestBPFDiagnostics(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	s.SetLoader(bpf.NewLoader(zap.NewNop(), "xdp_scrubber.o"))
+
+	rec := httptest.NewRecorder()
+	s.handleBPFDiagnostics(rec, httptest.NewRequest(http.MethodGet, "/api/v1/bpf/diagnostics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var res struct {
+		Kernel          string            `json:"kernel"`
+		MissingFeatures []json.RawMessage `json:"missingFeatures"`
+		LastLoadError   *json.RawMessage  `json:"lastLoadError"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if res.Kernel == "" || res.MissingFeatures == nil {
+		t.Errorf("diagnostics = %+v, want kernel and feature list", res)
+	}
+	if res.LastLoadError != nil {
+		t.Errorf("lastLoadError = %s before any load", *res.LastLoadError)
+	}
+}
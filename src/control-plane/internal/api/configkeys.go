@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+// configKeyToJSON describes a config map entry and its current value.
+func configKeyToJSON(k bpf.ConfigKey, raw uint64) map[string]interface{} {
+	m := map[string]interface{}{
+		"key":         k.Name,
+		"index":       k.Key,
+		"type":        k.Type,
+		"value":       k.Format(raw),
+		"description": k.Description,
+		"writable":    k.ManagedBy == "",
+	}
+	if k.Type == bpf.ConfigUint && k.Max > 0 {
+		m["min"] = k.Min
+		m["max"] = k.Max
+	}
+	if k.Type == bpf.ConfigEnum {
+		m["values"] = k.Values
+	}
+	if k.ManagedBy != "" {
+		m["managedBy"] = k.ManagedBy
+	}
+	return m
+}
+
+// handleConfigKeys lists every config map entry (GET /api/v1/config).
+func (s *Server) handleConfigKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.maps == nil {
+		http.Error(w, "BPF maps not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	keys := make([]map[string]interface{}, 0, len(bpf.ConfigKeys))
+	for _, k := range bpf.ConfigKeys {
+		raw, err := s.maps.GetConfig(k.Key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		keys = append(keys, configKeyToJSON(k, raw))
+	}
+	writeJSON(w, keys)
+}
+
+// handleConfigKey shows (GET) or changes (PUT {"value": ...}) one config
+// map entry by name, e.g. /api/v1/config/tcp_state_enable. Values are
+// typed: booleans, numbers within the key's range, enum names or
+// addresses. Entries kept in step with userspace state are read-only here.
+func (s *Server) handleConfigKey(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/api/v1/config/")
+	k, ok := bpf.LookupConfigKey(name)
+	if !ok {
+		http.Error(w, "unknown config key", http.StatusNotFound)
+		return
+	}
+	if s.maps == nil {
+		http.Error(w, "BPF maps not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		raw, err := s.maps.GetConfig(k.Key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, configKeyToJSON(k, raw))
+
+	case http.MethodPut:
+		if k.ManagedBy != "" {
+			http.Error(w, k.Name+" is managed by "+k.ManagedBy, http.StatusConflict)
+			return
+		}
+		var req struct {
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Value == nil {
+			http.Error(w, `expected {"value": ...}`, http.StatusBadRequest)
+			return
+		}
+		// UseNumber keeps 64-bit limits exact.
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(req.Value))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		raw, err := k.Parse(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if k.Key == bpf.CfgEnabled && s.maintenanceConflict(w) {
+			return
+		}
+		prev, _ := s.maps.GetConfig(k.Key)
+		if err := s.maps.SetConfig(k.Key, raw); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.log.Info("config key updated via API",
+			zap.String("key", k.Name),
+			zap.Any("from", k.Format(prev)),
+			zap.Any("to", k.Format(raw)),
+		)
+		writeJSON(w, configKeyToJSON(k, raw))
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestConfigKeysWithoutMaps(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	s.handleConfigKey(rec, httptest.NewRequest(http.MethodGet, "/api/v1/config/no_such_key", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown key status = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	body := strings.NewReader(`{"value":true}`)
+	s.handleConfigKey(rec, httptest.NewRequest(http.MethodPut, "/api/v1/config/tcp_state_enable", body))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("PUT status = %d, want 503", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleConfigKeys(rec, httptest.NewRequest(http.MethodPost, "/api/v1/config", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST list status = %d, want 405", rec.Code)
+	}
+}
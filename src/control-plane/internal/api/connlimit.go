@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+)
+
+// handleConnLimit reports the per-source connection limits and the sources
+// holding the most connections (?limit=N, default 20).
+func (s *Server) handleConnLimit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.maps == nil {
+		http.Error(w, "BPF maps not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	perSource, err := s.maps.GetConfig(bpf.CfgConnLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	limits, err := s.maps.ConnLimits()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	counts, err := s.maps.ConnCounts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	prefixes := make([]map[string]interface{}, 0, len(limits))
+	for p, l := range limits {
+		prefixes = append(prefixes, map[string]interface{}{"prefix": p, "limit": l})
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return prefixes[i]["prefix"].(string) < prefixes[j]["prefix"].(string)
+	})
+
+	if len(counts) > limit {
+		counts = counts[:limit]
+	}
+	sources := make([]map[string]interface{}, 0, len(counts))
+	for _, c := range counts {
+		sources = append(sources, map[string]interface{}{"ip": c.IP.String(), "conns": c.Conns})
+	}
+
+	result := map[string]interface{}{
+		"perSource": perSource,
+		"prefixes":  prefixes,
+		"sources":   sources,
+	}
+	if s.stats != nil {
+		if snap := s.stats.Current(); snap != nil {
+			result["dropped"] = snap.Stats.ConnLimitDropped
+		}
+	}
+	writeJSON(w, result)
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestConnLimitWithoutMaps(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+
+	for method, want := range map[string]int{
+		http.MethodGet:  http.StatusServiceUnavailable,
+		http.MethodPost: http.StatusMethodNotAllowed,
+	} {
+		rec := httptest.NewRecorder()
+		s.handleConnLimit(rec, httptest.NewRequest(method, "/api/v1/connlimit", nil))
+		if rec.Code != want {
+			t.Errorf("%s status = %d, want %d", method, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// debugPrefix is where admin-only diagnostics are served. requiredRole
+// demands admin for everything under it, reads included.
+const debugPrefix = "/api/v1/debug/"
+
+// pprofHandler serves the net/http/pprof profiles under
+// /api/v1/debug/pprof/. pprof resolves profile names relative to
+// /debug/pprof/, so the API prefix is stripped first.
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return http.StripPrefix("/api/v1", mux)
+}
+
+// handleDebugRuntime reports Go runtime state, how long the control
+// plane's heavier background work has been taking (BPF map walks and
+// threat intel feed syncs) and how the event reader is keeping up.
+func (s *Server) handleDebugRuntime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	var lastPause time.Duration
+	if ms.NumGC > 0 {
+		lastPause = time.Duration(ms.PauseNs[(ms.NumGC+255)%256])
+	}
+	var lastGC string
+	if ms.LastGC > 0 {
+		lastGC = formatTime(time.Unix(0, int64(ms.LastGC)))
+	}
+
+	iterations := []map[string]interface{}{}
+	if s.maps != nil {
+		for _, it := range s.maps.IterationStats() {
+			iterations = append(iterations, map[string]interface{}{
+				"map":       it.Map,
+				"count":     it.Count,
+				"lastMs":    durationMs(it.Last),
+				"maxMs":     durationMs(it.Max),
+				"meanMs":    durationMs(it.Total / time.Duration(it.Count)),
+				"lastRunAt": formatTime(it.At),
+			})
+		}
+	}
+
+	syncs := []map[string]interface{}{}
+	if s.threatIntel != nil {
+		for _, f := range s.threatIntel.GetFeeds() {
+			if !f.Enabled {
+				continue
+			}
+			syncs = append(syncs, map[string]interface{}{
+				"feed":       f.Name,
+				"lastSync":   formatTime(f.LastSync),
+				"durationMs": durationMs(f.SyncDuration),
+				"entries":    f.EntryCount,
+				"error":      f.Error,
+			})
+		}
+	}
+
+	var pipeline, ring interface{}
+	if s.events != nil {
+		pipeline = s.events.SamplingStats()
+		rs := s.events.RingStats()
+		ring = map[string]interface{}{
+			"sizeBytes":        rs.SizeBytes,
+			"backlogBytes":     rs.BacklogBytes,
+			"peakBacklogBytes": rs.PeakBacklogBytes,
+			"lost":             rs.Lost,
+			"lastLoss":         formatTime(rs.LastLoss),
+		}
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"goVersion":  runtime.Version(),
+		"goroutines": runtime.NumGoroutine(),
+		"numCpu":     runtime.NumCPU(),
+		"gomaxprocs": runtime.GOMAXPROCS(0),
+		"uptimeSec":  int64(time.Since(s.startTime).Seconds()),
+		"pprof":      s.cfg != nil && s.cfg.API.Pprof,
+		"heap": map[string]interface{}{
+			"allocBytes":      ms.HeapAlloc,
+			"inuseBytes":      ms.HeapInuse,
+			"idleBytes":       ms.HeapIdle,
+			"releasedBytes":   ms.HeapReleased,
+			"objects":         ms.HeapObjects,
+			"sysBytes":        ms.Sys,
+			"totalAllocBytes": ms.TotalAlloc,
+		},
+		"gc": map[string]interface{}{
+			"count":        ms.NumGC,
+			"forced":       ms.NumForcedGC,
+			"lastGc":       lastGC,
+			"lastPauseMs":  durationMs(lastPause),
+			"pauseTotalMs": durationMs(time.Duration(ms.PauseTotalNs)),
+			"cpuFraction":  ms.GCCPUFraction,
+			"nextGcBytes":  ms.NextGC,
+		},
+		"mapIterations": iterations,
+		"feedSyncs":     syncs,
+		"eventPipeline": pipeline,
+		"ringBuffer":    ring,
+	})
+}
+
+// durationMs renders d in milliseconds with microsecond precision.
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
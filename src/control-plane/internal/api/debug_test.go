@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestDebugRuntime(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	s.handleDebugRuntime(rec, httptest.NewRequest(http.MethodGet, "/api/v1/debug/runtime", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var got struct {
+		Goroutines int `json:"goroutines"`
+		Heap       struct {
+			AllocBytes uint64 `json:"allocBytes"`
+		} `json:"heap"`
+		MapIterations []interface{} `json:"mapIterations"`
+		FeedSyncs     []interface{} `json:"feedSyncs"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if got.Goroutines == 0 || got.Heap.AllocBytes == 0 {
+		t.Errorf("runtime = %+v, want goroutines and heap reported", got)
+	}
+	if got.MapIterations == nil || got.FeedSyncs == nil {
+		t.Error("durations should be empty lists without maps or feeds, not null")
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleDebugRuntime(rec, httptest.NewRequest(http.MethodPost, "/api/v1/debug/runtime", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status = %d, want 405", rec.Code)
+	}
+}
+
+func TestPprofHandler(t *testing.T) {
+	h := pprofHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/debug/pprof/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine") {
+		t.Errorf("index: status = %d, body lacks the goroutine profile", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/debug/pprof/goroutine?debug=1", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine profile:") {
+		t.Errorf("goroutine profile: status = %d, body %.80q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// handleDiversion reports (GET) the scrubbing-center diversion state or
+// switches (PUT) between automatic and manual diversion.
+func (s *Server) handleDiversion(w http.ResponseWriter, r *http.Request) {
+	if s.diversion == nil {
+		http.Error(w, "diversion not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeDiversion(w)
+
+	case http.MethodPut:
+		var req struct {
+			Mode string `json:"mode"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := s.diversion.SetMode(req.Mode); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.log.Warn("diversion mode set via API", zap.String("mode", req.Mode))
+		s.writeDiversion(w)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) writeDiversion(w http.ResponseWriter) {
+	st := s.diversion.Status()
+	prefixes := make([]map[string]interface{}, 0, len(st.Prefixes))
+	for _, p := range st.Prefixes {
+		prefixes = append(prefixes, map[string]interface{}{
+			"prefix":    p.Prefix,
+			"announced": p.Announced,
+			"tunnel":    p.Tunnel,
+			"endpoint":  p.Endpoint,
+			"error":     p.Error,
+		})
+	}
+	writeJSON(w, map[string]interface{}{
+		"mode":       st.Mode,
+		"active":     st.Active,
+		"since":      formatTime(st.Since),
+		"level":      st.Level.String(),
+		"startLevel": st.StartLevel.String(),
+		"holdUntil":  formatTime(st.HoldUntil),
+		"prefixes":   prefixes,
+	})
+}
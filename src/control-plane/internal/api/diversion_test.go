@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/diversion"
+	"go.uber.org/zap"
+)
+
+type fakeDiversionRoutes map[string]bool
+
+func (f fakeDiversionRoutes) AnnounceDiversion(prefix string) error { f[prefix] = true; return nil }
+func (f fakeDiversionRoutes) WithdrawDiversion(prefix string) error { delete(f, prefix); return nil }
+
+type fakeReinjector struct{}
+
+func (fakeReinjector) SetReinjection(bool, net.IP) error { return nil }
+
+func TestDiversion(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	const path = "/api/v1/diversion"
+
+	rec := httptest.NewRecorder()
+	s.handleDiversion(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without manager: status = %d, want 503", rec.Code)
+	}
+
+	routes := fakeDiversionRoutes{}
+	s.SetDiversion(diversion.NewManager(zap.NewNop(), diversion.Config{
+		Prefixes: []string{"203.0.113.0/24"},
+		LocalIP:  "192.0.2.254",
+	}, routes, fakeReinjector{}, nil))
+
+	rec = httptest.NewRecorder()
+	s.handleDiversion(rec, httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{"mode":"always"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid mode: status = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleDiversion(rec, httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{"mode":"on"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var res struct {
+		Mode     string `json:"mode"`
+		Active   bool   `json:"active"`
+		Prefixes []struct {
+			Announced bool   `json:"announced"`
+			Error     string `json:"error"`
+		} `json:"prefixes"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	// Without a GRE tunnel the prefix must not be announced.
+	if res.Mode != "on" || !res.Active || len(res.Prefixes) != 1 ||
+		res.Prefixes[0].Announced || res.Prefixes[0].Error == "" || len(routes) != 0 {
+		t.Errorf("response = %+v, routes = %v", res, routes)
+	}
+}
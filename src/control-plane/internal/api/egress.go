@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/egress"
+	"go.uber.org/zap"
+)
+
+// handleEgress reports the outbound scrubbing policy and what it dropped
+// (GET), and replaces the policy (PUT) until the next restart.
+func (s *Server) handleEgress(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if s.egress == nil {
+			writeJSON(w, map[string]bool{"enabled": false})
+			return
+		}
+		st, err := s.egress.Status()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, st)
+
+	case http.MethodPut:
+		if s.egress == nil {
+			http.Error(w, "egress scrubbing not enabled", http.StatusServiceUnavailable)
+			return
+		}
+		var req egress.Policy
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := s.egress.Apply(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.log.Info("egress policy updated via API",
+			zap.Bool("block_spoofed", req.BlockSpoofed),
+			zap.Bool("block_amplification", req.BlockAmplification),
+		)
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/egress"
+	"go.uber.org/zap"
+)
+
+// fakeEgressMap keeps the egress config keys and ignores the map entries.
+type fakeEgressMap map[uint32]uint64
+
+func (f fakeEgressMap) SetConfig(key uint32, value uint64) error {
+	f[key] = value
+	return nil
+}
+
+func (f fakeEgressMap) SetEgressSources([]string) error           { return nil }
+func (f fakeEgressMap) SetEgressAmpPorts(map[uint16]uint64) error { return nil }
+
+func (f fakeEgressMap) ReadEgressStats() (bpf.EgressStats, error) {
+	return bpf.EgressStats{Packets: 100, AmpDropped: 7}, nil
+}
+
+func TestEgress(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	const path = "/api/v1/egress"
+
+	rec := httptest.NewRecorder()
+	s.handleEgress(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"enabled":false`) {
+		t.Errorf("disabled: %d %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	s.handleEgress(rec, httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{}`)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("disabled PUT: status = %d, want 503", rec.Code)
+	}
+
+	m := fakeEgressMap{}
+	mg := egress.NewManager(zap.NewNop(), m)
+	mg.SetAttachment(bpf.EgressTCX, "eth0")
+	s.SetEgress(mg)
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"blockSpoofed":true}`, http.StatusBadRequest},
+		{`{"blockAmplification":true,"ampPorts":{"11211":0,"123":100}}`, http.StatusOK},
+		{`{"blockAmplification":true,"ampPorts":{"123":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleEgress(rec, httptest.NewRequest(http.MethodPut, path, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("PUT %s: status = %d, want %d", tt.body, rec.Code, tt.want)
+		}
+	}
+	if m[bpf.CfgEgressAmp] != 1 || m[bpf.CfgEgressSpoof] != 0 {
+		t.Errorf("config = %v, want only egress_amp on", m)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleEgress(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var st egress.Status
+	if err := json.NewDecoder(rec.Body).Decode(&st); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if st.Attachment != bpf.EgressTCX || st.AmpPorts[123] != 100 || st.Stats.AmpDropped != 7 {
+		t.Errorf("status = %+v", st)
+	}
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"go.uber.org/zap"
+)
+
+// Escalation history page sizes.
+const (
+	defaultEscalationHistoryLimit = 50
+	maxEscalationHistoryLimit     = 1000
+)
+
+// maxEscalationReasonLen caps the reason recorded for a manual override.
+const maxEscalationReasonLen = 256
+
+// handleEscalationHistory pages through the escalation transitions, newest
+// first, with the triggers active at each.
+func (s *Server) handleEscalationHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.escalation == nil {
+		http.Error(w, "escalation engine not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	q := r.URL.Query()
+	offset := 0
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+	limit := defaultEscalationHistoryLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxEscalationHistoryLimit {
+			http.Error(w, fmt.Sprintf("limit must be 1-%d", maxEscalationHistoryLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	history := s.escalation.GetHistory()
+	entries := make([]map[string]interface{}, 0, limit)
+	for i := len(history) - 1 - offset; i >= 0 && len(entries) < limit; i-- {
+		ev := history[i]
+		entries = append(entries, map[string]interface{}{
+			"timestamp": formatTime(ev.Timestamp),
+			"from":      ev.FromLevel.String(),
+			"to":        ev.ToLevel.String(),
+			"reason":    ev.Reason,
+			"triggers":  triggersToJSON(ev.Triggers, true),
+		})
+	}
+	writeJSON(w, map[string]interface{}{
+		"total":   len(history),
+		"offset":  offset,
+		"limit":   limit,
+		"entries": entries,
+	})
+}
+
+// handleEscalationLevel overrides the escalation level (PUT). The body
+// names the level, e.g. {"level":"high","reason":"customer-reported
+// outage"}; the reason is required and recorded in the history.
+func (s *Server) handleEscalationLevel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.maps == nil {
+		http.Error(w, "BPF maps not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		Level  string `json:"level"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	level, ok := escalation.ParseLevel(req.Level)
+	if !ok {
+		http.Error(w, "level must be low, medium, high or critical", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		http.Error(w, "reason required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Reason) > maxEscalationReasonLen {
+		http.Error(w, fmt.Sprintf("reason longer than %d bytes", maxEscalationReasonLen), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.setEscalationLevel(r, level, req.Reason); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"level": int(level),
+		"name":  level.String(),
+	})
+}
+
+// handleEscalationPin pins the escalation level during a known incident
+// (PUT) or removes the pin (DELETE). The body names the floor, how long it
+// holds and why, e.g. {"level":"high","durationSec":3600,"reason":"ongoing
+// carpet bombing"}; durationSec 0 holds it until removed.
+func (s *Server) handleEscalationPin(w http.ResponseWriter, r *http.Request) {
+	if s.escalation == nil {
+		http.Error(w, "escalation engine not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var req struct {
+			Level       string `json:"level"`
+			DurationSec uint64 `json:"durationSec"`
+			Reason      string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		level, ok := escalation.ParseLevel(req.Level)
+		if !ok || level == escalation.Low {
+			http.Error(w, "level must be medium, high or critical", http.StatusBadRequest)
+			return
+		}
+		req.Reason = strings.TrimSpace(req.Reason)
+		if req.Reason == "" {
+			http.Error(w, "reason required", http.StatusBadRequest)
+			return
+		}
+		if len(req.Reason) > maxEscalationReasonLen {
+			http.Error(w, fmt.Sprintf("reason longer than %d bytes", maxEscalationReasonLen), http.StatusBadRequest)
+			return
+		}
+
+		reason := req.Reason
+		if id, ok := requestIdentity(r); ok {
+			reason = "by " + id.Name + ": " + reason
+		}
+		if err := s.escalation.PinLevel(level, time.Duration(req.DurationSec)*time.Second, reason); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pin, _ := s.escalation.GetPin()
+		writeJSON(w, pinToJSON(pin))
+
+	case http.MethodDelete:
+		writeJSON(w, map[string]bool{"unpinned": s.escalation.Unpin()})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// pinToJSON converts an escalation pin; until is omitted for a pin held
+// until removed.
+func pinToJSON(p escalation.Pin) map[string]interface{} {
+	out := map[string]interface{}{
+		"level":  p.Level.String(),
+		"reason": p.Reason,
+		"since":  formatTime(p.Since),
+	}
+	if !p.Until.IsZero() {
+		out["until"] = formatTime(p.Until)
+	}
+	return out
+}
+
+// prefixLevelsToJSON converts the levels of escalated protected prefixes.
+func prefixLevelsToJSON(levels []escalation.PrefixLevel) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(levels))
+	for _, pl := range levels {
+		out = append(out, map[string]interface{}{
+			"prefix":    pl.Prefix,
+			"level":     pl.Level.String(),
+			"since":     formatTime(pl.Since),
+			"dropRatio": pl.DropRatio,
+			"dropPps":   pl.DropPPS,
+		})
+	}
+	return out
+}
+
+// setEscalationLevel applies a manual override to the data plane and
+// reports it to the engine with the caller and reason.
+func (s *Server) setEscalationLevel(r *http.Request, level escalation.Level, reason string) error {
+	prev, _ := s.maps.GetConfig(bpf.CfgEscalationLevel)
+	if err := s.maps.SetConfig(bpf.CfgEscalationLevel, uint64(level)); err != nil {
+		return err
+	}
+
+	audit := "set via API"
+	if id, ok := requestIdentity(r); ok {
+		audit += " by " + id.Name
+	}
+	if reason != "" {
+		audit += ": " + reason
+	}
+	s.log.Warn("escalation level set via API",
+		zap.String("level", level.String()),
+		zap.String("reason", audit))
+	s.BroadcastEscalation(escalation.Level(prev), level)
+	if s.onEscalationChange != nil && escalation.Level(prev) != level {
+		s.onEscalationChange(escalation.Level(prev), level, audit)
+	}
+	return nil
+}
+
+// triggersToJSON converts escalation triggers, only the active ones if
+// activeOnly is set.
+func triggersToJSON(triggers []escalation.TriggerState, activeOnly bool) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(triggers))
+	for _, t := range triggers {
+		if activeOnly && !t.Active {
+			continue
+		}
+		out = append(out, map[string]interface{}{
+			"name":      t.Name,
+			"current":   t.Current,
+			"threshold": t.Threshold,
+			"active":    t.Active,
+		})
+	}
+	return out
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"go.uber.org/zap"
+)
+
+// fakeConfigTable stores config map writes.
+type fakeConfigTable map[uint32]uint64
+
+func (f fakeConfigTable) Lookup(key, valueOut interface{}) error {
+	*valueOut.(*uint64) = f[key.(uint32)]
+	return nil
+}
+
+func (f fakeConfigTable) Update(key, value interface{}, _ ebpf.MapUpdateFlags) error {
+	f[key.(uint32)] = value.(uint64)
+	return nil
+}
+
+func TestEscalationHistory(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	const path = "/api/v1/escalation/history"
+
+	rec := httptest.NewRecorder()
+	s.handleEscalationHistory(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without engine: status = %d, want 503", rec.Code)
+	}
+
+	e := escalation.NewEngine(zap.NewNop(), fakeConfigTable{})
+	e.Evaluate(context.Background(), escalation.Metrics{RxPPS: 1000, DropPPS: 400, DropRatio: 0.4}) // LOW -> HIGH
+	for i := 0; i < 3; i++ {
+		e.Evaluate(context.Background(), escalation.Metrics{RxPPS: 1000}) // HIGH -> MEDIUM
+	}
+	s.SetEscalation(e)
+
+	rec = httptest.NewRecorder()
+	s.handleEscalationHistory(rec, httptest.NewRequest(http.MethodGet, path+"?limit=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET: status = %d", rec.Code)
+	}
+	var page struct {
+		Total   int `json:"total"`
+		Entries []struct {
+			From     string                   `json:"from"`
+			To       string                   `json:"to"`
+			Triggers []map[string]interface{} `json:"triggers"`
+		} `json:"entries"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || len(page.Entries) != 1 || page.Entries[0].From != "HIGH" || page.Entries[0].To != "MEDIUM" {
+		t.Errorf("first page = %+v", page)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleEscalationHistory(rec, httptest.NewRequest(http.MethodGet, path+"?offset=1", nil))
+	page.Entries = nil
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Entries) != 1 || page.Entries[0].To != "HIGH" || len(page.Entries[0].Triggers) != 1 ||
+		page.Entries[0].Triggers[0]["name"] != "drop_ratio" {
+		t.Errorf("second page = %+v", page)
+	}
+
+	for _, q := range []string{"?limit=0", "?limit=5000", "?offset=-1"} {
+		rec = httptest.NewRecorder()
+		s.handleEscalationHistory(rec, httptest.NewRequest(http.MethodGet, path+q, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", q, rec.Code)
+		}
+	}
+}
+
+func TestEscalationLevelMethod(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		rec := httptest.NewRecorder()
+		body := strings.NewReader(fmt.Sprintf(`{"level":"high","reason":"%s test"}`, method))
+		s.handleEscalationLevel(rec, httptest.NewRequest(method, "/api/v1/escalation/level", body))
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s: status = %d, want 405", method, rec.Code)
+		}
+	}
+}
+
+func TestEscalationPin(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	const path = "/api/v1/escalation/pin"
+	e := escalation.NewEngine(zap.NewNop(), fakeConfigTable{})
+	s.SetEscalation(e)
+
+	for _, body := range []string{
+		`{"level":"low","reason":"x"}`,
+		`{"level":"high"}`,
+		`{"level":"extreme","reason":"x"}`,
+	} {
+		rec := httptest.NewRecorder()
+		s.handleEscalationPin(rec, httptest.NewRequest(http.MethodPut, path, strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", body, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	body := `{"level":"high","durationSec":600,"reason":"ongoing incident"}`
+	s.handleEscalationPin(rec, httptest.NewRequest(http.MethodPut, path, strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT: status = %d: %s", rec.Code, rec.Body)
+	}
+	var pin map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&pin); err != nil {
+		t.Fatal(err)
+	}
+	if pin["level"] != "HIGH" || pin["reason"] != "ongoing incident" || pin["until"] == nil {
+		t.Errorf("pin = %v", pin)
+	}
+	if e.GetLevel() != escalation.High {
+		t.Errorf("level = %s, want HIGH", e.GetLevel())
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleEscalationPin(rec, httptest.NewRequest(http.MethodDelete, path, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"unpinned":true`) {
+		t.Errorf("DELETE: %d %s", rec.Code, rec.Body)
+	}
+	if _, ok := e.GetPin(); ok {
+		t.Error("still pinned")
+	}
+}
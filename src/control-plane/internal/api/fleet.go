@@ -0,0 +1,30 @@
+package api
+
+import "net/http"
+
+// handleFleet shows the local view of the fleet election (GET).
+func (s *Server) handleFleet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.fleet == nil {
+		writeJSON(w, map[string]bool{"enabled": false})
+		return
+	}
+	writeJSON(w, s.fleet.Status())
+}
+
+// fleetConflict rejects BGP announcements on fleet followers: only the
+// leader announces, so that sites don't repeat each other's routes.
+func (s *Server) fleetConflict(w http.ResponseWriter) bool {
+	if s.fleet == nil || s.fleet.IsLeader() {
+		return false
+	}
+	msg := "not the fleet leader; no leader is elected"
+	if leader := s.fleet.Status().Leader; leader != "" {
+		msg = "not the fleet leader; announce from " + leader
+	}
+	http.Error(w, msg, http.StatusConflict)
+	return true
+}
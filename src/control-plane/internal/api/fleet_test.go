@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/fleet"
+	"go.uber.org/zap"
+)
+
+func TestFleetFollowerRejectsAnnouncements(t *testing.T) {
+	s := newBGPServer(t)
+
+	rec := httptest.NewRecorder()
+	s.handleFleet(rec, httptest.NewRequest(http.MethodGet, "/api/v1/fleet", nil))
+	if !strings.Contains(rec.Body.String(), `"enabled":false`) {
+		t.Errorf("fleet disabled: GET = %s", rec.Body)
+	}
+
+	cfg := fleet.DefaultConfig()
+	cfg.NodeID = "pop-ams"
+	cfg.Peers = []string{"127.0.0.1:1"}
+	cfg.TLS.Insecure = true
+	f, err := fleet.New(zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetFleet(f)
+
+	rec = httptest.NewRecorder()
+	s.handleFleet(rec, httptest.NewRequest(http.MethodGet, "/api/v1/fleet", nil))
+	var st fleet.Status
+	if err := json.NewDecoder(rec.Body).Decode(&st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Node != "pop-ams" || st.IsLeader || st.Size != 2 {
+		t.Errorf("fleet status = %+v", st)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleBGPBlackholes(rec, httptest.NewRequest(http.MethodPost, "/api/v1/bgp/blackholes",
+		strings.NewReader(`{"prefix":"203.0.113.7/32"}`)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("follower blackhole POST status = %d, want 409", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.handleBGPFlowspec(rec, httptest.NewRequest(http.MethodPost, "/api/v1/bgp/flowspec",
+		strings.NewReader(`{"srcPrefix":"198.51.100.7/32","dstPrefix":"203.0.113.0/24","action":"drop"}`)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("follower flowspec POST status = %d, want 409", rec.Code)
+	}
+}
@@ -0,0 +1,325 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/geoip"
+	"go.uber.org/zap"
+)
+
+// handleGeoIPDatabase reports the loaded country data and the progress of
+// a load (GET), loads data (POST) or cancels a load in progress (DELETE). A
+// POST naming no database reloads the current one, picking up a file
+// updated in place.
+func (s *Server) handleGeoIPDatabase(w http.ResponseWriter, r *http.Request) {
+	if s.geoip == nil {
+		http.Error(w, "geoip data not loaded", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		info := geoipDatabaseToJSON(s.geoip.GetDatabaseInfo())
+		if p, ok := s.geoip.GetLoadProgress(); ok {
+			info["load"] = map[string]interface{}{
+				"database": p.Database,
+				"phase":    p.Phase,
+				"started":  formatTime(p.Started),
+				"read":     p.Read,
+				"changes":  p.Changes,
+				"applied":  p.Applied,
+				"added":    p.Added,
+				"changed":  p.Changed,
+				"removed":  p.Removed,
+				"percent":  p.Percent,
+				"rate":     p.Rate,
+				"etaSec":   int64(p.ETA.Seconds()),
+			}
+		}
+		writeJSON(w, info)
+
+	case http.MethodPost:
+		var req struct {
+			Database  string `json:"database"`
+			Locations string `json:"locations"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid JSON", http.StatusBadRequest)
+				return
+			}
+		}
+		start := time.Now()
+		var err error
+		if req.Database == "" {
+			err = s.geoip.Reload()
+		} else {
+			err = s.geoip.Load(req.Database, req.Locations)
+		}
+		if errors.Is(err, geoip.ErrLoading) || errors.Is(err, geoip.ErrLoadCanceled) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		info := s.geoip.GetDatabaseInfo()
+		s.log.Info("geoip data loaded via API",
+			zap.String("database", info.Database),
+			zap.Int("prefixes", info.Prefixes),
+			zap.Duration("took", time.Since(start)),
+			zap.String("by", requester(r)),
+		)
+		writeJSON(w, geoipDatabaseToJSON(info))
+
+	case http.MethodDelete:
+		if !s.geoip.CancelLoad() {
+			http.Error(w, "no geoip data load in progress", http.StatusNotFound)
+			return
+		}
+		s.log.Info("geoip data load canceled via API", zap.String("by", requester(r)))
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func geoipDatabaseToJSON(info geoip.DatabaseInfo) map[string]interface{} {
+	return map[string]interface{}{
+		"database":  info.Database,
+		"locations": info.Locations,
+		"loadedAt":  formatTime(info.LoadedAt),
+		"prefixes":  info.Prefixes,
+	}
+}
+
+// handleGeoIPPolicies lists (GET), sets (POST) or removes (DELETE)
+// per-country policies, or with continent instead of country the policy of
+// a continent or region. A POST with ttlSec sets a temporary country
+// policy that reverts when it expires; a DELETE with temporary clears one
+// early.
+func (s *Server) handleGeoIPPolicies(w http.ResponseWriter, r *http.Request) {
+	if s.geoip == nil {
+		http.Error(w, "geoip data not loaded", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		names := func(policies map[string]uint8) map[string]string {
+			out := make(map[string]string, len(policies))
+			for cc, action := range policies {
+				out[cc] = geoip.ActionName(action)
+			}
+			return out
+		}
+		temp := s.geoip.GetTempPolicies()
+		temporary := make([]map[string]interface{}, 0, len(temp))
+		for _, t := range temp {
+			temporary = append(temporary, map[string]interface{}{
+				"country": t.Country,
+				"action":  geoip.ActionName(t.Action),
+				"expires": formatTime(t.Expires),
+				"reason":  t.Reason,
+			})
+		}
+		writeJSON(w, map[string]interface{}{
+			"policies":   names(s.geoip.GetCountryPolicy()),
+			"continents": names(s.geoip.GetContinentPolicies()),
+			"regions":    s.geoip.GetRegions(),
+			"active":     names(s.geoip.GetActivePolicy()),
+			"temporary":  temporary,
+			"rateLimits": s.geoip.GetCountryRates(),
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Country   string `json:"country"`
+			Continent string `json:"continent"`
+			Action    string `json:"action"`
+			TTLSec    int64  `json:"ttlSec"`
+			Reason    string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		action, err := geoip.ParseAction(req.Action)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.TTLSec < 0 {
+			http.Error(w, "ttlSec must not be negative", http.StatusBadRequest)
+			return
+		}
+		if req.Continent != "" {
+			if req.Country != "" || req.TTLSec > 0 {
+				http.Error(w, "continent excludes country and ttlSec", http.StatusBadRequest)
+				return
+			}
+			if err := s.geoip.SetContinentPolicy(req.Continent, action); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.log.Info("geoip continent policy set via API",
+				zap.String("continent", req.Continent),
+				zap.String("action", req.Action),
+				zap.String("by", requester(r)),
+			)
+			writeJSON(w, map[string]bool{"ok": true})
+			return
+		}
+		if req.TTLSec > 0 {
+			err = s.geoip.SetCountryPolicyFor(req.Country, action, time.Duration(req.TTLSec)*time.Second, req.Reason)
+		} else {
+			err = s.geoip.SetCountryPolicy(req.Country, action)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.log.Info("geoip policy set via API",
+			zap.String("country", req.Country),
+			zap.String("action", req.Action),
+			zap.Int64("ttl_sec", req.TTLSec),
+			zap.String("by", requester(r)),
+		)
+		writeJSON(w, map[string]bool{"ok": true})
+
+	case http.MethodDelete:
+		var req struct {
+			Country   string `json:"country"`
+			Continent string `json:"continent"`
+			Temporary bool   `json:"temporary"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Continent != "" {
+			if err := s.geoip.RemoveContinentPolicy(req.Continent); err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			s.log.Info("geoip continent policy removed via API",
+				zap.String("continent", req.Continent),
+				zap.String("by", requester(r)),
+			)
+			writeJSON(w, map[string]bool{"ok": true})
+			return
+		}
+		var err error
+		if req.Temporary {
+			err = s.geoip.ClearTempCountryPolicy(req.Country)
+		} else {
+			err = s.geoip.RemoveCountryPolicy(req.Country)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.log.Info("geoip policy removed via API",
+			zap.String("country", req.Country),
+			zap.Bool("temporary", req.Temporary),
+			zap.String("by", requester(r)),
+		)
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleGeoIPLookup reports the country an address maps to and the policy
+// in force for it.
+func (s *Server) handleGeoIPLookup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.geoip == nil {
+		http.Error(w, "geoip data not loaded", http.StatusServiceUnavailable)
+		return
+	}
+
+	ip := net.ParseIP(r.URL.Query().Get("ip")).To4()
+	if ip == nil {
+		http.Error(w, "ip must be an IPv4 address", http.StatusBadRequest)
+		return
+	}
+	country := s.geoip.LookupCountry(ip)
+	action := "pass"
+	if a, ok := s.geoip.GetActivePolicy()[country]; ok && country != "" {
+		action = geoip.ActionName(a)
+	}
+	writeJSON(w, map[string]interface{}{
+		"ip":        ip.String(),
+		"country":   country,
+		"continent": s.geoip.GetContinent(country),
+		"action":    action,
+	})
+}
+
+// handleGeoIPStats reports the per-country counters of the data plane,
+// most dropped first.
+func (s *Server) handleGeoIPStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.geoip == nil {
+		http.Error(w, "geoip data not loaded", http.StatusServiceUnavailable)
+		return
+	}
+
+	stats, err := s.geoip.GetCountryStats()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].Drops+stats[i].RateLimited > stats[j].Drops+stats[j].RateLimited
+	})
+	countries := make([]map[string]interface{}, 0, len(stats))
+	for _, cs := range stats {
+		countries = append(countries, map[string]interface{}{
+			"country":     cs.Country,
+			"packets":     cs.Packets,
+			"dropped":     cs.Drops,
+			"rateLimited": cs.RateLimited,
+			"monitored":   cs.Monitored,
+		})
+	}
+	writeJSON(w, map[string]interface{}{"countries": countries})
+}
+
+// handleGeoIPAudit returns the country policy audit trail, newest first.
+func (s *Server) handleGeoIPAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.geoip == nil {
+		http.Error(w, "geoip data not loaded", http.StatusServiceUnavailable)
+		return
+	}
+
+	log := s.geoip.GetAuditLog()
+	entries := make([]map[string]interface{}, 0, len(log))
+	for i := len(log) - 1; i >= 0; i-- {
+		entries = append(entries, map[string]interface{}{
+			"timestamp": formatTime(log[i].Timestamp),
+			"action":    log[i].Action,
+			"detail":    log[i].Detail,
+		})
+	}
+	writeJSON(w, map[string]interface{}{"entries": entries})
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/geoip"
+	"go.uber.org/zap"
+)
+
+func TestGeoIPEndpoints(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	s.handleGeoIPPolicies(rec, httptest.NewRequest(http.MethodGet, "/api/v1/geoip/policies", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without manager: status = %d, want 503", rec.Code)
+	}
+
+	// Requests that fail validation never reach the BPF maps, so the
+	// manager needs none here.
+	s.SetGeoIP(geoip.NewManager(zap.NewNop(), nil, nil, nil, nil, nil))
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		path    string
+		body    string
+		want    int
+	}{
+		{"list policies", s.handleGeoIPPolicies, http.MethodGet, "/api/v1/geoip/policies", "", http.StatusOK},
+		{"invalid action", s.handleGeoIPPolicies, http.MethodPost, "/api/v1/geoip/policies", `{"country":"CN","action":"tarpit"}`, http.StatusBadRequest},
+		{"invalid country", s.handleGeoIPPolicies, http.MethodPost, "/api/v1/geoip/policies", `{"country":"CHN","action":"drop"}`, http.StatusBadRequest},
+		{"negative ttl", s.handleGeoIPPolicies, http.MethodPost, "/api/v1/geoip/policies", `{"country":"CN","action":"drop","ttlSec":-1}`, http.StatusBadRequest},
+		{"remove missing policy", s.handleGeoIPPolicies, http.MethodDelete, "/api/v1/geoip/policies", `{"country":"CN"}`, http.StatusNotFound},
+		{"clear missing temporary policy", s.handleGeoIPPolicies, http.MethodDelete, "/api/v1/geoip/policies", `{"country":"CN","temporary":true}`, http.StatusNotFound},
+		{"unknown continent", s.handleGeoIPPolicies, http.MethodPost, "/api/v1/geoip/policies", `{"continent":"APAC","action":"rate_limit"}`, http.StatusBadRequest},
+		{"continent with ttl", s.handleGeoIPPolicies, http.MethodPost, "/api/v1/geoip/policies", `{"continent":"AS","action":"drop","ttlSec":60}`, http.StatusBadRequest},
+		{"remove missing continent policy", s.handleGeoIPPolicies, http.MethodDelete, "/api/v1/geoip/policies", `{"continent":"EU"}`, http.StatusNotFound},
+		{"lookup without ip", s.handleGeoIPLookup, http.MethodGet, "/api/v1/geoip/lookup", "", http.StatusBadRequest},
+		{"lookup IPv6", s.handleGeoIPLookup, http.MethodGet, "/api/v1/geoip/lookup?ip=2001:db8::1", "", http.StatusBadRequest},
+		{"database", s.handleGeoIPDatabase, http.MethodGet, "/api/v1/geoip/database", "", http.StatusOK},
+		{"reload with nothing loaded", s.handleGeoIPDatabase, http.MethodPost, "/api/v1/geoip/database", "", http.StatusBadRequest},
+		{"load missing file", s.handleGeoIPDatabase, http.MethodPost, "/api/v1/geoip/database", `{"database":"/nonexistent/GeoLite2-Country.mmdb"}`, http.StatusBadRequest},
+		{"load CSV without locations", s.handleGeoIPDatabase, http.MethodPost, "/api/v1/geoip/database", `{"database":"/tmp/blocks.csv"}`, http.StatusBadRequest},
+		{"cancel without load", s.handleGeoIPDatabase, http.MethodDelete, "/api/v1/geoip/database", "", http.StatusNotFound},
+		{"audit", s.handleGeoIPAudit, http.MethodGet, "/api/v1/geoip/audit", "", http.StatusOK},
+		{"stats method", s.handleGeoIPStats, http.MethodPost, "/api/v1/geoip/stats", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		tt.handler(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleGeoIPPolicies(rec, httptest.NewRequest(http.MethodGet, "/api/v1/geoip/policies", nil))
+	var res struct {
+		Policies  map[string]string        `json:"policies"`
+		Active    map[string]string        `json:"active"`
+		Temporary []map[string]interface{} `json:"temporary"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Policies == nil || res.Active == nil || res.Temporary == nil {
+		t.Errorf("policies = %+v, want empty lists rather than null", res)
+	}
+}
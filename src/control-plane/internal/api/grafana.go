@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/stats"
+)
+
+// queryPaths take POST bodies but only read data, so viewers may use them
+// and they keep working while the server drains.
+var queryPaths = map[string]bool{
+	"/api/v1/query":  true,
+	"/api/v1/search": true,
+}
+
+// grafanaSearch is the body of a Grafana JSON datasource metric search.
+type grafanaSearch struct {
+	Target string `json:"target"`
+}
+
+// grafanaQuery is the body of a Grafana JSON datasource query.
+type grafanaQuery struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	IntervalMS int64 `json:"intervalMs"`
+	Targets    []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		Hide   bool   `json:"hide"`
+	} `json:"targets"`
+}
+
+// grafanaSeries is one time series in a query response. Datapoints are
+// [value, unix milliseconds] pairs.
+type grafanaSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// handleDatasourceTest answers the connection test of the Grafana JSON
+// datasource, which requests the datasource URL (/api/v1/) itself.
+func (s *Server) handleDatasourceTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// handleSearch lists the stats history series whose names contain the
+// searched text, for the Grafana JSON datasource metric picker.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req grafanaSearch
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	needle := strings.ToLower(req.Target)
+	names := []string{}
+	for _, name := range stats.SeriesNames() {
+		if strings.Contains(strings.ToLower(name), needle) {
+			names = append(names, name)
+		}
+	}
+	writeJSON(w, names)
+}
+
+// handleQuery returns stats history series in the Grafana JSON datasource
+// time series format. The dashboard interval becomes the step, raised as
+// needed to fit the history resolution and MaxHistoryPoints.
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.history == nil {
+		http.Error(w, "stats history not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req grafanaQuery
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	var empty stats.Point
+	for _, t := range req.Targets {
+		if _, ok := empty.Value(t.Target); !ok && !t.Hide && t.Target != "" {
+			http.Error(w, "unknown target "+t.Target, http.StatusBadRequest)
+			return
+		}
+	}
+	window := req.Range.To.Sub(req.Range.From)
+	if window <= 0 {
+		http.Error(w, "range.to must be after range.from", http.StatusBadRequest)
+		return
+	}
+	step := time.Duration(req.IntervalMS) * time.Millisecond
+	if step > 0 && window/step > stats.MaxHistoryPoints {
+		step = 0
+	}
+
+	points, _, err := s.history.Query(window, step, req.Range.To)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result := make([]grafanaSeries, 0, len(req.Targets))
+	for _, t := range req.Targets {
+		if t.Hide || t.Target == "" {
+			continue
+		}
+		series := grafanaSeries{Target: t.Target, Datapoints: make([][2]float64, 0, len(points))}
+		for i := range points {
+			v, _ := points[i].Value(t.Target)
+			series.Datapoints = append(series.Datapoints, [2]float64{v, float64(points[i].Timestamp.UnixMilli())})
+		}
+		result = append(result, series)
+	}
+	writeJSON(w, result)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/stats"
+	"go.uber.org/zap"
+)
+
+func TestGrafanaSearch(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	s.handleSearch(rec, httptest.NewRequest(http.MethodPost, "/api/v1/search", strings.NewReader(`{"target":"drop"}`)))
+	var names []string
+	if err := json.NewDecoder(rec.Body).Decode(&names); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(names) != 2 || names[0] != "dropPps" || names[1] != "dropBps" {
+		t.Errorf("search(drop) = %v", names)
+	}
+}
+
+func TestGrafanaQuery(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	h := stats.NewHistory(zap.NewNop(), stats.HistoryConfig{RetentionSec: 600, ResolutionSec: 10})
+	start := time.Unix(1_699_999_980, 0) // multiple of 30s
+	for i := 0; i <= 60; i++ {
+		h.Add(&stats.Snapshot{Timestamp: start.Add(time.Duration(i) * time.Second), RxPPS: 100, DropPPS: float64(i)})
+	}
+	s.SetHistory(h)
+
+	rng := fmt.Sprintf(`"range":{"from":%q,"to":%q}`,
+		start.UTC().Format(time.RFC3339), start.Add(time.Minute).UTC().Format(time.RFC3339))
+	body := `{` + rng + `,"intervalMs":30000,
+		"targets":[{"target":"rxPps","refId":"A"},{"target":"dropPps","refId":"B"}]}`
+	rec := httptest.NewRecorder()
+	s.handleQuery(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var series []grafanaSeries
+	if err := json.NewDecoder(rec.Body).Decode(&series); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(series) != 2 || series[0].Target != "rxPps" || len(series[0].Datapoints) != 2 {
+		t.Fatalf("series = %+v", series)
+	}
+	if dp := series[1].Datapoints[0]; dp[0] != 14.5 || dp[1] != float64(start.UnixMilli()) {
+		t.Errorf("first dropPps datapoint = %v, want 30s average 14.5 at start", dp)
+	}
+
+	body = `{` + rng + `,"targets":[{"target":"bogus"}]}`
+	rec = httptest.NewRecorder()
+	s.handleQuery(rec, httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown target status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// handleHandshakes lists the sources that had TLS or QUIC handshakes
+// dropped within ?since=D (default 5m), highest peak rate first, up to
+// ?limit=N (default 100), with the configured limits.
+func (s *Server) handleHandshakes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.maps == nil {
+		http.Error(w, "BPF maps not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	since := 5 * time.Minute
+	if v := r.URL.Query().Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		since = d
+	}
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	flooders, err := s.maps.HandshakeFlooders(since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	total := len(flooders)
+	if len(flooders) > limit {
+		flooders = flooders[:limit]
+	}
+
+	list := make([]map[string]interface{}, 0, len(flooders))
+	for _, f := range flooders {
+		list = append(list, map[string]interface{}{
+			"ip":       f.IP.String(),
+			"kind":     f.Kind,
+			"rate":     f.Rate,
+			"peak":     f.Peak,
+			"dropped":  f.Dropped,
+			"lastDrop": formatTime(f.LastDrop),
+		})
+	}
+
+	result := map[string]interface{}{
+		"since":   since.String(),
+		"total":   total,
+		"sources": list,
+	}
+	if s.cfg != nil {
+		result["tlsRatePps"] = s.cfg.HandshakeFlood.TLSRatePPS
+		result["quicRatePps"] = s.cfg.HandshakeFlood.QUICRatePPS
+	}
+	if s.stats != nil {
+		if snap := s.stats.Current(); snap != nil {
+			result["tlsHandshakes"] = snap.Stats.TLSHandshakes
+			result["quicInitials"] = snap.Stats.QUICInitials
+			result["tlsDropped"] = snap.Stats.TLSFloodDropped
+			result["quicDropped"] = snap.Stats.QUICFloodDropped
+		}
+	}
+	writeJSON(w, result)
+}
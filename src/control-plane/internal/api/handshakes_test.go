@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestHandshakesWithoutMaps(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+
+	for method, want := range map[string]int{
+		http.MethodGet:  http.StatusServiceUnavailable,
+		http.MethodPost: http.StatusMethodNotAllowed,
+	} {
+		rec := httptest.NewRecorder()
+		s.handleHandshakes(rec, httptest.NewRequest(method, "/api/v1/handshakes", nil))
+		if rec.Code != want {
+			t.Errorf("%s status = %d, want %d", method, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"net/http"
+)
+
+// handleHTTPFlood reports the destinations the HTTP flood detector
+// flagged and whether it is holding the escalation level up.
+func (s *Server) handleHTTPFlood(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.httpFlood == nil {
+		writeJSON(w, map[string]bool{"enabled": false})
+		return
+	}
+	writeJSON(w, s.httpFlood.Status())
+}
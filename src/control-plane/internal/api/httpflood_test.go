@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/httpflood"
+	"go.uber.org/zap"
+)
+
+// fakeHTTPFloodMap has no connections.
+type fakeHTTPFloodMap struct{}
+
+func (fakeHTTPFloodMap) ConntrackList(bpf.ConntrackFilter, int, int) ([]bpf.ConntrackFlow, int, error) {
+	return nil, 0, nil
+}
+func (fakeHTTPFloodMap) SetTCPStrictDst(net.IP, time.Duration) error { return nil }
+func (fakeHTTPFloodMap) RemoveTCPStrictDst(net.IP) error             { return nil }
+
+func TestHTTPFlood(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	const path = "/api/v1/httpflood"
+
+	rec := httptest.NewRecorder()
+	s.handleHTTPFlood(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"enabled":false`) {
+		t.Errorf("disabled: %d %s", rec.Code, rec.Body)
+	}
+
+	s.SetHTTPFlood(httpflood.NewDetector(zap.NewNop(), httpflood.DefaultConfig(), fakeHTTPFloodMap{}, nil))
+	rec = httptest.NewRecorder()
+	s.handleHTTPFlood(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET: status = %d", rec.Code)
+	}
+	var st httpflood.Status
+	if err := json.NewDecoder(rec.Body).Decode(&st); err != nil {
+		t.Fatal(err)
+	}
+	if !st.Enabled || st.Raised || st.Escalate != "medium" || st.Targets == nil {
+		t.Errorf("status = %+v", st)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleHTTPFlood(rec, httptest.NewRequest(http.MethodPost, path, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status = %d, want 405", rec.Code)
+	}
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+)
+
+// handleInspect reports the deep inspection sockets, the traffic the XDP
+// program redirected to them and the sources blocked on their verdicts.
+func (s *Server) handleInspect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.inspector == nil {
+		writeJSON(w, map[string]bool{"enabled": false})
+		return
+	}
+	st, err := s.inspector.Status()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, st)
+}
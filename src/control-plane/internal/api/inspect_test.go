@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/inspect"
+	"go.uber.org/zap"
+)
+
+// fakeInspectMap accepts every verdict and reports fixed redirect counters.
+type fakeInspectMap struct{}
+
+func (fakeInspectMap) SetConfig(uint32, uint64) error                 { return nil }
+func (fakeInspectMap) SetInspectPorts(map[uint16]uint8) error         { return nil }
+func (fakeInspectMap) SetInspectSocket(uint32, int) error             { return nil }
+func (fakeInspectMap) RemoveInspectSocket(uint32) error               { return nil }
+func (fakeInspectMap) ClearInspectSource(net.IP, time.Duration) error { return nil }
+
+func (fakeInspectMap) AddBlacklistCIDRWithTTL(string, uint32, time.Duration) error { return nil }
+
+func (fakeInspectMap) ReadInspectStats() (bpf.InspectStats, error) {
+	return bpf.InspectStats{Redirected: 12, NoSocket: 3}, nil
+}
+
+func TestInspect(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	const path = "/api/v1/inspect"
+
+	rec := httptest.NewRecorder()
+	s.handleInspect(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"enabled":false`) {
+		t.Errorf("disabled: %d %s", rec.Code, rec.Body)
+	}
+
+	s.SetInspector(inspect.NewInspector(zap.NewNop(), inspect.DefaultConfig(), fakeInspectMap{}))
+	rec = httptest.NewRecorder()
+	s.handleInspect(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET: status = %d", rec.Code)
+	}
+	var st inspect.Status
+	if err := json.NewDecoder(rec.Body).Decode(&st); err != nil {
+		t.Fatal(err)
+	}
+	if !st.Enabled || st.Redirected != 12 || st.NoSocket != 3 {
+		t.Errorf("status = %+v", st)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleInspect(rec, httptest.NewRequest(http.MethodPost, path, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status = %d, want 405", rec.Code)
+	}
+}
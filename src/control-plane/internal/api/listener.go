@@ -0,0 +1,99 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+)
+
+// listen opens the API listener: a TCP address or, for "unix:PATH", a unix
+// socket created with cfg.SocketMode and removed again on close. TLS wraps
+// either kind.
+func listen(cfg config.APIConfig) (net.Listener, error) {
+	var ln net.Listener
+	if path, ok := cfg.UnixSocket(); ok {
+		// A socket left behind by an unclean exit blocks the bind.
+		if fi, err := os.Lstat(path); err == nil && fi.Mode()&fs.ModeSocket != 0 {
+			os.Remove(path)
+		}
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, fmt.Errorf("listening on %s: %w", cfg.Listen, err)
+		}
+		mode := fs.FileMode(cfg.SocketMode)
+		if mode == 0 {
+			mode = 0660
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("setting permissions on %s: %w", path, err)
+		}
+		ln = l
+	} else {
+		l, err := net.Listen("tcp", cfg.Listen)
+		if err != nil {
+			return nil, fmt.Errorf("listening on %s: %w", cfg.Listen, err)
+		}
+		ln = l
+	}
+
+	if cfg.TLS {
+		tlsCfg, err := serverTLSConfig(cfg)
+		if err != nil {
+			ln.Close()
+			return nil, err
+		}
+		ln = tls.NewListener(ln, tlsCfg)
+	}
+	return ln, nil
+}
+
+// serverTLSConfig loads the API certificate and, if configured, the CA
+// that client certificates are verified against.
+func serverTLSConfig(cfg config.APIConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("loading API certificate: %w", err)
+	}
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.ClientCA != "" {
+		pem, err := os.ReadFile(cfg.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("reading API client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.ClientCA)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if cfg.RequireClientCert {
+			tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return tlsCfg, nil
+}
+
+// newHTTPServer applies the configured timeouts and header limit.
+func newHTTPServer(cfg config.APIConfig, handler http.Handler) *http.Server {
+	sec := func(n uint64) time.Duration { return time.Duration(n) * time.Second }
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: sec(cfg.ReadHeaderTimeoutSec),
+		ReadTimeout:       sec(cfg.ReadTimeoutSec),
+		WriteTimeout:      sec(cfg.WriteTimeoutSec),
+		IdleTimeout:       sec(cfg.IdleTimeoutSec),
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
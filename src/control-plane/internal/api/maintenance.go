@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/maintenance"
+	"go.uber.org/zap"
+)
+
+// handleMaintenance shows the maintenance state and history (GET), enters
+// maintenance (POST) and ends it (DELETE). Entering is refused with 409
+// while escalation is HIGH or CRITICAL unless the request sets force.
+func (s *Server) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	if s.maintenance == nil {
+		http.Error(w, "maintenance not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.maintenance.Status())
+
+	case http.MethodPost, http.MethodPut:
+		var req struct {
+			Mode       string `json:"mode"`
+			Reason     string `json:"reason"`
+			TimeoutSec int64  `json:"timeoutSec"`
+			Force      bool   `json:"force"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		st, err := s.maintenance.Enter(maintenance.Request{
+			Mode:    req.Mode,
+			Reason:  req.Reason,
+			Timeout: time.Duration(req.TimeoutSec) * time.Second,
+			Force:   req.Force,
+			By:      requester(r),
+		})
+		switch {
+		case errors.Is(err, maintenance.ErrEscalated), errors.Is(err, maintenance.ErrActive):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.log.Warn("maintenance entered via API",
+			zap.String("by", st.Current.By),
+			zap.String("reason", req.Reason),
+		)
+		writeJSON(w, st)
+
+	case http.MethodDelete:
+		st, err := s.maintenance.Exit(requester(r))
+		switch {
+		case errors.Is(err, maintenance.ErrInactive):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.log.Warn("maintenance ended via API", zap.String("by", requester(r)))
+		writeJSON(w, st)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// maintenanceConflict rejects changes to the enabled switch while
+// maintenance holds the scrubber in pass-all.
+func (s *Server) maintenanceConflict(w http.ResponseWriter) bool {
+	if s.maintenance != nil && s.maintenance.Active() {
+		http.Error(w, "scrubber is in maintenance; end it via /api/v1/maintenance", http.StatusConflict)
+		return true
+	}
+	return false
+}
+
+// requester names the caller: the API key or certificate identity, or
+// the client address when auth is disabled.
+func requester(r *http.Request) string {
+	if id, ok := requestIdentity(r); ok {
+		return id.Name
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
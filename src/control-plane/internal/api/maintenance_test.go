@@ -0,0 +1,73 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/maintenance"
+	"go.uber.org/zap"
+)
+
+type fakeMaintenanceDataplane map[uint32]uint64
+
+func (f fakeMaintenanceDataplane) GetConfig(key uint32) (uint64, error) { return f[key], nil }
+
+func (f fakeMaintenanceDataplane) SetConfig(key uint32, value uint64) error {
+	f[key] = value
+	return nil
+}
+
+func (f fakeMaintenanceDataplane) Detach() error   { return nil }
+func (f fakeMaintenanceDataplane) Reattach() error { return nil }
+
+func TestMaintenance(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	const path = "/api/v1/maintenance"
+
+	rec := httptest.NewRecorder()
+	s.handleMaintenance(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without manager: status = %d, want 503", rec.Code)
+	}
+
+	dp := fakeMaintenanceDataplane{bpf.CfgEnabled: 1, bpf.CfgEscalationLevel: 3}
+	s.SetMaintenance(maintenance.NewManager(zap.NewNop(), maintenance.DefaultConfig(), dp))
+
+	tests := []struct {
+		method string
+		body   string
+		want   int
+	}{
+		{http.MethodDelete, ``, http.StatusConflict},
+		{http.MethodPost, `{"mode":"bypass"}`, http.StatusBadRequest},
+		{http.MethodPost, `{"reason":"kernel upgrade"}`, http.StatusConflict},
+		{http.MethodPost, `{"reason":"kernel upgrade","force":true}`, http.StatusOK},
+		{http.MethodPost, `{"reason":"kernel upgrade","force":true}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleMaintenance(rec, httptest.NewRequest(tt.method, path, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.body, rec.Code, tt.want)
+		}
+	}
+	if dp[bpf.CfgEnabled] != 0 {
+		t.Error("maintenance did not switch to pass-all")
+	}
+
+	// The enabled switch is locked while in maintenance.
+	rec = httptest.NewRecorder()
+	s.handleSetEnabled(rec, httptest.NewRequest(http.MethodPut, "/api/v1/status/enabled", strings.NewReader(`{"enabled":true}`)))
+	if rec.Code != http.StatusConflict || dp[bpf.CfgEnabled] != 0 {
+		t.Errorf("enable during maintenance: status = %d, enabled = %d", rec.Code, dp[bpf.CfgEnabled])
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleMaintenance(rec, httptest.NewRequest(http.MethodDelete, path, nil))
+	if rec.Code != http.StatusOK || dp[bpf.CfgEnabled] != 1 {
+		t.Errorf("DELETE: status = %d, enabled = %d", rec.Code, dp[bpf.CfgEnabled])
+	}
+}
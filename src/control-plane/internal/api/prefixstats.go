@@ -0,0 +1,106 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// statsScope is the set of destinations a per-prefix stats request covers.
+type statsScope struct {
+	prefix string // Requested prefix, if any
+	tenant string // Tenant whose assets are covered, if any
+	nets   []*net.IPNet
+}
+
+// prefixStatsScope resolves the prefix and tenant query parameters of
+// GET /api/v1/stats. Tenant callers are always limited to their own
+// assets. It returns an HTTP status and error for invalid requests.
+func (s *Server) prefixStatsScope(r *http.Request) (statsScope, int, error) {
+	q := r.URL.Query()
+	sc := statsScope{prefix: q.Get("prefix"), tenant: q.Get("tenant")}
+	if caller := requestTenant(r); caller != "" {
+		if sc.tenant != "" && sc.tenant != caller {
+			return sc, http.StatusForbidden, fmt.Errorf("not available to tenant %s", caller)
+		}
+		sc.tenant = caller
+	}
+
+	var owned []*net.IPNet
+	if sc.tenant != "" {
+		if s.assets == nil {
+			return sc, http.StatusServiceUnavailable, fmt.Errorf("asset registry not available")
+		}
+		for _, a := range s.assets.Owned(sc.tenant) {
+			_, n, _ := net.ParseCIDR(a.Prefix)
+			owned = append(owned, n)
+		}
+	}
+
+	if sc.prefix == "" {
+		sc.nets = owned
+		return sc, http.StatusOK, nil
+	}
+	n, err := parseCIDROrIP(sc.prefix)
+	if err != nil {
+		return sc, http.StatusBadRequest, fmt.Errorf("invalid prefix")
+	}
+	if sc.tenant != "" && !coveredBy(n, owned) {
+		return sc, http.StatusForbidden, fmt.Errorf("prefix %s is not assigned to tenant %s", n, sc.tenant)
+	}
+	sc.prefix = n.String()
+	sc.nets = []*net.IPNet{n}
+	return sc, http.StatusOK, nil
+}
+
+// handlePrefixStats serves GET /api/v1/stats?prefix=CIDR or ?tenant=NAME:
+// traffic and drops by reason towards a single victim or a tenant's
+// assets rather than box-wide totals.
+func (s *Server) handlePrefixStats(w http.ResponseWriter, r *http.Request) {
+	sc, status, err := s.prefixStatsScope(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if s.maps == nil {
+		http.Error(w, "BPF maps not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	st, err := s.maps.ReadPrefixStats(sc.nets)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	prefixes := make([]string, 0, len(sc.nets))
+	for _, n := range sc.nets {
+		prefixes = append(prefixes, n.String())
+	}
+	resp := map[string]interface{}{
+		"prefixes":       prefixes,
+		"destinations":   st.Destinations,
+		"rxPackets":      st.RxPackets,
+		"rxBytes":        st.RxBytes,
+		"droppedPackets": st.DroppedPackets,
+		"droppedBytes":   st.DroppedBytes,
+		"dropReasons":    st.DropReasons,
+	}
+	if sc.prefix != "" {
+		resp["prefix"] = sc.prefix
+	}
+	if sc.tenant != "" {
+		resp["tenant"] = sc.tenant
+	}
+	writeJSON(w, resp)
+}
+
+// coveredBy reports whether n lies entirely inside one of nets.
+func coveredBy(n *net.IPNet, nets []*net.IPNet) bool {
+	ones, _ := n.Mask.Size()
+	for _, o := range nets {
+		if oOnes, _ := o.Mask.Size(); oOnes <= ones && o.Contains(n.IP) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/assets"
+	"go.uber.org/zap"
+)
+
+func TestPrefixStatsScope(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	reg := assets.NewRegistry(zap.NewNop(), fakeDstPolicyMap{})
+	if err := reg.Configure([]assets.Asset{
+		{Name: "web", Prefix: "203.0.113.0/24", Owner: "acme"},
+		{Name: "dns", Prefix: "198.51.100.53/32", Owner: "acme"},
+		{Name: "mail", Prefix: "192.0.2.0/24", Owner: "globex"},
+	}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	s.SetAssets(reg)
+
+	tests := []struct {
+		tenant string // Caller's tenant
+		query  string
+		status int
+		nets   int
+	}{
+		{"", "prefix=203.0.113.0/25", http.StatusOK, 1},
+		{"", "prefix=192.0.2.1", http.StatusOK, 1},
+		{"", "prefix=bogus", http.StatusBadRequest, 0},
+		{"", "tenant=acme", http.StatusOK, 2},
+		{"", "tenant=acme&prefix=192.0.2.0/24", http.StatusForbidden, 0},
+		{"acme", "", http.StatusOK, 2},
+		{"acme", "prefix=203.0.113.128/25", http.StatusOK, 1},
+		{"acme", "prefix=203.0.112.0/23", http.StatusForbidden, 0},
+		{"acme", "prefix=192.0.2.0/24", http.StatusForbidden, 0},
+		{"acme", "tenant=globex", http.StatusForbidden, 0},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/stats?"+tt.query, nil)
+		if tt.tenant != "" {
+			id := identity{Name: "portal", Source: "key", Role: roleViewer, Tenant: tt.tenant}
+			req = req.WithContext(context.WithValue(req.Context(), identityKey{}, id))
+		}
+		sc, status, err := s.prefixStatsScope(req)
+		if status != tt.status || (err == nil) != (tt.status == http.StatusOK) {
+			t.Errorf("%q as %q: status = %d (%v), want %d", tt.query, tt.tenant, status, err, tt.status)
+			continue
+		}
+		if err == nil && len(sc.nets) != tt.nets {
+			t.Errorf("%q as %q: %d prefixes, want %d", tt.query, tt.tenant, len(sc.nets), tt.nets)
+		}
+	}
+}
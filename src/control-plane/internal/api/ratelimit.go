@@ -0,0 +1,188 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/rateclass"
+	"go.uber.org/zap"
+)
+
+func sourceRateToJSON(sr bpf.SourceRate) map[string]interface{} {
+	return map[string]interface{}{
+		"ip":             sr.IP.String(),
+		"ratePps":        sr.RatePPS,
+		"burst":          sr.BurstSize,
+		"tokens":         sr.Tokens,
+		"cpus":           sr.CPUs,
+		"packets":        sr.TotalPackets,
+		"droppedPackets": sr.DroppedPackets,
+		"lastRefill":     formatTime(sr.LastRefill),
+	}
+}
+
+// handleRateLimiters lists the per-source token buckets that have dropped
+// packets, most drops first, up to ?limit=N (default 100). ?all=true also
+// lists the sources still within their limit.
+func (s *Server) handleRateLimiters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.maps == nil {
+		http.Error(w, "BPF maps not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	all := r.URL.Query().Get("all") == "true"
+
+	buckets, err := s.maps.RateLimiters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tracked, limited := len(buckets), 0
+	for _, sr := range buckets {
+		if sr.DroppedPackets > 0 {
+			limited++
+		}
+	}
+	if !all {
+		buckets = buckets[:limited] // Sorted by drops, so these come first
+	}
+	if len(buckets) > limit {
+		buckets = buckets[:limit]
+	}
+
+	sources := make([]map[string]interface{}, 0, len(buckets))
+	for _, sr := range buckets {
+		sources = append(sources, sourceRateToJSON(sr))
+	}
+	writeJSON(w, map[string]interface{}{
+		"tracked": tracked,
+		"limited": limited,
+		"sources": sources,
+	})
+}
+
+// handleRateLimiter shows (GET) or resets (DELETE) the token bucket of one
+// source, e.g. /api/v1/ratelimit/sources/198.51.100.7. A reset source
+// starts over with a full bucket on its next packet.
+func (s *Server) handleRateLimiter(w http.ResponseWriter, r *http.Request) {
+	ip := net.ParseIP(strings.TrimPrefix(r.URL.Path, "/api/v1/ratelimit/sources/"))
+	if ip == nil || ip.To4() == nil {
+		http.Error(w, "invalid IPv4 address", http.StatusBadRequest)
+		return
+	}
+	if s.maps == nil {
+		http.Error(w, "BPF maps not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		sr, err := s.maps.RateLimiter(ip)
+		if errors.Is(err, bpf.ErrNoRateLimiter) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, sourceRateToJSON(sr))
+
+	case http.MethodDelete:
+		err := s.maps.ResetRateLimiter(ip)
+		if errors.Is(err, bpf.ErrNoRateLimiter) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.log.Info("rate limiter reset via API", zap.String("ip", ip.String()))
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRateClasses lists the rate classes with the packets their pps and
+// bps limits counted and dropped, and adds (POST), replaces (PUT) and
+// removes (DELETE) them.
+func (s *Server) handleRateClasses(w http.ResponseWriter, r *http.Request) {
+	if s.rateClasses == nil {
+		http.Error(w, "rate classes not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		stats, err := s.rateClasses.Stats()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"classes": stats})
+
+	case http.MethodPost, http.MethodPut:
+		var req rateclass.Class
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodPut {
+			if _, ok := s.rateClasses.Get(req.Name); !ok {
+				http.Error(w, "rate class not found", http.StatusNotFound)
+				return
+			}
+			if err := s.rateClasses.Update(req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.log.Info("rate class updated via API", zap.String("name", req.Name))
+		} else {
+			if err := s.rateClasses.Add(req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.log.Info("rate class added via API", zap.String("name", req.Name))
+		}
+		writeJSON(w, map[string]bool{"ok": true})
+
+	case http.MethodDelete:
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := s.rateClasses.Remove(req.Name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.log.Info("rate class removed via API", zap.String("name", req.Name))
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
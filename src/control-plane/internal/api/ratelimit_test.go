@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/rateclass"
+	"go.uber.org/zap"
+)
+
+func TestRateLimitersWithoutMaps(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodGet, "/api/v1/ratelimit/sources", http.StatusServiceUnavailable},
+		{http.MethodPost, "/api/v1/ratelimit/sources", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/v1/ratelimit/sources/198.51.100.7", http.StatusServiceUnavailable},
+		{http.MethodDelete, "/api/v1/ratelimit/sources/198.51.100.7", http.StatusServiceUnavailable},
+		{http.MethodDelete, "/api/v1/ratelimit/sources/2001:db8::1", http.StatusBadRequest},
+		{http.MethodDelete, "/api/v1/ratelimit/sources/bogus", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.path == "/api/v1/ratelimit/sources" {
+			s.handleRateLimiters(rec, req)
+		} else {
+			s.handleRateLimiter(rec, req)
+		}
+		if rec.Code != tt.want {
+			t.Errorf("%s %s status = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+		}
+	}
+}
+
+// fakeRateClassMap keeps the rate class limits by id and ignores bindings.
+type fakeRateClassMap map[uint32]bpf.RateClass
+
+func (f fakeRateClassMap) SetRateClass(id uint32, rc bpf.RateClass) error {
+	f[id] = rc
+	return nil
+}
+
+func (f fakeRateClassMap) RemoveRateClass(id uint32) error {
+	delete(f, id)
+	return nil
+}
+
+func (f fakeRateClassMap) BindRateClass(bpf.RateClassDir, string, uint32) error { return nil }
+func (f fakeRateClassMap) UnbindRateClass(bpf.RateClassDir, string) error       { return nil }
+
+func (f fakeRateClassMap) RateClassCounters(uint32) (bpf.RateClassCounters, error) {
+	return bpf.RateClassCounters{Packets: 10, DroppedPackets: 4}, nil
+}
+
+func TestRateClasses(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	const path = "/api/v1/ratelimit/classes"
+
+	rec := httptest.NewRecorder()
+	s.handleRateClasses(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without registry: status = %d, want 503", rec.Code)
+	}
+
+	m := fakeRateClassMap{}
+	s.SetRateClasses(rateclass.NewRegistry(zap.NewNop(), m))
+
+	tests := []struct {
+		method string
+		body   string
+		want   int
+	}{
+		{http.MethodPost, `{"name":"game-traffic","udpRatePps":2000,"destinations":["203.0.113.0/24"]}`, http.StatusOK},
+		{http.MethodPost, `{"name":"game-traffic","pps":1000}`, http.StatusBadRequest},
+		{http.MethodPost, `{"name":"dns-servers","sources":["192.0.2.53/32"]}`, http.StatusBadRequest},
+		{http.MethodPut, `{"name":"game-traffic","udpRatePps":2000,"pps":500000,"destinations":["203.0.113.0/24"]}`, http.StatusOK},
+		{http.MethodPut, `{"name":"voip","pps":1000}`, http.StatusNotFound},
+		{http.MethodDelete, `{"name":"voip"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleRateClasses(rec, httptest.NewRequest(tt.method, path, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.body, rec.Code, tt.want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleRateClasses(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var res struct {
+		Classes []rateclass.Stats `json:"classes"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(res.Classes) != 1 || res.Classes[0].PPS != 500000 || res.Classes[0].DroppedPackets != 4 || m[1].PPS != 500000 {
+		t.Errorf("classes = %+v, map = %v", res.Classes, m)
+	}
+}
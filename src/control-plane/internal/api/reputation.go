@@ -0,0 +1,197 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// maxReputationThreshold matches the score cap in the BPF reputation module.
+const maxReputationThreshold = 1000
+
+func (s *Server) handleReputationConfig(w http.ResponseWriter, r *http.Request) {
+	if s.reputation == nil {
+		http.Error(w, "reputation engine not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.reputation.Config())
+
+	case http.MethodPut:
+		// Fields omitted from the body keep their current value.
+		cfg := s.reputation.Config()
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := s.reputation.SetConfig(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.log.Info("reputation config updated via API")
+		writeJSON(w, cfg)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleReputationTop lists the highest-scoring sources.
+func (s *Server) handleReputationTop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.reputation == nil {
+		http.Error(w, "reputation engine not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"threshold": s.reputation.GetThreshold(),
+		"tracked":   s.reputation.GetTrackedCount(),
+		"offenders": s.reputation.GetTopOffenders(limit),
+	})
+}
+
+// handleReputationBlocked lists (GET), manually blocks (POST) or unblocks
+// (DELETE) sources.
+func (s *Server) handleReputationBlocked(w http.ResponseWriter, r *http.Request) {
+	if s.reputation == nil {
+		http.Error(w, "reputation engine not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.reputation.GetBlocked())
+
+	case http.MethodPost, http.MethodDelete:
+		var req struct {
+			IP string `json:"ip"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodPost {
+			if err := s.reputation.BlockIP(req.IP); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.log.Info("ip blocked via API", zap.String("ip", req.IP))
+		} else {
+			if err := s.reputation.UnblockIP(req.IP); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.log.Info("ip unblocked via API", zap.String("ip", req.IP))
+		}
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleReputationThreshold reads (GET) or changes (PUT) the auto-block
+// threshold.
+func (s *Server) handleReputationThreshold(w http.ResponseWriter, r *http.Request) {
+	if s.reputation == nil {
+		http.Error(w, "reputation engine not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]uint32{"threshold": s.reputation.GetThreshold()})
+
+	case http.MethodPut:
+		var req struct {
+			Threshold uint32 `json:"threshold"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Threshold == 0 || req.Threshold > maxReputationThreshold {
+			http.Error(w, "threshold must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		if err := s.reputation.SetThreshold(req.Threshold); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.log.Info("reputation threshold updated via API", zap.Uint32("threshold", req.Threshold))
+		writeJSON(w, map[string]uint32{"threshold": req.Threshold})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleReputationExport downloads the whole reputation table as JSON
+// (default) or CSV (?format=csv).
+func (s *Server) handleReputationExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.reputation == nil {
+		http.Error(w, "reputation engine not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	all := s.reputation.All()
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Disposition", `attachment; filename="reputation.json"`)
+		writeJSON(w, all)
+
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="reputation.csv"`)
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"ip", "score", "total_packets", "dropped_packets", "blocked", "manual", "first_seen", "last_seen"})
+		for _, rep := range all {
+			cw.Write([]string{
+				rep.IP,
+				strconv.FormatUint(uint64(rep.Score), 10),
+				strconv.FormatUint(uint64(rep.TotalPkts), 10),
+				strconv.FormatUint(uint64(rep.DroppedPkts), 10),
+				strconv.FormatBool(rep.Blocked),
+				strconv.FormatBool(rep.Manual),
+				formatTime(rep.FirstSeen),
+				formatTime(rep.LastSeen),
+			})
+		}
+		cw.Flush()
+
+	default:
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+	}
+}
+
+// formatTime renders t as RFC 3339, or "" if unset.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
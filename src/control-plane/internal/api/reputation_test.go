@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/reputation"
+	"go.uber.org/zap"
+)
+
+func newReputationServer() *Server {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	s.SetReputation(reputation.NewEngine(zap.NewNop(), nil, nil, nil))
+	return s
+}
+
+func TestReputationConfigPartialUpdate(t *testing.T) {
+	s := newReputationServer()
+
+	body := `{"decay":"exponential","decayHalfLifeSec":120,"weights":{"synNoAck":90}}`
+	rec := httptest.NewRecorder()
+	s.handleReputationConfig(rec, httptest.NewRequest(http.MethodPut, "/api/v1/reputation/config", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d: %s", rec.Code, rec.Body)
+	}
+
+	cfg := s.reputation.Config()
+	if cfg.Decay != reputation.DecayExponential || cfg.DecayHalfLifeSec != 120 || cfg.Weights.SYNNoACK != 90 {
+		t.Errorf("config = %+v", cfg)
+	}
+	if def := reputation.DefaultConfig(); cfg.PollIntervalSec != def.PollIntervalSec || cfg.UnblockRatio != def.UnblockRatio {
+		t.Errorf("omitted fields changed: %+v", cfg)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleReputationConfig(rec, httptest.NewRequest(http.MethodPut, "/api/v1/reputation/config", strings.NewReader(`{"unblockRatio":0}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid PUT status = %d", rec.Code)
+	}
+}
+
+func TestReputationThresholdBounds(t *testing.T) {
+	s := newReputationServer()
+	for _, body := range []string{`{"threshold":0}`, `{"threshold":1001}`, `not json`} {
+		rec := httptest.NewRecorder()
+		s.handleReputationThreshold(rec, httptest.NewRequest(http.MethodPut, "/api/v1/reputation/threshold", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s status = %d, want 400", body, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleReputationThreshold(rec, httptest.NewRequest(http.MethodGet, "/api/v1/reputation/threshold", nil))
+	var got map[string]uint32
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || got["threshold"] != 500 {
+		t.Errorf("GET threshold = %v, %v", got, err)
+	}
+}
+
+func TestReputationExportFormats(t *testing.T) {
+	s := newReputationServer()
+
+	rec := httptest.NewRecorder()
+	s.handleReputationExport(rec, httptest.NewRequest(http.MethodGet, "/api/v1/reputation/export?format=csv", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.HasPrefix(rec.Body.String(), "ip,score,total_packets,") {
+		t.Errorf("csv = %q", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleReputationExport(rec, httptest.NewRequest(http.MethodGet, "/api/v1/reputation/export?format=xml", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("xml status = %d", rec.Code)
+	}
+}
+
+func TestReputationUnavailable(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	rec := httptest.NewRecorder()
+	s.handleReputationBlocked(rec, httptest.NewRequest(http.MethodGet, "/api/v1/reputation/blocked", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/revisions"
+	"go.uber.org/zap"
+)
+
+// revisionsPrefix serves the configuration history. Revisions hold the
+// whole configuration, API keys included, so requiredRole demands admin
+// for reads too.
+const revisionsPrefix = "/api/v1/config/revisions"
+
+// revisionMiddleware records a configuration revision after every
+// state-changing request. Requests that change nothing are not recorded.
+func (s *Server) revisionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+		if s.revisions == nil || queryPaths[r.URL.Path] || strings.HasPrefix(r.URL.Path, "/api/v1/config/rollback/") {
+			return
+		}
+		id, _ := requestIdentity(r)
+		if _, err := s.revisions.Record(r.Method+" "+r.URL.Path, id.Name); err != nil {
+			s.log.Warn("failed to record config revision", zap.Error(err))
+		}
+	})
+}
+
+func revisionToJSON(rev revisions.Revision) map[string]interface{} {
+	m := map[string]interface{}{
+		"number": rev.Number,
+		"at":     formatTime(rev.At),
+		"source": rev.Source,
+	}
+	if rev.Author != "" {
+		m["author"] = rev.Author
+	}
+	return m
+}
+
+// handleRevisions lists the retained revisions, newest first, with the
+// number of settings each changed (GET /api/v1/config/revisions).
+func (s *Server) handleRevisions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.revisions == nil {
+		http.Error(w, "config revisions not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	list := s.revisions.List()
+	out := make([]map[string]interface{}, 0, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		m := revisionToJSON(list[i])
+		if i > 0 {
+			if changes, err := revisions.Diff(list[i-1], list[i]); err == nil {
+				m["changes"] = len(changes)
+			}
+		}
+		out = append(out, m)
+	}
+	writeJSON(w, out)
+}
+
+// handleRevision shows one revision: its config map values, the YAML
+// configuration and what changed against the previous revision, or
+// against ?against=N (GET /api/v1/config/revisions/{n}).
+func (s *Server) handleRevision(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.revisions == nil {
+		http.Error(w, "config revisions not available", http.StatusServiceUnavailable)
+		return
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, revisionsPrefix+"/"))
+	if err != nil {
+		http.Error(w, "invalid revision number", http.StatusBadRequest)
+		return
+	}
+	rev, ok := s.revisions.Get(n)
+	if !ok {
+		http.Error(w, "revision not found", http.StatusNotFound)
+		return
+	}
+
+	base, hasBase := s.revisions.Previous(n)
+	if v := r.URL.Query().Get("against"); v != "" {
+		m, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid against revision", http.StatusBadRequest)
+			return
+		}
+		if base, hasBase = s.revisions.Get(m); !hasBase {
+			http.Error(w, "against revision not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	values := make(map[string]interface{}, len(rev.Values))
+	for name, v := range rev.Values {
+		if k, ok := bpf.LookupConfigKey(name); ok {
+			values[name] = k.Format(v)
+		}
+	}
+	m := revisionToJSON(rev)
+	m["values"] = values
+	m["config"] = rev.Config
+	changes := []revisions.Change{}
+	if hasBase {
+		c, err := revisions.Diff(base, rev)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if c != nil {
+			changes = c
+		}
+		m["against"] = base.Number
+	}
+	m["changes"] = changes
+	writeJSON(w, m)
+}
+
+// handleRollback re-applies a revision to the config map and the
+// configuration (POST /api/v1/config/rollback/{n}). The result is recorded
+// as a new revision.
+func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.revisions == nil {
+		http.Error(w, "config revisions not available", http.StatusServiceUnavailable)
+		return
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/v1/config/rollback/"))
+	if err != nil {
+		http.Error(w, "invalid revision number", http.StatusBadRequest)
+		return
+	}
+	if _, ok := s.revisions.Get(n); !ok {
+		http.Error(w, "revision not found", http.StatusNotFound)
+		return
+	}
+
+	id, _ := requestIdentity(r)
+	rev, err := s.revisions.Rollback(n, id.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.log.Info("configuration rolled back via API",
+		zap.Int("to", n),
+		zap.Int("revision", rev.Number),
+		zap.String("by", id.Name),
+	)
+	writeJSON(w, revisionToJSON(rev))
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+)
+
+// handleScanners lists the sources flagged as port scanners within
+// ?since=D (default 5m), most distinct ports first, up to ?limit=N
+// (default 100), with the configured response.
+func (s *Server) handleScanners(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.maps == nil {
+		http.Error(w, "BPF maps not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	since := 5 * time.Minute
+	if v := r.URL.Query().Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+		since = d
+	}
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	scanners, err := s.maps.Scanners(since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	total := len(scanners)
+	if len(scanners) > limit {
+		scanners = scanners[:limit]
+	}
+
+	expiry := s.maps.BlacklistExpiry()
+	list := make([]map[string]interface{}, 0, len(scanners))
+	for _, sc := range scanners {
+		ip := sc.IP.String()
+		list = append(list, map[string]interface{}{
+			"ip":            ip,
+			"distinctPorts": sc.DistinctPorts,
+			"peakPorts":     sc.PeakPorts,
+			"windowStart":   formatTime(sc.WindowStart),
+			"detected":      formatTime(sc.Detected),
+			"blockedUntil":  formatTime(expiry[ip+"/32"]),
+		})
+	}
+
+	result := map[string]interface{}{
+		"since":    since.String(),
+		"total":    total,
+		"scanners": list,
+	}
+	if s.cfg != nil {
+		ps := s.cfg.PortScan
+		result["action"] = ps.Action
+		result["threshold"] = ps.Threshold
+		result["windowSec"] = ps.WindowSec
+		if ps.Action == config.PortScanBlock {
+			result["blockSec"] = ps.BlockSec
+		}
+	}
+	if s.stats != nil {
+		if snap := s.stats.Current(); snap != nil {
+			result["detected"] = snap.Stats.PortScanDetected
+		}
+	}
+	writeJSON(w, result)
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// handleSchedule returns the active policy profile, where it came from,
+// the next scheduled switch and the configured profiles.
+func (s *Server) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	if s.scheduler == nil {
+		http.Error(w, "policy schedule not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, s.scheduler.Status())
+}
+
+// handleScheduleOverride forces a profile (PUT), for durationSec seconds
+// or until cleared, and returns to the schedule (DELETE).
+func (s *Server) handleScheduleOverride(w http.ResponseWriter, r *http.Request) {
+	if s.scheduler == nil {
+		http.Error(w, "policy schedule not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		var req struct {
+			Profile     string `json:"profile"`
+			DurationSec int64  `json:"durationSec"` // 0 = until cleared
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Profile == "" {
+			http.Error(w, "profile is required", http.StatusBadRequest)
+			return
+		}
+		if req.DurationSec < 0 {
+			http.Error(w, "durationSec must not be negative", http.StatusBadRequest)
+			return
+		}
+		var until time.Time
+		if req.DurationSec > 0 {
+			until = time.Now().Add(time.Duration(req.DurationSec) * time.Second)
+		}
+		if _, ok := s.scheduler.Status().Profiles[req.Profile]; !ok {
+			http.Error(w, "profile not found", http.StatusNotFound)
+			return
+		}
+		if err := s.scheduler.SetOverride(req.Profile, until); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.log.Info("policy profile override set via API",
+			zap.String("profile", req.Profile),
+			zap.Int64("duration_sec", req.DurationSec),
+		)
+		writeJSON(w, s.scheduler.Status())
+
+	case http.MethodDelete:
+		s.scheduler.ClearOverride()
+		s.log.Info("policy profile override cleared via API")
+		writeJSON(w, s.scheduler.Status())
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/schedule"
+	"go.uber.org/zap"
+)
+
+type fakeScheduleConfigMap map[uint32]uint64
+
+func (f fakeScheduleConfigMap) GetConfig(key uint32) (uint64, error) { return f[key], nil }
+
+func (f fakeScheduleConfigMap) SetConfig(key uint32, value uint64) error {
+	f[key] = value
+	return nil
+}
+
+func TestScheduleOverride(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	s.handleSchedule(rec, httptest.NewRequest(http.MethodGet, "/api/v1/schedule", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without scheduler: status = %d, want 503", rec.Code)
+	}
+
+	sched, err := schedule.NewScheduler(zap.NewNop(), schedule.Config{
+		Profiles: map[string]schedule.Profile{"night": {SYNRatePPS: 500}},
+		Entries:  []schedule.Entry{{Cron: "0 20 * * *", Profile: "night"}},
+	}, fakeScheduleConfigMap{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetScheduler(sched)
+
+	const path = "/api/v1/schedule/override"
+	tests := []struct {
+		method string
+		body   string
+		want   int
+	}{
+		{http.MethodPut, `{"profile":"weekend"}`, http.StatusNotFound},
+		{http.MethodPut, `{"durationSec":60}`, http.StatusBadRequest},
+		{http.MethodPut, `{"profile":"night","durationSec":-1}`, http.StatusBadRequest},
+		{http.MethodPut, `{"profile":"night","durationSec":3600}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleScheduleOverride(rec, httptest.NewRequest(tt.method, path, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.body, rec.Code, tt.want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleSchedule(rec, httptest.NewRequest(http.MethodGet, "/api/v1/schedule", nil))
+	var st schedule.Status
+	if err := json.NewDecoder(rec.Body).Decode(&st); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if st.Active != "night" || st.Source != "override" || st.OverrideUntil.IsZero() {
+		t.Errorf("status = %+v, want night from an expiring override", st)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleScheduleOverride(rec, httptest.NewRequest(http.MethodDelete, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("DELETE: status = %d", rec.Code)
+	}
+	if st := sched.Status(); st.Source == "override" {
+		t.Error("override still active after DELETE")
+	}
+}
@@ -4,16 +4,44 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/approval"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/assets"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/attacks"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/baseline"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bgp"
 	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/capture"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/cluster"
 	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/diversion"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/egress"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
 	"github.com/ebpf-ddos-scrubber/control-plane/internal/events"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/fleet"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/geoip"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/httpflood"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/inspect"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/maintenance"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/rateclass"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/reputation"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/revisions"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/schedule"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/siglearn"
 	"github.com/ebpf-ddos-scrubber/control-plane/internal/stats"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/threatintel"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/tunnel"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/watchdog"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 )
@@ -22,18 +50,61 @@ import (
 type Server struct {
 	log       *zap.Logger
 	cfg       *config.Config
+	loader    *bpf.Loader
 	maps      *bpf.MapManager
+	tenantACL tenantACLStore
 	stats     *stats.Collector
 	events    *events.Reader
 	startTime time.Time
 
+	topTalkers  *stats.TopTalkers
+	history     *stats.History
+	capturer    *capture.Capturer
+	sigLearner  *siglearn.Learner
+	cluster     *cluster.Syncer
+	geoip       *geoip.Manager
+	asn         *geoip.ASNManager
+	reputation  *reputation.Engine
+	threatIntel *threatintel.Manager
+	bgp         *bgp.Client
+	tunnels     *tunnel.Manager
+	diversion   *diversion.Manager
+	assets      *assets.Registry
+	rateClasses *rateclass.Registry
+	egress      *egress.Manager
+	inspector   *inspect.Inspector
+	httpFlood   *httpflood.Detector
+	baseline    *baseline.Baseline
+	escalation  *escalation.Engine
+	attacks     *attacks.Tracker
+	revisions   *revisions.Store
+	scheduler   *schedule.Scheduler
+	maintenance *maintenance.Manager
+	approvals   *approval.Manager
+	watchdog    *watchdog.Watchdog
+	fleet       *fleet.Fleet
+
+	onEscalationChange func(from, to escalation.Level, reason string)
+
+	// API key and client certificate roles; nil when auth is disabled
+	auth *authorizer
+
 	httpServer *http.Server
 
-	// WebSocket clients
-	wsMu    sync.RWMutex
-	wsConns map[*websocket.Conn]struct{}
+	// WebSocket and SSE stream clients, and the recent broadcasts SSE
+	// clients can resume from
+	wsMu       sync.RWMutex
+	wsConns    map[*websocket.Conn]*wsClient
+	sseClients map[*sseClient]struct{}
+	streamSeq  uint64
+	recent     *streamLog
 
 	upgrader websocket.Upgrader
+
+	// Mutating requests hold drainMu for reading while they run; Drain
+	// takes it for writing so it returns only once in-flight writes finish.
+	drainMu  sync.RWMutex
+	draining bool
 }
 
 // NewServer creates a new API server.
@@ -44,48 +115,305 @@ func NewServer(
 	statsCollector *stats.Collector,
 	eventReader *events.Reader,
 ) *Server {
-	return &Server{
+	s := &Server{
 		log:       log,
 		cfg:       cfg,
 		maps:      maps,
 		stats:     statsCollector,
 		events:    eventReader,
 		startTime: time.Now(),
-		wsConns:   make(map[*websocket.Conn]struct{}),
+		wsConns:    make(map[*websocket.Conn]*wsClient),
+		sseClients: make(map[*sseClient]struct{}),
+		recent:     newStreamLog(streamReplay),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool { return true },
 		},
 	}
+	if maps != nil {
+		s.tenantACL = maps
+	}
+	return s
+}
+
+// SetLoader attaches the BPF loader behind /api/v1/bpf.
+func (s *Server) SetLoader(l *bpf.Loader) {
+	s.loader = l
+}
+
+// SetTopTalkers attaches the top-talkers aggregator served by
+// GET /api/v1/top-talkers.
+func (s *Server) SetTopTalkers(t *stats.TopTalkers) {
+	s.topTalkers = t
+}
+
+// SetHistory attaches the rate history served by GET /api/v1/stats/history.
+func (s *Server) SetHistory(h *stats.History) {
+	s.history = h
+}
+
+// SetCapturer attaches the packet capturer controlled through
+// /api/v1/capture. A nil capturer disables those endpoints.
+func (s *Server) SetCapturer(c *capture.Capturer) {
+	s.capturer = c
+}
+
+// SetSignatureLearner attaches the signature learner whose proposals are
+// served by /api/v1/signatures/proposals.
+func (s *Server) SetSignatureLearner(l *siglearn.Learner) {
+	s.sigLearner = l
+}
+
+// SetCluster attaches the cluster syncer whose state is served by
+// /api/v1/cluster. A nil syncer means the scrubber runs standalone.
+func (s *Server) SetCluster(c *cluster.Syncer) {
+	s.cluster = c
+}
+
+// SetGeoIP attaches the country data manager behind /api/v1/geoip. A nil
+// manager means no GeoIP data is loaded.
+func (s *Server) SetGeoIP(m *geoip.Manager) {
+	s.geoip = m
+}
+
+// SetASN attaches the ASN manager behind /api/v1/asn. A nil manager means
+// no ASN data is loaded.
+func (s *Server) SetASN(a *geoip.ASNManager) {
+	s.asn = a
+}
+
+// SetReputation attaches the reputation engine behind /api/v1/reputation.
+func (s *Server) SetReputation(e *reputation.Engine) {
+	s.reputation = e
+}
+
+// SetThreatIntel attaches the threat intel manager behind
+// /api/v1/threatintel. A nil manager means feeds are disabled.
+func (s *Server) SetThreatIntel(m *threatintel.Manager) {
+	s.threatIntel = m
+}
+
+// SetBGP attaches the BGP client behind /api/v1/bgp. A nil client means
+// BGP signaling is disabled.
+func (s *Server) SetBGP(c *bgp.Client) {
+	s.bgp = c
+}
+
+// SetTunnels attaches the GRE tunnel manager behind /api/v1/tunnels. A nil
+// manager means GRE tunnels are disabled.
+func (s *Server) SetTunnels(m *tunnel.Manager) {
+	s.tunnels = m
+}
+
+// SetDiversion attaches the diversion manager behind /api/v1/diversion. A
+// nil manager means diversion is disabled.
+func (s *Server) SetDiversion(m *diversion.Manager) {
+	s.diversion = m
+}
+
+// SetAssets attaches the protected asset registry behind /api/v1/assets.
+func (s *Server) SetAssets(r *assets.Registry) {
+	s.assets = r
+}
+
+// SetRateClasses attaches the rate class registry behind
+// /api/v1/ratelimit/classes.
+func (s *Server) SetRateClasses(r *rateclass.Registry) {
+	s.rateClasses = r
+}
+
+// SetEgress attaches the outbound scrubbing policy behind /api/v1/egress;
+// nil when egress scrubbing is disabled.
+func (s *Server) SetEgress(m *egress.Manager) {
+	s.egress = m
+}
+
+// SetInspector attaches the deep inspector behind /api/v1/inspect; nil
+// when deep inspection is disabled.
+func (s *Server) SetInspector(in *inspect.Inspector) {
+	s.inspector = in
+}
+
+// SetHTTPFlood attaches the HTTP flood detector behind /api/v1/httpflood;
+// nil when detection is disabled.
+func (s *Server) SetHTTPFlood(d *httpflood.Detector) {
+	s.httpFlood = d
+}
+
+// SetBaseline attaches the traffic baseline behind /api/v1/baseline.
+func (s *Server) SetBaseline(b *baseline.Baseline) {
+	s.baseline = b
+}
+
+// SetAttacks attaches the attack session tracker behind /api/v1/attacks.
+func (s *Server) SetAttacks(t *attacks.Tracker) {
+	s.attacks = t
+}
+
+// SetRevisions attaches the configuration history behind
+// /api/v1/config/revisions and records a revision after each change.
+func (s *Server) SetRevisions(r *revisions.Store) {
+	s.revisions = r
+}
+
+// SetScheduler attaches the policy profile scheduler behind
+// /api/v1/schedule; nil when no schedule is configured.
+func (s *Server) SetScheduler(sc *schedule.Scheduler) {
+	s.scheduler = sc
+}
+
+// SetMaintenance attaches the maintenance manager behind
+// /api/v1/maintenance.
+func (s *Server) SetMaintenance(m *maintenance.Manager) {
+	s.maintenance = m
+}
+
+// SetApprovals attaches the approval manager behind /api/v1/approvals.
+func (s *Server) SetApprovals(m *approval.Manager) {
+	s.approvals = m
+}
+
+// SetWatchdog attaches the watchdog behind /api/v1/watchdog; nil when
+// the watchdog is disabled.
+func (s *Server) SetWatchdog(wd *watchdog.Watchdog) {
+	s.watchdog = wd
+}
+
+// SetFleet attaches the fleet membership behind /api/v1/fleet; nil when
+// the scrubber runs alone.
+func (s *Server) SetFleet(f *fleet.Fleet) {
+	s.fleet = f
+}
+
+// SetEscalation attaches the escalation engine whose triggers and history
+// /api/v1/escalation reports.
+func (s *Server) SetEscalation(e *escalation.Engine) {
+	s.escalation = e
+}
+
+// OnEscalationChange sets a callback invoked when the escalation level is
+// changed through the API, with the reason recorded for it.
+func (s *Server) OnEscalationChange(fn func(from, to escalation.Level, reason string)) {
+	s.onEscalationChange = fn
 }
 
 // Start starts the HTTP server and WebSocket broadcast loops.
 func (s *Server) Start() error {
+	auth, err := newAuthorizer(s.cfg.API.Auth)
+	if err != nil {
+		return err
+	}
+	s.auth = auth
+
 	mux := http.NewServeMux()
 
 	// REST endpoints
 	mux.HandleFunc("/api/v1/status", s.handleStatus)
 	mux.HandleFunc("/api/v1/status/enabled", s.handleSetEnabled)
 	mux.HandleFunc("/api/v1/stats", s.handleStats)
+	mux.HandleFunc("/api/v1/stats/history", s.handleStatsHistory)
+	mux.HandleFunc("/api/v1/stats/interval", s.handleStatsInterval)
+	mux.HandleFunc("/api/v1/top-talkers", s.handleTopTalkers)
 	mux.HandleFunc("/api/v1/acl/blacklist", s.handleBlacklist)
 	mux.HandleFunc("/api/v1/acl/whitelist", s.handleWhitelist)
+	mux.HandleFunc("/api/v1/geoip/database", s.handleGeoIPDatabase)
+	mux.HandleFunc("/api/v1/geoip/policies", s.handleGeoIPPolicies)
+	mux.HandleFunc("/api/v1/geoip/lookup", s.handleGeoIPLookup)
+	mux.HandleFunc("/api/v1/geoip/stats", s.handleGeoIPStats)
+	mux.HandleFunc("/api/v1/geoip/audit", s.handleGeoIPAudit)
+	mux.HandleFunc("/api/v1/asn/policies", s.handleASNPolicies)
+	mux.HandleFunc("/api/v1/asn/lookup", s.handleASNLookup)
+	mux.HandleFunc("/api/v1/config", s.handleConfigKeys)
+	mux.HandleFunc("/api/v1/config/", s.handleConfigKey)
 	mux.HandleFunc("/api/v1/config/rate", s.handleRateConfig)
+	mux.HandleFunc(revisionsPrefix, s.handleRevisions)
+	mux.HandleFunc(revisionsPrefix+"/", s.handleRevision)
+	mux.HandleFunc("/api/v1/config/rollback/", s.handleRollback)
+	mux.HandleFunc("/api/v1/reputation/config", s.handleReputationConfig)
+	mux.HandleFunc("/api/v1/reputation/top", s.handleReputationTop)
+	mux.HandleFunc("/api/v1/reputation/blocked", s.handleReputationBlocked)
+	mux.HandleFunc("/api/v1/reputation/threshold", s.handleReputationThreshold)
+	mux.HandleFunc("/api/v1/reputation/export", s.handleReputationExport)
+	mux.HandleFunc("/api/v1/threatintel/feeds", s.handleThreatIntelFeeds)
+	mux.HandleFunc("/api/v1/threatintel/sync", s.handleThreatIntelSync)
+	mux.HandleFunc("/api/v1/threatintel/lookup", s.handleThreatIntelLookup)
+	mux.HandleFunc("/api/v1/threatintel/stats", s.handleThreatIntelStats)
+	mux.HandleFunc("/api/v1/threat-intel/push", s.handleThreatIntelPush)
+	mux.HandleFunc("/api/v1/threatintel/push", s.handleThreatIntelPush) // Former path, kept as an alias
+	mux.HandleFunc("/api/v1/threatintel/quarantine", s.handleThreatIntelQuarantine)
+	mux.HandleFunc("/api/v1/bgp", s.handleBGP)
+	mux.HandleFunc("/api/v1/bgp/blackholes", s.handleBGPBlackholes)
+	mux.HandleFunc("/api/v1/bgp/flowspec", s.handleBGPFlowspec)
+	mux.HandleFunc("/api/v1/bgp/audit", s.handleBGPAudit)
 	mux.HandleFunc("/api/v1/conntrack", s.handleConntrack)
 	mux.HandleFunc("/api/v1/conntrack/flush", s.handleConntrackFlush)
+	mux.HandleFunc("/api/v1/conntrack/flows", s.handleConntrackFlows)
 	mux.HandleFunc("/api/v1/signatures", s.handleSignatures)
+	mux.HandleFunc("/api/v1/signatures/proposals", s.handleSignatureProposals)
+	mux.HandleFunc("/api/v1/escalation", s.handleEscalation)
+	mux.HandleFunc("/api/v1/escalation/history", s.handleEscalationHistory)
+	mux.HandleFunc("/api/v1/escalation/level", s.handleEscalationLevel)
+	mux.HandleFunc("/api/v1/escalation/pin", s.handleEscalationPin)
+	mux.HandleFunc("/api/v1/approvals", s.handleApprovals)
+	mux.HandleFunc("/api/v1/cluster", s.handleCluster)
+	mux.HandleFunc("/api/v1/capture", s.handleCapture)
+	mux.HandleFunc("/api/v1/capture/files", s.handleCaptureFiles)
+	mux.HandleFunc("/api/v1/capture/files/", s.handleCaptureFile)
+	mux.HandleFunc("/api/v1/tunnels", s.handleTunnels)
+	mux.HandleFunc("/api/v1/diversion", s.handleDiversion)
+	mux.HandleFunc("/api/v1/assets", s.handleAssets)
+	mux.HandleFunc("/api/v1/assets/stats", s.handleAssetStats)
+	mux.HandleFunc("/api/v1/tenants", s.handleTenants)
+	mux.HandleFunc("/api/v1/attacks", s.handleAttacks)
+	mux.HandleFunc("/api/v1/attacks/", s.handleAttackReport)
+	mux.HandleFunc("/api/v1/{$}", s.handleDatasourceTest)
+	mux.HandleFunc("/api/v1/search", s.handleSearch)
+	mux.HandleFunc("/api/v1/query", s.handleQuery)
+	mux.HandleFunc("/api/v1/syncookie", s.handleSYNCookie)
+	mux.HandleFunc("/api/v1/connlimit", s.handleConnLimit)
+	mux.HandleFunc("/api/v1/egress", s.handleEgress)
+	mux.HandleFunc("/api/v1/inspect", s.handleInspect)
+	mux.HandleFunc("/api/v1/httpflood", s.handleHTTPFlood)
+	mux.HandleFunc("/api/v1/baseline", s.handleBaseline)
+	mux.HandleFunc("/api/v1/baseline/reset", s.handleBaselineReset)
+	mux.HandleFunc("/api/v1/scanners", s.handleScanners)
+	mux.HandleFunc("/api/v1/handshakes", s.handleHandshakes)
+	mux.HandleFunc("/api/v1/ratelimit/sources", s.handleRateLimiters)
+	mux.HandleFunc("/api/v1/ratelimit/sources/", s.handleRateLimiter)
+	mux.HandleFunc("/api/v1/ratelimit/classes", s.handleRateClasses)
+	mux.HandleFunc("/api/v1/schedule", s.handleSchedule)
+	mux.HandleFunc("/api/v1/schedule/override", s.handleScheduleOverride)
+	mux.HandleFunc("/api/v1/maintenance", s.handleMaintenance)
+	mux.HandleFunc("/api/v1/watchdog", s.handleWatchdog)
+	mux.HandleFunc("/api/v1/fleet", s.handleFleet)
+	mux.HandleFunc("/api/v1/syncookie/destinations", s.handleSYNCookieDestinations)
+	mux.HandleFunc("/api/v1/bpf", s.handleBPF)
+	mux.HandleFunc("/api/v1/bpf/maps", s.handleBPFMaps)
+	mux.HandleFunc("/api/v1/bpf/diagnostics", s.handleBPFDiagnostics)
+	mux.HandleFunc("/api/v1/bpf/replace", s.handleBPFReplace)
+	mux.HandleFunc("/api/v1/auth/whoami", s.handleAuthWhoami)
+	mux.HandleFunc("/api/v1/auth/audit", s.handleAuthAudit)
+	mux.HandleFunc("/api/v1/debug/runtime", s.handleDebugRuntime)
+	if s.cfg.API.Pprof {
+		mux.Handle("/api/v1/debug/pprof/", pprofHandler())
+	}
 
-	// WebSocket
+	// WebSocket, and the same channels as server-sent events
 	mux.HandleFunc("/ws/realtime", s.handleWS)
+	mux.HandleFunc("/api/v1/stream", s.handleStream)
 
-	s.httpServer = &http.Server{
-		Handler: corsMiddleware(mux),
-	}
+	s.httpServer = newHTTPServer(s.cfg.API, corsMiddleware(s.authMiddleware(s.drainMiddleware(s.revisionMiddleware(mux)))))
 
-	lis, err := net.Listen("tcp", s.cfg.API.Listen)
+	lis, err := listen(s.cfg.API)
 	if err != nil {
-		return fmt.Errorf("listening on %s: %w", s.cfg.API.Listen, err)
+		return err
 	}
 
-	s.log.Info("HTTP API server starting", zap.String("listen", s.cfg.API.Listen))
+	s.log.Info("HTTP API server starting",
+		zap.String("listen", s.cfg.API.Listen),
+		zap.Bool("tls", s.cfg.API.TLS),
+		zap.Bool("client_certs", s.cfg.API.ClientCA != ""),
+		zap.Bool("auth", s.auth != nil),
+	)
 
 	go func() {
 		if err := s.httpServer.Serve(lis); err != nil && err != http.ErrServerClosed {
@@ -99,28 +427,67 @@ func (s *Server) Start() error {
 	return nil
 }
 
-// Stop gracefully stops the HTTP server.
-func (s *Server) Stop() {
+// Drain stops accepting state-changing requests. Read-only requests and
+// WebSocket streams keep working. It returns once every mutation that was
+// already in flight has completed.
+func (s *Server) Drain() {
+	s.drainMu.Lock()
+	s.draining = true
+	s.drainMu.Unlock()
+	s.log.Info("HTTP API draining, mutations rejected")
+}
+
+// Stop gracefully stops the HTTP server, waiting for open requests until
+// ctx expires.
+func (s *Server) Stop(ctx context.Context) {
+	// Shutdown waits for open requests, and SSE streams never end on
+	// their own.
+	s.wsMu.Lock()
+	for c := range s.sseClients {
+		c.close()
+	}
+	s.wsMu.Unlock()
+
 	if s.httpServer != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancel()
-		s.httpServer.Shutdown(ctx)
+		if err := s.httpServer.Shutdown(ctx); err != nil {
+			s.log.Warn("HTTP API server shutdown incomplete", zap.Error(err))
+		}
 		s.log.Info("HTTP API server stopped")
 	}
 	s.wsMu.Lock()
-	for c := range s.wsConns {
-		c.Close()
+	for _, c := range s.wsConns {
+		c.close()
 	}
 	s.wsMu.Unlock()
 }
 
-// BroadcastEvent sends a BPF event to all connected WebSocket clients.
-func (s *Server) BroadcastEvent(ev *bpf.Event) {
+// BroadcastEvent sends a BPF event and its enrichment context to WebSocket
+// clients subscribed to the events channel whose filters match it. Tenant
+// clients only get events towards their own assets.
+func (s *Server) BroadcastEvent(ev *bpf.Event, info events.Info) {
 	msg := wsMessage{
 		Type: "event",
-		Data: eventToJSON(ev),
+		Data: eventToJSON(ev, info),
+	}
+	owner := s.eventOwner(ev)
+	s.broadcast(msg, func(tenant string, sub *subscription) bool {
+		return (tenant == "" || tenant == owner) && sub.matchEvent(ev)
+	})
+}
+
+// BroadcastEscalation notifies clients subscribed to the escalation channel
+// of a level change. The level is global, so tenant clients do not get it.
+func (s *Server) BroadcastEscalation(from, to escalation.Level) {
+	msg := wsMessage{
+		Type: "escalation",
+		Data: map[string]interface{}{
+			"timestampNs": time.Now().UnixNano(),
+			"from":        from.String(),
+			"to":          to.String(),
+			"level":       uint8(to),
+		},
 	}
-	s.broadcast(msg)
+	s.broadcast(msg, func(tenant string, _ *subscription) bool { return tenant == "" })
 }
 
 // --- WebSocket ---
@@ -131,50 +498,126 @@ type wsMessage struct {
 }
 
 func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	wsCfg := s.cfg.API.WebSocket
+	s.wsMu.RLock()
+	full := len(s.wsConns)+len(s.sseClients) >= wsCfg.MaxClients
+	s.wsMu.RUnlock()
+	if full {
+		s.log.Warn("websocket client rejected, too many clients",
+			zap.String("remote", r.RemoteAddr),
+			zap.Int("max_clients", wsCfg.MaxClients),
+		)
+		http.Error(w, "too many WebSocket clients", http.StatusServiceUnavailable)
+		return
+	}
+
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		s.log.Warn("websocket upgrade failed", zap.Error(err))
 		return
 	}
+	// Streams are long-lived; drop the HTTP read/write deadlines. The
+	// client sets its own for keepalive and writes.
+	conn.UnderlyingConn().SetDeadline(time.Time{})
 
+	client := newWSClient(conn, wsCfg)
+	client.tenant = requestTenant(r)
+	client.keepalive()
 	s.wsMu.Lock()
-	s.wsConns[conn] = struct{}{}
+	s.wsConns[conn] = client
 	s.wsMu.Unlock()
+	go client.writeLoop()
 
 	s.log.Debug("websocket client connected", zap.String("remote", conn.RemoteAddr().String()))
 
-	// Read loop (just drain; client doesn't send meaningful data)
+	// Read loop: clients send subscribe requests to select channels and
+	// filters. Until they do, everything is forwarded. It also processes
+	// pongs, and ends when the client misses them or is closed.
 	for {
-		if _, _, err := conn.ReadMessage(); err != nil {
+		var req wsRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				s.replyWS(client, "error", map[string]string{"message": "invalid JSON"})
+				continue
+			}
 			break
 		}
+		s.handleWSRequest(client, req)
 	}
 
 	s.wsMu.Lock()
 	delete(s.wsConns, conn)
 	s.wsMu.Unlock()
-	conn.Close()
+	client.close()
 
-	s.log.Debug("websocket client disconnected", zap.String("remote", conn.RemoteAddr().String()))
+	s.log.Debug("websocket client disconnected",
+		zap.String("remote", conn.RemoteAddr().String()),
+		zap.Uint64("dropped_messages", client.droppedMessages()),
+	)
 }
 
-func (s *Server) broadcast(msg wsMessage) {
-	data, err := json.Marshal(msg)
+func (s *Server) handleWSRequest(client *wsClient, req wsRequest) {
+	switch req.Action {
+	case "subscribe":
+		sub, err := newSubscription(req)
+		if err != nil {
+			s.replyWS(client, "error", map[string]string{"message": err.Error()})
+			return
+		}
+		client.setSubscription(sub)
+		s.replyWS(client, "subscribed", sub.describe())
+
+	case "unsubscribe":
+		sub := &subscription{channels: map[string]bool{}}
+		client.setSubscription(sub)
+		s.replyWS(client, "subscribed", sub.describe())
+
+	default:
+		s.replyWS(client, "error", map[string]string{
+			"message": fmt.Sprintf("unknown action %q", req.Action),
+		})
+	}
+}
+
+func (s *Server) replyWS(client *wsClient, msgType string, data interface{}) {
+	b, err := json.Marshal(wsMessage{Type: msgType, Data: data})
 	if err != nil {
 		return
 	}
+	client.send(b)
+}
 
-	s.wsMu.RLock()
-	defer s.wsMu.RUnlock()
+// broadcast queues msg for every WebSocket and SSE client subscribed to
+// its channel, and keeps it for SSE clients that resume later. If match is
+// non-nil it must also accept the client's tenant and subscription.
+// Queueing never blocks, so a slow client cannot hold up the others.
+func (s *Server) broadcast(msg wsMessage, match func(tenant string, sub *subscription) bool) {
+	data, err := json.Marshal(msg.Data)
+	if err != nil {
+		return
+	}
+	wsData, err := json.Marshal(wsMessage{Type: msg.Type, Data: json.RawMessage(data)})
+	if err != nil {
+		return
+	}
+
+	s.wsMu.Lock()
+	defer s.wsMu.Unlock()
+
+	s.streamSeq++
+	e := streamEntry{id: s.streamSeq, typ: msg.Type, frame: sseFrame(s.streamSeq, msg.Type, data), match: match}
+	s.recent.add(e)
 
-	for c := range s.wsConns {
-		if err := c.WriteMessage(websocket.TextMessage, data); err != nil {
-			c.Close()
-			go func(conn *websocket.Conn) {
-				s.wsMu.Lock()
-				delete(s.wsConns, conn)
-				s.wsMu.Unlock()
-			}(c)
+	for _, c := range s.wsConns {
+		if e.wanted(c.tenant, c.current()) {
+			c.send(wsData)
+		}
+	}
+	for c := range s.sseClients {
+		if e.wanted(c.tenant, c.sub) {
+			c.send(e.frame)
 		}
 	}
 }
@@ -186,7 +629,8 @@ func (s *Server) broadcastStats() {
 			Type: "stats",
 			Data: snapshotToJSON(snap),
 		}
-		s.broadcast(msg)
+		// Global counters cover every tenant's traffic.
+		s.broadcast(msg, func(tenant string, _ *subscription) bool { return tenant == "" })
 	}
 }
 
@@ -211,6 +655,12 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 		"escalationLevel": escLevel,
 		"pipelineStages":  18,
 	}
+	if s.cluster != nil {
+		resp["role"] = s.cluster.Role()
+	}
+	if s.maintenance != nil {
+		resp["maintenance"] = s.maintenance.Status().State
+	}
 	writeJSON(w, resp)
 }
 
@@ -227,6 +677,9 @@ func (s *Server) handleSetEnabled(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid JSON", http.StatusBadRequest)
 		return
 	}
+	if s.maintenanceConflict(w) {
+		return
+	}
 
 	var val uint64
 	if req.Enabled {
@@ -246,6 +699,12 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	q := r.URL.Query()
+	if q.Get("prefix") != "" || q.Get("tenant") != "" || requestTenant(r) != "" {
+		s.handlePrefixStats(w, r)
+		return
+	}
+
 	snap := s.stats.Current()
 	if snap == nil {
 		writeJSON(w, map[string]interface{}{})
@@ -254,16 +713,144 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, snapshotToJSON(snap))
 }
 
+// handleStatsInterval shows (GET) or changes (PUT) how often BPF stats
+// are collected, e.g. to 100ms during an attack and back to 1s after.
+func (s *Server) handleStatsInterval(w http.ResponseWriter, r *http.Request) {
+	if s.stats == nil {
+		http.Error(w, "stats collector not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]int64{"intervalMs": s.stats.Interval().Milliseconds()})
+
+	case http.MethodPut:
+		var req struct {
+			IntervalMS int64 `json:"intervalMs"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := s.stats.SetInterval(time.Duration(req.IntervalMS) * time.Millisecond); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]int64{"intervalMs": req.IntervalMS})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleStatsHistory returns the rate series of the last window (default
+// 1h), averaged into step buckets (default: sized to fit the point limit).
+func (s *Server) handleStatsHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.history == nil {
+		http.Error(w, "stats history not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	window := time.Hour
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid window", http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+	var step time.Duration
+	if v := r.URL.Query().Get("step"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid step", http.StatusBadRequest)
+			return
+		}
+		step = d
+	}
+
+	points, step, err := s.history.Query(window, step, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if points == nil {
+		points = []stats.Point{}
+	}
+	writeJSON(w, map[string]interface{}{
+		"windowSeconds":     window.Seconds(),
+		"stepSeconds":       step.Seconds(),
+		"resolutionSeconds": s.history.Resolution().Seconds(),
+		"points":            points,
+	})
+}
+
+func (s *Server) handleTopTalkers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.topTalkers == nil {
+		http.Error(w, "top talkers not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	sortBy := r.URL.Query().Get("sort")
+	switch sortBy {
+	case "":
+		sortBy = stats.SortByPPS
+	case stats.SortByPPS, stats.SortByBPS, stats.SortByDrops:
+	default:
+		http.Error(w, "sort must be pps, bps, or drops", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"windowSeconds": s.topTalkers.Window().Seconds(),
+		"sort":          sortBy,
+		"talkers":       s.topTalkers.Top(limit, sortBy),
+	})
+}
+
 func (s *Server) handleBlacklist(w http.ResponseWriter, r *http.Request) {
+	if tenant := requestTenant(r); tenant != "" {
+		s.handleTenantACL(w, r, tenant, bpf.TenantBlacklist)
+		return
+	}
 	switch r.Method {
 	case http.MethodGet:
-		// Return empty list (BPF LPM trie iteration not trivially supported)
-		writeJSON(w, []interface{}{})
+		idle, ok := idleParam(w, r)
+		if !ok {
+			return
+		}
+		entries, err := s.maps.BlacklistEntries()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, aclToJSON(entries, s.maps.BlacklistExpiry(), idle, time.Now()))
 
 	case http.MethodPost:
 		var req struct {
 			CIDR   string `json:"cidr"`
 			Reason uint32 `json:"reason"`
+			TTLSec uint64 `json:"ttlSec"` // 0 = permanent
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "invalid JSON", http.StatusBadRequest)
@@ -272,11 +859,12 @@ func (s *Server) handleBlacklist(w http.ResponseWriter, r *http.Request) {
 		if req.Reason == 0 {
 			req.Reason = bpf.DropBlacklist
 		}
-		if err := s.maps.AddBlacklistCIDR(req.CIDR, req.Reason); err != nil {
+		ttl := time.Duration(req.TTLSec) * time.Second
+		if err := s.maps.AddBlacklistCIDRWithTTL(req.CIDR, req.Reason, ttl); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		s.log.Info("blacklist entry added via API", zap.String("cidr", req.CIDR))
+		s.log.Info("blacklist entry added via API", zap.String("cidr", req.CIDR), zap.Duration("ttl", ttl))
 		writeJSON(w, map[string]bool{"ok": true})
 
 	case http.MethodDelete:
@@ -299,10 +887,74 @@ func (s *Server) handleBlacklist(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// idleParam parses the optional idle query parameter, which limits a
+// listing to the entries not hit within it. It writes the error response
+// and returns false if the parameter is invalid.
+func idleParam(w http.ResponseWriter, r *http.Request) (time.Duration, bool) {
+	v := r.URL.Query().Get("idle")
+	if v == "" {
+		return 0, true
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		http.Error(w, "invalid idle", http.StatusBadRequest)
+		return 0, false
+	}
+	return d, true
+}
+
+// aclToJSON lists blacklist or whitelist entries sorted by CIDR, with their
+// hit counters. Temporary entries carry their expiry and the seconds left
+// until it. A non-zero idle keeps only the entries not hit within it.
+func aclToJSON(entries map[string]bpf.ACLRule, expiry map[string]time.Time, idle time.Duration, now time.Time) []map[string]interface{} {
+	cidrs := make([]string, 0, len(entries))
+	for cidr, rule := range entries {
+		if idle > 0 && !rule.LastHit.IsZero() && now.Sub(rule.LastHit) < idle {
+			continue
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+
+	result := make([]map[string]interface{}, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		rule := entries[cidr]
+		e := map[string]interface{}{
+			"cidr":    cidr,
+			"reason":  rule.Reason,
+			"hits":    rule.Hits,
+			"lastHit": formatTime(rule.LastHit),
+		}
+		if t, ok := expiry[cidr]; ok {
+			remaining := t.Sub(now)
+			if remaining < 0 {
+				remaining = 0
+			}
+			e["expiresAt"] = formatTime(t)
+			e["ttlRemainingSec"] = int64(remaining.Round(time.Second) / time.Second)
+		}
+		result = append(result, e)
+	}
+	return result
+}
+
 func (s *Server) handleWhitelist(w http.ResponseWriter, r *http.Request) {
+	if tenant := requestTenant(r); tenant != "" {
+		s.handleTenantACL(w, r, tenant, bpf.TenantWhitelist)
+		return
+	}
 	switch r.Method {
 	case http.MethodGet:
-		writeJSON(w, []interface{}{})
+		idle, ok := idleParam(w, r)
+		if !ok {
+			return
+		}
+		entries, err := s.maps.WhitelistRules()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, aclToJSON(entries, nil, idle, time.Now()))
 
 	case http.MethodPost:
 		var req struct {
@@ -339,6 +991,97 @@ func (s *Server) handleWhitelist(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) handleASNPolicies(w http.ResponseWriter, r *http.Request) {
+	if s.asn == nil {
+		http.Error(w, "asn data not loaded", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		policies := s.asn.GetPolicies()
+		result := make([]map[string]interface{}, 0, len(policies))
+		for _, p := range policies {
+			result = append(result, map[string]interface{}{
+				"asn":          p.ASN,
+				"organization": p.Organization,
+				"action":       geoip.ActionName(p.Action),
+			})
+		}
+		writeJSON(w, map[string]interface{}{
+			"prefixes": s.asn.GetLoadedPrefixes(),
+			"policies": result,
+		})
+
+	case http.MethodPost:
+		var req struct {
+			ASN    uint32 `json:"asn"`
+			Action string `json:"action"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		action, err := geoip.ParseAction(req.Action)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.asn.SetPolicy(req.ASN, action); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.log.Info("asn policy set via API", zap.Uint32("asn", req.ASN), zap.String("action", req.Action))
+		writeJSON(w, map[string]bool{"ok": true})
+
+	case http.MethodDelete:
+		var req struct {
+			ASN uint32 `json:"asn"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := s.asn.RemovePolicy(req.ASN); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.log.Info("asn policy removed via API", zap.Uint32("asn", req.ASN))
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleASNLookup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.asn == nil {
+		http.Error(w, "asn data not loaded", http.StatusServiceUnavailable)
+		return
+	}
+
+	ip := net.ParseIP(r.URL.Query().Get("ip")).To4()
+	if ip == nil {
+		http.Error(w, "ip must be an IPv4 address", http.StatusBadRequest)
+		return
+	}
+	asn, org := s.asn.Lookup(ip)
+	action := "pass"
+	if a, ok := s.asn.Policy(asn); ok {
+		action = geoip.ActionName(a)
+	}
+	writeJSON(w, map[string]interface{}{
+		"ip":           ip.String(),
+		"asn":          asn,
+		"organization": org,
+		"action":       action,
+	})
+}
+
 func (s *Server) handleRateConfig(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
@@ -365,6 +1108,8 @@ func (s *Server) handleRateConfig(w http.ResponseWriter, r *http.Request) {
 			ICMPRatePPS   uint64 `json:"icmpRatePps"`
 			GlobalPPS     uint64 `json:"globalPpsLimit"`
 			GlobalBPS     uint64 `json:"globalBpsLimit"`
+			// Optional; omitted leaves adaptive mode unchanged
+			AdaptiveEnabled *bool `json:"adaptiveEnabled"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "invalid JSON", http.StatusBadRequest)
@@ -377,6 +1122,12 @@ func (s *Server) handleRateConfig(w http.ResponseWriter, r *http.Request) {
 			bpf.CfgGlobalPPSLimit: req.GlobalPPS,
 			bpf.CfgGlobalBPSLimit: req.GlobalBPS,
 		}
+		if req.AdaptiveEnabled != nil {
+			configs[bpf.CfgAdaptiveRate] = 0
+			if *req.AdaptiveEnabled {
+				configs[bpf.CfgAdaptiveRate] = 1
+			}
+		}
 		for key, val := range configs {
 			if err := s.maps.SetConfig(key, val); err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -417,6 +1168,91 @@ func (s *Server) handleConntrackFlush(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, map[string]interface{}{"entriesRemoved": count})
 }
 
+// Conntrack listing page sizes.
+const (
+	defaultConntrackLimit = 100
+	maxConntrackLimit     = 1000
+)
+
+func (s *Server) handleConntrackFlows(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	var filter bpf.ConntrackFilter
+	if v := q.Get("src"); v != "" {
+		n, err := parseCIDROrIP(v)
+		if err != nil {
+			http.Error(w, "invalid src prefix", http.StatusBadRequest)
+			return
+		}
+		filter.SrcNet = n
+	}
+	if v := q.Get("dst"); v != "" {
+		n, err := parseCIDROrIP(v)
+		if err != nil {
+			http.Error(w, "invalid dst prefix", http.StatusBadRequest)
+			return
+		}
+		filter.DstNet = n
+	}
+	if v := q.Get("proto"); v != "" {
+		p, ok := parseProtocol(v)
+		if !ok {
+			http.Error(w, "proto must be tcp, udp, icmp, or a protocol number", http.StatusBadRequest)
+			return
+		}
+		filter.Protocol = p
+	}
+	if v := q.Get("state"); v != "" {
+		st, ok := bpf.ParseConntrackState(v)
+		if !ok {
+			http.Error(w, "unknown conntrack state", http.StatusBadRequest)
+			return
+		}
+		filter.State = &st
+	}
+
+	offset := 0
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+	limit := defaultConntrackLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxConntrackLimit {
+			http.Error(w, fmt.Sprintf("limit must be 1-%d", maxConntrackLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	flows, total, err := s.maps.ConntrackList(filter, offset, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	out := make([]map[string]interface{}, 0, len(flows))
+	for _, f := range flows {
+		out = append(out, flowToJSON(f, now))
+	}
+	writeJSON(w, map[string]interface{}{
+		"total":  total,
+		"offset": offset,
+		"limit":  limit,
+		"flows":  out,
+	})
+}
+
 func (s *Server) handleSignatures(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
@@ -468,6 +1304,235 @@ func (s *Server) handleSignatures(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) handleSignatureProposals(w http.ResponseWriter, r *http.Request) {
+	if s.sigLearner == nil {
+		http.Error(w, "signature learning not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.sigLearner.Proposals())
+
+	case http.MethodPost:
+		var req struct {
+			ID     uint32 `json:"id"`
+			Action string `json:"action"` // "approve" or "reject"
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		var (
+			p   siglearn.Proposal
+			err error
+		)
+		switch req.Action {
+		case "approve":
+			p, err = s.sigLearner.Approve(req.ID)
+		case "reject":
+			p, err = s.sigLearner.Reject(req.ID)
+		default:
+			http.Error(w, "action must be approve or reject", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, siglearn.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		s.log.Info("signature proposal updated via API",
+			zap.Uint32("id", req.ID), zap.String("action", req.Action))
+		writeJSON(w, p)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleEscalation(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		level, _ := s.maps.GetConfig(bpf.CfgEscalationLevel)
+		resp := map[string]interface{}{
+			"level": level,
+			"name":  escalation.Level(level).String(),
+		}
+		if s.escalation != nil {
+			resp["triggers"] = triggersToJSON(s.escalation.GetTriggers(), false)
+			indicators := make(map[string]string)
+			for name, l := range s.escalation.Indicators() {
+				indicators[name] = l.String()
+			}
+			resp["indicators"] = indicators
+			if pin, ok := s.escalation.GetPin(); ok {
+				resp["pin"] = pinToJSON(pin)
+			}
+			resp["levelSince"] = formatTime(s.escalation.LevelSince())
+			resp["minDwellSec"] = s.escalation.MinDwell(escalation.Level(level)).Seconds()
+			resp["maxLevel"] = s.escalation.MaxLevel().String()
+			resp["prefixes"] = prefixLevelsToJSON(s.escalation.PrefixLevels())
+		}
+		writeJSON(w, resp)
+
+	case http.MethodPut:
+		var req struct {
+			Level uint64 `json:"level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Level > uint64(escalation.Critical) {
+			http.Error(w, "level must be 0-3", http.StatusBadRequest)
+			return
+		}
+		if err := s.setEscalationLevel(r, escalation.Level(req.Level), ""); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleCluster(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.cluster == nil {
+		http.Error(w, "cluster sync not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, s.cluster.Status())
+}
+
+func (s *Server) handleCapture(w http.ResponseWriter, r *http.Request) {
+	if s.capturer == nil {
+		http.Error(w, "packet capture not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.capturer.Status())
+
+	case http.MethodPost:
+		var req struct {
+			Mode        string `json:"mode"` // "drops" (default) or "all"
+			DurationSec uint64 `json:"durationSec"`
+			MaxPackets  uint64 `json:"maxPackets"`
+			SampleRate  uint32 `json:"sampleRate"`
+			Snaplen     uint32 `json:"snaplen"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		mode, ok := capture.ParseMode(req.Mode)
+		if !ok {
+			http.Error(w, "mode must be drops or all", http.StatusBadRequest)
+			return
+		}
+		st, err := s.capturer.Start(capture.Options{
+			Mode:       mode,
+			Duration:   time.Duration(req.DurationSec) * time.Second,
+			MaxPackets: req.MaxPackets,
+			SampleRate: req.SampleRate,
+			Snaplen:    req.Snaplen,
+			Reason:     "started via API",
+		})
+		if errors.Is(err, capture.ErrActive) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, st)
+
+	case http.MethodDelete:
+		writeJSON(w, s.capturer.Stop())
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleCaptureFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.capturer == nil {
+		http.Error(w, "packet capture not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	files, err := s.capturer.Files()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if files == nil {
+		files = []capture.FileInfo{}
+	}
+	writeJSON(w, files)
+}
+
+// handleCaptureFile downloads (GET) or deletes (DELETE) a single pcap file.
+func (s *Server) handleCaptureFile(w http.ResponseWriter, r *http.Request) {
+	if s.capturer == nil {
+		http.Error(w, "packet capture not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/api/v1/capture/files/")
+
+	switch r.Method {
+	case http.MethodGet:
+		f, err := s.capturer.Open(name)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				http.Error(w, "capture file not found", http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.tcpdump.pcap")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		http.ServeContent(w, r, name, info.ModTime(), f)
+
+	case http.MethodDelete:
+		if err := s.capturer.Remove(name); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				http.Error(w, "capture file not found", http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+		s.log.Info("capture file removed via API", zap.String("file", name))
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
 // --- Helpers ---
 
 func writeJSON(w http.ResponseWriter, v interface{}) {
@@ -475,11 +1540,36 @@ func writeJSON(w http.ResponseWriter, v interface{}) {
 	json.NewEncoder(w).Encode(v)
 }
 
+// drainMiddleware rejects state-changing requests once Drain was called
+// and tracks in-flight ones so Drain can wait for them.
+func (s *Server) drainMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		if queryPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		s.drainMu.RLock()
+		defer s.drainMu.RUnlock()
+		if s.draining {
+			w.Header().Set("Retry-After", "30")
+			http.Error(w, "scrubber is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -520,12 +1610,19 @@ func snapshotToJSON(snap *stats.Snapshot) map[string]interface{} {
 		"ssdpAmpDropped":        st.SSDPAmpDropped,
 		"memcachedAmpDropped":   st.MemcachedAmpDropped,
 		"threatIntelDropped":    st.ThreatIntelDropped,
+		"asnDropped":            st.ASNDropped,
+		"connLimitDropped":      st.ConnLimitDropped,
 		"reputationAutoBlocked": st.ReputationAutoBlocked,
 		"dnsQueriesValidated":   st.DNSQueriesValidated,
 		"dnsQueriesBlocked":     st.DNSQueriesBlocked,
 		"ntpMonlistBlocked":     st.NTPMonlistBlocked,
 		"tcpStateViolations":    st.TCPStateViolations,
 		"portScanDetected":      st.PortScanDetected,
+		"eventsLost":            st.EventsLost,
+		"tlsHandshakes":         st.TLSHandshakes,
+		"quicInitials":          st.QUICInitials,
+		"tlsFloodDropped":       st.TLSFloodDropped,
+		"quicFloodDropped":      st.QUICFloodDropped,
 		// Rates
 		"rxPps":   snap.RxPPS,
 		"rxBps":   snap.RxBPS,
@@ -533,11 +1630,15 @@ func snapshotToJSON(snap *stats.Snapshot) map[string]interface{} {
 		"txBps":   snap.TxBPS,
 		"dropPps": snap.DropPPS,
 		"dropBps": snap.DropBPS,
+		"synPps":  snap.SYNPPS,
+		"udpPps":  snap.UDPPPS,
+		"icmpPps": snap.ICMPPPS,
+		"dnsPps":  snap.DNSPPS,
 	}
 }
 
-func eventToJSON(ev *bpf.Event) map[string]interface{} {
-	return map[string]interface{}{
+func eventToJSON(ev *bpf.Event, info events.Info) map[string]interface{} {
+	m := map[string]interface{}{
 		"timestampNs":     ev.TimestampNS,
 		"srcIp":           bpf.U32BEToIP(ev.SrcIP).String(),
 		"dstIp":           bpf.U32BEToIP(ev.DstIP).String(),
@@ -553,6 +1654,54 @@ func eventToJSON(ev *bpf.Event) map[string]interface{} {
 		"countryCode":     countryCodeStr(ev.CountryCode),
 		"escalationLevel": ev.EscalationLevel,
 	}
+	if info.ASN != 0 {
+		m["asn"] = info.ASN
+		m["asOrg"] = info.ASOrg
+	}
+	if info.Hostname != "" {
+		m["hostname"] = info.Hostname
+	}
+	if n := info.Events(); n > 1 {
+		m["count"] = n
+	}
+	return m
+}
+
+func flowToJSON(f bpf.ConntrackFlow, now time.Time) map[string]interface{} {
+	k, e := f.Key, f.Entry
+	return map[string]interface{}{
+		"srcIp":      bpf.U32BEToIP(k.SrcIP).String(),
+		"dstIp":      bpf.U32BEToIP(k.DstIP).String(),
+		"srcPort":    ntohs(k.SrcPort),
+		"dstPort":    ntohs(k.DstPort),
+		"protocol":   k.Protocol,
+		"state":      bpf.ConntrackStateName(e.State),
+		"suspect":    e.Flags&bpf.CTFlagSuspect != 0,
+		"flags":      e.Flags,
+		"packetsFwd": e.PacketsFwd,
+		"packetsRev": e.PacketsRev,
+		"bytesFwd":   e.BytesFwd,
+		"bytesRev":   e.BytesRev,
+		"violations": e.ViolationCount,
+		"lastSeen":   stats.BootNSToTime(e.LastSeenNS, now),
+	}
+}
+
+// parseProtocol accepts tcp, udp, icmp, or an IP protocol number.
+func parseProtocol(s string) (uint8, bool) {
+	switch strings.ToLower(s) {
+	case "tcp":
+		return 6, true
+	case "udp":
+		return 17, true
+	case "icmp":
+		return 1, true
+	}
+	n, err := strconv.ParseUint(s, 10, 8)
+	if err != nil || n == 0 {
+		return 0, false
+	}
+	return uint8(n), true
 }
 
 func actionName(a uint8) string {
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/stats"
+	"go.uber.org/zap"
+)
+
+func TestStatsInterval(t *testing.T) {
+	const path = "/api/v1/stats/interval"
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	s.handleStatsInterval(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without collector: status = %d, want 503", rec.Code)
+	}
+
+	c := stats.NewCollector(zap.NewNop(), nil, time.Second)
+	s = NewServer(zap.NewNop(), nil, nil, c, nil)
+	tests := []struct {
+		method string
+		body   string
+		want   int
+	}{
+		{http.MethodGet, "", http.StatusOK},
+		{http.MethodPut, `{"intervalMs":100}`, http.StatusOK},
+		{http.MethodPut, `{"intervalMs":1}`, http.StatusBadRequest},
+		{http.MethodPut, `{"intervalMs":`, http.StatusBadRequest},
+		{http.MethodPost, `{"intervalMs":100}`, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleStatsInterval(rec, httptest.NewRequest(tt.method, path, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s %q: status = %d, want %d", tt.method, tt.body, rec.Code, tt.want)
+		}
+	}
+	if got := c.Interval(); got != 100*time.Millisecond {
+		t.Errorf("interval = %v, want 100ms", got)
+	}
+}
+
+func TestACLToJSON(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	entries := map[string]bpf.ACLRule{
+		"203.0.113.0/24":  {Reason: bpf.DropBlacklist, Hits: 7, LastHit: now.Add(-time.Minute)},
+		"198.51.100.0/24": {Reason: bpf.DropBlacklist, Hits: 3, LastHit: now.Add(-48 * time.Hour)},
+		"192.0.2.0/24":    {Reason: bpf.DropBlacklist},
+	}
+	expiry := map[string]time.Time{"192.0.2.0/24": now.Add(90 * time.Second)}
+
+	all := aclToJSON(entries, expiry, 0, now)
+	if len(all) != 3 || all[0]["cidr"] != "192.0.2.0/24" {
+		t.Fatalf("entries = %v", all)
+	}
+	if all[0]["lastHit"] != "" || all[0]["ttlRemainingSec"] != int64(90) {
+		t.Errorf("never-hit temporary entry = %v", all[0])
+	}
+	if all[2]["hits"] != uint64(7) || all[2]["lastHit"] != "2024-05-01T11:59:00Z" {
+		t.Errorf("hit entry = %v", all[2])
+	}
+
+	idle := aclToJSON(entries, expiry, 24*time.Hour, now)
+	if len(idle) != 2 || idle[0]["cidr"] != "192.0.2.0/24" || idle[1]["cidr"] != "198.51.100.0/24" {
+		t.Errorf("idle entries = %v", idle)
+	}
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// streamReplay is how many recent broadcasts are kept for SSE clients
+// resuming with Last-Event-ID.
+const streamReplay = 1024
+
+// streamEntry is one broadcast message as kept for replay.
+type streamEntry struct {
+	id    uint64
+	typ   string
+	frame []byte // SSE encoding of the message
+	match func(tenant string, sub *subscription) bool
+}
+
+// wanted reports whether a client with the tenant and subscription should
+// receive the message.
+func (e *streamEntry) wanted(tenant string, sub *subscription) bool {
+	return sub.wants(e.typ) && (e.match == nil || e.match(tenant, sub))
+}
+
+// streamLog is a ring of the most recent broadcasts.
+type streamLog struct {
+	buf  []streamEntry
+	next int
+	full bool
+}
+
+func newStreamLog(size int) *streamLog {
+	return &streamLog{buf: make([]streamEntry, size)}
+}
+
+func (l *streamLog) add(e streamEntry) {
+	l.buf[l.next] = e
+	l.next = (l.next + 1) % len(l.buf)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// since returns the retained entries after id, oldest first.
+func (l *streamLog) since(id uint64) []streamEntry {
+	var ordered []streamEntry
+	if l.full {
+		ordered = append(ordered, l.buf[l.next:]...)
+	}
+	ordered = append(ordered, l.buf[:l.next]...)
+	for i, e := range ordered {
+		if e.id > id {
+			return ordered[i:]
+		}
+	}
+	return nil
+}
+
+// sseFrame encodes a message as a server-sent event. JSON never contains
+// raw newlines, so data fits on one line.
+func sseFrame(id uint64, typ string, data []byte) []byte {
+	return []byte(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", id, typ, data))
+}
+
+// sseClient is a connected /api/v1/stream client. Its subscription is
+// fixed by the request's query parameters.
+type sseClient struct {
+	sendQueue
+
+	tenant string
+	sub    *subscription
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *sseClient) close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+// streamSubscription builds a subscription from query parameters that
+// mirror the WebSocket subscribe message: channels, attackTypes and
+// srcPrefixes as comma-separated lists, and minPps.
+func streamSubscription(q url.Values) (*subscription, error) {
+	list := func(key string) []string {
+		if v := q.Get(key); v != "" {
+			return strings.Split(v, ",")
+		}
+		return nil
+	}
+	req := wsRequest{
+		Channels: list("channels"),
+		Filters: wsFilters{
+			AttackTypes: list("attackTypes"),
+			SrcPrefixes: list("srcPrefixes"),
+		},
+	}
+	if v := q.Get("minPps"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid minPps %q", v)
+		}
+		req.Filters.MinPPS = n
+	}
+	return newSubscription(req)
+}
+
+// handleStream streams the WebSocket channels as server-sent events for
+// clients behind proxies that break WebSockets. Each event carries the
+// message type as its event name and an ID; a client reconnecting with
+// Last-Event-ID (or ?lastEventId=) first receives the retained messages
+// it missed.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sub, err := streamSubscription(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var lastID uint64
+	if v := r.Header.Get("Last-Event-ID"); v != "" || r.URL.Query().Has("lastEventId") {
+		if v == "" {
+			v = r.URL.Query().Get("lastEventId")
+		}
+		if lastID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	wsCfg := s.cfg.API.WebSocket
+	client := &sseClient{
+		sendQueue: newSendQueue(wsCfg.SendQueue),
+		tenant:    requestTenant(r),
+		sub:       sub,
+		done:      make(chan struct{}),
+	}
+
+	// Registering and queueing the backlog under the lock means no
+	// broadcast is missed or sent twice in between.
+	s.wsMu.Lock()
+	if len(s.wsConns)+len(s.sseClients) >= wsCfg.MaxClients {
+		s.wsMu.Unlock()
+		http.Error(w, "too many streaming clients", http.StatusServiceUnavailable)
+		return
+	}
+	s.sseClients[client] = struct{}{}
+	if lastID > 0 {
+		for _, e := range s.recent.since(lastID) {
+			if e.wanted(client.tenant, sub) {
+				client.send(e.frame)
+			}
+		}
+	}
+	s.wsMu.Unlock()
+
+	defer func() {
+		s.wsMu.Lock()
+		delete(s.sseClients, client)
+		s.wsMu.Unlock()
+		s.log.Debug("stream client disconnected",
+			zap.String("remote", r.RemoteAddr),
+			zap.Uint64("dropped_messages", client.droppedMessages()),
+		)
+	}()
+	s.log.Debug("stream client connected", zap.String("remote", r.RemoteAddr))
+
+	// The stream outlives the server's read and write timeouts (an expired
+	// read deadline would cancel the request); each write gets its own
+	// deadline instead.
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+	timeout := time.Duration(wsCfg.WriteTimeoutSec) * time.Second
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // Stop nginx buffering the stream
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	// Proxies close idle connections; comments keep the stream busy.
+	ping := time.NewTicker(time.Duration(wsCfg.PingIntervalSec) * time.Second)
+	defer ping.Stop()
+
+	write := func(b []byte) bool {
+		rc.SetWriteDeadline(time.Now().Add(timeout))
+		_, err := w.Write(b)
+		return err == nil
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-client.done:
+			return
+		case <-client.wake:
+			for _, frame := range client.take() {
+				if !write(frame) {
+					return
+				}
+			}
+		case <-ping.C:
+			if !write([]byte(": ping\n\n")) {
+				return
+			}
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"go.uber.org/zap"
+)
+
+func TestStreamLogSince(t *testing.T) {
+	l := newStreamLog(3)
+	for id := uint64(1); id <= 5; id++ {
+		l.add(streamEntry{id: id})
+	}
+
+	tests := []struct {
+		after uint64
+		want  []uint64
+	}{
+		{0, []uint64{3, 4, 5}}, // 1 and 2 were overwritten
+		{3, []uint64{4, 5}},
+		{5, nil},
+	}
+	for _, tt := range tests {
+		var got []uint64
+		for _, e := range l.since(tt.after) {
+			got = append(got, e.id)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("since(%d) = %v, want %v", tt.after, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("since(%d) = %v, want %v", tt.after, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestStreamSubscription(t *testing.T) {
+	sub, err := streamSubscription(url.Values{
+		"channels":    {"events,escalation"},
+		"attackTypes": {"syn_flood"},
+		"minPps":      {"1000"},
+	})
+	if err != nil {
+		t.Fatalf("streamSubscription: %v", err)
+	}
+	if sub.wants("stats") || !sub.wants("event") || !sub.wants("escalation") {
+		t.Errorf("channels = %v", sub.channels)
+	}
+	if sub.minPPS != 1000 || !sub.attackTypes["syn_flood"] {
+		t.Errorf("filters = %+v", sub.filters)
+	}
+
+	for _, q := range []url.Values{
+		{"channels": {"alerts"}},
+		{"minPps": {"lots"}},
+		{"srcPrefixes": {"not-an-ip"}},
+	} {
+		if _, err := streamSubscription(q); err == nil {
+			t.Errorf("streamSubscription(%v) should fail", q)
+		}
+	}
+}
+
+func TestStreamTenantEscalation(t *testing.T) {
+	s := NewServer(zap.NewNop(), config.DefaultConfig(), nil, nil, nil)
+	defer s.Stop(context.Background())
+
+	s.BroadcastEscalation(escalation.Low, escalation.High)
+	entries := s.recent.since(0)
+	if len(entries) != 1 {
+		t.Fatalf("%d broadcasts logged, want 1", len(entries))
+	}
+	sub := defaultSubscription()
+	if !entries[0].wanted("", sub) {
+		t.Error("unscoped client does not get the escalation")
+	}
+	// Neither live nor replayed on resume: replay applies the same match.
+	if entries[0].wanted("acme", sub) {
+		t.Error("tenant client gets the global escalation level")
+	}
+}
+
+func TestStreamResume(t *testing.T) {
+	s := NewServer(zap.NewNop(), config.DefaultConfig(), nil, nil, nil)
+	ts := httptest.NewServer(http.HandlerFunc(s.handleStream))
+	defer ts.Close()
+	defer s.Stop(context.Background())
+
+	for _, level := range []string{"MEDIUM", "HIGH", "CRITICAL"} {
+		s.broadcast(wsMessage{Type: "escalation", Data: level}, nil)
+	}
+	s.broadcast(wsMessage{Type: "stats", Data: 1}, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"?channels=escalation", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	// Messages 2 and 3 are replayed; 4 is on an unsubscribed channel.
+	r := bufio.NewReader(resp.Body)
+	want := []string{
+		"id: 2", "event: escalation", `data: "HIGH"`, "",
+		"id: 3", "event: escalation", `data: "CRITICAL"`, "",
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i, w := range want {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Errorf("line %d: %v", i, err)
+				return
+			}
+			if got := strings.TrimSuffix(line, "\n"); got != w {
+				t.Errorf("line %d = %q, want %q", i, got, w)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out reading replayed events")
+	}
+}
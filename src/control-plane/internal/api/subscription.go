@@ -0,0 +1,156 @@
+package api
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+)
+
+// WebSocket channels a client can subscribe to.
+const (
+	ChannelStats      = "stats"
+	ChannelEvents     = "events"
+	ChannelEscalation = "escalation"
+)
+
+// channelForType maps outgoing message types to their channel.
+var channelForType = map[string]string{
+	"stats":      ChannelStats,
+	"event":      ChannelEvents,
+	"escalation": ChannelEscalation,
+}
+
+// wsRequest is a control message sent by a WebSocket client:
+//
+//	{"action":"subscribe","channels":["events"],
+//	 "filters":{"attackTypes":["syn_flood"],"minPps":10000,"srcPrefixes":["203.0.113.0/24"]}}
+type wsRequest struct {
+	Action   string    `json:"action"`
+	Channels []string  `json:"channels"`
+	Filters  wsFilters `json:"filters"`
+}
+
+// wsFilters restrict which events are forwarded. They do not apply to the
+// stats and escalation channels.
+type wsFilters struct {
+	AttackTypes []string `json:"attackTypes,omitempty"`
+	MinPPS      uint64   `json:"minPps,omitempty"`
+	SrcPrefixes []string `json:"srcPrefixes,omitempty"`
+}
+
+// subscription is the compiled form of a subscribe request.
+type subscription struct {
+	channels    map[string]bool
+	attackTypes map[string]bool
+	minPPS      uint64
+	srcNets     []*net.IPNet
+	filters     wsFilters
+}
+
+// defaultSubscription forwards everything, which keeps clients that never
+// send a subscribe message working as before.
+func defaultSubscription() *subscription {
+	return &subscription{
+		channels: map[string]bool{
+			ChannelStats:      true,
+			ChannelEvents:     true,
+			ChannelEscalation: true,
+		},
+	}
+}
+
+// newSubscription validates a subscribe request. An empty channel list
+// selects all channels.
+func newSubscription(req wsRequest) (*subscription, error) {
+	sub := defaultSubscription()
+	if len(req.Channels) > 0 {
+		sub.channels = make(map[string]bool, len(req.Channels))
+		for _, ch := range req.Channels {
+			switch ch {
+			case ChannelStats, ChannelEvents, ChannelEscalation:
+				sub.channels[ch] = true
+			default:
+				return nil, fmt.Errorf("unknown channel %q", ch)
+			}
+		}
+	}
+
+	if len(req.Filters.AttackTypes) > 0 {
+		known := make(map[string]bool)
+		for t := 0; t < 256; t++ {
+			known[bpf.AttackTypeName(uint8(t))] = true
+		}
+		sub.attackTypes = make(map[string]bool, len(req.Filters.AttackTypes))
+		for _, name := range req.Filters.AttackTypes {
+			if !known[name] {
+				return nil, fmt.Errorf("unknown attack type %q", name)
+			}
+			sub.attackTypes[name] = true
+		}
+	}
+
+	for _, p := range req.Filters.SrcPrefixes {
+		ipnet, err := parseCIDROrIP(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source prefix %q", p)
+		}
+		sub.srcNets = append(sub.srcNets, ipnet)
+	}
+
+	sub.minPPS = req.Filters.MinPPS
+	sub.filters = req.Filters
+	return sub, nil
+}
+
+// wants reports whether the subscription selects the message type.
+func (sub *subscription) wants(msgType string) bool {
+	ch, ok := channelForType[msgType]
+	return ok && sub.channels[ch]
+}
+
+// matchEvent applies the event filters.
+func (sub *subscription) matchEvent(ev *bpf.Event) bool {
+	if len(sub.attackTypes) > 0 && !sub.attackTypes[bpf.AttackTypeName(ev.AttackType)] {
+		return false
+	}
+	if ev.PPSEstimate < sub.minPPS {
+		return false
+	}
+	if len(sub.srcNets) > 0 {
+		src := bpf.U32BEToIP(ev.SrcIP)
+		for _, n := range sub.srcNets {
+			if n.Contains(src) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// describe returns the active subscription for the acknowledgement.
+func (sub *subscription) describe() map[string]interface{} {
+	channels := make([]string, 0, len(sub.channels))
+	for _, ch := range []string{ChannelStats, ChannelEvents, ChannelEscalation} {
+		if sub.channels[ch] {
+			channels = append(channels, ch)
+		}
+	}
+	return map[string]interface{}{
+		"channels": channels,
+		"filters":  sub.filters,
+	}
+}
+
+// parseCIDROrIP accepts a CIDR or a bare IPv4 address (treated as /32).
+func parseCIDROrIP(s string) (*net.IPNet, error) {
+	if _, ipnet, err := net.ParseCIDR(s); err == nil {
+		return ipnet, nil
+	}
+	ip := net.ParseIP(s).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address %q", s)
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}, nil
+}
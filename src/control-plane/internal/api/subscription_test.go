@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+)
+
+func testEvent(src string, attack uint8, pps uint64) *bpf.Event {
+	return &bpf.Event{
+		SrcIP:       binary.BigEndian.Uint32(net.ParseIP(src).To4()),
+		AttackType:  attack,
+		PPSEstimate: pps,
+	}
+}
+
+func TestDefaultSubscriptionForwardsEverything(t *testing.T) {
+	sub := defaultSubscription()
+	for _, typ := range []string{"stats", "event", "escalation"} {
+		if !sub.wants(typ) {
+			t.Errorf("default subscription should want %q", typ)
+		}
+	}
+	if !sub.matchEvent(testEvent("192.0.2.1", bpf.AttackUDPFlood, 0)) {
+		t.Error("default subscription should match any event")
+	}
+}
+
+func TestSubscriptionFilters(t *testing.T) {
+	sub, err := newSubscription(wsRequest{
+		Action:   "subscribe",
+		Channels: []string{ChannelEvents},
+		Filters: wsFilters{
+			AttackTypes: []string{"syn_flood"},
+			MinPPS:      1000,
+			SrcPrefixes: []string{"203.0.113.0/24", "198.51.100.7"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("newSubscription() error: %v", err)
+	}
+
+	if sub.wants("stats") {
+		t.Error("stats should not be wanted")
+	}
+	if !sub.wants("event") {
+		t.Error("events should be wanted")
+	}
+
+	tests := []struct {
+		name string
+		ev   *bpf.Event
+		want bool
+	}{
+		{"match prefix", testEvent("203.0.113.9", bpf.AttackSYNFlood, 5000), true},
+		{"match host", testEvent("198.51.100.7", bpf.AttackSYNFlood, 1000), true},
+		{"wrong attack", testEvent("203.0.113.9", bpf.AttackUDPFlood, 5000), false},
+		{"below min pps", testEvent("203.0.113.9", bpf.AttackSYNFlood, 999), false},
+		{"outside prefix", testEvent("192.0.2.1", bpf.AttackSYNFlood, 5000), false},
+	}
+	for _, tt := range tests {
+		if got := sub.matchEvent(tt.ev); got != tt.want {
+			t.Errorf("%s: matchEvent() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSubscriptionRejectsInvalid(t *testing.T) {
+	bad := []wsRequest{
+		{Channels: []string{"alerts"}},
+		{Filters: wsFilters{AttackTypes: []string{"bogus_flood"}}},
+		{Filters: wsFilters{SrcPrefixes: []string{"not-an-ip"}}},
+	}
+	for _, req := range bad {
+		if _, err := newSubscription(req); err == nil {
+			t.Errorf("newSubscription(%+v) should fail", req)
+		}
+	}
+}
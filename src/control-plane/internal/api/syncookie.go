@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+// handleSYNCookie reports SYN cookie mode, counters, rates and seed age.
+func (s *Server) handleSYNCookie(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.maps == nil {
+		http.Error(w, "BPF maps not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	enabled, err := s.maps.GetConfig(bpf.CfgSYNCookieEnable)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	dests, err := s.maps.SYNCookieDests()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if dests == nil {
+		dests = []string{}
+	}
+	result := map[string]interface{}{
+		"enabled":      enabled == 1,
+		"scoped":       len(dests) > 0,
+		"destinations": dests,
+	}
+
+	if seeds, err := s.maps.SYNCookieSeeds(); err == nil && seeds.SeedUpdateNS > 0 {
+		rotated := time.Unix(0, int64(seeds.SeedUpdateNS))
+		result["seedRotatedAt"] = formatTime(rotated)
+		result["seedAgeSec"] = time.Since(rotated).Seconds()
+	}
+	if s.cfg != nil {
+		result["seedRotationSec"] = s.cfg.SYNCookie.SeedRotationSec
+	}
+
+	if s.stats != nil {
+		if snap := s.stats.Current(); snap != nil {
+			st := snap.Stats
+			result["sent"] = st.SYNCookiesSent
+			result["validated"] = st.SYNCookiesValidated
+			result["failed"] = st.SYNCookiesFailed
+			result["sentPps"] = snap.SYNCookieSentPPS
+			result["validatedPps"] = snap.SYNCookieValidatedPPS
+			result["failedPps"] = snap.SYNCookieFailedPPS
+			// Share of returning ACKs that carried a valid cookie
+			if checked := snap.SYNCookieValidatedPPS + snap.SYNCookieFailedPPS; checked > 0 {
+				result["successPct"] = 100 * snap.SYNCookieValidatedPPS / checked
+			}
+		}
+	}
+	writeJSON(w, result)
+}
+
+// handleSYNCookieDestinations lists, adds and removes the destination
+// prefixes SYN cookies are limited to.
+func (s *Server) handleSYNCookieDestinations(w http.ResponseWriter, r *http.Request) {
+	if s.maps == nil {
+		http.Error(w, "BPF maps not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		dests, err := s.maps.SYNCookieDests()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if dests == nil {
+			dests = []string{}
+		}
+		writeJSON(w, map[string]interface{}{"destinations": dests})
+
+	case http.MethodPost, http.MethodDelete:
+		var req struct {
+			CIDR string `json:"cidr"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodPost {
+			if err := s.maps.AddSYNCookieDest(req.CIDR); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.log.Info("SYN cookie destination added via API", zap.String("cidr", req.CIDR))
+		} else {
+			if err := s.maps.RemoveSYNCookieDest(req.CIDR); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.log.Info("SYN cookie destination removed via API", zap.String("cidr", req.CIDR))
+		}
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSYNCookieWithoutMaps(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	s.handleSYNCookie(rec, httptest.NewRequest(http.MethodPost, "/api/v1/syncookie", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleSYNCookie(rec, httptest.NewRequest(http.MethodGet, "/api/v1/syncookie", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET status = %d, want 503", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	body := strings.NewReader(`{"cidr":"203.0.113.0/24"}`)
+	s.handleSYNCookieDestinations(rec, httptest.NewRequest(http.MethodPost, "/api/v1/syncookie/destinations", body))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("POST destination status = %d, want 503", rec.Code)
+	}
+}
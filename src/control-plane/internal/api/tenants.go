@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/attacks"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+// handleTenants lists the configured tenants with the assets they own and
+// the API keys and client certificates scoped to them.
+func (s *Server) handleTenants(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.cfg == nil || !s.cfg.API.Auth.Enabled {
+		http.Error(w, "API auth not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	auth := s.cfg.API.Auth
+	result := make([]map[string]interface{}, 0, len(auth.Tenants))
+	for _, t := range auth.Tenants {
+		prefixes := []string{}
+		if s.assets != nil {
+			for _, a := range s.assets.Owned(t.Name) {
+				prefixes = append(prefixes, a.Prefix)
+			}
+		}
+		keys := []string{}
+		for _, k := range auth.Keys {
+			if k.Tenant == t.Name {
+				keys = append(keys, k.Name)
+			}
+		}
+		clients := []string{}
+		for _, c := range auth.Clients {
+			if c.Tenant == t.Name {
+				clients = append(clients, c.CommonName)
+			}
+		}
+		result = append(result, map[string]interface{}{
+			"name":        t.Name,
+			"description": t.Description,
+			"prefixes":    prefixes,
+			"keys":        keys,
+			"clients":     clients,
+		})
+	}
+	writeJSON(w, map[string]interface{}{"tenants": result})
+}
+
+// eventOwner returns the owner of the protected asset an event was
+// addressed to, or "" if the destination is not a registered asset.
+func (s *Server) eventOwner(ev *bpf.Event) string {
+	if s.assets == nil {
+		return ""
+	}
+	a, ok := s.assets.Lookup(bpf.U32BEToIP(ev.DstIP))
+	if !ok {
+		return ""
+	}
+	return a.Owner
+}
+
+// tenantACLStore holds the tenants' own ACL lists, implemented by
+// bpf.MapManager.
+type tenantACLStore interface {
+	TenantACL(id uint32, list string) (map[string]bpf.ACLRule, error)
+	AddTenantACL(id uint32, list, cidr string, reason uint32) error
+	RemoveTenantACL(id uint32, list, cidr string) error
+}
+
+// handleTenantACL serves the blacklist and whitelist endpoints to a tenant.
+// The global lists match sources towards every destination, so tenants
+// get lists of their own instead, which the data plane applies only to
+// traffic towards the tenant's assets. Any source may be listed.
+func (s *Server) handleTenantACL(w http.ResponseWriter, r *http.Request, tenant, list string) {
+	if s.tenantACL == nil || s.assets == nil {
+		http.Error(w, "tenant ACLs not available", http.StatusServiceUnavailable)
+		return
+	}
+	id, ok := s.assets.ACLID(tenant)
+
+	switch r.Method {
+	case http.MethodGet:
+		idle, valid := idleParam(w, r)
+		if !valid {
+			return
+		}
+		entries := map[string]bpf.ACLRule{}
+		if ok {
+			var err error
+			if entries, err = s.tenantACL.TenantACL(id, list); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		writeJSON(w, aclToJSON(entries, nil, idle, time.Now()))
+
+	case http.MethodPost, http.MethodDelete:
+		var req struct {
+			CIDR   string `json:"cidr"`
+			TTLSec uint64 `json:"ttlSec"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if !ok {
+			http.Error(w, "tenant has no assets", http.StatusForbidden)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			if err := s.tenantACL.RemoveTenantACL(id, list, req.CIDR); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.log.Info("tenant ACL entry removed via API",
+				zap.String("tenant", tenant), zap.String("list", list), zap.String("cidr", req.CIDR))
+			writeJSON(w, map[string]bool{"ok": true})
+			return
+		}
+		if req.TTLSec != 0 {
+			http.Error(w, "ttlSec is not supported for tenant entries", http.StatusBadRequest)
+			return
+		}
+		reason := uint32(1)
+		if list == bpf.TenantBlacklist {
+			reason = bpf.DropBlacklist
+		}
+		if err := s.tenantACL.AddTenantACL(id, list, req.CIDR, reason); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.log.Info("tenant ACL entry added via API",
+			zap.String("tenant", tenant), zap.String("list", list), zap.String("cidr", req.CIDR))
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// tenantAttack narrows an attack to what tenant may see: the targets among
+// its assets. Mitigations name other tenants' prefixes and are left out.
+// It returns false if none of the attack's targets is the tenant's.
+func (s *Server) tenantAttack(tenant string, a attacks.Attack) (attacks.Attack, bool) {
+	if s.assets == nil {
+		return attacks.Attack{}, false
+	}
+	var targets []attacks.Target
+	for _, t := range a.TopTargets {
+		if owner, ok := s.assets.Lookup(net.ParseIP(t.IP)); ok && owner.Owner == tenant {
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == 0 {
+		return attacks.Attack{}, false
+	}
+	a.TopTargets = targets
+	a.Mitigations, a.MitigationsOmitted = nil, 0
+	return a, true
+}
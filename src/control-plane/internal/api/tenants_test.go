@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/assets"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/attacks"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/events"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/stats"
+	"go.uber.org/zap"
+)
+
+// newTenantServer serves assets for two tenants: acme owns 203.0.113.0/24
+// and globex 198.51.100.0/24.
+func newTenantServer(t *testing.T) *Server {
+	t.Helper()
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	reg := assets.NewRegistry(zap.NewNop(), fakeDstPolicyMap{})
+	if err := reg.Configure([]assets.Asset{
+		{Name: "web", Prefix: "203.0.113.0/24", Owner: "acme"},
+		{Name: "mail", Prefix: "198.51.100.0/24", Owner: "globex"},
+	}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	s.SetAssets(reg)
+	return s
+}
+
+// asTenant returns req made by an operator key of tenant.
+func asTenant(req *http.Request, tenant string) *http.Request {
+	id := identity{Name: tenant + "-portal", Source: "key", Role: roleOperator, Tenant: tenant}
+	return req.WithContext(context.WithValue(req.Context(), identityKey{}, id))
+}
+
+// fakeTenantACL keeps tenant ACL entries by list id, list and CIDR.
+type fakeTenantACL map[uint32]map[string]map[string]bpf.ACLRule
+
+func (f fakeTenantACL) TenantACL(id uint32, list string) (map[string]bpf.ACLRule, error) {
+	out := make(map[string]bpf.ACLRule)
+	for cidr, rule := range f[id][list] {
+		out[cidr] = rule
+	}
+	return out, nil
+}
+
+func (f fakeTenantACL) AddTenantACL(id uint32, list, cidr string, reason uint32) error {
+	if _, _, err := net.ParseCIDR(cidr); err != nil && net.ParseIP(cidr) == nil {
+		return err
+	}
+	if f[id] == nil {
+		f[id] = make(map[string]map[string]bpf.ACLRule)
+	}
+	if f[id][list] == nil {
+		f[id][list] = make(map[string]bpf.ACLRule)
+	}
+	f[id][list][cidr] = bpf.ACLRule{Reason: reason}
+	return nil
+}
+
+func (f fakeTenantACL) RemoveTenantACL(id uint32, list, cidr string) error {
+	delete(f[id][list], cidr)
+	return nil
+}
+
+func TestTenantACL(t *testing.T) {
+	// The global lists are behind a nil MapManager: a tenant request that
+	// reached them would panic.
+	s := newTenantServer(t)
+	store := fakeTenantACL{}
+	s.tenantACL = store
+
+	do := func(tenant, method, path, body string) *httptest.ResponseRecorder {
+		req := asTenant(httptest.NewRequest(method, path, strings.NewReader(body)), tenant)
+		rec := httptest.NewRecorder()
+		if path == "/api/v1/acl/blacklist" {
+			s.handleBlacklist(rec, req)
+		} else {
+			s.handleWhitelist(rec, req)
+		}
+		return rec
+	}
+	list := func(tenant, path string) []string {
+		var entries []struct {
+			CIDR string `json:"cidr"`
+		}
+		rec := do(tenant, http.MethodGet, path, "")
+		if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+			t.Fatalf("decoding %s as %s: %v", path, tenant, err)
+		}
+		var cidrs []string
+		for _, e := range entries {
+			cidrs = append(cidrs, e.CIDR)
+		}
+		return cidrs
+	}
+
+	// Tenants list any source; the entry lands in their own list, which the
+	// data plane applies only towards their assets.
+	for _, path := range []string{"/api/v1/acl/blacklist", "/api/v1/acl/whitelist"} {
+		if rec := do("acme", http.MethodPost, path, `{"cidr":"192.0.2.0/24"}`); rec.Code != http.StatusOK {
+			t.Fatalf("POST %s as acme: status = %d %s", path, rec.Code, rec.Body)
+		}
+		if got := list("acme", path); len(got) != 1 || got[0] != "192.0.2.0/24" {
+			t.Errorf("acme %s = %v", path, got)
+		}
+		if got := list("globex", path); len(got) != 0 {
+			t.Errorf("globex sees acme's %s entries: %v", path, got)
+		}
+	}
+	acme, _ := s.assets.ACLID("acme")
+	globex, _ := s.assets.ACLID("globex")
+	if acme == 0 || acme == globex {
+		t.Fatalf("ACL ids acme=%d globex=%d, want distinct non-zero", acme, globex)
+	}
+	if store[acme][bpf.TenantBlacklist]["192.0.2.0/24"].Reason != bpf.DropBlacklist ||
+		store[acme][bpf.TenantWhitelist]["192.0.2.0/24"].Reason != 1 {
+		t.Errorf("acme's lists = %+v", store[acme])
+	}
+
+	// globex removing the same source leaves acme's entry alone.
+	do("globex", http.MethodDelete, "/api/v1/acl/blacklist", `{"cidr":"192.0.2.0/24"}`)
+	if got := list("acme", "/api/v1/acl/blacklist"); len(got) != 1 {
+		t.Errorf("acme blacklist after globex's delete = %v", got)
+	}
+	if rec := do("acme", http.MethodDelete, "/api/v1/acl/blacklist", `{"cidr":"192.0.2.0/24"}`); rec.Code != http.StatusOK {
+		t.Errorf("DELETE as acme: status = %d", rec.Code)
+	}
+	if got := list("acme", "/api/v1/acl/blacklist"); len(got) != 0 {
+		t.Errorf("acme blacklist after delete = %v", got)
+	}
+
+	for _, tc := range []struct {
+		tenant, body string
+		want         int
+	}{
+		{"acme", `{"cidr":"not-a-cidr"}`, http.StatusBadRequest},
+		{"acme", `{"cidr":"192.0.2.1","ttlSec":60}`, http.StatusBadRequest},
+		{"initech", `{"cidr":"192.0.2.1"}`, http.StatusForbidden}, // No assets
+	} {
+		if rec := do(tc.tenant, http.MethodPost, "/api/v1/acl/blacklist", tc.body); rec.Code != tc.want {
+			t.Errorf("POST %s as %s: status = %d, want %d", tc.body, tc.tenant, rec.Code, tc.want)
+		}
+	}
+	if got := list("initech", "/api/v1/acl/whitelist"); len(got) != 0 {
+		t.Errorf("tenant without assets sees %v", got)
+	}
+}
+
+func TestTenantAttacks(t *testing.T) {
+	s := newTenantServer(t)
+	tr := attacks.NewTracker(zap.NewNop(), attacks.DefaultConfig())
+	tr.Observe(&stats.Snapshot{Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), DropPPS: 5000})
+	tr.HandleEvent(&bpf.Event{SrcIP: bpf.IPToU32BE(net.ParseIP("192.0.2.1")), DstIP: bpf.IPToU32BE(net.ParseIP("203.0.113.10"))}, events.Info{})
+	tr.Mitigation("rtbh", "198.51.100.0/24")
+	s.SetAttacks(tr)
+	const id = "atk-20260102T030405Z"
+
+	var list struct {
+		Attacks []attacks.Attack `json:"attacks"`
+	}
+	rec := httptest.NewRecorder()
+	s.handleAttacks(rec, asTenant(httptest.NewRequest(http.MethodGet, "/api/v1/attacks", nil), "acme"))
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(list.Attacks) != 1 || len(list.Attacks[0].TopTargets) != 1 || len(list.Attacks[0].Mitigations) != 0 {
+		t.Errorf("acme attacks = %+v", list.Attacks)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleAttacks(rec, asTenant(httptest.NewRequest(http.MethodGet, "/api/v1/attacks", nil), "globex"))
+	list.Attacks = nil
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(list.Attacks) != 0 {
+		t.Errorf("globex sees acme's attack: %+v", list.Attacks)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleAttackReport(rec, asTenant(httptest.NewRequest(http.MethodGet, "/api/v1/attacks/"+id, nil), "globex"))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("globex report: status = %d, want 404", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	s.handleAttackReport(rec, asTenant(httptest.NewRequest(http.MethodGet, "/api/v1/attacks/"+id, nil), "acme"))
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "198.51.100.0/24") {
+		t.Errorf("acme report = %d %s", rec.Code, rec.Body)
+	}
+}
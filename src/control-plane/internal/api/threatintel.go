@@ -0,0 +1,529 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/threatintel"
+	"go.uber.org/zap"
+)
+
+// feedToJSON renders a feed's configuration and sync status. The API key
+// is reported only as present or not.
+func feedToJSON(f threatintel.Feed) map[string]interface{} {
+	out := map[string]interface{}{
+		"name":            f.Name,
+		"url":             f.URL,
+		"type":            f.Type,
+		"enabled":         f.Enabled,
+		"lastSync":        formatTime(f.LastSync),
+		"entries":         f.EntryCount,
+		"error":           f.Error,
+		"threatType":      threatintel.ThreatTypeName(f.ThreatType),
+		"confidence":      f.Confidence,
+		"action":          threatintel.ActionName(f.Action),
+		"minConfidence":   f.MinConfidence,
+		"blockConfidence": f.BlockConfidence,
+		"syncIntervalSec": int64(f.SyncInterval.Seconds()),
+		"entryTtlSec":     int64(f.EntryTTL.Seconds()),
+		"apiKeySet":       f.APIKey != "",
+		"maxEntries":      f.MaxEntries,
+	}
+	if !f.JSON.IsZero() {
+		out["json"] = f.JSON
+	}
+	if f.Quarantine != nil {
+		out["quarantine"] = quarantineToJSON(f.Quarantine)
+	}
+	if p, ok := f.SyncProgress(); ok {
+		out["sync"] = map[string]interface{}{
+			"bytes":       p.Done,
+			"totalBytes":  p.Total,
+			"percent":     p.Percent,
+			"bytesPerSec": p.Rate,
+			"etaSec":      int64(p.ETA.Seconds()),
+		}
+	}
+	return out
+}
+
+func quarantineToJSON(q *threatintel.Quarantine) map[string]interface{} {
+	return map[string]interface{}{
+		"at":       formatTime(q.At),
+		"entries":  q.Entries,
+		"previous": q.Previous,
+		"reason":   q.Reason,
+	}
+}
+
+// handleThreatIntelFeeds lists (GET), adds (POST), updates (PUT) or
+// removes (DELETE) threat intel feeds.
+func (s *Server) handleThreatIntelFeeds(w http.ResponseWriter, r *http.Request) {
+	if s.threatIntel == nil {
+		http.Error(w, "threat intel not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		feeds := s.threatIntel.GetFeeds()
+		result := make([]map[string]interface{}, 0, len(feeds))
+		for _, f := range feeds {
+			result = append(result, feedToJSON(f))
+		}
+		st := s.threatIntel.GetStats()
+		writeJSON(w, map[string]interface{}{
+			"totalEntries": st.TotalEntries,
+			"lastSync":     formatTime(st.LastSync),
+			"paused":       st.Paused,
+			"feeds":        result,
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Name string                   `json:"name"`
+			URL  string                   `json:"url"`
+			Type string                   `json:"type"`
+			JSON *threatintel.JSONMapping `json:"json"` // Field mapping of a json feed
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.JSON != nil {
+			if req.Type != "json" {
+				http.Error(w, "json mapping needs type json", http.StatusBadRequest)
+				return
+			}
+			if err := req.JSON.Validate(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := s.threatIntel.AddFeed(req.Name, req.URL, req.Type); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.JSON != nil {
+			if err := s.threatIntel.SetFeedJSONMapping(req.Name, *req.JSON); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		s.log.Info("threat feed added via API", zap.String("feed", req.Name))
+		writeJSON(w, map[string]bool{"ok": true})
+
+	case http.MethodPut:
+		// Omitted fields are left unchanged.
+		var req struct {
+			Name       string `json:"name"`
+			Enabled    *bool  `json:"enabled"`
+			Confidence *uint8 `json:"confidence"`
+			Action     string `json:"action"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		feed, ok := s.findFeed(req.Name)
+		if !ok {
+			http.Error(w, "feed not found", http.StatusNotFound)
+			return
+		}
+
+		if req.Confidence != nil || req.Action != "" {
+			confidence, action := feed.Confidence, feed.Action
+			if req.Confidence != nil {
+				confidence = *req.Confidence
+			}
+			if req.Action != "" {
+				a, err := threatintel.ParseAction(req.Action)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				action = a
+			}
+			if err := s.threatIntel.SetFeedAction(req.Name, confidence, action); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Enabled != nil {
+			var err error
+			if *req.Enabled {
+				err = s.threatIntel.EnableFeed(req.Name)
+			} else {
+				err = s.threatIntel.DisableFeed(req.Name)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+		}
+		s.log.Info("threat feed updated via API", zap.String("feed", req.Name))
+		feed, _ = s.findFeed(req.Name)
+		writeJSON(w, feedToJSON(feed))
+
+	case http.MethodDelete:
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := s.threatIntel.RemoveFeed(req.Name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.log.Info("threat feed removed via API", zap.String("feed", req.Name))
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) findFeed(name string) (threatintel.Feed, bool) {
+	for _, f := range s.threatIntel.GetFeeds() {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return threatintel.Feed{}, false
+}
+
+// handleThreatIntelSync forces an immediate sync of every enabled feed.
+func (s *Server) handleThreatIntelSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.threatIntel == nil {
+		http.Error(w, "threat intel not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	s.log.Info("threat intel sync requested via API")
+	err := s.threatIntel.SyncNow()
+	if errors.Is(err, threatintel.ErrPaused) {
+		http.Error(w, "feeds are pulled by the fleet leader", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		// Feeds that did sync keep their new entries; report the failure.
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"ok":           true,
+		"totalEntries": s.threatIntel.GetStats().TotalEntries,
+	})
+}
+
+// handleThreatIntelLookup reports which feeds list an address or prefix
+// (?ip=198.51.100.7 or ?ip=198.51.100.0/24), with what action, and which
+// entry threat_intel_map applies to it.
+func (s *Server) handleThreatIntelLookup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.threatIntel == nil {
+		http.Error(w, "threat intel not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query().Get("ip")
+	prefix, err := parseLookupPrefix(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sources := s.feedsBySource()
+	matches := s.threatIntel.LookupPrefix(prefix)
+	result := make([]map[string]interface{}, 0, len(matches))
+	for _, m := range matches {
+		result = append(result, map[string]interface{}{
+			"feed":       m.Feed,
+			"prefix":     m.Prefix,
+			"threatType": threatintel.ThreatTypeName(m.ThreatType),
+			"confidence": m.Confidence,
+			"action":     threatintel.ActionName(m.Action),
+			"lastSeen":   formatTime(m.LastSeen),
+		})
+	}
+	resp := map[string]interface{}{
+		"ip":      query,
+		"listed":  len(matches) > 0,
+		"matches": result,
+	}
+	if s.maps != nil {
+		entry, ok, err := s.maps.LookupThreatIntel(prefix.String())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if ok {
+			resp["dataplane"] = map[string]interface{}{
+				"feed":       sources[entry.SourceID],
+				"sourceId":   entry.SourceID,
+				"threatType": threatintel.ThreatTypeName(entry.ThreatType),
+				"confidence": entry.Confidence,
+				"action":     threatintel.ActionName(entry.Action),
+			}
+		}
+	}
+	writeJSON(w, resp)
+}
+
+// parseLookupPrefix parses an IPv4 address or CIDR, an address as a /32.
+func parseLookupPrefix(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, prefix, err := net.ParseCIDR(s)
+		if err != nil || prefix.IP.To4() == nil {
+			return nil, errors.New("ip must be an IPv4 address or CIDR")
+		}
+		return prefix, nil
+	}
+	ip := net.ParseIP(s).To4()
+	if ip == nil {
+		return nil, errors.New("ip must be an IPv4 address or CIDR")
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}, nil
+}
+
+// feedsBySource maps threat_intel_entry.source_id to feed names.
+func (s *Server) feedsBySource() map[uint8]string {
+	feeds := s.threatIntel.GetFeeds()
+	out := make(map[uint8]string, len(feeds))
+	for _, f := range feeds {
+		out[f.SourceID] = f.Name
+	}
+	return out
+}
+
+// handleThreatIntelStats reports per feed how many packets its entries
+// matched in the data plane and what became of them, so feeds that never
+// stop anything can be pruned. Counters run since the program was loaded.
+func (s *Server) handleThreatIntelStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.threatIntel == nil || s.maps == nil {
+		http.Error(w, "threat intel not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	counters, err := s.maps.ThreatIntelFeedStats()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, feedStatsToJSON(s.threatIntel.GetFeeds(), counters))
+}
+
+// feedStatsToJSON joins the per-source counters to the configured feeds,
+// busiest dropper first. Counters of removed feeds are left out.
+func feedStatsToJSON(feeds []threatintel.Feed, counters map[uint8]bpf.ThreatIntelFeedStats) []map[string]interface{} {
+	sort.SliceStable(feeds, func(i, j int) bool {
+		return counters[feeds[i].SourceID].Dropped > counters[feeds[j].SourceID].Dropped
+	})
+	result := make([]map[string]interface{}, 0, len(feeds))
+	for _, f := range feeds {
+		c := counters[f.SourceID]
+		result = append(result, map[string]interface{}{
+			"feed":        f.Name,
+			"sourceId":    f.SourceID,
+			"enabled":     f.Enabled,
+			"entries":     f.EntryCount,
+			"matched":     c.Matched,
+			"dropped":     c.Dropped,
+			"rateLimited": c.RateLimited,
+			"monitored":   c.Monitored,
+		})
+	}
+	return result
+}
+
+// maxPushBodyBytes caps the size of a push request, comfortably above a
+// full batch of threatintel.MaxPushBatch indicators.
+const maxPushBodyBytes = 4 << 20
+
+// pushIndicator is one indicator in a push request. Omitted fields take
+// the defaults: botnet, confidence 80, drop, DefaultPushTTL.
+type pushIndicator struct {
+	IP         string `json:"ip"`
+	Type       string `json:"type"`
+	Confidence *uint8 `json:"confidence"`
+	Action     string `json:"action"`
+	TTLSec     int64  `json:"ttlSec"`
+}
+
+// handleThreatIntelPush installs indicators pushed by internal detection
+// systems, so they take effect now rather than at the next feed pull:
+//
+//	{"source":"ids","indicators":[{"ip":"198.51.100.0/24","type":"scanner","confidence":90,"action":"drop","ttlSec":3600}]}
+//
+// Each source gets a push feed of its own. A batch with any invalid
+// indicator is rejected whole.
+func (s *Server) handleThreatIntelPush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.threatIntel == nil {
+		http.Error(w, "threat intel not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		Source     string          `json:"source"`
+		Indicators []pushIndicator `json:"indicators"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxPushBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("request body over %d bytes", maxPushBodyBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.Indicators) > threatintel.MaxPushBatch {
+		http.Error(w, fmt.Sprintf("at most %d indicators per push", threatintel.MaxPushBatch), http.StatusBadRequest)
+		return
+	}
+	if len(req.Indicators) == 0 {
+		http.Error(w, "no indicators", http.StatusBadRequest)
+		return
+	}
+	indicators := make([]threatintel.Indicator, len(req.Indicators))
+	for i, pi := range req.Indicators {
+		ind, err := parsePushIndicator(pi)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("indicator %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+		indicators[i] = ind
+	}
+
+	source := req.Source
+	if source == "" {
+		source = threatintel.DefaultPushSource
+	}
+	n, err := s.threatIntel.Push(source, indicators)
+	if errors.Is(err, threatintel.ErrPaused) {
+		http.Error(w, "push to the fleet leader", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.log.Info("threat indicators pushed via API", zap.String("feed", source), zap.Int("indicators", n))
+	writeJSON(w, map[string]interface{}{
+		"feed":     source,
+		"accepted": n,
+	})
+}
+
+// parsePushIndicator converts a pushed indicator, applying defaults.
+func parsePushIndicator(pi pushIndicator) (threatintel.Indicator, error) {
+	ind := threatintel.Indicator{Prefix: pi.IP, Confidence: 80}
+	if pi.Type != "" {
+		t, err := threatintel.ParseThreatType(pi.Type)
+		if err != nil {
+			return ind, err
+		}
+		ind.ThreatType = t
+	}
+	if pi.Confidence != nil {
+		ind.Confidence = *pi.Confidence
+	}
+	if pi.Action != "" {
+		a, err := threatintel.ParseAction(pi.Action)
+		if err != nil {
+			return ind, err
+		}
+		ind.Action = a
+	}
+	if pi.TTLSec < 0 {
+		return ind, errors.New("ttlSec must not be negative")
+	}
+	ind.TTL = time.Duration(pi.TTLSec) * time.Second
+	return ind, nil
+}
+
+// handleThreatIntelQuarantine lists feed syncs quarantined as anomalous
+// (GET) and approves or rejects one (POST {"feed","action"}). Approving
+// installs the held entries; rejecting keeps those installed before.
+func (s *Server) handleThreatIntelQuarantine(w http.ResponseWriter, r *http.Request) {
+	if s.threatIntel == nil {
+		http.Error(w, "threat intel not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		result := []map[string]interface{}{}
+		for _, f := range s.threatIntel.GetFeeds() {
+			if f.Quarantine == nil {
+				continue
+			}
+			q := quarantineToJSON(f.Quarantine)
+			q["feed"] = f.Name
+			result = append(result, q)
+		}
+		writeJSON(w, result)
+
+	case http.MethodPost:
+		var req struct {
+			Feed   string `json:"feed"`
+			Action string `json:"action"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.findFeed(req.Feed); !ok {
+			http.Error(w, "feed not found", http.StatusNotFound)
+			return
+		}
+		var err error
+		switch req.Action {
+		case "approve":
+			_, err = s.threatIntel.ApproveQuarantine(req.Feed)
+		case "reject":
+			err = s.threatIntel.RejectQuarantine(req.Feed)
+		default:
+			http.Error(w, `action must be "approve" or "reject"`, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		s.log.Info("threat feed quarantine resolved via API",
+			zap.String("feed", req.Feed),
+			zap.String("action", req.Action),
+			zap.String("by", requester(r)),
+		)
+		feed, _ := s.findFeed(req.Feed)
+		writeJSON(w, feedToJSON(feed))
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
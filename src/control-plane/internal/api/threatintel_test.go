@@ -0,0 +1,235 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/threatintel"
+	"go.uber.org/zap"
+)
+
+func newThreatIntelServer() *Server {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	s.SetThreatIntel(threatintel.NewManager(zap.NewNop(), nil, nil))
+	return s
+}
+
+func TestThreatIntelFeedLifecycle(t *testing.T) {
+	s := newThreatIntelServer()
+
+	rec := httptest.NewRecorder()
+	s.handleThreatIntelFeeds(rec, httptest.NewRequest(http.MethodPost, "/api/v1/threatintel/feeds",
+		strings.NewReader(`{"name":"bl-ssh","url":"ssh","type":"blocklistde"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST status = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleThreatIntelFeeds(rec, httptest.NewRequest(http.MethodPut, "/api/v1/threatintel/feeds",
+		strings.NewReader(`{"name":"bl-ssh","enabled":false,"confidence":60,"action":"rate_limit"}`)))
+	var feed map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&feed); err != nil {
+		t.Fatalf("PUT status = %d: %v", rec.Code, err)
+	}
+	if feed["enabled"] != false || feed["confidence"] != float64(60) || feed["action"] != "rate_limit" {
+		t.Errorf("feed = %v", feed)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleThreatIntelFeeds(rec, httptest.NewRequest(http.MethodPut, "/api/v1/threatintel/feeds",
+		strings.NewReader(`{"name":"bl-ssh","action":"tarpit"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid action status = %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleThreatIntelFeeds(rec, httptest.NewRequest(http.MethodGet, "/api/v1/threatintel/feeds", nil))
+	var list struct {
+		Feeds []map[string]interface{} `json:"feeds"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Feeds) != 5 {
+		t.Errorf("feeds = %d, want 4 built-in + 1", len(list.Feeds))
+	}
+	for _, f := range list.Feeds {
+		if _, leaked := f["apiKey"]; leaked {
+			t.Error("API key exposed")
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleThreatIntelFeeds(rec, httptest.NewRequest(http.MethodDelete, "/api/v1/threatintel/feeds",
+		strings.NewReader(`{"name":"missing"}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE missing status = %d", rec.Code)
+	}
+}
+
+func TestThreatIntelJSONFeed(t *testing.T) {
+	s := newThreatIntelServer()
+
+	rec := httptest.NewRecorder()
+	s.handleThreatIntelFeeds(rec, httptest.NewRequest(http.MethodPost, "/api/v1/threatintel/feeds",
+		strings.NewReader(`{"name":"vendor","url":"https://intel.example.net/v1","type":"csv","json":{"ip":"ip"}}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("mapping on a csv feed: status = %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleThreatIntelFeeds(rec, httptest.NewRequest(http.MethodPost, "/api/v1/threatintel/feeds",
+		strings.NewReader(`{"name":"vendor","url":"https://intel.example.net/v1","type":"json","json":{"items":"$.data","ip":"ip","confidence":"score"}}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST status = %d: %s", rec.Code, rec.Body)
+	}
+	feed, ok := s.findFeed("vendor")
+	if !ok || feed.JSON.Items != "$.data" || feed.JSON.Confidence != "score" {
+		t.Errorf("feed = %+v", feed)
+	}
+}
+
+func TestThreatIntelLookup(t *testing.T) {
+	s := newThreatIntelServer()
+
+	rec := httptest.NewRecorder()
+	s.handleThreatIntelLookup(rec, httptest.NewRequest(http.MethodGet, "/api/v1/threatintel/lookup?ip=2001:db8::1", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("IPv6 status = %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleThreatIntelLookup(rec, httptest.NewRequest(http.MethodGet, "/api/v1/threatintel/lookup?ip=198.51.100.7", nil))
+	var got map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || got["listed"] != false {
+		t.Errorf("lookup = %v, %v", got, err)
+	}
+
+	for _, q := range []string{"198.51.100.0/24", "198.51.100.7"} {
+		rec = httptest.NewRecorder()
+		s.handleThreatIntelLookup(rec, httptest.NewRequest(http.MethodGet, "/api/v1/threatintel/lookup?ip="+q, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d", q, rec.Code)
+		}
+	}
+	rec = httptest.NewRecorder()
+	s.handleThreatIntelLookup(rec, httptest.NewRequest(http.MethodGet, "/api/v1/threatintel/lookup?ip=2001:db8::/32", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("IPv6 prefix status = %d", rec.Code)
+	}
+}
+
+func TestFeedStatsToJSON(t *testing.T) {
+	feeds := []threatintel.Feed{
+		{Name: "abuseipdb", SourceID: 1, EntryCount: 10000},
+		{Name: "custom", SourceID: 4, EntryCount: 12},
+		{Name: "spamhaus-drop", SourceID: 0, EntryCount: 900},
+	}
+	counters := map[uint8]bpf.ThreatIntelFeedStats{
+		0: {Matched: 500, Dropped: 500},
+		4: {Matched: 40, Dropped: 30, Monitored: 10},
+		9: {Matched: 7, Dropped: 7}, // removed feed
+	}
+
+	got := feedStatsToJSON(feeds, counters)
+	var order []string
+	for _, f := range got {
+		order = append(order, f["feed"].(string))
+	}
+	if strings.Join(order, ",") != "spamhaus-drop,custom,abuseipdb" {
+		t.Errorf("order = %v", order)
+	}
+	if got[2]["matched"] != uint64(0) || got[1]["monitored"] != uint64(10) {
+		t.Errorf("stats = %v", got)
+	}
+}
+
+func TestThreatIntelPush(t *testing.T) {
+	s := newThreatIntelServer()
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"indicators":[]}`, http.StatusBadRequest},
+		{`{"indicators":[{"ip":"198.51.100.7","type":"worm"}]}`, http.StatusBadRequest},
+		{`{"indicators":[{"ip":"198.51.100.7","action":"tarpit"}]}`, http.StatusBadRequest},
+		{`{"indicators":[{"ip":"198.51.100.7","ttlSec":-1}]}`, http.StatusBadRequest},
+		{`{"indicators":[{"ip":"2001:db8::1"}]}`, http.StatusBadRequest},
+		{`{"source":"spamhaus-drop","indicators":[{"ip":"198.51.100.7"}]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleThreatIntelPush(rec, httptest.NewRequest(http.MethodPost, "/api/v1/threatintel/push",
+			strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.body, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	ind, err := parsePushIndicator(pushIndicator{IP: "198.51.100.0/24", Type: "scanner", Action: "rate_limit", TTLSec: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := threatintel.Indicator{Prefix: "198.51.100.0/24", ThreatType: 1, Confidence: 80, Action: 1, TTL: time.Minute}
+	if ind != want {
+		t.Errorf("indicator = %+v, want %+v", ind, want)
+	}
+
+	rec := httptest.NewRecorder()
+	huge := `{"indicators":[` + strings.Repeat(`{"ip":"198.51.100.7"},`, maxPushBodyBytes/20) + `]}`
+	s.handleThreatIntelPush(rec, httptest.NewRequest(http.MethodPost, "/api/v1/threat-intel/push",
+		strings.NewReader(huge)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status = %d, want 413", rec.Code)
+	}
+
+	s.threatIntel.SetPaused(true)
+	rec = httptest.NewRecorder()
+	s.handleThreatIntelPush(rec, httptest.NewRequest(http.MethodPost, "/api/v1/threatintel/push",
+		strings.NewReader(`{"indicators":[{"ip":"198.51.100.7"}]}`)))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("paused status = %d, want 409", rec.Code)
+	}
+}
+
+func TestThreatIntelQuarantine(t *testing.T) {
+	s := newThreatIntelServer()
+
+	rec := httptest.NewRecorder()
+	s.handleThreatIntelQuarantine(rec, httptest.NewRequest(http.MethodGet, "/api/v1/threatintel/quarantine", nil))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("GET = %d %s, want an empty list", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"feed":"nope","action":"approve"}`, http.StatusNotFound},
+		{`{"feed":"spamhaus-drop","action":"ignore"}`, http.StatusBadRequest},
+		{`{"feed":"spamhaus-drop","action":"approve"}`, http.StatusConflict},
+		{`{"feed":"spamhaus-drop","action":"reject"}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleThreatIntelQuarantine(rec, httptest.NewRequest(http.MethodPost, "/api/v1/threatintel/quarantine",
+			strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.body, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestThreatIntelUnavailable(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	rec := httptest.NewRecorder()
+	s.handleThreatIntelSync(rec, httptest.NewRequest(http.MethodPost, "/api/v1/threatintel/sync", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/tunnel"
+	"go.uber.org/zap"
+)
+
+// handleTunnels lists, adds and removes GRE return tunnels.
+func (s *Server) handleTunnels(w http.ResponseWriter, r *http.Request) {
+	if s.tunnels == nil {
+		http.Error(w, "GRE tunnels not enabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		list := s.tunnels.List()
+		result := make([]map[string]interface{}, 0, len(list))
+		for _, t := range list {
+			entry := map[string]interface{}{
+				"name":       t.Name,
+				"prefix":     t.Prefix,
+				"endpoint":   t.Endpoint,
+				"backup":     t.Backup,
+				"active":     t.Active,
+				"endpointUp": t.EndpointUp,
+				"backupUp":   t.BackupUp,
+				"lastCheck":  formatTime(t.LastCheck),
+				"lastError":  t.LastError,
+			}
+			if t.EndpointRTT > 0 {
+				entry["endpointRttMs"] = float64(t.EndpointRTT.Microseconds()) / 1000
+			}
+			if t.BackupRTT > 0 {
+				entry["backupRttMs"] = float64(t.BackupRTT.Microseconds()) / 1000
+			}
+			result = append(result, entry)
+		}
+		writeJSON(w, map[string]interface{}{"tunnels": result})
+
+	case http.MethodPost:
+		var req tunnel.Tunnel
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := s.tunnels.Add(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.log.Info("GRE tunnel added via API",
+			zap.String("name", req.Name), zap.String("prefix", req.Prefix), zap.String("endpoint", req.Endpoint))
+		writeJSON(w, map[string]bool{"ok": true})
+
+	case http.MethodDelete:
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := s.tunnels.Remove(req.Name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.log.Info("GRE tunnel removed via API", zap.String("name", req.Name))
+		writeJSON(w, map[string]bool{"ok": true})
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/tunnel"
+	"go.uber.org/zap"
+)
+
+type fakeGREMap map[string]string
+
+func (f fakeGREMap) AddGRETunnel(cidr string, endpoint net.IP) error {
+	f[cidr] = endpoint.String()
+	return nil
+}
+
+func (f fakeGREMap) RemoveGRETunnel(cidr string) error {
+	delete(f, cidr)
+	return nil
+}
+
+func TestTunnels(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	const path = "/api/v1/tunnels"
+
+	rec := httptest.NewRecorder()
+	s.handleTunnels(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("without manager: status = %d, want 503", rec.Code)
+	}
+
+	m := fakeGREMap{}
+	s.SetTunnels(tunnel.NewManager(zap.NewNop(), tunnel.Config{}, m))
+
+	tests := []struct {
+		method string
+		body   string
+		want   int
+	}{
+		{http.MethodPost, `{"name":"dc1","prefix":"203.0.113.0/24","endpoint":"192.0.2.1"}`, http.StatusOK},
+		{http.MethodPost, `{"name":"dc1","prefix":"198.51.100.0/24","endpoint":"192.0.2.1"}`, http.StatusBadRequest},
+		{http.MethodPost, `{"name":"dc2","prefix":"198.51.100.0/24"}`, http.StatusBadRequest},
+		{http.MethodDelete, `{"name":"dc9"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleTunnels(rec, httptest.NewRequest(tt.method, path, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.body, rec.Code, tt.want)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleTunnels(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var res struct {
+		Tunnels []struct {
+			Name   string `json:"name"`
+			Active string `json:"active"`
+		} `json:"tunnels"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if len(res.Tunnels) != 1 || res.Tunnels[0].Active != "192.0.2.1" || m["203.0.113.0/24"] != "192.0.2.1" {
+		t.Errorf("tunnels = %+v, map = %v", res.Tunnels, m)
+	}
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// handleWatchdog shows the latest watchdog check and recent findings
+// (GET) and runs a check right away (POST).
+func (s *Server) handleWatchdog(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if s.watchdog == nil {
+			writeJSON(w, map[string]bool{"enabled": false})
+			return
+		}
+		writeJSON(w, s.watchdog.Status())
+
+	case http.MethodPost:
+		if s.watchdog == nil {
+			http.Error(w, "watchdog disabled", http.StatusServiceUnavailable)
+			return
+		}
+		found := s.watchdog.Check()
+		s.log.Info("watchdog check via API", zap.String("by", requester(r)), zap.Int("findings", len(found)))
+		writeJSON(w, s.watchdog.Status())
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/watchdog"
+	"go.uber.org/zap"
+)
+
+// fakeWatchdogDataplane reports a detached program until reattached.
+type fakeWatchdogDataplane struct {
+	attached bool
+}
+
+func (f *fakeWatchdogDataplane) AttachState() (bpf.AttachState, error) {
+	st := bpf.AttachState{Interface: "eth0", ProgramID: 5, Linked: true, LinkProgramID: 5}
+	if f.attached {
+		st.LinkIfindex, st.InterfaceProgramID = 2, 5
+	}
+	return st, nil
+}
+
+func (f *fakeWatchdogDataplane) Reattach() error {
+	f.attached = true
+	return nil
+}
+
+func (f *fakeWatchdogDataplane) ConfigDrift() ([]bpf.ConfigDrift, error) { return nil, nil }
+func (f *fakeWatchdogDataplane) RestoreConfig([]bpf.ConfigDrift) error   { return nil }
+func (f *fakeWatchdogDataplane) Paused() bool                            { return false }
+
+func TestWatchdog(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, nil, nil, nil)
+	const path = "/api/v1/watchdog"
+
+	rec := httptest.NewRecorder()
+	s.handleWatchdog(rec, httptest.NewRequest(http.MethodPost, path, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("POST without watchdog: status = %d, want 503", rec.Code)
+	}
+
+	dp := &fakeWatchdogDataplane{}
+	s.SetWatchdog(watchdog.New(zap.NewNop(), watchdog.DefaultConfig(), dp))
+
+	rec = httptest.NewRecorder()
+	s.handleWatchdog(rec, httptest.NewRequest(http.MethodPost, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST: status = %d", rec.Code)
+	}
+	var st watchdog.Status
+	if err := json.NewDecoder(rec.Body).Decode(&st); err != nil {
+		t.Fatal(err)
+	}
+	if !dp.attached || st.Repairs != 1 || len(st.Findings) != 1 || st.Findings[0].Kind != watchdog.KindDetached {
+		t.Errorf("status after check = %+v", st)
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"github.com/gorilla/websocket"
+)
+
+// Largest message a client may send; subscribe requests are small.
+const wsReadLimit = 64 << 10
+
+// sendQueue holds a stream client's outgoing messages. send never blocks:
+// when the queue is full the oldest message is dropped, so a slow client
+// cannot hold up a broadcast.
+type sendQueue struct {
+	limit int
+
+	mu      sync.Mutex
+	msgs    [][]byte
+	dropped uint64 // Messages dropped from a full queue
+
+	wake chan struct{} // Signals the writer that the queue has messages
+}
+
+func newSendQueue(limit int) sendQueue {
+	return sendQueue{limit: limit, wake: make(chan struct{}, 1)}
+}
+
+// send queues data for the writer, dropping the oldest queued message if
+// the queue is full.
+func (q *sendQueue) send(data []byte) {
+	q.mu.Lock()
+	if len(q.msgs) >= q.limit {
+		n := copy(q.msgs, q.msgs[1:])
+		q.msgs = q.msgs[:n]
+		q.dropped++
+	}
+	q.msgs = append(q.msgs, data)
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// take empties the queue and returns its messages.
+func (q *sendQueue) take() [][]byte {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	msgs := q.msgs
+	q.msgs = nil
+	return msgs
+}
+
+// droppedMessages returns how many messages a full queue has dropped.
+func (q *sendQueue) droppedMessages() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.dropped
+}
+
+// wsClient is a connected WebSocket client. Messages are queued by send
+// and written by the client's own writer goroutine, which is also the
+// one concurrent writer gorilla/websocket allows.
+type wsClient struct {
+	sendQueue
+
+	conn   *websocket.Conn
+	tenant string // Tenant the client is restricted to, or ""
+	cfg    config.WebSocketConfig
+
+	done      chan struct{}
+	closeOnce sync.Once
+
+	subMu sync.RWMutex
+	sub   *subscription
+}
+
+func newWSClient(conn *websocket.Conn, cfg config.WebSocketConfig) *wsClient {
+	return &wsClient{
+		sendQueue: newSendQueue(cfg.SendQueue),
+		conn:      conn,
+		cfg:       cfg,
+		done:      make(chan struct{}),
+		sub:       defaultSubscription(),
+	}
+}
+
+func (c *wsClient) current() *subscription {
+	c.subMu.RLock()
+	defer c.subMu.RUnlock()
+	return c.sub
+}
+
+func (c *wsClient) setSubscription(sub *subscription) {
+	c.subMu.Lock()
+	c.sub = sub
+	c.subMu.Unlock()
+}
+
+// close disconnects the client and stops its writer. It is safe to call
+// more than once.
+func (c *wsClient) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// keepalive arms the read deadline and extends it on every pong, so a
+// client that stops answering pings is disconnected by the read loop.
+func (c *wsClient) keepalive() {
+	wait := 2 * time.Duration(c.cfg.PingIntervalSec) * time.Second
+	c.conn.SetReadLimit(wsReadLimit)
+	c.conn.SetReadDeadline(time.Now().Add(wait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wait))
+	})
+}
+
+// writeLoop writes queued messages and keepalive pings until the client
+// is closed or a write fails or times out.
+func (c *wsClient) writeLoop() {
+	defer c.close()
+
+	timeout := time.Duration(c.cfg.WriteTimeoutSec) * time.Second
+	ping := time.NewTicker(time.Duration(c.cfg.PingIntervalSec) * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.wake:
+			for _, data := range c.take() {
+				c.conn.SetWriteDeadline(time.Now().Add(timeout))
+				if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+					return
+				}
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(timeout)); err != nil {
+				return
+			}
+		}
+	}
+}
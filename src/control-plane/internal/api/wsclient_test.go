@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+func TestWSClientDropsOldest(t *testing.T) {
+	c := newWSClient(nil, config.WebSocketConfig{SendQueue: 3})
+	for _, m := range []string{"1", "2", "3", "4", "5"} {
+		c.send([]byte(m))
+	}
+
+	var got []string
+	for _, m := range c.take() {
+		got = append(got, string(m))
+	}
+	if strings.Join(got, ",") != "3,4,5" {
+		t.Errorf("queued = %v, want the newest 3,4,5", got)
+	}
+	if n := c.droppedMessages(); n != 2 {
+		t.Errorf("dropped = %d, want 2", n)
+	}
+	if len(c.take()) != 0 {
+		t.Error("take() should empty the queue")
+	}
+}
+
+func TestWSMaxClientsAndBroadcast(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.API.WebSocket.MaxClients = 1
+	s := NewServer(zap.NewNop(), cfg, nil, nil, nil)
+	ts := httptest.NewServer(http.HandlerFunc(s.handleWS))
+	defer ts.Close()
+	defer s.Stop(context.Background())
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Registration happens after the handshake completes.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.wsMu.RLock()
+		n := len(s.wsConns)
+		s.wsMu.RUnlock()
+		if n == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The second client is over the limit.
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("second dial: err %v, response %v; want 503", err, resp)
+	}
+	s.broadcast(wsMessage{Type: "escalation", Data: "HIGH"}, nil)
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg wsMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("reading broadcast: %v", err)
+	}
+	if msg.Type != "escalation" || msg.Data != "HIGH" {
+		t.Errorf("message = %+v", msg)
+	}
+}
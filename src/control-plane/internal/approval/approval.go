@@ -0,0 +1,364 @@
+// Package approval holds destructive mitigations for a human decision.
+//
+// With approval enabled, the actions it covers (RTBH blackholes of
+// protected assets and GeoIP country blocks at CRITICAL) are not taken
+// when escalation asks for them. They are staged as a request instead, a
+// notification goes out to the configured targets, and the action runs
+// once the request is approved through the API. A request nobody answers
+// in time either runs or is dropped, as configured. Requests for an
+// action whose cause went away (escalation fell below CRITICAL) are
+// cancelled.
+package approval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Actions that can require approval.
+const (
+	ActionRTBH  = "rtbh"
+	ActionGeoIP = "geoip"
+)
+
+// Request states.
+const (
+	StatusPending   = "pending"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusExpired   = "expired"
+	StatusCancelled = "cancelled"
+)
+
+// Behaviour when a request is not answered in time.
+const (
+	OnTimeoutExecute = "execute"
+	OnTimeoutCancel  = "cancel"
+)
+
+const (
+	defaultTimeoutSec = 300
+
+	// maxRequests caps the requests kept, oldest resolved first.
+	maxRequests = 100
+
+	// checkInterval is how often request deadlines are checked.
+	checkInterval = time.Second
+)
+
+var (
+	// ErrNotFound is returned for unknown request IDs.
+	ErrNotFound = errors.New("approval request not found")
+
+	// ErrResolved is returned when deciding a request that is no longer
+	// pending.
+	ErrResolved = errors.New("approval request already resolved")
+)
+
+// Config controls which actions need approval.
+type Config struct {
+	Enabled    bool     `yaml:"enabled"`
+	Actions    []string `yaml:"actions"`     // rtbh, geoip
+	TimeoutSec uint64   `yaml:"timeout_sec"` // How long a request waits for a decision
+	OnTimeout  string   `yaml:"on_timeout"`  // "execute" (veto window) or "cancel"
+}
+
+// DefaultConfig returns approval disabled, covering both actions with a
+// five minute veto window when enabled.
+func DefaultConfig() Config {
+	return Config{
+		Actions:    []string{ActionRTBH, ActionGeoIP},
+		TimeoutSec: defaultTimeoutSec,
+		OnTimeout:  OnTimeoutExecute,
+	}
+}
+
+// Validate checks the approval configuration.
+func (c Config) Validate() error {
+	for _, a := range c.Actions {
+		if a != ActionRTBH && a != ActionGeoIP {
+			return fmt.Errorf("actions: unknown action %q (must be rtbh or geoip)", a)
+		}
+	}
+	if c.Enabled && c.TimeoutSec == 0 {
+		return fmt.Errorf("timeout_sec must be positive")
+	}
+	if c.OnTimeout != OnTimeoutExecute && c.OnTimeout != OnTimeoutCancel {
+		return fmt.Errorf("on_timeout must be execute or cancel, got %q", c.OnTimeout)
+	}
+	return nil
+}
+
+// Request is a staged action waiting for, or having had, a decision.
+type Request struct {
+	ID         uint32     `json:"id"`
+	Action     string     `json:"action"`
+	Summary    string     `json:"summary"`
+	Status     string     `json:"status"`
+	Created    time.Time  `json:"created"`
+	Deadline   time.Time  `json:"deadline"`
+	Resolved   *time.Time `json:"resolved,omitempty"`
+	ResolvedBy string     `json:"resolvedBy,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+
+	execute func()
+}
+
+// Notifier announces requests and their outcome, implemented by
+// notify.Notifier.
+type Notifier interface {
+	ApprovalRequested(id uint32, action, summary string, deadline time.Time, onTimeout string)
+	ApprovalResolved(id uint32, action, status, by, reason string)
+}
+
+// Manager stages actions and tracks the decisions on them.
+type Manager struct {
+	log      *zap.Logger
+	cfg      Config
+	notifier Notifier
+
+	mu       sync.Mutex
+	nextID   uint32
+	requests []*Request
+	decided  map[string]string // action -> StatusApproved or StatusRejected until Clear
+
+	now func() time.Time
+}
+
+// NewManager creates a manager for cfg. notifier may be nil.
+func NewManager(log *zap.Logger, cfg Config, notifier Notifier) *Manager {
+	return &Manager{
+		log:      log,
+		cfg:      cfg,
+		notifier: notifier,
+		nextID:   1,
+		decided:  make(map[string]string),
+		now:      time.Now,
+	}
+}
+
+// Required reports whether action needs approval.
+func (m *Manager) Required(action string) bool {
+	if !m.cfg.Enabled {
+		return false
+	}
+	for _, a := range m.cfg.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Gate reports whether action may be taken now. If it needs approval that
+// has not been given, a request is staged, unless one is already pending
+// or was rejected, and execute runs in its own goroutine once the request
+// is approved or expires with on_timeout execute.
+func (m *Manager) Gate(action, summary string, execute func()) bool {
+	if !m.Required(action) {
+		return true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch m.decided[action] {
+	case StatusApproved:
+		return true
+	case StatusRejected:
+		return false
+	}
+	if m.pendingLocked(action) != nil {
+		return false
+	}
+
+	now := m.now()
+	r := &Request{
+		ID:       m.nextID,
+		Action:   action,
+		Summary:  summary,
+		Status:   StatusPending,
+		Created:  now,
+		Deadline: now.Add(time.Duration(m.cfg.TimeoutSec) * time.Second),
+		execute:  execute,
+	}
+	m.nextID++
+	m.requests = append(m.requests, r)
+	m.trimLocked()
+
+	m.log.Warn("mitigation staged for approval",
+		zap.Uint32("id", r.ID),
+		zap.String("action", action),
+		zap.String("summary", summary),
+		zap.Time("deadline", r.Deadline),
+		zap.String("on_timeout", m.cfg.OnTimeout),
+	)
+	if m.notifier != nil {
+		m.notifier.ApprovalRequested(r.ID, action, summary, r.Deadline, m.cfg.OnTimeout)
+	}
+	return false
+}
+
+// Clear forgets the decision on action and cancels its pending request,
+// so the next Gate asks again. Call it when the cause of the action is
+// gone.
+func (m *Manager) Clear(action string) {
+	if !m.Required(action) {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.decided, action)
+	if r := m.pendingLocked(action); r != nil {
+		m.resolveLocked(r, StatusCancelled, "", "no longer needed")
+	}
+}
+
+// Approve runs the pending request id.
+func (m *Manager) Approve(id uint32, by, reason string) (Request, error) {
+	return m.decide(id, StatusApproved, by, reason)
+}
+
+// Reject drops the pending request id. The action is not asked for again
+// until its cause has gone and come back.
+func (m *Manager) Reject(id uint32, by, reason string) (Request, error) {
+	return m.decide(id, StatusRejected, by, reason)
+}
+
+func (m *Manager) decide(id uint32, status, by, reason string) (Request, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, r := range m.requests {
+		if r.ID != id {
+			continue
+		}
+		if r.Status != StatusPending {
+			return *r, ErrResolved
+		}
+		m.resolveLocked(r, status, by, reason)
+		return *r, nil
+	}
+	return Request{}, ErrNotFound
+}
+
+// Requests returns the requests kept, newest first.
+func (m *Manager) Requests() []Request {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]Request, 0, len(m.requests))
+	for i := len(m.requests) - 1; i >= 0; i-- {
+		out = append(out, *m.requests[i])
+	}
+	return out
+}
+
+// Pending returns the number of requests waiting for a decision.
+func (m *Manager) Pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for _, r := range m.requests {
+		if r.Status == StatusPending {
+			n++
+		}
+	}
+	return n
+}
+
+// Run expires requests past their deadline until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.expire()
+		}
+	}
+}
+
+// expire resolves the pending requests past their deadline.
+func (m *Manager) expire() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for _, r := range m.requests {
+		if r.Status == StatusPending && !now.Before(r.Deadline) {
+			m.resolveLocked(r, StatusExpired, "", "no decision by the deadline, on_timeout "+m.cfg.OnTimeout)
+		}
+	}
+}
+
+// resolveLocked records the outcome of r and runs its action if the
+// outcome allows it.
+func (m *Manager) resolveLocked(r *Request, status, by, reason string) {
+	now := m.now()
+	r.Status, r.Resolved, r.ResolvedBy, r.Reason = status, &now, by, reason
+
+	run := status == StatusApproved || (status == StatusExpired && m.cfg.OnTimeout == OnTimeoutExecute)
+	switch {
+	case run:
+		m.decided[r.Action] = StatusApproved
+	case status != StatusCancelled:
+		m.decided[r.Action] = StatusRejected
+	}
+
+	m.log.Warn("mitigation approval resolved",
+		zap.Uint32("id", r.ID),
+		zap.String("action", r.Action),
+		zap.String("status", status),
+		zap.String("by", by),
+		zap.String("reason", reason),
+		zap.Bool("executing", run),
+	)
+	if m.notifier != nil {
+		m.notifier.ApprovalResolved(r.ID, r.Action, status, by, reason)
+	}
+	if run && r.execute != nil {
+		go r.execute()
+	}
+	r.execute = nil
+}
+
+// pendingLocked returns the pending request for action, if any.
+func (m *Manager) pendingLocked(action string) *Request {
+	for _, r := range m.requests {
+		if r.Action == action && r.Status == StatusPending {
+			return r
+		}
+	}
+	return nil
+}
+
+// trimLocked drops the oldest resolved requests beyond maxRequests.
+func (m *Manager) trimLocked() {
+	if len(m.requests) <= maxRequests {
+		return
+	}
+	sort.SliceStable(m.requests, func(i, j int) bool { return m.requests[i].ID < m.requests[j].ID })
+	kept := m.requests[:0]
+	excess := len(m.requests) - maxRequests
+	for _, r := range m.requests {
+		if excess > 0 && r.Status != StatusPending {
+			excess--
+			continue
+		}
+		kept = append(kept, r)
+	}
+	m.requests = kept
+}
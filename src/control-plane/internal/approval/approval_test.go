@@ -0,0 +1,134 @@
+package approval
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestManager(onTimeout string) (*Manager, *time.Time) {
+	cfg := DefaultConfig()
+	cfg.Enabled = true
+	cfg.Actions = []string{ActionRTBH}
+	cfg.OnTimeout = onTimeout
+	m := NewManager(zap.NewNop(), cfg, nil)
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return clock }
+	return m, &clock
+}
+
+// executed returns an execute func and a channel it signals on.
+func executed() (func(), chan struct{}) {
+	ch := make(chan struct{}, 1)
+	return func() { ch <- struct{}{} }, ch
+}
+
+func wait(t *testing.T, ch chan struct{}) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("action not executed")
+	}
+}
+
+func TestGateApprove(t *testing.T) {
+	m, _ := newTestManager(OnTimeoutExecute)
+	if !m.Gate(ActionGeoIP, "geoip", nil) {
+		t.Error("action without approval was held")
+	}
+
+	exec, ch := executed()
+	if m.Gate(ActionRTBH, "blackhole 192.0.2.0/24", exec) {
+		t.Fatal("action requiring approval was allowed")
+	}
+	if m.Gate(ActionRTBH, "blackhole 192.0.2.0/24", exec) || len(m.Requests()) != 1 {
+		t.Fatalf("second gate staged another request: %+v", m.Requests())
+	}
+
+	if _, err := m.Approve(42, "noc", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("approve unknown id: err = %v", err)
+	}
+	r, err := m.Approve(1, "noc", "confirmed with customer")
+	if err != nil || r.Status != StatusApproved || r.ResolvedBy != "noc" {
+		t.Fatalf("approve = %+v, %v", r, err)
+	}
+	wait(t, ch)
+	if _, err := m.Reject(1, "noc", ""); !errors.Is(err, ErrResolved) {
+		t.Errorf("reject after approve: err = %v", err)
+	}
+	if !m.Gate(ActionRTBH, "blackhole 192.0.2.0/24", exec) {
+		t.Error("approved action held")
+	}
+
+	// Once the cause is gone, the next occurrence asks again.
+	m.Clear(ActionRTBH)
+	if m.Gate(ActionRTBH, "blackhole 192.0.2.0/24", exec) || m.Pending() != 1 {
+		t.Error("cleared action not staged again")
+	}
+	m.Clear(ActionRTBH)
+	if got := m.Requests()[0].Status; got != StatusCancelled {
+		t.Errorf("status after clear = %s, want cancelled", got)
+	}
+}
+
+func TestGateReject(t *testing.T) {
+	m, _ := newTestManager(OnTimeoutExecute)
+	m.Gate(ActionRTBH, "blackhole", func() { t.Error("rejected action executed") })
+	if _, err := m.Reject(1, "noc", "absorbing it"); err != nil {
+		t.Fatal(err)
+	}
+	if m.Gate(ActionRTBH, "blackhole", nil) || len(m.Requests()) != 1 {
+		t.Error("rejected action asked for again")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		onTimeout string
+		run       bool
+	}{
+		{OnTimeoutExecute, true},
+		{OnTimeoutCancel, false},
+	}
+	for _, tt := range tests {
+		m, clock := newTestManager(tt.onTimeout)
+		exec, ch := executed()
+		m.Gate(ActionRTBH, "blackhole", exec)
+
+		*clock = clock.Add(defaultTimeoutSec*time.Second - time.Second)
+		m.expire()
+		if m.Pending() != 1 {
+			t.Fatalf("%s: request expired early", tt.onTimeout)
+		}
+		*clock = clock.Add(time.Second)
+		m.expire()
+		if got := m.Requests()[0].Status; got != StatusExpired {
+			t.Fatalf("%s: status = %s, want expired", tt.onTimeout, got)
+		}
+		if tt.run {
+			wait(t, ch)
+		}
+		if got := m.Gate(ActionRTBH, "blackhole", exec); got != tt.run {
+			t.Errorf("%s: gate after timeout = %v, want %v", tt.onTimeout, got, tt.run)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("default config: %v", err)
+	}
+	bad := []Config{
+		{Actions: []string{"shutdown"}, OnTimeout: OnTimeoutExecute},
+		{Enabled: true, OnTimeout: OnTimeoutExecute},
+		{TimeoutSec: 60, OnTimeout: "wait"},
+	}
+	for _, c := range bad {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%+v: expected error", c)
+		}
+	}
+}
@@ -0,0 +1,597 @@
+// Package assets keeps the registry of protected assets: the destination
+// prefixes the scrubber defends, who owns them and how each one is
+// mitigated. The registry programs the per-destination BPF maps
+// (dst_policy_map, syn_cookie_dst and geoip_allow) and is persisted in the
+// state directory, so assets added through the API survive restarts.
+package assets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/geoip"
+	"go.uber.org/zap"
+)
+
+// Asset is a protected destination prefix and its mitigation policy.
+type Asset struct {
+	Name   string `yaml:"name" json:"name"`
+	Prefix string `yaml:"prefix" json:"prefix"`
+	Owner  string `yaml:"owner" json:"owner,omitempty"`
+
+	// Escalation level whose mitigations always apply to traffic towards
+	// the prefix, whatever the global level: low, medium, high or critical.
+	Profile string `yaml:"profile" json:"profile,omitempty"`
+
+	// SYN cookies for connections to the prefix
+	SYNCookie bool `yaml:"syn_cookie" json:"synCookie"`
+
+	// Per-source rate limits towards the prefix; 0 keeps the global limit
+	SYNRatePPS  uint64 `yaml:"syn_rate_pps" json:"synRatePps,omitempty"`
+	UDPRatePPS  uint64 `yaml:"udp_rate_pps" json:"udpRatePps,omitempty"`
+	ICMPRatePPS uint64 `yaml:"icmp_rate_pps" json:"icmpRatePps,omitempty"`
+
+	// AutoRTBH allows the prefix to be blackholed over BGP when escalation
+	// reaches CRITICAL.
+	AutoRTBH bool `yaml:"auto_rtbh" json:"autoRtbh"`
+
+	// GeoAllow, if set, admits traffic towards the prefix only from these
+	// countries; sources from other countries, or none, get GeoAllowAction.
+	// Takes effect while GeoIP policies are enforced.
+	GeoAllow       []string `yaml:"geo_allow" json:"geoAllow,omitempty"`
+	GeoAllowAction string   `yaml:"geo_allow_action" json:"geoAllowAction,omitempty"` // drop (default) or rate_limit
+}
+
+// Validate checks that the asset has a name, an IPv4 prefix and a known
+// profile.
+func (a Asset) Validate() error {
+	if a.Name == "" {
+		return fmt.Errorf("asset name is required")
+	}
+	_, n, err := net.ParseCIDR(a.Prefix)
+	if err != nil {
+		return fmt.Errorf("asset %s: invalid prefix: %w", a.Name, err)
+	}
+	if n.IP.To4() == nil {
+		return fmt.Errorf("asset %s: IPv6 prefix not supported: %s", a.Name, a.Prefix)
+	}
+	if a.Profile != "" {
+		if _, ok := escalation.ParseLevel(a.Profile); !ok {
+			return fmt.Errorf("asset %s: invalid profile %q (must be low, medium, high, or critical)", a.Name, a.Profile)
+		}
+	}
+	for _, cc := range a.GeoAllow {
+		if len(cc) != 2 {
+			return fmt.Errorf("asset %s: invalid country code %q in geo_allow", a.Name, cc)
+		}
+	}
+	if a.GeoAllowAction != "" {
+		if len(a.GeoAllow) == 0 {
+			return fmt.Errorf("asset %s: geo_allow_action requires geo_allow", a.Name)
+		}
+		if _, err := a.geoAllowAction(); err != nil {
+			return fmt.Errorf("asset %s: %w", a.Name, err)
+		}
+	}
+	return nil
+}
+
+// geoAllowAction returns the GeoIP action for countries not on GeoAllow.
+func (a Asset) geoAllowAction() (uint8, error) {
+	switch a.GeoAllowAction {
+	case "", "drop":
+		return geoip.ActionDrop, nil
+	case "rate_limit":
+		return geoip.ActionRateLimit, nil
+	}
+	return 0, fmt.Errorf("invalid geo_allow_action %q (must be drop or rate_limit)", a.GeoAllowAction)
+}
+
+// ValidateAll checks every asset and that names and prefixes are unique.
+func ValidateAll(assets []Asset) error {
+	names := make(map[string]bool, len(assets))
+	prefixes := make(map[string]string, len(assets))
+	for _, a := range assets {
+		if err := a.Validate(); err != nil {
+			return err
+		}
+		if names[a.Name] {
+			return fmt.Errorf("duplicate asset %q", a.Name)
+		}
+		names[a.Name] = true
+		_, n, _ := net.ParseCIDR(a.Prefix)
+		if other, ok := prefixes[n.String()]; ok {
+			return fmt.Errorf("asset %s: prefix %s already belongs to %q", a.Name, n, other)
+		}
+		prefixes[n.String()] = a.Name
+	}
+	return nil
+}
+
+// policy returns the data plane policy of the asset, whose country
+// allow-list, if any, has id allowID and whose owner's ACL lists have id
+// aclID.
+func (a Asset) policy(allowID uint16, aclID uint32) bpf.DstPolicy {
+	level, _ := escalation.ParseLevel(a.Profile)
+	p := bpf.DstPolicy{
+		SYNRatePPS:  a.SYNRatePPS,
+		UDPRatePPS:  a.UDPRatePPS,
+		ICMPRatePPS: a.ICMPRatePPS,
+		MinLevel:    uint32(level),
+		ACLID:       aclID,
+	}
+	if allowID != 0 {
+		p.GeoAllowID = allowID
+		p.GeoAllowAction, _ = a.geoAllowAction()
+	}
+	return p
+}
+
+// Map holds the per-destination policies, implemented by bpf.MapManager.
+type Map interface {
+	SetDstPolicy(cidr string, p bpf.DstPolicy) error
+	RemoveDstPolicy(cidr string) error
+	DstPolicies() (map[string]bpf.DstPolicy, error)
+	AddSYNCookieDest(cidr string) error
+	RemoveSYNCookieDest(cidr string) error
+	SetGeoAllow(id uint16, countries []string) error
+	RemoveGeoAllow(id uint16) error
+}
+
+// Registry owns the protected assets and their map entries.
+type Registry struct {
+	log *zap.Logger
+	m   Map
+
+	mu        sync.RWMutex
+	assets    map[string]Asset  // by name
+	allowIDs  map[string]uint16 // asset name → geoip_allow id
+	aclIDs    map[string]uint32 // owner → tenant ACL list id, kept for the process lifetime
+	nextACLID uint32
+}
+
+// NewRegistry creates an empty registry writing to m.
+func NewRegistry(log *zap.Logger, m Map) *Registry {
+	return &Registry{
+		log:      log,
+		m:        m,
+		assets:   make(map[string]Asset),
+		allowIDs: make(map[string]uint16),
+		aclIDs:   make(map[string]uint32),
+	}
+}
+
+// Configure adds the configured assets.
+func (r *Registry) Configure(assets []Asset) error {
+	for _, a := range assets {
+		if err := r.Add(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add registers an asset and programs its policy. Names and prefixes must
+// be unique.
+func (r *Registry) Add(a Asset) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	a.Prefix = canonical(a.Prefix)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.assets[a.Name]; ok {
+		return fmt.Errorf("asset %q already exists", a.Name)
+	}
+	if err := r.checkPrefixLocked(a); err != nil {
+		return err
+	}
+	if err := r.program(a); err != nil {
+		return fmt.Errorf("adding asset %s: %w", a.Name, err)
+	}
+	r.assets[a.Name] = a
+	r.log.Info("protected asset added",
+		zap.String("name", a.Name),
+		zap.String("prefix", a.Prefix),
+		zap.String("owner", a.Owner),
+		zap.String("profile", a.Profile),
+	)
+	return nil
+}
+
+// Update replaces the asset with the same name. The prefix keeps its
+// policy entry, and its traffic counters, throughout.
+func (r *Registry) Update(a Asset) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	a.Prefix = canonical(a.Prefix)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, ok := r.assets[a.Name]
+	if !ok {
+		return fmt.Errorf("asset %q not found", a.Name)
+	}
+	if err := r.checkPrefixLocked(a); err != nil {
+		return err
+	}
+	if err := r.reprogram(old, a); err != nil {
+		return fmt.Errorf("updating asset %s: %w", a.Name, err)
+	}
+	r.assets[a.Name] = a
+	r.log.Info("protected asset updated", zap.String("name", a.Name), zap.String("prefix", a.Prefix))
+	return nil
+}
+
+// Remove deletes an asset and its map entries.
+func (r *Registry) Remove(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.assets[name]
+	if !ok {
+		return fmt.Errorf("asset %q not found", name)
+	}
+	if err := r.unprogram(a); err != nil {
+		return fmt.Errorf("removing asset %s: %w", name, err)
+	}
+	delete(r.assets, name)
+	r.log.Info("protected asset removed", zap.String("name", name), zap.String("prefix", a.Prefix))
+	return nil
+}
+
+// Get returns the asset with the given name.
+func (r *Registry) Get(name string) (Asset, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	a, ok := r.assets[name]
+	return a, ok
+}
+
+// List returns every asset sorted by name.
+func (r *Registry) List() []Asset {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Asset, 0, len(r.assets))
+	for _, a := range r.assets {
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Owned returns the assets of owner sorted by name.
+func (r *Registry) Owned(owner string) []Asset {
+	out := make([]Asset, 0)
+	for _, a := range r.List() {
+		if a.Owner == owner {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// Stats is an asset and the traffic the data plane counted towards it.
+type Stats struct {
+	Asset
+	RxPackets      uint64 `json:"rxPackets"`
+	RxBytes        uint64 `json:"rxBytes"`
+	DroppedPackets uint64 `json:"droppedPackets"`
+	DroppedBytes   uint64 `json:"droppedBytes"`
+}
+
+// Stats returns the traffic counters of the given assets.
+func (r *Registry) Stats(list []Asset) ([]Stats, error) {
+	policies, err := r.m.DstPolicies()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Stats, 0, len(list))
+	for _, a := range list {
+		p := policies[a.Prefix]
+		out = append(out, Stats{
+			Asset:          a,
+			RxPackets:      p.RxPackets,
+			RxBytes:        p.RxBytes,
+			DroppedPackets: p.DroppedPackets,
+			DroppedBytes:   p.DroppedBytes,
+		})
+	}
+	return out, nil
+}
+
+// Lookup returns the asset with the most specific prefix containing ip.
+func (r *Registry) Lookup(ip net.IP) (Asset, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var (
+		best    Asset
+		bestLen = -1
+	)
+	for _, a := range r.assets {
+		_, n, _ := net.ParseCIDR(a.Prefix)
+		if ones, _ := n.Mask.Size(); n.Contains(ip) && ones > bestLen {
+			best, bestLen = a, ones
+		}
+	}
+	return best, bestLen >= 0
+}
+
+// ACLID returns the id of owner's lists in the tenant ACL maps, which
+// apply to traffic towards owner's assets. It reports false if owner has
+// never had an asset.
+func (r *Registry) ACLID(owner string) (uint32, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	id, ok := r.aclIDs[owner]
+	return id, ok
+}
+
+// aclIDLocked returns owner's tenant ACL list id, assigning the next one
+// on first use, or 0 for assets without owner. Caller must hold r.mu.
+func (r *Registry) aclIDLocked(owner string) uint32 {
+	if owner == "" {
+		return 0
+	}
+	id, ok := r.aclIDs[owner]
+	if !ok {
+		r.nextACLID++
+		id = r.nextACLID
+		r.aclIDs[owner] = id
+	}
+	return id
+}
+
+// AutoRTBH returns the prefixes that may be blackholed at CRITICAL.
+func (r *Registry) AutoRTBH() []string {
+	var out []string
+	for _, a := range r.List() {
+		if a.AutoRTBH {
+			out = append(out, a.Prefix)
+		}
+	}
+	return out
+}
+
+// checkPrefixLocked rejects a prefix that belongs to another asset. Caller
+// must hold r.mu.
+func (r *Registry) checkPrefixLocked(a Asset) error {
+	for _, other := range r.assets {
+		if other.Name != a.Name && other.Prefix == a.Prefix {
+			return fmt.Errorf("prefix %s already belongs to asset %q", a.Prefix, other.Name)
+		}
+	}
+	return nil
+}
+
+// program writes the asset's policy to the data plane. The country
+// allow-list goes in before the policy that refers to it. Caller must hold
+// r.mu.
+func (r *Registry) program(a Asset) error {
+	var allowID uint16
+	if len(a.GeoAllow) > 0 {
+		id, err := r.allocAllowIDLocked(a.Name)
+		if err != nil {
+			return err
+		}
+		if err := r.m.SetGeoAllow(id, a.GeoAllow); err != nil {
+			delete(r.allowIDs, a.Name)
+			return err
+		}
+		allowID = id
+	}
+	if err := r.m.SetDstPolicy(a.Prefix, a.policy(allowID, r.aclIDLocked(a.Owner))); err != nil {
+		r.releaseAllowLocked(a.Name)
+		return err
+	}
+	if a.SYNCookie {
+		if err := r.m.AddSYNCookieDest(a.Prefix); err != nil {
+			r.m.RemoveDstPolicy(a.Prefix)
+			r.releaseAllowLocked(a.Name)
+			return err
+		}
+	}
+	return nil
+}
+
+// reprogram changes the data plane from old's policy to a's, which has the
+// same name. The policy entry is replaced in place rather than removed and
+// re-added, so traffic towards the prefix is never without policy and its
+// counters carry over; SYN cookie and country allow-list entries are only
+// written where the setting changed. On error the old policy is restored
+// as far as possible. Caller must hold r.mu.
+func (r *Registry) reprogram(old, a Asset) error {
+	moved := old.Prefix != a.Prefix
+	oldAllowID, hadAllow := r.allowIDs[a.Name]
+
+	allowID := oldAllowID
+	if len(a.GeoAllow) == 0 {
+		allowID = 0
+	} else if !hadAllow {
+		id, err := r.allocAllowIDLocked(a.Name)
+		if err != nil {
+			return err
+		}
+		allowID = id
+	}
+	undoAllow := func() {
+		switch {
+		case !hadAllow:
+			r.releaseAllowLocked(a.Name)
+		case !sameCountries(old.GeoAllow, a.GeoAllow):
+			if err := r.m.SetGeoAllow(oldAllowID, old.GeoAllow); err != nil {
+				r.log.Error("failed to restore country allow-list", zap.String("asset", a.Name), zap.Error(err))
+			}
+		}
+	}
+	if allowID != 0 && (!hadAllow || !sameCountries(old.GeoAllow, a.GeoAllow)) {
+		if err := r.m.SetGeoAllow(allowID, a.GeoAllow); err != nil {
+			undoAllow()
+			return err
+		}
+	}
+
+	if err := r.m.SetDstPolicy(a.Prefix, a.policy(allowID, r.aclIDLocked(a.Owner))); err != nil {
+		undoAllow()
+		return err
+	}
+	if a.SYNCookie && (moved || !old.SYNCookie) {
+		if err := r.m.AddSYNCookieDest(a.Prefix); err != nil {
+			if moved {
+				r.m.RemoveDstPolicy(a.Prefix)
+			} else if rerr := r.m.SetDstPolicy(old.Prefix, old.policy(oldAllowID, r.aclIDLocked(old.Owner))); rerr != nil {
+				r.log.Error("failed to restore asset policy", zap.String("asset", a.Name), zap.Error(rerr))
+			}
+			undoAllow()
+			return err
+		}
+	}
+
+	// The new policy is in place; drop what only the old one used.
+	if old.SYNCookie && (moved || !a.SYNCookie) {
+		if err := r.m.RemoveSYNCookieDest(old.Prefix); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			r.log.Warn("failed to remove SYN cookie prefix", zap.String("prefix", old.Prefix), zap.Error(err))
+		}
+	}
+	if moved {
+		if err := r.m.RemoveDstPolicy(old.Prefix); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			r.log.Warn("failed to remove destination policy", zap.String("prefix", old.Prefix), zap.Error(err))
+		}
+	}
+	if hadAllow && allowID == 0 {
+		r.releaseAllowLocked(a.Name)
+	}
+	return nil
+}
+
+// sameCountries reports whether two country allow-lists hold the same
+// countries, in any order or case.
+func sameCountries(a, b []string) bool {
+	set := make(map[string]bool, len(a))
+	for _, cc := range a {
+		set[strings.ToUpper(cc)] = true
+	}
+	other := make(map[string]bool, len(b))
+	for _, cc := range b {
+		cc = strings.ToUpper(cc)
+		if !set[cc] {
+			return false
+		}
+		other[cc] = true
+	}
+	return len(other) == len(set)
+}
+
+// unprogram removes the asset's policy from the data plane. Entries that
+// are already gone are not an error. Caller must hold r.mu.
+func (r *Registry) unprogram(a Asset) error {
+	if a.SYNCookie {
+		if err := r.m.RemoveSYNCookieDest(a.Prefix); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return err
+		}
+	}
+	if err := r.m.RemoveDstPolicy(a.Prefix); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+		return err
+	}
+	r.releaseAllowLocked(a.Name)
+	return nil
+}
+
+// allocAllowIDLocked assigns the lowest free geoip_allow id to an asset.
+// Caller must hold r.mu.
+func (r *Registry) allocAllowIDLocked(name string) (uint16, error) {
+	used := make(map[uint16]bool, len(r.allowIDs))
+	for _, id := range r.allowIDs {
+		used[id] = true
+	}
+	for id := uint16(1); id != 0; id++ {
+		if !used[id] {
+			r.allowIDs[name] = id
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("no free country allow-list ids")
+}
+
+// releaseAllowLocked removes an asset's country allow-list, if it has one.
+// Caller must hold r.mu.
+func (r *Registry) releaseAllowLocked(name string) {
+	id, ok := r.allowIDs[name]
+	if !ok {
+		return
+	}
+	if err := r.m.RemoveGeoAllow(id); err != nil {
+		r.log.Warn("failed to remove country allow-list", zap.String("asset", name), zap.Error(err))
+	}
+	delete(r.allowIDs, name)
+}
+
+func canonical(prefix string) string {
+	_, n, _ := net.ParseCIDR(prefix)
+	return n.String()
+}
+
+// persistedState is the on-disk form of the registry.
+type persistedState struct {
+	SavedAt time.Time `json:"savedAt"`
+	Assets  []Asset   `json:"assets"`
+}
+
+// SaveState writes the registry to path atomically.
+func (r *Registry) SaveState(path string) error {
+	st := persistedState{SavedAt: time.Now(), Assets: r.List()}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling asset registry: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing asset registry: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing asset registry: %w", err)
+	}
+	r.log.Info("asset registry saved", zap.String("path", path), zap.Int("assets", len(st.Assets)))
+	return nil
+}
+
+// LoadState adds the assets saved at path. It reports false without error
+// if there is no saved registry.
+func (r *Registry) LoadState(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading asset registry: %w", err)
+	}
+	var st persistedState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return false, fmt.Errorf("parsing asset registry: %w", err)
+	}
+	if err := r.Configure(st.Assets); err != nil {
+		return true, err
+	}
+	r.log.Info("asset registry restored",
+		zap.String("path", path),
+		zap.Int("assets", len(st.Assets)),
+		zap.Time("saved_at", st.SavedAt),
+	)
+	return true, nil
+}
@@ -0,0 +1,354 @@
+package assets
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+// fakeMap records the policies, SYN cookie prefixes and country
+// allow-lists programmed, and counts the removals and allow-list writes.
+// Like MapManager, it keeps a policy's counters when the policy is set
+// again.
+type fakeMap struct {
+	policies    map[string]bpf.DstPolicy
+	cookies     map[string]bool
+	allow       map[uint16][]string
+	removals    int
+	allowWrites int
+}
+
+func newFakeMap() *fakeMap {
+	return &fakeMap{policies: map[string]bpf.DstPolicy{}, cookies: map[string]bool{}, allow: map[uint16][]string{}}
+}
+
+func (f *fakeMap) SetDstPolicy(cidr string, p bpf.DstPolicy) error {
+	cur := f.policies[cidr]
+	p.RxPackets, p.RxBytes = cur.RxPackets, cur.RxBytes
+	p.DroppedPackets, p.DroppedBytes = cur.DroppedPackets, cur.DroppedBytes
+	f.policies[cidr] = p
+	return nil
+}
+
+func (f *fakeMap) RemoveDstPolicy(cidr string) error {
+	f.removals++
+	delete(f.policies, cidr)
+	return nil
+}
+
+func (f *fakeMap) DstPolicies() (map[string]bpf.DstPolicy, error) {
+	return f.policies, nil
+}
+
+func (f *fakeMap) AddSYNCookieDest(cidr string) error {
+	f.cookies[cidr] = true
+	return nil
+}
+
+func (f *fakeMap) RemoveSYNCookieDest(cidr string) error {
+	f.removals++
+	delete(f.cookies, cidr)
+	return nil
+}
+
+func (f *fakeMap) SetGeoAllow(id uint16, countries []string) error {
+	f.allowWrites++
+	f.allow[id] = countries
+	return nil
+}
+
+func (f *fakeMap) RemoveGeoAllow(id uint16) error {
+	f.removals++
+	delete(f.allow, id)
+	return nil
+}
+
+func TestRegistryAddUpdateRemove(t *testing.T) {
+	m := newFakeMap()
+	r := NewRegistry(zap.NewNop(), m)
+
+	web := Asset{Name: "web", Prefix: "203.0.113.9/24", Profile: "high", SYNCookie: true, SYNRatePPS: 500}
+	if err := r.Add(web); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	p, ok := m.policies["203.0.113.0/24"]
+	if !ok || p.MinLevel != 2 || p.SYNRatePPS != 500 {
+		t.Fatalf("policy = %+v (present %t), want level 2 and 500 SYN pps", p, ok)
+	}
+	if !m.cookies["203.0.113.0/24"] {
+		t.Error("SYN cookies not enabled for the prefix")
+	}
+
+	for _, bad := range []Asset{
+		{Name: "web", Prefix: "198.51.100.0/24"},
+		{Name: "web2", Prefix: "203.0.113.0/24"},
+		{Name: "v6", Prefix: "2001:db8::/32"},
+		{Name: "bad", Prefix: "198.51.100.0/24", Profile: "extreme"},
+		{Prefix: "198.51.100.0/24"},
+	} {
+		if err := r.Add(bad); err == nil {
+			t.Errorf("Add(%+v) should fail", bad)
+		}
+	}
+
+	web.SYNCookie = false
+	web.Profile = ""
+	if err := r.Update(web); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if m.cookies["203.0.113.0/24"] || m.policies["203.0.113.0/24"].MinLevel != 0 {
+		t.Errorf("update not applied: policies %v, cookies %v", m.policies, m.cookies)
+	}
+	if err := r.Update(Asset{Name: "missing", Prefix: "198.51.100.0/24"}); err == nil {
+		t.Error("updating a missing asset should fail")
+	}
+
+	if err := r.Remove("web"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if len(m.policies) != 0 || len(r.List()) != 0 {
+		t.Errorf("asset still present after Remove: %v", m.policies)
+	}
+	if err := r.Remove("web"); err == nil {
+		t.Error("removing a missing asset should fail")
+	}
+}
+
+func TestRegistryGeoAllow(t *testing.T) {
+	m := newFakeMap()
+	r := NewRegistry(zap.NewNop(), m)
+
+	shop := Asset{Name: "shop", Prefix: "203.0.113.0/24", GeoAllow: []string{"DE", "AT"}}
+	if err := r.Add(shop); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	p := m.policies["203.0.113.0/24"]
+	if p.GeoAllowID != 1 || p.GeoAllowAction != 1 || len(m.allow[1]) != 2 {
+		t.Fatalf("policy = %+v, allow-lists = %v, want list 1 with drop", p, m.allow)
+	}
+	api := Asset{Name: "api", Prefix: "198.51.100.0/24", GeoAllow: []string{"FR"}, GeoAllowAction: "rate_limit"}
+	if err := r.Add(api); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if p := m.policies["198.51.100.0/24"]; p.GeoAllowID != 2 || p.GeoAllowAction != 2 {
+		t.Errorf("policy = %+v, want list 2 with rate_limit", p)
+	}
+
+	shop.GeoAllow = nil
+	if err := r.Update(shop); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if p := m.policies["203.0.113.0/24"]; p.GeoAllowID != 0 || len(m.allow) != 1 {
+		t.Errorf("policy = %+v, allow-lists = %v, want the list removed", p, m.allow)
+	}
+	if err := r.Remove("api"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if len(m.allow) != 0 {
+		t.Errorf("allow-lists = %v after Remove, want none", m.allow)
+	}
+
+	for _, bad := range []Asset{
+		{Name: "bad", Prefix: "192.0.2.0/24", GeoAllow: []string{"DEU"}},
+		{Name: "bad", Prefix: "192.0.2.0/24", GeoAllow: []string{"DE"}, GeoAllowAction: "monitor"},
+		{Name: "bad", Prefix: "192.0.2.0/24", GeoAllowAction: "drop"},
+	} {
+		if err := r.Add(bad); err == nil {
+			t.Errorf("Add(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	r := NewRegistry(zap.NewNop(), newFakeMap())
+	if err := r.Configure([]Asset{
+		{Name: "dc", Prefix: "203.0.113.0/24", AutoRTBH: true},
+		{Name: "dns", Prefix: "203.0.113.53/32"},
+	}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"203.0.113.53", "dns"},
+		{"203.0.113.80", "dc"},
+		{"198.51.100.1", ""},
+	}
+	for _, tt := range tests {
+		a, ok := r.Lookup(net.ParseIP(tt.ip))
+		if got := a.Name; got != tt.want || ok != (tt.want != "") {
+			t.Errorf("Lookup(%s) = %q, %t; want %q", tt.ip, got, ok, tt.want)
+		}
+	}
+
+	if got := r.AutoRTBH(); len(got) != 1 || got[0] != "203.0.113.0/24" {
+		t.Errorf("AutoRTBH() = %v", got)
+	}
+}
+
+func TestRegistryOwnedStats(t *testing.T) {
+	m := newFakeMap()
+	r := NewRegistry(zap.NewNop(), m)
+	if err := r.Configure([]Asset{
+		{Name: "web", Prefix: "203.0.113.0/24", Owner: "acme"},
+		{Name: "mail", Prefix: "198.51.100.0/24", Owner: "globex"},
+	}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	p := m.policies["203.0.113.0/24"]
+	p.RxPackets, p.DroppedPackets = 100, 40
+	m.policies["203.0.113.0/24"] = p
+
+	owned := r.Owned("acme")
+	if len(owned) != 1 || owned[0].Name != "web" {
+		t.Fatalf("Owned(acme) = %+v", owned)
+	}
+	st, err := r.Stats(owned)
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if len(st) != 1 || st[0].RxPackets != 100 || st[0].DroppedPackets != 40 {
+		t.Errorf("Stats = %+v", st)
+	}
+}
+
+func TestRegistryState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "assets.json")
+
+	r := NewRegistry(zap.NewNop(), newFakeMap())
+	if ok, err := r.LoadState(path); ok || err != nil {
+		t.Fatalf("LoadState without a file = %t, %v", ok, err)
+	}
+	if err := r.Add(Asset{Name: "web", Prefix: "203.0.113.0/24", Owner: "acme", UDPRatePPS: 100}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := r.SaveState(path); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+
+	m := newFakeMap()
+	restored := NewRegistry(zap.NewNop(), m)
+	if ok, err := restored.LoadState(path); !ok || err != nil {
+		t.Fatalf("LoadState = %t, %v", ok, err)
+	}
+	if a, ok := restored.Get("web"); !ok || a.Owner != "acme" {
+		t.Errorf("restored asset = %+v (present %t)", a, ok)
+	}
+	if m.policies["203.0.113.0/24"].UDPRatePPS != 100 {
+		t.Errorf("restored policy not programmed: %v", m.policies)
+	}
+}
+
+func TestValidateAll(t *testing.T) {
+	if err := ValidateAll([]Asset{
+		{Name: "a", Prefix: "203.0.113.0/24"},
+		{Name: "b", Prefix: "203.0.113.1/24"},
+	}); err == nil {
+		t.Error("overlapping identical prefixes should be rejected")
+	}
+	if err := ValidateAll([]Asset{
+		{Name: "a", Prefix: "203.0.113.0/24"},
+		{Name: "a", Prefix: "198.51.100.0/24"},
+	}); err == nil {
+		t.Error("duplicate names should be rejected")
+	}
+}
+
+func TestRegistryACLIDs(t *testing.T) {
+	m := newFakeMap()
+	r := NewRegistry(zap.NewNop(), m)
+
+	if err := r.Configure([]Asset{
+		{Name: "web", Prefix: "203.0.113.0/25", Owner: "acme"},
+		{Name: "mail", Prefix: "203.0.113.128/25", Owner: "acme"},
+		{Name: "shop", Prefix: "198.51.100.0/24", Owner: "globex"},
+		{Name: "core", Prefix: "192.0.2.0/24"},
+	}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	acme, _ := r.ACLID("acme")
+	globex, _ := r.ACLID("globex")
+	if acme == 0 || globex == 0 || acme == globex {
+		t.Fatalf("ACL ids acme=%d globex=%d, want distinct non-zero", acme, globex)
+	}
+	if m.policies["203.0.113.0/25"].ACLID != acme || m.policies["203.0.113.128/25"].ACLID != acme ||
+		m.policies["198.51.100.0/24"].ACLID != globex || m.policies["192.0.2.0/24"].ACLID != 0 {
+		t.Errorf("policies = %+v", m.policies)
+	}
+
+	// An owner keeps its id once its assets are gone, so its entries in the
+	// tenant lists never apply to another owner.
+	if err := r.Remove("shop"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := r.Add(Asset{Name: "shop2", Prefix: "198.51.100.0/24", Owner: "initech"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if id, _ := r.ACLID("initech"); id == globex {
+		t.Errorf("initech reuses globex's ACL id %d", id)
+	}
+	if id, ok := r.ACLID("globex"); !ok || id != globex {
+		t.Errorf("globex ACL id = %d, %t after removing its asset", id, ok)
+	}
+}
+
+func TestRegistryUpdateInPlace(t *testing.T) {
+	m := newFakeMap()
+	r := NewRegistry(zap.NewNop(), m)
+
+	web := Asset{Name: "web", Prefix: "203.0.113.0/24", Profile: "high", SYNCookie: true, GeoAllow: []string{"DE", "NL"}}
+	if err := r.Add(web); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	p := m.policies["203.0.113.0/24"]
+	p.RxPackets, p.DroppedPackets = 1000, 40
+	m.policies["203.0.113.0/24"] = p
+	m.removals, m.allowWrites = 0, 0
+
+	// Neither the prefix, SYN cookies nor the countries change.
+	web.Profile = "low"
+	web.GeoAllow = []string{"nl", "de"}
+	if err := r.Update(web); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	p = m.policies["203.0.113.0/24"]
+	if p.MinLevel != 0 || p.RxPackets != 1000 || p.DroppedPackets != 40 {
+		t.Errorf("policy = %+v, want level 0 with counters kept", p)
+	}
+	if m.removals != 0 || m.allowWrites != 0 {
+		t.Errorf("%d removals and %d allow-list writes, want none", m.removals, m.allowWrites)
+	}
+	if !m.cookies["203.0.113.0/24"] {
+		t.Error("SYN cookies dropped")
+	}
+
+	// Only the countries change: the list is rewritten under the same id.
+	web.GeoAllow = []string{"FR"}
+	if err := r.Update(web); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if m.removals != 0 || m.allowWrites != 1 {
+		t.Errorf("%d removals and %d allow-list writes, want 0 and 1", m.removals, m.allowWrites)
+	}
+	if got := m.allow[m.policies["203.0.113.0/24"].GeoAllowID]; len(got) != 1 || got[0] != "FR" {
+		t.Errorf("allow-list = %v, want [FR]", got)
+	}
+
+	// Moving the prefix removes the old entries.
+	web.Prefix = "198.51.100.0/24"
+	if err := r.Update(web); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, ok := m.policies["203.0.113.0/24"]; ok || m.cookies["203.0.113.0/24"] {
+		t.Error("old prefix still programmed")
+	}
+	if p := m.policies["198.51.100.0/24"]; p.RxPackets != 0 || !m.cookies["198.51.100.0/24"] {
+		t.Errorf("new prefix policy = %+v (SYN cookies %t), want fresh counters and SYN cookies",
+			p, m.cookies["198.51.100.0/24"])
+	}
+}
@@ -0,0 +1,503 @@
+// Package attacks groups traffic stats and events into attack sessions.
+//
+// An attack opens when the dropped packet rate reaches a threshold and
+// closes once it has stayed below it for a quiet period. While open, the
+// tracker records peak rates, the attack vectors and drop reasons of
+// events, the busiest sources and targets, and the mitigations the engine
+// applied. Finished attacks are kept in a bounded history and can be
+// rendered as JSON or Markdown reports for postmortems.
+package attacks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/events"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/stats"
+	"go.uber.org/zap"
+)
+
+const (
+	// Distinct sources and targets tracked per attack.
+	maxTrackedSources = 65536
+	maxTrackedTargets = 4096
+
+	// Mitigations recorded per attack; later ones are only counted.
+	maxMitigations = 200
+
+	// Targets listed in an attack summary.
+	topTargets = 10
+)
+
+// ErrNotFound is returned for unknown attack IDs.
+var ErrNotFound = errors.New("attack not found")
+
+// Config controls attack session tracking.
+type Config struct {
+	StartDropPPS float64 `yaml:"start_drop_pps"` // Drop rate that opens an attack
+	EndQuietSec  uint64  `yaml:"end_quiet_sec"`  // Seconds below start_drop_pps before it is closed
+	TopSources   int     `yaml:"top_sources"`    // Sources listed per attack
+	Retain       int     `yaml:"retain"`         // Finished attacks kept in history
+	ReportDir    string  `yaml:"report_dir"`     // Write JSON and Markdown reports of finished attacks ("" = off)
+}
+
+// DefaultConfig opens an attack at 1000 dropped pps and closes it after a
+// quiet minute.
+func DefaultConfig() Config {
+	return Config{StartDropPPS: 1000, EndQuietSec: 60, TopSources: 20, Retain: 200}
+}
+
+// Validate checks the tracker configuration.
+func (c Config) Validate() error {
+	if c.StartDropPPS <= 0 {
+		return fmt.Errorf("start_drop_pps must be positive")
+	}
+	if c.EndQuietSec == 0 {
+		return fmt.Errorf("end_quiet_sec must be positive")
+	}
+	if c.TopSources <= 0 {
+		return fmt.Errorf("top_sources must be positive")
+	}
+	if c.Retain <= 0 {
+		return fmt.Errorf("retain must be positive")
+	}
+	return nil
+}
+
+// Source is an attacking address seen in events.
+type Source struct {
+	IP      string `json:"ip"`
+	Country string `json:"country,omitempty"`
+	ASN     uint32 `json:"asn,omitempty"`
+	Events  uint64 `json:"events"`
+	Drops   uint64 `json:"drops"`
+	PeakPPS uint64 `json:"peakPps"` // Highest per-source estimate reported by the data plane
+}
+
+// Target is an attacked destination seen in events.
+type Target struct {
+	IP     string `json:"ip"`
+	Events uint64 `json:"events"`
+}
+
+// Mitigation is an action the engine took while the attack was open.
+type Mitigation struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// Attack is one attack session. End is nil while the attack is active.
+type Attack struct {
+	ID             string            `json:"id"`
+	Start          time.Time         `json:"start"`
+	End            *time.Time        `json:"end,omitempty"`
+	DurationSec    float64           `json:"durationSec"`
+	PeakRxPPS      float64           `json:"peakRxPps"`
+	PeakRxBPS      float64           `json:"peakRxBps"`
+	PeakDropPPS    float64           `json:"peakDropPps"`
+	PeakDropBPS    float64           `json:"peakDropBps"`
+	DroppedPackets uint64            `json:"droppedPackets"`
+	DroppedBytes   uint64            `json:"droppedBytes"`
+	PeakEscalation string            `json:"peakEscalation,omitempty"`
+	Events         uint64            `json:"events"`
+	Vectors        map[string]uint64 `json:"vectors"`     // Events per attack type
+	DropReasons    map[string]uint64 `json:"dropReasons"` // Dropped events per reason
+	UniqueSources  int               `json:"uniqueSources"`
+	TopSources     []Source          `json:"topSources"`
+	TopTargets     []Target          `json:"topTargets"`
+	Mitigations    []Mitigation      `json:"mitigations"`
+	// Mitigations beyond the recorded ones
+	MitigationsOmitted int `json:"mitigationsOmitted,omitempty"`
+}
+
+// Active reports whether the attack is still open.
+func (a *Attack) Active() bool {
+	return a.End == nil
+}
+
+// VectorNames returns the attack vectors seen, most frequent first.
+func (a *Attack) VectorNames() []string {
+	return sortedKeys(a.Vectors)
+}
+
+// session is the open attack and the tallies its summary is built from.
+type session struct {
+	Attack
+	base       bpf.GlobalStats // Counters before the attack opened
+	peakLevel  int
+	quietSince time.Time // Zero while the drop rate is above the threshold
+	sources    map[string]*Source
+	targets    map[string]uint64
+}
+
+// Tracker builds attack sessions from stats snapshots and events.
+type Tracker struct {
+	log *zap.Logger
+	cfg Config
+
+	mu      sync.Mutex
+	active  *session
+	history []Attack        // Finished attacks, oldest first
+	last    bpf.GlobalStats // Counters of the previous snapshot
+	onEnd   func(Attack)
+}
+
+// NewTracker creates a tracker for cfg, which must be valid.
+func NewTracker(log *zap.Logger, cfg Config) *Tracker {
+	return &Tracker{log: log, cfg: cfg}
+}
+
+// OnEnd registers a callback invoked with each finished attack.
+func (t *Tracker) OnEnd(fn func(Attack)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onEnd = fn
+}
+
+// Run feeds snapshots from ch to Observe until the context is cancelled.
+func (t *Tracker) Run(ctx context.Context, ch <-chan *stats.Snapshot) {
+	t.log.Info("attack tracking started",
+		zap.Float64("start_drop_pps", t.cfg.StartDropPPS),
+		zap.Uint64("end_quiet_sec", t.cfg.EndQuietSec),
+	)
+
+	first := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case snap := <-ch:
+			// The first snapshot has no previous one to compute rates from.
+			if first {
+				first = false
+				t.mu.Lock()
+				t.last = snap.Stats
+				t.mu.Unlock()
+				continue
+			}
+			t.Observe(snap)
+		}
+	}
+}
+
+// Observe opens, updates or closes the attack session from a snapshot.
+func (t *Tracker) Observe(snap *stats.Snapshot) {
+	t.mu.Lock()
+	s := t.active
+	prev := t.last
+	t.last = snap.Stats
+	attacking := snap.DropPPS >= t.cfg.StartDropPPS
+	if s == nil {
+		if !attacking {
+			t.mu.Unlock()
+			return
+		}
+		s = &session{
+			Attack: Attack{
+				ID:          "atk-" + snap.Timestamp.UTC().Format("20060102T150405Z"),
+				Start:       snap.Timestamp,
+				Vectors:     make(map[string]uint64),
+				DropReasons: make(map[string]uint64),
+			},
+			base:    prev,
+			sources: make(map[string]*Source),
+			targets: make(map[string]uint64),
+		}
+		t.active = s
+		t.log.Warn("attack started", zap.String("id", s.ID), zap.Float64("drop_pps", snap.DropPPS))
+	}
+
+	s.PeakRxPPS = max(s.PeakRxPPS, snap.RxPPS)
+	s.PeakRxBPS = max(s.PeakRxBPS, snap.RxBPS)
+	s.PeakDropPPS = max(s.PeakDropPPS, snap.DropPPS)
+	s.PeakDropBPS = max(s.PeakDropBPS, snap.DropBPS)
+	s.DroppedPackets = delta(snap.Stats.DroppedPackets, s.base.DroppedPackets)
+	s.DroppedBytes = delta(snap.Stats.DroppedBytes, s.base.DroppedBytes)
+	s.DurationSec = snap.Timestamp.Sub(s.Start).Seconds()
+
+	switch {
+	case attacking:
+		s.quietSince = time.Time{}
+	case s.quietSince.IsZero():
+		s.quietSince = snap.Timestamp
+	}
+	if s.quietSince.IsZero() || snap.Timestamp.Sub(s.quietSince) < time.Duration(t.cfg.EndQuietSec)*time.Second {
+		t.mu.Unlock()
+		return
+	}
+
+	// The attack ended when the drop rate first fell below the threshold.
+	end := s.quietSince
+	s.End = &end
+	s.DurationSec = end.Sub(s.Start).Seconds()
+	a := t.summary(s)
+	t.active = nil
+	t.history = append(t.history, a)
+	if len(t.history) > t.cfg.Retain {
+		t.history = t.history[len(t.history)-t.cfg.Retain:]
+	}
+	onEnd := t.onEnd
+	t.mu.Unlock()
+
+	t.log.Warn("attack ended",
+		zap.String("id", a.ID),
+		zap.Float64("duration_sec", a.DurationSec),
+		zap.Float64("peak_drop_pps", a.PeakDropPPS),
+		zap.Uint64("dropped_packets", a.DroppedPackets),
+	)
+	if t.cfg.ReportDir != "" {
+		if err := WriteReports(t.cfg.ReportDir, a); err != nil {
+			t.log.Error("failed to write attack report", zap.String("id", a.ID), zap.Error(err))
+		}
+	}
+	if onEnd != nil {
+		onEnd(a)
+	}
+}
+
+// delta returns cur-base, or 0 if the counters were reset.
+func delta(cur, base uint64) uint64 {
+	if cur < base {
+		return 0
+	}
+	return cur - base
+}
+
+// HandleEvent attributes an enriched event to the open attack, if any.
+func (t *Tracker) HandleEvent(ev *bpf.Event, info events.Info) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.active
+	if s == nil {
+		return
+	}
+
+	// Sampled and aggregated events count for all the events they stand for.
+	n := info.Events()
+	s.Events += n
+	s.Vectors[bpf.AttackTypeName(ev.AttackType)] += n
+	dropped := ev.Action == 1
+	if dropped {
+		s.DropReasons[bpf.DropReasonName(ev.DropReason)] += n
+	}
+
+	ip := bpf.U32BEToIP(ev.SrcIP).String()
+	src, ok := s.sources[ip]
+	if !ok && len(s.sources) < maxTrackedSources {
+		src = &Source{IP: ip, ASN: info.ASN}
+		if ev.CountryCode != 0 {
+			src.Country = string([]byte{byte(ev.CountryCode >> 8), byte(ev.CountryCode)})
+		}
+		s.sources[ip] = src
+	}
+	if src != nil {
+		src.Events += n
+		if dropped {
+			src.Drops += n
+		}
+		src.PeakPPS = max(src.PeakPPS, ev.PPSEstimate)
+	}
+
+	dst := bpf.U32BEToIP(ev.DstIP).String()
+	if _, ok := s.targets[dst]; ok || len(s.targets) < maxTrackedTargets {
+		s.targets[dst] += n
+	}
+}
+
+// Mitigation records an action taken against the open attack, if any.
+func (t *Tracker) Mitigation(action, detail string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.active
+	if s == nil {
+		return
+	}
+	if len(s.Mitigations) >= maxMitigations {
+		s.MitigationsOmitted++
+		return
+	}
+	s.Mitigations = append(s.Mitigations, Mitigation{Time: time.Now(), Action: action, Detail: detail})
+}
+
+// Escalated records an escalation level reached during the open attack.
+// Escalations raised before the attack opened are picked up by the next
+// change.
+func (t *Tracker) Escalated(level int, name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if s := t.active; s != nil && (s.PeakEscalation == "" || level > s.peakLevel) {
+		s.peakLevel = level
+		s.PeakEscalation = name
+	}
+}
+
+// summary copies a session into an Attack with its top sources and
+// targets. Caller must hold t.mu.
+func (t *Tracker) summary(s *session) Attack {
+	a := s.Attack
+	a.Vectors = copyCounts(s.Vectors)
+	a.DropReasons = copyCounts(s.DropReasons)
+	a.Mitigations = append([]Mitigation(nil), s.Mitigations...)
+	if s.End != nil {
+		end := *s.End
+		a.End = &end
+	}
+
+	a.UniqueSources = len(s.sources)
+	a.TopSources = make([]Source, 0, len(s.sources))
+	for _, src := range s.sources {
+		a.TopSources = append(a.TopSources, *src)
+	}
+	sort.Slice(a.TopSources, func(i, j int) bool {
+		if a.TopSources[i].Events != a.TopSources[j].Events {
+			return a.TopSources[i].Events > a.TopSources[j].Events
+		}
+		return a.TopSources[i].IP < a.TopSources[j].IP
+	})
+	if len(a.TopSources) > t.cfg.TopSources {
+		a.TopSources = a.TopSources[:t.cfg.TopSources]
+	}
+
+	a.TopTargets = make([]Target, 0, len(s.targets))
+	for ip, n := range s.targets {
+		a.TopTargets = append(a.TopTargets, Target{IP: ip, Events: n})
+	}
+	sort.Slice(a.TopTargets, func(i, j int) bool {
+		if a.TopTargets[i].Events != a.TopTargets[j].Events {
+			return a.TopTargets[i].Events > a.TopTargets[j].Events
+		}
+		return a.TopTargets[i].IP < a.TopTargets[j].IP
+	})
+	if len(a.TopTargets) > topTargets {
+		a.TopTargets = a.TopTargets[:topTargets]
+	}
+	return a
+}
+
+func copyCounts(m map[string]uint64) map[string]uint64 {
+	out := make(map[string]uint64, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// Active returns the open attack, if any.
+func (t *Tracker) Active() (Attack, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.active == nil {
+		return Attack{}, false
+	}
+	return t.summary(t.active), true
+}
+
+// List returns the open attack, if any, and the finished ones, newest
+// first.
+func (t *Tracker) List() []Attack {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Attack, 0, len(t.history)+1)
+	if t.active != nil {
+		out = append(out, t.summary(t.active))
+	}
+	for i := len(t.history) - 1; i >= 0; i-- {
+		out = append(out, t.history[i])
+	}
+	return out
+}
+
+// Get returns the attack with the given ID.
+func (t *Tracker) Get(id string) (Attack, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.active != nil && t.active.ID == id {
+		return t.summary(t.active), nil
+	}
+	for _, a := range t.history {
+		if a.ID == id {
+			return a, nil
+		}
+	}
+	return Attack{}, ErrNotFound
+}
+
+// SaveState writes the finished attacks to path atomically. An attack
+// still open is closed at the time of the save.
+func (t *Tracker) SaveState(path string) error {
+	t.mu.Lock()
+	history := append([]Attack(nil), t.history...)
+	if s := t.active; s != nil {
+		a := t.summary(s)
+		end := a.Start.Add(time.Duration(a.DurationSec * float64(time.Second)))
+		a.End = &end
+		history = append(history, a)
+	}
+	t.mu.Unlock()
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("marshaling attack history: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing attack history: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing attack history: %w", err)
+	}
+
+	t.log.Info("attack history saved", zap.String("path", path), zap.Int("attacks", len(history)))
+	return nil
+}
+
+// LoadState restores attacks saved by SaveState. A missing file is not an
+// error.
+func (t *Tracker) LoadState(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading attack history: %w", err)
+	}
+
+	var history []Attack
+	if err := json.Unmarshal(data, &history); err != nil {
+		return fmt.Errorf("parsing attack history: %w", err)
+	}
+	if len(history) > t.cfg.Retain {
+		history = history[len(history)-t.cfg.Retain:]
+	}
+
+	t.mu.Lock()
+	t.history = history
+	t.mu.Unlock()
+
+	t.log.Info("attack history restored", zap.String("path", path), zap.Int("attacks", len(history)))
+	return nil
+}
+
+// WriteReports writes <id>.json and <id>.md reports of a into dir.
+func WriteReports(dir string, a Attack) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, a.ID+".json"), data, 0640); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, a.ID+".md"), []byte(Markdown(a)), 0640)
+}
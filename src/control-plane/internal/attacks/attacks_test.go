@@ -0,0 +1,142 @@
+package attacks
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/events"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/stats"
+	"go.uber.org/zap"
+)
+
+func snapshot(at time.Time, dropPPS float64, dropped uint64) *stats.Snapshot {
+	return &stats.Snapshot{
+		Timestamp: at,
+		Stats:     bpf.GlobalStats{DroppedPackets: dropped},
+		RxPPS:     dropPPS * 2,
+		DropPPS:   dropPPS,
+	}
+}
+
+func event(src, dst string, attack uint8) *bpf.Event {
+	return &bpf.Event{
+		SrcIP:       bpf.IPToU32BE(net.ParseIP(src)),
+		DstIP:       bpf.IPToU32BE(net.ParseIP(dst)),
+		AttackType:  attack,
+		Action:      1,
+		DropReason:  bpf.DropBlacklist,
+		PPSEstimate: 5000,
+	}
+}
+
+func TestTrackerSession(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.EndQuietSec = 10
+	cfg.TopSources = 1
+	tr := NewTracker(zap.NewNop(), cfg)
+	var ended []Attack
+	tr.OnEnd(func(a Attack) { ended = append(ended, a) })
+
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tr.Observe(snapshot(t0, 10, 100))
+	tr.HandleEvent(event("198.51.100.1", "203.0.113.9", bpf.AttackSYNFlood), events.Info{})
+	if _, ok := tr.Active(); ok {
+		t.Fatal("attack opened below the threshold")
+	}
+
+	tr.Observe(snapshot(t0.Add(time.Second), 5000, 5100))
+	tr.HandleEvent(event("198.51.100.1", "203.0.113.9", bpf.AttackSYNFlood), events.Info{ASN: 64500})
+	tr.HandleEvent(event("198.51.100.1", "203.0.113.9", bpf.AttackSYNFlood), events.Info{})
+	tr.HandleEvent(event("198.51.100.2", "203.0.113.9", bpf.AttackUDPFlood), events.Info{})
+	tr.Mitigation("escalation", "HIGH")
+	tr.Escalated(2, "HIGH")
+	tr.Escalated(1, "MEDIUM")
+	tr.Observe(snapshot(t0.Add(2*time.Second), 8000, 13100))
+
+	a, ok := tr.Active()
+	if !ok || a.ID != "atk-20260102T030406Z" {
+		t.Fatalf("Active() = %+v, %t", a, ok)
+	}
+	if a.PeakDropPPS != 8000 || a.DroppedPackets != 13000 {
+		t.Errorf("peak %v, dropped %d; want 8000 and 13000", a.PeakDropPPS, a.DroppedPackets)
+	}
+	if a.Vectors["syn_flood"] != 2 || a.Vectors["udp_flood"] != 1 {
+		t.Errorf("vectors = %v", a.Vectors)
+	}
+	if len(a.TopSources) != 1 || a.TopSources[0].IP != "198.51.100.1" || a.TopSources[0].ASN != 64500 || a.UniqueSources != 2 {
+		t.Errorf("top sources = %+v of %d", a.TopSources, a.UniqueSources)
+	}
+	if a.PeakEscalation != "HIGH" || len(a.Mitigations) != 1 {
+		t.Errorf("escalation %q, mitigations %v", a.PeakEscalation, a.Mitigations)
+	}
+
+	// Quiet, a brief spike that resets the quiet period, then quiet again.
+	tr.Observe(snapshot(t0.Add(3*time.Second), 0, 13100))
+	tr.Observe(snapshot(t0.Add(8*time.Second), 2000, 14000))
+	tr.Observe(snapshot(t0.Add(9*time.Second), 0, 14000))
+	tr.Observe(snapshot(t0.Add(18*time.Second), 0, 14000))
+	if len(ended) != 0 {
+		t.Fatal("attack closed before the quiet period elapsed")
+	}
+	tr.Observe(snapshot(t0.Add(19*time.Second), 0, 14000))
+	if len(ended) != 1 {
+		t.Fatal("attack not closed after the quiet period")
+	}
+	if got := ended[0]; got.End == nil || !got.End.Equal(t0.Add(9*time.Second)) || got.DurationSec != 8 {
+		t.Errorf("ended attack end %v, duration %v", got.End, got.DurationSec)
+	}
+
+	if _, ok := tr.Active(); ok {
+		t.Error("attack still active after closing")
+	}
+	if list := tr.List(); len(list) != 1 || list[0].Active() {
+		t.Errorf("List() = %+v", list)
+	}
+	if _, err := tr.Get("atk-missing"); err != ErrNotFound {
+		t.Errorf("Get(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestTrackerStateAndReports(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.ReportDir = filepath.Join(dir, "reports")
+	tr := NewTracker(zap.NewNop(), cfg)
+
+	t0 := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tr.Observe(snapshot(t0, 5000, 100))
+	tr.HandleEvent(event("198.51.100.1", "203.0.113.9", bpf.AttackDNSAmp), events.Info{})
+	tr.Mitigation("rtbh", "203.0.113.0/24")
+	tr.Observe(snapshot(t0.Add(time.Second), 0, 100))
+	tr.Observe(snapshot(t0.Add(61*time.Second), 0, 100))
+
+	md, err := os.ReadFile(filepath.Join(cfg.ReportDir, "atk-20260102T030405Z.md"))
+	if err != nil {
+		t.Fatalf("reading Markdown report: %v", err)
+	}
+	for _, want := range []string{"# Attack report atk-20260102T030405Z", "| dns_amplification | 1 |", "rtbh: 203.0.113.0/24"} {
+		if !strings.Contains(string(md), want) {
+			t.Errorf("report missing %q:\n%s", want, md)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(cfg.ReportDir, "atk-20260102T030405Z.json")); err != nil {
+		t.Errorf("JSON report: %v", err)
+	}
+
+	path := filepath.Join(dir, "attacks.json")
+	if err := tr.SaveState(path); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	restored := NewTracker(zap.NewNop(), cfg)
+	if err := restored.LoadState(path); err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if a, err := restored.Get("atk-20260102T030405Z"); err != nil || a.Vectors["dns_amplification"] != 1 {
+		t.Errorf("restored attack = %+v, %v", a, err)
+	}
+}
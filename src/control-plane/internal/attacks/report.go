@@ -0,0 +1,106 @@
+package attacks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Markdown renders a postmortem summary of a.
+func Markdown(a Attack) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Attack report %s\n\n", a.ID)
+
+	end := "ongoing"
+	if a.End != nil {
+		end = a.End.UTC().Format(time.RFC3339)
+	}
+	fmt.Fprintf(&b, "| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Start | %s |\n", a.Start.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "| End | %s |\n", end)
+	fmt.Fprintf(&b, "| Duration | %s |\n", time.Duration(a.DurationSec*float64(time.Second)).Round(time.Second))
+	fmt.Fprintf(&b, "| Peak receive | %.0f pps / %s |\n", a.PeakRxPPS, formatBPS(a.PeakRxBPS))
+	fmt.Fprintf(&b, "| Peak drop | %.0f pps / %s |\n", a.PeakDropPPS, formatBPS(a.PeakDropBPS))
+	fmt.Fprintf(&b, "| Dropped | %d packets / %d bytes |\n", a.DroppedPackets, a.DroppedBytes)
+	if a.PeakEscalation != "" {
+		fmt.Fprintf(&b, "| Peak escalation | %s |\n", a.PeakEscalation)
+	}
+	fmt.Fprintf(&b, "| Events | %d from %d sources |\n", a.Events, a.UniqueSources)
+
+	writeCounts(&b, "Attack vectors", "Vector", a.Vectors)
+	writeCounts(&b, "Drop reasons", "Reason", a.DropReasons)
+
+	if len(a.TopSources) > 0 {
+		b.WriteString("\n## Top sources\n\n| Source | Country | ASN | Events | Drops | Peak pps |\n|---|---|---|---|---|---|\n")
+		for _, s := range a.TopSources {
+			asn := ""
+			if s.ASN != 0 {
+				asn = fmt.Sprintf("AS%d", s.ASN)
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %d | %d | %d |\n", s.IP, s.Country, asn, s.Events, s.Drops, s.PeakPPS)
+		}
+	}
+
+	if len(a.TopTargets) > 0 {
+		b.WriteString("\n## Targets\n\n| Destination | Events |\n|---|---|\n")
+		for _, t := range a.TopTargets {
+			fmt.Fprintf(&b, "| %s | %d |\n", t.IP, t.Events)
+		}
+	}
+
+	b.WriteString("\n## Mitigations\n\n")
+	if len(a.Mitigations) == 0 {
+		b.WriteString("None recorded.\n")
+	}
+	for _, m := range a.Mitigations {
+		fmt.Fprintf(&b, "- %s %s", m.Time.UTC().Format("15:04:05"), m.Action)
+		if m.Detail != "" {
+			fmt.Fprintf(&b, ": %s", m.Detail)
+		}
+		b.WriteString("\n")
+	}
+	if a.MitigationsOmitted > 0 {
+		fmt.Fprintf(&b, "- ... and %d more\n", a.MitigationsOmitted)
+	}
+	return b.String()
+}
+
+// writeCounts renders a count table, largest first.
+func writeCounts(b *strings.Builder, title, column string, counts map[string]uint64) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n## %s\n\n| %s | Events |\n|---|---|\n", title, column)
+	for _, k := range sortedKeys(counts) {
+		fmt.Fprintf(b, "| %s | %d |\n", k, counts[k])
+	}
+}
+
+// sortedKeys returns the keys of counts, largest count first.
+func sortedKeys(counts map[string]uint64) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// formatBPS renders a bit rate with an SI prefix.
+func formatBPS(bps float64) string {
+	switch {
+	case bps >= 1e9:
+		return fmt.Sprintf("%.2f Gbps", bps/1e9)
+	case bps >= 1e6:
+		return fmt.Sprintf("%.2f Mbps", bps/1e6)
+	case bps >= 1e3:
+		return fmt.Sprintf("%.2f kbps", bps/1e3)
+	}
+	return fmt.Sprintf("%.0f bps", bps)
+}
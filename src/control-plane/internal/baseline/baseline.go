@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/cilium/ebpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
 	"go.uber.org/zap"
 )
 
@@ -35,6 +36,11 @@ const (
 	adaptiveUDPMultiplier  = 2.0
 	adaptiveICMPMultiplier = 5.0
 	adaptiveGlobalMargin   = 2.0
+	adaptiveDNSMultiplier  = 3.0
+
+	// Minimum adaptive limits to avoid zero-rate lockout.
+	minAdaptivePPS     = 100
+	minAdaptiveICMPPPS = 100
 )
 
 // Config map keys matching types.h CFG_* constants.
@@ -59,6 +65,12 @@ type Metrics struct {
 	ZScoreBPS    float64
 	IsAnomaly    bool
 	AnomalyScore float64
+	PulseWave    bool // Bursts repeating at a regular interval
+
+	// Seasonal is the hour-of-week bucket of the current time. Once it
+	// has learned, the z-scores compare against it rather than the
+	// overall EWMA, which is still what is pushed to the data plane.
+	Seasonal SeasonalBucket
 }
 
 // AdaptiveRates holds recommended rate limits derived from the baseline.
@@ -67,12 +79,81 @@ type AdaptiveRates struct {
 	UdpPPS    uint64
 	IcmpPPS   uint64
 	GlobalPPS uint64
+	// DnsPPS is advisory; the data plane has no separate DNS rate limit.
+	DnsPPS uint64
+}
+
+// Protocol identifies a per-protocol traffic class with its own baseline.
+type Protocol int
+
+const (
+	ProtoTCPSYN Protocol = iota
+	ProtoUDP
+	ProtoICMP
+	ProtoDNS
+	numProtocols
+)
+
+// String returns the protocol class name.
+func (p Protocol) String() string {
+	switch p {
+	case ProtoTCPSYN:
+		return "tcp_syn"
+	case ProtoUDP:
+		return "udp"
+	case ProtoICMP:
+		return "icmp"
+	case ProtoDNS:
+		return "dns"
+	default:
+		return "unknown"
+	}
+}
+
+// ProtocolRates holds per-protocol packet rates for one sample.
+type ProtocolRates struct {
+	SYN  float64
+	UDP  float64
+	ICMP float64
+	DNS  float64
+}
+
+// ProtocolBaseline is the learned state of one protocol class.
+type ProtocolBaseline struct {
+	Protocol    string
+	BaselinePPS float64
+	StdDevPPS   float64
+	CurrentPPS  float64
+	Samples     int
+	Operational bool
+}
+
+// protoEWMA is the EWMA state of one protocol class.
+type protoEWMA struct {
+	mean     float64
+	variance float64
+	current  float64
+	samples  int
+}
+
+func (e *protoEWMA) feed(x float64) {
+	e.current = x
+	e.samples++
+	if e.samples == 1 {
+		e.mean, e.variance = x, 0
+		return
+	}
+	e.mean, e.variance = updateEWMA(e.mean, e.variance, x)
+}
+
+func (e *protoEWMA) operational() bool {
+	return e.samples >= learningPeriod
 }
 
 // Baseline provides EWMA-based traffic baseline learning and anomaly detection.
 type Baseline struct {
 	log       *zap.Logger
-	configMap *ebpf.Map
+	configMap bpf.ConfigTable
 
 	mu sync.RWMutex
 
@@ -88,6 +169,13 @@ type Baseline struct {
 	meanDropPPS     float64
 	varianceDropPPS float64
 
+	// Per-protocol EWMA state, indexed by Protocol.
+	protos [numProtocols]protoEWMA
+
+	// Per-hour-of-week EWMA state, so traffic is compared with the same
+	// hour in earlier weeks.
+	seasons [hoursPerWeek]seasonEWMA
+
 	// Current values (most recent feed).
 	currentPPS     float64
 	currentBPS     float64
@@ -96,15 +184,27 @@ type Baseline struct {
 	// Sample count for learning period tracking.
 	sampleCount int
 
+	// Bursts above the baseline, and whether the current sample is part
+	// of one and kept out of the EWMA.
+	pulse     pulseTracker
+	pulseHold bool
+
 	// Last push time.
 	lastPush time.Time
+
+	statePath string // Saved every saveInterval when set
+
+	done chan struct{} // closed when the background loop exits
+	now  func() time.Time
 }
 
 // NewBaseline creates a new traffic baseline tracker.
-func NewBaseline(log *zap.Logger, configMap *ebpf.Map) *Baseline {
+func NewBaseline(log *zap.Logger, configMap bpf.ConfigTable) *Baseline {
 	return &Baseline{
 		log:       log,
 		configMap: configMap,
+		done:      make(chan struct{}),
+		now:       time.Now,
 	}
 }
 
@@ -120,9 +220,22 @@ func (b *Baseline) Start(ctx context.Context) error {
 	return nil
 }
 
+// Done returns a channel that is closed once the push loop started by
+// Start has exited.
+func (b *Baseline) Done() <-chan struct{} {
+	return b.done
+}
+
 func (b *Baseline) run(ctx context.Context) {
+	defer close(b.done)
+
 	ticker := time.NewTicker(pushInterval)
 	defer ticker.Stop()
+	saveTicker := time.NewTicker(saveInterval)
+	defer saveTicker.Stop()
+
+	// A baseline restored warm is pushed straight away.
+	b.push()
 
 	for {
 		select {
@@ -130,19 +243,34 @@ func (b *Baseline) run(ctx context.Context) {
 			b.log.Info("baseline engine stopped")
 			return
 		case <-ticker.C:
+			b.push()
+		case <-saveTicker.C:
 			b.mu.RLock()
-			operational := b.sampleCount >= learningPeriod
+			path := b.statePath
 			b.mu.RUnlock()
 
-			if operational {
-				if err := b.UpdateBPFConfig(); err != nil {
-					b.log.Warn("failed to push baseline to BPF", zap.Error(err))
+			if path != "" {
+				if err := b.SaveState(path); err != nil {
+					b.log.Warn("failed to save baseline", zap.Error(err))
 				}
 			}
 		}
 	}
 }
 
+// push writes the baseline to the BPF config map once it is operational.
+func (b *Baseline) push() {
+	b.mu.RLock()
+	operational := b.sampleCount >= learningPeriod
+	b.mu.RUnlock()
+
+	if operational {
+		if err := b.UpdateBPFConfig(); err != nil {
+			b.log.Warn("failed to push baseline to BPF", zap.Error(err))
+		}
+	}
+}
+
 // Feed pushes a new stats snapshot for baseline calculation.
 // Should be called approximately every 1 second.
 func (b *Baseline) Feed(rxPps, rxBps, dropPps float64) {
@@ -162,9 +290,23 @@ func (b *Baseline) Feed(rxPps, rxBps, dropPps float64) {
 		b.variancePPS = 0
 		b.varianceBPS = 0
 		b.varianceDropPPS = 0
+		b.seasons[hourOfWeek(b.now())].feed(rxPps, rxBps)
 		return
 	}
 
+	// Short bursts are pulse-wave candidates: learning them would inflate
+	// the mean and variance and hide the next burst.
+	b.pulseHold = false
+	if b.sampleCount > learningPeriod {
+		z := zScore(rxPps, b.meanPPS, math.Sqrt(b.variancePPS))
+		b.pulseHold = b.observePulse(rxPps, z > anomalyZThreshold && rxPps >= b.meanPPS*pulseMinRatio)
+		if b.pulseHold {
+			return
+		}
+	}
+
+	b.seasons[hourOfWeek(b.now())].feed(rxPps, rxBps)
+
 	// Update EWMA for PPS.
 	b.meanPPS, b.variancePPS = updateEWMA(b.meanPPS, b.variancePPS, rxPps)
 
@@ -175,6 +317,45 @@ func (b *Baseline) Feed(rxPps, rxBps, dropPps float64) {
 	b.meanDropPPS, b.varianceDropPPS = updateEWMA(b.meanDropPPS, b.varianceDropPPS, dropPps)
 }
 
+// FeedProtocols pushes per-protocol packet rates derived from the
+// per-protocol RX counters. Should be called at the same cadence as Feed.
+func (b *Baseline) FeedProtocols(r ProtocolRates) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.pulseHold {
+		b.protos[ProtoTCPSYN].current = r.SYN
+		b.protos[ProtoUDP].current = r.UDP
+		b.protos[ProtoICMP].current = r.ICMP
+		b.protos[ProtoDNS].current = r.DNS
+		return
+	}
+	b.protos[ProtoTCPSYN].feed(r.SYN)
+	b.protos[ProtoUDP].feed(r.UDP)
+	b.protos[ProtoICMP].feed(r.ICMP)
+	b.protos[ProtoDNS].feed(r.DNS)
+}
+
+// GetProtocolBaselines returns the learned baseline of every protocol class.
+func (b *Baseline) GetProtocolBaselines() []ProtocolBaseline {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	out := make([]ProtocolBaseline, 0, numProtocols)
+	for p := Protocol(0); p < numProtocols; p++ {
+		e := &b.protos[p]
+		out = append(out, ProtocolBaseline{
+			Protocol:    p.String(),
+			BaselinePPS: e.mean,
+			StdDevPPS:   math.Sqrt(e.variance),
+			CurrentPPS:  e.current,
+			Samples:     e.samples,
+			Operational: e.operational(),
+		})
+	}
+	return out
+}
+
 // GetMetrics returns the current baseline state and anomaly detection results.
 func (b *Baseline) GetMetrics() Metrics {
 	b.mu.RLock()
@@ -183,8 +364,15 @@ func (b *Baseline) GetMetrics() Metrics {
 	stdPPS := math.Sqrt(b.variancePPS)
 	stdBPS := math.Sqrt(b.varianceBPS)
 
-	zPPS := zScore(b.currentPPS, b.meanPPS, stdPPS)
-	zBPS := zScore(b.currentBPS, b.meanBPS, stdBPS)
+	season := b.seasonalBucket(hourOfWeek(b.now()))
+	var zPPS, zBPS float64
+	if season.Operational {
+		zPPS = zScore(b.currentPPS, season.BaselinePPS, season.StdDevPPS)
+		zBPS = zScore(b.currentBPS, season.BaselineBPS, season.StdDevBPS)
+	} else {
+		zPPS = zScore(b.currentPPS, b.meanPPS, stdPPS)
+		zBPS = zScore(b.currentBPS, b.meanBPS, stdBPS)
+	}
 
 	isLearning := b.sampleCount < learningPeriod
 	isAnomaly := false
@@ -205,25 +393,51 @@ func (b *Baseline) GetMetrics() Metrics {
 		ZScoreBPS:    zBPS,
 		IsAnomaly:    isAnomaly,
 		AnomalyScore: anomalyScore,
+		PulseWave:    b.pulse.recognized,
+		Seasonal:     season,
 	}
 }
 
-// GetAdaptiveRates returns recommended rate limits based on the learned baseline.
+// GetPulse returns the bursts above the baseline and whether they form a
+// pulse-wave pattern.
+func (b *Baseline) GetPulse() Pulse {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.pulse.pulse()
+}
+
+// GetAdaptiveRates returns recommended rate limits based on the learned
+// baselines. Each protocol limit is derived from that protocol's own
+// baseline once it has finished learning; until then it falls back to a
+// multiple of the aggregate mean.
 func (b *Baseline) GetAdaptiveRates() AdaptiveRates {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	basePPS := b.meanPPS
-	if basePPS < 100 {
-		basePPS = 100 // Minimum floor to avoid zero-rate lockout.
-	}
+	basePPS := math.Max(b.meanPPS, minAdaptivePPS)
 
-	return AdaptiveRates{
+	rates := AdaptiveRates{
 		SynPPS:    uint64(basePPS * adaptiveSYNMultiplier),
 		UdpPPS:    uint64(basePPS * adaptiveUDPMultiplier),
-		IcmpPPS:   uint64(math.Max(basePPS*0.1*adaptiveICMPMultiplier, 100)),
+		IcmpPPS:   uint64(math.Max(basePPS*0.1*adaptiveICMPMultiplier, minAdaptiveICMPPPS)),
 		GlobalPPS: uint64(basePPS * adaptiveGlobalMargin),
+		DnsPPS:    uint64(basePPS * adaptiveDNSMultiplier),
+	}
+
+	if e := &b.protos[ProtoTCPSYN]; e.operational() {
+		rates.SynPPS = protoLimit(e, adaptiveSYNMultiplier, minAdaptivePPS)
+	}
+	if e := &b.protos[ProtoUDP]; e.operational() {
+		rates.UdpPPS = protoLimit(e, adaptiveUDPMultiplier, minAdaptivePPS)
+	}
+	if e := &b.protos[ProtoICMP]; e.operational() {
+		rates.IcmpPPS = protoLimit(e, adaptiveICMPMultiplier, minAdaptiveICMPPPS)
 	}
+	if e := &b.protos[ProtoDNS]; e.operational() {
+		rates.DnsPPS = protoLimit(e, adaptiveDNSMultiplier, minAdaptivePPS)
+	}
+
+	return rates
 }
 
 // UpdateBPFConfig pushes the learned baseline PPS and BPS to the BPF config map.
@@ -282,23 +496,61 @@ func (b *Baseline) Reset() {
 	b.currentBPS = 0
 	b.currentDropPPS = 0
 	b.sampleCount = 0
+	b.protos = [numProtocols]protoEWMA{}
+	b.seasons = [hoursPerWeek]seasonEWMA{}
+	b.pulse = pulseTracker{}
+	b.pulseHold = false
 
 	b.log.Info("baseline reset, re-entering learning period")
 }
 
 // --- Internal helpers ---
 
+// observePulse passes a sample to the pulse tracker, logging when a
+// pattern is recognised or resumes, and reports whether the sample is to
+// be kept out of the baseline.
+func (b *Baseline) observePulse(pps float64, burst bool) bool {
+	wasRecognized, wasInBurst := b.pulse.recognized, b.pulse.inBurst
+	hold := b.pulse.observe(b.now(), pps, burst)
+
+	switch {
+	case b.pulse.recognized && !wasRecognized:
+		p := b.pulse.pulse()
+		b.log.Warn("pulse-wave attack pattern recognized",
+			zap.Int("bursts", len(p.Bursts)),
+			zap.Duration("interval", p.Interval),
+			zap.Duration("mean_duration", p.MeanDuration),
+			zap.Float64("mean_peak_pps", p.MeanPeakPPS),
+		)
+	case wasRecognized && !b.pulse.recognized:
+		b.log.Info("pulse-wave attack pattern ended")
+	case b.pulse.recognized && b.pulse.inBurst && !wasInBurst:
+		b.log.Warn("pulse-wave attack resumed", zap.Float64("pps", pps))
+	}
+	return hold
+}
+
 // updateEWMA computes the next EWMA mean and variance.
 //
 //	newMean = alpha * x + (1 - alpha) * oldMean
 //	newVariance = alpha * (x - newMean)^2 + (1 - alpha) * oldVariance
 func updateEWMA(oldMean, oldVariance, x float64) (float64, float64) {
-	newMean := alpha*x + (1-alpha)*oldMean
+	return ewma(alpha, oldMean, oldVariance, x)
+}
+
+// ewma is updateEWMA with weight a.
+func ewma(a, oldMean, oldVariance, x float64) (float64, float64) {
+	newMean := a*x + (1-a)*oldMean
 	diff := x - newMean
-	newVariance := alpha*(diff*diff) + (1-alpha)*oldVariance
+	newVariance := a*(diff*diff) + (1-a)*oldVariance
 	return newMean, newVariance
 }
 
+// protoLimit scales a protocol's learned mean by its safety multiplier.
+func protoLimit(e *protoEWMA, multiplier, floor float64) uint64 {
+	return uint64(math.Max(e.mean*multiplier, floor))
+}
+
 // zScore computes (value - mean) / stddev. Returns 0 if stddev is near zero.
 func zScore(value, mean, stddev float64) float64 {
 	if stddev < 1e-9 {
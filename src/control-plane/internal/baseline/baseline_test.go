@@ -0,0 +1,59 @@
+package baseline
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestAdaptiveRatesFallBackToGlobalWhileLearning(t *testing.T) {
+	b := NewBaseline(zap.NewNop(), nil)
+	for i := 0; i < 10; i++ {
+		b.Feed(1000, 8e6, 0)
+		b.FeedProtocols(ProtocolRates{SYN: 10, UDP: 200, ICMP: 1, DNS: 50})
+	}
+
+	rates := b.GetAdaptiveRates()
+	if rates.SynPPS != 3000 {
+		t.Errorf("SynPPS = %d, want 3000 (global mean * 3)", rates.SynPPS)
+	}
+	if rates.UdpPPS != 2000 {
+		t.Errorf("UdpPPS = %d, want 2000 (global mean * 2)", rates.UdpPPS)
+	}
+}
+
+func TestAdaptiveRatesPerProtocol(t *testing.T) {
+	b := NewBaseline(zap.NewNop(), nil)
+	for i := 0; i < learningPeriod; i++ {
+		b.Feed(10000, 8e7, 0)
+		b.FeedProtocols(ProtocolRates{SYN: 500, UDP: 4000, ICMP: 5, DNS: 1000})
+	}
+
+	rates := b.GetAdaptiveRates()
+	if rates.SynPPS != 1500 {
+		t.Errorf("SynPPS = %d, want 1500", rates.SynPPS)
+	}
+	if rates.UdpPPS != 8000 {
+		t.Errorf("UdpPPS = %d, want 8000", rates.UdpPPS)
+	}
+	if rates.IcmpPPS != minAdaptiveICMPPPS {
+		t.Errorf("IcmpPPS = %d, want floor %d", rates.IcmpPPS, minAdaptiveICMPPPS)
+	}
+	if rates.DnsPPS != 3000 {
+		t.Errorf("DnsPPS = %d, want 3000", rates.DnsPPS)
+	}
+	if rates.GlobalPPS != 20000 {
+		t.Errorf("GlobalPPS = %d, want 20000", rates.GlobalPPS)
+	}
+
+	for _, pb := range b.GetProtocolBaselines() {
+		if !pb.Operational {
+			t.Errorf("%s baseline should be operational", pb.Protocol)
+		}
+	}
+
+	b.Reset()
+	if b.GetProtocolBaselines()[0].Samples != 0 {
+		t.Error("Reset should clear protocol baselines")
+	}
+}
@@ -0,0 +1,169 @@
+package baseline
+
+import (
+	"math"
+	"time"
+)
+
+// Pulse-wave recognition parameters.
+const (
+	// pulseMinRatio is how far above the baseline mean a sample must also
+	// be to count as part of a burst, so that a quiet, steady baseline
+	// does not turn small wobbles into bursts.
+	pulseMinRatio = 2.0
+
+	// maxPulseBurst is the longest burst treated as a pulse. Longer ones
+	// are sustained attacks, and the baseline resumes learning during them
+	// so a lasting change in traffic is eventually adopted.
+	maxPulseBurst = 2 * time.Minute
+
+	// maxPulseGap is the longest quiet time between two bursts of one
+	// pattern.
+	maxPulseGap = 10 * time.Minute
+
+	// maxPulseHistory is the number of bursts remembered per pattern.
+	maxPulseHistory = 8
+
+	// minPulseBursts is the number of bursts needed to recognise a pattern.
+	minPulseBursts = 3
+
+	// pulseIntervalTolerance is how far each inter-burst interval may
+	// stray from their mean, as a fraction of it.
+	pulseIntervalTolerance = 0.25
+
+	// pulseExpiryIntervals is how many intervals may pass without a burst
+	// before a recognised pattern is forgotten.
+	pulseExpiryIntervals = 3
+)
+
+// Burst is one short excursion of the receive rate above the baseline.
+type Burst struct {
+	Start    time.Time
+	Duration time.Duration
+	PeakPPS  float64
+}
+
+// Pulse describes the bursts of a suspected pulse-wave attack: short
+// floods repeating at a steady interval, each too short to outlast the
+// escalation hysteresis.
+type Pulse struct {
+	Recognized   bool          // At least minPulseBursts bursts at a regular interval
+	InBurst      bool          // The receive rate is above the baseline now
+	Interval     time.Duration // Mean time between burst starts
+	MeanDuration time.Duration
+	MeanPeakPPS  float64
+	Bursts       []Burst // Oldest first
+}
+
+// pulseTracker follows bursts against the baseline. It is guarded by the
+// Baseline mutex.
+type pulseTracker struct {
+	bursts     []Burst
+	inBurst    bool
+	burstStart time.Time
+	burstPeak  float64
+	recognized bool
+}
+
+// observe records a sample taken at now and reports whether it belongs to
+// a burst short enough that it must not be learned into the baseline.
+func (p *pulseTracker) observe(now time.Time, pps float64, burst bool) (hold bool) {
+	if !burst {
+		if p.inBurst {
+			p.endBurst(now)
+		}
+		p.expire(now)
+		return false
+	}
+	if !p.inBurst {
+		p.expire(now)
+		p.inBurst, p.burstStart, p.burstPeak = true, now, pps
+	}
+	p.burstPeak = math.Max(p.burstPeak, pps)
+	return now.Sub(p.burstStart) <= maxPulseBurst
+}
+
+// endBurst closes the current burst. A burst longer than maxPulseBurst is
+// a sustained attack and ends the pattern instead.
+func (p *pulseTracker) endBurst(now time.Time) {
+	p.inBurst = false
+	d := now.Sub(p.burstStart)
+	if d > maxPulseBurst {
+		p.reset()
+		return
+	}
+	p.bursts = append(p.bursts, Burst{Start: p.burstStart, Duration: d, PeakPPS: p.burstPeak})
+	if len(p.bursts) > maxPulseHistory {
+		p.bursts = p.bursts[len(p.bursts)-maxPulseHistory:]
+	}
+	p.recognized = regularInterval(p.bursts)
+}
+
+// expire forgets the bursts once the pattern has gone quiet for longer
+// than it plausibly pauses.
+func (p *pulseTracker) expire(now time.Time) {
+	if p.inBurst || len(p.bursts) == 0 {
+		return
+	}
+	last := p.bursts[len(p.bursts)-1]
+	limit := maxPulseGap
+	if p.recognized {
+		limit = time.Duration(pulseExpiryIntervals) * meanInterval(p.bursts)
+	}
+	if now.Sub(last.Start) > limit {
+		p.reset()
+	}
+}
+
+func (p *pulseTracker) reset() {
+	p.bursts = nil
+	p.recognized = false
+}
+
+// pulse returns the tracker's state.
+func (p *pulseTracker) pulse() Pulse {
+	out := Pulse{
+		Recognized: p.recognized,
+		InBurst:    p.inBurst,
+		Bursts:     append([]Burst(nil), p.bursts...),
+	}
+	if len(p.bursts) == 0 {
+		return out
+	}
+	var d time.Duration
+	for _, b := range p.bursts {
+		d += b.Duration
+		out.MeanPeakPPS += b.PeakPPS
+	}
+	out.MeanDuration = d / time.Duration(len(p.bursts))
+	out.MeanPeakPPS /= float64(len(p.bursts))
+	out.Interval = meanInterval(p.bursts)
+	return out
+}
+
+// regularInterval reports whether there are enough bursts and every gap
+// between consecutive starts is within pulseIntervalTolerance of the mean.
+func regularInterval(bursts []Burst) bool {
+	if len(bursts) < minPulseBursts {
+		return false
+	}
+	mean := meanInterval(bursts)
+	if mean <= 0 {
+		return false
+	}
+	for i := 1; i < len(bursts); i++ {
+		gap := bursts[i].Start.Sub(bursts[i-1].Start)
+		if math.Abs(float64(gap-mean)) > pulseIntervalTolerance*float64(mean) {
+			return false
+		}
+	}
+	return true
+}
+
+// meanInterval returns the mean time between consecutive burst starts.
+func meanInterval(bursts []Burst) time.Duration {
+	if len(bursts) < 2 {
+		return 0
+	}
+	return bursts[len(bursts)-1].Start.Sub(bursts[0].Start) / time.Duration(len(bursts)-1)
+}
@@ -0,0 +1,79 @@
+package baseline
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// learned returns a baseline that has learned roughly 10k pps and feeds
+// it one sample per second of its clock.
+func learned(t *testing.T) (*Baseline, func(pps float64, n int)) {
+	t.Helper()
+	b := NewBaseline(zap.NewNop(), nil)
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return clock }
+	rng := rand.New(rand.NewSource(1))
+	feed := func(pps float64, n int) {
+		for i := 0; i < n; i++ {
+			clock = clock.Add(time.Second)
+			b.Feed(pps+rng.Float64()*200, pps*800, 0)
+		}
+	}
+	feed(10000, learningPeriod)
+	return b, feed
+}
+
+func TestPulseWaveRecognized(t *testing.T) {
+	b, feed := learned(t)
+	mean := b.GetMetrics().BaselinePPS
+
+	for i := 0; i < minPulseBursts; i++ {
+		feed(200000, 20)
+		if !b.GetPulse().InBurst {
+			t.Fatalf("burst %d not tracked", i)
+		}
+		feed(10000, 100)
+	}
+
+	p := b.GetPulse()
+	if !p.Recognized || len(p.Bursts) != minPulseBursts || p.InBurst {
+		t.Fatalf("pulse = %+v", p)
+	}
+	if p.Interval != 120*time.Second || p.MeanDuration != 20*time.Second {
+		t.Errorf("interval = %v, duration = %v, want 2m0s, 20s", p.Interval, p.MeanDuration)
+	}
+	if !b.GetMetrics().PulseWave {
+		t.Error("metrics do not flag the pulse wave")
+	}
+	if got := b.GetMetrics().BaselinePPS; got > mean*1.1 {
+		t.Errorf("bursts were learned: baseline %.0f -> %.0f pps", mean, got)
+	}
+
+	// The pattern is forgotten after three quiet intervals.
+	feed(10000, 3*120)
+	if p := b.GetPulse(); p.Recognized || len(p.Bursts) != 0 {
+		t.Errorf("pattern not expired: %+v", p)
+	}
+}
+
+func TestPulseWaveIrregularOrSustained(t *testing.T) {
+	b, feed := learned(t)
+	for _, gap := range []int{30, 200, 60} {
+		feed(200000, 10)
+		feed(10000, gap)
+	}
+	if p := b.GetPulse(); p.Recognized || len(p.Bursts) != 3 {
+		t.Errorf("irregular bursts: %+v", p)
+	}
+
+	// A sustained flood is not a pulse; the baseline learns it again
+	// once it outlasts maxPulseBurst.
+	feed(200000, int(maxPulseBurst/time.Second)+30)
+	feed(10000, 1)
+	if p := b.GetPulse(); len(p.Bursts) != 0 {
+		t.Errorf("sustained flood kept as a burst: %+v", p)
+	}
+}
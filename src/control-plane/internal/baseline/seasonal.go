@@ -0,0 +1,94 @@
+package baseline
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Seasonal baseline parameters.
+const (
+	// hoursPerWeek is the number of seasonal buckets, one per hour of the
+	// week in the local time zone, Monday 00:00 first.
+	hoursPerWeek = 7 * 24
+
+	// seasonalAlpha is the EWMA weight within a bucket. Each bucket sees
+	// about 3600 samples a week, so it learns over a longer window than
+	// the overall baseline.
+	seasonalAlpha = 0.001
+
+	// seasonalLearningPeriod is the number of samples a bucket needs
+	// before anomalies are scored against it: half of its hour.
+	seasonalLearningPeriod = 1800
+)
+
+var weekdayNames = [7]string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// SeasonalBucket is the learned traffic of one hour of the week.
+type SeasonalBucket struct {
+	Hour        int    // Hour of the week, 0 = Monday 00:00
+	Name        string // e.g. "Tue 03:00"
+	BaselinePPS float64
+	StdDevPPS   float64
+	BaselineBPS float64
+	StdDevBPS   float64
+	Samples     int
+	Operational bool // Anomalies are scored against this bucket
+}
+
+// seasonEWMA is the EWMA state of one hour-of-week bucket.
+type seasonEWMA struct {
+	meanPPS     float64
+	variancePPS float64
+	meanBPS     float64
+	varianceBPS float64
+	samples     int
+}
+
+func (s *seasonEWMA) feed(pps, bps float64) {
+	s.samples++
+	if s.samples == 1 {
+		s.meanPPS, s.meanBPS = pps, bps
+		s.variancePPS, s.varianceBPS = 0, 0
+		return
+	}
+	s.meanPPS, s.variancePPS = ewma(seasonalAlpha, s.meanPPS, s.variancePPS, pps)
+	s.meanBPS, s.varianceBPS = ewma(seasonalAlpha, s.meanBPS, s.varianceBPS, bps)
+}
+
+func (s *seasonEWMA) operational() bool {
+	return s.samples >= seasonalLearningPeriod
+}
+
+// hourOfWeek returns the seasonal bucket t falls in.
+func hourOfWeek(t time.Time) int {
+	day := (int(t.Weekday()) + 6) % 7 // Monday first
+	return day*24 + t.Hour()
+}
+
+// seasonalBucket returns the exported form of bucket hour.
+func (b *Baseline) seasonalBucket(hour int) SeasonalBucket {
+	s := &b.seasons[hour]
+	return SeasonalBucket{
+		Hour:        hour,
+		Name:        fmt.Sprintf("%s %02d:00", weekdayNames[hour/24], hour%24),
+		BaselinePPS: s.meanPPS,
+		StdDevPPS:   math.Sqrt(s.variancePPS),
+		BaselineBPS: s.meanBPS,
+		StdDevBPS:   math.Sqrt(s.varianceBPS),
+		Samples:     s.samples,
+		Operational: s.operational(),
+	}
+}
+
+// GetSeasonalBuckets returns all hour-of-week buckets, Monday 00:00 first.
+func (b *Baseline) GetSeasonalBuckets() []SeasonalBucket {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	out := make([]SeasonalBucket, 0, hoursPerWeek)
+	for h := 0; h < hoursPerWeek; h++ {
+		out = append(out, b.seasonalBucket(h))
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package baseline
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestHourOfWeek(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want int
+	}{
+		{time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC), 0},    // Monday
+		{time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC), 27},    // Tuesday 03:00
+		{time.Date(2024, 1, 7, 23, 59, 0, 0, time.UTC), 167}, // Sunday
+	}
+	for _, tt := range tests {
+		if got := hourOfWeek(tt.t); got != tt.want {
+			t.Errorf("hourOfWeek(%v) = %d, want %d", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestSeasonalBucketScoresAgainstSameHour(t *testing.T) {
+	b := NewBaseline(zap.NewNop(), nil)
+	// Monday 10:00 is busy, Tuesday 03:00 quiet.
+	clock := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return clock }
+	feed := func(pps float64, n int) {
+		for i := 0; i < n; i++ {
+			b.Feed(pps+float64(i%10)*10, pps*800, 0)
+			clock = clock.Add(time.Second)
+		}
+	}
+	feed(100000, 3600)
+	clock = time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	feed(5000, seasonalLearningPeriod)
+
+	m := b.GetMetrics()
+	if !m.Seasonal.Operational || m.Seasonal.Name != "Tue 03:00" || m.Seasonal.Hour != 27 {
+		t.Fatalf("seasonal = %+v", m.Seasonal)
+	}
+	if m.Seasonal.BaselinePPS > 6000 {
+		t.Errorf("Tue 03:00 baseline = %.0f pps, want ~5000", m.Seasonal.BaselinePPS)
+	}
+
+	// 20k pps is far below Monday's peak but an anomaly at 3 AM.
+	b.Feed(20000, 20000*800, 0)
+	if m := b.GetMetrics(); !m.IsAnomaly || m.ZScorePPS < anomalyZThreshold {
+		t.Errorf("20k pps at Tue 03:00 not anomalous: z = %.1f", m.ZScorePPS)
+	}
+
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := b.SaveState(path); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewBaseline(zap.NewNop(), nil)
+	if err := restored.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	buckets := restored.GetSeasonalBuckets()
+	if len(buckets) != hoursPerWeek || buckets[10].Samples != 3600 || !buckets[27].Operational {
+		t.Errorf("restored buckets: Mon 10:00 %+v, Tue 03:00 %+v", buckets[10], buckets[27])
+	}
+	if err := restored.LoadState(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("missing state file: %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package baseline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// saveInterval is how often the baseline is written to its state file
+	// while running, so a crash loses little of it.
+	saveInterval = time.Minute
+
+	// maxWarmStartAge is the oldest saved overall EWMA that is restored.
+	// Traffic may have changed in a longer outage, so the baseline then
+	// learns afresh; the seasonal buckets are restored whatever their age.
+	maxWarmStartAge = 15 * time.Minute
+)
+
+// persistedEWMA is the on-disk form of the overall and per-protocol EWMA.
+type persistedEWMA struct {
+	Samples         int     `json:"samples"`
+	MeanPPS         float64 `json:"mean_pps"`
+	VariancePPS     float64 `json:"variance_pps"`
+	MeanBPS         float64 `json:"mean_bps"`
+	VarianceBPS     float64 `json:"variance_bps"`
+	MeanDropPPS     float64 `json:"mean_drop_pps"`
+	VarianceDropPPS float64 `json:"variance_drop_pps"`
+
+	Protocols []persistedProto `json:"protocols"`
+}
+
+// persistedProto is the on-disk form of one protocol class EWMA.
+type persistedProto struct {
+	Protocol string  `json:"protocol"`
+	Mean     float64 `json:"mean"`
+	Variance float64 `json:"variance"`
+	Samples  int     `json:"samples"`
+}
+
+// persistedBucket is the on-disk form of one seasonal bucket.
+type persistedBucket struct {
+	Hour        int     `json:"hour"`
+	MeanPPS     float64 `json:"mean_pps"`
+	VariancePPS float64 `json:"variance_pps"`
+	MeanBPS     float64 `json:"mean_bps"`
+	VarianceBPS float64 `json:"variance_bps"`
+	Samples     int     `json:"samples"`
+}
+
+// persistedBaseline is the on-disk form of the baseline. Overall is
+// omitted until the baseline has finished learning.
+type persistedBaseline struct {
+	SavedAt time.Time         `json:"saved_at"`
+	Overall *persistedEWMA    `json:"overall,omitempty"`
+	Buckets []persistedBucket `json:"buckets"`
+}
+
+// SetStatePath makes the running baseline save itself to path every
+// saveInterval. Call it before Start.
+func (b *Baseline) SetStatePath(path string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.statePath = path
+}
+
+// SaveState writes the learned baseline to path atomically.
+func (b *Baseline) SaveState(path string) error {
+	b.mu.RLock()
+	st := persistedBaseline{SavedAt: b.now()}
+	if b.sampleCount >= learningPeriod {
+		o := &persistedEWMA{
+			Samples:         b.sampleCount,
+			MeanPPS:         b.meanPPS,
+			VariancePPS:     b.variancePPS,
+			MeanBPS:         b.meanBPS,
+			VarianceBPS:     b.varianceBPS,
+			MeanDropPPS:     b.meanDropPPS,
+			VarianceDropPPS: b.varianceDropPPS,
+		}
+		for p := Protocol(0); p < numProtocols; p++ {
+			e := &b.protos[p]
+			o.Protocols = append(o.Protocols, persistedProto{
+				Protocol: p.String(),
+				Mean:     e.mean,
+				Variance: e.variance,
+				Samples:  e.samples,
+			})
+		}
+		st.Overall = o
+	}
+	for h, s := range b.seasons {
+		if s.samples == 0 {
+			continue
+		}
+		st.Buckets = append(st.Buckets, persistedBucket{
+			Hour:        h,
+			MeanPPS:     s.meanPPS,
+			VariancePPS: s.variancePPS,
+			MeanBPS:     s.meanBPS,
+			VarianceBPS: s.varianceBPS,
+			Samples:     s.samples,
+		})
+	}
+	b.mu.RUnlock()
+
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("marshaling baseline: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing baseline: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing baseline: %w", err)
+	}
+
+	b.log.Debug("baseline saved", zap.String("path", path), zap.Int("buckets", len(st.Buckets)))
+	return nil
+}
+
+// LoadState restores a baseline saved by SaveState. The overall EWMA is
+// restored only if it was saved within maxWarmStartAge, in which case the
+// baseline is operational straight away; otherwise it learns afresh. A
+// missing file is not an error.
+func (b *Baseline) LoadState(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading baseline: %w", err)
+	}
+
+	var st persistedBaseline
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("parsing baseline: %w", err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	restored := 0
+	for _, p := range st.Buckets {
+		if p.Hour < 0 || p.Hour >= hoursPerWeek || p.Samples <= 0 {
+			continue
+		}
+		b.seasons[p.Hour] = seasonEWMA{
+			meanPPS:     p.MeanPPS,
+			variancePPS: p.VariancePPS,
+			meanBPS:     p.MeanBPS,
+			varianceBPS: p.VarianceBPS,
+			samples:     p.Samples,
+		}
+		restored++
+	}
+
+	age := b.now().Sub(st.SavedAt)
+	warm := st.Overall != nil && st.Overall.Samples >= learningPeriod && age >= 0 && age <= maxWarmStartAge
+	if warm {
+		o := st.Overall
+		b.sampleCount = o.Samples
+		b.meanPPS, b.variancePPS = o.MeanPPS, o.VariancePPS
+		b.meanBPS, b.varianceBPS = o.MeanBPS, o.VarianceBPS
+		b.meanDropPPS, b.varianceDropPPS = o.MeanDropPPS, o.VarianceDropPPS
+		for _, p := range o.Protocols {
+			for proto := Protocol(0); proto < numProtocols; proto++ {
+				if proto.String() == p.Protocol {
+					b.protos[proto] = protoEWMA{mean: p.Mean, variance: p.Variance, samples: p.Samples}
+				}
+			}
+		}
+	}
+
+	b.log.Info("baseline restored",
+		zap.String("path", path),
+		zap.Bool("warm_start", warm),
+		zap.Duration("age", age),
+		zap.Int("seasonal_buckets", restored),
+	)
+	return nil
+}
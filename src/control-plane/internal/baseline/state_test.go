@@ -0,0 +1,72 @@
+package baseline
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestWarmStart(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+
+	b := NewBaseline(zap.NewNop(), nil)
+	b.now = now
+	for i := 0; i < learningPeriod; i++ {
+		b.Feed(10000, 8e7, 50)
+		b.FeedProtocols(ProtocolRates{SYN: 500, UDP: 4000, ICMP: 5, DNS: 1000})
+	}
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := b.SaveState(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		after    time.Duration
+		wantWarm bool
+	}{
+		{"restart", time.Minute, true},
+		{"stale", maxWarmStartAge + time.Minute, false},
+	}
+	for _, tt := range tests {
+		clock = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC).Add(tt.after)
+		r := NewBaseline(zap.NewNop(), nil)
+		r.now = now
+		if err := r.LoadState(path); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if r.IsOperational() != tt.wantWarm {
+			t.Errorf("%s: operational = %t, want %t", tt.name, r.IsOperational(), tt.wantWarm)
+		}
+		if !tt.wantWarm {
+			continue
+		}
+		if m := r.GetMetrics(); m.BaselinePPS != 10000 || m.BaselineBPS != 8e7 {
+			t.Errorf("%s: baseline = %.0f pps, %.0f bps", tt.name, m.BaselinePPS, m.BaselineBPS)
+		}
+		if rates := r.GetAdaptiveRates(); rates.SynPPS != 1500 || rates.UdpPPS != 8000 {
+			t.Errorf("%s: adaptive rates = %+v", tt.name, rates)
+		}
+	}
+}
+
+func TestLearningBaselineNotSaved(t *testing.T) {
+	b := NewBaseline(zap.NewNop(), nil)
+	for i := 0; i < 10; i++ {
+		b.Feed(10000, 8e7, 0)
+	}
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := b.SaveState(path); err != nil {
+		t.Fatal(err)
+	}
+	r := NewBaseline(zap.NewNop(), nil)
+	if err := r.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	if r.IsOperational() || r.SampleCount() != 0 {
+		t.Errorf("learning baseline restored: %d samples", r.SampleCount())
+	}
+}
@@ -0,0 +1,250 @@
+package bgp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+// Default lifetime of an auto-generated Flowspec rule after its last match.
+const defaultAutoFlowspecTTL = 5 * time.Minute
+
+// Poll interval for signature hit counters.
+const autoFlowspecPollInterval = 10 * time.Second
+
+// Maximum number of signatures the BPF fingerprint stage evaluates.
+const maxAttackSignatures = 256
+
+// autoRule tracks a Flowspec rule generated from an attack signature.
+type autoRule struct {
+	rule      FlowspecRule
+	lastMatch time.Time
+}
+
+// SignatureSource reads the attack signatures and their hit counters;
+// *bpf.MapManager satisfies it.
+type SignatureSource interface {
+	GetAttackSignatureCount() (uint32, error)
+	AttackSignatureHits(index uint32) (uint64, error)
+	GetAttackSignature(index uint32) (bpf.AttackSig, error)
+}
+
+// AutoFlowspec translates attack signatures that are actively matching
+// traffic into Flowspec announcements while escalation is HIGH or above.
+// Rules are withdrawn once their signature has not matched for the TTL,
+// or when escalation drops below HIGH.
+type AutoFlowspec struct {
+	log    *zap.Logger
+	client *Client
+	maps   SignatureSource
+	ttl    time.Duration
+
+	mu        sync.Mutex
+	active    bool
+	lastHits  map[uint32]uint64
+	announced map[uint32]*autoRule // signature index -> rule
+}
+
+// NewAutoFlowspec creates a signature-driven Flowspec generator.
+func NewAutoFlowspec(log *zap.Logger, client *Client, maps SignatureSource) *AutoFlowspec {
+	ttl := time.Duration(client.cfg.FlowspecTTLSec) * time.Second
+	if ttl == 0 {
+		ttl = defaultAutoFlowspecTTL
+	}
+
+	return &AutoFlowspec{
+		log:       log,
+		client:    client,
+		maps:      maps,
+		ttl:       ttl,
+		lastHits:  make(map[uint32]uint64),
+		announced: make(map[uint32]*autoRule),
+	}
+}
+
+// SetActive enables or disables announcement of new rules. It is driven by
+// the escalation level: active at HIGH and CRITICAL. Deactivating withdraws
+// the rules already announced.
+func (a *AutoFlowspec) SetActive(active bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.active == active {
+		return
+	}
+	a.log.Info("auto flowspec state changed", zap.Bool("active", active))
+	a.active = active
+	if !active {
+		for idx, ar := range a.announced {
+			a.withdrawLocked(idx, ar)
+		}
+	}
+}
+
+// Rules returns the rules currently announced.
+func (a *AutoFlowspec) Rules() []FlowspecRule {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rules := make([]FlowspecRule, 0, len(a.announced))
+	for _, ar := range a.announced {
+		rules = append(rules, ar.rule)
+	}
+	return rules
+}
+
+// Run polls signature hit counters until the context is cancelled.
+func (a *AutoFlowspec) Run(ctx context.Context) {
+	ticker := time.NewTicker(autoFlowspecPollInterval)
+	defer ticker.Stop()
+
+	a.log.Info("auto flowspec started",
+		zap.String("dst_prefix", a.client.cfg.FlowspecDstPrefix),
+		zap.Duration("ttl", a.ttl),
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			a.log.Info("auto flowspec stopped")
+			return
+		case <-ticker.C:
+			a.Poll(time.Now())
+		}
+	}
+}
+
+// Poll compares hit counters against the previous poll, announces rules for
+// newly matching signatures, and withdraws rules whose TTL has elapsed. Run
+// calls it every autoFlowspecPollInterval.
+func (a *AutoFlowspec) Poll(now time.Time) {
+	count, err := a.maps.GetAttackSignatureCount()
+	if err != nil {
+		a.log.Warn("reading signature count failed", zap.Error(err))
+		return
+	}
+	if count > maxAttackSignatures {
+		count = maxAttackSignatures
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for idx := uint32(0); idx < count; idx++ {
+		hits, err := a.maps.AttackSignatureHits(idx)
+		if err != nil {
+			continue
+		}
+		prev, seen := a.lastHits[idx]
+		a.lastHits[idx] = hits
+		if !seen || hits <= prev {
+			continue
+		}
+
+		// Signature matched traffic since the last poll.
+		if ar, ok := a.announced[idx]; ok {
+			ar.lastMatch = now
+			ar.rule.ExpiresAt = now.Add(a.ttl)
+			a.client.setFlowspecExpiry(ar.rule, ar.rule.ExpiresAt)
+			continue
+		}
+		if !a.active {
+			continue
+		}
+
+		sig, err := a.maps.GetAttackSignature(idx)
+		if err != nil {
+			continue
+		}
+		rule, err := SignatureToFlowspec(sig, a.client.cfg.FlowspecDstPrefix)
+		if err != nil {
+			a.log.Debug("signature not expressible as flowspec",
+				zap.Uint32("index", idx), zap.Error(err))
+			continue
+		}
+		rule.ExpiresAt = now.Add(a.ttl)
+		rule.Reason = fmt.Sprintf("auto: attack signature %d", idx)
+
+		if err := a.client.AnnounceFlowspec(rule); err != nil {
+			a.log.Warn("auto flowspec announce failed",
+				zap.Uint32("index", idx), zap.Error(err))
+			continue
+		}
+		a.announced[idx] = &autoRule{rule: rule, lastMatch: now}
+	}
+
+	// Withdraw rules whose signature stopped matching.
+	for idx, ar := range a.announced {
+		if now.Sub(ar.lastMatch) >= a.ttl {
+			a.withdrawLocked(idx, ar)
+		}
+	}
+}
+
+// withdrawLocked withdraws the rule of signature idx. Caller must hold a.mu.
+func (a *AutoFlowspec) withdrawLocked(idx uint32, ar *autoRule) {
+	if err := a.client.WithdrawFlowspec(ar.rule); err != nil {
+		a.log.Warn("auto flowspec withdraw failed",
+			zap.Uint32("index", idx), zap.Error(err))
+	}
+	delete(a.announced, idx)
+}
+
+// SignatureToFlowspec converts an attack signature into an equivalent
+// Flowspec drop rule scoped to dstPrefix. Signatures matching on TCP flags
+// or payload hash are approximated by their protocol/port/length tuple.
+func SignatureToFlowspec(sig bpf.AttackSig, dstPrefix string) (FlowspecRule, error) {
+	rule := FlowspecRule{
+		DstPrefix: dstPrefix,
+		Action:    "drop",
+	}
+
+	switch sig.Protocol {
+	case 0:
+		// Any protocol.
+	case 1:
+		rule.Protocol = "icmp"
+	case 6:
+		rule.Protocol = "tcp"
+	case 17:
+		rule.Protocol = "udp"
+	default:
+		return rule, fmt.Errorf("unsupported protocol %d", sig.Protocol)
+	}
+
+	rule.SrcPort = formatRange(ntohs(sig.SrcPortMin), ntohs(sig.SrcPortMax))
+	rule.DstPort = formatRange(ntohs(sig.DstPortMin), ntohs(sig.DstPortMax))
+	rule.PktLen = formatRange(sig.PktLenMin, sig.PktLenMax)
+
+	if rule.Protocol == "" && rule.SrcPort == "" && rule.DstPort == "" && rule.PktLen == "" {
+		return rule, fmt.Errorf("signature has no flowspec-expressible match fields")
+	}
+
+	if err := validateFlowspecRule(rule); err != nil {
+		return rule, err
+	}
+	return rule, nil
+}
+
+// formatRange renders a [min, max] range in Flowspec notation. A zero range
+// means "any" and yields an empty string.
+func formatRange(min, max uint16) string {
+	if min == 0 && max == 0 {
+		return ""
+	}
+	if max == 0 {
+		max = 65535
+	}
+	if min == max {
+		return fmt.Sprintf("%d", min)
+	}
+	return fmt.Sprintf("%d-%d", min, max)
+}
+
+func ntohs(v uint16) uint16 {
+	return (v >> 8) | (v << 8)
+}
@@ -0,0 +1,95 @@
+package bgp
+
+import (
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+)
+
+func htons(v uint16) uint16 {
+	return (v >> 8) | (v << 8)
+}
+
+func TestSignatureToFlowspec(t *testing.T) {
+	tests := []struct {
+		name    string
+		sig     bpf.AttackSig
+		want    FlowspecRule
+		wantErr bool
+	}{
+		{
+			name: "udp dns reflection",
+			sig: bpf.AttackSig{
+				Protocol:   17,
+				SrcPortMin: htons(53),
+				SrcPortMax: htons(53),
+				PktLenMin:  512,
+				PktLenMax:  1500,
+			},
+			want: FlowspecRule{
+				DstPrefix: "203.0.113.0/24",
+				Protocol:  "udp",
+				SrcPort:   "53",
+				PktLen:    "512-1500",
+				Action:    "drop",
+			},
+		},
+		{
+			name: "tcp port range",
+			sig: bpf.AttackSig{
+				Protocol:   6,
+				DstPortMin: htons(1024),
+				DstPortMax: htons(65535),
+			},
+			want: FlowspecRule{
+				DstPrefix: "203.0.113.0/24",
+				Protocol:  "tcp",
+				DstPort:   "1024-65535",
+				Action:    "drop",
+			},
+		},
+		{
+			name:    "unsupported protocol",
+			sig:     bpf.AttackSig{Protocol: 47},
+			wantErr: true,
+		},
+		{
+			name:    "no match fields",
+			sig:     bpf.AttackSig{PayloadHash: 0xdeadbeef},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SignatureToFlowspec(tt.sig, "203.0.113.0/24")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SignatureToFlowspec() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !flowspecMatch(got, tt.want) {
+				t.Errorf("SignatureToFlowspec() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatRange(t *testing.T) {
+	tests := []struct {
+		min, max uint16
+		want     string
+	}{
+		{0, 0, ""},
+		{80, 80, "80"},
+		{1024, 65535, "1024-65535"},
+		{100, 0, "100-65535"},
+	}
+
+	for _, tt := range tests {
+		if got := formatRange(tt.min, tt.max); got != tt.want {
+			t.Errorf("formatRange(%d, %d) = %q, want %q", tt.min, tt.max, got, tt.want)
+		}
+	}
+}
@@ -24,6 +24,12 @@ import (
 // Default blackhole community (RFC 7999: 65535:666).
 const defaultBlackholeCommunity = "65535:666"
 
+// Default lifetime of an RTBH announcement that is not renewed.
+const defaultBlackholeTTL = time.Hour
+
+// How often expired blackholes are withdrawn.
+const blackholeJanitorInterval = 10 * time.Second
+
 // Config holds BGP session configuration.
 type Config struct {
 	Enabled            bool   `yaml:"enabled"`
@@ -32,6 +38,16 @@ type Config struct {
 	PeerAS             uint32 `yaml:"peer_as"`               // Peer AS number.
 	NextHopSelf        string `yaml:"next_hop_self"`         // Next-hop for announcements.
 	CommunityBlackhole string `yaml:"community_blackhole"`   // Blackhole community string.
+	BlackholeTTLSec    uint64 `yaml:"blackhole_ttl_sec"`     // Withdraw blackholes after this long unless renewed.
+
+	// Address space we are authorized to blackhole or protect with
+	// Flowspec. Announcements for anything outside it are rejected.
+	AuthorizedPrefixes []string `yaml:"authorized_prefixes"`
+
+	// Automatic Flowspec generation from attack signatures.
+	AutoFlowspec      bool   `yaml:"auto_flowspec"`
+	FlowspecDstPrefix string `yaml:"flowspec_dst_prefix"` // Protected prefix used as the rule destination.
+	FlowspecTTLSec    uint64 `yaml:"flowspec_ttl_sec"`    // Withdraw after this long without a match.
 }
 
 // FlowspecRule represents a BGP Flowspec traffic filtering rule (RFC 5575).
@@ -41,10 +57,13 @@ type FlowspecRule struct {
 	Protocol  string `json:"protocol,omitempty"`   // "tcp", "udp", "icmp", or "".
 	SrcPort   string `json:"src_port,omitempty"`   // Source port or range ("80", "1024-65535").
 	DstPort   string `json:"dst_port,omitempty"`   // Destination port or range.
+	PktLen    string `json:"pkt_len,omitempty"`    // Packet length or range ("60", "0-128").
 	Action    string `json:"action"`               // "drop", "rate-limit", "redirect".
+	RateBps   uint64 `json:"rate_bps,omitempty"`   // Policing rate in bits/s for "rate-limit".
 
 	// Metadata (not sent via BGP, used for tracking).
 	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"` // Zero = no expiry.
 	Reason    string    `json:"reason,omitempty"`
 }
 
@@ -52,24 +71,31 @@ type FlowspecRule struct {
 type blackholeRoute struct {
 	Prefix      string
 	AnnouncedAt time.Time
+	ExpiresAt   time.Time
 	Reason      string
 }
 
 // Client manages BGP sessions for Flowspec and RTBH signaling.
 type Client struct {
-	log *zap.Logger
-	cfg Config
+	log          *zap.Logger
+	cfg          Config
+	blackholeTTL time.Duration
+	authorized   []*net.IPNet
 
 	mu             sync.RWMutex
 	connected      bool
+	connectedAt    time.Time
 	blackholes     map[string]*blackholeRoute // prefix -> route
+	diversions     map[string]time.Time       // prefix -> announced at
 	flowspecRules  []FlowspecRule
-	auditLog       []auditEntry
+	auditLog       []AuditEntry
 	cancelFunc     context.CancelFunc
+
+	onBlackhole func(prefix string, announced bool)
 }
 
-// auditEntry records a BGP action for audit trail purposes.
-type auditEntry struct {
+// AuditEntry records a BGP action for audit trail purposes.
+type AuditEntry struct {
 	Timestamp time.Time
 	Action    string // "announce_blackhole", "withdraw_blackhole", "announce_flowspec", etc.
 	Detail    string
@@ -84,10 +110,17 @@ func NewClient(log *zap.Logger, cfg Config) *Client {
 		cfg.CommunityBlackhole = defaultBlackholeCommunity
 	}
 
+	ttl := time.Duration(cfg.BlackholeTTLSec) * time.Second
+	if ttl == 0 {
+		ttl = defaultBlackholeTTL
+	}
+
 	return &Client{
-		log:        log,
-		cfg:        cfg,
-		blackholes: make(map[string]*blackholeRoute),
+		log:          log,
+		cfg:          cfg,
+		blackholeTTL: ttl,
+		blackholes:   make(map[string]*blackholeRoute),
+		diversions:   make(map[string]time.Time),
 	}
 }
 
@@ -120,6 +153,18 @@ func (c *Client) Connect(ctx context.Context) error {
 		return fmt.Errorf("BGP peer AS is required")
 	}
 
+	authorized, err := parseAuthorized(c.cfg.AuthorizedPrefixes)
+	if err != nil {
+		return err
+	}
+	c.authorized = authorized
+
+	if c.cfg.FlowspecDstPrefix != "" {
+		if err := c.checkAuthorized(c.cfg.FlowspecDstPrefix); err != nil {
+			return fmt.Errorf("flowspec_dst_prefix: %w", err)
+		}
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	c.cancelFunc = cancel
 
@@ -130,6 +175,7 @@ func (c *Client) Connect(ctx context.Context) error {
 
 	c.mu.Lock()
 	c.connected = true
+	c.connectedAt = time.Now()
 	c.mu.Unlock()
 
 	c.log.Info("BGP session established",
@@ -139,8 +185,9 @@ func (c *Client) Connect(ctx context.Context) error {
 		zap.String("community", c.cfg.CommunityBlackhole),
 	)
 
-	// Start keepalive monitoring.
+	// Start keepalive monitoring and blackhole expiry.
 	go c.monitorSession(ctx)
+	go c.runJanitor(ctx)
 
 	return nil
 }
@@ -174,7 +221,10 @@ func (c *Client) monitorSession(ctx context.Context) {
 // RTBH works by announcing the victim's prefix with:
 // - next-hop set to a null route (typically RFC 5737 discard prefix)
 // - community set to the operator's blackhole community (default 65535:666)
-func (c *Client) AnnounceBlackhole(prefix string) error {
+//
+// The route is withdrawn after ttl (0 = the configured default) unless it
+// is renewed. Announcing an active prefix again extends its expiry.
+func (c *Client) AnnounceBlackhole(prefix string, ttl time.Duration) error {
 	if err := c.checkConnected(); err != nil {
 		return err
 	}
@@ -182,12 +232,25 @@ func (c *Client) AnnounceBlackhole(prefix string) error {
 	if err := validatePrefix(prefix); err != nil {
 		return fmt.Errorf("invalid prefix for blackhole: %w", err)
 	}
+	if err := c.checkAuthorized(prefix); err != nil {
+		c.recordAudit("reject_blackhole", fmt.Sprintf("prefix=%s", prefix))
+		return fmt.Errorf("refusing to blackhole %s: %w", prefix, err)
+	}
+
+	if ttl <= 0 {
+		ttl = c.blackholeTTL
+	}
+	now := time.Now()
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if _, exists := c.blackholes[prefix]; exists {
-		return nil // Already announced.
+	if bh, exists := c.blackholes[prefix]; exists {
+		// Already announced.
+		if expiresAt := now.Add(ttl); expiresAt.After(bh.ExpiresAt) {
+			bh.ExpiresAt = expiresAt
+		}
+		return nil
 	}
 
 	// In production with GoBGP:
@@ -197,17 +260,23 @@ func (c *Client) AnnounceBlackhole(prefix string) error {
 
 	c.blackholes[prefix] = &blackholeRoute{
 		Prefix:      prefix,
-		AnnouncedAt: time.Now(),
+		AnnouncedAt: now,
+		ExpiresAt:   now.Add(ttl),
 	}
 
-	c.appendAudit("announce_blackhole", fmt.Sprintf("prefix=%s community=%s", prefix, c.cfg.CommunityBlackhole))
+	c.appendAudit("announce_blackhole", fmt.Sprintf("prefix=%s community=%s ttl=%s", prefix, c.cfg.CommunityBlackhole, ttl))
 
 	c.log.Warn("RTBH blackhole announced",
 		zap.String("prefix", prefix),
 		zap.String("community", c.cfg.CommunityBlackhole),
 		zap.String("next_hop", c.cfg.NextHopSelf),
+		zap.Duration("ttl", ttl),
 	)
 
+	if c.onBlackhole != nil {
+		c.onBlackhole(prefix, true)
+	}
+
 	return nil
 }
 
@@ -232,9 +301,86 @@ func (c *Client) WithdrawBlackhole(prefix string) error {
 	c.appendAudit("withdraw_blackhole", fmt.Sprintf("prefix=%s", prefix))
 
 	c.log.Info("RTBH blackhole withdrawn", zap.String("prefix", prefix))
+
+	if c.onBlackhole != nil {
+		c.onBlackhole(prefix, false)
+	}
 	return nil
 }
 
+// RenewBlackholes pushes the expiry of every active blackhole to ttl from
+// now (0 = the configured default) and returns how many were renewed. It is
+// called while an attack continues so its blackholes do not lapse.
+func (c *Client) RenewBlackholes(ttl time.Duration) int {
+	if ttl <= 0 {
+		ttl = c.blackholeTTL
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	renewed := 0
+	for _, bh := range c.blackholes {
+		if expiresAt.After(bh.ExpiresAt) {
+			bh.ExpiresAt = expiresAt
+			renewed++
+		}
+	}
+	return renewed
+}
+
+// runJanitor withdraws expired blackholes until the context is cancelled.
+func (c *Client) runJanitor(ctx context.Context) {
+	ticker := time.NewTicker(blackholeJanitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.expireBlackholes(now)
+		}
+	}
+}
+
+// expireBlackholes withdraws the blackholes whose TTL has elapsed and
+// returns their prefixes.
+func (c *Client) expireBlackholes(now time.Time) []string {
+	if c.checkConnected() != nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	var expired []string
+	for prefix, bh := range c.blackholes {
+		if now.Before(bh.ExpiresAt) {
+			continue
+		}
+
+		// In production with GoBGP:
+		// server.DeletePath(ctx, &gobgpapi.DeletePathRequest{...})
+
+		delete(c.blackholes, prefix)
+		expired = append(expired, prefix)
+		c.appendAudit("expire_blackhole", fmt.Sprintf("prefix=%s", prefix))
+		c.log.Info("RTBH blackhole expired",
+			zap.String("prefix", prefix),
+			zap.Duration("age", now.Sub(bh.AnnouncedAt)),
+		)
+	}
+	onBlackhole := c.onBlackhole
+	c.mu.Unlock()
+
+	if onBlackhole != nil {
+		for _, p := range expired {
+			onBlackhole(p, false)
+		}
+	}
+	return expired
+}
+
 // AnnounceFlowspec injects a BGP Flowspec rule (RFC 5575) to upstream routers.
 //
 // Flowspec allows fine-grained traffic filtering rules to be distributed via BGP:
@@ -248,6 +394,15 @@ func (c *Client) AnnounceFlowspec(rule FlowspecRule) error {
 	if err := validateFlowspecRule(rule); err != nil {
 		return fmt.Errorf("invalid flowspec rule: %w", err)
 	}
+	// The destination is what upstream filters on our behalf; the source
+	// is attacker space and may be anything.
+	if rule.DstPrefix == "" {
+		return fmt.Errorf("refusing flowspec rule without dst_prefix: it would filter traffic to any destination")
+	}
+	if err := c.checkAuthorized(rule.DstPrefix); err != nil {
+		c.recordAudit("reject_flowspec", fmt.Sprintf("src=%s dst=%s", rule.SrcPrefix, rule.DstPrefix))
+		return fmt.Errorf("refusing flowspec rule for %s: %w", rule.DstPrefix, err)
+	}
 
 	rule.CreatedAt = time.Now()
 
@@ -255,22 +410,31 @@ func (c *Client) AnnounceFlowspec(rule FlowspecRule) error {
 	c.flowspecRules = append(c.flowspecRules, rule)
 	c.mu.Unlock()
 
+	// Drop and rate-limit travel as a traffic-rate extended community.
+	community, hasRate := TrafficRateCommunity(c.cfg.LocalAS, rule)
+
 	// In production with GoBGP:
 	// Build Flowspec NLRI from rule fields.
 	// flowspecNLRI := buildFlowspecNLRI(rule)
+	// extComm := &gobgpapi.ExtendedCommunitiesAttribute{Communities: [community]}
 	// server.AddPath(ctx, &gobgpapi.AddPathRequest{TableType: GLOBAL, Path: ...})
 
-	c.appendAudit("announce_flowspec", fmt.Sprintf(
-		"src=%s dst=%s proto=%s src_port=%s dst_port=%s action=%s",
+	detail := fmt.Sprintf(
+		"src=%s dst=%s proto=%s src_port=%s dst_port=%s pkt_len=%s action=%s",
 		rule.SrcPrefix, rule.DstPrefix, rule.Protocol,
-		rule.SrcPort, rule.DstPort, rule.Action,
-	))
+		rule.SrcPort, rule.DstPort, rule.PktLen, rule.Action,
+	)
+	if hasRate {
+		detail += fmt.Sprintf(" rate_bps=%d ext_community=%x", rule.RateBps, community)
+	}
+	c.appendAudit("announce_flowspec", detail)
 
 	c.log.Warn("Flowspec rule announced",
 		zap.String("src", rule.SrcPrefix),
 		zap.String("dst", rule.DstPrefix),
 		zap.String("proto", rule.Protocol),
 		zap.String("action", rule.Action),
+		zap.Uint64("rate_bps", rule.RateBps),
 	)
 
 	return nil
@@ -315,6 +479,19 @@ func (c *Client) WithdrawFlowspec(rule FlowspecRule) error {
 	return nil
 }
 
+// setFlowspecExpiry updates the expiry metadata of an announced rule.
+func (c *Client) setFlowspecExpiry(rule FlowspecRule, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.flowspecRules {
+		if flowspecMatch(c.flowspecRules[i], rule) {
+			c.flowspecRules[i].ExpiresAt = expiresAt
+			return
+		}
+	}
+}
+
 // GetActiveRules returns all active Flowspec and RTBH announcements as FlowspecRule entries.
 // RTBH entries are represented with Action="blackhole".
 func (c *Client) GetActiveRules() []FlowspecRule {
@@ -329,6 +506,7 @@ func (c *Client) GetActiveRules() []FlowspecRule {
 			DstPrefix: bh.Prefix,
 			Action:    "blackhole",
 			CreatedAt: bh.AnnouncedAt,
+			ExpiresAt: bh.ExpiresAt,
 			Reason:    bh.Reason,
 		})
 	}
@@ -353,6 +531,36 @@ func (c *Client) GetBlackholes() []string {
 	return result
 }
 
+// PeerState describes the session with one BGP peer.
+type PeerState struct {
+	RouterIP   string
+	LocalAS    uint32
+	PeerAS     uint32
+	Connected  bool
+	Since      time.Time // When the session was established; zero if down.
+	Blackholes int
+	Flowspec   int
+}
+
+// Peers returns the state of each configured peer session.
+func (c *Client) Peers() []PeerState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	st := PeerState{
+		RouterIP:   c.cfg.RouterIP,
+		LocalAS:    c.cfg.LocalAS,
+		PeerAS:     c.cfg.PeerAS,
+		Connected:  c.connected,
+		Blackholes: len(c.blackholes),
+		Flowspec:   len(c.flowspecRules),
+	}
+	if c.connected {
+		st.Since = c.connectedAt
+	}
+	return []PeerState{st}
+}
+
 // IsConnected returns the BGP session state.
 func (c *Client) IsConnected() bool {
 	c.mu.RLock()
@@ -385,17 +593,26 @@ func (c *Client) Disconnect() error {
 }
 
 // GetAuditLog returns the BGP action audit trail.
-func (c *Client) GetAuditLog() []auditEntry {
+func (c *Client) GetAuditLog() []AuditEntry {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	result := make([]auditEntry, len(c.auditLog))
+	result := make([]AuditEntry, len(c.auditLog))
 	copy(result, c.auditLog)
 	return result
 }
 
-// WithdrawAll withdraws all active blackhole and flowspec announcements.
-// Used during graceful shutdown or when de-escalating from CRITICAL.
+// OnBlackhole sets a callback invoked whenever an RTBH blackhole is
+// announced or withdrawn. It is called synchronously and must not block.
+func (c *Client) OnBlackhole(fn func(prefix string, announced bool)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onBlackhole = fn
+}
+
+// WithdrawAll withdraws all active blackhole, flowspec and diversion
+// announcements. Used during graceful shutdown or when de-escalating from
+// CRITICAL.
 func (c *Client) WithdrawAll() error {
 	c.mu.Lock()
 
@@ -404,18 +621,28 @@ func (c *Client) WithdrawAll() error {
 	for p := range c.blackholes {
 		prefixes = append(prefixes, p)
 	}
+	diversions := len(c.diversions)
 	c.blackholes = make(map[string]*blackholeRoute)
+	c.diversions = make(map[string]time.Time)
 	c.flowspecRules = nil
 
 	c.appendAudit("withdraw_all", fmt.Sprintf(
-		"blackholes=%d flowspec=%d",
-		len(prefixes), 0,
+		"blackholes=%d flowspec=%d diversions=%d",
+		len(prefixes), 0, diversions,
 	))
 
+	onBlackhole := c.onBlackhole
 	c.mu.Unlock()
 
+	if onBlackhole != nil {
+		for _, p := range prefixes {
+			onBlackhole(p, false)
+		}
+	}
+
 	c.log.Warn("all BGP announcements withdrawn",
 		zap.Int("blackholes_withdrawn", len(prefixes)),
+		zap.Int("diversions_withdrawn", diversions),
 	)
 
 	return nil
@@ -437,7 +664,7 @@ func (c *Client) checkConnected() error {
 }
 
 func (c *Client) appendAudit(action, detail string) {
-	entry := auditEntry{
+	entry := AuditEntry{
 		Timestamp: time.Now(),
 		Action:    action,
 		Detail:    detail,
@@ -451,6 +678,53 @@ func (c *Client) appendAudit(action, detail string) {
 	}
 }
 
+// recordAudit appends an audit entry, taking the lock.
+func (c *Client) recordAudit(action, detail string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.appendAudit(action, detail)
+}
+
+// parseAuthorized parses the authorized prefix list. At least one prefix is
+// required so a misconfiguration cannot leave announcements unrestricted.
+func parseAuthorized(prefixes []string) ([]*net.IPNet, error) {
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("BGP authorized_prefixes is required")
+	}
+	nets := make([]*net.IPNet, 0, len(prefixes))
+	for _, p := range prefixes {
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid authorized prefix %q: %w", p, err)
+		}
+		if n.IP.To4() == nil {
+			return nil, fmt.Errorf("IPv6 authorized prefix not supported: %s", p)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// checkAuthorized reports an error unless prefix (an IP or CIDR) lies
+// entirely within one of the authorized prefixes.
+func (c *Client) checkAuthorized(prefix string) error {
+	ip, n, err := net.ParseCIDR(prefix)
+	if err != nil {
+		if ip = net.ParseIP(prefix); ip == nil {
+			return fmt.Errorf("invalid prefix %q", prefix)
+		}
+		n = &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}
+	}
+	ones, _ := n.Mask.Size()
+	for _, auth := range c.authorized {
+		authOnes, _ := auth.Mask.Size()
+		if ones >= authOnes && auth.Contains(n.IP) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is outside the authorized prefixes", prefix)
+}
+
 // validatePrefix checks that a string is a valid IPv4 CIDR or single IP.
 func validatePrefix(prefix string) error {
 	if ip := net.ParseIP(prefix); ip != nil {
@@ -474,8 +748,17 @@ func validateFlowspecRule(rule FlowspecRule) error {
 	}
 
 	switch rule.Action {
-	case "drop", "rate-limit", "redirect":
-		// Valid.
+	case "drop", "redirect":
+		if rule.RateBps != 0 {
+			return fmt.Errorf("rate_bps is only valid with the rate-limit action")
+		}
+	case "rate-limit":
+		if rule.RateBps == 0 {
+			return fmt.Errorf("rate-limit requires rate_bps (use drop to discard all traffic)")
+		}
+		if rule.RateBps > maxFlowspecRateBps {
+			return fmt.Errorf("rate_bps %d exceeds %d", rule.RateBps, uint64(maxFlowspecRateBps))
+		}
 	default:
 		return fmt.Errorf("unsupported action %q: must be drop, rate-limit, or redirect", rule.Action)
 	}
@@ -515,5 +798,7 @@ func flowspecMatch(a, b FlowspecRule) bool {
 		a.Protocol == b.Protocol &&
 		a.SrcPort == b.SrcPort &&
 		a.DstPort == b.DstPort &&
-		a.Action == b.Action
+		a.PktLen == b.PktLen &&
+		a.Action == b.Action &&
+		a.RateBps == b.RateBps
 }
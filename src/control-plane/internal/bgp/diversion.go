@@ -0,0 +1,89 @@
+package bgp
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// AnnounceDiversion advertises prefix as a plain unicast route with
+// next-hop self, attracting its traffic to the scrubber. Unlike a blackhole
+// it carries no community and does not expire: it stays until withdrawn.
+// Announcing an already diverted prefix is a no-op.
+func (c *Client) AnnounceDiversion(prefix string) error {
+	if err := c.checkConnected(); err != nil {
+		return err
+	}
+
+	if err := validatePrefix(prefix); err != nil {
+		return fmt.Errorf("invalid prefix for diversion: %w", err)
+	}
+	if err := c.checkAuthorized(prefix); err != nil {
+		c.recordAudit("reject_diversion", fmt.Sprintf("prefix=%s", prefix))
+		return fmt.Errorf("refusing to divert %s: %w", prefix, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.diversions[prefix]; exists {
+		return nil
+	}
+
+	// In production with GoBGP:
+	// nlri, _ := apb.New(&gobgpapi.IPAddressPrefix{PrefixLen: prefixLen, Prefix: ip})
+	// attrs := []*anypb.Any{origin, nexthop}
+	// server.AddPath(ctx, &gobgpapi.AddPathRequest{...})
+
+	c.diversions[prefix] = time.Now()
+	c.appendAudit("announce_diversion", fmt.Sprintf("prefix=%s next_hop=%s", prefix, c.cfg.NextHopSelf))
+
+	c.log.Warn("diversion route announced",
+		zap.String("prefix", prefix),
+		zap.String("next_hop", c.cfg.NextHopSelf),
+	)
+	return nil
+}
+
+// WithdrawDiversion withdraws the diversion route for prefix, returning
+// its traffic to the normal path.
+func (c *Client) WithdrawDiversion(prefix string) error {
+	if err := c.checkConnected(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	announcedAt, exists := c.diversions[prefix]
+	if !exists {
+		return fmt.Errorf("diversion for %s not found", prefix)
+	}
+
+	// In production with GoBGP:
+	// server.DeletePath(ctx, &gobgpapi.DeletePathRequest{...})
+
+	delete(c.diversions, prefix)
+	c.appendAudit("withdraw_diversion", fmt.Sprintf("prefix=%s", prefix))
+
+	c.log.Info("diversion route withdrawn",
+		zap.String("prefix", prefix),
+		zap.Duration("age", time.Since(announcedAt)),
+	)
+	return nil
+}
+
+// GetDiversions returns the diverted prefixes, sorted.
+func (c *Client) GetDiversions() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	result := make([]string, 0, len(c.diversions))
+	for prefix := range c.diversions {
+		result = append(result, prefix)
+	}
+	sort.Strings(result)
+	return result
+}
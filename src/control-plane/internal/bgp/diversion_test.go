@@ -0,0 +1,33 @@
+package bgp
+
+import "testing"
+
+func TestDiversion(t *testing.T) {
+	c := connectedClient(t)
+
+	if err := c.AnnounceDiversion("203.0.113.0/24"); err != nil {
+		t.Fatalf("AnnounceDiversion: %v", err)
+	}
+	if err := c.AnnounceDiversion("192.0.2.0/24"); err == nil {
+		t.Error("diverting an unauthorized prefix should fail")
+	}
+	if got := c.GetDiversions(); len(got) != 1 || got[0] != "203.0.113.0/24" {
+		t.Errorf("diversions = %v", got)
+	}
+	if len(c.GetBlackholes()) != 0 {
+		t.Error("a diversion must not be announced as a blackhole")
+	}
+
+	if err := c.WithdrawDiversion("203.0.113.0/24"); err != nil {
+		t.Fatalf("WithdrawDiversion: %v", err)
+	}
+	if err := c.WithdrawDiversion("203.0.113.0/24"); err == nil {
+		t.Error("withdrawing a missing diversion should fail")
+	}
+
+	c.AnnounceDiversion("198.51.100.0/24")
+	c.WithdrawAll()
+	if got := c.GetDiversions(); len(got) != 0 {
+		t.Errorf("diversions after WithdrawAll = %v", got)
+	}
+}
@@ -0,0 +1,48 @@
+package bgp
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Traffic-rate extended community (RFC 8955 section 7.3): transitive
+// experimental type 0x80, sub-type 0x06.
+const (
+	extCommTypeTrafficRate    = 0x80
+	extCommSubTypeTrafficRate = 0x06
+)
+
+// AS_TRANS (RFC 6793) stands in for 4-byte AS numbers in 2-byte fields.
+const asTrans = 23456
+
+// Upper bound on a rate-limit rule: 10 Tbit/s, far beyond any link but
+// still exact enough as a float32 byte rate.
+const maxFlowspecRateBps = 10_000_000_000_000
+
+// TrafficRateCommunity encodes the traffic-rate extended community for a
+// drop or rate-limit rule. The rate field is an IEEE 754 float of bytes
+// per second; drop is a rate of 0. It reports false for other actions.
+func TrafficRateCommunity(localAS uint32, rule FlowspecRule) ([8]byte, bool) {
+	var comm [8]byte
+
+	var bytesPerSec float32
+	switch rule.Action {
+	case "drop":
+	case "rate-limit":
+		bytesPerSec = float32(rule.RateBps) / 8
+	default:
+		return comm, false
+	}
+
+	// The AS field is informational; 4-byte AS numbers don't fit.
+	as := localAS
+	if as > math.MaxUint16 {
+		as = asTrans
+	}
+
+	comm[0] = extCommTypeTrafficRate
+	comm[1] = extCommSubTypeTrafficRate
+	binary.BigEndian.PutUint16(comm[2:4], uint16(as))
+	binary.BigEndian.PutUint32(comm[4:8], math.Float32bits(bytesPerSec))
+	return comm, true
+}
@@ -0,0 +1,76 @@
+package bgp
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestTrafficRateCommunity(t *testing.T) {
+	tests := []struct {
+		name    string
+		localAS uint32
+		rule    FlowspecRule
+		want    string
+		ok      bool
+	}{
+		{
+			name:    "drop",
+			localAS: 64512,
+			rule:    FlowspecRule{Action: "drop"},
+			want:    "8006fc0000000000",
+			ok:      true,
+		},
+		{
+			// 8 Mbit/s = 1,000,000 bytes/s = 0x49742400.
+			name:    "rate limit",
+			localAS: 64512,
+			rule:    FlowspecRule{Action: "rate-limit", RateBps: 8_000_000},
+			want:    "8006fc0049742400",
+			ok:      true,
+		},
+		{
+			name:    "4-byte AS uses AS_TRANS",
+			localAS: 4200000000,
+			rule:    FlowspecRule{Action: "drop"},
+			want:    "80065ba000000000",
+			ok:      true,
+		},
+		{
+			name: "redirect",
+			rule: FlowspecRule{Action: "redirect"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := TrafficRateCommunity(tt.localAS, tt.rule)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && hex.EncodeToString(got[:]) != tt.want {
+				t.Errorf("community = %x, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFlowspecRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    FlowspecRule
+		wantErr bool
+	}{
+		{"rate limit", FlowspecRule{DstPrefix: "203.0.113.0/24", Action: "rate-limit", RateBps: 1_000_000}, false},
+		{"rate limit without rate", FlowspecRule{DstPrefix: "203.0.113.0/24", Action: "rate-limit"}, true},
+		{"rate limit above max", FlowspecRule{DstPrefix: "203.0.113.0/24", Action: "rate-limit", RateBps: maxFlowspecRateBps + 1}, true},
+		{"drop with rate", FlowspecRule{DstPrefix: "203.0.113.0/24", Action: "drop", RateBps: 1000}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateFlowspecRule(tt.rule); (err != nil) != tt.wantErr {
+				t.Errorf("validateFlowspecRule() error = %v, wantErr = %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package bgp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// persistedState is the on-disk form of the active announcements, written
+// on shutdown when announcements are intentionally kept across a restart.
+type persistedState struct {
+	SavedAt         time.Time            `json:"saved_at"`
+	Blackholes      []string             `json:"blackholes"`
+	BlackholeExpiry map[string]time.Time `json:"blackhole_expiry,omitempty"`
+	Flowspec        []FlowspecRule       `json:"flowspec"`
+}
+
+// SaveState writes the active blackhole and Flowspec announcements to path
+// atomically. After WithdrawAll the file records an empty set.
+func (c *Client) SaveState(path string) error {
+	c.mu.RLock()
+	st := persistedState{
+		SavedAt:         time.Now(),
+		BlackholeExpiry: make(map[string]time.Time, len(c.blackholes)),
+		Flowspec:        append([]FlowspecRule(nil), c.flowspecRules...),
+	}
+	for prefix, bh := range c.blackholes {
+		st.Blackholes = append(st.Blackholes, prefix)
+		st.BlackholeExpiry[prefix] = bh.ExpiresAt
+	}
+	c.mu.RUnlock()
+
+	sort.Strings(st.Blackholes)
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling BGP state: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing BGP state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing BGP state: %w", err)
+	}
+
+	c.log.Info("BGP state saved",
+		zap.String("path", path),
+		zap.Int("blackholes", len(st.Blackholes)),
+		zap.Int("flowspec", len(st.Flowspec)),
+	)
+	return nil
+}
+
+// RestoreState re-announces the announcements saved by SaveState with
+// their remaining lifetime. The session must be established. A missing file
+// is not an error.
+func (c *Client) RestoreState(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading BGP state: %w", err)
+	}
+
+	var st persistedState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("parsing BGP state: %w", err)
+	}
+
+	now := time.Now()
+	restored := 0
+	for _, prefix := range st.Blackholes {
+		// State saved without an expiry gets the default TTL.
+		var ttl time.Duration
+		if expiresAt, ok := st.BlackholeExpiry[prefix]; ok {
+			if ttl = expiresAt.Sub(now); ttl <= 0 {
+				continue // Expired while we were down.
+			}
+		}
+		if err := c.AnnounceBlackhole(prefix, ttl); err != nil {
+			c.log.Warn("failed to restore blackhole", zap.String("prefix", prefix), zap.Error(err))
+			continue
+		}
+		restored++
+	}
+	for _, rule := range st.Flowspec {
+		if !rule.ExpiresAt.IsZero() && now.After(rule.ExpiresAt) {
+			continue // Expired while we were down.
+		}
+		if err := c.AnnounceFlowspec(rule); err != nil {
+			c.log.Warn("failed to restore flowspec rule", zap.Error(err))
+			continue
+		}
+		restored++
+	}
+
+	c.log.Info("BGP state restored",
+		zap.String("path", path),
+		zap.Time("saved_at", st.SavedAt),
+		zap.Int("announcements", restored),
+	)
+	return nil
+}
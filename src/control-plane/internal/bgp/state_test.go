@@ -0,0 +1,160 @@
+package bgp
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func connectedClient(t *testing.T) *Client {
+	t.Helper()
+	c := NewClient(zap.NewNop(), Config{
+		Enabled:            true,
+		RouterIP:           "192.0.2.1",
+		LocalAS:            64512,
+		PeerAS:             64513,
+		AuthorizedPrefixes: []string{"198.51.100.0/24", "203.0.113.0/24"},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	return c
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bgp.json")
+
+	c := connectedClient(t)
+	if err := c.AnnounceBlackhole("198.51.100.7/32", 0); err != nil {
+		t.Fatalf("AnnounceBlackhole: %v", err)
+	}
+	live := FlowspecRule{DstPrefix: "203.0.113.0/24", Protocol: "udp", SrcPort: "53", Action: "drop"}
+	if err := c.AnnounceFlowspec(live); err != nil {
+		t.Fatalf("AnnounceFlowspec: %v", err)
+	}
+	expired := FlowspecRule{DstPrefix: "203.0.113.0/24", Protocol: "udp", SrcPort: "123", Action: "drop"}
+	if err := c.AnnounceFlowspec(expired); err != nil {
+		t.Fatalf("AnnounceFlowspec: %v", err)
+	}
+	c.setFlowspecExpiry(expired, time.Now().Add(-time.Minute))
+
+	if err := c.SaveState(path); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+
+	restored := connectedClient(t)
+	if err := restored.RestoreState(path); err != nil {
+		t.Fatalf("RestoreState: %v", err)
+	}
+	if got := restored.GetBlackholes(); len(got) != 1 || got[0] != "198.51.100.7/32" {
+		t.Errorf("blackholes = %v, want [198.51.100.7/32]", got)
+	}
+	// Blackhole plus the unexpired flowspec rule.
+	if got := restored.GetActiveRules(); len(got) != 2 {
+		t.Errorf("active rules = %d, want 2", len(got))
+	}
+
+	// After WithdrawAll the saved state is empty.
+	if err := c.WithdrawAll(); err != nil {
+		t.Fatalf("WithdrawAll: %v", err)
+	}
+	if err := c.SaveState(path); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	empty := connectedClient(t)
+	if err := empty.RestoreState(path); err != nil {
+		t.Fatalf("RestoreState: %v", err)
+	}
+	if got := empty.GetActiveRules(); len(got) != 0 {
+		t.Errorf("active rules after withdraw = %d, want 0", len(got))
+	}
+}
+
+func TestRestoreStateMissingFile(t *testing.T) {
+	c := connectedClient(t)
+	if err := c.RestoreState(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("RestoreState on missing file: %v", err)
+	}
+}
+
+func TestBlackholeExpiry(t *testing.T) {
+	c := connectedClient(t)
+	var withdrawn []string
+	c.OnBlackhole(func(prefix string, announced bool) {
+		if !announced {
+			withdrawn = append(withdrawn, prefix)
+		}
+	})
+
+	if err := c.AnnounceBlackhole("198.51.100.7/32", time.Minute); err != nil {
+		t.Fatalf("AnnounceBlackhole: %v", err)
+	}
+	if err := c.AnnounceBlackhole("198.51.100.8/32", 0); err != nil {
+		t.Fatalf("AnnounceBlackhole: %v", err)
+	}
+
+	now := time.Now()
+	if got := c.expireBlackholes(now.Add(2 * time.Minute)); len(got) != 1 || got[0] != "198.51.100.7/32" {
+		t.Fatalf("expired = %v, want [198.51.100.7/32]", got)
+	}
+	if len(withdrawn) != 1 {
+		t.Errorf("withdraw callbacks = %v", withdrawn)
+	}
+
+	// Renewal pushes the default-TTL route past its original expiry.
+	if n := c.RenewBlackholes(2 * defaultBlackholeTTL); n != 1 {
+		t.Errorf("renewed = %d, want 1", n)
+	}
+	if got := c.expireBlackholes(now.Add(defaultBlackholeTTL + time.Minute)); len(got) != 0 {
+		t.Errorf("renewed blackhole expired: %v", got)
+	}
+	if got := c.expireBlackholes(now.Add(3 * defaultBlackholeTTL)); len(got) != 1 {
+		t.Errorf("expired = %v, want 1", got)
+	}
+	if got := c.GetBlackholes(); len(got) != 0 {
+		t.Errorf("blackholes = %v, want none", got)
+	}
+}
+
+func TestAuthorizedPrefixes(t *testing.T) {
+	c := connectedClient(t)
+
+	for _, prefix := range []string{"198.51.100.7", "198.51.100.0/25", "203.0.113.0/24"} {
+		if err := c.AnnounceBlackhole(prefix, 0); err != nil {
+			t.Errorf("AnnounceBlackhole(%s): %v", prefix, err)
+		}
+	}
+	for _, prefix := range []string{"192.0.2.1/32", "198.51.0.0/16", "0.0.0.0/0"} {
+		if err := c.AnnounceBlackhole(prefix, 0); err == nil {
+			t.Errorf("AnnounceBlackhole(%s) accepted a prefix outside the authorized space", prefix)
+		}
+	}
+
+	if err := c.AnnounceFlowspec(FlowspecRule{SrcPrefix: "192.0.2.1/32", DstPrefix: "203.0.113.10/32", Action: "drop"}); err != nil {
+		t.Errorf("AnnounceFlowspec to authorized destination: %v", err)
+	}
+	if err := c.AnnounceFlowspec(FlowspecRule{DstPrefix: "192.0.2.0/24", Protocol: "udp", Action: "drop"}); err == nil {
+		t.Error("AnnounceFlowspec accepted an unauthorized destination")
+	}
+	if err := c.AnnounceFlowspec(FlowspecRule{SrcPrefix: "192.0.2.1/32", Action: "drop"}); err == nil {
+		t.Error("AnnounceFlowspec accepted a rule without a destination")
+	}
+}
+
+func TestConnectRequiresAuthorizedPrefixes(t *testing.T) {
+	cfg := Config{Enabled: true, RouterIP: "192.0.2.1", LocalAS: 64512, PeerAS: 64513}
+	if err := NewClient(zap.NewNop(), cfg).Connect(context.Background()); err == nil {
+		t.Error("Connect succeeded without authorized_prefixes")
+	}
+
+	cfg.AuthorizedPrefixes = []string{"203.0.113.0/24"}
+	cfg.FlowspecDstPrefix = "198.51.100.0/24"
+	if err := NewClient(zap.NewNop(), cfg).Connect(context.Background()); err == nil {
+		t.Error("Connect accepted a flowspec_dst_prefix outside the authorized prefixes")
+	}
+}
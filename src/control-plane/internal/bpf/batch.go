@@ -0,0 +1,165 @@
+package bpf
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/compat"
+)
+
+// DefaultBatchSize is the number of entries BatchWriter sends per syscall.
+const DefaultBatchSize = 4096
+
+// batchMap is the subset of *ebpf.Map used by BatchWriter.
+type batchMap interface {
+	BatchUpdate(keys, values interface{}, opts *ebpf.BatchOptions) (int, error)
+	BatchDelete(keys interface{}, opts *ebpf.BatchOptions) (int, error)
+	Update(key, value interface{}, flags ebpf.MapUpdateFlags) error
+	Delete(key interface{}) error
+}
+
+// BatchWriter queues updates and deletes for one map and writes them with
+// BatchUpdate/BatchDelete, so bulk loads cost one syscall per batch rather
+// than one per entry. Without kernel support for batch operations (or for
+// map types that lack them, such as LPM tries on older kernels) it falls
+// back to per-key syscalls. Queued operations are applied in order. A
+// BatchWriter is not safe for concurrent use.
+type BatchWriter[K any, V any] struct {
+	m     batchMap
+	size  int
+	batch bool
+
+	keys    []K
+	values  []V
+	deletes []K
+
+	onError  func(key K, err error)
+	failed   int
+	pending  int   // Failures since the last Flush
+	firstErr error // First failure since the last Flush
+}
+
+// NewBatchWriter creates a writer for m that sends size entries per batch
+// (DefaultBatchSize if size is 0).
+func NewBatchWriter[K any, V any](m *ebpf.Map, size int) *BatchWriter[K, V] {
+	return newBatchWriter[K, V](m, size, compat.Detect().HaveBatchOps())
+}
+
+func newBatchWriter[K any, V any](m batchMap, size int, batch bool) *BatchWriter[K, V] {
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+	return &BatchWriter[K, V]{m: m, size: size, batch: batch}
+}
+
+// OnError sets a function called for each key that could not be written.
+func (w *BatchWriter[K, V]) OnError(fn func(key K, err error)) {
+	w.onError = fn
+}
+
+// Update queues key to be set to value, writing the batch once it is full.
+func (w *BatchWriter[K, V]) Update(key K, value V) {
+	if len(w.deletes) > 0 {
+		w.flushDeletes()
+	}
+	w.keys = append(w.keys, key)
+	w.values = append(w.values, value)
+	if len(w.keys) >= w.size {
+		w.flushUpdates()
+	}
+}
+
+// Delete queues key for removal, writing the batch once it is full. Keys
+// that do not exist are not an error.
+func (w *BatchWriter[K, V]) Delete(key K) {
+	if len(w.keys) > 0 {
+		w.flushUpdates()
+	}
+	w.deletes = append(w.deletes, key)
+	if len(w.deletes) >= w.size {
+		w.flushDeletes()
+	}
+}
+
+// Flush writes every queued operation. It returns an error if any write
+// since the previous Flush failed.
+func (w *BatchWriter[K, V]) Flush() error {
+	w.flushUpdates()
+	w.flushDeletes()
+
+	if w.pending == 0 {
+		return nil
+	}
+	err := fmt.Errorf("%d map writes failed: %w", w.pending, w.firstErr)
+	w.pending, w.firstErr = 0, nil
+	return err
+}
+
+// Failed returns the number of keys that could not be written.
+func (w *BatchWriter[K, V]) Failed() int {
+	return w.failed
+}
+
+func (w *BatchWriter[K, V]) flushUpdates() {
+	if len(w.keys) == 0 {
+		return
+	}
+	done := 0
+	if w.batch {
+		n, err := w.m.BatchUpdate(w.keys, w.values, nil)
+		done = w.batchDone(n, err, len(w.keys))
+	}
+	// Entries the batch did not reach (or all of them, without batch
+	// support) are written one by one so a bad key fails alone.
+	for i := done; i < len(w.keys); i++ {
+		if err := w.m.Update(w.keys[i], w.values[i], ebpf.UpdateAny); err != nil {
+			w.fail(w.keys[i], err)
+		}
+	}
+	w.keys, w.values = w.keys[:0], w.values[:0]
+}
+
+func (w *BatchWriter[K, V]) flushDeletes() {
+	if len(w.deletes) == 0 {
+		return
+	}
+	done := 0
+	if w.batch {
+		n, err := w.m.BatchDelete(w.deletes, nil)
+		done = w.batchDone(n, err, len(w.deletes))
+	}
+	for i := done; i < len(w.deletes); i++ {
+		if err := w.m.Delete(w.deletes[i]); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			w.fail(w.deletes[i], err)
+		}
+	}
+	w.deletes = w.deletes[:0]
+}
+
+// batchDone returns how many of total entries a batch call completed. A
+// map type without batch support disables batching for later flushes.
+func (w *BatchWriter[K, V]) batchDone(n int, err error, total int) int {
+	if err == nil {
+		return total
+	}
+	if errors.Is(err, ebpf.ErrNotSupported) {
+		w.batch = false
+		return 0
+	}
+	if n < 0 || n > total {
+		return 0
+	}
+	return n
+}
+
+func (w *BatchWriter[K, V]) fail(key K, err error) {
+	w.failed++
+	w.pending++
+	if w.firstErr == nil {
+		w.firstErr = err
+	}
+	if w.onError != nil {
+		w.onError(key, err)
+	}
+}
@@ -0,0 +1,122 @@
+package bpf
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cilium/ebpf"
+)
+
+// fakeMap records writes. Keys listed in bad fail, aborting a batch at
+// that key as the kernel does.
+type fakeMap struct {
+	noBatch bool
+	bad     map[uint32]bool
+	data    map[uint32]uint32
+	calls   []string
+}
+
+func (f *fakeMap) BatchUpdate(keys, values interface{}, _ *ebpf.BatchOptions) (int, error) {
+	ks, vs := keys.([]uint32), values.([]uint32)
+	f.calls = append(f.calls, fmt.Sprintf("batch-update:%d", len(ks)))
+	if f.noBatch {
+		return 0, ebpf.ErrNotSupported
+	}
+	for i, k := range ks {
+		if f.bad[k] {
+			return i, errors.New("bad key")
+		}
+		f.data[k] = vs[i]
+	}
+	return len(ks), nil
+}
+
+func (f *fakeMap) BatchDelete(keys interface{}, _ *ebpf.BatchOptions) (int, error) {
+	ks := keys.([]uint32)
+	f.calls = append(f.calls, fmt.Sprintf("batch-delete:%d", len(ks)))
+	if f.noBatch {
+		return 0, ebpf.ErrNotSupported
+	}
+	for i, k := range ks {
+		if _, ok := f.data[k]; !ok {
+			return i, ebpf.ErrKeyNotExist
+		}
+		delete(f.data, k)
+	}
+	return len(ks), nil
+}
+
+func (f *fakeMap) Update(key, value interface{}, _ ebpf.MapUpdateFlags) error {
+	f.calls = append(f.calls, "update")
+	if f.bad[key.(uint32)] {
+		return errors.New("bad key")
+	}
+	f.data[key.(uint32)] = value.(uint32)
+	return nil
+}
+
+func (f *fakeMap) Delete(key interface{}) error {
+	f.calls = append(f.calls, "delete")
+	if _, ok := f.data[key.(uint32)]; !ok {
+		return ebpf.ErrKeyNotExist
+	}
+	delete(f.data, key.(uint32))
+	return nil
+}
+
+func TestBatchWriter(t *testing.T) {
+	f := &fakeMap{bad: map[uint32]bool{5: true}, data: map[uint32]uint32{}}
+	w := newBatchWriter[uint32, uint32](f, 4, true)
+	var failed []uint32
+	w.OnError(func(key uint32, err error) { failed = append(failed, key) })
+
+	for k := uint32(1); k <= 6; k++ {
+		w.Update(k, k*10)
+	}
+	w.Delete(2)
+	w.Delete(99) // Missing keys are not failures
+	if err := w.Flush(); err == nil {
+		t.Error("Flush should report the failed key")
+	}
+
+	// 1-4 fill a batch. 5 aborts the next one and is retried alone, then
+	// 6 follows. 99 aborts the delete batch after 2 and is retried alone.
+	want := []string{
+		"batch-update:4",
+		"batch-update:2", "update", "update",
+		"batch-delete:2", "delete",
+	}
+	if fmt.Sprint(f.calls) != fmt.Sprint(want) {
+		t.Errorf("calls = %v, want %v", f.calls, want)
+	}
+	if len(failed) != 1 || failed[0] != 5 || w.Failed() != 1 {
+		t.Errorf("failed = %v (count %d), want [5]", failed, w.Failed())
+	}
+	if len(f.data) != 4 || f.data[6] != 60 {
+		t.Errorf("map = %v", f.data)
+	}
+	if err := w.Flush(); err != nil {
+		t.Errorf("second Flush = %v, want nil", err)
+	}
+}
+
+func TestBatchWriterFallback(t *testing.T) {
+	f := &fakeMap{noBatch: true, data: map[uint32]uint32{}}
+	w := newBatchWriter[uint32, uint32](f, 2, true)
+
+	for k := uint32(1); k <= 3; k++ {
+		w.Update(k, k)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	// The first unsupported batch switches the writer to per-key updates.
+	want := []string{"batch-update:2", "update", "update", "update"}
+	if fmt.Sprint(f.calls) != fmt.Sprint(want) {
+		t.Errorf("calls = %v, want %v", f.calls, want)
+	}
+	if len(f.data) != 3 {
+		t.Errorf("map = %v", f.data)
+	}
+}
@@ -0,0 +1,170 @@
+package bpf
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// Value types of config map entries.
+const (
+	ConfigBool = "bool" // 0 or 1
+	ConfigUint = "uint" // Number within [Min, Max]
+	ConfigEnum = "enum" // Index into Values
+	ConfigIPv4 = "ipv4" // Address in network byte order
+)
+
+// ConfigKey describes a config map entry for the runtime config API.
+// Entries with ManagedBy set are kept in step with userspace state and
+// can only be read here; they are changed through that endpoint instead.
+type ConfigKey struct {
+	Key         uint32
+	Name        string
+	Type        string
+	Min, Max    uint64   // ConfigUint bounds
+	Values      []string // ConfigEnum names by value
+	Description string
+	ManagedBy   string
+}
+
+// ConfigKeys lists every CFG_* entry of the config map.
+var ConfigKeys = []ConfigKey{
+	{Key: CfgEnabled, Name: "enabled", Type: ConfigBool, Description: "Scrubbing enabled; when off all traffic passes"},
+	{Key: CfgSYNRatePPS, Name: "syn_rate_pps", Type: ConfigUint, Max: 100_000_000, Description: "SYN rate limit per source IP (0 = none)"},
+	{Key: CfgUDPRatePPS, Name: "udp_rate_pps", Type: ConfigUint, Max: 100_000_000, Description: "UDP rate limit per source IP (0 = none)"},
+	{Key: CfgICMPRatePPS, Name: "icmp_rate_pps", Type: ConfigUint, Max: 100_000_000, Description: "ICMP rate limit per source IP (0 = none)"},
+	{Key: CfgGlobalPPSLimit, Name: "global_pps_limit", Type: ConfigUint, Max: 1_000_000_000, Description: "Global packet rate limit (0 = none)"},
+	{Key: CfgGlobalBPSLimit, Name: "global_bps_limit", Type: ConfigUint, Max: 1_000_000_000_000, Description: "Global bit rate limit (0 = none)"},
+	{Key: CfgSYNCookieEnable, Name: "syn_cookie_enable", Type: ConfigBool, Description: "Answer SYNs with SYN cookies"},
+	{Key: CfgConntrackEnable, Name: "conntrack_enable", Type: ConfigBool, Description: "Connection tracking"},
+	{Key: CfgBaselinePPS, Name: "baseline_pps", Type: ConfigUint, Description: "Learned baseline packet rate", ManagedBy: "baseline engine"},
+	{Key: CfgBaselineBPS, Name: "baseline_bps", Type: ConfigUint, Description: "Learned baseline bit rate", ManagedBy: "baseline engine"},
+	{Key: CfgAttackThreshold, Name: "attack_threshold", Type: ConfigUint, Min: 100, Max: 100_000, Description: "Attack detection threshold as a multiple of baseline x100"},
+	{Key: CfgGeoIPEnable, Name: "geoip_enable", Type: ConfigBool, Description: "GeoIP country policies"},
+	{Key: CfgReputationEnable, Name: "reputation_enable", Type: ConfigBool, Description: "Drop sources with a blocking reputation score"},
+	{Key: CfgReputationThresh, Name: "reputation_thresh", Type: ConfigUint, Max: 1000, Description: "Reputation score that auto-blocks a source", ManagedBy: "/api/v1/reputation/threshold"},
+	{Key: CfgProtoValidEnable, Name: "proto_valid_enable", Type: ConfigBool, Description: "Drop malformed and protocol-violating packets"},
+	{Key: CfgPayloadMatchEn, Name: "payload_match_enable", Type: ConfigBool, Description: "Payload signature matching"},
+	{Key: CfgEscalationLevel, Name: "escalation_level", Type: ConfigEnum, Values: []string{"normal", "elevated", "high", "critical"}, Description: "Current escalation level", ManagedBy: "/api/v1/escalation"},
+	{Key: CfgThreatIntelEn, Name: "threat_intel_enable", Type: ConfigBool, Description: "Drop sources listed by threat intel feeds"},
+	{Key: CfgDNSValidMode, Name: "dns_valid_mode", Type: ConfigEnum, Values: []string{"off", "basic", "strict"}, Description: "DNS response validation"},
+	{Key: CfgTCPStateEnable, Name: "tcp_state_enable", Type: ConfigBool, Description: "TCP state machine validation"},
+	{Key: CfgAdaptiveRate, Name: "adaptive_rate", Type: ConfigBool, Description: "Derive per-source rate limits from the baseline"},
+	{Key: CfgCaptureMode, Name: "capture_mode", Type: ConfigEnum, Values: []string{"off", "drops", "all"}, Description: "Packet capture", ManagedBy: "/api/v1/capture"},
+	{Key: CfgCaptureSample, Name: "capture_sample", Type: ConfigUint, Description: "Capture 1 in N packets", ManagedBy: "/api/v1/capture"},
+	{Key: CfgCaptureSnaplen, Name: "capture_snaplen", Type: ConfigUint, Max: CaptureSnaplenMax, Description: "Bytes captured per packet", ManagedBy: "/api/v1/capture"},
+	{Key: CfgASNEnable, Name: "asn_enable", Type: ConfigBool, Description: "ASN policy enforcement"},
+	{Key: CfgSYNCookieScoped, Name: "syn_cookie_scoped", Type: ConfigBool, Description: "SYN cookies only for listed destinations", ManagedBy: "/api/v1/syncookie/destinations"},
+	{Key: CfgDiversionEnable, Name: "diversion_enable", Type: ConfigBool, Description: "Re-inject clean traffic over GRE tunnels", ManagedBy: "/api/v1/diversion"},
+	{Key: CfgGRELocalIP, Name: "gre_local_ip", Type: ConfigIPv4, Description: "Outer source address of re-injected packets", ManagedBy: "/api/v1/diversion"},
+	{Key: CfgConnLimit, Name: "conn_limit", Type: ConfigUint, Max: 1_000_000, Description: "Concurrent TCP connections per source (0 = none)"},
+	{Key: CfgPortScanAction, Name: "port_scan_action", Type: ConfigEnum, Values: []string{"off", "score", "block", "event"}, Description: "Port scan response", ManagedBy: "port_scan.action in the config file"},
+	{Key: CfgPortScanThresh, Name: "port_scan_thresh", Type: ConfigUint, Max: 512, Description: "Distinct destination ports per window (0 = 20)"},
+	{Key: CfgPortScanWindow, Name: "port_scan_window", Type: ConfigUint, Max: 3600, Description: "Port scan window in seconds (0 = 10)"},
+	{Key: CfgEgressSpoof, Name: "egress_spoof", Type: ConfigBool, Description: "Drop outbound packets from sources outside the local prefixes", ManagedBy: "/api/v1/egress"},
+	{Key: CfgEgressAmp, Name: "egress_amp", Type: ConfigBool, Description: "Limit outbound UDP responses from amplification-prone ports", ManagedBy: "/api/v1/egress"},
+	{Key: CfgInspectEnable, Name: "inspect_enable", Type: ConfigBool, Description: "Redirect selected traffic to the AF_XDP deep inspector", ManagedBy: "inspect.enabled in the config file"},
+	{Key: CfgInspectSample, Name: "inspect_sample", Type: ConfigUint, Max: 1_000_000, Description: "Inspect 1 in N selected packets"},
+	{Key: CfgInspectScore, Name: "inspect_score", Type: ConfigUint, Max: 1000, Description: "Always inspect sources with this reputation score (0 = never)"},
+	{Key: CfgTLSHSRate, Name: "tls_hs_rate", Type: ConfigUint, Max: 1_000_000, Description: "TLS ClientHellos per second per source (0 = no limit)"},
+	{Key: CfgQUICInitRate, Name: "quic_init_rate", Type: ConfigUint, Max: 1_000_000, Description: "QUIC Initial packets per second per source (0 = no limit)"},
+}
+
+// LookupConfigKey finds a config key by name.
+func LookupConfigKey(name string) (ConfigKey, bool) {
+	for _, k := range ConfigKeys {
+		if k.Name == name {
+			return k, true
+		}
+	}
+	return ConfigKey{}, false
+}
+
+// Format renders a raw config map value as its typed form: a bool, a
+// number, an enum name or an address.
+func (k ConfigKey) Format(v uint64) interface{} {
+	switch k.Type {
+	case ConfigBool:
+		return v != 0
+	case ConfigEnum:
+		if v < uint64(len(k.Values)) {
+			return k.Values[v]
+		}
+		return v
+	case ConfigIPv4:
+		return U32BEToIP(uint32(v)).String()
+	default:
+		return v
+	}
+}
+
+// Parse converts a typed value to its raw config map form, checking it
+// against the key's type and range. Enums accept a name or a number.
+func (k ConfigKey) Parse(v interface{}) (uint64, error) {
+	switch k.Type {
+	case ConfigBool:
+		b, ok := v.(bool)
+		if !ok {
+			return 0, fmt.Errorf("%s: expected true or false", k.Name)
+		}
+		if b {
+			return 1, nil
+		}
+		return 0, nil
+
+	case ConfigEnum:
+		if s, ok := v.(string); ok {
+			for i, name := range k.Values {
+				if name == s {
+					return uint64(i), nil
+				}
+			}
+			return 0, fmt.Errorf("%s: unknown value %q (must be one of %v)", k.Name, s, k.Values)
+		}
+		n, err := configNumber(k.Name, v)
+		if err != nil {
+			return 0, err
+		}
+		if n >= uint64(len(k.Values)) {
+			return 0, fmt.Errorf("%s: %d out of range (0-%d)", k.Name, n, len(k.Values)-1)
+		}
+		return n, nil
+
+	case ConfigIPv4:
+		s, _ := v.(string)
+		ip := net.ParseIP(s).To4()
+		if ip == nil {
+			return 0, fmt.Errorf("%s: expected an IPv4 address", k.Name)
+		}
+		return uint64(IPToU32BE(ip)), nil
+
+	default:
+		n, err := configNumber(k.Name, v)
+		if err != nil {
+			return 0, err
+		}
+		if n < k.Min || (k.Max > 0 && n > k.Max) {
+			return 0, fmt.Errorf("%s: %d out of range (%d-%d)", k.Name, n, k.Min, k.Max)
+		}
+		return n, nil
+	}
+}
+
+// configNumber accepts a non-negative integer decoded from JSON, either as
+// a json.Number or a float64.
+func configNumber(name string, v interface{}) (uint64, error) {
+	var s string
+	switch n := v.(type) {
+	case fmt.Stringer:
+		s = n.String()
+	case float64:
+		s = strconv.FormatFloat(n, 'f', -1, 64)
+	default:
+		return 0, fmt.Errorf("%s: expected a number", name)
+	}
+	u, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: expected a non-negative integer, got %s", name, s)
+	}
+	return u, nil
+}
@@ -0,0 +1,81 @@
+package bpf
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestConfigKeysComplete(t *testing.T) {
+	seen := make(map[uint32]bool)
+	names := make(map[string]bool)
+	for _, k := range ConfigKeys {
+		if k.Key >= CfgMax {
+			t.Errorf("%s: key %d out of range", k.Name, k.Key)
+		}
+		if seen[k.Key] || names[k.Name] {
+			t.Errorf("%s (%d) listed twice", k.Name, k.Key)
+		}
+		seen[k.Key], names[k.Name] = true, true
+	}
+	for key := uint32(0); key <= CfgQUICInitRate; key++ {
+		if !seen[key] {
+			t.Errorf("config key %d not listed", key)
+		}
+	}
+}
+
+func TestConfigKeyParse(t *testing.T) {
+	// Values as decoded with json.Decoder.UseNumber.
+	tests := []struct {
+		key     string
+		value   interface{}
+		want    uint64
+		wantErr bool
+	}{
+		{"tcp_state_enable", true, 1, false},
+		{"tcp_state_enable", false, 0, false},
+		{"tcp_state_enable", json.Number("1"), 0, true},
+		{"dns_valid_mode", "strict", 2, false},
+		{"dns_valid_mode", json.Number("1"), 1, false},
+		{"dns_valid_mode", "paranoid", 0, true},
+		{"dns_valid_mode", json.Number("3"), 0, true},
+		{"syn_rate_pps", json.Number("5000"), 5000, false},
+		{"syn_rate_pps", float64(5000), 5000, false},
+		{"syn_rate_pps", json.Number("-1"), 0, true},
+		{"syn_rate_pps", json.Number("1.5"), 0, true},
+		{"syn_rate_pps", "5000", 0, true},
+		{"attack_threshold", json.Number("50"), 0, true},
+		{"global_bps_limit", json.Number("1000000000000"), 1_000_000_000_000, false},
+		{"gre_local_ip", "192.0.2.1", uint64(IPToU32BE([]byte{192, 0, 2, 1})), false},
+		{"gre_local_ip", "2001:db8::1", 0, true},
+	}
+	for _, tt := range tests {
+		k, ok := LookupConfigKey(tt.key)
+		if !ok {
+			t.Fatalf("LookupConfigKey(%q) not found", tt.key)
+		}
+		got, err := k.Parse(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s.Parse(%v) error = %v, wantErr %v", tt.key, tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s.Parse(%v) = %d, want %d", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestConfigKeyFormat(t *testing.T) {
+	mode, _ := LookupConfigKey("dns_valid_mode")
+	if got := mode.Format(1); got != "basic" {
+		t.Errorf("Format(1) = %v, want basic", got)
+	}
+	if got := mode.Format(9); got != uint64(9) {
+		t.Errorf("Format(9) = %v, want 9", got)
+	}
+	gre, _ := LookupConfigKey("gre_local_ip")
+	raw, _ := gre.Parse("192.0.2.1")
+	if got := gre.Format(raw); got != "192.0.2.1" {
+		t.Errorf("Format(Parse(192.0.2.1)) = %v", got)
+	}
+}
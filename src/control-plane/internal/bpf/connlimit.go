@@ -0,0 +1,200 @@
+package bpf
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Conntrack idle timeouts, matching CT_TIMEOUT_* in conntrack.h. Flows idle
+// for longer no longer count as open connections.
+const (
+	ctTimeoutTCPEstablished = 300 * time.Second
+	ctTimeoutTCPNew         = 30 * time.Second
+)
+
+// SourceConns is the number of open TCP connections from one source.
+type SourceConns struct {
+	IP    net.IP
+	Conns uint32
+}
+
+// SetConnLimits replaces the per-destination connection limits with
+// limits, keyed by prefix. Destinations without a limit use the
+// CfgConnLimit default.
+func (m *MapManager) SetConnLimits(limits map[string]uint32) error {
+	keys := make(map[LPMKeyV4]uint32, len(limits))
+	for cidr, limit := range limits {
+		key, err := cidrToLPMKey(cidr)
+		if err != nil {
+			return err
+		}
+		keys[key] = limit
+	}
+
+	m.connLimitMu.Lock()
+	defer m.connLimitMu.Unlock()
+
+	current, err := m.connLimits()
+	if err != nil {
+		return err
+	}
+	w := NewBatchWriter[LPMKeyV4, uint32](m.objs.ConnLimitDst, 0)
+	for key := range current {
+		if _, keep := keys[key]; !keep {
+			w.Delete(key)
+		}
+	}
+	for key, limit := range keys {
+		w.Update(key, limit)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing connection limits: %w", err)
+	}
+	return nil
+}
+
+// ConnLimits returns the per-destination connection limits by prefix.
+func (m *MapManager) ConnLimits() (map[string]uint32, error) {
+	keys, err := m.connLimits()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]uint32, len(keys))
+	for key, limit := range keys {
+		out[lpmKeyToCIDR(key)] = limit
+	}
+	return out, nil
+}
+
+func (m *MapManager) connLimits() (map[LPMKeyV4]uint32, error) {
+	var (
+		key   LPMKeyV4
+		limit uint32
+	)
+	out := make(map[LPMKeyV4]uint32)
+	defer m.timeIteration("conn_limit_dst", time.Now())
+	iter := m.objs.ConnLimitDst.Iterate()
+	for iter.Next(&key, &limit) {
+		out[key] = limit
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating connection limits: %w", err)
+	}
+	return out, nil
+}
+
+// SyncConnCounts recounts the open TCP connections of every source from
+// conntrack and rewrites conn_count with the result. The data plane only
+// sees connections open and close; entries evicted from conntrack or left
+// idle would otherwise hold their sources' counts up forever. It returns
+// the sources sorted by connection count, highest first.
+func (m *MapManager) SyncConnCounts() ([]SourceConns, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return nil, fmt.Errorf("reading monotonic clock: %w", err)
+	}
+	now := uint64(ts.Nano())
+
+	var (
+		key   ConntrackKey
+		value []ConntrackEntry // per-CPU slice
+	)
+	counts := make(map[uint32]uint32)
+	start := time.Now()
+	iter := m.objs.ConntrackMap.Iterate()
+	for iter.Next(&key, &value) {
+		if key.Protocol != unix.IPPROTO_TCP {
+			continue
+		}
+		if e := mergeConntrackEntries(value); connOpen(e, now) {
+			counts[key.SrcIP]++
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating conntrack: %w", err)
+	}
+	m.timeIteration("conntrack_map", start)
+
+	var (
+		src   uint32
+		count uint32
+		stale []uint32
+	)
+	start = time.Now()
+	iter = m.objs.ConnCount.Iterate()
+	for iter.Next(&src, &count) {
+		if _, ok := counts[src]; !ok {
+			stale = append(stale, src)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating connection counts: %w", err)
+	}
+	m.timeIteration("conn_count", start)
+
+	w := NewBatchWriter[uint32, uint32](m.objs.ConnCount, 0)
+	for _, src := range stale {
+		w.Delete(src)
+	}
+	out := make([]SourceConns, 0, len(counts))
+	for src, n := range counts {
+		w.Update(src, n)
+		out = append(out, SourceConns{IP: U32BEToIP(src), Conns: n})
+	}
+	if err := w.Flush(); err != nil {
+		return nil, fmt.Errorf("writing connection counts: %w", err)
+	}
+
+	sortSourceConns(out)
+	return out, nil
+}
+
+// ConnCounts returns the open connection counts the data plane currently
+// enforces, highest first.
+func (m *MapManager) ConnCounts() ([]SourceConns, error) {
+	var (
+		src   uint32
+		count uint32
+		out   []SourceConns
+	)
+	defer m.timeIteration("conn_count", time.Now())
+	iter := m.objs.ConnCount.Iterate()
+	for iter.Next(&src, &count) {
+		if count > 0 {
+			out = append(out, SourceConns{IP: U32BEToIP(src), Conns: count})
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating connection counts: %w", err)
+	}
+	sortSourceConns(out)
+	return out, nil
+}
+
+func sortSourceConns(s []SourceConns) {
+	sort.Slice(s, func(i, j int) bool {
+		if s[i].Conns != s[j].Conns {
+			return s[i].Conns > s[j].Conns
+		}
+		return s[i].IP.String() < s[j].IP.String()
+	})
+}
+
+// connOpen reports whether a TCP conntrack entry is a live connection at
+// now (bpf_ktime_get_ns time).
+func connOpen(e ConntrackEntry, now uint64) bool {
+	var timeout time.Duration
+	switch e.State {
+	case CTStateEstablished, CTStateFINWait:
+		timeout = ctTimeoutTCPEstablished
+	case CTStateNew, CTStateSYNSent, CTStateSYNRecv:
+		timeout = ctTimeoutTCPNew
+	default:
+		return false
+	}
+	return e.LastSeenNS+uint64(timeout) > now
+}
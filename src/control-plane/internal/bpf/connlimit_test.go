@@ -0,0 +1,29 @@
+package bpf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConnOpen(t *testing.T) {
+	now := uint64(time.Hour)
+	ago := func(d time.Duration) uint64 { return now - uint64(d) }
+
+	tests := []struct {
+		name  string
+		entry ConntrackEntry
+		want  bool
+	}{
+		{"established", ConntrackEntry{State: CTStateEstablished, LastSeenNS: ago(time.Minute)}, true},
+		{"idle established", ConntrackEntry{State: CTStateEstablished, LastSeenNS: ago(10 * time.Minute)}, false},
+		{"handshake", ConntrackEntry{State: CTStateSYNSent, LastSeenNS: ago(10 * time.Second)}, true},
+		{"stale handshake", ConntrackEntry{State: CTStateSYNSent, LastSeenNS: ago(time.Minute)}, false},
+		{"closed", ConntrackEntry{State: CTStateClosed, LastSeenNS: now}, false},
+		{"reset", ConntrackEntry{State: CTStateRST, LastSeenNS: now}, false},
+	}
+	for _, tt := range tests {
+		if got := connOpen(tt.entry, now); got != tt.want {
+			t.Errorf("%s: connOpen = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package bpf
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/compat"
+	"go.uber.org/zap"
+)
+
+// DefaultVerifierLogLines is how many verifier log lines are kept from a
+// failed load when no limit is configured.
+const DefaultVerifierLogLines = 200
+
+// LoadDiagnostics explains why loading a BPF object failed.
+type LoadDiagnostics struct {
+	Time   time.Time
+	Object string
+	Kernel string
+	Error  string
+
+	// Tail of the verifier log; the rejection reason is at the end.
+	// Truncated is set when lines were dropped from the start.
+	VerifierLog []string
+	LogLines    int // Lines in the full log
+	Truncated   bool
+
+	// Kernel features that are unavailable or could not be probed.
+	MissingFeatures []compat.Feature
+}
+
+// diagnose builds the diagnostics for a failed load of objPath, keeping at
+// most maxLines lines of the verifier log (all of them if maxLines is 0).
+func diagnose(objPath string, err error, maxLines int) *LoadDiagnostics {
+	f := compat.Detect()
+	d := &LoadDiagnostics{
+		Time:            time.Now(),
+		Object:          objPath,
+		Kernel:          f.Kernel,
+		Error:           err.Error(),
+		MissingFeatures: f.Missing(),
+	}
+
+	var verr *ebpf.VerifierError
+	if errors.As(err, &verr) {
+		d.LogLines = len(verr.Log)
+		d.VerifierLog = verr.Log
+		if maxLines > 0 && len(d.VerifierLog) > maxLines {
+			d.VerifierLog = d.VerifierLog[len(d.VerifierLog)-maxLines:]
+			d.Truncated = true
+		}
+	}
+	return d
+}
+
+// logDiagnostics writes d to the log: a summary, then the verifier log one
+// line per entry so it survives JSON log encoding readably.
+func logDiagnostics(log *zap.Logger, d *LoadDiagnostics) {
+	missing := make([]string, 0, len(d.MissingFeatures))
+	for _, f := range d.MissingFeatures {
+		missing = append(missing, f.Name+" ("+string(f.Status)+")")
+	}
+	log.Error("BPF object failed to load",
+		zap.String("path", d.Object),
+		zap.String("kernel", d.Kernel),
+		zap.Strings("missing_features", missing),
+		zap.Int("verifier_log_lines", d.LogLines),
+		zap.Bool("verifier_log_truncated", d.Truncated),
+		zap.String("error", d.Error),
+	)
+	for _, line := range d.VerifierLog {
+		log.Error("verifier", zap.String("line", line))
+	}
+}
+
+// SetVerifierLogLines limits how many verifier log lines are kept from a
+// failed load (0 keeps the full log).
+func (l *Loader) SetVerifierLogLines(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logLines = n
+}
+
+// LastLoadError returns the diagnostics of the most recent failed Load or
+// Replace, or nil if none has failed.
+func (l *Loader) LastLoadError() *LoadDiagnostics {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lastErr
+}
+
+// loadFailed records and logs the diagnostics for a failed load. Caller
+// must hold l.mu.
+func (l *Loader) loadFailed(objPath string, err error) {
+	l.lastErr = diagnose(objPath, err, l.logLines)
+	logDiagnostics(l.log, l.lastErr)
+}
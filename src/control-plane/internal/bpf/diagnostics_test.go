@@ -0,0 +1,32 @@
+package bpf
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cilium/ebpf"
+)
+
+func TestDiagnoseVerifierLog(t *testing.T) {
+	verr := &ebpf.VerifierError{
+		Cause: errors.New("permission denied"),
+		Log:   []string{"0: (b7) r0 = 2", "1: (95) exit", "R1 invalid mem access 'scalar'", "processed 2 insns"},
+	}
+	err := fmt.Errorf("loading and assigning BPF objects: %w", verr)
+
+	d := diagnose("scrubber.o", err, 2)
+	if d.LogLines != 4 || !d.Truncated {
+		t.Errorf("LogLines = %d, Truncated = %t, want 4, true", d.LogLines, d.Truncated)
+	}
+	if len(d.VerifierLog) != 2 || d.VerifierLog[0] != "R1 invalid mem access 'scalar'" {
+		t.Errorf("VerifierLog = %q, want the last two lines", d.VerifierLog)
+	}
+
+	if d := diagnose("scrubber.o", err, 0); len(d.VerifierLog) != 4 || d.Truncated {
+		t.Errorf("unlimited: VerifierLog = %q, Truncated = %t", d.VerifierLog, d.Truncated)
+	}
+	if d := diagnose("scrubber.o", errors.New("map create: no space"), 2); d.VerifierLog != nil || d.LogLines != 0 {
+		t.Errorf("non-verifier error produced log %q", d.VerifierLog)
+	}
+}
@@ -0,0 +1,161 @@
+package bpf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"go.uber.org/zap"
+)
+
+// SetDstPolicy adds or replaces the policy of a protected destination
+// prefix. Replacing a policy keeps the prefix's traffic counters; the
+// counters in p are ignored.
+func (m *MapManager) SetDstPolicy(cidr string, p DstPolicy) error {
+	key, err := cidrToLPMKey(cidr)
+	if err != nil {
+		return err
+	}
+	var cur DstPolicy
+	if err := m.objs.DstPolicyMap.Lookup(key, &cur); err == nil {
+		p.RxPackets, p.RxBytes = cur.RxPackets, cur.RxBytes
+		p.DroppedPackets, p.DroppedBytes = cur.DroppedPackets, cur.DroppedBytes
+	} else {
+		p.RxPackets, p.RxBytes, p.DroppedPackets, p.DroppedBytes = 0, 0, 0, 0
+	}
+	if err := m.objs.DstPolicyMap.Update(key, p, ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("setting destination policy %s: %w", cidr, err)
+	}
+	m.log.Debug("destination policy set", zap.String("cidr", cidr))
+	return nil
+}
+
+// RemoveDstPolicy removes the policy of a destination prefix.
+func (m *MapManager) RemoveDstPolicy(cidr string) error {
+	key, err := cidrToLPMKey(cidr)
+	if err != nil {
+		return err
+	}
+	if err := m.objs.DstPolicyMap.Delete(key); err != nil {
+		return fmt.Errorf("removing destination policy %s: %w", cidr, err)
+	}
+	m.log.Debug("destination policy removed", zap.String("cidr", cidr))
+	return nil
+}
+
+// DstPolicies returns the policy and traffic counters of every protected
+// destination prefix.
+func (m *MapManager) DstPolicies() (map[string]DstPolicy, error) {
+	var (
+		key LPMKeyV4
+		p   DstPolicy
+	)
+	out := make(map[string]DstPolicy)
+	defer m.timeIteration("dst_policy_map", time.Now())
+	iter := m.objs.DstPolicyMap.Iterate()
+	for iter.Next(&key, &p) {
+		out[lpmKeyToCIDR(key)] = p
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating destination policies: %w", err)
+	}
+	return out, nil
+}
+
+// SetDstEscalation sets the escalation level of a protected destination
+// prefix, raising the level the data plane applies to traffic towards it.
+func (m *MapManager) SetDstEscalation(cidr string, level uint32) error {
+	key, err := cidrToLPMKey(cidr)
+	if err != nil {
+		return err
+	}
+	if err := m.objs.DstEscalation.Update(key, level, ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("setting destination escalation %s: %w", cidr, err)
+	}
+	m.log.Debug("destination escalation set", zap.String("cidr", cidr), zap.Uint32("level", level))
+	return nil
+}
+
+// RemoveDstEscalation drops the escalation level of a destination prefix,
+// leaving its traffic at the global level.
+func (m *MapManager) RemoveDstEscalation(cidr string) error {
+	key, err := cidrToLPMKey(cidr)
+	if err != nil {
+		return err
+	}
+	if err := m.objs.DstEscalation.Delete(key); err != nil {
+		return fmt.Errorf("removing destination escalation %s: %w", cidr, err)
+	}
+	m.log.Debug("destination escalation removed", zap.String("cidr", cidr))
+	return nil
+}
+
+// SetGeoAllow replaces the countries of allow-list id in geoip_allow.
+// Country codes are two letters, e.g. "DE".
+func (m *MapManager) SetGeoAllow(id uint16, countries []string) error {
+	want := make(map[GeoIPAllowKey]bool, len(countries))
+	for _, cc := range countries {
+		if len(cc) != 2 {
+			return fmt.Errorf("invalid country code %q", cc)
+		}
+		cc = strings.ToUpper(cc)
+		want[GeoIPAllowKey{ID: id, CountryCode: uint16(cc[0])<<8 | uint16(cc[1])}] = true
+	}
+
+	stale, err := m.geoAllowKeys(id)
+	if err != nil {
+		return err
+	}
+	for _, key := range stale {
+		if want[key] {
+			continue
+		}
+		if err := m.objs.GeoIPAllow.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return fmt.Errorf("removing country from allow-list %d: %w", id, err)
+		}
+	}
+	one := uint8(1)
+	for key := range want {
+		if err := m.objs.GeoIPAllow.Update(key, one, ebpf.UpdateAny); err != nil {
+			return fmt.Errorf("setting allow-list %d: %w", id, err)
+		}
+	}
+	m.log.Debug("country allow-list set", zap.Uint16("id", id), zap.Strings("countries", countries))
+	return nil
+}
+
+// RemoveGeoAllow removes every country of allow-list id.
+func (m *MapManager) RemoveGeoAllow(id uint16) error {
+	keys, err := m.geoAllowKeys(id)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := m.objs.GeoIPAllow.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return fmt.Errorf("removing allow-list %d: %w", id, err)
+		}
+	}
+	m.log.Debug("country allow-list removed", zap.Uint16("id", id))
+	return nil
+}
+
+// geoAllowKeys returns the geoip_allow keys of allow-list id.
+func (m *MapManager) geoAllowKeys(id uint16) ([]GeoIPAllowKey, error) {
+	var (
+		key  GeoIPAllowKey
+		val  uint8
+		keys []GeoIPAllowKey
+	)
+	iter := m.objs.GeoIPAllow.Iterate()
+	for iter.Next(&key, &val) {
+		if key.ID == id {
+			keys = append(keys, key)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating country allow-lists: %w", err)
+	}
+	return keys, nil
+}
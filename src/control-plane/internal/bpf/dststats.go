@@ -0,0 +1,65 @@
+package bpf
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// PrefixStats is the traffic towards the destinations inside a set of
+// prefixes, summed from dst_stats across addresses and CPUs.
+type PrefixStats struct {
+	Destinations   int // Addresses in the prefixes that received traffic
+	RxPackets      uint64
+	RxBytes        uint64
+	DroppedPackets uint64
+	DroppedBytes   uint64
+	DropReasons    map[string]uint64 // Dropped packets by DropReasonName
+}
+
+// ReadPrefixStats sums the per-destination counters of every address
+// inside prefixes. Destinations evicted from the LRU map are not counted.
+func (m *MapManager) ReadPrefixStats(prefixes []*net.IPNet) (PrefixStats, error) {
+	st := PrefixStats{DropReasons: make(map[string]uint64)}
+	var (
+		key    uint32
+		perCPU []DstStats
+	)
+	defer m.timeIteration("dst_stats", time.Now())
+	iter := m.objs.DstStats.Iterate()
+	for iter.Next(&key, &perCPU) {
+		if inPrefixes(prefixes, U32BEToIP(key)) {
+			st.add(perCPU)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return PrefixStats{}, fmt.Errorf("iterating destination stats: %w", err)
+	}
+	return st, nil
+}
+
+// add counts one destination's per-CPU counters.
+func (st *PrefixStats) add(perCPU []DstStats) {
+	st.Destinations++
+	for i := range perCPU {
+		d := &perCPU[i]
+		st.RxPackets += d.RxPackets
+		st.RxBytes += d.RxBytes
+		st.DroppedPackets += d.DroppedPackets
+		st.DroppedBytes += d.DroppedBytes
+		for r, n := range d.DropReasons {
+			if n > 0 {
+				st.DropReasons[DropReasonName(uint8(r))] += n
+			}
+		}
+	}
+}
+
+func inPrefixes(prefixes []*net.IPNet, ip net.IP) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package bpf
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPrefixStatsAdd(t *testing.T) {
+	st := PrefixStats{DropReasons: make(map[string]uint64)}
+
+	var cpu0, cpu1 DstStats
+	cpu0.RxPackets, cpu0.DroppedPackets = 10, 4
+	cpu0.DropReasons[DropSYNFlood] = 3
+	cpu0.DropReasons[DropRateLimit] = 1
+	cpu1.RxPackets, cpu1.DroppedPackets = 5, 2
+	cpu1.DropReasons[DropSYNFlood] = 2
+	st.add([]DstStats{cpu0, cpu1})
+	st.add([]DstStats{{RxPackets: 1}})
+
+	if st.Destinations != 2 || st.RxPackets != 16 || st.DroppedPackets != 6 {
+		t.Errorf("totals = %+v", st)
+	}
+	if st.DropReasons["syn_flood"] != 5 || st.DropReasons["rate_limit"] != 1 || len(st.DropReasons) != 2 {
+		t.Errorf("drop reasons = %v", st.DropReasons)
+	}
+}
+
+func TestInPrefixes(t *testing.T) {
+	_, a, _ := net.ParseCIDR("203.0.113.0/24")
+	_, b, _ := net.ParseCIDR("198.51.100.7/32")
+	prefixes := []*net.IPNet{a, b}
+
+	for ip, want := range map[string]bool{
+		"203.0.113.9":  true,
+		"198.51.100.7": true,
+		"198.51.100.8": false,
+	} {
+		if got := inPrefixes(prefixes, net.ParseIP(ip)); got != want {
+			t.Errorf("inPrefixes(%s) = %t, want %t", ip, got, want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package bpf
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SetEgressSources replaces the prefixes the protected network may send
+// from (egress_src_allow). With CfgEgressSpoof set, the egress program
+// drops every other source.
+func (m *MapManager) SetEgressSources(cidrs []string) error {
+	keys := make(map[LPMKeyV4]bool, len(cidrs))
+	for _, cidr := range cidrs {
+		key, err := cidrToLPMKey(cidr)
+		if err != nil {
+			return err
+		}
+		keys[key] = true
+	}
+
+	current, err := m.egressSources()
+	if err != nil {
+		return err
+	}
+	w := NewBatchWriter[LPMKeyV4, uint8](m.objs.EgressSrc, 0)
+	for _, key := range current {
+		if !keys[key] {
+			w.Delete(key)
+		}
+	}
+	for key := range keys {
+		w.Update(key, 1)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing egress source prefixes: %w", err)
+	}
+	return nil
+}
+
+// EgressSources returns the prefixes the protected network may send from,
+// sorted.
+func (m *MapManager) EgressSources() ([]string, error) {
+	keys, err := m.egressSources()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, lpmKeyToCIDR(key))
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func (m *MapManager) egressSources() ([]LPMKeyV4, error) {
+	var (
+		key LPMKeyV4
+		val uint8
+		out []LPMKeyV4
+	)
+	defer m.timeIteration("egress_src_allow", time.Now())
+	iter := m.objs.EgressSrc.Iterate()
+	for iter.Next(&key, &val) {
+		out = append(out, key)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating egress source prefixes: %w", err)
+	}
+	return out, nil
+}
+
+// SetEgressAmpPorts replaces the outbound amplification limits: UDP
+// source port -> responses per second a single destination may receive,
+// 0 dropping every response. They apply while CfgEgressAmp is set.
+func (m *MapManager) SetEgressAmpPorts(ports map[uint16]uint64) error {
+	if len(ports) > EgressAmpPortsMax {
+		return fmt.Errorf("%d egress amplification ports exceed the limit of %d", len(ports), EgressAmpPortsMax)
+	}
+	current, err := m.EgressAmpPorts()
+	if err != nil {
+		return err
+	}
+	w := NewBatchWriter[uint16, uint64](m.objs.EgressAmp, 0)
+	for port := range current {
+		if _, keep := ports[port]; !keep {
+			w.Delete(port)
+		}
+	}
+	for port, pps := range ports {
+		w.Update(port, pps)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing egress amplification ports: %w", err)
+	}
+	return nil
+}
+
+// EgressAmpPorts returns the outbound amplification limits by UDP source
+// port.
+func (m *MapManager) EgressAmpPorts() (map[uint16]uint64, error) {
+	var (
+		port uint16
+		pps  uint64
+	)
+	out := make(map[uint16]uint64)
+	defer m.timeIteration("egress_amp_ports", time.Now())
+	iter := m.objs.EgressAmp.Iterate()
+	for iter.Next(&port, &pps) {
+		out[port] = pps
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating egress amplification ports: %w", err)
+	}
+	return out, nil
+}
+
+// ReadEgressStats returns the counters of the egress program, summed
+// over CPUs.
+func (m *MapManager) ReadEgressStats() (EgressStats, error) {
+	var perCPU []EgressStats
+	if err := m.objs.EgressStats.Lookup(uint32(0), &perCPU); err != nil {
+		return EgressStats{}, fmt.Errorf("reading egress stats: %w", err)
+	}
+	return mergeEgressStats(perCPU), nil
+}
+
+func mergeEgressStats(perCPU []EgressStats) EgressStats {
+	var s EgressStats
+	for _, c := range perCPU {
+		s.Packets += c.Packets
+		s.Bytes += c.Bytes
+		s.SpoofedDropped += c.SpoofedDropped
+		s.AmpDropped += c.AmpDropped
+		s.DroppedBytes += c.DroppedBytes
+	}
+	return s
+}
@@ -0,0 +1,14 @@
+package bpf
+
+import "testing"
+
+func TestMergeEgressStats(t *testing.T) {
+	got := mergeEgressStats([]EgressStats{
+		{Packets: 100, Bytes: 64000, SpoofedDropped: 3, DroppedBytes: 180},
+		{Packets: 50, Bytes: 70000, AmpDropped: 20, DroppedBytes: 28000},
+	})
+	want := EgressStats{Packets: 150, Bytes: 134000, SpoofedDropped: 3, AmpDropped: 20, DroppedBytes: 28180}
+	if got != want {
+		t.Errorf("merged = %+v, want %+v", got, want)
+	}
+}
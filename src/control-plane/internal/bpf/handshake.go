@@ -0,0 +1,85 @@
+package bpf
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+)
+
+// HandshakeFlooder is a source the data plane dropped TLS or QUIC
+// handshakes from for exceeding its per-second limit.
+type HandshakeFlooder struct {
+	IP       net.IP
+	Kind     string // "tls" or "quic"
+	Rate     uint32 // Handshakes in the current one-second window
+	Peak     uint32 // Most handshakes in any window
+	Dropped  uint64
+	LastDrop time.Time
+}
+
+// HandshakeKindName returns the name of a HANDSHAKE_* kind.
+func HandshakeKindName(kind uint8) string {
+	switch kind {
+	case HandshakeTLS:
+		return "tls"
+	case HandshakeQUIC:
+		return "quic"
+	default:
+		return fmt.Sprintf("unknown(%d)", kind)
+	}
+}
+
+// HandshakeFlooders returns the sources that had handshakes dropped
+// within the last since, highest peak rate first.
+func (m *MapManager) HandshakeFlooders(since time.Duration) ([]HandshakeFlooder, error) {
+	now, wallNow, err := monotonicNow()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		key   HandshakeKey
+		entry HandshakeRate
+		out   []HandshakeFlooder
+	)
+	defer m.timeIteration("handshake_rate", time.Now())
+	iter := m.objs.HandshakeRate.Iterate()
+	for iter.Next(&key, &entry) {
+		if f, ok := flooderFrom(key, entry, now, wallNow, since); ok {
+			out = append(out, f)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating handshake rate map: %w", err)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Peak != out[j].Peak {
+			return out[i].Peak > out[j].Peak
+		}
+		return out[i].IP.String() < out[j].IP.String()
+	})
+	return out, nil
+}
+
+// flooderFrom converts a handshake_rate entry read at now (bpf_ktime_get_ns
+// time, wallNow in wall time) and reports whether it had a drop within
+// since. Rate is 0 once the entry's window has passed.
+func flooderFrom(key HandshakeKey, e HandshakeRate, now uint64, wallNow time.Time, since time.Duration) (HandshakeFlooder, bool) {
+	if e.LastDropNS == 0 || e.LastDropNS > now || now-e.LastDropNS > uint64(since) {
+		return HandshakeFlooder{}, false
+	}
+	rate := e.Count
+	if now-e.WindowStartNS >= uint64(time.Second) {
+		rate = 0
+	}
+	return HandshakeFlooder{
+		IP:       U32BEToIP(key.SrcIP),
+		Kind:     HandshakeKindName(key.Kind),
+		Rate:     rate,
+		Peak:     e.Peak,
+		Dropped:  e.Dropped,
+		LastDrop: wallNow.Add(-time.Duration(now - e.LastDropNS)),
+	}, true
+}
@@ -0,0 +1,41 @@
+package bpf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFlooderFrom(t *testing.T) {
+	now := uint64(time.Hour)
+	wallNow := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) uint64 { return now - uint64(d) }
+	key := HandshakeKey{SrcIP: IPToU32BE([]byte{192, 0, 2, 1}), Kind: HandshakeQUIC}
+
+	tests := []struct {
+		name     string
+		entry    HandshakeRate
+		want     bool
+		wantRate uint32
+	}{
+		{"never dropped", HandshakeRate{WindowStartNS: ago(100 * time.Millisecond), Count: 5, Peak: 5}, false, 0},
+		{"dropping", HandshakeRate{WindowStartNS: ago(500 * time.Millisecond), LastDropNS: ago(time.Millisecond), Count: 300, Peak: 300, Dropped: 100}, true, 300},
+		{"window over", HandshakeRate{WindowStartNS: ago(2 * time.Second), LastDropNS: ago(time.Second), Count: 300, Peak: 400}, true, 0},
+		{"expired", HandshakeRate{LastDropNS: ago(10 * time.Minute), Peak: 300}, false, 0},
+	}
+	for _, tt := range tests {
+		f, ok := flooderFrom(key, tt.entry, now, wallNow, 5*time.Minute)
+		if ok != tt.want {
+			t.Errorf("%s: listed = %t, want %t", tt.name, ok, tt.want)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if f.IP.String() != "192.0.2.1" || f.Kind != "quic" || f.Rate != tt.wantRate || f.Peak != tt.entry.Peak {
+			t.Errorf("%s: flooder = %+v", tt.name, f)
+		}
+		if want := wallNow.Add(-time.Duration(now - tt.entry.LastDropNS)); !f.LastDrop.Equal(want) {
+			t.Errorf("%s: last drop = %v, want %v", tt.name, f.LastDrop, want)
+		}
+	}
+}
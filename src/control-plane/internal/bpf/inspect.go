@@ -0,0 +1,91 @@
+package bpf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/cilium/ebpf"
+)
+
+// SetInspectPorts replaces the destination ports whose traffic the XDP
+// program hands to the deep inspector, with InspectDNS or InspectTLS as
+// the parser each needs.
+func (m *MapManager) SetInspectPorts(ports map[uint16]uint8) error {
+	if len(ports) > InspectPortsMax {
+		return fmt.Errorf("%d inspection ports exceed the limit of %d", len(ports), InspectPortsMax)
+	}
+	var (
+		port uint16
+		kind uint8
+	)
+	var stale []uint16
+	iter := m.objs.InspectPorts.Iterate()
+	for iter.Next(&port, &kind) {
+		if _, keep := ports[port]; !keep {
+			stale = append(stale, port)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("iterating inspection ports: %w", err)
+	}
+
+	w := NewBatchWriter[uint16, uint8](m.objs.InspectPorts, 0)
+	for _, port := range stale {
+		w.Delete(port)
+	}
+	for port, kind := range ports {
+		w.Update(port, kind)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing inspection ports: %w", err)
+	}
+	return nil
+}
+
+// SetInspectSocket registers the AF_XDP socket fd that receives the
+// inspected traffic of an interface receive queue.
+func (m *MapManager) SetInspectSocket(queue uint32, fd int) error {
+	if err := m.objs.XSKMap.Put(queue, uint32(fd)); err != nil {
+		return fmt.Errorf("registering inspection socket for queue %d: %w", queue, err)
+	}
+	return nil
+}
+
+// RemoveInspectSocket unregisters the socket of a receive queue, whose
+// traffic then passes uninspected.
+func (m *MapManager) RemoveInspectSocket(queue uint32) error {
+	if err := m.objs.XSKMap.Delete(queue); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+		return fmt.Errorf("unregistering inspection socket for queue %d: %w", queue, err)
+	}
+	return nil
+}
+
+// ClearInspectSource stops the traffic of a source found clean from being
+// redirected to the inspector for ttl.
+func (m *MapManager) ClearInspectSource(ip net.IP, ttl time.Duration) error {
+	now, _, err := monotonicNow()
+	if err != nil {
+		return err
+	}
+	if err := m.objs.InspectClear.Put(IPToU32BE(ip), now+uint64(ttl)); err != nil {
+		return fmt.Errorf("clearing %s for inspection: %w", ip, err)
+	}
+	return nil
+}
+
+// ReadInspectStats returns the redirect counters of the XDP program,
+// summed over CPUs.
+func (m *MapManager) ReadInspectStats() (InspectStats, error) {
+	var perCPU []InspectStats
+	if err := m.objs.InspectStats.Lookup(uint32(0), &perCPU); err != nil {
+		return InspectStats{}, fmt.Errorf("reading inspection stats: %w", err)
+	}
+	var s InspectStats
+	for _, c := range perCPU {
+		s.Redirected += c.Redirected
+		s.NoSocket += c.NoSocket
+	}
+	return s, nil
+}
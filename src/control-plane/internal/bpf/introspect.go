@@ -0,0 +1,189 @@
+package bpf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cilium/ebpf"
+)
+
+// Path of the sysctl that enables BPF program run time accounting.
+const bpfStatsSysctl = "/proc/sys/kernel/bpf_stats_enabled"
+
+// ProgramStats describes the loaded XDP program as the kernel reports it.
+type ProgramStats struct {
+	ID     uint32
+	Name   string
+	Tag    string
+	Object string // Object file the program was loaded from
+
+	// Zero when the kernel does not report them: verified instructions
+	// need 5.16+, run counts need kernel.bpf_stats_enabled=1.
+	VerifiedInsns uint64
+	MemlockBytes  uint64
+	StatsEnabled  bool
+	RunCount      uint64
+	RunTime       time.Duration
+}
+
+// MapStats describes one loaded map and its memory use.
+type MapStats struct {
+	Name         string
+	ID           uint32
+	Type         string
+	KeySize      uint32
+	ValueSize    uint32
+	MaxEntries   uint32
+	Entries      int // -1 for maps without entries to count (ring buffers)
+	MemlockBytes uint64
+}
+
+// ProgramStats returns identity, verifier and runtime statistics for the
+// XDP program.
+func (l *Loader) ProgramStats() (*ProgramStats, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.objs == nil || l.objs.XDPProgram == nil {
+		return nil, fmt.Errorf("program not loaded")
+	}
+
+	prog := l.objs.XDPProgram
+	info, err := prog.Info()
+	if err != nil {
+		return nil, fmt.Errorf("reading program info: %w", err)
+	}
+	st := &ProgramStats{Name: info.Name, Tag: info.Tag, Object: l.objPath}
+	if id, ok := info.ID(); ok {
+		st.ID = uint32(id)
+	}
+
+	fields, err := readFDInfo(prog.FD())
+	if err != nil {
+		return nil, err
+	}
+	st.VerifiedInsns = fields.uint("verified_insns")
+	st.MemlockBytes = fields.uint("memlock")
+	st.RunCount = fields.uint("run_cnt")
+	st.RunTime = time.Duration(fields.uint("run_time_ns"))
+	st.StatsEnabled = bpfStatsEnabled()
+	return st, nil
+}
+
+// MapStats returns the definition, entry count and memory use of every
+// loaded map, sorted by name. Counting walks each hash and trie map's keys,
+// so it takes longer the fuller the maps are.
+func (l *Loader) MapStats() ([]MapStats, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.objs == nil {
+		return nil, fmt.Errorf("maps not loaded")
+	}
+
+	var out []MapStats
+	for name, m := range l.objs.maps() {
+		if m == nil {
+			continue
+		}
+		st := MapStats{
+			Name:       name,
+			Type:       m.Type().String(),
+			KeySize:    m.KeySize(),
+			ValueSize:  m.ValueSize(),
+			MaxEntries: m.MaxEntries(),
+		}
+		if info, err := m.Info(); err == nil {
+			if id, ok := info.ID(); ok {
+				st.ID = uint32(id)
+			}
+		}
+		fields, err := readFDInfo(m.FD())
+		if err != nil {
+			return nil, err
+		}
+		st.MemlockBytes = fields.uint("memlock")
+
+		n, err := countEntries(m)
+		if err != nil {
+			return nil, fmt.Errorf("counting entries of %s: %w", name, err)
+		}
+		st.Entries = n
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// countEntries returns the number of entries in m. Arrays always hold
+// MaxEntries; ring buffers have no entries and return -1.
+func countEntries(m *ebpf.Map) (int, error) {
+	switch m.Type() {
+	case ebpf.Array, ebpf.PerCPUArray:
+		return int(m.MaxEntries()), nil
+	case ebpf.RingBuf, ebpf.PerfEventArray:
+		return -1, nil
+	}
+
+	key := make([]byte, m.KeySize())
+	next := make([]byte, m.KeySize())
+	var prev interface{}
+	n := 0
+	// Concurrent deletes can restart a hash walk; stop at the map size.
+	for n < int(m.MaxEntries()) {
+		err := m.NextKey(prev, next)
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+		copy(key, next)
+		prev = key
+	}
+	return n, nil
+}
+
+// fdInfo holds the "key: value" lines of /proc/self/fdinfo for a BPF object.
+type fdInfo map[string]string
+
+func (f fdInfo) uint(key string) uint64 {
+	v, _ := strconv.ParseUint(f[key], 10, 64)
+	return v
+}
+
+func readFDInfo(fd int) (fdInfo, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/self/fdinfo/%d", fd))
+	if err != nil {
+		return nil, fmt.Errorf("reading fdinfo: %w", err)
+	}
+	defer f.Close()
+	return parseFDInfo(f)
+}
+
+func parseFDInfo(r io.Reader) (fdInfo, error) {
+	fields := make(fdInfo)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok {
+			fields[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("parsing fdinfo: %w", err)
+	}
+	return fields, nil
+}
+
+// bpfStatsEnabled reports whether the kernel accounts program run time.
+func bpfStatsEnabled() bool {
+	data, err := os.ReadFile(bpfStatsSysctl)
+	return err == nil && strings.TrimSpace(string(data)) == "1"
+}
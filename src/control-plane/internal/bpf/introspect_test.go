@@ -0,0 +1,34 @@
+package bpf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFDInfo(t *testing.T) {
+	fields, err := parseFDInfo(strings.NewReader(`pos:	0
+flags:	02000002
+prog_type:	6
+prog_tag:	a04f5eef06a7f555
+memlock:	4096
+prog_id:	42
+run_time_ns:	1500
+run_cnt:	3
+verified_insns:	1234
+`))
+	if err != nil {
+		t.Fatalf("parseFDInfo: %v", err)
+	}
+	if got := fields.uint("verified_insns"); got != 1234 {
+		t.Errorf("verified_insns = %d, want 1234", got)
+	}
+	if got := fields.uint("memlock"); got != 4096 {
+		t.Errorf("memlock = %d, want 4096", got)
+	}
+	if fields["prog_tag"] != "a04f5eef06a7f555" {
+		t.Errorf("prog_tag = %q", fields["prog_tag"])
+	}
+	if got := fields.uint("missing"); got != 0 {
+		t.Errorf("missing field = %d, want 0", got)
+	}
+}
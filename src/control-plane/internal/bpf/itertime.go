@@ -0,0 +1,69 @@
+package bpf
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// IterationStats summarizes how long walks over one map have taken. Large
+// LRU and LPM maps are walked entry by entry through syscalls, so these
+// grow with map occupancy and show up as control-plane CPU.
+type IterationStats struct {
+	Map   string
+	Count uint64
+	Last  time.Duration
+	Max   time.Duration
+	Total time.Duration
+	At    time.Time // When the last walk finished
+}
+
+// iterTimes records map walk durations by map name.
+type iterTimes struct {
+	mu    sync.Mutex
+	stats map[string]*IterationStats
+}
+
+func (t *iterTimes) record(name string, d time.Duration, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stats == nil {
+		t.stats = make(map[string]*IterationStats)
+	}
+	st, ok := t.stats[name]
+	if !ok {
+		st = &IterationStats{Map: name}
+		t.stats[name] = st
+	}
+	st.Count++
+	st.Last = d
+	st.Total += d
+	st.At = now
+	if d > st.Max {
+		st.Max = d
+	}
+}
+
+func (t *iterTimes) list() []IterationStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := make([]IterationStats, 0, len(t.stats))
+	for _, st := range t.stats {
+		result = append(result, *st)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Map < result[j].Map })
+	return result
+}
+
+// timeIteration records a walk over the named map that began at start.
+// Deferred at the top of a reader, it also counts walks that fail.
+func (m *MapManager) timeIteration(name string, start time.Time) {
+	now := time.Now()
+	m.iterTimes.record(name, now.Sub(start), now)
+}
+
+// IterationStats returns the walk durations of every map the control
+// plane has iterated, ordered by map name.
+func (m *MapManager) IterationStats() []IterationStats {
+	return m.iterTimes.list()
+}
@@ -0,0 +1,28 @@
+package bpf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIterTimes(t *testing.T) {
+	var it iterTimes
+	if got := it.list(); len(got) != 0 {
+		t.Fatalf("list() before any walk = %v", got)
+	}
+
+	now := time.Unix(1_700_000_000, 0)
+	it.record("top_talkers", 30*time.Millisecond, now)
+	it.record("conntrack_map", 5*time.Millisecond, now)
+	it.record("top_talkers", 10*time.Millisecond, now.Add(time.Second))
+
+	got := it.list()
+	if len(got) != 2 || got[0].Map != "conntrack_map" || got[1].Map != "top_talkers" {
+		t.Fatalf("list() = %+v, want conntrack_map then top_talkers", got)
+	}
+	tt := got[1]
+	if tt.Count != 2 || tt.Last != 10*time.Millisecond || tt.Max != 30*time.Millisecond ||
+		tt.Total != 40*time.Millisecond || !tt.At.Equal(now.Add(time.Second)) {
+		t.Errorf("top_talkers = %+v", tt)
+	}
+}
@@ -2,12 +2,16 @@
 package bpf
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"sort"
+	"sync"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/compat"
 	"go.uber.org/zap"
 )
 
@@ -15,21 +19,115 @@ import (
 type Objects struct {
 	// Programs
 	XDPProgram *ebpf.Program `ebpf:"xdp_ddos_scrubber"`
+	TCEgress   *ebpf.Program `ebpf:"tc_egress_scrubber"`
 
 	// Maps
 	ConfigMap     *ebpf.Map `ebpf:"config_map"`
 	BlacklistV4   *ebpf.Map `ebpf:"blacklist_v4"`
 	WhitelistV4   *ebpf.Map `ebpf:"whitelist_v4"`
+	TenantWL      *ebpf.Map `ebpf:"tenant_whitelist"`
+	TenantBL      *ebpf.Map `ebpf:"tenant_blacklist"`
 	RateLimitMap  *ebpf.Map `ebpf:"rate_limit_map"`
 	ConntrackMap  *ebpf.Map `ebpf:"conntrack_map"`
 	SYNCookieMap  *ebpf.Map `ebpf:"syn_cookie_map"`
+	SYNCookieDst  *ebpf.Map `ebpf:"syn_cookie_dst"`
+	TCPStrictDst  *ebpf.Map `ebpf:"tcp_strict_dst"`
 	AttackSigMap  *ebpf.Map `ebpf:"attack_sig_map"`
 	AttackSigCnt  *ebpf.Map `ebpf:"attack_sig_count"`
+	AttackSigHits *ebpf.Map `ebpf:"attack_sig_hits"`
 	StatsMap      *ebpf.Map `ebpf:"stats_map"`
 	Events        *ebpf.Map `ebpf:"events"`
 	GlobalRateMap *ebpf.Map `ebpf:"global_rate_map"`
 	GREtunnels    *ebpf.Map `ebpf:"gre_tunnels"`
 	PortProtoMap  *ebpf.Map `ebpf:"port_proto_map"`
+	ReputationMap *ebpf.Map `ebpf:"reputation_map"`
+	TopTalkers    *ebpf.Map `ebpf:"top_talkers"`
+	DstStats      *ebpf.Map `ebpf:"dst_stats"`
+	CaptureEvents *ebpf.Map `ebpf:"capture_events"`
+	ThreatIntel   *ebpf.Map `ebpf:"threat_intel_map"`
+	ThreatStats   *ebpf.Map `ebpf:"threat_intel_stats"`
+	GeoIPMap      *ebpf.Map `ebpf:"geoip_map"`
+	GeoIPPolicy   *ebpf.Map `ebpf:"geoip_policy"`
+	GeoIPRate     *ebpf.Map `ebpf:"geoip_country_rate"`
+	GeoIPBucket   *ebpf.Map `ebpf:"geoip_country_bucket"`
+	GeoIPStats    *ebpf.Map `ebpf:"geoip_country_stats"`
+	GeoIPAllow    *ebpf.Map `ebpf:"geoip_allow"`
+	ASNMap        *ebpf.Map `ebpf:"asn_map"`
+	ASNPolicy     *ebpf.Map `ebpf:"asn_policy"`
+	ConnLimitDst  *ebpf.Map `ebpf:"conn_limit_dst"`
+	ConnCount     *ebpf.Map `ebpf:"conn_count"`
+	PortScanMap   *ebpf.Map `ebpf:"port_scan_map"`
+	HandshakeRate *ebpf.Map `ebpf:"handshake_rate"`
+	DstPolicyMap  *ebpf.Map `ebpf:"dst_policy_map"`
+	DstEscalation *ebpf.Map `ebpf:"dst_escalation"`
+	RateClasses   *ebpf.Map `ebpf:"rate_classes"`
+	RateClassSrc  *ebpf.Map `ebpf:"rate_class_src"`
+	RateClassDst  *ebpf.Map `ebpf:"rate_class_dst"`
+	RateClassBkt  *ebpf.Map `ebpf:"rate_class_bucket"`
+	EgressSrc     *ebpf.Map `ebpf:"egress_src_allow"`
+	EgressAmp     *ebpf.Map `ebpf:"egress_amp_ports"`
+	EgressAmpBkt  *ebpf.Map `ebpf:"egress_amp_bucket"`
+	EgressStats   *ebpf.Map `ebpf:"egress_stats"`
+	XSKMap        *ebpf.Map `ebpf:"xsks_map"`
+	InspectPorts  *ebpf.Map `ebpf:"inspect_ports"`
+	InspectClear  *ebpf.Map `ebpf:"inspect_cleared"`
+	InspectStats  *ebpf.Map `ebpf:"inspect_stats"`
+}
+
+// maps returns the maps by their names in the object file.
+func (o *Objects) maps() map[string]*ebpf.Map {
+	return map[string]*ebpf.Map{
+		"config_map":           o.ConfigMap,
+		"blacklist_v4":         o.BlacklistV4,
+		"whitelist_v4":         o.WhitelistV4,
+		"tenant_whitelist":     o.TenantWL,
+		"tenant_blacklist":     o.TenantBL,
+		"rate_limit_map":       o.RateLimitMap,
+		"conntrack_map":        o.ConntrackMap,
+		"syn_cookie_map":       o.SYNCookieMap,
+		"syn_cookie_dst":       o.SYNCookieDst,
+		"tcp_strict_dst":       o.TCPStrictDst,
+		"attack_sig_map":       o.AttackSigMap,
+		"attack_sig_count":     o.AttackSigCnt,
+		"attack_sig_hits":      o.AttackSigHits,
+		"stats_map":            o.StatsMap,
+		"events":               o.Events,
+		"global_rate_map":      o.GlobalRateMap,
+		"gre_tunnels":          o.GREtunnels,
+		"port_proto_map":       o.PortProtoMap,
+		"reputation_map":       o.ReputationMap,
+		"top_talkers":          o.TopTalkers,
+		"dst_stats":            o.DstStats,
+		"capture_events":       o.CaptureEvents,
+		"threat_intel_map":     o.ThreatIntel,
+		"threat_intel_stats":   o.ThreatStats,
+		"geoip_map":            o.GeoIPMap,
+		"geoip_policy":         o.GeoIPPolicy,
+		"geoip_country_rate":   o.GeoIPRate,
+		"geoip_country_bucket": o.GeoIPBucket,
+		"geoip_country_stats":  o.GeoIPStats,
+		"geoip_allow":          o.GeoIPAllow,
+		"asn_map":              o.ASNMap,
+		"asn_policy":           o.ASNPolicy,
+		"conn_limit_dst":       o.ConnLimitDst,
+		"conn_count":           o.ConnCount,
+		"port_scan_map":        o.PortScanMap,
+		"handshake_rate":       o.HandshakeRate,
+		"dst_policy_map":       o.DstPolicyMap,
+		"dst_escalation":       o.DstEscalation,
+		"rate_classes":         o.RateClasses,
+		"rate_class_src":       o.RateClassSrc,
+		"rate_class_dst":       o.RateClassDst,
+		"rate_class_bucket":    o.RateClassBkt,
+		"egress_src_allow":     o.EgressSrc,
+		"egress_amp_ports":     o.EgressAmp,
+		"egress_amp_bucket":    o.EgressAmpBkt,
+		"egress_stats":         o.EgressStats,
+		"xsks_map":             o.XSKMap,
+		"inspect_ports":        o.InspectPorts,
+		"inspect_cleared":      o.InspectClear,
+		"inspect_stats":        o.InspectStats,
+	}
 }
 
 // Loader manages the lifecycle of BPF programs and maps.
@@ -39,18 +137,36 @@ type Loader struct {
 	objs    *Objects
 	xdpLink link.Link
 	iface   string
+
+	// TC egress attachment: a tcx link, or the clsact filter on
+	// egressIfindex (see tc.go).
+	egressMode    string
+	egressIface   string
+	egressLink    link.Link
+	egressIfindex int
+
+	// Verifier log lines kept from a failed load, and its diagnostics.
+	logLines int
+	lastErr  *LoadDiagnostics
+
+	// mu guards the program and object path against a concurrent Replace.
+	mu sync.Mutex
 }
 
 // NewLoader creates a new BPF loader.
 func NewLoader(log *zap.Logger, objPath string) *Loader {
 	return &Loader{
-		log:     log,
-		objPath: objPath,
+		log:      log,
+		objPath:  objPath,
+		logLines: DefaultVerifierLogLines,
 	}
 }
 
 // Load reads the compiled BPF object file and loads programs/maps into the kernel.
 func (l *Loader) Load() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	l.log.Info("loading BPF object", zap.String("path", l.objPath))
 
 	// Verify the object file exists
@@ -58,6 +174,13 @@ func (l *Loader) Load() error {
 		return fmt.Errorf("BPF object not found: %s", l.objPath)
 	}
 
+	// The event pipeline has no perf buffer fallback; fail with a clear
+	// message instead of an opaque map creation error.
+	if err := compat.Detect().RingBuf; errors.Is(err, ebpf.ErrNotSupported) {
+		return fmt.Errorf("kernel %s lacks BPF ring buffer support (requires 5.8+); run 'scrubber doctor'",
+			compat.Detect().Kernel)
+	}
+
 	spec, err := ebpf.LoadCollectionSpec(l.objPath)
 	if err != nil {
 		return fmt.Errorf("loading collection spec: %w", err)
@@ -69,13 +192,14 @@ func (l *Loader) Load() error {
 			PinPath: "", // No pinning by default
 		},
 	}); err != nil {
+		l.loadFailed(l.objPath, err)
 		return fmt.Errorf("loading and assigning BPF objects: %w", err)
 	}
 
 	l.objs = objs
 	l.log.Info("BPF objects loaded successfully",
 		zap.String("program", "xdp_ddos_scrubber"),
-		zap.Int("maps", 13),
+		zap.Int("maps", len(objs.maps())),
 	)
 
 	return nil
@@ -83,6 +207,9 @@ func (l *Loader) Load() error {
 
 // Attach attaches the XDP program to the given network interface.
 func (l *Loader) Attach(ifaceName string, flags link.XDPAttachFlags) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	if l.objs == nil || l.objs.XDPProgram == nil {
 		return fmt.Errorf("BPF program not loaded")
 	}
@@ -114,6 +241,13 @@ func (l *Loader) Attach(ifaceName string, flags link.XDPAttachFlags) error {
 
 // Detach removes the XDP program from the interface.
 func (l *Loader) Detach() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.detachLocked()
+}
+
+// detachLocked closes the XDP link. Caller must hold l.mu.
+func (l *Loader) detachLocked() error {
 	if l.xdpLink != nil {
 		l.log.Info("detaching XDP program", zap.String("interface", l.iface))
 		if err := l.xdpLink.Close(); err != nil {
@@ -126,21 +260,20 @@ func (l *Loader) Detach() error {
 
 // Close releases all BPF resources.
 func (l *Loader) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	var firstErr error
 
-	if err := l.Detach(); err != nil && firstErr == nil {
+	if err := l.detachLocked(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if err := l.detachEgressLocked(); err != nil && firstErr == nil {
 		firstErr = err
 	}
 
 	if l.objs != nil {
-		maps := []*ebpf.Map{
-			l.objs.ConfigMap, l.objs.BlacklistV4, l.objs.WhitelistV4,
-			l.objs.RateLimitMap, l.objs.ConntrackMap, l.objs.SYNCookieMap,
-			l.objs.AttackSigMap, l.objs.AttackSigCnt, l.objs.StatsMap,
-			l.objs.Events, l.objs.GlobalRateMap, l.objs.GREtunnels,
-			l.objs.PortProtoMap,
-		}
-		for _, m := range maps {
+		for _, m := range l.objs.maps() {
 			if m != nil {
 				m.Close()
 			}
@@ -148,12 +281,102 @@ func (l *Loader) Close() error {
 		if l.objs.XDPProgram != nil {
 			l.objs.XDPProgram.Close()
 		}
+		if l.objs.TCEgress != nil {
+			l.objs.TCEgress.Close()
+		}
 	}
 
 	l.log.Info("BPF resources released")
 	return firstErr
 }
 
+// Replace loads the XDP and TC egress programs from a new object file and
+// swaps them in for the running ones. The new program shares the maps already loaded, so ACLs,
+// conntrack state and counters carry over and every component keeps its
+// map handles; maps the new object adds are created empty. On an attached
+// interface the swap is a single atomic link update, so no packet passes
+// unfiltered. A map whose definition changed makes Replace fail, leaving
+// the running program in place. An egress program that cannot be swapped
+// keeps running the old code.
+func (l *Loader) Replace(objPath string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.objs == nil || l.objs.XDPProgram == nil {
+		return fmt.Errorf("BPF program not loaded")
+	}
+
+	spec, err := ebpf.LoadCollectionSpec(objPath)
+	if err != nil {
+		return fmt.Errorf("loading collection spec: %w", err)
+	}
+
+	replacements := make(map[string]*ebpf.Map)
+	var dropped []string
+	for name, m := range l.objs.maps() {
+		if m == nil {
+			continue
+		}
+		if _, ok := spec.Maps[name]; !ok {
+			dropped = append(dropped, name)
+			continue
+		}
+		replacements[name] = m
+	}
+	if len(dropped) > 0 {
+		sort.Strings(dropped)
+		l.log.Warn("replacement BPF object no longer uses some maps", zap.Strings("maps", dropped))
+	}
+
+	// Only the programs are assigned; the cloned map handles are released
+	// once they are loaded, the kernel keeps the maps alive through them.
+	var next struct {
+		XDPProgram *ebpf.Program `ebpf:"xdp_ddos_scrubber"`
+		TCEgress   *ebpf.Program `ebpf:"tc_egress_scrubber"`
+	}
+	if err := spec.LoadAndAssign(&next, &ebpf.CollectionOptions{MapReplacements: replacements}); err != nil {
+		l.loadFailed(objPath, err)
+		return fmt.Errorf("loading replacement BPF program: %w", err)
+	}
+
+	if l.xdpLink != nil {
+		if err := l.xdpLink.Update(next.XDPProgram); err != nil {
+			next.XDPProgram.Close()
+			next.TCEgress.Close()
+			return fmt.Errorf("swapping XDP program on %s: %w", l.iface, err)
+		}
+	}
+
+	old := l.objs.XDPProgram
+	l.objs.XDPProgram = next.XDPProgram
+	l.objPath = objPath
+	old.Close()
+
+	if err := l.replaceEgressLocked(next.TCEgress); err != nil {
+		l.log.Warn("swapping TC egress program failed, keeping the old one",
+			zap.String("interface", l.egressIface), zap.Error(err))
+		next.TCEgress.Close()
+	} else {
+		oldEgress := l.objs.TCEgress
+		l.objs.TCEgress = next.TCEgress
+		oldEgress.Close()
+	}
+
+	l.log.Info("XDP program replaced",
+		zap.String("path", objPath),
+		zap.String("interface", l.iface),
+		zap.Int("shared_maps", len(replacements)),
+	)
+	return nil
+}
+
+// ObjectPath returns the object file the running program was loaded from.
+func (l *Loader) ObjectPath() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.objPath
+}
+
 // Objects returns the loaded BPF objects for map operations.
 func (l *Loader) Objects() *Objects {
 	return l.objs
@@ -161,6 +384,8 @@ func (l *Loader) Objects() *Objects {
 
 // ProgramInfo returns information about the loaded XDP program.
 func (l *Loader) ProgramInfo() (*ebpf.ProgramInfo, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	if l.objs == nil || l.objs.XDPProgram == nil {
 		return nil, fmt.Errorf("program not loaded")
 	}
@@ -1,23 +1,58 @@
 package bpf
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/cilium/ebpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/compat"
 	"go.uber.org/zap"
 )
 
+// How often RunACLJanitor looks for expired blacklist entries.
+const aclJanitorInterval = 5 * time.Second
+
 // MapManager provides high-level operations on BPF maps.
 type MapManager struct {
 	log  *zap.Logger
 	objs *Objects
+
+	// Expiry of temporary blacklist entries, keyed by canonical CIDR. mu
+	// also serializes blacklist writes so the janitor cannot remove an
+	// entry that was just re-added.
+	mu              sync.Mutex
+	blacklistExpiry map[string]time.Time
+
+	// synDstMu keeps the SYN cookie scope flag in step with the
+	// protected destination map.
+	synDstMu sync.Mutex
+
+	// connLimitMu serializes replacing the per-prefix connection limits.
+	connLimitMu sync.Mutex
+
+	// Config map values as last written by the control plane, checked
+	// by the watchdog.
+	configMu     sync.Mutex
+	configValues map[uint32]uint64
+
+	// Durations of map walks, for runtime diagnostics
+	iterTimes iterTimes
 }
 
 // NewMapManager creates a new map manager.
 func NewMapManager(log *zap.Logger, objs *Objects) *MapManager {
-	return &MapManager{log: log, objs: objs}
+	return &MapManager{
+		log:             log,
+		objs:            objs,
+		blacklistExpiry: make(map[string]time.Time),
+		configValues:    make(map[uint32]uint64),
+	}
 }
 
 // --- Config Map ---
@@ -27,7 +62,13 @@ func (m *MapManager) SetConfig(key uint32, value uint64) error {
 	if key >= CfgMax {
 		return fmt.Errorf("config key %d out of range (max %d)", key, CfgMax)
 	}
-	return m.objs.ConfigMap.Update(key, value, ebpf.UpdateAny)
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+	if err := m.objs.ConfigMap.Update(key, value, ebpf.UpdateAny); err != nil {
+		return err
+	}
+	m.configValues[key] = value
+	return nil
 }
 
 // GetConfig reads a configuration value from the config map.
@@ -41,16 +82,35 @@ func (m *MapManager) GetConfig(key uint32) (uint64, error) {
 
 // --- Blacklist/Whitelist ---
 
-// AddBlacklistCIDR adds a CIDR prefix to the blacklist.
+// AddBlacklistCIDR adds a CIDR prefix to the blacklist permanently.
 func (m *MapManager) AddBlacklistCIDR(cidr string, reason uint32) error {
+	return m.AddBlacklistCIDRWithTTL(cidr, reason, 0)
+}
+
+// AddBlacklistCIDRWithTTL adds a CIDR prefix to the blacklist, to be
+// removed by RunACLJanitor after ttl. A zero ttl makes the entry permanent.
+// Re-adding an existing prefix replaces its expiry and reason but keeps its
+// hit counters.
+func (m *MapManager) AddBlacklistCIDRWithTTL(cidr string, reason uint32, ttl time.Duration) error {
 	key, err := cidrToLPMKey(cidr)
 	if err != nil {
 		return err
 	}
-	if err := m.objs.BlacklistV4.Update(key, reason, ebpf.UpdateAny); err != nil {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := putACL(m.objs.BlacklistV4, key, reason); err != nil {
 		return fmt.Errorf("adding blacklist entry %s: %w", cidr, err)
 	}
-	m.log.Debug("blacklist entry added", zap.String("cidr", cidr), zap.Uint32("reason", reason))
+	canonical := lpmKeyToCIDR(key)
+	if ttl > 0 {
+		m.blacklistExpiry[canonical] = time.Now().Add(ttl)
+	} else {
+		delete(m.blacklistExpiry, canonical)
+	}
+	m.log.Debug("blacklist entry added",
+		zap.String("cidr", cidr), zap.Uint32("reason", reason), zap.Duration("ttl", ttl))
 	return nil
 }
 
@@ -60,6 +120,11 @@ func (m *MapManager) RemoveBlacklistCIDR(cidr string) error {
 	if err != nil {
 		return err
 	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.blacklistExpiry, lpmKeyToCIDR(key))
 	if err := m.objs.BlacklistV4.Delete(key); err != nil {
 		return fmt.Errorf("removing blacklist entry %s: %w", cidr, err)
 	}
@@ -67,14 +132,171 @@ func (m *MapManager) RemoveBlacklistCIDR(cidr string) error {
 	return nil
 }
 
-// AddWhitelistCIDR adds a CIDR prefix to the whitelist.
+// AddBlacklistCIDRs adds prefixes to the blacklist permanently, writing
+// them in batches. Prefixes that are invalid or fail to write are skipped
+// and reported in the returned error.
+func (m *MapManager) AddBlacklistCIDRs(cidrs []string, reason uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys, err := writeACL(m.objs.BlacklistV4, "blacklist", cidrs, reason)
+	for _, key := range keys {
+		delete(m.blacklistExpiry, lpmKeyToCIDR(key))
+	}
+	m.log.Debug("blacklist entries added", zap.Int("count", len(keys)))
+	return err
+}
+
+// AddWhitelistCIDRs adds prefixes to the whitelist, writing them in
+// batches. Prefixes that are invalid or fail to write are skipped and
+// reported in the returned error.
+func (m *MapManager) AddWhitelistCIDRs(cidrs []string) error {
+	keys, err := writeACL(m.objs.WhitelistV4, "whitelist", cidrs, 1)
+	m.log.Debug("whitelist entries added", zap.Int("count", len(keys)))
+	return err
+}
+
+// writeACL batch-writes cidrs with reason to an ACL map and returns the
+// keys written. Prefixes already in the map keep their hit counters, read
+// from one snapshot of the map taken before the batch.
+func writeACL(mp *ebpf.Map, list string, cidrs []string, reason uint32) ([]LPMKeyV4, error) {
+	var errs []error
+	existing := readACLEntries(mp)
+	failed := make(map[LPMKeyV4]bool)
+	w := NewBatchWriter[LPMKeyV4, ACLEntry](mp, 0)
+	w.OnError(func(key LPMKeyV4, err error) {
+		failed[key] = true
+		errs = append(errs, fmt.Errorf("adding %s entry %s: %w", list, lpmKeyToCIDR(key), err))
+	})
+
+	keys := make([]LPMKeyV4, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		key, err := cidrToLPMKey(cidr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		entry := existing[key]
+		entry.Reason = reason
+		w.Update(key, entry)
+		keys = append(keys, key)
+	}
+	w.Flush()
+
+	written := keys[:0]
+	for _, key := range keys {
+		if !failed[key] {
+			written = append(written, key)
+		}
+	}
+	return written, errors.Join(errs...)
+}
+
+// aclMap is the subset of *ebpf.Map used to write single ACL entries.
+type aclMap interface {
+	Lookup(key, valueOut interface{}) error
+	Update(key, value interface{}, flags ebpf.MapUpdateFlags) error
+}
+
+// putACL writes key with reason, keeping the hit counters of the entry for
+// exactly the same prefix, if any. A lookup in an LPM trie returns the
+// longest prefix containing the key, which may be a shorter one, so it is
+// only trusted once a create-only update has reported the key exists.
+func putACL(mp aclMap, key interface{}, reason uint32) error {
+	err := mp.Update(key, ACLEntry{Reason: reason}, ebpf.UpdateNoExist)
+	if !errors.Is(err, ebpf.ErrKeyExist) {
+		return err
+	}
+	var entry ACLEntry
+	if err := mp.Lookup(key, &entry); err != nil {
+		entry = ACLEntry{}
+	}
+	entry.Reason = reason
+	return mp.Update(key, entry, ebpf.UpdateAny)
+}
+
+// readACLEntries returns every entry of an ACL map by key. Entries it
+// fails to read are treated as absent, so their counters restart.
+func readACLEntries(mp *ebpf.Map) map[LPMKeyV4]ACLEntry {
+	var (
+		key   LPMKeyV4
+		entry ACLEntry
+	)
+	entries := make(map[LPMKeyV4]ACLEntry)
+	iter := mp.Iterate()
+	for iter.Next(&key, &entry) {
+		entries[key] = entry
+	}
+	return entries
+}
+
+// BlacklistExpiry returns the expiry of every temporary blacklist entry,
+// keyed by CIDR as returned by BlacklistEntries.
+func (m *MapManager) BlacklistExpiry() map[string]time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]time.Time, len(m.blacklistExpiry))
+	for cidr, t := range m.blacklistExpiry {
+		out[cidr] = t
+	}
+	return out
+}
+
+// RunACLJanitor removes temporary blacklist entries as they expire, until
+// the context is cancelled.
+func (m *MapManager) RunACLJanitor(ctx context.Context) {
+	ticker := time.NewTicker(aclJanitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.expireBlacklist(now)
+		}
+	}
+}
+
+// expireBlacklist removes the blacklist entries that expired by now.
+func (m *MapManager) expireBlacklist(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, cidr := range m.popExpiredLocked(now) {
+		key, err := cidrToLPMKey(cidr)
+		if err != nil {
+			continue
+		}
+		if err := m.objs.BlacklistV4.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			m.log.Warn("failed to remove expired blacklist entry", zap.String("cidr", cidr), zap.Error(err))
+			continue
+		}
+		m.log.Info("blacklist entry expired", zap.String("cidr", cidr))
+	}
+}
+
+// popExpiredLocked forgets the expiries reached by now and returns their
+// CIDRs in order. Caller must hold m.mu.
+func (m *MapManager) popExpiredLocked(now time.Time) []string {
+	var expired []string
+	for cidr, t := range m.blacklistExpiry {
+		if !now.Before(t) {
+			delete(m.blacklistExpiry, cidr)
+			expired = append(expired, cidr)
+		}
+	}
+	sort.Strings(expired)
+	return expired
+}
+
+// AddWhitelistCIDR adds a CIDR prefix to the whitelist. Re-adding an
+// existing prefix keeps its hit counters.
 func (m *MapManager) AddWhitelistCIDR(cidr string) error {
 	key, err := cidrToLPMKey(cidr)
 	if err != nil {
 		return err
 	}
-	var value uint32 = 1
-	if err := m.objs.WhitelistV4.Update(key, value, ebpf.UpdateAny); err != nil {
+	if err := putACL(m.objs.WhitelistV4, key, 1); err != nil {
 		return fmt.Errorf("adding whitelist entry %s: %w", cidr, err)
 	}
 	m.log.Debug("whitelist entry added", zap.String("cidr", cidr))
@@ -94,6 +316,192 @@ func (m *MapManager) RemoveWhitelistCIDR(cidr string) error {
 	return nil
 }
 
+// ACLRule is a blacklist or whitelist entry with its hit counters.
+type ACLRule struct {
+	Reason  uint32    // Drop reason (blacklist) or 1 (whitelist)
+	Hits    uint64    // Packets matched since the entry was added
+	LastHit time.Time // Zero if never hit
+}
+
+// BlacklistEntries returns every blacklisted prefix with its drop reason
+// and hit counters.
+func (m *MapManager) BlacklistEntries() (map[string]ACLRule, error) {
+	defer m.timeIteration("blacklist_v4", time.Now())
+	rules, err := readACL(m.objs.BlacklistV4)
+	if err != nil {
+		return nil, fmt.Errorf("iterating blacklist: %w", err)
+	}
+	return rules, nil
+}
+
+// WhitelistRules returns every whitelisted prefix with its hit counters.
+func (m *MapManager) WhitelistRules() (map[string]ACLRule, error) {
+	defer m.timeIteration("whitelist_v4", time.Now())
+	rules, err := readACL(m.objs.WhitelistV4)
+	if err != nil {
+		return nil, fmt.Errorf("iterating whitelist: %w", err)
+	}
+	return rules, nil
+}
+
+// WhitelistEntries returns every whitelisted prefix.
+func (m *MapManager) WhitelistEntries() ([]string, error) {
+	var (
+		key   LPMKeyV4
+		entry ACLEntry
+		cidrs []string
+	)
+	defer m.timeIteration("whitelist_v4", time.Now())
+	iter := m.objs.WhitelistV4.Iterate()
+	for iter.Next(&key, &entry) {
+		cidrs = append(cidrs, lpmKeyToCIDR(key))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating whitelist: %w", err)
+	}
+	return cidrs, nil
+}
+
+// readACL reads every entry of an ACL map, keyed by CIDR.
+func readACL(mp *ebpf.Map) (map[string]ACLRule, error) {
+	now, wallNow, err := monotonicNow()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		key   LPMKeyV4
+		entry ACLEntry
+	)
+	rules := make(map[string]ACLRule)
+	iter := mp.Iterate()
+	for iter.Next(&key, &entry) {
+		rules[lpmKeyToCIDR(key)] = aclRule(entry, now, wallNow)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// aclRule converts an entry read at now (bpf_ktime_get_ns time, wallNow in
+// wall time).
+func aclRule(e ACLEntry, now uint64, wallNow time.Time) ACLRule {
+	r := ACLRule{Reason: e.Reason, Hits: e.Hits}
+	switch {
+	case e.LastHitNS == 0:
+	case e.LastHitNS > now:
+		r.LastHit = wallNow
+	default:
+		r.LastHit = wallNow.Add(-time.Duration(now - e.LastHitNS))
+	}
+	return r
+}
+
+// --- Threat Intelligence ---
+
+// ThreatIntelEntries returns every threat intel prefix with its entry.
+func (m *MapManager) ThreatIntelEntries() (map[string]ThreatIntelEntry, error) {
+	var (
+		key   LPMKeyV4
+		entry ThreatIntelEntry
+	)
+	entries := make(map[string]ThreatIntelEntry)
+	defer m.timeIteration("threat_intel_map", time.Now())
+	iter := m.objs.ThreatIntel.Iterate()
+	for iter.Next(&key, &entry) {
+		entries[lpmKeyToCIDR(key)] = entry
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating threat intel: %w", err)
+	}
+	return entries, nil
+}
+
+// SetThreatIntelEntry adds or replaces the threat intel entry for a prefix.
+func (m *MapManager) SetThreatIntelEntry(cidr string, entry ThreatIntelEntry) error {
+	key, err := cidrToLPMKey(cidr)
+	if err != nil {
+		return err
+	}
+	if err := m.objs.ThreatIntel.Update(key, entry, ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("setting threat intel entry %s: %w", cidr, err)
+	}
+	return nil
+}
+
+// RemoveThreatIntelEntry removes the threat intel entry for a prefix.
+func (m *MapManager) RemoveThreatIntelEntry(cidr string) error {
+	key, err := cidrToLPMKey(cidr)
+	if err != nil {
+		return err
+	}
+	if err := m.objs.ThreatIntel.Delete(key); err != nil {
+		return fmt.Errorf("removing threat intel entry %s: %w", cidr, err)
+	}
+	return nil
+}
+
+// LookupThreatIntel returns the entry the data plane applies to cidr: the
+// longest threat intel prefix covering all of it.
+func (m *MapManager) LookupThreatIntel(cidr string) (ThreatIntelEntry, bool, error) {
+	key, err := cidrToLPMKey(cidr)
+	if err != nil {
+		return ThreatIntelEntry{}, false, err
+	}
+	var entry ThreatIntelEntry
+	if err := m.objs.ThreatIntel.Lookup(key, &entry); err != nil {
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			return ThreatIntelEntry{}, false, nil
+		}
+		return ThreatIntelEntry{}, false, fmt.Errorf("looking up threat intel %s: %w", cidr, err)
+	}
+	return entry, true, nil
+}
+
+// ThreatIntelFeedStats returns the per-feed threat intel counters by
+// source ID, summed over CPUs. Feeds that never matched are left out.
+func (m *MapManager) ThreatIntelFeedStats() (map[uint8]ThreatIntelFeedStats, error) {
+	var (
+		key    uint32
+		perCPU []ThreatIntelFeedStats
+	)
+	out := make(map[uint8]ThreatIntelFeedStats)
+	iter := m.objs.ThreatStats.Iterate()
+	for iter.Next(&key, &perCPU) {
+		if s := mergeThreatIntelFeedStats(perCPU); s.Matched > 0 {
+			out[uint8(key)] = s
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating threat intel stats: %w", err)
+	}
+	return out, nil
+}
+
+// ResetThreatIntelFeedStats zeroes the counters of a source ID in
+// threat_intel_stats, so a feed later given the ID starts from zero.
+func (m *MapManager) ResetThreatIntelFeedStats(sourceID uint8) error {
+	ncpu, err := ebpf.PossibleCPU()
+	if err != nil {
+		return err
+	}
+	if err := m.objs.ThreatStats.Put(uint32(sourceID), make([]ThreatIntelFeedStats, ncpu)); err != nil {
+		return fmt.Errorf("resetting threat intel stats of source %d: %w", sourceID, err)
+	}
+	return nil
+}
+
+func mergeThreatIntelFeedStats(perCPU []ThreatIntelFeedStats) ThreatIntelFeedStats {
+	var s ThreatIntelFeedStats
+	for _, c := range perCPU {
+		s.Matched += c.Matched
+		s.Dropped += c.Dropped
+		s.RateLimited += c.RateLimited
+		s.Monitored += c.Monitored
+	}
+	return s
+}
+
 // --- Attack Signatures ---
 
 // SetAttackSignature sets an attack signature at the given index.
@@ -110,6 +518,143 @@ func (m *MapManager) SetAttackSignatureCount(count uint32) error {
 	return m.objs.AttackSigCnt.Update(key, count, ebpf.UpdateAny)
 }
 
+// GetAttackSignature reads the attack signature at the given index.
+func (m *MapManager) GetAttackSignature(index uint32) (AttackSig, error) {
+	var sig AttackSig
+	if index >= 256 {
+		return sig, fmt.Errorf("signature index %d out of range (max 255)", index)
+	}
+	if err := m.objs.AttackSigMap.Lookup(index, &sig); err != nil {
+		return sig, fmt.Errorf("reading signature %d: %w", index, err)
+	}
+	return sig, nil
+}
+
+// GetAttackSignatureCount returns the number of active signatures.
+func (m *MapManager) GetAttackSignatureCount() (uint32, error) {
+	var key uint32 = 0
+	var count uint32
+	if err := m.objs.AttackSigCnt.Lookup(key, &count); err != nil {
+		return 0, fmt.Errorf("reading signature count: %w", err)
+	}
+	return count, nil
+}
+
+// AttackSignatureHits returns the total match count for the signature at the
+// given index, aggregated across all CPUs.
+func (m *MapManager) AttackSignatureHits(index uint32) (uint64, error) {
+	var perCPU []uint64
+	if err := m.objs.AttackSigHits.Lookup(index, &perCPU); err != nil {
+		return 0, fmt.Errorf("reading signature %d hits: %w", index, err)
+	}
+	var total uint64
+	for _, v := range perCPU {
+		total += v
+	}
+	return total, nil
+}
+
+// --- Top Talkers ---
+
+// topTalkersBatch is the number of sources ReadTopTalkers reads per
+// BatchLookup call.
+const topTalkersBatch = 4096
+
+// talkerBatchMap is the subset of *ebpf.Map used by readTalkersBatch.
+type talkerBatchMap interface {
+	BatchLookup(cursor *ebpf.MapBatchCursor, keysOut, valuesOut interface{}, opts *ebpf.BatchOptions) (int, error)
+}
+
+// ReadTopTalkers returns the per-source counters from top_talkers keyed by
+// source IP (__be32), aggregated across all CPUs. The map is read in
+// batches when the kernel supports it, and iterated key by key otherwise.
+func (m *MapManager) ReadTopTalkers() (map[uint32]TalkerStats, error) {
+	defer m.timeIteration("top_talkers", time.Now())
+
+	if compat.Detect().HaveBatchOps() {
+		ncpu, err := ebpf.PossibleCPU()
+		if err == nil {
+			var result map[uint32]TalkerStats
+			result, err = readTalkersBatch(m.objs.TopTalkers, ncpu, topTalkersBatch)
+			if err == nil {
+				return result, nil
+			}
+		}
+		m.log.Debug("batch lookup failed, falling back to iteration", zap.Error(err))
+	}
+
+	var (
+		key    uint32
+		perCPU []TalkerStats
+	)
+	result := make(map[uint32]TalkerStats)
+	iter := m.objs.TopTalkers.Iterate()
+	for iter.Next(&key, &perCPU) {
+		result[key] = sumTalkerStats(perCPU)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating top talkers: %w", err)
+	}
+	return result, nil
+}
+
+// readTalkersBatch reads every entry of the per-CPU map tm, size keys per
+// syscall, and aggregates each entry's ncpu values.
+func readTalkersBatch(tm talkerBatchMap, ncpu, size int) (map[uint32]TalkerStats, error) {
+	keys := make([]uint32, size)
+	values := make([]TalkerStats, size*ncpu)
+	result := make(map[uint32]TalkerStats)
+
+	var cursor ebpf.MapBatchCursor
+	for {
+		n, err := tm.BatchLookup(&cursor, keys, values, nil)
+		for i := 0; i < n; i++ {
+			result[keys[i]] = sumTalkerStats(values[i*ncpu : (i+1)*ncpu])
+		}
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			return result, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("batch reading top talkers: %w", err)
+		}
+	}
+}
+
+// sumTalkerStats aggregates one source's per-CPU counters.
+func sumTalkerStats(perCPU []TalkerStats) TalkerStats {
+	var agg TalkerStats
+	for i := range perCPU {
+		agg.Packets += perCPU[i].Packets
+		agg.Bytes += perCPU[i].Bytes
+		agg.DroppedPackets += perCPU[i].DroppedPackets
+		agg.DroppedBytes += perCPU[i].DroppedBytes
+		if perCPU[i].LastSeenNS > agg.LastSeenNS {
+			agg.LastSeenNS = perCPU[i].LastSeenNS
+		}
+	}
+	return agg
+}
+
+// deleteConntrackKeys removes conntrack entries, using a single batch
+// operation when the kernel supports it and per-key deletes otherwise.
+func (m *MapManager) deleteConntrackKeys(keys []ConntrackKey) {
+	if len(keys) == 0 {
+		return
+	}
+	if compat.Detect().HaveBatchOps() {
+		_, err := m.objs.ConntrackMap.BatchDelete(keys, nil)
+		if err == nil {
+			return
+		}
+		// Entries evicted by the LRU meanwhile abort the batch; delete
+		// the remainder one by one.
+		m.log.Debug("batch delete failed, falling back to per-key deletes", zap.Error(err))
+	}
+	for _, k := range keys {
+		m.objs.ConntrackMap.Delete(k)
+	}
+}
+
 // --- SYN Cookie ---
 
 // UpdateSYNCookieSeeds sets new SYN cookie seeds.
@@ -123,6 +668,131 @@ func (m *MapManager) UpdateSYNCookieSeeds(current, previous uint32, updateNS uin
 	return m.objs.SYNCookieMap.Update(key, ctx, ebpf.UpdateAny)
 }
 
+// SYNCookieSeeds returns the current SYN cookie seed context.
+func (m *MapManager) SYNCookieSeeds() (SYNCookieCtx, error) {
+	var (
+		key uint32 = 0
+		ctx SYNCookieCtx
+	)
+	if err := m.objs.SYNCookieMap.Lookup(key, &ctx); err != nil {
+		return ctx, fmt.Errorf("reading SYN cookie seeds: %w", err)
+	}
+	return ctx, nil
+}
+
+// AddSYNCookieDest protects a destination prefix with SYN cookies. While
+// any prefix is protected, SYNs to other destinations get no cookies.
+func (m *MapManager) AddSYNCookieDest(cidr string) error {
+	key, err := cidrToLPMKey(cidr)
+	if err != nil {
+		return err
+	}
+
+	m.synDstMu.Lock()
+	defer m.synDstMu.Unlock()
+
+	var value uint8 = 1
+	if err := m.objs.SYNCookieDst.Update(key, value, ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("adding SYN cookie destination %s: %w", cidr, err)
+	}
+	m.log.Debug("SYN cookie destination added", zap.String("cidr", cidr))
+	return m.updateSYNCookieScopeLocked()
+}
+
+// RemoveSYNCookieDest stops protecting a destination prefix. Removing the
+// last one applies SYN cookies to every destination again.
+func (m *MapManager) RemoveSYNCookieDest(cidr string) error {
+	key, err := cidrToLPMKey(cidr)
+	if err != nil {
+		return err
+	}
+
+	m.synDstMu.Lock()
+	defer m.synDstMu.Unlock()
+
+	if err := m.objs.SYNCookieDst.Delete(key); err != nil {
+		return fmt.Errorf("removing SYN cookie destination %s: %w", cidr, err)
+	}
+	m.log.Debug("SYN cookie destination removed", zap.String("cidr", cidr))
+	return m.updateSYNCookieScopeLocked()
+}
+
+// SetSYNCookieDests replaces the protected destinations with cidrs.
+func (m *MapManager) SetSYNCookieDests(cidrs []string) error {
+	keys := make([]LPMKeyV4, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		key, err := cidrToLPMKey(cidr)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+
+	m.synDstMu.Lock()
+	defer m.synDstMu.Unlock()
+
+	current, err := m.synCookieDestKeys()
+	if err != nil {
+		return err
+	}
+	w := NewBatchWriter[LPMKeyV4, uint8](m.objs.SYNCookieDst, 0)
+	for _, key := range current {
+		w.Delete(key)
+	}
+	for _, key := range keys {
+		w.Update(key, 1)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("writing SYN cookie destinations: %w", err)
+	}
+	return m.updateSYNCookieScopeLocked()
+}
+
+// SYNCookieDests returns the protected destination prefixes.
+func (m *MapManager) SYNCookieDests() ([]string, error) {
+	keys, err := m.synCookieDestKeys()
+	if err != nil {
+		return nil, err
+	}
+	cidrs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		cidrs = append(cidrs, lpmKeyToCIDR(key))
+	}
+	sort.Strings(cidrs)
+	return cidrs, nil
+}
+
+func (m *MapManager) synCookieDestKeys() ([]LPMKeyV4, error) {
+	var (
+		key   LPMKeyV4
+		value uint8
+		keys  []LPMKeyV4
+	)
+	defer m.timeIteration("syn_cookie_dst", time.Now())
+	iter := m.objs.SYNCookieDst.Iterate()
+	for iter.Next(&key, &value) {
+		keys = append(keys, key)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating SYN cookie destinations: %w", err)
+	}
+	return keys, nil
+}
+
+// updateSYNCookieScopeLocked scopes SYN cookies to the protected
+// destinations while there are any. Caller must hold m.synDstMu.
+func (m *MapManager) updateSYNCookieScopeLocked() error {
+	keys, err := m.synCookieDestKeys()
+	if err != nil {
+		return err
+	}
+	var scoped uint64
+	if len(keys) > 0 {
+		scoped = 1
+	}
+	return m.SetConfig(CfgSYNCookieScoped, scoped)
+}
+
 // --- Statistics ---
 
 // ReadStats reads and aggregates per-CPU global statistics.
@@ -172,6 +842,17 @@ func (m *MapManager) ReadStats() (*GlobalStats, error) {
 		agg.NTPMonlistBlocked += perCPU[i].NTPMonlistBlocked
 		agg.TCPStateViolations += perCPU[i].TCPStateViolations
 		agg.PortScanDetected += perCPU[i].PortScanDetected
+		agg.RxTCPSYNPackets += perCPU[i].RxTCPSYNPackets
+		agg.RxUDPPackets += perCPU[i].RxUDPPackets
+		agg.RxICMPPackets += perCPU[i].RxICMPPackets
+		agg.RxDNSPackets += perCPU[i].RxDNSPackets
+		agg.ASNDropped += perCPU[i].ASNDropped
+		agg.ConnLimitDropped += perCPU[i].ConnLimitDropped
+		agg.EventsLost += perCPU[i].EventsLost
+		agg.TLSHandshakes += perCPU[i].TLSHandshakes
+		agg.QUICInitials += perCPU[i].QUICInitials
+		agg.TLSFloodDropped += perCPU[i].TLSFloodDropped
+		agg.QUICFloodDropped += perCPU[i].QUICFloodDropped
 	}
 
 	return agg, nil
@@ -197,6 +878,33 @@ func (m *MapManager) AddGRETunnel(cidr string, tunnelEndpoint net.IP) error {
 	return m.objs.GREtunnels.Update(key, endpointBE, ebpf.UpdateAny)
 }
 
+// RemoveGRETunnel removes the tunnel for a destination prefix.
+func (m *MapManager) RemoveGRETunnel(cidr string) error {
+	key, err := cidrToLPMKey(cidr)
+	if err != nil {
+		return err
+	}
+	if err := m.objs.GREtunnels.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+		return fmt.Errorf("removing GRE tunnel %s: %w", cidr, err)
+	}
+	return nil
+}
+
+// SetReinjection turns GRE re-injection of clean traffic on or off.
+// localIP is the outer source address of the encapsulated packets.
+func (m *MapManager) SetReinjection(enabled bool, localIP net.IP) error {
+	if enabled {
+		if localIP.To4() == nil {
+			return fmt.Errorf("GRE local address %v is not IPv4", localIP)
+		}
+		if err := m.SetConfig(CfgGRELocalIP, uint64(IPToU32BE(localIP))); err != nil {
+			return err
+		}
+		return m.SetConfig(CfgDiversionEnable, 1)
+	}
+	return m.SetConfig(CfgDiversionEnable, 0)
+}
+
 // --- Conntrack ---
 
 // ConntrackCount returns the approximate number of conntrack entries.
@@ -206,6 +914,7 @@ func (m *MapManager) ConntrackCount() (int, error) {
 		value []ConntrackEntry // per-CPU slice
 		count int
 	)
+	defer m.timeIteration("conntrack_map", time.Now())
 	iter := m.objs.ConntrackMap.Iterate()
 	for iter.Next(&key, &value) {
 		count++
@@ -213,6 +922,138 @@ func (m *MapManager) ConntrackCount() (int, error) {
 	return count, iter.Err()
 }
 
+// ConntrackFilter selects conntrack entries. Unset fields match everything.
+type ConntrackFilter struct {
+	SrcNet   *net.IPNet
+	DstNet   *net.IPNet
+	Protocol uint8  // 0 = any
+	State    *uint8 // nil = any
+}
+
+// ConntrackFlow is a conntrack entry with its per-CPU copies merged:
+// counters are summed and state is taken from the most recent copy.
+type ConntrackFlow struct {
+	Key   ConntrackKey
+	Entry ConntrackEntry
+}
+
+// ConntrackList returns up to limit flows matching f, starting at offset,
+// and the total number of matching flows. Flows are ordered by their key
+// so pages stay stable between calls. A limit of 0 returns all remaining
+// flows.
+func (m *MapManager) ConntrackList(f ConntrackFilter, offset, limit int) ([]ConntrackFlow, int, error) {
+	var (
+		key   ConntrackKey
+		value []ConntrackEntry // per-CPU slice
+		flows []ConntrackFlow
+	)
+
+	defer m.timeIteration("conntrack_map", time.Now())
+	iter := m.objs.ConntrackMap.Iterate()
+	for iter.Next(&key, &value) {
+		if !f.match(key) {
+			continue
+		}
+		flow := ConntrackFlow{Key: key, Entry: mergeConntrackEntries(value)}
+		if f.State != nil && flow.Entry.State != *f.State {
+			continue
+		}
+		flows = append(flows, flow)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterating conntrack: %w", err)
+	}
+
+	sort.Slice(flows, func(i, j int) bool {
+		return conntrackKeyLess(flows[i].Key, flows[j].Key)
+	})
+
+	total := len(flows)
+	if offset >= total {
+		return []ConntrackFlow{}, total, nil
+	}
+	flows = flows[offset:]
+	if limit > 0 && limit < len(flows) {
+		flows = flows[:limit]
+	}
+	return flows, total, nil
+}
+
+func (f ConntrackFilter) match(k ConntrackKey) bool {
+	if f.Protocol != 0 && k.Protocol != f.Protocol {
+		return false
+	}
+	if f.SrcNet != nil && !f.SrcNet.Contains(U32BEToIP(k.SrcIP)) {
+		return false
+	}
+	if f.DstNet != nil && !f.DstNet.Contains(U32BEToIP(k.DstIP)) {
+		return false
+	}
+	return true
+}
+
+// mergeConntrackEntries folds the per-CPU copies of a conntrack entry.
+func mergeConntrackEntries(perCPU []ConntrackEntry) ConntrackEntry {
+	var agg ConntrackEntry
+	for _, e := range perCPU {
+		if e.LastSeenNS >= agg.LastSeenNS {
+			agg.LastSeenNS = e.LastSeenNS
+			agg.State = e.State
+			agg.TCPWindowScale = e.TCPWindowScale
+			agg.SeqExpected = e.SeqExpected
+		}
+		agg.PacketsFwd += e.PacketsFwd
+		agg.PacketsRev += e.PacketsRev
+		agg.BytesFwd += e.BytesFwd
+		agg.BytesRev += e.BytesRev
+		agg.Flags |= e.Flags
+		if sum := int(agg.ViolationCount) + int(e.ViolationCount); sum > 255 {
+			agg.ViolationCount = 255
+		} else {
+			agg.ViolationCount = uint8(sum)
+		}
+	}
+	return agg
+}
+
+func conntrackKeyLess(a, b ConntrackKey) bool {
+	if a.SrcIP != b.SrcIP {
+		return a.SrcIP < b.SrcIP
+	}
+	if a.DstIP != b.DstIP {
+		return a.DstIP < b.DstIP
+	}
+	if a.SrcPort != b.SrcPort {
+		return a.SrcPort < b.SrcPort
+	}
+	if a.DstPort != b.DstPort {
+		return a.DstPort < b.DstPort
+	}
+	return a.Protocol < b.Protocol
+}
+
+// SetTCPStrictDst validates TCP towards ip strictly for ttl, even while
+// TCP state validation is off.
+func (m *MapManager) SetTCPStrictDst(ip net.IP, ttl time.Duration) error {
+	now, _, err := monotonicNow()
+	if err != nil {
+		return err
+	}
+	if err := m.objs.TCPStrictDst.Put(IPToU32BE(ip), now+uint64(ttl)); err != nil {
+		return fmt.Errorf("setting strict TCP validation for %s: %w", ip, err)
+	}
+	return nil
+}
+
+// RemoveTCPStrictDst ends strict TCP validation towards ip.
+func (m *MapManager) RemoveTCPStrictDst(ip net.IP) error {
+	err := m.objs.TCPStrictDst.Delete(IPToU32BE(ip))
+	if err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+		return fmt.Errorf("removing strict TCP validation for %s: %w", ip, err)
+	}
+	return nil
+}
+
 // FlushConntrack removes all entries from the conntrack map.
 func (m *MapManager) FlushConntrack() error {
 	var key ConntrackKey
@@ -227,9 +1068,7 @@ func (m *MapManager) FlushConntrack() error {
 		return fmt.Errorf("iterating conntrack: %w", err)
 	}
 
-	for _, k := range keys {
-		m.objs.ConntrackMap.Delete(k)
-	}
+	m.deleteConntrackKeys(keys)
 
 	m.log.Info("conntrack flushed", zap.Int("entries_removed", len(keys)))
 	return nil
@@ -258,6 +1097,11 @@ func cidrToLPMKey(cidr string) (LPMKeyV4, error) {
 	}, nil
 }
 
+// lpmKeyToCIDR formats an LPM key as a CIDR string accepted by cidrToLPMKey.
+func lpmKeyToCIDR(key LPMKeyV4) string {
+	return fmt.Sprintf("%s/%d", U32BEToIP(key.Addr), key.PrefixLen)
+}
+
 func hostToBE16(v uint16) uint16 {
 	var buf [2]byte
 	binary.BigEndian.PutUint16(buf[:], v)
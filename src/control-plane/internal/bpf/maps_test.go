@@ -0,0 +1,303 @@
+package bpf
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+)
+
+func TestConntrackStateName(t *testing.T) {
+	for s := uint8(CTStateNew); s <= CTStateRST; s++ {
+		name := ConntrackStateName(s)
+		got, ok := ParseConntrackState(name)
+		if !ok || got != s {
+			t.Errorf("ParseConntrackState(%q) = %d, %v; want %d", name, got, ok, s)
+		}
+	}
+	if got := ConntrackStateName(42); got != "unknown(42)" {
+		t.Errorf("ConntrackStateName(42) = %s, want unknown(42)", got)
+	}
+	if _, ok := ParseConntrackState("bogus"); ok {
+		t.Error("ParseConntrackState(bogus) should fail")
+	}
+}
+
+func TestMergeConntrackEntries(t *testing.T) {
+	merged := mergeConntrackEntries([]ConntrackEntry{
+		{LastSeenNS: 100, PacketsFwd: 3, BytesFwd: 300, State: CTStateSYNSent, Flags: CTFlagSuspect},
+		{},
+		{LastSeenNS: 200, PacketsFwd: 2, PacketsRev: 4, BytesRev: 400, State: CTStateEstablished, ViolationCount: 250},
+		{LastSeenNS: 150, ViolationCount: 10},
+	})
+
+	if merged.LastSeenNS != 200 || merged.State != CTStateEstablished {
+		t.Errorf("state from latest copy: got last_seen=%d state=%d", merged.LastSeenNS, merged.State)
+	}
+	if merged.PacketsFwd != 5 || merged.PacketsRev != 4 {
+		t.Errorf("packets = %d/%d, want 5/4", merged.PacketsFwd, merged.PacketsRev)
+	}
+	if merged.BytesFwd != 300 || merged.BytesRev != 400 {
+		t.Errorf("bytes = %d/%d, want 300/400", merged.BytesFwd, merged.BytesRev)
+	}
+	if merged.Flags != CTFlagSuspect {
+		t.Errorf("flags = %#x, want %#x", merged.Flags, CTFlagSuspect)
+	}
+	if merged.ViolationCount != 255 {
+		t.Errorf("violations = %d, want saturated 255", merged.ViolationCount)
+	}
+}
+
+func TestConntrackFilterMatch(t *testing.T) {
+	_, src, _ := net.ParseCIDR("203.0.113.0/24")
+	key := ConntrackKey{
+		SrcIP:    IPToU32BE(net.ParseIP("203.0.113.7")),
+		DstIP:    IPToU32BE(net.ParseIP("192.0.2.1")),
+		Protocol: 6,
+	}
+
+	tests := []struct {
+		name string
+		f    ConntrackFilter
+		want bool
+	}{
+		{"empty", ConntrackFilter{}, true},
+		{"src match", ConntrackFilter{SrcNet: src}, true},
+		{"dst mismatch", ConntrackFilter{DstNet: src}, false},
+		{"proto match", ConntrackFilter{Protocol: 6}, true},
+		{"proto mismatch", ConntrackFilter{Protocol: 17}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.f.match(key); got != tt.want {
+			t.Errorf("%s: match() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLPMKeyToCIDR(t *testing.T) {
+	for _, cidr := range []string{"10.0.0.0/8", "192.0.2.1/32", "0.0.0.0/0"} {
+		key, err := cidrToLPMKey(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := lpmKeyToCIDR(key); got != cidr {
+			t.Errorf("lpmKeyToCIDR(%s) = %s", cidr, got)
+		}
+	}
+	key, _ := cidrToLPMKey("198.51.100.7")
+	if got := lpmKeyToCIDR(key); got != "198.51.100.7/32" {
+		t.Errorf("single IP formatted as %s", got)
+	}
+}
+
+func TestPopExpiredBlacklist(t *testing.T) {
+	now := time.Now()
+	m := &MapManager{blacklistExpiry: map[string]time.Time{
+		"203.0.113.0/24":  now.Add(-time.Second),
+		"198.51.100.7/32": now,
+		"192.0.2.0/24":    now.Add(time.Minute),
+	}}
+
+	got := m.popExpiredLocked(now)
+	want := []string{"198.51.100.7/32", "203.0.113.0/24"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("expired = %v, want %v", got, want)
+	}
+	left := m.BlacklistExpiry()
+	if len(left) != 1 || !left["192.0.2.0/24"].Equal(now.Add(time.Minute)) {
+		t.Errorf("remaining expiries = %v", left)
+	}
+	if got := m.popExpiredLocked(now); len(got) != 0 {
+		t.Errorf("second pass expired %v", got)
+	}
+}
+
+func TestACLRule(t *testing.T) {
+	wall := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	const now = uint64(100 * time.Second)
+
+	never := aclRule(ACLEntry{Reason: DropBlacklist}, now, wall)
+	if never.Reason != DropBlacklist || never.Hits != 0 || !never.LastHit.IsZero() {
+		t.Errorf("never hit = %+v", never)
+	}
+	hit := aclRule(ACLEntry{Reason: DropBlacklist, Hits: 42, LastHitNS: now - uint64(30*time.Second)}, now, wall)
+	if hit.Hits != 42 || !hit.LastHit.Equal(wall.Add(-30*time.Second)) {
+		t.Errorf("hit = %+v", hit)
+	}
+	// A hit recorded after the clocks were read is clamped to wallNow.
+	if r := aclRule(ACLEntry{Hits: 1, LastHitNS: now + 1}, now, wall); !r.LastHit.Equal(wall) {
+		t.Errorf("future hit at %v, want %v", r.LastHit, wall)
+	}
+}
+
+// fakeLPM is an ACL map with LPM trie semantics: a lookup returns the
+// longest prefix containing the key's address, no longer than its own.
+type fakeLPM map[LPMKeyV4]ACLEntry
+
+func (f fakeLPM) Lookup(key, valueOut interface{}) error {
+	k := key.(LPMKeyV4)
+	best, found := LPMKeyV4{}, false
+	for e := range f {
+		mask := ^uint32(0) << (32 - e.PrefixLen)
+		if e.PrefixLen == 0 {
+			mask = 0
+		}
+		if e.PrefixLen <= k.PrefixLen && ipU32(k.Addr)&mask == ipU32(e.Addr)&mask &&
+			(!found || e.PrefixLen > best.PrefixLen) {
+			best, found = e, true
+		}
+	}
+	if !found {
+		return ebpf.ErrKeyNotExist
+	}
+	*valueOut.(*ACLEntry) = f[best]
+	return nil
+}
+
+func (f fakeLPM) Update(key, value interface{}, flags ebpf.MapUpdateFlags) error {
+	k := key.(LPMKeyV4)
+	if _, ok := f[k]; ok && flags == ebpf.UpdateNoExist {
+		return ebpf.ErrKeyExist
+	}
+	f[k] = value.(ACLEntry)
+	return nil
+}
+
+// ipU32 returns a big-endian address as a host-order integer.
+func ipU32(addr uint32) uint32 {
+	ip := U32BEToIP(addr).To4()
+	return uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+}
+
+func TestPutACLKeepsExactHits(t *testing.T) {
+	key := func(cidr string) LPMKeyV4 {
+		k, err := cidrToLPMKey(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	m := fakeLPM{key("10.0.0.0/8"): {Reason: DropBlacklist, Hits: 50, LastHitNS: 1000}}
+
+	// A nested prefix must not inherit the enclosing one's counters.
+	if err := putACL(m, key("10.1.0.0/16"), DropBlacklist); err != nil {
+		t.Fatal(err)
+	}
+	if e := m[key("10.1.0.0/16")]; e.Hits != 0 || e.LastHitNS != 0 {
+		t.Errorf("nested prefix = %+v, want zeroed counters", e)
+	}
+
+	// Re-adding the same prefix keeps its counters and takes the new reason.
+	if err := putACL(m, key("10.0.0.0/8"), DropReputation); err != nil {
+		t.Fatal(err)
+	}
+	if e := m[key("10.0.0.0/8")]; e.Reason != DropReputation || e.Hits != 50 || e.LastHitNS != 1000 {
+		t.Errorf("re-added prefix = %+v", e)
+	}
+}
+
+func TestMergeThreatIntelFeedStats(t *testing.T) {
+	got := mergeThreatIntelFeedStats([]ThreatIntelFeedStats{
+		{Matched: 10, Dropped: 8, Monitored: 2},
+		{Matched: 5, Dropped: 1, RateLimited: 4},
+	})
+	want := ThreatIntelFeedStats{Matched: 15, Dropped: 9, RateLimited: 4, Monitored: 2}
+	if got != want {
+		t.Errorf("merged = %+v, want %+v", got, want)
+	}
+}
+
+// fakeTalkerBatch serves BatchLookup from a fixed list of per-CPU entries,
+// ending with ErrKeyNotExist as the kernel does.
+type fakeTalkerBatch struct {
+	keys   []uint32
+	perCPU [][]TalkerStats
+	pos    int
+	calls  int
+}
+
+func (f *fakeTalkerBatch) BatchLookup(_ *ebpf.MapBatchCursor, keysOut, valuesOut interface{}, _ *ebpf.BatchOptions) (int, error) {
+	f.calls++
+	keys, values := keysOut.([]uint32), valuesOut.([]TalkerStats)
+	ncpu := len(values) / len(keys)
+	n := 0
+	for ; n < len(keys) && f.pos < len(f.keys); n, f.pos = n+1, f.pos+1 {
+		keys[n] = f.keys[f.pos]
+		copy(values[n*ncpu:(n+1)*ncpu], f.perCPU[f.pos])
+	}
+	if f.pos == len(f.keys) {
+		return n, ebpf.ErrKeyNotExist
+	}
+	return n, nil
+}
+
+func TestReadTalkersBatch(t *testing.T) {
+	f := &fakeTalkerBatch{}
+	for i := uint32(1); i <= 5; i++ {
+		f.keys = append(f.keys, i)
+		f.perCPU = append(f.perCPU, []TalkerStats{
+			{Packets: uint64(i), Bytes: 100, LastSeenNS: 7},
+			{Packets: uint64(i), DroppedPackets: 1, LastSeenNS: 9},
+		})
+	}
+
+	got, err := readTalkersBatch(f, 2, 2)
+	if err != nil {
+		t.Fatalf("readTalkersBatch() error: %v", err)
+	}
+	if f.calls != 3 {
+		t.Errorf("%d BatchLookup calls for 5 entries in batches of 2, want 3", f.calls)
+	}
+	if len(got) != 5 {
+		t.Fatalf("read %d sources, want 5", len(got))
+	}
+	want := TalkerStats{Packets: 8, Bytes: 100, DroppedPackets: 1, LastSeenNS: 9}
+	if got[4] != want {
+		t.Errorf("source 4 = %+v, want %+v summed across CPUs", got[4], want)
+	}
+}
+
+func TestTenantACLKey(t *testing.T) {
+	key, err := tenantACLKey(7, "192.0.2.0/24")
+	if err != nil {
+		t.Fatalf("tenantACLKey: %v", err)
+	}
+	if key.PrefixLen != 56 || key.ACLID != 7 || key.Addr != IPToU32BE(net.ParseIP("192.0.2.0")) {
+		t.Errorf("key = %+v, want the list id matched in full and a /24 source", key)
+	}
+	if got := tenantKeyToCIDR(key); got != "192.0.2.0/24" {
+		t.Errorf("tenantKeyToCIDR() = %s", got)
+	}
+
+	// Tenant keys keep the hit counters of the exact prefix like the
+	// global lists do.
+	mp := fakeTenantLPM{key: {Reason: 1, Hits: 9}}
+	if err := putACL(mp, key, 1); err != nil {
+		t.Fatalf("putACL: %v", err)
+	}
+	if mp[key].Hits != 9 {
+		t.Errorf("hits = %d after re-adding, want 9", mp[key].Hits)
+	}
+}
+
+// fakeTenantLPM is an exact-match stand-in for a tenant ACL map.
+type fakeTenantLPM map[TenantACLKey]ACLEntry
+
+func (f fakeTenantLPM) Lookup(key, valueOut interface{}) error {
+	e, ok := f[key.(TenantACLKey)]
+	if !ok {
+		return ebpf.ErrKeyNotExist
+	}
+	*valueOut.(*ACLEntry) = e
+	return nil
+}
+
+func (f fakeTenantLPM) Update(key, value interface{}, flags ebpf.MapUpdateFlags) error {
+	k := key.(TenantACLKey)
+	if _, ok := f[k]; ok && flags == ebpf.UpdateNoExist {
+		return ebpf.ErrKeyExist
+	}
+	f[k] = value.(ACLEntry)
+	return nil
+}
@@ -0,0 +1,76 @@
+package bpf
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Scanner is a source the data plane flagged as scanning ports.
+type Scanner struct {
+	IP            net.IP
+	DistinctPorts uint32 // Distinct ports in the current window
+	PeakPorts     uint32 // Most distinct ports in any window
+	WindowStart   time.Time
+	Detected      time.Time // Last time the threshold was crossed
+}
+
+// Scanners returns the sources flagged as port scanners within the last
+// since, most distinct ports first.
+func (m *MapManager) Scanners(since time.Duration) ([]Scanner, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return nil, fmt.Errorf("reading monotonic clock: %w", err)
+	}
+	now := uint64(ts.Nano())
+	wallNow := time.Now()
+
+	var (
+		src   uint32
+		entry PortScanEntry
+		out   []Scanner
+	)
+	defer m.timeIteration("port_scan_map", time.Now())
+	iter := m.objs.PortScanMap.Iterate()
+	for iter.Next(&src, &entry) {
+		if sc, ok := scannerFrom(src, entry, now, wallNow, since); ok {
+			out = append(out, sc)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating port scan map: %w", err)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].PeakPorts != out[j].PeakPorts {
+			return out[i].PeakPorts > out[j].PeakPorts
+		}
+		return out[i].IP.String() < out[j].IP.String()
+	})
+	return out, nil
+}
+
+// scannerFrom converts a port_scan_map entry read at now (bpf_ktime_get_ns
+// time, wallNow in wall time) and reports whether the source was flagged
+// within since.
+func scannerFrom(src uint32, e PortScanEntry, now uint64, wallNow time.Time, since time.Duration) (Scanner, bool) {
+	if e.DetectedNS == 0 || e.DetectedNS > now || now-e.DetectedNS > uint64(since) {
+		return Scanner{}, false
+	}
+	wall := func(ns uint64) time.Time {
+		if ns > now {
+			return wallNow
+		}
+		return wallNow.Add(-time.Duration(now - ns))
+	}
+	return Scanner{
+		IP:            U32BEToIP(src),
+		DistinctPorts: e.DistinctPorts,
+		PeakPorts:     e.PeakPorts,
+		WindowStart:   wall(e.WindowStartNS),
+		Detected:      wall(e.DetectedNS),
+	}, true
+}
@@ -0,0 +1,42 @@
+package bpf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScannerFrom(t *testing.T) {
+	now := uint64(time.Hour)
+	wallNow := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) uint64 { return now - uint64(d) }
+	src := IPToU32BE([]byte{192, 0, 2, 1})
+
+	tests := []struct {
+		name  string
+		entry PortScanEntry
+		want  bool
+	}{
+		{"never detected", PortScanEntry{WindowStartNS: ago(time.Second), DistinctPorts: 5}, false},
+		{"recent", PortScanEntry{WindowStartNS: ago(3 * time.Second), DetectedNS: ago(time.Second), DistinctPorts: 21, PeakPorts: 40}, true},
+		{"expired", PortScanEntry{DetectedNS: ago(10 * time.Minute)}, false},
+	}
+	for _, tt := range tests {
+		sc, ok := scannerFrom(src, tt.entry, now, wallNow, 5*time.Minute)
+		if ok != tt.want {
+			t.Errorf("%s: flagged = %t, want %t", tt.name, ok, tt.want)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if sc.IP.String() != "192.0.2.1" || sc.PeakPorts != 40 {
+			t.Errorf("%s: scanner = %+v", tt.name, sc)
+		}
+		if want := wallNow.Add(-time.Second); !sc.Detected.Equal(want) {
+			t.Errorf("%s: detected = %v, want %v", tt.name, sc.Detected, want)
+		}
+		if want := wallNow.Add(-3 * time.Second); !sc.WindowStart.Equal(want) {
+			t.Errorf("%s: window start = %v, want %v", tt.name, sc.WindowStart, want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package bpf
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"go.uber.org/zap"
+)
+
+// RateClassDir says which address of a packet a rate class binding
+// matches.
+type RateClassDir int
+
+const (
+	RateClassSource RateClassDir = iota // rate_class_src
+	RateClassDest                       // rate_class_dst
+)
+
+func (d RateClassDir) String() string {
+	if d == RateClassSource {
+		return "source"
+	}
+	return "destination"
+}
+
+// Rate class bucket kinds, matching RATE_CLASS_BUCKET_* in types.h.
+const (
+	rateClassBucketPPS = 0
+	rateClassBucketBPS = 1
+)
+
+// RateClassCounters are the packets seen and dropped by the pps and bps
+// buckets of a rate class, summed over CPUs.
+type RateClassCounters struct {
+	Packets        uint64
+	DroppedPackets uint64
+}
+
+func (m *MapManager) rateClassMap(dir RateClassDir) *ebpf.Map {
+	if dir == RateClassSource {
+		return m.objs.RateClassSrc
+	}
+	return m.objs.RateClassDst
+}
+
+// SetRateClass adds or replaces the limits of rate class id.
+func (m *MapManager) SetRateClass(id uint32, rc RateClass) error {
+	if id == 0 || id >= RateClassMax {
+		return fmt.Errorf("rate class id %d out of range 1-%d", id, RateClassMax-1)
+	}
+	if err := m.objs.RateClasses.Update(id, rc, ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("setting rate class %d: %w", id, err)
+	}
+	m.log.Debug("rate class set", zap.Uint32("id", id))
+	return nil
+}
+
+// RemoveRateClass removes rate class id and its token buckets, so an id
+// that is reused starts with full buckets.
+func (m *MapManager) RemoveRateClass(id uint32) error {
+	if err := m.objs.RateClasses.Delete(id); err != nil {
+		return fmt.Errorf("removing rate class %d: %w", id, err)
+	}
+	for _, kind := range []uint32{rateClassBucketPPS, rateClassBucketBPS} {
+		key := id*2 + kind
+		if err := m.objs.RateClassBkt.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return fmt.Errorf("removing rate class %d buckets: %w", id, err)
+		}
+	}
+	m.log.Debug("rate class removed", zap.Uint32("id", id))
+	return nil
+}
+
+// BindRateClass puts the sources or destinations in cidr in rate class
+// id. A source binding wins over a destination binding.
+func (m *MapManager) BindRateClass(dir RateClassDir, cidr string, id uint32) error {
+	key, err := cidrToLPMKey(cidr)
+	if err != nil {
+		return err
+	}
+	if err := m.rateClassMap(dir).Update(key, id, ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("binding %s %s to rate class %d: %w", dir, cidr, id, err)
+	}
+	return nil
+}
+
+// UnbindRateClass removes the rate class binding of cidr.
+func (m *MapManager) UnbindRateClass(dir RateClassDir, cidr string) error {
+	key, err := cidrToLPMKey(cidr)
+	if err != nil {
+		return err
+	}
+	if err := m.rateClassMap(dir).Delete(key); err != nil {
+		return fmt.Errorf("unbinding %s %s from rate class: %w", dir, cidr, err)
+	}
+	return nil
+}
+
+// RateClassCounters returns the packets counted by the buckets of rate
+// class id. Classes without a pps or bps limit have no buckets and count
+// nothing.
+func (m *MapManager) RateClassCounters(id uint32) (RateClassCounters, error) {
+	var pps, bps []RateLimiter
+	for _, b := range []struct {
+		kind uint32
+		out  *[]RateLimiter
+	}{{rateClassBucketPPS, &pps}, {rateClassBucketBPS, &bps}} {
+		key := id*2 + b.kind
+		if err := m.objs.RateClassBkt.Lookup(key, b.out); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return RateClassCounters{}, fmt.Errorf("reading rate class %d buckets: %w", id, err)
+		}
+	}
+	return mergeRateClassBuckets(pps, bps), nil
+}
+
+// mergeRateClassBuckets sums the per-CPU buckets of a class. Every packet
+// of the class goes through the pps bucket first, and only those it lets
+// through reach the bps bucket.
+func mergeRateClassBuckets(pps, bps []RateLimiter) RateClassCounters {
+	var c RateClassCounters
+	var ppsPackets, bpsPackets uint64
+	for _, rl := range pps {
+		ppsPackets += rl.TotalPackets
+		c.DroppedPackets += rl.DroppedPackets
+	}
+	for _, rl := range bps {
+		bpsPackets += rl.TotalPackets
+		c.DroppedPackets += rl.DroppedPackets
+	}
+	c.Packets = ppsPackets
+	if len(pps) == 0 {
+		c.Packets = bpsPackets
+	}
+	return c
+}
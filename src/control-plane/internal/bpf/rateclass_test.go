@@ -0,0 +1,25 @@
+package bpf
+
+import "testing"
+
+func TestMergeRateClassBuckets(t *testing.T) {
+	pps := []RateLimiter{
+		{TotalPackets: 700, DroppedPackets: 100},
+		{TotalPackets: 300, DroppedPackets: 50},
+	}
+	bps := []RateLimiter{
+		{TotalPackets: 600, DroppedPackets: 20},
+		{TotalPackets: 250, DroppedPackets: 0},
+	}
+
+	// The bps bucket only sees what the pps bucket let through.
+	if c := mergeRateClassBuckets(pps, bps); c.Packets != 1000 || c.DroppedPackets != 170 {
+		t.Errorf("pps+bps = %+v, want 1000 packets, 170 dropped", c)
+	}
+	if c := mergeRateClassBuckets(nil, bps); c.Packets != 850 || c.DroppedPackets != 20 {
+		t.Errorf("bps only = %+v, want 850 packets, 20 dropped", c)
+	}
+	if c := mergeRateClassBuckets(nil, nil); c != (RateClassCounters{}) {
+		t.Errorf("no buckets = %+v", c)
+	}
+}
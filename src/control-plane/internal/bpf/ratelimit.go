@@ -0,0 +1,130 @@
+package bpf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+)
+
+// ErrNoRateLimiter reports a source without a token bucket in
+// rate_limit_map.
+var ErrNoRateLimiter = errors.New("no rate limiter for source")
+
+// SourceRate is the token bucket of one source in rate_limit_map. The map
+// is per-CPU, so each CPU keeps its own bucket for the source; they are
+// merged here.
+type SourceRate struct {
+	IP             net.IP
+	RatePPS        uint64 // Limit the buckets last ran at (per CPU)
+	BurstSize      uint64
+	Tokens         uint64 // Tokens left, summed over CPUs
+	CPUs           int    // CPUs holding a bucket for the source
+	TotalPackets   uint64
+	DroppedPackets uint64
+	LastRefill     time.Time // Latest refill on any CPU
+}
+
+// RateLimiters returns every source in rate_limit_map, most dropped
+// packets first.
+func (m *MapManager) RateLimiters() ([]SourceRate, error) {
+	now, wallNow, err := monotonicNow()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		src    uint32
+		perCPU []RateLimiter
+		out    []SourceRate
+	)
+	defer m.timeIteration("rate_limit_map", time.Now())
+	iter := m.objs.RateLimitMap.Iterate()
+	for iter.Next(&src, &perCPU) {
+		out = append(out, mergeRateLimiters(src, perCPU, now, wallNow))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rate limiters: %w", err)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].DroppedPackets != out[j].DroppedPackets {
+			return out[i].DroppedPackets > out[j].DroppedPackets
+		}
+		return out[i].IP.String() < out[j].IP.String()
+	})
+	return out, nil
+}
+
+// RateLimiter returns the token bucket of one source, or ErrNoRateLimiter.
+func (m *MapManager) RateLimiter(ip net.IP) (SourceRate, error) {
+	now, wallNow, err := monotonicNow()
+	if err != nil {
+		return SourceRate{}, err
+	}
+	src := IPToU32BE(ip)
+	var perCPU []RateLimiter
+	if err := m.objs.RateLimitMap.Lookup(src, &perCPU); err != nil {
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			return SourceRate{}, ErrNoRateLimiter
+		}
+		return SourceRate{}, fmt.Errorf("looking up rate limiter: %w", err)
+	}
+	return mergeRateLimiters(src, perCPU, now, wallNow), nil
+}
+
+// ResetRateLimiter removes the token bucket of a source, or returns
+// ErrNoRateLimiter. Its next packet starts a full bucket with zeroed
+// counters.
+func (m *MapManager) ResetRateLimiter(ip net.IP) error {
+	if err := m.objs.RateLimitMap.Delete(IPToU32BE(ip)); err != nil {
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			return ErrNoRateLimiter
+		}
+		return fmt.Errorf("deleting rate limiter: %w", err)
+	}
+	return nil
+}
+
+// mergeRateLimiters combines the per-CPU buckets of src read at now
+// (bpf_ktime_get_ns time, wallNow in wall time). CPUs that never saw the
+// source hold zeroed buckets and are skipped.
+func mergeRateLimiters(src uint32, perCPU []RateLimiter, now uint64, wallNow time.Time) SourceRate {
+	sr := SourceRate{IP: U32BEToIP(src)}
+	var lastNS uint64
+	for _, rl := range perCPU {
+		if rl.LastRefillNS == 0 {
+			continue
+		}
+		sr.CPUs++
+		sr.Tokens += rl.Tokens
+		sr.TotalPackets += rl.TotalPackets
+		sr.DroppedPackets += rl.DroppedPackets
+		if rl.LastRefillNS > lastNS {
+			lastNS = rl.LastRefillNS
+			sr.RatePPS = rl.RatePPS
+			sr.BurstSize = rl.BurstSize
+		}
+	}
+	switch {
+	case lastNS == 0:
+	case lastNS > now:
+		sr.LastRefill = wallNow
+	default:
+		sr.LastRefill = wallNow.Add(-time.Duration(now - lastNS))
+	}
+	return sr
+}
+
+// monotonicNow returns bpf_ktime_get_ns time and the matching wall time.
+func monotonicNow() (uint64, time.Time, error) {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return 0, time.Time{}, fmt.Errorf("reading monotonic clock: %w", err)
+	}
+	return uint64(ts.Nano()), time.Now(), nil
+}
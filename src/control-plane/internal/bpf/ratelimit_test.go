@@ -0,0 +1,38 @@
+package bpf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeRateLimiters(t *testing.T) {
+	now := uint64(time.Hour)
+	wallNow := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) uint64 { return now - uint64(d) }
+	src := IPToU32BE([]byte{198, 51, 100, 7})
+
+	perCPU := []RateLimiter{
+		{Tokens: 10, LastRefillNS: ago(3 * time.Second), RatePPS: 1000, BurstSize: 2000, TotalPackets: 5000, DroppedPackets: 1200},
+		{}, // CPU that never saw the source
+		{Tokens: 0, LastRefillNS: ago(time.Second), RatePPS: 500, BurstSize: 1000, TotalPackets: 3000, DroppedPackets: 800},
+	}
+	sr := mergeRateLimiters(src, perCPU, now, wallNow)
+
+	if sr.IP.String() != "198.51.100.7" || sr.CPUs != 2 {
+		t.Errorf("ip = %s, cpus = %d", sr.IP, sr.CPUs)
+	}
+	if sr.Tokens != 10 || sr.TotalPackets != 8000 || sr.DroppedPackets != 2000 {
+		t.Errorf("tokens = %d, total = %d, dropped = %d", sr.Tokens, sr.TotalPackets, sr.DroppedPackets)
+	}
+	// The rate comes from the most recently refilled bucket.
+	if sr.RatePPS != 500 || sr.BurstSize != 1000 {
+		t.Errorf("rate = %d, burst = %d, want 500 and 1000", sr.RatePPS, sr.BurstSize)
+	}
+	if want := wallNow.Add(-time.Second); !sr.LastRefill.Equal(want) {
+		t.Errorf("last refill = %v, want %v", sr.LastRefill, want)
+	}
+
+	if empty := mergeRateLimiters(src, make([]RateLimiter, 4), now, wallNow); empty.CPUs != 0 || !empty.LastRefill.IsZero() {
+		t.Errorf("empty buckets merged to %+v", empty)
+	}
+}
@@ -0,0 +1,282 @@
+package bpf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+// Ways the egress program can be attached.
+const (
+	EgressTCX    = "tcx"    // BPF link on the tcx egress hook (kernel 6.6+)
+	EgressClsact = "clsact" // Direct-action bpf filter on a clsact qdisc
+)
+
+// tc definitions from linux/pkt_sched.h and linux/pkt_cls.h, which
+// x/sys/unix does not carry.
+const (
+	tcaKind    = 1 // TCA_KIND
+	tcaOptions = 2 // TCA_OPTIONS
+
+	tcaBPFFD            = 6 // TCA_BPF_FD
+	tcaBPFName          = 7 // TCA_BPF_NAME
+	tcaBPFFlags         = 8 // TCA_BPF_FLAGS
+	tcaBPFFlagActDirect = 1 // TCA_BPF_FLAG_ACT_DIRECT
+
+	tcHClsact    = 0xFFFFFFF1 // TC_H_CLSACT
+	tcHMinEgress = 0xFFF3     // TC_H_MIN_EGRESS
+
+	sizeofTcMsg = 20
+)
+
+// The clsact filter is installed with a fixed priority and handle, so it
+// can be replaced and deleted again. The priority is unusual enough not to
+// clash with filters set up by hand.
+const (
+	egressFilterPrio   = 0xdd05
+	egressFilterHandle = 1
+)
+
+// tcMsg is struct tcmsg of linux/rtnetlink.h.
+type tcMsg struct {
+	Family  uint8
+	Ifindex int32
+	Handle  uint32
+	Parent  uint32
+	Info    uint32
+}
+
+func (t tcMsg) marshal() []byte {
+	b := make([]byte, sizeofTcMsg)
+	b[0] = t.Family
+	binary.NativeEndian.PutUint32(b[4:], uint32(t.Ifindex))
+	binary.NativeEndian.PutUint32(b[8:], t.Handle)
+	binary.NativeEndian.PutUint32(b[12:], t.Parent)
+	binary.NativeEndian.PutUint32(b[16:], t.Info)
+	return b
+}
+
+// rtAttr encodes a netlink attribute, padded to NLA_ALIGNTO.
+func rtAttr(typ uint16, payload []byte) []byte {
+	l := unix.SizeofRtAttr + len(payload)
+	b := make([]byte, (l+unix.NLA_ALIGNTO-1)&^(unix.NLA_ALIGNTO-1))
+	binary.NativeEndian.PutUint16(b[0:], uint16(l))
+	binary.NativeEndian.PutUint16(b[2:], typ)
+	copy(b[unix.SizeofRtAttr:], payload)
+	return b
+}
+
+func rtAttrU32(typ uint16, v uint32) []byte {
+	b := make([]byte, 4)
+	binary.NativeEndian.PutUint32(b, v)
+	return rtAttr(typ, b)
+}
+
+func rtAttrString(typ uint16, s string) []byte {
+	return rtAttr(typ, append([]byte(s), 0))
+}
+
+// nlMessage builds a netlink request of type typ from a tcmsg and its
+// attributes.
+func nlMessage(typ, flags uint16, tcm tcMsg, attrs ...[]byte) []byte {
+	body := tcm.marshal()
+	for _, a := range attrs {
+		body = append(body, a...)
+	}
+	b := make([]byte, unix.SizeofNlMsghdr, unix.SizeofNlMsghdr+len(body))
+	binary.NativeEndian.PutUint32(b[0:], uint32(unix.SizeofNlMsghdr+len(body)))
+	binary.NativeEndian.PutUint16(b[4:], typ)
+	binary.NativeEndian.PutUint16(b[6:], flags|unix.NLM_F_REQUEST|unix.NLM_F_ACK)
+	binary.NativeEndian.PutUint32(b[8:], 1) // Sequence; one request per socket
+	return append(b, body...)
+}
+
+// clsactQdiscMsg creates the clsact qdisc of the interface.
+func clsactQdiscMsg(ifindex int) []byte {
+	return nlMessage(unix.RTM_NEWQDISC, unix.NLM_F_CREATE|unix.NLM_F_EXCL, tcMsg{
+		Family:  unix.AF_UNSPEC,
+		Ifindex: int32(ifindex),
+		Handle:  tcHClsact & 0xFFFF0000,
+		Parent:  tcHClsact,
+	}, rtAttrString(tcaKind, "clsact"))
+}
+
+// egressFilterTcMsg addresses the egress filter of the interface.
+func egressFilterTcMsg(ifindex int) tcMsg {
+	return tcMsg{
+		Family:  unix.AF_UNSPEC,
+		Ifindex: int32(ifindex),
+		Handle:  egressFilterHandle,
+		Parent:  tcHClsact&0xFFFF0000 | tcHMinEgress,
+		Info:    egressFilterPrio<<16 | uint32(htons(unix.ETH_P_ALL)),
+	}
+}
+
+// egressFilterMsg installs prog as the direct-action egress filter,
+// replacing the one installed before.
+func egressFilterMsg(ifindex, progFD int, name string) []byte {
+	opts := append(rtAttrU32(tcaBPFFD, uint32(progFD)), rtAttrString(tcaBPFName, name)...)
+	opts = append(opts, rtAttrU32(tcaBPFFlags, tcaBPFFlagActDirect)...)
+	return nlMessage(unix.RTM_NEWTFILTER, unix.NLM_F_CREATE|unix.NLM_F_REPLACE,
+		egressFilterTcMsg(ifindex), rtAttrString(tcaKind, "bpf"), rtAttr(tcaOptions, opts))
+}
+
+// egressFilterDelMsg removes the egress filter.
+func egressFilterDelMsg(ifindex int) []byte {
+	return nlMessage(unix.RTM_DELTFILTER, 0, egressFilterTcMsg(ifindex), rtAttrString(tcaKind, "bpf"))
+}
+
+func htons(v uint16) uint16 {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	return binary.NativeEndian.Uint16(b[:])
+}
+
+// tcRequest sends a netlink request to the kernel and waits for its
+// acknowledgement.
+func tcRequest(msg []byte) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return fmt.Errorf("opening netlink socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	kernel := &unix.SockaddrNetlink{Family: unix.AF_NETLINK}
+	if err := unix.Sendto(fd, msg, 0, kernel); err != nil {
+		return fmt.Errorf("sending netlink request: %w", err)
+	}
+	buf := make([]byte, 4096)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return fmt.Errorf("receiving netlink reply: %w", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return fmt.Errorf("parsing netlink reply: %w", err)
+		}
+		for _, m := range msgs {
+			if m.Header.Type != unix.NLMSG_ERROR {
+				continue
+			}
+			if len(m.Data) < 4 {
+				return fmt.Errorf("short netlink error message")
+			}
+			if errno := int32(binary.NativeEndian.Uint32(m.Data)); errno != 0 {
+				return unix.Errno(-errno)
+			}
+			return nil
+		}
+	}
+}
+
+// AttachEgress attaches the TC egress program to the interface, next to
+// the XDP program on its ingress. Kernels with tcx (6.6+) get a BPF link;
+// older ones a direct-action bpf filter on the clsact qdisc, which is
+// created if the interface has none. It returns how the program was
+// attached.
+func (l *Loader) AttachEgress(ifaceName string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.objs == nil || l.objs.TCEgress == nil {
+		return "", fmt.Errorf("BPF program not loaded")
+	}
+	if l.egressMode != "" {
+		return "", fmt.Errorf("egress program already attached to %s", l.egressIface)
+	}
+
+	iface, err := net.InterfaceByName(ifaceName)
+	if err != nil {
+		return "", fmt.Errorf("finding interface %s: %w", ifaceName, err)
+	}
+
+	tcx, err := link.AttachTCX(link.TCXOptions{
+		Program:   l.objs.TCEgress,
+		Interface: iface.Index,
+		Attach:    ebpf.AttachTCXEgress,
+	})
+	switch {
+	case err == nil:
+		l.egressLink = tcx
+		l.egressMode = EgressTCX
+	case errors.Is(err, ebpf.ErrNotSupported):
+		if err := tcRequest(clsactQdiscMsg(iface.Index)); err != nil && !errors.Is(err, unix.EEXIST) {
+			return "", fmt.Errorf("adding clsact qdisc to %s: %w", ifaceName, err)
+		}
+		if err := tcRequest(egressFilterMsg(iface.Index, l.objs.TCEgress.FD(), "tc_egress_scrubber")); err != nil {
+			return "", fmt.Errorf("attaching egress filter to %s: %w", ifaceName, err)
+		}
+		l.egressIfindex = iface.Index
+		l.egressMode = EgressClsact
+	default:
+		return "", fmt.Errorf("attaching TC egress to %s: %w", ifaceName, err)
+	}
+	l.egressIface = ifaceName
+
+	l.log.Info("TC egress program attached",
+		zap.String("interface", ifaceName),
+		zap.String("mode", l.egressMode),
+	)
+	return l.egressMode, nil
+}
+
+// DetachEgress removes the TC egress program from the interface. A clsact
+// qdisc it created is left in place for other filters.
+func (l *Loader) DetachEgress() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.detachEgressLocked()
+}
+
+// detachEgressLocked removes the egress link or filter. Caller must hold
+// l.mu.
+func (l *Loader) detachEgressLocked() error {
+	switch l.egressMode {
+	case EgressTCX:
+		if err := l.egressLink.Close(); err != nil {
+			return fmt.Errorf("detaching TC egress: %w", err)
+		}
+		l.egressLink = nil
+	case EgressClsact:
+		if err := tcRequest(egressFilterDelMsg(l.egressIfindex)); err != nil && !errors.Is(err, unix.ENOENT) {
+			return fmt.Errorf("removing egress filter from %s: %w", l.egressIface, err)
+		}
+		l.egressIfindex = 0
+	default:
+		return nil
+	}
+	l.log.Info("TC egress program detached", zap.String("interface", l.egressIface))
+	l.egressMode = ""
+	return nil
+}
+
+// EgressAttachment returns how the egress program is attached, and to
+// which interface; empty when it is not.
+func (l *Loader) EgressAttachment() (mode, iface string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.egressMode == "" {
+		return "", ""
+	}
+	return l.egressMode, l.egressIface
+}
+
+// replaceEgressLocked swaps prog in for the attached egress program.
+// Caller must hold l.mu.
+func (l *Loader) replaceEgressLocked(prog *ebpf.Program) error {
+	switch l.egressMode {
+	case EgressTCX:
+		return l.egressLink.Update(prog)
+	case EgressClsact:
+		return tcRequest(egressFilterMsg(l.egressIfindex, prog.FD(), "tc_egress_scrubber"))
+	}
+	return nil
+}
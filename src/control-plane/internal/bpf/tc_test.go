@@ -0,0 +1,94 @@
+package bpf
+
+import (
+	"encoding/binary"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestEgressFilterMsg(t *testing.T) {
+	msgs, err := syscall.ParseNetlinkMessage(egressFilterMsg(7, 42, "tc_egress_scrubber"))
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("parsing: %v (%d messages)", err, len(msgs))
+	}
+	m := msgs[0]
+	if m.Header.Type != unix.RTM_NEWTFILTER {
+		t.Errorf("type = %d, want RTM_NEWTFILTER", m.Header.Type)
+	}
+	if want := uint16(unix.NLM_F_REQUEST | unix.NLM_F_ACK | unix.NLM_F_CREATE | unix.NLM_F_REPLACE); m.Header.Flags != want {
+		t.Errorf("flags = %#x, want %#x", m.Header.Flags, want)
+	}
+
+	tcm := m.Data[:sizeofTcMsg]
+	if ifindex := binary.NativeEndian.Uint32(tcm[4:]); ifindex != 7 {
+		t.Errorf("ifindex = %d, want 7", ifindex)
+	}
+	if parent := binary.NativeEndian.Uint32(tcm[12:]); parent != 0xFFFFFFF3 {
+		t.Errorf("parent = %#x, want clsact egress 0xfffffff3", parent)
+	}
+	info := binary.NativeEndian.Uint32(tcm[16:])
+	if prio := info >> 16; prio != egressFilterPrio {
+		t.Errorf("priority = %#x, want %#x", prio, egressFilterPrio)
+	}
+	var proto [2]byte
+	binary.NativeEndian.PutUint16(proto[:], uint16(info))
+	if binary.BigEndian.Uint16(proto[:]) != unix.ETH_P_ALL {
+		t.Errorf("protocol = %#x, want ETH_P_ALL in network order", proto)
+	}
+
+	attrs := parseAttrs(t, m.Data[sizeofTcMsg:])
+	if kind := string(attrs[tcaKind]); kind != "bpf\x00" {
+		t.Errorf("kind = %q, want bpf", kind)
+	}
+	opts := parseAttrs(t, attrs[tcaOptions])
+	if fd := binary.NativeEndian.Uint32(opts[tcaBPFFD]); fd != 42 {
+		t.Errorf("fd = %d, want 42", fd)
+	}
+	if name := string(opts[tcaBPFName]); name != "tc_egress_scrubber\x00" {
+		t.Errorf("name = %q", name)
+	}
+	if flags := binary.NativeEndian.Uint32(opts[tcaBPFFlags]); flags != tcaBPFFlagActDirect {
+		t.Errorf("flags = %d, want direct action", flags)
+	}
+}
+
+func TestClsactQdiscMsg(t *testing.T) {
+	msgs, err := syscall.ParseNetlinkMessage(clsactQdiscMsg(3))
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("parsing: %v (%d messages)", err, len(msgs))
+	}
+	m := msgs[0]
+	if m.Header.Type != unix.RTM_NEWQDISC || m.Header.Flags&unix.NLM_F_EXCL == 0 {
+		t.Errorf("header = %+v, want exclusive RTM_NEWQDISC", m.Header)
+	}
+	if handle := binary.NativeEndian.Uint32(m.Data[8:]); handle != 0xFFFF0000 {
+		t.Errorf("handle = %#x, want ffff:", handle)
+	}
+	if kind := string(parseAttrs(t, m.Data[sizeofTcMsg:])[tcaKind]); kind != "clsact\x00" {
+		t.Errorf("kind = %q, want clsact", kind)
+	}
+}
+
+// parseAttrs returns the netlink attributes in b by type.
+func parseAttrs(t *testing.T, b []byte) map[uint16][]byte {
+	t.Helper()
+	out := make(map[uint16][]byte)
+	for len(b) > 0 {
+		if len(b) < unix.SizeofRtAttr {
+			t.Fatalf("truncated attribute header")
+		}
+		l := int(binary.NativeEndian.Uint16(b[0:]))
+		if l < unix.SizeofRtAttr || l > len(b) {
+			t.Fatalf("attribute length %d out of range", l)
+		}
+		out[binary.NativeEndian.Uint16(b[2:])] = b[unix.SizeofRtAttr:l]
+		next := (l + unix.NLA_ALIGNTO - 1) &^ (unix.NLA_ALIGNTO - 1)
+		if next > len(b) {
+			next = len(b)
+		}
+		b = b[next:]
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package bpf
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"go.uber.org/zap"
+)
+
+// Tenant ACL lists, selected by name in the tenant ACL methods.
+const (
+	TenantWhitelist = "whitelist"
+	TenantBlacklist = "blacklist"
+)
+
+// tenantACLMap returns the map holding the named tenant list.
+func (m *MapManager) tenantACLMap(list string) (*ebpf.Map, error) {
+	switch list {
+	case TenantWhitelist:
+		return m.objs.TenantWL, nil
+	case TenantBlacklist:
+		return m.objs.TenantBL, nil
+	}
+	return nil, fmt.Errorf("unknown tenant ACL list %q", list)
+}
+
+// tenantACLKey returns the tenant_whitelist/tenant_blacklist key of a
+// source prefix or address in list id.
+func tenantACLKey(id uint32, cidr string) (TenantACLKey, error) {
+	k, err := cidrToLPMKey(cidr)
+	if err != nil {
+		return TenantACLKey{}, err
+	}
+	return TenantACLKey{PrefixLen: 32 + k.PrefixLen, ACLID: id, Addr: k.Addr}, nil
+}
+
+// tenantKeyToCIDR returns the source prefix of a tenant ACL key.
+func tenantKeyToCIDR(k TenantACLKey) string {
+	return lpmKeyToCIDR(LPMKeyV4{PrefixLen: k.PrefixLen - 32, Addr: k.Addr})
+}
+
+// AddTenantACL adds a source prefix to list (TenantWhitelist or
+// TenantBlacklist) id, which applies only to traffic towards the prefixes
+// whose dst_policy carries id. Re-adding an existing prefix keeps its hit
+// counters.
+func (m *MapManager) AddTenantACL(id uint32, list, cidr string, reason uint32) error {
+	mp, err := m.tenantACLMap(list)
+	if err != nil {
+		return err
+	}
+	key, err := tenantACLKey(id, cidr)
+	if err != nil {
+		return err
+	}
+	if err := putACL(mp, key, reason); err != nil {
+		return fmt.Errorf("adding tenant %s entry %s: %w", list, cidr, err)
+	}
+	m.log.Debug("tenant ACL entry added",
+		zap.Uint32("acl_id", id), zap.String("list", list), zap.String("cidr", cidr))
+	return nil
+}
+
+// RemoveTenantACL removes a source prefix from list id.
+func (m *MapManager) RemoveTenantACL(id uint32, list, cidr string) error {
+	mp, err := m.tenantACLMap(list)
+	if err != nil {
+		return err
+	}
+	key, err := tenantACLKey(id, cidr)
+	if err != nil {
+		return err
+	}
+	if err := mp.Delete(key); err != nil {
+		return fmt.Errorf("removing tenant %s entry %s: %w", list, cidr, err)
+	}
+	m.log.Debug("tenant ACL entry removed",
+		zap.Uint32("acl_id", id), zap.String("list", list), zap.String("cidr", cidr))
+	return nil
+}
+
+// TenantACL returns the entries of list id with their hit counters, keyed
+// by source CIDR.
+func (m *MapManager) TenantACL(id uint32, list string) (map[string]ACLRule, error) {
+	mp, err := m.tenantACLMap(list)
+	if err != nil {
+		return nil, err
+	}
+	now, wallNow, err := monotonicNow()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		key   TenantACLKey
+		entry ACLEntry
+	)
+	rules := make(map[string]ACLRule)
+	defer m.timeIteration("tenant_"+list, time.Now())
+	iter := mp.Iterate()
+	for iter.Next(&key, &entry) {
+		if key.ACLID == id {
+			rules[tenantKeyToCIDR(key)] = aclRule(entry, now, wallNow)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating tenant %s: %w", list, err)
+	}
+	return rules, nil
+}
@@ -33,6 +33,11 @@ const (
 	AttackProtoViolation = 13
 	AttackPayloadMatch   = 14
 	AttackThreatIntel    = 15
+	AttackASNBlock       = 16
+	AttackConnFlood      = 17
+	AttackPortScan       = 18
+	AttackTLSFlood       = 19
+	AttackQUICFlood      = 20
 )
 
 // Drop reason codes (matching types.h)
@@ -57,6 +62,13 @@ const (
 	DropTCPState       = 18
 	DropThreatIntel    = 19
 	DropEscalation     = 20
+	DropASN            = 21
+	DropGeoIPRate      = 22
+	DropConnLimit      = 23
+	DropTLSFlood       = 24
+	DropQUICFlood      = 25
+
+	DropReasonMax = 26 // Size of per-reason counter arrays
 )
 
 // Config keys (matching types.h CFG_* constants)
@@ -82,9 +94,94 @@ const (
 	CfgDNSValidMode     = 18
 	CfgTCPStateEnable   = 19
 	CfgAdaptiveRate     = 20
+	CfgCaptureMode      = 21
+	CfgCaptureSample    = 22
+	CfgCaptureSnaplen   = 23
+	CfgASNEnable        = 24
+	CfgSYNCookieScoped  = 25
+	CfgDiversionEnable  = 26
+	CfgGRELocalIP       = 27
+	CfgConnLimit        = 28
+	CfgPortScanAction   = 29
+	CfgPortScanThresh   = 30
+	CfgPortScanWindow   = 31
+	CfgEgressSpoof      = 32
+	CfgEgressAmp        = 33
+	CfgInspectEnable    = 34
+	CfgInspectSample    = 35
+	CfgInspectScore     = 36
+	CfgTLSHSRate        = 37
+	CfgQUICInitRate     = 38
 	CfgMax              = 64
 )
 
+// Packet capture modes (matching CAPTURE_* in types.h)
+const (
+	CaptureOff   = 0
+	CaptureDrops = 1
+	CaptureAll   = 2
+
+	CaptureSnaplenMax = 1518
+)
+
+// Port scan responses (matching PORT_SCAN_* in types.h)
+const (
+	PortScanOff   = 0
+	PortScanScore = 1
+	PortScanBlock = 2
+	PortScanEvent = 3
+)
+
+// Conntrack states (matching CT_STATE_* in types.h)
+const (
+	CTStateNew         = 0
+	CTStateSYNSent     = 1
+	CTStateSYNRecv     = 2
+	CTStateEstablished = 3
+	CTStateFINWait     = 4
+	CTStateClosed      = 5
+	CTStateTimeWait    = 6
+	CTStateRST         = 7
+)
+
+// Conntrack flags (matching CT_FLAG_* in types.h)
+const (
+	CTFlagSYNCookieVerified = 1 << 0
+	CTFlagWhitelisted       = 1 << 1
+	CTFlagSuspect           = 1 << 2
+	CTFlagReputationOK      = 1 << 3
+	CTFlagGeoIPChecked      = 1 << 4
+)
+
+var ctStateNames = []string{
+	CTStateNew:         "new",
+	CTStateSYNSent:     "syn_sent",
+	CTStateSYNRecv:     "syn_recv",
+	CTStateEstablished: "established",
+	CTStateFINWait:     "fin_wait",
+	CTStateClosed:      "closed",
+	CTStateTimeWait:    "time_wait",
+	CTStateRST:         "rst",
+}
+
+// ConntrackStateName returns the human-readable name of a conntrack state.
+func ConntrackStateName(s uint8) string {
+	if int(s) < len(ctStateNames) {
+		return ctStateNames[s]
+	}
+	return fmt.Sprintf("unknown(%d)", s)
+}
+
+// ParseConntrackState returns the conntrack state with the given name.
+func ParseConntrackState(name string) (uint8, bool) {
+	for i, n := range ctStateNames {
+		if n == name {
+			return uint8(i), true
+		}
+	}
+	return 0, false
+}
+
 // ConntrackKey matches struct conntrack_key in types.h.
 type ConntrackKey struct {
 	SrcIP    uint32 // __be32
@@ -147,6 +244,19 @@ type GlobalStats struct {
 	NTPMonlistBlocked     uint64
 	TCPStateViolations    uint64
 	PortScanDetected      uint64
+	// Per-protocol RX counters
+	RxTCPSYNPackets  uint64
+	RxUDPPackets     uint64
+	RxICMPPackets    uint64
+	RxDNSPackets     uint64
+	ASNDropped       uint64
+	ConnLimitDropped uint64
+	EventsLost       uint64 // Ring buffer full when emitting an event
+	// Handshake floods
+	TLSHandshakes    uint64
+	QUICInitials     uint64
+	TLSFloodDropped  uint64
+	QUICFloodDropped uint64
 }
 
 // Event matches struct event in types.h (ring buffer events).
@@ -174,6 +284,23 @@ type LPMKeyV4 struct {
 	Addr      uint32 // __be32
 }
 
+// TenantACLKey matches struct tenant_acl_key in types.h. PrefixLen counts
+// the 32 bits of ACLID as well as those of the source prefix.
+type TenantACLKey struct {
+	PrefixLen uint32
+	ACLID     uint32
+	Addr      uint32 // __be32
+}
+
+// ACLEntry matches struct acl_entry in types.h, the value of blacklist_v4
+// and whitelist_v4.
+type ACLEntry struct {
+	Reason    uint32 // Drop reason (blacklist) or 1 (whitelist)
+	Pad       uint32
+	Hits      uint64
+	LastHitNS uint64 // bpf_ktime_get_ns, 0 = never hit
+}
+
 // SYNCookieCtx matches struct syn_cookie_ctx in types.h.
 type SYNCookieCtx struct {
 	SeedCurrent  uint32
@@ -206,6 +333,147 @@ type RateLimiter struct {
 	DroppedPackets uint64
 }
 
+// TalkerStats matches struct talker_stats in types.h.
+type TalkerStats struct {
+	Packets        uint64
+	Bytes          uint64
+	DroppedPackets uint64
+	DroppedBytes   uint64
+	LastSeenNS     uint64
+}
+
+// DstStats matches struct dst_stats in types.h.
+type DstStats struct {
+	RxPackets      uint64
+	RxBytes        uint64
+	DroppedPackets uint64
+	DroppedBytes   uint64
+	DropReasons    [DropReasonMax]uint64 // Dropped packets by Drop* reason
+}
+
+// ThreatIntelEntry matches struct threat_intel_entry in types.h.
+type ThreatIntelEntry struct {
+	SourceID    uint8
+	ThreatType  uint8
+	Confidence  uint8
+	Action      uint8
+	LastUpdated uint32 // Unix seconds
+}
+
+// ThreatIntelFeedsMax is the size of threat_intel_stats, one slot per
+// source ID (matching types.h).
+const ThreatIntelFeedsMax = 256
+
+// ThreatIntelFeedStats matches struct threat_intel_feed_stats in types.h.
+type ThreatIntelFeedStats struct {
+	Matched     uint64
+	Dropped     uint64
+	RateLimited uint64
+	Monitored   uint64
+}
+
+// DstPolicy matches struct dst_policy in types.h: the data plane policy
+// of a protected destination prefix.
+type DstPolicy struct {
+	SYNRatePPS  uint64 // Per-source limits towards the prefix; 0 = global limit
+	UDPRatePPS  uint64
+	ICMPRatePPS uint64
+	MinLevel    uint32 // Escalation level the prefix is held at, at least
+
+	// Country allow-list in geoip_allow (0 = none) and the GeoIP action
+	// for sources from countries not on it
+	GeoAllowID     uint16
+	GeoAllowAction uint8
+	Pad            uint8
+
+	// Owner's lists in tenant_whitelist and tenant_blacklist (0 = none)
+	ACLID uint32
+	Pad2  uint32
+
+	// Traffic towards the prefix, counted by the data plane
+	RxPackets      uint64
+	RxBytes        uint64
+	DroppedPackets uint64
+	DroppedBytes   uint64
+}
+
+// GeoIPAllowKey matches struct geoip_allow_key in types.h.
+type GeoIPAllowKey struct {
+	ID          uint16
+	CountryCode uint16 // Packed: 'C'<<8|'N'
+}
+
+// RateClassMax matches RATE_CLASS_MAX in types.h: rate class ids run from
+// 1 to RateClassMax-1.
+const RateClassMax = 64
+
+// RateClass matches struct rate_class in types.h.
+type RateClass struct {
+	SYNRatePPS  uint64 // Per-source limits; 0 = global limit
+	UDPRatePPS  uint64
+	ICMPRatePPS uint64
+	PPS         uint64 // Limits on all traffic in the class; 0 = none
+	BPS         uint64
+}
+
+// EgressAmpPortsMax matches EGRESS_AMP_PORTS_MAX in types.h.
+const EgressAmpPortsMax = 64
+
+// EgressStats matches struct egress_stats in types.h.
+type EgressStats struct {
+	Packets        uint64
+	Bytes          uint64
+	SpoofedDropped uint64
+	AmpDropped     uint64
+	DroppedBytes   uint64
+}
+
+// Deep inspection limits and inspect_ports values (matching types.h)
+const (
+	InspectQueuesMax = 64
+	InspectPortsMax  = 64
+
+	InspectDNS = 1
+	InspectTLS = 2
+)
+
+// InspectStats matches struct inspect_stats in types.h.
+type InspectStats struct {
+	Redirected uint64
+	NoSocket   uint64
+}
+
+// Handshake kinds of handshake_rate keys (matching HANDSHAKE_* in types.h)
+const (
+	HandshakeTLS  = 0
+	HandshakeQUIC = 1
+)
+
+// HandshakeKey matches struct handshake_key in types.h.
+type HandshakeKey struct {
+	SrcIP uint32 // __be32
+	Kind  uint8
+	Pad   [3]uint8
+}
+
+// HandshakeRate matches struct handshake_rate in types.h.
+type HandshakeRate struct {
+	WindowStartNS uint64
+	LastDropNS    uint64
+	Dropped       uint64
+	Count         uint32
+	Peak          uint32
+}
+
+// PortScanEntry matches struct port_scan_entry in types.h.
+type PortScanEntry struct {
+	WindowStartNS uint64
+	DetectedNS    uint64
+	DistinctPorts uint32
+	PeakPorts     uint32
+	PortBitmap    [8]uint64
+}
+
 // Helper functions
 
 // IPToU32BE converts a net.IP to big-endian uint32.
@@ -276,6 +544,16 @@ func AttackTypeName(t uint8) string {
 		return "payload_match"
 	case AttackThreatIntel:
 		return "threat_intel"
+	case AttackASNBlock:
+		return "asn_block"
+	case AttackConnFlood:
+		return "conn_flood"
+	case AttackPortScan:
+		return "port_scan"
+	case AttackTLSFlood:
+		return "tls_flood"
+	case AttackQUICFlood:
+		return "quic_flood"
 	default:
 		return fmt.Sprintf("unknown(%d)", t)
 	}
@@ -324,6 +602,16 @@ func DropReasonName(r uint8) string {
 		return "threat_intel"
 	case DropEscalation:
 		return "escalation"
+	case DropASN:
+		return "asn"
+	case DropGeoIPRate:
+		return "geoip_rate"
+	case DropConnLimit:
+		return "conn_limit"
+	case DropTLSFlood:
+		return "tls_flood"
+	case DropQUICFlood:
+		return "quic_flood"
 	default:
 		return fmt.Sprintf("unknown(%d)", r)
 	}
@@ -77,6 +77,10 @@ func TestAttackTypeName(t *testing.T) {
 		{AttackMemcachedAmp, "memcached_amplification"},
 		{AttackFragment, "fragment"},
 		{AttackRSTFlood, "rst_flood"},
+		{AttackASNBlock, "asn_block"},
+		{AttackConnFlood, "conn_flood"},
+		{AttackPortScan, "port_scan"},
+		{AttackQUICFlood, "quic_flood"},
 		{255, "unknown(255)"},
 	}
 
@@ -98,6 +102,10 @@ func TestDropReasonName(t *testing.T) {
 		{DropSYNFlood, "syn_flood"},
 		{DropParseError, "parse_error"},
 		{DropFingerprint, "fingerprint"},
+		{DropASN, "asn"},
+		{DropGeoIPRate, "geoip_rate"},
+		{DropConnLimit, "conn_limit"},
+		{DropTLSFlood, "tls_flood"},
 		{200, "unknown(200)"},
 	}
 
@@ -0,0 +1,226 @@
+package bpf
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"syscall"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+)
+
+// ConfigTable is the config map as seen by the packages that write it
+// directly. *ebpf.Map satisfies it, as does the table returned by
+// MapManager.ConfigTable, which also records what was written so the
+// watchdog does not mistake it for drift.
+type ConfigTable interface {
+	Lookup(key, valueOut interface{}) error
+	Update(key, value interface{}, flags ebpf.MapUpdateFlags) error
+}
+
+// trackedConfig is a ConfigTable recording writes in the MapManager.
+type trackedConfig struct {
+	m *MapManager
+}
+
+// ConfigTable returns the config map for packages that take a
+// ConfigTable, with their writes recorded like those of SetConfig.
+func (m *MapManager) ConfigTable() ConfigTable {
+	return trackedConfig{m}
+}
+
+func (t trackedConfig) Lookup(key, valueOut interface{}) error {
+	return t.m.objs.ConfigMap.Lookup(key, valueOut)
+}
+
+func (t trackedConfig) Update(key, value interface{}, flags ebpf.MapUpdateFlags) error {
+	t.m.configMu.Lock()
+	defer t.m.configMu.Unlock()
+	if err := t.m.objs.ConfigMap.Update(key, value, flags); err != nil {
+		return err
+	}
+	// Read the value back rather than assume its type.
+	if k, ok := key.(uint32); ok {
+		var v uint64
+		if err := t.m.objs.ConfigMap.Lookup(k, &v); err == nil {
+			t.m.configValues[k] = v
+		}
+	}
+	return nil
+}
+
+// ConfigDrift is a config map entry that no longer holds the value the
+// control plane last wrote.
+type ConfigDrift struct {
+	Key  uint32 `json:"key"`
+	Name string `json:"name"`
+	Want uint64 `json:"want"`
+	Got  uint64 `json:"got"`
+}
+
+// ConfigDrift compares the config map with the values last written
+// through the MapManager, in key order.
+func (m *MapManager) ConfigDrift() ([]ConfigDrift, error) {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+
+	var drift []ConfigDrift
+	for key, want := range m.configValues {
+		var got uint64
+		if err := m.objs.ConfigMap.Lookup(key, &got); err != nil {
+			return nil, fmt.Errorf("reading config key %d: %w", key, err)
+		}
+		if got != want {
+			drift = append(drift, ConfigDrift{Key: key, Name: configKeyName(key), Want: want, Got: got})
+		}
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].Key < drift[j].Key })
+	return drift, nil
+}
+
+// RestoreConfig writes back the recorded value of each drifted key.
+func (m *MapManager) RestoreConfig(drift []ConfigDrift) error {
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+
+	for _, d := range drift {
+		want, ok := m.configValues[d.Key]
+		if !ok {
+			continue
+		}
+		if err := m.objs.ConfigMap.Update(d.Key, want, ebpf.UpdateAny); err != nil {
+			return fmt.Errorf("restoring config key %s: %w", d.Name, err)
+		}
+	}
+	return nil
+}
+
+func configKeyName(key uint32) string {
+	for _, k := range ConfigKeys {
+		if k.Key == key {
+			return k.Name
+		}
+	}
+	return fmt.Sprintf("key %d", key)
+}
+
+// AttachState is how the XDP program is attached, as the loader believes
+// and as the kernel reports it.
+type AttachState struct {
+	Interface string `json:"interface"`
+	// ProgramID is the program the loader runs.
+	ProgramID uint32 `json:"programId"`
+	// Linked is whether the loader holds an XDP link, LinkIfindex and
+	// LinkProgramID what the kernel reports for it; a link detached from
+	// outside reports ifindex 0.
+	Linked        bool   `json:"linked"`
+	LinkIfindex   uint32 `json:"linkIfindex"`
+	LinkProgramID uint32 `json:"linkProgramId"`
+	// InterfaceProgramID is the XDP program the interface runs (0 = none).
+	InterfaceProgramID uint32 `json:"interfaceProgramId"`
+}
+
+// Intact reports whether our program runs on the interface through our
+// link.
+func (s AttachState) Intact() bool {
+	return s.Linked && s.LinkIfindex != 0 && s.LinkProgramID == s.ProgramID &&
+		s.InterfaceProgramID == s.ProgramID
+}
+
+// AttachState returns the attachment of the XDP program as the kernel
+// reports it.
+func (l *Loader) AttachState() (AttachState, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	st := AttachState{Interface: l.iface}
+	if l.objs == nil || l.objs.XDPProgram == nil {
+		return st, fmt.Errorf("BPF program not loaded")
+	}
+	info, err := l.objs.XDPProgram.Info()
+	if err != nil {
+		return st, fmt.Errorf("program info: %w", err)
+	}
+	if id, ok := info.ID(); ok {
+		st.ProgramID = uint32(id)
+	}
+
+	if l.xdpLink != nil {
+		st.Linked = true
+		li, err := l.xdpLink.Info()
+		if err != nil {
+			return st, fmt.Errorf("XDP link info: %w", err)
+		}
+		st.LinkProgramID = uint32(li.Program)
+		if xdp := li.XDP(); xdp != nil {
+			st.LinkIfindex = xdp.Ifindex
+		}
+	}
+
+	if l.iface != "" {
+		iface, err := net.InterfaceByName(l.iface)
+		if err != nil {
+			return st, fmt.Errorf("finding interface %s: %w", l.iface, err)
+		}
+		if st.InterfaceProgramID, err = interfaceXDPProgram(iface.Index); err != nil {
+			return st, err
+		}
+	}
+	return st, nil
+}
+
+// interfaceXDPProgram asks the kernel over rtnetlink which XDP program
+// the interface runs.
+func interfaceXDPProgram(ifindex int) (uint32, error) {
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETLINK, syscall.AF_UNSPEC)
+	if err != nil {
+		return 0, fmt.Errorf("listing links: %w", err)
+	}
+	msgs, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return 0, fmt.Errorf("parsing links: %w", err)
+	}
+	for _, msg := range msgs {
+		if msg.Header.Type != syscall.RTM_NEWLINK || len(msg.Data) < syscall.SizeofIfInfomsg {
+			continue
+		}
+		ifi := (*syscall.IfInfomsg)(unsafe.Pointer(&msg.Data[0]))
+		if int(ifi.Index) != ifindex {
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(&msg)
+		if err != nil {
+			return 0, fmt.Errorf("parsing link attributes: %w", err)
+		}
+		for _, a := range attrs {
+			if a.Attr.Type == unix.IFLA_XDP {
+				return xdpProgramID(a.Value), nil
+			}
+		}
+		return 0, nil
+	}
+	return 0, fmt.Errorf("interface %d not found", ifindex)
+}
+
+// xdpProgramID returns IFLA_XDP_PROG_ID from the nested IFLA_XDP
+// attributes.
+func xdpProgramID(b []byte) uint32 {
+	for len(b) >= unix.SizeofRtAttr {
+		alen := int(*(*uint16)(unsafe.Pointer(&b[0])))
+		atype := *(*uint16)(unsafe.Pointer(&b[2]))
+		if alen < unix.SizeofRtAttr || alen > len(b) {
+			return 0
+		}
+		if atype == unix.IFLA_XDP_PROG_ID && alen >= unix.SizeofRtAttr+4 {
+			return *(*uint32)(unsafe.Pointer(&b[unix.SizeofRtAttr]))
+		}
+		next := (alen + unix.NLA_ALIGNTO - 1) &^ (unix.NLA_ALIGNTO - 1)
+		if next > len(b) {
+			return 0
+		}
+		b = b[next:]
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package bpf
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// rtattr encodes one netlink attribute, padded to 4 bytes.
+func rtattr(typ uint16, payload []byte) []byte {
+	b := make([]byte, unix.SizeofRtAttr, unix.SizeofRtAttr+len(payload)+3)
+	binary.NativeEndian.PutUint16(b[0:], uint16(unix.SizeofRtAttr+len(payload)))
+	binary.NativeEndian.PutUint16(b[2:], typ)
+	b = append(b, payload...)
+	for len(b)%unix.NLA_ALIGNTO != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func TestXDPProgramID(t *testing.T) {
+	id := make([]byte, 4)
+	binary.NativeEndian.PutUint32(id, 1234)
+
+	tests := []struct {
+		name string
+		b    []byte
+		want uint32
+	}{
+		{"empty", nil, 0},
+		{"attached mode only", rtattr(unix.IFLA_XDP_ATTACHED, []byte{1}), 0},
+		{"after attached mode", append(rtattr(unix.IFLA_XDP_ATTACHED, []byte{1}), rtattr(unix.IFLA_XDP_PROG_ID, id)...), 1234},
+		{"truncated", rtattr(unix.IFLA_XDP_PROG_ID, id)[:6], 0},
+	}
+	for _, tt := range tests {
+		if got := xdpProgramID(tt.b); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAttachStateIntact(t *testing.T) {
+	ok := AttachState{Interface: "eth0", ProgramID: 7, Linked: true, LinkIfindex: 2, LinkProgramID: 7, InterfaceProgramID: 7}
+	if !ok.Intact() {
+		t.Fatal("attached state not intact")
+	}
+	detached := ok
+	detached.LinkIfindex = 0
+	replaced := ok
+	replaced.InterfaceProgramID = 9
+	for name, st := range map[string]AttachState{"detached": detached, "replaced": replaced} {
+		if st.Intact() {
+			t.Errorf("%s state reported intact", name)
+		}
+	}
+}
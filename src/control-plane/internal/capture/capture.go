@@ -0,0 +1,619 @@
+// Package capture samples packets from the XDP program into rotating pcap
+// files for offline analysis of attack payloads.
+//
+// The data plane copies sampled packets (dropped only, or all traffic) onto
+// the capture_events perf buffer while CFG_CAPTURE_MODE is set. A capture
+// session is started on demand through the API or automatically when the
+// escalation level reaches Config.AutoLevel, and ends when stopped, when its
+// duration or packet budget is exhausted, or on de-escalation.
+package capture
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/perf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultDir             = "/var/lib/ddos-scrubber/pcap"
+	defaultSnaplen         = 256
+	defaultSampleRate      = 1
+	defaultMaxFileMB       = 100
+	defaultMaxFiles        = 10
+	defaultAutoDurationSec = 300
+
+	filePrefix = "capture-"
+	fileSuffix = ".pcap"
+
+	metaLen          = 24 // sizeof(struct capture_meta)
+	perCPUBufferSize = 1 << 20
+	readPollInterval = time.Second
+)
+
+// ErrActive is returned by Start when a capture is already running.
+var ErrActive = errors.New("capture already running")
+
+// Config controls packet capture.
+type Config struct {
+	Enabled         bool   `yaml:"enabled"`
+	Dir             string `yaml:"dir"`               // Where pcap files are written
+	Snaplen         uint32 `yaml:"snaplen"`           // Bytes kept per packet (max 1518)
+	SampleRate      uint32 `yaml:"sample_rate"`       // Capture 1 in N packets
+	MaxFileMB       int    `yaml:"max_file_mb"`       // Rotate files at this size
+	MaxFiles        int    `yaml:"max_files"`         // Oldest files beyond this are deleted
+	AutoLevel       string `yaml:"auto_level"`        // Escalation level that starts a capture; empty = never
+	AutoDurationSec uint64 `yaml:"auto_duration_sec"` // Length of automatic captures
+}
+
+// Validate checks the capture configuration.
+func (c Config) Validate() error {
+	if c.Snaplen > bpf.CaptureSnaplenMax {
+		return fmt.Errorf("snaplen %d exceeds maximum %d", c.Snaplen, bpf.CaptureSnaplenMax)
+	}
+	if c.MaxFileMB < 0 || c.MaxFiles < 0 {
+		return fmt.Errorf("max_file_mb and max_files must not be negative")
+	}
+	if c.AutoLevel != "" {
+		if _, ok := parseLevel(c.AutoLevel); !ok {
+			return fmt.Errorf("invalid auto_level %q (must be MEDIUM, HIGH, or CRITICAL)", c.AutoLevel)
+		}
+	}
+	return nil
+}
+
+// parseLevel maps an escalation level name to its value. LOW is rejected
+// since a capture triggered by it would never end.
+func parseLevel(s string) (escalation.Level, bool) {
+	for l := escalation.Medium; l <= escalation.Critical; l++ {
+		if strings.EqualFold(s, l.String()) {
+			return l, true
+		}
+	}
+	return 0, false
+}
+
+// Options describe a single capture session. Zero values fall back to the
+// configured defaults.
+type Options struct {
+	Mode       uint8         // bpf.CaptureDrops or bpf.CaptureAll
+	Duration   time.Duration // 0 = until stopped
+	MaxPackets uint64        // 0 = unlimited
+	SampleRate uint32
+	Snaplen    uint32
+	Reason     string
+}
+
+// Status reports the current or most recent capture session.
+type Status struct {
+	Active     bool      `json:"active"`
+	Mode       string    `json:"mode,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	StartedAt  time.Time `json:"startedAt,omitempty"`
+	StoppedAt  time.Time `json:"stoppedAt,omitempty"`
+	Deadline   time.Time `json:"deadline,omitempty"`
+	SampleRate uint32    `json:"sampleRate,omitempty"`
+	Snaplen    uint32    `json:"snaplen,omitempty"`
+	MaxPackets uint64    `json:"maxPackets,omitempty"`
+	Packets    uint64    `json:"packets"`
+	Bytes      uint64    `json:"bytes"`
+	Lost       uint64    `json:"lost"`
+	File       string    `json:"file,omitempty"`
+	Files      int       `json:"files"`
+}
+
+// FileInfo describes a capture file on disk.
+type FileInfo struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// ModeName returns the API name of a capture mode.
+func ModeName(mode uint8) string {
+	switch mode {
+	case bpf.CaptureDrops:
+		return "drops"
+	case bpf.CaptureAll:
+		return "all"
+	default:
+		return "off"
+	}
+}
+
+// ParseMode parses a capture mode name. An empty string selects drops.
+func ParseMode(s string) (uint8, bool) {
+	switch s {
+	case "", "drops":
+		return bpf.CaptureDrops, true
+	case "all":
+		return bpf.CaptureAll, true
+	default:
+		return 0, false
+	}
+}
+
+// Sample is one packet read from capture_events.
+type Sample struct {
+	Time    time.Time
+	Data    []byte // Captured bytes, starting at the Ethernet header
+	OrigLen uint32 // Length on the wire
+	Action  uint32 // XDP action taken
+}
+
+// configWriter is the subset of bpf.MapManager used to control the data
+// plane.
+type configWriter interface {
+	SetConfig(key uint32, value uint64) error
+}
+
+// session is an active capture.
+type session struct {
+	opts      Options
+	status    Status
+	file      *os.File
+	writer    *pcapWriter
+	seq       int
+	auto      bool
+	monoToRTC time.Duration // added to bpf_ktime_get_ns to get wall time
+}
+
+// Capturer owns the capture_events reader and the capture session.
+type Capturer struct {
+	log     *zap.Logger
+	cfg     Config
+	maps    configWriter
+	perfMap *ebpf.Map
+
+	autoLevel escalation.Level
+	autoOn    bool
+
+	onSample []func(Sample)
+
+	mu      sync.Mutex
+	session *session
+	last    Status
+
+	done chan struct{} // closed when Run exits
+}
+
+// NewCapturer creates a capturer reading from the capture_events map.
+func NewCapturer(log *zap.Logger, cfg Config, maps configWriter, perfMap *ebpf.Map) *Capturer {
+	if cfg.Dir == "" {
+		cfg.Dir = defaultDir
+	}
+	if cfg.Snaplen == 0 {
+		cfg.Snaplen = defaultSnaplen
+	}
+	if cfg.SampleRate == 0 {
+		cfg.SampleRate = defaultSampleRate
+	}
+	if cfg.MaxFileMB == 0 {
+		cfg.MaxFileMB = defaultMaxFileMB
+	}
+	if cfg.MaxFiles == 0 {
+		cfg.MaxFiles = defaultMaxFiles
+	}
+	if cfg.AutoDurationSec == 0 {
+		cfg.AutoDurationSec = defaultAutoDurationSec
+	}
+
+	c := &Capturer{
+		log:     log,
+		cfg:     cfg,
+		maps:    maps,
+		perfMap: perfMap,
+		done:    make(chan struct{}),
+	}
+	c.autoLevel, c.autoOn = parseLevel(cfg.AutoLevel)
+	return c
+}
+
+// OnSample registers fn to receive every packet of a running capture. It
+// must be called before Run; fn runs on the reader goroutine.
+func (c *Capturer) OnSample(fn func(Sample)) {
+	c.onSample = append(c.onSample, fn)
+}
+
+// Start begins a capture session.
+func (c *Capturer) Start(opts Options) (Status, error) {
+	if opts.Mode != bpf.CaptureDrops && opts.Mode != bpf.CaptureAll {
+		return Status{}, fmt.Errorf("invalid capture mode %d", opts.Mode)
+	}
+	if opts.Snaplen == 0 {
+		opts.Snaplen = c.cfg.Snaplen
+	}
+	if opts.Snaplen > bpf.CaptureSnaplenMax {
+		return Status{}, fmt.Errorf("snaplen %d exceeds maximum %d", opts.Snaplen, bpf.CaptureSnaplenMax)
+	}
+	if opts.SampleRate == 0 {
+		opts.SampleRate = c.cfg.SampleRate
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.startLocked(opts, false)
+}
+
+func (c *Capturer) startLocked(opts Options, auto bool) (Status, error) {
+	if c.session != nil {
+		return Status{}, ErrActive
+	}
+	if err := os.MkdirAll(c.cfg.Dir, 0o750); err != nil {
+		return Status{}, fmt.Errorf("creating capture directory: %w", err)
+	}
+
+	now := time.Now()
+	s := &session{
+		opts:      opts,
+		auto:      auto,
+		monoToRTC: monoToRTC(now),
+		status: Status{
+			Active:     true,
+			Mode:       ModeName(opts.Mode),
+			Reason:     opts.Reason,
+			StartedAt:  now,
+			SampleRate: opts.SampleRate,
+			Snaplen:    opts.Snaplen,
+			MaxPackets: opts.MaxPackets,
+		},
+	}
+	if opts.Duration > 0 {
+		s.status.Deadline = now.Add(opts.Duration)
+	}
+	if err := c.rotate(s); err != nil {
+		return Status{}, err
+	}
+
+	// Mode last, so the data plane only emits once sampling is set.
+	settings := []struct {
+		key uint32
+		val uint64
+	}{
+		{bpf.CfgCaptureSample, uint64(opts.SampleRate)},
+		{bpf.CfgCaptureSnaplen, uint64(opts.Snaplen)},
+		{bpf.CfgCaptureMode, uint64(opts.Mode)},
+	}
+	for _, kv := range settings {
+		if err := c.maps.SetConfig(kv.key, kv.val); err != nil {
+			c.maps.SetConfig(bpf.CfgCaptureMode, bpf.CaptureOff)
+			s.file.Close()
+			return Status{}, fmt.Errorf("enabling capture: %w", err)
+		}
+	}
+
+	c.session = s
+	c.log.Info("packet capture started",
+		zap.String("mode", s.status.Mode),
+		zap.String("reason", opts.Reason),
+		zap.Duration("duration", opts.Duration),
+		zap.Uint32("sample_rate", opts.SampleRate),
+		zap.String("file", s.status.File),
+	)
+	return s.status, nil
+}
+
+// Stop ends the running capture session, if any, and returns its final
+// status.
+func (c *Capturer) Stop() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopLocked("stopped")
+	return c.last
+}
+
+func (c *Capturer) stopLocked(why string) {
+	s := c.session
+	if s == nil {
+		return
+	}
+	if err := c.maps.SetConfig(bpf.CfgCaptureMode, bpf.CaptureOff); err != nil {
+		c.log.Error("failed to disable capture in data plane", zap.Error(err))
+	}
+	if err := closeFile(s); err != nil {
+		c.log.Error("failed to close capture file", zap.String("file", s.status.File), zap.Error(err))
+	}
+
+	s.status.Active = false
+	s.status.StoppedAt = time.Now()
+	c.last = s.status
+	c.session = nil
+
+	c.log.Info("packet capture stopped",
+		zap.String("why", why),
+		zap.Uint64("packets", s.status.Packets),
+		zap.Uint64("lost", s.status.Lost),
+		zap.Int("files", s.status.Files),
+	)
+}
+
+// Status returns the running session, or the last one if none is active.
+func (c *Capturer) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session != nil {
+		return c.session.status
+	}
+	return c.last
+}
+
+// HandleEscalation starts an automatic capture when the level rises to
+// Config.AutoLevel and stops it when the level falls back below.
+// Captures started through the API are left alone.
+func (c *Capturer) HandleEscalation(from, to escalation.Level) {
+	if !c.autoOn {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case from < c.autoLevel && to >= c.autoLevel:
+		_, err := c.startLocked(Options{
+			Mode:       bpf.CaptureDrops,
+			Duration:   time.Duration(c.cfg.AutoDurationSec) * time.Second,
+			SampleRate: c.cfg.SampleRate,
+			Snaplen:    c.cfg.Snaplen,
+			Reason:     "escalation to " + to.String(),
+		}, true)
+		if err != nil && !errors.Is(err, ErrActive) {
+			c.log.Error("failed to start automatic capture", zap.Error(err))
+		}
+	case from >= c.autoLevel && to < c.autoLevel:
+		if c.session != nil && c.session.auto {
+			c.stopLocked("de-escalated to " + to.String())
+		}
+	}
+}
+
+// Run reads sampled packets until ctx is cancelled, then stops any running
+// capture.
+func (c *Capturer) Run(ctx context.Context) error {
+	defer close(c.done)
+
+	rd, err := perf.NewReader(c.perfMap, perCPUBufferSize)
+	if err != nil {
+		return fmt.Errorf("opening capture reader: %w", err)
+	}
+	defer rd.Close()
+
+	c.log.Info("capture reader started", zap.String("dir", c.cfg.Dir))
+
+	for {
+		// A read deadline lets the loop notice cancellation and session
+		// deadlines while no packets arrive.
+		rd.SetDeadline(time.Now().Add(readPollInterval))
+		record, err := rd.Read()
+
+		if ctx.Err() != nil {
+			c.mu.Lock()
+			c.stopLocked("shutdown")
+			c.mu.Unlock()
+			c.log.Info("capture reader stopped")
+			return nil
+		}
+
+		switch {
+		case err == nil:
+			c.handleRecord(record)
+		case errors.Is(err, os.ErrDeadlineExceeded):
+		case errors.Is(err, perf.ErrClosed):
+			return nil
+		default:
+			c.log.Warn("error reading capture sample", zap.Error(err))
+		}
+
+		c.mu.Lock()
+		if s := c.session; s != nil && !s.status.Deadline.IsZero() && time.Now().After(s.status.Deadline) {
+			c.stopLocked("duration elapsed")
+		}
+		c.mu.Unlock()
+	}
+}
+
+// Done returns a channel that is closed once Run has returned.
+func (c *Capturer) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *Capturer) handleRecord(record perf.Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.session
+	if s == nil {
+		// Samples still in flight after Stop.
+		return
+	}
+	s.status.Lost += record.LostSamples
+	if len(record.RawSample) == 0 {
+		return
+	}
+
+	tsNS, pkt, err := parseSample(record.RawSample)
+	if err != nil {
+		c.log.Warn("malformed capture sample", zap.Error(err))
+		return
+	}
+
+	pkt.Time = time.Unix(0, int64(tsNS)).Add(s.monoToRTC)
+	if err := s.writer.writePacket(pkt.Time, pkt.Data, pkt.OrigLen); err != nil {
+		c.log.Error("writing capture file failed", zap.String("file", s.status.File), zap.Error(err))
+		c.stopLocked("write error")
+		return
+	}
+	s.status.Packets++
+	s.status.Bytes += uint64(len(pkt.Data))
+	for _, fn := range c.onSample {
+		fn(pkt)
+	}
+
+	if s.opts.MaxPackets > 0 && s.status.Packets >= s.opts.MaxPackets {
+		c.stopLocked("packet limit reached")
+		return
+	}
+	if s.writer.size() >= int64(c.cfg.MaxFileMB)<<20 {
+		if err := c.rotate(s); err != nil {
+			c.log.Error("rotating capture file failed", zap.Error(err))
+			c.stopLocked("rotation error")
+		}
+	}
+}
+
+// parseSample splits a capture_events sample into its bpf_ktime_get_ns
+// timestamp and the packet. Perf samples are padded, so cap_len bounds the
+// data.
+func parseSample(raw []byte) (uint64, Sample, error) {
+	if len(raw) < metaLen {
+		return 0, Sample{}, fmt.Errorf("sample too short: %d bytes", len(raw))
+	}
+	tsNS := binary.LittleEndian.Uint64(raw[0:8])
+	capLen := binary.LittleEndian.Uint32(raw[12:16])
+	if int(capLen) > len(raw)-metaLen {
+		return 0, Sample{}, fmt.Errorf("cap_len %d exceeds sample size %d", capLen, len(raw)-metaLen)
+	}
+	return tsNS, Sample{
+		Data:    raw[metaLen : metaLen+int(capLen)],
+		OrigLen: binary.LittleEndian.Uint32(raw[8:12]),
+		Action:  binary.LittleEndian.Uint32(raw[16:20]),
+	}, nil
+}
+
+// rotate closes the current file, opens the next one, and prunes old files.
+func (c *Capturer) rotate(s *session) error {
+	if err := closeFile(s); err != nil {
+		c.log.Warn("failed to close capture file", zap.String("file", s.status.File), zap.Error(err))
+	}
+
+	// Sessions started within the same second share a name prefix, so
+	// skip sequence numbers that are already taken.
+	var (
+		name string
+		f    *os.File
+		err  error
+	)
+	for {
+		s.seq++
+		name = fmt.Sprintf("%s%s-%03d%s", filePrefix,
+			s.status.StartedAt.UTC().Format("20060102T150405Z"), s.seq, fileSuffix)
+		f, err = os.OpenFile(filepath.Join(c.cfg.Dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+		if !errors.Is(err, os.ErrExist) {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("creating capture file: %w", err)
+	}
+	w, err := newPcapWriter(f, s.opts.Snaplen)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("writing pcap header: %w", err)
+	}
+
+	s.file, s.writer = f, w
+	s.status.File = name
+	s.status.Files++
+	c.prune()
+	return nil
+}
+
+func closeFile(s *session) error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.writer.flush()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	s.file, s.writer = nil, nil
+	return err
+}
+
+// prune deletes the oldest capture files beyond MaxFiles.
+func (c *Capturer) prune() {
+	files, err := c.Files()
+	if err != nil || len(files) <= c.cfg.MaxFiles {
+		return
+	}
+	for _, f := range files[c.cfg.MaxFiles:] {
+		if err := os.Remove(filepath.Join(c.cfg.Dir, f.Name)); err != nil {
+			c.log.Warn("failed to remove old capture file", zap.String("file", f.Name), zap.Error(err))
+		}
+	}
+}
+
+// Files lists capture files, newest first.
+func (c *Capturer) Files() ([]FileInfo, error) {
+	entries, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading capture directory: %w", err)
+	}
+
+	var files []FileInfo
+	for _, e := range entries {
+		if e.IsDir() || !validName(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, FileInfo{Name: e.Name(), Size: info.Size(), Modified: info.ModTime()})
+	}
+	// Names embed the session start and sequence, so they sort by age.
+	sort.Slice(files, func(i, j int) bool { return files[i].Name > files[j].Name })
+	return files, nil
+}
+
+// Open opens a capture file for download.
+func (c *Capturer) Open(name string) (*os.File, error) {
+	if !validName(name) {
+		return nil, fmt.Errorf("invalid capture file name %q", name)
+	}
+	return os.Open(filepath.Join(c.cfg.Dir, name))
+}
+
+// Remove deletes a capture file. The file being written cannot be removed.
+func (c *Capturer) Remove(name string) error {
+	if !validName(name) {
+		return fmt.Errorf("invalid capture file name %q", name)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session != nil && c.session.status.File == name {
+		return fmt.Errorf("capture file %s is being written", name)
+	}
+	return os.Remove(filepath.Join(c.cfg.Dir, name))
+}
+
+func validName(name string) bool {
+	return filepath.Base(name) == name &&
+		strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix)
+}
+
+// monoToRTC returns the offset from CLOCK_MONOTONIC (bpf_ktime_get_ns) to
+// wall-clock time.
+func monoToRTC(now time.Time) time.Duration {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return 0
+	}
+	return time.Duration(now.UnixNano() - ts.Nano())
+}
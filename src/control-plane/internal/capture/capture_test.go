@@ -0,0 +1,230 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/perf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"go.uber.org/zap"
+)
+
+// fakeConfig records config map writes.
+type fakeConfig map[uint32]uint64
+
+func (f fakeConfig) SetConfig(key uint32, value uint64) error {
+	f[key] = value
+	return nil
+}
+
+// sample builds a capture_events record as emitted by capture_packet.
+func sample(tsNS uint64, pktLen uint32, data []byte, pad int) []byte {
+	raw := make([]byte, metaLen, metaLen+len(data)+pad)
+	binary.LittleEndian.PutUint64(raw[0:8], tsNS)
+	binary.LittleEndian.PutUint32(raw[8:12], pktLen)
+	binary.LittleEndian.PutUint32(raw[12:16], uint32(len(data)))
+	binary.LittleEndian.PutUint32(raw[16:20], 1) // XDP_DROP
+	raw = append(raw, data...)
+	return append(raw, make([]byte, pad)...)
+}
+
+func newTestCapturer(t *testing.T, cfg Config) (*Capturer, fakeConfig) {
+	t.Helper()
+	cfg.Dir = t.TempDir()
+	maps := fakeConfig{}
+	return NewCapturer(zap.NewNop(), cfg, maps, nil), maps
+}
+
+func TestPcapWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newPcapWriter(&buf, 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Unix(1700000000, 123456789)
+	if err := w.writePacket(ts, []byte{1, 2, 3}, 60); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	b := buf.Bytes()
+	if len(b) != pcapFileHeaderLen+pcapRecordHeaderLen+3 || int64(len(b)) != w.size() {
+		t.Fatalf("wrote %d bytes, size() = %d", len(b), w.size())
+	}
+	if magic := binary.LittleEndian.Uint32(b[0:4]); magic != pcapMagicNanos {
+		t.Errorf("magic = %#x", magic)
+	}
+	if snap := binary.LittleEndian.Uint32(b[16:20]); snap != 128 {
+		t.Errorf("snaplen = %d, want 128", snap)
+	}
+	rec := b[pcapFileHeaderLen:]
+	if sec := binary.LittleEndian.Uint32(rec[0:4]); sec != 1700000000 {
+		t.Errorf("ts_sec = %d", sec)
+	}
+	if nsec := binary.LittleEndian.Uint32(rec[4:8]); nsec != 123456789 {
+		t.Errorf("ts_nsec = %d", nsec)
+	}
+	if incl, orig := binary.LittleEndian.Uint32(rec[8:12]), binary.LittleEndian.Uint32(rec[12:16]); incl != 3 || orig != 60 {
+		t.Errorf("incl/orig = %d/%d, want 3/60", incl, orig)
+	}
+}
+
+func TestParseSample(t *testing.T) {
+	ts, pkt, err := parseSample(sample(42, 1500, []byte("abcd"), 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts != 42 || pkt.OrigLen != 1500 || pkt.Action != 1 || string(pkt.Data) != "abcd" {
+		t.Errorf("got ts=%d sample=%+v", ts, pkt)
+	}
+
+	if _, _, err := parseSample(make([]byte, metaLen-1)); err == nil {
+		t.Error("short sample should fail")
+	}
+	bad := sample(1, 100, []byte("ab"), 0)
+	binary.LittleEndian.PutUint32(bad[12:16], 10)
+	if _, _, err := parseSample(bad); err == nil {
+		t.Error("cap_len beyond sample should fail")
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	c, maps := newTestCapturer(t, Config{Snaplen: 64, SampleRate: 10})
+
+	st, err := c.Start(Options{Mode: bpf.CaptureAll, MaxPackets: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Active || st.Mode != "all" || st.Snaplen != 64 || st.SampleRate != 10 {
+		t.Errorf("unexpected status: %+v", st)
+	}
+	if maps[bpf.CfgCaptureMode] != bpf.CaptureAll || maps[bpf.CfgCaptureSample] != 10 || maps[bpf.CfgCaptureSnaplen] != 64 {
+		t.Errorf("data plane config = %v", maps)
+	}
+	if _, err := c.Start(Options{Mode: bpf.CaptureDrops}); !errors.Is(err, ErrActive) {
+		t.Errorf("second Start: err = %v, want ErrActive", err)
+	}
+
+	var observed int
+	c.OnSample(func(Sample) { observed++ })
+
+	c.handleRecord(perf.Record{RawSample: sample(1, 100, []byte("pkt1"), 0)})
+	c.handleRecord(perf.Record{LostSamples: 3})
+	c.handleRecord(perf.Record{RawSample: sample(2, 100, []byte("pkt2"), 0)})
+
+	// The packet limit ends the session.
+	st = c.Status()
+	if st.Active || st.Packets != 2 || st.Bytes != 8 || st.Lost != 3 {
+		t.Errorf("status after limit: %+v", st)
+	}
+	if maps[bpf.CfgCaptureMode] != bpf.CaptureOff {
+		t.Error("capture not disabled in data plane")
+	}
+	if observed != 2 {
+		t.Errorf("OnSample saw %d packets, want 2", observed)
+	}
+
+	files, err := c.Files()
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Files() = %v, %v", files, err)
+	}
+	want := int64(pcapFileHeaderLen + 2*(pcapRecordHeaderLen+4))
+	if files[0].Size != want {
+		t.Errorf("file size = %d, want %d", files[0].Size, want)
+	}
+}
+
+func TestStartRejectsInvalidOptions(t *testing.T) {
+	c, _ := newTestCapturer(t, Config{})
+	if _, err := c.Start(Options{Mode: bpf.CaptureOff}); err == nil {
+		t.Error("mode off should be rejected")
+	}
+	if _, err := c.Start(Options{Mode: bpf.CaptureDrops, Snaplen: 9000}); err == nil {
+		t.Error("oversized snaplen should be rejected")
+	}
+}
+
+func TestRotateAndPrune(t *testing.T) {
+	c, _ := newTestCapturer(t, Config{MaxFiles: 2})
+	c.cfg.MaxFileMB = 0 // rotate after every packet
+
+	if _, err := c.Start(Options{Mode: bpf.CaptureDrops}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		c.handleRecord(perf.Record{RawSample: sample(uint64(i), 60, []byte("x"), 0)})
+	}
+	st := c.Stop()
+	if st.Files != 5 {
+		t.Errorf("files written = %d, want 5", st.Files)
+	}
+
+	files, _ := c.Files()
+	if len(files) != 2 {
+		t.Fatalf("kept %d files, want 2", len(files))
+	}
+	if files[0].Name != st.File {
+		t.Errorf("newest file = %s, want %s", files[0].Name, st.File)
+	}
+}
+
+func TestFileNames(t *testing.T) {
+	c, _ := newTestCapturer(t, Config{})
+	for _, name := range []string{"../etc/passwd", "capture-x.txt", "other.pcap", "sub/capture-1.pcap"} {
+		if _, err := c.Open(name); err == nil {
+			t.Errorf("Open(%q) should fail", name)
+		}
+		if err := c.Remove(name); err == nil {
+			t.Errorf("Remove(%q) should fail", name)
+		}
+	}
+
+	st, err := c.Start(Options{Mode: bpf.CaptureDrops})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Remove(st.File); err == nil {
+		t.Error("removing the active file should fail")
+	}
+	c.Stop()
+	if err := c.Remove(st.File); err != nil {
+		t.Errorf("Remove after stop: %v", err)
+	}
+}
+
+func TestHandleEscalation(t *testing.T) {
+	c, _ := newTestCapturer(t, Config{AutoLevel: "high"})
+
+	c.HandleEscalation(escalation.Low, escalation.Medium)
+	if c.Status().Active {
+		t.Fatal("capture started below auto level")
+	}
+	c.HandleEscalation(escalation.Medium, escalation.Critical)
+	st := c.Status()
+	if !st.Active || st.Mode != "drops" || st.Deadline.IsZero() {
+		t.Fatalf("automatic capture not started: %+v", st)
+	}
+	c.HandleEscalation(escalation.Critical, escalation.High)
+	if !c.Status().Active {
+		t.Error("capture stopped while still at auto level")
+	}
+	c.HandleEscalation(escalation.High, escalation.Low)
+	if c.Status().Active {
+		t.Error("automatic capture not stopped on de-escalation")
+	}
+
+	// Manual captures survive de-escalation.
+	if _, err := c.Start(Options{Mode: bpf.CaptureAll}); err != nil {
+		t.Fatal(err)
+	}
+	c.HandleEscalation(escalation.Critical, escalation.Low)
+	if !c.Status().Active {
+		t.Error("manual capture stopped by de-escalation")
+	}
+}
@@ -0,0 +1,613 @@
+// Package cluster replicates mitigation state between an active/standby
+// pair of scrubbers and decides which of the two is active.
+//
+// Each node periodically snapshots its registered sources (blacklist,
+// whitelist, reputation blocks, threat intel entries, escalation level),
+// records differences as versioned entries, and pushes the entries its peer
+// has not yet acknowledged over HTTPS with mutual TLS. Concurrent writes to
+// the same entry are resolved last-writer-wins on the version timestamp,
+// with the node ID as tie-break.
+//
+// The same exchange carries each node's role. A standby that has not heard
+// from its peer for Config.FailoverSec promotes itself. When both nodes
+// claim the same role, the one that has been active longer keeps (or takes)
+// the active role; with Config.Preempt, the node configured as active
+// always wins.
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultIntervalSec = 2
+	defaultFailoverSec = 10
+
+	syncPath        = "/ha/v1/sync"
+	maxSyncBody     = 64 << 20
+	tombstoneTTL    = 24 * time.Hour
+	shutdownTimeout = 5 * time.Second
+)
+
+// Kinds of replicated state.
+const (
+	KindBlacklist   = "blacklist"
+	KindWhitelist   = "whitelist"
+	KindReputation  = "reputation"
+	KindThreatIntel = "threat_intel"
+	KindEscalation  = "escalation"
+)
+
+// Role is the failover role of a scrubber.
+type Role string
+
+const (
+	RoleActive  Role = "active"
+	RoleStandby Role = "standby"
+)
+
+// Config controls state synchronization with the peer scrubber.
+type Config struct {
+	Enabled     bool      `yaml:"enabled"`
+	NodeID      string    `yaml:"node_id"` // Unique name of this scrubber
+	Role        string    `yaml:"role"`    // Role at startup: "active" or "standby"
+	Listen      string    `yaml:"listen"`  // Sync listener, e.g. "0.0.0.0:9443"
+	Peer        string    `yaml:"peer"`    // Peer sync address (host:port)
+	TLS         TLSConfig `yaml:"tls"`
+	IntervalSec uint64    `yaml:"interval_sec"` // Sync interval
+	FailoverSec uint64    `yaml:"failover_sec"` // Standby promotes itself after this long without contact
+	Preempt     bool      `yaml:"preempt"`      // The node configured as active reclaims the role on recovery
+}
+
+// TLSConfig holds the mutual TLS material. Both nodes present certificates
+// signed by CA; the peer's certificate must be valid for the Peer host.
+type TLSConfig struct {
+	Cert     string `yaml:"cert"`
+	Key      string `yaml:"key"`
+	CA       string `yaml:"ca"`
+	Insecure bool   `yaml:"insecure"` // Plain HTTP, for lab setups only
+}
+
+// Validate checks the cluster configuration.
+func (c Config) Validate() error {
+	if c.NodeID == "" {
+		return fmt.Errorf("node_id is required")
+	}
+	switch Role(c.Role) {
+	case RoleActive, RoleStandby:
+		// ok
+	default:
+		return fmt.Errorf("invalid role %q (must be active or standby)", c.Role)
+	}
+	if c.Listen == "" || c.Peer == "" {
+		return fmt.Errorf("listen and peer are required")
+	}
+	if !c.TLS.Insecure && (c.TLS.Cert == "" || c.TLS.Key == "" || c.TLS.CA == "") {
+		return fmt.Errorf("tls.cert, tls.key and tls.ca are required unless tls.insecure is set")
+	}
+	if c.FailoverSec != 0 && c.FailoverSec <= c.IntervalSec {
+		return fmt.Errorf("failover_sec must exceed interval_sec")
+	}
+	return nil
+}
+
+// Source is a kind of local state replicated to the peer. Entries are
+// identified by ID and carry an opaque value.
+type Source interface {
+	Snapshot() (map[string]string, error)
+	Apply(id, value string) error
+	Remove(id string) error
+}
+
+// Status reports the local role and the state of the peer link.
+type Status struct {
+	Node        string    `json:"node"`
+	Role        Role      `json:"role"`
+	ActiveSince time.Time `json:"activeSince,omitempty"`
+	Peer        string    `json:"peer"`
+	PeerNode    string    `json:"peerNode,omitempty"`
+	PeerRole    Role      `json:"peerRole,omitempty"`
+	LastContact time.Time `json:"lastContact,omitempty"`
+	LastError   string    `json:"lastError,omitempty"`
+	Entries     int       `json:"entries"`
+	Pending     int       `json:"pending"`
+}
+
+// nodeState is exchanged on every sync so each side can resolve roles.
+type nodeState struct {
+	Node        string    `json:"node"`
+	Epoch       int64     `json:"epoch"` // Changes when the process restarts
+	Role        Role      `json:"role"`
+	ActiveSince time.Time `json:"activeSince,omitempty"`
+	Preferred   bool      `json:"preferred"` // Configured as active
+}
+
+type syncRequest struct {
+	nodeState
+	Entries []Entry `json:"entries"`
+}
+
+type syncResponse struct {
+	nodeState
+	Applied int `json:"applied"`
+}
+
+// Syncer replicates registered sources to the peer and tracks the role.
+type Syncer struct {
+	log       *zap.Logger
+	cfg       Config
+	peerURL   string
+	client    *http.Client
+	serverTLS *tls.Config
+	epoch     int64
+	started   time.Time
+	now       func() time.Time
+
+	mu          sync.Mutex
+	sources     map[string]Source
+	kinds       []string
+	prev        map[string]map[string]string // last snapshot per kind
+	store       *store
+	role        Role
+	activeSince time.Time
+	peer        nodeState
+	peerEpoch   int64
+	acked       uint64 // highest local seq the current peer process received
+	lastContact time.Time
+	lastErr     string
+
+	onRoleChange []func(Role)
+}
+
+// NewSyncer creates a syncer. It fails if the TLS material cannot be loaded.
+func NewSyncer(log *zap.Logger, cfg Config) (*Syncer, error) {
+	if cfg.IntervalSec == 0 {
+		cfg.IntervalSec = defaultIntervalSec
+	}
+	if cfg.FailoverSec == 0 {
+		cfg.FailoverSec = defaultFailoverSec
+	}
+
+	s := &Syncer{
+		log:     log,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: time.Duration(cfg.IntervalSec) * time.Second * 5},
+		now:     time.Now,
+		sources: make(map[string]Source),
+		prev:    make(map[string]map[string]string),
+		store:   newStore(),
+		role:    Role(cfg.Role),
+	}
+	s.started = s.now()
+	s.epoch = s.started.UnixNano()
+	if s.role == RoleActive {
+		s.activeSince = s.started
+	}
+
+	if cfg.TLS.Insecure {
+		s.peerURL = "http://" + cfg.Peer
+		return s, nil
+	}
+	server, client, err := cfg.TLS.Load()
+	if err != nil {
+		return nil, err
+	}
+	s.serverTLS = server
+	s.client.Transport = &http.Transport{TLSClientConfig: client}
+	s.peerURL = "https://" + cfg.Peer
+	return s, nil
+}
+
+// Load builds the server and client TLS configurations. Both require the
+// other side to present a certificate signed by the CA.
+func (c TLSConfig) Load() (server, client *tls.Config, err error) {
+	cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading TLS key pair: %w", err)
+	}
+	caPEM, err := os.ReadFile(c.CA)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, nil, fmt.Errorf("no certificates found in %s", c.CA)
+	}
+
+	server = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+	client = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS12,
+	}
+	return server, client, nil
+}
+
+// Register adds a kind of state to replicate. It must be called before Run.
+func (s *Syncer) Register(kind string, src Source) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sources[kind] = src
+	s.kinds = append(s.kinds, kind)
+}
+
+// OnRoleChange registers a callback invoked after the local role changes.
+func (s *Syncer) OnRoleChange(fn func(Role)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onRoleChange = append(s.onRoleChange, fn)
+}
+
+// Role returns the current role of this node.
+func (s *Syncer) Role() Role {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.role
+}
+
+// Status returns the local role and peer link state.
+func (s *Syncer) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending, _ := s.store.since(s.acked, s.acked == 0)
+	return Status{
+		Node:        s.cfg.NodeID,
+		Role:        s.role,
+		ActiveSince: s.activeSince,
+		Peer:        s.cfg.Peer,
+		PeerNode:    s.peer.Node,
+		PeerRole:    s.peer.Role,
+		LastContact: s.lastContact,
+		LastError:   s.lastErr,
+		Entries:     s.store.live(),
+		Pending:     len(pending),
+	}
+}
+
+// Run serves the peer's sync requests and pushes local changes every
+// Config.IntervalSec until ctx is cancelled.
+func (s *Syncer) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", s.cfg.Listen, err)
+	}
+	if s.serverTLS != nil {
+		ln = tls.NewListener(ln, s.serverTLS)
+	}
+
+	srv := &http.Server{Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			s.log.Error("sync listener failed", zap.Error(err))
+		}
+	}()
+	// Wait for in-flight applies so nothing writes maps after Run returns.
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	s.log.Info("cluster sync started",
+		zap.String("node", s.cfg.NodeID),
+		zap.String("role", string(s.Role())),
+		zap.String("listen", s.cfg.Listen),
+		zap.String("peer", s.cfg.Peer),
+	)
+
+	ticker := time.NewTicker(time.Duration(s.cfg.IntervalSec) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		s.tick(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Syncer) tick(ctx context.Context) {
+	s.scan()
+	s.push(ctx)
+	s.checkFailover()
+
+	s.mu.Lock()
+	s.store.prune(s.now().Add(-tombstoneTTL).UnixNano())
+	s.mu.Unlock()
+}
+
+// scan records local changes as new versions. An entry is written when the
+// local value differs from the stored one, and deleted when it disappears
+// from a source that held it at the previous scan, so entries that failed
+// to apply locally are not turned into deletions.
+func (s *Syncer) scan() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().UnixNano()
+	for _, kind := range s.kinds {
+		snap, err := s.sources[kind].Snapshot()
+		if err != nil {
+			s.log.Warn("snapshot failed", zap.String("kind", kind), zap.Error(err))
+			continue
+		}
+		for id, value := range snap {
+			if cur, ok := s.store.get(kind, id); ok && !cur.Deleted && cur.Value == value {
+				continue
+			}
+			s.writeLocked(Entry{Kind: kind, ID: id, Value: value}, now)
+		}
+		for id := range s.prev[kind] {
+			if _, ok := snap[id]; ok {
+				continue
+			}
+			if cur, ok := s.store.get(kind, id); ok && !cur.Deleted {
+				s.writeLocked(Entry{Kind: kind, ID: id, Deleted: true}, now)
+			}
+		}
+		s.prev[kind] = snap
+	}
+}
+
+// writeLocked stores a local write. Its version is kept ahead of the one it
+// replaces so local changes win even if the peer's clock runs ahead.
+func (s *Syncer) writeLocked(e Entry, now int64) {
+	if cur, ok := s.store.get(e.Kind, e.ID); ok && cur.Version.Time >= now {
+		now = cur.Version.Time + 1
+	}
+	e.Version = Version{Time: now, Node: s.cfg.NodeID}
+	s.store.put(e, false)
+}
+
+// push sends unacknowledged entries to the peer. Entries received from the
+// peer are only echoed back when resending everything after the peer
+// restarted and lost its state.
+func (s *Syncer) push(ctx context.Context) {
+	s.mu.Lock()
+	full := s.acked == 0
+	entries, seq := s.store.since(s.acked, full)
+	req := syncRequest{nodeState: s.stateLocked(), Entries: entries}
+	s.mu.Unlock()
+
+	resp, err := s.post(ctx, req)
+	if err != nil {
+		s.mu.Lock()
+		s.lastErr = err.Error()
+		s.mu.Unlock()
+		s.log.Debug("sync to peer failed", zap.Error(err))
+		return
+	}
+
+	s.mu.Lock()
+	if resp.Epoch != s.peerEpoch && !full {
+		// The peer restarted since the last push: resend everything.
+		s.acked = 0
+	} else {
+		s.acked = seq
+	}
+	s.peerEpoch = resp.Epoch
+	role, changed := s.contactLocked(resp.nodeState)
+	s.mu.Unlock()
+
+	if changed {
+		s.notifyRole(role)
+	}
+}
+
+func (s *Syncer) post(ctx context.Context, req syncRequest) (*syncResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encoding sync request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.peerURL+syncPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := s.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(httpResp.Body, 1024))
+		return nil, fmt.Errorf("peer returned HTTP %d: %s", httpResp.StatusCode, bytes.TrimSpace(msg))
+	}
+	var resp syncResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("decoding sync response: %w", err)
+	}
+	return &resp, nil
+}
+
+func (s *Syncer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(syncPath, s.handleSync)
+	return mux
+}
+
+// handleSync merges the peer's entries into the store and applies the ones
+// that win to the local sources.
+func (s *Syncer) handleSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req syncRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSyncBody)).Decode(&req); err != nil {
+		http.Error(w, "invalid sync request", http.StatusBadRequest)
+		return
+	}
+	if req.Node == s.cfg.NodeID {
+		http.Error(w, "peer uses the same node_id", http.StatusConflict)
+		return
+	}
+
+	s.mu.Lock()
+	applied := 0
+	for _, e := range req.Entries {
+		if !s.store.merge(e) {
+			continue
+		}
+		if s.applyLocked(e) {
+			applied++
+		}
+	}
+	role, changed := s.contactLocked(req.nodeState)
+	resp := syncResponse{nodeState: s.stateLocked(), Applied: applied}
+	s.mu.Unlock()
+
+	if changed {
+		s.notifyRole(role)
+	}
+	if applied > 0 {
+		s.log.Debug("applied peer changes", zap.String("peer", req.Node), zap.Int("entries", applied))
+	}
+	writeJSON(w, resp)
+}
+
+// applyLocked writes a merged peer entry to its source and to the last
+// snapshot, so the next scan does not mistake it for a local change.
+func (s *Syncer) applyLocked(e Entry) bool {
+	src, ok := s.sources[e.Kind]
+	if !ok {
+		return false
+	}
+	prev := s.prev[e.Kind]
+	if prev == nil {
+		prev = make(map[string]string)
+		s.prev[e.Kind] = prev
+	}
+
+	var err error
+	if e.Deleted {
+		err = src.Remove(e.ID)
+		delete(prev, e.ID)
+	} else {
+		err = src.Apply(e.ID, e.Value)
+		if err == nil {
+			prev[e.ID] = e.Value
+		}
+	}
+	if err != nil {
+		s.log.Warn("failed to apply peer entry",
+			zap.String("kind", e.Kind), zap.String("id", e.ID), zap.Bool("deleted", e.Deleted), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+func (s *Syncer) stateLocked() nodeState {
+	return nodeState{
+		Node:        s.cfg.NodeID,
+		Epoch:       s.epoch,
+		Role:        s.role,
+		ActiveSince: s.activeSince,
+		Preferred:   Role(s.cfg.Role) == RoleActive,
+	}
+}
+
+// contactLocked records contact with the peer and resolves the roles. Both
+// nodes evaluate the same rule on each other's state, so they agree on a
+// single active node.
+func (s *Syncer) contactLocked(peer nodeState) (Role, bool) {
+	s.lastContact = s.now()
+	s.lastErr = ""
+	s.peer = peer
+
+	self := s.stateLocked()
+	switch {
+	case self.Role == peer.Role && outranks(self, peer, s.cfg.Preempt):
+		return RoleActive, s.setRoleLocked(RoleActive, "outranks peer")
+	case self.Role == peer.Role:
+		return RoleStandby, s.setRoleLocked(RoleStandby, "peer outranks this node")
+	case s.cfg.Preempt && self.Preferred && !peer.Preferred:
+		return RoleActive, s.setRoleLocked(RoleActive, "preempting peer")
+	}
+	return s.role, false
+}
+
+// outranks reports whether a should be active rather than b: the preferred
+// node when preempting, otherwise the node active for longer, then the
+// preferred node, then the lower node ID.
+func outranks(a, b nodeState, preempt bool) bool {
+	if preempt && a.Preferred != b.Preferred {
+		return a.Preferred
+	}
+	if !a.ActiveSince.IsZero() && !b.ActiveSince.IsZero() && !a.ActiveSince.Equal(b.ActiveSince) {
+		return a.ActiveSince.Before(b.ActiveSince)
+	}
+	if a.Preferred != b.Preferred {
+		return a.Preferred
+	}
+	return a.Node < b.Node
+}
+
+// checkFailover promotes a standby that has lost contact with its peer.
+func (s *Syncer) checkFailover() {
+	s.mu.Lock()
+	since := s.lastContact
+	if since.Before(s.started) {
+		since = s.started
+	}
+	changed := false
+	if s.role == RoleStandby && s.now().Sub(since) > time.Duration(s.cfg.FailoverSec)*time.Second {
+		changed = s.setRoleLocked(RoleActive, "peer unreachable")
+	}
+	s.mu.Unlock()
+
+	if changed {
+		s.notifyRole(RoleActive)
+	}
+}
+
+func (s *Syncer) setRoleLocked(role Role, reason string) bool {
+	if s.role == role {
+		return false
+	}
+	s.role = role
+	if role == RoleActive {
+		s.activeSince = s.now()
+	} else {
+		s.activeSince = time.Time{}
+	}
+	s.log.Warn("cluster role changed", zap.String("role", string(role)), zap.String("reason", reason))
+	return true
+}
+
+func (s *Syncer) notifyRole(role Role) {
+	s.mu.Lock()
+	fns := append([]func(Role){}, s.onRoleChange...)
+	s.mu.Unlock()
+	for _, fn := range fns {
+		fn(role)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,310 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeSource is an in-memory source. Apply fails for IDs in reject.
+type fakeSource struct {
+	items  map[string]string
+	reject map[string]bool
+}
+
+func newFakeSource() *fakeSource {
+	return &fakeSource{items: map[string]string{}, reject: map[string]bool{}}
+}
+
+func (f *fakeSource) Snapshot() (map[string]string, error) {
+	snap := make(map[string]string, len(f.items))
+	for k, v := range f.items {
+		snap[k] = v
+	}
+	return snap, nil
+}
+
+func (f *fakeSource) Apply(id, value string) error {
+	if f.reject[id] {
+		return fmt.Errorf("rejected %s", id)
+	}
+	f.items[id] = value
+	return nil
+}
+
+func (f *fakeSource) Remove(id string) error {
+	delete(f.items, id)
+	return nil
+}
+
+func newTestSyncer(t *testing.T, node, role string) (*Syncer, *fakeSource) {
+	t.Helper()
+	s, err := NewSyncer(zap.NewNop(), Config{NodeID: node, Role: role, TLS: TLSConfig{Insecure: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := newFakeSource()
+	s.Register(KindBlacklist, src)
+	return s, src
+}
+
+func TestStoreMerge(t *testing.T) {
+	st := newStore()
+	st.put(Entry{Kind: "k", ID: "a", Value: "1", Version: Version{Time: 10, Node: "n1"}}, false)
+
+	if st.merge(Entry{Kind: "k", ID: "a", Value: "0", Version: Version{Time: 9, Node: "n2"}}) {
+		t.Error("older version merged")
+	}
+	if !st.merge(Entry{Kind: "k", ID: "a", Value: "2", Version: Version{Time: 10, Node: "n2"}}) {
+		t.Error("tie not broken by node ID")
+	}
+	if !st.merge(Entry{Kind: "k", ID: "a", Deleted: true, Version: Version{Time: 11, Node: "n1"}}) {
+		t.Error("newer tombstone not merged")
+	}
+	if st.live() != 0 {
+		t.Errorf("live = %d, want 0", st.live())
+	}
+
+	entries, seq := st.since(1, false)
+	if len(entries) != 0 || seq != 1 {
+		t.Errorf("since(1, false) = %v, %d; peer entries should be skipped", entries, seq)
+	}
+	entries, seq = st.since(0, true)
+	if len(entries) != 1 || seq != 3 || !entries[0].Deleted {
+		t.Errorf("since(0, true) = %v, %d", entries, seq)
+	}
+
+	st.prune(12)
+	if len(st.entries) != 0 {
+		t.Error("tombstone not pruned")
+	}
+}
+
+func TestScan(t *testing.T) {
+	s, src := newTestSyncer(t, "a", "active")
+	src.items["10.0.0.0/8"] = "1"
+	src.items["192.0.2.0/24"] = "1"
+	s.scan()
+
+	if s.store.live() != 2 {
+		t.Fatalf("live = %d, want 2", s.store.live())
+	}
+	e, _ := s.store.get(KindBlacklist, "10.0.0.0/8")
+	first := e.Version.Time
+
+	// Unchanged entries are not rewritten.
+	s.scan()
+	if s.store.seq != 2 {
+		t.Errorf("seq = %d after no-op scan, want 2", s.store.seq)
+	}
+
+	src.items["10.0.0.0/8"] = "19"
+	delete(src.items, "192.0.2.0/24")
+	s.scan()
+	e, _ = s.store.get(KindBlacklist, "10.0.0.0/8")
+	if e.Value != "19" || e.Version.Time <= first || e.Version.Node != "a" {
+		t.Errorf("changed entry: %+v", e.Entry)
+	}
+	if e, _ := s.store.get(KindBlacklist, "192.0.2.0/24"); !e.Deleted {
+		t.Error("removed entry not tombstoned")
+	}
+
+	// A peer entry that fails to apply must not be scanned as a deletion.
+	src.reject["198.51.100.0/24"] = true
+	s.mu.Lock()
+	s.store.merge(Entry{Kind: KindBlacklist, ID: "198.51.100.0/24", Value: "1", Version: Version{Time: 1, Node: "b"}})
+	s.applyLocked(Entry{Kind: KindBlacklist, ID: "198.51.100.0/24", Value: "1"})
+	s.mu.Unlock()
+	s.scan()
+	if e, _ := s.store.get(KindBlacklist, "198.51.100.0/24"); e.Deleted || e.Version.Node != "b" {
+		t.Errorf("failed apply rewritten locally: %+v", e.Entry)
+	}
+}
+
+func TestLocalWriteBeatsSkewedPeer(t *testing.T) {
+	s, src := newTestSyncer(t, "a", "active")
+	future := time.Now().Add(time.Hour).UnixNano()
+	s.store.merge(Entry{Kind: KindBlacklist, ID: "x", Value: "1", Version: Version{Time: future, Node: "b"}})
+
+	src.items["x"] = "2"
+	s.scan()
+	e, _ := s.store.get(KindBlacklist, "x")
+	if e.Value != "2" || e.Version.Time <= future {
+		t.Errorf("local write lost to peer clock skew: %+v", e.Entry)
+	}
+}
+
+func TestOutranks(t *testing.T) {
+	now := time.Now()
+	older := nodeState{Node: "b", Role: RoleActive, ActiveSince: now.Add(-time.Minute)}
+	newer := nodeState{Node: "a", Role: RoleActive, ActiveSince: now, Preferred: true}
+
+	if !outranks(older, newer, false) {
+		t.Error("longer-active node should win without preemption")
+	}
+	if !outranks(newer, older, true) {
+		t.Error("preferred node should win with preemption")
+	}
+
+	a := nodeState{Node: "a", Role: RoleStandby}
+	b := nodeState{Node: "b", Role: RoleStandby}
+	if !outranks(a, b, false) || outranks(b, a, false) {
+		t.Error("lower node ID should win between equal standbys")
+	}
+	b.Preferred = true
+	if outranks(a, b, false) {
+		t.Error("preferred standby should win")
+	}
+}
+
+// pair wires two syncers to each other over HTTP.
+func pair(t *testing.T, preempt bool) (a, b *Syncer, srcA, srcB *fakeSource) {
+	t.Helper()
+	a, srcA = newTestSyncer(t, "node-a", "active")
+	b, srcB = newTestSyncer(t, "node-b", "standby")
+	a.cfg.Preempt, b.cfg.Preempt = preempt, preempt
+
+	srvA := httptest.NewServer(a.handler())
+	srvB := httptest.NewServer(b.handler())
+	t.Cleanup(srvA.Close)
+	t.Cleanup(srvB.Close)
+	a.peerURL, b.peerURL = srvB.URL, srvA.URL
+	return a, b, srcA, srcB
+}
+
+func TestReplication(t *testing.T) {
+	a, b, srcA, srcB := pair(t, false)
+	ctx := context.Background()
+
+	srcA.items["10.0.0.0/8"] = "1"
+	srcB.items["192.0.2.0/24"] = "1"
+	a.tick(ctx)
+	b.tick(ctx)
+
+	if srcB.items["10.0.0.0/8"] != "1" || srcA.items["192.0.2.0/24"] != "1" {
+		t.Fatalf("not replicated: a=%v b=%v", srcA.items, srcB.items)
+	}
+	if a.Role() != RoleActive || b.Role() != RoleStandby {
+		t.Errorf("roles = %s/%s, want active/standby", a.Role(), b.Role())
+	}
+
+	// Replicated entries are neither echoed back nor rescanned as changes.
+	seqA := a.store.seq
+	a.tick(ctx)
+	b.tick(ctx)
+	if a.store.seq != seqA {
+		t.Errorf("store churned: seq %d -> %d", seqA, a.store.seq)
+	}
+
+	// Deletions propagate; concurrent writes converge on the later one.
+	delete(srcB.items, "10.0.0.0/8")
+	srcA.items["192.0.2.0/24"] = "13"
+	srcB.items["192.0.2.0/24"] = "19"
+	a.tick(ctx)
+	b.tick(ctx)
+	a.tick(ctx)
+
+	if _, ok := srcA.items["10.0.0.0/8"]; ok {
+		t.Error("deletion not replicated")
+	}
+	if srcA.items["192.0.2.0/24"] != srcB.items["192.0.2.0/24"] {
+		t.Errorf("conflict not resolved: a=%s b=%s", srcA.items["192.0.2.0/24"], srcB.items["192.0.2.0/24"])
+	}
+
+	if st := a.Status(); st.PeerNode != "node-b" || st.PeerRole != RoleStandby || st.Pending != 0 {
+		t.Errorf("status: %+v", st)
+	}
+}
+
+func TestPeerRestartResync(t *testing.T) {
+	a, b, srcA, srcB := pair(t, false)
+	ctx := context.Background()
+
+	srcA.items["10.0.0.0/8"] = "1"
+	a.tick(ctx)
+	b.tick(ctx)
+	a.tick(ctx)
+
+	// Simulate b restarting with empty state.
+	fresh, _ := newTestSyncer(t, "node-b", "standby")
+	fresh.Register(KindBlacklist, srcB)
+	delete(srcB.items, "10.0.0.0/8")
+	fresh.epoch = b.epoch + 1
+	srv := httptest.NewServer(fresh.handler())
+	defer srv.Close()
+	a.peerURL = srv.URL
+
+	a.tick(ctx) // learns the new epoch
+	a.tick(ctx) // resends everything
+	if srcB.items["10.0.0.0/8"] != "1" {
+		t.Errorf("state not resent after peer restart: %v", srcB.items)
+	}
+}
+
+func TestFailoverAndPreempt(t *testing.T) {
+	a, b, _, _ := pair(t, true)
+	ctx := context.Background()
+
+	var roles []Role
+	b.OnRoleChange(func(r Role) { roles = append(roles, r) })
+
+	a.tick(ctx)
+	b.tick(ctx)
+
+	// a goes away: b promotes itself after the failover timeout.
+	b.peerURL = "http://127.0.0.1:1"
+	now := time.Now()
+	b.now = func() time.Time { return now.Add(time.Duration(b.cfg.FailoverSec+1) * time.Second) }
+	b.tick(ctx)
+	if b.Role() != RoleActive {
+		t.Fatalf("standby not promoted after losing its peer")
+	}
+
+	// a comes back; with preemption it takes the active role back.
+	srvA := httptest.NewServer(a.handler())
+	defer srvA.Close()
+	b.peerURL = srvA.URL
+	b.tick(ctx)
+	if a.Role() != RoleActive || b.Role() != RoleStandby {
+		t.Errorf("after recovery roles = %s/%s, want active/standby", a.Role(), b.Role())
+	}
+	if len(roles) != 2 || roles[0] != RoleActive || roles[1] != RoleStandby {
+		t.Errorf("role callbacks = %v", roles)
+	}
+}
+
+func TestSameNodeIDRejected(t *testing.T) {
+	a, _, _, _ := pair(t, false)
+	a.cfg.NodeID = "node-b"
+	a.tick(context.Background())
+	if a.Status().LastError == "" {
+		t.Error("sync with a duplicate node ID should fail")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Config{NodeID: "a", Role: "active", Listen: ":9443", Peer: "b:9443",
+		TLS: TLSConfig{Cert: "c", Key: "k", CA: "ca"}, IntervalSec: 2, FailoverSec: 10}
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := []func(*Config){
+		func(c *Config) { c.NodeID = "" },
+		func(c *Config) { c.Role = "primary" },
+		func(c *Config) { c.Peer = "" },
+		func(c *Config) { c.TLS.CA = "" },
+		func(c *Config) { c.FailoverSec = 2 },
+	}
+	for i, mutate := range bad {
+		c := valid
+		mutate(&c)
+		if err := c.Validate(); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
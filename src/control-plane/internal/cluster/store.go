@@ -0,0 +1,110 @@
+package cluster
+
+import "sort"
+
+// Version orders writes to a replicated entry. The later timestamp wins;
+// ties are broken by node ID so both peers pick the same winner.
+type Version struct {
+	Time int64  `json:"time"` // Unix nanoseconds
+	Node string `json:"node"`
+}
+
+// newer reports whether v supersedes o.
+func (v Version) newer(o Version) bool {
+	if v.Time != o.Time {
+		return v.Time > o.Time
+	}
+	return v.Node > o.Node
+}
+
+// Entry is one replicated item of state, such as a blacklisted prefix.
+// Deleted entries are kept as tombstones so a removal wins over stale
+// copies still held by the peer.
+type Entry struct {
+	Kind    string  `json:"kind"`
+	ID      string  `json:"id"`
+	Value   string  `json:"value,omitempty"`
+	Deleted bool    `json:"deleted,omitempty"`
+	Version Version `json:"version"`
+}
+
+type entryKey struct {
+	kind, id string
+}
+
+type storedEntry struct {
+	Entry
+	seq      uint64 // local sequence number of the write
+	fromPeer bool   // received from the peer rather than written locally
+}
+
+// store holds the latest version of every replicated entry.
+type store struct {
+	entries map[entryKey]*storedEntry
+	seq     uint64
+}
+
+func newStore() *store {
+	return &store{entries: make(map[entryKey]*storedEntry)}
+}
+
+func (s *store) get(kind, id string) (*storedEntry, bool) {
+	e, ok := s.entries[entryKey{kind, id}]
+	return e, ok
+}
+
+// put stores e under the next sequence number.
+func (s *store) put(e Entry, fromPeer bool) {
+	s.seq++
+	s.entries[entryKey{e.Kind, e.ID}] = &storedEntry{Entry: e, seq: s.seq, fromPeer: fromPeer}
+}
+
+// merge stores an entry received from the peer if it supersedes the local
+// version, and reports whether it did.
+func (s *store) merge(e Entry) bool {
+	if cur, ok := s.get(e.Kind, e.ID); ok && !e.Version.newer(cur.Version) {
+		return false
+	}
+	s.put(e, true)
+	return true
+}
+
+// since returns the entries written after seq in write order, and the
+// sequence number of the last one (seq if there are none). Entries received
+// from the peer are included only if withPeer is set.
+func (s *store) since(seq uint64, withPeer bool) ([]Entry, uint64) {
+	var pending []*storedEntry
+	for _, e := range s.entries {
+		if e.seq > seq && (withPeer || !e.fromPeer) {
+			pending = append(pending, e)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].seq < pending[j].seq })
+
+	entries := make([]Entry, len(pending))
+	for i, e := range pending {
+		entries[i] = e.Entry
+		seq = e.seq
+	}
+	return entries, seq
+}
+
+// prune drops tombstones written before cutoff (Unix nanoseconds).
+func (s *store) prune(cutoff int64) {
+	for k, e := range s.entries {
+		if e.Deleted && e.Version.Time < cutoff {
+			delete(s.entries, k)
+		}
+	}
+}
+
+// live returns the number of entries that are not tombstones.
+func (s *store) live() int {
+	n := 0
+	for _, e := range s.entries {
+		if !e.Deleted {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,254 @@
+// Package compat probes the running kernel and NIC for the BPF features the
+// scrubber depends on, so that kernel-version-dependent code paths (batch
+// map operations, ring buffer events, native XDP) can pick a fallback
+// instead of failing at load or attach time.
+package compat
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/features"
+	"github.com/cilium/ebpf/link"
+	"golang.org/x/sys/unix"
+)
+
+// Status describes whether a scrubber feature will work on this host.
+type Status string
+
+const (
+	Active      Status = "active"      // Fully supported.
+	Degraded    Status = "degraded"    // Works through a slower fallback.
+	Unavailable Status = "unavailable" // Not supported; the feature is off.
+	Unknown     Status = "unknown"     // Probe failed (usually missing privileges).
+)
+
+// Feature is one line of the compatibility report.
+type Feature struct {
+	Name     string `json:"name"`
+	Status   Status `json:"status"`
+	Required bool   `json:"required"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// Features holds the probe results for kernel-version-dependent
+// functionality. A nil error means the feature is supported.
+type Features struct {
+	Kernel string
+	Arch   string
+
+	XDP          error
+	RingBuf      error
+	LPMTrie      error
+	LRUPerCPU    error
+	PerCPUArray  error
+	BatchOps     error
+	XDPMetadata  error
+	BoundedLoops error
+}
+
+var (
+	detectOnce sync.Once
+	detected   *Features
+)
+
+// Detect probes the kernel once and returns the cached result.
+func Detect() *Features {
+	detectOnce.Do(func() {
+		detected = probe()
+	})
+	return detected
+}
+
+// HaveBatchOps reports whether batch map operations (kernel 5.6+) can be
+// used instead of per-key syscalls.
+func (f *Features) HaveBatchOps() bool {
+	return f.BatchOps == nil
+}
+
+func probe() *Features {
+	f := &Features{
+		Kernel: kernelRelease(),
+		Arch:   runtime.GOARCH,
+	}
+
+	f.XDP = features.HaveProgramType(ebpf.XDP)
+	f.RingBuf = features.HaveMapType(ebpf.RingBuf)
+	f.LPMTrie = features.HaveMapType(ebpf.LPMTrie)
+	f.LRUPerCPU = features.HaveMapType(ebpf.LRUCPUHash)
+	f.PerCPUArray = features.HaveMapType(ebpf.PerCPUArray)
+	f.BatchOps = probeBatchOps()
+	f.XDPMetadata = features.HaveProgramHelper(ebpf.XDP, asm.FnXdpAdjustMeta)
+	f.BoundedLoops = features.HaveBoundedLoops()
+
+	return f
+}
+
+// probeBatchOps creates a tiny hash map and attempts a batch update.
+func probeBatchOps() error {
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	_, err = m.BatchUpdate([]uint32{0}, []uint32{0}, nil)
+	return err
+}
+
+// ProbeNativeXDP checks whether the interface's driver supports native
+// (driver-mode) XDP by briefly attaching a pass-through program. It fails
+// if another XDP program is already attached.
+func ProbeNativeXDP(iface string) error {
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		return fmt.Errorf("interface %s: %w", iface, err)
+	}
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type: ebpf.XDP,
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 2), // XDP_PASS
+			asm.Return(),
+		},
+		License: "GPL",
+	})
+	if err != nil {
+		return fmt.Errorf("loading probe program: %w", err)
+	}
+	defer prog.Close()
+
+	l, err := link.AttachXDP(link.XDPOptions{
+		Program:   prog,
+		Interface: ifc.Index,
+		Flags:     link.XDPDriverMode,
+	})
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+// Report turns the probe results into the per-feature lines printed by
+// `scrubber doctor`. nativeXDP is the result of ProbeNativeXDP for the
+// configured interface (nil if supported).
+func (f *Features) Report(iface, xdpMode string, nativeXDP error) []Feature {
+	report := f.kernelFeatures()
+
+	native := Feature{Name: fmt.Sprintf("Native XDP on %s", iface), Status: Active}
+	switch {
+	case xdpMode == "skb":
+		native.Status = Degraded
+		native.Detail = "generic (skb) mode configured"
+	case xdpMode == "offload":
+		native.Status = Unknown
+		native.Detail = "offload mode is not probed"
+	case errors.Is(nativeXDP, unix.EOPNOTSUPP) || errors.Is(nativeXDP, ebpf.ErrNotSupported):
+		native.Status = Degraded
+		native.Detail = "driver lacks native XDP; falls back to generic (skb) mode"
+	case nativeXDP != nil:
+		native.Status = Unknown
+		native.Detail = fmt.Sprintf("probe failed: %v", nativeXDP)
+	}
+	report = append(report, native)
+
+	return report
+}
+
+// Missing returns the kernel features that are not fully available,
+// for explaining why the BPF object failed to load.
+func (f *Features) Missing() []Feature {
+	var missing []Feature
+	for _, ft := range f.kernelFeatures() {
+		if ft.Status != Active {
+			missing = append(missing, ft)
+		}
+	}
+	return missing
+}
+
+// kernelFeatures reports the probed kernel features, without the
+// interface-specific native XDP check.
+func (f *Features) kernelFeatures() []Feature {
+	return []Feature{
+		required("XDP program type", f.XDP, "scrubber cannot run"),
+		required("BPF ring buffer (events)", f.RingBuf, "requires kernel 5.8+"),
+		required("LPM trie maps (ACL, GeoIP, threat intel)", f.LPMTrie, "requires kernel 4.11+"),
+		required("LRU per-CPU hash maps (rate limit, conntrack)", f.LRUPerCPU, "requires kernel 4.10+"),
+		required("Per-CPU array maps (statistics)", f.PerCPUArray, "requires kernel 4.6+"),
+		fallback("Batch map operations", f.BatchOps, "per-key syscalls are used (kernel < 5.6)"),
+		optional("XDP metadata (bpf_xdp_adjust_meta)", f.XDPMetadata, "metadata cannot be passed to the stack"),
+		optional("Bounded loops", f.BoundedLoops, "requires kernel 5.3+"),
+	}
+}
+
+// Usable reports whether every required feature is available.
+func Usable(report []Feature) bool {
+	for _, f := range report {
+		if f.Required && f.Status == Unavailable {
+			return false
+		}
+	}
+	return true
+}
+
+func required(name string, err error, why string) Feature {
+	f := Feature{Name: name, Status: statusOf(err), Required: true}
+	f.Detail = detail(f.Status, err, why)
+	return f
+}
+
+func fallback(name string, err error, why string) Feature {
+	f := Feature{Name: name, Status: statusOf(err)}
+	if f.Status == Unavailable {
+		f.Status = Degraded
+	}
+	f.Detail = detail(f.Status, err, why)
+	return f
+}
+
+func optional(name string, err error, why string) Feature {
+	f := Feature{Name: name, Status: statusOf(err)}
+	f.Detail = detail(f.Status, err, why)
+	return f
+}
+
+func statusOf(err error) Status {
+	switch {
+	case err == nil:
+		return Active
+	case errors.Is(err, ebpf.ErrNotSupported):
+		return Unavailable
+	default:
+		return Unknown
+	}
+}
+
+func detail(st Status, err error, why string) string {
+	switch st {
+	case Active:
+		return ""
+	case Unknown:
+		return fmt.Sprintf("probe failed: %v", err)
+	default:
+		return why
+	}
+}
+
+func kernelRelease() string {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return "unknown"
+	}
+	return unix.ByteSliceToString(uts.Release[:])
+}
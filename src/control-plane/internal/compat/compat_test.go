@@ -0,0 +1,72 @@
+package compat
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+)
+
+func findFeature(t *testing.T, report []Feature, name string) Feature {
+	t.Helper()
+	for _, f := range report {
+		if f.Name == name {
+			return f
+		}
+	}
+	t.Fatalf("feature %q not in report", name)
+	return Feature{}
+}
+
+func TestReportStatuses(t *testing.T) {
+	notSupported := fmt.Errorf("probe: %w", ebpf.ErrNotSupported)
+
+	f := &Features{
+		BatchOps:    notSupported,
+		XDPMetadata: notSupported,
+		RingBuf:     errors.New("operation not permitted"),
+	}
+	report := f.Report("eth0", "native", fmt.Errorf("attach: %w", unix.EOPNOTSUPP))
+
+	if got := findFeature(t, report, "XDP program type").Status; got != Active {
+		t.Errorf("XDP status = %s, want active", got)
+	}
+	if got := findFeature(t, report, "Batch map operations").Status; got != Degraded {
+		t.Errorf("batch ops status = %s, want degraded", got)
+	}
+	if got := findFeature(t, report, "XDP metadata (bpf_xdp_adjust_meta)").Status; got != Unavailable {
+		t.Errorf("XDP metadata status = %s, want unavailable", got)
+	}
+	if got := findFeature(t, report, "BPF ring buffer (events)").Status; got != Unknown {
+		t.Errorf("ring buffer status = %s, want unknown", got)
+	}
+	if got := findFeature(t, report, "Native XDP on eth0").Status; got != Degraded {
+		t.Errorf("native XDP status = %s, want degraded", got)
+	}
+	if !Usable(report) {
+		t.Error("report should be usable: no required feature is unavailable")
+	}
+}
+
+func TestUsableRequiresRingBuf(t *testing.T) {
+	f := &Features{RingBuf: ebpf.ErrNotSupported}
+	if Usable(f.Report("eth0", "skb", nil)) {
+		t.Error("report without ring buffer support should not be usable")
+	}
+}
+
+func TestMissing(t *testing.T) {
+	f := &Features{LPMTrie: ebpf.ErrNotSupported, BatchOps: ebpf.ErrNotSupported}
+	missing := f.Missing()
+	if len(missing) != 2 {
+		t.Fatalf("missing = %+v, want LPM trie and batch ops", missing)
+	}
+	if missing[0].Status != Unavailable || !missing[0].Required {
+		t.Errorf("LPM trie = %+v, want required and unavailable", missing[0])
+	}
+	if missing[1].Status != Degraded {
+		t.Errorf("batch ops status = %s, want degraded", missing[1].Status)
+	}
+}
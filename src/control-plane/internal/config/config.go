@@ -2,10 +2,43 @@
 package config
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/approval"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/assets"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/attacks"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bgp"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/capture"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/cluster"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/diversion"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/egress"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/events"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/fleet"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/geoip"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/httpflood"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/inspect"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/kube"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/maintenance"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/notify"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/rateclass"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/reputation"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/schedule"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/siglearn"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/stats"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/threatintel"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/tunnel"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/watchdog"
 	"gopkg.in/yaml.v3"
 )
 
@@ -13,12 +46,18 @@ import (
 type Config struct {
 	mu sync.RWMutex
 
+	// File the configuration was loaded from ("" if built in code)
+	Path string `yaml:"-"`
+
 	// General
 	Interface string `yaml:"interface"`
 	XDPMode   string `yaml:"xdp_mode"` // "native", "skb", "offload"
 	BPFObject string `yaml:"bpf_object"`
 	LogLevel  string `yaml:"log_level"` // "debug", "info", "warn", "error"
 
+	// Verifier log lines kept when the BPF object fails to load (0 = all)
+	VerifierLogLines int `yaml:"verifier_log_lines"`
+
 	// Scrubber settings
 	Scrubber ScrubberConfig `yaml:"scrubber"`
 
@@ -31,12 +70,127 @@ type Config struct {
 	// Rate limits
 	RateLimit RateLimitConfig `yaml:"rate_limit"`
 
+	// Concurrent TCP connections per source
+	ConnLimit ConnLimitConfig `yaml:"conn_limit"`
+
+	// TLS and QUIC handshakes per source
+	HandshakeFlood HandshakeFloodConfig `yaml:"handshake_flood"`
+
+	// Port scan detection and response
+	PortScan PortScanConfig `yaml:"port_scan"`
+
+	// Outbound scrubbing by a TC egress program: spoofed sources and
+	// amplification responses leaving the protected network
+	Egress egress.Config `yaml:"egress"`
+
+	// Protected prefixes and their per-prefix mitigation policies, used
+	// until assets are managed through the API
+	Assets []assets.Asset `yaml:"assets"`
+
+	// Named rate limits for source and destination prefixes, replacing
+	// the global per-protocol rates; seeds the rate class registry
+	RateClasses []rateclass.Class `yaml:"rate_classes"`
+
 	// ACL
 	Blacklist []string `yaml:"blacklist"` // CIDR list
 	Whitelist []string `yaml:"whitelist"` // CIDR list
 
 	// Amplification ports
 	AmpPorts []AmpPortConfig `yaml:"amp_ports"`
+
+	// Upstream dependencies of the protected service
+	Dependencies DependencyConfig `yaml:"dependencies"`
+
+	// BGP Flowspec / RTBH signaling
+	BGP bgp.Config `yaml:"bgp"`
+
+	// GRE return tunnels for scrubbed traffic and their health checks
+	GRE tunnel.Config `yaml:"gre"`
+
+	// Scrubbing-center mode: attract victim prefixes over BGP during
+	// attacks and re-inject clean traffic over the GRE tunnels
+	Diversion diversion.Config `yaml:"diversion"`
+
+	// IP reputation scoring weights and decay
+	Reputation reputation.Config `yaml:"reputation"`
+
+	// Mitigation profiles applied per escalation level
+	Escalation escalation.Config `yaml:"escalation"`
+
+	// NOC approval of RTBH and GeoIP enforcement at CRITICAL
+	Approval approval.Config `yaml:"approval"`
+
+	// Policy profiles switched on cron-like schedules
+	Schedule schedule.Config `yaml:"schedule"`
+
+	// Pass-all or detached XDP for planned work, via /api/v1/maintenance
+	Maintenance maintenance.Config `yaml:"maintenance"`
+
+	// Reattach the XDP program and restore config map values on drift
+	Watchdog watchdog.Config `yaml:"watchdog"`
+
+	// DaemonSet mode: config from a ScrubberPolicy or ConfigMap, status
+	// reported to the policy
+	Kubernetes kube.Config `yaml:"kubernetes"`
+
+	// Webhook / Slack / PagerDuty notifications
+	Notifications notify.Config `yaml:"notifications"`
+
+	// GeoIP country database
+	GeoIP GeoIPConfig `yaml:"geoip"`
+
+	// Threat intelligence feeds
+	ThreatIntel threatintel.Config `yaml:"threat_intel"`
+
+	// Prefix to ASN data and per-ASN policies
+	ASN ASNConfig `yaml:"asn"`
+
+	// Event enrichment with country, ASN, reputation and reverse DNS
+	Enrichment events.EnrichConfig `yaml:"enrichment"`
+
+	// Userspace sampling and aggregation of ring buffer events
+	EventSampling events.SamplingConfig `yaml:"event_sampling"`
+
+	// Syslog / CEF forwarding of events to a SIEM
+	Syslog events.SyslogConfig `yaml:"syslog"`
+
+	// NATS streaming of events for high-volume consumers
+	NATS events.NATSConfig `yaml:"nats"`
+
+	// PCAP capture of dropped/suspect traffic
+	Capture capture.Config `yaml:"capture"`
+
+	// Attack signature learning from captured packets
+	SignatureLearning siglearn.Config `yaml:"signature_learning"`
+
+	// Deep inspection of sampled or suspect DNS and TLS traffic,
+	// redirected to userspace over AF_XDP
+	Inspect inspect.Config `yaml:"inspect"`
+
+	// HTTP request flood detection from conntrack churn and response size
+	HTTPFlood httpflood.Config `yaml:"http_flood"`
+
+	// Active/standby state synchronization with a peer scrubber
+	Cluster cluster.Config `yaml:"cluster"`
+
+	// Leader election among scrubbers announcing the same anycast
+	// prefixes; only the leader announces BGP routes and pulls threat intel
+	Fleet fleet.Config `yaml:"fleet"`
+
+	// BPF stats collection interval, adjustable at runtime
+	Stats stats.CollectorConfig `yaml:"stats"`
+
+	// Time series of traffic rates for dashboard graphs
+	StatsHistory stats.HistoryConfig `yaml:"stats_history"`
+
+	// Attack session tracking and postmortem reports
+	Attacks attacks.Config `yaml:"attacks"`
+
+	// History of applied configuration for diffs and rollback
+	Revisions RevisionsConfig `yaml:"config_revisions"`
+
+	// Shutdown behavior
+	Shutdown ShutdownConfig `yaml:"shutdown"`
 }
 
 // ScrubberConfig controls the scrubber engine behavior.
@@ -48,18 +202,342 @@ type ScrubberConfig struct {
 	AttackThreshold    uint64 `yaml:"attack_threshold"` // Multiplier x100 (e.g. 300 = 3x)
 }
 
-// APIConfig controls the gRPC API server.
+// Validate checks the attack threshold.
+func (c ScrubberConfig) Validate() error {
+	if c.AttackThreshold < 100 || c.AttackThreshold > 100_000 {
+		return fmt.Errorf("attack_threshold %d out of range (100-100000, i.e. 1x-1000x baseline)", c.AttackThreshold)
+	}
+	return nil
+}
+
+// APIConfig controls the HTTP API server.
 type APIConfig struct {
-	Listen string `yaml:"listen"` // e.g. "0.0.0.0:9090"
-	TLS    bool   `yaml:"tls"`
-	Cert   string `yaml:"cert"`
-	Key    string `yaml:"key"`
+	Listen     string `yaml:"listen"`      // e.g. "0.0.0.0:9090", or "unix:/run/ddos-scrubber/api.sock"
+	SocketMode uint32 `yaml:"socket_mode"` // Permissions of a unix socket (default: 0660)
+	TLS        bool   `yaml:"tls"`
+	Cert       string `yaml:"cert"`
+	Key        string `yaml:"key"`
+
+	// Client certificate verification. With client_ca set, certificates
+	// presented by clients must chain to it; require_client_cert also
+	// rejects clients that present none.
+	ClientCA          string `yaml:"client_ca"`
+	RequireClientCert bool   `yaml:"require_client_cert"`
+
+	// Connection limits. WebSocket streams are exempt from the read and
+	// write timeouts once upgraded.
+	ReadHeaderTimeoutSec uint64 `yaml:"read_header_timeout_sec"`
+	ReadTimeoutSec       uint64 `yaml:"read_timeout_sec"`
+	WriteTimeoutSec      uint64 `yaml:"write_timeout_sec"`
+	IdleTimeoutSec       uint64 `yaml:"idle_timeout_sec"`
+	MaxHeaderBytes       int    `yaml:"max_header_bytes"`
+
+	// WebSocket stream limits and keepalive
+	WebSocket WebSocketConfig `yaml:"websocket"`
+
+	// Authentication and role-based authorization
+	Auth APIAuthConfig `yaml:"auth"`
+
+	// Pprof serves the net/http/pprof profiles under /api/v1/debug/pprof/
+	// to admins. CPU profiles and traces must be shorter than the write
+	// timeout.
+	Pprof bool `yaml:"pprof"`
+}
+
+// WebSocketConfig bounds the realtime streams, WebSocket and SSE alike.
+// Each client has its own send queue; when a slow client lets it fill up, the oldest
+// messages are dropped so broadcasts never wait on one connection.
+type WebSocketConfig struct {
+	MaxClients      int    `yaml:"max_clients"`       // Concurrent streams (default: 100)
+	SendQueue       int    `yaml:"send_queue"`        // Messages queued per client (default: 256)
+	PingIntervalSec uint64 `yaml:"ping_interval_sec"` // Keepalive pings; two missed pongs disconnect (default: 30)
+	WriteTimeoutSec uint64 `yaml:"write_timeout_sec"` // Deadline for each message write (default: 10)
+}
+
+// Validate checks the WebSocket limits.
+func (c WebSocketConfig) Validate() error {
+	if c.MaxClients <= 0 {
+		return fmt.Errorf("max_clients must be positive")
+	}
+	if c.SendQueue <= 0 {
+		return fmt.Errorf("send_queue must be positive")
+	}
+	if c.PingIntervalSec == 0 || c.WriteTimeoutSec == 0 {
+		return fmt.Errorf("ping_interval_sec and write_timeout_sec must be positive")
+	}
+	return nil
+}
+
+// APIAuthConfig maps API keys and client certificate identities to roles.
+// When enabled, every request must present a known key or certificate:
+// viewer may only read, operator may also change ACLs, rate limits and
+// other mitigation settings, and admin may do everything. Keys and clients
+// scoped to a tenant only reach the tenant's own protected assets.
+type APIAuthConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	Keys    []APIKeyConfig    `yaml:"keys"`
+	Clients []APIClientConfig `yaml:"clients"`
+	Tenants []APITenantConfig `yaml:"tenants"`
+}
+
+// APITenantConfig is a customer of the scrubber. A tenant owns the
+// protected assets whose owner is the tenant name.
+type APITenantConfig struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+}
+
+// APIKeyConfig assigns a role to an API key, sent as
+// "Authorization: Bearer KEY".
+type APIKeyConfig struct {
+	Name      string `yaml:"name"` // Identity recorded in logs and the audit log
+	Key       string `yaml:"key"`
+	KeySHA256 string `yaml:"key_sha256"` // Hex SHA-256 of the key, instead of key
+	Role      string `yaml:"role"`       // viewer, operator or admin
+	Tenant    string `yaml:"tenant"`     // Restrict the key to this tenant's assets
+}
+
+// APIClientConfig assigns a role to a client certificate by its subject
+// common name.
+type APIClientConfig struct {
+	CommonName string `yaml:"common_name"`
+	Role       string `yaml:"role"`   // viewer, operator or admin
+	Tenant     string `yaml:"tenant"` // Restrict the client to this tenant's assets
+}
+
+// validateAPIRole checks a role name from the API auth configuration.
+func validateAPIRole(role string) error {
+	switch role {
+	case "viewer", "operator", "admin":
+		return nil
+	}
+	return fmt.Errorf("unknown role %q (want viewer, operator or admin)", role)
+}
+
+// validateAPITenant checks the tenant scope of a key or client. Tenant
+// callers cannot be admins: administration is not scoped.
+func validateAPITenant(tenant, role string, tenants map[string]bool) error {
+	if tenant == "" {
+		return nil
+	}
+	if !tenants[tenant] {
+		return fmt.Errorf("unknown tenant %q", tenant)
+	}
+	if role == "admin" {
+		return fmt.Errorf("tenant %s: role must be viewer or operator", tenant)
+	}
+	return nil
+}
+
+// Validate checks the API auth configuration.
+func (c APIAuthConfig) Validate(clientCA string) error {
+	if !c.Enabled {
+		return nil
+	}
+	if len(c.Keys) == 0 && len(c.Clients) == 0 {
+		return fmt.Errorf("at least one key or client is required when enabled")
+	}
+	tenants := make(map[string]bool, len(c.Tenants))
+	for i, t := range c.Tenants {
+		if t.Name == "" {
+			return fmt.Errorf("tenants[%d]: name is required", i)
+		}
+		if tenants[t.Name] {
+			return fmt.Errorf("tenants[%d]: duplicate name %q", i, t.Name)
+		}
+		tenants[t.Name] = true
+	}
+	names := make(map[string]bool, len(c.Keys))
+	for i, k := range c.Keys {
+		if k.Name == "" {
+			return fmt.Errorf("keys[%d]: name is required", i)
+		}
+		if names[k.Name] {
+			return fmt.Errorf("keys[%d]: duplicate name %q", i, k.Name)
+		}
+		names[k.Name] = true
+		if (k.Key == "") == (k.KeySHA256 == "") {
+			return fmt.Errorf("keys[%d]: exactly one of key and key_sha256 is required", i)
+		}
+		if k.KeySHA256 != "" {
+			if b, err := hex.DecodeString(k.KeySHA256); err != nil || len(b) != sha256.Size {
+				return fmt.Errorf("keys[%d]: key_sha256 must be %d hex characters", i, 2*sha256.Size)
+			}
+		}
+		if err := validateAPIRole(k.Role); err != nil {
+			return fmt.Errorf("keys[%d]: %w", i, err)
+		}
+		if err := validateAPITenant(k.Tenant, k.Role, tenants); err != nil {
+			return fmt.Errorf("keys[%d]: %w", i, err)
+		}
+	}
+	if len(c.Clients) > 0 && clientCA == "" {
+		return fmt.Errorf("clients require api.client_ca")
+	}
+	cns := make(map[string]bool, len(c.Clients))
+	for i, cl := range c.Clients {
+		if cl.CommonName == "" {
+			return fmt.Errorf("clients[%d]: common_name is required", i)
+		}
+		if cns[cl.CommonName] {
+			return fmt.Errorf("clients[%d]: duplicate common_name %q", i, cl.CommonName)
+		}
+		cns[cl.CommonName] = true
+		if err := validateAPIRole(cl.Role); err != nil {
+			return fmt.Errorf("clients[%d]: %w", i, err)
+		}
+		if err := validateAPITenant(cl.Tenant, cl.Role, tenants); err != nil {
+			return fmt.Errorf("clients[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// UnixSocket returns the socket path if Listen names a unix socket.
+func (c APIConfig) UnixSocket() (string, bool) {
+	return strings.CutPrefix(c.Listen, "unix:")
+}
+
+// Validate checks the API configuration.
+func (c APIConfig) Validate() error {
+	if c.Listen == "" {
+		return fmt.Errorf("listen is required")
+	}
+	if path, ok := c.UnixSocket(); ok && path == "" {
+		return fmt.Errorf("listen: unix socket path is required")
+	}
+	if c.SocketMode > 0777 {
+		return fmt.Errorf("socket_mode %o is not a permission mode", c.SocketMode)
+	}
+	if c.TLS && (c.Cert == "" || c.Key == "") {
+		return fmt.Errorf("cert and key are required when tls is enabled")
+	}
+	if c.ClientCA != "" && !c.TLS {
+		return fmt.Errorf("client_ca requires tls")
+	}
+	if c.RequireClientCert && c.ClientCA == "" {
+		return fmt.Errorf("require_client_cert requires client_ca")
+	}
+	if c.MaxHeaderBytes < 0 {
+		return fmt.Errorf("max_header_bytes must not be negative")
+	}
+	if err := c.WebSocket.Validate(); err != nil {
+		return fmt.Errorf("websocket: %w", err)
+	}
+	if err := c.Auth.Validate(c.ClientCA); err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	return nil
 }
 
 // SYNCookieConfig controls SYN cookie behavior.
 type SYNCookieConfig struct {
 	Enabled         bool   `yaml:"enabled"`
 	SeedRotationSec uint64 `yaml:"seed_rotation_sec"` // Seed rotation interval
+
+	// Protected destination prefixes. When any are set, only SYNs to
+	// these destinations are answered with cookies; empty means all.
+	Destinations []string `yaml:"destinations"`
+}
+
+// Validate checks the destination prefixes.
+func (c SYNCookieConfig) Validate() error {
+	for _, d := range c.Destinations {
+		if _, _, err := net.ParseCIDR(d); err != nil {
+			return fmt.Errorf("destinations: %w", err)
+		}
+	}
+	return nil
+}
+
+// ConnLimitConfig caps the concurrent TCP connections a single source
+// may hold. Counting relies on connection tracking.
+type ConnLimitConfig struct {
+	PerSource       uint32            `yaml:"per_source"`        // Default limit; 0 = none
+	Prefixes        map[string]uint32 `yaml:"prefixes"`          // Destination prefix -> limit, overriding per_source
+	SyncIntervalSec uint64            `yaml:"sync_interval_sec"` // How often counts are recounted from conntrack
+}
+
+// Enabled reports whether any connection limit is configured.
+func (c ConnLimitConfig) Enabled() bool {
+	return c.PerSource > 0 || len(c.Prefixes) > 0
+}
+
+// Validate checks the destination prefixes and their limits.
+func (c ConnLimitConfig) Validate() error {
+	if c.PerSource > maxConnLimit {
+		return fmt.Errorf("per_source %d exceeds %d", c.PerSource, maxConnLimit)
+	}
+	for p, limit := range c.Prefixes {
+		if _, _, err := net.ParseCIDR(p); err != nil {
+			return fmt.Errorf("prefixes: %w", err)
+		}
+		if limit == 0 || limit > maxConnLimit {
+			return fmt.Errorf("prefixes: limit for %s must be 1-%d", p, maxConnLimit)
+		}
+	}
+	return nil
+}
+
+// HandshakeFloodConfig limits the TLS and QUIC handshakes a single source
+// may start per second.
+type HandshakeFloodConfig struct {
+	TLSRatePPS  uint64 `yaml:"tls_rate_pps"`  // TLS ClientHellos per second; 0 = no limit
+	QUICRatePPS uint64 `yaml:"quic_rate_pps"` // QUIC Initial packets per second; 0 = no limit
+}
+
+// Validate checks the limits are within what the data plane accepts.
+func (c HandshakeFloodConfig) Validate() error {
+	if c.TLSRatePPS > maxHandshakeRate {
+		return fmt.Errorf("tls_rate_pps %d exceeds %d", c.TLSRatePPS, maxHandshakeRate)
+	}
+	if c.QUICRatePPS > maxHandshakeRate {
+		return fmt.Errorf("quic_rate_pps %d exceeds %d", c.QUICRatePPS, maxHandshakeRate)
+	}
+	return nil
+}
+
+// Port scan responses.
+const (
+	PortScanOff   = "off"
+	PortScanScore = "score" // Raise the source's reputation score
+	PortScanBlock = "block" // Blacklist the source for block_sec
+	PortScanEvent = "event" // Only emit the detection event
+)
+
+// PortScanConfig controls port scan detection: a source touching more than
+// threshold distinct ports within window_sec is flagged and handled by
+// action.
+type PortScanConfig struct {
+	Action      string `yaml:"action"`       // off, score, block or event
+	Threshold   uint32 `yaml:"threshold"`    // Distinct destination ports per window
+	WindowSec   uint64 `yaml:"window_sec"`   // Detection window
+	ScoreWeight uint32 `yaml:"score_weight"` // score: points added per detection
+	BlockSec    uint64 `yaml:"block_sec"`    // block: how long the source stays blacklisted
+}
+
+// Validate checks the port scan action and its parameters.
+func (c PortScanConfig) Validate() error {
+	switch c.Action {
+	case PortScanOff, PortScanEvent:
+	case PortScanScore:
+		if c.ScoreWeight == 0 {
+			return fmt.Errorf("score_weight must be positive")
+		}
+	case PortScanBlock:
+		if c.BlockSec == 0 {
+			return fmt.Errorf("block_sec must be positive")
+		}
+	default:
+		return fmt.Errorf("invalid action %q (must be off, score, block or event)", c.Action)
+	}
+	if c.Threshold == 0 || c.Threshold > 512 {
+		return fmt.Errorf("threshold must be 1-512")
+	}
+	if c.WindowSec == 0 || c.WindowSec > 3600 {
+		return fmt.Errorf("window_sec must be 1-3600")
+	}
+	return nil
 }
 
 // RateLimitConfig controls rate limiting thresholds.
@@ -69,6 +547,75 @@ type RateLimitConfig struct {
 	ICMPRatePPS   uint64 `yaml:"icmp_rate_pps"`   // Per-source ICMP rate
 	GlobalPPS     uint64 `yaml:"global_pps"`       // Global PPS limit
 	GlobalBPS     uint64 `yaml:"global_bps"`       // Global BPS limit
+
+	// Adaptive limits derived from the learned traffic baseline
+	Adaptive AdaptiveRateConfig `yaml:"adaptive"`
+}
+
+// Upper bounds of the rate limits, as accepted by the data plane config map.
+const (
+	maxSourceRatePPS = 100_000_000
+	maxGlobalPPS     = 1_000_000_000
+	maxGlobalBPS     = 1_000_000_000_000
+	maxConnLimit     = 1_000_000
+	maxHandshakeRate = 1_000_000
+)
+
+// Validate checks the limits are within what the data plane accepts and
+// consistent with each other, and the adaptive bounds.
+func (c RateLimitConfig) Validate() error {
+	perSource := []struct {
+		name string
+		pps  uint64
+	}{{"syn_rate_pps", c.SYNRatePPS}, {"udp_rate_pps", c.UDPRatePPS}, {"icmp_rate_pps", c.ICMPRatePPS}}
+	for _, r := range perSource {
+		if r.pps > maxSourceRatePPS {
+			return fmt.Errorf("%s %d exceeds %d", r.name, r.pps, uint64(maxSourceRatePPS))
+		}
+		if c.GlobalPPS > 0 && r.pps > c.GlobalPPS {
+			return fmt.Errorf("%s %d exceeds global_pps %d", r.name, r.pps, c.GlobalPPS)
+		}
+	}
+	if c.GlobalPPS > maxGlobalPPS {
+		return fmt.Errorf("global_pps %d exceeds %d", c.GlobalPPS, uint64(maxGlobalPPS))
+	}
+	if c.GlobalBPS > maxGlobalBPS {
+		return fmt.Errorf("global_bps %d exceeds %d", c.GlobalBPS, uint64(maxGlobalBPS))
+	}
+
+	ad := c.Adaptive
+	if ad.Enabled && ad.IntervalSec == 0 {
+		return fmt.Errorf("adaptive.interval_sec must be positive")
+	}
+	for _, b := range []struct {
+		name string
+		RateBounds
+	}{{"syn", ad.SYN}, {"udp", ad.UDP}, {"icmp", ad.ICMP}} {
+		if b.MaxPPS != 0 && b.MinPPS > b.MaxPPS {
+			return fmt.Errorf("adaptive.%s: min_pps %d exceeds max_pps %d", b.name, b.MinPPS, b.MaxPPS)
+		}
+	}
+	if ad.HysteresisPct >= 100 {
+		return fmt.Errorf("adaptive.hysteresis_pct must be below 100")
+	}
+	return nil
+}
+
+// AdaptiveRateConfig controls how learned baseline rates are pushed into
+// the per-source rate limits.
+type AdaptiveRateConfig struct {
+	Enabled       bool       `yaml:"enabled"`
+	IntervalSec   uint64     `yaml:"interval_sec"`   // Push interval
+	HysteresisPct uint64     `yaml:"hysteresis_pct"` // Skip changes smaller than this
+	SYN           RateBounds `yaml:"syn"`
+	UDP           RateBounds `yaml:"udp"`
+	ICMP          RateBounds `yaml:"icmp"`
+}
+
+// RateBounds clamps an adaptive rate. Zero means unbounded.
+type RateBounds struct {
+	MinPPS uint64 `yaml:"min_pps"`
+	MaxPPS uint64 `yaml:"max_pps"`
 }
 
 // AmpPortConfig defines an amplification-sensitive port.
@@ -77,6 +624,84 @@ type AmpPortConfig struct {
 	Flags uint32 `yaml:"flags"` // Protocol type flags
 }
 
+// Amplification protocol flags of amp_ports entries.
+const (
+	AmpDNS uint32 = 1 << iota
+	AmpNTP
+	AmpSSDP
+	AmpMemcached
+	AmpChargen
+	AmpCLDAP
+	AmpSNMP
+
+	ampAllFlags = AmpSNMP<<1 - 1
+)
+
+// validateAmpPorts checks each port is listed once with known protocol
+// flags.
+func validateAmpPorts(ports []AmpPortConfig) error {
+	seen := make(map[uint16]bool, len(ports))
+	for i, ap := range ports {
+		if ap.Port == 0 {
+			return fmt.Errorf("[%d]: port is required", i)
+		}
+		if seen[ap.Port] {
+			return fmt.Errorf("[%d]: port %d listed twice", i, ap.Port)
+		}
+		seen[ap.Port] = true
+		if ap.Flags == 0 || ap.Flags&^ampAllFlags != 0 {
+			return fmt.Errorf("[%d]: invalid flags %d for port %d (combine 1 DNS, 2 NTP, 4 SSDP, 8 memcached, 16 chargen, 32 CLDAP, 64 SNMP)", i, ap.Flags, ap.Port)
+		}
+	}
+	return nil
+}
+
+// DependencyConfig lists upstream endpoints the protected service relies on
+// (DNS resolvers, payment APIs, CDNs). They are resolved periodically and
+// whitelisted so mitigation never drops their return traffic.
+type DependencyConfig struct {
+	Hosts      []string `yaml:"hosts"`       // Hostnames or literal IPs
+	RefreshSec uint64   `yaml:"refresh_sec"` // Re-resolve interval
+	TTLSec     uint64   `yaml:"ttl_sec"`     // Whitelist lifetime after last resolution
+}
+
+// GeoIPConfig points at the country database loaded into geoip_map.
+type GeoIPConfig struct {
+	Database   string            `yaml:"database"`    // GeoLite2-Country .mmdb, or blocks CSV ("" = none)
+	Locations  string            `yaml:"locations"`   // Locations CSV, required with a blocks CSV
+	Enforce    bool              `yaml:"enforce"`     // Apply country policies in the data plane
+	RateLimits map[string]uint64 `yaml:"rate_limits"` // Country code → pps; sets a rate_limit policy
+
+	// Country policies applied only while escalation is at a level or
+	// above, reverted when it drops
+	LevelPolicies []geoip.LevelPolicy `yaml:"level_policies"`
+
+	// Continent code (AF, AN, AS, EU, NA, OC, SA) or region name → action
+	// for the continent's countries without a policy of their own
+	ContinentPolicies map[string]string   `yaml:"continent_policies"`
+	Regions           map[string][]string `yaml:"regions"` // Region name → continent codes, e.g. APAC: [AS, OC]
+}
+
+// ASNConfig points at the prefix to ASN data loaded into asn_map and sets
+// the initial per-ASN policies.
+type ASNConfig struct {
+	Database string            `yaml:"database"` // GeoLite2-ASN .mmdb or CSV, or "prefix ASN" text ("" = none)
+	Policies map[string]string `yaml:"policies"` // ASN ("64496" or "AS64496") → pass, drop, rate_limit or monitor
+}
+
+// RevisionsConfig controls the numbered history of applied configuration.
+type RevisionsConfig struct {
+	Max         int  `yaml:"max"`          // Revisions kept; the oldest are dropped
+	WriteConfig bool `yaml:"write_config"` // Rewrite the config file on each change and rollback
+}
+
+// ShutdownConfig controls the orderly drain performed on SIGTERM.
+type ShutdownConfig struct {
+	TimeoutSec uint64 `yaml:"timeout_sec"` // Upper bound for the whole drain
+	StateDir   string `yaml:"state_dir"`   // Where state is persisted ("" = don't persist)
+	BGPPolicy  string `yaml:"bgp_policy"`  // "withdraw" or "persist" announcements
+}
+
 // DefaultConfig returns a configuration with reasonable defaults.
 func DefaultConfig() *Config {
 	return &Config{
@@ -91,19 +716,49 @@ func DefaultConfig() *Config {
 			BaselineBPS:      1000000000, // 1 Gbps
 			AttackThreshold:  300,         // 3x
 		},
+		VerifierLogLines: 200,
 		API: APIConfig{
-			Listen: "0.0.0.0:9090",
+			Listen:               "0.0.0.0:9090",
+			SocketMode:           0660,
+			ReadHeaderTimeoutSec: 10,
+			ReadTimeoutSec:       30,
+			WriteTimeoutSec:      60,
+			IdleTimeoutSec:       120,
+			MaxHeaderBytes:       64 << 10,
+			WebSocket: WebSocketConfig{
+				MaxClients:      100,
+				SendQueue:       256,
+				PingIntervalSec: 30,
+				WriteTimeoutSec: 10,
+			},
 		},
 		SYNCookie: SYNCookieConfig{
 			Enabled:         true,
 			SeedRotationSec: 60,
 		},
+		ConnLimit: ConnLimitConfig{
+			SyncIntervalSec: 10,
+		},
+		PortScan: PortScanConfig{
+			Action:      PortScanOff,
+			Threshold:   20,
+			WindowSec:   10,
+			ScoreWeight: 70,
+			BlockSec:    600,
+		},
 		RateLimit: RateLimitConfig{
 			SYNRatePPS:  1000,
 			UDPRatePPS:  10000,
 			ICMPRatePPS: 100,
 			GlobalPPS:   0, // 0 = disabled
 			GlobalBPS:   0,
+			Adaptive: AdaptiveRateConfig{
+				IntervalSec:   30,
+				HysteresisPct: 10,
+				SYN:           RateBounds{MinPPS: 100, MaxPPS: 100000},
+				UDP:           RateBounds{MinPPS: 1000, MaxPPS: 1000000},
+				ICMP:          RateBounds{MinPPS: 10, MaxPPS: 10000},
+			},
 		},
 		AmpPorts: []AmpPortConfig{
 			{Port: 53, Flags: 1},    // DNS
@@ -112,25 +767,148 @@ func DefaultConfig() *Config {
 			{Port: 11211, Flags: 8}, // Memcached
 			{Port: 19, Flags: 16},   // Chargen
 		},
+		Dependencies: DependencyConfig{
+			RefreshSec: 300,
+			TTLSec:     900,
+		},
+		GRE: tunnel.Config{
+			CheckIntervalSec: 5,
+			TimeoutMS:        1000,
+			FailThreshold:    3,
+			RiseThreshold:    2,
+		},
+		Diversion: diversion.Config{
+			StartLevel: "high",
+			HoldSec:    300,
+		},
+		Reputation: reputation.DefaultConfig(),
+		Escalation: escalation.DefaultConfig(),
+		Approval:   approval.DefaultConfig(),
+		Notifications: notify.Config{
+			TimeoutSec: 10,
+			MaxRetries: 3,
+			RatePerMin: 30,
+		},
+		Maintenance: maintenance.DefaultConfig(),
+		Watchdog:    watchdog.DefaultConfig(),
+		Kubernetes:  kube.DefaultConfig(),
+		Fleet:       fleet.DefaultConfig(),
+		Enrichment: events.EnrichConfig{
+			CacheSize:    100000,
+			CacheTTLSec:  3600,
+			DNSWorkers:   4,
+			DNSTimeoutMS: 2000,
+		},
+		EventSampling: events.SamplingConfig{
+			SampleRate:        1,
+			AggregateWindowMS: 1000,
+			MaxKeys:           65536,
+		},
+		Syslog: events.SyslogConfig{
+			Transport:  events.TransportUDP,
+			Format:     events.FormatRFC5424,
+			Facility:   16, // local0
+			AppName:    "ddos-scrubber",
+			DropsOnly:  true,
+			RatePerSec: 1000,
+		},
+		NATS: events.NATSConfig{
+			Subject:     "ddos.events",
+			BatchSize:   500,
+			FlushMS:     100,
+			Compression: events.CompressionGzip,
+			QueueSize:   65536,
+		},
+		Capture: capture.Config{
+			Dir:             "/var/lib/ddos-scrubber/pcap",
+			Snaplen:         256,
+			SampleRate:      1,
+			MaxFileMB:       100,
+			MaxFiles:        10,
+			AutoDurationSec: 300,
+		},
+		SignatureLearning: siglearn.Config{
+			WindowSec:    30,
+			MinPackets:   100,
+			MinSharePct:  20,
+			MaxProposals: 32,
+		},
+		Inspect:   inspect.DefaultConfig(),
+		HTTPFlood: httpflood.DefaultConfig(),
+		Cluster: cluster.Config{
+			Role:        "active",
+			Listen:      "0.0.0.0:9443",
+			IntervalSec: 2,
+			FailoverSec: 10,
+		},
+		Stats:        stats.DefaultCollectorConfig(),
+		StatsHistory: stats.DefaultHistoryConfig(),
+		Attacks:      attacks.DefaultConfig(),
+		Revisions:    RevisionsConfig{Max: 100},
+		Shutdown: ShutdownConfig{
+			TimeoutSec: 15,
+			StateDir:   "/var/lib/ddos-scrubber",
+			BGPPolicy:  "withdraw",
+		},
 	}
 }
 
 // LoadFromFile loads configuration from a YAML file.
-func LoadFromFile(path string) (*Config, error) {
+func LoadFromFile(path string, overrides ...Overrides) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading config file: %w", err)
 	}
+	cfg, err := load(data, nil, overrides)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Path = path
+	return cfg, nil
+}
+
+// LoadFromKubernetes loads configuration read from a ScrubberPolicy or
+// ConfigMap. The kubernetes section comes from the bootstrap
+// configuration: a policy cannot switch Kubernetes mode off or point the
+// scrubber at another policy.
+func LoadFromKubernetes(data []byte, k kube.Config, overrides ...Overrides) (*Config, error) {
+	return load(data, func(c *Config) { c.Kubernetes = k }, overrides)
+}
 
+// load decodes data over the defaults, calls fixup if not nil, applies
+// overrides and validates the result.
+func load(data []byte, fixup func(c *Config), overrides []Overrides) (*Config, error) {
+	// Unknown fields and mistyped values are collected rather than fatal
+	// straight away, so one run reports them together with the first
+	// semantic error.
 	cfg := DefaultConfig()
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return nil, fmt.Errorf("parsing config: %w", err)
+	var errs []error
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("parsing config: %w", err)
+		}
+		for _, e := range typeErr.Errors {
+			errs = append(errs, fmt.Errorf("parsing config: %s", e))
+		}
+	}
+	if fixup != nil {
+		fixup(cfg)
+	}
+	for _, o := range overrides {
+		if err := cfg.ApplyOverrides(o); err != nil {
+			return nil, fmt.Errorf("applying overrides: %w", err)
+		}
 	}
 
 	if err := cfg.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid config: %w", err)
+		errs = append(errs, fmt.Errorf("invalid config: %w", err))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
 	}
-
 	return cfg, nil
 }
 
@@ -139,6 +917,9 @@ func (c *Config) Validate() error {
 	if c.Interface == "" {
 		return fmt.Errorf("interface is required")
 	}
+	if c.Interface == kube.AutoInterface && !c.Kubernetes.Enabled {
+		return fmt.Errorf("interface %q requires kubernetes.enabled", kube.AutoInterface)
+	}
 
 	switch c.XDPMode {
 	case "native", "skb", "offload":
@@ -150,25 +931,370 @@ func (c *Config) Validate() error {
 	if c.BPFObject == "" {
 		return fmt.Errorf("bpf_object path is required")
 	}
+	if c.VerifierLogLines < 0 {
+		return fmt.Errorf("verifier_log_lines must not be negative")
+	}
+
+	if err := c.Scrubber.Validate(); err != nil {
+		return fmt.Errorf("scrubber: %w", err)
+	}
+
+	if err := c.API.Validate(); err != nil {
+		return fmt.Errorf("api: %w", err)
+	}
+
+	if err := c.SYNCookie.Validate(); err != nil {
+		return fmt.Errorf("syn_cookie: %w", err)
+	}
+
+	if c.ConnLimit.Enabled() {
+		if !c.Scrubber.ConntrackEnabled {
+			return fmt.Errorf("conn_limit requires scrubber.conntrack_enabled")
+		}
+		if err := c.ConnLimit.Validate(); err != nil {
+			return fmt.Errorf("conn_limit: %w", err)
+		}
+	}
+
+	if err := c.HandshakeFlood.Validate(); err != nil {
+		return fmt.Errorf("handshake_flood: %w", err)
+	}
+
+	if err := c.PortScan.Validate(); err != nil {
+		return fmt.Errorf("port_scan: %w", err)
+	}
+
+	if c.Egress.Enabled {
+		if err := c.Egress.Validate(); err != nil {
+			return fmt.Errorf("egress: %w", err)
+		}
+	}
+
+	if err := assets.ValidateAll(c.Assets); err != nil {
+		return fmt.Errorf("assets: %w", err)
+	}
+	for _, a := range c.Assets {
+		if len(a.GeoAllow) > 0 && !c.GeoIP.Enforce {
+			return fmt.Errorf("assets: %s: geo_allow requires geoip.enforce", a.Name)
+		}
+	}
+
+	if err := rateclass.ValidateAll(c.RateClasses); err != nil {
+		return fmt.Errorf("rate_classes: %w", err)
+	}
+
+	if err := c.RateLimit.Validate(); err != nil {
+		return fmt.Errorf("rate_limit: %w", err)
+	}
+
+	for name, list := range map[string][]string{"blacklist": c.Blacklist, "whitelist": c.Whitelist} {
+		for _, entry := range list {
+			if err := validateIPv4Prefix(entry); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+
+	if err := validateAmpPorts(c.AmpPorts); err != nil {
+		return fmt.Errorf("amp_ports: %w", err)
+	}
 
-	if c.API.Listen == "" {
-		return fmt.Errorf("api.listen is required")
+	if err := c.Reputation.Validate(); err != nil {
+		return fmt.Errorf("reputation: %w", err)
 	}
 
+	if err := c.Escalation.Validate(); err != nil {
+		return fmt.Errorf("escalation: %w", err)
+	}
+
+	if err := c.Approval.Validate(); err != nil {
+		return fmt.Errorf("approval: %w", err)
+	}
+
+	if err := c.Schedule.Validate(); err != nil {
+		return fmt.Errorf("schedule: %w", err)
+	}
+	if c.Schedule.UsesGeoIP() && !c.GeoIP.Enforce {
+		return fmt.Errorf("schedule: profiles with country policies require geoip.enforce")
+	}
+
+	if err := c.Maintenance.Validate(); err != nil {
+		return fmt.Errorf("maintenance: %w", err)
+	}
+
+	if c.Watchdog.Enabled {
+		if err := c.Watchdog.Validate(); err != nil {
+			return fmt.Errorf("watchdog: %w", err)
+		}
+	}
+
+	if c.Kubernetes.Enabled {
+		if err := c.Kubernetes.Validate(); err != nil {
+			return fmt.Errorf("kubernetes: %w", err)
+		}
+	}
+
+	if c.ThreatIntel.Enabled {
+		if err := c.ThreatIntel.Validate(); err != nil {
+			return fmt.Errorf("threat_intel: %w", err)
+		}
+	}
+
+	if c.Notifications.Enabled {
+		if err := c.Notifications.Validate(); err != nil {
+			return fmt.Errorf("notifications: %w", err)
+		}
+	}
+
+	if db := c.GeoIP.Database; db != "" && !strings.EqualFold(filepath.Ext(db), ".mmdb") && c.GeoIP.Locations == "" {
+		return fmt.Errorf("geoip.locations is required for CSV data")
+	}
+	if (c.GeoIP.Enforce || len(c.GeoIP.RateLimits) > 0) && c.GeoIP.Database == "" {
+		return fmt.Errorf("geoip.enforce and geoip.rate_limits require geoip.database")
+	}
+	if len(c.GeoIP.RateLimits) > 0 && !c.GeoIP.Enforce {
+		return fmt.Errorf("geoip.rate_limits require geoip.enforce")
+	}
+	if len(c.GeoIP.LevelPolicies) > 0 && c.GeoIP.Database == "" {
+		return fmt.Errorf("geoip.level_policies require geoip.database")
+	}
+	for i, p := range c.GeoIP.LevelPolicies {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("geoip.level_policies[%d]: %w", i, err)
+		}
+	}
+	if len(c.GeoIP.ContinentPolicies) > 0 && c.GeoIP.Database == "" {
+		return fmt.Errorf("geoip.continent_policies require geoip.database")
+	}
+	if err := geoip.ValidateRegions(c.GeoIP.Regions); err != nil {
+		return fmt.Errorf("geoip.regions: %w", err)
+	}
+	for name, action := range c.GeoIP.ContinentPolicies {
+		if _, ok := c.GeoIP.Regions[name]; !ok && !geoip.IsContinent(name) {
+			return fmt.Errorf("geoip.continent_policies: unknown continent or region %q", name)
+		}
+		if _, err := geoip.ParseAction(action); err != nil {
+			return fmt.Errorf("geoip.continent_policies[%s]: %w", name, err)
+		}
+	}
+	for cc, pps := range c.GeoIP.RateLimits {
+		if len(cc) != 2 {
+			return fmt.Errorf("geoip.rate_limits: invalid country code %q", cc)
+		}
+		if pps == 0 {
+			return fmt.Errorf("geoip.rate_limits[%s] must be positive", cc)
+		}
+	}
+
+	if len(c.ASN.Policies) > 0 && c.ASN.Database == "" {
+		return fmt.Errorf("asn.policies require asn.database")
+	}
+	for asn, action := range c.ASN.Policies {
+		if _, err := geoip.ParseASN(asn); err != nil {
+			return fmt.Errorf("asn.policies: %w", err)
+		}
+		if _, err := geoip.ParseAction(action); err != nil {
+			return fmt.Errorf("asn.policies[%s]: %w", asn, err)
+		}
+	}
+
+	if c.Enrichment.Enabled {
+		if err := c.Enrichment.Validate(); err != nil {
+			return fmt.Errorf("enrichment: %w", err)
+		}
+	}
+
+	if c.EventSampling.Enabled {
+		if err := c.EventSampling.Validate(); err != nil {
+			return fmt.Errorf("event_sampling: %w", err)
+		}
+	}
+
+	if c.Syslog.Enabled {
+		if err := c.Syslog.Validate(); err != nil {
+			return fmt.Errorf("syslog: %w", err)
+		}
+	}
+
+	if c.NATS.Enabled {
+		if err := c.NATS.Validate(); err != nil {
+			return fmt.Errorf("nats: %w", err)
+		}
+	}
+
+	if c.Capture.Enabled {
+		if err := c.Capture.Validate(); err != nil {
+			return fmt.Errorf("capture: %w", err)
+		}
+	}
+
+	if c.SignatureLearning.Enabled {
+		if !c.Capture.Enabled {
+			return fmt.Errorf("signature_learning requires capture.enabled")
+		}
+		if err := c.SignatureLearning.Validate(); err != nil {
+			return fmt.Errorf("signature_learning: %w", err)
+		}
+	}
+
+	if c.Inspect.Enabled {
+		if err := c.Inspect.Validate(); err != nil {
+			return fmt.Errorf("inspect: %w", err)
+		}
+	}
+
+	if c.HTTPFlood.Enabled {
+		if err := c.HTTPFlood.Validate(); err != nil {
+			return fmt.Errorf("http_flood: %w", err)
+		}
+	}
+
+	if c.Cluster.Enabled {
+		if err := c.Cluster.Validate(); err != nil {
+			return fmt.Errorf("cluster: %w", err)
+		}
+	}
+
+	if c.Fleet.Enabled {
+		if err := c.Fleet.Validate(); err != nil {
+			return fmt.Errorf("fleet: %w", err)
+		}
+	}
+
+	if c.GRE.Enabled {
+		if err := c.GRE.Validate(); err != nil {
+			return fmt.Errorf("gre: %w", err)
+		}
+	}
+
+	if c.BGP.Enabled && c.BGP.AutoFlowspec && c.BGP.FlowspecDstPrefix == "" {
+		return fmt.Errorf("bgp.auto_flowspec requires bgp.flowspec_dst_prefix")
+	}
+
+	if c.Diversion.Enabled {
+		if !c.BGP.Enabled || !c.GRE.Enabled {
+			return fmt.Errorf("diversion requires bgp.enabled and gre.enabled")
+		}
+		if err := c.Diversion.Validate(); err != nil {
+			return fmt.Errorf("diversion: %w", err)
+		}
+	}
+
+	if err := c.Stats.Validate(); err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+
+	if err := c.StatsHistory.Validate(); err != nil {
+		return fmt.Errorf("stats_history: %w", err)
+	}
+
+	if err := c.Attacks.Validate(); err != nil {
+		return fmt.Errorf("attacks: %w", err)
+	}
+
+	if err := c.validateTLSFiles(); err != nil {
+		return err
+	}
+
+	if c.Revisions.Max <= 0 {
+		return fmt.Errorf("config_revisions.max must be positive")
+	}
+
+	switch c.Shutdown.BGPPolicy {
+	case "withdraw", "persist":
+		// ok
+	default:
+		return fmt.Errorf("invalid shutdown.bgp_policy: %s (must be withdraw or persist)", c.Shutdown.BGPPolicy)
+	}
+
+	return nil
+}
+
+// validateIPv4Prefix checks an ACL entry: an IPv4 CIDR or address.
+func validateIPv4Prefix(s string) error {
+	if _, ipNet, err := net.ParseCIDR(s); err == nil {
+		if ipNet.IP.To4() == nil {
+			return fmt.Errorf("%s is not an IPv4 prefix", s)
+		}
+		return nil
+	}
+	if ip := net.ParseIP(s); ip == nil || ip.To4() == nil {
+		return fmt.Errorf("invalid IPv4 CIDR or address %q", s)
+	}
+	return nil
+}
+
+// validateTLSFiles checks that the certificates, keys and CA bundles of
+// the enabled sections exist, so a typo fails at startup rather than at
+// the first connection.
+func (c *Config) validateTLSFiles() error {
+	type file struct{ name, path string }
+	var files []file
+	if c.API.TLS {
+		files = append(files, file{"api.cert", c.API.Cert}, file{"api.key", c.API.Key}, file{"api.client_ca", c.API.ClientCA})
+	}
+	if c.Syslog.Enabled && c.Syslog.Transport == "tls" {
+		files = append(files, file{"syslog.tls.ca", c.Syslog.TLS.CA})
+	}
+	if c.NATS.Enabled {
+		files = append(files, file{"nats.tls.ca", c.NATS.TLS.CA})
+	}
+	if c.ThreatIntel.Enabled {
+		h := c.ThreatIntel.HTTP
+		files = append(files, file{"threat_intel.http.ca", h.CA}, file{"threat_intel.http.cert", h.Cert}, file{"threat_intel.http.key", h.Key})
+	}
+	if c.Cluster.Enabled && !c.Cluster.TLS.Insecure {
+		t := c.Cluster.TLS
+		files = append(files, file{"cluster.tls.cert", t.Cert}, file{"cluster.tls.key", t.Key}, file{"cluster.tls.ca", t.CA})
+	}
+	if c.Fleet.Enabled && !c.Fleet.TLS.Insecure {
+		t := c.Fleet.TLS
+		files = append(files, file{"fleet.tls.cert", t.Cert}, file{"fleet.tls.key", t.Key}, file{"fleet.tls.ca", t.CA})
+	}
+
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		info, err := os.Stat(f.path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("%s: %s is a directory", f.name, f.path)
+		}
+	}
 	return nil
 }
 
 // SaveToFile writes the current configuration to a YAML file.
 func (c *Config) SaveToFile(path string) error {
+	data, err := c.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// Marshal returns the current configuration as YAML (thread-safe).
+func (c *Config) Marshal() ([]byte, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	data, err := yaml.Marshal(c)
 	if err != nil {
-		return fmt.Errorf("marshaling config: %w", err)
+		return nil, fmt.Errorf("marshaling config: %w", err)
 	}
+	return data, nil
+}
 
-	return os.WriteFile(path, data, 0644)
+// Update changes the configuration under its lock, for settings changed
+// at runtime.
+func (c *Config) Update(fn func(c *Config)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(c)
 }
 
 // GetRateLimit returns the current rate limit config (thread-safe).
@@ -3,7 +3,18 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/assets"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/cluster"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/kube"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/notify"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/rateclass"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/schedule"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/threatintel"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/tunnel"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -67,11 +78,234 @@ func TestValidate(t *testing.T) {
 			modify:  func(c *Config) { c.BPFObject = "" },
 			wantErr: true,
 		},
+		{
+			name:    "negative verifier_log_lines",
+			modify:  func(c *Config) { c.VerifierLogLines = -1 },
+			wantErr: true,
+		},
+		{
+			name:    "invalid syn_cookie destination",
+			modify:  func(c *Config) { c.SYNCookie.Destinations = []string{"203.0.113.0/33"} },
+			wantErr: true,
+		},
+		{
+			name: "gre tunnel without endpoint",
+			modify: func(c *Config) {
+				c.GRE.Enabled = true
+				c.GRE.Tunnels = []tunnel.Tunnel{{Name: "dc1", Prefix: "203.0.113.0/24"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "diversion without gre",
+			modify: func(c *Config) {
+				c.Diversion.Enabled = true
+				c.Diversion.Prefixes = []string{"203.0.113.0/24"}
+				c.Diversion.LocalIP = "192.0.2.254"
+			},
+			wantErr: true,
+		},
+		{
+			name: "auto flowspec without destination",
+			modify: func(c *Config) {
+				c.BGP.Enabled = true
+				c.BGP.AutoFlowspec = true
+			},
+			wantErr: true,
+		},
+		{
+			name: "conn limit without conntrack",
+			modify: func(c *Config) {
+				c.Scrubber.ConntrackEnabled = false
+				c.ConnLimit.PerSource = 1000
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero conn limit for prefix",
+			modify: func(c *Config) {
+				c.ConnLimit.Prefixes = map[string]uint32{"203.0.113.0/24": 0}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "quic rate too high",
+			modify:  func(c *Config) { c.HandshakeFlood.QUICRatePPS = 2_000_000 },
+			wantErr: true,
+		},
+		{
+			name:    "invalid port scan action",
+			modify:  func(c *Config) { c.PortScan.Action = "drop" },
+			wantErr: true,
+		},
+		{
+			name: "port scan block without duration",
+			modify: func(c *Config) {
+				c.PortScan.Action = PortScanBlock
+				c.PortScan.BlockSec = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "egress spoof blocking without local prefixes",
+			modify: func(c *Config) {
+				c.Egress.Enabled = true
+				c.Egress.BlockSpoofed = true
+			},
+			wantErr: true,
+		},
+		{
+			name: "inspection without ports",
+			modify: func(c *Config) {
+				c.Inspect.Enabled = true
+				c.Inspect.DNS.Ports = nil
+				c.Inspect.TLS.Ports = nil
+			},
+			wantErr: true,
+		},
+		{
+			name: "http flood escalating to low",
+			modify: func(c *Config) {
+				c.HTTPFlood.Enabled = true
+				c.HTTPFlood.Escalate = "low"
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate asset prefix",
+			modify: func(c *Config) {
+				c.Assets = []assets.Asset{
+					{Name: "web", Prefix: "203.0.113.0/24"},
+					{Name: "mail", Prefix: "203.0.113.0/24"},
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "asset geo_allow without geoip enforcement",
+			modify: func(c *Config) {
+				c.Assets = []assets.Asset{{Name: "shop", Prefix: "203.0.113.0/24", GeoAllow: []string{"DE"}}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "rate class without limits",
+			modify: func(c *Config) {
+				c.RateClasses = []rateclass.Class{{Name: "dns-servers", Sources: []string{"192.0.2.53/32"}}}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "maintenance unknown mode",
+			modify:  func(c *Config) { c.Maintenance.Mode = "off" },
+			wantErr: true,
+		},
+		{
+			name:    "maintenance max timeout below default",
+			modify:  func(c *Config) { c.Maintenance.MaxTimeoutSec = 60 },
+			wantErr: true,
+		},
+		{
+			name:    "auto interface outside kubernetes",
+			modify:  func(c *Config) { c.Interface = "auto" },
+			wantErr: true,
+		},
+		{
+			name: "kubernetes without policy",
+			modify: func(c *Config) {
+				c.Kubernetes.Enabled = true
+				c.Kubernetes.Policy = ""
+			},
+			wantErr: true,
+		},
+		{
+			name:    "watchdog zero interval",
+			modify:  func(c *Config) { c.Watchdog.IntervalSec = 0 },
+			wantErr: true,
+		},
+		{
+			name: "watchdog disabled skips interval",
+			modify: func(c *Config) {
+				c.Watchdog.Enabled = false
+				c.Watchdog.IntervalSec = 0
+			},
+			wantErr: false,
+		},
+		{
+			name: "schedule entry with unknown profile",
+			modify: func(c *Config) {
+				c.Schedule.Entries = []schedule.Entry{{Cron: "0 20 * * *", Profile: "night"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "schedule country policy without geoip enforce",
+			modify: func(c *Config) {
+				c.Schedule.Profiles = map[string]schedule.Profile{"night": {GeoIP: map[string]string{"CN": "drop"}}}
+				c.Schedule.Entries = []schedule.Entry{{Cron: "0 20 * * *", Profile: "night"}}
+			},
+			wantErr: true,
+		},
 		{
 			name:    "empty api listen",
 			modify:  func(c *Config) { c.API.Listen = "" },
 			wantErr: true,
 		},
+		{
+			name:    "api unix socket",
+			modify:  func(c *Config) { c.API.Listen = "unix:/run/ddos-scrubber/api.sock" },
+			wantErr: false,
+		},
+		{
+			name: "api client cert without tls",
+			modify: func(c *Config) {
+				c.API.ClientCA = "/etc/ddos-scrubber/tls/clients.crt"
+				c.API.RequireClientCert = true
+			},
+			wantErr: true,
+		},
+		{
+			name: "api auth key",
+			modify: func(c *Config) {
+				c.API.Auth.Enabled = true
+				c.API.Auth.Keys = []APIKeyConfig{{Name: "noc", Key: "secret", Role: "operator"}}
+			},
+			wantErr: false,
+		},
+		{
+			name: "api auth unknown role",
+			modify: func(c *Config) {
+				c.API.Auth.Enabled = true
+				c.API.Auth.Keys = []APIKeyConfig{{Name: "noc", Key: "secret", Role: "root"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "api auth key with unknown tenant",
+			modify: func(c *Config) {
+				c.API.Auth.Enabled = true
+				c.API.Auth.Tenants = []APITenantConfig{{Name: "acme"}}
+				c.API.Auth.Keys = []APIKeyConfig{{Name: "portal", Key: "secret", Role: "viewer", Tenant: "globex"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "api auth tenant admin",
+			modify: func(c *Config) {
+				c.API.Auth.Enabled = true
+				c.API.Auth.Tenants = []APITenantConfig{{Name: "acme"}}
+				c.API.Auth.Keys = []APIKeyConfig{{Name: "portal", Key: "secret", Role: "admin", Tenant: "acme"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "api auth client without client ca",
+			modify: func(c *Config) {
+				c.API.Auth.Enabled = true
+				c.API.Auth.Clients = []APIClientConfig{{CommonName: "ops", Role: "admin"}}
+			},
+			wantErr: true,
+		},
 		{
 			name:    "offload mode valid",
 			modify:  func(c *Config) { c.XDPMode = "offload" },
@@ -82,6 +316,383 @@ func TestValidate(t *testing.T) {
 			modify:  func(c *Config) { c.XDPMode = "skb" },
 			wantErr: false,
 		},
+		{
+			name:    "persist bgp policy valid",
+			modify:  func(c *Config) { c.Shutdown.BGPPolicy = "persist" },
+			wantErr: false,
+		},
+		{
+			name:    "adaptive min above max",
+			modify:  func(c *Config) { c.RateLimit.Adaptive.SYN = RateBounds{MinPPS: 500, MaxPPS: 100} },
+			wantErr: true,
+		},
+		{
+			name:    "adaptive unbounded max",
+			modify:  func(c *Config) { c.RateLimit.Adaptive.UDP = RateBounds{MinPPS: 500} },
+			wantErr: false,
+		},
+		{
+			name:    "adaptive hysteresis too large",
+			modify:  func(c *Config) { c.RateLimit.Adaptive.HysteresisPct = 100 },
+			wantErr: true,
+		},
+		{
+			name: "notification target without url",
+			modify: func(c *Config) {
+				c.Notifications.Enabled = true
+				c.Notifications.Targets = []notify.Target{{Type: "slack"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "pagerduty target valid",
+			modify: func(c *Config) {
+				c.Notifications.Enabled = true
+				c.Notifications.Targets = []notify.Target{{Type: "pagerduty", RoutingKey: "abc"}}
+			},
+			wantErr: false,
+		},
+		{
+			name: "capture snaplen too large",
+			modify: func(c *Config) {
+				c.Capture.Enabled = true
+				c.Capture.Snaplen = 9000
+			},
+			wantErr: true,
+		},
+		{
+			name: "capture auto level low",
+			modify: func(c *Config) {
+				c.Capture.Enabled = true
+				c.Capture.AutoLevel = "low"
+			},
+			wantErr: true,
+		},
+		{
+			name: "capture auto at high",
+			modify: func(c *Config) {
+				c.Capture.Enabled = true
+				c.Capture.AutoLevel = "HIGH"
+			},
+			wantErr: false,
+		},
+		{
+			name:    "signature learning without capture",
+			modify:  func(c *Config) { c.SignatureLearning.Enabled = true },
+			wantErr: true,
+		},
+		{
+			name: "signature learning with capture",
+			modify: func(c *Config) {
+				c.Capture.Enabled = true
+				c.SignatureLearning.Enabled = true
+			},
+			wantErr: false,
+		},
+		{
+			name:    "geoip csv without locations",
+			modify:  func(c *Config) { c.GeoIP.Database = "/var/lib/GeoLite2-Country-Blocks-IPv4.csv" },
+			wantErr: true,
+		},
+		{
+			name: "reputation exponential decay",
+			modify: func(c *Config) {
+				c.Reputation.Decay = "exponential"
+				c.Reputation.DecayHalfLifeSec = 600
+			},
+			wantErr: false,
+		},
+		{
+			name:    "reputation unknown decay",
+			modify:  func(c *Config) { c.Reputation.Decay = "step" },
+			wantErr: true,
+		},
+		{
+			name: "escalation profile halving SYN limits",
+			modify: func(c *Config) {
+				c.Escalation.Profiles["high"] = escalation.Profile{SYNRatePct: 50, DNSValidation: "basic"}
+			},
+			wantErr: false,
+		},
+		{
+			name:    "escalation profile bgp below critical",
+			modify:  func(c *Config) { c.Escalation.Profiles["medium"] = escalation.Profile{BGP: true} },
+			wantErr: true,
+		},
+		{
+			name: "threat intel builtin and custom feeds",
+			modify: func(c *Config) {
+				c.ThreatIntel.Enabled = true
+				c.ThreatIntel.Feeds = []threatintel.FeedConfig{
+					{Name: "abuseipdb", APIKey: "key"},
+					{Name: "firehol", URL: "https://iplists.firehol.org/files/firehol_level1.netset", Type: "plaintext", SyncIntervalSec: 600},
+				}
+			},
+			wantErr: false,
+		},
+		{
+			name: "threat intel feed url without type",
+			modify: func(c *Config) {
+				c.ThreatIntel.Enabled = true
+				c.ThreatIntel.Feeds = []threatintel.FeedConfig{{Name: "x", URL: "https://example.com/list.txt"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "stats history retention below resolution",
+			modify: func(c *Config) {
+				c.StatsHistory.RetentionSec = 5
+			},
+			wantErr: true,
+		},
+		{
+			name: "websocket without send queue",
+			modify: func(c *Config) {
+				c.API.WebSocket.SendQueue = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "stats interval too short",
+			modify: func(c *Config) {
+				c.Stats.IntervalMS = 1
+			},
+			wantErr: true,
+		},
+		{
+			name: "attacks without quiet period",
+			modify: func(c *Config) {
+				c.Attacks.EndQuietSec = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "geoip country rate limits",
+			modify: func(c *Config) {
+				c.GeoIP.Database = "/var/lib/GeoLite2-Country.mmdb"
+				c.GeoIP.Enforce = true
+				c.GeoIP.RateLimits = map[string]uint64{"CN": 50000, "ru": 20000}
+			},
+			wantErr: false,
+		},
+		{
+			name: "geoip rate limits without enforcement",
+			modify: func(c *Config) {
+				c.GeoIP.Database = "/var/lib/GeoLite2-Country.mmdb"
+				c.GeoIP.RateLimits = map[string]uint64{"CN": 50000}
+			},
+			wantErr: true,
+		},
+		{
+			name: "geoip continent and region policies",
+			modify: func(c *Config) {
+				c.GeoIP.Database = "/var/lib/GeoLite2-Country.mmdb"
+				c.GeoIP.Regions = map[string][]string{"APAC": {"AS", "oc"}}
+				c.GeoIP.ContinentPolicies = map[string]string{"AF": "monitor", "APAC": "rate_limit"}
+			},
+			wantErr: false,
+		},
+		{
+			name: "geoip continent policy for unknown region",
+			modify: func(c *Config) {
+				c.GeoIP.Database = "/var/lib/GeoLite2-Country.mmdb"
+				c.GeoIP.ContinentPolicies = map[string]string{"APAC": "rate_limit"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "geoip region shadowing a continent",
+			modify: func(c *Config) {
+				c.GeoIP.Database = "/var/lib/GeoLite2-Country.mmdb"
+				c.GeoIP.Regions = map[string][]string{"EU": {"EU", "AF"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "asn policies",
+			modify: func(c *Config) {
+				c.ASN.Database = "/var/lib/GeoLite2-ASN.mmdb"
+				c.ASN.Policies = map[string]string{"AS64496": "drop", "64511": "rate_limit"}
+			},
+			wantErr: false,
+		},
+		{
+			name: "asn policy with invalid action",
+			modify: func(c *Config) {
+				c.ASN.Database = "/var/lib/GeoLite2-ASN.mmdb"
+				c.ASN.Policies = map[string]string{"64496": "block"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "enrichment reverse dns without workers",
+			modify: func(c *Config) {
+				c.Enrichment.Enabled = true
+				c.Enrichment.ReverseDNS = true
+				c.Enrichment.DNSWorkers = 0
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no config revisions kept",
+			modify:  func(c *Config) { c.Revisions.Max = 0 },
+			wantErr: true,
+		},
+		{
+			name: "event sampling unknown attack type",
+			modify: func(c *Config) {
+				c.EventSampling.Enabled = true
+				c.EventSampling.SampleRates = map[string]int{"syn_storm": 10}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "syslog without address",
+			modify:  func(c *Config) { c.Syslog.Enabled = true },
+			wantErr: true,
+		},
+		{
+			name: "syslog cef over tls",
+			modify: func(c *Config) {
+				c.Syslog.Enabled = true
+				c.Syslog.Address = "siem.example.com:6514"
+				c.Syslog.Transport = "tls"
+				c.Syslog.Format = "cef"
+			},
+			wantErr: false,
+		},
+		{
+			name:    "nats without url",
+			modify:  func(c *Config) { c.NATS.Enabled = true },
+			wantErr: true,
+		},
+		{
+			name: "nats tls url",
+			modify: func(c *Config) {
+				c.NATS.Enabled = true
+				c.NATS.URL = "tls://nats.example.com:4222"
+			},
+			wantErr: false,
+		},
+		{
+			name:    "cluster without node id",
+			modify:  func(c *Config) { c.Cluster.Enabled = true },
+			wantErr: true,
+		},
+		{
+			name: "cluster insecure",
+			modify: func(c *Config) {
+				c.Cluster.Enabled = true
+				c.Cluster.NodeID = "scrubber-a"
+				c.Cluster.Peer = "10.0.0.2:9443"
+				c.Cluster.TLS.Insecure = true
+			},
+			wantErr: false,
+		},
+		{
+			name:    "fleet without peers",
+			modify:  func(c *Config) { c.Fleet.Enabled, c.Fleet.NodeID = true, "pop-ams" },
+			wantErr: true,
+		},
+		{
+			name: "fleet insecure",
+			modify: func(c *Config) {
+				c.Fleet.Enabled = true
+				c.Fleet.NodeID = "pop-ams"
+				c.Fleet.Peers = []string{"10.1.0.2:9444", "10.2.0.2:9444"}
+				c.Fleet.TLS.Insecure = true
+			},
+			wantErr: false,
+		},
+		{
+			name: "fleet reputation ttl below interval",
+			modify: func(c *Config) {
+				c.Fleet.Enabled = true
+				c.Fleet.NodeID = "pop-ams"
+				c.Fleet.Peers = []string{"10.1.0.2:9444"}
+				c.Fleet.TLS.Insecure = true
+				c.Fleet.Reputation.Enabled = true
+				c.Fleet.Reputation.TTLSec = 5
+			},
+			wantErr: true,
+		},
+		{
+			name:    "attack threshold below baseline",
+			modify:  func(c *Config) { c.Scrubber.AttackThreshold = 50 },
+			wantErr: true,
+		},
+		{
+			name: "per-source rate above global limit",
+			modify: func(c *Config) {
+				c.RateLimit.GlobalPPS = 5000
+				c.RateLimit.UDPRatePPS = 10000
+			},
+			wantErr: true,
+		},
+		{
+			name:    "per-source rate out of range",
+			modify:  func(c *Config) { c.RateLimit.SYNRatePPS = 200_000_000 },
+			wantErr: true,
+		},
+		{
+			name: "adaptive rate without interval",
+			modify: func(c *Config) {
+				c.RateLimit.Adaptive.Enabled = true
+				c.RateLimit.Adaptive.IntervalSec = 0
+			},
+			wantErr: true,
+		},
+		{
+			name:    "acl addresses and prefixes",
+			modify:  func(c *Config) { c.Blacklist = []string{"198.51.100.0/24", "192.0.2.7"} },
+			wantErr: false,
+		},
+		{
+			name:    "invalid blacklist entry",
+			modify:  func(c *Config) { c.Blacklist = []string{"198.51.100.0/33"} },
+			wantErr: true,
+		},
+		{
+			name:    "ipv6 whitelist entry",
+			modify:  func(c *Config) { c.Whitelist = []string{"2001:db8::/32"} },
+			wantErr: true,
+		},
+		{
+			name:    "amp port with unknown flag",
+			modify:  func(c *Config) { c.AmpPorts = []AmpPortConfig{{Port: 5353, Flags: 128}} },
+			wantErr: true,
+		},
+		{
+			name: "amp port listed twice",
+			modify: func(c *Config) {
+				c.AmpPorts = []AmpPortConfig{{Port: 53, Flags: AmpDNS}, {Port: 53, Flags: AmpDNS | AmpCLDAP}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "api tls cert missing",
+			modify: func(c *Config) {
+				c.API.TLS = true
+				c.API.Cert = "/nonexistent/server.crt"
+				c.API.Key = "/nonexistent/server.key"
+			},
+			wantErr: true,
+		},
+		{
+			name: "cluster tls ca missing",
+			modify: func(c *Config) {
+				c.Cluster.Enabled = true
+				c.Cluster.NodeID = "scrubber-a"
+				c.Cluster.Peer = "10.0.0.2:9443"
+				c.Cluster.TLS = cluster.TLSConfig{Cert: "/nonexistent/c.crt", Key: "/nonexistent/c.key", CA: "/nonexistent/ca.crt"}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "invalid bgp policy",
+			modify:  func(c *Config) { c.Shutdown.BGPPolicy = "keep" },
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -96,6 +707,29 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestLoadFromKubernetes(t *testing.T) {
+	k := kube.DefaultConfig()
+	k.Enabled = true
+	k.Policy = "edge"
+
+	// Policies arrive as JSON; the kubernetes section is the bootstrap's.
+	data := `{"interface":"auto","rate_limit":{"syn_rate_pps":500},"kubernetes":{"enabled":false}}`
+	cfg, err := LoadFromKubernetes([]byte(data), k, Overrides{"log_level": "debug"})
+	if err != nil {
+		t.Fatalf("LoadFromKubernetes() error: %v", err)
+	}
+	if cfg.Interface != "auto" || cfg.RateLimit.SYNRatePPS != 500 || cfg.LogLevel != "debug" {
+		t.Errorf("config = interface %s, syn_rate_pps %d, log_level %s", cfg.Interface, cfg.RateLimit.SYNRatePPS, cfg.LogLevel)
+	}
+	if !cfg.Kubernetes.Enabled || cfg.Kubernetes.Policy != "edge" || cfg.Path != "" {
+		t.Errorf("kubernetes = %+v, path %q", cfg.Kubernetes, cfg.Path)
+	}
+
+	if _, err := LoadFromKubernetes([]byte(`{"rate_limits":{}}`), k); err == nil {
+		t.Error("unknown field accepted")
+	}
+}
+
 func TestLoadFromFile(t *testing.T) {
 	yaml := `
 interface: ens3f0
@@ -153,6 +787,55 @@ whitelist:
 	}
 }
 
+func TestLoadFromFile_Strict(t *testing.T) {
+	yaml := `
+interface: ens3f0
+rate_limt:
+  syn_rate_pps: 2000
+api:
+  tsl: true
+rate_limit:
+  syn_rate_pps: 200000000
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadFromFile(path)
+	if err == nil {
+		t.Fatal("LoadFromFile() accepted unknown fields")
+	}
+	// Every unknown field and the semantic error are reported together.
+	for _, want := range []string{"line 3: field rate_limt", "line 6: field tsl", "syn_rate_pps 200000000 exceeds"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadFromFile() error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestValidateTLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"server.crt", "server.key"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("test"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.API.TLS = true
+	cfg.API.Cert = filepath.Join(dir, "server.crt")
+	cfg.API.Key = filepath.Join(dir, "server.key")
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error: %v", err)
+	}
+
+	cfg.API.Key = dir
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "api.key") {
+		t.Errorf("Validate() error = %v, want api.key is a directory", err)
+	}
+}
+
 func TestLoadFromFile_NotFound(t *testing.T) {
 	_, err := LoadFromFile("/nonexistent/config.yaml")
 	if err == nil {
@@ -192,6 +875,9 @@ func TestSaveToFile(t *testing.T) {
 	if loaded.Interface != "ens4f1" {
 		t.Errorf("reloaded interface = %s, want ens4f1", loaded.Interface)
 	}
+	if loaded.Path != path {
+		t.Errorf("reloaded path = %q, want %q", loaded.Path, path)
+	}
 }
 
 func TestRateLimitThreadSafe(t *testing.T) {
@@ -0,0 +1,206 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the name of every environment override.
+const EnvPrefix = "SCRUBBER_"
+
+// Overrides are configuration settings given outside the config file, by
+// dotted YAML path (e.g. "rate_limit.syn_rate_pps") to value. Strings are
+// taken as is, string lists as comma-separated values, and anything else
+// as YAML, so structured settings use flow syntax:
+// '[{name: noc, key_sha256: ..., role: operator}]'.
+type Overrides map[string]string
+
+// overrideField is a setting that can be overridden.
+type overrideField struct {
+	path  string
+	index []int // Field index path from Config
+}
+
+// env returns the environment variable for the setting, e.g.
+// SCRUBBER_RATE_LIMIT_SYN_RATE_PPS.
+func (f overrideField) env() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(f.path, ".", "_"))
+}
+
+// flag returns the command line flag for the setting, e.g.
+// rate-limit.syn-rate-pps.
+func (f overrideField) flag() string {
+	return strings.ReplaceAll(f.path, "_", "-")
+}
+
+var overrideFields = collectOverrideFields(reflect.TypeOf((*Config)(nil)).Elem(), "", nil)
+
+// collectOverrideFields lists the settings of a config struct: every field
+// that is not itself a nested section, named by its yaml tags.
+func collectOverrideFields(t reflect.Type, prefix string, index []int) []overrideField {
+	var fields []overrideField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		idx := append(append([]int(nil), index...), i)
+		if sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, collectOverrideFields(sf.Type, path, idx)...)
+			continue
+		}
+		fields = append(fields, overrideField{path: path, index: idx})
+	}
+	return fields
+}
+
+func lookupOverrideField(path string) (overrideField, bool) {
+	for _, f := range overrideFields {
+		if f.path == path {
+			return f, true
+		}
+	}
+	return overrideField{}, false
+}
+
+// OverrideKeys lists every setting that can be overridden, sorted.
+func OverrideKeys() []string {
+	keys := make([]string, len(overrideFields))
+	for i, f := range overrideFields {
+		keys[i] = f.path
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// clientEnv are variables of the client tools (scrubberctl, loadgen). They
+// share the prefix, and SCRUBBER_API_KEY would otherwise read as api.key,
+// which can only be overridden by flag.
+var clientEnv = map[string]bool{
+	"SCRUBBER_ADDR":    true,
+	"SCRUBBER_API_KEY": true,
+	"SCRUBBER_CA":      true,
+	"SCRUBBER_CERT":    true,
+	"SCRUBBER_KEY":     true,
+	"SCRUBBER_TOKEN":   true,
+}
+
+// serviceLinkEnv matches the variables Kubernetes sets for the services in
+// a pod's namespace (SCRUBBER_SERVICE_HOST, SCRUBBER_PORT_9090_TCP_ADDR
+// for a service named scrubber, and so on).
+var serviceLinkEnv = regexp.MustCompile(`_(SERVICE_(HOST|PORT(_[A-Z0-9_]+)?)|PORT(_[0-9]+_(TCP|UDP|SCTP)(_(PROTO|PORT|ADDR))?)?)$`)
+
+// EnvOverrides collects the SCRUBBER_* variables in environ (as returned
+// by os.Environ). The client tools' own variables are skipped so both can
+// share an environment, as are Kubernetes service links that name no
+// setting; any other SCRUBBER_ variable that names no setting is an
+// error, so a typo does not go unnoticed.
+func EnvOverrides(environ []string) (Overrides, error) {
+	o := make(Overrides)
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, EnvPrefix) || clientEnv[name] {
+			continue
+		}
+		found := false
+		for _, f := range overrideFields {
+			if f.env() == name {
+				o[f.path] = value
+				found = true
+				break
+			}
+		}
+		if !found && !serviceLinkEnv.MatchString(name) {
+			return nil, fmt.Errorf("%s does not name a config setting", name)
+		}
+	}
+	return o, nil
+}
+
+// RegisterFlags defines a flag on fs for every setting, named after its
+// path with dashes (--rate-limit.syn-rate-pps=500). Names fs already
+// defines are left alone. The returned overrides fill in as fs is parsed.
+func RegisterFlags(fs *flag.FlagSet) Overrides {
+	o := make(Overrides)
+	for _, f := range overrideFields {
+		if fs.Lookup(f.flag()) != nil {
+			continue
+		}
+		path := f.path
+		fs.Func(f.flag(), "Override config setting "+path, func(v string) error {
+			o[path] = v
+			return nil
+		})
+	}
+	return o
+}
+
+// ApplyOverrides sets each overridden setting. Call Validate afterwards.
+func (c *Config) ApplyOverrides(o Overrides) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	paths := make([]string, 0, len(o))
+	for path := range o {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	root := reflect.ValueOf(c).Elem()
+	for _, path := range paths {
+		f, ok := lookupOverrideField(path)
+		if !ok {
+			return fmt.Errorf("unknown config setting %q", path)
+		}
+		if err := setOverride(root.FieldByIndex(f.index), o[path]); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func setOverride(v reflect.Value, s string) error {
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+		return nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(s), "["):
+		list := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = reflect.Append(list, reflect.ValueOf(item).Convert(v.Type().Elem()))
+			}
+		}
+		v.Set(list)
+		return nil
+	}
+
+	// Decode into a fresh value so maps are replaced rather than merged,
+	// as strictly as the config file.
+	nv := reflect.New(v.Type())
+	dec := yaml.NewDecoder(strings.NewReader(s))
+	dec.KnownFields(true)
+	if err := dec.Decode(nv.Interface()); err != nil && err != io.EOF {
+		return err
+	}
+	v.Set(nv.Elem())
+	return nil
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOverrideNamesUnique(t *testing.T) {
+	envs := make(map[string]string)
+	for _, f := range overrideFields {
+		if prev, ok := envs[f.env()]; ok {
+			t.Errorf("%s and %s share %s", prev, f.path, f.env())
+		}
+		envs[f.env()] = f.path
+	}
+	if _, ok := lookupOverrideField("rate_limit.adaptive.enabled"); !ok {
+		t.Error("nested setting rate_limit.adaptive.enabled not overridable")
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	o, err := EnvOverrides([]string{
+		"PATH=/usr/bin",
+		"SCRUBBER_API_KEY=client-side",
+		"SCRUBBER_INTERFACE=ens5",
+		"SCRUBBER_RATE_LIMIT_SYN_RATE_PPS=2500",
+		"SCRUBBER_WHITELIST=10.0.0.0/8, 192.168.0.0/16",
+		"SCRUBBER_EVENT_SAMPLING_SAMPLE_RATES={udp_flood: 100}",
+		"SCRUBBER_API_AUTH_KEYS=[{name: noc, key: secret, role: operator}]",
+		// Kubernetes service links for services named scrubber and
+		// scrubber-metrics.
+		"SCRUBBER_SERVICE_HOST=10.96.0.12",
+		"SCRUBBER_SERVICE_PORT=9090",
+		"SCRUBBER_SERVICE_PORT_API=9090",
+		"SCRUBBER_PORT=tcp://10.96.0.12:9090",
+		"SCRUBBER_PORT_9090_TCP=tcp://10.96.0.12:9090",
+		"SCRUBBER_PORT_9090_TCP_ADDR=10.96.0.12",
+		"SCRUBBER_METRICS_PORT_9100_TCP_PROTO=tcp",
+	})
+	if err != nil {
+		t.Fatalf("EnvOverrides() error: %v", err)
+	}
+	if len(o) != 5 {
+		t.Errorf("EnvOverrides() = %v, want the 5 config settings", o)
+	}
+
+	cfg := DefaultConfig()
+	cfg.EventSampling.SampleRates = map[string]int{"syn_flood": 10}
+	if err := cfg.ApplyOverrides(o); err != nil {
+		t.Fatalf("ApplyOverrides() error: %v", err)
+	}
+	if cfg.Interface != "ens5" {
+		t.Errorf("interface = %s, want ens5", cfg.Interface)
+	}
+	if cfg.RateLimit.SYNRatePPS != 2500 {
+		t.Errorf("syn_rate_pps = %d, want 2500", cfg.RateLimit.SYNRatePPS)
+	}
+	if len(cfg.Whitelist) != 2 || cfg.Whitelist[1] != "192.168.0.0/16" {
+		t.Errorf("whitelist = %v", cfg.Whitelist)
+	}
+	if len(cfg.EventSampling.SampleRates) != 1 || cfg.EventSampling.SampleRates["udp_flood"] != 100 {
+		t.Errorf("sample_rates = %v, want only udp_flood: 100", cfg.EventSampling.SampleRates)
+	}
+	if len(cfg.API.Auth.Keys) != 1 || cfg.API.Auth.Keys[0].Role != "operator" {
+		t.Errorf("api.auth.keys = %+v", cfg.API.Auth.Keys)
+	}
+
+	if _, err := EnvOverrides([]string{"SCRUBBER_INTERFAEC=ens5"}); err == nil {
+		t.Error("EnvOverrides() accepted a misspelled setting")
+	}
+	if _, err := EnvOverrides([]string{"SCRUBBER_RATE_LIMT_SYN_RATE_PPS=1"}); err == nil {
+		t.Error("EnvOverrides() accepted a misspelled setting")
+	}
+	if err := cfg.ApplyOverrides(Overrides{"rate_limit.syn_rate_pps": "fast"}); err == nil {
+		t.Error("ApplyOverrides() accepted a non-numeric rate")
+	}
+	if err := cfg.ApplyOverrides(Overrides{"api.auth.keys": "[{name: noc, rol: admin}]"}); err == nil {
+		t.Error("ApplyOverrides() accepted an unknown field")
+	}
+}
+
+func TestFlagOverrides(t *testing.T) {
+	fs := flag.NewFlagSet("scrubber", flag.ContinueOnError)
+	iface := fs.String("interface", "", "Override network interface")
+	o := RegisterFlags(fs)
+
+	err := fs.Parse([]string{"-interface", "ens5", "--rate-limit.adaptive.enabled=true", "--xdp-mode", "skb"})
+	if err != nil {
+		t.Fatalf("Parse() error: %v", err)
+	}
+	if *iface != "ens5" {
+		t.Errorf("existing -interface flag = %q, want ens5", *iface)
+	}
+	if len(o) != 2 || o["rate_limit.adaptive.enabled"] != "true" || o["xdp_mode"] != "skb" {
+		t.Errorf("flag overrides = %v", o)
+	}
+}
+
+func TestLoadFromFileOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("interface: \"\"\nrate_limit:\n  syn_rate_pps: 2000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The interface the file lacks comes from the environment; the flag
+	// wins over the environment.
+	env := Overrides{"interface": "ens5", "rate_limit.syn_rate_pps": "3000"}
+	flags := Overrides{"rate_limit.syn_rate_pps": "4000"}
+	cfg, err := LoadFromFile(path, env, flags)
+	if err != nil {
+		t.Fatalf("LoadFromFile() error: %v", err)
+	}
+	if cfg.Interface != "ens5" || cfg.RateLimit.SYNRatePPS != 4000 {
+		t.Errorf("interface = %s, syn_rate_pps = %d, want ens5 and 4000", cfg.Interface, cfg.RateLimit.SYNRatePPS)
+	}
+
+	if _, err := LoadFromFile(path, Overrides{"xdp_mode": "turbo"}); err == nil {
+		t.Error("LoadFromFile() accepted an invalid overridden xdp_mode")
+	}
+}
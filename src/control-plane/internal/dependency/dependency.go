@@ -0,0 +1,279 @@
+// Package dependency keeps the protected service's upstream dependencies
+// (DNS resolvers, payment APIs, CDNs) whitelisted in the BPF maps so that
+// mitigation never severs the service's own outbound-return traffic.
+package dependency
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Default timings used when the configuration leaves them unset.
+const (
+	defaultRefreshInterval = 5 * time.Minute
+	resolveTimeout         = 10 * time.Second
+)
+
+// LookupFunc resolves a hostname to its IP addresses.
+type LookupFunc func(ctx context.Context, host string) ([]net.IP, error)
+
+// Whitelist is the source whitelist the tracker writes to, implemented by
+// bpf.MapManager.
+type Whitelist interface {
+	WhitelistEntries() ([]string, error)
+	AddWhitelistCIDR(cidr string) error
+	RemoveWhitelistCIDR(cidr string) error
+}
+
+// Endpoint is a resolved dependency address with its whitelist expiry.
+type Endpoint struct {
+	IP        string    `json:"ip"`
+	Host      string    `json:"host"`
+	ExpiresAt time.Time `json:"expiresAt"`
+
+	owned bool // Whitelisted by the tracker, so removed on expiry
+}
+
+// Tracker periodically resolves dependency hostnames and maintains
+// whitelist entries for the resulting addresses with a TTL.
+type Tracker struct {
+	log     *zap.Logger
+	maps    Whitelist
+	hosts   []string
+	refresh time.Duration
+	ttl     time.Duration
+	lookup  LookupFunc
+
+	mu        sync.Mutex
+	endpoints map[string]*Endpoint // IP -> endpoint
+	static    map[string]bool      // statically configured whitelist entries, never removed
+}
+
+// NewTracker creates a dependency tracker. Only addresses the tracker
+// whitelisted itself are removed by expiry: not those listed in static
+// (the configured whitelist) nor those already whitelisted when resolved.
+func NewTracker(log *zap.Logger, maps Whitelist, hosts []string, refresh, ttl time.Duration, static []string) *Tracker {
+	if refresh == 0 {
+		refresh = defaultRefreshInterval
+	}
+	if ttl < refresh {
+		ttl = 3 * refresh
+	}
+
+	st := make(map[string]bool, len(static))
+	for _, s := range static {
+		st[singleAddr(s)] = true
+	}
+
+	return &Tracker{
+		log:       log,
+		maps:      maps,
+		hosts:     hosts,
+		refresh:   refresh,
+		ttl:       ttl,
+		lookup:    defaultLookup,
+		endpoints: make(map[string]*Endpoint),
+		static:    st,
+	}
+}
+
+// Run resolves all dependencies immediately and then on every refresh
+// interval until the context is cancelled.
+func (t *Tracker) Run(ctx context.Context) {
+	t.log.Info("dependency whitelist started",
+		zap.Strings("hosts", t.hosts),
+		zap.Duration("refresh", t.refresh),
+		zap.Duration("ttl", t.ttl),
+	)
+
+	t.Refresh(ctx)
+
+	ticker := time.NewTicker(t.refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.log.Info("dependency whitelist stopped")
+			return
+		case <-ticker.C:
+			t.Refresh(ctx)
+		}
+	}
+}
+
+// Refresh resolves every dependency, whitelists new addresses, extends the
+// TTL of known ones, and removes addresses that have expired.
+func (t *Tracker) Refresh(ctx context.Context) {
+	now := time.Now()
+
+	for _, host := range t.hosts {
+		rctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+		ips, err := t.lookup(rctx, host)
+		cancel()
+		if err != nil {
+			// Keep existing entries; they expire through their TTL.
+			t.log.Warn("dependency resolution failed", zap.String("host", host), zap.Error(err))
+			continue
+		}
+
+		added := t.observe(host, ips, now)
+		if len(added) == 0 {
+			continue
+		}
+		listed, err := t.whitelisted()
+		if err != nil {
+			// Without the current entries the tracker cannot tell its own
+			// from the operator's; try again on the next refresh.
+			t.log.Warn("failed to read whitelist", zap.String("host", host), zap.Error(err))
+			for _, ip := range added {
+				t.forget(ip)
+			}
+			continue
+		}
+		for _, ip := range added {
+			if listed[ip] {
+				continue // Whitelisted by someone else: left in place on expiry
+			}
+			if err := t.maps.AddWhitelistCIDR(ip); err != nil {
+				t.log.Warn("failed to whitelist dependency",
+					zap.String("host", host), zap.String("ip", ip), zap.Error(err))
+				t.forget(ip)
+				continue
+			}
+			t.own(ip)
+			t.log.Info("dependency whitelisted", zap.String("host", host), zap.String("ip", ip))
+		}
+	}
+
+	for _, ep := range t.expire(now) {
+		if err := t.maps.RemoveWhitelistCIDR(ep.IP); err != nil {
+			t.log.Warn("failed to remove expired dependency",
+				zap.String("host", ep.Host), zap.String("ip", ep.IP), zap.Error(err))
+			continue
+		}
+		t.log.Info("dependency whitelist expired", zap.String("host", ep.Host), zap.String("ip", ep.IP))
+	}
+}
+
+// Endpoints returns the currently whitelisted dependency addresses.
+func (t *Tracker) Endpoints() []Endpoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make([]Endpoint, 0, len(t.endpoints))
+	for _, ep := range t.endpoints {
+		result = append(result, *ep)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].IP < result[j].IP })
+	return result
+}
+
+// observe records resolved addresses for a host and returns the ones that
+// were not yet whitelisted.
+func (t *Tracker) observe(host string, ips []net.IP, now time.Time) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var added []string
+	for _, ip := range ips {
+		if ip.To4() == nil {
+			continue // IPv6 not supported by the BPF whitelist.
+		}
+		key := ip.To4().String()
+
+		if ep, ok := t.endpoints[key]; ok {
+			ep.ExpiresAt = now.Add(t.ttl)
+			continue
+		}
+		t.endpoints[key] = &Endpoint{IP: key, Host: host, ExpiresAt: now.Add(t.ttl)}
+		if !t.static[key] {
+			added = append(added, key)
+		}
+	}
+	return added
+}
+
+// expire removes and returns endpoints whose TTL has elapsed. Addresses
+// the tracker did not whitelist itself are dropped from tracking but not
+// returned, so their whitelist entry is left in place.
+func (t *Tracker) expire(now time.Time) []Endpoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var expired []Endpoint
+	for key, ep := range t.endpoints {
+		if now.Before(ep.ExpiresAt) {
+			continue
+		}
+		delete(t.endpoints, key)
+		if ep.owned {
+			expired = append(expired, *ep)
+		}
+	}
+	return expired
+}
+
+func (t *Tracker) forget(ip string) {
+	t.mu.Lock()
+	delete(t.endpoints, ip)
+	t.mu.Unlock()
+}
+
+// own marks an endpoint as whitelisted by the tracker.
+func (t *Tracker) own(ip string) {
+	t.mu.Lock()
+	if ep, ok := t.endpoints[ip]; ok {
+		ep.owned = true
+	}
+	t.mu.Unlock()
+}
+
+// whitelisted returns the single addresses currently in the whitelist.
+func (t *Tracker) whitelisted() (map[string]bool, error) {
+	entries, err := t.maps.WhitelistEntries()
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		listed[singleAddr(e)] = true
+	}
+	return listed, nil
+}
+
+// singleAddr returns the bare address of a whitelist entry for a single
+// IPv4 address ("192.0.2.10" or "192.0.2.10/32"), the form endpoints are
+// keyed by, and wider prefixes unchanged.
+func singleAddr(entry string) string {
+	if ip, n, err := net.ParseCIDR(entry); err == nil {
+		if ones, bits := n.Mask.Size(); ones == bits && ip.To4() != nil {
+			return ip.To4().String()
+		}
+		return entry
+	}
+	if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+		return ip.To4().String()
+	}
+	return entry
+}
+
+func defaultLookup(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, a := range addrs {
+		ips = append(ips, a.IP)
+	}
+	return ips, nil
+}
@@ -0,0 +1,105 @@
+package dependency
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestObserveAndExpire(t *testing.T) {
+	tr := NewTracker(zap.NewNop(), nil, []string{"api.example.com"},
+		time.Minute, 5*time.Minute, []string{"192.0.2.10"})
+
+	now := time.Now()
+	ips := []net.IP{
+		net.ParseIP("198.51.100.1"),
+		net.ParseIP("192.0.2.10"),
+		net.ParseIP("2001:db8::1"),
+	}
+
+	added := tr.observe("api.example.com", ips, now)
+	if len(added) != 1 || added[0] != "198.51.100.1" {
+		t.Fatalf("observe() added = %v, want [198.51.100.1]", added)
+	}
+	tr.own(added[0])
+	if got := len(tr.Endpoints()); got != 2 {
+		t.Errorf("tracked endpoints = %d, want 2", got)
+	}
+
+	// Re-observing extends the TTL without re-adding.
+	later := now.Add(4 * time.Minute)
+	if added := tr.observe("api.example.com", ips[:1], later); len(added) != 0 {
+		t.Errorf("re-observe added = %v, want none", added)
+	}
+
+	// The static entry expires from tracking but is not reported for removal.
+	expired := tr.expire(now.Add(6 * time.Minute))
+	if len(expired) != 0 {
+		t.Errorf("expire() = %v, want none", expired)
+	}
+	if got := len(tr.Endpoints()); got != 1 {
+		t.Errorf("tracked endpoints after expiry = %d, want 1", got)
+	}
+
+	expired = tr.expire(later.Add(5 * time.Minute))
+	if len(expired) != 1 || expired[0].IP != "198.51.100.1" {
+		t.Errorf("expire() = %v, want [198.51.100.1]", expired)
+	}
+}
+
+func TestNewTrackerTTLFloor(t *testing.T) {
+	tr := NewTracker(zap.NewNop(), nil, nil, time.Minute, time.Second, nil)
+	if tr.ttl != 3*time.Minute {
+		t.Errorf("ttl = %v, want %v", tr.ttl, 3*time.Minute)
+	}
+}
+
+// fakeWhitelist records whitelist entries as the MapManager lists them.
+type fakeWhitelist map[string]bool
+
+func (f fakeWhitelist) WhitelistEntries() ([]string, error) {
+	var out []string
+	for cidr := range f {
+		out = append(out, cidr)
+	}
+	return out, nil
+}
+
+func (f fakeWhitelist) AddWhitelistCIDR(cidr string) error {
+	f[cidr+"/32"] = true
+	return nil
+}
+
+func (f fakeWhitelist) RemoveWhitelistCIDR(cidr string) error {
+	delete(f, cidr+"/32")
+	return nil
+}
+
+func TestRefreshKeepsOthersEntries(t *testing.T) {
+	// 192.0.2.10 is configured as a /32, 192.0.2.20 was added through the
+	// API; only 198.51.100.1 is the tracker's own.
+	wl := fakeWhitelist{"192.0.2.10/32": true, "192.0.2.20/32": true}
+	tr := NewTracker(zap.NewNop(), wl, []string{"api.example.com"},
+		time.Minute, 5*time.Minute, []string{"192.0.2.10/32"})
+	tr.lookup = func(context.Context, string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("192.0.2.10"), net.ParseIP("192.0.2.20"), net.ParseIP("198.51.100.1")}, nil
+	}
+
+	tr.Refresh(context.Background())
+	if !wl["198.51.100.1/32"] || len(wl) != 3 {
+		t.Fatalf("whitelist = %v, want the new address added", wl)
+	}
+	if got := len(tr.Endpoints()); got != 3 {
+		t.Errorf("tracked endpoints = %d, want 3", got)
+	}
+
+	for _, ep := range tr.expire(time.Now().Add(time.Hour)) {
+		wl.RemoveWhitelistCIDR(ep.IP)
+	}
+	if !wl["192.0.2.10/32"] || !wl["192.0.2.20/32"] || wl["198.51.100.1/32"] {
+		t.Errorf("whitelist after expiry = %v, want only the tracker's entry removed", wl)
+	}
+}
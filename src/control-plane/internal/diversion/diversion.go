@@ -0,0 +1,376 @@
+// Package diversion runs the scrubber as a scrubbing center. While an
+// attack keeps escalation at or above a configured level, the victim
+// prefixes are advertised over BGP so their traffic is pulled through the
+// scrubber, and the data plane re-injects the clean traffic to the origin
+// over the GRE return tunnels. A prefix is only advertised while a healthy
+// tunnel covers it; attracting traffic that cannot be delivered would
+// blackhole the victim.
+package diversion
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/tunnel"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultStartLevel = "high"
+	defaultHold       = 5 * time.Minute
+
+	// How often announcements are reconciled with tunnel health.
+	reconcileInterval = 5 * time.Second
+)
+
+// Operating modes. In auto mode diversion follows the escalation level;
+// on and off are manual overrides.
+const (
+	ModeAuto = "auto"
+	ModeOn   = "on"
+	ModeOff  = "off"
+)
+
+// Config controls scrubbing-center diversion.
+type Config struct {
+	Enabled    bool     `yaml:"enabled"`
+	Prefixes   []string `yaml:"prefixes"`    // Victim prefixes advertised while diverting
+	LocalIP    string   `yaml:"local_ip"`    // Outer source address of re-injected packets
+	StartLevel string   `yaml:"start_level"` // Escalation level that starts diversion
+	HoldSec    uint64   `yaml:"hold_sec"`    // Keep diverting this long after the level drops
+}
+
+// Validate checks the diversion configuration.
+func (c Config) Validate() error {
+	if len(c.Prefixes) == 0 {
+		return fmt.Errorf("at least one prefix is required")
+	}
+	for _, p := range c.Prefixes {
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return fmt.Errorf("invalid prefix %q: %w", p, err)
+		}
+		if n.IP.To4() == nil {
+			return fmt.Errorf("IPv6 prefix not supported: %s", p)
+		}
+	}
+	if ip := net.ParseIP(c.LocalIP); ip == nil || ip.To4() == nil {
+		return fmt.Errorf("local_ip must be an IPv4 address, got %q", c.LocalIP)
+	}
+	if c.StartLevel != "" {
+		level, ok := escalation.ParseLevel(c.StartLevel)
+		if !ok || level == escalation.Low {
+			return fmt.Errorf("start_level must be medium, high, or critical, got %q", c.StartLevel)
+		}
+	}
+	return nil
+}
+
+// Announcer advertises diversion routes, implemented by bgp.Client.
+type Announcer interface {
+	AnnounceDiversion(prefix string) error
+	WithdrawDiversion(prefix string) error
+}
+
+// Reinjector switches GRE re-injection in the data plane, implemented by
+// bpf.MapManager.
+type Reinjector interface {
+	SetReinjection(enabled bool, localIP net.IP) error
+}
+
+// Tunnels reports GRE tunnel health, implemented by tunnel.Manager.
+type Tunnels interface {
+	List() []tunnel.Status
+}
+
+// PrefixStatus reports one victim prefix.
+type PrefixStatus struct {
+	Prefix    string
+	Announced bool
+	Tunnel    string // Covering GRE tunnel; "" if none
+	Endpoint  string // Its active endpoint; "" while the tunnel is down
+	Error     string // Why the prefix is not announced while diverting
+}
+
+// Status reports the diversion state.
+type Status struct {
+	Mode       string
+	Active     bool
+	Since      time.Time // When diversion started or stopped
+	Level      escalation.Level
+	StartLevel escalation.Level
+	HoldUntil  time.Time // Set while holding after the level dropped
+	Prefixes   []PrefixStatus
+}
+
+// Manager starts and stops diversion and keeps the announcements in step
+// with tunnel health.
+type Manager struct {
+	log        *zap.Logger
+	prefixes   []*net.IPNet
+	localIP    net.IP
+	startLevel escalation.Level
+	hold       time.Duration
+	bgp        Announcer
+	dp         Reinjector
+	tunnels    Tunnels
+
+	mu         sync.Mutex
+	mode       string
+	level      escalation.Level
+	active     bool
+	since      time.Time
+	belowSince time.Time // When the level fell below startLevel while active
+	announced  map[string]bool
+	errs       map[string]string
+}
+
+// NewManager creates a diversion manager from a validated configuration.
+// tunnels may be nil, in which case no prefix can be diverted.
+func NewManager(log *zap.Logger, cfg Config, bgp Announcer, dp Reinjector, tunnels Tunnels) *Manager {
+	m := &Manager{
+		log:        log,
+		localIP:    net.ParseIP(cfg.LocalIP).To4(),
+		startLevel: escalation.High,
+		hold:       time.Duration(cfg.HoldSec) * time.Second,
+		bgp:        bgp,
+		dp:         dp,
+		tunnels:    tunnels,
+		mode:       ModeAuto,
+		announced:  make(map[string]bool),
+		errs:       make(map[string]string),
+	}
+	for _, p := range cfg.Prefixes {
+		if _, n, err := net.ParseCIDR(p); err == nil {
+			m.prefixes = append(m.prefixes, n)
+		}
+	}
+	if level, ok := escalation.ParseLevel(cfg.StartLevel); ok && level != escalation.Low {
+		m.startLevel = level
+	}
+	if m.hold == 0 {
+		m.hold = defaultHold
+	}
+	return m
+}
+
+// SetLevel records an escalation level transition and starts or stops
+// diversion accordingly.
+func (m *Manager) SetLevel(level escalation.Level) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.level = level
+	m.reconcileLocked(time.Now())
+}
+
+// SetMode switches between automatic diversion and a manual override.
+func (m *Manager) SetMode(mode string) error {
+	switch mode {
+	case ModeAuto, ModeOn, ModeOff:
+	default:
+		return fmt.Errorf("invalid mode %q: must be auto, on, or off", mode)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.mode != mode {
+		m.log.Info("diversion mode changed", zap.String("from", m.mode), zap.String("to", mode))
+		m.mode = mode
+		m.belowSince = time.Time{}
+	}
+	m.reconcileLocked(time.Now())
+	return nil
+}
+
+// Run re-checks the announcements against tunnel health until ctx is
+// cancelled. Diversion is left as is; Shutdown ends it.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.mu.Lock()
+			m.reconcileLocked(now)
+			m.mu.Unlock()
+		}
+	}
+}
+
+// Shutdown withdraws every diversion route and turns off re-injection, so
+// no traffic is attracted to a scrubber that is going away.
+func (m *Manager) Shutdown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active {
+		m.stopLocked(time.Now(), "shutdown")
+	}
+}
+
+// Status returns the diversion state.
+func (m *Manager) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := Status{
+		Mode:       m.mode,
+		Active:     m.active,
+		Since:      m.since,
+		Level:      m.level,
+		StartLevel: m.startLevel,
+	}
+	if m.active && m.mode == ModeAuto && !m.belowSince.IsZero() {
+		st.HoldUntil = m.belowSince.Add(m.hold)
+	}
+	tunnels := m.tunnelStatus()
+	for _, n := range m.prefixes {
+		prefix := n.String()
+		ps := PrefixStatus{
+			Prefix:    prefix,
+			Announced: m.announced[prefix],
+			Error:     m.errs[prefix],
+		}
+		if t, ok := covering(n, tunnels); ok {
+			ps.Tunnel, ps.Endpoint = t.Name, t.Active
+		}
+		st.Prefixes = append(st.Prefixes, ps)
+	}
+	return st
+}
+
+// wantLocked reports whether traffic should be diverted at now.
+func (m *Manager) wantLocked(now time.Time) bool {
+	switch m.mode {
+	case ModeOn:
+		return true
+	case ModeOff:
+		return false
+	}
+	if m.level >= m.startLevel {
+		m.belowSince = time.Time{}
+		return true
+	}
+	if !m.active {
+		return false
+	}
+	if m.belowSince.IsZero() {
+		m.belowSince = now
+	}
+	return now.Sub(m.belowSince) < m.hold
+}
+
+// reconcileLocked starts or stops diversion and, while diverting,
+// announces the prefixes that have a healthy tunnel and withdraws those
+// that lost it.
+func (m *Manager) reconcileLocked(now time.Time) {
+	want := m.wantLocked(now)
+	if !want {
+		if m.active {
+			m.stopLocked(now, m.stopReasonLocked())
+		}
+		return
+	}
+
+	if !m.active {
+		// Re-injection must be on before any traffic is attracted.
+		if err := m.dp.SetReinjection(true, m.localIP); err != nil {
+			m.log.Error("failed to enable GRE re-injection, not diverting", zap.Error(err))
+			return
+		}
+		m.active = true
+		m.since = now
+		m.log.Warn("traffic diversion started",
+			zap.String("mode", m.mode),
+			zap.String("level", m.level.String()),
+		)
+	}
+
+	tunnels := m.tunnelStatus()
+	for _, n := range m.prefixes {
+		prefix := n.String()
+		t, ok := covering(n, tunnels)
+		healthy := ok && t.Active != ""
+
+		switch {
+		case healthy && !m.announced[prefix]:
+			if err := m.bgp.AnnounceDiversion(prefix); err != nil {
+				m.errs[prefix] = err.Error()
+				m.log.Error("failed to announce diversion", zap.String("prefix", prefix), zap.Error(err))
+				continue
+			}
+			m.announced[prefix] = true
+			delete(m.errs, prefix)
+
+		case !healthy:
+			reason := "no GRE tunnel covers the prefix"
+			if ok {
+				reason = "GRE tunnel " + t.Name + " is down"
+			}
+			m.errs[prefix] = reason
+			if m.announced[prefix] {
+				m.withdrawLocked(prefix, reason)
+			}
+		}
+	}
+}
+
+// stopLocked withdraws all announcements, then turns off re-injection.
+func (m *Manager) stopLocked(now time.Time, reason string) {
+	for prefix := range m.announced {
+		m.withdrawLocked(prefix, reason)
+	}
+	if err := m.dp.SetReinjection(false, m.localIP); err != nil {
+		m.log.Error("failed to disable GRE re-injection", zap.Error(err))
+	}
+	m.active = false
+	m.since = now
+	m.belowSince = time.Time{}
+	m.errs = make(map[string]string)
+	m.log.Info("traffic diversion stopped", zap.String("reason", reason))
+}
+
+func (m *Manager) withdrawLocked(prefix, reason string) {
+	if err := m.bgp.WithdrawDiversion(prefix); err != nil {
+		m.log.Warn("failed to withdraw diversion", zap.String("prefix", prefix), zap.Error(err))
+	}
+	delete(m.announced, prefix)
+	m.log.Info("diversion withdrawn", zap.String("prefix", prefix), zap.String("reason", reason))
+}
+
+func (m *Manager) stopReasonLocked() string {
+	if m.mode == ModeOff {
+		return "manual override"
+	}
+	return "escalation below " + m.startLevel.String()
+}
+
+func (m *Manager) tunnelStatus() []tunnel.Status {
+	if m.tunnels == nil {
+		return nil
+	}
+	return m.tunnels.List()
+}
+
+// covering returns the most specific tunnel whose prefix contains n.
+func covering(n *net.IPNet, tunnels []tunnel.Status) (tunnel.Status, bool) {
+	ones, _ := n.Mask.Size()
+	best, bestOnes := tunnel.Status{}, -1
+	for _, t := range tunnels {
+		_, tn, err := net.ParseCIDR(t.Prefix)
+		if err != nil {
+			continue
+		}
+		tOnes, _ := tn.Mask.Size()
+		if tOnes <= ones && tn.Contains(n.IP) && tOnes > bestOnes {
+			best, bestOnes = t, tOnes
+		}
+	}
+	return best, bestOnes >= 0
+}
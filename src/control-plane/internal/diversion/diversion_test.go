@@ -0,0 +1,102 @@
+package diversion
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/tunnel"
+	"go.uber.org/zap"
+)
+
+type fakeBGP map[string]bool
+
+func (f fakeBGP) AnnounceDiversion(prefix string) error { f[prefix] = true; return nil }
+func (f fakeBGP) WithdrawDiversion(prefix string) error { delete(f, prefix); return nil }
+
+type fakeDP struct{ enabled bool }
+
+func (f *fakeDP) SetReinjection(enabled bool, localIP net.IP) error {
+	f.enabled = enabled
+	return nil
+}
+
+type fakeTunnels []tunnel.Status
+
+func (f fakeTunnels) List() []tunnel.Status { return f }
+
+func TestDiversionFollowsEscalation(t *testing.T) {
+	bgp, dp := fakeBGP{}, &fakeDP{}
+	tunnels := fakeTunnels{
+		{Tunnel: tunnel.Tunnel{Name: "dc1", Prefix: "203.0.113.0/24"}, Active: "192.0.2.1"},
+	}
+	m := NewManager(zap.NewNop(), Config{
+		Prefixes: []string{"203.0.113.0/25", "198.51.100.0/24"},
+		LocalIP:  "192.0.2.254",
+		HoldSec:  60,
+	}, bgp, dp, tunnels)
+
+	m.SetLevel(escalation.Medium)
+	if dp.enabled || len(bgp) != 0 {
+		t.Fatal("diverting below the start level")
+	}
+
+	m.SetLevel(escalation.High)
+	if !dp.enabled || !bgp["203.0.113.0/25"] {
+		t.Fatalf("not diverting at HIGH: reinject=%t routes=%v", dp.enabled, bgp)
+	}
+	if bgp["198.51.100.0/24"] {
+		t.Error("prefix without a GRE tunnel was announced")
+	}
+	if st := m.Status(); st.Prefixes[1].Error == "" || st.Prefixes[0].Tunnel != "dc1" {
+		t.Errorf("status = %+v", st)
+	}
+
+	// The tunnel going down withdraws its prefix but keeps diverting.
+	tunnels[0].Active = ""
+	m.mu.Lock()
+	m.reconcileLocked(time.Now())
+	m.mu.Unlock()
+	if bgp["203.0.113.0/25"] || !dp.enabled {
+		t.Errorf("after tunnel failure: reinject=%t routes=%v", dp.enabled, bgp)
+	}
+	tunnels[0].Active = "192.0.2.1"
+
+	// Dropping below the start level holds diversion for HoldSec.
+	m.SetLevel(escalation.Medium)
+	if !dp.enabled || !bgp["203.0.113.0/25"] {
+		t.Fatal("diversion stopped before the hold time")
+	}
+	m.mu.Lock()
+	m.reconcileLocked(time.Now().Add(61 * time.Second))
+	m.mu.Unlock()
+	if dp.enabled || len(bgp) != 0 {
+		t.Errorf("still diverting after the hold time: reinject=%t routes=%v", dp.enabled, bgp)
+	}
+}
+
+func TestDiversionManualMode(t *testing.T) {
+	bgp, dp := fakeBGP{}, &fakeDP{}
+	m := NewManager(zap.NewNop(), Config{Prefixes: []string{"203.0.113.0/24"}, LocalIP: "192.0.2.254"},
+		bgp, dp, fakeTunnels{{Tunnel: tunnel.Tunnel{Name: "dc1", Prefix: "203.0.113.0/24"}, Active: "192.0.2.1"}})
+
+	if err := m.SetMode("sometimes"); err == nil {
+		t.Error("invalid mode accepted")
+	}
+	m.SetMode(ModeOn)
+	if !bgp["203.0.113.0/24"] {
+		t.Fatal("mode on did not divert")
+	}
+	m.SetLevel(escalation.Critical)
+	m.SetMode(ModeOff)
+	if dp.enabled || len(bgp) != 0 {
+		t.Error("mode off did not stop diversion at CRITICAL")
+	}
+
+	m.SetMode(ModeAuto)
+	m.Shutdown()
+	if dp.enabled || len(bgp) != 0 || m.Status().Active {
+		t.Error("Shutdown left diversion active")
+	}
+}
@@ -0,0 +1,360 @@
+// Package dnsanalytics analyses sampled DNS queries on the cold path
+// (AF_XDP or capture) to detect random-subdomain ("water torture") attacks
+// against protected authoritative zones.
+//
+// For every zone the analyzer tracks query volume, the NXDOMAIN ratio of
+// observed responses, the number of distinct first-level labels, and their
+// Shannon entropy. Zones showing a flood of high-entropy, mostly unique
+// labels are flagged, and per-zone rate-limit recommendations and match
+// signatures are derived for the mitigation pipeline.
+package dnsanalytics
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Detection tuning parameters.
+const (
+	// minQueries is the minimum number of queries in a window before a zone
+	// is evaluated for random-subdomain behaviour.
+	minQueries = 100
+
+	// uniqueRatioThreshold is the fraction of queries carrying a never-seen
+	// label above which the label distribution is considered random.
+	uniqueRatioThreshold = 0.5
+
+	// entropyThreshold is the mean Shannon entropy (bits per character) of
+	// query labels above which labels look machine-generated.
+	entropyThreshold = 3.0
+
+	// nxdomainThreshold is the NXDOMAIN response ratio that by itself marks
+	// a zone as under attack.
+	nxdomainThreshold = 0.5
+
+	// maxLabelsTracked caps the per-zone distinct-label set.
+	maxLabelsTracked = 65536
+
+	// minRecommendedQPS is the floor for per-zone rate recommendations.
+	minRecommendedQPS = 10
+
+	// recommendMargin scales the legitimate query rate into a limit.
+	recommendMargin = 2.0
+)
+
+// DNS wire-format constants.
+const (
+	dnsHeaderLen  = 12
+	dnsFlagQR     = 1 << 15
+	dnsRcodeMask  = 0x000F
+	RcodeNXDomain = 3
+	maxNameLen    = 255
+)
+
+// DomainReport summarises a zone's query behaviour over the current window.
+type DomainReport struct {
+	Domain          string  `json:"domain"`
+	Queries         uint64  `json:"queries"`
+	Responses       uint64  `json:"responses"`
+	NXDomain        uint64  `json:"nxdomain"`
+	NXDomainRatio   float64 `json:"nxdomainRatio"`
+	UniqueLabels    int     `json:"uniqueLabels"`
+	UniqueRatio     float64 `json:"uniqueRatio"`
+	MeanEntropy     float64 `json:"meanEntropy"`
+	RandomSubdomain bool    `json:"randomSubdomain"`
+	RecommendedQPS  uint64  `json:"recommendedQps"`
+	WindowSeconds   float64 `json:"windowSeconds"`
+}
+
+// Signature describes a water-torture attack against a zone. Suffix is the
+// zone name in DNS wire format (length-prefixed labels, root terminator),
+// which every attack query ends with regardless of its random prefix.
+type Signature struct {
+	Domain string `json:"domain"`
+	Suffix []byte `json:"suffix"`
+	MaxQPS uint64 `json:"maxQps"`
+}
+
+// domainStats holds per-zone counters for the current window.
+type domainStats struct {
+	queries    uint64
+	responses  uint64
+	nxdomain   uint64
+	labels     map[string]struct{}
+	entropySum float64
+	labelled   uint64 // queries with a label below the zone
+}
+
+// Analyzer aggregates sampled DNS traffic per zone.
+type Analyzer struct {
+	mu          sync.Mutex
+	zones       []string // protected zones, longest first
+	domains     map[string]*domainStats
+	windowStart time.Time
+}
+
+// NewAnalyzer creates an analyzer for the given protected zones. Queries
+// outside those zones are attributed to their last two labels.
+func NewAnalyzer(zones []string) *Analyzer {
+	norm := make([]string, 0, len(zones))
+	for _, z := range zones {
+		if z = normalizeName(z); z != "" {
+			norm = append(norm, z)
+		}
+	}
+	sort.Slice(norm, func(i, j int) bool { return len(norm[i]) > len(norm[j]) })
+
+	return &Analyzer{
+		zones:       norm,
+		domains:     make(map[string]*domainStats),
+		windowStart: time.Now(),
+	}
+}
+
+// ObservePacket parses a DNS message (UDP payload) and records it as a
+// query or response.
+func (a *Analyzer) ObservePacket(payload []byte) error {
+	qname, isResponse, rcode, err := ParseMessage(payload)
+	if err != nil {
+		return err
+	}
+	if isResponse {
+		a.ObserveResponse(qname, rcode)
+	} else {
+		a.ObserveQuery(qname)
+	}
+	return nil
+}
+
+// ObserveQuery records a query for qname.
+func (a *Analyzer) ObserveQuery(qname string) {
+	qname = normalizeName(qname)
+	if qname == "" {
+		return
+	}
+	zone, label := a.split(qname)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ds := a.stats(zone)
+	ds.queries++
+	if label == "" {
+		return
+	}
+	ds.labelled++
+	ds.entropySum += Entropy(label)
+	if len(ds.labels) < maxLabelsTracked {
+		ds.labels[label] = struct{}{}
+	}
+}
+
+// ObserveResponse records a response for qname with the given RCODE.
+func (a *Analyzer) ObserveResponse(qname string, rcode uint8) {
+	qname = normalizeName(qname)
+	if qname == "" {
+		return
+	}
+	zone, _ := a.split(qname)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ds := a.stats(zone)
+	ds.responses++
+	if rcode == RcodeNXDomain {
+		ds.nxdomain++
+	}
+}
+
+// Report returns per-zone reports for the current window, busiest first.
+func (a *Analyzer) Report() []DomainReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	window := time.Since(a.windowStart).Seconds()
+	reports := make([]DomainReport, 0, len(a.domains))
+	for zone, ds := range a.domains {
+		reports = append(reports, buildReport(zone, ds, window))
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Queries > reports[j].Queries })
+	return reports
+}
+
+// Signatures returns match signatures for zones currently flagged as
+// under a random-subdomain attack.
+func (a *Analyzer) Signatures() []Signature {
+	var sigs []Signature
+	for _, r := range a.Report() {
+		if !r.RandomSubdomain {
+			continue
+		}
+		sigs = append(sigs, Signature{
+			Domain: r.Domain,
+			Suffix: EncodeName(r.Domain),
+			MaxQPS: r.RecommendedQPS,
+		})
+	}
+	return sigs
+}
+
+// Rotate starts a new analysis window, discarding the current counters.
+func (a *Analyzer) Rotate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.domains = make(map[string]*domainStats)
+	a.windowStart = time.Now()
+}
+
+// --- Internal helpers ---
+
+func (a *Analyzer) stats(zone string) *domainStats {
+	ds, ok := a.domains[zone]
+	if !ok {
+		ds = &domainStats{labels: make(map[string]struct{})}
+		a.domains[zone] = ds
+	}
+	return ds
+}
+
+// split returns the zone a name belongs to and the label directly below it
+// ("" if the name is the zone apex).
+func (a *Analyzer) split(qname string) (zone, label string) {
+	for _, z := range a.zones {
+		if qname == z {
+			return z, ""
+		}
+		if strings.HasSuffix(qname, "."+z) {
+			rest := strings.TrimSuffix(qname, "."+z)
+			return z, rest[strings.LastIndexByte(rest, '.')+1:]
+		}
+	}
+
+	labels := strings.Split(qname, ".")
+	if len(labels) <= 2 {
+		return qname, ""
+	}
+	n := len(labels)
+	return labels[n-2] + "." + labels[n-1], labels[n-3]
+}
+
+func buildReport(zone string, ds *domainStats, window float64) DomainReport {
+	r := DomainReport{
+		Domain:        zone,
+		Queries:       ds.queries,
+		Responses:     ds.responses,
+		NXDomain:      ds.nxdomain,
+		UniqueLabels:  len(ds.labels),
+		WindowSeconds: window,
+	}
+	if ds.responses > 0 {
+		r.NXDomainRatio = float64(ds.nxdomain) / float64(ds.responses)
+	}
+	if ds.labelled > 0 {
+		r.UniqueRatio = float64(len(ds.labels)) / float64(ds.labelled)
+		r.MeanEntropy = ds.entropySum / float64(ds.labelled)
+	}
+
+	if ds.queries >= minQueries {
+		randomLabels := r.UniqueRatio > uniqueRatioThreshold && r.MeanEntropy > entropyThreshold
+		r.RandomSubdomain = randomLabels || r.NXDomainRatio > nxdomainThreshold
+	}
+
+	// Legitimate traffic is estimated as the share of queries that reused
+	// a known label; the recommendation leaves headroom above it.
+	if window > 0 {
+		legit := float64(ds.queries) * (1 - r.UniqueRatio) / window
+		r.RecommendedQPS = uint64(math.Max(legit*recommendMargin, minRecommendedQPS))
+	}
+
+	return r
+}
+
+// Entropy returns the Shannon entropy of s in bits per character.
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	n := float64(len(s))
+	h := 0.0
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// ParseMessage extracts the first question name, the QR bit, and the RCODE
+// from a DNS message in wire format.
+func ParseMessage(msg []byte) (qname string, isResponse bool, rcode uint8, err error) {
+	if len(msg) < dnsHeaderLen {
+		return "", false, 0, errors.New("dns message too short")
+	}
+	flags := binary.BigEndian.Uint16(msg[2:4])
+	qdcount := binary.BigEndian.Uint16(msg[4:6])
+	if qdcount == 0 {
+		return "", false, 0, errors.New("dns message has no question")
+	}
+
+	name, err := decodeName(msg[dnsHeaderLen:])
+	if err != nil {
+		return "", false, 0, err
+	}
+	return name, flags&dnsFlagQR != 0, uint8(flags & dnsRcodeMask), nil
+}
+
+// decodeName decodes an uncompressed name (questions are never compressed).
+func decodeName(b []byte) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(b); {
+		l := int(b[i])
+		if l == 0 {
+			return sb.String(), nil
+		}
+		if l&0xC0 != 0 {
+			return "", errors.New("compressed name in question")
+		}
+		i++
+		if i+l > len(b) {
+			return "", errors.New("dns name truncated")
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte('.')
+		}
+		sb.Write(b[i : i+l])
+		if sb.Len() > maxNameLen {
+			return "", errors.New("dns name too long")
+		}
+		i += l
+	}
+	return "", errors.New("dns name not terminated")
+}
+
+// EncodeName encodes a domain name in DNS wire format.
+func EncodeName(name string) []byte {
+	name = normalizeName(name)
+	var out []byte
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			out = append(out, byte(len(label)))
+			out = append(out, label...)
+		}
+	}
+	return append(out, 0)
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
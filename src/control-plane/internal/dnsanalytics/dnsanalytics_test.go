@@ -0,0 +1,107 @@
+package dnsanalytics
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+func buildMessage(qname string, response bool, rcode uint8) []byte {
+	msg := make([]byte, dnsHeaderLen)
+	var flags uint16
+	if response {
+		flags |= dnsFlagQR
+	}
+	flags |= uint16(rcode)
+	binary.BigEndian.PutUint16(msg[2:4], flags)
+	binary.BigEndian.PutUint16(msg[4:6], 1)
+	msg = append(msg, EncodeName(qname)...)
+	return append(msg, 0, 1, 0, 1) // QTYPE A, QCLASS IN
+}
+
+func TestParseMessage(t *testing.T) {
+	name, resp, rcode, err := ParseMessage(buildMessage("WWW.Example.com.", true, RcodeNXDomain))
+	if err != nil {
+		t.Fatalf("ParseMessage: %v", err)
+	}
+	if name != "www.example.com" || !resp || rcode != RcodeNXDomain {
+		t.Errorf("got (%q, %v, %d), want (www.example.com, true, 3)", name, resp, rcode)
+	}
+
+	if _, _, _, err := ParseMessage([]byte{0, 1, 2}); err == nil {
+		t.Error("expected error for short message")
+	}
+	trunc := buildMessage("example.com", false, 0)
+	if _, _, _, err := ParseMessage(trunc[:dnsHeaderLen+4]); err == nil {
+		t.Error("expected error for truncated name")
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	if e := Entropy("aaaa"); e != 0 {
+		t.Errorf("Entropy(aaaa) = %f, want 0", e)
+	}
+	if e := Entropy("abcd"); e != 2 {
+		t.Errorf("Entropy(abcd) = %f, want 2", e)
+	}
+}
+
+func TestRandomSubdomainDetection(t *testing.T) {
+	a := NewAnalyzer([]string{"example.com."})
+
+	// Legitimate traffic: a handful of well-known names queried repeatedly.
+	for i := 0; i < 200; i++ {
+		a.ObserveQuery([]string{"www", "mail", "api"}[i%3] + ".example.com")
+	}
+	for i := 0; i < 200; i++ {
+		a.ObserveQuery(fmt.Sprintf("host%d.other.org", i%2))
+	}
+	if sigs := a.Signatures(); len(sigs) != 0 {
+		t.Fatalf("unexpected signatures for legitimate traffic: %+v", sigs)
+	}
+
+	// Water torture: unique high-entropy labels under the protected zone.
+	for i := 0; i < 1000; i++ {
+		label := fmt.Sprintf("q%x7z%xk", i*7919, i*104729)
+		if err := a.ObservePacket(buildMessage(label+".example.com", false, 0)); err != nil {
+			t.Fatalf("ObservePacket: %v", err)
+		}
+	}
+
+	sigs := a.Signatures()
+	if len(sigs) != 1 || sigs[0].Domain != "example.com" {
+		t.Fatalf("expected one signature for example.com, got %+v", sigs)
+	}
+	if string(sigs[0].Suffix) != "\x07example\x03com\x00" {
+		t.Errorf("unexpected suffix %q", sigs[0].Suffix)
+	}
+	if sigs[0].MaxQPS < minRecommendedQPS {
+		t.Errorf("recommended QPS %d below floor", sigs[0].MaxQPS)
+	}
+
+	a.Rotate()
+	if len(a.Report()) != 0 {
+		t.Error("expected empty report after Rotate")
+	}
+}
+
+func TestNXDomainRatio(t *testing.T) {
+	a := NewAnalyzer(nil)
+	for i := 0; i < minQueries; i++ {
+		a.ObserveQuery("www.example.net")
+		rcode := uint8(0)
+		if i%4 != 0 {
+			rcode = RcodeNXDomain
+		}
+		a.ObserveResponse("www.example.net", rcode)
+	}
+
+	reports := a.Report()
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(reports))
+	}
+	r := reports[0]
+	if r.Domain != "example.net" || r.NXDomainRatio != 0.75 || !r.RandomSubdomain {
+		t.Errorf("unexpected report %+v", r)
+	}
+}
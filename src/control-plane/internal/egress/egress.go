@@ -0,0 +1,236 @@
+// Package egress controls outbound scrubbing: the TC egress program that
+// keeps the protected network from sending spoofed traffic or being used
+// as an amplification reflector. The manager holds the outbound policy,
+// programs it into the egress maps and reports what the program dropped.
+package egress
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+// Policy is the outbound policy. It is set from the config file and can
+// be replaced through the API until the next restart.
+type Policy struct {
+	// Drop packets leaving with a source outside LocalPrefixes
+	BlockSpoofed  bool     `yaml:"block_spoofed" json:"blockSpoofed"`
+	LocalPrefixes []string `yaml:"local_prefixes" json:"localPrefixes"`
+
+	// Limit UDP responses from the AmpPorts source ports to the given
+	// packets per second towards any one destination; 0 drops them all
+	BlockAmplification bool              `yaml:"block_amplification" json:"blockAmplification"`
+	AmpPorts           map[uint16]uint64 `yaml:"amp_ports" json:"ampPorts"`
+}
+
+// Config is the egress section of the config file.
+type Config struct {
+	Enabled bool `yaml:"enabled"` // Attach the TC egress program
+	Policy  `yaml:",inline"`
+}
+
+// Upper bounds of the policy, as held by the egress maps.
+const (
+	maxLocalPrefixes = 1024
+	maxAmpPPS        = 100_000_000
+)
+
+// Validate checks the prefixes and port limits, and that each enabled
+// policy has something to enforce.
+func (p Policy) Validate() error {
+	if p.BlockSpoofed && len(p.LocalPrefixes) == 0 {
+		return fmt.Errorf("block_spoofed requires local_prefixes, or every outbound packet is dropped")
+	}
+	if len(p.LocalPrefixes) > maxLocalPrefixes {
+		return fmt.Errorf("%d local_prefixes exceed the limit of %d", len(p.LocalPrefixes), maxLocalPrefixes)
+	}
+	for _, prefix := range p.LocalPrefixes {
+		_, n, err := net.ParseCIDR(prefix)
+		if err != nil {
+			return fmt.Errorf("local_prefixes: %w", err)
+		}
+		if n.IP.To4() == nil {
+			return fmt.Errorf("local_prefixes: IPv6 prefix not supported: %s", prefix)
+		}
+	}
+
+	if p.BlockAmplification && len(p.AmpPorts) == 0 {
+		return fmt.Errorf("block_amplification requires amp_ports")
+	}
+	if len(p.AmpPorts) > bpf.EgressAmpPortsMax {
+		return fmt.Errorf("%d amp_ports exceed the limit of %d", len(p.AmpPorts), bpf.EgressAmpPortsMax)
+	}
+	for port, pps := range p.AmpPorts {
+		if port == 0 {
+			return fmt.Errorf("amp_ports: port 0 is not valid")
+		}
+		if pps > maxAmpPPS {
+			return fmt.Errorf("amp_ports: limit %d for port %d exceeds %d", pps, port, maxAmpPPS)
+		}
+	}
+	return nil
+}
+
+// Map is the part of the data plane the manager programs, implemented by
+// bpf.MapManager.
+type Map interface {
+	SetConfig(key uint32, value uint64) error
+	SetEgressSources(cidrs []string) error
+	SetEgressAmpPorts(ports map[uint16]uint64) error
+	ReadEgressStats() (bpf.EgressStats, error)
+}
+
+// Stats are the egress program counters.
+type Stats struct {
+	Packets        uint64 `json:"packets"`
+	Bytes          uint64 `json:"bytes"`
+	SpoofedDropped uint64 `json:"spoofedDropped"`
+	AmpDropped     uint64 `json:"ampDropped"`
+	DroppedBytes   uint64 `json:"droppedBytes"`
+}
+
+// Status reports how the egress program is attached, the policy it
+// enforces and what it dropped.
+type Status struct {
+	Enabled    bool   `json:"enabled"`
+	Attachment string `json:"attachment,omitempty"` // tcx or clsact; empty until attached
+	Interface  string `json:"interface,omitempty"`
+	Policy
+	Stats Stats `json:"stats"`
+}
+
+// Manager owns the outbound policy and its map entries.
+type Manager struct {
+	log *zap.Logger
+	m   Map
+
+	mu         sync.Mutex
+	policy     Policy
+	attachment string
+	iface      string
+}
+
+// NewManager creates a manager writing to m. Nothing is programmed until
+// Apply.
+func NewManager(log *zap.Logger, m Map) *Manager {
+	return &Manager{log: log, m: m}
+}
+
+// Apply validates p and programs it. The prefixes and port limits are
+// written before the policies are switched on and after they are switched
+// off, so the program never enforces a half-written policy.
+func (mg *Manager) Apply(p Policy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	p = canonical(p)
+
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+
+	if err := mg.m.SetConfig(bpf.CfgEgressSpoof, boolValue(p.BlockSpoofed && mg.policy.BlockSpoofed)); err != nil {
+		return fmt.Errorf("setting egress_spoof: %w", err)
+	}
+	if err := mg.m.SetConfig(bpf.CfgEgressAmp, boolValue(p.BlockAmplification && mg.policy.BlockAmplification)); err != nil {
+		return fmt.Errorf("setting egress_amp: %w", err)
+	}
+	if err := mg.m.SetEgressSources(p.LocalPrefixes); err != nil {
+		return err
+	}
+	if err := mg.m.SetEgressAmpPorts(p.AmpPorts); err != nil {
+		return err
+	}
+	if err := mg.m.SetConfig(bpf.CfgEgressSpoof, boolValue(p.BlockSpoofed)); err != nil {
+		return fmt.Errorf("setting egress_spoof: %w", err)
+	}
+	if err := mg.m.SetConfig(bpf.CfgEgressAmp, boolValue(p.BlockAmplification)); err != nil {
+		return fmt.Errorf("setting egress_amp: %w", err)
+	}
+	mg.policy = p
+
+	mg.log.Info("egress policy applied",
+		zap.Bool("block_spoofed", p.BlockSpoofed),
+		zap.Int("local_prefixes", len(p.LocalPrefixes)),
+		zap.Bool("block_amplification", p.BlockAmplification),
+		zap.Int("amp_ports", len(p.AmpPorts)),
+	)
+	return nil
+}
+
+// SetAttachment records how the egress program was attached, for Status.
+func (mg *Manager) SetAttachment(mode, iface string) {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	mg.attachment, mg.iface = mode, iface
+}
+
+// Policy returns the policy in force.
+func (mg *Manager) Policy() Policy {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+	return clonePolicy(mg.policy)
+}
+
+// Status returns the attachment, policy and counters of the egress
+// program.
+func (mg *Manager) Status() (Status, error) {
+	mg.mu.Lock()
+	st := Status{
+		Enabled:    true,
+		Attachment: mg.attachment,
+		Interface:  mg.iface,
+		Policy:     clonePolicy(mg.policy),
+	}
+	mg.mu.Unlock()
+
+	s, err := mg.m.ReadEgressStats()
+	if err != nil {
+		return st, err
+	}
+	st.Stats = Stats{
+		Packets:        s.Packets,
+		Bytes:          s.Bytes,
+		SpoofedDropped: s.SpoofedDropped,
+		AmpDropped:     s.AmpDropped,
+		DroppedBytes:   s.DroppedBytes,
+	}
+	return st, nil
+}
+
+// canonical returns a validated policy with its prefixes in canonical
+// form, sorted and deduplicated, and its port map never nil.
+func canonical(p Policy) Policy {
+	seen := make(map[string]bool, len(p.LocalPrefixes))
+	prefixes := make([]string, 0, len(p.LocalPrefixes))
+	for _, prefix := range p.LocalPrefixes {
+		_, n, _ := net.ParseCIDR(prefix)
+		if s := n.String(); !seen[s] {
+			seen[s] = true
+			prefixes = append(prefixes, s)
+		}
+	}
+	sort.Strings(prefixes)
+	p.LocalPrefixes = prefixes
+	return clonePolicy(p)
+}
+
+func clonePolicy(p Policy) Policy {
+	p.LocalPrefixes = append([]string{}, p.LocalPrefixes...)
+	ports := make(map[uint16]uint64, len(p.AmpPorts))
+	for port, pps := range p.AmpPorts {
+		ports[port] = pps
+	}
+	p.AmpPorts = ports
+	return p
+}
+
+func boolValue(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package egress
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+// fakeMap records what the manager programs, and the order of the config
+// writes.
+type fakeMap struct {
+	config  map[uint32]uint64
+	writes  []string
+	sources []string
+	ports   map[uint16]uint64
+	stats   bpf.EgressStats
+}
+
+func newFakeMap() *fakeMap {
+	return &fakeMap{config: map[uint32]uint64{}}
+}
+
+func (f *fakeMap) SetConfig(key uint32, value uint64) error {
+	f.config[key] = value
+	f.writes = append(f.writes, "config")
+	return nil
+}
+
+func (f *fakeMap) SetEgressSources(cidrs []string) error {
+	f.sources = cidrs
+	f.writes = append(f.writes, "sources")
+	return nil
+}
+
+func (f *fakeMap) SetEgressAmpPorts(ports map[uint16]uint64) error {
+	f.ports = ports
+	f.writes = append(f.writes, "ports")
+	return nil
+}
+
+func (f *fakeMap) ReadEgressStats() (bpf.EgressStats, error) {
+	return f.stats, nil
+}
+
+func TestApply(t *testing.T) {
+	m := newFakeMap()
+	mg := NewManager(zap.NewNop(), m)
+
+	p := Policy{
+		BlockSpoofed:       true,
+		LocalPrefixes:      []string{"198.51.100.7/24", "192.0.2.0/24", "198.51.100.0/24"},
+		BlockAmplification: true,
+		AmpPorts:           map[uint16]uint64{53: 1000, 11211: 0},
+	}
+	if err := mg.Apply(p); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if want := []string{"192.0.2.0/24", "198.51.100.0/24"}; !reflect.DeepEqual(m.sources, want) {
+		t.Errorf("sources = %v, want %v", m.sources, want)
+	}
+	if m.ports[11211] != 0 || m.ports[53] != 1000 {
+		t.Errorf("ports = %v", m.ports)
+	}
+	if m.config[bpf.CfgEgressSpoof] != 1 || m.config[bpf.CfgEgressAmp] != 1 {
+		t.Errorf("config = %v, want both policies on", m.config)
+	}
+	// Switching a policy on waits until its entries are written.
+	if want := []string{"config", "config", "sources", "ports", "config", "config"}; !reflect.DeepEqual(m.writes, want) {
+		t.Errorf("writes = %v, want %v", m.writes, want)
+	}
+
+	m.stats = bpf.EgressStats{Packets: 10, SpoofedDropped: 2}
+	st, err := mg.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if !st.Enabled || st.Stats.SpoofedDropped != 2 || len(st.LocalPrefixes) != 2 {
+		t.Errorf("status = %+v", st)
+	}
+
+	// An invalid policy leaves the one in force alone.
+	if err := mg.Apply(Policy{BlockSpoofed: true}); err == nil {
+		t.Error("Apply without local prefixes succeeded")
+	}
+	if got := mg.Policy(); !got.BlockSpoofed || len(got.LocalPrefixes) != 2 {
+		t.Errorf("policy after failed Apply = %+v", got)
+	}
+
+	if err := mg.Apply(Policy{}); err != nil {
+		t.Fatalf("Apply off: %v", err)
+	}
+	if m.config[bpf.CfgEgressSpoof] != 0 || m.config[bpf.CfgEgressAmp] != 0 || len(m.sources) != 0 {
+		t.Errorf("after switching off: config = %v, sources = %v", m.config, m.sources)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{"empty", Policy{}, false},
+		{"prefixes without blocking", Policy{LocalPrefixes: []string{"192.0.2.0/24"}}, false},
+		{"spoof without prefixes", Policy{BlockSpoofed: true}, true},
+		{"invalid prefix", Policy{BlockSpoofed: true, LocalPrefixes: []string{"192.0.2.0/33"}}, true},
+		{"ipv6 prefix", Policy{BlockSpoofed: true, LocalPrefixes: []string{"2001:db8::/32"}}, true},
+		{"amp without ports", Policy{BlockAmplification: true}, true},
+		{"port 0", Policy{BlockAmplification: true, AmpPorts: map[uint16]uint64{0: 10}}, true},
+		{"limit too high", Policy{BlockAmplification: true, AmpPorts: map[uint16]uint64{53: maxAmpPPS + 1}}, true},
+		{"drop memcached", Policy{BlockAmplification: true, AmpPorts: map[uint16]uint64{11211: 0}}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
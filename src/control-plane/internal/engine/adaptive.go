@@ -0,0 +1,211 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/baseline"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/stats"
+	"go.uber.org/zap"
+)
+
+const defaultAdaptiveInterval = 30 * time.Second
+
+// baselineSampleInterval is how often the baseline is fed. Its learning
+// period and EWMA are counted in samples, so faster stats collection is
+// averaged down to this cadence instead of shortening both.
+const baselineSampleInterval = time.Second
+
+// baselineSample averages the snapshots of one baseline sample.
+type baselineSample struct {
+	rxPPS, rxBPS, dropPPS float64
+	proto                 baseline.ProtocolRates
+	covered               time.Duration
+	n                     int
+}
+
+func (s *baselineSample) add(snap *stats.Snapshot, dt time.Duration) {
+	s.rxPPS += snap.RxPPS
+	s.rxBPS += snap.RxBPS
+	s.dropPPS += snap.DropPPS
+	s.proto.SYN += snap.SYNPPS
+	s.proto.UDP += snap.UDPPPS
+	s.proto.ICMP += snap.ICMPPPS
+	s.proto.DNS += snap.DNSPPS
+	s.covered += dt
+	s.n++
+}
+
+// full reports whether the sample spans a baseline interval, allowing for
+// collection jitter.
+func (s *baselineSample) full() bool {
+	return s.covered >= baselineSampleInterval-baselineSampleInterval/20
+}
+
+// feedBaseline feeds the stats snapshots into the traffic baseline, one
+// averaged sample per baselineSampleInterval.
+func (e *Engine) feedBaseline(ctx context.Context, ch <-chan *stats.Snapshot) {
+	var (
+		sample baselineSample
+		last   time.Time
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case snap := <-ch:
+			// The first snapshot has no previous one to compute rates from.
+			if last.IsZero() {
+				last = snap.Timestamp
+				continue
+			}
+			sample.add(snap, snap.Timestamp.Sub(last))
+			last = snap.Timestamp
+			if !sample.full() {
+				continue
+			}
+			n := float64(sample.n)
+			e.baseline.Feed(sample.rxPPS/n, sample.rxBPS/n, sample.dropPPS/n)
+			e.baseline.FeedProtocols(baseline.ProtocolRates{
+				SYN:  sample.proto.SYN / n,
+				UDP:  sample.proto.UDP / n,
+				ICMP: sample.proto.ICMP / n,
+				DNS:  sample.proto.DNS / n,
+			})
+			sample = baselineSample{}
+		}
+	}
+}
+
+// adaptiveTarget is one rate limit driven by the baseline.
+type adaptiveTarget struct {
+	name   string
+	key    uint32
+	rate   uint64
+	bounds config.RateBounds
+}
+
+// runAdaptiveRates periodically pushes baseline-derived rate limits into
+// the BPF config map while CFG_ADAPTIVE_RATE is set. The flag is read from
+// the map on every tick so it can be toggled at runtime. The limits in
+// place when adaptive mode engages are restored when it is switched off.
+func (e *Engine) runAdaptiveRates(ctx context.Context) {
+	ad := e.cfg.GetRateLimit().Adaptive
+	interval := time.Duration(ad.IntervalSec) * time.Second
+	if interval == 0 {
+		interval = defaultAdaptiveInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	e.log.Info("adaptive rate loop started",
+		zap.Duration("interval", interval),
+		zap.Uint64("hysteresis_pct", ad.HysteresisPct),
+	)
+
+	var static map[uint32]uint64 // limits saved when adaptive mode engaged
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		enabled, err := e.maps.GetConfig(bpf.CfgAdaptiveRate)
+		if err != nil {
+			e.log.Warn("failed to read adaptive rate flag", zap.Error(err))
+			continue
+		}
+
+		if enabled == 0 {
+			if static != nil {
+				e.restoreStaticRates(static)
+				static = nil
+			}
+			continue
+		}
+		if !e.baseline.IsOperational() {
+			continue
+		}
+
+		rates := e.baseline.GetAdaptiveRates()
+		targets := []adaptiveTarget{
+			{"syn", bpf.CfgSYNRatePPS, rates.SynPPS, ad.SYN},
+			{"udp", bpf.CfgUDPRatePPS, rates.UdpPPS, ad.UDP},
+			{"icmp", bpf.CfgICMPRatePPS, rates.IcmpPPS, ad.ICMP},
+		}
+
+		if static == nil {
+			static = make(map[uint32]uint64, len(targets))
+			for _, t := range targets {
+				cur, _ := e.maps.GetConfig(t.key)
+				static[t.key] = cur
+			}
+			e.log.Info("adaptive rate limiting engaged")
+		}
+
+		for _, t := range targets {
+			e.applyAdaptiveRate(t, ad.HysteresisPct)
+		}
+	}
+}
+
+// applyAdaptiveRate clamps the target rate and writes it unless it is
+// within the hysteresis band of the current limit.
+func (e *Engine) applyAdaptiveRate(t adaptiveTarget, hysteresisPct uint64) {
+	want := clampRate(t.rate, t.bounds)
+	cur, err := e.maps.GetConfig(t.key)
+	if err != nil {
+		e.log.Warn("failed to read rate limit", zap.String("limit", t.name), zap.Error(err))
+		return
+	}
+	if withinHysteresis(cur, want, hysteresisPct) {
+		return
+	}
+	if err := e.maps.SetConfig(t.key, want); err != nil {
+		e.log.Warn("failed to apply adaptive rate", zap.String("limit", t.name), zap.Error(err))
+		return
+	}
+	e.log.Info("adaptive rate applied",
+		zap.String("limit", t.name),
+		zap.Uint64("from_pps", cur),
+		zap.Uint64("to_pps", want),
+	)
+}
+
+func (e *Engine) restoreStaticRates(static map[uint32]uint64) {
+	for key, val := range static {
+		if err := e.maps.SetConfig(key, val); err != nil {
+			e.log.Warn("failed to restore static rate limit", zap.Uint32("key", key), zap.Error(err))
+		}
+	}
+	e.log.Info("adaptive rate limiting disengaged, static limits restored")
+}
+
+// clampRate bounds rate to b; zero bounds are ignored.
+func clampRate(rate uint64, b config.RateBounds) uint64 {
+	if b.MinPPS != 0 && rate < b.MinPPS {
+		rate = b.MinPPS
+	}
+	if b.MaxPPS != 0 && rate > b.MaxPPS {
+		rate = b.MaxPPS
+	}
+	return rate
+}
+
+// withinHysteresis reports whether want differs from cur by less than pct
+// percent of cur. An unset (zero) current limit is always replaced.
+func withinHysteresis(cur, want, pct uint64) bool {
+	if cur == 0 {
+		return false
+	}
+	diff := want - cur
+	if want < cur {
+		diff = cur - want
+	}
+	return diff*100 < cur*pct
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/stats"
+)
+
+func TestClampRate(t *testing.T) {
+	b := config.RateBounds{MinPPS: 100, MaxPPS: 1000}
+	tests := []struct {
+		rate, want uint64
+	}{
+		{50, 100},
+		{500, 500},
+		{5000, 1000},
+	}
+	for _, tt := range tests {
+		if got := clampRate(tt.rate, b); got != tt.want {
+			t.Errorf("clampRate(%d) = %d, want %d", tt.rate, got, tt.want)
+		}
+	}
+	if got := clampRate(5000, config.RateBounds{}); got != 5000 {
+		t.Errorf("unbounded clampRate(5000) = %d, want 5000", got)
+	}
+}
+
+func TestWithinHysteresis(t *testing.T) {
+	tests := []struct {
+		cur, want, pct uint64
+		within         bool
+	}{
+		{1000, 1050, 10, true},
+		{1000, 950, 10, true},
+		{1000, 1100, 10, false},
+		{1000, 800, 10, false},
+		{0, 500, 10, false},
+		{1000, 1001, 0, false},
+	}
+	for _, tt := range tests {
+		if got := withinHysteresis(tt.cur, tt.want, tt.pct); got != tt.within {
+			t.Errorf("withinHysteresis(%d, %d, %d) = %v, want %v", tt.cur, tt.want, tt.pct, got, tt.within)
+		}
+	}
+}
+
+func TestBaselineSample(t *testing.T) {
+	var s baselineSample
+	for i := 0; i < 9; i++ {
+		s.add(&stats.Snapshot{RxPPS: float64(100 * (i + 1)), SYNPPS: 10}, 100*time.Millisecond)
+		if s.full() {
+			t.Fatalf("sample full after %d snapshots of 100ms", i+1)
+		}
+	}
+	// Jitter may leave ten 100ms snapshots slightly short of a second.
+	s.add(&stats.Snapshot{RxPPS: 1000, SYNPPS: 10}, 98*time.Millisecond)
+	if !s.full() {
+		t.Fatalf("sample not full after %v", s.covered)
+	}
+	if got := s.rxPPS / float64(s.n); got != 550 {
+		t.Errorf("mean rx = %v, want 550", got)
+	}
+	if got := s.proto.SYN / float64(s.n); got != 10 {
+		t.Errorf("mean SYN = %v, want 10", got)
+	}
+
+	var slow baselineSample
+	slow.add(&stats.Snapshot{}, 2*time.Second)
+	if !slow.full() {
+		t.Error("a single snapshot longer than the interval should fill the sample")
+	}
+}
@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/cluster"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/reputation"
+	"go.uber.org/zap"
+)
+
+// registerClusterSources hands the replicated state to the cluster syncer.
+func (e *Engine) registerClusterSources() {
+	e.cluster.Register(cluster.KindBlacklist, blacklistSource{e.maps})
+	e.cluster.Register(cluster.KindWhitelist, whitelistSource{e.maps})
+	e.cluster.Register(cluster.KindReputation, reputationSource{e.reputation})
+	e.cluster.Register(cluster.KindThreatIntel, threatIntelSource{e.maps})
+	e.cluster.Register(cluster.KindEscalation, escalationSource{e})
+	e.cluster.OnRoleChange(e.handleRoleChange)
+}
+
+// handleRoleChange withdraws BGP announcements on demotion so that only the
+// active scrubber attracts traffic.
+func (e *Engine) handleRoleChange(role cluster.Role) {
+	if role != cluster.RoleStandby || e.bgp == nil {
+		return
+	}
+	if err := e.bgp.WithdrawAll(); err != nil {
+		e.log.Error("failed to withdraw BGP announcements on demotion", zap.Error(err))
+	}
+}
+
+// blacklistSource replicates blacklist_v4 keyed by CIDR, valued by drop
+// reason. Reputation blocks are left to reputationSource.
+type blacklistSource struct{ maps *bpf.MapManager }
+
+func (s blacklistSource) Snapshot() (map[string]string, error) {
+	entries, err := s.maps.BlacklistEntries()
+	if err != nil {
+		return nil, err
+	}
+	snap := make(map[string]string, len(entries))
+	for cidr, rule := range entries {
+		if rule.Reason != bpf.DropReputation {
+			snap[cidr] = strconv.FormatUint(uint64(rule.Reason), 10)
+		}
+	}
+	return snap, nil
+}
+
+func (s blacklistSource) Apply(cidr, value string) error {
+	reason, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid drop reason %q", value)
+	}
+	return s.maps.AddBlacklistCIDR(cidr, uint32(reason))
+}
+
+func (s blacklistSource) Remove(cidr string) error {
+	return s.maps.RemoveBlacklistCIDR(cidr)
+}
+
+// whitelistSource replicates whitelist_v4 keyed by CIDR.
+type whitelistSource struct{ maps *bpf.MapManager }
+
+func (s whitelistSource) Snapshot() (map[string]string, error) {
+	cidrs, err := s.maps.WhitelistEntries()
+	if err != nil {
+		return nil, err
+	}
+	snap := make(map[string]string, len(cidrs))
+	for _, cidr := range cidrs {
+		snap[cidr] = ""
+	}
+	return snap, nil
+}
+
+func (s whitelistSource) Apply(cidr, _ string) error { return s.maps.AddWhitelistCIDR(cidr) }
+
+func (s whitelistSource) Remove(cidr string) error { return s.maps.RemoveWhitelistCIDR(cidr) }
+
+// reputationSource replicates reputation blocks keyed by IP. Blocks
+// received from the peer are installed as manual blocks and lifted when
+// the peer unblocks the address. Blocks shared by fleet members reach
+// both peers from the fleet and are not replicated.
+type reputationSource struct{ rep *reputation.Engine }
+
+func (s reputationSource) Snapshot() (map[string]string, error) {
+	blocked := s.rep.GetBlocked()
+	snap := make(map[string]string, len(blocked))
+	for _, r := range blocked {
+		if r.Origin == "" {
+			snap[r.IP] = ""
+		}
+	}
+	return snap, nil
+}
+
+func (s reputationSource) Apply(ip, _ string) error { return s.rep.BlockIP(ip) }
+
+func (s reputationSource) Remove(ip string) error { return s.rep.UnblockIP(ip) }
+
+// threatIntelSource replicates threat_intel_map keyed by CIDR, valued as
+// "source:type:confidence:action".
+type threatIntelSource struct{ maps *bpf.MapManager }
+
+func (s threatIntelSource) Snapshot() (map[string]string, error) {
+	entries, err := s.maps.ThreatIntelEntries()
+	if err != nil {
+		return nil, err
+	}
+	snap := make(map[string]string, len(entries))
+	for cidr, ti := range entries {
+		snap[cidr] = fmt.Sprintf("%d:%d:%d:%d", ti.SourceID, ti.ThreatType, ti.Confidence, ti.Action)
+	}
+	return snap, nil
+}
+
+func (s threatIntelSource) Apply(cidr, value string) error {
+	var f [4]uint8
+	parts := strings.Split(value, ":")
+	if len(parts) != len(f) {
+		return fmt.Errorf("invalid threat intel value %q", value)
+	}
+	for i, p := range parts {
+		v, err := strconv.ParseUint(p, 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid threat intel value %q", value)
+		}
+		f[i] = uint8(v)
+	}
+	return s.maps.SetThreatIntelEntry(cidr, bpf.ThreatIntelEntry{
+		SourceID:    f[0],
+		ThreatType:  f[1],
+		Confidence:  f[2],
+		Action:      f[3],
+		LastUpdated: uint32(time.Now().Unix()),
+	})
+}
+
+func (s threatIntelSource) Remove(cidr string) error {
+	return s.maps.RemoveThreatIntelEntry(cidr)
+}
+
+// escalationSource replicates the escalation level as a single entry.
+type escalationSource struct{ e *Engine }
+
+const escalationEntryID = "level"
+
+func (s escalationSource) Snapshot() (map[string]string, error) {
+	level, err := s.e.maps.GetConfig(bpf.CfgEscalationLevel)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{escalationEntryID: strconv.FormatUint(level, 10)}, nil
+}
+
+func (s escalationSource) Apply(_, value string) error {
+	level, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || level > uint64(escalation.Critical) {
+		return fmt.Errorf("invalid escalation level %q", value)
+	}
+	prev, _ := s.e.maps.GetConfig(bpf.CfgEscalationLevel)
+	if err := s.e.maps.SetConfig(bpf.CfgEscalationLevel, level); err != nil {
+		return err
+	}
+	if prev != level {
+		from, to := escalation.Level(prev), escalation.Level(level)
+		s.e.apiServer.BroadcastEscalation(from, to)
+		s.e.manualEscalationChanged(from, to, "synced from cluster peer")
+	}
+	return nil
+}
+
+// Remove is a no-op: the escalation level always exists.
+func (s escalationSource) Remove(string) error { return nil }
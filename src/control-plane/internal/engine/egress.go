@@ -0,0 +1,22 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/egress"
+)
+
+// startEgress programs the configured outbound policy and attaches the TC
+// egress program next to XDP.
+func (e *Engine) startEgress() error {
+	e.egress = egress.NewManager(e.log, e.maps)
+	if err := e.egress.Apply(e.cfg.Egress.Policy); err != nil {
+		return fmt.Errorf("applying egress policy: %w", err)
+	}
+	mode, err := e.loader.AttachEgress(e.cfg.Interface)
+	if err != nil {
+		return fmt.Errorf("attaching TC egress: %w", err)
+	}
+	e.egress.SetAttachment(mode, e.cfg.Interface)
+	return nil
+}
@@ -6,14 +6,43 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/cilium/ebpf/link"
 	"github.com/ebpf-ddos-scrubber/control-plane/internal/api"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/approval"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/assets"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/attacks"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/baseline"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bgp"
 	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/capture"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/cluster"
 	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/dependency"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/diversion"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/egress"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/fleet"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/geoip"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/httpflood"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/inspect"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/kube"
 	"github.com/ebpf-ddos-scrubber/control-plane/internal/events"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/maintenance"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/notify"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/rateclass"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/reputation"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/revisions"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/schedule"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/siglearn"
 	"github.com/ebpf-ddos-scrubber/control-plane/internal/stats"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/threatintel"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/tunnel"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/watchdog"
 	"go.uber.org/zap"
 )
 
@@ -26,12 +55,67 @@ type Engine struct {
 	maps   *bpf.MapManager
 
 	statsCollector *stats.Collector
+	topTalkers     *stats.TopTalkers
+	history        *stats.History
+	attacks        *attacks.Tracker
+	baseline       *baseline.Baseline
 	eventReader    *events.Reader
 	apiServer      *api.Server
+	reputation     *reputation.Engine
+	dependencies   *dependency.Tracker
+	bgp            *bgp.Client
+	autoFlowspec   *bgp.AutoFlowspec
+	notifier       *notify.Notifier
+	capturer       *capture.Capturer
+	sigLearner     *siglearn.Learner
+	cluster        *cluster.Syncer
+	fleet          *fleet.Fleet
+	geoip          *geoip.Manager
+	asnDB          *geoip.ASNDB
+	asn            *geoip.ASNManager
+	threatIntel    *threatintel.Manager
+	tunnels        *tunnel.Manager
+	diversion      *diversion.Manager
+	assets         *assets.Registry
+	rateClasses    *rateclass.Registry
+	egress         *egress.Manager
+	inspector      *inspect.Inspector
+	httpFlood      *httpflood.Detector
+	escalation     *escalation.Engine
+	approvals      *approval.Manager
+	scheduler      *schedule.Scheduler
+	maintenance    *maintenance.Manager
+	watchdog       *watchdog.Watchdog
+	operator       *kube.Operator
+	loadPolicy     func(data []byte) (*config.Config, error)
+	revisions      *revisions.Store
+	critical       criticalRules
+	sinks          []eventSink
 
-	cancel context.CancelFunc
+	cancel       context.CancelFunc
+	notifyCancel context.CancelFunc // stopped after BGP so withdrawals are reported
+	sinkCancel   context.CancelFunc // event sinks, stopped once the event ring is drained
+	wg     sync.WaitGroup // background loops that write to maps or sinks
 }
 
+// State files written to Shutdown.StateDir.
+const (
+	reputationStateFile = "reputation.json"
+	bgpStateFile        = "bgp.json"
+	historyStateFile    = "stats_history.json"
+	assetsStateFile     = "assets.json"
+	rateClassStateFile  = "rate_classes.json"
+	attacksStateFile    = "attacks.json"
+	revisionsStateFile  = "config_revisions.json"
+	baselineStateFile   = "baseline.json"
+
+	defaultShutdownTimeout = 15 * time.Second
+
+	// Top talkers are polled every second and ranked over a 10s window.
+	topTalkersInterval = time.Second
+	topTalkersWindow   = 10 * time.Second
+)
+
 // New creates a new Engine with the given configuration.
 func New(log *zap.Logger, cfg *config.Config) *Engine {
 	return &Engine{
@@ -49,6 +133,7 @@ func (e *Engine) Start(ctx context.Context) error {
 	e.log.Info("=== Starting DDoS Scrubber Engine ===")
 
 	e.loader = bpf.NewLoader(e.log, e.cfg.BPFObject)
+	e.loader.SetVerifierLogLines(e.cfg.VerifierLogLines)
 	if err := e.loader.Load(); err != nil {
 		return fmt.Errorf("loading BPF program: %w", err)
 	}
@@ -64,45 +149,476 @@ func (e *Engine) Start(ctx context.Context) error {
 		return fmt.Errorf("applying config: %w", err)
 	}
 
+	// Protected assets: the saved registry takes precedence over the
+	// configured list, which only seeds the first start.
+	e.assets = assets.NewRegistry(e.log, e.maps)
+	restored := false
+	if path := e.statePath(assetsStateFile); path != "" {
+		ok, err := e.assets.LoadState(path)
+		if err != nil {
+			e.log.Warn("failed to restore asset registry", zap.Error(err))
+		}
+		restored = ok
+	}
+	if !restored {
+		if err := e.assets.Configure(e.cfg.Assets); err != nil {
+			e.loader.Close()
+			return fmt.Errorf("configuring protected assets: %w", err)
+		}
+	}
+
+	// Rate classes, restored the same way
+	e.rateClasses = rateclass.NewRegistry(e.log, e.maps)
+	restored = false
+	if path := e.statePath(rateClassStateFile); path != "" {
+		ok, err := e.rateClasses.LoadState(path)
+		if err != nil {
+			e.log.Warn("failed to restore rate classes", zap.Error(err))
+		}
+		restored = ok
+	}
+	if !restored {
+		if err := e.rateClasses.Configure(e.cfg.RateClasses); err != nil {
+			e.loader.Close()
+			return fmt.Errorf("configuring rate classes: %w", err)
+		}
+	}
+
+	// Country data for the GeoIP module and event enrichment
+	if db := e.cfg.GeoIP.Database; db != "" {
+		objs := e.loader.Objects()
+		e.geoip = geoip.NewManager(e.log, objs.GeoIPMap, objs.GeoIPPolicy,
+			objs.GeoIPRate, objs.GeoIPBucket, objs.GeoIPStats)
+		if err := e.geoip.Load(db, e.cfg.GeoIP.Locations); err != nil {
+			e.loader.Close()
+			return fmt.Errorf("loading GeoIP data: %w", err)
+		}
+		if err := e.applyGeoIPPolicies(); err != nil {
+			e.loader.Close()
+			return err
+		}
+		e.goBackground(func() { e.geoip.Run(ctx) })
+	}
+	if err := e.loadASN(); err != nil {
+		e.loader.Close()
+		return err
+	}
+
+	// Keep upstream dependencies of the protected service whitelisted.
+	if deps := e.cfg.Dependencies; len(deps.Hosts) > 0 {
+		e.dependencies = dependency.NewTracker(e.log, e.maps, deps.Hosts,
+			time.Duration(deps.RefreshSec)*time.Second,
+			time.Duration(deps.TTLSec)*time.Second,
+			e.cfg.Whitelist,
+		)
+		e.goBackground(func() { e.dependencies.Run(ctx) })
+	}
+
 	// Step 4: NOW attach to interface (safe — maps are populated)
-	flags := xdpFlags(e.cfg.XDPMode)
-	if err := e.loader.Attach(e.cfg.Interface, flags); err != nil {
+	if err := e.attachXDP(); err != nil {
 		e.loader.Close()
-		return fmt.Errorf("attaching XDP: %w", err)
+		return err
+	}
+
+	// Step 4b: Outbound scrubbing on TC egress
+	if e.cfg.Egress.Enabled {
+		if err := e.startEgress(); err != nil {
+			e.loader.Close()
+			return err
+		}
+	}
+
+	// Step 4c: Deep inspection, redirected by the attached XDP program
+	if e.cfg.Inspect.Enabled {
+		e.inspector = inspect.NewInspector(e.log, e.cfg.Inspect, e.maps)
+		if err := e.inspector.Start(e.cfg.Interface); err != nil {
+			e.loader.Close()
+			return fmt.Errorf("starting deep inspection: %w", err)
+		}
+		e.goBackground(func() { e.inspector.Run(ctx) })
 	}
 
 	// Step 5: Start stats collector
-	e.statsCollector = stats.NewCollector(e.log, e.maps, time.Second)
-	go e.statsCollector.Run(ctx)
+	e.statsCollector = stats.NewCollector(e.log, e.maps, e.cfg.Stats.Interval())
+	e.goBackground(func() { e.statsCollector.Run(ctx) })
+
+	e.topTalkers = stats.NewTopTalkers(e.log, e.maps, topTalkersInterval, topTalkersWindow)
+	e.goBackground(func() { e.topTalkers.Run(ctx) })
+
+	e.history = stats.NewHistory(e.log, e.cfg.StatsHistory)
+	if path := e.historyPath(); path != "" {
+		if err := e.history.LoadState(path); err != nil {
+			e.log.Warn("failed to restore stats history", zap.Error(err))
+		}
+	}
+	historyFeed := e.statsCollector.Subscribe(4)
+	e.goBackground(func() { e.history.Run(ctx, historyFeed) })
 
-	// Step 6: Start event reader
-	e.eventReader = events.NewReader(e.log, e.loader.Objects().Events)
-	e.eventReader.OnEvent(func(ev *bpf.Event) {
+	e.attacks = attacks.NewTracker(e.log, e.cfg.Attacks)
+	if path := e.statePath(attacksStateFile); path != "" {
+		if err := e.attacks.LoadState(path); err != nil {
+			e.log.Warn("failed to restore attack history", zap.Error(err))
+		}
+	}
+	attacksFeed := e.statsCollector.Subscribe(4)
+	e.goBackground(func() { e.attacks.Run(ctx, attacksFeed) })
+
+	// Learn the traffic baseline and, in adaptive mode, derive rate limits
+	objs := e.loader.Objects()
+	e.baseline = baseline.NewBaseline(e.log, e.maps.ConfigTable())
+	if path := e.statePath(baselineStateFile); path != "" {
+		if err := e.baseline.LoadState(path); err != nil {
+			e.log.Warn("failed to restore baseline", zap.Error(err))
+		}
+		e.baseline.SetStatePath(path)
+	}
+	baselineFeed := e.statsCollector.Subscribe(4)
+	e.goBackground(func() { e.feedBaseline(ctx, baselineFeed) })
+	if err := e.baseline.Start(ctx); err != nil {
+		e.loader.Close()
+		return fmt.Errorf("starting baseline engine: %w", err)
+	}
+	e.goBackground(func() { e.runAdaptiveRates(ctx) })
+
+	// Notifications outlive ctx until Stop has applied the BGP policy.
+	if e.cfg.Notifications.Enabled {
+		e.notifier = notify.NewNotifier(e.log, e.cfg.Notifications)
+		notifyCtx, notifyCancel := context.WithCancel(context.WithoutCancel(ctx))
+		e.notifyCancel = notifyCancel
+		go e.notifier.Run(notifyCtx)
+	}
+
+	// Step 6: Start reputation engine
+	e.reputation = reputation.NewEngine(e.log, objs.ReputationMap, objs.BlacklistV4, e.maps.ConfigTable())
+	if err := e.reputation.SetConfig(e.cfg.Reputation); err != nil {
+		e.loader.Close()
+		return fmt.Errorf("configuring reputation engine: %w", err)
+	}
+	if path := e.statePath(reputationStateFile); path != "" {
+		if err := e.reputation.LoadState(path); err != nil {
+			e.log.Warn("failed to restore reputation state", zap.Error(err))
+		}
+	}
+	if e.notifier != nil {
+		e.attacks.OnEnd(func(a attacks.Attack) {
+			e.notifier.AttackEnded(a.ID, a.DurationSec, a.PeakDropPPS, a.DroppedPackets, a.VectorNames())
+		})
+	}
+	e.reputation.OnAutoBlock(func(ip string, score, threshold uint32) {
+		e.attacks.Mitigation("reputation_block", ip)
+		if e.notifier != nil {
+			e.notifier.ReputationBlocked(ip, score, threshold)
+		}
+	})
+	if err := e.reputation.Start(ctx); err != nil {
+		e.loader.Close()
+		return fmt.Errorf("starting reputation engine: %w", err)
+	}
+
+	// Pull threat intel feeds into threat_intel_map
+	if e.cfg.ThreatIntel.Enabled {
+		e.threatIntel = threatintel.NewManager(e.log, objs.ThreatIntel, objs.BlacklistV4)
+		e.threatIntel.SetProtectedSource(e.threatIntelProtected)
+		e.threatIntel.SetStatsReset(e.maps.ResetThreatIntelFeedStats)
+		if err := e.threatIntel.Configure(e.cfg.ThreatIntel); err != nil {
+			e.loader.Close()
+			return fmt.Errorf("configuring threat intel: %w", err)
+		}
+		if err := e.maps.SetConfig(bpf.CfgThreatIntelEn, 1); err != nil {
+			e.loader.Close()
+			return fmt.Errorf("enabling threat intel: %w", err)
+		}
+		// In a fleet only the leader pulls; followers mirror its entries.
+		e.threatIntel.SetPaused(e.cfg.Fleet.Enabled)
+		e.goBackground(func() { e.threatIntel.Run(ctx) })
+	}
+
+	// Step 7: Start event reader
+	e.eventReader = events.NewReader(e.log, objs.Events)
+	if e.cfg.EventSampling.Enabled {
+		e.eventReader.SetSampling(e.cfg.EventSampling)
+	}
+	if err := e.startEnrichment(ctx); err != nil {
+		e.loader.Close()
+		return err
+	}
+	e.eventReader.OnEnrichedEvent(func(ev *bpf.Event, info events.Info) {
 		e.log.Debug("event",
 			zap.String("detail", bpf.FormatEvent(ev)),
 			zap.String("attack", bpf.AttackTypeName(ev.AttackType)),
 		)
 		// Forward events to WebSocket clients
 		if e.apiServer != nil {
-			e.apiServer.BroadcastEvent(ev)
+			e.apiServer.BroadcastEvent(ev, info)
 		}
 	})
-	go func() {
+	e.eventReader.OnEnrichedEvent(e.attacks.HandleEvent)
+	// Drop events feed userspace reputation scoring between map polls.
+	e.eventReader.OnEvent(e.reputation.HandleEvent)
+	e.eventReader.OnEvent(e.handlePortScan)
+	if err := e.startEventSinks(ctx); err != nil {
+		e.loader.Close()
+		return err
+	}
+	lossFeed := e.statsCollector.Subscribe(4)
+	e.goBackground(func() { e.watchEventLoss(ctx, lossFeed) })
+	e.goBackground(func() {
 		if err := e.eventReader.Run(ctx); err != nil {
 			e.log.Error("event reader error", zap.Error(err))
 		}
-	}()
+	})
 
-	// Step 7: Start SYN cookie seed rotation
-	go e.rotateSYNCookieSeeds(ctx)
+	// Packet capture to pcap, on demand or at Capture.AutoLevel
+	if e.cfg.Capture.Enabled {
+		e.capturer = capture.NewCapturer(e.log, e.cfg.Capture, e.maps, objs.CaptureEvents)
+		if e.cfg.SignatureLearning.Enabled {
+			e.sigLearner = siglearn.NewLearner(e.log, e.cfg.SignatureLearning, e.maps)
+			e.capturer.OnSample(e.sigLearner.Observe)
+			e.goBackground(func() { e.sigLearner.Run(ctx) })
+		}
+		e.goBackground(func() {
+			if err := e.capturer.Run(ctx); err != nil {
+				e.log.Error("capture reader error", zap.Error(err))
+			}
+		})
+	}
+
+	// Step 8: Start SYN cookie seed rotation, temporary ACL expiry and
+	// connection count sync
+	e.goBackground(func() { e.rotateSYNCookieSeeds(ctx) })
+	e.goBackground(func() { e.maps.RunACLJanitor(ctx) })
+	if e.cfg.ConnLimit.Enabled() {
+		e.goBackground(func() { e.syncConnCounts(ctx) })
+	}
 
-	// Step 8: Start gRPC API server
+	// Step 9: Establish the BGP session. It outlives ctx so that the
+	// shutdown policy can still withdraw announcements after the loops stop.
+	if e.cfg.BGP.Enabled {
+		e.bgp = bgp.NewClient(e.log, e.cfg.BGP)
+		if err := e.bgp.Connect(context.WithoutCancel(ctx)); err != nil {
+			e.loader.Close()
+			return fmt.Errorf("connecting BGP: %w", err)
+		}
+		// A fleet member restarts as a follower and announces nothing
+		// until elected.
+		if path := e.statePath(bgpStateFile); path != "" && !e.cfg.Fleet.Enabled {
+			if err := e.bgp.RestoreState(path); err != nil {
+				e.log.Warn("failed to restore BGP state", zap.Error(err))
+			}
+		}
+		// Registered after the restore so restarts don't re-notify.
+		if e.notifier != nil {
+			community := e.cfg.BGP.CommunityBlackhole
+			e.bgp.OnBlackhole(func(prefix string, announced bool) {
+				if announced {
+					e.notifier.BlackholeAnnounced(prefix, community)
+				} else {
+					e.notifier.BlackholeWithdrawn(prefix)
+				}
+			})
+		}
+		// Flowspec rules from matching attack signatures, announced while
+		// escalation is HIGH or above.
+		if e.cfg.BGP.AutoFlowspec {
+			e.autoFlowspec = bgp.NewAutoFlowspec(e.log, e.bgp, e.maps)
+			e.goBackground(func() { e.autoFlowspec.Run(ctx) })
+		}
+	}
+
+	// GRE return tunnels, moved to their backups by health checks
+	if e.cfg.GRE.Enabled {
+		e.tunnels = tunnel.NewManager(e.log, e.cfg.GRE, e.maps)
+		if err := e.tunnels.Configure(e.cfg.GRE.Tunnels); err != nil {
+			e.loader.Close()
+			return fmt.Errorf("configuring GRE tunnels: %w", err)
+		}
+		if e.notifier != nil {
+			e.tunnels.OnChange(func(c tunnel.Change) {
+				e.notifier.TunnelChanged(c.Name, c.Prefix, c.From, c.To, c.Reason, c.Primary)
+			})
+		}
+		e.goBackground(func() { e.tunnels.Run(ctx) })
+	}
+
+	// Scrubbing-center diversion, started and stopped with escalation.
+	// Config validation guarantees the BGP client and tunnels exist.
+	if e.cfg.Diversion.Enabled {
+		e.diversion = diversion.NewManager(e.log, e.cfg.Diversion, e.bgp, e.maps, e.tunnels)
+		e.goBackground(func() { e.diversion.Run(ctx) })
+	}
+
+	// Step 10: Prepare state sync with the peer scrubber
+	if e.cfg.Cluster.Enabled {
+		syncer, err := cluster.NewSyncer(e.log, e.cfg.Cluster)
+		if err != nil {
+			e.loader.Close()
+			return fmt.Errorf("creating cluster syncer: %w", err)
+		}
+		e.cluster = syncer
+		e.registerClusterSources()
+	}
+
+	// Leader election among the scrubbers of the same anycast prefixes
+	if e.cfg.Fleet.Enabled {
+		f, err := fleet.New(e.log, e.cfg.Fleet)
+		if err != nil {
+			e.loader.Close()
+			return fmt.Errorf("creating fleet member: %w", err)
+		}
+		e.fleet = f
+		e.registerFleetSources()
+	}
+
+	// Configuration history: the applied configuration as it stands now
+	// becomes the first revision of this run.
+	e.revisions = revisions.NewStore(e.log, e.cfg, e.maps)
+	if path := e.statePath(revisionsStateFile); path != "" {
+		if err := e.revisions.LoadState(path); err != nil {
+			e.log.Warn("failed to restore config revisions", zap.Error(err))
+		}
+	}
+	if _, err := e.revisions.Record("startup", ""); err != nil {
+		e.log.Warn("failed to record startup config revision", zap.Error(err))
+	}
+
+	// Escalation thresholds are scaled by the scheduled policy profile, so
+	// the engine exists before the scheduler; it starts in step 12.
+	e.escalation = escalation.NewEngine(e.log, e.maps.ConfigTable())
+	if err := e.escalation.SetConfig(e.cfg.Escalation); err != nil {
+		e.loader.Close()
+		return fmt.Errorf("configuring escalation profiles: %w", err)
+	}
+	if e.cfg.Escalation.PerPrefix {
+		e.escalation.SetPrefixMap(e.maps)
+	}
+
+	// RTBH and GeoIP enforcement at CRITICAL may wait for a NOC decision.
+	var approvalNotifier approval.Notifier
+	if e.notifier != nil {
+		approvalNotifier = e.notifier
+	}
+	e.approvals = approval.NewManager(e.log, e.cfg.Approval, approvalNotifier)
+	if e.cfg.Approval.Enabled {
+		e.escalation.SetGate(e.approvals)
+		e.goBackground(func() { e.approvals.Run(ctx) })
+	}
+
+	// HTTP request floods are spotted in conntrack and raise an indicator
+	// with the escalation engine; detection starts alongside it.
+	if e.cfg.HTTPFlood.Enabled {
+		e.httpFlood = httpflood.NewDetector(e.log, e.cfg.HTTPFlood, e.maps, e.escalation)
+	}
+
+	// Policy profiles switched on a schedule; the first one applies once
+	// the escalation engine runs.
+	if e.cfg.Schedule.Enabled() {
+		var geo schedule.GeoIP
+		if e.geoip != nil {
+			geo = e.geoip
+		}
+		sched, err := schedule.NewScheduler(e.log, e.cfg.Schedule, e.maps, geo, e.escalation)
+		if err != nil {
+			e.loader.Close()
+			return fmt.Errorf("creating policy scheduler: %w", err)
+		}
+		e.scheduler = sched
+	}
+
+	// Maintenance mode: pass-all or a detached program on request,
+	// reverted when it times out.
+	e.maintenance = maintenance.NewManager(e.log, e.cfg.Maintenance, maintenanceDataplane{e})
+	e.goBackground(func() { e.maintenance.Run(ctx) })
+
+	// Watchdog: reattach the program and restore config values when other
+	// tooling on the box removes or overwrites them.
+	if e.cfg.Watchdog.Enabled {
+		e.watchdog = watchdog.New(e.log, e.cfg.Watchdog, watchdogDataplane{e})
+		if e.notifier != nil {
+			e.watchdog.OnFinding(func(f watchdog.Finding) {
+				e.notifier.WatchdogFinding(f.Kind, f.Detail, f.Repaired, f.Error)
+			})
+		}
+		e.goBackground(func() { e.watchdog.Run(ctx) })
+	}
+
+	// Step 11: Start gRPC API server
 	e.apiServer = api.NewServer(e.log, e.cfg, e.maps, e.statsCollector, e.eventReader)
+	e.apiServer.SetLoader(e.loader)
+	e.apiServer.SetTopTalkers(e.topTalkers)
+	e.apiServer.SetHistory(e.history)
+	e.apiServer.SetBaseline(e.baseline)
+	e.apiServer.SetEscalation(e.escalation)
+	e.apiServer.SetApprovals(e.approvals)
+	e.apiServer.SetAttacks(e.attacks)
+	e.apiServer.SetCapturer(e.capturer)
+	e.apiServer.SetSignatureLearner(e.sigLearner)
+	e.apiServer.SetCluster(e.cluster)
+	e.apiServer.SetGeoIP(e.geoip)
+	e.apiServer.SetASN(e.asn)
+	e.apiServer.SetReputation(e.reputation)
+	e.apiServer.SetThreatIntel(e.threatIntel)
+	e.apiServer.SetBGP(e.bgp)
+	e.apiServer.SetTunnels(e.tunnels)
+	e.apiServer.SetDiversion(e.diversion)
+	e.apiServer.SetAssets(e.assets)
+	e.apiServer.SetRateClasses(e.rateClasses)
+	e.apiServer.SetEgress(e.egress)
+	e.apiServer.SetInspector(e.inspector)
+	e.apiServer.SetHTTPFlood(e.httpFlood)
+	e.apiServer.SetRevisions(e.revisions)
+	e.apiServer.SetScheduler(e.scheduler)
+	e.apiServer.SetMaintenance(e.maintenance)
+	e.apiServer.SetWatchdog(e.watchdog)
+	e.apiServer.SetFleet(e.fleet)
+	e.apiServer.OnEscalationChange(e.manualEscalationChanged)
 	if err := e.apiServer.Start(); err != nil {
 		e.loader.Close()
 		return fmt.Errorf("starting API server: %w", err)
 	}
 
+	// Step 12: Drive the escalation level from traffic stats and the
+	// baseline. Transitions broadcast over the API, so this starts after it.
+	if err := e.escalation.Start(ctx); err != nil {
+		e.apiServer.Stop(context.Background())
+		e.loader.Close()
+		return fmt.Errorf("starting escalation engine: %w", err)
+	}
+	e.escalation.OnLevelChange(e.autoEscalationChanged)
+	e.escalation.OnCritical(e.announceCritical)
+	e.escalation.OnDeescalate(e.withdrawCritical)
+	escalationFeed := e.statsCollector.Subscribe(8)
+	e.goBackground(func() { e.runEscalation(ctx, escalationFeed) })
+	if e.scheduler != nil {
+		e.goBackground(func() { e.scheduler.Run(ctx) })
+	}
+	if e.httpFlood != nil {
+		e.goBackground(func() { e.httpFlood.Run(ctx) })
+	}
+
+	// Kubernetes mode: follow the policy and report to it once everything
+	// the status covers is running.
+	if e.operator != nil {
+		e.operator.OnChange(e.applyPolicy)
+		e.operator.SetReporter(e.nodeStatus)
+		e.goBackground(func() { e.operator.Run(ctx) })
+	}
+
+	// Peer changes may broadcast escalations, so sync starts after the API.
+	if e.cluster != nil {
+		e.goBackground(func() {
+			if err := e.cluster.Run(ctx); err != nil {
+				e.log.Error("cluster sync error", zap.Error(err))
+			}
+		})
+	}
+	if e.fleet != nil {
+		e.goBackground(func() {
+			if err := e.fleet.Run(ctx); err != nil {
+				e.log.Error("fleet election error", zap.Error(err))
+			}
+		})
+	}
+
 	e.log.Info("=== DDoS Scrubber Engine Started ===",
 		zap.String("interface", e.cfg.Interface),
 		zap.String("mode", e.cfg.XDPMode),
@@ -112,18 +628,90 @@ func (e *Engine) Start(ctx context.Context) error {
 	return nil
 }
 
-// Stop gracefully shuts down all components.
+// Stop performs an orderly drain: API mutations are rejected, background
+// loops finish their in-flight map writes and the event ring is flushed to
+// its handlers, state is persisted, BGP announcements are withdrawn or kept
+// per policy, and only then is XDP detached.
 func (e *Engine) Stop() {
 	e.log.Info("=== Stopping DDoS Scrubber Engine ===")
 
+	timeout := time.Duration(e.cfg.Shutdown.TimeoutSec) * time.Second
+	if timeout == 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Step 1: Stop accepting API mutations
+	if e.apiServer != nil {
+		e.apiServer.Drain()
+	}
+	// A GeoIP load started over the API would otherwise hold up shutdown.
+	if e.geoip != nil {
+		e.geoip.CancelLoad()
+	}
+
+	// Step 2: Stop background loops and wait for them to finish writing
 	if e.cancel != nil {
 		e.cancel()
 	}
+	e.waitBackground(ctx)
+	e.stopEventSinks(ctx)
+	if e.asnDB != nil {
+		e.asnDB.Close()
+	}
 
+	// Step 3: Persist state
+	if path := e.statePath(reputationStateFile); path != "" && e.reputation != nil {
+		if err := e.reputation.SaveState(path); err != nil {
+			e.log.Error("failed to persist reputation state", zap.Error(err))
+		}
+	}
+	if path := e.statePath(assetsStateFile); path != "" && e.assets != nil {
+		if err := e.assets.SaveState(path); err != nil {
+			e.log.Error("failed to persist asset registry", zap.Error(err))
+		}
+	}
+	if path := e.statePath(rateClassStateFile); path != "" && e.rateClasses != nil {
+		if err := e.rateClasses.SaveState(path); err != nil {
+			e.log.Error("failed to persist rate classes", zap.Error(err))
+		}
+	}
+	if path := e.statePath(attacksStateFile); path != "" && e.attacks != nil {
+		if err := e.attacks.SaveState(path); err != nil {
+			e.log.Error("failed to persist attack history", zap.Error(err))
+		}
+	}
+	if path := e.statePath(baselineStateFile); path != "" && e.baseline != nil {
+		if err := e.baseline.SaveState(path); err != nil {
+			e.log.Error("failed to persist baseline", zap.Error(err))
+		}
+	}
+	if path := e.historyPath(); path != "" && e.history != nil {
+		if err := e.history.SaveState(path); err != nil {
+			e.log.Error("failed to persist stats history", zap.Error(err))
+		}
+	}
+	if path := e.statePath(revisionsStateFile); path != "" && e.revisions != nil {
+		if err := e.revisions.SaveState(path); err != nil {
+			e.log.Error("failed to persist config revisions", zap.Error(err))
+		}
+	}
+
+	// Step 4: Withdraw or keep BGP announcements, then deliver the
+	// resulting notifications. Diversion routes are always withdrawn.
+	if e.diversion != nil {
+		e.diversion.Shutdown()
+	}
+	e.stopBGP()
+	e.stopNotifier(ctx)
+
+	// Step 5: Close the API; WebSocket clients have received the flushed events
 	if e.apiServer != nil {
-		e.apiServer.Stop()
+		e.apiServer.Stop(ctx)
 	}
 
+	// Step 6: Detach XDP
 	if e.loader != nil {
 		e.loader.Close()
 	}
@@ -131,6 +719,296 @@ func (e *Engine) Stop() {
 	e.log.Info("=== DDoS Scrubber Engine Stopped ===")
 }
 
+// escalationChanged fans an escalation level change out to notifications,
+// automatic packet capture, diversion, signature Flowspec and the open
+// attack session.
+func (e *Engine) escalationChanged(from, to escalation.Level, reason string) {
+	if e.attacks != nil {
+		e.attacks.Escalated(int(to), to.String())
+		e.attacks.Mitigation("escalation", fmt.Sprintf("%s -> %s (%s)", from, to, reason))
+	}
+	if e.notifier != nil {
+		e.notifier.EscalationChanged(from.String(), to.String(), reason, int(to))
+	}
+	if e.capturer != nil {
+		e.capturer.HandleEscalation(from, to)
+	}
+	if e.diversion != nil {
+		e.diversion.SetLevel(to)
+	}
+	e.setAutoFlowspec(to)
+	if e.geoip != nil {
+		e.geoip.SetLevel(to)
+	}
+}
+
+// goBackground runs fn in a goroutine that Stop waits for.
+func (e *Engine) goBackground(fn func()) {
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		fn()
+	}()
+}
+
+// waitBackground blocks until all background loops have exited or ctx
+// expires.
+func (e *Engine) waitBackground(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		if e.reputation != nil {
+			<-e.reputation.Done()
+		}
+		if e.baseline != nil {
+			<-e.baseline.Done()
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		e.log.Info("background loops drained")
+	case <-ctx.Done():
+		e.log.Warn("timed out draining background loops")
+	}
+}
+
+// stopBGP applies the shutdown BGP policy and tears down the session.
+func (e *Engine) stopBGP() {
+	if e.bgp == nil {
+		return
+	}
+
+	if e.cfg.Shutdown.BGPPolicy == "persist" {
+		e.log.Warn("keeping BGP announcements across restart",
+			zap.Int("announcements", len(e.bgp.GetActiveRules())))
+	} else if err := e.bgp.WithdrawAll(); err != nil {
+		e.log.Error("failed to withdraw BGP announcements", zap.Error(err))
+	}
+
+	if path := e.statePath(bgpStateFile); path != "" {
+		if err := e.bgp.SaveState(path); err != nil {
+			e.log.Error("failed to persist BGP state", zap.Error(err))
+		}
+	}
+
+	if err := e.bgp.Disconnect(); err != nil {
+		e.log.Warn("BGP disconnect failed", zap.Error(err))
+	}
+}
+
+// applyGeoIPPolicies installs the configured per-country rate limits,
+// level and continent policies, and enables GeoIP enforcement in the data
+// plane if requested.
+func (e *Engine) applyGeoIPPolicies() error {
+	for cc, pps := range e.cfg.GeoIP.RateLimits {
+		if err := e.geoip.SetCountryRate(cc, pps); err != nil {
+			return err
+		}
+		if err := e.geoip.SetCountryPolicy(cc, geoip.ActionRateLimit); err != nil {
+			return err
+		}
+	}
+	if err := e.geoip.SetLevelPolicies(e.cfg.GeoIP.LevelPolicies); err != nil {
+		return err
+	}
+	if err := e.geoip.SetRegions(e.cfg.GeoIP.Regions); err != nil {
+		return err
+	}
+	for name, actionStr := range e.cfg.GeoIP.ContinentPolicies {
+		action, _ := geoip.ParseAction(actionStr)
+		if err := e.geoip.SetContinentPolicy(name, action); err != nil {
+			return err
+		}
+	}
+	if e.cfg.GeoIP.Enforce {
+		if err := e.maps.SetConfig(bpf.CfgGeoIPEnable, 1); err != nil {
+			return fmt.Errorf("enabling GeoIP policies: %w", err)
+		}
+	}
+	return nil
+}
+
+// threatIntelProtected returns the prefixes feed entries may not overlap:
+// the whitelist as installed, which includes runtime additions, and the
+// protected assets.
+func (e *Engine) threatIntelProtected() []string {
+	prefixes, err := e.maps.WhitelistEntries()
+	if err != nil {
+		e.log.Warn("failed to read whitelist for threat intel guard", zap.Error(err))
+		prefixes = append([]string{}, e.cfg.Whitelist...)
+	}
+	for _, a := range e.assets.List() {
+		prefixes = append(prefixes, a.Prefix)
+	}
+	return prefixes
+}
+
+// loadASN populates asn_map, applies the configured per-ASN policies and
+// enables ASN enforcement in the data plane.
+func (e *Engine) loadASN() error {
+	db := e.cfg.ASN.Database
+	if db == "" {
+		return nil
+	}
+	objs := e.loader.Objects()
+	e.asn = geoip.NewASNManager(e.log, objs.ASNMap, objs.ASNPolicy)
+	if err := e.asn.Load(db); err != nil {
+		return fmt.Errorf("loading ASN data: %w", err)
+	}
+	for asnStr, actionStr := range e.cfg.ASN.Policies {
+		// Validated by config.Validate.
+		asn, _ := geoip.ParseASN(asnStr)
+		action, _ := geoip.ParseAction(actionStr)
+		if err := e.asn.SetPolicy(asn, action); err != nil {
+			return err
+		}
+	}
+	if err := e.maps.SetConfig(bpf.CfgASNEnable, 1); err != nil {
+		return fmt.Errorf("enabling ASN policies: %w", err)
+	}
+	return nil
+}
+
+// watchEventLoss passes the BPF count of events lost to a full ring buffer
+// to the event reader, which reports and logs it.
+func (e *Engine) watchEventLoss(ctx context.Context, ch <-chan *stats.Snapshot) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case snap := <-ch:
+			e.eventReader.ObserveLost(snap.Stats.EventsLost, snap.Timestamp)
+		}
+	}
+}
+
+// startEnrichment installs the enrichment stage that annotates events with
+// country, ASN, reputation, escalation level and reverse DNS.
+func (e *Engine) startEnrichment(ctx context.Context) error {
+	if !e.cfg.Enrichment.Enabled {
+		return nil
+	}
+	lookups := events.Lookups{
+		Reputation: e.reputation.Score,
+		Escalation: func() uint8 {
+			level, _ := e.maps.GetConfig(bpf.CfgEscalationLevel)
+			return uint8(level)
+		},
+	}
+	if e.geoip != nil {
+		lookups.Country = e.geoip.LookupCountry
+	}
+	if path := e.cfg.Enrichment.ASNDatabase; path != "" {
+		db, err := geoip.OpenASN(path)
+		if err != nil {
+			return err
+		}
+		e.asnDB = db
+		lookups.ASN = db.Lookup
+	} else if e.asn != nil {
+		lookups.ASN = e.asn.Lookup
+	}
+
+	enricher := events.NewEnricher(e.log, e.cfg.Enrichment, lookups)
+	e.eventReader.SetEnricher(enricher)
+	go enricher.Run(ctx)
+	return nil
+}
+
+// eventSink is an external consumer of the event stream.
+type eventSink interface {
+	Handle(ev *bpf.Event, info events.Info)
+	Run(ctx context.Context)
+	Done() <-chan struct{}
+}
+
+// startEventSinks registers the configured external event sinks with the
+// event reader. Sinks outlive ctx until Stop has drained the event ring.
+func (e *Engine) startEventSinks(ctx context.Context) error {
+	if e.cfg.Syslog.Enabled {
+		sink, err := events.NewSyslogSink(e.log, e.cfg.Syslog)
+		if err != nil {
+			return fmt.Errorf("creating syslog sink: %w", err)
+		}
+		e.sinks = append(e.sinks, sink)
+	}
+	if e.cfg.NATS.Enabled {
+		sink, err := events.NewNATSSink(e.log, e.cfg.NATS)
+		if err != nil {
+			return fmt.Errorf("creating NATS sink: %w", err)
+		}
+		e.sinks = append(e.sinks, sink)
+	}
+	if len(e.sinks) == 0 {
+		return nil
+	}
+
+	sinkCtx, sinkCancel := context.WithCancel(context.WithoutCancel(ctx))
+	e.sinkCancel = sinkCancel
+	for _, sink := range e.sinks {
+		e.eventReader.OnEnrichedEvent(sink.Handle)
+		go sink.Run(sinkCtx)
+	}
+	return nil
+}
+
+// stopEventSinks stops the event sinks once their queues are flushed or
+// ctx expires.
+func (e *Engine) stopEventSinks(ctx context.Context) {
+	if e.sinkCancel == nil {
+		return
+	}
+	e.sinkCancel()
+	for _, sink := range e.sinks {
+		select {
+		case <-sink.Done():
+		case <-ctx.Done():
+			e.log.Warn("timed out flushing event sinks")
+			return
+		}
+	}
+}
+
+// stopNotifier stops the notifier once its queue has been flushed or ctx
+// expires.
+func (e *Engine) stopNotifier(ctx context.Context) {
+	if e.notifier == nil {
+		return
+	}
+	e.notifyCancel()
+	select {
+	case <-e.notifier.Done():
+	case <-ctx.Done():
+		e.log.Warn("timed out flushing notifications")
+	}
+}
+
+// statePath returns the path of a state file in the configured state
+// directory, creating the directory if needed. It returns "" if state
+// persistence is disabled or the directory is unusable.
+func (e *Engine) statePath(name string) string {
+	dir := e.cfg.Shutdown.StateDir
+	if dir == "" {
+		return ""
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		e.log.Warn("state directory unavailable", zap.String("dir", dir), zap.Error(err))
+		return ""
+	}
+	return filepath.Join(dir, name)
+}
+
+// historyPath returns the stats history state file, or "" unless history
+// persistence is enabled.
+func (e *Engine) historyPath() string {
+	if !e.cfg.StatsHistory.Persist {
+		return ""
+	}
+	return e.statePath(historyStateFile)
+}
+
 // applyConfig pushes the YAML configuration into BPF maps.
 func (e *Engine) applyConfig() error {
 	m := e.maps
@@ -177,6 +1055,15 @@ func (e *Engine) applyConfig() error {
 		}
 	}
 
+	// Adaptive rate limiting
+	var adaptive uint64
+	if rl.Adaptive.Enabled {
+		adaptive = 1
+	}
+	if err := m.SetConfig(bpf.CfgAdaptiveRate, adaptive); err != nil {
+		return err
+	}
+
 	// Baseline & threshold
 	if err := m.SetConfig(bpf.CfgBaselinePPS, e.cfg.Scrubber.BaselinePPS); err != nil {
 		return err
@@ -189,17 +1076,13 @@ func (e *Engine) applyConfig() error {
 	}
 
 	// Blacklist
-	for _, cidr := range e.cfg.Blacklist {
-		if err := m.AddBlacklistCIDR(cidr, bpf.DropBlacklist); err != nil {
-			e.log.Warn("failed to add blacklist entry", zap.String("cidr", cidr), zap.Error(err))
-		}
+	if err := m.AddBlacklistCIDRs(e.cfg.Blacklist, bpf.DropBlacklist); err != nil {
+		e.log.Warn("failed to add blacklist entries", zap.Error(err))
 	}
 
 	// Whitelist
-	for _, cidr := range e.cfg.Whitelist {
-		if err := m.AddWhitelistCIDR(cidr); err != nil {
-			e.log.Warn("failed to add whitelist entry", zap.String("cidr", cidr), zap.Error(err))
-		}
+	if err := m.AddWhitelistCIDRs(e.cfg.Whitelist); err != nil {
+		e.log.Warn("failed to add whitelist entries", zap.Error(err))
 	}
 
 	// Amplification-sensitive ports
@@ -209,6 +1092,31 @@ func (e *Engine) applyConfig() error {
 		}
 	}
 
+	if err := m.SetSYNCookieDests(e.cfg.SYNCookie.Destinations); err != nil {
+		return err
+	}
+
+	// Per-source connection limits
+	if err := m.SetConfig(bpf.CfgConnLimit, uint64(e.cfg.ConnLimit.PerSource)); err != nil {
+		return err
+	}
+	if err := m.SetConnLimits(e.cfg.ConnLimit.Prefixes); err != nil {
+		return err
+	}
+
+	// TLS and QUIC handshake rates per source
+	if err := m.SetConfig(bpf.CfgTLSHSRate, e.cfg.HandshakeFlood.TLSRatePPS); err != nil {
+		return err
+	}
+	if err := m.SetConfig(bpf.CfgQUICInitRate, e.cfg.HandshakeFlood.QUICRatePPS); err != nil {
+		return err
+	}
+
+	// Port scan detection
+	if err := e.applyPortScan(); err != nil {
+		return err
+	}
+
 	// Initial SYN cookie seeds
 	seed1, seed2 := randomSeed(), randomSeed()
 	if err := m.UpdateSYNCookieSeeds(seed1, seed2, uint64(time.Now().UnixNano())); err != nil {
@@ -253,6 +1161,57 @@ func (e *Engine) rotateSYNCookieSeeds(ctx context.Context) {
 	}
 }
 
+// syncConnCounts periodically recounts open connections per source from
+// conntrack so evicted and idle flows stop counting against the limit.
+func (e *Engine) syncConnCounts(ctx context.Context) {
+	interval := time.Duration(e.cfg.ConnLimit.SyncIntervalSec) * time.Second
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	e.log.Info("connection count sync started", zap.Duration("interval", interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sources, err := e.maps.SyncConnCounts()
+			if err != nil {
+				e.log.Warn("failed to sync connection counts", zap.Error(err))
+				continue
+			}
+			if len(sources) > 0 {
+				e.log.Debug("connection counts synced",
+					zap.Int("sources", len(sources)),
+					zap.String("top_source", sources[0].IP.String()),
+					zap.Uint32("top_conns", sources[0].Conns),
+				)
+			}
+		}
+	}
+}
+
+// attachXDP attaches the program in the configured mode, falling back to
+// generic mode where the driver lacks native XDP.
+func (e *Engine) attachXDP() error {
+	flags := xdpFlags(e.cfg.XDPMode)
+	err := e.loader.Attach(e.cfg.Interface, flags)
+	if err != nil && flags == link.XDPDriverMode {
+		// Drivers without native XDP still work in generic mode, slower.
+		e.log.Warn("native XDP unsupported, falling back to generic (skb) mode",
+			zap.String("interface", e.cfg.Interface), zap.Error(err))
+		err = e.loader.Attach(e.cfg.Interface, link.XDPGenericMode)
+	}
+	if err != nil {
+		return fmt.Errorf("attaching XDP: %w", err)
+	}
+	return nil
+}
+
 func xdpFlags(mode string) link.XDPAttachFlags {
 	switch mode {
 	case "offload":
@@ -0,0 +1,366 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/approval"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/assets"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/baseline"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bgp"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/stats"
+	"go.uber.org/zap"
+)
+
+// pulseIndicator is the escalation indicator raised while a recognised
+// pulse-wave attack is bursting.
+const pulseIndicator = "pulse_wave"
+
+// criticalFlowspecMax caps the Flowspec drop rules announced for blocked
+// sources when escalation reaches CRITICAL.
+const criticalFlowspecMax = 100
+
+// pulseEscalation returns the level earlier bursts of a pulse-wave attack
+// reached, so that escalation returns to it as soon as the next burst
+// starts instead of climbing again from LOW.
+type pulseEscalation struct {
+	peak escalation.Level
+}
+
+// indicator returns the level to hold the pulse_wave indicator at for the
+// current pulse state: the peak while a recognised pattern bursts, Low
+// otherwise.
+func (p *pulseEscalation) indicator(pulse baseline.Pulse) escalation.Level {
+	if len(pulse.Bursts) == 0 && !pulse.InBurst {
+		p.peak = escalation.Low
+	}
+	if pulse.Recognized && pulse.InBurst {
+		return p.peak
+	}
+	return escalation.Low
+}
+
+// observe records the level reached by an evaluation while bursts are
+// being tracked. Levels reached just after a burst count too, as the
+// evaluation averages the rates since the previous one.
+func (p *pulseEscalation) observe(pulse baseline.Pulse, level escalation.Level) {
+	if (pulse.InBurst || len(pulse.Bursts) > 0) && level > p.peak {
+		p.peak = level
+	}
+}
+
+// rateSample averages stats snapshot rates between two evaluations.
+type rateSample struct {
+	sum escalation.Metrics
+	n   int
+}
+
+func (s *rateSample) add(snap *stats.Snapshot) {
+	s.sum.RxPPS += snap.RxPPS
+	s.sum.DropPPS += snap.DropPPS
+	s.sum.ConntrackNewPPS += snap.ConntrackNewPPS
+	s.sum.SYNCookieFailedPPS += snap.SYNCookieFailedPPS
+	s.sum.ThreatIntelPPS += snap.ThreatIntelPPS
+	s.n++
+}
+
+// mean returns the average rates and resets the sample.
+func (s *rateSample) mean() escalation.Metrics {
+	var m escalation.Metrics
+	if n := float64(s.n); n > 0 {
+		m.RxPPS, m.DropPPS = s.sum.RxPPS/n, s.sum.DropPPS/n
+		m.ConntrackNewPPS = s.sum.ConntrackNewPPS / n
+		m.SYNCookieFailedPPS = s.sum.SYNCookieFailedPPS / n
+		m.ThreatIntelPPS = s.sum.ThreatIntelPPS / n
+	}
+	*s = rateSample{}
+	return m
+}
+
+// prefixRates turns the cumulative per-asset traffic counters into rates
+// between evaluations.
+type prefixRates struct {
+	prev map[string]assets.Stats
+	at   time.Time
+}
+
+// sample returns the rates towards each asset since the previous call. The
+// first call, and an asset whose counters went backwards because it was
+// re-added, report no traffic.
+func (p *prefixRates) sample(list []assets.Stats, now time.Time) []escalation.PrefixSample {
+	secs := now.Sub(p.at).Seconds()
+	out := make([]escalation.PrefixSample, 0, len(list))
+	cur := make(map[string]assets.Stats, len(list))
+	for _, s := range list {
+		cur[s.Prefix] = s
+		ps := escalation.PrefixSample{Prefix: s.Prefix}
+		if prev, ok := p.prev[s.Prefix]; ok && secs > 0 &&
+			s.RxPackets >= prev.RxPackets && s.DroppedPackets >= prev.DroppedPackets {
+			ps.RxPPS = float64(s.RxPackets-prev.RxPackets) / secs
+			ps.DropPPS = float64(s.DroppedPackets-prev.DroppedPackets) / secs
+		}
+		out = append(out, ps)
+	}
+	p.prev, p.at = cur, now
+	return out
+}
+
+// withoutPrefixes takes the traffic towards escalated prefixes out of the
+// global rates, so that it only escalates the prefixes it is aimed at.
+func withoutPrefixes(rxPPS, dropPPS float64, samples []escalation.PrefixSample, escalated []escalation.PrefixLevel) (float64, float64) {
+	if len(escalated) == 0 {
+		return rxPPS, dropPPS
+	}
+	up := make(map[string]bool, len(escalated))
+	for _, pl := range escalated {
+		up[pl.Prefix] = true
+	}
+	for _, s := range samples {
+		if up[s.Prefix] {
+			rxPPS -= s.RxPPS
+			dropPPS -= s.DropPPS
+		}
+	}
+	return math.Max(rxPPS, 0), math.Max(dropPPS, 0)
+}
+
+// dropRatio returns dropPPS/rxPPS clamped to [0, 1].
+func dropRatio(rxPPS, dropPPS float64) float64 {
+	if rxPPS <= 0 {
+		return 0
+	}
+	if r := dropPPS / rxPPS; r < 1 {
+		return r
+	}
+	return 1
+}
+
+// runEscalation averages the stats feed and calls Evaluate every
+// escalation.EvalInterval with the averaged rates, the drop ratio, the
+// baseline anomaly score and the number of reputation-blocked sources. While the attack keeps
+// escalation at CRITICAL, active RTBH blackholes are renewed so they do not
+// expire mid-attack. When the baseline recognises a pulse-wave attack,
+// each new burst raises the pulse_wave indicator at the level the earlier
+// bursts reached. With per-prefix escalation, each protected asset is
+// evaluated on its own traffic first and the traffic towards escalated
+// assets is left out of the global evaluation.
+func (e *Engine) runEscalation(ctx context.Context, ch <-chan *stats.Snapshot) {
+	ticker := time.NewTicker(escalation.EvalInterval)
+	defer ticker.Stop()
+
+	e.log.Info("escalation loop started", zap.Duration("interval", escalation.EvalInterval))
+
+	var (
+		sample   rateSample
+		pulses   pulseEscalation
+		prefixes prefixRates
+	)
+	perPrefix := e.cfg.Escalation.PerPrefix
+	first := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case snap := <-ch:
+			// The first snapshot has no previous one to compute rates from.
+			if first {
+				first = false
+				continue
+			}
+			sample.add(snap)
+		case <-ticker.C:
+			if sample.n == 0 {
+				continue
+			}
+			m := sample.mean()
+			if perPrefix {
+				m.RxPPS, m.DropPPS = e.evaluatePrefixes(ctx, &prefixes, m.RxPPS, m.DropPPS)
+			}
+			m.DropRatio = dropRatio(m.RxPPS, m.DropPPS)
+			m.ZScore = e.anomalyScore()
+			// Blocks shared by fleet members say nothing about the
+			// attack here and are not counted.
+			m.ReputationBlocked = len(e.reputation.Offenders(0))
+			pulse := e.baseline.GetPulse()
+			e.escalation.SetIndicator(pulseIndicator, pulses.indicator(pulse))
+			level := e.escalation.Evaluate(ctx, m)
+			pulses.observe(pulse, level)
+			if e.bgp != nil && level == escalation.Critical {
+				e.bgp.RenewBlackholes(0)
+			}
+		}
+	}
+}
+
+// evaluatePrefixes runs the per-prefix evaluation and returns the global
+// rates without the traffic towards the escalated prefixes.
+func (e *Engine) evaluatePrefixes(ctx context.Context, p *prefixRates, rxPPS, dropPPS float64) (float64, float64) {
+	list, err := e.assets.Stats(e.assets.List())
+	if err != nil {
+		e.log.Warn("failed to read asset traffic for prefix escalation", zap.Error(err))
+		return rxPPS, dropPPS
+	}
+	samples := p.sample(list, time.Now())
+	return withoutPrefixes(rxPPS, dropPPS, samples, e.escalation.EvaluatePrefixes(ctx, samples))
+}
+
+// anomalyScore returns the baseline z-score, or 0 while the baseline is
+// still learning and its deviation is meaningless.
+func (e *Engine) anomalyScore() float64 {
+	if !e.baseline.IsOperational() {
+		return 0
+	}
+	return e.baseline.GetMetrics().AnomalyScore
+}
+
+// autoEscalationChanged fans out a transition made by Evaluate or a pin.
+func (e *Engine) autoEscalationChanged(from, to escalation.Level) {
+	reason := "automatic escalation"
+	if h := e.escalation.GetHistory(); len(h) > 0 {
+		reason = h[len(h)-1].Reason
+	}
+	e.apiServer.BroadcastEscalation(from, to)
+	e.escalationChanged(from, to, reason)
+}
+
+// manualEscalationChanged adopts a level applied by the API or a cluster
+// peer and keeps the CRITICAL BGP announcements in step with it.
+func (e *Engine) manualEscalationChanged(from, to escalation.Level, reason string) {
+	e.escalation.SyncLevel(to, reason)
+	if to == escalation.Critical && e.escalation.BGPEnabled() {
+		go e.announceCritical()
+	} else {
+		go e.withdrawCritical(to)
+	}
+	e.escalationChanged(from, to, reason)
+}
+
+// setAutoFlowspec lets matching attack signatures be announced as
+// Flowspec rules at HIGH and above, and withdraws them below. In a fleet
+// only the leader announces.
+func (e *Engine) setAutoFlowspec(level escalation.Level) {
+	if e.autoFlowspec != nil {
+		e.autoFlowspec.SetActive(level >= escalation.High && e.leads())
+	}
+}
+
+// criticalRules tracks the Flowspec rules and asset blackholes announced
+// while CRITICAL.
+type criticalRules struct {
+	mu         sync.Mutex
+	rules      []bgp.FlowspecRule
+	blackholes []string
+}
+
+// announceCritical asks upstream routers to drop the worst blocked sources
+// towards the protected prefix via Flowspec, and blackholes the protected
+// assets that allow automatic RTBH. In a fleet only the leader announces.
+func (e *Engine) announceCritical() {
+	if e.bgp == nil || !e.leads() {
+		return
+	}
+
+	e.critical.mu.Lock()
+	defer e.critical.mu.Unlock()
+	if len(e.critical.rules) > 0 || len(e.critical.blackholes) > 0 {
+		return
+	}
+
+	if e.assets != nil {
+		prefixes := e.assets.AutoRTBH()
+		if len(prefixes) > 0 && e.approvals.Gate(approval.ActionRTBH,
+			"blackhole protected assets "+strings.Join(prefixes, ", "), e.announceApprovedBlackholes) {
+			e.announceBlackholesLocked(prefixes)
+		}
+	}
+
+	blocked := e.reputation.GetBlocked()
+	sort.Slice(blocked, func(i, j int) bool { return blocked[i].Score > blocked[j].Score })
+	if len(blocked) > criticalFlowspecMax {
+		blocked = blocked[:criticalFlowspecMax]
+	}
+	for _, rep := range blocked {
+		if net.ParseIP(rep.IP).To4() == nil {
+			continue
+		}
+		rule := bgp.FlowspecRule{
+			SrcPrefix: rep.IP + "/32",
+			DstPrefix: e.cfg.BGP.FlowspecDstPrefix,
+			Action:    "drop",
+			Reason:    "escalation CRITICAL",
+		}
+		if err := e.bgp.AnnounceFlowspec(rule); err != nil {
+			e.log.Warn("failed to announce critical flowspec rule",
+				zap.String("src", rule.SrcPrefix), zap.Error(err))
+			continue
+		}
+		e.critical.rules = append(e.critical.rules, rule)
+	}
+	if len(e.critical.rules) > 0 {
+		e.attacks.Mitigation("flowspec", fmt.Sprintf("%d source drop rules", len(e.critical.rules)))
+	}
+	e.log.Warn("escalation CRITICAL: upstream flowspec announced",
+		zap.Int("rules", len(e.critical.rules)),
+		zap.Int("blackholes", len(e.critical.blackholes)))
+}
+
+// announceApprovedBlackholes blackholes the AutoRTBH assets once their
+// approval comes through, if escalation is still CRITICAL.
+func (e *Engine) announceApprovedBlackholes() {
+	if e.bgp == nil || !e.leads() || e.escalation.GetLevel() < escalation.Critical {
+		return
+	}
+
+	e.critical.mu.Lock()
+	defer e.critical.mu.Unlock()
+	if len(e.critical.blackholes) == 0 {
+		e.announceBlackholesLocked(e.assets.AutoRTBH())
+	}
+	e.log.Warn("escalation CRITICAL: approved asset blackholes announced",
+		zap.Int("blackholes", len(e.critical.blackholes)))
+}
+
+func (e *Engine) announceBlackholesLocked(prefixes []string) {
+	for _, prefix := range prefixes {
+		if err := e.bgp.AnnounceBlackhole(prefix, 0); err != nil {
+			e.log.Warn("failed to blackhole protected asset",
+				zap.String("prefix", prefix), zap.Error(err))
+			continue
+		}
+		e.critical.blackholes = append(e.critical.blackholes, prefix)
+		e.attacks.Mitigation("rtbh", prefix)
+	}
+}
+
+// withdrawCritical withdraws the announcements made by announceCritical once
+// escalation drops below CRITICAL.
+func (e *Engine) withdrawCritical(level escalation.Level) {
+	if e.bgp == nil || level >= escalation.Critical {
+		return
+	}
+	e.approvals.Clear(approval.ActionRTBH)
+
+	e.critical.mu.Lock()
+	defer e.critical.mu.Unlock()
+	for _, rule := range e.critical.rules {
+		if err := e.bgp.WithdrawFlowspec(rule); err != nil {
+			e.log.Warn("failed to withdraw critical flowspec rule",
+				zap.String("src", rule.SrcPrefix), zap.Error(err))
+		}
+	}
+	e.critical.rules = nil
+	for _, prefix := range e.critical.blackholes {
+		if err := e.bgp.WithdrawBlackhole(prefix); err != nil {
+			e.log.Warn("failed to withdraw protected asset blackhole",
+				zap.String("prefix", prefix), zap.Error(err))
+		}
+	}
+	e.critical.blackholes = nil
+}
@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/assets"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/baseline"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bgp"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/stats"
+	"go.uber.org/zap"
+)
+
+func TestDropRatio(t *testing.T) {
+	tests := []struct {
+		rx, drop, want float64
+	}{
+		{0, 0, 0},
+		{0, 100, 0},
+		{1000, 250, 0.25},
+		{1000, 1500, 1},
+	}
+	for _, tt := range tests {
+		if got := dropRatio(tt.rx, tt.drop); got != tt.want {
+			t.Errorf("dropRatio(%v, %v) = %v, want %v", tt.rx, tt.drop, got, tt.want)
+		}
+	}
+}
+
+func TestRateSampleMean(t *testing.T) {
+	var s rateSample
+	s.add(&stats.Snapshot{RxPPS: 1000, DropPPS: 100, ConntrackNewPPS: 50})
+	s.add(&stats.Snapshot{RxPPS: 3000, DropPPS: 500, ConntrackNewPPS: 150})
+
+	m := s.mean()
+	if m.RxPPS != 2000 || m.DropPPS != 300 || m.ConntrackNewPPS != 100 {
+		t.Errorf("mean = %+v, want 2000 rx, 300 drop, 100 new conns pps", m)
+	}
+	if s.n != 0 {
+		t.Error("sample not reset")
+	}
+}
+
+func TestPulseEscalation(t *testing.T) {
+	var p pulseEscalation
+	burst := baseline.Pulse{InBurst: true}
+	after := baseline.Pulse{Bursts: make([]baseline.Burst, 1)}
+
+	// Unrecognised bursts only record the level they reach.
+	if got := p.indicator(burst); got != escalation.Low {
+		t.Errorf("first burst: indicator = %s", got)
+	}
+	p.observe(burst, escalation.Medium)
+	p.observe(after, escalation.High)
+	if got := p.indicator(after); got != escalation.Low {
+		t.Errorf("between bursts: indicator = %s", got)
+	}
+
+	recognized := baseline.Pulse{Recognized: true, InBurst: true, Bursts: make([]baseline.Burst, 3)}
+	if got := p.indicator(recognized); got != escalation.High {
+		t.Errorf("resumed burst: indicator = %s, want HIGH", got)
+	}
+
+	// Forgetting the pattern forgets its level.
+	if got := p.indicator(baseline.Pulse{}); got != escalation.Low || p.peak != escalation.Low {
+		t.Errorf("expired: indicator = %s, peak = %s", got, p.peak)
+	}
+}
+
+func TestPrefixRates(t *testing.T) {
+	var p prefixRates
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stat := func(prefix string, rx, drop uint64) assets.Stats {
+		return assets.Stats{Asset: assets.Asset{Prefix: prefix}, RxPackets: rx, DroppedPackets: drop}
+	}
+
+	first := p.sample([]assets.Stats{stat("192.0.2.0/24", 1000, 100)}, at)
+	if len(first) != 1 || first[0].RxPPS != 0 {
+		t.Fatalf("first sample = %+v", first)
+	}
+	got := p.sample([]assets.Stats{
+		stat("192.0.2.0/24", 11000, 5100),
+		stat("198.51.100.0/24", 500, 0),
+	}, at.Add(5*time.Second))
+	if got[0].RxPPS != 2000 || got[0].DropPPS != 1000 || got[1].RxPPS != 0 {
+		t.Errorf("rates = %+v", got)
+	}
+
+	rx, drop := withoutPrefixes(10000, 1200, got, []escalation.PrefixLevel{{Prefix: "192.0.2.0/24", Level: escalation.High}})
+	if rx != 8000 || drop != 200 {
+		t.Errorf("global without escalated prefix = %v, %v, want 8000, 200", rx, drop)
+	}
+}
+
+// fakeSignatures serves attack signatures with settable hit counters.
+type fakeSignatures struct {
+	sigs []bpf.AttackSig
+	hits []uint64
+}
+
+func (f *fakeSignatures) GetAttackSignatureCount() (uint32, error) { return uint32(len(f.sigs)), nil }
+
+func (f *fakeSignatures) AttackSignatureHits(i uint32) (uint64, error) { return f.hits[i], nil }
+
+func (f *fakeSignatures) GetAttackSignature(i uint32) (bpf.AttackSig, error) { return f.sigs[i], nil }
+
+func TestAutoFlowspecFollowsEscalation(t *testing.T) {
+	client := bgp.NewClient(zap.NewNop(), bgp.Config{
+		Enabled:            true,
+		RouterIP:           "192.0.2.1",
+		LocalAS:            64512,
+		PeerAS:             64513,
+		AuthorizedPrefixes: []string{"203.0.113.0/24"},
+		FlowspecDstPrefix:  "203.0.113.0/24",
+		AutoFlowspec:       true,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	// UDP from source port 53 (network byte order).
+	sigs := &fakeSignatures{
+		sigs: []bpf.AttackSig{{Protocol: 17, SrcPortMin: 53 << 8, SrcPortMax: 53 << 8}},
+		hits: []uint64{0},
+	}
+	e := &Engine{log: zap.NewNop(), bgp: client, autoFlowspec: bgp.NewAutoFlowspec(zap.NewNop(), client, sigs)}
+
+	now := time.Now()
+	e.autoFlowspec.Poll(now)
+	sigs.hits[0] = 100
+	now = now.Add(10 * time.Second)
+	e.autoFlowspec.Poll(now)
+	if rules := client.GetActiveRules(); len(rules) != 0 {
+		t.Fatalf("announced below HIGH: %+v", rules)
+	}
+
+	e.escalationChanged(escalation.Medium, escalation.High, "test")
+	sigs.hits[0] = 200
+	e.autoFlowspec.Poll(now.Add(10 * time.Second))
+	rules := client.GetActiveRules()
+	if len(rules) != 1 || rules[0].Protocol != "udp" || rules[0].SrcPort != "53" || rules[0].DstPrefix != "203.0.113.0/24" {
+		t.Fatalf("rules at HIGH = %+v", rules)
+	}
+
+	e.escalationChanged(escalation.High, escalation.Medium, "test")
+	if rules := client.GetActiveRules(); len(rules) != 0 {
+		t.Errorf("rules after de-escalation = %+v", rules)
+	}
+	if rules := e.autoFlowspec.Rules(); len(rules) != 0 {
+		t.Errorf("auto flowspec still tracks %+v", rules)
+	}
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/fleet"
+	"go.uber.org/zap"
+)
+
+// registerFleetSources shares the leader's threat intel entries with the
+// followers, which don't pull the feeds themselves, and every member's
+// reputation blocks with all others.
+func (e *Engine) registerFleetSources() {
+	if e.threatIntel != nil {
+		e.fleet.Share(fleet.KindThreatIntel, threatIntelSource{e.maps})
+	}
+	e.fleet.ShareReputation(e.reputation)
+	e.fleet.OnLeaderChange(e.handleLeaderChange)
+}
+
+// leads reports whether this scrubber takes fleet-wide decisions: BGP
+// RTBH and flowspec announcements. A scrubber outside a fleet always does.
+// Diversion is per site, as each attracts its own anycast catchment, and
+// is not gated.
+func (e *Engine) leads() bool {
+	return e.fleet == nil || e.fleet.IsLeader()
+}
+
+// handleLeaderChange hands the fleet-wide decisions over. A new leader
+// pulls the threat intel feeds and, if the attack is already CRITICAL,
+// announces upstream; a former leader withdraws its RTBH and flowspec
+// announcements, so that the fleet announces each route once.
+func (e *Engine) handleLeaderChange(leader bool) {
+	if e.threatIntel != nil {
+		e.threatIntel.SetPaused(!leader)
+	}
+	if e.bgp == nil {
+		return
+	}
+	e.setAutoFlowspec(e.escalation.GetLevel())
+	if leader {
+		if e.escalation.GetLevel() >= escalation.Critical {
+			e.announceCritical()
+		}
+		return
+	}
+
+	e.withdrawCritical(escalation.Low)
+	for _, prefix := range e.bgp.GetBlackholes() {
+		if err := e.bgp.WithdrawBlackhole(prefix); err != nil {
+			e.log.Warn("failed to withdraw blackhole after losing fleet leadership",
+				zap.String("prefix", prefix), zap.Error(err))
+		}
+	}
+	for _, rule := range e.bgp.GetActiveRules() {
+		if rule.Action == "blackhole" {
+			continue
+		}
+		if err := e.bgp.WithdrawFlowspec(rule); err != nil {
+			e.log.Warn("failed to withdraw flowspec rule after losing fleet leadership",
+				zap.String("src", rule.SrcPrefix), zap.Error(err))
+		}
+	}
+}
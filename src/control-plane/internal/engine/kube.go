@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/kube"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/maintenance"
+	"go.uber.org/zap"
+)
+
+// SetOperator runs the engine under the Kubernetes operator: new versions
+// of the configuration are parsed with load and applied, and the node's
+// state is reported to the policy. Call before Start.
+func (e *Engine) SetOperator(op *kube.Operator, load func(data []byte) (*config.Config, error)) {
+	e.operator = op
+	e.loadPolicy = load
+}
+
+// applyPolicy applies the config map settings of a new configuration
+// version; the others are left for a restart.
+func (e *Engine) applyPolicy(src kube.Source) (bool, error) {
+	cfg, err := e.loadPolicy(src.Data)
+	if err != nil {
+		return false, err
+	}
+	// Scrubbing is off on purpose; retried once maintenance ends.
+	if e.maintenance != nil && e.maintenance.Active() {
+		return false, fmt.Errorf("in maintenance, applied when it ends")
+	}
+
+	rev, restart, err := e.revisions.Apply(cfg, src.String(), "kubernetes")
+	if err != nil {
+		return false, err
+	}
+	if rev != nil {
+		e.log.Info("kubernetes configuration applied", zap.Int("revision", rev.Number), zap.Stringer("source", src))
+	}
+	return restart, nil
+}
+
+// nodeStatus is the engine's part of the status reported to the policy.
+func (e *Engine) nodeStatus() kube.NodeStatus {
+	st := kube.NodeStatus{
+		Interface:       e.cfg.Interface,
+		XDPMode:         e.cfg.XDPMode,
+		EscalationLevel: e.escalation.GetLevel().String(),
+		Healthy:         true,
+	}
+	if v, err := e.maps.GetConfig(bpf.CfgEnabled); err == nil {
+		st.Enabled = v != 0
+	}
+	if e.maintenance != nil {
+		if state := e.maintenance.Status().State; state != maintenance.StateOff {
+			st.Maintenance = state
+		}
+	}
+	if e.watchdog != nil {
+		st.Healthy = e.watchdog.Status().Healthy
+	}
+	if snap := e.statsCollector.Current(); snap != nil {
+		st.PPS = uint64(snap.RxPPS)
+		st.DroppedPPS = uint64(snap.DropPPS)
+	}
+	if revs := e.revisions.List(); len(revs) > 0 {
+		st.Revision = revs[len(revs)-1].Number
+	}
+	return st
+}
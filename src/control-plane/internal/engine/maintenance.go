@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+// maintenanceDataplane lets the maintenance manager switch the scrubber
+// to pass-all and detach and reattach the XDP program.
+type maintenanceDataplane struct {
+	e *Engine
+}
+
+func (d maintenanceDataplane) GetConfig(key uint32) (uint64, error) {
+	return d.e.maps.GetConfig(key)
+}
+
+func (d maintenanceDataplane) SetConfig(key uint32, value uint64) error {
+	return d.e.maps.SetConfig(key, value)
+}
+
+func (d maintenanceDataplane) Detach() error {
+	return d.e.loader.Detach()
+}
+
+func (d maintenanceDataplane) Reattach() error {
+	return d.e.attachXDP()
+}
+
+// watchdogDataplane lets the watchdog check and repair the attachment and
+// the config map.
+type watchdogDataplane struct {
+	e *Engine
+}
+
+func (d watchdogDataplane) AttachState() (bpf.AttachState, error) {
+	return d.e.loader.AttachState()
+}
+
+// Reattach drops our stale link, if any, before attaching again.
+func (d watchdogDataplane) Reattach() error {
+	if err := d.e.loader.Detach(); err != nil {
+		d.e.log.Warn("closing stale XDP link", zap.Error(err))
+	}
+	return d.e.attachXDP()
+}
+
+func (d watchdogDataplane) ConfigDrift() ([]bpf.ConfigDrift, error) {
+	return d.e.maps.ConfigDrift()
+}
+
+func (d watchdogDataplane) RestoreConfig(drift []bpf.ConfigDrift) error {
+	return d.e.maps.RestoreConfig(drift)
+}
+
+func (d watchdogDataplane) Paused() bool {
+	return d.e.maintenance != nil && d.e.maintenance.Active()
+}
@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"go.uber.org/zap"
+)
+
+// portScanActions maps the configured response to CFG_PORT_SCAN_ACTION.
+var portScanActions = map[string]uint64{
+	config.PortScanOff:   bpf.PortScanOff,
+	config.PortScanScore: bpf.PortScanScore,
+	config.PortScanBlock: bpf.PortScanBlock,
+	config.PortScanEvent: bpf.PortScanEvent,
+}
+
+// applyPortScan writes the port scan detection parameters to the config map.
+func (e *Engine) applyPortScan() error {
+	ps := e.cfg.PortScan
+	for key, val := range map[uint32]uint64{
+		bpf.CfgPortScanAction: portScanActions[ps.Action],
+		bpf.CfgPortScanThresh: uint64(ps.Threshold),
+		bpf.CfgPortScanWindow: ps.WindowSec,
+	} {
+		if err := e.maps.SetConfig(key, val); err != nil {
+			return fmt.Errorf("configuring port scan detection: %w", err)
+		}
+	}
+	return nil
+}
+
+// handlePortScan applies the configured response to a source the data
+// plane flagged as a port scanner. The data plane reports each scanner once
+// per detection window.
+func (e *Engine) handlePortScan(ev *bpf.Event) {
+	if ev.AttackType != bpf.AttackPortScan {
+		return
+	}
+	ps := e.cfg.PortScan
+	ip := bpf.U32BEToIP(ev.SrcIP).String()
+
+	switch ps.Action {
+	case config.PortScanScore:
+		e.reputation.Penalize(ev.SrcIP, ps.ScoreWeight, bpf.AttackTypeName(ev.AttackType))
+	case config.PortScanBlock:
+		ttl := time.Duration(ps.BlockSec) * time.Second
+		if err := e.maps.AddBlacklistCIDRWithTTL(ip+"/32", bpf.DropBlacklist, ttl); err != nil {
+			e.log.Warn("failed to block port scanner", zap.String("ip", ip), zap.Error(err))
+			return
+		}
+		e.log.Info("port scanner blocked", zap.String("ip", ip), zap.Duration("ttl", ttl))
+	default:
+		e.log.Debug("port scan detected", zap.String("ip", ip))
+	}
+}
@@ -5,18 +5,21 @@ package escalation
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/cilium/ebpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/approval"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
 	"go.uber.org/zap"
 )
 
 // Config map key for escalation level, matching types.h CFG_ESCALATION_LEVEL.
 const cfgEscalationLevel uint32 = 16
 
-// Evaluation interval for the escalation loop.
-const evalInterval = 5 * time.Second
+// EvalInterval is how often the control loop should call Evaluate.
+const EvalInterval = 5 * time.Second
 
 // Maximum history entries to retain.
 const maxHistory = 1000
@@ -47,8 +50,8 @@ func (l Level) String() string {
 	}
 }
 
-// Trigger represents a single trigger condition for escalation decisions.
-type Trigger struct {
+// TriggerState is the state of one trigger at the latest evaluation.
+type TriggerState struct {
 	Name      string
 	Current   float64
 	Threshold float64
@@ -61,19 +64,7 @@ type EscalationEvent struct {
 	FromLevel Level
 	ToLevel   Level
 	Reason    string
-	Triggers  []Trigger
-}
-
-// Escalation thresholds for upgrading levels.
-var escalateThresholds = map[Level]struct {
-	dropRatio          float64
-	zScore             float64
-	reputationBlocked  int
-	dropPps            float64
-}{
-	Medium:   {dropRatio: 0.10, zScore: 2.0, reputationBlocked: 0, dropPps: 0},
-	High:     {dropRatio: 0.30, zScore: 3.0, reputationBlocked: 100, dropPps: 0},
-	Critical: {dropRatio: 0.50, zScore: 5.0, reputationBlocked: 0, dropPps: 500000},
+	Triggers  []TriggerState
 }
 
 // De-escalation thresholds: must be below these for 3 consecutive evaluations.
@@ -93,27 +84,156 @@ const hysteresisCount = 3
 // Engine manages escalation levels based on threat indicators.
 type Engine struct {
 	log       *zap.Logger
-	configMap *ebpf.Map
+	configMap bpf.ConfigTable
 
 	mu               sync.RWMutex
 	level            Level
 	history          []EscalationEvent
-	triggers         []Trigger
+	triggers         []TriggerState
 	deescalateStreak int // Consecutive evaluations meeting de-escalation criteria.
 
+	profiles map[Level]Profile
+	saved    map[uint32]uint64 // config values in place before a profile overrode them
+
+	sensitivity   float64                       // Trigger thresholds are divided by this.
+	triggerCfg    map[string]map[string]float64 // Configured trigger thresholds by level name.
+	levelTriggers map[Level][]Trigger           // Triggers that escalate to each level.
+
+	levelSince time.Time               // When the current level was entered.
+	minDwell   map[Level]time.Duration // Time at a level before de-escalating from it.
+	pin        *Pin                    // Floor auto de-escalation may not go below.
+
+	// Per-prefix escalation, enabled by SetPrefixMap.
+	prefixMap PrefixMap
+	prefixes  map[string]*prefixState
+
+	// Attack indicators raised by detectors outside the traffic rates,
+	// with the level each holds escalation at while raised.
+	indicators map[string]Level
+
+	// gate holds profile actions that need a human decision first.
+	gate Gate
+
 	// Callbacks for external actions.
-	onCritical   func()
-	onDeescalate func(Level)
+	onCritical    func()
+	onDeescalate  func(Level)
+	onLevelChange func(from, to Level)
+
+	now func() time.Time
+}
+
+// Gate decides whether a profile action may be taken, implemented by
+// approval.Manager. An action held back is staged, and execute is called
+// once it is approved.
+type Gate interface {
+	Gate(action, summary string, execute func()) bool
+	Clear(action string)
+}
+
+// Pin holds escalation at or above Level during a known incident: the
+// engine still escalates past it but does not de-escalate below it.
+type Pin struct {
+	Level  Level
+	Reason string
+	Since  time.Time
+	Until  time.Time // Zero until unpinned.
 }
 
 // NewEngine creates a new escalation engine.
-func NewEngine(log *zap.Logger, configMap *ebpf.Map) *Engine {
+func NewEngine(log *zap.Logger, configMap bpf.ConfigTable) *Engine {
 	return &Engine{
-		log:       log,
-		configMap: configMap,
-		level:     Low,
-		history:   make([]EscalationEvent, 0, 64),
+		log:           log,
+		configMap:     configMap,
+		level:         Low,
+		history:       make([]EscalationEvent, 0, 64),
+		saved:         make(map[uint32]uint64),
+		sensitivity:   1,
+		levelTriggers: buildTriggers(nil, 1),
+		indicators:    make(map[string]Level),
+		prefixes:      make(map[string]*prefixState),
+		levelSince:    time.Now(),
+		now:           time.Now,
+	}
+}
+
+// SetIndicator raises the named attack indicator so that the next
+// evaluation escalates to at least level and later ones do not go below
+// it. Level Low clears the indicator.
+func (e *Engine) SetIndicator(name string, level Level) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	prev, raised := e.indicators[name]
+	if level <= Low {
+		if raised {
+			delete(e.indicators, name)
+			e.log.Info("attack indicator cleared", zap.String("indicator", name))
+		}
+		return
+	}
+	if !raised || prev != level {
+		e.log.Warn("attack indicator raised",
+			zap.String("indicator", name),
+			zap.String("level", level.String()),
+		)
+	}
+	e.indicators[name] = level
+}
+
+// Indicators returns the raised attack indicators and the level each holds.
+func (e *Engine) Indicators() map[string]Level {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	out := make(map[string]Level, len(e.indicators))
+	for name, level := range e.indicators {
+		out[name] = level
+	}
+	return out
+}
+
+// SetSensitivity scales how readily the engine escalates: the trigger
+// thresholds are divided by s, so 2 escalates at half the usual values.
+// Values <= 0 reset it to 1.
+func (e *Engine) SetSensitivity(s float64) {
+	if s <= 0 {
+		s = 1
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if s != e.sensitivity {
+		e.log.Info("escalation sensitivity changed",
+			zap.Float64("from", e.sensitivity),
+			zap.Float64("to", s),
+		)
+	}
+	e.sensitivity = s
+	e.levelTriggers = buildTriggers(e.triggerCfg, s)
+}
+
+// SetConfig replaces the per-level mitigation profiles and triggers and
+// re-applies the profiles for the current level.
+func (e *Engine) SetConfig(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
 	}
+	profiles, _ := cfg.levels()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.profiles = profiles
+	e.triggerCfg = cfg.Triggers
+	e.levelTriggers = buildTriggers(cfg.Triggers, e.sensitivity)
+	e.minDwell = cfg.dwellTimes()
+	e.applyProfilesLocked()
+	return nil
+}
+
+// BGPEnabled reports whether the CRITICAL profile allows BGP signaling.
+func (e *Engine) BGPEnabled() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.profiles[Critical].BGP
 }
 
 // Start begins the escalation evaluation loop (every 5 seconds).
@@ -129,68 +249,46 @@ func (e *Engine) Start(ctx context.Context) error {
 	return nil
 }
 
-// Evaluate checks trigger conditions and adjusts the escalation level.
-// Parameters:
-//   - rxPps: current receive packets per second
-//   - dropPps: current drop packets per second
-//   - dropRatio: dropPps / rxPps (0.0 - 1.0)
-//   - zScore: anomaly Z-score from baseline engine
-//   - reputationBlocked: number of IPs currently auto-blocked by reputation
+// Evaluate checks the triggers of each level above the current one
+// against m and adjusts the escalation level. Raised attack indicators
+// (SetIndicator) are evaluated alongside them. De-escalation needs the
+// drop ratio and z-score below the next level's de-escalation thresholds.
 //
 // Returns the new escalation level after evaluation.
-func (e *Engine) Evaluate(rxPps, dropPps, dropRatio float64, zScore float64, reputationBlocked int) Level {
+func (e *Engine) Evaluate(ctx context.Context, m Metrics) Level {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
 	oldLevel := e.level
+	now := e.now()
+	if e.pin != nil && !e.pin.Until.IsZero() && !now.Before(e.pin.Until) {
+		e.log.Info("escalation pin expired", zap.String("level", e.pin.Level.String()))
+		e.pin = nil
+	}
 
 	// Build current trigger states.
-	e.triggers = []Trigger{
-		{Name: "drop_ratio", Current: dropRatio, Threshold: 0, Active: false},
-		{Name: "z_score", Current: zScore, Threshold: 0, Active: false},
-		{Name: "reputation_blocked", Current: float64(reputationBlocked), Threshold: 0, Active: false},
-		{Name: "drop_pps", Current: dropPps, Threshold: 0, Active: false},
+	e.triggers = e.triggerStatesLocked(m)
+	indicators := e.sortedIndicators()
+	for _, name := range indicators {
+		e.triggers = append(e.triggers, TriggerState{Name: name, Current: 1})
 	}
 
 	// Check for escalation: try to escalate from current level upward.
 	newLevel := e.level
 	for targetLevel := e.level + 1; targetLevel <= Critical; targetLevel++ {
-		thresh, ok := escalateThresholds[targetLevel]
-		if !ok {
-			continue
-		}
-
 		triggered := false
-		reason := ""
-
-		if dropRatio > thresh.dropRatio && thresh.dropRatio > 0 {
-			triggered = true
-			reason = fmt.Sprintf("drop_ratio=%.2f > %.2f", dropRatio, thresh.dropRatio)
-			e.setTriggerActive("drop_ratio", thresh.dropRatio)
-		}
-		if zScore > thresh.zScore && thresh.zScore > 0 {
-			triggered = true
-			if reason != "" {
-				reason += " OR "
-			}
-			reason += fmt.Sprintf("z_score=%.2f > %.2f", zScore, thresh.zScore)
-			e.setTriggerActive("z_score", thresh.zScore)
-		}
-		if thresh.reputationBlocked > 0 && reputationBlocked > thresh.reputationBlocked {
-			triggered = true
-			if reason != "" {
-				reason += " OR "
+		for _, t := range e.levelTriggers[targetLevel] {
+			if t.Evaluate(ctx, m) {
+				triggered = true
+				_, threshold := measure(t, m)
+				e.setTriggerActive(t.Name(), threshold)
 			}
-			reason += fmt.Sprintf("reputation_blocked=%d > %d", reputationBlocked, thresh.reputationBlocked)
-			e.setTriggerActive("reputation_blocked", float64(thresh.reputationBlocked))
 		}
-		if thresh.dropPps > 0 && dropPps > thresh.dropPps {
-			triggered = true
-			if reason != "" {
-				reason += " OR "
+		for _, name := range indicators {
+			if e.indicators[name] >= targetLevel {
+				triggered = true
+				e.setTriggerActive(name, 0)
 			}
-			reason += fmt.Sprintf("drop_pps=%.0f > %.0f", dropPps, thresh.dropPps)
-			e.setTriggerActive("drop_pps", thresh.dropPps)
 		}
 
 		if triggered {
@@ -200,33 +298,7 @@ func (e *Engine) Evaluate(rxPps, dropPps, dropRatio float64, zScore float64, rep
 
 	// If we escalated, apply the change.
 	if newLevel > e.level {
-		e.deescalateStreak = 0
-		e.level = newLevel
-
-		event := EscalationEvent{
-			Timestamp: time.Now(),
-			FromLevel: oldLevel,
-			ToLevel:   newLevel,
-			Reason:    fmt.Sprintf("escalate: %s", e.buildReason()),
-			Triggers:  copyTriggers(e.triggers),
-		}
-		e.appendHistory(event)
-
-		e.log.Warn("escalation level increased",
-			zap.String("from", oldLevel.String()),
-			zap.String("to", newLevel.String()),
-			zap.String("reason", event.Reason),
-		)
-
-		if err := e.pushLevelLocked(); err != nil {
-			e.log.Error("failed to push escalation level to BPF", zap.Error(err))
-		}
-
-		// Fire critical callback.
-		if newLevel == Critical && e.onCritical != nil {
-			go e.onCritical()
-		}
-
+		e.raiseLocked(newLevel, fmt.Sprintf("escalate: %s", e.buildReason()))
 		return e.level
 	}
 
@@ -234,18 +306,22 @@ func (e *Engine) Evaluate(rxPps, dropPps, dropRatio float64, zScore float64, rep
 	if e.level > Low {
 		targetLevel := e.level - 1
 		deThresh, ok := deescalateThresholds[targetLevel]
-		if ok && dropRatio < deThresh.dropRatio && zScore < deThresh.zScore {
+		if ok && m.DropRatio < deThresh.dropRatio && m.ZScore < deThresh.zScore &&
+			e.indicatorLevel() <= targetLevel && (e.pin == nil || e.pin.Level <= targetLevel) {
 			e.deescalateStreak++
 		} else {
 			e.deescalateStreak = 0
 		}
 
-		if e.deescalateStreak >= hysteresisCount {
+		// The streak keeps counting through the minimum dwell so the level
+		// drops as soon as the dwell has passed.
+		if e.deescalateStreak >= hysteresisCount && now.Sub(e.levelSince) >= e.minDwell[e.level] {
 			e.level = targetLevel
+			e.levelSince = now
 			e.deescalateStreak = 0
 
 			event := EscalationEvent{
-				Timestamp: time.Now(),
+				Timestamp: now,
 				FromLevel: oldLevel,
 				ToLevel:   targetLevel,
 				Reason:    fmt.Sprintf("de-escalate: %d consecutive evals below threshold", hysteresisCount),
@@ -262,10 +338,14 @@ func (e *Engine) Evaluate(rxPps, dropPps, dropRatio float64, zScore float64, rep
 			if err := e.pushLevelLocked(); err != nil {
 				e.log.Error("failed to push escalation level to BPF", zap.Error(err))
 			}
+			e.applyProfilesLocked()
 
 			if e.onDeescalate != nil {
 				go e.onDeescalate(targetLevel)
 			}
+			if e.onLevelChange != nil {
+				go e.onLevelChange(oldLevel, targetLevel)
+			}
 		}
 	}
 
@@ -290,11 +370,11 @@ func (e *Engine) GetHistory() []EscalationEvent {
 }
 
 // GetTriggers returns the current trigger states from the most recent evaluation.
-func (e *Engine) GetTriggers() []Trigger {
+func (e *Engine) GetTriggers() []TriggerState {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	result := make([]Trigger, len(e.triggers))
+	result := make([]TriggerState, len(e.triggers))
 	copy(result, e.triggers)
 	return result
 }
@@ -314,6 +394,14 @@ func (e *Engine) OnDeescalate(fn func(Level)) {
 	e.onDeescalate = fn
 }
 
+// OnLevelChange sets a callback that fires on every escalation level
+// transition, including manual overrides.
+func (e *Engine) OnLevelChange(fn func(from, to Level)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onLevelChange = fn
+}
+
 // SetLevel manually overrides the escalation level. Use with caution.
 func (e *Engine) SetLevel(level Level) error {
 	if level < Low || level > Critical {
@@ -323,21 +411,28 @@ func (e *Engine) SetLevel(level Level) error {
 	e.mu.Lock()
 	oldLevel := e.level
 	e.level = level
+	e.levelSince = e.now()
 	e.deescalateStreak = 0
 
 	event := EscalationEvent{
-		Timestamp: time.Now(),
+		Timestamp: e.levelSince,
 		FromLevel: oldLevel,
 		ToLevel:   level,
 		Reason:    "manual override",
 	}
 	e.appendHistory(event)
+	e.applyProfilesLocked()
+	onLevelChange := e.onLevelChange
 	e.mu.Unlock()
 
 	if err := e.pushLevel(); err != nil {
 		return fmt.Errorf("pushing manual level override: %w", err)
 	}
 
+	if onLevelChange != nil && oldLevel != level {
+		go onLevelChange(oldLevel, level)
+	}
+
 	e.log.Info("escalation level manually set",
 		zap.String("from", oldLevel.String()),
 		zap.String("to", level.String()),
@@ -346,8 +441,139 @@ func (e *Engine) SetLevel(level Level) error {
 	return nil
 }
 
+// SyncLevel adopts a level that was already applied to the BPF config map
+// elsewhere, e.g. by the API or a cluster peer, so that later evaluations
+// start from it. Unlike SetLevel it fires no callbacks.
+func (e *Engine) SyncLevel(level Level, reason string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.level == level {
+		return
+	}
+	e.appendHistory(EscalationEvent{
+		Timestamp: e.now(),
+		FromLevel: e.level,
+		ToLevel:   level,
+		Reason:    reason,
+	})
+	e.level = level
+	e.levelSince = e.now()
+	e.deescalateStreak = 0
+	e.applyProfilesLocked()
+}
+
+// PinLevel holds escalation at or above level for d, or until Unpin if d
+// is 0, raising it to level now if it is below. A new pin replaces the
+// previous one.
+func (e *Engine) PinLevel(level Level, d time.Duration, reason string) error {
+	if level <= Low || level > Critical {
+		return fmt.Errorf("invalid pin level %s: must be MEDIUM-CRITICAL", level)
+	}
+	if d < 0 {
+		return fmt.Errorf("invalid pin duration %v", d)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	pin := &Pin{Level: level, Reason: reason, Since: now}
+	if d > 0 {
+		pin.Until = now.Add(d)
+	}
+	e.pin = pin
+	e.log.Warn("escalation level pinned",
+		zap.String("level", level.String()),
+		zap.Duration("duration", d),
+		zap.String("reason", reason),
+	)
+
+	if level > e.level {
+		e.raiseLocked(level, "pinned: "+reason)
+	}
+	return nil
+}
+
+// Unpin removes the pin, letting later evaluations de-escalate again. It
+// reports whether a pin was in place.
+func (e *Engine) Unpin() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.pin == nil {
+		return false
+	}
+	e.log.Info("escalation level unpinned", zap.String("level", e.pin.Level.String()))
+	e.pin = nil
+	return true
+}
+
+// GetPin returns the pin in place, if any.
+func (e *Engine) GetPin() (Pin, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.pin == nil || (!e.pin.Until.IsZero() && !e.now().Before(e.pin.Until)) {
+		return Pin{}, false
+	}
+	return *e.pin, true
+}
+
+// LevelSince returns when the current level was entered.
+func (e *Engine) LevelSince() time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.levelSince
+}
+
+// MinDwell returns how long escalation stays at level before it may
+// de-escalate from it.
+func (e *Engine) MinDwell(level Level) time.Duration {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.minDwell[level]
+}
+
 // --- Internal helpers ---
 
+// raiseLocked escalates to level, recording reason and the current
+// triggers, and fires the callbacks.
+func (e *Engine) raiseLocked(level Level, reason string) {
+	oldLevel := e.level
+	e.deescalateStreak = 0
+	e.level = level
+	e.levelSince = e.now()
+
+	event := EscalationEvent{
+		Timestamp: e.levelSince,
+		FromLevel: oldLevel,
+		ToLevel:   level,
+		Reason:    reason,
+		Triggers:  copyTriggers(e.triggers),
+	}
+	e.appendHistory(event)
+
+	e.log.Warn("escalation level increased",
+		zap.String("from", oldLevel.String()),
+		zap.String("to", level.String()),
+		zap.String("reason", event.Reason),
+	)
+
+	if err := e.pushLevelLocked(); err != nil {
+		e.log.Error("failed to push escalation level to BPF", zap.Error(err))
+	}
+	e.applyProfilesLocked()
+
+	// Fire critical callback.
+	if level == Critical && e.onCritical != nil && e.profiles[Critical].BGP {
+		go e.onCritical()
+	}
+	if e.onLevelChange != nil {
+		go e.onLevelChange(oldLevel, level)
+	}
+}
+
 func (e *Engine) pushLevel() error {
 	e.mu.RLock()
 	level := e.level
@@ -361,6 +587,80 @@ func (e *Engine) pushLevelLocked() error {
 	return e.configMap.Update(cfgEscalationLevel, uint64(e.level), ebpf.UpdateAny)
 }
 
+// SetGate makes the GeoIP enforcement of the profiles wait for approval
+// through g.
+func (e *Engine) SetGate(g Gate) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.gate = g
+}
+
+// ApplyProfiles re-applies the profiles of the current level, e.g. once a
+// gated action has been approved.
+func (e *Engine) ApplyProfiles() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.applyProfilesLocked()
+}
+
+// applyProfilesLocked writes the config values the profiles up to the
+// current level require and restores those no longer required to the value
+// they had before the first override.
+func (e *Engine) applyProfilesLocked() {
+	want := profileValues(e.profiles, e.level, func(key uint32) uint64 {
+		if v, ok := e.saved[key]; ok {
+			return v
+		}
+		var v uint64
+		if err := e.configMap.Lookup(key, &v); err != nil {
+			e.log.Warn("failed to read config for escalation profile", zap.Uint32("key", key), zap.Error(err))
+		}
+		return v
+	})
+	if e.gate != nil {
+		if _, ok := want[cfgGeoIPEnable]; !ok {
+			e.gate.Clear(approval.ActionGeoIP)
+		} else if !e.gate.Gate(approval.ActionGeoIP, "enforce GeoIP country policies at "+e.level.String(), e.ApplyProfiles) {
+			delete(want, cfgGeoIPEnable)
+		}
+	}
+
+	reverted := 0
+	for _, key := range sortedKeys(e.saved) {
+		if _, ok := want[key]; ok {
+			continue
+		}
+		if err := e.configMap.Update(key, e.saved[key], ebpf.UpdateAny); err != nil {
+			e.log.Error("failed to revert escalation profile", zap.Uint32("key", key), zap.Error(err))
+			continue
+		}
+		delete(e.saved, key)
+		reverted++
+	}
+
+	for _, key := range sortedKeys(want) {
+		if _, ok := e.saved[key]; !ok {
+			var cur uint64
+			if err := e.configMap.Lookup(key, &cur); err != nil {
+				e.log.Error("failed to read config for escalation profile", zap.Uint32("key", key), zap.Error(err))
+				continue
+			}
+			e.saved[key] = cur
+		}
+		if err := e.configMap.Update(key, want[key], ebpf.UpdateAny); err != nil {
+			e.log.Error("failed to apply escalation profile", zap.Uint32("key", key), zap.Error(err))
+		}
+	}
+
+	if len(want) > 0 || reverted > 0 {
+		e.log.Info("escalation profile applied",
+			zap.String("level", e.level.String()),
+			zap.Int("overrides", len(want)),
+			zap.Int("reverted", reverted),
+		)
+	}
+}
+
 func (e *Engine) appendHistory(event EscalationEvent) {
 	e.history = append(e.history, event)
 	// Trim history if it exceeds the maximum.
@@ -369,6 +669,54 @@ func (e *Engine) appendHistory(event EscalationEvent) {
 	}
 }
 
+// sortedIndicators returns the names of the raised indicators in order.
+func (e *Engine) sortedIndicators() []string {
+	names := make([]string, 0, len(e.indicators))
+	for name := range e.indicators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// triggerStatesLocked returns the state of every trigger configured at a
+// level above LOW, by name, none of them active yet.
+func (e *Engine) triggerStatesLocked(m Metrics) []TriggerState {
+	seen := make(map[string]bool)
+	var out []TriggerState
+	for l := Medium; l <= Critical; l++ {
+		for _, t := range e.levelTriggers[l] {
+			if seen[t.Name()] {
+				continue
+			}
+			seen[t.Name()] = true
+			current, _ := measure(t, m)
+			out = append(out, TriggerState{Name: t.Name(), Current: current})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// measure returns the value and threshold of a Measurer trigger, or zeros.
+func measure(t Trigger, m Metrics) (current, threshold float64) {
+	if mt, ok := t.(Measurer); ok {
+		return mt.Measure(m)
+	}
+	return 0, 0
+}
+
+// indicatorLevel returns the highest level a raised indicator holds.
+func (e *Engine) indicatorLevel() Level {
+	level := Low
+	for _, l := range e.indicators {
+		if l > level {
+			level = l
+		}
+	}
+	return level
+}
+
 func (e *Engine) setTriggerActive(name string, threshold float64) {
 	for i := range e.triggers {
 		if e.triggers[i].Name == name {
@@ -391,8 +739,8 @@ func (e *Engine) buildReason() string {
 	return reasons
 }
 
-func copyTriggers(triggers []Trigger) []Trigger {
-	result := make([]Trigger, len(triggers))
+func copyTriggers(triggers []TriggerState) []TriggerState {
+	result := make([]TriggerState, len(triggers))
 	copy(result, triggers)
 	return result
 }
@@ -0,0 +1,184 @@
+package escalation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"go.uber.org/zap"
+)
+
+// fakeConfigTable stores config map writes.
+type fakeConfigTable map[uint32]uint64
+
+func (f fakeConfigTable) Lookup(key, valueOut interface{}) error {
+	*valueOut.(*uint64) = f[key.(uint32)]
+	return nil
+}
+
+func (f fakeConfigTable) Update(key, value interface{}, _ ebpf.MapUpdateFlags) error {
+	f[key.(uint32)] = value.(uint64)
+	return nil
+}
+
+var (
+	ctx    = context.Background()
+	idle   = Metrics{RxPPS: 1000}
+	attack = Metrics{RxPPS: 1000, DropPPS: 400, DropRatio: 0.4} // escalates to HIGH
+)
+
+func TestIndicatorHoldsLevel(t *testing.T) {
+	cfg := fakeConfigTable{}
+	e := NewEngine(zap.NewNop(), cfg)
+
+	e.SetIndicator("http_flood", Medium)
+	if got := e.Evaluate(ctx, idle); got != Medium {
+		t.Fatalf("level with indicator raised = %s, want MEDIUM", got)
+	}
+	if cfg[cfgEscalationLevel] != uint64(Medium) {
+		t.Errorf("config level = %d, want %d", cfg[cfgEscalationLevel], Medium)
+	}
+
+	for i := 0; i < hysteresisCount+1; i++ {
+		e.Evaluate(ctx, idle)
+	}
+	if got := e.GetLevel(); got != Medium {
+		t.Errorf("level while raised = %s, want MEDIUM", got)
+	}
+
+	e.SetIndicator("http_flood", Low)
+	if len(e.Indicators()) != 0 {
+		t.Errorf("indicators after clearing = %v", e.Indicators())
+	}
+	for i := 0; i < hysteresisCount; i++ {
+		e.Evaluate(ctx, idle)
+	}
+	if got := e.GetLevel(); got != Low {
+		t.Errorf("level after clearing = %s, want LOW", got)
+	}
+}
+
+// quiet evaluates no attack n times, advancing clock by EvalInterval each.
+func quiet(e *Engine, clock *time.Time, n int) Level {
+	for i := 0; i < n; i++ {
+		*clock = clock.Add(EvalInterval)
+		e.Evaluate(ctx, idle)
+	}
+	return e.GetLevel()
+}
+
+func TestMinDwell(t *testing.T) {
+	e := NewEngine(zap.NewNop(), fakeConfigTable{})
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return clock }
+	if err := e.SetConfig(Config{MinDwellSec: map[string]uint64{"high": 60}}); err != nil {
+		t.Fatal(err)
+	}
+
+	e.Evaluate(ctx, attack) // LOW -> HIGH
+	if got := quiet(e, &clock, hysteresisCount+5); got != High {
+		t.Fatalf("level within dwell = %s, want HIGH", got)
+	}
+	// 60s have passed at the next evaluation; MEDIUM has no dwell.
+	if got := quiet(e, &clock, 4); got != Medium {
+		t.Fatalf("level after dwell = %s, want MEDIUM", got)
+	}
+	if got := quiet(e, &clock, hysteresisCount); got != Low {
+		t.Errorf("level = %s, want LOW", got)
+	}
+}
+
+func TestPinLevel(t *testing.T) {
+	cfg := fakeConfigTable{}
+	e := NewEngine(zap.NewNop(), cfg)
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return clock }
+
+	if err := e.PinLevel(Low, 0, "x"); err == nil {
+		t.Error("pinning LOW: expected error")
+	}
+	if err := e.PinLevel(Medium, 10*time.Minute, "known incident"); err != nil {
+		t.Fatal(err)
+	}
+	if e.GetLevel() != Medium || cfg[cfgEscalationLevel] != uint64(Medium) {
+		t.Fatalf("level after pin = %s", e.GetLevel())
+	}
+	if h := e.GetHistory(); len(h) != 1 || h[0].Reason != "pinned: known incident" {
+		t.Errorf("history = %+v", h)
+	}
+
+	// Escalation above the pin still happens, but not below it.
+	e.Evaluate(ctx, attack)
+	if got := quiet(e, &clock, 4*hysteresisCount); got != Medium {
+		t.Fatalf("level while pinned = %s, want MEDIUM", got)
+	}
+
+	clock = clock.Add(10 * time.Minute)
+	if _, ok := e.GetPin(); ok {
+		t.Error("pin not expired")
+	}
+	if got := quiet(e, &clock, hysteresisCount); got != Low {
+		t.Errorf("level after pin expired = %s, want LOW", got)
+	}
+
+	if err := e.PinLevel(High, 0, "indefinite"); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := e.GetPin(); !ok || p.Level != High || !p.Until.IsZero() {
+		t.Errorf("pin = %+v, %v", p, ok)
+	}
+	if !e.Unpin() || e.Unpin() {
+		t.Error("Unpin should report the pin once")
+	}
+}
+
+// fakeGate holds every action until approve is called.
+type fakeGate struct {
+	approved bool
+	execute  func()
+	cleared  int
+}
+
+func (g *fakeGate) Gate(_, _ string, execute func()) bool {
+	g.execute = execute
+	return g.approved
+}
+
+func (g *fakeGate) Clear(string) {
+	g.approved = false
+	g.cleared++
+}
+
+func TestGeoIPGated(t *testing.T) {
+	cfg := fakeConfigTable{}
+	e := NewEngine(zap.NewNop(), cfg)
+	if err := e.SetConfig(DefaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+	g := &fakeGate{}
+	e.SetGate(g)
+
+	if err := e.SetLevel(Critical); err != nil {
+		t.Fatal(err)
+	}
+	if cfg[cfgGeoIPEnable] != 0 {
+		t.Fatal("GeoIP enforced before approval")
+	}
+	if cfg[cfgSYNCookieEnable] != 1 {
+		t.Error("ungated profile actions held back")
+	}
+
+	g.approved = true
+	g.execute()
+	if cfg[cfgGeoIPEnable] != 1 {
+		t.Fatal("GeoIP not enforced after approval")
+	}
+
+	if err := e.SetLevel(High); err != nil {
+		t.Fatal(err)
+	}
+	if cfg[cfgGeoIPEnable] != 0 || g.cleared == 0 {
+		t.Errorf("GeoIP after de-escalation = %d, cleared %d times", cfg[cfgGeoIPEnable], g.cleared)
+	}
+}
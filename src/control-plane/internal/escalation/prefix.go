@@ -0,0 +1,187 @@
+package escalation
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// prefixMinPPS is the traffic a protected prefix needs before its drop
+// ratio is trusted: a handful of ACL drops towards a quiet prefix is not
+// an attack on it.
+const prefixMinPPS = 1000
+
+// PrefixSample is the traffic towards one protected prefix since the
+// previous evaluation.
+type PrefixSample struct {
+	Prefix  string
+	RxPPS   float64
+	DropPPS float64
+}
+
+// PrefixLevel is the escalation level a protected prefix reached on its
+// own traffic.
+type PrefixLevel struct {
+	Prefix    string
+	Level     Level
+	Since     time.Time
+	DropRatio float64
+	DropPPS   float64
+}
+
+// PrefixMap holds the per-prefix levels in the data plane, implemented by
+// bpf.MapManager.
+type PrefixMap interface {
+	SetDstEscalation(cidr string, level uint32) error
+	RemoveDstEscalation(cidr string) error
+}
+
+// prefixState is the escalation state of one protected prefix.
+type prefixState struct {
+	PrefixLevel
+	deescalateStreak int
+}
+
+// SetPrefixMap enables per-prefix escalation: EvaluatePrefixes tracks a
+// level for each protected prefix and writes those above LOW to m.
+func (e *Engine) SetPrefixMap(m PrefixMap) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.prefixMap = m
+}
+
+// EvaluatePrefixes adjusts the level of each protected prefix from its own
+// drop ratio and drop rate, with the triggers, hysteresis and minimum
+// dwell times of the global level. Prefixes missing from
+// samples are dropped back to the global level. It returns the prefixes
+// above LOW.
+func (e *Engine) EvaluatePrefixes(ctx context.Context, samples []PrefixSample) []PrefixLevel {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.prefixMap == nil {
+		return nil
+	}
+	now := e.now()
+	seen := make(map[string]bool, len(samples))
+	for _, s := range samples {
+		seen[s.Prefix] = true
+		st, ok := e.prefixes[s.Prefix]
+		if !ok {
+			st = &prefixState{PrefixLevel: PrefixLevel{Prefix: s.Prefix, Level: Low, Since: now}}
+			e.prefixes[s.Prefix] = st
+		}
+		st.DropPPS = s.DropPPS
+		st.DropRatio = 0
+		if s.RxPPS >= prefixMinPPS {
+			st.DropRatio = s.DropPPS / s.RxPPS
+		}
+		if level := e.prefixLevelLocked(ctx, st, now); level != st.Level {
+			e.setPrefixLevelLocked(st, level, now)
+		}
+	}
+	for prefix, st := range e.prefixes {
+		if !seen[prefix] {
+			e.setPrefixLevelLocked(st, Low, now)
+			delete(e.prefixes, prefix)
+		}
+	}
+	return e.prefixLevelsLocked()
+}
+
+// PrefixLevels returns the protected prefixes above LOW, by prefix.
+func (e *Engine) PrefixLevels() []PrefixLevel {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.prefixLevelsLocked()
+}
+
+// MaxLevel returns the highest of the global level and the prefix levels:
+// the level some traffic through the box is mitigated at.
+func (e *Engine) MaxLevel() Level {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	level := e.level
+	for _, st := range e.prefixes {
+		if st.Level > level {
+			level = st.Level
+		}
+	}
+	return level
+}
+
+// prefixLevelLocked returns the level st should move to this evaluation:
+// as high as a trigger fires on its drop ratio or drop rate, or one level
+// down after hysteresisCount quiet evaluations and the level's minimum
+// dwell.
+func (e *Engine) prefixLevelLocked(ctx context.Context, st *prefixState, now time.Time) Level {
+	m := Metrics{DropPPS: st.DropPPS, DropRatio: st.DropRatio}
+	level := st.Level
+	for target := st.Level + 1; target <= Critical; target++ {
+		for _, t := range e.levelTriggers[target] {
+			if t.Evaluate(ctx, m) {
+				level = target
+			}
+		}
+	}
+	if level > st.Level || st.Level == Low {
+		st.deescalateStreak = 0
+		return level
+	}
+
+	if st.DropRatio < deescalateThresholds[st.Level-1].dropRatio {
+		st.deescalateStreak++
+	} else {
+		st.deescalateStreak = 0
+	}
+	if st.deescalateStreak >= hysteresisCount && now.Sub(st.Since) >= e.minDwell[st.Level] {
+		st.deescalateStreak = 0
+		return st.Level - 1
+	}
+	return st.Level
+}
+
+// setPrefixLevelLocked moves st to level and updates dst_escalation.
+func (e *Engine) setPrefixLevelLocked(st *prefixState, level Level, now time.Time) {
+	from := st.Level
+	var err error
+	if level > Low {
+		err = e.prefixMap.SetDstEscalation(st.Prefix, uint32(level))
+	} else if from > Low {
+		err = e.prefixMap.RemoveDstEscalation(st.Prefix)
+	}
+	if err != nil {
+		e.log.Error("failed to push prefix escalation level to BPF",
+			zap.String("prefix", st.Prefix), zap.Error(err))
+	}
+
+	st.Level, st.Since = level, now
+	if from == level {
+		return
+	}
+	log := e.log.Info
+	if level > from {
+		log = e.log.Warn
+	}
+	log("prefix escalation level changed",
+		zap.String("prefix", st.Prefix),
+		zap.String("from", from.String()),
+		zap.String("to", level.String()),
+		zap.Float64("drop_ratio", st.DropRatio),
+		zap.Float64("drop_pps", st.DropPPS),
+	)
+}
+
+func (e *Engine) prefixLevelsLocked() []PrefixLevel {
+	out := make([]PrefixLevel, 0, len(e.prefixes))
+	for _, st := range e.prefixes {
+		if st.Level > Low {
+			out = append(out, st.PrefixLevel)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Prefix < out[j].Prefix })
+	return out
+}
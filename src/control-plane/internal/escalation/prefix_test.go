@@ -0,0 +1,61 @@
+package escalation
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakePrefixMap stores dst_escalation writes.
+type fakePrefixMap map[string]uint32
+
+func (f fakePrefixMap) SetDstEscalation(cidr string, level uint32) error {
+	f[cidr] = level
+	return nil
+}
+
+func (f fakePrefixMap) RemoveDstEscalation(cidr string) error {
+	delete(f, cidr)
+	return nil
+}
+
+func TestEvaluatePrefixes(t *testing.T) {
+	m := fakePrefixMap{}
+	e := NewEngine(zap.NewNop(), fakeConfigTable{})
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return clock }
+
+	if got := e.EvaluatePrefixes(ctx, []PrefixSample{{Prefix: "192.0.2.0/24", RxPPS: 1e5, DropPPS: 9e4}}); got != nil {
+		t.Fatalf("disabled: %+v", got)
+	}
+	e.SetPrefixMap(m)
+
+	victim := PrefixSample{Prefix: "192.0.2.0/24", RxPPS: 1e5, DropPPS: 4e4}
+	quiet := PrefixSample{Prefix: "198.51.100.0/24", RxPPS: 100, DropPPS: 90}
+	got := e.EvaluatePrefixes(ctx, []PrefixSample{victim, quiet})
+	if len(got) != 1 || got[0].Prefix != victim.Prefix || got[0].Level != High {
+		t.Fatalf("levels = %+v", got)
+	}
+	if m[victim.Prefix] != uint32(High) || len(m) != 1 {
+		t.Errorf("dst_escalation = %v", m)
+	}
+	if e.GetLevel() != Low || e.MaxLevel() != High {
+		t.Errorf("global %s, max %s; want LOW, HIGH", e.GetLevel(), e.MaxLevel())
+	}
+
+	victim.DropPPS = 0
+	for i := 0; i < hysteresisCount; i++ {
+		clock = clock.Add(EvalInterval)
+		e.EvaluatePrefixes(ctx, []PrefixSample{victim, quiet})
+	}
+	if m[victim.Prefix] != uint32(Medium) {
+		t.Errorf("after quiet evaluations: dst_escalation = %v", m)
+	}
+
+	// A prefix no longer sampled, e.g. a removed asset, is dropped.
+	e.EvaluatePrefixes(ctx, []PrefixSample{quiet})
+	if len(m) != 0 || len(e.PrefixLevels()) != 0 || e.MaxLevel() != Low {
+		t.Errorf("after removal: dst_escalation = %v, levels = %+v", m, e.PrefixLevels())
+	}
+}
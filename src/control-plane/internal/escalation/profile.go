@@ -0,0 +1,196 @@
+package escalation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config map keys a profile may override, matching types.h.
+const (
+	cfgSYNRatePPS       uint32 = 1
+	cfgUDPRatePPS       uint32 = 2
+	cfgICMPRatePPS      uint32 = 3
+	cfgSYNCookieEnable  uint32 = 6
+	cfgGeoIPEnable      uint32 = 11
+	cfgProtoValidEnable uint32 = 14
+	cfgDNSValidMode     uint32 = 18
+	cfgTCPStateEnable   uint32 = 19
+)
+
+// DNS validation modes for CFG_DNS_VALID_MODE.
+var dnsValidModes = map[string]uint64{"off": 0, "basic": 1, "strict": 2}
+
+// Profile is a set of mitigation actions applied while escalation is at or
+// above its level. Profiles are cumulative: at HIGH the MEDIUM profile
+// applies too, and a later level wins where both set the same value.
+// Unset fields leave the running configuration alone.
+type Profile struct {
+	SYNCookie       bool   `yaml:"syn_cookie"`
+	ProtoValidation bool   `yaml:"proto_validation"`
+	TCPState        bool   `yaml:"tcp_state"`
+	DNSValidation   string `yaml:"dns_validation"` // "off", "basic" or "strict"
+	GeoIP           bool   `yaml:"geoip"`          // enforce GeoIP country policies
+
+	// Rate limits as a percentage of the limit in place when the profile
+	// engaged, e.g. 50 halves it. 0 leaves the limit unchanged.
+	SYNRatePct  uint64 `yaml:"syn_rate_pct"`
+	UDPRatePct  uint64 `yaml:"udp_rate_pct"`
+	ICMPRatePct uint64 `yaml:"icmp_rate_pct"`
+
+	// BGP allows OnCritical to signal upstream. CRITICAL profile only.
+	BGP bool `yaml:"bgp"`
+}
+
+// Config binds a profile to each escalation level above LOW, keyed by
+// level name ("medium", "high", "critical").
+type Config struct {
+	Profiles map[string]Profile `yaml:"profiles"`
+
+	// MinDwellSec is the minimum time escalation stays at a level before
+	// it may de-escalate from it, keyed by level name like Profiles. It
+	// keeps an intermittent attack from cycling the mitigation profiles.
+	// Levels not listed de-escalate after the usual hysteresis alone.
+	MinDwellSec map[string]uint64 `yaml:"min_dwell_sec"`
+
+	// PerPrefix escalates each protected asset prefix on its own traffic,
+	// so one victim under attack does not raise the level for the rest.
+	// Traffic towards an escalated prefix is left out of the global level.
+	PerPrefix bool `yaml:"per_prefix"`
+
+	// Triggers sets the thresholds of the triggers that escalate to each
+	// level, keyed by level name and then trigger name, e.g. drop_ratio or
+	// conntrack_churn. A level listed replaces its built-in triggers.
+	Triggers map[string]map[string]float64 `yaml:"triggers"`
+}
+
+// DefaultConfig returns the built-in profiles: SYN cookies at MEDIUM,
+// strict DNS validation and halved UDP limits at HIGH, and GeoIP
+// enforcement with BGP signaling at CRITICAL.
+func DefaultConfig() Config {
+	return Config{Profiles: map[string]Profile{
+		"medium":   {SYNCookie: true},
+		"high":     {DNSValidation: "strict", UDPRatePct: 50},
+		"critical": {GeoIP: true, BGP: true},
+	}}
+}
+
+// Validate checks the escalation configuration.
+func (c Config) Validate() error {
+	if _, err := c.levels(); err != nil {
+		return err
+	}
+	if err := validateTriggers(c.Triggers); err != nil {
+		return err
+	}
+	for name := range c.MinDwellSec {
+		if level, ok := ParseLevel(name); !ok || level == Low {
+			return fmt.Errorf("min_dwell_sec: invalid level %q (must be medium, high or critical)", name)
+		}
+	}
+	return nil
+}
+
+// levels returns the profiles keyed by Level.
+func (c Config) levels() (map[Level]Profile, error) {
+	out := make(map[Level]Profile, len(c.Profiles))
+	for name, p := range c.Profiles {
+		level, ok := ParseLevel(name)
+		if !ok || level == Low {
+			return nil, fmt.Errorf("profiles: invalid level %q (must be medium, high or critical)", name)
+		}
+		if _, ok := dnsValidModes[p.DNSValidation]; p.DNSValidation != "" && !ok {
+			return nil, fmt.Errorf("profiles.%s: invalid dns_validation %q (must be off, basic or strict)", name, p.DNSValidation)
+		}
+		for field, pct := range map[string]uint64{"syn_rate_pct": p.SYNRatePct, "udp_rate_pct": p.UDPRatePct, "icmp_rate_pct": p.ICMPRatePct} {
+			if pct > 100 {
+				return nil, fmt.Errorf("profiles.%s: %s must be 0-100", name, field)
+			}
+		}
+		if p.BGP && level != Critical {
+			return nil, fmt.Errorf("profiles.%s: bgp is only supported at critical", name)
+		}
+		out[level] = p
+	}
+	return out, nil
+}
+
+// dwellTimes returns the minimum dwell times keyed by Level. Call it on a
+// validated Config.
+func (c Config) dwellTimes() map[Level]time.Duration {
+	out := make(map[Level]time.Duration, len(c.MinDwellSec))
+	for name, sec := range c.MinDwellSec {
+		if level, ok := ParseLevel(name); ok {
+			out[level] = time.Duration(sec) * time.Second
+		}
+	}
+	return out
+}
+
+// ParseLevel parses a level name such as "high", case-insensitively.
+func ParseLevel(name string) (Level, bool) {
+	for l := Low; l <= Critical; l++ {
+		if strings.EqualFold(name, l.String()) {
+			return l, true
+		}
+	}
+	return Low, false
+}
+
+// profileValues returns the config map values the profiles up to level
+// require. Scaled rate limits are computed from orig, the value the key had
+// before any profile touched it.
+func profileValues(profiles map[Level]Profile, level Level, orig func(key uint32) uint64) map[uint32]uint64 {
+	want := make(map[uint32]uint64)
+	for l := Medium; l <= level; l++ {
+		p, ok := profiles[l]
+		if !ok {
+			continue
+		}
+		for key, on := range map[uint32]bool{
+			cfgSYNCookieEnable:  p.SYNCookie,
+			cfgProtoValidEnable: p.ProtoValidation,
+			cfgTCPStateEnable:   p.TCPState,
+			cfgGeoIPEnable:      p.GeoIP,
+		} {
+			if on {
+				want[key] = 1
+			}
+		}
+		if p.DNSValidation != "" {
+			want[cfgDNSValidMode] = dnsValidModes[p.DNSValidation]
+		}
+		for key, pct := range map[uint32]uint64{
+			cfgSYNRatePPS:  p.SYNRatePct,
+			cfgUDPRatePPS:  p.UDPRatePct,
+			cfgICMPRatePPS: p.ICMPRatePct,
+		} {
+			if pct > 0 {
+				want[key] = scaleRate(orig(key), pct)
+			}
+		}
+	}
+	return want
+}
+
+// scaleRate returns pct percent of rate, never rounding a limit down to 0,
+// which BPF treats as unlimited.
+func scaleRate(rate, pct uint64) uint64 {
+	scaled := rate * pct / 100
+	if scaled == 0 && rate > 0 {
+		return 1
+	}
+	return scaled
+}
+
+// sortedKeys returns the keys of m in ascending order so map writes and
+// logs are deterministic.
+func sortedKeys(m map[uint32]uint64) []uint32 {
+	keys := make([]uint32, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
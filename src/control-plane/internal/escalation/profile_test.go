@@ -0,0 +1,63 @@
+package escalation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProfileValues(t *testing.T) {
+	profiles, err := DefaultConfig().levels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := func(key uint32) uint64 {
+		if key == cfgUDPRatePPS {
+			return 5000
+		}
+		return 0
+	}
+
+	tests := []struct {
+		level Level
+		want  map[uint32]uint64
+	}{
+		{Low, map[uint32]uint64{}},
+		{Medium, map[uint32]uint64{cfgSYNCookieEnable: 1}},
+		{High, map[uint32]uint64{cfgSYNCookieEnable: 1, cfgDNSValidMode: 2, cfgUDPRatePPS: 2500}},
+		{Critical, map[uint32]uint64{cfgSYNCookieEnable: 1, cfgDNSValidMode: 2, cfgUDPRatePPS: 2500, cfgGeoIPEnable: 1}},
+	}
+	for _, tt := range tests {
+		if got := profileValues(profiles, tt.level, orig); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("profileValues(%s) = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestProfileLaterLevelWins(t *testing.T) {
+	profiles := map[Level]Profile{
+		Medium: {DNSValidation: "basic", UDPRatePct: 50},
+		High:   {DNSValidation: "strict", UDPRatePct: 10},
+	}
+	got := profileValues(profiles, High, func(uint32) uint64 { return 5 })
+	if got[cfgDNSValidMode] != 2 || got[cfgUDPRatePPS] != 1 {
+		t.Errorf("values = %v", got)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, cfg := range []Config{
+		{Profiles: map[string]Profile{"low": {SYNCookie: true}}},
+		{Profiles: map[string]Profile{"severe": {}}},
+		{Profiles: map[string]Profile{"high": {DNSValidation: "paranoid"}}},
+		{Profiles: map[string]Profile{"high": {UDPRatePct: 150}}},
+		{Profiles: map[string]Profile{"high": {BGP: true}}},
+		{MinDwellSec: map[string]uint64{"low": 60}},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%+v: expected error", cfg)
+		}
+	}
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("default config: %v", err)
+	}
+}
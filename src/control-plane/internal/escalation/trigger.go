@@ -0,0 +1,160 @@
+package escalation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Metrics is what triggers are evaluated against, gathered by the caller
+// of Evaluate once per EvalInterval.
+type Metrics struct {
+	RxPPS             float64
+	DropPPS           float64
+	DropRatio         float64 // DropPPS / RxPPS (0.0 - 1.0)
+	ZScore            float64 // Anomaly z-score from the baseline
+	ReputationBlocked int     // Sources auto-blocked by reputation
+
+	ConntrackNewPPS    float64 // Conntrack entries created per second
+	SYNCookieFailedPPS float64 // ACKs failing SYN cookie validation per second
+	ThreatIntelPPS     float64 // Packets dropped by threat intel per second
+}
+
+// Trigger is one escalation signal. A trigger is built for each level it
+// is configured at, with that level's threshold; Evaluate reports whether
+// the metrics justify escalating to the level.
+type Trigger interface {
+	Name() string
+	Evaluate(ctx context.Context, m Metrics) bool
+}
+
+// Measurer is implemented by triggers that compare one metric with a
+// threshold, so that the trigger states can report both.
+type Measurer interface {
+	Measure(m Metrics) (current, threshold float64)
+}
+
+// TriggerFactory builds a trigger with the threshold configured for a
+// level, already divided by the escalation sensitivity.
+type TriggerFactory func(threshold float64) Trigger
+
+var (
+	triggerMu        sync.RWMutex
+	triggerFactories = map[string]TriggerFactory{
+		"drop_ratio":          metricTrigger("drop_ratio", func(m Metrics) float64 { return m.DropRatio }),
+		"z_score":             metricTrigger("z_score", func(m Metrics) float64 { return m.ZScore }),
+		"reputation_blocked":  metricTrigger("reputation_blocked", func(m Metrics) float64 { return float64(m.ReputationBlocked) }),
+		"drop_pps":            metricTrigger("drop_pps", func(m Metrics) float64 { return m.DropPPS }),
+		"conntrack_churn":     metricTrigger("conntrack_churn", func(m Metrics) float64 { return m.ConntrackNewPPS }),
+		"syn_cookie_failures": metricTrigger("syn_cookie_failures", func(m Metrics) float64 { return m.SYNCookieFailedPPS }),
+		"threat_intel_hits":   metricTrigger("threat_intel_hits", func(m Metrics) float64 { return m.ThreatIntelPPS }),
+	}
+)
+
+// RegisterTrigger makes a trigger available to the escalation.triggers
+// configuration under name. It panics if the name is taken, so call it
+// from an init function.
+func RegisterTrigger(name string, f TriggerFactory) {
+	triggerMu.Lock()
+	defer triggerMu.Unlock()
+	if _, ok := triggerFactories[name]; ok {
+		panic(fmt.Sprintf("escalation: trigger %q registered twice", name))
+	}
+	triggerFactories[name] = f
+}
+
+// TriggerNames returns the registered trigger names in order.
+func TriggerNames() []string {
+	triggerMu.RLock()
+	defer triggerMu.RUnlock()
+	names := make([]string, 0, len(triggerFactories))
+	for name := range triggerFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func triggerFactory(name string) (TriggerFactory, bool) {
+	triggerMu.RLock()
+	defer triggerMu.RUnlock()
+	f, ok := triggerFactories[name]
+	return f, ok
+}
+
+// thresholdTrigger fires when its metric exceeds the threshold.
+type thresholdTrigger struct {
+	name      string
+	threshold float64
+	metric    func(Metrics) float64
+}
+
+// metricTrigger returns the factory of a trigger on one metric.
+func metricTrigger(name string, metric func(Metrics) float64) TriggerFactory {
+	return func(threshold float64) Trigger {
+		return &thresholdTrigger{name: name, threshold: threshold, metric: metric}
+	}
+}
+
+func (t *thresholdTrigger) Name() string { return t.name }
+
+func (t *thresholdTrigger) Evaluate(_ context.Context, m Metrics) bool {
+	return t.threshold > 0 && t.metric(m) > t.threshold
+}
+
+func (t *thresholdTrigger) Measure(m Metrics) (float64, float64) {
+	return t.metric(m), t.threshold
+}
+
+// defaultTriggers are the built-in thresholds of each level.
+func defaultTriggers() map[string]map[string]float64 {
+	return map[string]map[string]float64{
+		"medium":   {"drop_ratio": 0.10, "z_score": 2.0},
+		"high":     {"drop_ratio": 0.30, "z_score": 3.0, "reputation_blocked": 100},
+		"critical": {"drop_ratio": 0.50, "z_score": 5.0, "drop_pps": 500000},
+	}
+}
+
+// buildTriggers returns the triggers of each level with their thresholds
+// divided by sensitivity. Levels missing from cfg keep the built-in
+// triggers. cfg must have been validated.
+func buildTriggers(cfg map[string]map[string]float64, sensitivity float64) map[Level][]Trigger {
+	levels := defaultTriggers()
+	for name, triggers := range cfg {
+		levels[name] = triggers
+	}
+
+	out := make(map[Level][]Trigger, len(levels))
+	for name, triggers := range levels {
+		level, _ := ParseLevel(name)
+		names := make([]string, 0, len(triggers))
+		for trigger := range triggers {
+			names = append(names, trigger)
+		}
+		sort.Strings(names)
+		for _, trigger := range names {
+			f, _ := triggerFactory(trigger)
+			out[level] = append(out[level], f(triggers[trigger]/sensitivity))
+		}
+	}
+	return out
+}
+
+// validateTriggers checks that cfg names known levels and triggers.
+func validateTriggers(cfg map[string]map[string]float64) error {
+	for name, triggers := range cfg {
+		if level, ok := ParseLevel(name); !ok || level == Low {
+			return fmt.Errorf("triggers: invalid level %q (must be medium, high or critical)", name)
+		}
+		for trigger, threshold := range triggers {
+			if _, ok := triggerFactory(trigger); !ok {
+				return fmt.Errorf("triggers.%s: unknown trigger %q (have %v)", name, trigger, TriggerNames())
+			}
+			if threshold <= 0 {
+				return fmt.Errorf("triggers.%s.%s: threshold must be positive", name, trigger)
+			}
+		}
+	}
+	return nil
+}
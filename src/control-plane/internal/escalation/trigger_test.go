@@ -0,0 +1,76 @@
+package escalation
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// flagTrigger fires while its flag is set.
+type flagTrigger struct{ on *bool }
+
+func (t flagTrigger) Name() string                           { return "test_flag" }
+func (t flagTrigger) Evaluate(context.Context, Metrics) bool { return *t.on }
+
+func TestRegisteredTrigger(t *testing.T) {
+	var on bool
+	RegisterTrigger("test_flag", func(float64) Trigger { return flagTrigger{&on} })
+
+	e := NewEngine(zap.NewNop(), fakeConfigTable{})
+	if err := e.SetConfig(Config{Triggers: map[string]map[string]float64{"high": {"test_flag": 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Evaluate(ctx, attack); got != Medium {
+		t.Fatalf("drop ratio 0.4 with only test_flag at HIGH = %s, want MEDIUM", got)
+	}
+	on = true
+	if got := e.Evaluate(ctx, idle); got != High {
+		t.Fatalf("level with test_flag set = %s, want HIGH", got)
+	}
+	var active []string
+	for _, ts := range e.GetTriggers() {
+		if ts.Active {
+			active = append(active, ts.Name)
+		}
+	}
+	if len(active) != 1 || active[0] != "test_flag" {
+		t.Errorf("active triggers = %v", active)
+	}
+}
+
+func TestTriggerThresholds(t *testing.T) {
+	e := NewEngine(zap.NewNop(), fakeConfigTable{})
+	cfg := Config{Triggers: map[string]map[string]float64{"medium": {"conntrack_churn": 5000}}}
+	if err := e.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	churn := Metrics{RxPPS: 1000, ConntrackNewPPS: 3000}
+	if got := e.Evaluate(ctx, churn); got != Low {
+		t.Fatalf("churn below threshold = %s, want LOW", got)
+	}
+	e.SetSensitivity(2)
+	if got := e.Evaluate(ctx, churn); got != Medium {
+		t.Fatalf("churn at sensitivity 2 = %s, want MEDIUM", got)
+	}
+	for _, ts := range e.GetTriggers() {
+		if ts.Name == "conntrack_churn" && (!ts.Active || ts.Current != 3000 || ts.Threshold != 2500) {
+			t.Errorf("conntrack_churn state = %+v", ts)
+		}
+	}
+}
+
+func TestValidateTriggers(t *testing.T) {
+	for _, cfg := range []map[string]map[string]float64{
+		{"low": {"drop_ratio": 0.1}},
+		{"high": {"no_such_trigger": 1}},
+		{"high": {"z_score": 0}},
+	} {
+		if err := validateTriggers(cfg); err == nil {
+			t.Errorf("%v: expected error", cfg)
+		}
+	}
+	if err := validateTriggers(defaultTriggers()); err != nil {
+		t.Errorf("default triggers: %v", err)
+	}
+}
@@ -0,0 +1,291 @@
+package events
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+const (
+	rdnsQueueSize     = 1024
+	escalationRefresh = time.Second
+)
+
+// EnrichConfig controls the enrichment stage that annotates events before
+// they are dispatched.
+type EnrichConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	ASNDatabase  string `yaml:"asn_database"`   // GeoLite2-ASN.mmdb ("" = no ASN lookups)
+	ReverseDNS   bool   `yaml:"reverse_dns"`    // Resolve source hostnames in the background
+	CacheSize    int    `yaml:"cache_size"`     // Addresses cached per lookup
+	CacheTTLSec  int    `yaml:"cache_ttl_sec"`  // Lifetime of cached lookups
+	DNSWorkers   int    `yaml:"dns_workers"`    // Concurrent reverse lookups
+	DNSTimeoutMS int    `yaml:"dns_timeout_ms"` // Per-lookup timeout
+}
+
+// Validate checks the enrichment configuration.
+func (c EnrichConfig) Validate() error {
+	if c.CacheSize <= 0 {
+		return fmt.Errorf("cache_size must be positive")
+	}
+	if c.CacheTTLSec <= 0 {
+		return fmt.Errorf("cache_ttl_sec must be positive")
+	}
+	if c.ReverseDNS && (c.DNSWorkers <= 0 || c.DNSTimeoutMS <= 0) {
+		return fmt.Errorf("reverse_dns requires positive dns_workers and dns_timeout_ms")
+	}
+	return nil
+}
+
+// Info is the context the enrichment stage adds beyond the fields of
+// bpf.Event. Apart from Count it is empty when enrichment is disabled.
+type Info struct {
+	ASN      uint32
+	ASOrg    string
+	Hostname string // Reverse DNS of the source, once resolved
+	Count    uint64 // Events this one stands for after sampling and aggregation
+}
+
+// Events returns how many ring buffer events the event stands for; one
+// unless sampling or aggregation folded others into it.
+func (i Info) Events() uint64 {
+	if i.Count == 0 {
+		return 1
+	}
+	return i.Count
+}
+
+// Lookups are the data sources of the enrichment stage. Nil lookups are
+// skipped.
+type Lookups struct {
+	Country    func(ip net.IP) string
+	ASN        func(ip net.IP) (uint32, string)
+	Reputation func(ipBE uint32) uint32
+	Escalation func() uint8
+}
+
+type geoInfo struct {
+	country uint16 // Packed as in bpf.Event
+	asn     uint32
+	asOrg   string
+}
+
+// Enricher fills the country, reputation and escalation fields of events
+// and looks up their source ASN and hostname. Country and ASN lookups are
+// cached per source. Reverse DNS never blocks the event path: a cache miss
+// queues a background lookup and the hostname is attached to later events
+// from the same source.
+type Enricher struct {
+	log     *zap.Logger
+	cfg     EnrichConfig
+	lookups Lookups
+
+	geo  *lruCache[geoInfo]
+	rdns *lruCache[string]
+
+	mu       sync.Mutex
+	pending  map[uint32]bool // Queued or in-flight reverse lookups
+	level    uint8
+	levelAt  time.Time
+	dnsQueue chan uint32
+
+	lookupAddr func(ctx context.Context, addr string) ([]string, error)
+	now        func() time.Time
+}
+
+// NewEnricher creates an enrichment stage backed by the given lookups.
+func NewEnricher(log *zap.Logger, cfg EnrichConfig, lookups Lookups) *Enricher {
+	ttl := time.Duration(cfg.CacheTTLSec) * time.Second
+	return &Enricher{
+		log:        log,
+		cfg:        cfg,
+		lookups:    lookups,
+		geo:        newLRUCache[geoInfo](cfg.CacheSize, ttl),
+		rdns:       newLRUCache[string](cfg.CacheSize, ttl),
+		pending:    make(map[uint32]bool),
+		dnsQueue:   make(chan uint32, rdnsQueueSize),
+		lookupAddr: net.DefaultResolver.LookupAddr,
+		now:        time.Now,
+	}
+}
+
+// Enrich annotates ev in place and returns the context that does not fit
+// in bpf.Event.
+func (e *Enricher) Enrich(ev *bpf.Event) Info {
+	now := e.now()
+	ip := bpf.U32BEToIP(ev.SrcIP)
+
+	geo, ok := e.geo.get(ev.SrcIP, now)
+	if !ok {
+		if e.lookups.Country != nil {
+			cc := e.lookups.Country(ip)
+			if len(cc) == 2 {
+				geo.country = uint16(cc[0])<<8 | uint16(cc[1])
+			}
+		}
+		if e.lookups.ASN != nil {
+			geo.asn, geo.asOrg = e.lookups.ASN(ip)
+		}
+		e.geo.put(ev.SrcIP, geo, now)
+	}
+	ev.CountryCode = geo.country
+	info := Info{ASN: geo.asn, ASOrg: geo.asOrg}
+
+	if e.lookups.Reputation != nil {
+		ev.ReputationScore = e.lookups.Reputation(ev.SrcIP)
+	}
+	ev.EscalationLevel = e.escalation(now)
+
+	if e.cfg.ReverseDNS {
+		if host, ok := e.rdns.get(ev.SrcIP, now); ok {
+			info.Hostname = host
+		} else {
+			e.queueReverse(ev.SrcIP)
+		}
+	}
+	return info
+}
+
+// escalation returns the escalation level, refreshed at most once per
+// escalationRefresh.
+func (e *Enricher) escalation(now time.Time) uint8 {
+	if e.lookups.Escalation == nil {
+		return 0
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if now.Sub(e.levelAt) >= escalationRefresh {
+		e.level = e.lookups.Escalation()
+		e.levelAt = now
+	}
+	return e.level
+}
+
+func (e *Enricher) queueReverse(ipBE uint32) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pending[ipBE] {
+		return
+	}
+	select {
+	case e.dnsQueue <- ipBE:
+		e.pending[ipBE] = true
+	default:
+		// Busy resolving; a later event from this source retries.
+	}
+}
+
+// Run resolves queued reverse DNS lookups until ctx is cancelled. It
+// returns immediately if reverse DNS is disabled.
+func (e *Enricher) Run(ctx context.Context) {
+	if !e.cfg.ReverseDNS {
+		return
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < e.cfg.DNSWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case ipBE := <-e.dnsQueue:
+					e.resolve(ctx, ipBE)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// resolve looks up the name of a source. Failures are cached as an empty
+// hostname so unresolvable attackers are not queried on every event.
+func (e *Enricher) resolve(ctx context.Context, ipBE uint32) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(e.cfg.DNSTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	var host string
+	names, err := e.lookupAddr(ctx, bpf.U32BEToIP(ipBE).String())
+	if err == nil && len(names) > 0 {
+		host = strings.TrimSuffix(names[0], ".")
+	}
+	e.rdns.put(ipBE, host, e.now())
+
+	e.mu.Lock()
+	delete(e.pending, ipBE)
+	e.mu.Unlock()
+}
+
+// lruCache is a size-bounded cache keyed by IPv4 address whose entries
+// expire after a TTL.
+type lruCache[V any] struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List // Front is most recently used
+	items map[uint32]*list.Element
+}
+
+type lruEntry[V any] struct {
+	key     uint32
+	value   V
+	expires time.Time
+}
+
+func newLRUCache[V any](size int, ttl time.Duration) *lruCache[V] {
+	return &lruCache[V]{
+		size:  size,
+		ttl:   ttl,
+		order: list.New(),
+		items: make(map[uint32]*list.Element),
+	}
+}
+
+func (c *lruCache[V]) get(key uint32, now time.Time) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var zero V
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	entry := el.Value.(*lruEntry[V])
+	if now.After(entry.expires) {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *lruCache[V]) put(key uint32, value V, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*lruEntry[V])
+		entry.value, entry.expires = value, now.Add(c.ttl)
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value, expires: now.Add(c.ttl)})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+func (c *lruCache[V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,127 @@
+package events
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+func testEnrichConfig() EnrichConfig {
+	return EnrichConfig{Enabled: true, CacheSize: 2, CacheTTLSec: 60, DNSWorkers: 1, DNSTimeoutMS: 1000}
+}
+
+func TestEnrich(t *testing.T) {
+	var countryCalls, asnCalls int
+	en := NewEnricher(zap.NewNop(), testEnrichConfig(), Lookups{
+		Country: func(ip net.IP) string {
+			countryCalls++
+			return "NL"
+		},
+		ASN: func(ip net.IP) (uint32, string) {
+			asnCalls++
+			return 64496, "Example Hosting"
+		},
+		Reputation: func(ipBE uint32) uint32 { return 75 },
+		Escalation: func() uint8 { return 2 },
+	})
+
+	for i := 0; i < 3; i++ {
+		ev := testEvent()
+		info := en.Enrich(ev)
+		if ev.CountryCode != 'N'<<8|'L' || ev.ReputationScore != 75 || ev.EscalationLevel != 2 {
+			t.Errorf("event = %+v", ev)
+		}
+		if info.ASN != 64496 || info.ASOrg != "Example Hosting" || info.Hostname != "" {
+			t.Errorf("info = %+v", info)
+		}
+	}
+	if countryCalls != 1 || asnCalls != 1 {
+		t.Errorf("lookups not cached: country=%d asn=%d", countryCalls, asnCalls)
+	}
+}
+
+func TestEnrichReverseDNS(t *testing.T) {
+	cfg := testEnrichConfig()
+	cfg.ReverseDNS = true
+	en := NewEnricher(zap.NewNop(), cfg, Lookups{})
+
+	queried := make(chan string, 4)
+	en.lookupAddr = func(ctx context.Context, addr string) ([]string, error) {
+		queried <- addr
+		return []string{"vps-7.example.net."}, nil
+	}
+
+	// The first events queue a single lookup and carry no hostname.
+	if info := en.Enrich(testEvent()); info.Hostname != "" {
+		t.Errorf("hostname before lookup = %q", info.Hostname)
+	}
+	en.Enrich(testEvent())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go en.Run(ctx)
+
+	select {
+	case addr := <-queried:
+		if addr != "198.51.100.7" {
+			t.Errorf("queried %s", addr)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reverse lookup")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for en.Enrich(testEvent()).Hostname != "vps-7.example.net" {
+		if time.Now().After(deadline) {
+			t.Fatal("hostname not attached to later events")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(queried) != 0 {
+		t.Error("source looked up more than once")
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache[string](2, time.Minute)
+	now := time.Now()
+	c.put(1, "a", now)
+	c.put(2, "b", now)
+	c.get(1, now) // 2 is now least recently used
+	c.put(3, "c", now)
+
+	if _, ok := c.get(2, now); ok {
+		t.Error("least recently used entry not evicted")
+	}
+	if v, ok := c.get(1, now); !ok || v != "a" {
+		t.Errorf("get(1) = %q, %v", v, ok)
+	}
+	if _, ok := c.get(3, now.Add(2*time.Minute)); ok {
+		t.Error("expired entry returned")
+	}
+	if c.len() != 1 {
+		t.Errorf("len = %d, want 1", c.len())
+	}
+}
+
+func TestReaderEnrichesBeforeDispatch(t *testing.T) {
+	r := NewReader(zap.NewNop(), nil)
+	r.SetEnricher(NewEnricher(zap.NewNop(), testEnrichConfig(), Lookups{
+		Reputation: func(ipBE uint32) uint32 { return 42 },
+		ASN:        func(ip net.IP) (uint32, string) { return 64496, "" },
+	}))
+
+	var plain uint32
+	var asn uint32
+	r.OnEvent(func(ev *bpf.Event) { plain = ev.ReputationScore })
+	r.OnEnrichedEvent(func(ev *bpf.Event, info Info) { asn = info.ASN })
+	r.dispatch(testEvent(), 1)
+
+	if plain != 42 || asn != 64496 {
+		t.Errorf("handlers saw reputation %d, asn %d", plain, asn)
+	}
+}
@@ -0,0 +1,91 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// lossLogInterval bounds how often event loss is logged, so a storm that
+// keeps the ring full produces one warning per interval rather than one
+// per stats tick.
+const lossLogInterval = 10 * time.Second
+
+// RingStats describes how well the reader keeps up with the ring buffer.
+// Lost is the BPF program's count of events it found no room for; when it
+// grows, the event view is incomplete.
+type RingStats struct {
+	SizeBytes        int
+	BacklogBytes     int // Unread after the last read
+	PeakBacklogBytes int
+	Lost             uint64
+	LastLoss         time.Time // Zero if no event was lost
+}
+
+// ringMonitor tracks ring buffer fill from the reader goroutine and event
+// loss from the BPF stats counter.
+type ringMonitor struct {
+	size, backlog, peak atomic.Int64
+
+	mu       sync.Mutex
+	lost     uint64
+	lastLoss time.Time
+	unlogged uint64 // Lost since the last warning
+	lastLog  time.Time
+}
+
+// observeBacklog records the bytes left unread after a read. Only the
+// reader goroutine writes, so the peak needs no compare-and-swap.
+func (m *ringMonitor) observeBacklog(remaining int) {
+	n := int64(remaining)
+	m.backlog.Store(n)
+	if n > m.peak.Load() {
+		m.peak.Store(n)
+	}
+}
+
+// ObserveLost records the BPF program's running count of events lost to a
+// full ring buffer, as read from the global stats at the given time, and
+// logs a warning when it has grown. A count lower than the last one means
+// the program was reloaded and counts from zero again.
+func (r *Reader) ObserveLost(total uint64, at time.Time) {
+	m := &r.ring
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delta := total - m.lost
+	if total < m.lost {
+		delta = total
+	}
+	m.lost = total
+	if delta > 0 {
+		m.lastLoss = at
+		m.unlogged += delta
+	}
+	if m.unlogged > 0 && at.Sub(m.lastLog) >= lossLogInterval && r.log != nil {
+		r.log.Warn("event ring buffer full, events lost",
+			zap.Uint64("lost", m.unlogged),
+			zap.Uint64("total_lost", total),
+			zap.Int64("peak_backlog_bytes", m.peak.Load()),
+			zap.Int64("size_bytes", m.size.Load()),
+		)
+		m.unlogged = 0
+		m.lastLog = at
+	}
+}
+
+// RingStats returns the ring buffer fill and loss counters.
+func (r *Reader) RingStats() RingStats {
+	m := &r.ring
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return RingStats{
+		SizeBytes:        int(m.size.Load()),
+		BacklogBytes:     int(m.backlog.Load()),
+		PeakBacklogBytes: int(m.peak.Load()),
+		Lost:             m.lost,
+		LastLoss:         m.lastLoss,
+	}
+}
@@ -0,0 +1,62 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestObserveLost(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	r := NewReader(zap.New(core), nil)
+	t0 := time.Unix(1700000000, 0)
+
+	r.ObserveLost(0, t0)
+	if logs.Len() != 0 || !r.RingStats().LastLoss.IsZero() {
+		t.Fatal("loss reported without lost events")
+	}
+
+	r.ObserveLost(100, t0.Add(time.Second))
+	if logs.Len() != 1 {
+		t.Fatalf("warnings = %d, want 1", logs.Len())
+	}
+	// Further loss within the interval is held back, then logged in one go.
+	r.ObserveLost(150, t0.Add(2*time.Second))
+	r.ObserveLost(180, t0.Add(3*time.Second))
+	if logs.Len() != 1 {
+		t.Fatalf("warnings within interval = %d, want 1", logs.Len())
+	}
+	r.ObserveLost(180, t0.Add(time.Second+lossLogInterval))
+	if logs.Len() != 2 {
+		t.Fatalf("warnings after interval = %d, want 2", logs.Len())
+	}
+	if lost := logs.All()[1].ContextMap()["lost"]; lost != uint64(80) {
+		t.Errorf("second warning lost = %v, want 80", lost)
+	}
+
+	rs := r.RingStats()
+	if rs.Lost != 180 || !rs.LastLoss.Equal(t0.Add(3*time.Second)) {
+		t.Errorf("RingStats() = %+v, want 180 lost, last at +3s", rs)
+	}
+
+	// A reloaded program counts from zero again.
+	r.ObserveLost(5, t0.Add(time.Minute))
+	if logs.Len() != 3 {
+		t.Fatalf("warnings after reload = %d, want 3", logs.Len())
+	}
+	if lost := logs.All()[2].ContextMap()["lost"]; lost != uint64(5) {
+		t.Errorf("warning after reload lost = %v, want 5", lost)
+	}
+}
+
+func TestObserveBacklog(t *testing.T) {
+	var m ringMonitor
+	m.observeBacklog(4096)
+	m.observeBacklog(128)
+	if m.backlog.Load() != 128 || m.peak.Load() != 4096 {
+		t.Errorf("backlog = %d, peak = %d, want 128, 4096", m.backlog.Load(), m.peak.Load())
+	}
+}
@@ -0,0 +1,606 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+// Stream compression modes.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+const (
+	natsDefaultPort       = "4222"
+	natsDialTimeout       = 5 * time.Second
+	natsWriteTimeout      = 5 * time.Second
+	natsReconnectDelay    = 2 * time.Second
+	natsDefaultMaxPayload = 1 << 20
+
+	defaultNATSBatchSize = 500
+	defaultNATSFlushMS   = 100
+	defaultNATSQueueSize = 65536
+
+	// Once the queue is this full (percent), passing events are shed so
+	// the remaining room goes to drops.
+	natsShedPct = 75
+)
+
+// NATSConfig controls streaming of events to a NATS server.
+type NATSConfig struct {
+	Enabled     bool            `yaml:"enabled"`
+	URL         string          `yaml:"url"`     // nats://host:port, or tls://host:port to require TLS
+	Subject     string          `yaml:"subject"` // Subject events are published to
+	Token       string          `yaml:"token"`   // Token authentication
+	User        string          `yaml:"user"`    // User/password authentication
+	Password    string          `yaml:"password"`
+	BatchSize   int             `yaml:"batch_size"`  // Events per message
+	FlushMS     int             `yaml:"flush_ms"`    // Max delay before a partial batch is published
+	Compression string          `yaml:"compression"` // none or gzip
+	QueueSize   int             `yaml:"queue_size"`  // Events buffered while the server is slow or unreachable
+	DropsOnly   bool            `yaml:"drops_only"`  // Publish only dropped packets
+	TLS         SyslogTLSConfig `yaml:"tls"`
+}
+
+// Validate checks the NATS configuration.
+func (c NATSConfig) Validate() error {
+	if _, _, err := natsAddress(c.URL); err != nil {
+		return err
+	}
+	if c.Subject == "" || strings.ContainsAny(c.Subject, " \t\r\n*>") {
+		return fmt.Errorf("invalid subject %q", c.Subject)
+	}
+	switch c.Compression {
+	case CompressionNone, CompressionGzip:
+	default:
+		return fmt.Errorf("invalid compression %q (must be none or gzip)", c.Compression)
+	}
+	if c.BatchSize <= 0 {
+		return fmt.Errorf("batch_size must be positive")
+	}
+	if c.FlushMS <= 0 {
+		return fmt.Errorf("flush_ms must be positive")
+	}
+	if c.QueueSize < c.BatchSize {
+		return fmt.Errorf("queue_size (%d) must be at least batch_size (%d)", c.QueueSize, c.BatchSize)
+	}
+	if c.Token != "" && c.User != "" {
+		return fmt.Errorf("token and user are mutually exclusive")
+	}
+	return nil
+}
+
+// natsAddress returns the dial address of a NATS URL and whether its
+// scheme requires TLS. A bare host:port is accepted as nats://.
+func natsAddress(raw string) (string, bool, error) {
+	if raw == "" {
+		return "", false, fmt.Errorf("url is required")
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "nats://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid url: %w", err)
+	}
+	var useTLS bool
+	switch u.Scheme {
+	case "nats":
+	case "tls":
+		useTLS = true
+	default:
+		return "", false, fmt.Errorf("invalid url scheme %q (must be nats or tls)", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return "", false, fmt.Errorf("url %q has no host", raw)
+	}
+	port := u.Port()
+	if port == "" {
+		port = natsDefaultPort
+	}
+	return net.JoinHostPort(u.Hostname(), port), useTLS, nil
+}
+
+// NATSStats counts published and discarded events.
+type NATSStats struct {
+	Published uint64 `json:"published"` // Events
+	Messages  uint64 `json:"messages"`
+	Bytes     uint64 `json:"bytes"`   // Payload bytes, after compression
+	Shed      uint64 `json:"shed"`    // Passing events shed under backpressure
+	Dropped   uint64 `json:"dropped"` // Queue full
+	Errors    uint64 `json:"errors"`  // Connection or publish failures
+}
+
+// NATSSink streams events to NATS as batches of newline-delimited JSON
+// records, optionally gzip-compressed. Events are queued by Handle and
+// published by Run; while the server is slow or unreachable Run stops
+// draining the queue, and Handle sheds passing events and then discards
+// everything once the queue is full. A batch that fails to publish is
+// retried after reconnecting, so delivery is at least once.
+type NATSSink struct {
+	log    *zap.Logger
+	cfg    NATSConfig
+	addr   string
+	useTLS bool
+	tls    *tls.Config
+	queue  chan queuedEvent
+	shedAt int
+
+	mu    sync.Mutex
+	stats NATSStats
+
+	// Owned by Run.
+	conn    *natsConn
+	batch   [][]byte
+	zw      *gzip.Writer
+	failing bool
+
+	done chan struct{}
+}
+
+// NewNATSSink creates a sink for the given configuration. It fails if the
+// URL is invalid or the TLS CA bundle cannot be loaded.
+func NewNATSSink(log *zap.Logger, cfg NATSConfig) (*NATSSink, error) {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultNATSBatchSize
+	}
+	if cfg.FlushMS <= 0 {
+		cfg.FlushMS = defaultNATSFlushMS
+	}
+	if cfg.QueueSize < cfg.BatchSize {
+		cfg.QueueSize = max(defaultNATSQueueSize, cfg.BatchSize)
+	}
+	addr, useTLS, err := natsAddress(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &NATSSink{
+		log:    log,
+		cfg:    cfg,
+		addr:   addr,
+		useTLS: useTLS,
+		queue:  make(chan queuedEvent, cfg.QueueSize),
+		shedAt: cfg.QueueSize * natsShedPct / 100,
+		done:   make(chan struct{}),
+	}
+	if cfg.Compression == CompressionGzip {
+		s.zw, _ = gzip.NewWriterLevel(nil, gzip.BestSpeed)
+	}
+
+	// Built even for nats:// URLs, as the server may require TLS.
+	s.tls = &tls.Config{
+		ServerName:         cfg.TLS.ServerName,
+		InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if s.tls.ServerName == "" {
+		s.tls.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+	if cfg.TLS.CA != "" {
+		pem, err := os.ReadFile(cfg.TLS.CA)
+		if err != nil {
+			return nil, fmt.Errorf("reading NATS CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.TLS.CA)
+		}
+		s.tls.RootCAs = pool
+	}
+	return s, nil
+}
+
+// Handle queues an event for publishing. It never blocks.
+func (s *NATSSink) Handle(ev *bpf.Event, info Info) {
+	if ev.Action != 1 && (s.cfg.DropsOnly || len(s.queue) >= s.shedAt) {
+		if !s.cfg.DropsOnly {
+			s.count(func(st *NATSStats) { st.Shed++ })
+		}
+		return
+	}
+	select {
+	case s.queue <- queuedEvent{ev: *ev, info: info, at: time.Now()}:
+	default:
+		s.count(func(st *NATSStats) { st.Dropped++ })
+	}
+}
+
+// Stats returns the publishing counters.
+func (s *NATSSink) Stats() NATSStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *NATSSink) count(fn func(*NATSStats)) {
+	s.mu.Lock()
+	fn(&s.stats)
+	s.mu.Unlock()
+}
+
+// Run publishes queued events until ctx is cancelled, then publishes
+// whatever is still queued.
+func (s *NATSSink) Run(ctx context.Context) {
+	defer close(s.done)
+	s.log.Info("NATS event streaming started",
+		zap.String("address", s.addr),
+		zap.String("subject", s.cfg.Subject),
+		zap.Int("batch_size", s.cfg.BatchSize),
+		zap.String("compression", s.cfg.Compression),
+	)
+
+	ticker := time.NewTicker(time.Duration(s.cfg.FlushMS) * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.drain()
+			s.closeConn()
+			st := s.Stats()
+			s.log.Info("NATS event streaming stopped",
+				zap.Uint64("published", st.Published),
+				zap.Uint64("shed", st.Shed),
+				zap.Uint64("dropped", st.Dropped),
+				zap.Uint64("errors", st.Errors),
+			)
+			return
+		case item := <-s.queue:
+			s.add(item)
+			if len(s.batch) >= s.cfg.BatchSize {
+				s.flush(ctx)
+			}
+		case <-ticker.C:
+			s.flush(ctx)
+		}
+	}
+}
+
+// Done returns a channel that is closed once Run has returned.
+func (s *NATSSink) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *NATSSink) add(item queuedEvent) {
+	rec, err := json.Marshal(NewRecord(&item.ev, item.info, item.at))
+	if err != nil {
+		return
+	}
+	s.batch = append(s.batch, rec)
+}
+
+// flush publishes the current batch, retrying until it succeeds or ctx is
+// cancelled. The queue is not drained meanwhile, which is what pushes
+// backpressure onto Handle.
+func (s *NATSSink) flush(ctx context.Context) {
+	for len(s.batch) > 0 {
+		err := s.publish(s.batch)
+		if err == nil {
+			s.batch = s.batch[:0]
+			return
+		}
+		s.fail(err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(natsReconnectDelay):
+		}
+	}
+}
+
+// drain makes one attempt to publish the current batch and the queue.
+func (s *NATSSink) drain() {
+	for {
+		empty := false
+		select {
+		case item := <-s.queue:
+			s.add(item)
+		default:
+			empty = true
+		}
+		if len(s.batch) >= s.cfg.BatchSize || (empty && len(s.batch) > 0) {
+			if err := s.publish(s.batch); err != nil {
+				s.fail(err)
+				lost := uint64(len(s.batch) + len(s.queue))
+				s.count(func(st *NATSStats) { st.Dropped += lost })
+				s.log.Warn("discarding queued events", zap.Uint64("events", lost))
+				return
+			}
+			s.batch = s.batch[:0]
+		}
+		if empty {
+			return
+		}
+	}
+}
+
+func (s *NATSSink) fail(err error) {
+	s.count(func(st *NATSStats) { st.Errors++ })
+	if !s.failing {
+		s.log.Warn("NATS event streaming failing", zap.String("address", s.addr), zap.Error(err))
+		s.failing = true
+	}
+}
+
+// publish sends batch, connecting first if needed.
+func (s *NATSSink) publish(batch [][]byte) error {
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if err := s.publishSplit(batch); err != nil {
+		s.closeConn()
+		return err
+	}
+	if s.failing {
+		s.log.Info("NATS event streaming recovered", zap.String("address", s.addr))
+		s.failing = false
+	}
+	return nil
+}
+
+// publishSplit publishes batch as one message, halving it until each
+// message fits the server's payload limit. A single record that does not
+// fit is discarded.
+func (s *NATSSink) publishSplit(batch [][]byte) error {
+	payload := s.encode(batch)
+	header := s.header()
+	if len(header)+len(payload) > s.conn.maxPayload() {
+		if len(batch) == 1 {
+			s.count(func(st *NATSStats) { st.Errors++ })
+			return nil
+		}
+		mid := len(batch) / 2
+		if err := s.publishSplit(batch[:mid]); err != nil {
+			return err
+		}
+		return s.publishSplit(batch[mid:])
+	}
+
+	if err := s.conn.publish(s.cfg.Subject, header, payload); err != nil {
+		return err
+	}
+	s.count(func(st *NATSStats) {
+		st.Published += uint64(len(batch))
+		st.Messages++
+		st.Bytes += uint64(len(payload))
+	})
+	return nil
+}
+
+// encode renders batch as newline-delimited JSON.
+func (s *NATSSink) encode(batch [][]byte) []byte {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	if s.zw != nil {
+		s.zw.Reset(&buf)
+		w = s.zw
+	}
+	for _, rec := range batch {
+		w.Write(rec)
+		w.Write([]byte{'\n'})
+	}
+	if s.zw != nil {
+		s.zw.Close()
+	}
+	return buf.Bytes()
+}
+
+// header returns the message headers, or "" if the server does not
+// support them.
+func (s *NATSSink) header() string {
+	if !s.conn.info.Headers {
+		return ""
+	}
+	h := "NATS/1.0\r\nContent-Type: application/x-ndjson\r\n"
+	if s.zw != nil {
+		h += "Content-Encoding: gzip\r\n"
+	}
+	return h + "\r\n"
+}
+
+func (s *NATSSink) dial() (*natsConn, error) {
+	var tlsCfg *tls.Config
+	if s.useTLS {
+		tlsCfg = s.tls
+	}
+	return dialNATS(s.addr, tlsCfg, s.tls, natsConnectOpts{
+		Name:     "ddos-scrubber",
+		Lang:     "go",
+		Version:  cefVersion,
+		Protocol: 1,
+		Headers:  true,
+		Token:    s.cfg.Token,
+		User:     s.cfg.User,
+		Pass:     s.cfg.Password,
+	})
+}
+
+func (s *NATSSink) closeConn() {
+	if s.conn != nil {
+		s.conn.close()
+		s.conn = nil
+	}
+}
+
+// natsInfo is the subset of the server INFO message the sink uses.
+type natsInfo struct {
+	ServerID    string `json:"server_id"`
+	MaxPayload  int    `json:"max_payload"`
+	Headers     bool   `json:"headers"`
+	TLSRequired bool   `json:"tls_required"`
+}
+
+// natsConnectOpts is the CONNECT message.
+type natsConnectOpts struct {
+	Verbose     bool   `json:"verbose"`
+	Pedantic    bool   `json:"pedantic"`
+	TLSRequired bool   `json:"tls_required"`
+	Name        string `json:"name"`
+	Lang        string `json:"lang"`
+	Version     string `json:"version"`
+	Protocol    int    `json:"protocol"`
+	Headers     bool   `json:"headers"`
+	Token       string `json:"auth_token,omitempty"`
+	User        string `json:"user,omitempty"`
+	Pass        string `json:"pass,omitempty"`
+}
+
+// natsConn is a publish-only NATS client connection. A reader goroutine
+// answers server PINGs and reports the error that ends the connection.
+type natsConn struct {
+	conn net.Conn
+	info natsInfo
+	errc chan error
+
+	wmu sync.Mutex // guards w
+	w   *bufio.Writer
+}
+
+// dialNATS connects and completes the CONNECT/PING handshake. TLS is
+// negotiated with required, or with fallback if the server asks for it.
+func dialNATS(addr string, required, fallback *tls.Config, opts natsConnectOpts) (*natsConn, error) {
+	raw, err := (&net.Dialer{Timeout: natsDialTimeout}).Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	raw.SetDeadline(time.Now().Add(natsDialTimeout))
+	conn := raw
+	r := bufio.NewReader(conn)
+
+	line, err := readNATSLine(r)
+	if err != nil {
+		raw.Close()
+		return nil, fmt.Errorf("reading INFO: %w", err)
+	}
+	var info natsInfo
+	if !strings.HasPrefix(line, "INFO ") || json.Unmarshal([]byte(line[5:]), &info) != nil {
+		raw.Close()
+		return nil, fmt.Errorf("unexpected greeting %q", line)
+	}
+
+	tlsCfg := required
+	if tlsCfg == nil && info.TLSRequired {
+		tlsCfg = fallback
+	}
+	if tlsCfg != nil {
+		tc := tls.Client(raw, tlsCfg)
+		if err := tc.Handshake(); err != nil {
+			raw.Close()
+			return nil, fmt.Errorf("TLS handshake: %w", err)
+		}
+		conn = tc
+		r = bufio.NewReader(conn)
+		opts.TLSRequired = true
+	}
+
+	c := &natsConn{conn: conn, info: info, errc: make(chan error, 1), w: bufio.NewWriter(conn)}
+	connect, _ := json.Marshal(opts)
+	fmt.Fprintf(c.w, "CONNECT %s\r\nPING\r\n", connect)
+	if err := c.w.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The server answers the PING once CONNECT has been accepted.
+	for {
+		line, err := readNATSLine(r)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("reading handshake: %w", err)
+		}
+		if line == "PONG" {
+			break
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			conn.Close()
+			return nil, fmt.Errorf("server rejected connection: %s", strings.TrimSpace(line[4:]))
+		}
+	}
+
+	conn.SetDeadline(time.Time{})
+	go c.readLoop(r)
+	return c, nil
+}
+
+func (c *natsConn) readLoop(r *bufio.Reader) {
+	for {
+		line, err := readNATSLine(r)
+		if err != nil {
+			c.errc <- err
+			return
+		}
+		switch {
+		case line == "PING":
+			c.wmu.Lock()
+			c.conn.SetWriteDeadline(time.Now().Add(natsWriteTimeout))
+			c.w.WriteString("PONG\r\n")
+			c.w.Flush()
+			c.wmu.Unlock()
+		case strings.HasPrefix(line, "-ERR"):
+			c.errc <- fmt.Errorf("server error: %s", strings.TrimSpace(line[4:]))
+			return
+		}
+	}
+}
+
+// publish sends one message, with headers if header is not empty.
+func (c *natsConn) publish(subject, header string, payload []byte) error {
+	select {
+	case err := <-c.errc:
+		return err
+	default:
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(natsWriteTimeout))
+	if header != "" {
+		fmt.Fprintf(c.w, "HPUB %s %d %d\r\n%s", subject, len(header), len(header)+len(payload), header)
+	} else {
+		fmt.Fprintf(c.w, "PUB %s %d\r\n", subject, len(payload))
+	}
+	c.w.Write(payload)
+	c.w.WriteString("\r\n")
+	return c.w.Flush()
+}
+
+func (c *natsConn) maxPayload() int {
+	if c.info.MaxPayload > 0 {
+		return c.info.MaxPayload
+	}
+	return natsDefaultMaxPayload
+}
+
+func (c *natsConn) close() {
+	c.conn.Close()
+}
+
+func readNATSLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
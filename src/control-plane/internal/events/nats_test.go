@@ -0,0 +1,215 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type natsMsg struct {
+	subject string
+	header  string
+	records []Record
+}
+
+// fakeNATS accepts one client, completes the handshake and decodes the
+// messages it publishes.
+func fakeNATS(t *testing.T, info string) (string, <-chan natsMsg) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	msgs := make(chan natsMsg, 16)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "INFO %s\r\n", info)
+		r := bufio.NewReader(conn)
+		for {
+			line, err := readNATSLine(r)
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			var hdrLen, total int
+			switch fields[0] {
+			case "PING":
+				io.WriteString(conn, "PONG\r\n")
+				continue
+			case "HPUB":
+				fmt.Sscan(fields[2]+" "+fields[3], &hdrLen, &total)
+			case "PUB":
+				fmt.Sscan(fields[2], &total)
+			default:
+				continue
+			}
+			buf := make([]byte, total+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			msg := natsMsg{subject: fields[1], header: string(buf[:hdrLen])}
+			var body io.Reader = bytes.NewReader(buf[hdrLen:total])
+			if strings.Contains(msg.header, "Content-Encoding: gzip") {
+				if body, err = gzip.NewReader(body); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			dec := json.NewDecoder(body)
+			for dec.More() {
+				var rec Record
+				if err := dec.Decode(&rec); err != nil {
+					t.Error(err)
+					return
+				}
+				msg.records = append(msg.records, rec)
+			}
+			msgs <- msg
+		}
+	}()
+	return ln.Addr().String(), msgs
+}
+
+func newTestNATSSink(t *testing.T, cfg NATSConfig) *NATSSink {
+	t.Helper()
+	if cfg.Subject == "" {
+		cfg.Subject = "ddos.events"
+	}
+	s, err := NewNATSSink(zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNewRecord(t *testing.T) {
+	ev := testEvent()
+	ev.CountryCode = 'D'<<8 | 'E'
+	rec := NewRecord(ev, Info{ASN: 64496, Hostname: "host.example"}, testTime)
+	if rec.SrcIP != "198.51.100.7" || rec.SrcPort != 12345 || rec.DstPort != 80 ||
+		rec.Action != "drop" || rec.AttackType != "syn_flood" || rec.CountryCode != "DE" ||
+		rec.ASN != 64496 || rec.Hostname != "host.example" {
+		t.Errorf("record = %+v", rec)
+	}
+}
+
+func TestNATSBatching(t *testing.T) {
+	addr, msgs := fakeNATS(t, `{"server_id":"test","headers":true,"max_payload":1048576}`)
+	s := newTestNATSSink(t, NATSConfig{URL: "nats://" + addr, BatchSize: 3, FlushMS: 10, Compression: CompressionGzip})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.Run(ctx)
+	for i := 0; i < 7; i++ {
+		s.Handle(testEvent(), Info{})
+	}
+
+	var got int
+	for got < 7 {
+		select {
+		case m := <-msgs:
+			if m.subject != "ddos.events" || len(m.records) > 3 {
+				t.Errorf("message on %s with %d records", m.subject, len(m.records))
+			}
+			if !strings.Contains(m.header, "Content-Type: application/x-ndjson") {
+				t.Errorf("header = %q", m.header)
+			}
+			if m.records[0].SrcIP != "198.51.100.7" {
+				t.Errorf("record = %+v", m.records[0])
+			}
+			got += len(m.records)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received %d of 7 events", got)
+		}
+	}
+	cancel()
+	<-s.Done()
+
+	if st := s.Stats(); st.Published != 7 || st.Messages < 3 || st.Errors != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestNATSSplitsOversizedBatches(t *testing.T) {
+	// No header support and a payload limit that fits two records.
+	addr, msgs := fakeNATS(t, `{"server_id":"test","max_payload":700}`)
+	s := newTestNATSSink(t, NATSConfig{URL: addr, BatchSize: 8, FlushMS: 1000, Compression: CompressionNone})
+	for i := 0; i < 8; i++ {
+		s.Handle(testEvent(), Info{})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Run(ctx) // publishes the queue on the way out
+
+	var got int
+	for got < 8 {
+		select {
+		case m := <-msgs:
+			if m.header != "" || len(m.records) > 2 {
+				t.Errorf("message with header %q and %d records", m.header, len(m.records))
+			}
+			got += len(m.records)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received %d of 8 events", got)
+		}
+	}
+}
+
+func TestNATSBackpressure(t *testing.T) {
+	s := newTestNATSSink(t, NATSConfig{URL: "127.0.0.1:1", BatchSize: 1, QueueSize: 4})
+	pass := testEvent()
+	pass.Action = 0
+
+	for i := 0; i < 4; i++ {
+		s.Handle(pass, Info{}) // the fourth is shed at 75% full
+	}
+	s.Handle(testEvent(), Info{})
+	s.Handle(testEvent(), Info{}) // queue full
+
+	if st := s.Stats(); st.Shed != 1 || st.Dropped != 1 || len(s.queue) != 4 {
+		t.Errorf("stats = %+v, queued = %d", st, len(s.queue))
+	}
+}
+
+func TestNATSValidate(t *testing.T) {
+	valid := NATSConfig{URL: "tls://nats.example.com", Subject: "ddos.events", BatchSize: 100,
+		FlushMS: 100, Compression: CompressionGzip, QueueSize: 1000}
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if addr, useTLS, _ := natsAddress(valid.URL); addr != "nats.example.com:4222" || !useTLS {
+		t.Errorf("natsAddress = %s, %v", addr, useTLS)
+	}
+
+	for i, mutate := range []func(*NATSConfig){
+		func(c *NATSConfig) { c.URL = "" },
+		func(c *NATSConfig) { c.URL = "kafka://broker:9092" },
+		func(c *NATSConfig) { c.Subject = "ddos.*" },
+		func(c *NATSConfig) { c.Compression = "snappy" },
+		func(c *NATSConfig) { c.BatchSize = 0 },
+		func(c *NATSConfig) { c.QueueSize = 10 },
+		func(c *NATSConfig) { c.Token, c.User = "t", "u" },
+	} {
+		c := valid
+		mutate(&c)
+		if err := c.Validate(); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/ringbuf"
@@ -16,6 +17,10 @@ import (
 // Handler is called for each event read from the ring buffer.
 type Handler func(event *bpf.Event)
 
+// EnrichedHandler is called for each event together with the context
+// added by the enrichment stage.
+type EnrichedHandler func(event *bpf.Event, info Info)
+
 // Reader reads events from the BPF ring buffer.
 type Reader struct {
 	log       *zap.Logger
@@ -23,6 +28,17 @@ type Reader struct {
 
 	mu       sync.RWMutex
 	handlers []Handler
+	enriched []EnrichedHandler
+	enricher *Enricher
+
+	// Sampling and aggregation, set up by SetSampling
+	sampler    *sampler
+	aggregator *aggregator
+	window     time.Duration
+	flushMu    sync.Mutex
+	counters   samplingCounters
+
+	ring ringMonitor
 }
 
 // NewReader creates a new event reader for the given events ring buffer map.
@@ -40,6 +56,22 @@ func (r *Reader) OnEvent(h Handler) {
 	r.mu.Unlock()
 }
 
+// OnEnrichedEvent registers a handler to receive events with their
+// enrichment context.
+func (r *Reader) OnEnrichedEvent(h EnrichedHandler) {
+	r.mu.Lock()
+	r.enriched = append(r.enriched, h)
+	r.mu.Unlock()
+}
+
+// SetEnricher installs the enrichment stage. Events are annotated before
+// any handler sees them.
+func (r *Reader) SetEnricher(en *Enricher) {
+	r.mu.Lock()
+	r.enricher = en
+	r.mu.Unlock()
+}
+
 // Run starts reading events. Blocks until context is cancelled.
 func (r *Reader) Run(ctx context.Context) error {
 	rd, err := ringbuf.NewReader(r.eventsMap)
@@ -47,44 +79,72 @@ func (r *Reader) Run(ctx context.Context) error {
 		return err
 	}
 	defer rd.Close()
+	r.ring.size.Store(int64(rd.BufferSize()))
 
 	r.log.Info("event reader started")
 
-	// Close reader when context is done
+	// When the context is done, flush rather than close so that events
+	// already in the ring are still dispatched before Run returns.
 	go func() {
 		<-ctx.Done()
-		rd.Close()
+		rd.Flush()
 	}()
 
+	if r.aggregator != nil {
+		stop, flushed := make(chan struct{}), make(chan struct{})
+		go r.flushEvery(stop, flushed)
+		defer func() {
+			close(stop)
+			<-flushed
+		}()
+	}
+
+	drained := 0
 	for {
 		record, err := rd.Read()
 		if err != nil {
-			if errors.Is(err, ringbuf.ErrClosed) {
-				r.log.Info("event reader stopped")
+			if errors.Is(err, ringbuf.ErrFlushed) || errors.Is(err, ringbuf.ErrClosed) {
+				r.log.Info("event reader stopped", zap.Int("drained", drained))
 				return nil
 			}
 			r.log.Warn("error reading event", zap.Error(err))
 			continue
 		}
 
+		r.ring.observeBacklog(record.Remaining)
+
 		event, err := parseEvent(record.RawSample)
 		if err != nil {
 			r.log.Warn("error parsing event", zap.Error(err))
 			continue
 		}
 
-		r.dispatch(event)
+		r.admit(event)
+		if ctx.Err() != nil {
+			drained++
+		}
 	}
 }
 
-func (r *Reader) dispatch(event *bpf.Event) {
+// dispatch passes an event standing for count ring buffer events to the
+// handlers.
+func (r *Reader) dispatch(event *bpf.Event, count uint64) {
 	r.mu.RLock()
-	handlers := r.handlers
+	handlers, enriched, enricher := r.handlers, r.enriched, r.enricher
 	r.mu.RUnlock()
 
+	var info Info
+	if enricher != nil {
+		info = enricher.Enrich(event)
+	}
+	info.Count = count
+	r.counters.dispatched.Add(1)
 	for _, h := range handlers {
 		h(event)
 	}
+	for _, h := range enriched {
+		h(event, info)
+	}
 }
 
 func parseEvent(data []byte) (*bpf.Event, error) {
@@ -95,7 +95,7 @@ func TestHandlerDispatch(t *testing.T) {
 		Action:     1,
 	}
 
-	r.dispatch(event)
+	r.dispatch(event, 1)
 
 	if received == nil {
 		t.Fatal("handler was not called")
@@ -118,7 +118,7 @@ func TestMultipleHandlers(t *testing.T) {
 		})
 	}
 
-	r.dispatch(&bpf.Event{})
+	r.dispatch(&bpf.Event{}, 1)
 
 	if count != 5 {
 		t.Errorf("handler call count = %d, want 5", count)
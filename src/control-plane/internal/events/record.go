@@ -0,0 +1,71 @@
+package events
+
+import (
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+)
+
+// Record is the JSON form of an event published to streaming sinks. Keys
+// match the events returned by the API.
+type Record struct {
+	Time            time.Time `json:"time"`
+	TimestampNS     uint64    `json:"timestampNs"`
+	SrcIP           string    `json:"srcIp"`
+	DstIP           string    `json:"dstIp"`
+	SrcPort         uint16    `json:"srcPort"`
+	DstPort         uint16    `json:"dstPort"`
+	Protocol        uint8     `json:"protocol"`
+	AttackType      string    `json:"attackType"`
+	Action          string    `json:"action"`
+	DropReason      string    `json:"dropReason"`
+	PPSEstimate     uint64    `json:"ppsEstimate"`
+	BPSEstimate     uint64    `json:"bpsEstimate"`
+	ReputationScore uint32    `json:"reputationScore"`
+	CountryCode     string    `json:"countryCode,omitempty"`
+	EscalationLevel uint8     `json:"escalationLevel"`
+	ASN             uint32    `json:"asn,omitempty"`
+	ASOrg           string    `json:"asOrg,omitempty"`
+	Hostname        string    `json:"hostname,omitempty"`
+	Count           uint64    `json:"count,omitempty"` // Set when sampling or aggregation folded events
+}
+
+// NewRecord converts an enriched event read at the given time.
+func NewRecord(ev *bpf.Event, info Info, at time.Time) Record {
+	r := Record{
+		Time:            at.UTC(),
+		TimestampNS:     ev.TimestampNS,
+		SrcIP:           bpf.U32BEToIP(ev.SrcIP).String(),
+		DstIP:           bpf.U32BEToIP(ev.DstIP).String(),
+		SrcPort:         ev.SrcPort>>8 | ev.SrcPort<<8,
+		DstPort:         ev.DstPort>>8 | ev.DstPort<<8,
+		Protocol:        ev.Protocol,
+		AttackType:      bpf.AttackTypeName(ev.AttackType),
+		Action:          "pass",
+		DropReason:      bpf.DropReasonName(ev.DropReason),
+		PPSEstimate:     ev.PPSEstimate,
+		BPSEstimate:     ev.BPSEstimate,
+		ReputationScore: ev.ReputationScore,
+		EscalationLevel: ev.EscalationLevel,
+		ASN:             info.ASN,
+		ASOrg:           info.ASOrg,
+		Hostname:        info.Hostname,
+	}
+	if ev.Action == 1 {
+		r.Action = "drop"
+	}
+	if n := info.Events(); n > 1 {
+		r.Count = n
+	}
+	if ev.CountryCode != 0 {
+		r.CountryCode = string([]byte{byte(ev.CountryCode >> 8), byte(ev.CountryCode)})
+	}
+	return r
+}
+
+// queuedEvent is an event waiting in a sink queue.
+type queuedEvent struct {
+	ev   bpf.Event
+	info Info
+	at   time.Time
+}
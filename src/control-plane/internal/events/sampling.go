@@ -0,0 +1,249 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+)
+
+// SamplingConfig controls the stage that thins out events between the ring
+// buffer and the handlers, so a flood that fills the ring does not swamp
+// enrichment, the API and the sinks with identical events.
+type SamplingConfig struct {
+	Enabled           bool           `yaml:"enabled"`
+	SampleRate        int            `yaml:"sample_rate"`         // Keep 1 in N events per attack type and source (1 = all)
+	SampleRates       map[string]int `yaml:"sample_rates"`        // Per attack type overrides of sample_rate
+	AggregateWindowMS int            `yaml:"aggregate_window_ms"` // Collapse identical src/dst/reason events (0 = off)
+	MaxKeys           int            `yaml:"max_keys"`            // Sources or aggregates tracked at once
+}
+
+// Validate checks the sampling configuration.
+func (c SamplingConfig) Validate() error {
+	if c.SampleRate < 1 {
+		return fmt.Errorf("sample_rate must be at least 1")
+	}
+	for name, rate := range c.SampleRates {
+		if _, ok := attackTypeByName(name); !ok {
+			return fmt.Errorf("sample_rates: unknown attack type %q", name)
+		}
+		if rate < 1 {
+			return fmt.Errorf("sample_rates[%s] must be at least 1", name)
+		}
+	}
+	if c.AggregateWindowMS < 0 || c.AggregateWindowMS > 60000 {
+		return fmt.Errorf("aggregate_window_ms must be between 0 and 60000")
+	}
+	if c.MaxKeys <= 0 {
+		return fmt.Errorf("max_keys must be positive")
+	}
+	return nil
+}
+
+func attackTypeByName(name string) (uint8, bool) {
+	for t := 0; t < 256; t++ {
+		if bpf.AttackTypeName(uint8(t)) == name {
+			return uint8(t), true
+		}
+	}
+	return 0, false
+}
+
+// SamplingStats counts events through the sampling stage.
+type SamplingStats struct {
+	Read       uint64 `json:"read"`       // Parsed from the ring buffer
+	SampledOut uint64 `json:"sampledOut"` // Discarded by 1-in-N sampling
+	Aggregated uint64 `json:"aggregated"` // Folded into another event's count
+	Dispatched uint64 `json:"dispatched"` // Passed to handlers
+}
+
+type samplingCounters struct {
+	read, sampledOut, aggregated, dispatched atomic.Uint64
+}
+
+func (c *samplingCounters) snapshot() SamplingStats {
+	return SamplingStats{
+		Read:       c.read.Load(),
+		SampledOut: c.sampledOut.Load(),
+		Aggregated: c.aggregated.Load(),
+		Dispatched: c.dispatched.Load(),
+	}
+}
+
+type sampleKey struct {
+	src    uint32
+	attack uint8
+}
+
+// sampler keeps the first of every N events per attack type and source.
+// It is only used from the reader goroutine.
+type sampler struct {
+	rates   [256]uint64
+	seen    map[sampleKey]uint64
+	maxKeys int
+}
+
+func newSampler(cfg SamplingConfig) *sampler {
+	s := &sampler{seen: make(map[sampleKey]uint64), maxKeys: cfg.MaxKeys}
+	for i := range s.rates {
+		s.rates[i] = uint64(cfg.SampleRate)
+	}
+	for name, rate := range cfg.SampleRates {
+		if t, ok := attackTypeByName(name); ok {
+			s.rates[t] = uint64(rate)
+		}
+	}
+	return s
+}
+
+// admit reports whether ev is kept and, if so, how many events it stands
+// for. When too many sources are tracked the counts start over, so a
+// spoofed flood cannot grow the table without bound.
+func (s *sampler) admit(ev *bpf.Event) (uint64, bool) {
+	n := s.rates[ev.AttackType]
+	if n <= 1 {
+		return 1, true
+	}
+	k := sampleKey{src: ev.SrcIP, attack: ev.AttackType}
+	seen, ok := s.seen[k]
+	if !ok && len(s.seen) >= s.maxKeys {
+		clear(s.seen)
+	}
+	s.seen[k] = seen + 1
+	if seen%n != 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+type aggregateKey struct {
+	src, dst       uint32
+	attack, reason uint8
+	action         uint8
+}
+
+// aggregate is the latest event of a key with the number of events
+// collapsed into it.
+type aggregate struct {
+	ev    bpf.Event
+	count uint64
+}
+
+// aggregator collapses events with the same source, destination, attack
+// type, action and drop reason until the next flush.
+type aggregator struct {
+	mu      sync.Mutex
+	pending map[aggregateKey]*aggregate
+	order   []aggregateKey // First-seen order, so flushes keep arrival order
+	maxKeys int
+}
+
+func newAggregator(maxKeys int) *aggregator {
+	return &aggregator{pending: make(map[aggregateKey]*aggregate), maxKeys: maxKeys}
+}
+
+// add folds ev into its aggregate and reports whether it was merged into
+// an existing one, and whether the aggregator is now full.
+func (a *aggregator) add(ev *bpf.Event, count uint64) (merged, full bool) {
+	k := aggregateKey{
+		src:    ev.SrcIP,
+		dst:    ev.DstIP,
+		attack: ev.AttackType,
+		reason: ev.DropReason,
+		action: ev.Action,
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if agg, ok := a.pending[k]; ok {
+		agg.ev = *ev
+		agg.count += count
+		return true, false
+	}
+	a.pending[k] = &aggregate{ev: *ev, count: count}
+	a.order = append(a.order, k)
+	return false, len(a.order) >= a.maxKeys
+}
+
+// take returns the pending aggregates in first-seen order and starts a new
+// window.
+func (a *aggregator) take() []aggregate {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]aggregate, 0, len(a.order))
+	for _, k := range a.order {
+		out = append(out, *a.pending[k])
+	}
+	clear(a.pending)
+	a.order = a.order[:0]
+	return out
+}
+
+// SetSampling installs the sampling and aggregation stage. It must be
+// called before Run.
+func (r *Reader) SetSampling(cfg SamplingConfig) {
+	r.sampler = newSampler(cfg)
+	if cfg.AggregateWindowMS > 0 {
+		r.aggregator = newAggregator(cfg.MaxKeys)
+		r.window = time.Duration(cfg.AggregateWindowMS) * time.Millisecond
+	}
+}
+
+// SamplingStats returns the sampling stage counters.
+func (r *Reader) SamplingStats() SamplingStats {
+	return r.counters.snapshot()
+}
+
+// admit passes an event read from the ring through sampling and
+// aggregation, dispatching whatever comes out.
+func (r *Reader) admit(event *bpf.Event) {
+	r.counters.read.Add(1)
+	count := uint64(1)
+	if r.sampler != nil {
+		n, ok := r.sampler.admit(event)
+		if !ok {
+			r.counters.sampledOut.Add(1)
+			return
+		}
+		count = n
+	}
+	if r.aggregator == nil {
+		r.dispatch(event, count)
+		return
+	}
+	merged, full := r.aggregator.add(event, count)
+	if merged {
+		r.counters.aggregated.Add(1)
+	}
+	if full {
+		r.flush()
+	}
+}
+
+// flush dispatches the pending aggregates. flushMu keeps flushes from the
+// window ticker and from a full aggregator in order.
+func (r *Reader) flush() {
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+	for _, agg := range r.aggregator.take() {
+		r.dispatch(&agg.ev, agg.count)
+	}
+}
+
+// flushEvery flushes the aggregator each window until stop is closed, then
+// once more so nothing pending is lost.
+func (r *Reader) flushEvery(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(r.window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			r.flush()
+			return
+		case <-ticker.C:
+			r.flush()
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+)
+
+func testSamplingConfig() SamplingConfig {
+	return SamplingConfig{Enabled: true, SampleRate: 1, MaxKeys: 100}
+}
+
+func TestSamplerOneInN(t *testing.T) {
+	cfg := testSamplingConfig()
+	cfg.SampleRates = map[string]int{"syn_flood": 4}
+	s := newSampler(cfg)
+
+	syn := &bpf.Event{SrcIP: 1, AttackType: bpf.AttackSYNFlood}
+	kept := 0
+	for i := 0; i < 10; i++ {
+		n, ok := s.admit(syn)
+		if ok {
+			kept++
+			if n != 4 {
+				t.Errorf("kept event count = %d, want 4", n)
+			}
+		}
+	}
+	if kept != 3 { // events 0, 4 and 8
+		t.Errorf("kept %d of 10 events, want 3", kept)
+	}
+
+	// Another source is sampled independently and its first event kept.
+	if _, ok := s.admit(&bpf.Event{SrcIP: 2, AttackType: bpf.AttackSYNFlood}); !ok {
+		t.Error("first event of a new source was sampled out")
+	}
+	// Attack types without an override use sample_rate.
+	for i := 0; i < 3; i++ {
+		if n, ok := s.admit(&bpf.Event{SrcIP: 1, AttackType: bpf.AttackUDPFlood}); !ok || n != 1 {
+			t.Errorf("udp event %d = (%d, %v), want (1, true)", i, n, ok)
+		}
+	}
+}
+
+func TestSamplerMaxKeys(t *testing.T) {
+	cfg := testSamplingConfig()
+	cfg.SampleRate = 10
+	cfg.MaxKeys = 2
+	s := newSampler(cfg)
+
+	for src := uint32(1); src <= 5; src++ {
+		s.admit(&bpf.Event{SrcIP: src})
+	}
+	if len(s.seen) > 2 {
+		t.Errorf("tracked %d sources, want at most 2", len(s.seen))
+	}
+}
+
+func TestReaderAggregation(t *testing.T) {
+	cfg := testSamplingConfig()
+	cfg.AggregateWindowMS = 1000
+	r := &Reader{}
+	r.SetSampling(cfg)
+
+	var got []Info
+	var srcs []uint32
+	r.OnEnrichedEvent(func(ev *bpf.Event, info Info) {
+		got = append(got, info)
+		srcs = append(srcs, ev.SrcIP)
+	})
+
+	for i := 0; i < 5; i++ {
+		r.admit(&bpf.Event{SrcIP: 1, DstIP: 9, DropReason: bpf.DropSYNFlood, PPSEstimate: uint64(i)})
+	}
+	r.admit(&bpf.Event{SrcIP: 2, DstIP: 9, DropReason: bpf.DropSYNFlood})
+	r.admit(&bpf.Event{SrcIP: 1, DstIP: 9, DropReason: bpf.DropRateLimit})
+	if len(got) != 0 {
+		t.Fatalf("dispatched %d events before the window closed", len(got))
+	}
+
+	r.flush()
+	if len(got) != 3 {
+		t.Fatalf("dispatched %d aggregates, want 3", len(got))
+	}
+	if got[0].Count != 5 || srcs[0] != 1 {
+		t.Errorf("first aggregate = src %d count %d, want src 1 count 5", srcs[0], got[0].Count)
+	}
+	if got[1].Count != 1 || got[2].Count != 1 {
+		t.Errorf("distinct events counts = %d, %d, want 1, 1", got[1].Count, got[2].Count)
+	}
+
+	stats := r.SamplingStats()
+	want := SamplingStats{Read: 7, Aggregated: 4, Dispatched: 3}
+	if stats != want {
+		t.Errorf("SamplingStats() = %+v, want %+v", stats, want)
+	}
+
+	got = nil
+	r.flush()
+	if len(got) != 0 {
+		t.Errorf("empty window dispatched %d events", len(got))
+	}
+}
+
+func TestReaderAggregationFlushesWhenFull(t *testing.T) {
+	cfg := testSamplingConfig()
+	cfg.AggregateWindowMS = 1000
+	cfg.MaxKeys = 2
+	r := &Reader{}
+	r.SetSampling(cfg)
+
+	dispatched := 0
+	r.OnEvent(func(ev *bpf.Event) { dispatched++ })
+
+	r.admit(&bpf.Event{SrcIP: 1})
+	r.admit(&bpf.Event{SrcIP: 2})
+	if dispatched != 2 {
+		t.Errorf("dispatched %d events after filling the aggregator, want 2", dispatched)
+	}
+}
+
+func TestSampledCountsAggregate(t *testing.T) {
+	cfg := testSamplingConfig()
+	cfg.SampleRate = 10
+	cfg.AggregateWindowMS = 1000
+	r := &Reader{}
+	r.SetSampling(cfg)
+
+	var count uint64
+	r.OnEnrichedEvent(func(ev *bpf.Event, info Info) { count += info.Count })
+
+	for i := 0; i < 100; i++ {
+		r.admit(&bpf.Event{SrcIP: 1, AttackType: bpf.AttackUDPFlood})
+	}
+	r.flush()
+	if count != 100 {
+		t.Errorf("dispatched count = %d, want 100", count)
+	}
+	if stats := r.SamplingStats(); stats.SampledOut != 90 || stats.Aggregated != 9 {
+		t.Errorf("SamplingStats() = %+v, want 90 sampled out and 9 aggregated", stats)
+	}
+}
+
+func TestSamplingConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *SamplingConfig)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(c *SamplingConfig) {}},
+		{name: "zero sample rate", modify: func(c *SamplingConfig) { c.SampleRate = 0 }, wantErr: true},
+		{name: "override", modify: func(c *SamplingConfig) { c.SampleRates = map[string]int{"udp_flood": 100} }},
+		{name: "zero override", modify: func(c *SamplingConfig) { c.SampleRates = map[string]int{"udp_flood": 0} }, wantErr: true},
+		{name: "negative window", modify: func(c *SamplingConfig) { c.AggregateWindowMS = -1 }, wantErr: true},
+		{name: "no keys", modify: func(c *SamplingConfig) { c.MaxKeys = 0 }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testSamplingConfig()
+			tt.modify(&cfg)
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,501 @@
+package events
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+// Syslog transports and message formats.
+const (
+	TransportUDP = "udp"
+	TransportTCP = "tcp"
+	TransportTLS = "tls"
+
+	FormatRFC5424 = "rfc5424"
+	FormatCEF     = "cef"
+)
+
+const (
+	syslogQueueSize      = 4096
+	syslogDialTimeout    = 5 * time.Second
+	syslogWriteTimeout   = 5 * time.Second
+	syslogReconnectDelay = 5 * time.Second
+	defaultSyslogRate    = 1000
+
+	// Structured data ID under the documentation enterprise number.
+	syslogSDID = "ddos@32473"
+
+	cefVendor  = "ebpf-ddos-scrubber"
+	cefProduct = "scrubber"
+	cefVersion = "0.1.0"
+)
+
+// Event fields, in the order they are emitted. SyslogConfig.Fields renames
+// them per output. Enrichment fields are omitted while empty.
+var syslogFields = []string{
+	"src_ip", "dst_ip", "src_port", "dst_port", "protocol",
+	"action", "attack", "reason", "pps", "bps",
+	"country", "asn", "as_org", "reputation", "hostname", "count",
+}
+
+// Default keys for CEF output; RFC 5424 uses the field names.
+var cefKeys = map[string]string{
+	"src_ip":     "src",
+	"dst_ip":     "dst",
+	"src_port":   "spt",
+	"dst_port":   "dpt",
+	"protocol":   "proto",
+	"action":     "act",
+	"attack":     "cat",
+	"reason":     "reason",
+	"pps":        "cn1",
+	"bps":        "cn2",
+	"country":    "cs1",
+	"as_org":     "cs2",
+	"asn":        "cs3",
+	"reputation": "cn3",
+	"hostname":   "shost",
+	"count":      "cnt",
+}
+
+// cefCustomKey matches CEF custom fields, which need a companion label.
+var cefCustomKey = regexp.MustCompile(`^c[sn][0-9]$`)
+
+// SyslogConfig controls forwarding of events to a syslog server.
+type SyslogConfig struct {
+	Enabled    bool              `yaml:"enabled"`
+	Address    string            `yaml:"address"`      // host:port of the syslog server
+	Transport  string            `yaml:"transport"`    // udp, tcp, or tls
+	Format     string            `yaml:"format"`       // rfc5424 or cef
+	Facility   int               `yaml:"facility"`     // Syslog facility (0-23)
+	AppName    string            `yaml:"app_name"`     // APP-NAME header field
+	Hostname   string            `yaml:"hostname"`     // HOSTNAME header field (default: hostname)
+	DropsOnly  bool              `yaml:"drops_only"`   // Forward only dropped packets
+	RatePerSec int               `yaml:"rate_per_sec"` // Message budget; excess events are discarded
+	Fields     map[string]string `yaml:"fields"`       // Field name -> output key; "" omits the field
+	TLS        SyslogTLSConfig   `yaml:"tls"`
+}
+
+// SyslogTLSConfig configures the tls transport.
+type SyslogTLSConfig struct {
+	CA                 string `yaml:"ca"`          // CA bundle (default: system roots)
+	ServerName         string `yaml:"server_name"` // Overrides the name verified in the certificate
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// Validate checks the syslog configuration.
+func (c SyslogConfig) Validate() error {
+	if c.Address == "" {
+		return fmt.Errorf("address is required")
+	}
+	switch c.Transport {
+	case TransportUDP, TransportTCP, TransportTLS:
+	default:
+		return fmt.Errorf("invalid transport %q (must be udp, tcp, or tls)", c.Transport)
+	}
+	switch c.Format {
+	case FormatRFC5424, FormatCEF:
+	default:
+		return fmt.Errorf("invalid format %q (must be rfc5424 or cef)", c.Format)
+	}
+	if c.Facility < 0 || c.Facility > 23 {
+		return fmt.Errorf("facility %d out of range (0-23)", c.Facility)
+	}
+	for name, key := range c.Fields {
+		if !knownField(name) {
+			return fmt.Errorf("unknown field %q", name)
+		}
+		if strings.ContainsAny(key, " =]\"") {
+			return fmt.Errorf("field %s: invalid key %q", name, key)
+		}
+	}
+	return nil
+}
+
+func knownField(name string) bool {
+	for _, f := range syslogFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SyslogStats counts forwarded and discarded events.
+type SyslogStats struct {
+	Sent        uint64 `json:"sent"`
+	RateLimited uint64 `json:"rateLimited"`
+	Dropped     uint64 `json:"dropped"` // Queue full
+	Errors      uint64 `json:"errors"`  // Connection or write failures
+}
+
+// SyslogSink forwards events to a remote syslog server as RFC 5424
+// messages or CEF records. Events are queued by Handle and written by Run,
+// so a slow or unreachable server never stalls the event reader; events
+// beyond the rate budget or the queue are discarded and counted.
+type SyslogSink struct {
+	log      *zap.Logger
+	cfg      SyslogConfig
+	hostname string
+	procID   string
+	keys     map[string]string // field -> output key
+	tls      *tls.Config
+	queue    chan queuedEvent
+	budget   *rateWindow
+
+	mu    sync.Mutex
+	stats SyslogStats
+
+	conn     net.Conn
+	nextDial time.Time
+	failing  bool
+
+	done chan struct{}
+}
+
+// NewSyslogSink creates a sink for the given configuration. It fails if
+// the TLS CA bundle cannot be loaded.
+func NewSyslogSink(log *zap.Logger, cfg SyslogConfig) (*SyslogSink, error) {
+	if cfg.RatePerSec <= 0 {
+		cfg.RatePerSec = defaultSyslogRate
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = "ddos-scrubber"
+	}
+
+	s := &SyslogSink{
+		log:      log,
+		cfg:      cfg,
+		hostname: cfg.Hostname,
+		procID:   strconv.Itoa(os.Getpid()),
+		keys:     make(map[string]string, len(syslogFields)),
+		queue:    make(chan queuedEvent, syslogQueueSize),
+		budget:   newRateWindow(cfg.RatePerSec, time.Second),
+		done:     make(chan struct{}),
+	}
+	if s.hostname == "" {
+		s.hostname, _ = os.Hostname()
+	}
+	for _, f := range syslogFields {
+		s.keys[f] = f
+		if cfg.Format == FormatCEF {
+			s.keys[f] = cefKeys[f]
+		}
+	}
+	for f, key := range cfg.Fields {
+		s.keys[f] = key
+	}
+
+	if cfg.Transport == TransportTLS {
+		s.tls = &tls.Config{
+			ServerName:         cfg.TLS.ServerName,
+			InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+			MinVersion:         tls.VersionTLS12,
+		}
+		if s.tls.ServerName == "" {
+			s.tls.ServerName, _, _ = net.SplitHostPort(cfg.Address)
+		}
+		if cfg.TLS.CA != "" {
+			pem, err := os.ReadFile(cfg.TLS.CA)
+			if err != nil {
+				return nil, fmt.Errorf("reading syslog CA: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", cfg.TLS.CA)
+			}
+			s.tls.RootCAs = pool
+		}
+	}
+	return s, nil
+}
+
+// Handle queues an event for forwarding. It never blocks.
+func (s *SyslogSink) Handle(ev *bpf.Event, info Info) {
+	if s.cfg.DropsOnly && ev.Action != 1 {
+		return
+	}
+	now := time.Now()
+	if !s.budget.allow(now) {
+		s.count(func(st *SyslogStats) { st.RateLimited++ })
+		return
+	}
+	select {
+	case s.queue <- queuedEvent{ev: *ev, info: info, at: now}:
+	default:
+		s.count(func(st *SyslogStats) { st.Dropped++ })
+	}
+}
+
+// Stats returns the forwarding counters.
+func (s *SyslogSink) Stats() SyslogStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *SyslogSink) count(fn func(*SyslogStats)) {
+	s.mu.Lock()
+	fn(&s.stats)
+	s.mu.Unlock()
+}
+
+// Run writes queued events until ctx is cancelled, then forwards whatever
+// is still queued.
+func (s *SyslogSink) Run(ctx context.Context) {
+	defer close(s.done)
+	s.log.Info("syslog forwarding started",
+		zap.String("address", s.cfg.Address),
+		zap.String("transport", s.cfg.Transport),
+		zap.String("format", s.cfg.Format),
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.flush()
+			s.closeConn()
+			st := s.Stats()
+			s.log.Info("syslog forwarding stopped",
+				zap.Uint64("sent", st.Sent),
+				zap.Uint64("rate_limited", st.RateLimited),
+				zap.Uint64("dropped", st.Dropped),
+				zap.Uint64("errors", st.Errors),
+			)
+			return
+		case item := <-s.queue:
+			s.send(item)
+		}
+	}
+}
+
+// Done returns a channel that is closed once Run has returned.
+func (s *SyslogSink) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *SyslogSink) flush() {
+	for {
+		select {
+		case item := <-s.queue:
+			s.send(item)
+		default:
+			return
+		}
+	}
+}
+
+// send writes one message, reconnecting as needed. Failed messages are not
+// retried: the event stream is telemetry and later events supersede them.
+func (s *SyslogSink) send(item queuedEvent) {
+	msg := s.format(&item.ev, item.info, item.at)
+
+	if err := s.write(msg); err != nil {
+		s.count(func(st *SyslogStats) { st.Errors++ })
+		s.closeConn()
+		if !s.failing {
+			s.log.Warn("syslog forwarding failing", zap.String("address", s.cfg.Address), zap.Error(err))
+			s.failing = true
+		}
+		return
+	}
+	if s.failing {
+		s.log.Info("syslog forwarding recovered", zap.String("address", s.cfg.Address))
+		s.failing = false
+	}
+	s.count(func(st *SyslogStats) { st.Sent++ })
+}
+
+func (s *SyslogSink) write(msg []byte) error {
+	if s.conn == nil {
+		if time.Now().Before(s.nextDial) {
+			return fmt.Errorf("waiting to reconnect")
+		}
+		conn, err := s.dial()
+		if err != nil {
+			s.nextDial = time.Now().Add(syslogReconnectDelay)
+			return err
+		}
+		s.conn = conn
+	}
+
+	// Stream transports use octet-counting framing (RFC 6587).
+	if s.cfg.Transport != TransportUDP {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+func (s *SyslogSink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: syslogDialTimeout}
+	switch s.cfg.Transport {
+	case TransportTLS:
+		return tls.DialWithDialer(dialer, "tcp", s.cfg.Address, s.tls)
+	case TransportTCP:
+		return dialer.Dial("tcp", s.cfg.Address)
+	default:
+		return dialer.Dial("udp", s.cfg.Address)
+	}
+}
+
+func (s *SyslogSink) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// format renders an event as a syslog message.
+func (s *SyslogSink) format(ev *bpf.Event, info Info, at time.Time) []byte {
+	dropped := ev.Action == 1
+	severity, msgID := 6, "PASS" // informational
+	if dropped {
+		severity, msgID = 4, "DROP" // warning
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s ",
+		s.cfg.Facility*8+severity,
+		at.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		headerField(s.hostname), headerField(s.cfg.AppName), s.procID, msgID)
+
+	values := eventFields(ev, info)
+	if s.cfg.Format == FormatCEF {
+		b.WriteString("- ")
+		s.writeCEF(&b, ev, values)
+		return []byte(b.String())
+	}
+
+	b.WriteString("[" + syslogSDID)
+	for _, f := range syslogFields {
+		if key := s.keys[f]; key != "" && values[f] != "" {
+			fmt.Fprintf(&b, ` %s="%s"`, key, sdEscaper.Replace(values[f]))
+		}
+	}
+	b.WriteString("] ")
+	fmt.Fprintf(&b, "%s %s %s:%s -> %s:%s proto=%s", msgID, values["attack"],
+		values["src_ip"], values["src_port"], values["dst_ip"], values["dst_port"], values["protocol"])
+	return []byte(b.String())
+}
+
+// writeCEF renders the CEF record: drops are keyed by drop reason, passes
+// by attack type.
+func (s *SyslogSink) writeCEF(b *strings.Builder, ev *bpf.Event, values map[string]string) {
+	sigID, name, severity := values["attack"], "Suspicious traffic passed", 3
+	if ev.Action == 1 {
+		sigID, name, severity = values["reason"], "Traffic dropped: "+values["reason"], 7
+	}
+	fmt.Fprintf(b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		cefHeaderEscaper.Replace(cefVendor), cefHeaderEscaper.Replace(cefProduct), cefVersion,
+		cefHeaderEscaper.Replace(sigID), cefHeaderEscaper.Replace(name), severity)
+
+	sep := ""
+	for _, f := range syslogFields {
+		key := s.keys[f]
+		if key == "" || values[f] == "" {
+			continue
+		}
+		fmt.Fprintf(b, "%s%s=%s", sep, key, cefExtEscaper.Replace(values[f]))
+		sep = " "
+		if cefCustomKey.MatchString(key) {
+			fmt.Fprintf(b, " %sLabel=%s", key, f)
+		}
+	}
+}
+
+// eventFields returns the string value of every field of ev, with "" for
+// enrichment fields that are not set.
+func eventFields(ev *bpf.Event, info Info) map[string]string {
+	action := "pass"
+	if ev.Action == 1 {
+		action = "drop"
+	}
+	values := map[string]string{
+		"src_ip":   bpf.U32BEToIP(ev.SrcIP).String(),
+		"dst_ip":   bpf.U32BEToIP(ev.DstIP).String(),
+		"src_port": strconv.Itoa(int(ev.SrcPort>>8 | ev.SrcPort<<8)),
+		"dst_port": strconv.Itoa(int(ev.DstPort>>8 | ev.DstPort<<8)),
+		"protocol": strconv.Itoa(int(ev.Protocol)),
+		"action":   action,
+		"attack":   bpf.AttackTypeName(ev.AttackType),
+		"reason":   bpf.DropReasonName(ev.DropReason),
+		"pps":      strconv.FormatUint(ev.PPSEstimate, 10),
+		"bps":      strconv.FormatUint(ev.BPSEstimate, 10),
+		"as_org":   info.ASOrg,
+		"hostname": info.Hostname,
+	}
+	if ev.CountryCode != 0 {
+		values["country"] = string([]byte{byte(ev.CountryCode >> 8), byte(ev.CountryCode)})
+	}
+	if info.ASN != 0 {
+		values["asn"] = strconv.FormatUint(uint64(info.ASN), 10)
+	}
+	if ev.ReputationScore != 0 {
+		values["reputation"] = strconv.FormatUint(uint64(ev.ReputationScore), 10)
+	}
+	if n := info.Events(); n > 1 {
+		values["count"] = strconv.FormatUint(n, 10)
+	}
+	return values
+}
+
+// headerField returns v, or the NILVALUE if it is empty, with characters
+// not allowed in RFC 5424 header fields replaced.
+func headerField(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, v)
+}
+
+var (
+	sdEscaper        = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	cefExtEscaper    = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// rateWindow allows at most n events per window.
+type rateWindow struct {
+	mu     sync.Mutex
+	n      int
+	window time.Duration
+	start  time.Time
+	count  int
+}
+
+func newRateWindow(n int, window time.Duration) *rateWindow {
+	return &rateWindow{n: n, window: window}
+}
+
+func (w *rateWindow) allow(now time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if now.Sub(w.start) >= w.window {
+		w.start = now
+		w.count = 0
+	}
+	if w.count >= w.n {
+		return false
+	}
+	w.count++
+	return true
+}
@@ -0,0 +1,205 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+func testEvent() *bpf.Event {
+	return &bpf.Event{
+		SrcIP:       0xc6336407, // 198.51.100.7
+		DstIP:       0xc0000201, // 192.0.2.1
+		SrcPort:     0x3930,     // 12345, byte-swapped
+		DstPort:     0x5000,     // 80, byte-swapped
+		Protocol:    6,
+		AttackType:  bpf.AttackSYNFlood,
+		Action:      1,
+		DropReason:  bpf.DropSYNFlood,
+		PPSEstimate: 50000,
+		BPSEstimate: 1000000,
+	}
+}
+
+func newTestSink(t *testing.T, cfg SyslogConfig) *SyslogSink {
+	t.Helper()
+	if cfg.Transport == "" {
+		cfg.Transport = TransportUDP
+	}
+	if cfg.Format == "" {
+		cfg.Format = FormatRFC5424
+	}
+	cfg.Hostname = "scrubber-1"
+	s, err := NewSyslogSink(zap.NewNop(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.procID = "42"
+	return s
+}
+
+var testTime = time.Date(2024, 5, 1, 12, 0, 0, 123456000, time.UTC)
+
+func TestFormatRFC5424(t *testing.T) {
+	s := newTestSink(t, SyslogConfig{Facility: 16})
+	got := string(s.format(testEvent(), Info{}, testTime))
+
+	want := `<132>1 2024-05-01T12:00:00.123456Z scrubber-1 ddos-scrubber 42 DROP ` +
+		`[ddos@32473 src_ip="198.51.100.7" dst_ip="192.0.2.1" src_port="12345" dst_port="80" protocol="6" ` +
+		`action="drop" attack="syn_flood" reason="syn_flood" pps="50000" bps="1000000"] ` +
+		`DROP syn_flood 198.51.100.7:12345 -> 192.0.2.1:80 proto=6`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestFormatCEF(t *testing.T) {
+	s := newTestSink(t, SyslogConfig{Format: FormatCEF, Facility: 4})
+	got := string(s.format(testEvent(), Info{}, testTime))
+
+	want := `<36>1 2024-05-01T12:00:00.123456Z scrubber-1 ddos-scrubber 42 DROP - ` +
+		`CEF:0|ebpf-ddos-scrubber|scrubber|0.1.0|syn_flood|Traffic dropped: syn_flood|7|` +
+		`src=198.51.100.7 dst=192.0.2.1 spt=12345 dpt=80 proto=6 act=drop cat=syn_flood reason=syn_flood ` +
+		`cn1=50000 cn1Label=pps cn2=1000000 cn2Label=bps`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestFieldMapping(t *testing.T) {
+	s := newTestSink(t, SyslogConfig{
+		Format: FormatCEF,
+		Fields: map[string]string{"attack": "cs1", "pps": "", "bps": ""},
+	})
+	got := string(s.format(testEvent(), Info{}, testTime))
+
+	if !strings.Contains(got, "cs1=syn_flood cs1Label=attack") {
+		t.Errorf("attack not remapped: %s", got)
+	}
+	if strings.Contains(got, "cn1") || strings.Contains(got, "50000") {
+		t.Errorf("omitted fields present: %s", got)
+	}
+}
+
+func TestEnrichedFields(t *testing.T) {
+	s := newTestSink(t, SyslogConfig{Format: FormatCEF})
+	ev := testEvent()
+	ev.CountryCode = 'N'<<8 | 'L'
+	ev.ReputationScore = 120
+	got := string(s.format(ev, Info{ASN: 64496, ASOrg: "Example Hosting", Hostname: "vps.example.net"}, testTime))
+
+	want := `cs1=NL cs1Label=country cs3=64496 cs3Label=asn cs2=Example Hosting cs2Label=as_org ` +
+		`cn3=120 cn3Label=reputation shost=vps.example.net`
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("got  %s\nwant suffix %s", got, want)
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	if got := sdEscaper.Replace(`a"b]c\d`); got != `a\"b\]c\\d` {
+		t.Errorf("SD escape = %s", got)
+	}
+	if got := cefExtEscaper.Replace("a=b\nc"); got != `a\=b\nc` {
+		t.Errorf("CEF escape = %s", got)
+	}
+	if got := headerField("my host"); got != "my_host" {
+		t.Errorf("headerField = %s", got)
+	}
+}
+
+func TestSyslogValidate(t *testing.T) {
+	valid := SyslogConfig{Address: "siem:514", Transport: TransportTLS, Format: FormatCEF, Facility: 16}
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for i, mutate := range []func(*SyslogConfig){
+		func(c *SyslogConfig) { c.Address = "" },
+		func(c *SyslogConfig) { c.Transport = "relp" },
+		func(c *SyslogConfig) { c.Format = "leef" },
+		func(c *SyslogConfig) { c.Facility = 24 },
+		func(c *SyslogConfig) { c.Fields = map[string]string{"ttl": "ttl"} },
+		func(c *SyslogConfig) { c.Fields = map[string]string{"pps": "a b"} },
+	} {
+		c := valid
+		mutate(&c)
+		if err := c.Validate(); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	s := newTestSink(t, SyslogConfig{Address: pc.LocalAddr().String(), Facility: 16, DropsOnly: true, RatePerSec: 2})
+	pass := testEvent()
+	pass.Action = 0
+	s.Handle(pass, Info{}) // filtered
+	for i := 0; i < 3; i++ {
+		s.Handle(testEvent(), Info{})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Run(ctx) // flushes the queue
+
+	pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 2048)
+	for i := 0; i < 2; i++ {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(buf[:n]), "<132>1 ") {
+			t.Errorf("unexpected datagram: %s", buf[:n])
+		}
+	}
+
+	st := s.Stats()
+	if st.Sent != 2 || st.RateLimited != 1 || st.Errors != 0 {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestSyslogTCPFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	s := newTestSink(t, SyslogConfig{Address: ln.Addr().String(), Transport: TransportTCP})
+	s.Handle(testEvent(), Info{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	go s.Run(ctx)
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	r := bufio.NewReader(conn)
+	length, err := r.ReadString(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := len(s.format(testEvent(), Info{}, testTime))
+	if length != strconv.Itoa(want)+" " {
+		t.Errorf("frame length = %s, want %d", length, want)
+	}
+	<-s.Done()
+}
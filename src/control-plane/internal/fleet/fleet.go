@@ -0,0 +1,689 @@
+// Package fleet elects a leader among the scrubbers protecting the same
+// anycast prefixes. Decisions with effects beyond the local node, BGP
+// RTBH and flowspec announcements and threat intel feed pulls, are taken
+// by the leader only; followers enforce locally and mirror the state the
+// leader shares, such as its threat intel entries.
+//
+// Members heartbeat each other over HTTPS with mutual TLS, set up like
+// the cluster package's peer link. A member is live while heard from
+// within Config.LeaseSec. Every member applies the same rule to its live
+// set: a member already leading keeps the role (the one leading longest
+// if several claim it after a partition heals); otherwise the highest
+// priority, then the lowest node ID, is elected. A member takes the role
+// only after winning for a full lease, by which time a leader it lost
+// contact with has stepped down. With RequireQuorum a member leads only
+// while it sees a majority of the fleet, so a partitioned minority never
+// announces.
+//
+// Independently of the election, members can share their reputation
+// blocks so that a source blocked at one site is blocked at all of them.
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/cluster"
+	"go.uber.org/zap"
+)
+
+const (
+	heartbeatPath   = "/fleet/v1/heartbeat"
+	statePath       = "/fleet/v1/state"
+	reputationPath  = "/fleet/v1/reputation"
+	maxBody         = 64 << 20
+	shutdownTimeout = 5 * time.Second
+)
+
+// Kinds of state the leader shares.
+const (
+	KindThreatIntel = "threat_intel"
+)
+
+// Config controls fleet membership and leader election.
+type Config struct {
+	Enabled       bool              `yaml:"enabled"`
+	NodeID        string            `yaml:"node_id"`  // Unique name of this scrubber
+	Listen        string            `yaml:"listen"`   // Heartbeat listener, e.g. "0.0.0.0:9444"
+	Peers         []string          `yaml:"peers"`    // host:port of every other member
+	Priority      int               `yaml:"priority"` // Higher is elected first
+	TLS           cluster.TLSConfig `yaml:"tls"`
+	IntervalSec   uint64            `yaml:"interval_sec"`   // Heartbeat interval
+	LeaseSec      uint64            `yaml:"lease_sec"`      // Members unheard from this long are down
+	MirrorSec     uint64            `yaml:"mirror_sec"`     // How often followers copy shared state
+	RequireQuorum bool              `yaml:"require_quorum"` // Lead only while a majority is live
+
+	// Every member shares its reputation blocks with all others
+	Reputation ReputationConfig `yaml:"reputation"`
+}
+
+// DefaultConfig returns the built-in fleet settings, disabled.
+func DefaultConfig() Config {
+	return Config{
+		Listen:        "0.0.0.0:9444",
+		IntervalSec:   1,
+		LeaseSec:      5,
+		MirrorSec:     60,
+		RequireQuorum: true,
+		Reputation:    DefaultReputationConfig(),
+	}
+}
+
+// Validate checks the fleet configuration.
+func (c Config) Validate() error {
+	if c.NodeID == "" {
+		return fmt.Errorf("node_id is required")
+	}
+	if c.Listen == "" {
+		return fmt.Errorf("listen is required")
+	}
+	if len(c.Peers) == 0 {
+		return fmt.Errorf("peers must list the other members")
+	}
+	seen := make(map[string]bool, len(c.Peers))
+	for _, p := range c.Peers {
+		if _, _, err := net.SplitHostPort(p); err != nil {
+			return fmt.Errorf("invalid peer %q: %w", p, err)
+		}
+		if seen[p] {
+			return fmt.Errorf("peer %s listed twice", p)
+		}
+		seen[p] = true
+	}
+	if !c.TLS.Insecure && (c.TLS.Cert == "" || c.TLS.Key == "" || c.TLS.CA == "") {
+		return fmt.Errorf("tls.cert, tls.key and tls.ca are required unless tls.insecure is set")
+	}
+	if c.IntervalSec == 0 {
+		return fmt.Errorf("interval_sec must be positive")
+	}
+	if c.LeaseSec < 2*c.IntervalSec {
+		return fmt.Errorf("lease_sec must be at least twice interval_sec")
+	}
+	if c.MirrorSec == 0 {
+		return fmt.Errorf("mirror_sec must be positive")
+	}
+	if c.Reputation.Enabled {
+		if err := c.Reputation.Validate(); err != nil {
+			return fmt.Errorf("reputation: %w", err)
+		}
+	}
+	return nil
+}
+
+// memberState is exchanged on every heartbeat.
+type memberState struct {
+	Node        string    `json:"node"`
+	Epoch       int64     `json:"epoch"` // Changes when the process restarts
+	Priority    int       `json:"priority"`
+	Leader      bool      `json:"leader"`
+	LeaderSince time.Time `json:"leaderSince,omitempty"`
+}
+
+// member is another scrubber as last heard from.
+type member struct {
+	memberState
+	addr        string // Learned from our own heartbeats; "" until then
+	lastContact time.Time
+}
+
+// Member is another scrubber as reported by Status.
+type Member struct {
+	Node        string    `json:"node,omitempty"` // "" until it answered once
+	Addr        string    `json:"addr"`
+	Priority    int       `json:"priority"`
+	Leader      bool      `json:"leader"`
+	Live        bool      `json:"live"`
+	LastContact time.Time `json:"lastContact,omitempty"`
+	LastError   string    `json:"lastError,omitempty"`
+}
+
+// Status reports the local view of the fleet.
+type Status struct {
+	Node        string    `json:"node"`
+	Leader      string    `json:"leader"` // "" while no member leads
+	IsLeader    bool      `json:"isLeader"`
+	LeaderSince time.Time `json:"leaderSince,omitempty"`
+	Priority    int       `json:"priority"`
+	Size        int       `json:"size"`
+	Live        int       `json:"live"`
+	Quorum      int       `json:"quorum"` // 0 when not required
+	Members     []Member  `json:"members"`
+	LastMirror  time.Time `json:"lastMirror,omitempty"`
+	MirrorError string    `json:"mirrorError,omitempty"`
+
+	Reputation *ReputationStatus `json:"reputation,omitempty"` // nil unless sharing
+}
+
+// Fleet runs this scrubber's side of the election.
+type Fleet struct {
+	log       *zap.Logger
+	cfg       Config
+	scheme    string
+	client    *http.Client
+	serverTLS *tls.Config
+	epoch     int64
+	now       func() time.Time
+
+	mu             sync.Mutex
+	members        map[string]*member // By node ID
+	linkErr        map[string]string  // By peer address
+	leader         bool
+	leaderSince    time.Time
+	candidateSince time.Time // When this node started winning the election
+	shared         map[string]cluster.Source
+	lastMirror     time.Time
+	mirrorErr      string
+	reputation     ReputationSource
+	repStatus      ReputationStatus
+
+	onLeaderChange []func(leader bool)
+}
+
+// New creates the fleet member. It fails if the TLS material cannot be
+// loaded.
+func New(log *zap.Logger, cfg Config) (*Fleet, error) {
+	f := &Fleet{
+		log:     log,
+		cfg:     cfg,
+		scheme:  "http",
+		client:  &http.Client{Timeout: time.Duration(cfg.IntervalSec) * time.Second * 2},
+		now:     time.Now,
+		members: make(map[string]*member),
+		linkErr: make(map[string]string),
+		shared:  make(map[string]cluster.Source),
+	}
+	f.repStatus.Received = make(map[string]int)
+	f.epoch = f.now().UnixNano()
+	if cfg.TLS.Insecure {
+		return f, nil
+	}
+	server, client, err := cfg.TLS.Load()
+	if err != nil {
+		return nil, err
+	}
+	f.serverTLS = server
+	f.client.Transport = &http.Transport{TLSClientConfig: client}
+	f.scheme = "https"
+	return f, nil
+}
+
+// Share registers state the leader serves and followers mirror. It must
+// be called before Run.
+func (f *Fleet) Share(kind string, src cluster.Source) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.shared[kind] = src
+}
+
+// OnLeaderChange registers a callback invoked after this node gains or
+// loses leadership.
+func (f *Fleet) OnLeaderChange(fn func(leader bool)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.onLeaderChange = append(f.onLeaderChange, fn)
+}
+
+// IsLeader reports whether this node takes the fleet-wide decisions.
+func (f *Fleet) IsLeader() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.leader
+}
+
+// Status returns the local view of the fleet.
+func (f *Fleet) Status() Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := f.now()
+	st := Status{
+		Node:        f.cfg.NodeID,
+		IsLeader:    f.leader,
+		LeaderSince: f.leaderSince,
+		Priority:    f.cfg.Priority,
+		Size:        len(f.cfg.Peers) + 1,
+		Live:        len(f.liveLocked(now)),
+		LastMirror:  f.lastMirror,
+		MirrorError: f.mirrorErr,
+	}
+	if f.cfg.RequireQuorum {
+		st.Quorum = st.Size/2 + 1
+	}
+	if l, ok := f.leaderLocked(now); ok {
+		st.Leader = l.Node
+	}
+	if f.reputation != nil && f.cfg.Reputation.Enabled {
+		rs := f.repStatus
+		rs.Received = make(map[string]int, len(f.repStatus.Received))
+		for origin, n := range f.repStatus.Received {
+			rs.Received[origin] = n
+		}
+		st.Reputation = &rs
+	}
+
+	byAddr := make(map[string]*member)
+	for _, m := range f.members {
+		if m.addr != "" {
+			byAddr[m.addr] = m
+		}
+	}
+	for _, addr := range f.cfg.Peers {
+		pm := Member{Addr: addr, LastError: f.linkErr[addr]}
+		if m := byAddr[addr]; m != nil {
+			pm.Node = m.Node
+			pm.Priority = m.Priority
+			pm.Leader = m.Leader
+			pm.LastContact = m.lastContact
+			pm.Live = f.isLive(m, now)
+		}
+		st.Members = append(st.Members, pm)
+	}
+	return st
+}
+
+// Run serves heartbeats, sends them every Config.IntervalSec, re-runs
+// the election and, on followers, mirrors shared state until ctx is done.
+func (f *Fleet) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", f.cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", f.cfg.Listen, err)
+	}
+	if f.serverTLS != nil {
+		ln = tls.NewListener(ln, f.serverTLS)
+	}
+
+	srv := &http.Server{Handler: f.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			f.log.Error("fleet listener failed", zap.Error(err))
+		}
+	}()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	f.log.Info("fleet election started",
+		zap.String("node", f.cfg.NodeID),
+		zap.Int("priority", f.cfg.Priority),
+		zap.Strings("peers", f.cfg.Peers),
+		zap.Bool("require_quorum", f.cfg.RequireQuorum),
+	)
+
+	ticker := time.NewTicker(time.Duration(f.cfg.IntervalSec) * time.Second)
+	defer ticker.Stop()
+	mirror := time.Duration(f.cfg.MirrorSec) * time.Second
+	publish := time.Duration(f.cfg.Reputation.IntervalSec) * time.Second
+	var lastMirror, lastPublish time.Time
+
+	for {
+		f.heartbeat(ctx)
+		f.elect()
+		if now := f.now(); now.Sub(lastMirror) >= mirror {
+			lastMirror = now
+			f.mirror(ctx)
+		}
+		if now := f.now(); f.sharesReputation() && now.Sub(lastPublish) >= publish {
+			lastPublish = now
+			f.publishReputation(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// heartbeat sends this node's state to every peer in parallel.
+func (f *Fleet) heartbeat(ctx context.Context) {
+	f.mu.Lock()
+	self := f.stateLocked()
+	f.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, addr := range f.cfg.Peers {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			var resp memberState
+			err := f.post(ctx, addr, heartbeatPath, self, &resp)
+
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if err != nil {
+				f.linkErr[addr] = err.Error()
+				return
+			}
+			delete(f.linkErr, addr)
+			if m := f.contactLocked(resp); m != nil {
+				m.addr = addr
+			}
+		}(addr)
+	}
+	wg.Wait()
+}
+
+// post sends body to a peer and decodes the answer into out, if not nil.
+func (f *Fleet) post(ctx context.Context, addr, path string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.scheme+"://"+addr+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (f *Fleet) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(heartbeatPath, f.handleHeartbeat)
+	mux.HandleFunc(statePath, f.handleState)
+	mux.HandleFunc(reputationPath, f.handleReputation)
+	return mux
+}
+
+func (f *Fleet) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req memberState
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "invalid heartbeat", http.StatusBadRequest)
+		return
+	}
+	if req.Node == f.cfg.NodeID {
+		http.Error(w, "member uses the same node_id", http.StatusConflict)
+		return
+	}
+
+	f.mu.Lock()
+	f.contactLocked(req)
+	resp := f.stateLocked()
+	f.mu.Unlock()
+	writeJSON(w, resp)
+}
+
+// handleState serves a snapshot of shared state, on the leader only.
+func (f *Fleet) handleState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	f.mu.Lock()
+	leader := f.leader
+	src := f.shared[r.URL.Query().Get("kind")]
+	f.mu.Unlock()
+
+	if !leader {
+		http.Error(w, "not the fleet leader", http.StatusConflict)
+		return
+	}
+	if src == nil {
+		http.Error(w, "unknown kind", http.StatusNotFound)
+		return
+	}
+	snap, err := src.Snapshot()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, snap)
+}
+
+func (f *Fleet) stateLocked() memberState {
+	return memberState{
+		Node:        f.cfg.NodeID,
+		Epoch:       f.epoch,
+		Priority:    f.cfg.Priority,
+		Leader:      f.leader,
+		LeaderSince: f.leaderSince,
+	}
+}
+
+// contactLocked records a member's state.
+func (f *Fleet) contactLocked(s memberState) *member {
+	if s.Node == "" || s.Node == f.cfg.NodeID {
+		return nil
+	}
+	m := f.members[s.Node]
+	if m == nil {
+		m = &member{}
+		f.members[s.Node] = m
+		f.log.Info("fleet member joined", zap.String("node", s.Node), zap.Int("priority", s.Priority))
+	}
+	m.memberState = s
+	m.lastContact = f.now()
+	return m
+}
+
+func (f *Fleet) isLive(m *member, now time.Time) bool {
+	return now.Sub(m.lastContact) <= time.Duration(f.cfg.LeaseSec)*time.Second
+}
+
+// liveLocked returns the states of the live members, this node included.
+func (f *Fleet) liveLocked(now time.Time) []memberState {
+	live := []memberState{f.stateLocked()}
+	for _, m := range f.members {
+		if f.isLive(m, now) {
+			live = append(live, m.memberState)
+		}
+	}
+	return live
+}
+
+// leaderLocked returns the live member leading, if any.
+func (f *Fleet) leaderLocked(now time.Time) (memberState, bool) {
+	var claims []memberState
+	for _, s := range f.liveLocked(now) {
+		if s.Leader {
+			claims = append(claims, s)
+		}
+	}
+	if len(claims) == 0 {
+		return memberState{}, false
+	}
+	return elect(claims), true
+}
+
+// elect applies the election rule to the live members: leaders first,
+// the one leading longest, then priority, then node ID.
+func elect(live []memberState) memberState {
+	sort.Slice(live, func(i, j int) bool {
+		a, b := live[i], live[j]
+		if a.Leader != b.Leader {
+			return a.Leader
+		}
+		if a.Leader && !a.LeaderSince.Equal(b.LeaderSince) {
+			return a.LeaderSince.Before(b.LeaderSince)
+		}
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return a.Node < b.Node
+	})
+	return live[0]
+}
+
+// elect re-runs the election and takes or gives up leadership.
+func (f *Fleet) elect() {
+	f.mu.Lock()
+	now := f.now()
+	live := f.liveLocked(now)
+	quorum := !f.cfg.RequireQuorum || len(live) >= (len(f.cfg.Peers)+1)/2+1
+	wins := quorum && elect(live).Node == f.cfg.NodeID
+
+	var reason string
+	took := false
+	switch {
+	case f.leader && !quorum:
+		reason = "lost quorum"
+	case f.leader && !wins:
+		reason = "outranked by another leader"
+	case f.leader:
+		// Still leading.
+	case !wins:
+		f.candidateSince = time.Time{}
+	case f.candidateSince.IsZero():
+		f.candidateSince = now
+	case now.Sub(f.candidateSince) >= time.Duration(f.cfg.LeaseSec)*time.Second:
+		f.leader = true
+		f.leaderSince = now
+		f.candidateSince = time.Time{}
+		took = true
+		f.log.Warn("fleet leadership taken", zap.Int("live", len(live)))
+	}
+	f.mu.Unlock()
+
+	if reason != "" {
+		f.stepDown(reason)
+	}
+	if took {
+		f.notify(true)
+	}
+}
+
+// stepDown gives up leadership.
+func (f *Fleet) stepDown(reason string) {
+	f.mu.Lock()
+	was := f.leader
+	f.leader = false
+	f.leaderSince = time.Time{}
+	f.candidateSince = time.Time{}
+	f.mu.Unlock()
+
+	if was {
+		f.log.Warn("fleet leadership given up", zap.String("reason", reason))
+		f.notify(false)
+	}
+}
+
+func (f *Fleet) notify(leader bool) {
+	f.mu.Lock()
+	fns := append([]func(bool){}, f.onLeaderChange...)
+	f.mu.Unlock()
+	for _, fn := range fns {
+		fn(leader)
+	}
+}
+
+// mirror copies the leader's shared state on followers.
+func (f *Fleet) mirror(ctx context.Context) {
+	f.mu.Lock()
+	l, ok := f.leaderLocked(f.now())
+	addr := ""
+	if m := f.members[l.Node]; ok && m != nil {
+		addr = m.addr
+	}
+	kinds := make([]string, 0, len(f.shared))
+	for kind := range f.shared {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	f.mu.Unlock()
+	if !ok || l.Node == f.cfg.NodeID || addr == "" || len(kinds) == 0 {
+		return
+	}
+
+	var errMsg string
+	for _, kind := range kinds {
+		n, err := f.mirrorKind(ctx, addr, kind)
+		if err != nil {
+			errMsg = fmt.Sprintf("%s: %v", kind, err)
+			f.log.Warn("mirroring leader state failed", zap.String("leader", l.Node), zap.String("kind", kind), zap.Error(err))
+			continue
+		}
+		if n > 0 {
+			f.log.Info("mirrored leader state", zap.String("leader", l.Node), zap.String("kind", kind), zap.Int("changes", n))
+		}
+	}
+
+	f.mu.Lock()
+	f.lastMirror = f.now()
+	f.mirrorErr = errMsg
+	f.mu.Unlock()
+}
+
+// mirrorKind fetches the leader's snapshot of kind and applies the
+// differences to the local source, returning how many entries changed.
+func (f *Fleet) mirrorKind(ctx context.Context, addr, kind string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		f.scheme+"://"+addr+statePath+"?kind="+url.QueryEscape(kind), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	var want map[string]string
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxBody)).Decode(&want); err != nil {
+		return 0, fmt.Errorf("decoding snapshot: %w", err)
+	}
+
+	f.mu.Lock()
+	src := f.shared[kind]
+	f.mu.Unlock()
+	have, err := src.Snapshot()
+	if err != nil {
+		return 0, err
+	}
+
+	changes := 0
+	for id, value := range want {
+		if cur, ok := have[id]; ok && cur == value {
+			continue
+		}
+		if err := src.Apply(id, value); err != nil {
+			return changes, fmt.Errorf("applying %s: %w", id, err)
+		}
+		changes++
+	}
+	for id := range have {
+		if _, ok := want[id]; ok {
+			continue
+		}
+		if err := src.Remove(id); err != nil {
+			return changes, fmt.Errorf("removing %s: %w", id, err)
+		}
+		changes++
+	}
+	return changes, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
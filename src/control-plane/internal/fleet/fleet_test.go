@@ -0,0 +1,333 @@
+package fleet
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeSource is an in-memory shared state.
+type fakeSource struct{ items map[string]string }
+
+func (f *fakeSource) Snapshot() (map[string]string, error) {
+	snap := make(map[string]string, len(f.items))
+	for k, v := range f.items {
+		snap[k] = v
+	}
+	return snap, nil
+}
+
+func (f *fakeSource) Apply(id, value string) error {
+	f.items[id] = value
+	return nil
+}
+
+func (f *fakeSource) Remove(id string) error {
+	delete(f.items, id)
+	return nil
+}
+
+// testFleet wires members to each other over HTTP on a shared clock.
+type testFleet struct {
+	clock   time.Time
+	members map[string]*Fleet
+	servers map[string]*httptest.Server
+	sources map[string]*fakeSource
+	changes map[string][]bool
+}
+
+func newTestFleet(t *testing.T, priorities map[string]int) *testFleet {
+	t.Helper()
+	tf := &testFleet{
+		clock:   time.Now(),
+		members: make(map[string]*Fleet),
+		servers: make(map[string]*httptest.Server),
+		sources: make(map[string]*fakeSource),
+		changes: make(map[string][]bool),
+	}
+	for node, prio := range priorities {
+		cfg := DefaultConfig()
+		cfg.Enabled = true
+		cfg.NodeID = node
+		cfg.Priority = prio
+		cfg.TLS.Insecure = true
+		f, err := New(zap.NewNop(), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.now = func() time.Time { return tf.clock }
+		src := &fakeSource{items: map[string]string{}}
+		f.Share(KindThreatIntel, src)
+		node := node
+		f.OnLeaderChange(func(leader bool) { tf.changes[node] = append(tf.changes[node], leader) })
+
+		srv := httptest.NewServer(f.handler())
+		t.Cleanup(srv.Close)
+		tf.members[node], tf.servers[node], tf.sources[node] = f, srv, src
+	}
+	for node, f := range tf.members {
+		for other, srv := range tf.servers {
+			if other != node {
+				f.cfg.Peers = append(f.cfg.Peers, strings.TrimPrefix(srv.URL, "http://"))
+			}
+		}
+	}
+	return tf
+}
+
+// round advances the clock and runs one heartbeat and election on every
+// member still up.
+func (tf *testFleet) round(d time.Duration) {
+	tf.clock = tf.clock.Add(d)
+	ctx := context.Background()
+	for node, f := range tf.members {
+		if tf.servers[node] != nil {
+			f.heartbeat(ctx)
+		}
+	}
+	for node, f := range tf.members {
+		if tf.servers[node] != nil {
+			f.elect()
+		}
+	}
+}
+
+func (tf *testFleet) stop(node string) {
+	tf.servers[node].Close()
+	tf.servers[node] = nil
+}
+
+func (tf *testFleet) leaders() []string {
+	var leaders []string
+	for node, f := range tf.members {
+		if tf.servers[node] != nil && f.IsLeader() {
+			leaders = append(leaders, node)
+		}
+	}
+	return leaders
+}
+
+func TestElectRule(t *testing.T) {
+	t0 := time.Now()
+	tests := []struct {
+		name string
+		live []memberState
+		want string
+	}{
+		{"priority", []memberState{{Node: "a", Priority: 1}, {Node: "b", Priority: 5}}, "b"},
+		{"node id breaks ties", []memberState{{Node: "b"}, {Node: "a"}}, "a"},
+		{"leader is kept", []memberState{{Node: "a", Priority: 9}, {Node: "b", Leader: true, LeaderSince: t0}}, "b"},
+		{"longest leader after a partition", []memberState{
+			{Node: "a", Priority: 9, Leader: true, LeaderSince: t0.Add(time.Minute)},
+			{Node: "b", Leader: true, LeaderSince: t0},
+		}, "b"},
+	}
+	for _, tt := range tests {
+		if got := elect(tt.live).Node; got != tt.want {
+			t.Errorf("%s: elected %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestElection(t *testing.T) {
+	tf := newTestFleet(t, map[string]int{"a": 10, "b": 5, "c": 0})
+	lease := time.Duration(DefaultConfig().LeaseSec) * time.Second
+
+	// The winner holds off for a lease before taking the role.
+	tf.round(0)
+	if got := tf.leaders(); len(got) != 0 {
+		t.Fatalf("leaders before a lease passed = %v", got)
+	}
+	tf.round(lease)
+	tf.round(time.Second) // Followers learn the leader
+	if got := tf.leaders(); len(got) != 1 || got[0] != "a" {
+		t.Fatalf("leaders = %v, want [a]", got)
+	}
+	if st := tf.members["c"].Status(); st.Leader != "a" || st.Live != 3 || st.Quorum != 2 {
+		t.Errorf("follower status = %+v", st)
+	}
+
+	// The leader goes away: b takes over a lease after losing it, once
+	// a itself would have stepped down.
+	tf.stop("a")
+	tf.round(lease + time.Second)
+	if got := tf.leaders(); len(got) != 0 {
+		t.Fatalf("leaders right after the leader was lost = %v", got)
+	}
+	tf.round(lease)
+	if got := tf.leaders(); len(got) != 1 || got[0] != "b" {
+		t.Fatalf("leaders after failover = %v, want [b]", got)
+	}
+
+	// Without a majority the remaining member steps down.
+	tf.stop("c")
+	tf.round(lease + time.Second)
+	if got := tf.leaders(); len(got) != 0 {
+		t.Errorf("minority kept leading: %v", got)
+	}
+	if got := tf.changes["b"]; len(got) != 2 || !got[0] || got[1] {
+		t.Errorf("b leader changes = %v, want [true false]", got)
+	}
+	if got := tf.changes["a"]; len(got) != 1 || !got[0] {
+		t.Errorf("a leader changes = %v, want [true]", got)
+	}
+}
+
+func TestNoQuorumRequired(t *testing.T) {
+	tf := newTestFleet(t, map[string]int{"a": 0, "b": 0})
+	for _, f := range tf.members {
+		f.cfg.RequireQuorum = false
+	}
+	lease := time.Duration(DefaultConfig().LeaseSec) * time.Second
+	tf.round(0)
+	tf.round(lease)
+	tf.stop("a")
+	tf.round(lease + time.Second)
+	tf.round(lease)
+	if got := tf.leaders(); len(got) != 1 || got[0] != "b" {
+		t.Errorf("leaders = %v, want [b] without quorum", got)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	tf := newTestFleet(t, map[string]int{"a": 10, "b": 0, "c": 0})
+	lease := time.Duration(DefaultConfig().LeaseSec) * time.Second
+	tf.round(0)
+	tf.round(lease)
+	tf.round(time.Second)
+
+	tf.sources["a"].items = map[string]string{"192.0.2.0/24": "1:0:90:0", "198.51.100.0/24": "2:1:60:1"}
+	tf.sources["b"].items = map[string]string{"192.0.2.0/24": "1:0:50:0", "203.0.113.0/24": "1:0:90:0"}
+	tf.members["b"].mirror(context.Background())
+
+	want := tf.sources["a"].items
+	got := tf.sources["b"].items
+	if len(got) != len(want) || got["192.0.2.0/24"] != want["192.0.2.0/24"] || got["198.51.100.0/24"] != want["198.51.100.0/24"] {
+		t.Errorf("mirrored = %v, want %v", got, want)
+	}
+	if st := tf.members["b"].Status(); st.LastMirror.IsZero() || st.MirrorError != "" {
+		t.Errorf("mirror status = %+v", st)
+	}
+
+	// The leader serves nothing to mirror from itself.
+	tf.members["a"].mirror(context.Background())
+	if len(tf.sources["a"].items) != 2 {
+		t.Errorf("leader state changed: %v", tf.sources["a"].items)
+	}
+}
+
+// fakeReputation records the sources shared with it.
+type fakeReputation struct {
+	offenders map[string]uint32
+	minScore  uint32
+	shared    map[string]map[string]uint32
+	ttl       time.Duration
+}
+
+func (f *fakeReputation) Offenders(minScore uint32) map[string]uint32 {
+	f.minScore = minScore
+	return f.offenders
+}
+
+func (f *fakeReputation) SetShared(origin string, scores map[string]uint32, ttl time.Duration) error {
+	f.shared[origin], f.ttl = scores, ttl
+	return nil
+}
+
+func TestReputationSharing(t *testing.T) {
+	tf := newTestFleet(t, map[string]int{"a": 0, "b": 0, "c": 0})
+	reps := make(map[string]*fakeReputation)
+	for node, f := range tf.members {
+		f.cfg.Reputation.Enabled = true
+		f.cfg.Reputation.MinScore = 300
+		f.cfg.Reputation.MaxEntries = 2
+		reps[node] = &fakeReputation{shared: map[string]map[string]uint32{}}
+		f.ShareReputation(reps[node])
+	}
+	reps["a"].offenders = map[string]uint32{"198.51.100.7": 900, "198.51.100.8": 500, "198.51.100.9": 300}
+
+	tf.members["a"].publishReputation(context.Background())
+
+	if reps["a"].minScore != 300 {
+		t.Errorf("offenders asked with min score %d", reps["a"].minScore)
+	}
+	for _, node := range []string{"b", "c"} {
+		got := reps[node].shared["a"]
+		if len(got) != 2 || got["198.51.100.7"] != 900 || got["198.51.100.8"] != 500 {
+			t.Errorf("%s received %v, want the two highest", node, got)
+		}
+		if reps[node].ttl != time.Hour {
+			t.Errorf("%s ttl = %s", node, reps[node].ttl)
+		}
+		if st := tf.members[node].Status(); st.Reputation == nil || st.Reputation.Received["a"] != 2 {
+			t.Errorf("%s status = %+v", node, st.Reputation)
+		}
+	}
+	if st := tf.members["a"].Status().Reputation; st.Published != 2 || st.PublishError != "" {
+		t.Errorf("publisher status = %+v", st)
+	}
+
+	// A member with sharing off refuses updates.
+	tf.members["c"].cfg.Reputation.Enabled = false
+	tf.members["a"].publishReputation(context.Background())
+	if st := tf.members["a"].Status().Reputation; !strings.Contains(st.PublishError, "404") {
+		t.Errorf("publish error = %q, want a 404", st.PublishError)
+	}
+}
+
+func TestFollowerRejectsStateRequests(t *testing.T) {
+	tf := newTestFleet(t, map[string]int{"a": 0, "b": 0})
+	resp, err := http.Get(tf.servers["b"].URL + statePath + "?kind=" + KindThreatIntel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("follower state request: HTTP %d, want 409", resp.StatusCode)
+	}
+}
+
+func TestSameNodeIDRejected(t *testing.T) {
+	tf := newTestFleet(t, map[string]int{"a": 0, "b": 0})
+	tf.members["a"].cfg.NodeID = "b"
+	tf.members["a"].heartbeat(context.Background())
+	st := tf.members["a"].Status()
+	if len(st.Members) != 1 || !strings.Contains(st.Members[0].LastError, "409") {
+		t.Errorf("members = %+v, want a 409 from the duplicate", st.Members)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := DefaultConfig()
+	valid.NodeID = "pop-ams"
+	valid.Peers = []string{"10.1.0.2:9444", "10.2.0.2:9444"}
+	valid.TLS.Cert, valid.TLS.Key, valid.TLS.CA = "c", "k", "ca"
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := []func(*Config){
+		func(c *Config) { c.NodeID = "" },
+		func(c *Config) { c.Peers = nil },
+		func(c *Config) { c.Peers = []string{"10.1.0.2"} },
+		func(c *Config) { c.Peers = []string{"10.1.0.2:9444", "10.1.0.2:9444"} },
+		func(c *Config) { c.TLS.CA = "" },
+		func(c *Config) { c.LeaseSec = 1 },
+		func(c *Config) { c.MirrorSec = 0 },
+		func(c *Config) { c.Reputation.Enabled, c.Reputation.TTLSec = true, 5 },
+		func(c *Config) { c.Reputation.Enabled, c.Reputation.MaxEntries = true, 0 },
+	}
+	for i, mutate := range bad {
+		c := valid
+		c.Peers = append([]string(nil), valid.Peers...)
+		mutate(&c)
+		if err := c.Validate(); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package fleet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ReputationConfig controls reputation sharing. Every member, leader or
+// not, periodically sends the sources it blocked, or scored at least
+// MinScore, to all others, which block them until TTLSec passes without
+// the member sending them again.
+type ReputationConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	IntervalSec uint64 `yaml:"interval_sec"` // How often blocks are sent
+	TTLSec      uint64 `yaml:"ttl_sec"`      // How long received blocks last unless sent again
+	MinScore    uint32 `yaml:"min_score"`    // Also share unblocked sources scoring this much; 0 = blocked only
+	MaxEntries  int    `yaml:"max_entries"`  // Highest scores first beyond this many
+}
+
+// DefaultReputationConfig returns the built-in sharing settings, disabled.
+func DefaultReputationConfig() ReputationConfig {
+	return ReputationConfig{
+		IntervalSec: 10,
+		TTLSec:      3600,
+		MaxEntries:  10000,
+	}
+}
+
+// Validate checks the reputation sharing configuration.
+func (c ReputationConfig) Validate() error {
+	if c.IntervalSec == 0 {
+		return fmt.Errorf("interval_sec must be positive")
+	}
+	if c.TTLSec < 2*c.IntervalSec {
+		return fmt.Errorf("ttl_sec must be at least twice interval_sec")
+	}
+	if c.MaxEntries <= 0 {
+		return fmt.Errorf("max_entries must be positive")
+	}
+	return nil
+}
+
+// ReputationSource is this member's reputation engine, implemented by
+// reputation.Engine.
+type ReputationSource interface {
+	// Offenders returns the sources blocked here, plus those scoring at
+	// least minScore if not 0, by IP.
+	Offenders(minScore uint32) map[string]uint32
+	// SetShared replaces the sources shared by origin.
+	SetShared(origin string, scores map[string]uint32, ttl time.Duration) error
+}
+
+// ReputationStatus reports reputation sharing.
+type ReputationStatus struct {
+	Published    int            `json:"published"` // Sources sent in the last round
+	LastPublish  time.Time      `json:"lastPublish,omitempty"`
+	PublishError string         `json:"publishError,omitempty"`
+	Received     map[string]int `json:"received"` // Sources shared, by origin
+}
+
+// reputationUpdate is the full set of a member's shared sources.
+type reputationUpdate struct {
+	Node    string            `json:"node"`
+	TTLSec  uint64            `json:"ttlSec"`
+	Sources map[string]uint32 `json:"sources"`
+}
+
+// ShareReputation registers the reputation engine whose blocks are sent
+// to and received from the other members. It must be called before Run
+// and has no effect unless Config.Reputation is enabled.
+func (f *Fleet) ShareReputation(src ReputationSource) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reputation = src
+}
+
+func (f *Fleet) sharesReputation() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reputation != nil && f.cfg.Reputation.Enabled
+}
+
+// publishReputation sends this member's sources to every peer in
+// parallel.
+func (f *Fleet) publishReputation(ctx context.Context) {
+	f.mu.Lock()
+	src := f.reputation
+	f.mu.Unlock()
+
+	rc := f.cfg.Reputation
+	update := reputationUpdate{
+		Node:    f.cfg.NodeID,
+		TTLSec:  rc.TTLSec,
+		Sources: highest(src.Offenders(rc.MinScore), rc.MaxEntries),
+	}
+
+	var (
+		wg      sync.WaitGroup
+		errMu   sync.Mutex
+		lastErr string
+	)
+	for _, addr := range f.cfg.Peers {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			if err := f.post(ctx, addr, reputationPath, update, nil); err != nil {
+				errMu.Lock()
+				lastErr = fmt.Sprintf("%s: %v", addr, err)
+				errMu.Unlock()
+			}
+		}(addr)
+	}
+	wg.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if lastErr != "" && lastErr != f.repStatus.PublishError {
+		f.log.Warn("sharing reputation blocks failed", zap.String("error", lastErr))
+	}
+	f.repStatus.Published = len(update.Sources)
+	f.repStatus.LastPublish = f.now()
+	f.repStatus.PublishError = lastErr
+}
+
+func (f *Fleet) handleReputation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !f.sharesReputation() {
+		http.Error(w, "reputation sharing disabled", http.StatusNotFound)
+		return
+	}
+	var req reputationUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(&req); err != nil {
+		http.Error(w, "invalid reputation update", http.StatusBadRequest)
+		return
+	}
+	if req.Node == "" || req.TTLSec == 0 {
+		http.Error(w, "node and ttlSec are required", http.StatusBadRequest)
+		return
+	}
+	if req.Node == f.cfg.NodeID {
+		http.Error(w, "member uses the same node_id", http.StatusConflict)
+		return
+	}
+
+	sources := highest(req.Sources, f.cfg.Reputation.MaxEntries)
+	f.mu.Lock()
+	src := f.reputation
+	f.mu.Unlock()
+	if err := src.SetShared(req.Node, sources, time.Duration(req.TTLSec)*time.Second); err != nil {
+		f.log.Warn("applying shared reputation blocks failed", zap.String("origin", req.Node), zap.Error(err))
+	}
+
+	f.mu.Lock()
+	f.repStatus.Received[req.Node] = len(sources)
+	f.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// highest returns the n highest scoring sources, ties broken by IP.
+func highest(scores map[string]uint32, n int) map[string]uint32 {
+	if len(scores) <= n {
+		return scores
+	}
+	ips := make([]string, 0, len(scores))
+	for ip := range scores {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		if scores[ips[i]] != scores[ips[j]] {
+			return scores[ips[i]] > scores[ips[j]]
+		}
+		return ips[i] < ips[j]
+	})
+	top := make(map[string]uint32, n)
+	for _, ip := range ips[:n] {
+		top[ip] = scores[ip]
+	}
+	return top
+}
@@ -0,0 +1,44 @@
+package geoip
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// asnRecord is the subset of a GeoLite2-ASN record used for lookups.
+type asnRecord struct {
+	Number       uint32 `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// ASNDB resolves addresses to their autonomous system from a GeoLite2-ASN
+// (or compatible) MaxMind database.
+type ASNDB struct {
+	db *maxminddb.Reader
+}
+
+// OpenASN opens a GeoLite2-ASN.mmdb file.
+func OpenASN(path string) (*ASNDB, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening ASN database: %w", err)
+	}
+	return &ASNDB{db: db}, nil
+}
+
+// Lookup returns the AS number and organization announcing ip, or 0 if it
+// is not in the database.
+func (a *ASNDB) Lookup(ip net.IP) (uint32, string) {
+	var rec asnRecord
+	if err := a.db.Lookup(ip, &rec); err != nil {
+		return 0, ""
+	}
+	return rec.Number, rec.Organization
+}
+
+// Close releases the database.
+func (a *ASNDB) Close() error {
+	return a.db.Close()
+}
@@ -0,0 +1,345 @@
+package geoip
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/oschwald/maxminddb-golang"
+	"go.uber.org/zap"
+)
+
+// asnEntry matches struct asn_entry in types.h.
+type asnEntry struct {
+	ASN uint32
+}
+
+// ASNPolicy is the action applied to traffic originating from an AS.
+type ASNPolicy struct {
+	ASN          uint32
+	Organization string
+	Action       uint8
+}
+
+// ASNManager populates the BPF asn_map and asn_policy maps so whole
+// autonomous systems can be dropped, rate-limited or monitored the same
+// way countries are.
+type ASNManager struct {
+	log       *zap.Logger
+	asnMap    *ebpf.Map
+	policyMap *ebpf.Map
+
+	mu             sync.RWMutex
+	policies       map[uint32]uint8  // ASN → action
+	orgs           map[uint32]string // ASN → organization, when the data has it
+	loadedPrefixes int
+}
+
+// NewASNManager creates an ASN manager that operates on the given BPF maps.
+func NewASNManager(log *zap.Logger, asnMap, policyMap *ebpf.Map) *ASNManager {
+	return &ASNManager{
+		log:       log,
+		asnMap:    asnMap,
+		policyMap: policyMap,
+		policies:  make(map[uint32]uint8),
+		orgs:      make(map[uint32]string),
+	}
+}
+
+// Load loads prefix to ASN data, detecting the format from the file
+// extension: ".mmdb" is a GeoLite2-ASN database, ".csv" is the
+// GeoLite2-ASN-Blocks-IPv4.csv export, and anything else is a text file of
+// "prefix ASN" lines as produced from RIR or RouteViews dumps.
+func (a *ASNManager) Load(path string) error {
+	var (
+		loaded int
+		err    error
+	)
+	w := bpf.NewBatchWriter[lpmKeyV4, asnEntry](a.asnMap, 0)
+	w.OnError(func(key lpmKeyV4, err error) {
+		a.log.Debug("failed to insert asn entry", zap.String("cidr", formatLPMKey(key)), zap.Error(err))
+	})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mmdb":
+		loaded, err = a.loadMMDB(w, path)
+	case ".csv":
+		loaded, err = a.loadCSV(w, path)
+	default:
+		loaded, err = a.loadText(w, path)
+	}
+	w.Flush()
+	loaded -= w.Failed()
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	a.loadedPrefixes = loaded
+	a.mu.Unlock()
+
+	a.log.Info("asn data loaded",
+		zap.Int("prefixes", loaded),
+		zap.String("file", path),
+	)
+	return nil
+}
+
+func (a *ASNManager) loadMMDB(w *asnWriter, path string) (int, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("opening mmdb: %w", err)
+	}
+	defer db.Close()
+
+	allIPv4 := &net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}
+	networks := db.NetworksWithin(allIPv4, maxminddb.SkipAliasedNetworks)
+
+	loaded := 0
+	for networks.Next() {
+		var rec asnRecord
+		subnet, err := networks.Network(&rec)
+		if err != nil {
+			return loaded, fmt.Errorf("decoding mmdb record: %w", err)
+		}
+		if a.insert(w, subnet, rec.Number, rec.Organization) {
+			loaded++
+		}
+	}
+	if err := networks.Err(); err != nil {
+		return loaded, fmt.Errorf("iterating mmdb networks: %w", err)
+	}
+	return loaded, nil
+}
+
+// loadCSV parses GeoLite2-ASN-Blocks-IPv4.csv.
+// Expected columns: network, autonomous_system_number, autonomous_system_organization
+func (a *ASNManager) loadCSV(w *asnWriter, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("opening asn file: %w", err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	header, err := reader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("reading asn header: %w", err)
+	}
+
+	networkIdx, asnIdx, orgIdx := -1, -1, -1
+	for i, col := range header {
+		switch strings.TrimSpace(col) {
+		case "network":
+			networkIdx = i
+		case "autonomous_system_number":
+			asnIdx = i
+		case "autonomous_system_organization":
+			orgIdx = i
+		}
+	}
+	if networkIdx < 0 || asnIdx < 0 {
+		return 0, fmt.Errorf("asn CSV missing required columns (network, autonomous_system_number)")
+	}
+
+	loaded := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil || networkIdx >= len(record) || asnIdx >= len(record) {
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(record[networkIdx]))
+		if err != nil {
+			continue
+		}
+		asn, err := ParseASN(record[asnIdx])
+		if err != nil {
+			continue
+		}
+		org := ""
+		if orgIdx >= 0 && orgIdx < len(record) {
+			org = strings.TrimSpace(record[orgIdx])
+		}
+		if a.insert(w, ipNet, asn, org) {
+			loaded++
+		}
+	}
+	return loaded, nil
+}
+
+// loadText parses one "prefix ASN" pair per line, separated by whitespace
+// or a comma. Blank lines and lines starting with '#' or ';' are skipped.
+func (a *ASNManager) loadText(w *asnWriter, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("opening asn file: %w", err)
+	}
+	defer f.Close()
+
+	loaded := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) < 2 {
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(fields[0])
+		if err != nil {
+			continue
+		}
+		asn, err := ParseASN(fields[1])
+		if err != nil {
+			continue
+		}
+		if a.insert(w, ipNet, asn, "") {
+			loaded++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return loaded, fmt.Errorf("reading asn file: %w", err)
+	}
+	return loaded, nil
+}
+
+// asnWriter batches writes to asn_map.
+type asnWriter = bpf.BatchWriter[lpmKeyV4, asnEntry]
+
+// insert queues an IPv4 prefix for asn_map and reports whether it was
+// accepted.
+func (a *ASNManager) insert(w *asnWriter, ipNet *net.IPNet, asn uint32, org string) bool {
+	ip := ipNet.IP.To4()
+	if ip == nil || asn == 0 {
+		return false
+	}
+
+	ones, _ := ipNet.Mask.Size()
+	key := lpmKeyV4{
+		PrefixLen: uint32(ones),
+		Addr:      ipToU32BE(ip),
+	}
+	w.Update(key, asnEntry{ASN: asn})
+
+	if org != "" {
+		a.mu.Lock()
+		a.orgs[asn] = org
+		a.mu.Unlock()
+	}
+	return true
+}
+
+// SetPolicy sets the action for an AS (e.g., 64496 -> DROP).
+func (a *ASNManager) SetPolicy(asn uint32, action uint8) error {
+	if asn == 0 {
+		return fmt.Errorf("asn must be non-zero")
+	}
+	if action > ActionMonitor {
+		return fmt.Errorf("invalid action %d: must be 0-3", action)
+	}
+
+	if err := a.policyMap.Update(asn, action, ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("updating asn policy for AS%d: %w", asn, err)
+	}
+
+	a.mu.Lock()
+	a.policies[asn] = action
+	a.mu.Unlock()
+
+	a.log.Info("asn policy set",
+		zap.Uint32("asn", asn),
+		zap.String("action", ActionName(action)),
+	)
+	return nil
+}
+
+// RemovePolicy removes the policy for an AS so its traffic passes again.
+func (a *ASNManager) RemovePolicy(asn uint32) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.policies[asn]; !ok {
+		return fmt.Errorf("no policy for AS%d", asn)
+	}
+	if err := a.policyMap.Delete(asn); err != nil {
+		return fmt.Errorf("deleting asn policy for AS%d: %w", asn, err)
+	}
+	delete(a.policies, asn)
+
+	a.log.Info("asn policy removed", zap.Uint32("asn", asn))
+	return nil
+}
+
+// GetPolicies returns all configured ASN policies ordered by AS number.
+func (a *ASNManager) GetPolicies() []ASNPolicy {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	result := make([]ASNPolicy, 0, len(a.policies))
+	for asn, action := range a.policies {
+		result = append(result, ASNPolicy{ASN: asn, Organization: a.orgs[asn], Action: action})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ASN < result[j].ASN })
+	return result
+}
+
+// Policy returns the action configured for an AS, if any.
+func (a *ASNManager) Policy(asn uint32) (uint8, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	action, ok := a.policies[asn]
+	return action, ok
+}
+
+// Lookup returns the origin AS of ip from asn_map and its organization if
+// known, or 0 if the address is not covered by the loaded data.
+func (a *ASNManager) Lookup(ip net.IP) (uint32, string) {
+	var entry asnEntry
+	key := lpmKeyV4{PrefixLen: 32, Addr: ipToU32BE(ip)}
+	if err := a.asnMap.Lookup(key, &entry); err != nil {
+		return 0, ""
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return entry.ASN, a.orgs[entry.ASN]
+}
+
+// GetLoadedPrefixes returns the number of loaded CIDR prefixes.
+func (a *ASNManager) GetLoadedPrefixes() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.loadedPrefixes
+}
+
+// ParseASN parses a non-zero AS number written as "64496" or "AS64496".
+func ParseASN(s string) (uint32, error) {
+	s = strings.TrimSpace(s)
+	digits := s
+	if len(s) > 2 && strings.EqualFold(s[:2], "AS") {
+		digits = s[2:]
+	}
+	n, err := strconv.ParseUint(digits, 10, 32)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid ASN %q", s)
+	}
+	return uint32(n), nil
+}
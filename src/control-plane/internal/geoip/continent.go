@@ -0,0 +1,211 @@
+package geoip
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// continentNames are the continent codes used by MaxMind data.
+//
+// A continent policy applies to every country of the continent, as the
+// loaded data places them, that has no policy of its own: country, level
+// and temporary policies all take precedence. Regions name groups of
+// continents, such as APAC for AS and OC, so a policy can cover them at
+// once.
+var continentNames = map[string]string{
+	"AF": "Africa",
+	"AN": "Antarctica",
+	"AS": "Asia",
+	"EU": "Europe",
+	"NA": "North America",
+	"OC": "Oceania",
+	"SA": "South America",
+}
+
+// IsContinent reports whether code is a continent code.
+func IsContinent(code string) bool {
+	_, ok := continentNames[strings.ToUpper(code)]
+	return ok
+}
+
+// ValidateRegions checks that region names do not shadow continent codes
+// and that each region lists continent codes.
+func ValidateRegions(regions map[string][]string) error {
+	for name, continents := range regions {
+		if _, ok := continentNames[strings.ToUpper(name)]; ok {
+			return fmt.Errorf("region %q shadows a continent code", name)
+		}
+		if len(continents) == 0 {
+			return fmt.Errorf("region %q lists no continents", name)
+		}
+		for _, c := range continents {
+			if _, ok := continentNames[strings.ToUpper(c)]; !ok {
+				return fmt.Errorf("region %q: unknown continent %q (must be AF, AN, AS, EU, NA, OC or SA)", name, c)
+			}
+		}
+	}
+	return nil
+}
+
+// SetRegions replaces the named continent groups that SetContinentPolicy
+// accepts besides continent codes.
+func (m *Manager) SetRegions(regions map[string][]string) error {
+	if err := ValidateRegions(regions); err != nil {
+		return err
+	}
+	parsed := make(map[string][]string, len(regions))
+	for name, continents := range regions {
+		for _, c := range continents {
+			parsed[strings.ToUpper(name)] = append(parsed[strings.ToUpper(name)], strings.ToUpper(c))
+		}
+	}
+
+	m.mu.Lock()
+	m.regions = parsed
+	m.mu.Unlock()
+	return nil
+}
+
+// GetRegions returns the configured regions.
+func (m *Manager) GetRegions() map[string][]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make(map[string][]string, len(m.regions))
+	for name, continents := range m.regions {
+		result[name] = append([]string(nil), continents...)
+	}
+	return result
+}
+
+// SetContinentPolicy sets the action for every country of a continent, or
+// of each continent in a region, that has no policy of its own.
+func (m *Manager) SetContinentPolicy(name string, action uint8) error {
+	if action > ActionMonitor {
+		return fmt.Errorf("invalid action %d: must be 0-3", action)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	continents, err := m.resolveContinentsLocked(name)
+	if err != nil {
+		return err
+	}
+	prev := make(map[string]uint8, len(m.continentPolicies))
+	for c, a := range m.continentPolicies {
+		prev[c] = a
+	}
+	for _, c := range continents {
+		m.continentPolicies[c] = action
+	}
+	if err := m.programAllLocked(m.continentCountriesLocked(continents)); err != nil {
+		m.continentPolicies = prev
+		m.programAllLocked(m.continentCountriesLocked(continents))
+		return err
+	}
+	m.auditLocked("continent_policy", fmt.Sprintf("continent=%s action=%s", strings.Join(continents, ","), ActionName(action)))
+
+	m.log.Info("geoip continent policy set",
+		zap.String("name", strings.ToUpper(name)),
+		zap.Strings("continents", continents),
+		zap.String("action", ActionName(action)),
+	)
+	return nil
+}
+
+// RemoveContinentPolicy removes the policy of a continent, or of each
+// continent in a region.
+func (m *Manager) RemoveContinentPolicy(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	continents, err := m.resolveContinentsLocked(name)
+	if err != nil {
+		return err
+	}
+	removed := false
+	for _, c := range continents {
+		if _, ok := m.continentPolicies[c]; ok {
+			delete(m.continentPolicies, c)
+			removed = true
+		}
+	}
+	if !removed {
+		return fmt.Errorf("no policy for %s", strings.ToUpper(name))
+	}
+	m.auditLocked("remove_continent_policy", "continent="+strings.Join(continents, ","))
+	if err := m.programAllLocked(m.continentCountriesLocked(continents)); err != nil {
+		return err
+	}
+
+	m.log.Info("geoip continent policy removed", zap.String("name", strings.ToUpper(name)))
+	return nil
+}
+
+// GetContinentPolicies returns the policy of every continent that has one.
+func (m *Manager) GetContinentPolicies() map[string]uint8 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make(map[string]uint8, len(m.continentPolicies))
+	for c, action := range m.continentPolicies {
+		result[c] = action
+	}
+	return result
+}
+
+// GetContinent returns the continent code of a country, or "" if the
+// loaded data does not place it.
+func (m *Manager) GetContinent(country string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.continents[strings.ToUpper(country)]
+}
+
+// resolveContinentsLocked expands a continent code or region name.
+func (m *Manager) resolveContinentsLocked(name string) ([]string, error) {
+	name = strings.ToUpper(name)
+	if _, ok := continentNames[name]; ok {
+		return []string{name}, nil
+	}
+	if continents, ok := m.regions[name]; ok {
+		return continents, nil
+	}
+	return nil, fmt.Errorf("unknown continent or region %q", name)
+}
+
+// continentCountriesLocked returns the countries of the given continents,
+// or of every continent with a policy if none are given.
+func (m *Manager) continentCountriesLocked(continents []string) map[string]bool {
+	want := make(map[string]bool)
+	for _, c := range continents {
+		want[c] = true
+	}
+	if len(continents) == 0 {
+		for c := range m.continentPolicies {
+			want[c] = true
+		}
+	}
+	set := make(map[string]bool)
+	for cc, c := range m.continents {
+		if want[c] {
+			set[cc] = true
+		}
+	}
+	return set
+}
+
+// setContinentLocked records a country's continent from loaded data. The
+// first continent seen for a country is kept.
+func (m *Manager) setContinentLocked(cc, continent string) {
+	continent = strings.ToUpper(continent)
+	if _, ok := continentNames[continent]; !ok {
+		return
+	}
+	if _, ok := m.continents[cc]; !ok {
+		m.continents[cc] = continent
+	}
+}
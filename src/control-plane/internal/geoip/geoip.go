@@ -3,18 +3,26 @@
 package geoip
 
 import (
+	"context"
 	"encoding/binary"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/cilium/ebpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/progress"
 	"go.uber.org/zap"
 )
 
@@ -26,6 +34,31 @@ const (
 	ActionMonitor   uint8 = 3
 )
 
+var actionNames = map[uint8]string{
+	ActionPass:      "pass",
+	ActionDrop:      "drop",
+	ActionRateLimit: "rate_limit",
+	ActionMonitor:   "monitor",
+}
+
+// ParseAction returns the action named pass, drop, rate_limit or monitor.
+func ParseAction(name string) (uint8, error) {
+	for action, n := range actionNames {
+		if strings.EqualFold(name, n) {
+			return action, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid action %q (must be pass, drop, rate_limit or monitor)", name)
+}
+
+// ActionName returns the name of an action.
+func ActionName(action uint8) string {
+	if n, ok := actionNames[action]; ok {
+		return n
+	}
+	return fmt.Sprintf("unknown(%d)", action)
+}
+
 // lpmKeyV4 matches struct lpm_key_v4 in the BPF program.
 type lpmKeyV4 struct {
 	PrefixLen uint32
@@ -39,9 +72,18 @@ type geoipEntry struct {
 	Pad         uint8
 }
 
+// countryCounters matches struct country_stats in types.h.
+type countryCounters struct {
+	Packets     uint64
+	Dropped     uint64
+	RateLimited uint64
+	Monitored   uint64
+}
+
 // CountryStats holds drop statistics per country.
 type CountryStats struct {
 	Country     string
+	Packets     uint64
 	Drops       uint64
 	RateLimited uint64
 	Monitored   uint64
@@ -52,67 +94,98 @@ type Manager struct {
 	log          *zap.Logger
 	geoipMap     *ebpf.Map
 	policyMap    *ebpf.Map
+	rateMap      *ebpf.Map // geoip_country_rate
+	bucketMap    *ebpf.Map // geoip_country_bucket
+	statsMap     *ebpf.Map // geoip_country_stats
 
 	mu           sync.RWMutex
 	policies     map[string]uint8          // country code → action
-	geonameToCC  map[int]string            // geoname_id → country code (e.g. "US")
+	temp         map[string]TempPolicy     // country code → policy until it expires
+	levelPolicies []levelPolicy
+	level        escalation.Level
+	audit        []AuditEntry
+	rates        map[string]uint64         // country code → pps across all CPUs
+	continents   map[string]string         // country code → continent code, from loaded data
+	continentPolicies map[string]uint8     // continent code → action
+	regions      map[string][]string       // region name → continent codes
 	loadedPrefixes int
-	countryStats map[string]*CountryStats  // country code → stats
+	database     string                    // Source of the loaded data
+	locations    string
+	loadedAt     time.Time
+
+	loadMu       sync.Mutex                // Held for the duration of a load
+	shadow       prefixIndex               // What geoip_map holds; guarded by loadMu
+	progMu       sync.Mutex
+	progress     *LoadProgress             // Load in progress, if any
+	cancelLoad   context.CancelFunc
+}
+
+// DatabaseInfo describes the loaded country data.
+type DatabaseInfo struct {
+	Database  string // .mmdb or blocks CSV
+	Locations string // Locations CSV, with a blocks CSV
+	LoadedAt  time.Time
+	Prefixes  int
 }
 
 // NewManager creates a geoip manager that operates on the given BPF maps.
-func NewManager(log *zap.Logger, geoipMap, policyMap *ebpf.Map) *Manager {
+func NewManager(log *zap.Logger, geoipMap, policyMap, rateMap, bucketMap, statsMap *ebpf.Map) *Manager {
 	return &Manager{
 		log:          log,
 		geoipMap:     geoipMap,
 		policyMap:    policyMap,
+		rateMap:      rateMap,
+		bucketMap:    bucketMap,
+		statsMap:     statsMap,
 		policies:     make(map[string]uint8),
-		geonameToCC:  make(map[int]string),
-		countryStats: make(map[string]*CountryStats),
+		temp:         make(map[string]TempPolicy),
+		rates:        make(map[string]uint64),
+		continents:   make(map[string]string),
+		continentPolicies: make(map[string]uint8),
+		regions:      make(map[string][]string),
 	}
 }
 
-// LoadCSV loads GeoLite2-Country-Blocks-IPv4.csv and GeoLite2-Country-Locations-en.csv.
+// readCSV reads GeoLite2-Country-Blocks-IPv4.csv and
+// GeoLite2-Country-Locations-en.csv.
 //
 // The locations file maps geoname_id to country_iso_code.
 // The blocks file maps CIDR → geoname_id.
 //
-// For each network block, an LPM trie entry is created in geoip_map mapping
-// the CIDR prefix to the packed country code.
-func (m *Manager) LoadCSV(blocksPath, locationsPath string) error {
+// It returns the country of every network block, keyed by its LPM trie
+// prefix, and the continent of every country.
+func (m *Manager) readCSV(ctx context.Context, blocksPath, locationsPath string, tr *progress.Tracker) (prefixIndex, map[string]string, error) {
 	// Step 1: Load locations to build geoname_id → country_code mapping.
-	if err := m.loadLocations(locationsPath); err != nil {
-		return fmt.Errorf("loading locations: %w", err)
+	geonameToCC, countries, err := m.loadLocations(locationsPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading locations: %w", err)
 	}
 
-	// Step 2: Load blocks and populate BPF map.
-	loaded, err := m.loadBlocks(blocksPath)
+	// Step 2: Load blocks.
+	idx, err := m.loadBlocks(ctx, blocksPath, geonameToCC, tr)
 	if err != nil {
-		return fmt.Errorf("loading blocks: %w", err)
+		return nil, nil, fmt.Errorf("loading blocks: %w", err)
 	}
 
-	m.mu.Lock()
-	m.loadedPrefixes = loaded
-	m.mu.Unlock()
-
-	m.log.Info("geoip data loaded",
-		zap.Int("prefixes", loaded),
-		zap.Int("countries", len(m.geonameToCC)),
+	m.log.Info("geoip data read",
+		zap.Int("prefixes", len(idx)),
+		zap.Int("countries", len(countries)),
 		zap.String("blocks_file", blocksPath),
 		zap.String("locations_file", locationsPath),
 	)
 
-	return nil
+	return idx, countries, nil
 }
 
-// loadLocations parses GeoLite2-Country-Locations-en.csv.
+// loadLocations parses GeoLite2-Country-Locations-en.csv into geoname_id →
+// country code and country code → continent code maps.
 // Expected columns: geoname_id, locale_code, continent_code, continent_name,
 //
 //	country_iso_code, country_name, is_in_european_union
-func (m *Manager) loadLocations(path string) error {
+func (m *Manager) loadLocations(path string) (map[int]string, map[string]string, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("opening locations file: %w", err)
+		return nil, nil, fmt.Errorf("opening locations file: %w", err)
 	}
 	defer f.Close()
 
@@ -121,34 +194,36 @@ func (m *Manager) loadLocations(path string) error {
 	// Read and validate header.
 	header, err := reader.Read()
 	if err != nil {
-		return fmt.Errorf("reading locations header: %w", err)
+		return nil, nil, fmt.Errorf("reading locations header: %w", err)
 	}
 
 	geonameIdx := -1
 	countryIdx := -1
+	continentIdx := -1
 	for i, col := range header {
 		switch strings.TrimSpace(col) {
 		case "geoname_id":
 			geonameIdx = i
 		case "country_iso_code":
 			countryIdx = i
+		case "continent_code":
+			continentIdx = i
 		}
 	}
 
 	if geonameIdx < 0 || countryIdx < 0 {
-		return fmt.Errorf("locations CSV missing required columns (geoname_id, country_iso_code)")
+		return nil, nil, fmt.Errorf("locations CSV missing required columns (geoname_id, country_iso_code)")
 	}
 
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
+	geonameToCC := make(map[int]string)
+	countries := make(map[string]string)
 	for {
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return fmt.Errorf("reading locations record: %w", err)
+			return nil, nil, fmt.Errorf("reading locations record: %w", err)
 		}
 
 		if geonameIdx >= len(record) || countryIdx >= len(record) {
@@ -165,79 +240,144 @@ func (m *Manager) loadLocations(path string) error {
 			continue
 		}
 
-		m.geonameToCC[geonameID] = strings.ToUpper(cc)
+		cc = strings.ToUpper(cc)
+		geonameToCC[geonameID] = cc
+		if continentIdx >= 0 && continentIdx < len(record) && countries[cc] == "" {
+			countries[cc] = strings.TrimSpace(record[continentIdx])
+		}
 	}
 
-	return nil
+	return geonameToCC, countries, nil
 }
 
-// loadBlocks parses GeoLite2-Country-Blocks-IPv4.csv and inserts entries into geoip_map.
+// blockColumns are the column indexes of a blocks CSV, -1 if absent.
+type blockColumns struct {
+	network, geoname, regGeoname int
+}
+
+// loadBlocks parses GeoLite2-Country-Blocks-IPv4.csv. The file is streamed
+// in chunks of records to a pool of workers that resolve them, so memory
+// holds the index and a few chunks rather than the whole file.
 // Expected columns: network, geoname_id, registered_country_geoname_id, ...
-func (m *Manager) loadBlocks(path string) (int, error) {
+func (m *Manager) loadBlocks(ctx context.Context, path string, geonameToCC map[int]string, tr *progress.Tracker) (prefixIndex, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return 0, fmt.Errorf("opening blocks file: %w", err)
+		return nil, fmt.Errorf("opening blocks file: %w", err)
 	}
 	defer f.Close()
+	if info, err := f.Stat(); err == nil {
+		tr.SetTotal(info.Size())
+	}
 
-	reader := csv.NewReader(f)
+	reader := csv.NewReader(tr.Reader(f))
 
 	header, err := reader.Read()
 	if err != nil {
-		return 0, fmt.Errorf("reading blocks header: %w", err)
+		return nil, fmt.Errorf("reading blocks header: %w", err)
 	}
 
-	networkIdx := -1
-	geonameIdx := -1
-	regGeonameIdx := -1
+	cols := blockColumns{network: -1, geoname: -1, regGeoname: -1}
 	for i, col := range header {
 		switch strings.TrimSpace(col) {
 		case "network":
-			networkIdx = i
+			cols.network = i
 		case "geoname_id":
-			geonameIdx = i
+			cols.geoname = i
 		case "registered_country_geoname_id":
-			regGeonameIdx = i
+			cols.regGeoname = i
 		}
 	}
 
-	if networkIdx < 0 {
-		return 0, fmt.Errorf("blocks CSV missing 'network' column")
+	if cols.network < 0 {
+		return nil, fmt.Errorf("blocks CSV missing 'network' column")
 	}
 
-	m.mu.RLock()
-	geonameToCC := m.geonameToCC
-	m.mu.RUnlock()
+	workers := runtime.GOMAXPROCS(0)
+	chunks := make(chan [][]string, workers)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(chunks)
+		readErr <- readChunks(ctx, reader, chunks)
+	}()
+
+	results := make(chan []prefixEntry, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				results <- resolveBlocks(chunk, cols, geonameToCC)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	idx, _ := m.mergeEntries(results)
+	if err := <-readErr; err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
 
-	loaded := 0
+// readChunks sends the records of reader to chunks, progressStep at a
+// time. Malformed records are skipped.
+func readChunks(ctx context.Context, reader *csv.Reader, chunks chan<- [][]string) error {
+	chunk := make([][]string, 0, progressStep)
 	for {
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
-		if err != nil {
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
 			continue
 		}
+		if err != nil {
+			return fmt.Errorf("reading blocks: %w", err)
+		}
+
+		chunk = append(chunk, record)
+		if len(chunk) == progressStep {
+			if ctx.Err() != nil {
+				return ErrLoadCanceled
+			}
+			chunks <- chunk
+			chunk = make([][]string, 0, progressStep)
+		}
+	}
+	if len(chunk) > 0 {
+		chunks <- chunk
+	}
+	return nil
+}
 
-		if networkIdx >= len(record) {
+// resolveBlocks resolves the network and country of each blocks record.
+func resolveBlocks(records [][]string, cols blockColumns, geonameToCC map[int]string) []prefixEntry {
+	entries := make([]prefixEntry, 0, len(records))
+	for _, record := range records {
+		if cols.network >= len(record) {
 			continue
 		}
 
-		cidr := strings.TrimSpace(record[networkIdx])
+		cidr := strings.TrimSpace(record[cols.network])
 		_, ipNet, err := net.ParseCIDR(cidr)
-		if err != nil {
+		if err != nil || ipNet.IP.To4() == nil {
 			continue
 		}
 
 		// Resolve geoname_id to country code; fall back to registered_country_geoname_id.
 		cc := ""
-		if geonameIdx >= 0 && geonameIdx < len(record) {
-			if gid, err := strconv.Atoi(strings.TrimSpace(record[geonameIdx])); err == nil {
+		if cols.geoname >= 0 && cols.geoname < len(record) {
+			if gid, err := strconv.Atoi(strings.TrimSpace(record[cols.geoname])); err == nil {
 				cc = geonameToCC[gid]
 			}
 		}
-		if cc == "" && regGeonameIdx >= 0 && regGeonameIdx < len(record) {
-			if gid, err := strconv.Atoi(strings.TrimSpace(record[regGeonameIdx])); err == nil {
+		if cc == "" && cols.regGeoname >= 0 && cols.regGeoname < len(record) {
+			if gid, err := strconv.Atoi(strings.TrimSpace(record[cols.regGeoname])); err == nil {
 				cc = geonameToCC[gid]
 			}
 		}
@@ -246,30 +386,29 @@ func (m *Manager) loadBlocks(path string) (int, error) {
 		}
 
 		ones, _ := ipNet.Mask.Size()
-		key := lpmKeyV4{
-			PrefixLen: uint32(ones),
-			Addr:      ipToU32BE(ipNet.IP),
-		}
-
-		entry := geoipEntry{
-			CountryCode: packCountryCode(cc),
-			Action:      ActionPass, // Default action; policy map overrides per-country.
-		}
-
-		if err := m.geoipMap.Update(key, entry, ebpf.UpdateAny); err != nil {
-			// Log at debug level since individual failures are common for large datasets.
-			m.log.Debug("failed to insert geoip entry",
-				zap.String("cidr", cidr),
-				zap.String("country", cc),
-				zap.Error(err),
-			)
-			continue
-		}
-
-		loaded++
+		entries = append(entries, prefixEntry{
+			key: lpmKeyV4{
+				PrefixLen: uint32(ones),
+				Addr:      ipToU32BE(ipNet.IP),
+			},
+			country: packCountryCode(cc),
+		})
 	}
+	return entries
+}
 
-	return loaded, nil
+// newGeoIPWriter returns a batch writer for geoip_map. Failed entries are
+// logged at debug level since individual failures are common for large
+// datasets. A failed key is left in an unknown state, so its shadow entry
+// is set to country code 0, which no data uses: the next load writes or
+// removes it again. Only Load may use the writer.
+func (m *Manager) newGeoIPWriter() *bpf.BatchWriter[lpmKeyV4, geoipEntry] {
+	w := bpf.NewBatchWriter[lpmKeyV4, geoipEntry](m.geoipMap, 0)
+	w.OnError(func(key lpmKeyV4, err error) {
+		m.shadow[key] = 0
+		m.log.Debug("failed to write geoip entry", zap.String("cidr", formatLPMKey(key)), zap.Error(err))
+	})
+	return w
 }
 
 // SetCountryPolicy sets the action for a country code (e.g., "CN" -> DROP).
@@ -283,14 +422,20 @@ func (m *Manager) SetCountryPolicy(country string, action uint8) error {
 	}
 
 	cc := strings.ToUpper(country)
-	packed := packCountryCode(cc)
-
-	if err := m.policyMap.Update(packed, action, ebpf.UpdateAny); err != nil {
-		return fmt.Errorf("updating geoip policy for %s: %w", cc, err)
-	}
 
 	m.mu.Lock()
+	prev, had := m.policies[cc]
 	m.policies[cc] = action
+	if err := m.programLocked(cc); err != nil {
+		if had {
+			m.policies[cc] = prev
+		} else {
+			delete(m.policies, cc)
+		}
+		m.mu.Unlock()
+		return err
+	}
+	m.auditLocked("set_policy", fmt.Sprintf("country=%s action=%s", cc, ActionName(action)))
 	m.mu.Unlock()
 
 	m.log.Info("geoip policy set",
@@ -301,7 +446,32 @@ func (m *Manager) SetCountryPolicy(country string, action uint8) error {
 	return nil
 }
 
-// GetCountryPolicy returns the current policy for all configured countries.
+// RemoveCountryPolicy removes the policy set with SetCountryPolicy for a
+// country. A temporary or level policy for the country stays in force.
+func (m *Manager) RemoveCountryPolicy(country string) error {
+	cc := strings.ToUpper(country)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prev, ok := m.policies[cc]
+	if !ok {
+		return fmt.Errorf("no policy for %s", cc)
+	}
+	delete(m.policies, cc)
+	if err := m.programLocked(cc); err != nil {
+		m.policies[cc] = prev
+		return err
+	}
+	m.auditLocked("remove_policy", "country="+cc)
+
+	m.log.Info("geoip policy removed", zap.String("country", cc))
+	return nil
+}
+
+// GetCountryPolicy returns the policy set with SetCountryPolicy for all
+// configured countries. Temporary and level policies may override it; see
+// GetActivePolicy.
 func (m *Manager) GetCountryPolicy() map[string]uint8 {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -313,36 +483,117 @@ func (m *Manager) GetCountryPolicy() map[string]uint8 {
 	return result
 }
 
-// GetCountryStats returns drop statistics per country.
-func (m *Manager) GetCountryStats() []CountryStats {
+// SetCountryRate sets the packet rate allowed from a country while its
+// policy is ActionRateLimit; 0 removes the limit so the policy falls back
+// to halving per-source rates. The BPF token buckets are per CPU, so each
+// CPU is given an equal share of pps.
+func (m *Manager) SetCountryRate(country string, pps uint64) error {
+	if len(country) != 2 {
+		return fmt.Errorf("country code must be exactly 2 characters, got %q", country)
+	}
+	cc := strings.ToUpper(country)
+	packed := packCountryCode(cc)
+
+	if pps == 0 {
+		if err := m.rateMap.Delete(packed); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return fmt.Errorf("removing geoip rate for %s: %w", cc, err)
+		}
+	} else {
+		share := pps / uint64(possibleCPUs())
+		if share == 0 {
+			share = 1
+		}
+		if err := m.rateMap.Update(packed, share, ebpf.UpdateAny); err != nil {
+			return fmt.Errorf("updating geoip rate for %s: %w", cc, err)
+		}
+	}
+	// Start the next packet with a full bucket at the new rate.
+	m.bucketMap.Delete(packed)
+
+	m.mu.Lock()
+	if pps == 0 {
+		delete(m.rates, cc)
+	} else {
+		m.rates[cc] = pps
+	}
+	m.mu.Unlock()
+
+	m.log.Info("geoip rate limit set",
+		zap.String("country", cc),
+		zap.Uint64("pps", pps),
+	)
+
+	return nil
+}
+
+// GetCountryRates returns the configured per-country rate limits in pps.
+func (m *Manager) GetCountryRates() map[string]uint64 {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	result := make([]CountryStats, 0, len(m.countryStats))
-	for _, cs := range m.countryStats {
-		result = append(result, *cs)
+	result := make(map[string]uint64, len(m.rates))
+	for cc, pps := range m.rates {
+		result[cc] = pps
 	}
 	return result
 }
 
-// RecordDrop records a drop event for the given country code for stats tracking.
-func (m *Manager) RecordDrop(country string, action uint8) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// GetCountryStats reads the per-country counters from BPF, summed across
+// CPUs and ordered by country code.
+func (m *Manager) GetCountryStats() ([]CountryStats, error) {
+	var (
+		key    uint16
+		perCPU []countryCounters
+		result []CountryStats
+	)
+	iter := m.statsMap.Iterate()
+	for iter.Next(&key, &perCPU) {
+		cs := CountryStats{Country: unpackCountryCode(key)}
+		for _, c := range perCPU {
+			cs.Packets += c.Packets
+			cs.Drops += c.Dropped
+			cs.RateLimited += c.RateLimited
+			cs.Monitored += c.Monitored
+		}
+		result = append(result, cs)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("iterating geoip country stats: %w", err)
+	}
 
-	cs, ok := m.countryStats[country]
-	if !ok {
-		cs = &CountryStats{Country: country}
-		m.countryStats[country] = cs
+	sort.Slice(result, func(i, j int) bool { return result[i].Country < result[j].Country })
+	return result, nil
+}
+
+// possibleCPUs returns the number of per-CPU map slots, at least 1.
+func possibleCPUs() int {
+	n, err := ebpf.PossibleCPU()
+	if err != nil || n < 1 {
+		return 1
 	}
+	return n
+}
 
-	switch action {
-	case ActionDrop:
-		cs.Drops++
-	case ActionRateLimit:
-		cs.RateLimited++
-	case ActionMonitor:
-		cs.Monitored++
+// LookupCountry returns the country code of ip from geoip_map, or "" if
+// the address is not covered by the loaded data.
+func (m *Manager) LookupCountry(ip net.IP) string {
+	var entry geoipEntry
+	key := lpmKeyV4{PrefixLen: 32, Addr: ipToU32BE(ip)}
+	if err := m.geoipMap.Lookup(key, &entry); err != nil {
+		return ""
+	}
+	return unpackCountryCode(entry.CountryCode)
+}
+
+// GetDatabaseInfo returns the source and size of the loaded data.
+func (m *Manager) GetDatabaseInfo() DatabaseInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return DatabaseInfo{
+		Database:  m.database,
+		Locations: m.locations,
+		LoadedAt:  m.loadedAt,
+		Prefixes:  m.loadedPrefixes,
 	}
 }
 
@@ -371,6 +622,13 @@ func unpackCountryCode(packed uint16) string {
 	return string([]byte{byte(packed >> 8), byte(packed & 0xFF)})
 }
 
+// formatLPMKey renders an LPM key as a CIDR.
+func formatLPMKey(key lpmKeyV4) string {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, key.Addr)
+	return fmt.Sprintf("%s/%d", ip, key.PrefixLen)
+}
+
 // ipToU32BE converts a net.IP (IPv4) to a big-endian uint32.
 func ipToU32BE(ip net.IP) uint32 {
 	ip = ip.To4()
@@ -0,0 +1,244 @@
+package geoip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/progress"
+
+	"github.com/oschwald/maxminddb-golang"
+	"go.uber.org/zap"
+)
+
+// mmdbRecord is the subset of a GeoLite2-Country / GeoIP2-Country record
+// needed to resolve a network's country.
+type mmdbRecord struct {
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// ErrLoading is returned by Load while another load is in progress.
+var ErrLoading = errors.New("geoip data load already in progress")
+
+// Load loads GeoIP data, detecting the format from the file extension:
+// ".mmdb" files are read directly; anything else is treated as a GeoLite2
+// CSV blocks file and requires locationsPath.
+//
+// The data is read in full before geoip_map is touched, and only prefixes
+// that are new, moved to another country or no longer listed are written,
+// so lookups keep working while data is reloaded. CancelLoad stops a load:
+// while reading, nothing changes; while applying, the changes written so
+// far stay and the next load completes them.
+func (m *Manager) Load(path, locationsPath string) error {
+	if !m.loadMu.TryLock() {
+		return ErrLoading
+	}
+	defer m.loadMu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tr := m.startProgress(ctx, cancel, path)
+	defer m.endProgress()
+
+	idx, countries, err := m.readData(ctx, path, locationsPath, tr)
+	if err != nil {
+		return err
+	}
+	if isMMDB(path) {
+		locationsPath = ""
+	}
+
+	loaded, err := m.applyIndex(ctx, idx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.database, m.locations, m.loadedAt = path, locationsPath, time.Now()
+	m.loadedPrefixes = loaded
+
+	// Countries the data moves between continents, or places for the first
+	// time, may gain or lose a continent policy.
+	affected := m.continentCountriesLocked(nil)
+	m.continents = make(map[string]string, len(countries))
+	for cc, continent := range countries {
+		m.setContinentLocked(cc, continent)
+	}
+	for cc := range m.continentCountriesLocked(nil) {
+		affected[cc] = true
+	}
+	if err := m.programAllLocked(affected); err != nil {
+		m.log.Error("failed to apply geoip continent policies", zap.Error(err))
+	}
+	return nil
+}
+
+// isMMDB reports whether path is read as a MaxMind binary database rather
+// than GeoLite2 CSV.
+func isMMDB(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".mmdb")
+}
+
+// readData reads the prefixes and continents of the GeoIP data at path in
+// the format its extension names, as described at Load.
+func (m *Manager) readData(ctx context.Context, path, locationsPath string, tr *progress.Tracker) (prefixIndex, map[string]string, error) {
+	switch {
+	case isMMDB(path):
+		return m.readMMDB(ctx, path, tr)
+	case locationsPath == "":
+		return nil, nil, fmt.Errorf("locations file is required for CSV data %s", path)
+	default:
+		return m.readCSV(ctx, path, locationsPath, tr)
+	}
+}
+
+// Reload loads the data last loaded with Load again, picking up a file
+// updated in place.
+func (m *Manager) Reload() error {
+	m.mu.RLock()
+	path, locations := m.database, m.locations
+	m.mu.RUnlock()
+
+	if path == "" {
+		return fmt.Errorf("no geoip data loaded")
+	}
+	return m.Load(path, locations)
+}
+
+// mmdbShards is how many networks the IPv4 space is split into, so that
+// workers can read a .mmdb file in parallel.
+const mmdbShards = 16
+
+// readMMDB reads every IPv4 network of a MaxMind binary database
+// (GeoLite2-Country.mmdb). Unlike the CSV pair, no geoname_id lookup table
+// has to be built in memory. The database reader is safe for concurrent
+// use, so a pool of workers each reads a share of the address space.
+func (m *Manager) readMMDB(ctx context.Context, path string, tr *progress.Tracker) (prefixIndex, map[string]string, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening mmdb: %w", err)
+	}
+	defer db.Close()
+	tr.SetTotal(1 << 32) // Addresses
+
+	shards := make(chan *net.IPNet, mmdbShards)
+	for i := 0; i < mmdbShards; i++ {
+		shards <- &net.IPNet{
+			IP:   net.IPv4(byte(i*256/mmdbShards), 0, 0, 0).To4(),
+			Mask: net.CIDRMask(bits.TrailingZeros(mmdbShards), 32),
+		}
+	}
+	close(shards)
+
+	workers := runtime.GOMAXPROCS(0)
+	results := make(chan []prefixEntry, workers)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for shard := range shards {
+				if err := readMMDBShard(ctx, db, shard, tr, results); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	idx, countries := m.mergeEntries(results)
+	select {
+	case err := <-errs:
+		return nil, nil, err
+	default:
+	}
+
+	m.log.Info("geoip data read",
+		zap.Int("prefixes", len(idx)),
+		zap.Int("countries", len(countries)),
+		zap.String("mmdb_file", path),
+		zap.String("database_type", db.Metadata.DatabaseType),
+	)
+
+	return idx, countries, nil
+}
+
+// readMMDBShard sends the networks of db within shard to results,
+// progressStep at a time, recording the addresses covered in tr.
+func readMMDBShard(ctx context.Context, db *maxminddb.Reader, shard *net.IPNet, tr *progress.Tracker, results chan<- []prefixEntry) error {
+	ones, _ := shard.Mask.Size()
+	pos := int64(ipToU32BE(shard.IP)) // End of the last network read
+	end := pos + 1<<(32-ones)
+	defer func() { tr.Add(end - pos) }()
+
+	networks := db.NetworksWithin(shard, maxminddb.SkipAliasedNetworks)
+	batch := make([]prefixEntry, 0, progressStep)
+	for networks.Next() {
+		var rec mmdbRecord
+		subnet, err := networks.Network(&rec)
+		if err != nil {
+			return fmt.Errorf("decoding mmdb record: %w", err)
+		}
+
+		ip := subnet.IP.To4()
+		if ip == nil {
+			continue
+		}
+		ones, _ := subnet.Mask.Size()
+		key := lpmKeyV4{
+			PrefixLen: uint32(ones),
+			Addr:      ipToU32BE(ip),
+		}
+		if next := int64(key.Addr) + 1<<(32-ones); next > pos {
+			tr.Add(next - pos)
+			pos = next
+		}
+
+		// Fall back to the registered country, as the CSV data does.
+		cc := rec.Country.ISOCode
+		if cc == "" {
+			cc = rec.RegisteredCountry.ISOCode
+		}
+		if len(cc) != 2 {
+			continue
+		}
+		cc = strings.ToUpper(cc)
+
+		batch = append(batch, prefixEntry{key: key, country: packCountryCode(cc), continent: rec.Continent.Code})
+		if len(batch) == progressStep {
+			if ctx.Err() != nil {
+				return ErrLoadCanceled
+			}
+			results <- batch
+			batch = make([]prefixEntry, 0, progressStep)
+		}
+	}
+	if err := networks.Err(); err != nil {
+		return fmt.Errorf("iterating mmdb networks: %w", err)
+	}
+	if len(batch) > 0 {
+		results <- batch
+	}
+	return nil
+}
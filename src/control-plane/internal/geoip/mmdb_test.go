@@ -0,0 +1,205 @@
+package geoip
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/progress"
+	"go.uber.org/zap"
+)
+
+// mmdbFixture is a network of a generated test database and the record it
+// maps to, as a map of string keys to strings or nested maps.
+type mmdbFixture struct {
+	cidr   string
+	record map[string]interface{}
+}
+
+func countryRecord(cc, continent string) map[string]interface{} {
+	return map[string]interface{}{
+		"continent": map[string]interface{}{"code": continent},
+		"country":   map[string]interface{}{"iso_code": cc},
+	}
+}
+
+// writeMMDB writes an IPv4 MaxMind DB of the fixtures to path: a binary
+// search tree with 24-bit records, the data section and the metadata, as
+// the MaxMind DB format specification lays them out.
+func writeMMDB(t *testing.T, path string, fixtures []mmdbFixture) {
+	t.Helper()
+
+	// Records of the tree: node index, or -1-i for fixture i's data, or
+	// empty (no data).
+	const empty = int(^uint(0) >> 1)
+	nodes := [][2]int{{empty, empty}}
+	for i, f := range fixtures {
+		_, ipnet, err := net.ParseCIDR(f.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip := ipnet.IP.To4()
+		ones, _ := ipnet.Mask.Size()
+		node := 0
+		for b := 0; b < ones; b++ {
+			bit := int(ip[b/8]>>(7-b%8)) & 1
+			if b == ones-1 {
+				nodes[node][bit] = -1 - i
+				break
+			}
+			if nodes[node][bit] == empty {
+				nodes = append(nodes, [2]int{empty, empty})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+
+	var data bytes.Buffer
+	offsets := make([]int, len(fixtures))
+	for i, f := range fixtures {
+		offsets[i] = data.Len()
+		encodeMMDB(&data, f.record)
+	}
+
+	var buf bytes.Buffer
+	n := len(nodes)
+	for _, node := range nodes {
+		for _, rec := range node {
+			v := rec
+			switch {
+			case rec == empty:
+				v = n
+			case rec < 0:
+				v = n + 16 + offsets[-1-rec]
+			}
+			buf.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	buf.Write(make([]byte, 16))
+	buf.Write(data.Bytes())
+	buf.WriteString("\xab\xcd\xefMaxMind.com")
+	encodeMMDB(&buf, map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint32(1700000000),
+		"database_type":               "Test-Country",
+		"description":                 map[string]interface{}{},
+		"ip_version":                  uint16(4),
+		"node_count":                  uint32(n),
+		"record_size":                 uint16(24),
+	})
+
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// encodeMMDB appends v to the data section in MaxMind DB encoding. Only
+// the types the fixtures use are supported, all short enough for a size
+// in the control byte.
+func encodeMMDB(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		buf.WriteByte(2<<5 | byte(len(v)))
+		buf.WriteString(v)
+	case uint16:
+		buf.WriteByte(5<<5 | 2)
+		binary.Write(buf, binary.BigEndian, v)
+	case uint32:
+		buf.WriteByte(6<<5 | 4)
+		binary.Write(buf, binary.BigEndian, v)
+	case map[string]interface{}:
+		buf.WriteByte(7<<5 | byte(len(v)))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			encodeMMDB(buf, k)
+			encodeMMDB(buf, v[k])
+		}
+	default:
+		panic("unsupported mmdb type")
+	}
+}
+
+func TestReadMMDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GeoLite2-Country.mmdb")
+	writeMMDB(t, path, []mmdbFixture{
+		{"192.0.2.0/24", countryRecord("de", "EU")},
+		{"198.51.100.0/25", map[string]interface{}{ // Registered country only
+			"continent":          map[string]interface{}{"code": "EU"},
+			"registered_country": map[string]interface{}{"iso_code": "GB"},
+		}},
+		{"203.0.113.0/24", map[string]interface{}{ // No country
+			"continent": map[string]interface{}{"code": "AS"},
+		}},
+		{"100.64.0.0/10", countryRecord("US", "NA")},
+	})
+
+	m := NewManager(zap.NewNop(), nil, nil, nil, nil, nil)
+	idx, countries, err := m.readMMDB(context.Background(), path, progress.New(0))
+	if err != nil {
+		t.Fatalf("readMMDB: %v", err)
+	}
+
+	want := prefixIndex{
+		{PrefixLen: 24, Addr: 0xC0000200}: packCountryCode("DE"),
+		{PrefixLen: 25, Addr: 0xC6336400}: packCountryCode("GB"),
+		{PrefixLen: 10, Addr: 0x64400000}: packCountryCode("US"),
+	}
+	if len(idx) != len(want) {
+		t.Errorf("index has %d prefixes, want %d", len(idx), len(want))
+	}
+	for key, cc := range want {
+		if idx[key] != cc {
+			t.Errorf("%s = %q, want %s", formatLPMKey(key), unpackCountryCode(idx[key]), unpackCountryCode(cc))
+		}
+	}
+	if countries["DE"] != "EU" || countries["US"] != "NA" {
+		t.Errorf("continents = %v, want DE in EU and US in NA", countries)
+	}
+}
+
+func TestReadDataFormat(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(zap.NewNop(), nil, nil, nil, nil, nil)
+	ctx := context.Background()
+	fixtures := []mmdbFixture{{"192.0.2.0/24", countryRecord("DE", "EU")}}
+
+	// The extension picks the format, in any case.
+	for _, name := range []string{"country.mmdb", "country.MMDB"} {
+		path := filepath.Join(dir, name)
+		writeMMDB(t, path, fixtures)
+		idx, _, err := m.readData(ctx, path, "", progress.New(0))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if len(idx) != 1 {
+			t.Errorf("%s: read %d prefixes, want 1", name, len(idx))
+		}
+	}
+
+	// The same data under another name is read as CSV blocks.
+	blocks := filepath.Join(dir, "country.bin")
+	writeMMDB(t, blocks, fixtures)
+	locations := filepath.Join(dir, "GeoLite2-Country-Locations-en.csv")
+	if err := os.WriteFile(locations, []byte("geoname_id,locale_code,continent_code,continent_name,country_iso_code,country_name\n2921044,en,EU,Europe,DE,Germany\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.readData(ctx, blocks, locations, progress.New(0)); err == nil || !strings.Contains(err.Error(), "loading blocks") {
+		t.Errorf("mmdb data named .bin: err = %v, want a CSV blocks error", err)
+	}
+
+	// CSV needs its locations file; Load fails before touching geoip_map.
+	if err := m.Load(blocks, ""); err == nil || !strings.Contains(err.Error(), "locations file is required") {
+		t.Errorf("Load of CSV without locations: err = %v", err)
+	}
+}
@@ -0,0 +1,343 @@
+package geoip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"go.uber.org/zap"
+)
+
+// A country's policy in geoip_policy is the first of: a temporary policy
+// set with a TTL, a level policy active at the current escalation level,
+// the policy set with SetCountryPolicy, and the policy of its continent.
+// Temporary and level policies revert on their own; every change is
+// recorded in the audit log.
+const (
+	// maxAuditEntries caps the policy audit log.
+	maxAuditEntries = 1000
+
+	// expireInterval is how often temporary policies are checked.
+	expireInterval = time.Second
+)
+
+// LevelPolicy applies Action to Countries while escalation is at MinLevel
+// or above, e.g. dropping high-risk countries only during attacks.
+type LevelPolicy struct {
+	Countries []string `yaml:"countries" json:"countries"`
+	Action    string   `yaml:"action" json:"action"`      // pass, drop, rate_limit or monitor
+	MinLevel  string   `yaml:"min_level" json:"minLevel"` // medium, high or critical
+}
+
+// Validate checks the countries, action and level.
+func (p LevelPolicy) Validate() error {
+	if len(p.Countries) == 0 {
+		return fmt.Errorf("countries is required")
+	}
+	for _, cc := range p.Countries {
+		if len(cc) != 2 {
+			return fmt.Errorf("country code must be exactly 2 characters, got %q", cc)
+		}
+	}
+	if _, err := ParseAction(p.Action); err != nil {
+		return err
+	}
+	level, ok := escalation.ParseLevel(p.MinLevel)
+	if !ok || level == escalation.Low {
+		return fmt.Errorf("min_level must be medium, high, or critical, got %q", p.MinLevel)
+	}
+	return nil
+}
+
+// levelPolicy is a validated LevelPolicy.
+type levelPolicy struct {
+	countries []string
+	action    uint8
+	minLevel  escalation.Level
+}
+
+// TempPolicy is a country policy that reverts when it expires.
+type TempPolicy struct {
+	Country string
+	Action  uint8
+	Expires time.Time
+	Reason  string
+}
+
+// AuditEntry records a country policy change.
+type AuditEntry struct {
+	Timestamp time.Time
+	Action    string // "set_policy", "remove_policy", "temp_policy", "expire_policy", "clear_policy", "level_policy", "continent_policy", "remove_continent_policy"
+	Detail    string
+}
+
+// SetCountryPolicyFor applies action to a country for ttl, after which the
+// country reverts to its level or permanent policy.
+func (m *Manager) SetCountryPolicyFor(country string, action uint8, ttl time.Duration, reason string) error {
+	if len(country) != 2 {
+		return fmt.Errorf("country code must be exactly 2 characters, got %q", country)
+	}
+	if action > ActionMonitor {
+		return fmt.Errorf("invalid action %d: must be 0-3", action)
+	}
+	if ttl <= 0 {
+		return fmt.Errorf("ttl must be positive")
+	}
+	cc := strings.ToUpper(country)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.temp[cc] = TempPolicy{Country: cc, Action: action, Expires: time.Now().Add(ttl), Reason: reason}
+	if err := m.programLocked(cc); err != nil {
+		delete(m.temp, cc)
+		return err
+	}
+	m.auditLocked("temp_policy", fmt.Sprintf("country=%s action=%s ttl=%s reason=%q", cc, ActionName(action), ttl, reason))
+
+	m.log.Info("temporary geoip policy set",
+		zap.String("country", cc),
+		zap.String("action", ActionName(action)),
+		zap.Duration("ttl", ttl),
+	)
+	return nil
+}
+
+// ClearTempCountryPolicy removes a country's temporary policy before it
+// expires.
+func (m *Manager) ClearTempCountryPolicy(country string) error {
+	cc := strings.ToUpper(country)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.temp[cc]; !ok {
+		return fmt.Errorf("no temporary policy for %s", cc)
+	}
+	delete(m.temp, cc)
+	m.auditLocked("clear_policy", "country="+cc)
+	return m.programLocked(cc)
+}
+
+// GetTempPolicies returns the temporary policies in force, soonest expiry
+// first.
+func (m *Manager) GetTempPolicies() []TempPolicy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]TempPolicy, 0, len(m.temp))
+	for _, t := range m.temp {
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Expires.Before(result[j].Expires) })
+	return result
+}
+
+// SetLevelPolicies replaces the level policies and applies those active at
+// the current level.
+func (m *Manager) SetLevelPolicies(policies []LevelPolicy) error {
+	parsed := make([]levelPolicy, 0, len(policies))
+	for i, p := range policies {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("level policy %d: %w", i, err)
+		}
+		action, _ := ParseAction(p.Action)
+		level, _ := escalation.ParseLevel(p.MinLevel)
+		lp := levelPolicy{action: action, minLevel: level}
+		for _, cc := range p.Countries {
+			lp.countries = append(lp.countries, strings.ToUpper(cc))
+		}
+		parsed = append(parsed, lp)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	affected := m.levelCountriesLocked()
+	m.levelPolicies = parsed
+	for cc := range m.levelCountriesLocked() {
+		affected[cc] = true
+	}
+	return m.programAllLocked(affected)
+}
+
+// SetLevel records an escalation level transition, applying the level
+// policies that become active and reverting those that no longer are.
+func (m *Manager) SetLevel(level escalation.Level) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	from := m.level
+	m.level = level
+	for _, p := range m.levelPolicies {
+		was, is := from >= p.minLevel, level >= p.minLevel
+		if was == is {
+			continue
+		}
+		verb := "applied"
+		if !is {
+			verb = "reverted"
+		}
+		m.auditLocked("level_policy", fmt.Sprintf("%s countries=%s action=%s level=%s",
+			verb, strings.Join(p.countries, ","), ActionName(p.action), level))
+		m.log.Info("geoip level policy "+verb,
+			zap.Strings("countries", p.countries),
+			zap.String("action", ActionName(p.action)),
+			zap.String("level", level.String()),
+		)
+	}
+	if err := m.programAllLocked(m.levelCountriesLocked()); err != nil {
+		m.log.Error("failed to apply geoip level policies", zap.Error(err))
+	}
+}
+
+// GetActivePolicy returns the policy in force for every country that has
+// one, after temporary and level policies.
+func (m *Manager) GetActivePolicy() map[string]uint8 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	countries := m.levelCountriesLocked()
+	for cc := range m.continentCountriesLocked(nil) {
+		countries[cc] = true
+	}
+	for cc := range m.policies {
+		countries[cc] = true
+	}
+	for cc := range m.temp {
+		countries[cc] = true
+	}
+	result := make(map[string]uint8, len(countries))
+	for cc := range countries {
+		if action, ok := m.effectiveLocked(cc); ok {
+			result[cc] = action
+		}
+	}
+	return result
+}
+
+// GetAuditLog returns the policy audit trail, oldest first.
+func (m *Manager) GetAuditLog() []AuditEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]AuditEntry, len(m.audit))
+	copy(result, m.audit)
+	return result
+}
+
+// Run reverts temporary policies as they expire until ctx is done.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.expire(now)
+		}
+	}
+}
+
+// expire removes temporary policies past their expiry.
+func (m *Manager) expire(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for cc, t := range m.temp {
+		if now.Before(t.Expires) {
+			continue
+		}
+		delete(m.temp, cc)
+		m.auditLocked("expire_policy", fmt.Sprintf("country=%s action=%s", cc, ActionName(t.Action)))
+		if err := m.programLocked(cc); err != nil {
+			m.log.Error("failed to revert temporary geoip policy", zap.String("country", cc), zap.Error(err))
+			continue
+		}
+		m.log.Info("temporary geoip policy expired", zap.String("country", cc))
+	}
+}
+
+// effectiveLocked returns the policy in force for a country.
+func (m *Manager) effectiveLocked(cc string) (uint8, bool) {
+	if t, ok := m.temp[cc]; ok {
+		return t.Action, true
+	}
+	// Of the active level policies, the one for the highest level wins.
+	var (
+		action uint8
+		found  bool
+		best   escalation.Level
+	)
+	for _, p := range m.levelPolicies {
+		if m.level < p.minLevel || (found && p.minLevel < best) {
+			continue
+		}
+		for _, c := range p.countries {
+			if c == cc {
+				action, found, best = p.action, true, p.minLevel
+			}
+		}
+	}
+	if found {
+		return action, true
+	}
+	if action, ok := m.policies[cc]; ok {
+		return action, true
+	}
+	action, ok := m.continentPolicies[m.continents[cc]]
+	return action, ok
+}
+
+// programLocked writes a country's policy in force to geoip_policy, or
+// removes it if none is.
+func (m *Manager) programLocked(cc string) error {
+	packed := packCountryCode(cc)
+	action, ok := m.effectiveLocked(cc)
+	if !ok {
+		if err := m.policyMap.Delete(packed); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return fmt.Errorf("removing geoip policy for %s: %w", cc, err)
+		}
+		return nil
+	}
+	if err := m.policyMap.Update(packed, action, ebpf.UpdateAny); err != nil {
+		return fmt.Errorf("updating geoip policy for %s: %w", cc, err)
+	}
+	return nil
+}
+
+// programAllLocked programs every country in set, returning the first
+// error.
+func (m *Manager) programAllLocked(set map[string]bool) error {
+	var first error
+	for cc := range set {
+		if err := m.programLocked(cc); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// levelCountriesLocked returns the countries named by any level policy.
+func (m *Manager) levelCountriesLocked() map[string]bool {
+	set := make(map[string]bool)
+	for _, p := range m.levelPolicies {
+		for _, cc := range p.countries {
+			set[cc] = true
+		}
+	}
+	return set
+}
+
+func (m *Manager) auditLocked(action, detail string) {
+	m.audit = append(m.audit, AuditEntry{Timestamp: time.Now(), Action: action, Detail: detail})
+	if len(m.audit) > maxAuditEntries {
+		m.audit = m.audit[len(m.audit)-maxAuditEntries:]
+	}
+}
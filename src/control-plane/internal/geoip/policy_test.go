@@ -0,0 +1,98 @@
+package geoip
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"go.uber.org/zap"
+)
+
+func TestLevelPolicyValidate(t *testing.T) {
+	tests := []struct {
+		p       LevelPolicy
+		wantErr bool
+	}{
+		{LevelPolicy{Countries: []string{"CN", "ru"}, Action: "drop", MinLevel: "high"}, false},
+		{LevelPolicy{Action: "drop", MinLevel: "high"}, true},
+		{LevelPolicy{Countries: []string{"CHN"}, Action: "drop", MinLevel: "high"}, true},
+		{LevelPolicy{Countries: []string{"CN"}, Action: "block", MinLevel: "high"}, true},
+		{LevelPolicy{Countries: []string{"CN"}, Action: "drop", MinLevel: "low"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.p.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v: err = %v, wantErr %v", tt.p, err, tt.wantErr)
+		}
+	}
+}
+
+func TestEffectivePolicy(t *testing.T) {
+	m := NewManager(zap.NewNop(), nil, nil, nil, nil, nil)
+	m.policies["CN"] = ActionMonitor
+	m.levelPolicies = []levelPolicy{
+		{countries: []string{"CN", "RU"}, action: ActionRateLimit, minLevel: escalation.Medium},
+		{countries: []string{"CN"}, action: ActionDrop, minLevel: escalation.Critical},
+	}
+
+	check := func(cc string, want uint8, wantOK bool) {
+		t.Helper()
+		if got, ok := m.effectiveLocked(cc); got != want || ok != wantOK {
+			t.Errorf("%s at %s = %s, %v; want %s, %v", cc, m.level, ActionName(got), ok, ActionName(want), wantOK)
+		}
+	}
+
+	check("CN", ActionMonitor, true)
+	check("RU", 0, false)
+
+	m.level = escalation.High
+	check("CN", ActionRateLimit, true)
+	check("RU", ActionRateLimit, true)
+
+	m.level = escalation.Critical
+	check("CN", ActionDrop, true)
+
+	// A temporary policy overrides both until it expires.
+	m.temp["CN"] = TempPolicy{Country: "CN", Action: ActionPass, Expires: time.Now().Add(time.Hour)}
+	check("CN", ActionPass, true)
+	if got := m.GetActivePolicy(); got["CN"] != ActionPass || got["RU"] != ActionRateLimit {
+		t.Errorf("active policy = %v", got)
+	}
+	if got := m.GetCountryPolicy(); len(got) != 1 || got["CN"] != ActionMonitor {
+		t.Errorf("country policy = %v, want only the permanent one", got)
+	}
+}
+
+func TestContinentPolicy(t *testing.T) {
+	m := NewManager(zap.NewNop(), nil, nil, nil, nil, nil)
+	m.continents = map[string]string{"CN": "AS", "JP": "AS", "DE": "EU"}
+	m.continentPolicies["AS"] = ActionRateLimit
+	m.policies["JP"] = ActionPass
+
+	for cc, want := range map[string]uint8{"CN": ActionRateLimit, "JP": ActionPass} {
+		if got, ok := m.effectiveLocked(cc); got != want || !ok {
+			t.Errorf("%s = %s, %v; want %s", cc, ActionName(got), ok, ActionName(want))
+		}
+	}
+	if _, ok := m.effectiveLocked("DE"); ok {
+		t.Error("DE has a policy, want none")
+	}
+	if got := m.GetActivePolicy(); len(got) != 2 || got["CN"] != ActionRateLimit {
+		t.Errorf("active policy = %v", got)
+	}
+
+	if err := m.SetRegions(map[string][]string{"apac": {"as", "OC"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := m.resolveContinentsLocked("APAC"); err != nil || len(got) != 2 || got[1] != "OC" {
+		t.Errorf("APAC = %v, %v", got, err)
+	}
+	if _, err := m.resolveContinentsLocked("EMEA"); err == nil {
+		t.Error("resolved unknown region EMEA")
+	}
+	if err := m.SetRegions(map[string][]string{"NA": {"NA", "SA"}}); err == nil {
+		t.Error("accepted a region shadowing a continent code")
+	}
+	if got := m.continentCountriesLocked(nil); len(got) != 2 || !got["CN"] || !got["JP"] {
+		t.Errorf("countries with continent policies = %v", got)
+	}
+}
@@ -0,0 +1,280 @@
+package geoip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/progress"
+	"go.uber.org/zap"
+)
+
+const (
+	// progressStep is how many records or map writes pass between progress
+	// updates and cancellation checks.
+	progressStep = 4096
+
+	// progressLogInterval is how often a load in progress is logged.
+	progressLogInterval = 10 * time.Second
+)
+
+// ErrLoadCanceled is returned by Load when CancelLoad stops it.
+var ErrLoadCanceled = errors.New("geoip data load canceled")
+
+// prefixIndex maps each prefix to its packed country code.
+type prefixIndex map[lpmKeyV4]uint16
+
+// prefixEntry is a prefix read by a load worker.
+type prefixEntry struct {
+	key       lpmKeyV4
+	country   uint16
+	continent string // With .mmdb data
+}
+
+// LoadProgress describes a load in progress. Percent, Rate and ETA are of
+// the current phase: reading goes by the share of the file (or of the
+// IPv4 address space, for .mmdb data) read, applying by the changes
+// written.
+type LoadProgress struct {
+	Database string
+	Phase    string // "reading" or "applying"
+	Started  time.Time
+	Read     int // Prefixes read so far
+	Changes  int // Prefixes to write or remove, once read
+	Applied  int // Changes written so far
+	Added    int
+	Changed  int
+	Removed  int
+	Percent  float64
+	Rate     float64 // Prefixes read or changes written per second
+	ETA      time.Duration
+
+	tracker *progress.Tracker // Of the current phase
+}
+
+// GetLoadProgress returns the progress of the load in progress, if any.
+func (m *Manager) GetLoadProgress() (LoadProgress, bool) {
+	m.progMu.Lock()
+	defer m.progMu.Unlock()
+	if m.progress == nil {
+		return LoadProgress{}, false
+	}
+	p := *m.progress
+	s := p.tracker.Snapshot()
+	p.Percent, p.ETA = s.Percent, s.ETA
+	if secs := s.Elapsed.Seconds(); secs > 0 {
+		if p.Phase == "applying" {
+			p.Rate = float64(p.Applied) / secs
+		} else {
+			p.Rate = float64(p.Read) / secs
+		}
+	}
+	return p, true
+}
+
+// CancelLoad stops the load in progress. It reports whether there was one.
+func (m *Manager) CancelLoad() bool {
+	m.progMu.Lock()
+	defer m.progMu.Unlock()
+	if m.cancelLoad == nil {
+		return false
+	}
+	m.cancelLoad()
+	m.log.Info("geoip data load cancel requested", zap.String("database", m.progress.Database))
+	return true
+}
+
+// startProgress records a load of path and returns the tracker of its
+// reading phase.
+func (m *Manager) startProgress(ctx context.Context, cancel context.CancelFunc, path string) *progress.Tracker {
+	tr := progress.New(0)
+	m.progMu.Lock()
+	m.progress = &LoadProgress{Database: path, Phase: "reading", Started: time.Now(), tracker: tr}
+	m.cancelLoad = cancel
+	m.progMu.Unlock()
+
+	go m.logProgress(ctx)
+	return tr
+}
+
+func (m *Manager) endProgress() {
+	m.progMu.Lock()
+	m.progress, m.cancelLoad = nil, nil
+	m.progMu.Unlock()
+}
+
+func (m *Manager) setProgress(fn func(p *LoadProgress)) {
+	m.progMu.Lock()
+	if m.progress != nil {
+		fn(m.progress)
+	}
+	m.progMu.Unlock()
+}
+
+// mergeEntries collects the prefixes the load workers send until results
+// is closed. It returns them with the continent of each country whose
+// entries carry one.
+func (m *Manager) mergeEntries(results <-chan []prefixEntry) (prefixIndex, map[string]string) {
+	idx := make(prefixIndex)
+	countries := make(map[string]string) // country code → continent code
+	for entries := range results {
+		for _, e := range entries {
+			idx[e.key] = e.country
+			if e.continent != "" {
+				if cc := unpackCountryCode(e.country); countries[cc] == "" {
+					countries[cc] = e.continent
+				}
+			}
+		}
+		read := len(idx)
+		m.setProgress(func(p *LoadProgress) { p.Read = read })
+	}
+	return idx, countries
+}
+
+// logProgress logs the load's progress until ctx is done.
+func (m *Manager) logProgress(ctx context.Context) {
+	ticker := time.NewTicker(progressLogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p, ok := m.GetLoadProgress()
+			if !ok {
+				return
+			}
+			m.log.Info("geoip data load in progress",
+				zap.String("phase", p.Phase),
+				zap.String("percent", fmt.Sprintf("%.1f", p.Percent)),
+				zap.Int("read", p.Read),
+				zap.Int("applied", p.Applied),
+				zap.Int("changes", p.Changes),
+				zap.Float64("rate", p.Rate),
+				zap.Duration("eta", p.ETA.Round(time.Second)),
+				zap.Duration("elapsed", time.Since(p.Started)),
+			)
+		}
+	}
+}
+
+// applyIndex brings geoip_map in line with idx, writing only the prefixes
+// whose country differs from the shadow index and removing those idx no
+// longer lists. It returns the number of prefixes loaded.
+func (m *Manager) applyIndex(ctx context.Context, idx prefixIndex) (int, error) {
+	start := time.Now()
+	if m.shadow == nil {
+		shadow, err := m.readShadow()
+		if err != nil {
+			return 0, err
+		}
+		m.shadow = shadow
+	}
+
+	updates, removes, added := diffIndex(m.shadow, idx)
+	tr := progress.New(int64(len(updates) + len(removes)))
+	m.setProgress(func(p *LoadProgress) {
+		p.Phase = "applying"
+		p.tracker = tr
+		p.Read = len(idx)
+		p.Changes = len(updates) + len(removes)
+		p.Added, p.Changed, p.Removed = added, len(updates)-added, len(removes)
+	})
+
+	// New prefixes go in before stale ones come out, so addresses moving
+	// to a shorter prefix are never left without a country. The shadow is
+	// updated before each write so that the writer's error callback, which
+	// may run during a later write, has the last word.
+	w := m.newGeoIPWriter()
+	applied := 0
+	step := func() error {
+		applied++
+		if applied%progressStep != 0 {
+			return nil
+		}
+		tr.Set(int64(applied))
+		m.setProgress(func(p *LoadProgress) { p.Applied = applied })
+		return ctx.Err()
+	}
+	for _, key := range updates {
+		m.shadow[key] = idx[key]
+		w.Update(key, geoipEntry{
+			CountryCode: idx[key],
+			Action:      ActionPass, // Policy map overrides per-country.
+		})
+		if step() != nil {
+			return 0, m.canceled(w, applied)
+		}
+	}
+	for _, key := range removes {
+		delete(m.shadow, key)
+		w.Delete(key)
+		if step() != nil {
+			return 0, m.canceled(w, applied)
+		}
+	}
+	w.Flush()
+
+	m.log.Info("geoip data applied",
+		zap.Int("added", added),
+		zap.Int("changed", len(updates)-added),
+		zap.Int("removed", len(removes)),
+		zap.Int("unchanged", len(idx)-len(updates)),
+		zap.Int("failed", w.Failed()),
+		zap.Duration("took", time.Since(start)),
+	)
+	return len(idx) - w.Failed(), nil
+}
+
+// diffIndex returns the prefixes of idx that shadow lacks or places in
+// another country, the prefixes of shadow that idx no longer lists, and how
+// many of the former are new.
+func diffIndex(shadow, idx prefixIndex) (updates, removes []lpmKeyV4, added int) {
+	for key, cc := range idx {
+		old, ok := shadow[key]
+		if !ok {
+			added++
+		}
+		if !ok || old != cc {
+			updates = append(updates, key)
+		}
+	}
+	for key := range shadow {
+		if _, ok := idx[key]; !ok {
+			removes = append(removes, key)
+		}
+	}
+	return updates, removes, added
+}
+
+// canceled writes the changes queued before a cancellation and returns
+// ErrLoadCanceled.
+func (m *Manager) canceled(w *bpf.BatchWriter[lpmKeyV4, geoipEntry], applied int) error {
+	w.Flush()
+	m.log.Warn("geoip data load canceled while applying",
+		zap.Int("applied", applied),
+	)
+	return ErrLoadCanceled
+}
+
+// readShadow builds the shadow index from geoip_map, so prefixes already in
+// the map when the first load runs are diffed like any other.
+func (m *Manager) readShadow() (prefixIndex, error) {
+	var (
+		key   lpmKeyV4
+		entry geoipEntry
+	)
+	shadow := make(prefixIndex)
+	iter := m.geoipMap.Iterate()
+	for iter.Next(&key, &entry) {
+		shadow[key] = entry.CountryCode
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("reading geoip_map: %w", err)
+	}
+	return shadow, nil
+}
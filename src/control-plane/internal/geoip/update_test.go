@@ -0,0 +1,111 @@
+package geoip
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestDiffIndex(t *testing.T) {
+	kept := lpmKeyV4{PrefixLen: 24, Addr: 0xC0000200}  // 192.0.2.0/24
+	moved := lpmKeyV4{PrefixLen: 24, Addr: 0xC6336400} // 198.51.100.0/24
+	gone := lpmKeyV4{PrefixLen: 24, Addr: 0xCB007100}  // 203.0.113.0/24
+	added := lpmKeyV4{PrefixLen: 16, Addr: 0x0A000000} // 10.0.0.0/16
+	failed := lpmKeyV4{PrefixLen: 8, Addr: 0x0B000000} // 11.0.0.0/8
+
+	shadow := prefixIndex{
+		kept:   packCountryCode("DE"),
+		moved:  packCountryCode("FR"),
+		gone:   packCountryCode("CN"),
+		failed: 0, // An earlier write failed.
+	}
+	idx := prefixIndex{
+		kept:   packCountryCode("DE"),
+		moved:  packCountryCode("NL"),
+		added:  packCountryCode("US"),
+		failed: packCountryCode("US"),
+	}
+
+	updates, removes, n := diffIndex(shadow, idx)
+	if n != 1 {
+		t.Errorf("added = %d, want 1", n)
+	}
+	want := map[lpmKeyV4]bool{moved: true, added: true, failed: true}
+	if len(updates) != len(want) {
+		t.Errorf("updates = %v, want %d", updates, len(want))
+	}
+	for _, key := range updates {
+		if !want[key] {
+			t.Errorf("unexpected update of %s", formatLPMKey(key))
+		}
+	}
+	if len(removes) != 1 || removes[0] != gone {
+		t.Errorf("removes = %v, want only %s", removes, formatLPMKey(gone))
+	}
+}
+
+func TestLoadCancel(t *testing.T) {
+	m := NewManager(zap.NewNop(), nil, nil, nil, nil, nil)
+	if _, ok := m.GetLoadProgress(); ok {
+		t.Error("progress reported with no load running")
+	}
+	if m.CancelLoad() {
+		t.Error("CancelLoad reported a load with none running")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tr := m.startProgress(ctx, cancel, "GeoLite2-Country-Blocks-IPv4.csv")
+	tr.SetTotal(200)
+	tr.Add(50)
+	if p, ok := m.GetLoadProgress(); !ok || p.Phase != "reading" || p.Percent != 25 {
+		t.Errorf("progress = %+v, %v", p, ok)
+	}
+	if !m.CancelLoad() {
+		t.Error("CancelLoad found no load")
+	}
+
+	rows := strings.Repeat("192.0.2.0/24,2921044\n", progressStep)
+	chunks := make(chan [][]string, 1)
+	if err := readChunks(ctx, csv.NewReader(strings.NewReader(rows)), chunks); !errors.Is(err, ErrLoadCanceled) {
+		t.Errorf("readChunks after cancel = %v, want ErrLoadCanceled", err)
+	}
+	m.endProgress()
+	if _, ok := m.GetLoadProgress(); ok {
+		t.Error("progress reported after the load ended")
+	}
+}
+
+func TestResolveBlocks(t *testing.T) {
+	m := NewManager(zap.NewNop(), nil, nil, nil, nil, nil)
+	records := [][]string{
+		{"192.0.2.0/24", "2921044", ""},
+		{"198.51.100.0/24", "", "2635167"}, // Registered country only
+		{"203.0.113.0/24", "1", ""},        // Unknown geoname
+		{"not a network", "2921044", ""},
+		{"2001:db8::/32", "2921044", ""},
+	}
+	cols := blockColumns{network: 0, geoname: 1, regGeoname: 2}
+	geonameToCC := map[int]string{2921044: "DE", 2635167: "GB"}
+
+	results := make(chan []prefixEntry, 1)
+	results <- resolveBlocks(records, cols, geonameToCC)
+	close(results)
+	idx, _ := m.mergeEntries(results)
+
+	want := prefixIndex{
+		{PrefixLen: 24, Addr: 0xC0000200}: packCountryCode("DE"),
+		{PrefixLen: 24, Addr: 0xC6336400}: packCountryCode("GB"),
+	}
+	if len(idx) != len(want) {
+		t.Fatalf("index = %v, want %v", idx, want)
+	}
+	for key, cc := range want {
+		if idx[key] != cc {
+			t.Errorf("%s = %s, want %s", formatLPMKey(key), unpackCountryCode(idx[key]), unpackCountryCode(cc))
+		}
+	}
+}
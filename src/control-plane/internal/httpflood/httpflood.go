@@ -0,0 +1,358 @@
+// Package httpflood detects HTTP request floods from connection tracking.
+//
+// Request floods use complete, well-formed connections and pass every
+// packet-level check. What gives them away is their shape: every few
+// seconds the conntrack scan finds a destination whose web ports receive
+// far more new connections than before, each sending a small request for
+// a large response. A destination showing both is flagged, which raises
+// the http_flood attack indicator with the escalation engine and
+// optionally has the data plane validate TCP towards it strictly.
+//
+// The response side is only visible when return traffic crosses the
+// scrubber; with min_response_ratio 0 connection churn alone flags.
+package httpflood
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+// Indicator is the name of the attack indicator raised with the
+// escalation engine.
+const Indicator = "http_flood"
+
+// Config controls HTTP flood detection.
+type Config struct {
+	Enabled           bool     `yaml:"enabled"`
+	Ports             []uint16 `yaml:"ports"`                 // TCP destination ports analysed
+	IntervalSec       uint64   `yaml:"interval_sec"`          // How often conntrack is scanned
+	MinNewConnsPerSec float64  `yaml:"min_new_conns_per_sec"` // New connections per second to one destination that suggest a flood
+	MinResponseRatio  float64  `yaml:"min_response_ratio"`    // Response/request bytes of those connections that suggest a flood; 0 = churn alone
+	ClearScans        int      `yaml:"clear_scans"`           // Scans without a flood before a destination is cleared
+	Escalate          string   `yaml:"escalate"`              // Level held while flagged: medium, high or critical; "" = none
+	StrictTCPState    bool     `yaml:"strict_tcp_state"`      // Validate TCP strictly towards flagged destinations
+	StrictSec         uint64   `yaml:"strict_sec"`            // How long strict validation outlasts the last flagged scan
+}
+
+// DefaultConfig watches ports 80 and 443 every 10 seconds and escalates
+// to MEDIUM while a destination is flooded.
+func DefaultConfig() Config {
+	return Config{
+		Ports:             []uint16{80, 443},
+		IntervalSec:       10,
+		MinNewConnsPerSec: 500,
+		MinResponseRatio:  10,
+		ClearScans:        3,
+		Escalate:          "medium",
+		StrictSec:         300,
+	}
+}
+
+// Validate checks the detection parameters.
+func (c Config) Validate() error {
+	if len(c.Ports) == 0 {
+		return fmt.Errorf("ports required")
+	}
+	for _, p := range c.Ports {
+		if p == 0 {
+			return fmt.Errorf("ports: 0 is not a port")
+		}
+	}
+	if c.IntervalSec == 0 {
+		return fmt.Errorf("interval_sec must be positive")
+	}
+	if c.MinNewConnsPerSec <= 0 {
+		return fmt.Errorf("min_new_conns_per_sec must be positive")
+	}
+	if c.MinResponseRatio < 0 {
+		return fmt.Errorf("min_response_ratio must not be negative")
+	}
+	if c.ClearScans <= 0 {
+		return fmt.Errorf("clear_scans must be positive")
+	}
+	if c.Escalate != "" {
+		if l, ok := escalation.ParseLevel(c.Escalate); !ok || l == escalation.Low {
+			return fmt.Errorf("invalid escalate %q (must be medium, high or critical)", c.Escalate)
+		}
+	}
+	if c.StrictTCPState && c.StrictSec == 0 {
+		return fmt.Errorf("strict_sec must be positive")
+	}
+	return nil
+}
+
+// Map is the subset of the BPF map manager the detector uses.
+type Map interface {
+	ConntrackList(f bpf.ConntrackFilter, offset, limit int) ([]bpf.ConntrackFlow, int, error)
+	SetTCPStrictDst(ip net.IP, ttl time.Duration) error
+	RemoveTCPStrictDst(ip net.IP) error
+}
+
+// Escalator receives the attack indicator.
+type Escalator interface {
+	SetIndicator(name string, level escalation.Level)
+}
+
+// Target is a destination's traffic in the last scan.
+type Target struct {
+	IP             string    `json:"ip"`
+	NewConnsPerSec float64   `json:"newConnsPerSec"`
+	RequestBytes   uint64    `json:"requestBytes"`  // Sent by clients on the new connections
+	ResponseBytes  uint64    `json:"responseBytes"` // Returned on them
+	ResponseRatio  float64   `json:"responseRatio"`
+	Flooded        bool      `json:"flooded"`  // Matched in the last scan
+	Since          time.Time `json:"since"`    // First matching scan
+	LastSeen       time.Time `json:"lastSeen"` // Last matching scan
+	StrictUntil    time.Time `json:"strictUntil,omitempty"`
+	quietScans     int
+}
+
+// Status is the detector state reported by the API.
+type Status struct {
+	Enabled  bool      `json:"enabled"`
+	Raised   bool      `json:"raised"` // http_flood indicator raised
+	Escalate string    `json:"escalate,omitempty"`
+	LastScan time.Time `json:"lastScan"`
+	Flows    int       `json:"flows"` // Connections to the watched ports in the last scan
+	Targets  []Target  `json:"targets"`
+}
+
+// Detector scans conntrack for HTTP floods.
+type Detector struct {
+	log  *zap.Logger
+	cfg  Config
+	maps Map
+	esc  Escalator
+
+	mu       sync.Mutex
+	ports    map[uint16]bool
+	prev     map[bpf.ConntrackKey]struct{} // Watched connections of the last scan
+	lastScan time.Time
+	flows    int
+	targets  map[string]*Target // Flagged destinations, cleared after clear_scans
+	raised   bool
+}
+
+// NewDetector creates a detector. esc may be nil.
+func NewDetector(log *zap.Logger, cfg Config, m Map, esc Escalator) *Detector {
+	ports := make(map[uint16]bool, len(cfg.Ports))
+	for _, p := range cfg.Ports {
+		ports[p] = true
+	}
+	return &Detector{
+		log:     log,
+		cfg:     cfg,
+		maps:    m,
+		esc:     esc,
+		ports:   ports,
+		targets: make(map[string]*Target),
+	}
+}
+
+// Run scans conntrack every interval_sec until ctx is done, then clears
+// the indicator and the strict TCP destinations it set.
+func (d *Detector) Run(ctx context.Context) {
+	interval := time.Duration(d.cfg.IntervalSec) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	d.log.Info("HTTP flood detection started",
+		zap.Duration("interval", interval),
+		zap.Uint16s("ports", d.cfg.Ports),
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			d.stop()
+			return
+		case now := <-ticker.C:
+			flows, _, err := d.maps.ConntrackList(bpf.ConntrackFilter{Protocol: unix.IPPROTO_TCP}, 0, 0)
+			if err != nil {
+				d.log.Warn("failed to read conntrack for HTTP flood detection", zap.Error(err))
+				continue
+			}
+			d.Scan(flows, now)
+		}
+	}
+}
+
+// Scan analyses the conntrack flows read at now. The first scan only
+// records the connections, as every one of them would count as new.
+func (d *Detector) Scan(flows []bpf.ConntrackFlow, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cur := make(map[bpf.ConntrackKey]struct{}, len(d.prev))
+	fresh := make(map[uint32]*Target)
+	for _, f := range flows {
+		if f.Key.Protocol != unix.IPPROTO_TCP || !d.ports[ntohs(f.Key.DstPort)] {
+			continue
+		}
+		cur[f.Key] = struct{}{}
+		if _, seen := d.prev[f.Key]; seen || d.prev == nil {
+			continue
+		}
+		t := fresh[f.Key.DstIP]
+		if t == nil {
+			t = &Target{IP: bpf.U32BEToIP(f.Key.DstIP).String()}
+			fresh[f.Key.DstIP] = t
+		}
+		t.NewConnsPerSec++
+		t.RequestBytes += f.Entry.BytesFwd
+		t.ResponseBytes += f.Entry.BytesRev
+	}
+	first := d.prev == nil
+	elapsed := now.Sub(d.lastScan).Seconds()
+	d.prev, d.lastScan, d.flows = cur, now, len(cur)
+	if first || elapsed <= 0 {
+		return
+	}
+
+	for _, t := range fresh {
+		t.NewConnsPerSec /= elapsed
+		if t.RequestBytes > 0 {
+			t.ResponseRatio = float64(t.ResponseBytes) / float64(t.RequestBytes)
+		}
+		if !d.flooded(t) {
+			continue
+		}
+		prev := d.targets[t.IP]
+		t.Flooded, t.LastSeen, t.Since = true, now, now
+		if prev != nil {
+			t.Since, t.StrictUntil = prev.Since, prev.StrictUntil
+		} else {
+			d.log.Warn("HTTP flood detected",
+				zap.String("dst", t.IP),
+				zap.Float64("new_conns_per_sec", t.NewConnsPerSec),
+				zap.Float64("response_ratio", t.ResponseRatio),
+			)
+		}
+		d.targets[t.IP] = t
+	}
+
+	for ip, t := range d.targets {
+		if t.LastSeen.Equal(now) {
+			d.strict(t, now)
+			continue
+		}
+		t.Flooded = false
+		t.quietScans++
+		if t.quietScans < d.cfg.ClearScans || now.Before(t.StrictUntil) {
+			continue
+		}
+		if !t.StrictUntil.IsZero() {
+			if err := d.maps.RemoveTCPStrictDst(net.ParseIP(ip)); err != nil {
+				d.log.Warn("failed to end strict TCP validation", zap.String("dst", ip), zap.Error(err))
+			}
+		}
+		delete(d.targets, ip)
+		d.log.Info("HTTP flood ended", zap.String("dst", ip))
+	}
+	d.updateIndicator()
+}
+
+// flooded reports whether a destination's new connections look like a
+// request flood.
+func (d *Detector) flooded(t *Target) bool {
+	if t.NewConnsPerSec < d.cfg.MinNewConnsPerSec {
+		return false
+	}
+	return d.cfg.MinResponseRatio == 0 || t.ResponseRatio >= d.cfg.MinResponseRatio
+}
+
+// strict has the data plane validate TCP towards a flooded destination
+// strictly for strict_sec from now.
+func (d *Detector) strict(t *Target, now time.Time) {
+	t.quietScans = 0
+	if !d.cfg.StrictTCPState {
+		return
+	}
+	ttl := time.Duration(d.cfg.StrictSec) * time.Second
+	if err := d.maps.SetTCPStrictDst(net.ParseIP(t.IP), ttl); err != nil {
+		d.log.Warn("failed to set strict TCP validation", zap.String("dst", t.IP), zap.Error(err))
+		return
+	}
+	t.StrictUntil = now.Add(ttl)
+}
+
+// updateIndicator raises the indicator while any destination is flooded
+// and clears it once all have been cleared.
+func (d *Detector) updateIndicator() {
+	raised := false
+	for _, t := range d.targets {
+		if t.Flooded {
+			raised = true
+			break
+		}
+	}
+	if raised == d.raised {
+		return
+	}
+	d.raised = raised
+	if d.esc == nil || d.cfg.Escalate == "" {
+		return
+	}
+	level := escalation.Low
+	if raised {
+		level, _ = escalation.ParseLevel(d.cfg.Escalate)
+	}
+	d.esc.SetIndicator(Indicator, level)
+}
+
+func (d *Detector) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for ip, t := range d.targets {
+		if !t.StrictUntil.IsZero() {
+			if err := d.maps.RemoveTCPStrictDst(net.ParseIP(ip)); err != nil {
+				d.log.Warn("failed to end strict TCP validation", zap.String("dst", ip), zap.Error(err))
+			}
+		}
+	}
+	d.targets = make(map[string]*Target)
+	if d.raised && d.esc != nil {
+		d.esc.SetIndicator(Indicator, escalation.Low)
+	}
+	d.raised = false
+}
+
+// Status returns the destinations flagged or being cleared, busiest
+// first.
+func (d *Detector) Status() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	st := Status{
+		Enabled:  true,
+		Raised:   d.raised,
+		Escalate: d.cfg.Escalate,
+		LastScan: d.lastScan,
+		Flows:    d.flows,
+		Targets:  make([]Target, 0, len(d.targets)),
+	}
+	for _, t := range d.targets {
+		st.Targets = append(st.Targets, *t)
+	}
+	sort.Slice(st.Targets, func(i, j int) bool {
+		if st.Targets[i].NewConnsPerSec != st.Targets[j].NewConnsPerSec {
+			return st.Targets[i].NewConnsPerSec > st.Targets[j].NewConnsPerSec
+		}
+		return st.Targets[i].IP < st.Targets[j].IP
+	})
+	return st
+}
+
+func ntohs(v uint16) uint16 {
+	return (v >> 8) | (v << 8)
+}
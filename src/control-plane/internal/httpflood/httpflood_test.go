@@ -0,0 +1,157 @@
+package httpflood
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+// fakeMap records strict TCP destinations.
+type fakeMap struct {
+	strict map[string]time.Duration
+}
+
+func (f *fakeMap) ConntrackList(bpf.ConntrackFilter, int, int) ([]bpf.ConntrackFlow, int, error) {
+	return nil, 0, nil
+}
+
+func (f *fakeMap) SetTCPStrictDst(ip net.IP, ttl time.Duration) error {
+	f.strict[ip.String()] = ttl
+	return nil
+}
+
+func (f *fakeMap) RemoveTCPStrictDst(ip net.IP) error {
+	delete(f.strict, ip.String())
+	return nil
+}
+
+// fakeEscalator records the last level of each indicator.
+type fakeEscalator map[string]escalation.Level
+
+func (f fakeEscalator) SetIndicator(name string, level escalation.Level) { f[name] = level }
+
+// flows returns n TCP connections to dst:port from distinct clients,
+// numbered from first, each with the given request and response bytes.
+func flows(dst string, port uint16, first, n int, req, resp uint64) []bpf.ConntrackFlow {
+	out := make([]bpf.ConntrackFlow, 0, n)
+	for i := first; i < first+n; i++ {
+		out = append(out, bpf.ConntrackFlow{
+			Key: bpf.ConntrackKey{
+				SrcIP:    bpf.IPToU32BE(net.IPv4(198, 51, byte(i>>8), byte(i))),
+				DstIP:    bpf.IPToU32BE(net.ParseIP(dst)),
+				SrcPort:  ntohs(40000),
+				DstPort:  ntohs(port),
+				Protocol: unix.IPPROTO_TCP,
+			},
+			Entry: bpf.ConntrackEntry{BytesFwd: req, BytesRev: resp},
+		})
+	}
+	return out
+}
+
+func TestDetector(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MinNewConnsPerSec = 10
+	cfg.ClearScans = 2
+	cfg.StrictTCPState = true
+	m := &fakeMap{strict: map[string]time.Duration{}}
+	esc := fakeEscalator{}
+	d := NewDetector(zap.NewNop(), cfg, m, esc)
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(scan int) time.Time { return start.Add(time.Duration(scan) * 10 * time.Second) }
+
+	// The first scan only seeds the known connections.
+	d.Scan(flows("203.0.113.10", 443, 0, 500, 200, 20000), at(0))
+	if st := d.Status(); st.Raised || len(st.Targets) != 0 || st.Flows != 500 {
+		t.Fatalf("after seeding: %+v", st)
+	}
+
+	// 200 new connections in 10s to .10 with 100x responses flood it;
+	// .20 gets as many but with balanced traffic, and port 22 is ignored.
+	var cur []bpf.ConntrackFlow
+	cur = append(cur, flows("203.0.113.10", 443, 0, 700, 200, 20000)...)
+	cur = append(cur, flows("203.0.113.20", 80, 0, 200, 5000, 5000)...)
+	cur = append(cur, flows("203.0.113.30", 22, 0, 200, 200, 20000)...)
+	d.Scan(cur, at(1))
+
+	st := d.Status()
+	if !st.Raised || len(st.Targets) != 1 || st.Flows != 900 {
+		t.Fatalf("during flood: %+v", st)
+	}
+	if tg := st.Targets[0]; tg.IP != "203.0.113.10" || tg.NewConnsPerSec != 20 || tg.ResponseRatio != 100 || !tg.Flooded {
+		t.Errorf("target = %+v", tg)
+	}
+	if esc[Indicator] != escalation.Medium {
+		t.Errorf("indicator = %s, want MEDIUM", esc[Indicator])
+	}
+	if m.strict["203.0.113.10"] != 300*time.Second || len(m.strict) != 1 {
+		t.Errorf("strict destinations = %v", m.strict)
+	}
+
+	// No new connections: the indicator drops straight away, the target
+	// stays listed until clear_scans and strict_sec have both passed.
+	d.Scan(cur, at(2))
+	if st := d.Status(); st.Raised || len(st.Targets) != 1 || st.Targets[0].Flooded {
+		t.Errorf("after flood: %+v", st)
+	}
+	if esc[Indicator] != escalation.Low {
+		t.Errorf("indicator = %s, want LOW", esc[Indicator])
+	}
+	d.Scan(cur, at(3))
+	if len(d.Status().Targets) != 1 || len(m.strict) != 1 {
+		t.Errorf("target cleared before strict_sec")
+	}
+	d.Scan(cur, at(31))
+	if len(d.Status().Targets) != 0 || len(m.strict) != 0 {
+		t.Errorf("target not cleared: %+v, strict %v", d.Status(), m.strict)
+	}
+}
+
+func TestDetectorChurnOnly(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MinNewConnsPerSec = 10
+	cfg.MinResponseRatio = 0
+	cfg.Escalate = ""
+	esc := fakeEscalator{}
+	d := NewDetector(zap.NewNop(), cfg, &fakeMap{strict: map[string]time.Duration{}}, esc)
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	d.Scan(nil, start)
+	d.Scan(flows("203.0.113.20", 80, 0, 100, 0, 0), start.Add(5*time.Second))
+	if st := d.Status(); !st.Raised || len(st.Targets) != 1 {
+		t.Errorf("churn alone not flagged: %+v", st)
+	}
+	if len(esc) != 0 {
+		t.Errorf("indicator set with escalate empty: %v", esc)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{"defaults", func(*Config) {}, false},
+		{"no ports", func(c *Config) { c.Ports = nil }, true},
+		{"port zero", func(c *Config) { c.Ports = []uint16{80, 0} }, true},
+		{"zero rate", func(c *Config) { c.MinNewConnsPerSec = 0 }, true},
+		{"negative ratio", func(c *Config) { c.MinResponseRatio = -1 }, true},
+		{"escalate low", func(c *Config) { c.Escalate = "low" }, true},
+		{"no escalation", func(c *Config) { c.Escalate = "" }, false},
+		{"strict without ttl", func(c *Config) { c.StrictTCPState, c.StrictSec = true, 0 }, true},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		tt.modify(&cfg)
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %t", tt.name, err, tt.wantErr)
+		}
+	}
+}
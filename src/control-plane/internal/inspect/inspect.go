@@ -0,0 +1,573 @@
+// Package inspect is the userspace deep inspection path. The XDP program
+// redirects a sampled or suspect subset of DNS and TLS traffic to AF_XDP
+// sockets, one per receive queue, and the inspector parses what XDP
+// cannot: DNS query names and TLS ClientHellos. Its verdicts are fed back
+// into the BPF maps: malicious sources are blacklisted for
+// Config.BlockSec, and clean ones are not redirected again for
+// Config.ClearSec.
+//
+// The inspector consumes the packets it is given; see deep_inspect.h.
+package inspect
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/dnsanalytics"
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	maxRecent      = 100
+	zoneWindow     = time.Minute // Random-subdomain analysis window
+	maxQNameLength = 255
+)
+
+// Reasons a source is blocked.
+const (
+	ReasonDNSBlockedName = "dns_blocked_name" // Query name under dns.block_suffixes
+	ReasonDNSLongName    = "dns_long_name"    // Query name longer than dns.max_qname_len
+	ReasonTLSNoSNI       = "tls_no_sni"       // ClientHello without SNI while tls.require_sni
+	ReasonTLSBlockedSNI  = "tls_blocked_sni"  // SNI under tls.block_sni_suffixes
+	ReasonTLSBlockedJA3  = "tls_blocked_ja3"  // JA3 fingerprint in tls.block_ja3
+)
+
+// Config controls deep inspection.
+type Config struct {
+	Enabled      bool      `yaml:"enabled"`
+	Queues       int       `yaml:"queues"`        // Receive queues given a socket; 0 = all
+	SampleRate   uint32    `yaml:"sample_rate"`   // Inspect 1 in N selected packets
+	SuspectScore uint32    `yaml:"suspect_score"` // Always inspect sources with this reputation score; 0 = never
+	ClearSec     uint64    `yaml:"clear_sec"`     // Sources found clean are not inspected again for this long
+	BlockSec     uint64    `yaml:"block_sec"`     // How long malicious sources are blacklisted
+	DNS          DNSConfig `yaml:"dns"`
+	TLS          TLSConfig `yaml:"tls"`
+}
+
+// DNSConfig selects DNS queries for inspection and what blocks their
+// source.
+type DNSConfig struct {
+	Ports         []uint16 `yaml:"ports"`          // UDP destination ports
+	MaxQNameLen   int      `yaml:"max_qname_len"`  // Longer query names are malicious; 0 = no limit
+	BlockSuffixes []string `yaml:"block_suffixes"` // Query names at or below these are malicious
+	Zones         []string `yaml:"zones"`          // Protected zones reported by random-subdomain analysis
+}
+
+// TLSConfig selects TLS ClientHellos for inspection and what blocks their
+// source.
+type TLSConfig struct {
+	Ports            []uint16 `yaml:"ports"`              // TCP destination ports
+	RequireSNI       bool     `yaml:"require_sni"`        // ClientHellos without server name are malicious
+	BlockSNISuffixes []string `yaml:"block_sni_suffixes"` // Server names at or below these are malicious
+	BlockJA3         []string `yaml:"block_ja3"`          // JA3 fingerprints (MD5, hex) of malicious clients
+}
+
+// DefaultConfig returns the built-in inspection settings, disabled.
+func DefaultConfig() Config {
+	return Config{
+		SampleRate: 100,
+		ClearSec:   300,
+		BlockSec:   600,
+		DNS:        DNSConfig{Ports: []uint16{53}},
+		TLS:        TLSConfig{Ports: []uint16{443}},
+	}
+}
+
+var ja3Pattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Validate checks the inspection configuration.
+func (c Config) Validate() error {
+	if c.Queues < 0 || c.Queues > bpf.InspectQueuesMax {
+		return fmt.Errorf("queues must be between 0 and %d", bpf.InspectQueuesMax)
+	}
+	if c.SuspectScore > 1000 {
+		return fmt.Errorf("suspect_score must be at most 1000")
+	}
+	if c.ClearSec == 0 || c.BlockSec == 0 {
+		return fmt.Errorf("clear_sec and block_sec must be positive")
+	}
+
+	seen := make(map[uint16]bool)
+	for _, ports := range [][]uint16{c.DNS.Ports, c.TLS.Ports} {
+		for _, p := range ports {
+			if p == 0 {
+				return fmt.Errorf("port 0 is not valid")
+			}
+			if seen[p] {
+				return fmt.Errorf("port %d listed twice", p)
+			}
+			seen[p] = true
+		}
+	}
+	if len(seen) == 0 {
+		return fmt.Errorf("dns.ports or tls.ports required")
+	}
+	if len(seen) > bpf.InspectPortsMax {
+		return fmt.Errorf("%d ports exceed the limit of %d", len(seen), bpf.InspectPortsMax)
+	}
+
+	if c.DNS.MaxQNameLen < 0 || c.DNS.MaxQNameLen > maxQNameLength {
+		return fmt.Errorf("dns.max_qname_len must be between 0 and %d", maxQNameLength)
+	}
+	for _, s := range append(append([]string{}, c.DNS.BlockSuffixes...), c.TLS.BlockSNISuffixes...) {
+		if normalizeName(s) == "" {
+			return fmt.Errorf("empty name in block suffixes")
+		}
+	}
+	for _, fp := range c.TLS.BlockJA3 {
+		if !ja3Pattern.MatchString(strings.ToLower(fp)) {
+			return fmt.Errorf("tls.block_ja3: %q is not an MD5 fingerprint", fp)
+		}
+	}
+	return nil
+}
+
+// Map is the part of the data plane the inspector programs, implemented
+// by bpf.MapManager.
+type Map interface {
+	SetConfig(key uint32, value uint64) error
+	SetInspectPorts(ports map[uint16]uint8) error
+	SetInspectSocket(queue uint32, fd int) error
+	RemoveInspectSocket(queue uint32) error
+	ClearInspectSource(ip net.IP, ttl time.Duration) error
+	AddBlacklistCIDRWithTTL(cidr string, reason uint32, ttl time.Duration) error
+	ReadInspectStats() (bpf.InspectStats, error)
+}
+
+// Verdict is a source blocked by the inspector.
+type Verdict struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+	Reason string    `json:"reason"`
+	Detail string    `json:"detail"` // Query name, server name or JA3 fingerprint
+}
+
+// Status reports the inspector sockets, its verdicts and the traffic the
+// XDP program redirected to it.
+type Status struct {
+	Enabled    bool                        `json:"enabled"`
+	Interface  string                      `json:"interface,omitempty"`
+	Sockets    int                         `json:"sockets"`
+	Redirected uint64                      `json:"redirected"` // Packets handed to the sockets
+	NoSocket   uint64                      `json:"noSocket"`   // Selected on a queue without socket
+	Inspected  uint64                      `json:"inspected"`
+	Malformed  uint64                      `json:"malformed"`
+	Cleared    uint64                      `json:"cleared"`
+	Blocked    map[string]uint64           `json:"blocked"` // By reason
+	Recent     []Verdict                   `json:"recent"`  // Latest blocks, newest first
+	DNSZones   []dnsanalytics.DomainReport `json:"dnsZones,omitempty"`
+
+	// DNSSignatures are the zones under a random-subdomain attack in the
+	// current window, with the query name suffix to match and the
+	// recommended rate.
+	DNSSignatures []dnsanalytics.Signature `json:"dnsSignatures,omitempty"`
+}
+
+// Inspector owns the AF_XDP sockets and turns what they receive into
+// verdicts.
+type Inspector struct {
+	log *zap.Logger
+	cfg Config
+	m   Map
+	dns *dnsanalytics.Analyzer
+
+	blockNames []string
+	blockSNI   []string
+	blockJA3   map[string]bool
+
+	mu        sync.Mutex
+	iface     string
+	sockets   []*xsk
+	inspected uint64
+	malformed uint64
+	cleared   uint64
+	blocked   map[string]uint64
+	recent    []Verdict
+}
+
+// NewInspector creates an inspector writing its verdicts to m. Nothing
+// is redirected to it until Start.
+func NewInspector(log *zap.Logger, cfg Config, m Map) *Inspector {
+	in := &Inspector{
+		log:      log,
+		cfg:      cfg,
+		m:        m,
+		dns:      dnsanalytics.NewAnalyzer(cfg.DNS.Zones),
+		blockJA3: make(map[string]bool, len(cfg.TLS.BlockJA3)),
+		blocked:  make(map[string]uint64),
+	}
+	for _, s := range cfg.DNS.BlockSuffixes {
+		in.blockNames = append(in.blockNames, normalizeName(s))
+	}
+	for _, s := range cfg.TLS.BlockSNISuffixes {
+		in.blockSNI = append(in.blockSNI, normalizeName(s))
+	}
+	for _, fp := range cfg.TLS.BlockJA3 {
+		in.blockJA3[strings.ToLower(fp)] = true
+	}
+	return in
+}
+
+// Start opens a socket on each receive queue of the interface, which XDP
+// must already be attached to, and starts redirecting to them.
+func (in *Inspector) Start(ifaceName string) error {
+	iface, err := net.InterfaceByName(ifaceName)
+	if err != nil {
+		return fmt.Errorf("finding interface %s: %w", ifaceName, err)
+	}
+	queues, err := rxQueues(ifaceName)
+	if err != nil {
+		return err
+	}
+	if in.cfg.Queues > 0 && in.cfg.Queues < queues {
+		queues = in.cfg.Queues
+	}
+	if queues > bpf.InspectQueuesMax {
+		queues = bpf.InspectQueuesMax
+	}
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.iface = ifaceName
+	for q := 0; q < queues; q++ {
+		s, err := openXSK(iface.Index, uint32(q))
+		if err == nil {
+			err = in.m.SetInspectSocket(uint32(q), s.fd)
+			if err != nil {
+				s.close()
+			}
+		}
+		if err != nil {
+			in.closeLocked()
+			return fmt.Errorf("opening inspection socket on %s queue %d: %w", ifaceName, q, err)
+		}
+		in.sockets = append(in.sockets, s)
+	}
+
+	if err := in.program(); err != nil {
+		in.closeLocked()
+		return err
+	}
+	in.log.Info("deep inspection started",
+		zap.String("interface", ifaceName),
+		zap.Int("queues", queues),
+		zap.Uint32("sample_rate", in.cfg.SampleRate),
+	)
+	return nil
+}
+
+// program writes the ports and sampling to the data plane, switching
+// redirection on last.
+func (in *Inspector) program() error {
+	ports := make(map[uint16]uint8)
+	for _, p := range in.cfg.DNS.Ports {
+		ports[p] = bpf.InspectDNS
+	}
+	for _, p := range in.cfg.TLS.Ports {
+		ports[p] = bpf.InspectTLS
+	}
+	if err := in.m.SetInspectPorts(ports); err != nil {
+		return err
+	}
+	for _, kv := range []struct {
+		key   uint32
+		name  string
+		value uint64
+	}{
+		{bpf.CfgInspectSample, "inspect_sample", uint64(in.cfg.SampleRate)},
+		{bpf.CfgInspectScore, "inspect_score", uint64(in.cfg.SuspectScore)},
+		{bpf.CfgInspectEnable, "inspect_enable", 1},
+	} {
+		if err := in.m.SetConfig(kv.key, kv.value); err != nil {
+			return fmt.Errorf("setting %s: %w", kv.name, err)
+		}
+	}
+	return nil
+}
+
+// Run inspects what the sockets receive until ctx is cancelled, then
+// stops redirection and closes them.
+func (in *Inspector) Run(ctx context.Context) {
+	in.mu.Lock()
+	sockets := in.sockets
+	in.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, s := range sockets {
+		wg.Add(1)
+		go func(s *xsk) {
+			defer wg.Done()
+			in.receive(ctx, s)
+		}(s)
+	}
+
+	ticker := time.NewTicker(zoneWindow)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			in.mu.Lock()
+			if err := in.m.SetConfig(bpf.CfgInspectEnable, 0); err != nil {
+				in.log.Warn("failed to stop deep inspection", zap.Error(err))
+			}
+			in.closeLocked()
+			in.mu.Unlock()
+			in.log.Info("deep inspection stopped")
+			return
+		case <-ticker.C:
+			in.logSignatures()
+			in.dns.Rotate()
+		}
+	}
+}
+
+// logSignatures warns about the zones the closing window flagged as under
+// a random-subdomain attack.
+func (in *Inspector) logSignatures() {
+	for _, sig := range in.dns.Signatures() {
+		in.log.Warn("random-subdomain attack on protected zone",
+			zap.String("zone", sig.Domain),
+			zap.Uint64("recommended_qps", sig.MaxQPS),
+		)
+	}
+}
+
+// receive hands the packets of one socket to Inspect until ctx is
+// cancelled.
+func (in *Inspector) receive(ctx context.Context, s *xsk) {
+	for ctx.Err() == nil {
+		ready, err := s.poll(time.Second)
+		if err != nil {
+			in.log.Warn("polling inspection socket failed", zap.Uint32("queue", s.queue), zap.Error(err))
+			time.Sleep(time.Second)
+			continue
+		}
+		if ready {
+			s.receive(in.Inspect)
+		}
+	}
+}
+
+// closeLocked unregisters and closes the sockets. Caller must hold in.mu.
+func (in *Inspector) closeLocked() {
+	for _, s := range in.sockets {
+		if err := in.m.RemoveInspectSocket(s.queue); err != nil {
+			in.log.Warn("failed to unregister inspection socket", zap.Uint32("queue", s.queue), zap.Error(err))
+		}
+		s.close()
+	}
+	in.sockets = nil
+}
+
+// Inspect parses a redirected frame and records its verdict: the source
+// is blacklisted when the query or ClientHello is malicious and cleared
+// otherwise. Frames it cannot parse get no verdict.
+func (in *Inspector) Inspect(frame []byte) {
+	src, proto, payload, ok := parseFrame(frame)
+	if !ok {
+		in.count(&in.malformed)
+		return
+	}
+
+	var (
+		reason, detail string
+		err            error
+	)
+	switch proto {
+	case unix.IPPROTO_UDP:
+		reason, detail, err = in.inspectDNS(payload)
+	case unix.IPPROTO_TCP:
+		reason, detail, err = in.inspectTLS(payload)
+	}
+	if err != nil {
+		in.count(&in.malformed)
+		in.log.Debug("uninspectable packet", zap.Stringer("source", src), zap.Error(err))
+		return
+	}
+
+	if reason == "" {
+		if err := in.m.ClearInspectSource(src, time.Duration(in.cfg.ClearSec)*time.Second); err != nil {
+			in.log.Warn("failed to clear inspected source", zap.Stringer("source", src), zap.Error(err))
+		}
+		in.mu.Lock()
+		in.inspected++
+		in.cleared++
+		in.mu.Unlock()
+		return
+	}
+
+	ttl := time.Duration(in.cfg.BlockSec) * time.Second
+	if err := in.m.AddBlacklistCIDRWithTTL(src.String()+"/32", bpf.DropBlacklist, ttl); err != nil {
+		in.log.Warn("failed to block inspected source", zap.Stringer("source", src), zap.Error(err))
+		return
+	}
+	in.log.Info("source blocked by deep inspection",
+		zap.Stringer("source", src),
+		zap.String("reason", reason),
+		zap.String("detail", detail),
+		zap.Duration("ttl", ttl),
+	)
+
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.inspected++
+	in.blocked[reason]++
+	in.recent = append(in.recent, Verdict{Time: time.Now(), Source: src.String(), Reason: reason, Detail: detail})
+	if len(in.recent) > maxRecent {
+		in.recent = in.recent[len(in.recent)-maxRecent:]
+	}
+}
+
+func (in *Inspector) count(c *uint64) {
+	in.mu.Lock()
+	*c++
+	in.mu.Unlock()
+}
+
+// inspectDNS returns why a DNS query is malicious, or no reason.
+func (in *Inspector) inspectDNS(payload []byte) (reason, detail string, err error) {
+	qname, isResponse, _, err := dnsanalytics.ParseMessage(payload)
+	if err != nil {
+		return "", "", err
+	}
+	if isResponse {
+		return "", "", fmt.Errorf("dns response to an inspected port")
+	}
+	in.dns.ObserveQuery(qname)
+
+	name := normalizeName(qname)
+	if max := in.cfg.DNS.MaxQNameLen; max > 0 && len(name) > max {
+		return ReasonDNSLongName, name, nil
+	}
+	if underAny(name, in.blockNames) {
+		return ReasonDNSBlockedName, name, nil
+	}
+	return "", "", nil
+}
+
+// inspectTLS returns why a ClientHello is malicious, or no reason. A
+// ClientHello continued in a later segment is judged by the server name
+// only, if its first segment carries one.
+func (in *Inspector) inspectTLS(payload []byte) (reason, detail string, err error) {
+	hello, err := parseClientHello(payload)
+	if err != nil {
+		return "", "", err
+	}
+	sni := normalizeName(hello.SNI)
+	switch {
+	case sni != "" && underAny(sni, in.blockSNI):
+		return ReasonTLSBlockedSNI, sni, nil
+	case !hello.Complete:
+		return "", "", nil
+	case sni == "" && in.cfg.TLS.RequireSNI:
+		return ReasonTLSNoSNI, hello.JA3, nil
+	case in.blockJA3[hello.JA3]:
+		return ReasonTLSBlockedJA3, hello.JA3, nil
+	}
+	return "", "", nil
+}
+
+// Status returns the sockets, verdicts and redirect counters.
+func (in *Inspector) Status() (Status, error) {
+	in.mu.Lock()
+	st := Status{
+		Enabled:   true,
+		Interface: in.iface,
+		Sockets:   len(in.sockets),
+		Inspected: in.inspected,
+		Malformed: in.malformed,
+		Cleared:   in.cleared,
+		Blocked:   make(map[string]uint64, len(in.blocked)),
+		Recent:    make([]Verdict, 0, len(in.recent)),
+	}
+	for r, n := range in.blocked {
+		st.Blocked[r] = n
+	}
+	for i := len(in.recent) - 1; i >= 0; i-- {
+		st.Recent = append(st.Recent, in.recent[i])
+	}
+	in.mu.Unlock()
+
+	if len(in.cfg.DNS.Zones) > 0 {
+		st.DNSZones = in.dns.Report()
+		st.DNSSignatures = in.dns.Signatures()
+	}
+	s, err := in.m.ReadInspectStats()
+	if err != nil {
+		return st, err
+	}
+	st.Redirected, st.NoSocket = s.Redirected, s.NoSocket
+	return st, nil
+}
+
+// parseFrame returns the IPv4 source, the L4 protocol and the L4 payload
+// of an Ethernet frame.
+func parseFrame(b []byte) (src net.IP, proto uint8, payload []byte, ok bool) {
+	if len(b) < 14+20 || binary.BigEndian.Uint16(b[12:14]) != unix.ETH_P_IP {
+		return nil, 0, nil, false
+	}
+	ip := b[14:]
+	ihl := int(ip[0]&0x0F) * 4
+	total := int(binary.BigEndian.Uint16(ip[2:4]))
+	if ip[0]>>4 != 4 || ihl < 20 || total < ihl || len(ip) < total {
+		return nil, 0, nil, false
+	}
+	l4 := ip[ihl:total]
+	proto = ip[9]
+	switch proto {
+	case unix.IPPROTO_UDP:
+		if len(l4) < 8 {
+			return nil, 0, nil, false
+		}
+		payload = l4[8:]
+	case unix.IPPROTO_TCP:
+		if len(l4) < 20 {
+			return nil, 0, nil, false
+		}
+		off := int(l4[12]>>4) * 4
+		if off < 20 || len(l4) < off {
+			return nil, 0, nil, false
+		}
+		payload = l4[off:]
+	default:
+		return nil, 0, nil, false
+	}
+	return net.IP(append([]byte{}, ip[12:16]...)), proto, payload, true
+}
+
+// underAny reports whether name equals or is below one of the suffixes.
+func underAny(name string, suffixes []string) bool {
+	for _, s := range suffixes {
+		if name == s || strings.HasSuffix(name, "."+s) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+}
+
+// rxQueues returns the number of receive queues of the interface.
+func rxQueues(ifaceName string) (int, error) {
+	queues, err := filepath.Glob(filepath.Join("/sys/class/net", ifaceName, "queues", "rx-*"))
+	if err != nil {
+		return 0, err
+	}
+	if len(queues) == 0 {
+		return 0, fmt.Errorf("no receive queues found for %s: %w", ifaceName, os.ErrNotExist)
+	}
+	return len(queues), nil
+}
@@ -0,0 +1,229 @@
+package inspect
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/dnsanalytics"
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+// fakeMap records the verdicts the inspector writes.
+type fakeMap struct {
+	config  map[uint32]uint64
+	ports   map[uint16]uint8
+	cleared map[string]time.Duration
+	blocked map[string]time.Duration
+}
+
+func newFakeMap() *fakeMap {
+	return &fakeMap{
+		config:  map[uint32]uint64{},
+		cleared: map[string]time.Duration{},
+		blocked: map[string]time.Duration{},
+	}
+}
+
+func (f *fakeMap) SetConfig(key uint32, value uint64) error {
+	f.config[key] = value
+	return nil
+}
+
+func (f *fakeMap) SetInspectPorts(ports map[uint16]uint8) error {
+	f.ports = ports
+	return nil
+}
+
+func (f *fakeMap) SetInspectSocket(queue uint32, fd int) error { return nil }
+func (f *fakeMap) RemoveInspectSocket(queue uint32) error      { return nil }
+
+func (f *fakeMap) ClearInspectSource(ip net.IP, ttl time.Duration) error {
+	f.cleared[ip.String()] = ttl
+	return nil
+}
+
+func (f *fakeMap) AddBlacklistCIDRWithTTL(cidr string, reason uint32, ttl time.Duration) error {
+	f.blocked[cidr] = ttl
+	return nil
+}
+
+func (f *fakeMap) ReadInspectStats() (bpf.InspectStats, error) {
+	return bpf.InspectStats{Redirected: 7}, nil
+}
+
+// frame builds an Ethernet/IPv4 frame from src carrying payload over UDP
+// or TCP.
+func frame(proto uint8, src string, payload []byte) []byte {
+	l4Len := 8
+	if proto == unix.IPPROTO_TCP {
+		l4Len = 20
+	}
+	b := make([]byte, 14+20+l4Len, 14+20+l4Len+len(payload))
+	binary.BigEndian.PutUint16(b[12:], unix.ETH_P_IP)
+	ip := b[14:]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+l4Len+len(payload)))
+	ip[9] = proto
+	copy(ip[12:], net.ParseIP(src).To4())
+	copy(ip[16:], net.ParseIP("192.0.2.1").To4())
+	if proto == unix.IPPROTO_TCP {
+		ip[20+12] = 5 << 4
+	}
+	return append(b, payload...)
+}
+
+func dnsQuery(name string) []byte {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[4:], 1) // QDCOUNT
+	msg = append(msg, dnsanalytics.EncodeName(name)...)
+	return append(msg, 0, 1, 0, 1) // A, IN
+}
+
+func TestInspect(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DNS.MaxQNameLen = 60
+	cfg.DNS.BlockSuffixes = []string{"Evil.example."}
+	cfg.TLS.RequireSNI = true
+	cfg.TLS.BlockSNISuffixes = []string{"bad.example"}
+	m := newFakeMap()
+	in := NewInspector(zap.NewNop(), cfg, m)
+
+	in.Inspect(frame(unix.IPPROTO_UDP, "198.51.100.1", dnsQuery("www.example.com")))
+	in.Inspect(frame(unix.IPPROTO_UDP, "198.51.100.2", dnsQuery("x.evil.example")))
+	in.Inspect(frame(unix.IPPROTO_UDP, "198.51.100.3", dnsQuery(strings.Repeat("a", 61)+".com")))
+	in.Inspect(frame(unix.IPPROTO_TCP, "198.51.100.4", helloRecord("www.example.com")))
+	in.Inspect(frame(unix.IPPROTO_TCP, "198.51.100.5", helloRecord("")))
+	in.Inspect(frame(unix.IPPROTO_TCP, "198.51.100.6", helloRecord("api.bad.example")))
+	in.Inspect(frame(unix.IPPROTO_TCP, "198.51.100.7", []byte("GET / HTTP/1.1\r\n")))
+	in.Inspect([]byte{1, 2, 3})
+
+	for _, ip := range []string{"198.51.100.1", "198.51.100.4"} {
+		if m.cleared[ip] != 300*time.Second {
+			t.Errorf("%s cleared for %v, want 5m", ip, m.cleared[ip])
+		}
+	}
+	for _, ip := range []string{"198.51.100.2", "198.51.100.3", "198.51.100.5", "198.51.100.6"} {
+		if m.blocked[ip+"/32"] != 600*time.Second {
+			t.Errorf("%s blocked for %v, want 10m", ip, m.blocked[ip+"/32"])
+		}
+	}
+	if len(m.cleared) != 2 || len(m.blocked) != 4 {
+		t.Errorf("cleared %v, blocked %v", m.cleared, m.blocked)
+	}
+
+	st, err := in.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if st.Inspected != 6 || st.Malformed != 2 || st.Cleared != 2 || st.Redirected != 7 {
+		t.Errorf("status counters = %+v", st)
+	}
+	for _, r := range []string{ReasonDNSBlockedName, ReasonDNSLongName, ReasonTLSNoSNI, ReasonTLSBlockedSNI} {
+		if st.Blocked[r] != 1 {
+			t.Errorf("blocked[%s] = %d, want 1", r, st.Blocked[r])
+		}
+	}
+	if len(st.Recent) != 4 || st.Recent[0].Source != "198.51.100.6" || st.Recent[0].Detail != "api.bad.example" {
+		t.Errorf("recent = %+v, want newest first", st.Recent)
+	}
+}
+
+func TestProgram(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DNS.Ports = []uint16{53, 5353}
+	cfg.SuspectScore = 200
+	m := newFakeMap()
+	in := NewInspector(zap.NewNop(), cfg, m)
+	if err := in.program(); err != nil {
+		t.Fatalf("program: %v", err)
+	}
+	if m.ports[53] != bpf.InspectDNS || m.ports[5353] != bpf.InspectDNS || m.ports[443] != bpf.InspectTLS {
+		t.Errorf("ports = %v", m.ports)
+	}
+	if m.config[bpf.CfgInspectEnable] != 1 || m.config[bpf.CfgInspectSample] != 100 || m.config[bpf.CfgInspectScore] != 200 {
+		t.Errorf("config = %v", m.config)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{"defaults", func(c *Config) {}, ""},
+		{"no ports", func(c *Config) { c.DNS.Ports, c.TLS.Ports = nil, nil }, "ports required"},
+		{"port twice", func(c *Config) { c.TLS.Ports = []uint16{53} }, "listed twice"},
+		{"zero clear", func(c *Config) { c.ClearSec = 0 }, "clear_sec"},
+		{"too many queues", func(c *Config) { c.Queues = 65 }, "queues"},
+		{"bad ja3", func(c *Config) { c.TLS.BlockJA3 = []string{"abc"} }, "not an MD5"},
+		{"ja3", func(c *Config) { c.TLS.BlockJA3 = []string{"E7D705A3286E19EA42F587B344EE6865"} }, ""},
+		{"qname too long", func(c *Config) { c.DNS.MaxQNameLen = 300 }, "max_qname_len"},
+		{"empty suffix", func(c *Config) { c.DNS.BlockSuffixes = []string{"."} }, "empty name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDNSSignatures(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DNS.Zones = []string{"example.com"}
+	in := NewInspector(zap.NewNop(), cfg, newFakeMap())
+
+	for i := 0; i < 50; i++ {
+		in.Inspect(frame(unix.IPPROTO_UDP, "198.51.100.1", dnsQuery("www.example.com")))
+	}
+	st, err := in.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(st.DNSSignatures) != 0 {
+		t.Fatalf("signatures for legitimate traffic: %+v", st.DNSSignatures)
+	}
+
+	// Water torture: a burst of random labels under the protected zone.
+	rnd := rand.New(rand.NewSource(1))
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	for i := 0; i < 500; i++ {
+		label := make([]byte, 16)
+		for j := range label {
+			label[j] = alphabet[rnd.Intn(len(alphabet))]
+		}
+		in.Inspect(frame(unix.IPPROTO_UDP, "203.0.113.9", dnsQuery(string(label)+".example.com")))
+	}
+
+	st, err = in.Status()
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(st.DNSZones) != 1 || !st.DNSZones[0].RandomSubdomain {
+		t.Errorf("zones = %+v, want example.com flagged", st.DNSZones)
+	}
+	if len(st.DNSSignatures) != 1 {
+		t.Fatalf("signatures = %+v, want one for example.com", st.DNSSignatures)
+	}
+	sig := st.DNSSignatures[0]
+	if sig.Domain != "example.com" || string(sig.Suffix) != "\x07example\x03com\x00" || sig.MaxQPS == 0 {
+		t.Errorf("signature = %+v", sig)
+	}
+}
@@ -0,0 +1,163 @@
+package inspect
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// TLS wire-format constants.
+const (
+	tlsRecordHandshake = 0x16
+	tlsClientHello     = 0x01
+
+	extServerName     = 0
+	extSupportedCurve = 10
+	extPointFormats   = 11
+	sniHostName       = 0
+)
+
+var errNotClientHello = errors.New("not a TLS ClientHello")
+
+// clientHello is what the inspector takes from a ClientHello. A
+// ClientHello longer than its first segment is not Complete and has no
+// JA3; its SNI is set if the segment carries it.
+type clientHello struct {
+	SNI      string
+	JA3      string // MD5 of the JA3 string, hex
+	Complete bool
+}
+
+// tlsReader reads big-endian fields and reports running out of data
+// instead of failing. A short reader holds a vector cut off by the end of
+// the segment.
+type tlsReader struct {
+	b     []byte
+	eof   bool
+	short bool
+}
+
+func (r *tlsReader) next(n int) []byte {
+	if r.eof || len(r.b) < n {
+		r.eof = true
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *tlsReader) u8() int {
+	if b := r.next(1); b != nil {
+		return int(b[0])
+	}
+	return 0
+}
+
+func (r *tlsReader) u16() int {
+	if b := r.next(2); b != nil {
+		return int(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+// vector returns the next length-prefixed field, or as much of it as is
+// left.
+func (r *tlsReader) vector(lenBytes int) *tlsReader {
+	var n int
+	if lenBytes == 1 {
+		n = r.u8()
+	} else {
+		n = r.u16()
+	}
+	if r.eof {
+		return &tlsReader{eof: true}
+	}
+	if len(r.b) < n {
+		v := &tlsReader{b: r.b, short: true}
+		r.b, r.eof = nil, true
+		return v
+	}
+	return &tlsReader{b: r.next(n)}
+}
+
+// parseClientHello parses the ClientHello starting a TLS stream: its
+// server name and JA3 fingerprint.
+func parseClientHello(b []byte) (clientHello, error) {
+	var hello clientHello
+	if len(b) < 9 || b[0] != tlsRecordHandshake || b[1] != 3 || b[5] != tlsClientHello {
+		return hello, errNotClientHello
+	}
+	r := &tlsReader{b: b[9:]}
+	if hsLen := int(b[6])<<16 | int(b[7])<<8 | int(b[8]); hsLen <= len(r.b) {
+		r.b = r.b[:hsLen]
+	}
+
+	version := r.u16()
+	r.next(32)  // Random
+	r.vector(1) // Session ID
+	suites := r.vector(2)
+	r.vector(1) // Compression methods
+	exts := r.vector(2)
+
+	var ciphers, extensions, curves, formats []string
+	for len(suites.b) >= 2 {
+		ciphers = appendValue(ciphers, suites.u16())
+	}
+	for !exts.eof && len(exts.b) > 0 {
+		typ := exts.u16()
+		data := exts.vector(2)
+		if exts.eof && data.eof {
+			break
+		}
+		extensions = appendValue(extensions, typ)
+		switch typ {
+		case extServerName:
+			list := data.vector(2)
+			for !list.eof && len(list.b) > 0 {
+				kind := list.u8()
+				name := list.vector(2)
+				if kind == sniHostName && !name.eof && !name.short {
+					hello.SNI = string(name.b)
+				}
+			}
+		case extSupportedCurve:
+			list := data.vector(2)
+			for len(list.b) >= 2 {
+				curves = appendValue(curves, list.u16())
+			}
+		case extPointFormats:
+			list := data.vector(1)
+			for len(list.b) >= 1 {
+				formats = append(formats, strconv.Itoa(list.u8()))
+			}
+		}
+	}
+	if r.eof || suites.eof || exts.eof {
+		return hello, nil
+	}
+
+	ja3 := strings.Join([]string{
+		strconv.Itoa(version),
+		strings.Join(ciphers, "-"),
+		strings.Join(extensions, "-"),
+		strings.Join(curves, "-"),
+		strings.Join(formats, "-"),
+	}, ",")
+	sum := md5.Sum([]byte(ja3))
+	hello.JA3 = hex.EncodeToString(sum[:])
+	hello.Complete = true
+	return hello, nil
+}
+
+// appendValue appends v unless it is a GREASE value (RFC 8701), which
+// JA3 leaves out.
+func appendValue(list []string, v int) []string {
+	if v&0x0F0F == 0x0A0A && v>>8 == v&0xFF {
+		return list
+	}
+	return append(list, strconv.Itoa(v))
+}
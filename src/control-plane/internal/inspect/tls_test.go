@@ -0,0 +1,72 @@
+package inspect
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+// helloRecord builds a ClientHello record as a browser would send it,
+// GREASE values included, with sni as server name unless empty.
+func helloRecord(sni string) []byte {
+	u16 := func(b []byte, v int) []byte { return binary.BigEndian.AppendUint16(b, uint16(v)) }
+	ext := func(b []byte, typ int, data []byte) []byte {
+		return append(u16(u16(b, typ), len(data)), data...)
+	}
+
+	var exts []byte
+	exts = ext(exts, 0x1a1a, nil)
+	if sni != "" {
+		name := append(u16([]byte{sniHostName}, len(sni)), sni...)
+		exts = ext(exts, extServerName, append(u16(nil, len(name)), name...))
+	}
+	exts = ext(exts, extSupportedCurve, []byte{0, 6, 0x2a, 0x2a, 0, 29, 0, 23})
+	exts = ext(exts, extPointFormats, []byte{1, 0})
+
+	body := u16(nil, 0x0303)
+	body = append(body, make([]byte, 32)...)                      // Random
+	body = append(body, 0)                                        // Session ID
+	body = append(body, 0, 6, 0x0a, 0x0a, 0x13, 0x01, 0xc0, 0x2f) // Cipher suites
+	body = append(body, 1, 0)                                     // Compression methods
+	body = append(u16(body, len(exts)), exts...)
+
+	hs := append([]byte{tlsClientHello, 0, byte(len(body) >> 8), byte(len(body))}, body...)
+	return append(u16([]byte{tlsRecordHandshake, 3, 1}, len(hs)), hs...)
+}
+
+func ja3(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestParseClientHello(t *testing.T) {
+	full := helloRecord("www.example.com")
+	tests := []struct {
+		name string
+		b    []byte
+		want clientHello
+	}{
+		{"with SNI", full, clientHello{SNI: "www.example.com", JA3: ja3("771,4865-49199,0-10-11,29-23,0"), Complete: true}},
+		{"without SNI", helloRecord(""), clientHello{JA3: ja3("771,4865-49199,10-11,29-23,0"), Complete: true}},
+		{"continued after SNI", full[:len(full)-5], clientHello{SNI: "www.example.com"}},
+		{"continued within SNI", full[:len(full)-20], clientHello{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseClientHello(tt.b)
+			if err != nil {
+				t.Fatalf("parseClientHello: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseClientHello = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	for _, b := range [][]byte{nil, []byte("GET / HTTP/1.1\r\n"), {0x16, 3, 1, 0, 4, 2, 0, 0, 0}} {
+		if _, err := parseClientHello(b); err != errNotClientHello {
+			t.Errorf("parseClientHello(%q) = %v, want errNotClientHello", b, err)
+		}
+	}
+}
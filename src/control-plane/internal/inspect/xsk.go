@@ -0,0 +1,197 @@
+package inspect
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Every socket has its own UMEM of frameCount frames, all of which are
+// kept on the fill ring: a frame received is inspected and handed back
+// before the next one is read. Nothing is transmitted, so the completion
+// ring, which the kernel requires, stays empty.
+const (
+	frameSize    = 2048
+	frameCount   = 2048
+	fillRingSize = frameCount
+	rxRingSize   = frameCount
+	compRingSize = 64
+
+	sizeofRxDesc   = 16 // struct xdp_desc
+	sizeofAddrDesc = 8  // UMEM address on the fill and completion rings
+)
+
+// ring is a single-producer single-consumer ring shared with the kernel.
+// Producer and consumer run freely and are masked to index descs.
+type ring struct {
+	mem      []byte
+	producer *uint32
+	consumer *uint32
+	descs    []byte
+	mask     uint32
+}
+
+func newRing(mem []byte, off unix.XDPRingOffset, size uint32, descSize int) ring {
+	return ring{
+		mem:      mem,
+		producer: (*uint32)(unsafe.Pointer(&mem[off.Producer])),
+		consumer: (*uint32)(unsafe.Pointer(&mem[off.Consumer])),
+		descs:    mem[off.Desc : off.Desc+uint64(size)*uint64(descSize)],
+		mask:     size - 1,
+	}
+}
+
+// xsk is an AF_XDP socket bound to one receive queue.
+type xsk struct {
+	fd    int
+	queue uint32
+	umem  []byte
+	fill  ring
+	comp  ring
+	rx    ring
+}
+
+// openXSK creates a socket receiving what the XDP program redirects on
+// the queue of the interface, in zero-copy mode where the driver
+// supports it.
+func openXSK(ifindex int, queue uint32) (*xsk, error) {
+	fd, err := unix.Socket(unix.AF_XDP, unix.SOCK_RAW|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("creating AF_XDP socket: %w", err)
+	}
+	s := &xsk{fd: fd, queue: queue}
+	if err := s.setup(ifindex); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *xsk) setup(ifindex int) error {
+	var err error
+	s.umem, err = unix.Mmap(-1, 0, frameCount*frameSize,
+		unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return fmt.Errorf("allocating UMEM: %w", err)
+	}
+	reg := unix.XDPUmemReg{
+		Addr:       uint64(uintptr(unsafe.Pointer(&s.umem[0]))),
+		Len:        uint64(len(s.umem)),
+		Chunk_size: frameSize,
+	}
+	if err := sockopt(unix.SYS_SETSOCKOPT, s.fd, unix.XDP_UMEM_REG, unsafe.Pointer(&reg), unsafe.Sizeof(reg)); err != nil {
+		return fmt.Errorf("registering UMEM: %w", err)
+	}
+	for _, r := range []struct{ opt, size int }{
+		{unix.XDP_UMEM_FILL_RING, fillRingSize},
+		{unix.XDP_UMEM_COMPLETION_RING, compRingSize},
+		{unix.XDP_RX_RING, rxRingSize},
+	} {
+		if err := unix.SetsockoptInt(s.fd, unix.SOL_XDP, r.opt, r.size); err != nil {
+			return fmt.Errorf("sizing AF_XDP rings: %w", err)
+		}
+	}
+
+	var off unix.XDPMmapOffsets
+	if err := sockopt(unix.SYS_GETSOCKOPT, s.fd, unix.XDP_MMAP_OFFSETS, unsafe.Pointer(&off), unsafe.Sizeof(off)); err != nil {
+		return fmt.Errorf("reading AF_XDP ring offsets: %w", err)
+	}
+	if s.fill, err = s.mmapRing(unix.XDP_UMEM_PGOFF_FILL_RING, off.Fr, fillRingSize, sizeofAddrDesc); err != nil {
+		return err
+	}
+	if s.comp, err = s.mmapRing(unix.XDP_UMEM_PGOFF_COMPLETION_RING, off.Cr, compRingSize, sizeofAddrDesc); err != nil {
+		return err
+	}
+	if s.rx, err = s.mmapRing(unix.XDP_PGOFF_RX_RING, off.Rx, rxRingSize, sizeofRxDesc); err != nil {
+		return err
+	}
+
+	// Hand every frame to the kernel before it can receive.
+	for i := uint32(0); i < frameCount; i++ {
+		binary.NativeEndian.PutUint64(s.fill.descs[i*sizeofAddrDesc:], uint64(i)*frameSize)
+	}
+	atomic.StoreUint32(s.fill.producer, frameCount)
+
+	if err := unix.Bind(s.fd, &unix.SockaddrXDP{Ifindex: uint32(ifindex), QueueID: s.queue}); err != nil {
+		return fmt.Errorf("binding AF_XDP socket: %w", err)
+	}
+	return nil
+}
+
+func (s *xsk) mmapRing(pgoff int64, off unix.XDPRingOffset, size uint32, descSize int) (ring, error) {
+	mem, err := unix.Mmap(s.fd, pgoff, int(off.Desc)+int(size)*descSize,
+		unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED|unix.MAP_POPULATE)
+	if err != nil {
+		return ring{}, fmt.Errorf("mapping AF_XDP ring: %w", err)
+	}
+	return newRing(mem, off, size, descSize), nil
+}
+
+// sockopt calls get- or setsockopt on SOL_XDP with a struct argument,
+// which x/sys/unix has no wrappers for.
+func sockopt(call uintptr, fd, opt int, val unsafe.Pointer, size uintptr) error {
+	var errno unix.Errno
+	if call == unix.SYS_GETSOCKOPT {
+		l := uint32(size)
+		_, _, errno = unix.Syscall6(call, uintptr(fd), unix.SOL_XDP, uintptr(opt), uintptr(val), uintptr(unsafe.Pointer(&l)), 0)
+	} else {
+		_, _, errno = unix.Syscall6(call, uintptr(fd), unix.SOL_XDP, uintptr(opt), uintptr(val), size, 0)
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// poll waits up to timeout for received frames.
+func (s *xsk) poll(timeout time.Duration) (bool, error) {
+	fds := []unix.PollFd{{Fd: int32(s.fd), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, int(timeout/time.Millisecond))
+	if err == unix.EINTR {
+		return false, nil
+	}
+	return n > 0, err
+}
+
+// receive passes every received frame to fn and returns it to the fill
+// ring. fn must not keep the frame.
+func (s *xsk) receive(fn func([]byte)) int {
+	return receiveRing(&s.rx, &s.fill, s.umem, fn)
+}
+
+// receiveRing drains rx into fn, moving each frame back to fill.
+func receiveRing(rx, fill *ring, umem []byte, fn func([]byte)) int {
+	prod := atomic.LoadUint32(rx.producer)
+	cons := *rx.consumer
+	fillProd := *fill.producer
+	n := 0
+	for ; cons != prod; cons++ {
+		d := rx.descs[(cons&rx.mask)*sizeofRxDesc:]
+		addr := binary.NativeEndian.Uint64(d)
+		length := binary.NativeEndian.Uint32(d[8:])
+		if addr+uint64(length) <= uint64(len(umem)) {
+			fn(umem[addr : addr+uint64(length)])
+		}
+
+		// Aligned mode: the frame starts at the chunk boundary.
+		binary.NativeEndian.PutUint64(fill.descs[(fillProd&fill.mask)*sizeofAddrDesc:], addr&^(frameSize-1))
+		fillProd++
+		n++
+	}
+	atomic.StoreUint32(fill.producer, fillProd)
+	atomic.StoreUint32(rx.consumer, cons)
+	return n
+}
+
+func (s *xsk) close() {
+	unix.Close(s.fd)
+	for _, mem := range [][]byte{s.rx.mem, s.comp.mem, s.fill.mem, s.umem} {
+		if mem != nil {
+			unix.Munmap(mem)
+		}
+	}
+}
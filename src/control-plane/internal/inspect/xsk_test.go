@@ -0,0 +1,39 @@
+package inspect
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestReceiveRing(t *testing.T) {
+	const size = 4
+	off := unix.XDPRingOffset{Producer: 0, Consumer: 64, Desc: 128}
+	rx := newRing(make([]byte, 128+size*sizeofRxDesc), off, size, sizeofRxDesc)
+	fill := newRing(make([]byte, 128+size*sizeofAddrDesc), off, size, sizeofAddrDesc)
+	umem := make([]byte, size*frameSize)
+
+	// The kernel received into frames 3 and 1 (at an offset), with the
+	// ring indexes about to wrap.
+	*rx.consumer, *rx.producer = 3, 5
+	*fill.consumer, *fill.producer = 7, 7
+	copy(umem[3*frameSize:], "abc")
+	copy(umem[1*frameSize+256:], "de")
+	binary.NativeEndian.PutUint64(rx.descs[3*sizeofRxDesc:], 3*frameSize)
+	binary.NativeEndian.PutUint32(rx.descs[3*sizeofRxDesc+8:], 3)
+	binary.NativeEndian.PutUint64(rx.descs[0:], 1*frameSize+256)
+	binary.NativeEndian.PutUint32(rx.descs[8:], 2)
+
+	var got []string
+	n := receiveRing(&rx, &fill, umem, func(b []byte) { got = append(got, string(b)) })
+	if n != 2 || len(got) != 2 || got[0] != "abc" || got[1] != "de" {
+		t.Fatalf("received %d frames %q, want abc and de", n, got)
+	}
+	if *rx.consumer != 5 || *fill.producer != 9 {
+		t.Errorf("rx consumer %d, fill producer %d, want 5 and 9", *rx.consumer, *fill.producer)
+	}
+	if a, b := binary.NativeEndian.Uint64(fill.descs[3*sizeofAddrDesc:]), binary.NativeEndian.Uint64(fill.descs[0:]); a != 3*frameSize || b != 1*frameSize {
+		t.Errorf("frames returned to fill ring at %d and %d, want %d and %d", a, b, 3*frameSize, frameSize)
+	}
+}
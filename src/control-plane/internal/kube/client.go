@@ -0,0 +1,212 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Service account files mounted into every pod.
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	requestTimeout    = 10 * time.Second
+)
+
+// errNotFound reports a 404 from the API server.
+var errNotFound = errors.New("not found")
+
+// Client is a minimal Kubernetes API client for the few resources the
+// scrubber reads and writes.
+type Client struct {
+	base      string // https://host:port
+	tokenFile string // Re-read on every request; projected tokens rotate
+	namespace string // The pod's namespace
+	http      *http.Client
+}
+
+// NewInClusterClient creates a client from the pod's environment and
+// service account.
+func NewInClusterClient() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes pod (KUBERNETES_SERVICE_HOST/PORT unset)")
+	}
+
+	pem, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("reading service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in service account CA")
+	}
+	ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+	if err != nil {
+		return nil, fmt.Errorf("reading service account namespace: %w", err)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return &Client{
+		base:      "https://" + net.JoinHostPort(host, port),
+		tokenFile: serviceAccountDir + "/token",
+		namespace: strings.TrimSpace(string(ns)),
+		http:      &http.Client{Transport: transport, Timeout: requestTimeout},
+	}, nil
+}
+
+// Namespace returns the pod's namespace.
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// do sends a request and decodes a JSON answer into out, if not nil.
+func (c *Client) do(ctx context.Context, method, path, contentType string, body, out interface{}) error {
+	var rd io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, rd)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.tokenFile != "" {
+		token, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return fmt.Errorf("reading service account token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s %s: %w", method, path, errNotFound)
+	}
+	if resp.StatusCode/100 != 2 {
+		// API errors are Status objects with a message.
+		var st struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&st)
+		return fmt.Errorf("%s %s: HTTP %d: %s", method, path, resp.StatusCode, st.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return nil
+}
+
+func policyPath(ns, name string) string {
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/scrubberpolicies/%s",
+		Group, Version, url.PathEscape(ns), url.PathEscape(name))
+}
+
+// FetchSource reads the configuration: the ConfigMap if one is set,
+// otherwise spec.config of the policy.
+func (c *Client) FetchSource(ctx context.Context, cfg Config) (Source, error) {
+	ns := cfg.Namespace
+	if ns == "" {
+		ns = c.namespace
+	}
+
+	if cfg.ConfigMap != "" {
+		var cm configMap
+		path := fmt.Sprintf("/api/v1/namespaces/%s/configmaps/%s", url.PathEscape(ns), url.PathEscape(cfg.ConfigMap))
+		if err := c.do(ctx, http.MethodGet, path, "", nil, &cm); err != nil {
+			return Source{}, fmt.Errorf("reading ConfigMap %s/%s: %w", ns, cfg.ConfigMap, err)
+		}
+		data, ok := cm.Data[cfg.ConfigKey]
+		if !ok {
+			return Source{}, fmt.Errorf("ConfigMap %s/%s has no key %q", ns, cfg.ConfigMap, cfg.ConfigKey)
+		}
+		return Source{
+			Kind:      "ConfigMap",
+			Namespace: ns,
+			Name:      cfg.ConfigMap,
+			Version:   cm.Metadata.ResourceVersion,
+			Data:      []byte(data),
+		}, nil
+	}
+
+	var p policy
+	if err := c.do(ctx, http.MethodGet, policyPath(ns, cfg.Policy), "", nil, &p); err != nil {
+		return Source{}, fmt.Errorf("reading ScrubberPolicy %s/%s: %w", ns, cfg.Policy, err)
+	}
+	data := []byte(p.Spec.Config)
+	if len(data) == 0 || string(data) == "null" {
+		// An empty policy runs with the built-in defaults.
+		data = []byte("{}")
+	}
+	return Source{
+		Kind:       "ScrubberPolicy",
+		Namespace:  ns,
+		Name:       cfg.Policy,
+		Version:    fmt.Sprintf("%d", p.Metadata.Generation),
+		Generation: p.Metadata.Generation,
+		Data:       data,
+	}, nil
+}
+
+// ReportStatus merges the node's status into status.nodes of the policy,
+// leaving the entries of other nodes alone.
+func (c *Client) ReportStatus(ctx context.Context, cfg Config, nodeName string, st NodeStatus) error {
+	ns := cfg.Namespace
+	if ns == "" {
+		ns = c.namespace
+	}
+	patch := map[string]interface{}{
+		"status": map[string]interface{}{
+			"nodes": map[string]NodeStatus{nodeName: st},
+		},
+	}
+	return c.do(ctx, http.MethodPatch, policyPath(ns, cfg.Policy)+"/status",
+		"application/merge-patch+json", patch, nil)
+}
+
+// NodeAddresses returns the node's ExternalIP addresses followed by its
+// InternalIP ones.
+func (c *Client) NodeAddresses(ctx context.Context, nodeName string) ([]net.IP, error) {
+	var n node
+	if err := c.do(ctx, http.MethodGet, "/api/v1/nodes/"+url.PathEscape(nodeName), "", nil, &n); err != nil {
+		return nil, fmt.Errorf("reading node %s: %w", nodeName, err)
+	}
+	var ips []net.IP
+	for _, typ := range []string{"ExternalIP", "InternalIP"} {
+		for _, a := range n.Status.Addresses {
+			if a.Type != typ {
+				continue
+			}
+			if ip := net.ParseIP(a.Address); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips, nil
+}
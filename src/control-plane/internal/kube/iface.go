@@ -0,0 +1,98 @@
+package kube
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// localAddrs maps interface names to their addresses.
+type localAddrs map[string][]net.IP
+
+// DiscoverInterface finds the node's external interface: the one holding
+// the node's ExternalIP, or else its InternalIP, as the API server lists
+// them. When no local interface holds any of them, e.g. behind cloud NAT,
+// the interface of the default route is used.
+func (c *Client) DiscoverInterface(ctx context.Context, nodeName string) (string, error) {
+	ips, err := c.NodeAddresses(ctx, nodeName)
+	if err != nil {
+		return "", err
+	}
+	local, err := readLocalAddrs()
+	if err != nil {
+		return "", err
+	}
+	if name := interfaceFor(ips, local); name != "" {
+		return name, nil
+	}
+
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return "", fmt.Errorf("no interface holds the node addresses and reading routes failed: %w", err)
+	}
+	defer f.Close()
+	name, err := defaultRouteInterface(f)
+	if err != nil {
+		return "", fmt.Errorf("no interface holds the node addresses: %w", err)
+	}
+	return name, nil
+}
+
+func readLocalAddrs() (localAddrs, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("listing interfaces: %w", err)
+	}
+	local := make(localAddrs, len(ifaces))
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipn, ok := a.(*net.IPNet); ok {
+				local[iface.Name] = append(local[iface.Name], ipn.IP)
+			}
+		}
+	}
+	return local, nil
+}
+
+// interfaceFor returns the interface holding the first of ips found.
+func interfaceFor(ips []net.IP, local localAddrs) string {
+	for _, ip := range ips {
+		for name, addrs := range local {
+			for _, a := range addrs {
+				if a.Equal(ip) {
+					return name
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// defaultRouteInterface returns the interface of the IPv4 default route
+// from /proc/net/route.
+func defaultRouteInterface(r io.Reader) (string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Scan() // Header
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		if len(fields) >= 8 && fields[1] == "00000000" && fields[7] == "00000000" {
+			return fields[0], nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no default route")
+}
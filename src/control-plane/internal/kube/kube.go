@@ -0,0 +1,127 @@
+// Package kube runs the scrubber as a Kubernetes DaemonSet on ingress
+// nodes: it finds the node's external interface, reads the configuration
+// from a ScrubberPolicy custom resource or a ConfigMap, applies changes
+// to it while running and reports each node's state in the policy
+// status. It talks to the API server directly with the pod's service
+// account.
+package kube
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// API group and version of the ScrubberPolicy custom resource, see
+// deploy/kubernetes/crd.yaml.
+const (
+	Group   = "scrubber.ebpf-ddos-scrubber.io"
+	Version = "v1alpha1"
+)
+
+// AutoInterface is the interface setting asking for the node's external
+// interface to be discovered.
+const AutoInterface = "auto"
+
+// Config enables Kubernetes mode. It is read from the bootstrap config
+// file, flags and environment only, never from the policy itself.
+type Config struct {
+	Enabled   bool   `yaml:"enabled"`
+	Namespace string `yaml:"namespace"`  // Default: the pod's namespace
+	Policy    string `yaml:"policy"`     // ScrubberPolicy holding the config and status
+	ConfigMap string `yaml:"config_map"` // Read the config from this ConfigMap instead
+	ConfigKey string `yaml:"config_key"` // ConfigMap key holding the YAML config
+	NodeName  string `yaml:"node_name"`  // Default: $NODE_NAME from the downward API
+	ResyncSec int    `yaml:"resync_sec"` // How often the source is re-read and status reported
+}
+
+// DefaultConfig returns the built-in Kubernetes settings, disabled.
+func DefaultConfig() Config {
+	return Config{
+		Policy:    "default",
+		ConfigKey: "config.yaml",
+		ResyncSec: 30,
+	}
+}
+
+// Validate checks the Kubernetes settings.
+func (c Config) Validate() error {
+	if c.Policy == "" {
+		return fmt.Errorf("policy is required")
+	}
+	if c.ConfigMap != "" && c.ConfigKey == "" {
+		return fmt.Errorf("config_key is required with config_map")
+	}
+	if c.ResyncSec < 5 || c.ResyncSec > 3600 {
+		return fmt.Errorf("resync_sec must be 5-3600")
+	}
+	return nil
+}
+
+// Source is the configuration read from the cluster.
+type Source struct {
+	Kind      string // "ScrubberPolicy" or "ConfigMap"
+	Namespace string
+	Name      string
+	// Version changes whenever the content does: the generation of a
+	// policy, the resourceVersion of a ConfigMap.
+	Version string
+	// Generation is the policy generation, reported back as
+	// observedGeneration (0 for a ConfigMap).
+	Generation int64
+	Data       []byte // YAML (or JSON) configuration
+}
+
+func (s Source) String() string {
+	return fmt.Sprintf("%s %s/%s version %s", s.Kind, s.Namespace, s.Name, s.Version)
+}
+
+// policy is the part of a ScrubberPolicy the scrubber reads.
+type policy struct {
+	Metadata struct {
+		Generation int64 `json:"generation"`
+	} `json:"metadata"`
+	Spec struct {
+		Config json.RawMessage `json:"config"`
+	} `json:"spec"`
+}
+
+// configMap is the part of a ConfigMap the scrubber reads.
+type configMap struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Data map[string]string `json:"data"`
+}
+
+// node is the part of a Node the scrubber reads.
+type node struct {
+	Status struct {
+		Addresses []struct {
+			Type    string `json:"type"`
+			Address string `json:"address"`
+		} `json:"addresses"`
+	} `json:"status"`
+}
+
+// NodeStatus is what a scrubber reports about itself under
+// status.nodes.<node> of the policy.
+type NodeStatus struct {
+	Interface       string `json:"interface"`
+	XDPMode         string `json:"xdpMode"`
+	Enabled         bool   `json:"enabled"`
+	EscalationLevel string `json:"escalationLevel"`
+	Maintenance     string `json:"maintenance,omitempty"`
+	// Healthy is false while the watchdog has unrepaired findings.
+	Healthy    bool   `json:"healthy"`
+	PPS        uint64 `json:"pps"`
+	DroppedPPS uint64 `json:"droppedPps"`
+	Revision   int    `json:"revision"` // Latest config revision
+
+	// Filled in by the operator
+	ObservedVersion    string    `json:"observedVersion,omitempty"`
+	ObservedGeneration int64     `json:"observedGeneration,omitempty"`
+	RestartRequired    bool      `json:"restartRequired,omitempty"`
+	Error              string    `json:"error,omitempty"` // Why the latest version was not applied
+	UpdatedAt          time.Time `json:"updatedAt"`
+}
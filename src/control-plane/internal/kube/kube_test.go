@@ -0,0 +1,229 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// fakeAPI serves a policy, a ConfigMap and a node, and records status
+// patches.
+type fakeAPI struct {
+	mu         sync.Mutex
+	generation int
+	config     string
+	patches    []map[string]interface{}
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	policy := "/apis/" + Group + "/" + Version + "/namespaces/scrubber/scrubberpolicies/edge"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == policy:
+		io.WriteString(w, `{"metadata":{"generation":`+strconv.Itoa(f.generation)+`},"spec":{"config":`+f.config+`}}`)
+	case r.Method == http.MethodPatch && r.URL.Path == policy+"/status":
+		if r.Header.Get("Content-Type") != "application/merge-patch+json" {
+			http.Error(w, "bad content type", http.StatusUnsupportedMediaType)
+			return
+		}
+		var patch map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&patch)
+		f.patches = append(f.patches, patch)
+		io.WriteString(w, `{}`)
+	case r.URL.Path == "/api/v1/namespaces/scrubber/configmaps/scrubber-config":
+		io.WriteString(w, `{"metadata":{"resourceVersion":"981"},"data":{"config.yaml":"interface: auto\n"}}`)
+	case r.URL.Path == "/api/v1/nodes/node-1":
+		io.WriteString(w, `{"status":{"addresses":[
+			{"type":"Hostname","address":"node-1"},
+			{"type":"InternalIP","address":"10.0.0.5"},
+			{"type":"ExternalIP","address":"203.0.113.5"}]}}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"kind":"Status","message":"not found"}`)
+	}
+}
+
+func newTestClient(t *testing.T, api *fakeAPI) *Client {
+	t.Helper()
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	return &Client{base: srv.URL, namespace: "scrubber", http: srv.Client()}
+}
+
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.Enabled = true
+	cfg.Policy = "edge"
+	return cfg
+}
+
+func TestFetchSource(t *testing.T) {
+	api := &fakeAPI{generation: 3, config: `{"rate_limit":{"syn_rate_pps":500}}`}
+	c := newTestClient(t, api)
+
+	src, err := c.FetchSource(context.Background(), testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.Kind != "ScrubberPolicy" || src.Version != "3" || src.Generation != 3 {
+		t.Errorf("policy source = %+v", src)
+	}
+	if !strings.Contains(string(src.Data), `"syn_rate_pps":500`) {
+		t.Errorf("policy data = %s", src.Data)
+	}
+
+	cfg := testConfig()
+	cfg.ConfigMap = "scrubber-config"
+	src, err = c.FetchSource(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.Kind != "ConfigMap" || src.Version != "981" || string(src.Data) != "interface: auto\n" {
+		t.Errorf("ConfigMap source = %+v", src)
+	}
+
+	cfg.ConfigKey = "other.yaml"
+	if _, err := c.FetchSource(context.Background(), cfg); err == nil {
+		t.Error("missing ConfigMap key accepted")
+	}
+
+	cfg = testConfig()
+	cfg.Policy = "missing"
+	if _, err := c.FetchSource(context.Background(), cfg); !errors.Is(err, errNotFound) {
+		t.Errorf("missing policy: err = %v", err)
+	}
+}
+
+func TestFetchSourceEmptyPolicy(t *testing.T) {
+	c := newTestClient(t, &fakeAPI{generation: 1, config: `null`})
+	src, err := c.FetchSource(context.Background(), testConfig())
+	if err != nil || string(src.Data) != "{}" {
+		t.Errorf("empty policy: data = %s, err = %v", src.Data, err)
+	}
+}
+
+func TestNodeAddresses(t *testing.T) {
+	c := newTestClient(t, &fakeAPI{})
+	ips, err := c.NodeAddresses(context.Background(), "node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ips) != 2 || ips[0].String() != "203.0.113.5" || ips[1].String() != "10.0.0.5" {
+		t.Errorf("addresses = %v, want ExternalIP first", ips)
+	}
+}
+
+func TestInterfaceFor(t *testing.T) {
+	local := localAddrs{
+		"eth0": {net.ParseIP("10.0.0.5")},
+		"eth1": {net.ParseIP("203.0.113.5"), net.ParseIP("2001:db8::5")},
+	}
+	tests := []struct {
+		ips  []string
+		want string
+	}{
+		{[]string{"203.0.113.5", "10.0.0.5"}, "eth1"},
+		{[]string{"198.51.100.1", "10.0.0.5"}, "eth0"},
+		{[]string{"198.51.100.1"}, ""},
+	}
+	for _, tt := range tests {
+		var ips []net.IP
+		for _, s := range tt.ips {
+			ips = append(ips, net.ParseIP(s))
+		}
+		if got := interfaceFor(ips, local); got != tt.want {
+			t.Errorf("interfaceFor(%v) = %q, want %q", tt.ips, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultRouteInterface(t *testing.T) {
+	routes := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+cni0	0001F40A	00000000	0001	0	0	0	00FFFFFF	0	0	0
+ens5	00000000	0100000A	0003	0	0	100	00000000	0	0	0
+`
+	got, err := defaultRouteInterface(strings.NewReader(routes))
+	if err != nil || got != "ens5" {
+		t.Errorf("default route interface = %q, %v, want ens5", got, err)
+	}
+	if _, err := defaultRouteInterface(strings.NewReader(strings.SplitN(routes, "\n", 3)[0])); err == nil {
+		t.Error("no default route: want error")
+	}
+}
+
+func TestOperatorSync(t *testing.T) {
+	api := &fakeAPI{generation: 1, config: `{}`}
+	c := newTestClient(t, api)
+	op := NewOperator(zap.NewNop(), testConfig(), c, "node-1")
+
+	var applied []string
+	fail := errors.New("in maintenance")
+	var applyErr error
+	op.OnChange(func(src Source) (bool, error) {
+		applied = append(applied, src.Version)
+		return src.Version == "2", applyErr
+	})
+	op.SetReporter(func() NodeStatus { return NodeStatus{Interface: "eth1", Healthy: true} })
+
+	ctx := context.Background()
+	op.Sync(ctx)
+	op.Sync(ctx) // Unchanged
+	api.mu.Lock()
+	api.generation = 2
+	api.mu.Unlock()
+	applyErr = fail
+	op.Sync(ctx)
+	applyErr = nil
+	op.Sync(ctx) // Retried
+
+	if got := strings.Join(applied, ","); got != "1,2,2" {
+		t.Errorf("applied versions = %s, want 1,2,2", got)
+	}
+	st := op.Status()
+	if st.ObservedGeneration != 2 || !st.RestartRequired || st.Error != "" || st.Interface != "eth1" {
+		t.Errorf("status = %+v", st)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.patches) != 4 {
+		t.Fatalf("%d status patches, want 4", len(api.patches))
+	}
+	nodes := api.patches[2]["status"].(map[string]interface{})["nodes"].(map[string]interface{})
+	failed := nodes["node-1"].(map[string]interface{})
+	if failed["error"] != "in maintenance" || failed["observedGeneration"] != 1.0 {
+		t.Errorf("status after failed apply = %v", failed)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{"defaults", func(c *Config) {}, false},
+		{"no policy", func(c *Config) { c.Policy = "" }, true},
+		{"config map without key", func(c *Config) { c.ConfigMap, c.ConfigKey = "cm", "" }, true},
+		{"resync too short", func(c *Config) { c.ResyncSec = 1 }, true},
+	}
+	for _, tt := range tests {
+		cfg := testConfig()
+		tt.modify(&cfg)
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
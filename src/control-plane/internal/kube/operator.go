@@ -0,0 +1,169 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Operator keeps a running scrubber in line with its policy: it re-reads
+// the configuration source every resync interval, hands new versions to
+// the engine and reports the node's state to the policy status.
+type Operator struct {
+	log    *zap.Logger
+	cfg    Config
+	client *Client
+	node   string
+
+	// now is the clock, replaced in tests.
+	now func() time.Time
+
+	mu       sync.Mutex
+	apply    func(Source) (restart bool, err error)
+	report   func() NodeStatus
+	observed Source
+	restart  bool
+	lastErr  string
+	// statusErr is the last status report error, logged once.
+	statusErr string
+}
+
+// NewOperator creates an operator for the node.
+func NewOperator(log *zap.Logger, cfg Config, client *Client, nodeName string) *Operator {
+	return &Operator{
+		log:    log,
+		cfg:    cfg,
+		client: client,
+		node:   nodeName,
+		now:    time.Now,
+	}
+}
+
+// OnChange sets the function applying a new version of the configuration.
+// It reports whether settings changed that only take effect after a
+// restart; an error leaves the version to be retried on the next resync.
+func (o *Operator) OnChange(fn func(Source) (restart bool, err error)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.apply = fn
+}
+
+// SetReporter sets the function returning the node's runtime state.
+func (o *Operator) SetReporter(fn func() NodeStatus) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.report = fn
+}
+
+// Run syncs every resync interval until ctx is done.
+func (o *Operator) Run(ctx context.Context) {
+	interval := time.Duration(o.cfg.ResyncSec) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	o.log.Info("kubernetes operator started",
+		zap.String("node", o.node),
+		zap.String("policy", o.cfg.Policy),
+		zap.Duration("resync", interval),
+	)
+	for {
+		o.Sync(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync applies the source if it changed and reports the status once.
+func (o *Operator) Sync(ctx context.Context) {
+	src, err := o.client.FetchSource(ctx, o.cfg)
+	if err != nil {
+		o.log.Warn("reading scrubber configuration from the cluster failed", zap.Error(err))
+		o.setError(err)
+	} else {
+		o.sync(src)
+	}
+	o.reportStatus(ctx)
+}
+
+func (o *Operator) sync(src Source) {
+	o.mu.Lock()
+	apply := o.apply
+	unchanged := src.Version == o.observed.Version && o.lastErr == ""
+	o.mu.Unlock()
+	if unchanged || apply == nil {
+		return
+	}
+
+	restart, err := apply(src)
+	if err != nil {
+		o.log.Error("applying scrubber configuration failed", zap.Stringer("source", src), zap.Error(err))
+		o.setError(err)
+		return
+	}
+	if restart {
+		o.log.Warn("scrubber configuration changed settings that need a restart", zap.Stringer("source", src))
+	}
+	o.log.Info("scrubber configuration applied", zap.Stringer("source", src))
+
+	o.mu.Lock()
+	o.observed = src
+	o.restart = restart
+	o.lastErr = ""
+	o.mu.Unlock()
+}
+
+func (o *Operator) setError(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.lastErr = err.Error()
+}
+
+// Status returns what is reported for the node.
+func (o *Operator) Status() NodeStatus {
+	o.mu.Lock()
+	report := o.report
+	o.mu.Unlock()
+
+	var st NodeStatus
+	if report != nil {
+		st = report()
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	st.ObservedVersion = o.observed.Version
+	st.ObservedGeneration = o.observed.Generation
+	st.RestartRequired = o.restart
+	st.Error = o.lastErr
+	st.UpdatedAt = o.now().UTC()
+	return st
+}
+
+func (o *Operator) reportStatus(ctx context.Context) {
+	err := o.client.ReportStatus(ctx, o.cfg, o.node, o.Status())
+
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	o.mu.Lock()
+	changed := msg != o.statusErr
+	o.statusErr = msg
+	o.mu.Unlock()
+
+	switch {
+	case err == nil && changed:
+		o.log.Info("reporting status to the ScrubberPolicy resumed")
+	case errors.Is(err, errNotFound) && changed:
+		// Expected with a ConfigMap source and no policy.
+		o.log.Info("ScrubberPolicy not found, status not reported", zap.String("policy", o.cfg.Policy))
+	case err != nil && changed:
+		o.log.Warn("reporting status to the ScrubberPolicy failed", zap.Error(err))
+	}
+}
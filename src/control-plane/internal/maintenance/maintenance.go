@@ -0,0 +1,337 @@
+// Package maintenance takes the scrubber out of the traffic path for
+// planned work. Entering maintenance first switches the XDP program to
+// pass-all and waits for in-flight events to drain, then either leaves the
+// program attached in bypass or detaches it from the interface. The
+// requester and reason are recorded, maintenance reverts on its own after
+// a timeout, and it is refused while escalation is HIGH or CRITICAL
+// unless forced.
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/escalation"
+	"go.uber.org/zap"
+)
+
+// Modes.
+const (
+	ModeBypass = "bypass" // Program stays attached, passing all traffic
+	ModeDetach = "detach" // Program removed from the interface
+)
+
+// States.
+const (
+	StateOff      = "off"
+	StateDraining = "draining" // Pass-all, waiting for in-flight events
+	StateActive   = "active"
+)
+
+// historySize bounds the list of past maintenance windows.
+const historySize = 32
+
+var (
+	// ErrEscalated is returned when entering maintenance while escalation
+	// is HIGH or CRITICAL without force.
+	ErrEscalated = errors.New("escalation is HIGH or CRITICAL; force required")
+	// ErrActive is returned when entering maintenance that is already on.
+	ErrActive = errors.New("maintenance already active")
+	// ErrInactive is returned when leaving maintenance that is off.
+	ErrInactive = errors.New("maintenance not active")
+)
+
+// Config holds the maintenance defaults.
+type Config struct {
+	Mode              string `yaml:"mode"`                // Default mode: bypass or detach
+	DrainSec          int    `yaml:"drain_sec"`           // Pass-all before the mode takes effect
+	DefaultTimeoutSec int    `yaml:"default_timeout_sec"` // Auto-revert when a request sets none
+	MaxTimeoutSec     int    `yaml:"max_timeout_sec"`     // Longest timeout a request may ask for
+}
+
+// DefaultConfig returns the built-in maintenance defaults.
+func DefaultConfig() Config {
+	return Config{
+		Mode:              ModeBypass,
+		DrainSec:          5,
+		DefaultTimeoutSec: 1800,
+		MaxTimeoutSec:     14400,
+	}
+}
+
+// Validate checks the mode and timeouts.
+func (c Config) Validate() error {
+	if err := validMode(c.Mode); err != nil {
+		return err
+	}
+	if c.DrainSec < 0 || c.DrainSec > 300 {
+		return fmt.Errorf("drain_sec must be 0-300")
+	}
+	if c.DefaultTimeoutSec <= 0 {
+		return fmt.Errorf("default_timeout_sec must be positive")
+	}
+	if c.MaxTimeoutSec < c.DefaultTimeoutSec {
+		return fmt.Errorf("max_timeout_sec must be at least default_timeout_sec")
+	}
+	return nil
+}
+
+func validMode(mode string) error {
+	if mode != ModeBypass && mode != ModeDetach {
+		return fmt.Errorf("mode must be %s or %s, got %q", ModeBypass, ModeDetach, mode)
+	}
+	return nil
+}
+
+// Dataplane switches the XDP program, implemented by the engine.
+type Dataplane interface {
+	GetConfig(key uint32) (uint64, error)
+	SetConfig(key uint32, value uint64) error
+	Detach() error
+	Reattach() error
+}
+
+// Request asks to enter maintenance.
+type Request struct {
+	Mode    string        // "" = configured default
+	Reason  string        // Required
+	Timeout time.Duration // 0 = configured default
+	Force   bool          // Enter even while escalation is HIGH or CRITICAL
+	By      string        // Requester
+}
+
+// Window is a maintenance period, current or past.
+type Window struct {
+	Mode     string    `json:"mode"`
+	Reason   string    `json:"reason"`
+	By       string    `json:"by"`
+	Forced   bool      `json:"forced"`
+	Level    string    `json:"level"` // Escalation level on entry
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"` // Auto-revert time
+	Ended    time.Time `json:"ended,omitempty"`
+	EndedBy  string    `json:"endedBy,omitempty"` // Requester, or "timeout"
+	EndError string    `json:"endError,omitempty"`
+}
+
+// Status is the maintenance state and recent windows.
+type Status struct {
+	State   string   `json:"state"`
+	Current *Window  `json:"current,omitempty"`
+	History []Window `json:"history"` // Newest first
+}
+
+// Manager enters and leaves maintenance.
+type Manager struct {
+	log *zap.Logger
+	cfg Config
+	dp  Dataplane
+
+	// now is the clock, replaced in tests.
+	now func() time.Time
+
+	mu         sync.Mutex
+	state      string
+	current    *Window
+	drainUntil time.Time
+	detached   bool
+	enabled    uint64 // CFG_ENABLED before maintenance
+	history    []Window
+}
+
+// NewManager creates a maintenance manager.
+func NewManager(log *zap.Logger, cfg Config, dp Dataplane) *Manager {
+	return &Manager{
+		log:   log,
+		cfg:   cfg,
+		dp:    dp,
+		now:   time.Now,
+		state: StateOff,
+	}
+}
+
+// Active reports whether maintenance is draining or active.
+func (m *Manager) Active() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state != StateOff
+}
+
+// Enter switches the program to pass-all and starts draining. The mode
+// takes effect once the drain period is over.
+func (m *Manager) Enter(req Request) (Status, error) {
+	if req.Mode == "" {
+		req.Mode = m.cfg.Mode
+	}
+	if err := validMode(req.Mode); err != nil {
+		return Status{}, err
+	}
+	if req.Reason == "" {
+		return Status{}, fmt.Errorf("reason is required")
+	}
+	if req.Timeout < 0 {
+		return Status{}, fmt.Errorf("timeout must not be negative")
+	}
+	if req.Timeout == 0 {
+		req.Timeout = time.Duration(m.cfg.DefaultTimeoutSec) * time.Second
+	}
+	if max := time.Duration(m.cfg.MaxTimeoutSec) * time.Second; req.Timeout > max {
+		return Status{}, fmt.Errorf("timeout exceeds the maximum of %s", max)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state != StateOff {
+		return Status{}, ErrActive
+	}
+	level, err := m.dp.GetConfig(bpf.CfgEscalationLevel)
+	if err != nil {
+		return Status{}, err
+	}
+	if level >= uint64(escalation.High) && !req.Force {
+		return Status{}, ErrEscalated
+	}
+	enabled, err := m.dp.GetConfig(bpf.CfgEnabled)
+	if err != nil {
+		return Status{}, err
+	}
+	if err := m.dp.SetConfig(bpf.CfgEnabled, 0); err != nil {
+		return Status{}, fmt.Errorf("switching to pass-all: %w", err)
+	}
+
+	now := m.now()
+	m.enabled = enabled
+	m.state = StateDraining
+	m.drainUntil = now.Add(time.Duration(m.cfg.DrainSec) * time.Second)
+	m.current = &Window{
+		Mode:   req.Mode,
+		Reason: req.Reason,
+		By:     req.By,
+		Forced: req.Force,
+		Level:  escalation.Level(level).String(),
+		Since:  now,
+		Until:  now.Add(req.Timeout),
+	}
+	m.log.Warn("entering maintenance, scrubbing bypassed",
+		zap.String("mode", req.Mode),
+		zap.String("by", req.By),
+		zap.String("reason", req.Reason),
+		zap.Bool("forced", req.Force),
+		zap.Time("until", m.current.Until),
+	)
+	m.tickLocked(now)
+	return m.statusLocked(), nil
+}
+
+// Exit ends maintenance: the program is reattached if it was detached and
+// scrubbing resumes.
+func (m *Manager) Exit(by string) (Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state == StateOff {
+		return Status{}, ErrInactive
+	}
+	if err := m.exitLocked(by); err != nil {
+		return Status{}, err
+	}
+	return m.statusLocked(), nil
+}
+
+// Status returns the current state and recent windows.
+func (m *Manager) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.statusLocked()
+}
+
+// Run finishes draining and reverts expired maintenance until ctx is
+// done.
+func (m *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		m.Tick()
+	}
+}
+
+// Tick moves draining maintenance to active once the drain period is over
+// and ends maintenance past its timeout.
+func (m *Manager) Tick() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tickLocked(m.now())
+}
+
+func (m *Manager) tickLocked(now time.Time) {
+	switch {
+	case m.state == StateOff:
+
+	case !now.Before(m.current.Until):
+		m.log.Warn("maintenance timed out", zap.String("reason", m.current.Reason))
+		if err := m.exitLocked("timeout"); err != nil {
+			m.log.Error("failed to end maintenance", zap.Error(err))
+		}
+
+	case m.state == StateDraining && !now.Before(m.drainUntil):
+		if m.current.Mode == ModeDetach {
+			if err := m.dp.Detach(); err != nil {
+				// Pass-all still keeps the scrubber out of the way.
+				m.log.Error("failed to detach XDP program, staying in bypass", zap.Error(err))
+				m.current.Mode = ModeBypass
+			} else {
+				m.detached = true
+			}
+		}
+		m.state = StateActive
+		m.log.Warn("maintenance active", zap.String("mode", m.current.Mode))
+	}
+}
+
+// exitLocked reattaches the program if needed, restores CFG_ENABLED and
+// moves the current window to the history.
+func (m *Manager) exitLocked(by string) error {
+	if m.detached {
+		if err := m.dp.Reattach(); err != nil {
+			m.current.EndError = err.Error()
+			return fmt.Errorf("reattaching XDP program: %w", err)
+		}
+		m.detached = false
+	}
+	if err := m.dp.SetConfig(bpf.CfgEnabled, m.enabled); err != nil {
+		return fmt.Errorf("resuming scrubbing: %w", err)
+	}
+
+	w := *m.current
+	w.Ended, w.EndedBy, w.EndError = m.now(), by, ""
+	m.history = append([]Window{w}, m.history...)
+	if len(m.history) > historySize {
+		m.history = m.history[:historySize]
+	}
+	m.state, m.current = StateOff, nil
+	m.log.Warn("maintenance ended, scrubbing resumed",
+		zap.String("by", by),
+		zap.Duration("duration", w.Ended.Sub(w.Since)),
+	)
+	return nil
+}
+
+func (m *Manager) statusLocked() Status {
+	st := Status{
+		State:   m.state,
+		History: append([]Window(nil), m.history...),
+	}
+	if m.current != nil {
+		w := *m.current
+		st.Current = &w
+	}
+	return st
+}
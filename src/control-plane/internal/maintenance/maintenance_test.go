@@ -0,0 +1,143 @@
+package maintenance
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+type fakeDataplane struct {
+	config    map[uint32]uint64
+	attached  bool
+	detachErr error
+}
+
+func (f *fakeDataplane) GetConfig(key uint32) (uint64, error) { return f.config[key], nil }
+
+func (f *fakeDataplane) SetConfig(key uint32, value uint64) error {
+	f.config[key] = value
+	return nil
+}
+
+func (f *fakeDataplane) Detach() error {
+	if f.detachErr != nil {
+		return f.detachErr
+	}
+	f.attached = false
+	return nil
+}
+
+func (f *fakeDataplane) Reattach() error {
+	f.attached = true
+	return nil
+}
+
+func newTestManager(now *time.Time) (*Manager, *fakeDataplane) {
+	dp := &fakeDataplane{config: map[uint32]uint64{bpf.CfgEnabled: 1}, attached: true}
+	m := NewManager(zap.NewNop(), DefaultConfig(), dp)
+	m.now = func() time.Time { return *now }
+	return m, dp
+}
+
+func TestDetachAfterDrain(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	m, dp := newTestManager(&now)
+
+	st, err := m.Enter(Request{Mode: ModeDetach, Reason: "NIC firmware upgrade", By: "alice"})
+	if err != nil {
+		t.Fatalf("Enter: %v", err)
+	}
+	if st.State != StateDraining || dp.config[bpf.CfgEnabled] != 0 || !dp.attached {
+		t.Fatalf("after Enter: state %s, enabled %d, attached %t", st.State, dp.config[bpf.CfgEnabled], dp.attached)
+	}
+	if _, err := m.Enter(Request{Reason: "again"}); !errors.Is(err, ErrActive) {
+		t.Errorf("second Enter = %v, want ErrActive", err)
+	}
+
+	now = now.Add(time.Duration(DefaultConfig().DrainSec) * time.Second)
+	m.Tick()
+	if st := m.Status(); st.State != StateActive || dp.attached {
+		t.Fatalf("after drain: state %s, attached %t", st.State, dp.attached)
+	}
+
+	st, err = m.Exit("bob")
+	if err != nil {
+		t.Fatalf("Exit: %v", err)
+	}
+	if st.State != StateOff || dp.config[bpf.CfgEnabled] != 1 || !dp.attached {
+		t.Errorf("after Exit: state %s, enabled %d, attached %t", st.State, dp.config[bpf.CfgEnabled], dp.attached)
+	}
+	if len(st.History) != 1 || st.History[0].By != "alice" || st.History[0].EndedBy != "bob" || st.History[0].Reason != "NIC firmware upgrade" {
+		t.Errorf("history = %+v", st.History)
+	}
+	if _, err := m.Exit("bob"); !errors.Is(err, ErrInactive) {
+		t.Errorf("second Exit = %v, want ErrInactive", err)
+	}
+}
+
+func TestTimeoutReverts(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	m, dp := newTestManager(&now)
+	dp.detachErr = errors.New("busy")
+
+	if _, err := m.Enter(Request{Mode: ModeDetach, Reason: "test", Timeout: time.Minute}); err != nil {
+		t.Fatalf("Enter: %v", err)
+	}
+	now = now.Add(10 * time.Second)
+	m.Tick()
+	// A failed detach falls back to bypass.
+	if st := m.Status(); st.State != StateActive || st.Current.Mode != ModeBypass || !dp.attached {
+		t.Fatalf("after failed detach: %+v", st.Current)
+	}
+
+	now = now.Add(time.Minute)
+	m.Tick()
+	st := m.Status()
+	if st.State != StateOff || dp.config[bpf.CfgEnabled] != 1 {
+		t.Fatalf("after timeout: state %s, enabled %d", st.State, dp.config[bpf.CfgEnabled])
+	}
+	if st.History[0].EndedBy != "timeout" {
+		t.Errorf("ended by %q, want timeout", st.History[0].EndedBy)
+	}
+}
+
+func TestRefusedWhileEscalated(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	m, dp := newTestManager(&now)
+	dp.config[bpf.CfgEscalationLevel] = 2 // HIGH
+
+	if _, err := m.Enter(Request{Reason: "test"}); !errors.Is(err, ErrEscalated) {
+		t.Fatalf("Enter at HIGH = %v, want ErrEscalated", err)
+	}
+	if dp.config[bpf.CfgEnabled] != 1 {
+		t.Error("refused Enter changed the enabled flag")
+	}
+	st, err := m.Enter(Request{Reason: "test", Force: true})
+	if err != nil {
+		t.Fatalf("forced Enter: %v", err)
+	}
+	if !st.Current.Forced || st.Current.Level != "HIGH" {
+		t.Errorf("current = %+v", st.Current)
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	m, _ := newTestManager(&now)
+	for _, req := range []Request{
+		{},
+		{Reason: "x", Mode: "off"},
+		{Reason: "x", Timeout: -time.Second},
+		{Reason: "x", Timeout: 24 * time.Hour},
+	} {
+		if _, err := m.Enter(req); err == nil {
+			t.Errorf("Enter(%+v) should fail", req)
+		}
+	}
+	if m.Active() {
+		t.Error("rejected requests left maintenance active")
+	}
+}
@@ -0,0 +1,204 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+)
+
+// Escalation levels as reported by escalation.Level.String().
+const levelLow = "LOW"
+
+// escalationDedupKey groups all escalation notifications of one scrubber
+// into a single PagerDuty incident.
+func escalationDedupKey(source string) string {
+	return "ddos-scrubber/" + source + "/escalation"
+}
+
+// EscalationChanged builds the notification for an escalation level
+// transition. Returning to LOW resolves the PagerDuty incident.
+func (n *Notifier) EscalationChanged(from, to, reason string, toLevel int) {
+	severity := SeverityInfo
+	switch {
+	case toLevel >= 3:
+		severity = SeverityCritical
+	case toLevel == 2:
+		severity = SeverityError
+	case toLevel == 1:
+		severity = SeverityWarning
+	}
+
+	summary := fmt.Sprintf("DDoS escalation level %s -> %s", from, to)
+	if reason != "" {
+		summary += " (" + reason + ")"
+	}
+
+	n.Notify(Event{
+		Kind:     KindEscalation,
+		Severity: severity,
+		Summary:  summary,
+		Details: map[string]interface{}{
+			"from":   from,
+			"to":     to,
+			"reason": reason,
+		},
+		DedupKey: escalationDedupKey(n.cfg.Source),
+		Resolve:  to == levelLow,
+	})
+}
+
+// ReputationBlocked notifies about an IP auto-blocked by reputation scoring
+// if its score reaches the configured minimum.
+func (n *Notifier) ReputationBlocked(ip string, score, threshold uint32) {
+	if score < n.cfg.MinBlockScore {
+		return
+	}
+	n.Notify(Event{
+		Kind:     KindReputationBlock,
+		Severity: SeverityWarning,
+		Summary:  fmt.Sprintf("%s auto-blocked by reputation (score %d, threshold %d)", ip, score, threshold),
+		Details: map[string]interface{}{
+			"ip":        ip,
+			"score":     score,
+			"threshold": threshold,
+		},
+	})
+}
+
+// BlackholeAnnounced notifies about an RTBH announcement. Traffic to the
+// prefix is discarded upstream, so this is always critical.
+func (n *Notifier) BlackholeAnnounced(prefix, community string) {
+	n.Notify(Event{
+		Kind:     KindBlackhole,
+		Severity: SeverityCritical,
+		Summary:  fmt.Sprintf("RTBH blackhole announced for %s", prefix),
+		Details: map[string]interface{}{
+			"prefix":    prefix,
+			"community": community,
+		},
+		DedupKey: "ddos-scrubber/" + n.cfg.Source + "/blackhole/" + prefix,
+	})
+}
+
+// BlackholeWithdrawn resolves the incident opened by BlackholeAnnounced.
+func (n *Notifier) BlackholeWithdrawn(prefix string) {
+	n.Notify(Event{
+		Kind:     KindBlackhole,
+		Severity: SeverityInfo,
+		Summary:  fmt.Sprintf("RTBH blackhole withdrawn for %s", prefix),
+		Details:  map[string]interface{}{"prefix": prefix},
+		DedupKey: "ddos-scrubber/" + n.cfg.Source + "/blackhole/" + prefix,
+		Resolve:  true,
+	})
+}
+
+// TunnelChanged notifies about a GRE tunnel moving to another endpoint.
+// Losing every endpoint is critical, as clean traffic for the prefix can
+// no longer be returned; returning to the primary endpoint resolves it.
+func (n *Notifier) TunnelChanged(name, prefix, from, to, reason string, primary bool) {
+	severity := SeverityWarning
+	summary := fmt.Sprintf("GRE tunnel %s (%s) moved from %s to %s", name, prefix, from, to)
+	switch {
+	case to == "":
+		severity = SeverityCritical
+		summary = fmt.Sprintf("GRE tunnel %s (%s) down: %s", name, prefix, reason)
+	case primary:
+		severity = SeverityInfo
+	}
+	n.Notify(Event{
+		Kind:     KindTunnel,
+		Severity: severity,
+		Summary:  summary,
+		Details: map[string]interface{}{
+			"tunnel": name,
+			"prefix": prefix,
+			"from":   from,
+			"to":     to,
+			"reason": reason,
+		},
+		DedupKey: "ddos-scrubber/" + n.cfg.Source + "/tunnel/" + name,
+		Resolve:  primary,
+	})
+}
+
+// AttackEnded sends the summary of a finished attack session.
+func (n *Notifier) AttackEnded(id string, durationSec, peakDropPPS float64, droppedPackets uint64, vectors []string) {
+	n.Notify(Event{
+		Kind:     KindAttack,
+		Severity: SeverityInfo,
+		Summary: fmt.Sprintf("Attack %s ended after %.0fs (peak %.0f dropped pps, %d packets dropped)",
+			id, durationSec, peakDropPPS, droppedPackets),
+		Details: map[string]interface{}{
+			"id":             id,
+			"durationSec":    durationSec,
+			"peakDropPps":    peakDropPPS,
+			"droppedPackets": droppedPackets,
+			"vectors":        vectors,
+		},
+	})
+}
+
+// WatchdogFinding notifies about the XDP program found detached or
+// replaced, or config map values changed outside the control plane. An
+// unrepaired finding is critical: traffic is no longer scrubbed as
+// configured.
+func (n *Notifier) WatchdogFinding(kind, detail string, repaired bool, repairErr string) {
+	severity, state := SeverityCritical, "not repaired"
+	if repaired {
+		severity, state = SeverityWarning, "repaired"
+	}
+	details := map[string]interface{}{
+		"kind":     kind,
+		"detail":   detail,
+		"repaired": repaired,
+	}
+	if repairErr != "" {
+		details["error"] = repairErr
+	}
+	n.Notify(Event{
+		Kind:     KindWatchdog,
+		Severity: severity,
+		Summary:  fmt.Sprintf("Watchdog: %s (%s, %s)", detail, kind, state),
+		Details:  details,
+	})
+}
+
+// ApprovalRequested asks the NOC to approve or reject a staged mitigation
+// before deadline, when it runs or is dropped as onTimeout says. The
+// incident stays open until ApprovalResolved.
+func (n *Notifier) ApprovalRequested(id uint32, action, summary string, deadline time.Time, onTimeout string) {
+	n.Notify(Event{
+		Kind:     KindApproval,
+		Severity: SeverityCritical,
+		Summary:  fmt.Sprintf("Approval needed (request %d): %s", id, summary),
+		Details: map[string]interface{}{
+			"id":        id,
+			"action":    action,
+			"deadline":  deadline.UTC().Format(time.RFC3339),
+			"onTimeout": onTimeout,
+		},
+		DedupKey: fmt.Sprintf("ddos-scrubber/%s/approval/%d", n.cfg.Source, id),
+	})
+}
+
+// ApprovalResolved resolves the incident opened by ApprovalRequested.
+func (n *Notifier) ApprovalResolved(id uint32, action, status, by, reason string) {
+	details := map[string]interface{}{
+		"id":     id,
+		"action": action,
+		"status": status,
+	}
+	if by != "" {
+		details["by"] = by
+	}
+	if reason != "" {
+		details["reason"] = reason
+	}
+	n.Notify(Event{
+		Kind:     KindApproval,
+		Severity: SeverityInfo,
+		Summary:  fmt.Sprintf("Approval request %d for %s %s", id, action, status),
+		Details:  details,
+		DedupKey: fmt.Sprintf("ddos-scrubber/%s/approval/%d", n.cfg.Source, id),
+		Resolve:  true,
+	})
+}
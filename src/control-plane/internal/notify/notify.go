@@ -0,0 +1,431 @@
+// Package notify delivers operator notifications (generic JSON webhooks,
+// Slack incoming webhooks, PagerDuty Events v2) for escalation changes,
+// reputation auto-blocks, BGP blackhole announcements, GRE tunnel
+// failovers, and data plane tampering found by the watchdog.
+//
+// Notifications are queued and delivered asynchronously so that callers on
+// the mitigation path never block on a slow endpoint. Each target is rate
+// limited independently, and failed deliveries are retried with
+// exponential backoff.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Event kinds.
+const (
+	KindEscalation      = "escalation"
+	KindReputationBlock = "reputation_block"
+	KindBlackhole       = "blackhole"
+	KindTunnel          = "tunnel"
+	KindAttack          = "attack"
+	KindWatchdog        = "watchdog"
+	KindApproval        = "approval"
+)
+
+// Target types.
+const (
+	TypeWebhook   = "webhook"
+	TypeSlack     = "slack"
+	TypePagerDuty = "pagerduty"
+)
+
+// Severities, using the PagerDuty Events v2 vocabulary.
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityError    = "error"
+	SeverityCritical = "critical"
+)
+
+const (
+	defaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+	defaultTimeout      = 10 * time.Second
+	defaultRatePerMin   = 30
+	retryBaseDelay      = time.Second
+	queueSize           = 256
+)
+
+// Config controls notification delivery.
+type Config struct {
+	Enabled       bool     `yaml:"enabled"`
+	Source        string   `yaml:"source"`             // Reported as the event source (default: hostname)
+	TimeoutSec    uint64   `yaml:"timeout_sec"`        // Per-request timeout
+	MaxRetries    int      `yaml:"max_retries"`        // Retries after the first attempt
+	RatePerMin    int      `yaml:"rate_limit_per_min"` // Per-target notification budget
+	MinBlockScore uint32   `yaml:"min_block_score"`    // Only notify auto-blocks at or above this score
+	Targets       []Target `yaml:"targets"`
+}
+
+// Target is a single notification endpoint.
+type Target struct {
+	Name       string            `yaml:"name"`
+	Type       string            `yaml:"type"`        // webhook, slack, pagerduty
+	URL        string            `yaml:"url"`         // PagerDuty defaults to the Events v2 endpoint
+	RoutingKey string            `yaml:"routing_key"` // PagerDuty integration key
+	Headers    map[string]string `yaml:"headers"`     // Extra HTTP headers (webhook)
+	Events     []string          `yaml:"events"`      // Event kinds to send; empty = all
+}
+
+// Validate checks the notification configuration.
+func (c Config) Validate() error {
+	for i, t := range c.Targets {
+		switch t.Type {
+		case TypeWebhook, TypeSlack:
+			if t.URL == "" {
+				return fmt.Errorf("target %d (%s): url is required", i, t.Name)
+			}
+		case TypePagerDuty:
+			if t.RoutingKey == "" {
+				return fmt.Errorf("target %d (%s): routing_key is required", i, t.Name)
+			}
+		default:
+			return fmt.Errorf("target %d (%s): invalid type %q (must be webhook, slack, or pagerduty)", i, t.Name, t.Type)
+		}
+		for _, k := range t.Events {
+			switch k {
+			case KindEscalation, KindReputationBlock, KindBlackhole, KindTunnel, KindAttack, KindWatchdog, KindApproval:
+			default:
+				return fmt.Errorf("target %d (%s): unknown event %q", i, t.Name, k)
+			}
+		}
+	}
+	return nil
+}
+
+// Event is a notification about a mitigation state change.
+type Event struct {
+	Kind      string                 `json:"kind"`
+	Severity  string                 `json:"severity"`
+	Summary   string                 `json:"summary"`
+	Source    string                 `json:"source"`
+	Timestamp time.Time              `json:"timestamp"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+
+	// DedupKey groups related PagerDuty events; Resolve closes the
+	// incident opened under the same key instead of triggering a new one.
+	DedupKey string `json:"dedupKey,omitempty"`
+	Resolve  bool   `json:"resolve,omitempty"`
+}
+
+// Notifier queues events and delivers them to the configured targets.
+type Notifier struct {
+	log    *zap.Logger
+	cfg    Config
+	client *http.Client
+	queue  chan Event
+
+	mu       sync.Mutex
+	limiters map[string]*limiter // target name -> budget
+	dropped  uint64
+
+	done chan struct{} // closed when Run exits
+}
+
+// NewNotifier creates a notifier for the given configuration.
+func NewNotifier(log *zap.Logger, cfg Config) *Notifier {
+	timeout := time.Duration(cfg.TimeoutSec) * time.Second
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	if cfg.RatePerMin <= 0 {
+		cfg.RatePerMin = defaultRatePerMin
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.Source == "" {
+		cfg.Source, _ = os.Hostname()
+	}
+
+	n := &Notifier{
+		log:      log,
+		cfg:      cfg,
+		client:   &http.Client{Timeout: timeout},
+		queue:    make(chan Event, queueSize),
+		limiters: make(map[string]*limiter),
+		done:     make(chan struct{}),
+	}
+	for i := range n.cfg.Targets {
+		t := &n.cfg.Targets[i]
+		if t.Name == "" {
+			t.Name = fmt.Sprintf("%s-%d", t.Type, i)
+		}
+		if t.Type == TypePagerDuty && t.URL == "" {
+			t.URL = defaultPagerDutyURL
+		}
+		n.limiters[t.Name] = newLimiter(cfg.RatePerMin, time.Minute)
+	}
+	return n
+}
+
+// Notify queues an event for delivery. It never blocks; if the queue is
+// full the event is dropped and counted.
+func (n *Notifier) Notify(ev Event) {
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now()
+	}
+	if ev.Source == "" {
+		ev.Source = n.cfg.Source
+	}
+
+	select {
+	case n.queue <- ev:
+	default:
+		n.mu.Lock()
+		n.dropped++
+		n.mu.Unlock()
+		n.log.Warn("notification queue full, event dropped", zap.String("kind", ev.Kind))
+	}
+}
+
+// Dropped returns the number of events dropped because the queue was full.
+func (n *Notifier) Dropped() uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.dropped
+}
+
+// Run delivers queued events until ctx is cancelled. Events still queued
+// at that point are delivered once more without retries.
+func (n *Notifier) Run(ctx context.Context) {
+	defer close(n.done)
+	n.log.Info("notifier started", zap.Int("targets", len(n.cfg.Targets)))
+
+	for {
+		select {
+		case <-ctx.Done():
+			n.flush()
+			n.log.Info("notifier stopped")
+			return
+		case ev := <-n.queue:
+			n.deliver(ctx, ev)
+		}
+	}
+}
+
+// Done returns a channel that is closed once Run has returned.
+func (n *Notifier) Done() <-chan struct{} {
+	return n.done
+}
+
+func (n *Notifier) flush() {
+	ctx, cancel := context.WithTimeout(context.Background(), n.client.Timeout)
+	defer cancel()
+	for {
+		select {
+		case ev := <-n.queue:
+			for _, t := range n.cfg.Targets {
+				if n.wants(t, ev) {
+					if err := n.send(ctx, t, ev); err != nil {
+						n.log.Warn("final notification failed", zap.String("target", t.Name), zap.Error(err))
+					}
+				}
+			}
+		default:
+			return
+		}
+	}
+}
+
+// deliver sends ev to every subscribed target that has budget left.
+func (n *Notifier) deliver(ctx context.Context, ev Event) {
+	for _, t := range n.cfg.Targets {
+		if !n.wants(t, ev) {
+			continue
+		}
+		// Resolutions bypass the budget so incidents are always closed.
+		if !ev.Resolve && !n.limiters[t.Name].allow(time.Now()) {
+			n.log.Warn("notification rate limited",
+				zap.String("target", t.Name),
+				zap.String("kind", ev.Kind),
+			)
+			continue
+		}
+		if err := n.sendWithRetry(ctx, t, ev); err != nil {
+			n.log.Error("notification failed",
+				zap.String("target", t.Name),
+				zap.String("kind", ev.Kind),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+func (n *Notifier) wants(t Target, ev Event) bool {
+	if len(t.Events) == 0 {
+		return true
+	}
+	for _, k := range t.Events {
+		if k == ev.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Notifier) sendWithRetry(ctx context.Context, t Target, ev Event) error {
+	var err error
+	delay := retryBaseDelay
+	for attempt := 0; attempt <= n.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+		if err = n.send(ctx, t, ev); err == nil {
+			return nil
+		}
+		if !retryable(err) {
+			return err
+		}
+		n.log.Debug("notification attempt failed",
+			zap.String("target", t.Name),
+			zap.Int("attempt", attempt+1),
+			zap.Error(err),
+		)
+	}
+	return err
+}
+
+// statusError is returned for non-2xx responses.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.code, e.body)
+}
+
+// retryable reports whether a delivery error may succeed on retry:
+// transport errors, throttling, and server errors.
+func retryable(err error) bool {
+	se, ok := err.(*statusError)
+	if !ok {
+		return true
+	}
+	return se.code == http.StatusTooManyRequests || se.code >= 500
+}
+
+func (n *Notifier) send(ctx context.Context, t Target, ev Event) error {
+	body, err := payload(t, ev)
+	if err != nil {
+		return fmt.Errorf("encoding payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("building request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{code: resp.StatusCode, body: string(bytes.TrimSpace(b))}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// payload renders ev in the target's wire format.
+func payload(t Target, ev Event) ([]byte, error) {
+	switch t.Type {
+	case TypeSlack:
+		return json.Marshal(map[string]string{
+			"text": fmt.Sprintf("%s [%s] %s", slackEmoji(ev), ev.Source, ev.Summary),
+		})
+
+	case TypePagerDuty:
+		action := "trigger"
+		if ev.Resolve {
+			action = "resolve"
+		}
+		msg := map[string]interface{}{
+			"routing_key":  t.RoutingKey,
+			"event_action": action,
+		}
+		if ev.DedupKey != "" {
+			msg["dedup_key"] = ev.DedupKey
+		}
+		if !ev.Resolve {
+			msg["payload"] = map[string]interface{}{
+				"summary":        ev.Summary,
+				"source":         ev.Source,
+				"severity":       ev.Severity,
+				"timestamp":      ev.Timestamp.UTC().Format(time.RFC3339),
+				"component":      "ddos-scrubber",
+				"class":          ev.Kind,
+				"custom_details": ev.Details,
+			}
+		}
+		return json.Marshal(msg)
+
+	default:
+		return json.Marshal(ev)
+	}
+}
+
+func slackEmoji(ev Event) string {
+	if ev.Resolve {
+		return ":white_check_mark:"
+	}
+	switch ev.Severity {
+	case SeverityCritical, SeverityError:
+		return ":rotating_light:"
+	case SeverityWarning:
+		return ":warning:"
+	default:
+		return ":information_source:"
+	}
+}
+
+// limiter allows up to n events per window (fixed window).
+type limiter struct {
+	mu     sync.Mutex
+	n      int
+	window time.Duration
+	start  time.Time
+	count  int
+}
+
+func newLimiter(n int, window time.Duration) *limiter {
+	return &limiter{n: n, window: window}
+}
+
+func (l *limiter) allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.start) >= l.window {
+		l.start = now
+		l.count = 0
+	}
+	if l.count >= l.n {
+		return false
+	}
+	l.count++
+	return true
+}
@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// recorder is a test endpoint that fails the first `fail` requests.
+type recorder struct {
+	mu     sync.Mutex
+	fail   int
+	bodies []map[string]interface{}
+}
+
+func (rc *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.fail > 0 {
+		rc.fail--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	rc.bodies = append(rc.bodies, body)
+}
+
+func (rc *recorder) received() []map[string]interface{} {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]map[string]interface{}(nil), rc.bodies...)
+}
+
+func TestPayloadFormats(t *testing.T) {
+	ev := Event{
+		Kind:      KindEscalation,
+		Severity:  SeverityCritical,
+		Summary:   "DDoS escalation level HIGH -> CRITICAL",
+		Source:    "scrubber-1",
+		Timestamp: time.Unix(1700000000, 0),
+		DedupKey:  "k",
+	}
+
+	var pd map[string]interface{}
+	b, _ := payload(Target{Type: TypePagerDuty, RoutingKey: "rk"}, ev)
+	json.Unmarshal(b, &pd)
+	if pd["routing_key"] != "rk" || pd["event_action"] != "trigger" || pd["dedup_key"] != "k" {
+		t.Errorf("unexpected PagerDuty envelope: %v", pd)
+	}
+	inner, _ := pd["payload"].(map[string]interface{})
+	if inner["severity"] != SeverityCritical || inner["source"] != "scrubber-1" {
+		t.Errorf("unexpected PagerDuty payload: %v", inner)
+	}
+
+	ev.Resolve = true
+	b, _ = payload(Target{Type: TypePagerDuty, RoutingKey: "rk"}, ev)
+	pd = nil
+	json.Unmarshal(b, &pd)
+	if pd["event_action"] != "resolve" || pd["payload"] != nil {
+		t.Errorf("resolve should carry no payload: %v", pd)
+	}
+
+	var slack map[string]string
+	b, _ = payload(Target{Type: TypeSlack}, ev)
+	json.Unmarshal(b, &slack)
+	if slack["text"] == "" {
+		t.Error("slack payload should have text")
+	}
+}
+
+func TestDeliveryRetriesAndFilters(t *testing.T) {
+	rec := &recorder{fail: 1}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	n := NewNotifier(zap.NewNop(), Config{
+		Source:     "test",
+		MaxRetries: 2,
+		Targets: []Target{
+			{Name: "hook", Type: TypeWebhook, URL: srv.URL, Events: []string{KindBlackhole}},
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go n.Run(ctx)
+
+	n.ReputationBlocked("192.0.2.1", 900, 800) // filtered out by Events
+	n.BlackholeAnnounced("198.51.100.0/24", "65535:666")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(rec.received()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	<-n.Done()
+
+	got := rec.received()
+	if len(got) != 1 {
+		t.Fatalf("received %d notifications, want 1", len(got))
+	}
+	if got[0]["kind"] != KindBlackhole || got[0]["source"] != "test" {
+		t.Errorf("unexpected notification: %v", got[0])
+	}
+}
+
+func TestNonRetryableStatus(t *testing.T) {
+	if retryable(&statusError{code: http.StatusBadRequest}) {
+		t.Error("400 should not be retried")
+	}
+	if !retryable(&statusError{code: http.StatusTooManyRequests}) {
+		t.Error("429 should be retried")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(2, time.Minute)
+	now := time.Now()
+	if !l.allow(now) || !l.allow(now) {
+		t.Fatal("first two events should be allowed")
+	}
+	if l.allow(now.Add(time.Second)) {
+		t.Error("third event in the window should be rate limited")
+	}
+	if !l.allow(now.Add(time.Minute)) {
+		t.Error("budget should reset after the window")
+	}
+}
+
+func TestMinBlockScore(t *testing.T) {
+	n := NewNotifier(zap.NewNop(), Config{MinBlockScore: 1000})
+	n.ReputationBlocked("192.0.2.1", 999, 500)
+	if len(n.queue) != 0 {
+		t.Error("auto-block below min_block_score should not be queued")
+	}
+	n.ReputationBlocked("192.0.2.1", 1000, 500)
+	if len(n.queue) != 1 {
+		t.Error("auto-block at min_block_score should be queued")
+	}
+}
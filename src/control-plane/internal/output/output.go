@@ -0,0 +1,57 @@
+// Package output renders the results of informational CLI commands either as
+// human-readable text or as JSON with a stable schema for automation.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Format selects how command results are rendered.
+type Format string
+
+// Supported output formats.
+const (
+	Text Format = "text"
+	JSON Format = "json"
+)
+
+// ParseFormat validates an --output flag value.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case "", Text:
+		return Text, nil
+	case JSON:
+		return JSON, nil
+	default:
+		return "", fmt.Errorf("invalid output format %q (must be text or json)", s)
+	}
+}
+
+// Error is the JSON schema for a failed command.
+type Error struct {
+	Error string `json:"error"`
+}
+
+// Print renders v. In JSON mode v is encoded as indented JSON; in text mode
+// the text callback writes the human-readable form.
+func Print(w io.Writer, f Format, v interface{}, text func(w io.Writer)) error {
+	if f == JSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	text(w)
+	return nil
+}
+
+// PrintError renders a command failure. In JSON mode the error is written
+// to w as an Error object so callers always receive parseable output.
+func PrintError(w, errw io.Writer, f Format, err error) {
+	if f == JSON {
+		Print(w, f, Error{Error: err.Error()}, nil)
+		return
+	}
+	fmt.Fprintf(errw, "Error: %v\n", err)
+}
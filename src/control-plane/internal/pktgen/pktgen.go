@@ -0,0 +1,247 @@
+// Package pktgen crafts IPv4 attack traffic: SYN and UDP floods and the
+// reflected responses of DNS, NTP, SSDP and memcached amplification. It
+// backs the loadgen command and the traffic integration tests.
+package pktgen
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// IP protocol numbers.
+const (
+	ProtoTCP = 6
+	ProtoUDP = 17
+)
+
+// Attack vectors a Generator can produce.
+const (
+	VectorSYN       = "syn"
+	VectorUDP       = "udp"
+	VectorDNS       = "dns"
+	VectorNTP       = "ntp"
+	VectorSSDP      = "ssdp"
+	VectorMemcached = "memcached"
+)
+
+// vectors lists the vectors with the reflector source port and default
+// payload size of each. Amplification payloads exceed the data plane's
+// amplification thresholds (512, 468, 256 and 1400 bytes).
+var vectors = []struct {
+	name    string
+	srcPort uint16 // 0 = varied per packet
+	size    int
+}{
+	{VectorSYN, 0, 0},
+	{VectorUDP, 0, 64},
+	{VectorDNS, 53, 1200},
+	{VectorNTP, 123, 1200},
+	{VectorSSDP, 1900, 1200},
+	{VectorMemcached, 11211, 1450},
+}
+
+// Vectors returns the names of the supported attack vectors.
+func Vectors() []string {
+	names := make([]string, len(vectors))
+	for i, v := range vectors {
+		names[i] = v.name
+	}
+	return names
+}
+
+// Checksum returns the Internet checksum of b, starting from the partial
+// sum sum.
+func Checksum(sum uint32, b []byte) uint16 {
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
+
+// pseudoHeaderSum sums the IPv4 pseudo header of a TCP or UDP segment.
+func pseudoHeaderSum(src, dst net.IP, proto uint8, length int) uint32 {
+	var sum uint32
+	for _, ip := range []net.IP{src.To4(), dst.To4()} {
+		sum += uint32(binary.BigEndian.Uint16(ip[0:])) + uint32(binary.BigEndian.Uint16(ip[2:]))
+	}
+	return sum + uint32(proto) + uint32(length)
+}
+
+// Ethernet wraps an IPv4 packet in an Ethernet frame.
+func Ethernet(dst, src net.HardwareAddr, ip []byte) []byte {
+	b := make([]byte, 14+len(ip))
+	copy(b[0:6], dst)
+	copy(b[6:12], src)
+	binary.BigEndian.PutUint16(b[12:], 0x0800)
+	copy(b[14:], ip)
+	return b
+}
+
+// IPv4 builds an IPv4 packet carrying an L4 segment.
+func IPv4(src, dst net.IP, proto uint8, l4 []byte) []byte {
+	b := make([]byte, 20+len(l4))
+	b[0] = 0x45
+	binary.BigEndian.PutUint16(b[2:], uint16(len(b)))
+	b[8] = 64
+	b[9] = proto
+	copy(b[12:16], src.To4())
+	copy(b[16:20], dst.To4())
+	binary.BigEndian.PutUint16(b[10:], Checksum(0, b[:20]))
+	copy(b[20:], l4)
+	return b
+}
+
+// TCPSYN builds an IPv4 TCP SYN.
+func TCPSYN(src, dst net.IP, srcPort, dstPort uint16, seq uint32) []byte {
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp[0:], srcPort)
+	binary.BigEndian.PutUint16(tcp[2:], dstPort)
+	binary.BigEndian.PutUint32(tcp[4:], seq)
+	tcp[12] = 5 << 4
+	tcp[13] = 0x02 // SYN
+	binary.BigEndian.PutUint16(tcp[14:], 65535)
+	binary.BigEndian.PutUint16(tcp[16:], Checksum(pseudoHeaderSum(src, dst, ProtoTCP, len(tcp)), tcp))
+	return IPv4(src, dst, ProtoTCP, tcp)
+}
+
+// UDP builds an IPv4 UDP datagram.
+func UDP(src, dst net.IP, srcPort, dstPort uint16, payload []byte) []byte {
+	seg := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint16(seg[0:], srcPort)
+	binary.BigEndian.PutUint16(seg[2:], dstPort)
+	binary.BigEndian.PutUint16(seg[4:], uint16(len(seg)))
+	copy(seg[8:], payload)
+	binary.BigEndian.PutUint16(seg[6:], Checksum(pseudoHeaderSum(src, dst, ProtoUDP, len(seg)), seg))
+	return IPv4(src, dst, ProtoUDP, seg)
+}
+
+// DNSResponse builds a DNS response with the given answer count, padded
+// to size bytes.
+func DNSResponse(id, answers uint16, size int) []byte {
+	b := make([]byte, max(size, 12))
+	binary.BigEndian.PutUint16(b[0:], id)
+	binary.BigEndian.PutUint16(b[2:], 0x8180) // Response, recursion available
+	binary.BigEndian.PutUint16(b[4:], 1)
+	binary.BigEndian.PutUint16(b[6:], answers)
+	return b
+}
+
+// NTPMonlistResponse builds a mode 7 MON_GETLIST_1 response padded to
+// size bytes.
+func NTPMonlistResponse(size int) []byte {
+	b := make([]byte, max(size, 8))
+	b[0] = 0x97 // Response, version 2, mode 7
+	b[2] = 3    // Implementation XNTPD
+	b[3] = 42   // MON_GETLIST_1
+	return b
+}
+
+// SSDPResponse builds an SSDP search response padded to size bytes.
+func SSDPResponse(size int) []byte {
+	return padded("HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=120\r\nST: upnp:rootdevice\r\n"+
+		"USN: uuid:00000000-0000-0000-0000-000000000000::upnp:rootdevice\r\nX-Pad: ", size)
+}
+
+// MemcachedResponse builds a memcached get response padded to size bytes.
+func MemcachedResponse(size int) []byte {
+	return padded(fmt.Sprintf("VALUE a 0 %d\r\n", size), size)
+}
+
+// padded returns s followed by filler up to size bytes.
+func padded(s string, size int) []byte {
+	if size < len(s) {
+		return []byte(s)
+	}
+	return []byte(s + strings.Repeat("x", size-len(s)))
+}
+
+// Generator builds the packets of one flood. Sources cycle through the
+// addresses of a prefix; ports left at 0 vary per packet.
+type Generator struct {
+	vector  string
+	srcPort uint16
+	dst     net.IP
+	dstPort uint16
+	size    int
+	base    uint32 // First source address
+	hosts   uint32 // Source addresses in the prefix
+}
+
+// NewGenerator creates a generator of vector packets from sources to
+// dst. size is the payload size; 0 picks the vector's default, which is
+// large enough to trip the amplification checks.
+func NewGenerator(vector string, sources *net.IPNet, dst net.IP, dstPort uint16, size int) (*Generator, error) {
+	g := &Generator{vector: vector, dstPort: dstPort, size: size}
+	found := false
+	for _, v := range vectors {
+		if v.name == vector {
+			found = true
+			g.srcPort = v.srcPort
+			if g.size == 0 {
+				g.size = v.size
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown vector %q (must be one of %s)", vector, strings.Join(Vectors(), ", "))
+	}
+	if g.dst = dst.To4(); g.dst == nil {
+		return nil, fmt.Errorf("destination %s is not IPv4", dst)
+	}
+	if sources.IP.To4() == nil {
+		return nil, fmt.Errorf("source prefix %s is not IPv4", sources)
+	}
+	if g.size < 0 || g.size > 1472 {
+		return nil, fmt.Errorf("payload size must be between 0 and 1472")
+	}
+	ones, bits := sources.Mask.Size()
+	g.base = binary.BigEndian.Uint32(sources.IP.To4())
+	g.hosts = uint32(1) << (bits - ones)
+	if g.hosts == 0 { // 0.0.0.0/0
+		g.hosts = 1<<32 - 1
+	}
+	return g, nil
+}
+
+// Sources returns the number of distinct source addresses.
+func (g *Generator) Sources() uint32 {
+	return g.hosts
+}
+
+// Packet returns the i-th IPv4 packet of the flood.
+func (g *Generator) Packet(i int) []byte {
+	src := make(net.IP, 4)
+	binary.BigEndian.PutUint32(src, g.base+uint32(i)%g.hosts)
+	ephemeral := uint16(1024 + i%64000)
+	dstPort := g.dstPort
+	if dstPort == 0 {
+		dstPort = ephemeral
+	}
+	srcPort := g.srcPort
+	if srcPort == 0 {
+		srcPort = ephemeral
+	}
+
+	switch g.vector {
+	case VectorSYN:
+		return TCPSYN(src, g.dst, srcPort, dstPort, uint32(i))
+	case VectorDNS:
+		return UDP(src, g.dst, srcPort, dstPort, DNSResponse(uint16(i), 20, g.size))
+	case VectorNTP:
+		return UDP(src, g.dst, srcPort, dstPort, NTPMonlistResponse(g.size))
+	case VectorSSDP:
+		return UDP(src, g.dst, srcPort, dstPort, SSDPResponse(g.size))
+	case VectorMemcached:
+		return UDP(src, g.dst, srcPort, dstPort, MemcachedResponse(g.size))
+	default:
+		return UDP(src, g.dst, srcPort, dstPort, make([]byte, g.size))
+	}
+}
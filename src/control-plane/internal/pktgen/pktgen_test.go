@@ -0,0 +1,57 @@
+package pktgen
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func TestChecksums(t *testing.T) {
+	src, dst := net.ParseIP("198.51.100.1"), net.ParseIP("203.0.113.9")
+	for name, pkt := range map[string][]byte{
+		"syn": TCPSYN(src, dst, 40000, 80, 1),
+		"udp": UDP(src, dst, 53, 40000, DNSResponse(1, 20, 601)),
+	} {
+		// A header that includes its checksum sums to zero.
+		if c := Checksum(0, pkt[:20]); c != 0 {
+			t.Errorf("%s: IPv4 header checksum does not verify (%#x)", name, c)
+		}
+		l4 := pkt[20:]
+		if c := Checksum(pseudoHeaderSum(src, dst, pkt[9], len(l4)), l4); c != 0 {
+			t.Errorf("%s: L4 checksum does not verify (%#x)", name, c)
+		}
+		if got := int(binary.BigEndian.Uint16(pkt[2:])); got != len(pkt) {
+			t.Errorf("%s: total length %d, want %d", name, got, len(pkt))
+		}
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	_, sources, _ := net.ParseCIDR("198.51.100.0/30")
+	dst := net.ParseIP("203.0.113.9")
+
+	g, err := NewGenerator(VectorDNS, sources, dst, 0, 0)
+	if err != nil {
+		t.Fatalf("NewGenerator: %v", err)
+	}
+	if g.Sources() != 4 {
+		t.Errorf("Sources() = %d, want 4", g.Sources())
+	}
+	pkt := g.Packet(5)
+	if src := net.IP(pkt[12:16]); !src.Equal(net.ParseIP("198.51.100.1")) {
+		t.Errorf("packet 5 source = %s, want 198.51.100.1", src)
+	}
+	if sport := binary.BigEndian.Uint16(pkt[20:]); sport != 53 {
+		t.Errorf("source port = %d, want 53", sport)
+	}
+	if payload := len(pkt) - 28; payload != 1200 {
+		t.Errorf("payload = %d bytes, want the 1200 byte default", payload)
+	}
+
+	if _, err := NewGenerator("smurf", sources, dst, 0, 0); err == nil {
+		t.Error("unknown vector accepted")
+	}
+	if _, err := NewGenerator(VectorUDP, sources, dst, 0, 2000); err == nil {
+		t.Error("payload beyond the MTU accepted")
+	}
+}
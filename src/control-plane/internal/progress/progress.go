@@ -0,0 +1,94 @@
+// Package progress tracks how far a long-running load has got, how fast it
+// is going and when it should finish, for GeoIP data and threat feeds.
+package progress
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Tracker counts the work done towards a total, such as bytes of a file or
+// map writes. It is safe for concurrent use.
+type Tracker struct {
+	started time.Time
+	done    atomic.Int64
+	total   atomic.Int64
+}
+
+// Snapshot is the state of a Tracker at one point in time. With a total of
+// 0 (unknown), Percent and ETA are 0.
+type Snapshot struct {
+	Done    int64
+	Total   int64
+	Elapsed time.Duration
+	Percent float64       // 0-100
+	Rate    float64       // Work done per second
+	ETA     time.Duration // Time left at the current rate
+}
+
+// New returns a tracker started now, with total units of work to do.
+func New(total int64) *Tracker {
+	t := &Tracker{started: time.Now()}
+	t.total.Store(total)
+	return t
+}
+
+// Add records n more units of work done.
+func (t *Tracker) Add(n int64) {
+	t.done.Add(n)
+}
+
+// Set records the units of work done so far.
+func (t *Tracker) Set(n int64) {
+	t.done.Store(n)
+}
+
+// SetTotal sets the units of work to do, once known.
+func (t *Tracker) SetTotal(n int64) {
+	t.total.Store(n)
+}
+
+// Reader returns a reader that records every byte read from r as done.
+func (t *Tracker) Reader(r io.Reader) io.Reader {
+	return &countingReader{r: r, t: t}
+}
+
+// Snapshot returns the tracker's state now.
+func (t *Tracker) Snapshot() Snapshot {
+	return t.snapshot(time.Now())
+}
+
+func (t *Tracker) snapshot(now time.Time) Snapshot {
+	s := Snapshot{
+		Done:    t.done.Load(),
+		Total:   t.total.Load(),
+		Elapsed: now.Sub(t.started),
+	}
+	if secs := s.Elapsed.Seconds(); secs > 0 {
+		s.Rate = float64(s.Done) / secs
+	}
+	if s.Total <= 0 {
+		return s
+	}
+	done := s.Done
+	if done > s.Total {
+		done = s.Total
+	}
+	s.Percent = float64(done) * 100 / float64(s.Total)
+	if s.Rate > 0 {
+		s.ETA = time.Duration(float64(s.Total-done) / s.Rate * float64(time.Second))
+	}
+	return s
+}
+
+type countingReader struct {
+	r io.Reader
+	t *Tracker
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.t.Add(int64(n))
+	return n, err
+}
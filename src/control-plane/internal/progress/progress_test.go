@@ -0,0 +1,37 @@
+package progress
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	tr := New(0)
+	start := tr.started
+
+	if _, err := io.Copy(io.Discard, tr.Reader(strings.NewReader(strings.Repeat("x", 250)))); err != nil {
+		t.Fatal(err)
+	}
+	s := tr.snapshot(start.Add(5 * time.Second))
+	if s.Done != 250 || s.Rate != 50 {
+		t.Errorf("done = %d, rate = %v; want 250, 50", s.Done, s.Rate)
+	}
+	if s.Percent != 0 || s.ETA != 0 {
+		t.Errorf("percent = %v, eta = %v with no total; want 0", s.Percent, s.ETA)
+	}
+
+	tr.SetTotal(1000)
+	s = tr.snapshot(start.Add(5 * time.Second))
+	if s.Percent != 25 || s.ETA != 15*time.Second {
+		t.Errorf("percent = %v, eta = %v; want 25, 15s", s.Percent, s.ETA)
+	}
+
+	// Work beyond the estimated total is done, not over 100%.
+	tr.Set(1200)
+	s = tr.snapshot(start.Add(10 * time.Second))
+	if s.Percent != 100 || s.ETA != 0 {
+		t.Errorf("percent = %v, eta = %v past the total; want 100, 0", s.Percent, s.ETA)
+	}
+}
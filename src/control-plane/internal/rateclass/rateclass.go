@@ -0,0 +1,459 @@
+// Package rateclass keeps the named rate classes: sets of rate limits
+// (e.g. "dns-servers", "game-traffic") that replace the global
+// per-protocol rates for the source or destination prefixes bound to
+// them. The registry programs the rate class BPF maps (rate_classes,
+// rate_class_src and rate_class_dst) and is persisted in the state
+// directory, so classes changed through the API survive restarts.
+package rateclass
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+// Class is a named set of rate limits and the prefixes bound to it.
+type Class struct {
+	Name string `yaml:"name" json:"name"`
+
+	// Per-source limits of sources in the class, or of sources sending to
+	// destinations in it; 0 keeps the global limit
+	SYNRatePPS  uint64 `yaml:"syn_rate_pps" json:"synRatePps,omitempty"`
+	UDPRatePPS  uint64 `yaml:"udp_rate_pps" json:"udpRatePps,omitempty"`
+	ICMPRatePPS uint64 `yaml:"icmp_rate_pps" json:"icmpRatePps,omitempty"`
+
+	// Limits on all traffic in the class together; 0 = none
+	PPS uint64 `yaml:"pps" json:"pps,omitempty"`
+	BPS uint64 `yaml:"bps" json:"bps,omitempty"`
+
+	// Prefixes bound to the class. A packet whose source is bound to a
+	// class is limited by that class, whatever its destination.
+	Sources      []string `yaml:"sources" json:"sources,omitempty"`
+	Destinations []string `yaml:"destinations" json:"destinations,omitempty"`
+}
+
+// Upper bounds of the class limits, the same as for the global rate_limit
+// settings.
+const (
+	maxSourceRatePPS = 100_000_000
+	maxPPS           = 1_000_000_000
+	maxBPS           = 1_000_000_000_000
+)
+
+// Validate checks that the class has a name, at least one limit within
+// bounds and distinct IPv4 prefixes.
+func (c Class) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("rate class name is required")
+	}
+	if c.SYNRatePPS == 0 && c.UDPRatePPS == 0 && c.ICMPRatePPS == 0 && c.PPS == 0 && c.BPS == 0 {
+		return fmt.Errorf("rate class %s: no limits set", c.Name)
+	}
+	for _, l := range []struct {
+		name       string
+		value, max uint64
+	}{
+		{"syn_rate_pps", c.SYNRatePPS, maxSourceRatePPS},
+		{"udp_rate_pps", c.UDPRatePPS, maxSourceRatePPS},
+		{"icmp_rate_pps", c.ICMPRatePPS, maxSourceRatePPS},
+		{"pps", c.PPS, maxPPS},
+		{"bps", c.BPS, maxBPS},
+	} {
+		if l.value > l.max {
+			return fmt.Errorf("rate class %s: %s %d exceeds %d", c.Name, l.name, l.value, l.max)
+		}
+	}
+	for _, list := range [][]string{c.Sources, c.Destinations} {
+		seen := make(map[string]bool, len(list))
+		for _, p := range list {
+			_, n, err := net.ParseCIDR(p)
+			if err != nil {
+				return fmt.Errorf("rate class %s: invalid prefix: %w", c.Name, err)
+			}
+			if n.IP.To4() == nil {
+				return fmt.Errorf("rate class %s: IPv6 prefix not supported: %s", c.Name, p)
+			}
+			if seen[n.String()] {
+				return fmt.Errorf("rate class %s: prefix %s listed twice", c.Name, n)
+			}
+			seen[n.String()] = true
+		}
+	}
+	return nil
+}
+
+// ValidateAll checks every class, that names are unique and that no
+// prefix is bound to two classes in the same direction.
+func ValidateAll(classes []Class) error {
+	if len(classes) >= bpf.RateClassMax {
+		return fmt.Errorf("%d rate classes exceed the limit of %d", len(classes), bpf.RateClassMax-1)
+	}
+	names := make(map[string]bool, len(classes))
+	bound := make(map[string]string)
+	for _, c := range classes {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+		if names[c.Name] {
+			return fmt.Errorf("duplicate rate class %q", c.Name)
+		}
+		names[c.Name] = true
+		for _, b := range c.bindings() {
+			key := b.dir.String() + " " + b.prefix
+			if other, ok := bound[key]; ok {
+				return fmt.Errorf("rate class %s: %s prefix %s already bound to %q", c.Name, b.dir, b.prefix, other)
+			}
+			bound[key] = c.Name
+		}
+	}
+	return nil
+}
+
+// limits returns the data plane limits of the class.
+func (c Class) limits() bpf.RateClass {
+	return bpf.RateClass{
+		SYNRatePPS:  c.SYNRatePPS,
+		UDPRatePPS:  c.UDPRatePPS,
+		ICMPRatePPS: c.ICMPRatePPS,
+		PPS:         c.PPS,
+		BPS:         c.BPS,
+	}
+}
+
+type binding struct {
+	dir    bpf.RateClassDir
+	prefix string
+}
+
+// bindings returns the canonical prefixes bound to a validated class.
+func (c Class) bindings() []binding {
+	out := make([]binding, 0, len(c.Sources)+len(c.Destinations))
+	for _, p := range c.Sources {
+		out = append(out, binding{bpf.RateClassSource, canonical(p)})
+	}
+	for _, p := range c.Destinations {
+		out = append(out, binding{bpf.RateClassDest, canonical(p)})
+	}
+	return out
+}
+
+// Map holds the rate classes and their bindings, implemented by
+// bpf.MapManager.
+type Map interface {
+	SetRateClass(id uint32, rc bpf.RateClass) error
+	RemoveRateClass(id uint32) error
+	BindRateClass(dir bpf.RateClassDir, cidr string, id uint32) error
+	UnbindRateClass(dir bpf.RateClassDir, cidr string) error
+	RateClassCounters(id uint32) (bpf.RateClassCounters, error)
+}
+
+// entry is a registered class and the id it has in the data plane.
+type entry struct {
+	Class
+	id uint32
+}
+
+// Registry owns the rate classes and their map entries.
+type Registry struct {
+	log *zap.Logger
+	m   Map
+
+	mu      sync.RWMutex
+	classes map[string]entry // by name
+}
+
+// NewRegistry creates an empty registry writing to m.
+func NewRegistry(log *zap.Logger, m Map) *Registry {
+	return &Registry{
+		log:     log,
+		m:       m,
+		classes: make(map[string]entry),
+	}
+}
+
+// Configure adds the configured classes.
+func (r *Registry) Configure(classes []Class) error {
+	for _, c := range classes {
+		if err := r.Add(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add registers a class and programs its limits and bindings. Names must
+// be unique, and a prefix can only be bound to one class per direction.
+func (r *Registry) Add(c Class) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	c = canonicalClass(c)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.classes[c.Name]; ok {
+		return fmt.Errorf("rate class %q already exists", c.Name)
+	}
+	if err := r.checkBindingsLocked(c); err != nil {
+		return err
+	}
+	id, err := r.freeIDLocked()
+	if err != nil {
+		return err
+	}
+	e := entry{Class: c, id: id}
+	if err := r.program(e); err != nil {
+		return fmt.Errorf("adding rate class %s: %w", c.Name, err)
+	}
+	r.classes[c.Name] = e
+	r.log.Info("rate class added",
+		zap.String("name", c.Name),
+		zap.Strings("sources", c.Sources),
+		zap.Strings("destinations", c.Destinations),
+	)
+	return nil
+}
+
+// Update replaces the class with the same name.
+func (r *Registry) Update(c Class) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	c = canonicalClass(c)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, ok := r.classes[c.Name]
+	if !ok {
+		return fmt.Errorf("rate class %q not found", c.Name)
+	}
+	if err := r.checkBindingsLocked(c); err != nil {
+		return err
+	}
+	if err := r.unprogram(old); err != nil {
+		return fmt.Errorf("updating rate class %s: %w", c.Name, err)
+	}
+	e := entry{Class: c, id: old.id}
+	if err := r.program(e); err != nil {
+		// Put the previous class back rather than leave its prefixes on
+		// the global limits.
+		if rerr := r.program(old); rerr != nil {
+			r.log.Error("failed to restore rate class", zap.String("name", old.Name), zap.Error(rerr))
+		}
+		return fmt.Errorf("updating rate class %s: %w", c.Name, err)
+	}
+	r.classes[c.Name] = e
+	r.log.Info("rate class updated", zap.String("name", c.Name))
+	return nil
+}
+
+// Remove deletes a class and its map entries. Its prefixes go back to the
+// global limits.
+func (r *Registry) Remove(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.classes[name]
+	if !ok {
+		return fmt.Errorf("rate class %q not found", name)
+	}
+	if err := r.unprogram(e); err != nil {
+		return fmt.Errorf("removing rate class %s: %w", name, err)
+	}
+	delete(r.classes, name)
+	r.log.Info("rate class removed", zap.String("name", name))
+	return nil
+}
+
+// Get returns the class with the given name.
+func (r *Registry) Get(name string) (Class, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.classes[name]
+	return e.Class, ok
+}
+
+// List returns every class sorted by name.
+func (r *Registry) List() []Class {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Class, 0, len(r.classes))
+	for _, e := range r.classes {
+		out = append(out, e.Class)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Stats is a class and the traffic its pps and bps buckets counted.
+type Stats struct {
+	Class
+	Packets        uint64 `json:"packets"`
+	DroppedPackets uint64 `json:"droppedPackets"`
+}
+
+// Stats returns every class with its counters, sorted by name.
+func (r *Registry) Stats() ([]Stats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Stats, 0, len(r.classes))
+	for _, e := range r.classes {
+		c, err := r.m.RateClassCounters(e.id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Stats{Class: e.Class, Packets: c.Packets, DroppedPackets: c.DroppedPackets})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// checkBindingsLocked rejects prefixes bound to another class in the same
+// direction. Caller must hold r.mu.
+func (r *Registry) checkBindingsLocked(c Class) error {
+	for _, other := range r.classes {
+		if other.Name == c.Name {
+			continue
+		}
+		for _, ob := range other.bindings() {
+			for _, b := range c.bindings() {
+				if b == ob {
+					return fmt.Errorf("%s prefix %s already bound to rate class %q", b.dir, b.prefix, other.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// freeIDLocked returns the lowest data plane id no class uses. Caller
+// must hold r.mu.
+func (r *Registry) freeIDLocked() (uint32, error) {
+	used := make(map[uint32]bool, len(r.classes))
+	for _, e := range r.classes {
+		used[e.id] = true
+	}
+	for id := uint32(1); id < bpf.RateClassMax; id++ {
+		if !used[id] {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("rate class limit of %d reached", bpf.RateClassMax-1)
+}
+
+// program writes the class limits and then its bindings to the data
+// plane, so no prefix points at a class without limits.
+func (r *Registry) program(e entry) error {
+	if err := r.m.SetRateClass(e.id, e.limits()); err != nil {
+		return err
+	}
+	var done []binding
+	for _, b := range e.bindings() {
+		if err := r.m.BindRateClass(b.dir, b.prefix, e.id); err != nil {
+			for _, d := range done {
+				r.m.UnbindRateClass(d.dir, d.prefix)
+			}
+			r.m.RemoveRateClass(e.id)
+			return err
+		}
+		done = append(done, b)
+	}
+	return nil
+}
+
+// unprogram removes the class from the data plane. Entries that are
+// already gone are not an error.
+func (r *Registry) unprogram(e entry) error {
+	for _, b := range e.bindings() {
+		if err := r.m.UnbindRateClass(b.dir, b.prefix); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return err
+		}
+	}
+	if err := r.m.RemoveRateClass(e.id); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+		return err
+	}
+	return nil
+}
+
+func canonical(prefix string) string {
+	_, n, _ := net.ParseCIDR(prefix)
+	return n.String()
+}
+
+// canonicalClass returns c with its prefixes in canonical form.
+func canonicalClass(c Class) Class {
+	canon := func(list []string) []string {
+		if len(list) == 0 {
+			return nil
+		}
+		out := make([]string, len(list))
+		for i, p := range list {
+			out[i] = canonical(p)
+		}
+		return out
+	}
+	c.Sources = canon(c.Sources)
+	c.Destinations = canon(c.Destinations)
+	return c
+}
+
+// persistedState is the on-disk form of the registry.
+type persistedState struct {
+	SavedAt time.Time `json:"savedAt"`
+	Classes []Class   `json:"classes"`
+}
+
+// SaveState writes the registry to path atomically.
+func (r *Registry) SaveState(path string) error {
+	st := persistedState{SavedAt: time.Now(), Classes: r.List()}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling rate classes: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing rate classes: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing rate classes: %w", err)
+	}
+	r.log.Info("rate classes saved", zap.String("path", path), zap.Int("classes", len(st.Classes)))
+	return nil
+}
+
+// LoadState adds the classes saved at path. It reports false without
+// error if there are no saved classes.
+func (r *Registry) LoadState(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading rate classes: %w", err)
+	}
+	var st persistedState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return false, fmt.Errorf("parsing rate classes: %w", err)
+	}
+	if err := r.Configure(st.Classes); err != nil {
+		return true, err
+	}
+	r.log.Info("rate classes restored",
+		zap.String("path", path),
+		zap.Int("classes", len(st.Classes)),
+		zap.Time("saved_at", st.SavedAt),
+	)
+	return true, nil
+}
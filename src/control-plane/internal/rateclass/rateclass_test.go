@@ -0,0 +1,183 @@
+package rateclass
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+// fakeMap records the classes and bindings programmed.
+type fakeMap struct {
+	classes  map[uint32]bpf.RateClass
+	bindings map[string]uint32 // "source 10.0.0.0/8" -> class id
+	counters map[uint32]bpf.RateClassCounters
+}
+
+func newFakeMap() *fakeMap {
+	return &fakeMap{
+		classes:  map[uint32]bpf.RateClass{},
+		bindings: map[string]uint32{},
+		counters: map[uint32]bpf.RateClassCounters{},
+	}
+}
+
+func (f *fakeMap) SetRateClass(id uint32, rc bpf.RateClass) error {
+	f.classes[id] = rc
+	return nil
+}
+
+func (f *fakeMap) RemoveRateClass(id uint32) error {
+	delete(f.classes, id)
+	return nil
+}
+
+func (f *fakeMap) BindRateClass(dir bpf.RateClassDir, cidr string, id uint32) error {
+	f.bindings[dir.String()+" "+cidr] = id
+	return nil
+}
+
+func (f *fakeMap) UnbindRateClass(dir bpf.RateClassDir, cidr string) error {
+	delete(f.bindings, dir.String()+" "+cidr)
+	return nil
+}
+
+func (f *fakeMap) RateClassCounters(id uint32) (bpf.RateClassCounters, error) {
+	return f.counters[id], nil
+}
+
+func TestRegistryAddUpdateRemove(t *testing.T) {
+	m := newFakeMap()
+	r := NewRegistry(zap.NewNop(), m)
+
+	dns := Class{Name: "dns-servers", UDPRatePPS: 50000, Sources: []string{"192.0.2.53/32"}}
+	game := Class{Name: "game-traffic", UDPRatePPS: 2000, PPS: 500000, Destinations: []string{"203.0.113.9/24"}}
+	if err := r.Configure([]Class{dns, game}); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	gameID, ok := m.bindings["destination 203.0.113.0/24"]
+	if !ok || m.classes[gameID].PPS != 500000 || m.classes[gameID].UDPRatePPS != 2000 {
+		t.Fatalf("game-traffic not programmed: classes %v, bindings %v", m.classes, m.bindings)
+	}
+	if id := m.bindings["source 192.0.2.53/32"]; id == 0 || id == gameID {
+		t.Errorf("dns-servers bound to id %d, game-traffic has %d", id, gameID)
+	}
+
+	for _, bad := range []Class{
+		{Name: "dns-servers", UDPRatePPS: 1},
+		{Name: "other", UDPRatePPS: 1, Destinations: []string{"203.0.113.0/24"}},
+		{Name: "v6", UDPRatePPS: 1, Sources: []string{"2001:db8::/32"}},
+		{Name: "twice", UDPRatePPS: 1, Sources: []string{"198.51.100.0/24", "198.51.100.1/24"}},
+		{Name: "nolimits", Sources: []string{"198.51.100.0/24"}},
+		{Name: "huge", BPS: 1 << 62},
+		{UDPRatePPS: 1},
+	} {
+		if err := r.Add(bad); err == nil {
+			t.Errorf("Add(%+v) should fail", bad)
+		}
+	}
+	// The same prefix may be bound as a source of one class and a
+	// destination of another.
+	if err := r.Add(Class{Name: "outbound", SYNRatePPS: 100, Sources: []string{"203.0.113.0/24"}}); err != nil {
+		t.Errorf("Add with a prefix bound in the other direction: %v", err)
+	}
+
+	game.Destinations = []string{"198.51.100.0/24"}
+	game.PPS = 0
+	if err := r.Update(game); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, ok := m.bindings["destination 203.0.113.0/24"]; ok {
+		t.Error("old destination still bound after Update")
+	}
+	if id := m.bindings["destination 198.51.100.0/24"]; id != gameID || m.classes[id].PPS != 0 {
+		t.Errorf("update not applied: classes %v, bindings %v", m.classes, m.bindings)
+	}
+	if err := r.Update(Class{Name: "missing", PPS: 1}); err == nil {
+		t.Error("updating a missing class should fail")
+	}
+
+	if err := r.Remove("game-traffic"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, ok := m.classes[gameID]; ok || len(r.List()) != 2 {
+		t.Errorf("class still present after Remove: %v", m.classes)
+	}
+	if err := r.Remove("game-traffic"); err == nil {
+		t.Error("removing a missing class should fail")
+	}
+
+	// The freed id is reused.
+	if err := r.Add(Class{Name: "voip", UDPRatePPS: 300}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, ok := m.classes[gameID]; !ok {
+		t.Errorf("id %d not reused: %v", gameID, m.classes)
+	}
+}
+
+func TestRegistryStats(t *testing.T) {
+	m := newFakeMap()
+	r := NewRegistry(zap.NewNop(), m)
+	if err := r.Add(Class{Name: "game-traffic", PPS: 1000, Destinations: []string{"203.0.113.0/24"}}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	m.counters[m.bindings["destination 203.0.113.0/24"]] = bpf.RateClassCounters{Packets: 5000, DroppedPackets: 1200}
+
+	st, err := r.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if len(st) != 1 || st[0].Packets != 5000 || st[0].DroppedPackets != 1200 {
+		t.Errorf("Stats = %+v", st)
+	}
+}
+
+func TestRegistryState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rate_classes.json")
+
+	r := NewRegistry(zap.NewNop(), newFakeMap())
+	if ok, err := r.LoadState(path); ok || err != nil {
+		t.Fatalf("LoadState without a file = %t, %v", ok, err)
+	}
+	if err := r.Add(Class{Name: "dns-servers", UDPRatePPS: 50000, Sources: []string{"192.0.2.53/32"}}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := r.SaveState(path); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+
+	m := newFakeMap()
+	restored := NewRegistry(zap.NewNop(), m)
+	if ok, err := restored.LoadState(path); !ok || err != nil {
+		t.Fatalf("LoadState = %t, %v", ok, err)
+	}
+	if c, ok := restored.Get("dns-servers"); !ok || c.UDPRatePPS != 50000 {
+		t.Errorf("restored class = %+v (present %t)", c, ok)
+	}
+	if _, ok := m.bindings["source 192.0.2.53/32"]; !ok {
+		t.Errorf("restored binding not programmed: %v", m.bindings)
+	}
+}
+
+func TestValidateAll(t *testing.T) {
+	if err := ValidateAll([]Class{
+		{Name: "a", PPS: 1, Sources: []string{"203.0.113.0/24"}},
+		{Name: "b", PPS: 1, Sources: []string{"203.0.113.1/24"}},
+	}); err == nil {
+		t.Error("a source prefix bound to two classes should be rejected")
+	}
+	if err := ValidateAll([]Class{
+		{Name: "a", PPS: 1},
+		{Name: "a", BPS: 1},
+	}); err == nil {
+		t.Error("duplicate names should be rejected")
+	}
+	if err := ValidateAll([]Class{
+		{Name: "a", PPS: 1, Sources: []string{"203.0.113.0/24"}},
+		{Name: "b", PPS: 1, Destinations: []string{"203.0.113.0/24"}},
+	}); err != nil {
+		t.Errorf("source and destination bindings of one prefix: %v", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"net"
 	"sort"
 	"sync"
@@ -14,6 +15,7 @@ import (
 	"unsafe"
 
 	"github.com/cilium/ebpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
 	"go.uber.org/zap"
 )
 
@@ -22,9 +24,106 @@ const (
 	defaultPollInterval = 5 * time.Second
 	defaultDecayRate    = uint32(5)  // Score points to decay per poll interval.
 	defaultThreshold    = uint32(500) // Score at which auto-block triggers.
-	unblockRatio        = 2           // Unblock when score < threshold / unblockRatio.
+	defaultUnblockRatio = 2           // Unblock when score < threshold / unblockRatio.
+	defaultHalfLife     = 5 * time.Minute
+
+	// eventDedupWindow collapses repeated drop events for the same source and
+	// reason into a single score increment.
+	eventDedupWindow = time.Second
+
+	// maxEventScores caps the sources with a pending event score, so a
+	// flood of spoofed sources cannot grow it without bound.
+	maxEventScores = 1 << 16
+)
+
+// Default userspace score weights applied per drop event, mirroring the
+// REP_WEIGHT_* constants in types.h.
+const (
+	weightSYNNoACK     = uint32(50)
+	weightRateExceeded = uint32(30)
+	weightProtoAnomaly = uint32(40)
+	weightBadPayload   = uint32(60)
+	weightFragment     = uint32(20)
+)
+
+// Decay modes.
+const (
+	DecayLinear      = "linear"      // Subtract DecayRate points per poll
+	DecayExponential = "exponential" // Halve the score every DecayHalfLifeSec
 )
 
+// Weights are the score increments applied per drop event, by class of
+// drop reason.
+type Weights struct {
+	SYNNoACK     uint32 `yaml:"syn_no_ack" json:"synNoAck"`
+	RateExceeded uint32 `yaml:"rate_exceeded" json:"rateExceeded"`
+	ProtoAnomaly uint32 `yaml:"proto_anomaly" json:"protoAnomaly"`
+	BadPayload   uint32 `yaml:"bad_payload" json:"badPayload"`
+	Fragment     uint32 `yaml:"fragment" json:"fragment"`
+}
+
+// Config tunes scoring, decay and auto-unblocking. It can be replaced at
+// runtime with SetConfig.
+type Config struct {
+	PollIntervalSec  uint64  `yaml:"poll_interval_sec" json:"pollIntervalSec"`     // How often reputation_map is read and decayed
+	Decay            string  `yaml:"decay" json:"decay"`                           // "linear" or "exponential"
+	DecayRate        uint32  `yaml:"decay_rate" json:"decayRate"`                  // Linear: points subtracted per poll
+	DecayHalfLifeSec uint64  `yaml:"decay_half_life_sec" json:"decayHalfLifeSec"`  // Exponential: time for a score to halve
+	UnblockRatio     uint32  `yaml:"unblock_ratio" json:"unblockRatio"`            // Auto-unblock below threshold / unblock_ratio
+	Weights          Weights `yaml:"weights" json:"weights"`
+}
+
+// DefaultConfig returns the built-in tuning: linear decay of 5 points
+// every 5 seconds and unblocking at half the threshold.
+func DefaultConfig() Config {
+	return Config{
+		PollIntervalSec:  uint64(defaultPollInterval / time.Second),
+		Decay:            DecayLinear,
+		DecayRate:        defaultDecayRate,
+		DecayHalfLifeSec: uint64(defaultHalfLife / time.Second),
+		UnblockRatio:     defaultUnblockRatio,
+		Weights: Weights{
+			SYNNoACK:     weightSYNNoACK,
+			RateExceeded: weightRateExceeded,
+			ProtoAnomaly: weightProtoAnomaly,
+			BadPayload:   weightBadPayload,
+			Fragment:     weightFragment,
+		},
+	}
+}
+
+// Validate checks the reputation configuration.
+func (c Config) Validate() error {
+	if c.PollIntervalSec == 0 {
+		return fmt.Errorf("poll_interval_sec must be positive")
+	}
+	switch c.Decay {
+	case DecayLinear:
+	case DecayExponential:
+		if c.DecayHalfLifeSec == 0 {
+			return fmt.Errorf("decay_half_life_sec must be positive for exponential decay")
+		}
+	default:
+		return fmt.Errorf("invalid decay %q (must be %s or %s)", c.Decay, DecayLinear, DecayExponential)
+	}
+	if c.UnblockRatio == 0 {
+		return fmt.Errorf("unblock_ratio must be positive")
+	}
+	return nil
+}
+
+// decay returns score after one poll interval of decay.
+func (c Config) decay(score uint32) uint32 {
+	if c.Decay == DecayExponential {
+		polls := float64(c.PollIntervalSec) / float64(c.DecayHalfLifeSec)
+		return uint32(math.Floor(float64(score) * math.Exp2(-polls)))
+	}
+	if score > c.DecayRate {
+		return score - c.DecayRate
+	}
+	return 0
+}
+
 // ipReputation matches struct ip_reputation in types.h (BPF map value).
 type ipReputation struct {
 	Score          uint32
@@ -47,13 +146,16 @@ type lpmKeyV4 struct {
 
 // IPReputation is the userspace representation of an IP's reputation state.
 type IPReputation struct {
-	IP          string
-	Score       uint32
-	TotalPkts   uint32
-	DroppedPkts uint32
-	Blocked     bool
-	FirstSeen   time.Time
-	LastSeen    time.Time
+	IP          string    `json:"ip"`
+	Score       uint32    `json:"score"`
+	TotalPkts   uint32    `json:"totalPackets"`
+	DroppedPkts uint32    `json:"droppedPackets"`
+	Blocked     bool      `json:"blocked"`
+	Manual      bool      `json:"manual"`              // Blocked through BlockIP, never auto-unblocked
+	Origin      string    `json:"origin,omitempty"`    // Scrubbers that shared the block; "" if blocked here
+	ExpiresAt   time.Time `json:"expiresAt,omitempty"` // When a shared block lapses unless shared again
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
 }
 
 // Engine manages IP reputation scoring from userspace.
@@ -61,49 +163,118 @@ type IPReputation struct {
 type Engine struct {
 	log            *zap.Logger
 	reputationMap  *ebpf.Map
-	blacklistMap   *ebpf.Map
-	configMap      *ebpf.Map
+	blacklistMap   lpmTable
+	configMap      bpf.ConfigTable
 
 	mu             sync.RWMutex
 	threshold      uint32
-	decayRate      uint32
+	cfg            Config
 	reputations    map[uint32]*IPReputation // key: __be32 IP
 	blocked        map[uint32]bool          // IPs currently auto-blocked
 	manualBlocked  map[uint32]bool          // IPs manually blocked (never auto-unblocked)
+	shared         map[uint32]*sharedBlock  // IPs blocked on other scrubbers' behalf
+
+	// Event feedback: score added from drop events since the last poll.
+	// Cleared per IP once the poll picks up the kernel-side score.
+	eventScore map[uint32]uint32
+	lastEvent  map[eventKey]time.Time
+
+	done chan struct{} // closed when the background loop exits
+
+	onAutoBlock func(ip string, score, threshold uint32)
+}
+
+// lpmTable is the blacklist map as written by the engine; *ebpf.Map
+// satisfies it.
+type lpmTable interface {
+	Update(key, value interface{}, flags ebpf.MapUpdateFlags) error
+	Delete(key interface{}) error
+}
+
+// eventKey identifies a (source, drop reason) pair for event dedup.
+type eventKey struct {
+	ip     uint32
+	reason uint8
 }
 
 // NewEngine creates a new reputation engine.
-func NewEngine(log *zap.Logger, reputationMap, blacklistMap, configMap *ebpf.Map) *Engine {
+func NewEngine(log *zap.Logger, reputationMap, blacklistMap *ebpf.Map, configMap bpf.ConfigTable) *Engine {
 	return &Engine{
 		log:           log,
 		reputationMap: reputationMap,
 		blacklistMap:  blacklistMap,
 		configMap:     configMap,
 		threshold:     defaultThreshold,
-		decayRate:     defaultDecayRate,
+		cfg:           DefaultConfig(),
 		reputations:   make(map[uint32]*IPReputation),
 		blocked:       make(map[uint32]bool),
 		manualBlocked: make(map[uint32]bool),
+		shared:        make(map[uint32]*sharedBlock),
+		eventScore:    make(map[uint32]uint32),
+		lastEvent:     make(map[eventKey]time.Time),
+		done:          make(chan struct{}),
 	}
 }
 
 // Start begins the background reputation management loop.
-// It runs every 5 seconds until the context is cancelled.
+// It runs every poll interval until the context is cancelled.
 func (e *Engine) Start(ctx context.Context) error {
 	// Read threshold from config map if available.
 	e.loadThresholdFromConfig()
 
 	go e.run(ctx)
 
+	cfg := e.Config()
 	e.log.Info("reputation engine started",
-		zap.Uint32("threshold", e.threshold),
-		zap.Uint32("decay_rate", e.decayRate),
+		zap.Uint32("threshold", e.GetThreshold()),
+		zap.String("decay", cfg.Decay),
+		zap.Uint64("poll_interval_sec", cfg.PollIntervalSec),
+	)
+	return nil
+}
+
+// SetConfig replaces the scoring, decay and unblock tuning. A new poll
+// interval takes effect after the next poll.
+func (e *Engine) SetConfig(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.cfg = cfg
+	e.mu.Unlock()
+
+	e.log.Info("reputation config updated",
+		zap.String("decay", cfg.Decay),
+		zap.Uint32("decay_rate", cfg.DecayRate),
+		zap.Uint64("decay_half_life_sec", cfg.DecayHalfLifeSec),
+		zap.Uint64("poll_interval_sec", cfg.PollIntervalSec),
+		zap.Uint32("unblock_ratio", cfg.UnblockRatio),
 	)
 	return nil
 }
 
+// Config returns the current tuning.
+func (e *Engine) Config() Config {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.cfg
+}
+
+func (e *Engine) pollInterval() time.Duration {
+	return time.Duration(e.Config().PollIntervalSec) * time.Second
+}
+
+// Done returns a channel that is closed once the background loop started
+// by Start has exited, after which no further map writes are made.
+func (e *Engine) Done() <-chan struct{} {
+	return e.done
+}
+
 func (e *Engine) run(ctx context.Context) {
-	ticker := time.NewTicker(defaultPollInterval)
+	defer close(e.done)
+
+	interval := e.pollInterval()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
@@ -113,6 +284,10 @@ func (e *Engine) run(ctx context.Context) {
 			return
 		case <-ticker.C:
 			e.poll()
+			if next := e.pollInterval(); next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
 		}
 	}
 }
@@ -134,12 +309,12 @@ func (e *Engine) poll() {
 	for iter.Next(&key, &value) {
 		ipStr := u32BEToIP(key).String()
 
+		// The kernel score now includes the violations that produced any
+		// pending event feedback; drop it to avoid double counting.
+		delete(e.eventScore, key)
+
 		// Apply time-based decay.
-		if value.Score > 0 && value.Score > e.decayRate {
-			value.Score -= e.decayRate
-		} else if value.Score > 0 {
-			value.Score = 0
-		}
+		value.Score = e.cfg.decay(value.Score)
 		value.LastDecayNS = nowNS
 
 		// Write decayed score back to BPF map.
@@ -183,13 +358,16 @@ func (e *Engine) poll() {
 					zap.Uint32("score", value.Score),
 					zap.Uint32("threshold", e.threshold),
 				)
+				if e.onAutoBlock != nil {
+					e.onAutoBlock(ipStr, value.Score, e.threshold)
+				}
 			}
 		}
 
-		// Auto-unblock: score decayed below threshold/2, was auto-blocked (not manual).
-		unblockThreshold := e.threshold / uint32(unblockRatio)
+		// Auto-unblock: score decayed below threshold/ratio, was auto-blocked (not manual).
+		unblockThreshold := e.threshold / e.cfg.UnblockRatio
 		if value.Score < unblockThreshold && e.blocked[key] && !e.manualBlocked[key] {
-			if err := e.removeFromBlacklist(key); err != nil {
+			if err := e.releaseLocked(key); err != nil {
 				e.log.Warn("auto-unblock failed",
 					zap.String("ip", ipStr),
 					zap.Uint32("score", value.Score),
@@ -214,26 +392,153 @@ func (e *Engine) poll() {
 	if err := iter.Err(); err != nil {
 		e.log.Debug("reputation map iteration error", zap.Error(err))
 	}
+
+	e.expireSharedLocked(now)
+	e.decayEventScoresLocked()
+
+	for k, t := range e.lastEvent {
+		if now.Sub(t) > eventDedupWindow {
+			delete(e.lastEvent, k)
+		}
+	}
+}
+
+// decayEventScoresLocked decays the pending event scores left after a poll,
+// those of sources the data plane has not scored, like the kernel scores,
+// and forgets the ones that reach zero. Caller must hold e.mu.
+func (e *Engine) decayEventScoresLocked() {
+	for key, score := range e.eventScore {
+		if score = e.cfg.decay(score); score == 0 {
+			delete(e.eventScore, key)
+		} else {
+			e.eventScore[key] = score
+		}
+	}
+}
+
+// HandleEvent applies a drop event to the source's userspace score so that
+// high-rate offenders can be auto-blocked between polls. It has the
+// signature of events.Handler.
+func (e *Engine) HandleEvent(ev *bpf.Event) {
+	if ev.Action != bpf.VerdictDrop {
+		return
+	}
+	now := time.Now()
+	key := ev.SrcIP
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	weight := e.cfg.Weights.forReason(ev.DropReason)
+	if weight == 0 {
+		return
+	}
+
+	ek := eventKey{ip: key, reason: ev.DropReason}
+	if last, ok := e.lastEvent[ek]; ok && now.Sub(last) < eventDedupWindow {
+		return
+	}
+	// Only sources that get a score are deduplicated, so lastEvent is
+	// bounded by maxEventScores as well.
+	if e.addEventScoreLocked(key, weight, bpf.DropReasonName(ev.DropReason)) {
+		e.lastEvent[ek] = now
+	}
+}
+
+// Penalize adds weight to the score of a source for a violation that did
+// not drop a packet, such as a port scan, auto-blocking it once the
+// threshold is reached. ipBE is in network byte order as in BPF events.
+func (e *Engine) Penalize(ipBE, weight uint32, reason string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.addEventScoreLocked(ipBE, weight, reason)
+}
+
+// addEventScoreLocked adds weight to the pending event score of key and
+// blocks it once its score reaches the threshold. New sources are ignored
+// while maxEventScores are pending; it reports whether the weight was
+// added. Caller must hold e.mu.
+func (e *Engine) addEventScoreLocked(key, weight uint32, reason string) bool {
+	if _, ok := e.eventScore[key]; !ok && len(e.eventScore) >= maxEventScores {
+		return false
+	}
+	e.eventScore[key] += weight
+
+	if e.blocked[key] {
+		return true
+	}
+
+	var base uint32
+	if rep, exists := e.reputations[key]; exists {
+		base = rep.Score
+	}
+	score := base + e.eventScore[key]
+	if score < e.threshold {
+		return true
+	}
+
+	ipStr := u32BEToIP(key).String()
+	if err := e.addToBlacklist(key); err != nil {
+		e.log.Warn("event-driven auto-block failed",
+			zap.String("ip", ipStr),
+			zap.Uint32("score", score),
+			zap.Error(err),
+		)
+		return true
+	}
+	e.blocked[key] = true
+	if rep, exists := e.reputations[key]; exists {
+		rep.Blocked = true
+	}
+
+	e.log.Info("ip auto-blocked by event feedback",
+		zap.String("ip", ipStr),
+		zap.Uint32("score", score),
+		zap.Uint32("threshold", e.threshold),
+		zap.String("reason", reason),
+	)
+	if e.onAutoBlock != nil {
+		e.onAutoBlock(ipStr, score, e.threshold)
+	}
+	return true
+}
+
+// OnAutoBlock sets a callback invoked whenever an IP is automatically
+// blocked. It is called with the engine lock held and must not block.
+func (e *Engine) OnAutoBlock(fn func(ip string, score, threshold uint32)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onAutoBlock = fn
 }
 
 // GetTopOffenders returns the top N IPs by reputation score.
 func (e *Engine) GetTopOffenders(n int) []IPReputation {
+	all := e.All()
+	if n > len(all) {
+		n = len(all)
+	}
+	return all[:n]
+}
+
+// All returns every tracked IP, highest score first.
+func (e *Engine) All() []IPReputation {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
 	all := make([]IPReputation, 0, len(e.reputations))
-	for _, rep := range e.reputations {
-		all = append(all, *rep)
+	for key, rep := range e.reputations {
+		r := *rep
+		r.Manual = e.manualBlocked[key]
+		all = append(all, r)
 	}
 
 	sort.Slice(all, func(i, j int) bool {
-		return all[i].Score > all[j].Score
+		if all[i].Score != all[j].Score {
+			return all[i].Score > all[j].Score
+		}
+		return all[i].IP < all[j].IP
 	})
-
-	if n > len(all) {
-		n = len(all)
-	}
-	return all[:n]
+	return all
 }
 
 // BlockIP manually blocks an IP address. Manual blocks are never auto-unblocked.
@@ -297,6 +602,7 @@ func (e *Engine) UnblockIP(ip string) error {
 
 	delete(e.blocked, key)
 	delete(e.manualBlocked, key)
+	delete(e.shared, key) // Until shared again
 
 	if rep, exists := e.reputations[key]; exists {
 		rep.Blocked = false
@@ -306,22 +612,34 @@ func (e *Engine) UnblockIP(ip string) error {
 	return nil
 }
 
-// GetBlocked returns all currently blocked IPs (auto + manual).
+// GetBlocked returns all currently blocked IPs (auto, manual and shared
+// by other scrubbers).
 func (e *Engine) GetBlocked() []IPReputation {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	result := make([]IPReputation, 0, len(e.blocked))
+	result := make([]IPReputation, 0, len(e.blocked)+len(e.shared))
 	for key := range e.blocked {
-		if rep, exists := e.reputations[key]; exists {
-			result = append(result, *rep)
-		} else {
-			result = append(result, IPReputation{
-				IP:      u32BEToIP(key).String(),
-				Blocked: true,
-			})
+		rep := IPReputation{IP: u32BEToIP(key).String(), Blocked: true}
+		if r, exists := e.reputations[key]; exists {
+			rep = *r
+		}
+		rep.Manual = e.manualBlocked[key]
+		result = append(result, rep)
+	}
+	for key, sb := range e.shared {
+		if e.blocked[key] {
+			continue
+		}
+		rep := IPReputation{IP: u32BEToIP(key).String(), Score: sb.score}
+		if r, exists := e.reputations[key]; exists {
+			rep = *r
 		}
+		rep.Blocked = true
+		rep.Origin, rep.ExpiresAt = sb.originsAndExpiry()
+		result = append(result, rep)
 	}
+	sort.Slice(result, func(i, j int) bool { return result[i].IP < result[j].IP })
 	return result
 }
 
@@ -348,6 +666,14 @@ func (e *Engine) GetThreshold() uint32 {
 	return e.threshold
 }
 
+// Score returns the current score of a source, including drop events seen
+// since the last poll. ipBE is in network byte order as in BPF events.
+func (e *Engine) Score(ipBE uint32) uint32 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.scoreLocked(ipBE)
+}
+
 // GetTrackedCount returns the number of IPs currently tracked.
 func (e *Engine) GetTrackedCount() int {
 	e.mu.RLock()
@@ -373,8 +699,8 @@ func (e *Engine) addToBlacklist(ipBE uint32) error {
 		Addr:      ipBE,
 	}
 	// Drop reason = DROP_REPUTATION (13 from types.h).
-	var reason uint32 = 13
-	return e.blacklistMap.Update(key, reason, ebpf.UpdateAny)
+	entry := bpf.ACLEntry{Reason: 13}
+	return e.blacklistMap.Update(key, entry, ebpf.UpdateAny)
 }
 
 func (e *Engine) removeFromBlacklist(ipBE uint32) error {
@@ -385,6 +711,27 @@ func (e *Engine) removeFromBlacklist(ipBE uint32) error {
 	return e.blacklistMap.Delete(key)
 }
 
+// forReason returns the score increment for a drop reason. Drops that
+// are themselves the result of a block (ACL, reputation, threat intel)
+// carry no weight to avoid a feedback loop.
+func (w Weights) forReason(reason uint8) uint32 {
+	switch reason {
+	case bpf.DropSYNFlood:
+		return w.SYNNoACK
+	case bpf.DropRateLimit, bpf.DropUDPFlood, bpf.DropICMPFlood, bpf.DropACKInvalid:
+		return w.RateExceeded
+	case bpf.DropDNSAmp, bpf.DropNTPAmp, bpf.DropSSDPAmp, bpf.DropMemcachedAmp,
+		bpf.DropProtoInvalid, bpf.DropTCPState:
+		return w.ProtoAnomaly
+	case bpf.DropPayloadMatch, bpf.DropFingerprint:
+		return w.BadPayload
+	case bpf.DropFragment:
+		return w.Fragment
+	default:
+		return 0
+	}
+}
+
 func u32BEToIP(addr uint32) net.IP {
 	ip := make(net.IP, 4)
 	binary.BigEndian.PutUint32(ip, addr)
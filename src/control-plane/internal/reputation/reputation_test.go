@@ -0,0 +1,239 @@
+package reputation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+func TestWeightsForReason(t *testing.T) {
+	w := DefaultConfig().Weights
+	tests := []struct {
+		reason uint8
+		want   uint32
+	}{
+		{bpf.DropSYNFlood, weightSYNNoACK},
+		{bpf.DropRateLimit, weightRateExceeded},
+		{bpf.DropDNSAmp, weightProtoAnomaly},
+		{bpf.DropPayloadMatch, weightBadPayload},
+		{bpf.DropFragment, weightFragment},
+		{bpf.DropBlacklist, 0},
+		{bpf.DropReputation, 0},
+		{bpf.DropThreatIntel, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(bpf.DropReasonName(tt.reason), func(t *testing.T) {
+			if got := w.forReason(tt.reason); got != tt.want {
+				t.Errorf("forReason(%d) = %d, want %d", tt.reason, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleEventDedup(t *testing.T) {
+	e := NewEngine(zap.NewNop(), nil, nil, nil)
+
+	ev := &bpf.Event{
+		SrcIP:      0x0100000a,
+		Action:     bpf.VerdictDrop,
+		DropReason: bpf.DropSYNFlood,
+	}
+
+	// Repeated events with the same reason inside the dedup window count once.
+	for i := 0; i < 10; i++ {
+		e.HandleEvent(ev)
+	}
+	if got := e.eventScore[ev.SrcIP]; got != weightSYNNoACK {
+		t.Errorf("eventScore after duplicate events = %d, want %d", got, weightSYNNoACK)
+	}
+
+	// A different reason is counted separately.
+	ev2 := *ev
+	ev2.DropReason = bpf.DropFragment
+	e.HandleEvent(&ev2)
+	if got := e.eventScore[ev.SrcIP]; got != weightSYNNoACK+weightFragment {
+		t.Errorf("eventScore = %d, want %d", got, weightSYNNoACK+weightFragment)
+	}
+
+	// Pass events are ignored.
+	pass := *ev
+	pass.SrcIP = 0x0200000a
+	pass.Action = bpf.VerdictPass
+	e.HandleEvent(&pass)
+	if _, ok := e.eventScore[pass.SrcIP]; ok {
+		t.Error("pass event should not add score")
+	}
+}
+
+func TestEventScoresBounded(t *testing.T) {
+	e := NewEngine(zap.NewNop(), nil, nil, nil)
+
+	// Sources the kernel never scored decay like kernel scores and are
+	// forgotten at zero.
+	e.Penalize(0x0100000a, 12, "test")
+	e.Penalize(0x0200000a, 3, "test")
+	e.decayEventScoresLocked()
+	if got := e.eventScore[0x0100000a]; got != 12-defaultDecayRate {
+		t.Errorf("decayed score = %d, want %d", got, 12-defaultDecayRate)
+	}
+	if _, ok := e.eventScore[0x0200000a]; ok {
+		t.Error("source decayed to zero is still tracked")
+	}
+	e.decayEventScoresLocked()
+	e.decayEventScoresLocked()
+	if len(e.eventScore) != 0 {
+		t.Errorf("event scores after decay = %v", e.eventScore)
+	}
+
+	// A spoofed-source flood stops at maxEventScores.
+	for ip := uint32(1); ip <= maxEventScores+100; ip++ {
+		e.Penalize(ip, 1, "test")
+	}
+	if len(e.eventScore) != maxEventScores {
+		t.Errorf("%d sources tracked, want %d", len(e.eventScore), maxEventScores)
+	}
+	e.Penalize(1, 1, "test")
+	if got := e.eventScore[1]; got != 2 {
+		t.Errorf("tracked source score = %d, want 2", got)
+	}
+
+	// Events from sources past the cap are not remembered for dedup either.
+	for ip := uint32(maxEventScores + 1); ip <= maxEventScores+100; ip++ {
+		e.HandleEvent(&bpf.Event{SrcIP: ip, Action: bpf.VerdictDrop, DropReason: bpf.DropSYNFlood})
+	}
+	if len(e.lastEvent) != 0 {
+		t.Errorf("%d dedup entries for untracked sources, want 0", len(e.lastEvent))
+	}
+}
+
+func TestPenalize(t *testing.T) {
+	e := NewEngine(zap.NewNop(), nil, nil, nil)
+
+	// Penalties are not deduplicated like drop events.
+	e.Penalize(0x0100000a, 70, "port_scan")
+	e.Penalize(0x0100000a, 70, "port_scan")
+	if got := e.Score(0x0100000a); got != 140 {
+		t.Errorf("score after two penalties = %d, want 140", got)
+	}
+}
+
+func TestDecay(t *testing.T) {
+	linear := DefaultConfig()
+	if got := linear.decay(12); got != 7 {
+		t.Errorf("linear decay(12) = %d, want 7", got)
+	}
+	if got := linear.decay(3); got != 0 {
+		t.Errorf("linear decay(3) = %d, want 0", got)
+	}
+
+	exp := DefaultConfig()
+	exp.Decay = DecayExponential
+	exp.PollIntervalSec = 10
+	exp.DecayHalfLifeSec = 10
+	if got := exp.decay(800); got != 400 {
+		t.Errorf("exponential decay(800) = %d, want 400", got)
+	}
+	if got := exp.decay(1); got != 0 {
+		t.Errorf("exponential decay(1) = %d, want 0", got)
+	}
+}
+
+func TestSetConfig(t *testing.T) {
+	e := NewEngine(zap.NewNop(), nil, nil, nil)
+
+	cfg := DefaultConfig()
+	cfg.Weights.SYNNoACK = 200
+	if err := e.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	e.HandleEvent(&bpf.Event{SrcIP: 0x0100000a, Action: bpf.VerdictDrop, DropReason: bpf.DropSYNFlood})
+	if got := e.Score(0x0100000a); got != 200 {
+		t.Errorf("score with custom weight = %d, want 200", got)
+	}
+
+	for i, mutate := range []func(*Config){
+		func(c *Config) { c.PollIntervalSec = 0 },
+		func(c *Config) { c.Decay = "step" },
+		func(c *Config) { c.Decay, c.DecayHalfLifeSec = DecayExponential, 0 },
+		func(c *Config) { c.UnblockRatio = 0 },
+	} {
+		bad := DefaultConfig()
+		mutate(&bad)
+		if err := e.SetConfig(bad); err == nil {
+			t.Errorf("case %d: expected error", i)
+		}
+	}
+	if e.Config().Weights.SYNNoACK != 200 {
+		t.Error("invalid config replaced the current one")
+	}
+}
+
+// fakeBlacklist records the addresses in the blacklist map.
+type fakeBlacklist map[uint32]bool
+
+func (f fakeBlacklist) Update(key, _ interface{}, _ ebpf.MapUpdateFlags) error {
+	f[key.(lpmKeyV4).Addr] = true
+	return nil
+}
+
+func (f fakeBlacklist) Delete(key interface{}) error {
+	delete(f, key.(lpmKeyV4).Addr)
+	return nil
+}
+
+func TestSharedBlocks(t *testing.T) {
+	e := NewEngine(zap.NewNop(), nil, nil, nil)
+	bl := fakeBlacklist{}
+	e.blacklistMap = bl
+	const (
+		shared = 0xc6336407 // 198.51.100.7
+		local  = 0xc6336408 // 198.51.100.8
+	)
+
+	e.Penalize(local, 600, "port_scan") // Auto-blocked here
+	if err := e.SetShared("pop-fra", map[string]uint32{"198.51.100.7": 900, "198.51.100.8": 700}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetShared("pop-lhr", map[string]uint32{"198.51.100.7": 800}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if !bl[shared] || !bl[local] {
+		t.Fatalf("blacklist = %v", bl)
+	}
+
+	// Only local blocks are shared onwards and counted.
+	if got := e.Offenders(0); len(got) != 1 || got["198.51.100.8"] != 600 {
+		t.Errorf("offenders = %v, want the local block only", got)
+	}
+	if got := e.Offenders(100); len(got) != 1 {
+		t.Errorf("offenders with min score = %v", got)
+	}
+	blocked := e.GetBlocked()
+	if len(blocked) != 2 || blocked[0].Origin != "pop-fra,pop-lhr" || blocked[0].Score != 900 || blocked[1].Origin != "" {
+		t.Errorf("blocked = %+v", blocked)
+	}
+
+	// A source one origin stops sharing stays blocked while another
+	// shares it, and a local block outlives every share.
+	if err := e.SetShared("pop-fra", nil, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if !bl[shared] || !bl[local] {
+		t.Errorf("blacklist after pop-fra withdrew = %v", bl)
+	}
+	e.expireSharedLocked(time.Now().Add(2 * time.Hour))
+	if bl[shared] || !bl[local] {
+		t.Errorf("blacklist after shares expired = %v", bl)
+	}
+	if len(e.shared) != 0 {
+		t.Errorf("shared = %v, want none", e.shared)
+	}
+
+	if err := e.SetShared("pop-fra", map[string]uint32{"2001:db8::1": 900}, time.Hour); err == nil {
+		t.Error("IPv6 source accepted")
+	}
+}
@@ -0,0 +1,162 @@
+package reputation
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// sharedBlock is a source blocked because other scrubbers blocked it or
+// scored it high. Each origin's share lapses on its own.
+type sharedBlock struct {
+	score   uint32               // Highest score shared
+	origins map[string]time.Time // Origin node -> expiry
+}
+
+// originsAndExpiry returns the origins, comma separated, and the latest
+// expiry.
+func (sb *sharedBlock) originsAndExpiry() (string, time.Time) {
+	origins := make([]string, 0, len(sb.origins))
+	var expires time.Time
+	for origin, exp := range sb.origins {
+		origins = append(origins, origin)
+		if exp.After(expires) {
+			expires = exp
+		}
+	}
+	sort.Strings(origins)
+	return strings.Join(origins, ","), expires
+}
+
+// Offenders returns the sources this scrubber blocked itself, or scores
+// at least minScore if minScore is not 0, with their scores. Blocks
+// shared by other scrubbers are left out so that they are never passed
+// on.
+func (e *Engine) Offenders(minScore uint32) map[string]uint32 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	out := make(map[string]uint32, len(e.blocked))
+	for key := range e.blocked {
+		out[u32BEToIP(key).String()] = e.scoreLocked(key)
+	}
+	if minScore == 0 {
+		return out
+	}
+	for key := range e.reputations {
+		if score := e.scoreLocked(key); score >= minScore {
+			out[u32BEToIP(key).String()] = score
+		}
+	}
+	return out
+}
+
+func (e *Engine) scoreLocked(key uint32) uint32 {
+	var base uint32
+	if rep, exists := e.reputations[key]; exists {
+		base = rep.Score
+	}
+	return base + e.eventScore[key]
+}
+
+// SetShared replaces the sources shared by origin with scores, blocking
+// each until ttl passes without origin sharing it again. Sources origin
+// no longer shares are unblocked unless blocked here or shared by
+// another origin. It returns the last source that could not be blocked.
+func (e *Engine) SetShared(origin string, scores map[string]uint32, ttl time.Duration) error {
+	expires := time.Now().Add(ttl)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var lastErr error
+	seen := make(map[uint32]bool, len(scores))
+	added := 0
+	for s, score := range scores {
+		ip := net.ParseIP(s).To4()
+		if ip == nil {
+			lastErr = fmt.Errorf("invalid IPv4 address %q from %s", s, origin)
+			continue
+		}
+		key := binary.BigEndian.Uint32(ip)
+		seen[key] = true
+
+		sb := e.shared[key]
+		if sb == nil {
+			if !e.blocked[key] {
+				if err := e.addToBlacklist(key); err != nil {
+					lastErr = fmt.Errorf("blocking %s shared by %s: %w", s, origin, err)
+					continue
+				}
+				added++
+			}
+			sb = &sharedBlock{origins: make(map[string]time.Time)}
+			e.shared[key] = sb
+		}
+		sb.origins[origin] = expires
+		if score > sb.score {
+			sb.score = score
+		}
+	}
+
+	removed := 0
+	for key, sb := range e.shared {
+		if _, ok := sb.origins[origin]; ok && !seen[key] {
+			e.dropSharedLocked(key, origin)
+			removed++
+		}
+	}
+
+	if added > 0 || removed > 0 {
+		e.log.Info("reputation blocks shared by fleet member",
+			zap.String("origin", origin),
+			zap.Int("blocked", added),
+			zap.Int("withdrawn", removed),
+			zap.Int("shared", len(scores)),
+		)
+	}
+	return lastErr
+}
+
+// expireSharedLocked drops the shares that were not renewed in time.
+func (e *Engine) expireSharedLocked(now time.Time) {
+	for key, sb := range e.shared {
+		for origin, exp := range sb.origins {
+			if now.After(exp) {
+				e.dropSharedLocked(key, origin)
+			}
+		}
+	}
+}
+
+// dropSharedLocked removes origin's share of key, unblocking the source
+// when no one else blocks it.
+func (e *Engine) dropSharedLocked(key uint32, origin string) {
+	sb := e.shared[key]
+	delete(sb.origins, origin)
+	if len(sb.origins) > 0 {
+		return
+	}
+	delete(e.shared, key)
+	if e.blocked[key] {
+		return
+	}
+	if err := e.removeFromBlacklist(key); err != nil {
+		e.log.Warn("failed to lift shared block",
+			zap.String("ip", u32BEToIP(key).String()), zap.Error(err))
+	}
+}
+
+// releaseLocked lifts this scrubber's own block of key from the map,
+// unless other scrubbers still share it.
+func (e *Engine) releaseLocked(key uint32) error {
+	if e.shared[key] != nil {
+		return nil
+	}
+	return e.removeFromBlacklist(key)
+}
@@ -0,0 +1,116 @@
+package reputation
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// persistedState is the on-disk form of the engine's block decisions. Scores
+// live in the kernel map and are rebuilt from traffic after a restart; the
+// blocks are what must survive, since the blacklist map is recreated empty.
+type persistedState struct {
+	SavedAt       time.Time `json:"savedAt"`
+	Threshold     uint32    `json:"threshold"`
+	Blocked       []string  `json:"blocked"`
+	ManualBlocked []string  `json:"manualBlocked"`
+}
+
+// SaveState writes the current auto and manual blocks to path atomically.
+func (e *Engine) SaveState(path string) error {
+	e.mu.RLock()
+	st := persistedState{
+		SavedAt:   time.Now(),
+		Threshold: e.threshold,
+	}
+	for key := range e.blocked {
+		ip := u32BEToIP(key).String()
+		if e.manualBlocked[key] {
+			st.ManualBlocked = append(st.ManualBlocked, ip)
+		} else {
+			st.Blocked = append(st.Blocked, ip)
+		}
+	}
+	e.mu.RUnlock()
+
+	sort.Strings(st.Blocked)
+	sort.Strings(st.ManualBlocked)
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling reputation state: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing reputation state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing reputation state: %w", err)
+	}
+
+	e.log.Info("reputation state saved",
+		zap.String("path", path),
+		zap.Int("auto_blocked", len(st.Blocked)),
+		zap.Int("manual_blocked", len(st.ManualBlocked)),
+	)
+	return nil
+}
+
+// LoadState restores blocks saved by SaveState into the blacklist map. A
+// missing file is not an error.
+func (e *Engine) LoadState(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading reputation state: %w", err)
+	}
+
+	var st persistedState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("parsing reputation state %s: %w", filepath.Base(path), err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	restore := func(ips []string, manual bool) int {
+		n := 0
+		for _, s := range ips {
+			ip := net.ParseIP(s).To4()
+			if ip == nil {
+				continue
+			}
+			key := binary.BigEndian.Uint32(ip)
+			if err := e.addToBlacklist(key); err != nil {
+				e.log.Warn("failed to restore block", zap.String("ip", s), zap.Error(err))
+				continue
+			}
+			e.blocked[key] = true
+			if manual {
+				e.manualBlocked[key] = true
+			}
+			n++
+		}
+		return n
+	}
+	auto := restore(st.Blocked, false)
+	manual := restore(st.ManualBlocked, true)
+
+	e.log.Info("reputation state restored",
+		zap.String("path", path),
+		zap.Time("saved_at", st.SavedAt),
+		zap.Int("auto_blocked", auto),
+		zap.Int("manual_blocked", manual),
+	)
+	return nil
+}
@@ -0,0 +1,420 @@
+// Package revisions keeps a numbered history of the applied configuration:
+// the YAML-backed config together with the data plane config map. Each
+// change made at runtime becomes a revision that can be diffed against
+// any other and rolled back to.
+package revisions
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigMap reads and writes data plane config map entries.
+type ConfigMap interface {
+	GetConfig(key uint32) (uint64, error)
+	SetConfig(key uint32, value uint64) error
+}
+
+// Revision is one applied configuration.
+type Revision struct {
+	Number int               `json:"number"`
+	At     time.Time         `json:"at"`
+	Source string            `json:"source"` // "startup", the API request, or "rollback to N"
+	Author string            `json:"author,omitempty"`
+	Values map[string]uint64 `json:"values"` // Writable config map entries by key name
+	Config string            `json:"config"` // Effective YAML configuration
+}
+
+// folds copy config map entries into the YAML settings they come from, so
+// the config reflects what the data plane runs with.
+var folds = map[string]func(c *config.Config, v uint64){
+	"enabled":           func(c *config.Config, v uint64) { c.Scrubber.Enabled = v != 0 },
+	"conntrack_enable":  func(c *config.Config, v uint64) { c.Scrubber.ConntrackEnabled = v != 0 },
+	"attack_threshold":  func(c *config.Config, v uint64) { c.Scrubber.AttackThreshold = v },
+	"syn_cookie_enable": func(c *config.Config, v uint64) { c.SYNCookie.Enabled = v != 0 },
+	"syn_rate_pps":      func(c *config.Config, v uint64) { c.RateLimit.SYNRatePPS = v },
+	"udp_rate_pps":      func(c *config.Config, v uint64) { c.RateLimit.UDPRatePPS = v },
+	"icmp_rate_pps":     func(c *config.Config, v uint64) { c.RateLimit.ICMPRatePPS = v },
+	"global_pps_limit":  func(c *config.Config, v uint64) { c.RateLimit.GlobalPPS = v },
+	"global_bps_limit":  func(c *config.Config, v uint64) { c.RateLimit.GlobalBPS = v },
+	"adaptive_rate":     func(c *config.Config, v uint64) { c.RateLimit.Adaptive.Enabled = v != 0 },
+	"conn_limit":        func(c *config.Config, v uint64) { c.ConnLimit.PerSource = uint32(v) },
+	"tls_hs_rate":       func(c *config.Config, v uint64) { c.HandshakeFlood.TLSRatePPS = v },
+	"quic_init_rate":    func(c *config.Config, v uint64) { c.HandshakeFlood.QUICRatePPS = v },
+	"port_scan_thresh":  func(c *config.Config, v uint64) { c.PortScan.Threshold = uint32(v) },
+	"port_scan_window":  func(c *config.Config, v uint64) { c.PortScan.WindowSec = v },
+}
+
+// settingValues are the reverse of folds: the config map entry a YAML
+// setting is written to.
+var settingValues = map[string]func(c *config.Config) uint64{
+	"enabled":           func(c *config.Config) uint64 { return boolValue(c.Scrubber.Enabled) },
+	"conntrack_enable":  func(c *config.Config) uint64 { return boolValue(c.Scrubber.ConntrackEnabled) },
+	"attack_threshold":  func(c *config.Config) uint64 { return c.Scrubber.AttackThreshold },
+	"syn_cookie_enable": func(c *config.Config) uint64 { return boolValue(c.SYNCookie.Enabled) },
+	"syn_rate_pps":      func(c *config.Config) uint64 { return c.RateLimit.SYNRatePPS },
+	"udp_rate_pps":      func(c *config.Config) uint64 { return c.RateLimit.UDPRatePPS },
+	"icmp_rate_pps":     func(c *config.Config) uint64 { return c.RateLimit.ICMPRatePPS },
+	"global_pps_limit":  func(c *config.Config) uint64 { return c.RateLimit.GlobalPPS },
+	"global_bps_limit":  func(c *config.Config) uint64 { return c.RateLimit.GlobalBPS },
+	"adaptive_rate":     func(c *config.Config) uint64 { return boolValue(c.RateLimit.Adaptive.Enabled) },
+	"conn_limit":        func(c *config.Config) uint64 { return uint64(c.ConnLimit.PerSource) },
+	"tls_hs_rate":       func(c *config.Config) uint64 { return c.HandshakeFlood.TLSRatePPS },
+	"quic_init_rate":    func(c *config.Config) uint64 { return c.HandshakeFlood.QUICRatePPS },
+	"port_scan_thresh":  func(c *config.Config) uint64 { return uint64(c.PortScan.Threshold) },
+	"port_scan_window":  func(c *config.Config) uint64 { return c.PortScan.WindowSec },
+}
+
+func boolValue(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// adaptiveKeys are rewritten by the adaptive rate loop while adaptive_rate
+// is on; they are left out of revisions then rather than recorded as
+// changes every time the loop moves them.
+var adaptiveKeys = []string{"syn_rate_pps", "udp_rate_pps", "icmp_rate_pps"}
+
+// Store records revisions and rolls back to them.
+type Store struct {
+	log  *zap.Logger
+	cfg  *config.Config
+	maps ConfigMap
+	max  int
+
+	mu   sync.Mutex
+	revs []*Revision // Oldest first
+}
+
+// NewStore creates an empty store for the configuration and config map.
+func NewStore(log *zap.Logger, cfg *config.Config, maps ConfigMap) *Store {
+	return &Store{log: log, cfg: cfg, maps: maps, max: cfg.Revisions.Max}
+}
+
+// Record captures the applied configuration and, if it differs from the
+// latest revision, adds it as a new one, which is returned. With
+// write_config the config file is rewritten to match.
+func (s *Store) Record(source, author string) (*Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.record(source, author)
+}
+
+func (s *Store) record(source, author string) (*Revision, error) {
+	values, err := s.readValues()
+	if err != nil {
+		return nil, err
+	}
+	s.cfg.Update(func(c *config.Config) {
+		for name, v := range values {
+			if fold := folds[name]; fold != nil {
+				fold(c, v)
+			}
+		}
+	})
+	data, err := s.cfg.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	rev := &Revision{
+		Number: 1,
+		At:     time.Now(),
+		Source: source,
+		Author: author,
+		Values: values,
+		Config: string(data),
+	}
+	if n := len(s.revs); n > 0 {
+		last := s.revs[n-1]
+		if last.Config == rev.Config && equalValues(last.Values, rev.Values) {
+			return nil, nil
+		}
+		rev.Number = last.Number + 1
+	}
+	s.revs = append(s.revs, rev)
+	if len(s.revs) > s.max {
+		s.revs = append([]*Revision(nil), s.revs[len(s.revs)-s.max:]...)
+	}
+
+	s.log.Info("configuration revision recorded",
+		zap.Int("revision", rev.Number),
+		zap.String("source", source),
+		zap.String("author", author),
+	)
+	if s.cfg.Revisions.WriteConfig && s.cfg.Path != "" {
+		if err := writeFile(s.cfg.Path, data); err != nil {
+			s.log.Warn("failed to write config file", zap.String("path", s.cfg.Path), zap.Error(err))
+		}
+	}
+	return rev, nil
+}
+
+// readValues reads the writable config map entries.
+func (s *Store) readValues() (map[string]uint64, error) {
+	values := make(map[string]uint64)
+	for _, k := range bpf.ConfigKeys {
+		if k.ManagedBy != "" {
+			continue
+		}
+		v, err := s.maps.GetConfig(k.Key)
+		if err != nil {
+			return nil, err
+		}
+		values[k.Name] = v
+	}
+	if values["adaptive_rate"] != 0 {
+		for _, name := range adaptiveKeys {
+			delete(values, name)
+		}
+	}
+	return values, nil
+}
+
+// List returns the retained revisions, oldest first.
+func (s *Store) List() []Revision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Revision, len(s.revs))
+	for i, r := range s.revs {
+		out[i] = *r
+	}
+	return out
+}
+
+// Get returns a retained revision.
+func (s *Store) Get(number int) (Revision, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r := s.find(number); r != nil {
+		return *r, true
+	}
+	return Revision{}, false
+}
+
+func (s *Store) find(number int) *Revision {
+	for _, r := range s.revs {
+		if r.Number == number {
+			return r
+		}
+	}
+	return nil
+}
+
+// Previous returns the revision before number, if retained.
+func (s *Store) Previous(number int) (Revision, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, r := range s.revs {
+		if r.Number == number && i > 0 {
+			return *s.revs[i-1], true
+		}
+	}
+	return Revision{}, false
+}
+
+// Rollback writes the config map entries of a revision back to the data
+// plane and records the result as a new revision. If the configuration
+// already matches, the latest revision is returned unchanged.
+func (s *Store) Rollback(number int, author string) (Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target := s.find(number)
+	if target == nil {
+		return Revision{}, fmt.Errorf("revision %d not found", number)
+	}
+	if err := s.write(target.Values); err != nil {
+		return Revision{}, err
+	}
+
+	rev, err := s.record(fmt.Sprintf("rollback to %d", number), author)
+	if err != nil {
+		return Revision{}, err
+	}
+	if rev == nil {
+		return *s.revs[len(s.revs)-1], nil
+	}
+	return *rev, nil
+}
+
+// Apply writes the config map entries of a new configuration, such as one
+// pushed by an orchestrator, and records the result. Other settings only
+// take effect on restart; restart reports whether cfg differs from the
+// running configuration in any of them.
+func (s *Store) Apply(cfg *config.Config, source, author string) (rev *Revision, restart bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values := make(map[string]uint64, len(settingValues))
+	for name, value := range settingValues {
+		values[name] = value(cfg)
+	}
+	if cfg.RateLimit.Adaptive.Enabled {
+		// The adaptive loop owns these.
+		for _, name := range adaptiveKeys {
+			delete(values, name)
+		}
+	}
+	if err := s.write(values); err != nil {
+		return nil, false, err
+	}
+	if rev, err = s.record(source, author); err != nil {
+		return nil, false, err
+	}
+
+	// With the config map entries folded in from the data plane on both
+	// sides, whatever still differs needs a restart.
+	current, err := s.readValues()
+	if err != nil {
+		return nil, false, err
+	}
+	cfg.Update(func(c *config.Config) {
+		for name, v := range current {
+			if fold := folds[name]; fold != nil {
+				fold(c, v)
+			}
+		}
+	})
+	want, err := cfg.Marshal()
+	if err != nil {
+		return nil, false, err
+	}
+	have, err := s.cfg.Marshal()
+	if err != nil {
+		return nil, false, err
+	}
+	return rev, string(want) != string(have), nil
+}
+
+// write sets config map entries by key name, adaptive_rate last so the
+// adaptive loop does not race the limits written with it.
+func (s *Store) write(values map[string]uint64) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == "adaptive_rate") != (names[j] == "adaptive_rate") {
+			return names[j] == "adaptive_rate"
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		k, ok := bpf.LookupConfigKey(name)
+		if !ok || k.ManagedBy != "" {
+			continue
+		}
+		if err := s.maps.SetConfig(k.Key, values[name]); err != nil {
+			return fmt.Errorf("writing %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func equalValues(a, b map[string]uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// writeFile replaces path atomically.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Change is one setting that differs between two revisions. Config map
+// entries without a YAML setting appear as config_map.<key>.
+type Change struct {
+	Path string `json:"path"`
+	From string `json:"from"` // "" if the setting was absent
+	To   string `json:"to"`
+}
+
+// Diff lists the settings that changed from one revision to another.
+func Diff(from, to Revision) ([]Change, error) {
+	a, err := flatten(from)
+	if err != nil {
+		return nil, fmt.Errorf("revision %d: %w", from.Number, err)
+	}
+	b, err := flatten(to)
+	if err != nil {
+		return nil, fmt.Errorf("revision %d: %w", to.Number, err)
+	}
+
+	var changes []Change
+	for path, v := range b {
+		if a[path] != v {
+			changes = append(changes, Change{Path: path, From: a[path], To: v})
+		}
+	}
+	for path, v := range a {
+		if _, ok := b[path]; !ok {
+			changes = append(changes, Change{Path: path, From: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// flatten renders a revision as dotted setting paths and their values.
+func flatten(r Revision) (map[string]string, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal([]byte(r.Config), &doc); err != nil {
+		return nil, err
+	}
+	out := make(map[string]string)
+	flattenValue(out, "", doc)
+	for name, v := range r.Values {
+		if folds[name] != nil {
+			continue // Already in the YAML
+		}
+		if k, ok := bpf.LookupConfigKey(name); ok {
+			out["config_map."+name] = fmt.Sprint(k.Format(v))
+		}
+	}
+	return out, nil
+}
+
+func flattenValue(out map[string]string, path string, v interface{}) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			flattenValue(out, join(key), child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenValue(out, fmt.Sprintf("%s[%d]", path, i), child)
+		}
+	case nil:
+		out[path] = ""
+	default:
+		out[path] = fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,217 @@
+package revisions
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"go.uber.org/zap"
+)
+
+// fakeMap is an in-memory config map.
+type fakeMap map[uint32]uint64
+
+func (m fakeMap) GetConfig(key uint32) (uint64, error) { return m[key], nil }
+
+func (m fakeMap) SetConfig(key uint32, value uint64) error {
+	m[key] = value
+	return nil
+}
+
+func newTestStore(t *testing.T) (*Store, fakeMap) {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	maps := fakeMap{
+		bpf.CfgEnabled:    1,
+		bpf.CfgSYNRatePPS: cfg.RateLimit.SYNRatePPS,
+		bpf.CfgUDPRatePPS: cfg.RateLimit.UDPRatePPS,
+	}
+	return NewStore(zap.NewNop(), cfg, maps), maps
+}
+
+func TestRecordOnlyChanges(t *testing.T) {
+	s, maps := newTestStore(t)
+
+	first, err := s.Record("startup", "")
+	if err != nil || first == nil || first.Number != 1 {
+		t.Fatalf("Record(startup) = %v, %v, want revision 1", first, err)
+	}
+	if rev, _ := s.Record("PUT /api/v1/acl/blacklist", "noc"); rev != nil {
+		t.Errorf("unchanged configuration recorded as revision %d", rev.Number)
+	}
+
+	maps[bpf.CfgSYNRatePPS] = 50
+	rev, err := s.Record("PUT /api/v1/config/syn_rate_pps", "noc")
+	if err != nil || rev == nil || rev.Number != 2 {
+		t.Fatalf("Record after change = %v, %v, want revision 2", rev, err)
+	}
+	if rev.Author != "noc" || rev.Values["syn_rate_pps"] != 50 {
+		t.Errorf("revision 2 = %+v", rev)
+	}
+	// The change is folded into the YAML-backed config.
+	if got := s.cfg.GetRateLimit().SYNRatePPS; got != 50 {
+		t.Errorf("config syn_rate_pps = %d, want 50", got)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	s, maps := newTestStore(t)
+	s.Record("startup", "")
+	maps[bpf.CfgSYNRatePPS] = 50
+	maps[bpf.CfgTCPStateEnable] = 1
+	s.Record("change", "")
+
+	from, _ := s.Get(1)
+	to, _ := s.Get(2)
+	changes, err := Diff(from, to)
+	if err != nil {
+		t.Fatalf("Diff() error: %v", err)
+	}
+	want := []Change{
+		{Path: "config_map.tcp_state_enable", From: "false", To: "true"},
+		{Path: "rate_limit.syn_rate_pps", From: "1000", To: "50"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Diff() = %+v, want %+v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+}
+
+func TestRollback(t *testing.T) {
+	s, maps := newTestStore(t)
+	s.Record("startup", "")
+	maps[bpf.CfgSYNRatePPS] = 50
+	maps[bpf.CfgEnabled] = 0
+	maps[bpf.CfgEscalationLevel] = 3 // Managed elsewhere; never restored
+	s.Record("fat finger", "noc")
+
+	rev, err := s.Rollback(1, "admin")
+	if err != nil {
+		t.Fatalf("Rollback(1) error: %v", err)
+	}
+	if rev.Number != 3 || rev.Source != "rollback to 1" || rev.Author != "admin" {
+		t.Errorf("rollback revision = %d %q by %q", rev.Number, rev.Source, rev.Author)
+	}
+	if maps[bpf.CfgSYNRatePPS] != 1000 || maps[bpf.CfgEnabled] != 1 {
+		t.Errorf("config map after rollback: syn_rate_pps = %d, enabled = %d", maps[bpf.CfgSYNRatePPS], maps[bpf.CfgEnabled])
+	}
+	if maps[bpf.CfgEscalationLevel] != 3 {
+		t.Error("rollback changed the managed escalation level")
+	}
+
+	// Rolling back to the current state records nothing new.
+	again, err := s.Rollback(3, "admin")
+	if err != nil || again.Number != 3 {
+		t.Errorf("Rollback(3) = %d, %v, want revision 3", again.Number, err)
+	}
+	if _, err := s.Rollback(42, "admin"); err == nil {
+		t.Error("Rollback(42) succeeded for an unknown revision")
+	}
+}
+
+func TestApply(t *testing.T) {
+	s, maps := newTestStore(t)
+	s.Record("startup", "")
+
+	cfg := config.DefaultConfig()
+	cfg.RateLimit.SYNRatePPS = 500
+	cfg.Scrubber.Enabled = false
+	rev, restart, err := s.Apply(cfg, "ScrubberPolicy scrubber/edge version 2", "kubernetes")
+	if err != nil || rev == nil || restart {
+		t.Fatalf("Apply = %v, restart %t, %v, want a revision without restart", rev, restart, err)
+	}
+	if maps[bpf.CfgSYNRatePPS] != 500 || maps[bpf.CfgEnabled] != 0 {
+		t.Errorf("config map after apply: syn_rate_pps = %d, enabled = %d", maps[bpf.CfgSYNRatePPS], maps[bpf.CfgEnabled])
+	}
+	if rev.Source != "ScrubberPolicy scrubber/edge version 2" || rev.Author != "kubernetes" {
+		t.Errorf("revision %d from %q by %q", rev.Number, rev.Source, rev.Author)
+	}
+
+	// Settings outside the config map need a restart.
+	cfg = config.DefaultConfig()
+	cfg.RateLimit.SYNRatePPS = 500
+	cfg.Scrubber.Enabled = false
+	cfg.Blacklist = []string{"198.51.100.0/24"}
+	rev, restart, err = s.Apply(cfg, "ScrubberPolicy scrubber/edge version 3", "kubernetes")
+	if err != nil || rev != nil || !restart {
+		t.Errorf("Apply with new blacklist = %v, restart %t, %v, want no revision and a restart", rev, restart, err)
+	}
+}
+
+func TestSettingValuesMatchFolds(t *testing.T) {
+	for name := range folds {
+		if settingValues[name] == nil {
+			t.Errorf("%s folded but not applied", name)
+		}
+	}
+	for name := range settingValues {
+		if folds[name] == nil {
+			t.Errorf("%s applied but not folded", name)
+		}
+	}
+}
+
+func TestAdaptiveRatesNotRecorded(t *testing.T) {
+	s, maps := newTestStore(t)
+	maps[bpf.CfgAdaptiveRate] = 1
+	s.Record("startup", "")
+
+	maps[bpf.CfgSYNRatePPS] = 4321 // Moved by the adaptive loop
+	if rev, _ := s.Record("PUT /api/v1/acl/blacklist", ""); rev != nil {
+		t.Errorf("adaptive rate change recorded as revision %d", rev.Number)
+	}
+}
+
+func TestMaxRevisionsAndWriteConfig(t *testing.T) {
+	s, maps := newTestStore(t)
+	s.max = 2
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	s.cfg.Path = path
+	s.cfg.Revisions.WriteConfig = true
+
+	for i := uint64(1); i <= 3; i++ {
+		maps[bpf.CfgConnLimit] = i * 100
+		s.Record("change", "")
+	}
+	list := s.List()
+	if len(list) != 2 || list[0].Number != 2 || list[1].Number != 3 {
+		t.Errorf("retained revisions = %d, want 2 and 3", len(list))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("config file not written: %v", err)
+	}
+	if !strings.Contains(string(data), "per_source: 300") {
+		t.Error("config file does not hold the latest conn_limit")
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	s, maps := newTestStore(t)
+	s.Record("startup", "")
+	maps[bpf.CfgSYNRatePPS] = 50
+	s.Record("change", "")
+
+	path := filepath.Join(t.TempDir(), "revisions.json")
+	if err := s.SaveState(path); err != nil {
+		t.Fatalf("SaveState() error: %v", err)
+	}
+
+	restored, maps2 := newTestStore(t)
+	if err := restored.LoadState(path); err != nil {
+		t.Fatalf("LoadState() error: %v", err)
+	}
+	// A restart with the file's rate limit numbers on from the saved history.
+	maps2[bpf.CfgSYNRatePPS] = 1000
+	rev, err := restored.Record("startup", "")
+	if err != nil || rev == nil || rev.Number != 3 {
+		t.Fatalf("Record after restore = %v, %v, want revision 3", rev, err)
+	}
+}
@@ -0,0 +1,65 @@
+package revisions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// persistedState is the on-disk form of the revision history. It holds
+// the full configuration, API keys included, so it is written 0600.
+type persistedState struct {
+	SavedAt   time.Time   `json:"savedAt"`
+	Revisions []*Revision `json:"revisions"`
+}
+
+// SaveState writes the retained revisions to path atomically.
+func (s *Store) SaveState(path string) error {
+	s.mu.Lock()
+	st := persistedState{SavedAt: time.Now(), Revisions: s.revs}
+	data, err := json.MarshalIndent(st, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("marshaling config revisions: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing config revisions: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing config revisions: %w", err)
+	}
+
+	s.log.Info("config revisions saved", zap.String("path", path), zap.Int("revisions", len(st.Revisions)))
+	return nil
+}
+
+// LoadState restores revisions saved by SaveState, so numbering continues
+// across restarts. Call it before the first Record. A missing file is not
+// an error.
+func (s *Store) LoadState(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading config revisions: %w", err)
+	}
+	var st persistedState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("parsing config revisions: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revs = st.Revisions
+	if len(s.revs) > s.max {
+		s.revs = s.revs[len(s.revs)-s.max:]
+	}
+	s.log.Info("config revisions restored", zap.String("path", path), zap.Int("revisions", len(s.revs)))
+	return nil
+}
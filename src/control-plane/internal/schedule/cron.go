@@ -0,0 +1,154 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed five-field cron expression: minute, hour, day of month,
+// month and day of week (0-7, 0 and 7 both Sunday). Fields take *, lists,
+// ranges and steps ("*/15", "1-5", "0,30"). As in cron, when both day
+// fields are restricted a time matches if either does.
+type Cron struct {
+	spec                         string
+	minute, hour, dom, month     uint64 // Bit sets of allowed values
+	dow                          uint64
+	domRestricted, dowRestricted bool
+}
+
+// maxLookback bounds the search for the previous match of an expression.
+const maxLookback = 366 * 24 * time.Hour
+
+// ParseCron parses a cron expression.
+func ParseCron(spec string) (Cron, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return Cron{}, fmt.Errorf("cron %q: want 5 fields (minute hour day month weekday), got %d", spec, len(fields))
+	}
+	c := Cron{spec: spec}
+	for i, f := range []struct {
+		name     string
+		min, max int
+		out      *uint64
+	}{
+		{"minute", 0, 59, &c.minute},
+		{"hour", 0, 23, &c.hour},
+		{"day of month", 1, 31, &c.dom},
+		{"month", 1, 12, &c.month},
+		{"day of week", 0, 7, &c.dow},
+	} {
+		bits, err := parseField(fields[i], f.min, f.max)
+		if err != nil {
+			return Cron{}, fmt.Errorf("cron %q: %s: %w", spec, f.name, err)
+		}
+		*f.out = bits
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1 // 7 is Sunday too
+	}
+	c.domRestricted = fields[2] != "*"
+	c.dowRestricted = fields[4] != "*"
+	return c, nil
+}
+
+// parseField returns the values a field allows as a bit set.
+func parseField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if r, s, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", s)
+			}
+			rng, step = r, n
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			var err error
+			a, b, isRange := strings.Cut(rng, "-")
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("invalid value %q", a)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("invalid value %q", b)
+				}
+			} else if step > 1 {
+				hi = max // "5/15" runs from 5 to the end
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (c Cron) String() string { return c.spec }
+
+// Match reports whether the minute t falls in matches the expression.
+func (c Cron) Match(t time.Time) bool {
+	if c.minute&(1<<uint(t.Minute())) == 0 || !c.matchHour(t) {
+		return false
+	}
+	return c.matchDay(t)
+}
+
+func (c Cron) matchHour(t time.Time) bool {
+	return c.hour&(1<<uint(t.Hour())) != 0 && c.month&(1<<uint(t.Month())) != 0
+}
+
+func (c Cron) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// Prev returns the latest matching minute at or before t, looking back at
+// most a year.
+func (c Cron) Prev(t time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+	stop := t.Add(-maxLookback)
+	for !t.Before(stop) {
+		if !c.matchDay(t) || !c.matchHour(t) {
+			// Skip to the last minute of the previous hour, in local
+			// time: zone offsets need not be whole hours.
+			t = t.Add(-time.Duration(t.Minute()+1) * time.Minute)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) != 0 {
+			return t, true
+		}
+		t = t.Add(-time.Minute)
+	}
+	return time.Time{}, false
+}
+
+// Next returns the first matching minute after t, looking ahead at most a
+// year.
+func (c Cron) Next(t time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	stop := t.Add(maxLookback)
+	for t.Before(stop) {
+		if !c.matchDay(t) || !c.matchHour(t) {
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) != 0 {
+			return t, true
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,115 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, spec := range []string{
+		"* * * * *",
+		"0 8 * * 1-5",
+		"*/15 0-6,22,23 * * *",
+		"30 18 1,15 * 7",
+		"5/20 * * 1-12/3 *",
+	} {
+		if _, err := ParseCron(spec); err != nil {
+			t.Errorf("ParseCron(%q): %v", spec, err)
+		}
+	}
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+	} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q) should fail", spec)
+		}
+	}
+}
+
+func TestCronMatch(t *testing.T) {
+	c, err := ParseCron("0 8 * * 1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2024-03-04 is a Monday.
+	if !c.Match(time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC)) {
+		t.Error("weekday 08:00 should match")
+	}
+	if c.Match(time.Date(2024, 3, 9, 8, 0, 0, 0, time.UTC)) {
+		t.Error("Saturday 08:00 should not match")
+	}
+	if c.Match(time.Date(2024, 3, 4, 8, 1, 0, 0, time.UTC)) {
+		t.Error("08:01 should not match")
+	}
+
+	// Both day fields restricted: either matches.
+	c, err = ParseCron("0 0 1 * 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Match(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || !c.Match(time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Error("the 1st and Sundays should both match")
+	}
+	if c.Match(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Monday the 4th should not match")
+	}
+}
+
+func TestCronPrevNext(t *testing.T) {
+	c, err := ParseCron("0 8 * * 1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sunday 2024-03-10 12:00: the last match was Friday 08:00, the next is
+	// Monday 08:00.
+	now := time.Date(2024, 3, 10, 12, 0, 30, 0, time.UTC)
+	if got, ok := c.Prev(now); !ok || !got.Equal(time.Date(2024, 3, 8, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Prev = %v, %t", got, ok)
+	}
+	if got, ok := c.Next(now); !ok || !got.Equal(time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Next = %v, %t", got, ok)
+	}
+
+	// Prev includes the current minute, Next does not.
+	at := time.Date(2024, 3, 11, 8, 0, 45, 0, time.UTC)
+	if got, _ := c.Prev(at); !got.Equal(at.Truncate(time.Minute)) {
+		t.Errorf("Prev(%v) = %v", at, got)
+	}
+	if got, _ := c.Next(at); !got.Equal(time.Date(2024, 3, 12, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("Next(%v) = %v", at, got)
+	}
+
+	// Never matches: 31 February.
+	c, err = ParseCron("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Prev(now); ok {
+		t.Error("Prev of an impossible date should fail")
+	}
+}
+
+func TestCronHalfHourZone(t *testing.T) {
+	// India is UTC+5:30: hour boundaries there fall on :30 UTC.
+	loc := time.FixedZone("IST", 5*3600+1800)
+	c, err := ParseCron("15 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, loc)
+	if got, ok := c.Prev(now); !ok || !got.Equal(time.Date(2024, 3, 10, 9, 15, 0, 0, loc)) {
+		t.Errorf("Prev = %v, %t", got, ok)
+	}
+	if got, ok := c.Next(now); !ok || !got.Equal(time.Date(2024, 3, 11, 9, 15, 0, 0, loc)) {
+		t.Errorf("Next = %v, %t", got, ok)
+	}
+}
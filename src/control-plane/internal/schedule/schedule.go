@@ -0,0 +1,465 @@
+// Package schedule switches between named policy profiles on cron-like
+// schedules, e.g. looser rate limits during business hours and tighter
+// ones at night. A profile overrides rate limits, GeoIP country policies
+// and escalation sensitivity; whatever it leaves unset keeps the value in
+// place before any profile applied. An operator can force a profile, for
+// a while or until the override is cleared.
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/geoip"
+	"go.uber.org/zap"
+)
+
+// Config names the profiles and when each one takes over.
+type Config struct {
+	Timezone string             `yaml:"timezone"` // IANA name for the schedule times ("" = local time)
+	Profiles map[string]Profile `yaml:"profiles"`
+	Entries  []Entry            `yaml:"entries"`
+}
+
+// Entry switches to Profile at every time Cron matches. The entry that
+// matched last is the active one.
+type Entry struct {
+	Cron    string `yaml:"cron" json:"cron"` // minute hour day month weekday
+	Profile string `yaml:"profile" json:"profile"`
+}
+
+// Profile is a set of policies applied while it is active. Zero values
+// leave the setting alone.
+type Profile struct {
+	// Rate limits replacing the rate_limit settings
+	SYNRatePPS  uint64 `yaml:"syn_rate_pps" json:"synRatePps,omitempty"`
+	UDPRatePPS  uint64 `yaml:"udp_rate_pps" json:"udpRatePps,omitempty"`
+	ICMPRatePPS uint64 `yaml:"icmp_rate_pps" json:"icmpRatePps,omitempty"`
+	GlobalPPS   uint64 `yaml:"global_pps" json:"globalPps,omitempty"`
+	GlobalBPS   uint64 `yaml:"global_bps" json:"globalBps,omitempty"`
+
+	// Country code → pass, drop, rate_limit or monitor, and country code →
+	// pps for rate_limit countries
+	GeoIP           map[string]string `yaml:"geoip" json:"geoip,omitempty"`
+	GeoIPRateLimits map[string]uint64 `yaml:"geoip_rate_limits" json:"geoipRateLimits,omitempty"`
+
+	// Escalation threshold divisor: 2 escalates at half the usual drop
+	// ratio, z-score and drop rate, 0.5 at twice them
+	EscalationSensitivity float64 `yaml:"escalation_sensitivity" json:"escalationSensitivity,omitempty"`
+}
+
+// Enabled reports whether any schedule entries are configured.
+func (c Config) Enabled() bool {
+	return len(c.Entries) > 0
+}
+
+// Validate checks the time zone, the profiles and that every entry has a
+// valid expression and a known profile.
+func (c Config) Validate() error {
+	if _, err := c.location(); err != nil {
+		return err
+	}
+	for name, p := range c.Profiles {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("profiles.%s: %w", name, err)
+		}
+	}
+	for i, e := range c.Entries {
+		if _, err := ParseCron(e.Cron); err != nil {
+			return fmt.Errorf("entries[%d]: %w", i, err)
+		}
+		if _, ok := c.Profiles[e.Profile]; !ok {
+			return fmt.Errorf("entries[%d]: unknown profile %q", i, e.Profile)
+		}
+	}
+	return nil
+}
+
+// UsesGeoIP reports whether any profile sets country policies.
+func (c Config) UsesGeoIP() bool {
+	for _, p := range c.Profiles {
+		if len(p.GeoIP) > 0 || len(p.GeoIPRateLimits) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Config) location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("timezone: %w", err)
+	}
+	return loc, nil
+}
+
+func (p Profile) validate() error {
+	for cc, action := range p.GeoIP {
+		if len(cc) != 2 {
+			return fmt.Errorf("geoip: invalid country code %q", cc)
+		}
+		if _, err := geoip.ParseAction(action); err != nil {
+			return fmt.Errorf("geoip[%s]: %w", cc, err)
+		}
+	}
+	for cc, pps := range p.GeoIPRateLimits {
+		if len(cc) != 2 {
+			return fmt.Errorf("geoip_rate_limits: invalid country code %q", cc)
+		}
+		if pps == 0 {
+			return fmt.Errorf("geoip_rate_limits[%s] must be positive", cc)
+		}
+	}
+	if p.EscalationSensitivity < 0 {
+		return fmt.Errorf("escalation_sensitivity must not be negative")
+	}
+	return nil
+}
+
+// ConfigMap reads and writes data plane config map entries.
+type ConfigMap interface {
+	GetConfig(key uint32) (uint64, error)
+	SetConfig(key uint32, value uint64) error
+}
+
+// GeoIP sets country policies, implemented by geoip.Manager.
+type GeoIP interface {
+	SetCountryPolicy(country string, action uint8) error
+	SetCountryRate(country string, pps uint64) error
+	GetCountryPolicy() map[string]uint8
+	GetCountryRates() map[string]uint64
+}
+
+// Escalation takes the escalation sensitivity, implemented by
+// escalation.Engine.
+type Escalation interface {
+	SetSensitivity(s float64)
+}
+
+// Status is the active profile and what comes next.
+type Status struct {
+	Active        string             `json:"active"`          // "" when no profile applies
+	Source        string             `json:"source"`          // "schedule", "override" or "" when none
+	Since         time.Time          `json:"since,omitempty"` // When the active profile was applied
+	OverrideUntil time.Time          `json:"overrideUntil,omitempty"`
+	Next          string             `json:"next,omitempty"` // Profile the schedule switches to next
+	NextAt        time.Time          `json:"nextAt,omitempty"`
+	Timezone      string             `json:"timezone"`
+	Profiles      map[string]Profile `json:"profiles"`
+	Entries       []Entry            `json:"entries"`
+}
+
+// country is the policy and rate of a country before a profile changed it.
+type country struct {
+	action uint8
+	pps    uint64
+}
+
+type entry struct {
+	cron    Cron
+	profile string
+}
+
+// Scheduler applies the profile the schedule or an override selects.
+type Scheduler struct {
+	log        *zap.Logger
+	cfg        Config
+	loc        *time.Location
+	entries    []entry
+	maps       ConfigMap
+	geo        GeoIP      // nil without GeoIP data
+	escalation Escalation // nil without an escalation engine
+
+	// now is the clock, replaced in tests.
+	now func() time.Time
+
+	mu            sync.Mutex
+	active        string
+	source        string
+	since         time.Time
+	override      string
+	overrideUntil time.Time // Zero: until cleared
+	savedConfig   map[uint32]uint64
+	savedCountry  map[string]country
+}
+
+// NewScheduler creates a scheduler for a validated configuration. geo and
+// esc may be nil.
+func NewScheduler(log *zap.Logger, cfg Config, maps ConfigMap, geo GeoIP, esc Escalation) (*Scheduler, error) {
+	loc, err := cfg.location()
+	if err != nil {
+		return nil, err
+	}
+	s := &Scheduler{
+		log:          log,
+		cfg:          cfg,
+		loc:          loc,
+		maps:         maps,
+		geo:          geo,
+		escalation:   esc,
+		now:          time.Now,
+		savedConfig:  make(map[uint32]uint64),
+		savedCountry: make(map[string]country),
+	}
+	for i, e := range cfg.Entries {
+		c, err := ParseCron(e.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("entries[%d]: %w", i, err)
+		}
+		s.entries = append(s.entries, entry{cron: c, profile: e.Profile})
+	}
+	return s, nil
+}
+
+// Run applies the scheduled profile now and then at the start of every
+// minute until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.Update()
+	for {
+		now := s.now()
+		wait := now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		s.Update()
+	}
+}
+
+// Update applies the profile that should be active now, if it changed.
+// An expired override is cleared.
+func (s *Scheduler) Update() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateLocked()
+}
+
+func (s *Scheduler) updateLocked() {
+	now := s.now()
+	if s.override != "" && !s.overrideUntil.IsZero() && !now.Before(s.overrideUntil) {
+		s.log.Info("schedule override expired", zap.String("profile", s.override))
+		s.override, s.overrideUntil = "", time.Time{}
+	}
+
+	name, source := s.scheduled(now), "schedule"
+	if s.override != "" {
+		name, source = s.override, "override"
+	}
+	if name == "" {
+		source = ""
+	}
+	if name == s.active && source == s.source {
+		return
+	}
+	if err := s.applyLocked(name); err != nil {
+		s.log.Error("failed to apply policy profile", zap.String("profile", name), zap.Error(err))
+		return
+	}
+	s.log.Info("policy profile applied",
+		zap.String("profile", name),
+		zap.String("previous", s.active),
+		zap.String("source", source),
+	)
+	if name != s.active {
+		s.since = now
+	}
+	s.active, s.source = name, source
+}
+
+// scheduled returns the profile of the entry that matched last at or
+// before now, or "" if none matched within a year.
+func (s *Scheduler) scheduled(now time.Time) string {
+	now = now.In(s.loc)
+	var (
+		name   string
+		latest time.Time
+	)
+	for _, e := range s.entries {
+		if at, ok := e.cron.Prev(now); ok && !at.Before(latest) {
+			name, latest = e.profile, at
+		}
+	}
+	return name
+}
+
+// next returns the first upcoming switch to a profile other than active.
+func (s *Scheduler) next(now time.Time, active string) (string, time.Time) {
+	now = now.In(s.loc)
+	var (
+		name  string
+		first time.Time
+	)
+	for _, e := range s.entries {
+		if e.profile == active {
+			continue
+		}
+		if at, ok := e.cron.Next(now); ok && (first.IsZero() || at.Before(first)) {
+			name, first = e.profile, at
+		}
+	}
+	return name, first
+}
+
+// SetOverride forces a profile until the given time, or until cleared if
+// until is zero.
+func (s *Scheduler) SetOverride(name string, until time.Time) error {
+	if _, ok := s.cfg.Profiles[name]; !ok {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.override, s.overrideUntil = name, until
+	s.log.Info("schedule override set", zap.String("profile", name), zap.Time("until", until))
+	s.updateLocked()
+	if s.active != name {
+		return fmt.Errorf("applying profile %q failed", name)
+	}
+	return nil
+}
+
+// ClearOverride returns to the scheduled profile.
+func (s *Scheduler) ClearOverride() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.override == "" {
+		return
+	}
+	s.log.Info("schedule override cleared", zap.String("profile", s.override))
+	s.override, s.overrideUntil = "", time.Time{}
+	s.updateLocked()
+}
+
+// Status returns the active profile, the override and the next switch.
+func (s *Scheduler) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := Status{
+		Active:   s.active,
+		Source:   s.source,
+		Since:    s.since,
+		Timezone: s.loc.String(),
+		Profiles: s.cfg.Profiles,
+		Entries:  s.cfg.Entries,
+	}
+	if s.override != "" {
+		st.OverrideUntil = s.overrideUntil
+	} else {
+		st.Next, st.NextAt = s.next(s.now(), s.active)
+	}
+	return st
+}
+
+// applyLocked applies profile name ("" for none) and restores the
+// settings the previous profile changed but this one does not.
+func (s *Scheduler) applyLocked(name string) error {
+	p := s.cfg.Profiles[name]
+
+	values := map[uint32]uint64{}
+	for key, v := range map[uint32]uint64{
+		bpf.CfgSYNRatePPS:     p.SYNRatePPS,
+		bpf.CfgUDPRatePPS:     p.UDPRatePPS,
+		bpf.CfgICMPRatePPS:    p.ICMPRatePPS,
+		bpf.CfgGlobalPPSLimit: p.GlobalPPS,
+		bpf.CfgGlobalBPSLimit: p.GlobalBPS,
+	} {
+		if v > 0 {
+			values[key] = v
+		}
+	}
+	for key, v := range s.savedConfig {
+		if _, ok := values[key]; ok {
+			continue
+		}
+		if err := s.maps.SetConfig(key, v); err != nil {
+			return err
+		}
+		delete(s.savedConfig, key)
+	}
+	for key, v := range values {
+		if _, ok := s.savedConfig[key]; !ok {
+			cur, err := s.maps.GetConfig(key)
+			if err != nil {
+				return err
+			}
+			s.savedConfig[key] = cur
+		}
+		if err := s.maps.SetConfig(key, v); err != nil {
+			return err
+		}
+	}
+
+	if err := s.applyCountriesLocked(p); err != nil {
+		return err
+	}
+
+	if s.escalation != nil {
+		sensitivity := p.EscalationSensitivity
+		if sensitivity == 0 {
+			sensitivity = 1
+		}
+		s.escalation.SetSensitivity(sensitivity)
+	}
+	return nil
+}
+
+// applyCountriesLocked sets the country policies of p and restores the
+// countries only the previous profile set.
+func (s *Scheduler) applyCountriesLocked(p Profile) error {
+	if s.geo == nil {
+		if len(p.GeoIP) > 0 || len(p.GeoIPRateLimits) > 0 {
+			s.log.Warn("policy profile sets country policies but GeoIP data is not loaded")
+		}
+		return nil
+	}
+
+	want := make(map[string]country)
+	for cc, name := range p.GeoIP {
+		action, _ := geoip.ParseAction(name)
+		want[strings.ToUpper(cc)] = country{action: action}
+	}
+	for cc, pps := range p.GeoIPRateLimits {
+		cc = strings.ToUpper(cc)
+		c, ok := want[cc]
+		if !ok {
+			c.action = geoip.ActionRateLimit
+		}
+		c.pps = pps
+		want[cc] = c
+	}
+
+	for cc, prev := range s.savedCountry {
+		if _, ok := want[cc]; ok {
+			continue
+		}
+		if err := s.setCountry(cc, prev); err != nil {
+			return err
+		}
+		delete(s.savedCountry, cc)
+	}
+	policies, rates := s.geo.GetCountryPolicy(), s.geo.GetCountryRates()
+	for cc, c := range want {
+		if _, ok := s.savedCountry[cc]; !ok {
+			s.savedCountry[cc] = country{action: policies[cc], pps: rates[cc]}
+		}
+		if err := s.setCountry(cc, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) setCountry(cc string, c country) error {
+	if err := s.geo.SetCountryRate(cc, c.pps); err != nil {
+		return err
+	}
+	return s.geo.SetCountryPolicy(cc, c.action)
+}
@@ -0,0 +1,202 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/geoip"
+	"go.uber.org/zap"
+)
+
+type fakeConfigMap map[uint32]uint64
+
+func (f fakeConfigMap) GetConfig(key uint32) (uint64, error) { return f[key], nil }
+
+func (f fakeConfigMap) SetConfig(key uint32, value uint64) error {
+	f[key] = value
+	return nil
+}
+
+type fakeGeoIP struct {
+	policies map[string]uint8
+	rates    map[string]uint64
+}
+
+func (f *fakeGeoIP) SetCountryPolicy(cc string, action uint8) error {
+	f.policies[cc] = action
+	return nil
+}
+
+func (f *fakeGeoIP) SetCountryRate(cc string, pps uint64) error {
+	if pps == 0 {
+		delete(f.rates, cc)
+	} else {
+		f.rates[cc] = pps
+	}
+	return nil
+}
+
+func (f *fakeGeoIP) GetCountryPolicy() map[string]uint8 {
+	out := map[string]uint8{}
+	for k, v := range f.policies {
+		out[k] = v
+	}
+	return out
+}
+
+func (f *fakeGeoIP) GetCountryRates() map[string]uint64 {
+	out := map[string]uint64{}
+	for k, v := range f.rates {
+		out[k] = v
+	}
+	return out
+}
+
+type fakeEscalation struct{ sensitivity float64 }
+
+func (f *fakeEscalation) SetSensitivity(s float64) { f.sensitivity = s }
+
+func testConfig() Config {
+	return Config{
+		Timezone: "UTC",
+		Profiles: map[string]Profile{
+			"business": {SYNRatePPS: 5000, GlobalPPS: 2000000},
+			"night": {
+				SYNRatePPS:            500,
+				GeoIP:                 map[string]string{"cn": "drop"},
+				GeoIPRateLimits:       map[string]uint64{"RU": 1000},
+				EscalationSensitivity: 2,
+			},
+		},
+		Entries: []Entry{
+			{Cron: "0 8 * * 1-5", Profile: "business"},
+			{Cron: "0 20 * * *", Profile: "night"},
+		},
+	}
+}
+
+func newTestScheduler(t *testing.T, now *time.Time) (*Scheduler, fakeConfigMap, *fakeGeoIP, *fakeEscalation) {
+	t.Helper()
+	maps := fakeConfigMap{bpf.CfgSYNRatePPS: 1000, bpf.CfgUDPRatePPS: 3000}
+	geo := &fakeGeoIP{policies: map[string]uint8{"RU": geoip.ActionMonitor}, rates: map[string]uint64{}}
+	esc := &fakeEscalation{}
+	s, err := NewScheduler(zap.NewNop(), testConfig(), maps, geo, esc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return *now }
+	return s, maps, geo, esc
+}
+
+func TestSchedulerSwitchesProfiles(t *testing.T) {
+	// Monday 2024-03-04 09:00 UTC: business hours.
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	s, maps, geo, esc := newTestScheduler(t, &now)
+
+	s.Update()
+	if st := s.Status(); st.Active != "business" || st.Source != "schedule" {
+		t.Fatalf("active = %q from %q, want business from schedule", st.Active, st.Source)
+	}
+	if maps[bpf.CfgSYNRatePPS] != 5000 || maps[bpf.CfgGlobalPPSLimit] != 2000000 || maps[bpf.CfgUDPRatePPS] != 3000 {
+		t.Errorf("business rates not applied: %v", maps)
+	}
+	if st := s.Status(); st.Next != "night" || !st.NextAt.Equal(time.Date(2024, 3, 4, 20, 0, 0, 0, time.UTC)) {
+		t.Errorf("next = %q at %v", st.Next, st.NextAt)
+	}
+
+	now = time.Date(2024, 3, 4, 20, 0, 0, 0, time.UTC)
+	s.Update()
+	if st := s.Status(); st.Active != "night" || !st.Since.Equal(now) {
+		t.Fatalf("active = %q since %v, want night", st.Active, st.Since)
+	}
+	// The global limit the night profile leaves alone is restored.
+	if maps[bpf.CfgSYNRatePPS] != 500 || maps[bpf.CfgGlobalPPSLimit] != 0 {
+		t.Errorf("night rates not applied: %v", maps)
+	}
+	if geo.policies["CN"] != geoip.ActionDrop || geo.policies["RU"] != geoip.ActionRateLimit || geo.rates["RU"] != 1000 {
+		t.Errorf("night country policies not applied: %v %v", geo.policies, geo.rates)
+	}
+	if esc.sensitivity != 2 {
+		t.Errorf("sensitivity = %v, want 2", esc.sensitivity)
+	}
+
+	// Tuesday morning: back to business, country policies restored.
+	now = time.Date(2024, 3, 5, 8, 30, 0, 0, time.UTC)
+	s.Update()
+	if s.Status().Active != "business" {
+		t.Fatalf("active = %q, want business", s.Status().Active)
+	}
+	if geo.policies["CN"] != geoip.ActionPass || geo.policies["RU"] != geoip.ActionMonitor || len(geo.rates) != 0 {
+		t.Errorf("country policies not restored: %v %v", geo.policies, geo.rates)
+	}
+	if esc.sensitivity != 1 {
+		t.Errorf("sensitivity = %v, want 1", esc.sensitivity)
+	}
+}
+
+func TestSchedulerOverride(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	s, maps, _, _ := newTestScheduler(t, &now)
+	s.Update()
+
+	if err := s.SetOverride("missing", time.Time{}); err == nil {
+		t.Error("overriding with an unknown profile should fail")
+	}
+	if err := s.SetOverride("night", now.Add(time.Hour)); err != nil {
+		t.Fatalf("SetOverride: %v", err)
+	}
+	st := s.Status()
+	if st.Active != "night" || st.Source != "override" || st.Next != "" {
+		t.Fatalf("status = %+v, want night from override", st)
+	}
+	if maps[bpf.CfgSYNRatePPS] != 500 {
+		t.Errorf("override not applied: %v", maps)
+	}
+
+	// The override expires back to the schedule.
+	now = now.Add(time.Hour)
+	s.Update()
+	if st := s.Status(); st.Active != "business" || st.Source != "schedule" {
+		t.Fatalf("after expiry active = %q from %q", st.Active, st.Source)
+	}
+
+	if err := s.SetOverride("night", time.Time{}); err != nil {
+		t.Fatalf("SetOverride: %v", err)
+	}
+	now = now.Add(48 * time.Hour)
+	s.Update()
+	if s.Status().Active != "night" {
+		t.Error("an override without an end should hold across schedule switches")
+	}
+	s.ClearOverride()
+	if st := s.Status(); st.Source != "schedule" {
+		t.Errorf("after ClearOverride source = %q", st.Source)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := testConfig().Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+	}{
+		{"bad timezone", func(c *Config) { c.Timezone = "Mars/Olympus" }},
+		{"bad cron", func(c *Config) { c.Entries[0].Cron = "0 25 * * *" }},
+		{"unknown profile", func(c *Config) { c.Entries[0].Profile = "weekend" }},
+		{"bad action", func(c *Config) { c.Profiles["x"] = Profile{GeoIP: map[string]string{"CN": "block"}} }},
+		{"bad country", func(c *Config) { c.Profiles["x"] = Profile{GeoIPRateLimits: map[string]uint64{"CHN": 1}} }},
+		{"zero country rate", func(c *Config) { c.Profiles["x"] = Profile{GeoIPRateLimits: map[string]uint64{"CN": 0}} }},
+		{"negative sensitivity", func(c *Config) { c.Profiles["x"] = Profile{EscalationSensitivity: -1} }},
+	}
+	for _, tt := range tests {
+		cfg := testConfig()
+		tt.modify(&cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: Validate should fail", tt.name)
+		}
+	}
+}
@@ -0,0 +1,529 @@
+// Package siglearn derives attack signatures from sampled packets.
+//
+// Packets sampled by the capture subsystem are grouped into clusters by
+// protocol, TCP flags, destination port and the first four payload bytes
+// (the payload hash the fingerprint stage compares). At the end of every
+// window, clusters that carry a large enough share of the sampled traffic
+// become signature proposals with the observed source port and length
+// ranges. Proposals are installed into attack_sig_map when approved through
+// the API, or immediately in auto-apply mode if they are specific enough to
+// be safe without review.
+package siglearn
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/capture"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultWindowSec    = 30
+	defaultMinPackets   = 100
+	defaultMinSharePct  = 20
+	defaultMaxProposals = 32
+
+	// Only the first MAX_SIG_CHECK signatures in fingerprint.h are
+	// evaluated per packet; installing beyond that has no effect.
+	maxActiveSignatures = 8
+
+	// Distinct sources tracked per cluster.
+	maxClusterSources = 1024
+)
+
+// Proposal states.
+const (
+	StatusPending  = "pending"
+	StatusApplied  = "applied"
+	StatusRejected = "rejected"
+)
+
+// ErrNotFound is returned for unknown proposal IDs.
+var ErrNotFound = errors.New("proposal not found")
+
+// Config controls signature learning.
+type Config struct {
+	Enabled      bool    `yaml:"enabled"`
+	WindowSec    uint64  `yaml:"window_sec"`    // Clustering window
+	MinPackets   uint64  `yaml:"min_packets"`   // Samples a cluster needs per window
+	MinSharePct  float64 `yaml:"min_share_pct"` // Share of the window's samples a cluster needs
+	MaxProposals int     `yaml:"max_proposals"` // Oldest pending/rejected proposals beyond this are dropped
+	AutoApply    bool    `yaml:"auto_apply"`    // Install specific proposals without approval
+}
+
+// Validate checks the learner configuration.
+func (c Config) Validate() error {
+	if c.MinSharePct < 0 || c.MinSharePct > 100 {
+		return fmt.Errorf("min_share_pct must be between 0 and 100")
+	}
+	if c.MaxProposals < 0 {
+		return fmt.Errorf("max_proposals must not be negative")
+	}
+	return nil
+}
+
+// Proposal is a learned signature. Ports are in host byte order.
+type Proposal struct {
+	ID          uint32    `json:"id"`
+	Status      string    `json:"status"`
+	Index       *uint32   `json:"index,omitempty"` // attack_sig_map slot once applied
+	Protocol    uint8     `json:"protocol"`
+	FlagsMask   uint8     `json:"flagsMask"`
+	FlagsMatch  uint8     `json:"flagsMatch"`
+	SrcPortMin  uint16    `json:"srcPortMin"`
+	SrcPortMax  uint16    `json:"srcPortMax"`
+	DstPortMin  uint16    `json:"dstPortMin"`
+	DstPortMax  uint16    `json:"dstPortMax"`
+	PktLenMin   uint16    `json:"pktLenMin"`
+	PktLenMax   uint16    `json:"pktLenMax"`
+	PayloadHash uint32    `json:"payloadHash"`
+	Packets     uint64    `json:"packets"`  // Samples in the most recent matching window
+	SharePct    float64   `json:"sharePct"` // Their share of all samples in that window
+	Sources     int       `json:"sources"`  // Distinct source addresses (capped)
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+
+	key clusterKey
+}
+
+// Signature returns the proposal as a data-plane signature.
+func (p Proposal) Signature() bpf.AttackSig {
+	return bpf.AttackSig{
+		Protocol:    p.Protocol,
+		FlagsMask:   p.FlagsMask,
+		FlagsMatch:  p.FlagsMatch,
+		SrcPortMin:  htons(p.SrcPortMin),
+		SrcPortMax:  htons(p.SrcPortMax),
+		DstPortMin:  htons(p.DstPortMin),
+		DstPortMax:  htons(p.DstPortMax),
+		PktLenMin:   p.PktLenMin,
+		PktLenMax:   p.PktLenMax,
+		PayloadHash: p.PayloadHash,
+	}
+}
+
+// specific reports whether the proposal is narrow enough to install without
+// review: it must match on payload bytes or on a fixed source port (as in
+// reflection attacks). Flag/port-only signatures such as "SYN to 443"
+// would also drop legitimate clients.
+func (p Proposal) specific() bool {
+	return p.PayloadHash != 0 || (p.SrcPortMin != 0 && p.SrcPortMin == p.SrcPortMax)
+}
+
+// signatureStore is the subset of bpf.MapManager used to install
+// signatures.
+type signatureStore interface {
+	GetAttackSignatureCount() (uint32, error)
+	GetAttackSignature(index uint32) (bpf.AttackSig, error)
+	SetAttackSignature(index uint32, sig bpf.AttackSig) error
+	SetAttackSignatureCount(count uint32) error
+}
+
+// clusterKey groups packets the fingerprint stage cannot tell apart
+// except by length and source port.
+type clusterKey struct {
+	proto       uint8
+	flags       uint8
+	dstPort     uint16
+	payloadHash uint32
+}
+
+type cluster struct {
+	packets    uint64
+	lenMin     uint16
+	lenMax     uint16
+	srcPortMin uint16
+	srcPortMax uint16
+	sources    map[uint32]struct{}
+}
+
+// Learner clusters sampled packets and manages signature proposals.
+type Learner struct {
+	log    *zap.Logger
+	cfg    Config
+	store  signatureStore
+	window time.Duration
+
+	mu        sync.Mutex
+	clusters  map[clusterKey]*cluster
+	total     uint64
+	proposals map[uint32]*Proposal
+	nextID    uint32
+}
+
+// NewLearner creates a signature learner that installs into store.
+func NewLearner(log *zap.Logger, cfg Config, store signatureStore) *Learner {
+	if cfg.WindowSec == 0 {
+		cfg.WindowSec = defaultWindowSec
+	}
+	if cfg.MinPackets == 0 {
+		cfg.MinPackets = defaultMinPackets
+	}
+	if cfg.MinSharePct == 0 {
+		cfg.MinSharePct = defaultMinSharePct
+	}
+	if cfg.MaxProposals == 0 {
+		cfg.MaxProposals = defaultMaxProposals
+	}
+
+	return &Learner{
+		log:       log,
+		cfg:       cfg,
+		store:     store,
+		window:    time.Duration(cfg.WindowSec) * time.Second,
+		clusters:  make(map[clusterKey]*cluster),
+		proposals: make(map[uint32]*Proposal),
+		nextID:    1,
+	}
+}
+
+// Observe adds a sampled packet to the current window. Non-IPv4 and
+// unparseable packets are ignored.
+func (l *Learner) Observe(s capture.Sample) {
+	h, ok := parsePacket(s.Data)
+	if !ok {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := clusterKey{proto: h.proto, flags: h.flags, dstPort: h.dstPort, payloadHash: h.payloadHash}
+	c := l.clusters[key]
+	if c == nil {
+		c = &cluster{
+			lenMin: h.length, lenMax: h.length,
+			srcPortMin: h.srcPort, srcPortMax: h.srcPort,
+			sources: make(map[uint32]struct{}),
+		}
+		l.clusters[key] = c
+	}
+	c.packets++
+	c.lenMin = min(c.lenMin, h.length)
+	c.lenMax = max(c.lenMax, h.length)
+	c.srcPortMin = min(c.srcPortMin, h.srcPort)
+	c.srcPortMax = max(c.srcPortMax, h.srcPort)
+	if len(c.sources) < maxClusterSources {
+		c.sources[h.srcIP] = struct{}{}
+	}
+	l.total++
+}
+
+// Run closes a clustering window every Config.WindowSec until ctx is
+// cancelled.
+func (l *Learner) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.window)
+	defer ticker.Stop()
+
+	l.log.Info("signature learner started",
+		zap.Duration("window", l.window),
+		zap.Bool("auto_apply", l.cfg.AutoApply),
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			l.log.Info("signature learner stopped")
+			return
+		case <-ticker.C:
+			l.analyze(time.Now())
+		}
+	}
+}
+
+// analyze turns dominant clusters of the closing window into proposals and
+// starts a new window.
+func (l *Learner) analyze(now time.Time) {
+	l.mu.Lock()
+	clusters, total := l.clusters, l.total
+	l.clusters = make(map[clusterKey]*cluster)
+	l.total = 0
+
+	var autoApply []uint32
+	for key, c := range clusters {
+		share := float64(c.packets) * 100 / float64(total)
+		if c.packets < l.cfg.MinPackets || share < l.cfg.MinSharePct {
+			continue
+		}
+		p := l.proposeLocked(key, c, share, now)
+		if p != nil && p.Status == StatusPending && l.cfg.AutoApply && p.specific() {
+			autoApply = append(autoApply, p.ID)
+		}
+	}
+	l.pruneLocked()
+	l.mu.Unlock()
+
+	for _, id := range autoApply {
+		if _, err := l.Approve(id); err != nil {
+			l.log.Warn("auto-applying learned signature failed", zap.Uint32("id", id), zap.Error(err))
+		}
+	}
+}
+
+// proposeLocked records a proposal for cluster c, refreshing an existing
+// proposal for the same signature.
+func (l *Learner) proposeLocked(key clusterKey, c *cluster, share float64, now time.Time) *Proposal {
+	srcPort := uint16(0)
+	if c.srcPortMin == c.srcPortMax {
+		srcPort = c.srcPortMin
+	}
+
+	if p := l.proposals[l.byKeyLocked(key)]; p != nil {
+		// Pending proposals widen to cover every window they were seen
+		// in; installed signatures stay as they are.
+		if p.Status == StatusPending {
+			p.PktLenMin = min(p.PktLenMin, c.lenMin)
+			p.PktLenMax = max(p.PktLenMax, c.lenMax)
+			if p.SrcPortMin != srcPort {
+				p.SrcPortMin, p.SrcPortMax = 0, 0
+			}
+		}
+		p.Packets = c.packets
+		p.SharePct = share
+		p.Sources = len(c.sources)
+		p.LastSeen = now
+		return p
+	}
+
+	p := &Proposal{
+		key:         key,
+		Protocol:    key.proto,
+		DstPortMin:  key.dstPort,
+		DstPortMax:  key.dstPort,
+		PktLenMin:   c.lenMin,
+		PktLenMax:   c.lenMax,
+		PayloadHash: key.payloadHash,
+	}
+	if key.proto == protoTCP {
+		p.FlagsMask, p.FlagsMatch = 0xFF, key.flags
+	}
+	// A single source port marks reflection traffic; otherwise any port.
+	p.SrcPortMin, p.SrcPortMax = srcPort, srcPort
+
+	p.ID = l.nextID
+	l.nextID++
+	p.Status = StatusPending
+	p.Packets = c.packets
+	p.SharePct = share
+	p.Sources = len(c.sources)
+	p.FirstSeen, p.LastSeen = now, now
+	l.proposals[p.ID] = p
+
+	l.log.Info("attack signature proposed",
+		zap.Uint32("id", p.ID),
+		zap.Uint8("protocol", p.Protocol),
+		zap.Uint16("dst_port", p.DstPortMin),
+		zap.Uint32("payload_hash", p.PayloadHash),
+		zap.Float64("share_pct", share),
+	)
+	return p
+}
+
+// byKeyLocked returns the ID of the proposal learned from cluster key, or 0.
+func (l *Learner) byKeyLocked(key clusterKey) uint32 {
+	for id, p := range l.proposals {
+		if p.key == key {
+			return id
+		}
+	}
+	return 0
+}
+
+// pruneLocked drops the least recently seen proposals that were not applied
+// once there are more than MaxProposals.
+func (l *Learner) pruneLocked() {
+	if len(l.proposals) <= l.cfg.MaxProposals {
+		return
+	}
+	var stale []*Proposal
+	for _, p := range l.proposals {
+		if p.Status != StatusApplied {
+			stale = append(stale, p)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].LastSeen.Before(stale[j].LastSeen) })
+	for _, p := range stale {
+		if len(l.proposals) <= l.cfg.MaxProposals {
+			break
+		}
+		delete(l.proposals, p.ID)
+	}
+}
+
+// Proposals returns all proposals, newest first.
+func (l *Learner) Proposals() []Proposal {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]Proposal, 0, len(l.proposals))
+	for _, p := range l.proposals {
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID > out[j].ID })
+	return out
+}
+
+// Approve installs a pending proposal into the next free attack_sig_map
+// slot.
+func (l *Learner) Approve(id uint32) (Proposal, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	p, ok := l.proposals[id]
+	if !ok {
+		return Proposal{}, ErrNotFound
+	}
+	if p.Status == StatusApplied {
+		return *p, nil
+	}
+
+	count, err := l.store.GetAttackSignatureCount()
+	if err != nil {
+		return Proposal{}, err
+	}
+	sig := p.Signature()
+	for idx := uint32(0); idx < count; idx++ {
+		if installed, err := l.store.GetAttackSignature(idx); err == nil && installed == sig {
+			return l.markAppliedLocked(p, idx), nil
+		}
+	}
+	if count >= maxActiveSignatures {
+		return Proposal{}, fmt.Errorf("all %d signature slots are in use", maxActiveSignatures)
+	}
+
+	if err := l.store.SetAttackSignature(count, sig); err != nil {
+		return Proposal{}, fmt.Errorf("installing signature: %w", err)
+	}
+	if err := l.store.SetAttackSignatureCount(count + 1); err != nil {
+		return Proposal{}, fmt.Errorf("updating signature count: %w", err)
+	}
+	return l.markAppliedLocked(p, count), nil
+}
+
+func (l *Learner) markAppliedLocked(p *Proposal, idx uint32) Proposal {
+	p.Status = StatusApplied
+	p.Index = &idx
+	l.log.Warn("learned attack signature installed",
+		zap.Uint32("id", p.ID), zap.Uint32("index", idx))
+	return *p
+}
+
+// Reject marks a pending proposal as rejected so it is not proposed again.
+// Installed signatures are removed through the signatures API instead.
+func (l *Learner) Reject(id uint32) (Proposal, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	p, ok := l.proposals[id]
+	if !ok {
+		return Proposal{}, ErrNotFound
+	}
+	if p.Status == StatusApplied {
+		return Proposal{}, fmt.Errorf("proposal %d is already installed", id)
+	}
+	p.Status = StatusRejected
+	l.log.Info("attack signature proposal rejected", zap.Uint32("id", id))
+	return *p, nil
+}
+
+// --- Packet parsing ---
+
+const (
+	protoICMP = 1
+	protoTCP  = 6
+	protoUDP  = 17
+)
+
+// headers are the fields the fingerprint stage matches on.
+type headers struct {
+	proto       uint8
+	flags       uint8
+	srcIP       uint32
+	srcPort     uint16
+	dstPort     uint16
+	length      uint16 // IPv4 total length
+	payloadHash uint32
+}
+
+// parsePacket extracts fingerprint fields from an Ethernet frame the same
+// way parser.h does: up to two VLAN tags, IPv4 only, ICMP type as the
+// destination port, and the first four payload bytes as the hash.
+func parsePacket(b []byte) (headers, bool) {
+	var h headers
+	if len(b) < 14 {
+		return h, false
+	}
+	off := 12
+	ethProto := binary.BigEndian.Uint16(b[off:])
+	off += 2
+	for i := 0; i < 2 && (ethProto == 0x8100 || ethProto == 0x88A8); i++ {
+		if len(b) < off+4 {
+			return h, false
+		}
+		ethProto = binary.BigEndian.Uint16(b[off+2:])
+		off += 4
+	}
+	if ethProto != 0x0800 || len(b) < off+20 {
+		return h, false
+	}
+
+	ip := b[off:]
+	ihl := int(ip[0]&0x0F) * 4
+	if ihl < 20 || len(ip) < ihl {
+		return h, false
+	}
+	h.proto = ip[9]
+	h.length = binary.BigEndian.Uint16(ip[2:])
+	h.srcIP = binary.BigEndian.Uint32(ip[12:])
+	if binary.BigEndian.Uint16(ip[6:])&0x1FFF != 0 {
+		// Non-first fragment: no L4 header.
+		return h, true
+	}
+
+	l4 := ip[ihl:]
+	var payload []byte
+	switch h.proto {
+	case protoTCP:
+		if len(l4) < 20 {
+			return h, false
+		}
+		h.srcPort = binary.BigEndian.Uint16(l4[0:])
+		h.dstPort = binary.BigEndian.Uint16(l4[2:])
+		h.flags = l4[13]
+		doff := int(l4[12]>>4) * 4
+		if doff < 20 {
+			return h, false
+		}
+		if len(l4) >= doff {
+			payload = l4[doff:]
+		}
+	case protoUDP:
+		if len(l4) < 8 {
+			return h, false
+		}
+		h.srcPort = binary.BigEndian.Uint16(l4[0:])
+		h.dstPort = binary.BigEndian.Uint16(l4[2:])
+		payload = l4[8:]
+	case protoICMP:
+		if len(l4) < 8 {
+			return h, false
+		}
+		h.dstPort = uint16(l4[0])
+	}
+	if len(payload) >= 4 {
+		// The BPF program loads these bytes as a host-order __u32.
+		h.payloadHash = binary.NativeEndian.Uint32(payload)
+	}
+	return h, true
+}
+
+func htons(v uint16) uint16 {
+	return (v >> 8) | (v << 8)
+}
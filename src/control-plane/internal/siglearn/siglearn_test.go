@@ -0,0 +1,233 @@
+package siglearn
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/capture"
+	"go.uber.org/zap"
+)
+
+// fakeStore is an in-memory attack_sig_map.
+type fakeStore struct {
+	sigs []bpf.AttackSig
+}
+
+func (f *fakeStore) GetAttackSignatureCount() (uint32, error) { return uint32(len(f.sigs)), nil }
+
+func (f *fakeStore) GetAttackSignature(index uint32) (bpf.AttackSig, error) {
+	return f.sigs[index], nil
+}
+
+func (f *fakeStore) SetAttackSignature(index uint32, sig bpf.AttackSig) error {
+	if int(index) == len(f.sigs) {
+		f.sigs = append(f.sigs, sig)
+	} else {
+		f.sigs[index] = sig
+	}
+	return nil
+}
+
+func (f *fakeStore) SetAttackSignatureCount(count uint32) error {
+	f.sigs = f.sigs[:count]
+	return nil
+}
+
+// udpFrame builds an Ethernet/IPv4/UDP frame with the given payload.
+func udpFrame(src uint32, srcPort, dstPort uint16, payload []byte) []byte {
+	b := make([]byte, 14+20+8+len(payload))
+	binary.BigEndian.PutUint16(b[12:], 0x0800)
+	ip := b[14:]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+8+len(payload)))
+	ip[9] = protoUDP
+	binary.BigEndian.PutUint32(ip[12:], src)
+	udp := ip[20:]
+	binary.BigEndian.PutUint16(udp[0:], srcPort)
+	binary.BigEndian.PutUint16(udp[2:], dstPort)
+	copy(udp[8:], payload)
+	return b
+}
+
+// tcpFrame builds a VLAN-tagged Ethernet/IPv4/TCP frame without payload.
+func tcpFrame(src uint32, srcPort, dstPort uint16, flags uint8) []byte {
+	b := make([]byte, 18+20+20)
+	binary.BigEndian.PutUint16(b[12:], 0x8100)
+	binary.BigEndian.PutUint16(b[16:], 0x0800)
+	ip := b[18:]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], 40)
+	ip[9] = protoTCP
+	binary.BigEndian.PutUint32(ip[12:], src)
+	tcp := ip[20:]
+	binary.BigEndian.PutUint16(tcp[0:], srcPort)
+	binary.BigEndian.PutUint16(tcp[2:], dstPort)
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	return b
+}
+
+func TestParsePacket(t *testing.T) {
+	h, ok := parsePacket(udpFrame(0x0a000001, 53, 4444, []byte{1, 2, 3, 4, 5}))
+	if !ok {
+		t.Fatal("UDP frame not parsed")
+	}
+	if h.proto != protoUDP || h.srcPort != 53 || h.dstPort != 4444 || h.length != 33 {
+		t.Errorf("unexpected headers: %+v", h)
+	}
+	if h.payloadHash != binary.NativeEndian.Uint32([]byte{1, 2, 3, 4}) {
+		t.Errorf("payload hash = %#x", h.payloadHash)
+	}
+
+	h, ok = parsePacket(tcpFrame(1, 1234, 443, 0x02))
+	if !ok || h.proto != protoTCP || h.flags != 0x02 || h.dstPort != 443 || h.payloadHash != 0 {
+		t.Errorf("VLAN TCP frame: %+v, %v", h, ok)
+	}
+
+	if _, ok := parsePacket(udpFrame(1, 1, 1, nil)[:30]); ok {
+		t.Error("truncated frame should not parse")
+	}
+}
+
+func newTestLearner(cfg Config) (*Learner, *fakeStore) {
+	store := &fakeStore{}
+	return NewLearner(zap.NewNop(), cfg, store), store
+}
+
+func observe(l *Learner, n int, frame func(i int) []byte) {
+	for i := 0; i < n; i++ {
+		l.Observe(capture.Sample{Data: frame(i)})
+	}
+}
+
+func TestProposeDominantCluster(t *testing.T) {
+	l, _ := newTestLearner(Config{MinPackets: 10, MinSharePct: 30})
+	payload := []byte("\x17\x00\x03\x2a")
+
+	// Reflection flood: fixed source port and payload, many sources.
+	observe(l, 80, func(i int) []byte {
+		return udpFrame(uint32(i), 123, uint16(1000+i), payload)
+	})
+	// Background traffic spread over many clusters.
+	observe(l, 20, func(i int) []byte {
+		return tcpFrame(uint32(i), uint16(40000+i), uint16(i), 0x10)
+	})
+	l.analyze(time.Now())
+
+	props := l.Proposals()
+	if len(props) != 0 {
+		// The flood varies its destination port, so each port is its own
+		// cluster and none dominates.
+		t.Fatalf("unexpected proposals: %+v", props)
+	}
+
+	observe(l, 80, func(i int) []byte { return udpFrame(uint32(i), 123, 80, payload) })
+	observe(l, 20, func(i int) []byte { return tcpFrame(uint32(i), 40000, 443, 0x10) })
+	l.analyze(time.Now())
+
+	props = l.Proposals()
+	if len(props) != 1 {
+		t.Fatalf("got %d proposals, want 1: %+v", len(props), props)
+	}
+	p := props[0]
+	if p.Status != StatusPending || p.Protocol != protoUDP || p.SrcPortMin != 123 || p.SrcPortMax != 123 ||
+		p.DstPortMin != 80 || p.PktLenMin != 32 || p.PktLenMax != 32 || p.Sources != 80 || p.SharePct != 80 {
+		t.Errorf("unexpected proposal: %+v", p)
+	}
+	if p.PayloadHash != binary.NativeEndian.Uint32(payload) {
+		t.Errorf("payload hash = %#x", p.PayloadHash)
+	}
+
+	// The same cluster in the next window refreshes the proposal.
+	observe(l, 50, func(i int) []byte { return udpFrame(uint32(i), uint16(i), 80, append(payload, 0)) })
+	l.analyze(time.Now())
+	props = l.Proposals()
+	if len(props) != 1 || props[0].Packets != 50 || props[0].PktLenMax != 33 || props[0].SrcPortMin != 0 {
+		t.Errorf("proposal not refreshed and widened: %+v", props)
+	}
+}
+
+func TestApproveReject(t *testing.T) {
+	l, store := newTestLearner(Config{MinPackets: 5})
+	observe(l, 10, func(i int) []byte { return tcpFrame(uint32(i), uint16(i), 443, 0x02) })
+	observe(l, 10, func(i int) []byte { return udpFrame(uint32(i), 19, 80, []byte("chargen")) })
+	l.analyze(time.Now())
+
+	props := l.Proposals()
+	if len(props) != 2 {
+		t.Fatalf("got %d proposals, want 2", len(props))
+	}
+
+	var syn, chargen Proposal
+	for _, p := range props {
+		if p.Protocol == protoTCP {
+			syn = p
+		} else {
+			chargen = p
+		}
+	}
+	if syn.FlagsMask != 0xFF || syn.FlagsMatch != 0x02 || syn.specific() {
+		t.Errorf("SYN proposal: %+v", syn)
+	}
+
+	applied, err := l.Approve(chargen.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied.Status != StatusApplied || applied.Index == nil || *applied.Index != 0 {
+		t.Errorf("approved proposal: %+v", applied)
+	}
+	if len(store.sigs) != 1 || store.sigs[0] != chargen.Signature() {
+		t.Errorf("installed signatures: %+v", store.sigs)
+	}
+	if got := ntohs(store.sigs[0].SrcPortMin); got != 19 {
+		t.Errorf("installed src port = %d, want 19", got)
+	}
+	if _, err := l.Reject(chargen.ID); err == nil {
+		t.Error("rejecting an installed proposal should fail")
+	}
+
+	if p, err := l.Reject(syn.ID); err != nil || p.Status != StatusRejected {
+		t.Errorf("Reject: %+v, %v", p, err)
+	}
+	if _, err := l.Approve(99); err != ErrNotFound {
+		t.Errorf("Approve(99) err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAutoApplyOnlySpecific(t *testing.T) {
+	l, store := newTestLearner(Config{MinPackets: 5, AutoApply: true})
+	observe(l, 10, func(i int) []byte { return tcpFrame(uint32(i), uint16(i), 443, 0x02) })
+	observe(l, 10, func(i int) []byte { return udpFrame(uint32(i), 1900, 80, []byte("HTTP/1.1")) })
+	l.analyze(time.Now())
+
+	if len(store.sigs) != 1 || store.sigs[0].Protocol != protoUDP {
+		t.Errorf("auto-applied signatures: %+v", store.sigs)
+	}
+	for _, p := range l.Proposals() {
+		want := StatusPending
+		if p.Protocol == protoUDP {
+			want = StatusApplied
+		}
+		if p.Status != want {
+			t.Errorf("proposal %d (proto %d) status %s, want %s", p.ID, p.Protocol, p.Status, want)
+		}
+	}
+}
+
+func TestApproveSlotLimit(t *testing.T) {
+	l, store := newTestLearner(Config{MinPackets: 1})
+	for i := 0; i < maxActiveSignatures; i++ {
+		store.sigs = append(store.sigs, bpf.AttackSig{Protocol: 99, PktLenMin: uint16(i)})
+	}
+	observe(l, 5, func(i int) []byte { return udpFrame(uint32(i), 53, 80, []byte("abcd")) })
+	l.analyze(time.Now())
+
+	if _, err := l.Approve(l.Proposals()[0].ID); err == nil {
+		t.Error("Approve should fail when all slots are in use")
+	}
+}
+
+func ntohs(v uint16) uint16 { return htons(v) }
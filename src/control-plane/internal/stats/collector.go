@@ -3,6 +3,7 @@ package stats
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
@@ -30,18 +31,69 @@ type Snapshot struct {
 	UDPFloodPPS  float64
 	ICMPFloodPPS float64
 	ACKFloodPPS  float64
+
+	// Per-protocol RX rates
+	SYNPPS  float64
+	UDPPPS  float64
+	ICMPPPS float64
+	DNSPPS  float64
+
+	// SYN cookie rates
+	SYNCookieSentPPS      float64
+	SYNCookieValidatedPPS float64
+	SYNCookieFailedPPS    float64
+
+	// Conntrack entries created and threat intel drops
+	ConntrackNewPPS float64
+	ThreatIntelPPS  float64
+}
+
+// Bounds of the collection interval.
+const (
+	MinInterval = 10 * time.Millisecond
+	MaxInterval = time.Minute
+)
+
+// CollectorConfig controls how often BPF stats are read.
+type CollectorConfig struct {
+	IntervalMS uint64 `yaml:"interval_ms"` // Collection interval (default: 1000)
+}
+
+// DefaultCollectorConfig collects once a second.
+func DefaultCollectorConfig() CollectorConfig {
+	return CollectorConfig{IntervalMS: 1000}
+}
+
+// Interval returns the configured collection interval.
+func (c CollectorConfig) Interval() time.Duration {
+	return time.Duration(c.IntervalMS) * time.Millisecond
+}
+
+// Validate checks the collector configuration.
+func (c CollectorConfig) Validate() error {
+	return validateInterval(c.Interval())
+}
+
+func validateInterval(d time.Duration) error {
+	if d < MinInterval || d > MaxInterval {
+		return fmt.Errorf("interval must be between %v and %v", MinInterval, MaxInterval)
+	}
+	return nil
 }
 
 // Collector periodically reads BPF stats and computes rates.
 type Collector struct {
-	log      *zap.Logger
-	maps     *bpf.MapManager
-	interval time.Duration
+	log  *zap.Logger
+	maps *bpf.MapManager
 
 	mu       sync.RWMutex
+	interval time.Duration
 	current  *Snapshot
 	previous *Snapshot
 
+	// Signals Run that the interval changed
+	reset chan struct{}
+
 	// Subscribers receive snapshot updates
 	subs   []chan<- *Snapshot
 	subsMu sync.RWMutex
@@ -53,7 +105,40 @@ func NewCollector(log *zap.Logger, maps *bpf.MapManager, interval time.Duration)
 		log:      log,
 		maps:     maps,
 		interval: interval,
+		reset:    make(chan struct{}, 1),
+	}
+}
+
+// Interval returns the current collection interval.
+func (c *Collector) Interval() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.interval
+}
+
+// SetInterval changes the collection interval, taking effect at the next
+// tick. Rates are computed from snapshot timestamps, so they stay correct
+// across the change.
+func (c *Collector) SetInterval(d time.Duration) error {
+	if err := validateInterval(d); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	old := c.interval
+	c.interval = d
+	c.mu.Unlock()
+
+	select {
+	case c.reset <- struct{}{}:
+	default:
+	}
+	if d != old {
+		c.log.Info("stats collection interval changed",
+			zap.Duration("from", old),
+			zap.Duration("to", d),
+		)
 	}
+	return nil
 }
 
 // Subscribe returns a channel that receives stats snapshots.
@@ -67,16 +152,19 @@ func (c *Collector) Subscribe(bufSize int) <-chan *Snapshot {
 
 // Run starts the collection loop. Blocks until context is cancelled.
 func (c *Collector) Run(ctx context.Context) {
-	ticker := time.NewTicker(c.interval)
+	interval := c.Interval()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	c.log.Info("stats collector started", zap.Duration("interval", c.interval))
+	c.log.Info("stats collector started", zap.Duration("interval", interval))
 
 	for {
 		select {
 		case <-ctx.Done():
 			c.log.Info("stats collector stopped")
 			return
+		case <-c.reset:
+			ticker.Reset(c.Interval())
 		case <-ticker.C:
 			c.collect()
 		}
@@ -116,6 +204,15 @@ func (c *Collector) collect() {
 			snap.UDPFloodPPS = float64(snap.Stats.UDPFloodDropped-prev.Stats.UDPFloodDropped) / dt
 			snap.ICMPFloodPPS = float64(snap.Stats.ICMPFloodDropped-prev.Stats.ICMPFloodDropped) / dt
 			snap.ACKFloodPPS = float64(snap.Stats.ACKFloodDropped-prev.Stats.ACKFloodDropped) / dt
+			snap.SYNPPS = float64(snap.Stats.RxTCPSYNPackets-prev.Stats.RxTCPSYNPackets) / dt
+			snap.UDPPPS = float64(snap.Stats.RxUDPPackets-prev.Stats.RxUDPPackets) / dt
+			snap.ICMPPPS = float64(snap.Stats.RxICMPPackets-prev.Stats.RxICMPPackets) / dt
+			snap.DNSPPS = float64(snap.Stats.RxDNSPackets-prev.Stats.RxDNSPackets) / dt
+			snap.SYNCookieSentPPS = float64(snap.Stats.SYNCookiesSent-prev.Stats.SYNCookiesSent) / dt
+			snap.SYNCookieValidatedPPS = float64(snap.Stats.SYNCookiesValidated-prev.Stats.SYNCookiesValidated) / dt
+			snap.SYNCookieFailedPPS = float64(snap.Stats.SYNCookiesFailed-prev.Stats.SYNCookiesFailed) / dt
+			snap.ConntrackNewPPS = float64(snap.Stats.ConntrackNew-prev.Stats.ConntrackNew) / dt
+			snap.ThreatIntelPPS = float64(snap.Stats.ThreatIntelDropped-prev.Stats.ThreatIntelDropped) / dt
 		}
 	}
 
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
 )
 
 func TestSnapshotRateCalculation(t *testing.T) {
@@ -141,3 +142,26 @@ func assertFloat(t *testing.T, name string, got, want float64) {
 		t.Errorf("%s = %f, want %f (diff=%f)", name, got, want, diff)
 	}
 }
+
+func TestCollectorSetInterval(t *testing.T) {
+	c := NewCollector(zap.NewNop(), nil, time.Second)
+
+	if err := c.SetInterval(100 * time.Millisecond); err != nil {
+		t.Fatalf("SetInterval(100ms): %v", err)
+	}
+	if got := c.Interval(); got != 100*time.Millisecond {
+		t.Errorf("Interval() = %v, want 100ms", got)
+	}
+	for _, d := range []time.Duration{0, time.Millisecond, 2 * time.Minute} {
+		if err := c.SetInterval(d); err == nil {
+			t.Errorf("SetInterval(%v) should fail", d)
+		}
+	}
+	if got := c.Interval(); got != 100*time.Millisecond {
+		t.Errorf("Interval() after rejected changes = %v, want 100ms", got)
+	}
+
+	if err := DefaultCollectorConfig().Validate(); err != nil {
+		t.Errorf("default config: %v", err)
+	}
+}
@@ -0,0 +1,334 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Maximum number of points a single history query may return.
+const MaxHistoryPoints = 2000
+
+// HistoryConfig controls the in-memory time series of traffic rates.
+type HistoryConfig struct {
+	RetentionSec  uint64 `yaml:"retention_sec"`  // How far back history reaches (default: 86400)
+	ResolutionSec uint64 `yaml:"resolution_sec"` // Seconds averaged into each stored point (default: 10)
+	Persist       bool   `yaml:"persist"`        // Save to shutdown.state_dir and restore on start
+}
+
+// DefaultHistoryConfig keeps 24 hours at 10-second resolution.
+func DefaultHistoryConfig() HistoryConfig {
+	return HistoryConfig{RetentionSec: 86400, ResolutionSec: 10}
+}
+
+// Validate checks the history configuration.
+func (c HistoryConfig) Validate() error {
+	if c.ResolutionSec == 0 {
+		return fmt.Errorf("resolution_sec must be positive")
+	}
+	if c.RetentionSec < c.ResolutionSec {
+		return fmt.Errorf("retention_sec must be at least resolution_sec")
+	}
+	if c.RetentionSec/c.ResolutionSec > 1<<20 {
+		return fmt.Errorf("retention_sec / resolution_sec must not exceed %d points", 1<<20)
+	}
+	return nil
+}
+
+// Point is the average of the traffic rates over one interval.
+type Point struct {
+	Timestamp    time.Time `json:"timestamp"` // Start of the interval
+	RxPPS        float64   `json:"rxPps"`
+	RxBPS        float64   `json:"rxBps"`
+	TxPPS        float64   `json:"txPps"`
+	TxBPS        float64   `json:"txBps"`
+	DropPPS      float64   `json:"dropPps"`
+	DropBPS      float64   `json:"dropBps"`
+	SYNFloodPPS  float64   `json:"synFloodPps"`
+	UDPFloodPPS  float64   `json:"udpFloodPps"`
+	ICMPFloodPPS float64   `json:"icmpFloodPps"`
+	ACKFloodPPS  float64   `json:"ackFloodPps"`
+	SYNPPS       float64   `json:"synPps"`
+	UDPPPS       float64   `json:"udpPps"`
+	ICMPPPS      float64   `json:"icmpPps"`
+	DNSPPS       float64   `json:"dnsPps"`
+}
+
+// fields returns pointers to the rate fields so points can be summed and
+// averaged without listing every field each time.
+func (p *Point) fields() [14]*float64 {
+	return [14]*float64{
+		&p.RxPPS, &p.RxBPS, &p.TxPPS, &p.TxBPS, &p.DropPPS, &p.DropBPS,
+		&p.SYNFloodPPS, &p.UDPFloodPPS, &p.ICMPFloodPPS, &p.ACKFloodPPS,
+		&p.SYNPPS, &p.UDPPPS, &p.ICMPPPS, &p.DNSPPS,
+	}
+}
+
+// seriesNames are the JSON keys of the rate fields, in fields() order.
+var seriesNames = [14]string{
+	"rxPps", "rxBps", "txPps", "txBps", "dropPps", "dropBps",
+	"synFloodPps", "udpFloodPps", "icmpFloodPps", "ackFloodPps",
+	"synPps", "udpPps", "icmpPps", "dnsPps",
+}
+
+// SeriesNames returns the names of the rate series a Point carries.
+func SeriesNames() []string {
+	return append([]string(nil), seriesNames[:]...)
+}
+
+// Value returns the rate of the named series.
+func (p *Point) Value(name string) (float64, bool) {
+	for i, f := range p.fields() {
+		if seriesNames[i] == name {
+			return *f, true
+		}
+	}
+	return 0, false
+}
+
+func pointFromSnapshot(snap *Snapshot) Point {
+	return Point{
+		Timestamp:    snap.Timestamp,
+		RxPPS:        snap.RxPPS,
+		RxBPS:        snap.RxBPS,
+		TxPPS:        snap.TxPPS,
+		TxBPS:        snap.TxBPS,
+		DropPPS:      snap.DropPPS,
+		DropBPS:      snap.DropBPS,
+		SYNFloodPPS:  snap.SYNFloodPPS,
+		UDPFloodPPS:  snap.UDPFloodPPS,
+		ICMPFloodPPS: snap.ICMPFloodPPS,
+		ACKFloodPPS:  snap.ACKFloodPPS,
+		SYNPPS:       snap.SYNPPS,
+		UDPPPS:       snap.UDPPPS,
+		ICMPPPS:      snap.ICMPPPS,
+		DNSPPS:       snap.DNSPPS,
+	}
+}
+
+// accumulator averages points falling into one interval.
+type accumulator struct {
+	start time.Time
+	sum   Point
+	n     int
+}
+
+func (a *accumulator) add(p Point) {
+	dst, src := a.sum.fields(), p.fields()
+	for i := range dst {
+		*dst[i] += *src[i]
+	}
+	a.n++
+}
+
+func (a *accumulator) mean() Point {
+	p := a.sum
+	p.Timestamp = a.start
+	for _, f := range p.fields() {
+		*f /= float64(a.n)
+	}
+	return p
+}
+
+// History keeps a fixed-size ring of averaged rate points fed from the
+// collector's snapshots.
+type History struct {
+	log        *zap.Logger
+	resolution time.Duration
+
+	mu    sync.RWMutex
+	ring  []Point
+	head  int // Next write position
+	count int
+	cur   accumulator
+}
+
+// NewHistory creates a history ring sized for cfg, which must be valid.
+func NewHistory(log *zap.Logger, cfg HistoryConfig) *History {
+	return &History{
+		log:        log,
+		resolution: time.Duration(cfg.ResolutionSec) * time.Second,
+		ring:       make([]Point, cfg.RetentionSec/cfg.ResolutionSec),
+	}
+}
+
+// Run records snapshots from ch until the context is cancelled.
+func (h *History) Run(ctx context.Context, ch <-chan *Snapshot) {
+	h.log.Info("stats history started",
+		zap.Duration("resolution", h.resolution),
+		zap.Int("points", len(h.ring)),
+	)
+
+	first := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case snap := <-ch:
+			// The first snapshot has no previous one to compute rates from.
+			if first {
+				first = false
+				continue
+			}
+			h.Add(snap)
+		}
+	}
+}
+
+// Add folds a snapshot into the current interval, storing the interval's
+// average once a snapshot from a later interval arrives.
+func (h *History) Add(snap *Snapshot) {
+	p := pointFromSnapshot(snap)
+	start := p.Timestamp.Truncate(h.resolution)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cur.n > 0 && !start.Equal(h.cur.start) {
+		h.push(h.cur.mean())
+		h.cur = accumulator{}
+	}
+	if h.cur.n == 0 {
+		h.cur.start = start
+	}
+	h.cur.add(p)
+}
+
+// push appends a point, overwriting the oldest once the ring is full.
+// Caller must hold h.mu.
+func (h *History) push(p Point) {
+	h.ring[h.head] = p
+	h.head = (h.head + 1) % len(h.ring)
+	if h.count < len(h.ring) {
+		h.count++
+	}
+}
+
+// points returns the stored points, oldest first. Caller must hold h.mu.
+func (h *History) points() []Point {
+	out := make([]Point, 0, h.count)
+	start := (h.head - h.count + len(h.ring)) % len(h.ring)
+	for i := 0; i < h.count; i++ {
+		out = append(out, h.ring[(start+i)%len(h.ring)])
+	}
+	return out
+}
+
+// Resolution returns the interval averaged into each stored point.
+func (h *History) Resolution() time.Duration {
+	return h.resolution
+}
+
+// Query returns the points of the last window before now, averaged into
+// buckets of step aligned to multiples of step. A zero step is chosen to
+// fit MaxHistoryPoints, and steps finer than the resolution are raised to
+// it. Buckets without data are omitted. The step used is returned.
+func (h *History) Query(window, step time.Duration, now time.Time) ([]Point, time.Duration, error) {
+	if window <= 0 {
+		return nil, 0, fmt.Errorf("window must be positive")
+	}
+	if step <= 0 {
+		step = (window + MaxHistoryPoints - 1) / MaxHistoryPoints
+	}
+	if step < h.resolution {
+		step = h.resolution
+	}
+	if window/step > MaxHistoryPoints {
+		return nil, 0, fmt.Errorf("window / step exceeds %d points", MaxHistoryPoints)
+	}
+	from := now.Add(-window).Truncate(step)
+
+	h.mu.RLock()
+	points := h.points()
+	h.mu.RUnlock()
+
+	var out []Point
+	var acc accumulator
+	for _, p := range points {
+		if p.Timestamp.Before(from) || p.Timestamp.After(now) {
+			continue
+		}
+		start := p.Timestamp.Truncate(step)
+		if acc.n > 0 && !start.Equal(acc.start) {
+			out = append(out, acc.mean())
+			acc = accumulator{}
+		}
+		if acc.n == 0 {
+			acc.start = start
+		}
+		acc.add(p)
+	}
+	if acc.n > 0 {
+		out = append(out, acc.mean())
+	}
+	return out, step, nil
+}
+
+// persistedHistory is the on-disk form of the history ring.
+type persistedHistory struct {
+	SavedAt       time.Time `json:"saved_at"`
+	ResolutionSec uint64    `json:"resolution_sec"`
+	Points        []Point   `json:"points"`
+}
+
+// SaveState writes the stored points to path atomically.
+func (h *History) SaveState(path string) error {
+	h.mu.RLock()
+	st := persistedHistory{
+		SavedAt:       time.Now(),
+		ResolutionSec: uint64(h.resolution / time.Second),
+		Points:        h.points(),
+	}
+	h.mu.RUnlock()
+
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("marshaling stats history: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("writing stats history: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing stats history: %w", err)
+	}
+
+	h.log.Info("stats history saved", zap.String("path", path), zap.Int("points", len(st.Points)))
+	return nil
+}
+
+// LoadState restores points saved by SaveState. History saved at a
+// different resolution is discarded. A missing file is not an error.
+func (h *History) LoadState(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading stats history: %w", err)
+	}
+
+	var st persistedHistory
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("parsing stats history: %w", err)
+	}
+	if time.Duration(st.ResolutionSec)*time.Second != h.resolution {
+		h.log.Info("discarding stats history saved at a different resolution",
+			zap.Uint64("saved_resolution_sec", st.ResolutionSec))
+		return nil
+	}
+
+	h.mu.Lock()
+	for _, p := range st.Points {
+		h.push(p)
+	}
+	h.mu.Unlock()
+
+	h.log.Info("stats history restored", zap.String("path", path), zap.Int("points", len(st.Points)))
+	return nil
+}
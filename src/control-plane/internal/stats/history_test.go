@@ -0,0 +1,106 @@
+package stats
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func feedHistory(h *History, start time.Time, seconds int) {
+	for i := 0; i < seconds; i++ {
+		h.Add(&Snapshot{Timestamp: start.Add(time.Duration(i) * time.Second), RxPPS: float64(i)})
+	}
+}
+
+func TestHistoryQuery(t *testing.T) {
+	h := NewHistory(zap.NewNop(), HistoryConfig{RetentionSec: 60, ResolutionSec: 10})
+	start := time.Unix(1_700_000_000, 0) // multiple of 10s
+	feedHistory(h, start, 91)            // 9 full intervals; the 10th is still open
+
+	now := start.Add(90 * time.Second)
+	points, step, err := h.Query(time.Hour, 0, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step != 10*time.Second {
+		t.Errorf("step = %v, want resolution", step)
+	}
+	// The ring holds only the last 6 intervals: 30s-90s.
+	if len(points) != 6 {
+		t.Fatalf("points = %d, want 6", len(points))
+	}
+	if !points[0].Timestamp.Equal(start.Add(30*time.Second)) || points[0].RxPPS != 34.5 {
+		t.Errorf("oldest point = %v %v, want 30s avg 34.5", points[0].Timestamp.Sub(start), points[0].RxPPS)
+	}
+
+	points, _, err = h.Query(time.Minute, 20*time.Second, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The window starts at 30s, inside the 20s bucket; then 40s, 60s and the
+	// 80s bucket holding only the 80s point.
+	if len(points) != 4 || points[1].RxPPS != 49.5 || points[3].RxPPS != 84.5 {
+		t.Errorf("downsampled = %+v", points)
+	}
+
+	if _, _, err := h.Query(24*time.Hour, 10*time.Second, now); err == nil {
+		t.Error("query exceeding MaxHistoryPoints accepted")
+	}
+}
+
+func TestPointValue(t *testing.T) {
+	var p Point
+	for i, f := range p.fields() {
+		*f = float64(i + 1)
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var byKey map[string]interface{}
+	if err := json.Unmarshal(data, &byKey); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range SeriesNames() {
+		v, ok := p.Value(name)
+		if !ok || v != byKey[name] {
+			t.Errorf("Value(%q) = %v, %t; JSON has %v", name, v, ok, byKey[name])
+		}
+	}
+	if _, ok := p.Value("timestamp"); ok {
+		t.Error("Value(timestamp) should not be a series")
+	}
+}
+
+func TestHistoryState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+	cfg := HistoryConfig{RetentionSec: 600, ResolutionSec: 10}
+	start := time.Unix(1_700_000_000, 0)
+
+	h := NewHistory(zap.NewNop(), cfg)
+	feedHistory(h, start, 31)
+	if err := h.SaveState(path); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewHistory(zap.NewNop(), cfg)
+	if err := restored.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	if points, _, _ := restored.Query(time.Hour, 0, start.Add(time.Minute)); len(points) != 3 {
+		t.Errorf("restored points = %d, want 3", len(points))
+	}
+
+	cfg.ResolutionSec = 5
+	other := NewHistory(zap.NewNop(), cfg)
+	if err := other.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	if points, _, _ := other.Query(time.Hour, 0, start.Add(time.Minute)); len(points) != 0 {
+		t.Errorf("history at another resolution restored %d points", len(points))
+	}
+}
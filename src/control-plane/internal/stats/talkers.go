@@ -0,0 +1,215 @@
+package stats
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+// Talker is a source IP's traffic over the sliding window.
+type Talker struct {
+	IP       string    `json:"ip"`
+	PPS      float64   `json:"pps"`
+	BPS      float64   `json:"bps"`
+	DropPPS  float64   `json:"dropPps"`
+	DropBPS  float64   `json:"dropBps"`
+	Packets  uint64    `json:"packets"` // Within the window
+	Bytes    uint64    `json:"bytes"`
+	Dropped  uint64    `json:"dropped"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// Sort keys accepted by TopTalkers.Top.
+const (
+	SortByPPS   = "pps"
+	SortByBPS   = "bps"
+	SortByDrops = "drops"
+)
+
+// talkerSample is the traffic between two polls of the top_talkers map.
+// Only sources that sent packets in between are kept, so idle entries of
+// the LRU cost nothing once they fall behind the previous poll.
+type talkerSample struct {
+	from, at time.Time
+	deltas   map[uint32]bpf.TalkerStats // LastSeenNS is the value at at
+}
+
+// TopTalkers periodically reads the BPF top_talkers map and computes
+// per-source rates over a sliding window.
+type TopTalkers struct {
+	log      *zap.Logger
+	maps     *bpf.MapManager
+	interval time.Duration
+	window   time.Duration
+
+	mu      sync.RWMutex
+	prev    map[uint32]bpf.TalkerStats // Counters at the last poll
+	prevAt  time.Time
+	samples []talkerSample // oldest first, spanning at most window
+}
+
+// NewTopTalkers creates a top-talkers aggregator polling every interval and
+// reporting rates over window.
+func NewTopTalkers(log *zap.Logger, maps *bpf.MapManager, interval, window time.Duration) *TopTalkers {
+	if window < interval {
+		window = interval
+	}
+	return &TopTalkers{
+		log:      log,
+		maps:     maps,
+		interval: interval,
+		window:   window,
+	}
+}
+
+// Run starts the polling loop. Blocks until context is cancelled.
+func (t *TopTalkers) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	t.log.Info("top talkers aggregator started",
+		zap.Duration("interval", t.interval),
+		zap.Duration("window", t.window),
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.log.Info("top talkers aggregator stopped")
+			return
+		case <-ticker.C:
+			counters, err := t.maps.ReadTopTalkers()
+			if err != nil {
+				t.log.Warn("failed to read top talkers", zap.Error(err))
+				continue
+			}
+			t.add(time.Now(), counters)
+		}
+	}
+}
+
+// Window returns the sliding window rates are computed over.
+func (t *TopTalkers) Window() time.Duration {
+	return t.window
+}
+
+// add records the traffic since the previous poll and drops samples that
+// fell out of the window. The oldest sample kept starts at or before the
+// window start.
+func (t *TopTalkers) add(now time.Time, counters map[uint32]bpf.TalkerStats) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	prev, prevAt := t.prev, t.prevAt
+	t.prev, t.prevAt = counters, now
+	if prev == nil {
+		return
+	}
+
+	deltas := make(map[uint32]bpf.TalkerStats)
+	for key, cur := range counters {
+		// Sources absent from the previous poll (new, or evicted and
+		// re-created by the LRU) count from zero.
+		base, ok := prev[key]
+		if !ok || cur.Packets < base.Packets {
+			base = bpf.TalkerStats{}
+		}
+		if cur.Packets == base.Packets {
+			continue
+		}
+		deltas[key] = bpf.TalkerStats{
+			Packets:        cur.Packets - base.Packets,
+			Bytes:          cur.Bytes - base.Bytes,
+			DroppedPackets: cur.DroppedPackets - base.DroppedPackets,
+			DroppedBytes:   cur.DroppedBytes - base.DroppedBytes,
+			LastSeenNS:     cur.LastSeenNS,
+		}
+	}
+	t.samples = append(t.samples, talkerSample{from: prevAt, at: now, deltas: deltas})
+
+	cutoff := now.Add(-t.window)
+	drop := 0
+	for drop+1 < len(t.samples) && !t.samples[drop+1].from.After(cutoff) {
+		drop++
+	}
+	t.samples = t.samples[drop:]
+}
+
+// Top returns up to n sources ordered by sortBy (pps, bps, or drops).
+func (t *TopTalkers) Top(n int, sortBy string) []Talker {
+	t.mu.RLock()
+	if len(t.samples) == 0 {
+		t.mu.RUnlock()
+		return []Talker{}
+	}
+	from, at := t.samples[0].from, t.samples[len(t.samples)-1].at
+	sums := make(map[uint32]bpf.TalkerStats)
+	for _, sample := range t.samples {
+		for key, d := range sample.deltas {
+			sum := sums[key]
+			sum.Packets += d.Packets
+			sum.Bytes += d.Bytes
+			sum.DroppedPackets += d.DroppedPackets
+			sum.DroppedBytes += d.DroppedBytes
+			sum.LastSeenNS = d.LastSeenNS
+			sums[key] = sum
+		}
+	}
+	t.mu.RUnlock()
+
+	dt := at.Sub(from).Seconds()
+	talkers := make([]Talker, 0, len(sums))
+	for key, sum := range sums {
+		tk := Talker{
+			IP:      bpf.U32BEToIP(key).String(),
+			Packets: sum.Packets,
+			Bytes:   sum.Bytes,
+			Dropped: sum.DroppedPackets,
+		}
+		tk.PPS = float64(tk.Packets) / dt
+		tk.BPS = float64(tk.Bytes) * 8 / dt
+		tk.DropPPS = float64(tk.Dropped) / dt
+		tk.DropBPS = float64(sum.DroppedBytes) * 8 / dt
+		tk.LastSeen = BootNSToTime(sum.LastSeenNS, at)
+		talkers = append(talkers, tk)
+	}
+
+	sort.Slice(talkers, func(i, j int) bool {
+		switch sortBy {
+		case SortByBPS:
+			return talkers[i].BPS > talkers[j].BPS
+		case SortByDrops:
+			return talkers[i].DropPPS > talkers[j].DropPPS
+		default:
+			return talkers[i].PPS > talkers[j].PPS
+		}
+	})
+
+	if n > 0 && n < len(talkers) {
+		talkers = talkers[:n]
+	}
+	return talkers
+}
+
+// BootNSToTime converts a bpf_ktime_get_ns() timestamp (CLOCK_MONOTONIC)
+// to wall-clock time. It falls back to the sample time if the clock cannot
+// be read.
+func BootNSToTime(ns uint64, sampledAt time.Time) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return sampledAt
+	}
+	now := uint64(ts.Nano())
+	if ns > now {
+		return sampledAt
+	}
+	return time.Now().Add(-time.Duration(now - ns))
+}
@@ -0,0 +1,103 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+func TestTopTalkersRates(t *testing.T) {
+	tt := NewTopTalkers(zap.NewNop(), nil, time.Second, 10*time.Second)
+	start := time.Now()
+
+	if got := tt.Top(10, SortByPPS); len(got) != 0 {
+		t.Fatalf("expected no talkers before two samples, got %d", len(got))
+	}
+
+	tt.add(start, map[uint32]bpf.TalkerStats{
+		0x0a000001: {Packets: 100, Bytes: 10000},
+		0x0a000002: {Packets: 5000, Bytes: 300000, DroppedPackets: 4000, DroppedBytes: 240000},
+	})
+	tt.add(start.Add(2*time.Second), map[uint32]bpf.TalkerStats{
+		0x0a000001: {Packets: 2100, Bytes: 3010000},
+		0x0a000002: {Packets: 6000, Bytes: 360000, DroppedPackets: 5000, DroppedBytes: 300000},
+		0x0a000003: {Packets: 50, Bytes: 5000}, // new since the baseline
+	})
+
+	got := tt.Top(10, SortByPPS)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 talkers, got %d", len(got))
+	}
+	if got[0].IP != "10.0.0.1" || got[0].PPS != 1000 || got[0].BPS != 12000000 {
+		t.Errorf("unexpected top talker by pps: %+v", got[0])
+	}
+
+	byDrops := tt.Top(1, SortByDrops)
+	if len(byDrops) != 1 || byDrops[0].IP != "10.0.0.2" || byDrops[0].DropPPS != 500 {
+		t.Errorf("unexpected top talker by drops: %+v", byDrops)
+	}
+}
+
+func TestTopTalkersWindow(t *testing.T) {
+	tt := NewTopTalkers(zap.NewNop(), nil, time.Second, 3*time.Second)
+	start := time.Now()
+
+	for i := 0; i < 10; i++ {
+		tt.add(start.Add(time.Duration(i)*time.Second), map[uint32]bpf.TalkerStats{
+			0x0a000001: {Packets: uint64(i) * 100},
+		})
+	}
+
+	// The polls inside the window, starting from the one at its start.
+	if n := len(tt.samples); n != 3 {
+		t.Errorf("expected 3 samples retained, got %d", n)
+	}
+	got := tt.Top(1, SortByPPS)
+	if len(got) != 1 || got[0].PPS != 100 {
+		t.Errorf("unexpected rate over window: %+v", got)
+	}
+}
+
+func TestTopTalkersCounterReset(t *testing.T) {
+	tt := NewTopTalkers(zap.NewNop(), nil, time.Second, 10*time.Second)
+	start := time.Now()
+
+	tt.add(start, map[uint32]bpf.TalkerStats{0x0a000001: {Packets: 1000}})
+	// Entry evicted and re-created by the LRU: counters restart.
+	tt.add(start.Add(time.Second), map[uint32]bpf.TalkerStats{0x0a000001: {Packets: 10}})
+
+	got := tt.Top(1, SortByPPS)
+	if len(got) != 1 || got[0].Packets != 10 {
+		t.Errorf("expected counters to restart from zero, got %+v", got)
+	}
+}
+
+func TestTopTalkersKeepsOnlyActiveSources(t *testing.T) {
+	tt := NewTopTalkers(zap.NewNop(), nil, time.Second, 10*time.Second)
+	start := time.Now()
+
+	idle := make(map[uint32]bpf.TalkerStats)
+	for i := uint32(0); i < 1000; i++ {
+		idle[0x0b000000+i] = bpf.TalkerStats{Packets: 10}
+	}
+	for i := 0; i < 5; i++ {
+		counters := make(map[uint32]bpf.TalkerStats, len(idle)+1)
+		for k, v := range idle {
+			counters[k] = v
+		}
+		counters[0x0a000001] = bpf.TalkerStats{Packets: uint64(i) * 100}
+		tt.add(start.Add(time.Duration(i)*time.Second), counters)
+	}
+
+	for _, s := range tt.samples {
+		if len(s.deltas) > 1 {
+			t.Fatalf("sample holds %d sources, want only the active one", len(s.deltas))
+		}
+	}
+	got := tt.Top(10, SortByPPS)
+	if len(got) != 1 || got[0].IP != "10.0.0.1" || got[0].PPS != 100 {
+		t.Errorf("Top() = %+v, want 10.0.0.1 at 100 pps", got)
+	}
+}
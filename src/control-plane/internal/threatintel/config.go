@@ -0,0 +1,138 @@
+package threatintel
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// Config configures threat intelligence feeds at startup.
+type Config struct {
+	Enabled         bool         `yaml:"enabled"`
+	SyncIntervalSec uint64       `yaml:"sync_interval_sec"` // Default per-feed interval (default: 3600)
+	HTTP            HTTPConfig   `yaml:"http"`
+	Feeds           []FeedConfig `yaml:"feeds"`       // Feeds to enable
+	DropInDir       string       `yaml:"drop_in_dir"` // *.txt plaintext feeds, reloaded on change
+	Guard           GuardConfig  `yaml:"guard"`       // Limits on what feeds may install
+}
+
+// FeedConfig enables a feed. Built-in feeds (spamhaus-drop, spamhaus-edrop,
+// abuseipdb, blocklistde-all) are referenced by name alone; any other feed
+// needs a URL and type. A file:// URL reads the feed from a local file,
+// reloaded when it changes.
+type FeedConfig struct {
+	Name            string            `yaml:"name"`
+	URL             string            `yaml:"url"`
+	Type            string            `yaml:"type"`
+	APIKey          string            `yaml:"api_key"`
+	Headers         map[string]string `yaml:"headers"`
+	SyncIntervalSec uint64            `yaml:"sync_interval_sec"`
+	EntryTTLSec     uint64            `yaml:"entry_ttl_sec"`
+	MaxEntries      int               `yaml:"max_entries"` // Overrides guard.max_entries
+
+	// JSON maps the fields of a json feed of records. Feeds with a
+	// confidence field skip entries scored below MinConfidence, drop
+	// those at or above BlockConfidence and rate-limit the rest.
+	JSON            JSONMapping `yaml:"json"`
+	MinConfidence   uint8       `yaml:"min_confidence"`
+	BlockConfidence uint8       `yaml:"block_confidence"`
+}
+
+// Validate checks the threat intel configuration.
+func (c Config) Validate() error {
+	seen := make(map[string]bool, len(c.Feeds))
+	for i, f := range c.Feeds {
+		if f.Name == "" {
+			return fmt.Errorf("feeds[%d]: name is required", i)
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("feeds[%d]: duplicate feed %q", i, f.Name)
+		}
+		seen[f.Name] = true
+		if (f.URL == "") != (f.Type == "") {
+			return fmt.Errorf("feeds[%d]: url and type must be set together", i)
+		}
+		if err := validateLocalFeed(f.URL, f.Type); err != nil {
+			return fmt.Errorf("feeds[%d]: %w", i, err)
+		}
+		if !f.JSON.IsZero() {
+			if f.Type != "json" {
+				return fmt.Errorf("feeds[%d]: json mapping needs type json", i)
+			}
+			if err := f.JSON.Validate(); err != nil {
+				return fmt.Errorf("feeds[%d]: json: %w", i, err)
+			}
+		}
+		if f.MaxEntries < 0 {
+			return fmt.Errorf("feeds[%d]: max_entries must not be negative", i)
+		}
+		if (f.MinConfidence != 0 || f.BlockConfidence != 0) && (f.MinConfidence > f.BlockConfidence || f.BlockConfidence > 100) {
+			return fmt.Errorf("feeds[%d]: confidence thresholds must satisfy min_confidence <= block_confidence <= 100", i)
+		}
+		if f.SyncIntervalSec != 0 && time.Duration(f.SyncIntervalSec)*time.Second < scheduleTick {
+			return fmt.Errorf("feeds[%d]: sync_interval_sec must be at least %d", i, int(scheduleTick/time.Second))
+		}
+	}
+	if c.DropInDir != "" && !filepath.IsAbs(c.DropInDir) {
+		return fmt.Errorf("drop_in_dir %q must be absolute", c.DropInDir)
+	}
+	if err := c.Guard.Validate(); err != nil {
+		return fmt.Errorf("guard: %w", err)
+	}
+	return nil
+}
+
+// Configure applies cfg to the manager: HTTP client, default interval,
+// feed guard, the feeds to enable and the drop-in directory.
+func (m *Manager) Configure(cfg Config) error {
+	if err := m.SetHTTPConfig(cfg.HTTP); err != nil {
+		return err
+	}
+	if cfg.SyncIntervalSec > 0 {
+		m.SetSyncInterval(time.Duration(cfg.SyncIntervalSec) * time.Second)
+	}
+	if err := m.SetGuard(cfg.Guard); err != nil {
+		return err
+	}
+
+	for _, f := range cfg.Feeds {
+		if f.URL != "" {
+			if err := m.AddFeed(f.Name, f.URL, f.Type); err != nil {
+				return err
+			}
+		} else if err := m.EnableFeed(f.Name); err != nil {
+			return err
+		}
+		if f.APIKey != "" {
+			if err := m.SetFeedAPIKey(f.Name, f.APIKey); err != nil {
+				return err
+			}
+		}
+		if len(f.Headers) > 0 {
+			if err := m.SetFeedHeaders(f.Name, f.Headers); err != nil {
+				return err
+			}
+		}
+		if err := m.SetFeedSyncInterval(f.Name, time.Duration(f.SyncIntervalSec)*time.Second); err != nil {
+			return err
+		}
+		if err := m.SetFeedEntryTTL(f.Name, time.Duration(f.EntryTTLSec)*time.Second); err != nil {
+			return err
+		}
+		if err := m.SetFeedMaxEntries(f.Name, f.MaxEntries); err != nil {
+			return err
+		}
+		if !f.JSON.IsZero() {
+			if err := m.SetFeedJSONMapping(f.Name, f.JSON); err != nil {
+				return err
+			}
+		}
+		if f.MinConfidence != 0 || f.BlockConfidence != 0 {
+			if err := m.SetFeedConfidence(f.Name, f.MinConfidence, f.BlockConfidence); err != nil {
+				return err
+			}
+		}
+	}
+	m.SetDropInDir(cfg.DropInDir)
+	return nil
+}
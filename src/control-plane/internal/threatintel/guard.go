@@ -0,0 +1,281 @@
+package threatintel
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Feed guards keep a broken or malicious feed from blocking address space
+// it has no business listing: entries shorter than the minimum prefix
+// length or overlapping reserved, whitelisted or protected prefixes are
+// dropped, a feed over its entry limit fails its sync, and a sync that
+// changes a feed's size too sharply is quarantined until an operator
+// approves or rejects it.
+const (
+	defaultMaxEntries   = 1000000
+	defaultMinPrefixLen = 8
+	defaultMaxChangePct = 50
+
+	// anomalyMinEntries is the feed size below which entry count changes
+	// are not judged; small feeds swing by large fractions legitimately.
+	anomalyMinEntries = 100
+)
+
+// ErrQuarantined is returned by a sync whose result was quarantined.
+var ErrQuarantined = errors.New("feed sync quarantined")
+
+// reservedPrefixes are never valid threat intel entries: this network,
+// private, shared, loopback, link-local, multicast and reserved space.
+var reservedPrefixes = []string{
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8",
+	"169.254.0.0/16", "172.16.0.0/12", "192.168.0.0/16",
+	"224.0.0.0/4", "240.0.0.0/4",
+}
+
+// GuardConfig limits what feeds may install.
+type GuardConfig struct {
+	MaxEntries   int      `yaml:"max_entries"`    // Per feed (default: 1000000)
+	MinPrefixLen uint8    `yaml:"min_prefix_len"` // Shorter entries are dropped (default: 8)
+	Protected    []string `yaml:"protected"`      // Prefixes no entry may overlap, besides the whitelist and assets
+	MaxChangePct int      `yaml:"max_change_pct"` // Quarantine syncs changing the entry count more (default: 50, -1 disables)
+}
+
+// Validate checks the guard settings.
+func (g GuardConfig) Validate() error {
+	if g.MaxEntries < 0 {
+		return fmt.Errorf("max_entries must not be negative")
+	}
+	if g.MinPrefixLen > 32 {
+		return fmt.Errorf("min_prefix_len must be 0-32")
+	}
+	if g.MaxChangePct < -1 {
+		return fmt.Errorf("max_change_pct must be -1 (disabled) or more")
+	}
+	for _, p := range g.Protected {
+		if _, err := parseLPMKey(p); err != nil {
+			return fmt.Errorf("protected: %w", err)
+		}
+	}
+	return nil
+}
+
+// Quarantine is a feed sync held back as anomalous.
+type Quarantine struct {
+	At       time.Time
+	Entries  int // Entries the sync would install
+	Previous int // Entries installed when it arrived
+	Reason   string
+
+	set keySet
+}
+
+// SetGuard sets the limits applied to feed entries from the next sync.
+func (m *Manager) SetGuard(g GuardConfig) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.guard = g
+	m.mu.Unlock()
+	return nil
+}
+
+// SetProtectedSource sets a function returning prefixes, such as the
+// whitelist and protected assets, that feed entries must not overlap. It
+// is called on each sync so the prefixes can change at runtime.
+func (m *Manager) SetProtectedSource(fn func() []string) {
+	m.mu.Lock()
+	m.protectedSource = fn
+	m.mu.Unlock()
+}
+
+// SetFeedMaxEntries overrides the guard's entry limit for a feed. Zero
+// uses the guard's.
+func (m *Manager) SetFeedMaxEntries(name string, max int) error {
+	if max < 0 {
+		return fmt.Errorf("max entries must not be negative")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	feed, exists := m.feeds[name]
+	if !exists {
+		return fmt.Errorf("feed %q not found", name)
+	}
+	feed.MaxEntries = max
+	return nil
+}
+
+// entryGuard is the guard resolved for one sync.
+type entryGuard struct {
+	minPrefixLen uint32
+	blocked      []lpmKeyV4
+}
+
+// newGuard resolves the configured and protected prefixes. Unparseable
+// protected prefixes are logged and skipped.
+func (m *Manager) newGuard() entryGuard {
+	m.mu.RLock()
+	g, source := m.guard, m.protectedSource
+	m.mu.RUnlock()
+
+	eg := entryGuard{minPrefixLen: defaultMinPrefixLen}
+	if g.MinPrefixLen > 0 {
+		eg.minPrefixLen = uint32(g.MinPrefixLen)
+	}
+	prefixes := append(append([]string{}, reservedPrefixes...), g.Protected...)
+	if source != nil {
+		prefixes = append(prefixes, source()...)
+	}
+	for _, p := range prefixes {
+		key, err := parseLPMKey(p)
+		if err != nil {
+			m.log.Debug("skipping protected prefix", zap.String("prefix", p), zap.Error(err))
+			continue
+		}
+		eg.blocked = append(eg.blocked, key)
+	}
+	return eg
+}
+
+// check reports why key may not be installed, or nil.
+func (eg entryGuard) check(key lpmKeyV4) error {
+	if key.PrefixLen < eg.minPrefixLen {
+		return fmt.Errorf("%s is shorter than /%d", formatLPMKey(key), eg.minPrefixLen)
+	}
+	for _, b := range eg.blocked {
+		if overlaps(key, b) {
+			return fmt.Errorf("%s overlaps protected prefix %s", formatLPMKey(key), formatLPMKey(b))
+		}
+	}
+	return nil
+}
+
+// overlaps reports whether one of two prefixes contains the other.
+func overlaps(a, b lpmKeyV4) bool {
+	l := a.PrefixLen
+	if b.PrefixLen < l {
+		l = b.PrefixLen
+	}
+	return (a.Addr^b.Addr)&prefixMask(l) == 0
+}
+
+// apply guards a parsed feed and installs it with applyDelta. A set over
+// the feed's limit fails; one whose size differs too much from what is
+// installed is quarantined with ErrQuarantined. A set that applies
+// discards any earlier quarantine.
+func (m *Manager) apply(feed *Feed, set keySet, now time.Time) (int, error) {
+	eg := m.newGuard()
+	rejected := 0
+	for key := range set {
+		if err := eg.check(key); err != nil {
+			if rejected == 0 {
+				m.log.Warn("threat feed entry rejected", zap.String("feed", feed.Name), zap.Error(err))
+			}
+			delete(set, key)
+			rejected++
+		}
+	}
+	if rejected > 1 {
+		m.log.Warn("threat feed entries rejected", zap.String("feed", feed.Name), zap.Int("rejected", rejected))
+	}
+
+	m.mu.Lock()
+	max := feed.MaxEntries
+	if max == 0 {
+		max = m.guard.MaxEntries
+	}
+	if max == 0 {
+		max = defaultMaxEntries
+	}
+	maxChange := m.guard.MaxChangePct
+	if maxChange == 0 {
+		maxChange = defaultMaxChangePct
+	}
+	prev := len(feed.entries)
+
+	if len(set) > max {
+		m.mu.Unlock()
+		return 0, fmt.Errorf("feed lists %d entries, over its limit of %d", len(set), max)
+	}
+	if maxChange > 0 && prev >= anomalyMinEntries {
+		change := len(set) - prev
+		if change < 0 {
+			change = -change
+		}
+		if pct := change * 100 / prev; pct > maxChange {
+			q := &Quarantine{
+				At:       now,
+				Entries:  len(set),
+				Previous: prev,
+				Reason:   fmt.Sprintf("entry count changed %d%% (%d to %d), limit %d%%", pct, prev, len(set), maxChange),
+				set:      set,
+			}
+			feed.Quarantine = q
+			m.mu.Unlock()
+
+			m.log.Warn("threat feed sync quarantined pending approval",
+				zap.String("feed", feed.Name),
+				zap.String("reason", q.Reason),
+			)
+			return 0, fmt.Errorf("%w: %s", ErrQuarantined, q.Reason)
+		}
+	}
+	feed.Quarantine = nil
+	m.mu.Unlock()
+
+	return m.applyDelta(feed, set, now), nil
+}
+
+// ApproveQuarantine installs a feed's quarantined sync.
+func (m *Manager) ApproveQuarantine(name string) (int, error) {
+	m.mu.Lock()
+	feed, exists := m.feeds[name]
+	if !exists {
+		m.mu.Unlock()
+		return 0, fmt.Errorf("feed %q not found", name)
+	}
+	q := feed.Quarantine
+	if q == nil {
+		m.mu.Unlock()
+		return 0, fmt.Errorf("feed %q has no quarantined sync", name)
+	}
+	feed.Quarantine = nil
+	m.mu.Unlock()
+
+	now := time.Now()
+	count := m.applyDelta(feed, q.set, now)
+
+	m.mu.Lock()
+	feed.LastSync = now
+	feed.Error = ""
+	m.countEntriesLocked(feed)
+	m.mu.Unlock()
+
+	m.log.Info("quarantined threat feed sync approved", zap.String("feed", name), zap.Int("entries", count))
+	return count, nil
+}
+
+// RejectQuarantine discards a feed's quarantined sync, keeping the entries
+// installed before it.
+func (m *Manager) RejectQuarantine(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	feed, exists := m.feeds[name]
+	if !exists {
+		return fmt.Errorf("feed %q not found", name)
+	}
+	if feed.Quarantine == nil {
+		return fmt.Errorf("feed %q has no quarantined sync", name)
+	}
+	feed.Quarantine = nil
+	feed.Error = ""
+
+	m.log.Info("quarantined threat feed sync rejected", zap.String("feed", name))
+	return nil
+}
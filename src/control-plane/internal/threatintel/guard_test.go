@@ -0,0 +1,100 @@
+package threatintel
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestEntryGuard(t *testing.T) {
+	m := NewManager(zap.NewNop(), nil, nil)
+	if err := m.SetGuard(GuardConfig{MinPrefixLen: 12, Protected: []string{"203.0.113.0/24"}}); err != nil {
+		t.Fatal(err)
+	}
+	m.SetProtectedSource(func() []string { return []string{"198.51.100.64/26", "2001:db8::/32"} })
+	eg := m.newGuard()
+
+	tests := []struct {
+		prefix string
+		ok     bool
+	}{
+		{"198.51.100.7", true},
+		{"198.51.100.70", false},   // whitelisted or asset
+		{"198.51.100.0/24", false}, // covers a protected prefix
+		{"203.0.113.9", false},     // configured protected prefix
+		{"192.168.1.1", false},     // reserved
+		{"8.0.0.0/11", false},      // shorter than /12
+		{"8.16.0.0/12", true},
+	}
+	for _, tt := range tests {
+		key, err := parseLPMKey(tt.prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := eg.check(key); (err == nil) != tt.ok {
+			t.Errorf("check(%s) = %v, want ok %v", tt.prefix, err, tt.ok)
+		}
+	}
+}
+
+func TestApplyGuards(t *testing.T) {
+	m := NewManager(zap.NewNop(), nil, nil)
+	feed := m.feeds["spamhaus-drop"]
+
+	// Every entry is rejected, so nothing reaches the map.
+	set := make(keySet)
+	set.add("10.1.2.3")
+	set.add("0.0.0.0/1")
+	if n, err := m.apply(feed, set, time.Now()); err != nil || n != 0 {
+		t.Errorf("apply of rejected entries = %d, %v", n, err)
+	}
+
+	feed.MaxEntries = 2
+	set = make(keySet)
+	set.add("198.51.100.1")
+	set.add("198.51.100.2")
+	set.add("198.51.100.3")
+	if _, err := m.apply(feed, set, time.Now()); err == nil {
+		t.Error("apply over the entry limit: expected error")
+	}
+	feed.MaxEntries = 0
+
+	// A feed of 100 entries that suddenly lists 300 is quarantined.
+	feed.entries = make(map[lpmKeyV4]time.Time)
+	for i := uint32(0); i < 100; i++ {
+		feed.entries[lpmKeyV4{PrefixLen: 32, Addr: 0xc6336400 + i}] = time.Now()
+	}
+	set = make(keySet)
+	for i := uint32(0); i < 300; i++ {
+		set[lpmKeyV4{PrefixLen: 32, Addr: 0xcb007100 + i}] = nil
+	}
+	if _, err := m.apply(feed, set, time.Now()); !errors.Is(err, ErrQuarantined) {
+		t.Fatalf("apply of a 200%% jump: err = %v, want ErrQuarantined", err)
+	}
+	if q := feed.Quarantine; q == nil || q.Entries != 300 || q.Previous != 100 {
+		t.Fatalf("quarantine = %+v", q)
+	}
+	if len(feed.entries) != 100 {
+		t.Errorf("entries = %d after quarantine, want 100 kept", len(feed.entries))
+	}
+
+	if err := m.RejectQuarantine("spamhaus-drop"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RejectQuarantine("spamhaus-drop"); err == nil {
+		t.Error("second reject: expected error")
+	}
+	if _, err := m.ApproveQuarantine("spamhaus-drop"); err == nil {
+		t.Error("approve without a quarantine: expected error")
+	}
+
+	// With the check disabled the same jump would apply.
+	if err := m.SetGuard(GuardConfig{MaxChangePct: -1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetGuard(GuardConfig{MaxChangePct: -2}); err == nil {
+		t.Error("max_change_pct -2: expected error")
+	}
+}
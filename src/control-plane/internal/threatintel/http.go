@@ -0,0 +1,155 @@
+package threatintel
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// errNotModified reports a 304 answer to a conditional fetch.
+var errNotModified = errors.New("feed not modified")
+
+// HTTPConfig configures how feeds are fetched.
+type HTTPConfig struct {
+	Proxy              string `yaml:"proxy"` // http(s) proxy URL (default: HTTPS_PROXY/HTTP_PROXY)
+	CA                 string `yaml:"ca"`    // CA bundle (default: system roots)
+	Cert               string `yaml:"cert"`  // Client certificate for feeds requiring mutual TLS
+	Key                string `yaml:"key"`   // Client certificate key
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	TimeoutSec         uint64 `yaml:"timeout_sec"` // Per-request timeout (default: 60)
+}
+
+// SetHTTPConfig replaces the HTTP client used for feed fetches.
+func (m *Manager) SetHTTPConfig(cfg HTTPConfig) error {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Proxy != "" {
+		proxy, err := url.Parse(cfg.Proxy)
+		if err != nil || proxy.Host == "" {
+			return fmt.Errorf("invalid proxy URL %q", cfg.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	tlsCfg := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if cfg.CA != "" {
+		pem, err := os.ReadFile(cfg.CA)
+		if err != nil {
+			return fmt.Errorf("reading threat intel CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in %s", cfg.CA)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if cfg.Cert != "" || cfg.Key != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+		if err != nil {
+			return fmt.Errorf("loading threat intel client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsCfg
+
+	timeout := httpTimeout
+	if cfg.TimeoutSec > 0 {
+		timeout = time.Duration(cfg.TimeoutSec) * time.Second
+	}
+
+	m.mu.Lock()
+	m.httpClient = &http.Client{Transport: transport, Timeout: timeout}
+	m.mu.Unlock()
+
+	m.log.Info("threat intel HTTP client configured",
+		zap.Bool("proxy", cfg.Proxy != ""),
+		zap.Bool("custom_ca", cfg.CA != ""),
+		zap.Bool("client_cert", cfg.Cert != ""),
+	)
+	return nil
+}
+
+// fetch issues a GET for a feed within its rate limit and returns the
+// response if it is 200 OK. The feed's custom headers are sent first, then
+// header. Provider throttling (429 with Retry-After, or an exhausted
+// X-RateLimit-Remaining) pauses the feed until the provider's reset time.
+// A 304 answer returns errNotModified.
+func (m *Manager) fetch(feed *Feed, url string, header http.Header) (*http.Response, error) {
+	now := time.Now()
+	m.mu.Lock()
+	err := feed.quota.take(feed.RateLimit, now)
+	client, custom := m.httpClient, feed.Headers
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request for %s: %w", url, err)
+	}
+	for k, v := range custom {
+		req.Header.Set(k, v)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+
+	if until, ok := throttledUntil(resp, now); ok {
+		m.mu.Lock()
+		feed.quota.pauseUntil(until)
+		m.mu.Unlock()
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusNotModified:
+		resp.Body.Close()
+		return nil, errNotModified
+	default:
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP %d from %s", resp.StatusCode, url)
+	}
+}
+
+// conditionalHeader returns the If-None-Match / If-Modified-Since headers
+// for the feed's last successful fetch.
+func (m *Manager) conditionalHeader(feed *Feed) http.Header {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	header := make(http.Header)
+	if feed.etag != "" {
+		header.Set("If-None-Match", feed.etag)
+	}
+	if feed.lastModified != "" {
+		header.Set("If-Modified-Since", feed.lastModified)
+	}
+	return header
+}
+
+// touchEntries marks every entry of an unchanged feed as seen now so that
+// EntryTTL does not expire them, and returns the entry count.
+func (m *Manager) touchEntries(feed *Feed, now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key := range feed.entries {
+		feed.entries[key] = now
+	}
+	return len(feed.entries)
+}
@@ -0,0 +1,262 @@
+package threatintel
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONMapping says where a JSON feed keeps its entries. Without one, a
+// JSON feed is a bare array of address strings.
+//
+// Paths use a JSONPath subset: $ for the root, .name or ['name'] for an
+// object member, [n] for an array element and [*] or .* for every
+// element. Items selects the entry records; a path ending on an array
+// selects its elements. The other paths are relative to each record ($ is
+// the record itself; a leading $. may be left out).
+type JSONMapping struct {
+	Items      string `yaml:"items" json:"items,omitempty"`           // Default: $
+	IP         string `yaml:"ip" json:"ip,omitempty"`                 // Default: $ (the record is the address)
+	Confidence string `yaml:"confidence" json:"confidence,omitempty"` // 0-100 score, scored like AbuseIPDB
+	Category   string `yaml:"category" json:"category,omitempty"`     // Threat type name, or a key of Categories
+
+	// Categories maps provider category values to threat types, e.g.
+	// {"bruteforce": "scanner", "tor": "tor_exit"}. Unknown categories
+	// use the feed's threat type.
+	Categories map[string]string `yaml:"categories" json:"categories,omitempty"`
+}
+
+// IsZero reports whether no mapping is set.
+func (j JSONMapping) IsZero() bool {
+	return j.Items == "" && j.IP == "" && j.Confidence == "" && j.Category == "" && len(j.Categories) == 0
+}
+
+// Validate checks the paths and category names.
+func (j JSONMapping) Validate() error {
+	for _, p := range []struct{ name, path string }{
+		{"items", j.Items}, {"ip", j.IP}, {"confidence", j.Confidence}, {"category", j.Category},
+	} {
+		if _, err := compileJSONPath(p.path); err != nil {
+			return fmt.Errorf("%s: %w", p.name, err)
+		}
+	}
+	for category, name := range j.Categories {
+		if _, err := ParseThreatType(name); err != nil {
+			return fmt.Errorf("categories[%q]: %w", category, err)
+		}
+	}
+	return nil
+}
+
+// jsonPathStep is one segment of a compiled path: an object member, an
+// array index, or every element (wildcard).
+type jsonPathStep struct {
+	member   string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// compileJSONPath parses a path. An empty path is $.
+func compileJSONPath(path string) ([]jsonPathStep, error) {
+	p := strings.TrimSpace(path)
+	switch {
+	case p == "" || p == "$":
+		return nil, nil
+	case strings.HasPrefix(p, "$"):
+		p = p[1:]
+	case !strings.HasPrefix(p, "["):
+		p = "." + p
+	}
+
+	var steps []jsonPathStep
+	for p != "" {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			name := p[:end]
+			if name == "" {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty member name", path)
+			}
+			if name == "*" {
+				steps = append(steps, jsonPathStep{wildcard: true})
+			} else {
+				steps = append(steps, jsonPathStep{member: name})
+			}
+			p = p[end:]
+
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unclosed [", path)
+			}
+			sel := strings.TrimSpace(p[1:end])
+			switch {
+			case sel == "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			case len(sel) >= 2 && (sel[0] == '\'' || sel[0] == '"') && sel[len(sel)-1] == sel[0]:
+				steps = append(steps, jsonPathStep{member: sel[1 : len(sel)-1]})
+			default:
+				n, err := strconv.Atoi(sel)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid JSONPath %q: bad selector [%s]", path, sel)
+				}
+				steps = append(steps, jsonPathStep{index: n, isIndex: true})
+			}
+			p = p[end+1:]
+
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", path, p[0])
+		}
+	}
+	return steps, nil
+}
+
+// evalJSONPath returns every value the path selects in v.
+func evalJSONPath(v interface{}, steps []jsonPathStep) []interface{} {
+	cur := []interface{}{v}
+	for _, s := range steps {
+		var next []interface{}
+		for _, c := range cur {
+			switch {
+			case s.wildcard:
+				switch c := c.(type) {
+				case []interface{}:
+					next = append(next, c...)
+				case map[string]interface{}:
+					for _, e := range c {
+						next = append(next, e)
+					}
+				}
+			case s.isIndex:
+				if a, ok := c.([]interface{}); ok && s.index < len(a) {
+					next = append(next, a[s.index])
+				}
+			default:
+				if o, ok := c.(map[string]interface{}); ok {
+					if e, ok := o[s.member]; ok {
+						next = append(next, e)
+					}
+				}
+			}
+		}
+		cur = next
+	}
+	return cur
+}
+
+// jsonFirst returns the first value the path selects in v.
+func jsonFirst(v interface{}, steps []jsonPathStep) (interface{}, bool) {
+	vals := evalJSONPath(v, steps)
+	if len(vals) == 0 {
+		return nil, false
+	}
+	return vals[0], true
+}
+
+// jsonScore reads a confidence score given as a number or numeric string.
+func jsonScore(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return int(f), err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return int(f), err == nil
+	}
+	return 0, false
+}
+
+// parseMappedJSON reads a JSON feed through its field mapping. Records
+// without a usable address are skipped, as are records scored below the
+// feed's MinConfidence.
+func (m *Manager) parseMappedJSON(doc interface{}, feed *Feed, set keySet) (int, error) {
+	mapping := feed.JSON
+	items, err := compileJSONPath(mapping.Items)
+	if err != nil {
+		return 0, err
+	}
+	ipPath, err := compileJSONPath(mapping.IP)
+	if err != nil {
+		return 0, err
+	}
+	confPath, err := compileJSONPath(mapping.Confidence)
+	if err != nil {
+		return 0, err
+	}
+	catPath, err := compileJSONPath(mapping.Category)
+	if err != nil {
+		return 0, err
+	}
+
+	records := evalJSONPath(doc, items)
+	if len(records) == 1 {
+		if a, ok := records[0].([]interface{}); ok {
+			records = a
+		}
+	}
+
+	count := 0
+	for _, rec := range records {
+		v, ok := jsonFirst(rec, ipPath)
+		if !ok {
+			continue
+		}
+		ipStr, ok := v.(string)
+		if !ok || strings.TrimSpace(ipStr) == "" {
+			continue
+		}
+		ipStr = strings.TrimSpace(ipStr)
+
+		scored := mapping.Confidence != "" || mapping.Category != ""
+		if !scored {
+			if set.add(ipStr) == nil {
+				count++
+			}
+			continue
+		}
+
+		meta := entryMeta{ThreatType: feed.ThreatType, Confidence: feed.Confidence, Action: feed.Action}
+		if mapping.Confidence != "" {
+			v, ok := jsonFirst(rec, confPath)
+			if !ok {
+				continue
+			}
+			score, ok := jsonScore(v)
+			if !ok {
+				continue
+			}
+			if meta, ok = scoreEntry(feed, score); !ok {
+				continue
+			}
+		}
+		if mapping.Category != "" {
+			if v, ok := jsonFirst(rec, catPath); ok {
+				if s, ok := v.(string); ok {
+					meta.ThreatType = mapping.threatType(s, feed.ThreatType)
+				}
+			}
+		}
+		if set.addScored(ipStr, meta) == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// threatType maps a provider category to a threat type, falling back to
+// def.
+func (j JSONMapping) threatType(category string, def uint8) uint8 {
+	if name, ok := j.Categories[category]; ok {
+		category = name
+	}
+	if t, err := ParseThreatType(category); err == nil {
+		return t
+	}
+	return def
+}
@@ -0,0 +1,104 @@
+package threatintel
+
+import (
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCompileJSONPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		steps   int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"$", 0, false},
+		{"$.data[*].ip", 3, false},
+		{"data.*", 2, false},
+		{"$['ip address'][0]", 2, false},
+		{"$.data[", 0, true},
+		{"$..ip", 0, true},
+		{"$[-1]", 0, true},
+	}
+	for _, tt := range tests {
+		steps, err := compileJSONPath(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("compileJSONPath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if len(steps) != tt.steps {
+			t.Errorf("compileJSONPath(%q) = %d steps, want %d", tt.path, len(steps), tt.steps)
+		}
+	}
+}
+
+func TestParseMappedJSON(t *testing.T) {
+	const doc = `{"meta":{"count":4},"data":[
+		{"indicator":{"value":"198.51.100.7"},"score":95,"category":"tor"},
+		{"indicator":{"value":"198.51.100.0/24"},"score":"80","category":"scanner"},
+		{"indicator":{"value":"203.0.113.9"},"score":40},
+		{"indicator":{"value":"not-an-ip"},"score":99},
+		{"score":99}]}`
+
+	m := NewManager(zap.NewNop(), nil, nil)
+	feed := &Feed{
+		Type:            "json",
+		ThreatType:      0, // botnet
+		MinConfidence:   50,
+		BlockConfidence: 90,
+		JSON: JSONMapping{
+			Items:      "$.data",
+			IP:         "indicator.value",
+			Confidence: "$.score",
+			Category:   "$.category",
+			Categories: map[string]string{"tor": "tor_exit"},
+		},
+	}
+	set := make(keySet)
+	n, err := m.parseJSON(strings.NewReader(doc), feed, set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || len(set) != 2 {
+		t.Fatalf("parsed %d entries, set %d, want 2", n, len(set))
+	}
+	tor := set[lpmKeyV4{PrefixLen: 32, Addr: 0xc6336407}]
+	if tor == nil || *tor != (entryMeta{ThreatType: 2, Confidence: 95, Action: 0}) {
+		t.Errorf("198.51.100.7 = %+v, want tor_exit drop at 95", tor)
+	}
+	scanner := set[lpmKeyV4{PrefixLen: 24, Addr: 0xc6336400}]
+	if scanner == nil || *scanner != (entryMeta{ThreatType: 1, Confidence: 80, Action: 1}) {
+		t.Errorf("198.51.100.0/24 = %+v, want scanner rate-limit at 80", scanner)
+	}
+
+	// A mapped address alone yields unscored entries.
+	feed.JSON = JSONMapping{Items: "$.blocks[*]", IP: "cidr"}
+	set = make(keySet)
+	if _, err := m.parseJSON(strings.NewReader(`{"blocks":[{"cidr":"192.0.2.0/24"}]}`), feed, set); err != nil {
+		t.Fatal(err)
+	}
+	if meta, ok := set[lpmKeyV4{PrefixLen: 24, Addr: 0xc0000200}]; !ok || meta != nil {
+		t.Errorf("set = %v", set)
+	}
+}
+
+func TestSetFeedJSONMapping(t *testing.T) {
+	m := NewManager(zap.NewNop(), nil, nil)
+	if err := m.AddFeed("vendor", "https://intel.example.net/v1/indicators", "json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetFeedJSONMapping("vendor", JSONMapping{Items: "$.data[*]", IP: "ip"}); err != nil {
+		t.Errorf("valid mapping: %v", err)
+	}
+	if err := m.SetFeedJSONMapping("vendor", JSONMapping{IP: "$.data["}); err == nil {
+		t.Error("invalid path: expected error")
+	}
+	if err := m.SetFeedJSONMapping("vendor", JSONMapping{Categories: map[string]string{"tor": "onion"}}); err == nil {
+		t.Error("invalid category threat type: expected error")
+	}
+	if err := m.SetFeedJSONMapping("spamhaus-drop", JSONMapping{IP: "ip"}); err == nil {
+		t.Error("mapping on a plaintext feed: expected error")
+	}
+}
@@ -0,0 +1,187 @@
+package threatintel
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/progress"
+	"go.uber.org/zap"
+)
+
+// Local feeds are read from disk instead of fetched, for sites that
+// cannot reach feed providers: a file:// feed names one file, and every
+// *.txt file in the drop-in directory is a plaintext feed of its own.
+const (
+	fileScheme = "file://"
+
+	// dropInPrefix names the feeds of drop-in files, e.g. dropin:tor for
+	// intel.d/tor.txt.
+	dropInPrefix = "dropin:"
+
+	// localWatchInterval is how often local feeds are checked for changes
+	// and the drop-in directory for files added or removed.
+	localWatchInterval = 10 * time.Second
+)
+
+// localPath returns the path of a file:// feed URL.
+func localPath(url string) (string, bool) {
+	if !strings.HasPrefix(url, fileScheme) {
+		return "", false
+	}
+	return strings.TrimPrefix(url, fileScheme), true
+}
+
+// validateLocalFeed checks the URL and type of a file:// feed.
+func validateLocalFeed(url, feedType string) error {
+	path, ok := localPath(url)
+	if !ok {
+		return nil
+	}
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("file feed path %q must be absolute", path)
+	}
+	switch feedType {
+	case "plaintext", "csv", "json":
+		return nil
+	}
+	return fmt.Errorf("file feeds must be plaintext, csv or json, not %q", feedType)
+}
+
+// fileVersion identifies the content of a local feed file by modification
+// time and size, standing in for an HTTP validator.
+func fileVersion(info os.FileInfo) string {
+	return strconv.FormatInt(info.ModTime().UnixNano(), 10) + "/" + strconv.FormatInt(info.Size(), 10)
+}
+
+// syncLocal reads a file:// feed and applies it like a fetched one. An
+// unchanged file only refreshes its entries' last-seen time.
+func (m *Manager) syncLocal(feed *Feed, path string, tr *progress.Tracker) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("opening feed file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("reading feed file: %w", err)
+	}
+
+	version := fileVersion(info)
+	m.mu.RLock()
+	unchanged := feed.lastModified == version
+	m.mu.RUnlock()
+	if unchanged {
+		return m.touchEntries(feed, time.Now()), nil
+	}
+
+	tr.SetTotal(info.Size())
+	set := make(keySet)
+	if err := m.parseFeed(tr.Reader(f), feed, set); err != nil {
+		return 0, err
+	}
+	count, err := m.apply(feed, set, time.Now())
+	if err != nil && !errors.Is(err, ErrQuarantined) {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	feed.lastModified = version
+	m.mu.Unlock()
+	return count, err
+}
+
+// SetDropInDir makes every *.txt file in dir a plaintext feed, added and
+// removed as files come and go. An empty dir disables drop-in feeds; a
+// missing one is watched until it appears.
+func (m *Manager) SetDropInDir(dir string) {
+	m.mu.Lock()
+	m.dropInDir = dir
+	m.mu.Unlock()
+	m.scanDropIn()
+}
+
+// scanDropIn adds a feed for each new drop-in file and removes the feeds
+// of files that are gone.
+func (m *Manager) scanDropIn() {
+	m.mu.RLock()
+	dir := m.dropInDir
+	var current []string
+	for name, f := range m.feeds {
+		if f.dropIn {
+			current = append(current, name)
+		}
+	}
+	m.mu.RUnlock()
+	if dir == "" {
+		return
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		m.log.Warn("failed to scan threat intel drop-in directory", zap.String("dir", dir), zap.Error(err))
+		return
+	}
+	sort.Strings(files)
+
+	present := make(map[string]bool, len(files))
+	for _, path := range files {
+		name := dropInPrefix + strings.TrimSuffix(filepath.Base(path), ".txt")
+		present[name] = true
+		if err := m.addDropIn(name, path); err != nil {
+			m.log.Warn("failed to add threat intel drop-in feed", zap.String("file", path), zap.Error(err))
+		}
+	}
+	for _, name := range current {
+		if !present[name] {
+			if err := m.RemoveFeed(name); err != nil {
+				m.log.Warn("failed to remove threat intel drop-in feed", zap.String("feed", name), zap.Error(err))
+			}
+		}
+	}
+}
+
+// addDropIn registers the feed of a drop-in file unless it exists.
+func (m *Manager) addDropIn(name, path string) error {
+	m.mu.RLock()
+	_, exists := m.feeds[name]
+	m.mu.RUnlock()
+	if exists {
+		return nil
+	}
+	if err := m.AddFeed(name, fileScheme+path, "plaintext"); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.feeds[name].dropIn = true
+	m.mu.Unlock()
+	return nil
+}
+
+// changedLocalFeeds returns the enabled local feeds whose file differs
+// from the version last applied.
+func (m *Manager) changedLocalFeeds() []*Feed {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.paused {
+		return nil
+	}
+	var changed []*Feed
+	for _, f := range m.feeds {
+		path, ok := localPath(f.URL)
+		if !ok || !f.Enabled {
+			continue
+		}
+		// A missing file is reported by the next scheduled sync.
+		if info, err := os.Stat(path); err == nil && fileVersion(info) != f.lastModified {
+			changed = append(changed, f)
+		}
+	}
+	return changed
+}
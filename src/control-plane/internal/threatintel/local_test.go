@@ -0,0 +1,135 @@
+package threatintel
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestValidateLocalFeed(t *testing.T) {
+	tests := []struct {
+		url, feedType string
+		wantErr       bool
+	}{
+		{"file:///etc/ddos-scrubber/tor.txt", "plaintext", false},
+		{"file:///srv/intel/feed.csv", "csv", false},
+		{"file://intel/feed.txt", "plaintext", true},
+		{"file:///srv/intel/abuse.json", "abuseipdb", true},
+		{"https://example.net/feed.txt", "abuseipdb", false},
+	}
+	for _, tt := range tests {
+		if err := validateLocalFeed(tt.url, tt.feedType); (err != nil) != tt.wantErr {
+			t.Errorf("%s (%s): err = %v, wantErr %v", tt.url, tt.feedType, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLocalFeedReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.txt")
+	if err := os.WriteFile(path, []byte("# nothing listed yet\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(zap.NewNop(), nil, nil)
+	if err := m.AddFeed("local", fileScheme+path, "plaintext"); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(m.changedLocalFeeds()); got != 1 {
+		t.Fatalf("changed feeds before first sync = %d, want 1", got)
+	}
+	if err := m.SyncNow(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(m.changedLocalFeeds()); got != 0 {
+		t.Errorf("changed feeds after sync = %d, want 0", got)
+	}
+
+	if err := os.WriteFile(path, []byte("# still nothing listed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(m.changedLocalFeeds()); got != 1 {
+		t.Errorf("changed feeds after rewrite = %d, want 1", got)
+	}
+
+	os.Remove(path)
+	if err := m.SyncNow(); err == nil {
+		t.Error("sync of a missing file: expected error")
+	}
+}
+
+func TestDropInDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"tor.txt", "scanners.txt", "notes.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := NewManager(zap.NewNop(), nil, nil)
+	m.SetDropInDir(dir)
+	feeds := map[string]Feed{}
+	for _, f := range m.GetFeeds() {
+		feeds[f.Name] = f
+	}
+	if f, ok := feeds["dropin:tor"]; !ok || f.Type != "plaintext" || f.URL != fileScheme+filepath.Join(dir, "tor.txt") {
+		t.Errorf("dropin:tor = %+v, %v", f, ok)
+	}
+	if _, ok := feeds["dropin:scanners"]; !ok {
+		t.Error("dropin:scanners not added")
+	}
+	if _, ok := feeds["dropin:notes"]; ok {
+		t.Error("non-.txt file added as a feed")
+	}
+
+	os.Remove(filepath.Join(dir, "tor.txt"))
+	m.scanDropIn()
+	for _, f := range m.GetFeeds() {
+		if f.Name == "dropin:tor" {
+			t.Error("feed of removed file kept")
+		}
+	}
+
+	// A missing directory is not an error; feeds appear once it exists.
+	m.SetDropInDir(filepath.Join(dir, "intel.d"))
+	for _, f := range m.GetFeeds() {
+		if f.dropIn {
+			t.Errorf("drop-in feed %s kept after the directory changed", f.Name)
+		}
+	}
+}
+
+func TestSyncFeedsConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(zap.NewNop(), nil, nil)
+	for i := 0; i < 2*syncWorkers; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("feed%d.txt", i))
+		if err := os.WriteFile(path, []byte("# nothing listed\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.AddFeed(fmt.Sprintf("local%d", i), fileScheme+path, "plaintext"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.AddFeed("missing", fileScheme+filepath.Join(dir, "missing.txt"), "plaintext"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.SyncNow(); err == nil {
+		t.Error("sync with a missing file: expected error")
+	}
+	for _, f := range m.GetFeeds() {
+		if !strings.HasPrefix(f.URL, fileScheme) {
+			continue
+		}
+		if _, ok := f.SyncProgress(); ok {
+			t.Errorf("%s: progress reported after the sync", f.Name)
+		}
+		if synced := !f.LastSync.IsZero(); synced != (f.Name != "missing") {
+			t.Errorf("%s: synced = %v, error %q", f.Name, synced, f.Error)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package threatintel
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Pushed indicators are sent by internal detection systems through the
+// API rather than pulled. Each pushing source gets a feed of type "push"
+// that the sync loop leaves alone; its entries carry their own threat
+// type, confidence, action and expiry.
+const (
+	feedTypePush = "push"
+
+	// DefaultPushSource is the feed indicators are pushed to when the
+	// request names none.
+	DefaultPushSource = "push"
+
+	// DefaultPushTTL is how long a pushed indicator lasts unless it sets
+	// its own TTL.
+	DefaultPushTTL = 24 * time.Hour
+
+	// MaxPushBatch caps the indicators accepted per push.
+	MaxPushBatch = 10000
+
+	// MaxPushSources caps the push feeds; pushes naming a new source are
+	// refused once it is reached.
+	MaxPushSources = 16
+
+	defaultPushConfidence = 80
+)
+
+// Indicator is one pushed threat intel entry.
+type Indicator struct {
+	Prefix     string // Address or CIDR
+	ThreatType uint8
+	Confidence uint8
+	Action     uint8
+	TTL        time.Duration // Zero uses DefaultPushTTL
+}
+
+// pulled reports whether the sync loop fetches the feed.
+func (f *Feed) pulled() bool {
+	return f.Type != feedTypePush
+}
+
+// Push installs indicators in the push feed source, creating it on first
+// use. An indicator already pushed is replaced, its expiry restarted. The
+// batch is checked as a whole before anything is installed. Pushes are
+// refused with ErrPaused on nodes that mirror another's feeds.
+func (m *Manager) Push(source string, indicators []Indicator) (int, error) {
+	if source == "" {
+		source = DefaultPushSource
+	}
+	if len(indicators) > MaxPushBatch {
+		return 0, fmt.Errorf("at most %d indicators per push, got %d", MaxPushBatch, len(indicators))
+	}
+	eg := m.newGuard()
+	keys := make([]lpmKeyV4, len(indicators))
+	for i, ind := range indicators {
+		key, err := parseLPMKey(ind.Prefix)
+		if err != nil {
+			return 0, fmt.Errorf("indicator %d: %w", i, err)
+		}
+		if err := eg.check(key); err != nil {
+			return 0, fmt.Errorf("indicator %d: %w", i, err)
+		}
+		if ind.Confidence > 100 {
+			return 0, fmt.Errorf("indicator %d: confidence must be 0-100", i)
+		}
+		if _, ok := actionNames[ind.Action]; !ok {
+			return 0, fmt.Errorf("indicator %d: invalid action %d", i, ind.Action)
+		}
+		if _, ok := threatTypeNames[ind.ThreatType]; !ok {
+			return 0, fmt.Errorf("indicator %d: invalid threat type %d", i, ind.ThreatType)
+		}
+		if ind.TTL < 0 {
+			return 0, fmt.Errorf("indicator %d: ttl must not be negative", i)
+		}
+		keys[i] = key
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.paused {
+		return 0, ErrPaused
+	}
+	feed, err := m.pushFeedLocked(source)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	w := m.newWriter()
+	for i, ind := range indicators {
+		ttl := ind.TTL
+		if ttl == 0 {
+			ttl = DefaultPushTTL
+		}
+		key := keys[i]
+		feed.entries[key] = now
+		feed.scores[key] = entryMeta{ThreatType: ind.ThreatType, Confidence: ind.Confidence, Action: ind.Action}
+		feed.pushed[key] = now.Add(ttl)
+		m.insertEntry(w, key, feed)
+	}
+	w.Flush()
+
+	feed.LastSync = now
+	m.countEntriesLocked(feed)
+
+	m.log.Info("threat indicators pushed",
+		zap.String("feed", source),
+		zap.Int("indicators", len(indicators)),
+		zap.Int("entries", feed.EntryCount),
+	)
+	return len(indicators), nil
+}
+
+// pushFeedLocked returns the push feed name, creating it if needed and
+// MaxPushSources and the source IDs allow.
+func (m *Manager) pushFeedLocked(name string) (*Feed, error) {
+	feed, ok := m.feeds[name]
+	if ok && feed.pulled() {
+		return nil, fmt.Errorf("feed %q is not a push feed", name)
+	}
+	if !ok {
+		sources := 0
+		for _, f := range m.feeds {
+			if !f.pulled() {
+				sources++
+			}
+		}
+		if sources >= MaxPushSources {
+			return nil, fmt.Errorf("at most %d push sources, push to an existing one", MaxPushSources)
+		}
+		id, err := m.allocSourceIDLocked()
+		if err != nil {
+			return nil, err
+		}
+		feed = &Feed{
+			Name:       name,
+			Type:       feedTypePush,
+			Enabled:    true,
+			Confidence: defaultPushConfidence,
+			SourceID:   id,
+		}
+		m.feeds[name] = feed
+		m.log.Info("threat push feed created", zap.String("name", name))
+	}
+	if feed.entries == nil {
+		feed.entries = make(map[lpmKeyV4]time.Time)
+	}
+	if feed.scores == nil {
+		feed.scores = make(map[lpmKeyV4]entryMeta)
+	}
+	if feed.pushed == nil {
+		feed.pushed = make(map[lpmKeyV4]time.Time)
+	}
+	return feed, nil
+}
+
+// expirePushed removes pushed indicators past their expiry.
+func (m *Manager) expirePushed(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, feed := range m.feeds {
+		if feed.pulled() {
+			continue
+		}
+		w := m.newWriter()
+		expired := 0
+		for key, expires := range feed.pushed {
+			if !now.Before(expires) {
+				m.removeEntryLocked(w, key, feed)
+				expired++
+			}
+		}
+		w.Flush()
+		if expired > 0 {
+			m.countEntriesLocked(feed)
+			m.log.Debug("pushed threat indicators expired",
+				zap.String("feed", feed.Name), zap.Int("expired", expired))
+		}
+	}
+}
+
+// countEntriesLocked refreshes the entry counts after feed changed outside
+// a sync.
+func (m *Manager) countEntriesLocked(feed *Feed) {
+	feed.EntryCount = len(feed.entries)
+	m.totalEntries = 0
+	for _, f := range m.feeds {
+		m.totalEntries += len(f.entries)
+	}
+}
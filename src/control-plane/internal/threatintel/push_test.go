@@ -0,0 +1,124 @@
+package threatintel
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestPushValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		ind  Indicator
+	}{
+		{"bad prefix", Indicator{Prefix: "not-an-ip"}},
+		{"confidence", Indicator{Prefix: "198.51.100.7", Confidence: 101}},
+		{"action", Indicator{Prefix: "198.51.100.7", Action: 9}},
+		{"threat type", Indicator{Prefix: "198.51.100.7", ThreatType: 9}},
+		{"ttl", Indicator{Prefix: "198.51.100.7", TTL: -time.Second}},
+		{"reserved", Indicator{Prefix: "10.0.0.1"}},
+	}
+	m := NewManager(zap.NewNop(), nil, nil)
+	for _, tt := range tests {
+		if _, err := m.Push("ids", []Indicator{tt.ind}); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+	for _, f := range m.GetFeeds() {
+		if f.Name == "ids" {
+			t.Error("push feed created by a rejected batch")
+		}
+	}
+
+	if _, err := m.Push("ids", make([]Indicator, MaxPushBatch+1)); err == nil {
+		t.Error("oversized batch: expected error")
+	}
+}
+
+func TestPushFeedIsolation(t *testing.T) {
+	m := NewManager(zap.NewNop(), nil, nil)
+
+	// Pushing to a pulled feed would mix its entries with the feed's own.
+	if _, err := m.Push("spamhaus-drop", nil); err == nil {
+		t.Error("push to a pulled feed: expected error")
+	}
+
+	if _, err := m.Push("ids", nil); err != nil {
+		t.Fatal(err)
+	}
+	var feed Feed
+	for _, f := range m.GetFeeds() {
+		if f.Name == "ids" {
+			feed = f
+		}
+	}
+	if feed.Type != feedTypePush || !feed.Enabled {
+		t.Fatalf("push feed = %+v", feed)
+	}
+	for _, f := range m.dueFeeds(time.Now()) {
+		if f.Name == "ids" {
+			t.Error("push feed scheduled for a pull")
+		}
+	}
+
+	m.SetPaused(true)
+	if _, err := m.Push("ids", nil); !errors.Is(err, ErrPaused) {
+		t.Errorf("push while paused: err = %v, want ErrPaused", err)
+	}
+}
+
+func TestPushSourceLimit(t *testing.T) {
+	m := NewManager(zap.NewNop(), nil, nil)
+	for i := 0; i < MaxPushSources; i++ {
+		if _, err := m.Push(fmt.Sprintf("ids-%d", i), nil); err != nil {
+			t.Fatalf("push source %d: %v", i, err)
+		}
+	}
+	if _, err := m.Push("one-too-many", nil); err == nil {
+		t.Error("push source beyond MaxPushSources: expected error")
+	}
+	if _, err := m.Push("ids-0", nil); err != nil {
+		t.Errorf("push to an existing source: %v", err)
+	}
+
+	ids := make(map[uint8]string)
+	for _, f := range m.GetFeeds() {
+		if other, dup := ids[f.SourceID]; dup {
+			t.Errorf("feeds %s and %s share source ID %d", other, f.Name, f.SourceID)
+		}
+		ids[f.SourceID] = f.Name
+	}
+
+	// Once every ID is held, new feeds fail until one is removed; its ID
+	// is then reused with the counters reset.
+	var reset []uint8
+	m.SetStatsReset(func(id uint8) error {
+		reset = append(reset, id)
+		return nil
+	})
+	for i := 0; len(m.feeds) <= math.MaxUint8; i++ {
+		if err := m.AddFeed(fmt.Sprintf("list-%d", i), "https://example.com/list.txt", "plaintext"); err != nil {
+			t.Fatalf("AddFeed %d: %v", i, err)
+		}
+	}
+	if err := m.AddFeed("late", "https://example.com/list.txt", "plaintext"); !errors.Is(err, ErrNoSourceIDs) {
+		t.Errorf("AddFeed with no IDs left: err = %v, want ErrNoSourceIDs", err)
+	}
+	freed := m.feeds["list-7"].SourceID
+	if err := m.RemoveFeed("list-7"); err != nil {
+		t.Fatalf("RemoveFeed: %v", err)
+	}
+	if len(reset) != 1 || reset[0] != freed {
+		t.Errorf("counters reset for %v, want [%d]", reset, freed)
+	}
+	if err := m.AddFeed("late", "https://example.com/list.txt", "plaintext"); err != nil {
+		t.Fatalf("AddFeed after a removal: %v", err)
+	}
+	if got := m.feeds["late"].SourceID; got != freed {
+		t.Errorf("new feed source ID = %d, want reused %d", got, freed)
+	}
+}
@@ -8,16 +8,21 @@ import (
 	"encoding/binary"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 	"unsafe"
 
 	"github.com/cilium/ebpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/progress"
 	"go.uber.org/zap"
 )
 
@@ -27,6 +32,22 @@ const defaultSyncInterval = 1 * time.Hour
 // HTTP client timeout for feed fetches.
 const httpTimeout = 60 * time.Second
 
+// scheduleTick is how often the run loop looks for feeds due a sync.
+const scheduleTick = time.Minute
+
+// syncWorkers is how many feeds sync at once. Fetching and parsing run in
+// parallel; installing entries is serialized by the manager's lock.
+const syncWorkers = 4
+
+// progressLogInterval is how often feed syncs in progress are logged.
+const progressLogInterval = 10 * time.Second
+
+// ErrPaused is returned by SyncNow while feed pulls are paused.
+var ErrPaused = errors.New("feed pulls are paused on this node")
+
+// ErrNoSourceIDs is returned when every source_id is held by a feed.
+var ErrNoSourceIDs = errors.New("no threat feed source IDs left")
+
 // lpmKeyV4 matches struct lpm_key_v4 in the BPF program.
 type lpmKeyV4 struct {
 	PrefixLen uint32
@@ -46,20 +67,171 @@ type threatIntelEntry struct {
 type Feed struct {
 	Name       string
 	URL        string
-	Type       string // "plaintext", "csv", "json"
+	Type       string // "plaintext", "csv", "json", "abuseipdb", "blocklistde"
 	Enabled    bool
 	LastSync   time.Time
 	EntryCount int
 	Error      string
 
+	// SyncDuration is how long the last sync attempt took, from fetch to
+	// the last BPF map update.
+	SyncDuration time.Duration
+
 	// CSV-specific configuration.
 	CSVColumn int // Column index containing IP/CIDR (0-based).
 
+	// JSON maps the fields of a JSON feed of records.
+	JSON JSONMapping
+
+	// MaxEntries overrides the guard's per-feed entry limit. Zero uses
+	// it.
+	MaxEntries int
+
+	// Quarantine holds the last sync if it was judged anomalous, until
+	// approved or rejected.
+	Quarantine *Quarantine
+
 	// Feed metadata for BPF entries.
 	SourceID   uint8
 	ThreatType uint8
 	Confidence uint8
 	Action     uint8 // Default action: 0=drop, 1=rate-limit, 2=monitor.
+
+	// EntryTTL keeps entries that disappear from the feed for this long
+	// before removing them from the BPF map. Zero removes them on the next
+	// successful sync.
+	EntryTTL time.Duration
+
+	// APIKey authenticates requests to providers that require one
+	// (AbuseIPDB). It is sent as a header, never in the URL.
+	APIKey string
+
+	// Scored feeds (AbuseIPDB) skip entries below MinConfidence, drop
+	// entries at or above BlockConfidence and rate-limit the rest.
+	MinConfidence   uint8
+	BlockConfidence uint8
+
+	// RateLimit caps the HTTP requests made for this feed so syncs stay
+	// within the provider's quota.
+	RateLimit RateLimit
+	quota     quota
+
+	// SyncInterval overrides the manager's sync interval. Zero uses it.
+	SyncInterval time.Duration
+	lastAttempt  time.Time
+
+	// Headers are added to every request, e.g. a provider's API key
+	// header.
+	Headers map[string]string
+
+	// progress tracks the bytes read by a sync in progress.
+	progress *progress.Tracker
+
+	// Validators from the last successful fetch, sent back as
+	// If-None-Match / If-Modified-Since.
+	etag         string
+	lastModified string
+
+	// entries holds the keys currently installed for this feed and when
+	// each was last present in the feed.
+	entries map[lpmKeyV4]time.Time
+
+	// scores holds per-entry metadata for scored feeds, overriding the
+	// feed's Confidence and Action.
+	scores map[lpmKeyV4]entryMeta
+
+	// dropIn marks the feed of a file in the drop-in directory.
+	dropIn bool
+
+	// pushed holds when each entry of a push feed expires.
+	pushed map[lpmKeyV4]time.Time
+}
+
+// entryMeta is the per-entry threat type, confidence and action of a
+// scored, mapped or pushed feed.
+type entryMeta struct {
+	ThreatType uint8
+	Confidence uint8
+	Action     uint8
+}
+
+// keySet is the set of LPM keys parsed from a single feed fetch. Entries
+// of scored feeds carry their own metadata; nil uses the feed defaults.
+type keySet map[lpmKeyV4]*entryMeta
+
+// add parses an IP or CIDR string and adds it to the set.
+func (ks keySet) add(ipOrCIDR string) error {
+	key, err := parseLPMKey(ipOrCIDR)
+	if err != nil {
+		return err
+	}
+	ks[key] = nil
+	return nil
+}
+
+// addScored adds an IP or CIDR with its own confidence and action.
+func (ks keySet) addScored(ipOrCIDR string, meta entryMeta) error {
+	key, err := parseLPMKey(ipOrCIDR)
+	if err != nil {
+		return err
+	}
+	ks[key] = &meta
+	return nil
+}
+
+// Entry actions, matching threat_intel_entry.action in types.h.
+var actionNames = map[uint8]string{0: "drop", 1: "rate_limit", 2: "monitor"}
+
+// Threat types, matching threat_intel_entry.threat_type in types.h.
+var threatTypeNames = map[uint8]string{0: "botnet", 1: "scanner", 2: "tor_exit", 3: "proxy", 4: "malware"}
+
+// ParseAction parses an entry action name (drop, rate_limit or monitor).
+func ParseAction(name string) (uint8, error) {
+	for action, n := range actionNames {
+		if strings.EqualFold(name, n) {
+			return action, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid action %q (must be drop, rate_limit or monitor)", name)
+}
+
+// ParseThreatType parses a threat type name (botnet, scanner, tor_exit,
+// proxy or malware).
+func ParseThreatType(name string) (uint8, error) {
+	for t, n := range threatTypeNames {
+		if strings.EqualFold(name, n) {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid threat type %q (must be botnet, scanner, tor_exit, proxy or malware)", name)
+}
+
+// ActionName returns the name of an entry action.
+func ActionName(action uint8) string {
+	if n, ok := actionNames[action]; ok {
+		return n
+	}
+	return fmt.Sprintf("unknown(%d)", action)
+}
+
+// ThreatTypeName returns the name of a threat type.
+func ThreatTypeName(t uint8) string {
+	if n, ok := threatTypeNames[t]; ok {
+		return n
+	}
+	return fmt.Sprintf("unknown(%d)", t)
+}
+
+// Match is a feed entry covering a looked-up address.
+type Match struct {
+	Feed       string
+	Prefix     string
+	ThreatType uint8
+	Confidence uint8
+	Action     uint8
+	LastSeen   time.Time // when the entry was last present in the feed
+
+	prefixLen uint32
 }
 
 // Stats holds aggregate threat intelligence statistics.
@@ -67,6 +239,7 @@ type Stats struct {
 	TotalEntries int
 	LastSync     time.Time
 	FeedCount    int
+	Paused       bool
 }
 
 // Manager fetches and syncs external threat intelligence feeds to BPF maps.
@@ -78,10 +251,15 @@ type Manager struct {
 
 	mu           sync.RWMutex
 	feeds        map[string]*Feed
-	nextSourceID uint8
+	statsReset   func(sourceID uint8) error // Zeroes a source_id's threat_intel_stats slot
 	totalEntries int
 	lastSync     time.Time
 	syncInterval time.Duration
+	paused       bool   // Another node pulls the feeds
+	dropInDir    string // Directory of *.txt drop-in feeds, see SetDropInDir
+
+	guard           GuardConfig
+	protectedSource func() []string // Whitelist and assets, see SetProtectedSource
 }
 
 // NewManager creates a new threat intelligence manager.
@@ -94,7 +272,6 @@ func NewManager(log *zap.Logger, threatMap, blacklistMap *ebpf.Map) *Manager {
 			Timeout: httpTimeout,
 		},
 		feeds:        make(map[string]*Feed),
-		nextSourceID: 0,
 		syncInterval: defaultSyncInterval,
 	}
 
@@ -104,7 +281,8 @@ func NewManager(log *zap.Logger, threatMap, blacklistMap *ebpf.Map) *Manager {
 	return m
 }
 
-// registerBuiltinFeeds adds the preconfigured Spamhaus feeds.
+// registerBuiltinFeeds adds the preconfigured Spamhaus, AbuseIPDB and
+// blocklist.de feeds.
 func (m *Manager) registerBuiltinFeeds() {
 	m.feeds["spamhaus-drop"] = &Feed{
 		Name:       "spamhaus-drop",
@@ -116,7 +294,6 @@ func (m *Manager) registerBuiltinFeeds() {
 		Confidence: 100,
 		Action:     0, // drop
 	}
-	m.nextSourceID++
 
 	m.feeds["spamhaus-edrop"] = &Feed{
 		Name:       "spamhaus-edrop",
@@ -128,7 +305,31 @@ func (m *Manager) registerBuiltinFeeds() {
 		Confidence: 100,
 		Action:     0, // drop
 	}
-	m.nextSourceID++
+
+	// Needs an API key; the free plan allows 5 blacklist requests a day.
+	m.feeds["abuseipdb"] = &Feed{
+		Name:            "abuseipdb",
+		URL:             abuseIPDBBlacklistURL,
+		Type:            "abuseipdb",
+		Enabled:         false,
+		SourceID:        2,
+		ThreatType:      1, // scanner
+		MinConfidence:   defaultMinConfidence,
+		BlockConfidence: defaultBlockConfidence,
+		RateLimit:       RateLimit{Requests: 5, Per: 24 * time.Hour},
+	}
+
+	m.feeds["blocklistde-all"] = &Feed{
+		Name:       "blocklistde-all",
+		URL:        blocklistDEURL("all"),
+		Type:       "blocklistde",
+		Enabled:    false,
+		SourceID:   3,
+		ThreatType: 1, // scanner
+		Confidence: 80,
+		Action:     0, // drop
+		RateLimit:  RateLimit{Requests: 1, Per: blocklistDEMinInterval},
+	}
 }
 
 // AddFeed registers a new threat feed.
@@ -139,12 +340,38 @@ func (m *Manager) AddFeed(name, url, feedType string) error {
 	if url == "" {
 		return fmt.Errorf("feed URL is required")
 	}
+	if err := validateLocalFeed(url, feedType); err != nil {
+		return err
+	}
 
+	feed := &Feed{
+		Name:       name,
+		URL:        url,
+		Type:       feedType,
+		Enabled:    true,
+		ThreatType: 0,  // Default: botnet.
+		Confidence: 80, // Default confidence.
+		Action:     0,  // Default: drop.
+	}
 	switch feedType {
 	case "plaintext", "csv", "json":
 		// Valid.
+	case "abuseipdb":
+		feed.ThreatType = 1 // scanner
+		feed.MinConfidence = defaultMinConfidence
+		feed.BlockConfidence = defaultBlockConfidence
+	case "blocklistde":
+		// A bare service name such as "ssh" selects that list.
+		if !strings.Contains(url, "/") {
+			if !blocklistDEServices[url] {
+				return fmt.Errorf("unknown blocklist.de service %q", url)
+			}
+			feed.URL = blocklistDEURL(url)
+		}
+		feed.ThreatType = 1 // scanner
+		feed.RateLimit = RateLimit{Requests: 1, Per: blocklistDEMinInterval}
 	default:
-		return fmt.Errorf("unsupported feed type %q: must be plaintext, csv, or json", feedType)
+		return fmt.Errorf("unsupported feed type %q: must be plaintext, csv, json, abuseipdb, or blocklistde", feedType)
 	}
 
 	m.mu.Lock()
@@ -154,149 +381,459 @@ func (m *Manager) AddFeed(name, url, feedType string) error {
 		return fmt.Errorf("feed %q already exists", name)
 	}
 
-	m.feeds[name] = &Feed{
-		Name:       name,
-		URL:        url,
-		Type:       feedType,
-		Enabled:    true,
-		SourceID:   m.nextSourceID,
-		ThreatType: 0,  // Default: botnet.
-		Confidence: 80, // Default confidence.
-		Action:     0,  // Default: drop.
+	id, err := m.allocSourceIDLocked()
+	if err != nil {
+		return err
 	}
-	m.nextSourceID++
+	feed.SourceID = id
+	m.feeds[name] = feed
 
 	m.log.Info("threat feed added",
 		zap.String("name", name),
-		zap.String("url", url),
+		zap.String("url", feed.URL),
 		zap.String("type", feedType),
 	)
 
 	return nil
 }
 
-// RemoveFeed removes a feed and optionally clears its entries.
+// allocSourceIDLocked returns the lowest source_id no feed holds. IDs
+// index the per-feed counters in threat_intel_stats; RemoveFeed zeroes a
+// feed's slot before its ID can be handed out again. Caller must hold m.mu.
+func (m *Manager) allocSourceIDLocked() (uint8, error) {
+	var used [math.MaxUint8 + 1]bool
+	for _, f := range m.feeds {
+		used[f.SourceID] = true
+	}
+	for id, taken := range used {
+		if !taken {
+			return uint8(id), nil
+		}
+	}
+	return 0, ErrNoSourceIDs
+}
+
+// SetStatsReset sets the function that zeroes a source_id's counters in
+// threat_intel_stats, called when a feed is removed so that the next feed
+// given the ID starts from zero.
+func (m *Manager) SetStatsReset(fn func(sourceID uint8) error) {
+	m.mu.Lock()
+	m.statsReset = fn
+	m.mu.Unlock()
+}
+
+// RemoveFeed removes a feed and clears its entries from the BPF map.
 func (m *Manager) RemoveFeed(name string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, exists := m.feeds[name]; !exists {
+	feed, exists := m.feeds[name]
+	if !exists {
 		return fmt.Errorf("feed %q not found", name)
 	}
 
+	w := m.newWriter()
+	for key := range feed.entries {
+		m.removeEntryLocked(w, key, feed)
+	}
 	delete(m.feeds, name)
+	w.Flush()
+	if m.statsReset != nil {
+		if err := m.statsReset(feed.SourceID); err != nil {
+			m.log.Warn("failed to reset threat feed counters",
+				zap.String("name", name), zap.Uint8("source_id", feed.SourceID), zap.Error(err))
+		}
+	}
 
 	m.log.Info("threat feed removed", zap.String("name", name))
 	return nil
 }
 
-// Start begins periodic sync of all enabled feeds.
+// Start syncs all enabled feeds, then syncs each again whenever its
+// interval elapses.
 func (m *Manager) Start(ctx context.Context) error {
 	// Perform initial sync.
 	m.SyncNow()
 
 	go m.run(ctx)
+	return nil
+}
 
+// Run is Start for callers that track the loop themselves: it performs
+// the initial sync and blocks until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	m.SyncNow()
+	m.run(ctx)
+}
+
+func (m *Manager) run(ctx context.Context) {
+	m.mu.RLock()
 	m.log.Info("threat intel manager started",
 		zap.Duration("sync_interval", m.syncInterval),
 		zap.Int("feeds", len(m.feeds)),
 	)
-	return nil
-}
+	m.mu.RUnlock()
 
-func (m *Manager) run(ctx context.Context) {
-	ticker := time.NewTicker(m.syncInterval)
+	ticker := time.NewTicker(scheduleTick)
 	defer ticker.Stop()
+	watch := time.NewTicker(localWatchInterval)
+	defer watch.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			m.log.Info("threat intel manager stopped")
 			return
-		case <-ticker.C:
-			m.SyncNow()
+		case now := <-ticker.C:
+			if feeds := m.dueFeeds(now); len(feeds) > 0 {
+				m.syncFeeds(feeds)
+			}
+			m.expirePushed(now)
+		case <-watch.C:
+			m.scanDropIn()
+			if feeds := m.changedLocalFeeds(); len(feeds) > 0 {
+				m.syncFeeds(feeds)
+			}
+		}
+	}
+}
+
+// dueFeeds returns the enabled feeds whose sync interval has elapsed since
+// their last attempt.
+func (m *Manager) dueFeeds(now time.Time) []*Feed {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.paused {
+		return nil
+	}
+	var due []*Feed
+	for _, f := range m.feeds {
+		interval := f.SyncInterval
+		if interval == 0 {
+			interval = m.syncInterval
+		}
+		if f.Enabled && f.pulled() && now.Sub(f.lastAttempt) >= interval {
+			due = append(due, f)
 		}
 	}
+	return due
 }
 
 // SyncNow forces immediate sync of all enabled feeds.
 func (m *Manager) SyncNow() error {
 	m.mu.RLock()
+	if m.paused {
+		m.mu.RUnlock()
+		return ErrPaused
+	}
 	feeds := make([]*Feed, 0, len(m.feeds))
 	for _, f := range m.feeds {
-		if f.Enabled {
+		if f.Enabled && f.pulled() {
 			feeds = append(feeds, f)
 		}
 	}
 	m.mu.RUnlock()
 
-	totalEntries := 0
-	var lastErr error
+	return m.syncFeeds(feeds)
+}
 
+// syncFeeds syncs the given feeds, syncWorkers at a time, and returns the
+// last error.
+func (m *Manager) syncFeeds(feeds []*Feed) error {
+	var (
+		errMu   sync.Mutex
+		lastErr error
+		wg      sync.WaitGroup
+	)
+	jobs := make(chan *Feed)
+	for i := 0; i < syncWorkers && i < len(feeds); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for feed := range jobs {
+				if err := m.syncOne(feed); err != nil {
+					errMu.Lock()
+					lastErr = err
+					errMu.Unlock()
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go m.logSyncProgress(done)
 	for _, feed := range feeds {
-		count, err := m.syncFeed(feed)
-		if err != nil {
-			m.mu.Lock()
-			feed.Error = err.Error()
-			m.mu.Unlock()
-
-			m.log.Warn("feed sync failed",
-				zap.String("feed", feed.Name),
-				zap.Error(err),
-			)
-			lastErr = err
-			continue
-		}
+		jobs <- feed
+	}
+	close(jobs)
+	wg.Wait()
+	close(done)
+
+	m.mu.Lock()
+	m.totalEntries = 0
+	for _, f := range m.feeds {
+		m.totalEntries += len(f.entries)
+	}
+	m.lastSync = time.Now()
+	m.mu.Unlock()
+
+	return lastErr
+}
+
+// syncOne syncs a feed and records the outcome in its status.
+func (m *Manager) syncOne(feed *Feed) error {
+	start := time.Now()
+	tr := progress.New(0)
+	m.mu.Lock()
+	feed.lastAttempt = start
+	feed.progress = tr
+	m.mu.Unlock()
 
+	count, err := m.syncFeed(feed, tr)
+	m.expireEntries(feed, time.Now())
+	took := time.Since(start)
+	if err != nil {
 		m.mu.Lock()
-		feed.LastSync = time.Now()
-		feed.EntryCount = count
-		feed.Error = ""
+		feed.Error = err.Error()
+		feed.SyncDuration = took
+		feed.progress = nil
 		m.mu.Unlock()
 
-		totalEntries += count
-
-		m.log.Info("feed synced",
+		m.log.Warn("feed sync failed",
 			zap.String("feed", feed.Name),
-			zap.Int("entries", count),
+			zap.Duration("duration", took),
+			zap.Error(err),
 		)
+		return err
 	}
 
 	m.mu.Lock()
-	m.totalEntries = totalEntries
-	m.lastSync = time.Now()
+	feed.LastSync = time.Now()
+	feed.EntryCount = count
+	feed.Error = ""
+	feed.SyncDuration = took
+	feed.progress = nil
 	m.mu.Unlock()
 
-	return lastErr
+	m.log.Info("feed synced",
+		zap.String("feed", feed.Name),
+		zap.Int("entries", count),
+		zap.Duration("duration", took),
+	)
+	return nil
+}
+
+// logSyncProgress logs the progress of the feed syncs in progress until
+// done is closed.
+func (m *Manager) logSyncProgress(done <-chan struct{}) {
+	ticker := time.NewTicker(progressLogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			for _, f := range m.GetFeeds() {
+				s, ok := f.SyncProgress()
+				if !ok {
+					continue
+				}
+				m.log.Info("feed sync in progress",
+					zap.String("feed", f.Name),
+					zap.String("percent", fmt.Sprintf("%.1f", s.Percent)),
+					zap.Int64("bytes", s.Done),
+					zap.Float64("bytes_per_sec", s.Rate),
+					zap.Duration("eta", s.ETA.Round(time.Second)),
+				)
+			}
+		}
+	}
+}
+
+// SyncProgress returns the progress of the feed's sync in progress, if
+// any, in bytes read. The total is unknown for responses without a
+// Content-Length.
+func (f Feed) SyncProgress() (progress.Snapshot, bool) {
+	if f.progress == nil {
+		return progress.Snapshot{}, false
+	}
+	return f.progress.Snapshot(), true
 }
 
-// syncFeed fetches a single feed and inserts entries into the BPF map.
-func (m *Manager) syncFeed(feed *Feed) (int, error) {
-	resp, err := m.httpClient.Get(feed.URL)
+// syncFeed fetches a single feed and applies the difference against the
+// previously installed keyset to the BPF map. The body is parsed as it is
+// read, with the bytes read recorded in tr. It returns the number of
+// entries installed for the feed.
+func (m *Manager) syncFeed(feed *Feed, tr *progress.Tracker) (int, error) {
+	if path, ok := localPath(feed.URL); ok {
+		return m.syncLocal(feed, path, tr)
+	}
+
+	set := make(keySet)
+	if feed.Type == "abuseipdb" {
+		if err := m.fetchAbuseIPDB(feed, set); err != nil {
+			return 0, err
+		}
+		return m.apply(feed, set, time.Now())
+	}
+
+	resp, err := m.fetch(feed, feed.URL, m.conditionalHeader(feed))
+	if errors.Is(err, errNotModified) {
+		return m.touchEntries(feed, time.Now()), nil
+	}
 	if err != nil {
-		return 0, fmt.Errorf("fetching %s: %w", feed.URL, err)
+		return 0, err
 	}
 	defer resp.Body.Close()
+	if resp.ContentLength > 0 {
+		tr.SetTotal(resp.ContentLength)
+	}
+
+	if err := m.parseFeed(tr.Reader(resp.Body), feed, set); err != nil {
+		return 0, err
+	}
 
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("HTTP %d from %s", resp.StatusCode, feed.URL)
+	count, err := m.apply(feed, set, time.Now())
+	if err != nil && !errors.Is(err, ErrQuarantined) {
+		return 0, err
 	}
 
+	// Only remember validators once the body has been applied (or
+	// quarantined), so a failed parse is retried in full rather than
+	// answered with 304.
+	m.mu.Lock()
+	feed.etag = resp.Header.Get("ETag")
+	feed.lastModified = resp.Header.Get("Last-Modified")
+	m.mu.Unlock()
+
+	return count, err
+}
+
+// parseFeed parses a feed body of the feed's type into set.
+func (m *Manager) parseFeed(r io.Reader, feed *Feed, set keySet) error {
+	var err error
 	switch feed.Type {
-	case "plaintext":
-		return m.parsePlaintext(resp.Body, feed)
+	case "plaintext", "blocklistde":
+		_, err = m.parsePlaintext(r, feed, set)
 	case "csv":
-		return m.parseCSV(resp.Body, feed)
+		_, err = m.parseCSV(r, feed, set)
 	case "json":
-		return m.parseJSON(resp.Body, feed)
+		_, err = m.parseJSON(r, feed, set)
 	default:
-		return 0, fmt.Errorf("unsupported feed type: %s", feed.Type)
+		return fmt.Errorf("unsupported feed type: %s", feed.Type)
 	}
+	return err
+}
+
+// applyDelta inserts keys new to the feed, refreshes the last-seen time of
+// keys still present, and removes keys withdrawn from the feed once their
+// TTL has elapsed.
+func (m *Manager) applyDelta(feed *Feed, set keySet, now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if feed.entries == nil {
+		feed.entries = make(map[lpmKeyV4]time.Time, len(set))
+	}
+	if feed.scores == nil {
+		feed.scores = make(map[lpmKeyV4]entryMeta)
+	}
+
+	// Keys that fail to install are dropped from the feed so the next sync
+	// retries them.
+	w := m.newWriter()
+	w.OnError(func(key lpmKeyV4, err error) {
+		delete(feed.entries, key)
+		m.log.Debug("threat entry write failed", zap.String("prefix", formatLPMKey(key)), zap.Error(err))
+	})
+
+	added, removed := 0, 0
+	for key, meta := range set {
+		_, installed := feed.entries[key]
+		if meta != nil {
+			if old, ok := feed.scores[key]; !ok || old != *meta {
+				installed = false // rewrite with the new score
+			}
+			feed.scores[key] = *meta
+		} else {
+			delete(feed.scores, key)
+		}
+		if !installed {
+			m.insertEntry(w, key, feed)
+			added++
+		}
+		feed.entries[key] = now
+	}
+
+	for key, lastSeen := range feed.entries {
+		if _, ok := set[key]; ok {
+			continue
+		}
+		if now.Sub(lastSeen) < feed.EntryTTL {
+			continue
+		}
+		m.removeEntryLocked(w, key, feed)
+		removed++
+	}
+	w.Flush()
+
+	if added > 0 || removed > 0 {
+		m.log.Debug("feed delta applied",
+			zap.String("feed", feed.Name),
+			zap.Int("added", added),
+			zap.Int("removed", removed),
+			zap.Int("total", len(feed.entries)),
+		)
+	}
+
+	return len(feed.entries)
+}
+
+// expireEntries removes entries whose TTL has elapsed since they were last
+// seen. This ages out entries of feeds that keep failing to sync.
+func (m *Manager) expireEntries(feed *Feed, now time.Time) {
+	if feed.EntryTTL == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w := m.newWriter()
+	for key, lastSeen := range feed.entries {
+		if now.Sub(lastSeen) >= feed.EntryTTL {
+			m.removeEntryLocked(w, key, feed)
+		}
+	}
+	w.Flush()
+}
+
+// removeEntryLocked drops a key from the feed's keyset and queues its removal
+// from the BPF map on w. If another feed still lists the same key, the map
+// entry is handed over to that feed instead of being deleted. Caller must
+// hold m.mu.
+func (m *Manager) removeEntryLocked(w *entryWriter, key lpmKeyV4, feed *Feed) {
+	delete(feed.entries, key)
+	delete(feed.scores, key)
+	delete(feed.pushed, key)
+
+	for _, other := range m.feeds {
+		if other == feed {
+			continue
+		}
+		if _, ok := other.entries[key]; ok {
+			m.insertEntry(w, key, other)
+			return
+		}
+	}
+	w.Delete(key)
 }
 
 // parsePlaintext parses one IP/CIDR per line (Spamhaus DROP format).
 // Lines starting with ';' or '#' are treated as comments.
-func (m *Manager) parsePlaintext(r io.Reader, feed *Feed) (int, error) {
+func (m *Manager) parsePlaintext(r io.Reader, feed *Feed, set keySet) (int, error) {
 	scanner := bufio.NewScanner(r)
 	count := 0
 
@@ -314,7 +851,7 @@ func (m *Manager) parsePlaintext(r io.Reader, feed *Feed) (int, error) {
 			line = strings.TrimSpace(line[:idx])
 		}
 
-		if err := m.insertEntry(line, feed); err != nil {
+		if err := set.add(line); err != nil {
 			continue
 		}
 		count++
@@ -328,7 +865,7 @@ func (m *Manager) parsePlaintext(r io.Reader, feed *Feed) (int, error) {
 }
 
 // parseCSV parses a CSV feed with an IP column at the configured index.
-func (m *Manager) parseCSV(r io.Reader, feed *Feed) (int, error) {
+func (m *Manager) parseCSV(r io.Reader, feed *Feed, set keySet) (int, error) {
 	reader := csv.NewReader(r)
 	reader.LazyQuotes = true
 	reader.TrimLeadingSpace = true
@@ -359,7 +896,7 @@ func (m *Manager) parseCSV(r io.Reader, feed *Feed) (int, error) {
 			continue
 		}
 
-		if err := m.insertEntry(ipStr, feed); err != nil {
+		if err := set.add(ipStr); err != nil {
 			continue
 		}
 		count++
@@ -368,10 +905,20 @@ func (m *Manager) parseCSV(r io.Reader, feed *Feed) (int, error) {
 	return count, nil
 }
 
-// parseJSON parses a JSON array of IP strings.
-func (m *Manager) parseJSON(r io.Reader, feed *Feed) (int, error) {
-	var ips []string
+// parseJSON parses a JSON array of IP strings, or with the feed's JSON
+// mapping, a document of records.
+func (m *Manager) parseJSON(r io.Reader, feed *Feed, set keySet) (int, error) {
 	decoder := json.NewDecoder(r)
+	if !feed.JSON.IsZero() {
+		var doc interface{}
+		decoder.UseNumber()
+		if err := decoder.Decode(&doc); err != nil {
+			return 0, fmt.Errorf("decoding JSON feed: %w", err)
+		}
+		return m.parseMappedJSON(doc, feed, set)
+	}
+
+	var ips []string
 	if err := decoder.Decode(&ips); err != nil {
 		return 0, fmt.Errorf("decoding JSON feed: %w", err)
 	}
@@ -382,7 +929,7 @@ func (m *Manager) parseJSON(r io.Reader, feed *Feed) (int, error) {
 		if ipStr == "" {
 			continue
 		}
-		if err := m.insertEntry(ipStr, feed); err != nil {
+		if err := set.add(ipStr); err != nil {
 			continue
 		}
 		count++
@@ -391,13 +938,21 @@ func (m *Manager) parseJSON(r io.Reader, feed *Feed) (int, error) {
 	return count, nil
 }
 
-// insertEntry parses an IP or CIDR string and inserts it into the threat_intel_map.
-func (m *Manager) insertEntry(ipOrCIDR string, feed *Feed) error {
-	key, err := parseLPMKey(ipOrCIDR)
-	if err != nil {
-		return err
-	}
+// entryWriter batches writes to threat_intel_map.
+type entryWriter = bpf.BatchWriter[lpmKeyV4, threatIntelEntry]
+
+// newWriter returns a batch writer for threat_intel_map that logs failed
+// keys.
+func (m *Manager) newWriter() *entryWriter {
+	w := bpf.NewBatchWriter[lpmKeyV4, threatIntelEntry](m.threatMap, 0)
+	w.OnError(func(key lpmKeyV4, err error) {
+		m.log.Debug("threat entry write failed", zap.String("prefix", formatLPMKey(key)), zap.Error(err))
+	})
+	return w
+}
 
+// insertEntry queues a key for threat_intel_map with the feed's metadata.
+func (m *Manager) insertEntry(w *entryWriter, key lpmKeyV4, feed *Feed) {
 	entry := threatIntelEntry{
 		SourceID:    feed.SourceID,
 		ThreatType:  feed.ThreatType,
@@ -405,15 +960,17 @@ func (m *Manager) insertEntry(ipOrCIDR string, feed *Feed) error {
 		Action:      feed.Action,
 		LastUpdated: uint32(time.Now().Unix()),
 	}
-
-	if err := m.threatMap.Update(key, entry, ebpf.UpdateAny); err != nil {
-		return fmt.Errorf("inserting threat entry for %s: %w", ipOrCIDR, err)
+	if meta, ok := feed.scores[key]; ok {
+		entry.ThreatType = meta.ThreatType
+		entry.Confidence = meta.Confidence
+		entry.Action = meta.Action
 	}
 
-	return nil
+	w.Update(key, entry)
 }
 
-// GetFeeds returns all configured feeds with their current status.
+// GetFeeds returns all configured feeds with their current status,
+// ordered by name.
 func (m *Manager) GetFeeds() []Feed {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -422,6 +979,7 @@ func (m *Manager) GetFeeds() []Feed {
 	for _, f := range m.feeds {
 		result = append(result, *f)
 	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
 	return result
 }
 
@@ -434,7 +992,21 @@ func (m *Manager) GetStats() Stats {
 		TotalEntries: m.totalEntries,
 		LastSync:     m.lastSync,
 		FeedCount:    len(m.feeds),
+		Paused:       m.paused,
+	}
+}
+
+// SetPaused stops or resumes feed pulls. Entries already in the maps are
+// kept; while paused they are expected to be written by someone else,
+// such as a fleet leader's mirror. Feeds overdue when pulls resume are
+// synced on the next scheduling tick.
+func (m *Manager) SetPaused(paused bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.paused != paused {
+		m.log.Info("threat intel feed pulls", zap.Bool("paused", paused))
 	}
+	m.paused = paused
 }
 
 // SetSyncInterval changes the periodic sync interval.
@@ -470,6 +1042,219 @@ func (m *Manager) DisableFeed(name string) error {
 	return nil
 }
 
+// SetFeedEntryTTL sets how long entries withdrawn from a feed remain in the
+// BPF map before being removed.
+func (m *Manager) SetFeedEntryTTL(name string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	feed, exists := m.feeds[name]
+	if !exists {
+		return fmt.Errorf("feed %q not found", name)
+	}
+	feed.EntryTTL = ttl
+	return nil
+}
+
+// SetFeedAction sets the confidence and action installed for a feed's
+// entries and rewrites the entries already installed. Entries of scored
+// feeds keep their per-entry values.
+func (m *Manager) SetFeedAction(name string, confidence, action uint8) error {
+	if confidence > 100 {
+		return fmt.Errorf("confidence must be 0-100")
+	}
+	if _, ok := actionNames[action]; !ok {
+		return fmt.Errorf("invalid action %d", action)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	feed, exists := m.feeds[name]
+	if !exists {
+		return fmt.Errorf("feed %q not found", name)
+	}
+	feed.Confidence = confidence
+	feed.Action = action
+
+	w := m.newWriter()
+	for key := range feed.entries {
+		if _, scored := feed.scores[key]; scored {
+			continue
+		}
+		m.insertEntry(w, key, feed)
+	}
+	w.Flush()
+
+	m.log.Info("threat feed action set",
+		zap.String("feed", name),
+		zap.Uint8("confidence", confidence),
+		zap.String("action", ActionName(action)),
+	)
+	return nil
+}
+
+// Lookup returns every feed entry whose prefix covers ip, most specific
+// first, so operators can see why an address is dropped.
+func (m *Manager) Lookup(ip net.IP) []Match {
+	return m.LookupPrefix(&net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)})
+}
+
+// LookupPrefix returns every feed entry that overlaps prefix, either
+// covering it or listed inside it, most specific first.
+func (m *Manager) LookupPrefix(prefix *net.IPNet) []Match {
+	addr := ipToU32BE(prefix.IP)
+	bits, _ := prefix.Mask.Size()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var matches []Match
+	for _, feed := range m.feeds {
+		for key, lastSeen := range feed.entries {
+			mask := prefixMask(min(uint32(bits), key.PrefixLen))
+			if addr&mask != key.Addr&mask {
+				continue
+			}
+			match := Match{
+				Feed:       feed.Name,
+				Prefix:     formatLPMKey(key),
+				ThreatType: feed.ThreatType,
+				Confidence: feed.Confidence,
+				Action:     feed.Action,
+				LastSeen:   lastSeen,
+				prefixLen:  key.PrefixLen,
+			}
+			if meta, ok := feed.scores[key]; ok {
+				match.ThreatType = meta.ThreatType
+				match.Confidence = meta.Confidence
+				match.Action = meta.Action
+			}
+			matches = append(matches, match)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].prefixLen != matches[j].prefixLen {
+			return matches[i].prefixLen > matches[j].prefixLen
+		}
+		return matches[i].Feed < matches[j].Feed
+	})
+	return matches
+}
+
+// SetFeedAPIKey sets the API key for a feed that requires authentication.
+func (m *Manager) SetFeedAPIKey(name, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	feed, exists := m.feeds[name]
+	if !exists {
+		return fmt.Errorf("feed %q not found", name)
+	}
+	feed.APIKey = key
+	return nil
+}
+
+// SetFeedConfidence sets the confidence thresholds of a scored feed:
+// entries below min are ignored and those at or above block are dropped
+// rather than rate-limited.
+func (m *Manager) SetFeedConfidence(name string, min, block uint8) error {
+	if min > block || block > 100 {
+		return fmt.Errorf("confidence thresholds must satisfy min <= block <= 100")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	feed, exists := m.feeds[name]
+	if !exists {
+		return fmt.Errorf("feed %q not found", name)
+	}
+	feed.MinConfidence = min
+	feed.BlockConfidence = block
+	return nil
+}
+
+// SetFeedRateLimit sets the request quota for a feed. A zero limit
+// removes it.
+func (m *Manager) SetFeedRateLimit(name string, limit RateLimit) error {
+	if limit.Requests < 0 || (limit.Requests > 0 && limit.Per <= 0) {
+		return fmt.Errorf("rate limit needs a positive window")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	feed, exists := m.feeds[name]
+	if !exists {
+		return fmt.Errorf("feed %q not found", name)
+	}
+	feed.RateLimit = limit
+	feed.quota = quota{}
+	return nil
+}
+
+// SetFeedSyncInterval sets how often a feed is synced. Zero reverts to the
+// manager's interval.
+func (m *Manager) SetFeedSyncInterval(name string, interval time.Duration) error {
+	if interval != 0 && interval < scheduleTick {
+		return fmt.Errorf("sync interval must be at least %s", scheduleTick)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	feed, exists := m.feeds[name]
+	if !exists {
+		return fmt.Errorf("feed %q not found", name)
+	}
+	feed.SyncInterval = interval
+	return nil
+}
+
+// SetFeedJSONMapping sets where a JSON feed keeps its addresses, scores
+// and categories. It applies from the next sync.
+func (m *Manager) SetFeedJSONMapping(name string, mapping JSONMapping) error {
+	if err := mapping.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	feed, exists := m.feeds[name]
+	if !exists {
+		return fmt.Errorf("feed %q not found", name)
+	}
+	if feed.Type != "json" {
+		return fmt.Errorf("feed %q is not a JSON feed", name)
+	}
+	feed.JSON = mapping
+	// An unchanged document must still be parsed again.
+	feed.etag, feed.lastModified = "", ""
+	return nil
+}
+
+// SetFeedHeaders sets custom headers sent with every request for a feed,
+// replacing any set before.
+func (m *Manager) SetFeedHeaders(name string, headers map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	feed, exists := m.feeds[name]
+	if !exists {
+		return fmt.Errorf("feed %q not found", name)
+	}
+	feed.Headers = make(map[string]string, len(headers))
+	for k, v := range headers {
+		feed.Headers[k] = v
+	}
+	// Different headers may select different content.
+	feed.etag, feed.lastModified = "", ""
+	return nil
+}
+
 // --- Helpers ---
 
 // parseLPMKey converts an IP address or CIDR string to an LPM trie key.
@@ -480,6 +1265,9 @@ func parseLPMKey(s string) (lpmKeyV4, error) {
 		if err != nil {
 			return lpmKeyV4{}, fmt.Errorf("invalid CIDR: %s", s)
 		}
+		if ipNet.IP.To4() == nil {
+			return lpmKeyV4{}, fmt.Errorf("IPv6 not supported: %s", s)
+		}
 		ones, _ := ipNet.Mask.Size()
 		return lpmKeyV4{
 			PrefixLen: uint32(ones),
@@ -502,6 +1290,22 @@ func parseLPMKey(s string) (lpmKeyV4, error) {
 	}, nil
 }
 
+// prefixMask returns the mask of a prefix length for addresses read by
+// ipToU32BE.
+func prefixMask(prefixLen uint32) uint32 {
+	if prefixLen == 0 {
+		return 0
+	}
+	return ^uint32(0) << (32 - prefixLen)
+}
+
+// formatLPMKey renders an LPM key as CIDR notation.
+func formatLPMKey(key lpmKeyV4) string {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, key.Addr)
+	return fmt.Sprintf("%s/%d", ip, key.PrefixLen)
+}
+
 func ipToU32BE(ip net.IP) uint32 {
 	ip = ip.To4()
 	if ip == nil {
@@ -0,0 +1,307 @@
+package threatintel
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestParseLPMKey(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    lpmKeyV4
+		wantErr bool
+	}{
+		{"10.0.0.0/8", lpmKeyV4{PrefixLen: 8, Addr: 0x0a000000}, false},
+		{"192.168.1.1", lpmKeyV4{PrefixLen: 32, Addr: 0xc0a80101}, false},
+		{"10.1.2.3/16", lpmKeyV4{PrefixLen: 16, Addr: 0x0a010000}, false},
+		{"2001:db8::1", lpmKeyV4{}, true},
+		{"2001:db8::/32", lpmKeyV4{}, true},
+		{"not-an-ip", lpmKeyV4{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseLPMKey(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLPMKey(%q) error = %v, wantErr = %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseLPMKey(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePlaintext(t *testing.T) {
+	m := NewManager(zap.NewNop(), nil, nil)
+
+	feed := `; Spamhaus DROP List
+1.10.16.0/20 ; SBL256894
+1.19.0.0/16 ; SBL434604
+
+# comment
+1.19.0.0/16 ; duplicate
+garbage
+`
+	set := make(keySet)
+	count, err := m.parsePlaintext(strings.NewReader(feed), &Feed{}, set)
+	if err != nil {
+		t.Fatalf("parsePlaintext() error: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("parsed count = %d, want 3", count)
+	}
+	if len(set) != 2 {
+		t.Errorf("unique keys = %d, want 2", len(set))
+	}
+	if _, ok := set[lpmKeyV4{PrefixLen: 20, Addr: 0x010a1000}]; !ok {
+		t.Error("missing 1.10.16.0/20")
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	m := NewManager(zap.NewNop(), nil, nil)
+
+	feed := "first_seen,ip,port\n2024-01-01,198.51.100.7,443\n2024-01-02,203.0.113.0/24,80\n"
+	set := make(keySet)
+	if _, err := m.parseCSV(strings.NewReader(feed), &Feed{CSVColumn: 1}, set); err != nil {
+		t.Fatalf("parseCSV() error: %v", err)
+	}
+	if len(set) != 2 {
+		t.Errorf("unique keys = %d, want 2", len(set))
+	}
+}
+
+func TestFormatLPMKey(t *testing.T) {
+	key := lpmKeyV4{PrefixLen: 24, Addr: 0xcb007100}
+	if got := formatLPMKey(key); got != "203.0.113.0/24" {
+		t.Errorf("formatLPMKey() = %s, want 203.0.113.0/24", got)
+	}
+}
+
+func TestFetchAbuseIPDB(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Key") != "secret" || r.URL.Query().Get("confidenceMinimum") != "75" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("page") == "" {
+			fmt.Fprintf(w, `{"meta":{"nextPageUrl":%q},"data":[
+				{"ipAddress":"198.51.100.7","abuseConfidenceScore":100},
+				{"ipAddress":"198.51.100.8","abuseConfidenceScore":80}]}`,
+				srv.URL+"?confidenceMinimum=75&page=2")
+			return
+		}
+		fmt.Fprint(w, `{"meta":{},"data":[
+			{"ipAddress":"198.51.100.9","abuseConfidenceScore":50},
+			{"ipAddress":"2001:db8::1","abuseConfidenceScore":100}]}`)
+	}))
+	defer srv.Close()
+
+	m := NewManager(zap.NewNop(), nil, nil)
+	feed := &Feed{Name: "abuseipdb", URL: srv.URL, APIKey: "secret",
+		MinConfidence: defaultMinConfidence, BlockConfidence: defaultBlockConfidence}
+
+	set := make(keySet)
+	if err := m.fetchAbuseIPDB(feed, set); err != nil {
+		t.Fatalf("fetchAbuseIPDB() error: %v", err)
+	}
+	if len(set) != 2 {
+		t.Fatalf("keys = %d, want 2", len(set))
+	}
+	drop := set[lpmKeyV4{PrefixLen: 32, Addr: 0xc6336407}]
+	limit := set[lpmKeyV4{PrefixLen: 32, Addr: 0xc6336408}]
+	if drop == nil || *drop != (entryMeta{Confidence: 100, Action: 0}) {
+		t.Errorf("198.51.100.7 = %+v, want drop at 100", drop)
+	}
+	if limit == nil || *limit != (entryMeta{Confidence: 80, Action: 1}) {
+		t.Errorf("198.51.100.8 = %+v, want rate-limit at 80", limit)
+	}
+
+	feed.APIKey = ""
+	if err := m.fetchAbuseIPDB(feed, make(keySet)); err == nil {
+		t.Error("expected error without API key")
+	}
+}
+
+func TestFeedQuota(t *testing.T) {
+	var q quota
+	limit := RateLimit{Requests: 2, Per: time.Hour}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if err := q.take(limit, now); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if err := q.take(limit, now.Add(time.Minute)); err == nil {
+		t.Error("third request in window allowed")
+	}
+	if err := q.take(limit, now.Add(time.Hour)); err != nil {
+		t.Errorf("request in next window: %v", err)
+	}
+
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"120"}}}
+	until, ok := throttledUntil(resp, now)
+	if !ok || !until.Equal(now.Add(2*time.Minute)) {
+		t.Fatalf("throttledUntil = %v, %v", until, ok)
+	}
+	q.pauseUntil(until)
+	if err := q.take(RateLimit{}, now.Add(time.Minute)); err == nil {
+		t.Error("request allowed while paused")
+	}
+}
+
+func TestAddFeedBlocklistDEService(t *testing.T) {
+	m := NewManager(zap.NewNop(), nil, nil)
+	if err := m.AddFeed("bl-ssh", "ssh", "blocklistde"); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.feeds["bl-ssh"].URL; got != "https://lists.blocklist.de/lists/ssh.txt" {
+		t.Errorf("URL = %s", got)
+	}
+	if err := m.AddFeed("bl-x", "telnet", "blocklistde"); err == nil {
+		t.Error("expected error for unknown service")
+	}
+}
+
+func TestConditionalFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "k" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprintln(w, "198.51.100.7")
+	}))
+	defer srv.Close()
+
+	m := NewManager(zap.NewNop(), nil, nil)
+	feed := &Feed{URL: srv.URL, Headers: map[string]string{"X-Api-Key": "k"}}
+
+	resp, err := m.fetch(feed, feed.URL, m.conditionalHeader(feed))
+	if err != nil {
+		t.Fatalf("first fetch: %v", err)
+	}
+	resp.Body.Close()
+	feed.etag = resp.Header.Get("ETag")
+
+	if _, err := m.fetch(feed, feed.URL, m.conditionalHeader(feed)); err != errNotModified {
+		t.Errorf("second fetch error = %v, want errNotModified", err)
+	}
+}
+
+func TestFetchThroughProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		fmt.Fprintln(w, "198.51.100.7")
+	}))
+	defer proxy.Close()
+
+	m := NewManager(zap.NewNop(), nil, nil)
+	if err := m.SetHTTPConfig(HTTPConfig{Proxy: proxy.URL}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := m.fetch(&Feed{}, "http://feeds.example.com/drop.txt", nil)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	resp.Body.Close()
+	if proxied != "http://feeds.example.com/drop.txt" {
+		t.Errorf("proxy saw %q", proxied)
+	}
+
+	if err := m.SetHTTPConfig(HTTPConfig{Proxy: "not a url"}); err == nil {
+		t.Error("expected error for invalid proxy")
+	}
+}
+
+func TestDueFeeds(t *testing.T) {
+	m := NewManager(zap.NewNop(), nil, nil)
+	now := time.Now()
+	m.feeds = map[string]*Feed{
+		"hourly": {Name: "hourly", Enabled: true, lastAttempt: now.Add(-30 * time.Minute)},
+		"fast":   {Name: "fast", Enabled: true, SyncInterval: 5 * time.Minute, lastAttempt: now.Add(-10 * time.Minute)},
+		"off":    {Name: "off", SyncInterval: time.Minute},
+	}
+
+	due := m.dueFeeds(now)
+	if len(due) != 1 || due[0].Name != "fast" {
+		t.Errorf("due = %v, want [fast]", due)
+	}
+	if err := m.SetFeedSyncInterval("fast", time.Second); err == nil {
+		t.Error("expected error for sub-minute interval")
+	}
+
+	m.SetPaused(true)
+	if due := m.dueFeeds(now); len(due) != 0 {
+		t.Errorf("paused: due = %v, want none", due)
+	}
+	if err := m.SyncNow(); !errors.Is(err, ErrPaused) {
+		t.Errorf("paused: SyncNow err = %v, want ErrPaused", err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	m := NewManager(zap.NewNop(), nil, nil)
+	seen := time.Now()
+	m.feeds["spamhaus-drop"].entries = map[lpmKeyV4]time.Time{{PrefixLen: 16, Addr: 0xc6330000}: seen}
+	m.feeds["abuseipdb"].entries = map[lpmKeyV4]time.Time{{PrefixLen: 32, Addr: 0xc6336407}: seen}
+	m.feeds["abuseipdb"].scores = map[lpmKeyV4]entryMeta{{PrefixLen: 32, Addr: 0xc6336407}: {Confidence: 95, Action: 0}}
+
+	matches := m.Lookup(net.ParseIP("198.51.100.7"))
+	if len(matches) != 2 {
+		t.Fatalf("matches = %+v", matches)
+	}
+	if matches[0].Feed != "abuseipdb" || matches[0].Prefix != "198.51.100.7/32" || matches[0].Confidence != 95 {
+		t.Errorf("most specific match = %+v", matches[0])
+	}
+	if matches[1].Feed != "spamhaus-drop" || matches[1].Prefix != "198.51.0.0/16" {
+		t.Errorf("second match = %+v", matches[1])
+	}
+	if got := m.Lookup(net.ParseIP("203.0.113.1")); len(got) != 0 {
+		t.Errorf("unlisted address matched %+v", got)
+	}
+}
+
+func TestLookupPrefix(t *testing.T) {
+	m := NewManager(zap.NewNop(), nil, nil)
+	seen := time.Now()
+	m.feeds["spamhaus-drop"].entries = map[lpmKeyV4]time.Time{{PrefixLen: 16, Addr: 0xc6330000}: seen}
+	m.feeds["abuseipdb"].entries = map[lpmKeyV4]time.Time{{PrefixLen: 32, Addr: 0xc6336407}: seen}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"198.51.100.0/24", []string{"abuseipdb", "spamhaus-drop"}}, // covered, and one listed inside
+		{"198.51.0.0/16", []string{"abuseipdb", "spamhaus-drop"}},
+		{"198.0.0.0/8", []string{"abuseipdb", "spamhaus-drop"}},
+		{"198.51.200.0/24", []string{"spamhaus-drop"}},
+		{"203.0.113.0/24", nil},
+	}
+	for _, tt := range tests {
+		_, prefix, _ := net.ParseCIDR(tt.prefix)
+		var got []string
+		for _, match := range m.LookupPrefix(prefix) {
+			got = append(got, match.Feed)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: feeds = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	icmpEchoRequest = 8
+	icmpEchoReply   = 0
+)
+
+// Ping sends one ICMP echo request to ip and waits up to timeout for the
+// reply. It needs CAP_NET_RAW, which the scrubber already holds for XDP.
+func Ping(ctx context.Context, ip net.IP, timeout time.Duration) (time.Duration, error) {
+	conn, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return 0, fmt.Errorf("opening ICMP socket: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	var idBuf [4]byte
+	rand.Read(idBuf[:])
+	id := binary.BigEndian.Uint16(idBuf[:2])
+	seq := binary.BigEndian.Uint16(idBuf[2:])
+
+	start := time.Now()
+	if _, err := conn.WriteTo(echoRequest(id, seq), &net.IPAddr{IP: ip}); err != nil {
+		return 0, fmt.Errorf("sending echo request: %w", err)
+	}
+
+	// The raw socket sees every ICMP packet; wait for our reply.
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return 0, fmt.Errorf("no reply from %s within %s", ip, timeout)
+			}
+			return 0, err
+		}
+		addr, ok := from.(*net.IPAddr)
+		if ok && addr.IP.Equal(ip) && isEchoReply(buf[:n], id, seq) {
+			return time.Since(start), nil
+		}
+	}
+}
+
+// echoRequest builds an ICMP echo request with an 8-byte payload.
+func echoRequest(id, seq uint16) []byte {
+	pkt := make([]byte, 16)
+	pkt[0] = icmpEchoRequest
+	binary.BigEndian.PutUint16(pkt[4:], id)
+	binary.BigEndian.PutUint16(pkt[6:], seq)
+	copy(pkt[8:], "scrubber")
+	binary.BigEndian.PutUint16(pkt[2:], checksum(pkt))
+	return pkt
+}
+
+// isEchoReply reports whether pkt is the reply to the request id/seq.
+func isEchoReply(pkt []byte, id, seq uint16) bool {
+	return len(pkt) >= 8 &&
+		pkt[0] == icmpEchoReply &&
+		binary.BigEndian.Uint16(pkt[4:]) == id &&
+		binary.BigEndian.Uint16(pkt[6:]) == seq
+}
+
+// checksum is the Internet checksum (RFC 1071).
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,428 @@
+// Package tunnel manages the GRE tunnels that carry scrubbed traffic back
+// to the protected networks. Each tunnel maps a destination prefix to a
+// remote endpoint in the gre_tunnels BPF map. A health checker pings the
+// endpoints and moves a tunnel to its backup endpoint, or removes it, when
+// the endpoint stops answering, so a dead endpoint does not swallow clean
+// traffic.
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultCheckInterval = 5 * time.Second
+	defaultTimeout       = time.Second
+	defaultFailThreshold = 3
+	defaultRiseThreshold = 2
+)
+
+// Config controls GRE tunnel management and health checking.
+type Config struct {
+	Enabled          bool     `yaml:"enabled"`
+	CheckIntervalSec uint64   `yaml:"check_interval_sec"` // Time between health check rounds
+	TimeoutMS        uint64   `yaml:"timeout_ms"`         // Ping timeout per endpoint
+	FailThreshold    int      `yaml:"fail_threshold"`     // Failed pings before an endpoint is down
+	RiseThreshold    int      `yaml:"rise_threshold"`     // Answered pings before it is up again
+	Tunnels          []Tunnel `yaml:"tunnels"`
+}
+
+// Tunnel is one GRE return path.
+type Tunnel struct {
+	Name     string `yaml:"name" json:"name"`
+	Prefix   string `yaml:"prefix" json:"prefix"`           // Protected destination prefix
+	Endpoint string `yaml:"endpoint" json:"endpoint"`       // GRE remote address
+	Backup   string `yaml:"backup" json:"backup,omitempty"` // Used while Endpoint is down
+}
+
+// Validate checks the tunnel configuration.
+func (c Config) Validate() error {
+	if c.FailThreshold < 0 || c.RiseThreshold < 0 {
+		return fmt.Errorf("fail_threshold and rise_threshold must not be negative")
+	}
+	names := make(map[string]bool, len(c.Tunnels))
+	for _, t := range c.Tunnels {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+		if names[t.Name] {
+			return fmt.Errorf("duplicate tunnel %q", t.Name)
+		}
+		names[t.Name] = true
+	}
+	return nil
+}
+
+// Validate checks that the tunnel has a name, a prefix and IPv4 endpoints.
+func (t Tunnel) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("tunnel name is required")
+	}
+	if _, _, err := net.ParseCIDR(t.Prefix); err != nil {
+		return fmt.Errorf("tunnel %s: invalid prefix: %w", t.Name, err)
+	}
+	if ip := net.ParseIP(t.Endpoint); ip == nil || ip.To4() == nil {
+		return fmt.Errorf("tunnel %s: invalid endpoint %q", t.Name, t.Endpoint)
+	}
+	if t.Backup != "" {
+		if ip := net.ParseIP(t.Backup); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("tunnel %s: invalid backup endpoint %q", t.Name, t.Backup)
+		}
+	}
+	return nil
+}
+
+// Map is the gre_tunnels map, implemented by bpf.MapManager.
+type Map interface {
+	AddGRETunnel(cidr string, endpoint net.IP) error
+	RemoveGRETunnel(cidr string) error
+}
+
+// ProbeFunc pings an endpoint and returns the round-trip time.
+type ProbeFunc func(ctx context.Context, ip net.IP, timeout time.Duration) (time.Duration, error)
+
+// Change describes a tunnel switching endpoints. From and To are endpoint
+// addresses; an empty To means the tunnel was removed from the map because
+// no endpoint answers.
+type Change struct {
+	Name    string
+	Prefix  string
+	From    string
+	To      string
+	Primary bool // To is the tunnel's primary endpoint
+	Reason  string
+}
+
+// health tracks one endpoint. Endpoints start up so that a new tunnel
+// carries traffic before its first check.
+type health struct {
+	up        bool
+	fails     int
+	oks       int
+	lastCheck time.Time
+	rtt       time.Duration
+	lastErr   string
+}
+
+type state struct {
+	Tunnel
+	active  string // Endpoint programmed in the map; "" when removed
+	primary health
+	backup  health
+}
+
+// Status reports a tunnel and the health of its endpoints.
+type Status struct {
+	Tunnel
+	Active      string // Endpoint traffic is sent to; "" when none answers
+	EndpointUp  bool
+	BackupUp    bool
+	LastCheck   time.Time
+	EndpointRTT time.Duration
+	BackupRTT   time.Duration
+	LastError   string
+}
+
+// Manager owns the gre_tunnels map entries and their health checks.
+type Manager struct {
+	log      *zap.Logger
+	m        Map
+	probe    ProbeFunc
+	interval time.Duration
+	timeout  time.Duration
+	fall     int
+	rise     int
+
+	mu       sync.Mutex
+	tunnels  map[string]*state
+	onChange func(Change)
+}
+
+// NewManager creates a tunnel manager writing to m. Tunnels are added with
+// Configure or Add.
+func NewManager(log *zap.Logger, cfg Config, m Map) *Manager {
+	mgr := &Manager{
+		log:      log,
+		m:        m,
+		probe:    Ping,
+		interval: time.Duration(cfg.CheckIntervalSec) * time.Second,
+		timeout:  time.Duration(cfg.TimeoutMS) * time.Millisecond,
+		fall:     cfg.FailThreshold,
+		rise:     cfg.RiseThreshold,
+		tunnels:  make(map[string]*state),
+	}
+	if mgr.interval == 0 {
+		mgr.interval = defaultCheckInterval
+	}
+	if mgr.timeout == 0 {
+		mgr.timeout = defaultTimeout
+	}
+	if mgr.fall == 0 {
+		mgr.fall = defaultFailThreshold
+	}
+	if mgr.rise == 0 {
+		mgr.rise = defaultRiseThreshold
+	}
+	return mgr
+}
+
+// OnChange sets a callback invoked when a tunnel switches endpoints. It is
+// called synchronously and must not block.
+func (mgr *Manager) OnChange(fn func(Change)) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	mgr.onChange = fn
+}
+
+// Configure adds the configured tunnels.
+func (mgr *Manager) Configure(tunnels []Tunnel) error {
+	for _, t := range tunnels {
+		if err := mgr.Add(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add programs a tunnel towards its endpoint. Names and prefixes must be
+// unique.
+func (mgr *Manager) Add(t Tunnel) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	_, ipnet, _ := net.ParseCIDR(t.Prefix)
+	t.Prefix = ipnet.String()
+
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	for _, s := range mgr.tunnels {
+		if s.Name == t.Name {
+			return fmt.Errorf("tunnel %q already exists", t.Name)
+		}
+		if s.Prefix == t.Prefix {
+			return fmt.Errorf("prefix %s already routed through tunnel %q", t.Prefix, s.Name)
+		}
+	}
+	if err := mgr.m.AddGRETunnel(t.Prefix, net.ParseIP(t.Endpoint)); err != nil {
+		return fmt.Errorf("adding tunnel %s: %w", t.Name, err)
+	}
+	mgr.tunnels[t.Name] = &state{
+		Tunnel:  t,
+		active:  t.Endpoint,
+		primary: health{up: true},
+		backup:  health{up: true},
+	}
+	mgr.log.Info("GRE tunnel added",
+		zap.String("name", t.Name),
+		zap.String("prefix", t.Prefix),
+		zap.String("endpoint", t.Endpoint),
+		zap.String("backup", t.Backup),
+	)
+	return nil
+}
+
+// Remove deletes a tunnel and its map entry.
+func (mgr *Manager) Remove(name string) error {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	s, ok := mgr.tunnels[name]
+	if !ok {
+		return fmt.Errorf("tunnel %q not found", name)
+	}
+	if s.active != "" {
+		if err := mgr.m.RemoveGRETunnel(s.Prefix); err != nil {
+			return fmt.Errorf("removing tunnel %s: %w", name, err)
+		}
+	}
+	delete(mgr.tunnels, name)
+	mgr.log.Info("GRE tunnel removed", zap.String("name", name), zap.String("prefix", s.Prefix))
+	return nil
+}
+
+// List returns every tunnel sorted by name.
+func (mgr *Manager) List() []Status {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	out := make([]Status, 0, len(mgr.tunnels))
+	for _, s := range mgr.tunnels {
+		st := Status{
+			Tunnel:      s.Tunnel,
+			Active:      s.active,
+			EndpointUp:  s.primary.up,
+			BackupUp:    s.Backup != "" && s.backup.up,
+			LastCheck:   s.primary.lastCheck,
+			EndpointRTT: s.primary.rtt,
+			BackupRTT:   s.backup.rtt,
+			LastError:   s.primary.lastErr,
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Run health-checks the tunnel endpoints until ctx is cancelled.
+func (mgr *Manager) Run(ctx context.Context) {
+	ticker := time.NewTicker(mgr.interval)
+	defer ticker.Stop()
+
+	mgr.log.Info("GRE tunnel health checks started", zap.Duration("interval", mgr.interval))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			mgr.check(ctx)
+		}
+	}
+}
+
+// probeResult is the outcome of pinging one endpoint.
+type probeResult struct {
+	rtt time.Duration
+	err error
+}
+
+// check pings every endpoint in parallel, then moves tunnels whose active
+// endpoint changed.
+func (mgr *Manager) check(ctx context.Context) {
+	mgr.mu.Lock()
+	var endpoints []string
+	seen := make(map[string]bool)
+	for _, s := range mgr.tunnels {
+		for _, ep := range []string{s.Endpoint, s.Backup} {
+			if ep != "" && !seen[ep] {
+				seen[ep] = true
+				endpoints = append(endpoints, ep)
+			}
+		}
+	}
+	mgr.mu.Unlock()
+
+	results := make(map[string]probeResult, len(endpoints))
+	var (
+		wg    sync.WaitGroup
+		resMu sync.Mutex
+	)
+	for _, ep := range endpoints {
+		wg.Add(1)
+		go func(ep string) {
+			defer wg.Done()
+			rtt, err := mgr.probe(ctx, net.ParseIP(ep), mgr.timeout)
+			resMu.Lock()
+			results[ep] = probeResult{rtt, err}
+			resMu.Unlock()
+		}(ep)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	mgr.apply(results, time.Now())
+}
+
+// apply records probe results and reprograms tunnels whose preferred
+// endpoint changed.
+func (mgr *Manager) apply(results map[string]probeResult, now time.Time) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	names := make([]string, 0, len(mgr.tunnels))
+	for name := range mgr.tunnels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := mgr.tunnels[name]
+		if r, ok := results[s.Endpoint]; ok {
+			mgr.record(&s.primary, r, now)
+		}
+		if r, ok := results[s.Backup]; ok && s.Backup != "" {
+			mgr.record(&s.backup, r, now)
+		}
+
+		want, reason := s.preferred()
+		if want == s.active {
+			continue
+		}
+		var err error
+		if want == "" {
+			err = mgr.m.RemoveGRETunnel(s.Prefix)
+		} else {
+			err = mgr.m.AddGRETunnel(s.Prefix, net.ParseIP(want))
+		}
+		if err != nil {
+			// The map is unchanged; the next round retries.
+			mgr.log.Warn("failed to update GRE tunnel", zap.String("name", s.Name), zap.Error(err))
+			continue
+		}
+
+		ch := Change{
+			Name:    s.Name,
+			Prefix:  s.Prefix,
+			From:    s.active,
+			To:      want,
+			Primary: want == s.Endpoint,
+			Reason:  reason,
+		}
+		s.active = want
+		mgr.log.Warn("GRE tunnel endpoint changed",
+			zap.String("name", ch.Name),
+			zap.String("prefix", ch.Prefix),
+			zap.String("from", ch.From),
+			zap.String("to", ch.To),
+			zap.String("reason", ch.Reason),
+		)
+		if mgr.onChange != nil {
+			mgr.onChange(ch)
+		}
+	}
+}
+
+// record updates an endpoint's health with one probe result. An endpoint
+// goes down after fall consecutive failures and back up after rise
+// consecutive answers.
+func (mgr *Manager) record(h *health, r probeResult, now time.Time) {
+	h.lastCheck = now
+	if r.err != nil {
+		h.oks = 0
+		h.fails++
+		h.lastErr = r.err.Error()
+		if h.fails >= mgr.fall {
+			h.up = false
+		}
+		return
+	}
+	h.fails = 0
+	h.oks++
+	h.rtt = r.rtt
+	h.lastErr = ""
+	if h.oks >= mgr.rise {
+		h.up = true
+	}
+}
+
+// preferred returns the endpoint the tunnel should use: the primary while
+// it is up, else the backup, else none.
+func (s *state) preferred() (string, string) {
+	switch {
+	case s.primary.up:
+		return s.Endpoint, "endpoint reachable"
+	case s.Backup != "" && s.backup.up:
+		return s.Backup, "endpoint unreachable, using backup"
+	default:
+		return "", "no endpoint reachable"
+	}
+}
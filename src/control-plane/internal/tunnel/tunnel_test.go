@@ -0,0 +1,110 @@
+package tunnel
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fakeMap records the endpoint programmed for each prefix.
+type fakeMap struct {
+	entries map[string]string
+}
+
+func (f *fakeMap) AddGRETunnel(cidr string, endpoint net.IP) error {
+	f.entries[cidr] = endpoint.String()
+	return nil
+}
+
+func (f *fakeMap) RemoveGRETunnel(cidr string) error {
+	delete(f.entries, cidr)
+	return nil
+}
+
+func TestTunnelFailover(t *testing.T) {
+	m := &fakeMap{entries: map[string]string{}}
+	mgr := NewManager(zap.NewNop(), Config{FailThreshold: 2, RiseThreshold: 2}, m)
+	var changes []Change
+	mgr.OnChange(func(c Change) { changes = append(changes, c) })
+
+	if err := mgr.Add(Tunnel{Name: "dc1", Prefix: "203.0.113.7/24", Endpoint: "192.0.2.1", Backup: "192.0.2.2"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if got := m.entries["203.0.113.0/24"]; got != "192.0.2.1" {
+		t.Fatalf("map entry = %q, want primary endpoint", got)
+	}
+
+	down := probeResult{err: errors.New("timeout")}
+	ok := probeResult{rtt: time.Millisecond}
+	steps := []struct {
+		primary, backup probeResult
+		want            string
+	}{
+		{down, ok, "192.0.2.1"}, // One failure is below the threshold
+		{down, ok, "192.0.2.2"}, // Second failure: fail over to the backup
+		{down, down, "192.0.2.2"},
+		{down, down, ""}, // Backup down too: tunnel removed
+		{ok, down, ""},   // Primary needs two answers to recover
+		{ok, down, "192.0.2.1"},
+	}
+	for i, st := range steps {
+		mgr.apply(map[string]probeResult{"192.0.2.1": st.primary, "192.0.2.2": st.backup}, time.Now())
+		if got := m.entries["203.0.113.0/24"]; got != st.want {
+			t.Errorf("step %d: map entry = %q, want %q", i, got, st.want)
+		}
+	}
+
+	if len(changes) != 3 {
+		t.Fatalf("changes = %+v, want 3", changes)
+	}
+	if c := changes[1]; c.From != "192.0.2.2" || c.To != "" {
+		t.Errorf("second change = %+v, want removal from the backup", c)
+	}
+	if st := mgr.List()[0]; st.Active != "192.0.2.1" || !st.EndpointUp || st.BackupUp {
+		t.Errorf("status = %+v", st)
+	}
+}
+
+func TestTunnelAddRemove(t *testing.T) {
+	m := &fakeMap{entries: map[string]string{}}
+	mgr := NewManager(zap.NewNop(), Config{}, m)
+
+	if err := mgr.Add(Tunnel{Name: "dc1", Prefix: "203.0.113.0/24", Endpoint: "192.0.2.1"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	for _, bad := range []Tunnel{
+		{Name: "dc1", Prefix: "198.51.100.0/24", Endpoint: "192.0.2.1"},
+		{Name: "dc2", Prefix: "203.0.113.0/24", Endpoint: "192.0.2.1"},
+		{Name: "dc3", Prefix: "198.51.100.0/24", Endpoint: "2001:db8::1"},
+	} {
+		if err := mgr.Add(bad); err == nil {
+			t.Errorf("Add(%+v) should fail", bad)
+		}
+	}
+
+	if err := mgr.Remove("dc1"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if len(m.entries) != 0 || len(mgr.List()) != 0 {
+		t.Errorf("tunnel still present after Remove: %v", m.entries)
+	}
+	if err := mgr.Remove("dc1"); err == nil {
+		t.Error("removing a missing tunnel should fail")
+	}
+}
+
+func TestEchoRequestChecksum(t *testing.T) {
+	pkt := echoRequest(0x1234, 7)
+	if checksum(pkt) != 0 {
+		t.Error("checksum over a packet including its checksum should be 0")
+	}
+
+	reply := append([]byte(nil), pkt...)
+	reply[0] = icmpEchoReply
+	if !isEchoReply(reply, 0x1234, 7) || isEchoReply(reply, 0x1234, 8) {
+		t.Error("isEchoReply does not match on id and sequence")
+	}
+}
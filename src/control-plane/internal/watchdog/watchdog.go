@@ -0,0 +1,282 @@
+// Package watchdog checks that the XDP program is still attached to the
+// interface and that the config map still holds what the control plane
+// wrote, e.g. after other tooling on the box replaced the program or
+// wrote the map. Problems are reported, and repaired unless configured
+// to only alert: the program is reattached and drifted config values are
+// written back.
+package watchdog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+// Finding kinds.
+const (
+	KindDetached = "detached" // Program no longer on the interface
+	KindReplaced = "replaced" // Another program runs on the interface
+	KindConfig   = "config"   // Config map values changed behind our back
+)
+
+// findingsSize bounds the list of recent findings.
+const findingsSize = 50
+
+// Config controls the watchdog.
+type Config struct {
+	Enabled     bool `yaml:"enabled"`
+	IntervalSec int  `yaml:"interval_sec"`
+	Repair      bool `yaml:"repair"` // Reattach and restore config; false only alerts
+}
+
+// DefaultConfig returns the built-in watchdog settings.
+func DefaultConfig() Config {
+	return Config{
+		Enabled:     true,
+		IntervalSec: 10,
+		Repair:      true,
+	}
+}
+
+// Validate checks the check interval.
+func (c Config) Validate() error {
+	if c.IntervalSec < 1 || c.IntervalSec > 3600 {
+		return fmt.Errorf("interval_sec must be 1-3600")
+	}
+	return nil
+}
+
+// Dataplane is what the watchdog checks and repairs, implemented by the
+// engine.
+type Dataplane interface {
+	AttachState() (bpf.AttachState, error)
+	Reattach() error
+	ConfigDrift() ([]bpf.ConfigDrift, error)
+	RestoreConfig(drift []bpf.ConfigDrift) error
+	// Paused reports whether the program is out of the path on purpose,
+	// e.g. in maintenance.
+	Paused() bool
+}
+
+// Finding is a problem a check found.
+type Finding struct {
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	Detail   string    `json:"detail"`
+	Repaired bool      `json:"repaired"`
+	Error    string    `json:"error,omitempty"` // Why the repair failed
+}
+
+// Status is the outcome of the latest check and recent findings.
+type Status struct {
+	Enabled   bool            `json:"enabled"`
+	Repair    bool            `json:"repair"`
+	Interval  int             `json:"intervalSec"`
+	LastCheck time.Time       `json:"lastCheck,omitempty"`
+	Healthy   bool            `json:"healthy"`
+	Paused    bool            `json:"paused"`
+	Attach    bpf.AttachState `json:"attach"`
+	Checks    uint64          `json:"checks"`
+	Repairs   uint64          `json:"repairs"`
+	Findings  []Finding       `json:"findings"` // Newest first
+}
+
+// Watchdog periodically checks the data plane.
+type Watchdog struct {
+	log *zap.Logger
+	cfg Config
+	dp  Dataplane
+
+	// now is the clock, replaced in tests.
+	now func() time.Time
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	healthy   bool
+	paused    bool
+	attach    bpf.AttachState
+	checks    uint64
+	repairs   uint64
+	findings  []Finding
+
+	onFinding func(Finding)
+}
+
+// New creates a watchdog.
+func New(log *zap.Logger, cfg Config, dp Dataplane) *Watchdog {
+	return &Watchdog{
+		log:     log,
+		cfg:     cfg,
+		dp:      dp,
+		now:     time.Now,
+		healthy: true,
+	}
+}
+
+// OnFinding sets a callback invoked for every problem found, after the
+// repair attempt.
+func (w *Watchdog) OnFinding(fn func(Finding)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onFinding = fn
+}
+
+// Run checks every interval until ctx is done.
+func (w *Watchdog) Run(ctx context.Context) {
+	interval := time.Duration(w.cfg.IntervalSec) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	w.log.Info("watchdog started", zap.Duration("interval", interval), zap.Bool("repair", w.cfg.Repair))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		w.Check()
+	}
+}
+
+// Check verifies the attachment and the config map once, repairing what
+// it can, and returns the problems found.
+func (w *Watchdog) Check() []Finding {
+	var found []Finding
+	paused := w.dp.Paused()
+
+	st, err := w.dp.AttachState()
+	if err != nil {
+		w.log.Warn("watchdog: reading XDP attachment failed", zap.Error(err))
+	} else if !paused {
+		if f, ok := w.checkAttach(st); ok {
+			found = append(found, f)
+			if f.Repaired {
+				if after, err := w.dp.AttachState(); err == nil {
+					st = after
+				}
+			}
+		}
+	}
+
+	if f, ok := w.checkConfig(); ok {
+		found = append(found, f)
+	}
+
+	w.mu.Lock()
+	w.lastCheck = w.now()
+	w.checks++
+	w.paused = paused
+	w.attach = st
+	w.healthy = true
+	for _, f := range found {
+		if f.Repaired {
+			w.repairs++
+		} else {
+			w.healthy = false
+		}
+		w.findings = append([]Finding{f}, w.findings...)
+	}
+	if len(w.findings) > findingsSize {
+		w.findings = w.findings[:findingsSize]
+	}
+	fn := w.onFinding
+	w.mu.Unlock()
+
+	for _, f := range found {
+		if fn != nil {
+			fn(f)
+		}
+	}
+	return found
+}
+
+// checkAttach compares the attachment with our program and reattaches it
+// if it was removed or replaced.
+func (w *Watchdog) checkAttach(st bpf.AttachState) (Finding, bool) {
+	if st.Intact() {
+		return Finding{}, false
+	}
+
+	f := Finding{Time: w.now(), Kind: KindDetached}
+	switch {
+	case st.InterfaceProgramID != 0 && st.InterfaceProgramID != st.ProgramID:
+		f.Kind = KindReplaced
+		f.Detail = fmt.Sprintf("%s runs program %d instead of %d", st.Interface, st.InterfaceProgramID, st.ProgramID)
+	case st.Linked && st.LinkProgramID != st.ProgramID:
+		f.Kind = KindReplaced
+		f.Detail = fmt.Sprintf("XDP link on %s points at program %d instead of %d", st.Interface, st.LinkProgramID, st.ProgramID)
+	case !st.Linked:
+		f.Detail = fmt.Sprintf("no XDP link on %s", st.Interface)
+	default:
+		f.Detail = fmt.Sprintf("program %d no longer attached to %s", st.ProgramID, st.Interface)
+	}
+	w.log.Error("watchdog: XDP program not attached", zap.String("kind", f.Kind), zap.String("detail", f.Detail))
+
+	// Maintenance may have started since the check began.
+	if !w.cfg.Repair || w.dp.Paused() {
+		return f, true
+	}
+	if err := w.dp.Reattach(); err != nil {
+		f.Error = err.Error()
+		w.log.Error("watchdog: reattaching XDP program failed", zap.Error(err))
+		return f, true
+	}
+	f.Repaired = true
+	w.log.Warn("watchdog: XDP program reattached", zap.String("interface", st.Interface))
+	return f, true
+}
+
+// checkConfig compares the config map with the values last written and
+// writes the drifted ones back.
+func (w *Watchdog) checkConfig() (Finding, bool) {
+	drift, err := w.dp.ConfigDrift()
+	if err != nil {
+		w.log.Warn("watchdog: reading config map failed", zap.Error(err))
+		return Finding{}, false
+	}
+	if len(drift) == 0 {
+		return Finding{}, false
+	}
+
+	parts := make([]string, len(drift))
+	for i, d := range drift {
+		parts[i] = fmt.Sprintf("%s=%d (want %d)", d.Name, d.Got, d.Want)
+	}
+	f := Finding{Time: w.now(), Kind: KindConfig, Detail: strings.Join(parts, ", ")}
+	w.log.Error("watchdog: config map values changed outside the control plane", zap.String("detail", f.Detail))
+
+	if !w.cfg.Repair {
+		return f, true
+	}
+	if err := w.dp.RestoreConfig(drift); err != nil {
+		f.Error = err.Error()
+		w.log.Error("watchdog: restoring config failed", zap.Error(err))
+		return f, true
+	}
+	f.Repaired = true
+	w.log.Warn("watchdog: config map values restored", zap.Int("keys", len(drift)))
+	return f, true
+}
+
+// Status returns the latest check and recent findings.
+func (w *Watchdog) Status() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return Status{
+		Enabled:   true,
+		Repair:    w.cfg.Repair,
+		Interval:  w.cfg.IntervalSec,
+		LastCheck: w.lastCheck,
+		Healthy:   w.healthy,
+		Paused:    w.paused,
+		Attach:    w.attach,
+		Checks:    w.checks,
+		Repairs:   w.repairs,
+		Findings:  append([]Finding(nil), w.findings...),
+	}
+}
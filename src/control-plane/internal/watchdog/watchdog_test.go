@@ -0,0 +1,188 @@
+package watchdog
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/bpf"
+	"go.uber.org/zap"
+)
+
+// fakeDataplane keeps an attachment and a config map the tests tamper with.
+type fakeDataplane struct {
+	attach      bpf.AttachState
+	config      map[uint32]uint64
+	written     map[uint32]uint64
+	paused      bool
+	reattachErr error
+	reattached  int
+}
+
+func newFakeDataplane() *fakeDataplane {
+	return &fakeDataplane{
+		attach: bpf.AttachState{
+			Interface: "eth0", ProgramID: 42,
+			Linked: true, LinkIfindex: 2, LinkProgramID: 42, InterfaceProgramID: 42,
+		},
+		config:  map[uint32]uint64{bpf.CfgEnabled: 1, bpf.CfgSYNRatePPS: 1000},
+		written: map[uint32]uint64{bpf.CfgEnabled: 1, bpf.CfgSYNRatePPS: 1000},
+	}
+}
+
+func (f *fakeDataplane) AttachState() (bpf.AttachState, error) { return f.attach, nil }
+
+func (f *fakeDataplane) Reattach() error {
+	f.reattached++
+	if f.reattachErr != nil {
+		return f.reattachErr
+	}
+	f.attach.Linked = true
+	f.attach.LinkIfindex = 2
+	f.attach.LinkProgramID = f.attach.ProgramID
+	f.attach.InterfaceProgramID = f.attach.ProgramID
+	return nil
+}
+
+func (f *fakeDataplane) ConfigDrift() ([]bpf.ConfigDrift, error) {
+	var drift []bpf.ConfigDrift
+	for k, want := range f.written {
+		if got := f.config[k]; got != want {
+			drift = append(drift, bpf.ConfigDrift{Key: k, Name: "k", Want: want, Got: got})
+		}
+	}
+	return drift, nil
+}
+
+func (f *fakeDataplane) RestoreConfig(drift []bpf.ConfigDrift) error {
+	for _, d := range drift {
+		f.config[d.Key] = d.Want
+	}
+	return nil
+}
+
+func (f *fakeDataplane) Paused() bool { return f.paused }
+
+func TestCheckHealthy(t *testing.T) {
+	dp := newFakeDataplane()
+	w := New(zap.NewNop(), DefaultConfig(), dp)
+	if found := w.Check(); len(found) != 0 {
+		t.Fatalf("findings = %+v, want none", found)
+	}
+	if st := w.Status(); !st.Healthy || st.Checks != 1 {
+		t.Errorf("status = %+v", st)
+	}
+}
+
+func TestCheckAttach(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(*bpf.AttachState)
+		kind   string
+	}{
+		{"detached", func(s *bpf.AttachState) { s.LinkIfindex, s.InterfaceProgramID = 0, 0 }, KindDetached},
+		{"unlinked", func(s *bpf.AttachState) { s.Linked, s.LinkIfindex, s.LinkProgramID = false, 0, 0 }, KindDetached},
+		{"replaced", func(s *bpf.AttachState) { s.InterfaceProgramID = 77 }, KindReplaced},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dp := newFakeDataplane()
+			tt.tamper(&dp.attach)
+			w := New(zap.NewNop(), DefaultConfig(), dp)
+
+			var notified []Finding
+			w.OnFinding(func(f Finding) { notified = append(notified, f) })
+			found := w.Check()
+			if len(found) != 1 || found[0].Kind != tt.kind || !found[0].Repaired {
+				t.Fatalf("findings = %+v, want one repaired %s", found, tt.kind)
+			}
+			if dp.reattached != 1 || !dp.attach.Intact() {
+				t.Errorf("reattached = %d, attach = %+v", dp.reattached, dp.attach)
+			}
+			if len(notified) != 1 {
+				t.Errorf("notified %d times, want 1", len(notified))
+			}
+			if st := w.Status(); !st.Healthy || st.Repairs != 1 || !st.Attach.Intact() {
+				t.Errorf("status = %+v", st)
+			}
+		})
+	}
+}
+
+func TestCheckReattachFails(t *testing.T) {
+	dp := newFakeDataplane()
+	dp.attach.InterfaceProgramID = 0
+	dp.reattachErr = errors.New("device busy")
+	w := New(zap.NewNop(), DefaultConfig(), dp)
+
+	found := w.Check()
+	if len(found) != 1 || found[0].Repaired || found[0].Error != "device busy" {
+		t.Fatalf("findings = %+v", found)
+	}
+	if w.Status().Healthy {
+		t.Error("status healthy after failed repair")
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	dp := newFakeDataplane()
+	dp.config[bpf.CfgSYNRatePPS] = 0
+	w := New(zap.NewNop(), DefaultConfig(), dp)
+
+	found := w.Check()
+	if len(found) != 1 || found[0].Kind != KindConfig || !found[0].Repaired {
+		t.Fatalf("findings = %+v, want one repaired config finding", found)
+	}
+	if dp.config[bpf.CfgSYNRatePPS] != 1000 {
+		t.Errorf("SYN rate = %d, want restored 1000", dp.config[bpf.CfgSYNRatePPS])
+	}
+}
+
+func TestCheckAlertOnly(t *testing.T) {
+	dp := newFakeDataplane()
+	dp.attach.InterfaceProgramID = 0
+	dp.config[bpf.CfgEnabled] = 0
+	cfg := DefaultConfig()
+	cfg.Repair = false
+	w := New(zap.NewNop(), cfg, dp)
+
+	found := w.Check()
+	if len(found) != 2 {
+		t.Fatalf("findings = %+v, want 2", found)
+	}
+	for _, f := range found {
+		if f.Repaired {
+			t.Errorf("%s repaired with repair off", f.Kind)
+		}
+	}
+	if dp.reattached != 0 || dp.config[bpf.CfgEnabled] != 0 {
+		t.Error("dataplane changed with repair off")
+	}
+}
+
+func TestCheckPaused(t *testing.T) {
+	dp := newFakeDataplane()
+	dp.attach.Linked, dp.attach.LinkIfindex, dp.attach.InterfaceProgramID = false, 0, 0
+	dp.paused = true
+	w := New(zap.NewNop(), DefaultConfig(), dp)
+
+	if found := w.Check(); len(found) != 0 {
+		t.Fatalf("findings during maintenance = %+v", found)
+	}
+	if dp.reattached != 0 {
+		t.Error("reattached during maintenance")
+	}
+	if !w.Status().Paused {
+		t.Error("status not paused")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("default: %v", err)
+	}
+	cfg.IntervalSec = 0
+	if err := cfg.Validate(); err == nil {
+		t.Error("interval 0 accepted")
+	}
+}
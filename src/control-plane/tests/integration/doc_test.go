@@ -0,0 +1,20 @@
+//go:build privileged
+
+// Package integration runs the scrubber against live traffic. Each test
+// creates a veth pair with one end in a fresh network namespace, starts
+// the engine with the XDP program attached to the host end, and sends
+// synthetic floods or replayed pcap files from the namespace end. The
+// assertions read the same stats, escalation and attack APIs operators
+// use.
+//
+// The tests need root (or CAP_NET_ADMIN, CAP_SYS_ADMIN and CAP_BPF), the
+// ip command and a compiled BPF object:
+//
+//	make all
+//	cd src/control-plane
+//	sudo go test -tags privileged -count=1 -v ./tests/integration/
+//
+// SCRUBBER_BPF_OBJ overrides the object path, SCRUBBER_XDP_MODE the attach
+// mode (default skb) and SCRUBBER_PCAP_DIR the directory replayed by
+// TestPcapReplay (default tests/fixtures/pcap, see make gen-fixtures).
+package integration
@@ -0,0 +1,299 @@
+//go:build privileged
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/config"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/engine"
+	"github.com/ebpf-ddos-scrubber/control-plane/internal/pktgen"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"golang.org/x/sys/unix"
+)
+
+// Addresses of the veth pair. Traffic is sent from the namespace end to
+// the host end, where the scrubber is attached.
+var (
+	hostIP = net.IPv4(10, 200, 0, 1)
+	peerIP = net.IPv4(10, 200, 0, 2)
+)
+
+// Default BPF object, relative to this package.
+const defaultBPFObject = "../../../../build/obj/xdp_ddos_scrubber.o"
+
+var harnessSeq atomic.Int32
+
+// harness is a scrubber attached to one end of a veth pair and a packet
+// socket on the other end, inside its own network namespace.
+type harness struct {
+	t       *testing.T
+	ns      string
+	hostIf  string
+	hostMAC net.HardwareAddr
+	peerMAC net.HardwareAddr
+	sock    int // AF_PACKET socket bound to the namespace end
+	api     *http.Client
+}
+
+func TestMain(m *testing.M) {
+	if os.Geteuid() != 0 {
+		fmt.Println("integration tests need root; skipping")
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// newHarness sets up the veth pair and starts the engine on its host end.
+// modify adjusts the engine configuration before it is validated.
+func newHarness(t *testing.T, modify func(*config.Config)) *harness {
+	t.Helper()
+
+	obj := os.Getenv("SCRUBBER_BPF_OBJ")
+	if obj == "" {
+		obj = defaultBPFObject
+	}
+	if _, err := os.Stat(obj); err != nil {
+		t.Skipf("BPF object not available (run make all or set SCRUBBER_BPF_OBJ): %v", err)
+	}
+
+	n := harnessSeq.Add(1)
+	h := &harness{
+		t:      t,
+		ns:     fmt.Sprintf("scrubtest-%d-%d", os.Getpid(), n),
+		hostIf: fmt.Sprintf("sth%d", n),
+	}
+	peerIf := fmt.Sprintf("stp%d", n)
+
+	h.ip("netns", "add", h.ns)
+	t.Cleanup(func() { exec.Command("ip", "netns", "del", h.ns).Run() })
+	h.ip("link", "add", h.hostIf, "type", "veth", "peer", "name", peerIf)
+	t.Cleanup(func() { exec.Command("ip", "link", "del", h.hostIf).Run() })
+	h.ip("link", "set", peerIf, "netns", h.ns)
+	h.ip("addr", "add", hostIP.String()+"/24", "dev", h.hostIf)
+	h.ip("link", "set", h.hostIf, "up")
+	h.ip("-n", h.ns, "addr", "add", peerIP.String()+"/24", "dev", peerIf)
+	h.ip("-n", h.ns, "link", "set", peerIf, "up")
+
+	host, err := net.InterfaceByName(h.hostIf)
+	if err != nil {
+		t.Fatalf("host interface: %v", err)
+	}
+	h.hostMAC = host.HardwareAddr
+	h.openPeerSocket(peerIf)
+	t.Cleanup(func() { unix.Close(h.sock) })
+
+	dir := t.TempDir()
+	cfg := config.DefaultConfig()
+	cfg.Interface = h.hostIf
+	cfg.XDPMode = "skb"
+	if mode := os.Getenv("SCRUBBER_XDP_MODE"); mode != "" {
+		cfg.XDPMode = mode
+	}
+	cfg.BPFObject = obj
+	cfg.API.Listen = "unix:" + filepath.Join(dir, "api.sock")
+	cfg.Shutdown.StateDir = dir
+	if modify != nil {
+		modify(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("invalid test config: %v", err)
+	}
+
+	e := engine.New(zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel)), cfg)
+	if err := e.Start(context.Background()); err != nil {
+		t.Fatalf("starting engine: %v", err)
+	}
+	t.Cleanup(e.Stop)
+
+	sock, _ := cfg.API.UnixSocket()
+	h.api = &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", sock)
+			},
+		},
+	}
+	return h
+}
+
+// ip runs the ip command, failing the test on error.
+func (h *harness) ip(args ...string) {
+	h.t.Helper()
+	if out, err := exec.Command("ip", args...).CombinedOutput(); err != nil {
+		h.t.Fatalf("ip %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+}
+
+// openPeerSocket opens a packet socket on the namespace end of the pair.
+// The socket stays in the namespace it was created in, so only this
+// thread has to enter it.
+func (h *harness) openPeerSocket(peerIf string) {
+	h.t.Helper()
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	orig, err := os.Open("/proc/thread-self/ns/net")
+	if err != nil {
+		h.t.Fatalf("opening current netns: %v", err)
+	}
+	defer orig.Close()
+	target, err := os.Open(filepath.Join("/var/run/netns", h.ns))
+	if err != nil {
+		h.t.Fatalf("opening test netns: %v", err)
+	}
+	defer target.Close()
+
+	if err := unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+		h.t.Fatalf("entering test netns: %v", err)
+	}
+	defer func() {
+		if err := unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET); err != nil {
+			// The thread is unusable outside the namespace; don't
+			// return it to the scheduler.
+			panic(fmt.Sprintf("leaving test netns: %v", err))
+		}
+	}()
+
+	peer, err := net.InterfaceByName(peerIf)
+	if err != nil {
+		h.t.Fatalf("peer interface: %v", err)
+	}
+	h.peerMAC = peer.HardwareAddr
+
+	proto := htons(unix.ETH_P_ALL)
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, int(proto))
+	if err != nil {
+		h.t.Fatalf("opening packet socket: %v", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: proto, Ifindex: peer.Index}); err != nil {
+		unix.Close(fd)
+		h.t.Fatalf("binding packet socket: %v", err)
+	}
+	h.sock = fd
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// send transmits an IPv4 packet to the host end of the pair.
+func (h *harness) send(ip []byte) error {
+	_, err := unix.Write(h.sock, pktgen.Ethernet(h.hostMAC, h.peerMAC, ip))
+	return err
+}
+
+// sendFrame transmits a complete Ethernet frame, readdressed to the host
+// end of the pair.
+func (h *harness) sendFrame(f []byte) error {
+	if len(f) < 14 {
+		return nil
+	}
+	b := append([]byte(nil), f...)
+	copy(b[0:6], h.hostMAC)
+	copy(b[6:12], h.peerMAC)
+	_, err := unix.Write(h.sock, b)
+	return err
+}
+
+// flood sends packet(i) at pps for d and returns the number sent.
+// Packets are sent in 1ms batches, so rates beyond what the socket can
+// absorb are capped by the kernel rather than by the pacing.
+func (h *harness) flood(packet func(i int) []byte, pps int, d time.Duration) int {
+	h.t.Helper()
+	const tick = time.Millisecond
+	perTick := max(pps/int(time.Second/tick), 1)
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	deadline := time.Now().Add(d)
+	sent := 0
+	for time.Now().Before(deadline) {
+		for j := 0; j < perTick; j++ {
+			if err := h.send(packet(sent)); err != nil {
+				if err == unix.ENOBUFS {
+					continue
+				}
+				h.t.Fatalf("sending packet: %v", err)
+			}
+			sent++
+		}
+		<-ticker.C
+	}
+	return sent
+}
+
+// get decodes the JSON response of a GET request to the API.
+func (h *harness) get(path string, v interface{}) error {
+	resp, err := h.api.Get("http://scrubber" + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: HTTP %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// counters returns the cumulative counters of GET /api/v1/stats.
+func (h *harness) counters() map[string]float64 {
+	h.t.Helper()
+	var st map[string]float64
+	if err := h.get("/api/v1/stats", &st); err != nil {
+		h.t.Fatalf("reading stats: %v", err)
+	}
+	return st
+}
+
+// settle waits until the counters have been stable across two stats
+// collections, so drops still in flight are counted, and returns them.
+func (h *harness) settle() map[string]float64 {
+	h.t.Helper()
+	prev := h.counters()
+	for i := 0; i < 10; i++ {
+		time.Sleep(1100 * time.Millisecond)
+		cur := h.counters()
+		if cur["rxPackets"] == prev["rxPackets"] && cur["droppedPackets"] == prev["droppedPackets"] {
+			return cur
+		}
+		prev = cur
+	}
+	return prev
+}
+
+// eventually polls cond every 500ms until it holds or timeout expires.
+func (h *harness) eventually(timeout time.Duration, what string, cond func() bool) {
+	h.t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("timed out after %v waiting for %s", timeout, what)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// delta returns after[key]-before[key].
+func delta(before, after map[string]float64, keys ...string) float64 {
+	var d float64
+	for _, k := range keys {
+		d += after[k] - before[k]
+	}
+	return d
+}
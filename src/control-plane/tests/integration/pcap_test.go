@@ -0,0 +1,54 @@
+//go:build privileged
+
+package integration
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+const linkTypeEthernet = 1
+
+// readPcap returns the frames of a classic pcap file with Ethernet link
+// type, in either byte order and timestamp precision.
+func readPcap(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var hdr [24]byte
+	if _, err := io.ReadFull(f, hdr[:]); err != nil {
+		return nil, fmt.Errorf("reading pcap header: %w", err)
+	}
+	var order binary.ByteOrder
+	switch binary.LittleEndian.Uint32(hdr[0:]) {
+	case 0xa1b2c3d4, 0xa1b23c4d:
+		order = binary.LittleEndian
+	case 0xd4c3b2a1, 0x4d3cb2a1:
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("%s: not a pcap file", path)
+	}
+	if lt := order.Uint32(hdr[20:]); lt != linkTypeEthernet {
+		return nil, fmt.Errorf("%s: link type %d, want Ethernet", path, lt)
+	}
+
+	var frames [][]byte
+	for {
+		var rec [16]byte
+		if _, err := io.ReadFull(f, rec[:]); err == io.EOF {
+			return frames, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("reading pcap record: %w", err)
+		}
+		data := make([]byte, order.Uint32(rec[8:]))
+		if _, err := io.ReadFull(f, data); err != nil {
+			return nil, fmt.Errorf("reading pcap record: %w", err)
+		}
+		frames = append(frames, data)
+	}
+}
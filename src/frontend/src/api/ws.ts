@@ -1,16 +1,17 @@
 // WebSocket client for real-time stats and events streaming.
 
-import type { StatsSnapshot, ScrubberEvent } from '../types';
+import type { StatsSnapshot, ScrubberEvent, EscalationChange, WSSubscription } from '../types';
 
-export type WSMessageType = 'stats' | 'event';
+export type WSMessageType = 'stats' | 'event' | 'escalation' | 'subscribed' | 'error';
 
 export interface WSMessage {
   type: WSMessageType;
-  data: StatsSnapshot | ScrubberEvent;
+  data: StatsSnapshot | ScrubberEvent | EscalationChange | { message: string };
 }
 
 type StatsHandler = (stats: StatsSnapshot) => void;
 type EventHandler = (event: ScrubberEvent) => void;
+type EscalationHandler = (change: EscalationChange) => void;
 
 export class RealtimeClient {
   private ws: WebSocket | null = null;
@@ -19,6 +20,8 @@ export class RealtimeClient {
   private reconnectTimer: ReturnType<typeof setTimeout> | null = null;
   private statsHandlers: StatsHandler[] = [];
   private eventHandlers: EventHandler[] = [];
+  private escalationHandlers: EscalationHandler[] = [];
+  private subscription: WSSubscription | null = null;
   private _connected = false;
 
   constructor(url?: string) {
@@ -39,6 +42,7 @@ export class RealtimeClient {
       this.ws.onopen = () => {
         this._connected = true;
         console.log('[WS] connected');
+        if (this.subscription) this.sendSubscription();
         if (this.reconnectTimer) {
           clearTimeout(this.reconnectTimer);
           this.reconnectTimer = null;
@@ -84,6 +88,12 @@ export class RealtimeClient {
     this._connected = false;
   }
 
+  /** Select channels and event filters; re-sent after every reconnect. */
+  subscribe(sub: WSSubscription): void {
+    this.subscription = sub;
+    this.sendSubscription();
+  }
+
   onStats(handler: StatsHandler): () => void {
     this.statsHandlers.push(handler);
     return () => {
@@ -98,6 +108,18 @@ export class RealtimeClient {
     };
   }
 
+  onEscalation(handler: EscalationHandler): () => void {
+    this.escalationHandlers.push(handler);
+    return () => {
+      this.escalationHandlers = this.escalationHandlers.filter((h) => h !== handler);
+    };
+  }
+
+  private sendSubscription(): void {
+    if (this.ws?.readyState !== WebSocket.OPEN || !this.subscription) return;
+    this.ws.send(JSON.stringify({ action: 'subscribe', ...this.subscription }));
+  }
+
   private dispatch(msg: WSMessage): void {
     switch (msg.type) {
       case 'stats':
@@ -110,6 +132,14 @@ export class RealtimeClient {
           h(msg.data as ScrubberEvent);
         }
         break;
+      case 'escalation':
+        for (const h of this.escalationHandlers) {
+          h(msg.data as EscalationChange);
+        }
+        break;
+      case 'error':
+        console.warn('[WS] server error:', (msg.data as { message: string }).message);
+        break;
     }
   }
 
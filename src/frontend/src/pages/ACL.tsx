@@ -98,6 +98,18 @@ const ACLPage: React.FC = () => {
             width: 120,
             render: (r: number) => <Tag color="red">code {r}</Tag>,
           },
+          {
+            title: 'Expires',
+            dataIndex: 'ttlRemainingSec',
+            key: 'expires',
+            width: 140,
+            render: (ttl: number | undefined, record: ACLEntry) =>
+              record.expiresAt ? (
+                <Text title={record.expiresAt}>in {ttl ?? 0}s</Text>
+              ) : (
+                <Text type="secondary">never</Text>
+              ),
+          },
         ]
       : []),
     {
@@ -54,6 +54,10 @@ export interface StatsSnapshot {
   txBps: number;
   dropPps: number;
   dropBps: number;
+  synPps?: number;
+  udpPps?: number;
+  icmpPps?: number;
+  dnsPps?: number;
 }
 
 export interface ScrubberEvent {
@@ -73,6 +77,24 @@ export interface ScrubberEvent {
   escalationLevel?: number;
 }
 
+export interface EscalationChange {
+  timestampNs: number;
+  from: string;
+  to: string;
+  level: number;
+}
+
+export type WSChannel = 'stats' | 'events' | 'escalation';
+
+export interface WSSubscription {
+  channels?: WSChannel[];
+  filters?: {
+    attackTypes?: string[];
+    minPps?: number;
+    srcPrefixes?: string[];
+  };
+}
+
 export interface ScrubberStatus {
   enabled: boolean;
   interfaceName: string;
@@ -97,6 +119,8 @@ export interface ACLEntry {
   cidr: string;
   reason?: number;
   addedAt?: string;
+  expiresAt?: string;
+  ttlRemainingSec?: number;
 }
 
 export interface ConntrackInfo {
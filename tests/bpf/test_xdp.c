@@ -2,7 +2,8 @@
 /*
  * BPF XDP program test harness.
  * Uses bpf_prog_test_run_opts (BPF_PROG_TEST_RUN) to send crafted packets
- * through the loaded XDP program and verify verdicts.
+ * through the loaded XDP program, and the TC egress program, and verify
+ * verdicts.
  *
  * Compile: gcc -o test_xdp test_xdp.c -lbpf -lelf -lz
  * Run:     sudo ./test_xdp ../build/obj/xdp_ddos_scrubber.o
@@ -19,6 +20,7 @@
 #include <linux/tcp.h>
 #include <linux/udp.h>
 #include <linux/icmp.h>
+#include <linux/pkt_cls.h>
 #include <bpf/libbpf.h>
 #include <bpf/bpf.h>
 
@@ -120,7 +122,11 @@ static void build_icmp_echo(struct icmphdr *icmp)
 /* ===== BPF prog runner ===== */
 
 static int prog_fd = -1;
+static int egress_prog_fd = -1;
 static int config_map_fd = -1;
+static int egress_src_allow_fd = -1;
+static int egress_amp_ports_fd = -1;
+static int inspect_ports_fd = -1;
 
 static int run_xdp(void *pkt, __u32 pkt_len, __u32 *retval)
 {
@@ -138,6 +144,22 @@ static int run_xdp(void *pkt, __u32 pkt_len, __u32 *retval)
     return 0;
 }
 
+static int run_egress(void *pkt, __u32 pkt_len, __u32 *retval)
+{
+    LIBBPF_OPTS(bpf_test_run_opts, opts,
+        .data_in = pkt,
+        .data_size_in = pkt_len,
+        .repeat = 1,
+    );
+
+    int err = bpf_prog_test_run_opts(egress_prog_fd, &opts);
+    if (err < 0)
+        return err;
+
+    *retval = opts.retval;
+    return 0;
+}
+
 static int set_config(__u32 key, __u64 value)
 {
     return bpf_map_update_elem(config_map_fd, &key, &value, BPF_ANY);
@@ -325,6 +347,137 @@ int test_non_ipv4_drop(void)
     return retval == XDP_DROP ? TEST_PASS : TEST_FAIL;
 }
 
+/* Egress: only sources in egress_src_allow may leave when anti-spoofing is on */
+int test_egress_spoofed_drop(void)
+{
+    set_config(0, 1);
+    set_config(33 /* CFG_EGRESS_AMP */, 0);
+    set_config(32 /* CFG_EGRESS_SPOOF */, 1);
+
+    struct {
+        __u32 prefixlen;
+        __u32 addr;
+    } key = { .prefixlen = 24 };
+    __u8 one = 1;
+    inet_pton(AF_INET, "192.168.1.0", &key.addr);
+    if (bpf_map_update_elem(egress_src_allow_fd, &key, &one, BPF_ANY) < 0)
+        return TEST_FAIL;
+
+    struct test_pkt pkt = {};
+    build_eth(&pkt.eth, ETH_P_IP);
+    build_ip(&pkt.ip, IPPROTO_TCP, "192.168.1.1", "10.0.0.1",
+             sizeof(struct iphdr) + sizeof(struct tcphdr));
+    build_tcp_ack(&pkt.tcp, 80, 12345);
+
+    __u32 retval;
+    if (run_egress(&pkt, sizeof(pkt.eth) + sizeof(pkt.ip) + sizeof(pkt.tcp), &retval) < 0)
+        return TEST_FAIL;
+    if (retval != TC_ACT_OK)
+        return TEST_FAIL;
+
+    /* Same packet from an address outside the network */
+    inet_pton(AF_INET, "203.0.113.7", &pkt.ip.saddr);
+    if (run_egress(&pkt, sizeof(pkt.eth) + sizeof(pkt.ip) + sizeof(pkt.tcp), &retval) < 0)
+        return TEST_FAIL;
+
+    set_config(32, 0);
+    return retval == TC_ACT_SHOT ? TEST_PASS : TEST_FAIL;
+}
+
+/* Egress: responses from a port limited to 0 pps are dropped */
+int test_egress_amp_drop(void)
+{
+    set_config(0, 1);
+    set_config(32 /* CFG_EGRESS_SPOOF */, 0);
+    set_config(33 /* CFG_EGRESS_AMP */, 1);
+
+    __u16 port = 11211;
+    __u64 limit = 0;
+    if (bpf_map_update_elem(egress_amp_ports_fd, &port, &limit, BPF_ANY) < 0)
+        return TEST_FAIL;
+
+    char buf[1024];
+    memset(buf, 0, sizeof(buf));
+
+    struct ethhdr *eth = (void *)buf;
+    struct iphdr  *ip  = (void *)(eth + 1);
+    struct udphdr *udp = (void *)((char *)ip + 20);
+
+    build_eth(eth, ETH_P_IP);
+    build_ip(ip, IPPROTO_UDP, "192.168.1.1", "198.51.100.9", 20 + 8 + 900);
+    build_udp(udp, 11211, 12345, 8 + 900);
+
+    __u32 retval;
+    if (run_egress(buf, 14 + 20 + 8 + 900, &retval) < 0)
+        return TEST_FAIL;
+
+    set_config(33, 0);
+    return retval == TC_ACT_SHOT ? TEST_PASS : TEST_FAIL;
+}
+
+/* Deep inspection: a selected packet passes while no inspector socket is bound */
+int test_inspect_no_socket_passes(void)
+{
+    set_config(0, 1);
+    set_config(34 /* CFG_INSPECT_ENABLE */, 1);
+    set_config(35 /* CFG_INSPECT_SAMPLE */, 1);
+
+    __u16 port = 53;
+    __u8 kind = 1; /* INSPECT_DNS */
+    if (bpf_map_update_elem(inspect_ports_fd, &port, &kind, BPF_ANY) < 0)
+        return TEST_FAIL;
+
+    char buf[14 + 20 + 8 + 32];
+    memset(buf, 0, sizeof(buf));
+
+    struct ethhdr *eth = (void *)buf;
+    struct iphdr  *ip  = (void *)(eth + 1);
+    struct udphdr *udp = (void *)((char *)ip + 20);
+
+    build_eth(eth, ETH_P_IP);
+    build_ip(ip, IPPROTO_UDP, "10.0.0.1", "192.168.1.1", 20 + 8 + 32);
+    build_udp(udp, 40000, 53, 8 + 32);
+
+    __u32 retval;
+    if (run_xdp(buf, sizeof(buf), &retval) < 0)
+        return TEST_FAIL;
+
+    set_config(34, 0);
+    return retval == XDP_PASS ? TEST_PASS : TEST_FAIL;
+}
+
+/* Handshake floods: QUIC Initials beyond the per-source rate are dropped */
+int test_quic_flood_drop(void)
+{
+    set_config(0, 1);
+    set_config(38 /* CFG_QUIC_INIT_RATE */, 2);
+
+    char buf[14 + 20 + 8 + 64];
+    memset(buf, 0, sizeof(buf));
+
+    struct ethhdr *eth = (void *)buf;
+    struct iphdr  *ip  = (void *)(eth + 1);
+    struct udphdr *udp = (void *)((char *)ip + 20);
+    __u8 *quic = (void *)(udp + 1);
+
+    build_eth(eth, ETH_P_IP);
+    build_ip(ip, IPPROTO_UDP, "10.0.0.9", "192.168.1.1", 20 + 8 + 64);
+    build_udp(udp, 50000, 443, 8 + 64);
+    quic[0] = 0xc3;  /* Long header, Initial */
+    quic[4] = 0x01;  /* Version 1 */
+
+    __u32 retval;
+    for (int i = 0; i < 2; i++) {
+        if (run_xdp(buf, sizeof(buf), &retval) < 0 || retval != XDP_PASS)
+            return TEST_FAIL;
+    }
+    if (run_xdp(buf, sizeof(buf), &retval) < 0)
+        return TEST_FAIL;
+
+    set_config(38, 0);
+    return retval == XDP_DROP ? TEST_PASS : TEST_FAIL;
+}
+
 /* ===== Main ===== */
 
 int main(int argc, char **argv)
@@ -376,6 +529,39 @@ int main(int argc, char **argv)
     }
     config_map_fd = bpf_map__fd(map);
 
+    /* Find the egress program and its maps */
+    prog = bpf_object__find_program_by_name(obj, "tc_egress_scrubber");
+    if (!prog) {
+        fprintf(stderr, "Program 'tc_egress_scrubber' not found\n");
+        bpf_object__close(obj);
+        return 1;
+    }
+    egress_prog_fd = bpf_program__fd(prog);
+
+    map = bpf_object__find_map_by_name(obj, "egress_src_allow");
+    if (!map) {
+        fprintf(stderr, "Map 'egress_src_allow' not found\n");
+        bpf_object__close(obj);
+        return 1;
+    }
+    egress_src_allow_fd = bpf_map__fd(map);
+
+    map = bpf_object__find_map_by_name(obj, "egress_amp_ports");
+    if (!map) {
+        fprintf(stderr, "Map 'egress_amp_ports' not found\n");
+        bpf_object__close(obj);
+        return 1;
+    }
+    egress_amp_ports_fd = bpf_map__fd(map);
+
+    map = bpf_object__find_map_by_name(obj, "inspect_ports");
+    if (!map) {
+        fprintf(stderr, "Map 'inspect_ports' not found\n");
+        bpf_object__close(obj);
+        return 1;
+    }
+    inspect_ports_fd = bpf_map__fd(map);
+
     printf("Running tests...\n\n");
 
     /* ---- Run all tests ---- */
@@ -388,6 +574,10 @@ int main(int argc, char **argv)
     RUN_TEST(dns_amp_drop);
     RUN_TEST(ntp_amp_drop);
     RUN_TEST(non_ipv4_drop);
+    RUN_TEST(egress_spoofed_drop);
+    RUN_TEST(egress_amp_drop);
+    RUN_TEST(inspect_no_socket_passes);
+    RUN_TEST(quic_flood_drop);
 
     /* ---- Summary ---- */
     printf("\n=== Results: %d/%d passed", tests_passed, tests_run);